@@ -0,0 +1,96 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package arch
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// qemuArchitectures maps the architectures to the names qemu-user gives
+// them, which it registers its binfmt_misc handlers under.
+var qemuArchitectures = map[string]string{
+	"i386":    "i386",
+	"amd64":   "x86_64",
+	"armhf":   "arm",
+	"armel":   "arm",
+	"arm64":   "aarch64",
+	"ppc64el": "ppc64le",
+	"s390x":   "s390x",
+	"powerpc": "ppc",
+	"ppc64":   "ppc64",
+}
+
+var binfmtMiscDir = "/proc/sys/fs/binfmt_misc"
+
+// MockBinfmtMiscDir allows tests to use their own binfmt_misc handlers.
+func MockBinfmtMiscDir(dir string) (restore func()) {
+	old := binfmtMiscDir
+	binfmtMiscDir = dir
+	return func() { binfmtMiscDir = old }
+}
+
+// Emulator returns the interpreter registered with binfmt_misc, such as
+// qemu-arm-static, to run the binaries of the given architecture.
+//
+// The handler must have the fix-binary (F) flag, for the kernel to open
+// the interpreter when it is registered rather than when it is needed:
+// the interpreter is not to be found in the mount namespace of snaps.
+func Emulator(architecture string) (string, error) {
+	qemuArch, ok := qemuArchitectures[architecture]
+	if !ok {
+		return "", fmt.Errorf("cannot emulate unknown architecture %q", architecture)
+	}
+	f, err := os.Open(filepath.Join(binfmtMiscDir, "qemu-"+qemuArch))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no emulator for %s binaries is registered with binfmt_misc", architecture)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var enabled, fixBinary bool
+	var interpreter string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "enabled":
+			enabled = true
+		case strings.HasPrefix(line, "interpreter "):
+			interpreter = strings.TrimPrefix(line, "interpreter ")
+		case strings.HasPrefix(line, "flags: "):
+			fixBinary = strings.Contains(strings.TrimPrefix(line, "flags: "), "F")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	switch {
+	case !enabled:
+		return "", fmt.Errorf("the emulator for %s binaries is disabled", architecture)
+	case !fixBinary:
+		return "", fmt.Errorf("the emulator for %s binaries is not registered with the fix-binary (F) flag", architecture)
+	}
+	return interpreter, nil
+}
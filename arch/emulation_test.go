@@ -0,0 +1,83 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package arch
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type emulationSuite struct {
+	dir     string
+	restore func()
+}
+
+var _ = Suite(&emulationSuite{})
+
+func (s *emulationSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.restore = MockBinfmtMiscDir(s.dir)
+}
+
+func (s *emulationSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+func (s *emulationSuite) mockHandler(c *C, name, content string) {
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, name), []byte(content), 0644), IsNil)
+}
+
+func (s *emulationSuite) TestEmulator(c *C) {
+	s.mockHandler(c, "qemu-arm", `enabled
+interpreter /usr/bin/qemu-arm-static
+flags: OCF
+offset 0
+magic 7f454c4601010100000000000000000002002800
+mask ffffffffffffff00fffffffffffffffffeffffff
+`)
+	emulator, err := Emulator("armhf")
+	c.Assert(err, IsNil)
+	c.Check(emulator, Equals, "/usr/bin/qemu-arm-static")
+}
+
+func (s *emulationSuite) TestEmulatorErrors(c *C) {
+	s.mockHandler(c, "qemu-aarch64", `disabled
+interpreter /usr/bin/qemu-aarch64-static
+flags: OCF
+`)
+	s.mockHandler(c, "qemu-ppc64le", `enabled
+interpreter /usr/bin/qemu-ppc64le
+flags: OC
+`)
+
+	for _, t := range []struct {
+		arch string
+		err  string
+	}{
+		{"armhf", "no emulator for armhf binaries is registered with binfmt_misc"},
+		{"arm64", "the emulator for arm64 binaries is disabled"},
+		{"ppc64el", `the emulator for ppc64el binaries is not registered with the fix-binary \(F\) flag`},
+		{"sparc", `cannot emulate unknown architecture "sparc"`},
+	} {
+		_, err := Emulator(t.arch)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.arch))
+	}
+}
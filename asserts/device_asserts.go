@@ -86,6 +86,14 @@ func (mod *Model) Store() string {
 	return mod.HeaderString("store")
 }
 
+// RefreshMode returns how the devices of the model are allowed to
+// have their snaps refreshed: "managed" means auto-refreshes can be
+// disabled entirely in favour of an external agent, empty means the
+// default scheduled auto-refreshes only.
+func (mod *Model) RefreshMode() string {
+	return mod.HeaderString("refresh-mode")
+}
+
 // RequiredSnaps returns the snaps that must be installed at all times and cannot be removed for this model.
 func (mod *Model) RequiredSnaps() []string {
 	return mod.requiredSnaps
@@ -207,6 +215,15 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	// refresh-mode is optional, only "managed" is supported for now
+	refreshMode, err := checkOptionalString(assert.headers, "refresh-mode")
+	if err != nil {
+		return nil, err
+	}
+	if refreshMode != "" && refreshMode != "managed" {
+		return nil, fmt.Errorf(`"refresh-mode" header must be "managed" if set`)
+	}
+
 	reqSnaps, err := checkStringList(assert.headers, "required-snaps")
 	if err != nil {
 		return nil, err
@@ -138,6 +138,20 @@ func (mods *modelSuite) TestDecodeDisplayNameIsOptional(c *C) {
 	c.Check(model.DisplayName(), Equals, "baz-3000")
 }
 
+func (mods *modelSuite) TestDecodeRefreshModeIsOptional(c *C) {
+	withTimestamp := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
+	a, err := asserts.Decode([]byte(withTimestamp))
+	c.Assert(err, IsNil)
+	model := a.(*asserts.Model)
+	c.Check(model.RefreshMode(), Equals, "")
+
+	encoded := strings.Replace(withTimestamp, "store: brand-store\n", "store: brand-store\nrefresh-mode: managed\n", 1)
+	a, err = asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	model = a.(*asserts.Model)
+	c.Check(model.RefreshMode(), Equals, "managed")
+}
+
 func (mods *modelSuite) TestDecodeRequiredSnapsAreOptional(c *C) {
 	withTimestamp := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
 	encoded := strings.Replace(withTimestamp, reqSnaps, "", 1)
@@ -189,6 +203,8 @@ func (mods *modelSuite) TestDecodeInvalid(c *C) {
 		{"kernel: baz-linux\n", "", `"kernel" header is mandatory`},
 		{"kernel: baz-linux\n", "kernel: \n", `"kernel" header should not be empty`},
 		{"store: brand-store\n", "store:\n  - xyz\n", `"store" header must be a string`},
+		{"store: brand-store\n", "store: brand-store\nrefresh-mode:\n  - xyz\n", `"refresh-mode" header must be a string`},
+		{"store: brand-store\n", "store: brand-store\nrefresh-mode: sometimes\n", `"refresh-mode" header must be "managed" if set`},
 		{mods.tsLine, "", `"timestamp" header is mandatory`},
 		{mods.tsLine, "timestamp: \n", `"timestamp" header should not be empty`},
 		{mods.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
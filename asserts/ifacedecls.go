@@ -351,11 +351,23 @@ type OnClassicConstraint struct {
 	SystemIDs []string
 }
 
+// DeviceScopeConstraint specifies a constraint based on which store,
+// brand or model the device belongs to.
+type DeviceScopeConstraint struct {
+	Store []string
+	Brand []string
+	// Model is a list of brand-id/model-name pairs.
+	Model []string
+}
+
 // rules
 
 var (
 	validSnapType  = regexp.MustCompile("^(?:core|kernel|gadget|app)$")
 	validDistro    = regexp.MustCompile("^[-0-9a-z._]+$")
+	validStoreID   = regexp.MustCompile("^[-A-Za-z0-9_.]+$")
+	validBrandID   = regexp.MustCompile("^(?:[a-z0-9A-Z]{32}|[-a-z0-9]{2,28})$")
+	validOnModel   = regexp.MustCompile("^(?:[a-z0-9A-Z]{32}|[-a-z0-9]{2,28})/[a-zA-Z0-9](?:-?[a-zA-Z0-9])*$")
 	validSnapID    = regexp.MustCompile("^[a-z0-9A-Z]{32}$")                                        // snap-ids look like this
 	validPublisher = regexp.MustCompile("^(?:[a-z0-9A-Z]{32}|[-a-z0-9]{2,28}|\\$[A-Z][A-Z0-9_]*)$") // account ids look like snap-ids or are nice identifiers, support our own special markers $MARKER
 
@@ -388,6 +400,34 @@ type constraintsHolder interface {
 	setAttributeConstraints(field string, cstrs *AttributeConstraints)
 	setIDConstraints(field string, cstrs []string)
 	setOnClassicConstraint(onClassic *OnClassicConstraint)
+	setDeviceScopeConstraint(deviceScope *DeviceScopeConstraint)
+}
+
+var deviceScopeConstraints = []string{"on-store", "on-brand", "on-model"}
+
+// compileDeviceScopeConstraint compiles the on-store, on-brand and
+// on-model constraints, returning nil if none of them is set.
+func compileDeviceScopeConstraint(context string, cMap map[string]interface{}) (*DeviceScopeConstraint, error) {
+	var c DeviceScopeConstraint
+	var err error
+	for _, x := range []struct {
+		field   string
+		pattern *regexp.Regexp
+		target  *[]string
+	}{
+		{"on-store", validStoreID, &c.Store},
+		{"on-brand", validBrandID, &c.Brand},
+		{"on-model", validOnModel, &c.Model},
+	} {
+		*x.target, err = checkStringListInMap(cMap, x.field, fmt.Sprintf("%s in %s", x.field, context), x.pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Store == nil && c.Brand == nil && c.Model == nil {
+		return nil, nil
+	}
+	return &c, nil
 }
 
 func baseCompileConstraints(context string, cDef constraintsDef, target constraintsHolder, attrConstraints, idConstraints []string) error {
@@ -452,8 +492,17 @@ func baseCompileConstraints(context string, cDef constraintsDef, target constrai
 		}
 		target.setOnClassicConstraint(c)
 	}
-	if defaultUsed == len(attributeConstraints)+len(idConstraints)+1 {
-		return fmt.Errorf("%s must specify at least one of %s, %s, on-classic", context, strings.Join(attrConstraints, ", "), strings.Join(idConstraints, ", "))
+	deviceScope, err := compileDeviceScopeConstraint(context, cMap)
+	if err != nil {
+		return err
+	}
+	if deviceScope == nil {
+		defaultUsed++
+	} else {
+		target.setDeviceScopeConstraint(deviceScope)
+	}
+	if defaultUsed == len(attributeConstraints)+len(idConstraints)+2 {
+		return fmt.Errorf("%s must specify at least one of %s, %s, on-classic, %s", context, strings.Join(attrConstraints, ", "), strings.Join(idConstraints, ", "), strings.Join(deviceScopeConstraints, ", "))
 	}
 	return nil
 }
@@ -624,6 +673,8 @@ type PlugInstallationConstraints struct {
 	PlugAttributes *AttributeConstraints
 
 	OnClassic *OnClassicConstraint
+
+	DeviceScope *DeviceScopeConstraint
 }
 
 func (c *PlugInstallationConstraints) feature(flabel string) bool {
@@ -652,6 +703,10 @@ func (c *PlugInstallationConstraints) setOnClassicConstraint(onClassic *OnClassi
 	c.OnClassic = onClassic
 }
 
+func (c *PlugInstallationConstraints) setDeviceScopeConstraint(deviceScope *DeviceScopeConstraint) {
+	c.DeviceScope = deviceScope
+}
+
 func compilePlugInstallationConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	plugInstCstrs := &PlugInstallationConstraints{}
 	err := baseCompileConstraints(context, cDef, plugInstCstrs, []string{"plug-attributes"}, []string{"plug-snap-type"})
@@ -673,6 +728,8 @@ type PlugConnectionConstraints struct {
 	SlotAttributes *AttributeConstraints
 
 	OnClassic *OnClassicConstraint
+
+	DeviceScope *DeviceScopeConstraint
 }
 
 func (c *PlugConnectionConstraints) feature(flabel string) bool {
@@ -707,6 +764,10 @@ func (c *PlugConnectionConstraints) setOnClassicConstraint(onClassic *OnClassicC
 	c.OnClassic = onClassic
 }
 
+func (c *PlugConnectionConstraints) setDeviceScopeConstraint(deviceScope *DeviceScopeConstraint) {
+	c.DeviceScope = deviceScope
+}
+
 var (
 	attributeConstraints = []string{"plug-attributes", "slot-attributes"}
 	plugIDConstraints    = []string{"slot-snap-type", "slot-publisher-id", "slot-snap-id"}
@@ -856,6 +917,8 @@ type SlotInstallationConstraints struct {
 	SlotAttributes *AttributeConstraints
 
 	OnClassic *OnClassicConstraint
+
+	DeviceScope *DeviceScopeConstraint
 }
 
 func (c *SlotInstallationConstraints) feature(flabel string) bool {
@@ -884,6 +947,10 @@ func (c *SlotInstallationConstraints) setOnClassicConstraint(onClassic *OnClassi
 	c.OnClassic = onClassic
 }
 
+func (c *SlotInstallationConstraints) setDeviceScopeConstraint(deviceScope *DeviceScopeConstraint) {
+	c.DeviceScope = deviceScope
+}
+
 func compileSlotInstallationConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	slotInstCstrs := &SlotInstallationConstraints{}
 	err := baseCompileConstraints(context, cDef, slotInstCstrs, []string{"slot-attributes"}, []string{"slot-snap-type"})
@@ -905,6 +972,8 @@ type SlotConnectionConstraints struct {
 	PlugAttributes *AttributeConstraints
 
 	OnClassic *OnClassicConstraint
+
+	DeviceScope *DeviceScopeConstraint
 }
 
 func (c *SlotConnectionConstraints) feature(flabel string) bool {
@@ -943,6 +1012,10 @@ func (c *SlotConnectionConstraints) setOnClassicConstraint(onClassic *OnClassicC
 	c.OnClassic = onClassic
 }
 
+func (c *SlotConnectionConstraints) setDeviceScopeConstraint(deviceScope *DeviceScopeConstraint) {
+	c.DeviceScope = deviceScope
+}
+
 func compileSlotConnectionConstraints(context string, cDef constraintsDef) (constraintsHolder, error) {
 	slotConnCstrs := &SlotConnectionConstraints{}
 	err := baseCompileConstraints(context, cDef, slotConnCstrs, attributeConstraints, slotIDConstraints)
@@ -422,6 +422,18 @@ func (snaprev *SnapRevision) DeveloperID() string {
 	return snaprev.HeaderString("developer-id")
 }
 
+// VerityRootHash returns the root hash, hex encoded, of the dm-verity
+// data of the snap, if the store computed it.
+func (snaprev *SnapRevision) VerityRootHash() string {
+	return snaprev.HeaderString("snap-verity-root-hash")
+}
+
+// VeritySalt returns the salt, hex encoded, the dm-verity data of the snap
+// was computed with, if any.
+func (snaprev *SnapRevision) VeritySalt() string {
+	return snaprev.HeaderString("snap-verity-salt")
+}
+
 // Timestamp returns the time when the snap-revision was issued.
 func (snaprev *SnapRevision) Timestamp() time.Time {
 	return snaprev.timestamp
@@ -468,6 +480,12 @@ func (snaprev *SnapRevision) Prerequisites() []*Ref {
 	}
 }
 
+var (
+	// the root hash is a sha256 digest
+	validVerityRootHash = regexp.MustCompile("^[0-9a-f]{64}$")
+	validVeritySalt     = regexp.MustCompile("^(?:[0-9a-f]{2}){1,256}$")
+)
+
 func assembleSnapRevision(assert assertionBase) (Assertion, error) {
 	_, err := checkDigest(assert.headers, "snap-sha3-384", crypto.SHA3_384)
 	if err != nil {
@@ -492,6 +510,20 @@ func assembleSnapRevision(assert assertionBase) (Assertion, error) {
 		return nil, fmt.Errorf(`"snap-revision" header must be >=1: %d`, snapRevision)
 	}
 
+	if _, ok := assert.headers["snap-verity-root-hash"]; ok {
+		if _, err := checkStringMatches(assert.headers, "snap-verity-root-hash", validVerityRootHash); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := assert.headers["snap-verity-salt"]; ok {
+		if _, ok := assert.headers["snap-verity-root-hash"]; !ok {
+			return nil, fmt.Errorf(`"snap-verity-salt" header requires "snap-verity-root-hash"`)
+		}
+		if _, err := checkStringMatches(assert.headers, "snap-verity-salt", validVeritySalt); err != nil {
+			return nil, err
+		}
+	}
+
 	_, err = checkNotEmptyString(assert.headers, "developer-id")
 	if err != nil {
 		return nil, err
@@ -727,6 +727,24 @@ func (srs *snapRevSuite) TestDecodeOK(c *C) {
 	c.Check(snapRev.Revision(), Equals, 1)
 }
 
+func (srs *snapRevSuite) TestDecodeVerity(c *C) {
+	encoded := srs.makeValidEncoded()
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapRevision).VerityRootHash(), Equals, "")
+	c.Check(a.(*asserts.SnapRevision).VeritySalt(), Equals, "")
+
+	rootHash := strings.Repeat("0a", 32)
+	encoded = strings.Replace(encoded, "snap-revision: 1\n", "snap-revision: 1\n"+
+		"snap-verity-root-hash: "+rootHash+"\n"+
+		"snap-verity-salt: 00ff\n", 1)
+	a, err = asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	snapRev := a.(*asserts.SnapRevision)
+	c.Check(snapRev.VerityRootHash(), Equals, rootHash)
+	c.Check(snapRev.VeritySalt(), Equals, "00ff")
+}
+
 const (
 	snapRevErrPrefix = "assertion snap-revision: "
 )
@@ -756,6 +774,10 @@ func (srs *snapRevSuite) TestDecodeInvalid(c *C) {
 		{srs.tsLine, "", `"timestamp" header is mandatory`},
 		{srs.tsLine, "timestamp: \n", `"timestamp" header should not be empty`},
 		{srs.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+		{"snap-revision: 1\n", "snap-revision: 1\nsnap-verity-root-hash: \n", `"snap-verity-root-hash" header should not be empty`},
+		{"snap-revision: 1\n", "snap-revision: 1\nsnap-verity-root-hash: 0a0b\n", `"snap-verity-root-hash" header contains invalid characters: "0a0b"`},
+		{"snap-revision: 1\n", "snap-revision: 1\nsnap-verity-salt: 00\n", `"snap-verity-salt" header requires "snap-verity-root-hash"`},
+		{"snap-revision: 1\n", "snap-revision: 1\nsnap-verity-root-hash: " + strings.Repeat("0a", 32) + "\nsnap-verity-salt: 0\n", `"snap-verity-salt" header contains invalid characters: "0"`},
 	}
 
 	for _, test := range invalidTests {
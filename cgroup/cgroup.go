@@ -0,0 +1,254 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package cgroup finds the processes of snaps and freezes them, with
+// either the v1 freezer controller or the unified (v2) hierarchy,
+// matching what snap-confine does when it starts snap applications.
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	// Unknown is the version when no cgroup hierarchy could be found.
+	Unknown = 0
+	// V1 is the version of the legacy and hybrid hierarchies.
+	V1 = 1
+	// V2 is the version of the unified hierarchy.
+	V2 = 2
+)
+
+const cgroup2SuperMagic = 0x63677270
+
+var (
+	cgroupMountPoint  = "/sys/fs/cgroup"
+	freezerMountPoint = "/sys/fs/cgroup/freezer"
+	unifiedMountPoint = "/sys/fs/cgroup/unified"
+)
+
+var syscallStatfs = syscall.Statfs
+
+// ErrNoFreezer is returned when there is no way to freeze the
+// processes of a snap on this system.
+var ErrNoFreezer = errors.New("cannot freeze processes: no freezer cgroup available")
+
+var probeVersion = probeVersionImpl
+
+func probeVersionImpl() (int, error) {
+	var fs syscall.Statfs_t
+	path := filepath.Join(dirs.GlobalRootDir, cgroupMountPoint)
+	if err := syscallStatfs(path, &fs); err != nil {
+		if os.IsNotExist(err) {
+			return Unknown, nil
+		}
+		return Unknown, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	if fs.Type == cgroup2SuperMagic {
+		return V2, nil
+	}
+	return V1, nil
+}
+
+// Version returns the version of the cgroup hierarchy snap-confine
+// tracks the processes of snaps in.
+func Version() (int, error) {
+	return probeVersion()
+}
+
+// MockVersion makes the system believe it uses the given version of
+// the cgroup hierarchy.
+func MockVersion(version int, err error) (restore func()) {
+	old := probeVersion
+	probeVersion = func() (int, error) { return version, err }
+	return func() {
+		probeVersion = old
+	}
+}
+
+// freezerDirs returns the freezer groups of the given snap, and the
+// file listing their processes, for the given version. With cgroup v2
+// snap-confine creates the group of the snap below the group of the
+// session or service that started it, so there can be several of them
+// anywhere in the hierarchy. It returns no file if the processes of
+// snaps are not tracked on this system.
+func freezerDirs(version int, snapName string) (groups []string, procs string, err error) {
+	group := "snap." + snapName
+	switch version {
+	case V2:
+		groups, err := findGroups(filepath.Join(dirs.GlobalRootDir, cgroupMountPoint), group)
+		return groups, "cgroup.procs", err
+	case V1:
+		freezer := filepath.Join(dirs.GlobalRootDir, freezerMountPoint)
+		if !osutil.IsDirectory(freezer) {
+			break
+		}
+		dir := filepath.Join(freezer, group)
+		if !osutil.IsDirectory(dir) {
+			// nothing of the snap was ever run
+			return nil, "tasks", nil
+		}
+		return []string{dir}, "tasks", nil
+	}
+	return nil, "", nil
+}
+
+// findGroups returns all the groups with the given name below root.
+func findGroups(root, name string) ([]string, error) {
+	var found []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// groups come and go as processes exit
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == name && path != root {
+			found = append(found, path)
+			// nested groups are part of this one
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// PidsOfSnap returns the pids of the processes of the given snap
+// that are tracked in its freezer groups. It returns no pids, and no
+// error, if the processes of snaps cannot be tracked on this system.
+func PidsOfSnap(snapName string) ([]int, error) {
+	version, err := Version()
+	if err != nil {
+		return nil, err
+	}
+	groups, procs, err := freezerDirs(version, snapName)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, dir := range groups {
+		data, err := ioutil.ReadFile(filepath.Join(dir, procs))
+		if os.IsNotExist(err) {
+			// the group went away in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Fields(string(data)) {
+			pid, err := strconv.Atoi(line)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse pid %q of snap %q", line, snapName)
+			}
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func writeFreezerState(snapName string, frozen bool) error {
+	version, err := Version()
+	if err != nil {
+		return err
+	}
+	groups, procs, err := freezerDirs(version, snapName)
+	if err != nil {
+		return err
+	}
+	if procs == "" {
+		return ErrNoFreezer
+	}
+	var file, content string
+	switch {
+	case version == V2 && frozen:
+		file, content = "cgroup.freeze", "1"
+	case version == V2:
+		file, content = "cgroup.freeze", "0"
+	case frozen:
+		file, content = "freezer.state", "FROZEN"
+	default:
+		file, content = "freezer.state", "THAWED"
+	}
+	for _, dir := range groups {
+		path := filepath.Join(dir, file)
+		if version == V2 && !osutil.FileExists(path) {
+			// kernels before 5.2 have cgroup v2 but cannot freeze
+			return ErrNoFreezer
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FreezeSnapProcesses freezes all the processes of the given snap, so
+// that it can be refreshed without them observing the change. It
+// returns ErrNoFreezer if freezing is not possible on this system.
+func FreezeSnapProcesses(snapName string) error {
+	return writeFreezerState(snapName, true)
+}
+
+// ThawSnapProcesses thaws the processes of the given snap frozen with
+// FreezeSnapProcesses.
+func ThawSnapProcesses(snapName string) error {
+	return writeFreezerState(snapName, false)
+}
+
+// Features returns the cgroup related sandbox features of the system,
+// for reporting: the version of the hierarchy, whether the unified
+// hierarchy is mounted alongside the v1 one, and how processes are
+// tracked and frozen.
+func Features() []string {
+	version, err := Version()
+	if err != nil {
+		return nil
+	}
+	var features []string
+	switch version {
+	case V2:
+		features = append(features, "v2", "freezer-v2")
+	case V1:
+		features = append(features, "v1")
+		if osutil.IsDirectory(filepath.Join(dirs.GlobalRootDir, unifiedMountPoint)) {
+			features = append(features, "hybrid")
+		}
+		if osutil.IsDirectory(filepath.Join(dirs.GlobalRootDir, freezerMountPoint)) {
+			features = append(features, "freezer-v1")
+		}
+	}
+	return features
+}
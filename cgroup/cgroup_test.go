@@ -0,0 +1,206 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cgroup"
+	"github.com/snapcore/snapd/dirs"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type cgroupSuite struct {
+	rootDir string
+}
+
+var _ = Suite(&cgroupSuite{})
+
+func (s *cgroupSuite) SetUpTest(c *C) {
+	s.rootDir = c.MkDir()
+	dirs.SetRootDir(s.rootDir)
+}
+
+func (s *cgroupSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *cgroupSuite) mkdir(c *C, path string) string {
+	dir := filepath.Join(s.rootDir, path)
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	return dir
+}
+
+func readFile(c *C, path string) string {
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	return string(data)
+}
+
+func (s *cgroupSuite) TestProbeVersion(c *C) {
+	for _, t := range []struct {
+		unified bool
+		err     error
+		version int
+	}{
+		{true, nil, cgroup.V2},
+		{false, nil, cgroup.V1},
+		{false, syscall.ENOENT, cgroup.Unknown},
+	} {
+		restore := cgroup.MockSyscallStatfs(func(path string, buf *syscall.Statfs_t) error {
+			c.Check(path, Equals, filepath.Join(s.rootDir, "/sys/fs/cgroup"))
+			if t.unified {
+				buf.Type = 0x63677270
+			} else {
+				// tmpfs, as for the legacy and hybrid hierarchies
+				buf.Type = 0x1021994
+			}
+			return t.err
+		})
+		version, err := cgroup.ProbeVersion()
+		restore()
+		c.Assert(err, IsNil)
+		c.Check(version, Equals, t.version)
+	}
+
+	restore := cgroup.MockSyscallStatfs(func(path string, buf *syscall.Statfs_t) error {
+		return syscall.EPERM
+	})
+	defer restore()
+	_, err := cgroup.ProbeVersion()
+	c.Check(err, ErrorMatches, `statfs .*/sys/fs/cgroup: operation not permitted`)
+}
+
+func (s *cgroupSuite) TestPidsOfSnapV1(c *C) {
+	restore := cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+
+	// no freezer controller, no tracking
+	pids, err := cgroup.PidsOfSnap("foo")
+	c.Assert(err, IsNil)
+	c.Check(pids, HasLen, 0)
+
+	s.mkdir(c, "/sys/fs/cgroup/freezer")
+	pids, err = cgroup.PidsOfSnap("foo")
+	c.Assert(err, IsNil)
+	c.Check(pids, HasLen, 0)
+
+	dir := s.mkdir(c, "/sys/fs/cgroup/freezer/snap.foo")
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "tasks"), []byte("123\n456\n"), 0644), IsNil)
+	pids, err = cgroup.PidsOfSnap("foo")
+	c.Assert(err, IsNil)
+	c.Check(pids, DeepEquals, []int{123, 456})
+}
+
+func (s *cgroupSuite) TestPidsOfSnapV2(c *C) {
+	restore := cgroup.MockVersion(cgroup.V2, nil)
+	defer restore()
+
+	pids, err := cgroup.PidsOfSnap("foo")
+	c.Assert(err, IsNil)
+	c.Check(pids, HasLen, 0)
+
+	dir := s.mkdir(c, "/sys/fs/cgroup/user.slice/user-1000.slice/session-2.scope/snap.foo")
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte("789\n"), 0644), IsNil)
+	pids, err = cgroup.PidsOfSnap("foo")
+	c.Assert(err, IsNil)
+	c.Check(pids, DeepEquals, []int{789})
+
+	// the groups of the snap in all the delegated subtrees are found,
+	// but not those of other snaps
+	other := s.mkdir(c, "/sys/fs/cgroup/system.slice/snap.foo.svc.service/snap.foo")
+	c.Assert(ioutil.WriteFile(filepath.Join(other, "cgroup.procs"), []byte("42\n"), 0644), IsNil)
+	bar := s.mkdir(c, "/sys/fs/cgroup/system.slice/snap.bar.svc.service/snap.bar")
+	c.Assert(ioutil.WriteFile(filepath.Join(bar, "cgroup.procs"), []byte("24\n"), 0644), IsNil)
+	pids, err = cgroup.PidsOfSnap("foo")
+	c.Assert(err, IsNil)
+	c.Check(pids, DeepEquals, []int{42, 789})
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte("potato\n"), 0644), IsNil)
+	_, err = cgroup.PidsOfSnap("foo")
+	c.Check(err, ErrorMatches, `cannot parse pid "potato" of snap "foo"`)
+}
+
+func (s *cgroupSuite) TestFreezeThawV1(c *C) {
+	restore := cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+
+	c.Check(cgroup.FreezeSnapProcesses("foo"), Equals, cgroup.ErrNoFreezer)
+
+	s.mkdir(c, "/sys/fs/cgroup/freezer")
+	// nothing to freeze
+	c.Check(cgroup.FreezeSnapProcesses("foo"), IsNil)
+
+	dir := s.mkdir(c, "/sys/fs/cgroup/freezer/snap.foo")
+	c.Assert(cgroup.FreezeSnapProcesses("foo"), IsNil)
+	c.Check(readFile(c, filepath.Join(dir, "freezer.state")), Equals, "FROZEN")
+	c.Assert(cgroup.ThawSnapProcesses("foo"), IsNil)
+	c.Check(readFile(c, filepath.Join(dir, "freezer.state")), Equals, "THAWED")
+}
+
+func (s *cgroupSuite) TestFreezeThawV2(c *C) {
+	restore := cgroup.MockVersion(cgroup.V2, nil)
+	defer restore()
+
+	// nothing to freeze
+	c.Check(cgroup.FreezeSnapProcesses("foo"), IsNil)
+
+	dir := s.mkdir(c, "/sys/fs/cgroup/user.slice/user-1000.slice/session-2.scope/snap.foo")
+	// no cgroup.freeze, as with kernels before 5.2
+	c.Check(cgroup.FreezeSnapProcesses("foo"), Equals, cgroup.ErrNoFreezer)
+
+	other := s.mkdir(c, "/sys/fs/cgroup/system.slice/snap.foo.svc.service/snap.foo")
+	for _, d := range []string{dir, other} {
+		c.Assert(ioutil.WriteFile(filepath.Join(d, "cgroup.freeze"), []byte("0"), 0644), IsNil)
+	}
+	c.Assert(cgroup.FreezeSnapProcesses("foo"), IsNil)
+	c.Check(readFile(c, filepath.Join(dir, "cgroup.freeze")), Equals, "1")
+	c.Check(readFile(c, filepath.Join(other, "cgroup.freeze")), Equals, "1")
+	c.Assert(cgroup.ThawSnapProcesses("foo"), IsNil)
+	c.Check(readFile(c, filepath.Join(dir, "cgroup.freeze")), Equals, "0")
+	c.Check(readFile(c, filepath.Join(other, "cgroup.freeze")), Equals, "0")
+}
+
+func (s *cgroupSuite) TestFeatures(c *C) {
+	restore := cgroup.MockVersion(cgroup.V2, nil)
+	defer restore()
+	c.Check(cgroup.Features(), DeepEquals, []string{"v2", "freezer-v2"})
+
+	restore = cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+	c.Check(cgroup.Features(), DeepEquals, []string{"v1"})
+
+	s.mkdir(c, "/sys/fs/cgroup/unified")
+	c.Check(cgroup.Features(), DeepEquals, []string{"v1", "hybrid"})
+
+	s.mkdir(c, "/sys/fs/cgroup/freezer")
+	c.Check(cgroup.Features(), DeepEquals, []string{"v1", "hybrid", "freezer-v1"})
+
+	restore = cgroup.MockVersion(cgroup.Unknown, nil)
+	defer restore()
+	c.Check(cgroup.Features(), HasLen, 0)
+}
@@ -35,6 +35,7 @@ type Change struct {
 	Status  string  `json:"status"`
 	Tasks   []*Task `json:"tasks,omitempty"`
 	Ready   bool    `json:"ready"`
+	Paused  bool    `json:"paused,omitempty"`
 	Err     string  `json:"err,omitempty"`
 
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
@@ -92,10 +93,25 @@ func (client *Client) Change(id string) (*Change, error) {
 
 // Abort attempts to abort a change that is in not yet ready.
 func (client *Client) Abort(id string) (*Change, error) {
+	return client.changeAction(id, "abort")
+}
+
+// Pause asks for a change that is not yet ready to not start any
+// further tasks until it is resumed.
+func (client *Client) Pause(id string) (*Change, error) {
+	return client.changeAction(id, "pause")
+}
+
+// Resume lets a paused change carry on with its pending tasks.
+func (client *Client) Resume(id string) (*Change, error) {
+	return client.changeAction(id, "resume")
+}
+
+func (client *Client) changeAction(id, action string) (*Change, error) {
 	var postData struct {
 		Action string `json:"action"`
 	}
-	postData.Action = "abort"
+	postData.Action = action
 
 	var body bytes.Buffer
 	if err := json.NewEncoder(&body).Encode(postData); err != nil {
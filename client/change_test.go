@@ -20,6 +20,8 @@
 package client_test
 
 import (
+	"fmt"
+
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
@@ -213,3 +215,42 @@ func (cs *clientSuite) TestClientAbort(c *check.C) {
 
 	c.Assert(string(body), check.Equals, "{\"action\":\"abort\"}\n")
 }
+
+func (cs *clientSuite) TestClientPauseResume(c *check.C) {
+	for _, t := range []struct {
+		action string
+		do     func(id string) (*client.Change, error)
+		paused bool
+	}{
+		{"pause", cs.cli.Pause, true},
+		{"resume", cs.cli.Resume, false},
+	} {
+		cs.rsp = fmt.Sprintf(`{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Doing",
+  "ready": false,
+  "paused": %v,
+  "spawn-time": "2016-04-21T01:02:03Z"
+}}`, t.paused)
+
+		chg, err := t.do("uno")
+		c.Assert(err, check.IsNil)
+		c.Check(cs.req.Method, check.Equals, "POST")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/changes/uno")
+		c.Check(chg, check.DeepEquals, &client.Change{
+			ID:      "uno",
+			Kind:    "foo",
+			Summary: "...",
+			Status:  "Doing",
+			Paused:  t.paused,
+
+			SpawnTime: time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC),
+		})
+
+		body, err := ioutil.ReadAll(cs.req.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(string(body), check.Equals, fmt.Sprintf("{\"action\":%q}\n", t.action))
+	}
+}
@@ -66,6 +66,10 @@ type Config struct {
 
 	// Socket is the path to the unix socket to use
 	Socket string
+
+	// Token is a token created with CreateToken to authorize the
+	// requests with, instead of the auth.json data.
+	Token string
 }
 
 // A Client knows how to talk to the snappy daemon.
@@ -75,6 +79,7 @@ type Client struct {
 
 	disableAuth bool
 	interactive bool
+	token       string
 }
 
 // New returns a new instance of Client
@@ -95,6 +100,7 @@ func New(config *Config) *Client {
 			},
 			disableAuth: config.DisableAuth,
 			interactive: config.Interactive,
+			token:       config.Token,
 		}
 	}
 
@@ -107,6 +113,7 @@ func New(config *Config) *Client {
 		doer:        &http.Client{},
 		disableAuth: config.DisableAuth,
 		interactive: config.Interactive,
+		token:       config.Token,
 	}
 }
 
@@ -123,6 +130,11 @@ func (client *Client) WhoAmI() (string, error) {
 }
 
 func (client *Client) setAuthorization(req *http.Request) error {
+	if client.token != "" {
+		req.Header.Set("Authorization", "Token "+client.token)
+		return nil
+	}
+
 	user, err := readAuthData()
 	if os.IsNotExist(err) {
 		return nil
@@ -338,6 +350,7 @@ type response struct {
 	StatusCode int             `json:"status-code"`
 	Type       string          `json:"type"`
 	Change     string          `json:"change"`
+	Job        string          `json:"job"`
 
 	ResultInfo
 }
@@ -374,6 +387,8 @@ const (
 	ErrorKindNoUpdateAvailable      = "snap-no-update-available"
 
 	ErrorKindNotSnap = "snap-not-a-snap"
+
+	ErrorKindSnapHasDependents = "snap-has-dependents"
 )
 
 // IsTwoFactorError returns whether the given error is due to problems
@@ -411,6 +426,8 @@ type SysInfo struct {
 
 	Refresh     RefreshInfo `json:"refresh,omitempty"`
 	Confinement string      `json:"confinement"`
+
+	SandboxFeatures map[string][]string `json:"sandbox-features,omitempty"`
 }
 
 func (rsp *response) err() error {
@@ -574,3 +591,17 @@ func (client *Client) Debug(action string, params interface{}, result interface{
 	_, err = client.doSync("POST", "/v2/debug", nil, nil, bytes.NewReader(body), result)
 	return err
 }
+
+// DebugProfile returns the content of the profile of the daemon with the
+// given name, as captured by the "profile" debug action.
+func (client *Client) DebugProfile(name string) (io.ReadCloser, error) {
+	rsp, err := client.raw("GET", "/v2/debug/profiles/"+name, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != 200 {
+		defer rsp.Body.Close()
+		return nil, parseError(rsp)
+	}
+	return rsp.Body, nil
+}
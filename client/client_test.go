@@ -170,6 +170,22 @@ func (cs *clientSuite) TestClientHonorsDisableAuth(c *C) {
 	c.Check(authorization, Equals, "")
 }
 
+func (cs *clientSuite) TestClientSetsTokenAuthorization(c *C) {
+	mockUserData := client.User{
+		Macaroon:   "macaroon",
+		Discharges: []string{"discharge"},
+	}
+	err := client.TestWriteAuth(mockUserData)
+	c.Assert(err, IsNil)
+
+	var v string
+	cli := client.New(&client.Config{Token: "s3cr3t"})
+	cli.SetDoer(cs)
+	_ = cli.Do("GET", "/this", nil, nil, &v)
+	authorization := cs.req.Header.Get("Authorization")
+	c.Check(authorization, Equals, "Token s3cr3t")
+}
+
 func (cs *clientSuite) TestClientHonorsInteractive(c *C) {
 	var v string
 	cli := client.New(&client.Config{Interactive: false})
@@ -216,7 +232,8 @@ func (cs *clientSuite) TestClientSysInfo(c *C) {
                       "version": "2",
                       "os-release": {"id": "ubuntu", "version-id": "16.04"},
                       "on-classic": true,
-                      "confinement": "strict"}}`
+                      "confinement": "strict",
+                      "sandbox-features": {"cgroup": ["v1", "hybrid", "freezer-v1"]}}}`
 	sysInfo, err := cs.cli.SysInfo()
 	c.Check(err, IsNil)
 	c.Check(sysInfo, DeepEquals, &client.SysInfo{
@@ -228,6 +245,9 @@ func (cs *clientSuite) TestClientSysInfo(c *C) {
 		},
 		OnClassic:   true,
 		Confinement: "strict",
+		SandboxFeatures: map[string][]string{
+			"cgroup": {"v1", "hybrid", "freezer-v1"},
+		},
 	})
 }
 
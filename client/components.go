@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+)
+
+// ComponentOptions represents the options for installing a component.
+type ComponentOptions struct {
+	Dangerous bool `json:"dangerous,omitempty"`
+}
+
+// InstallComponentPath sideloads the component with the given path,
+// returning the UUID of the background operation upon success.
+func (client *Client) InstallComponentPath(path string, options *ComponentOptions) (changeID string, err error) {
+	return client.sideloadComponent("install", path, options)
+}
+
+// RefreshComponentPath refreshes an installed component from the file
+// with the given path, returning the UUID of the background operation
+// upon success.
+func (client *Client) RefreshComponentPath(path string, options *ComponentOptions) (changeID string, err error) {
+	return client.sideloadComponent("refresh", path, options)
+}
+
+func (client *Client) sideloadComponent(action, path string, options *ComponentOptions) (changeID string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open: %q", path)
+	}
+	if options == nil {
+		options = &ComponentOptions{}
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go sendComponentFile(action, path, f, pw, mw, options)
+
+	headers := map[string]string{
+		"Content-Type": mw.FormDataContentType(),
+	}
+
+	return client.doAsync("POST", "/v2/components", nil, headers, pr)
+}
+
+func sendComponentFile(action, compPath string, compFile *os.File, pw *io.PipeWriter, mw *multipart.Writer, options *ComponentOptions) {
+	defer compFile.Close()
+
+	if err := mw.WriteField("action", action); err != nil {
+		pw.CloseWithError(err)
+		return
+	}
+	if options.Dangerous {
+		if err := mw.WriteField("dangerous", "true"); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
+	fw, err := mw.CreateFormFile("component", filepath.Base(compPath))
+	if err != nil {
+		pw.CloseWithError(err)
+		return
+	}
+
+	if _, err := io.Copy(fw, compFile); err != nil {
+		pw.CloseWithError(err)
+		return
+	}
+
+	mw.Close()
+	pw.Close()
+}
+
+type componentAction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+// RemoveComponent removes the component with the given
+// <snap>+<component> name, returning the UUID of the background
+// operation upon success.
+func (client *Client) RemoveComponent(name string) (changeID string, err error) {
+	data, err := json.Marshal(&componentAction{Action: "remove", Name: name})
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal component action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	return client.doAsync("POST", "/v2/components", nil, headers, bytes.NewBuffer(data))
+}
@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) testClientSideloadComponent(c *check.C, action string, sideload func(string, *client.ComponentOptions) (string, error)) {
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	comp := filepath.Join(c.MkDir(), "foo+debug.comp")
+	err := ioutil.WriteFile(comp, []byte("comp-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	id, err := sideload(comp, &client.ComponentOptions{Dangerous: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "66b3")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"action\"\r\n\r\n"+action+"\r\n.*")
+	c.Check(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"dangerous\"\r\n\r\ntrue\r\n.*")
+	c.Check(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"component\"; filename=\"foo\\+debug.comp\"\r\n.*\r\ncomp-data\r\n.*")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/components")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Matches, "multipart/form-data; boundary=.*")
+}
+
+func (cs *clientSuite) TestClientInstallComponentPath(c *check.C) {
+	cs.testClientSideloadComponent(c, "install", cs.cli.InstallComponentPath)
+}
+
+func (cs *clientSuite) TestClientRefreshComponentPath(c *check.C) {
+	cs.testClientSideloadComponent(c, "refresh", cs.cli.RefreshComponentPath)
+}
+
+func (cs *clientSuite) TestClientInstallComponentPathMissingFile(c *check.C) {
+	_, err := cs.cli.InstallComponentPath("/does/not/exist", nil)
+	c.Check(err, check.ErrorMatches, `cannot open: "/does/not/exist"`)
+}
+
+func (cs *clientSuite) TestClientRemoveComponent(c *check.C) {
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RemoveComponent("foo+debug")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "66b3")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/components")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "remove",
+		"name":   "foo+debug",
+	})
+}
@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ConsoleConfInfo holds the state of console-conf, the first-boot
+// configuration tool of Ubuntu Core.
+type ConsoleConfInfo struct {
+	// Status is one of "pending", "started", "completed" or "disabled".
+	Status string `json:"status"`
+}
+
+// ConsoleConf returns the current state of console-conf.
+func (client *Client) ConsoleConf() (*ConsoleConfInfo, error) {
+	var info ConsoleConfInfo
+	if _, err := client.doSync("GET", "/v2/console-conf", nil, nil, nil, &info); err != nil {
+		return nil, fmt.Errorf("cannot get console-conf status: %v", err)
+	}
+	return &info, nil
+}
+
+type consoleConfAction struct {
+	Action string `json:"action"`
+}
+
+// ConsoleConfAction performs the given action, one of "start", "stop"
+// or "disable", on console-conf and returns its resulting state.
+func (client *Client) ConsoleConfAction(action string) (*ConsoleConfInfo, error) {
+	b, err := json.Marshal(consoleConfAction{Action: action})
+	if err != nil {
+		return nil, err
+	}
+
+	var info ConsoleConfInfo
+	if _, err := client.doSync("POST", "/v2/console-conf", nil, nil, bytes.NewReader(b), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientConsoleConf(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"status": "started"}}`
+	info, err := cs.cli.ConsoleConf()
+	c.Assert(err, check.IsNil)
+	c.Check(info, check.DeepEquals, &client.ConsoleConfInfo{Status: "started"})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/console-conf")
+}
+
+func (cs *clientSuite) TestClientConsoleConfAction(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"status": "disabled"}}`
+	info, err := cs.cli.ConsoleConfAction("disable")
+	c.Assert(err, check.IsNil)
+	c.Check(info, check.DeepEquals, &client.ConsoleConfInfo{Status: "disabled"})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/console-conf")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "disable",
+	})
+}
+
+func (cs *clientSuite) TestClientConsoleConfActionError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 400, "result": {"message": "cannot start console-conf: console-conf is disabled"}}`
+	_, err := cs.cli.ConsoleConfAction("start")
+	c.Check(err, check.ErrorMatches, "cannot start console-conf: console-conf is disabled")
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // Plug represents the potential of a given snap to connect to a slot.
@@ -34,6 +35,8 @@ type Plug struct {
 	Attrs       map[string]interface{} `json:"attrs,omitempty"`
 	Apps        []string               `json:"apps,omitempty"`
 	Label       string                 `json:"label,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Category    string                 `json:"category,omitempty"`
 	Connections []SlotRef              `json:"connections,omitempty"`
 }
 
@@ -51,6 +54,8 @@ type Slot struct {
 	Attrs       map[string]interface{} `json:"attrs,omitempty"`
 	Apps        []string               `json:"apps,omitempty"`
 	Label       string                 `json:"label,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Category    string                 `json:"category,omitempty"`
 	Connections []PlugRef              `json:"connections,omitempty"`
 }
 
@@ -66,6 +71,20 @@ type Connections struct {
 	Slots []Slot `json:"slots"`
 }
 
+// InterfaceAttr describes an attribute of the plugs, slots or
+// connections of an interface.
+type InterfaceAttr struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Path        bool     `json:"path,omitempty"`
+	Patterns    string   `json:"patterns,omitempty"`
+	Min         *int64   `json:"min,omitempty"`
+	Max         *int64   `json:"max,omitempty"`
+}
+
 // Interface holds information about a given interface and its instances.
 type Interface struct {
 	Name    string `json:"name,omitempty"`
@@ -73,13 +92,25 @@ type Interface struct {
 	DocURL  string `json:"doc-url,omitempty"`
 	Plugs   []Plug `json:"plugs,omitempty"`
 	Slots   []Slot `json:"slots,omitempty"`
+	// GreedyPlugs tells that plugs can be connected to several slots
+	GreedyPlugs bool `json:"greedy-plugs,omitempty"`
+	// Deprecated interfaces should be replaced by ReplacedBy, when set
+	Deprecated bool   `json:"deprecated,omitempty"`
+	ReplacedBy string `json:"replaced-by,omitempty"`
+	// The attributes are only described when asking for documentation
+	PlugAttrs       []InterfaceAttr `json:"plug-attrs,omitempty"`
+	SlotAttrs       []InterfaceAttr `json:"slot-attrs,omitempty"`
+	ConnectionAttrs []InterfaceAttr `json:"connection-attrs,omitempty"`
 }
 
 // InterfaceAction represents an action performed on the interface system.
 type InterfaceAction struct {
-	Action string `json:"action"`
-	Plugs  []Plug `json:"plugs,omitempty"`
-	Slots  []Slot `json:"slots,omitempty"`
+	Action string                 `json:"action"`
+	Plugs  []Plug                 `json:"plugs,omitempty"`
+	Slots  []Slot                 `json:"slots,omitempty"`
+	Snap   string                 `json:"snap,omitempty"`
+	Attrs  map[string]interface{} `json:"attrs,omitempty"`
+	DryRun bool                   `json:"dry-run,omitempty"`
 }
 
 // Connections returns all plugs, slots and their connections.
@@ -136,11 +167,74 @@ func (client *Client) performInterfaceAction(sa *InterfaceAction) (changeID stri
 // Connect establishes a connection between a plug and a slot.
 // The plug and the slot must have the same interface.
 func (client *Client) Connect(plugSnapName, plugName, slotSnapName, slotName string) (changeID string, err error) {
+	return client.ConnectWithAttrs(plugSnapName, plugName, slotSnapName, slotName, nil)
+}
+
+// ConnectWithAttrs connects the plug to the slot like Connect, with the
+// given attributes overriding those of the slot for the connection, as
+// the interface allows.
+func (client *Client) ConnectWithAttrs(plugSnapName, plugName, slotSnapName, slotName string, attrs map[string]interface{}) (changeID string, err error) {
 	return client.performInterfaceAction(&InterfaceAction{
 		Action: "connect",
 		Plugs:  []Plug{{Snap: plugSnapName, Name: plugName}},
 		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
+		Attrs:  attrs,
+	})
+}
+
+// ConnectDryRun returns the security rules connecting the plug to the
+// slot with the given attributes would add, keyed by security backend and
+// then by what they apply to, without making the connection.
+func (client *Client) ConnectDryRun(plugSnapName, plugName, slotSnapName, slotName string, attrs map[string]interface{}) (map[string]map[string]string, error) {
+	b, err := json.Marshal(&InterfaceAction{
+		Action: "connect",
+		Plugs:  []Plug{{Snap: plugSnapName, Name: plugName}},
+		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
+		Attrs:  attrs,
+		DryRun: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var snippets map[string]map[string]string
+	_, err = client.doSync("POST", "/v2/interfaces", nil, nil, bytes.NewReader(b), &snippets)
+	return snippets, err
+}
+
+// AutoConnect connects all the plugs and slots of the given snap that
+// the auto-connection policy allows but that are not connected yet.
+// The connections made are listed as AutoConnection values in the
+// "connected" data of the change.
+func (client *Client) AutoConnect(snapName string) (changeID string, err error) {
+	return client.performInterfaceAction(&InterfaceAction{
+		Action: "auto-connect",
+		Snap:   snapName,
+	})
+}
+
+// ForgetConnections drops the connections snapd keeps for a while after
+// the removal of the given snap, or of the snaps it was connected to, so
+// that they are not restored on reinstall. It returns how many were
+// dropped.
+func (client *Client) ForgetConnections(snapName string) (int, error) {
+	b, err := json.Marshal(&InterfaceAction{
+		Action: "forget-connections",
+		Snap:   snapName,
 	})
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		Forgotten int `json:"forgotten"`
+	}
+	_, err = client.doSync("POST", "/v2/interfaces", nil, nil, bytes.NewReader(b), &result)
+	return result.Forgotten, err
+}
+
+// AutoConnection is a connection made by AutoConnect.
+type AutoConnection struct {
+	Plug PlugRef `json:"plug"`
+	Slot SlotRef `json:"slot"`
 }
 
 // Disconnect breaks the connection between a plug and a slot.
@@ -151,3 +245,179 @@ func (client *Client) Disconnect(plugSnapName, plugName, slotSnapName, slotName
 		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
 	})
 }
+
+// Connection is an established connection between a plug and a slot.
+type Connection struct {
+	Plug      PlugRef `json:"plug"`
+	Slot      SlotRef `json:"slot"`
+	Interface string  `json:"interface,omitempty"`
+	// Manual is whether the connection was made manually rather than by
+	// the auto-connection policy.
+	Manual bool `json:"manual,omitempty"`
+
+	PlugLabel  string            `json:"plug-label,omitempty"`
+	PlugLabels map[string]string `json:"plug-labels,omitempty"`
+	SlotLabel  string            `json:"slot-label,omitempty"`
+	SlotLabels map[string]string `json:"slot-labels,omitempty"`
+	// Category is the category of the plug, or else of the slot, one of
+	// "hardware", "privacy" or "system" if set.
+	Category string `json:"category,omitempty"`
+}
+
+// LocalizedLabel returns the translation of label to the given language,
+// such as "pt_BR", from labels by language, falling back to the language
+// without its territory, and then to label itself.
+func LocalizedLabel(label string, labels map[string]string, lang string) string {
+	if l, ok := labels[lang]; ok {
+		return l
+	}
+	if i := strings.IndexByte(lang, '_'); i > 0 {
+		if l, ok := labels[lang[:i]]; ok {
+			return l
+		}
+	}
+	return label
+}
+
+// EstablishedConnections returns the connections established between
+// plugs and slots.
+func (client *Client) EstablishedConnections() ([]Connection, error) {
+	var conns []Connection
+	_, err := client.doSync("GET", "/v2/connections", nil, nil, nil, &conns)
+	return conns, err
+}
+
+// ConnectBatch connects the plugs and slots of the given connections in a
+// single change, skipping those that are connected already.
+func (client *Client) ConnectBatch(conns []Connection) (changeID string, err error) {
+	return client.connectionsAction("connect", conns)
+}
+
+// DisconnectBatch disconnects the plugs and slots of the given connections
+// in a single change, skipping those that are not connected.
+func (client *Client) DisconnectBatch(conns []Connection) (changeID string, err error) {
+	return client.connectionsAction("disconnect", conns)
+}
+
+func (client *Client) connectionsAction(action string, conns []Connection) (changeID string, err error) {
+	refs := make([]Connection, len(conns))
+	for i, conn := range conns {
+		refs[i] = Connection{Plug: conn.Plug, Slot: conn.Slot}
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"action":      action,
+		"connections": refs,
+	})
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/connections", nil, nil, bytes.NewReader(b))
+}
+
+// ConnectionOperation is a connection made or broken as part of a batch.
+type ConnectionOperation struct {
+	// Action is either "connect" or "disconnect".
+	Action string  `json:"action"`
+	Plug   PlugRef `json:"plug"`
+	Slot   SlotRef `json:"slot"`
+}
+
+// ConnectionsBatch makes and breaks connections in a single change, the
+// disconnections coming first, setting up the security profiles of each
+// affected snap only once.
+func (client *Client) ConnectionsBatch(ops []ConnectionOperation) (changeID string, err error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"action":     "batch",
+		"operations": ops,
+	})
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/connections", nil, nil, bytes.NewReader(b))
+}
+
+// ConnectionEvent is a connection being made or broken, as recorded in
+// the connection history.
+type ConnectionEvent struct {
+	Time time.Time `json:"time"`
+	// Action is either "connect" or "disconnect".
+	Action string `json:"action"`
+	// By is what initiated the event, such as "user" or "auto-connect".
+	By        string                 `json:"by"`
+	Plug      PlugRef                `json:"plug"`
+	Slot      SlotRef                `json:"slot"`
+	Interface string                 `json:"interface,omitempty"`
+	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
+}
+
+// ConnectionHistory returns the recorded connection events involving the
+// given snap, or all of them if snapName is empty, oldest first.
+func (client *Client) ConnectionHistory(snapName string) ([]ConnectionEvent, error) {
+	q := url.Values{}
+	if snapName != "" {
+		q.Set("snap", snapName)
+	}
+	var events []ConnectionEvent
+	_, err := client.doSync("GET", "/v2/connections/history", q, nil, nil, &events)
+	return events, err
+}
+
+// ConnectionProcess is a running process of the snap on one side of a
+// connection.
+type ConnectionProcess struct {
+	Pid  int    `json:"pid"`
+	Snap string `json:"snap"`
+	// Side is either "plug" or "slot".
+	Side string `json:"side"`
+	// Label is the apparmor label of the process, when it could be read.
+	Label string `json:"label,omitempty"`
+}
+
+// Denial is an access recently denied by apparmor to a profile affected
+// by a connection.
+type Denial struct {
+	Time          time.Time `json:"time"`
+	Profile       string    `json:"profile"`
+	Operation     string    `json:"operation,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Pid           int       `json:"pid,omitempty"`
+	Comm          string    `json:"comm,omitempty"`
+	RequestedMask string    `json:"requested-mask,omitempty"`
+	DeniedMask    string    `json:"denied-mask,omitempty"`
+}
+
+// ConnectionActivity is a connection with the processes currently
+// running with the access it grants, and the recent denials of their
+// profiles.
+type ConnectionActivity struct {
+	Plug      PlugRef             `json:"plug"`
+	Slot      SlotRef             `json:"slot"`
+	Interface string              `json:"interface"`
+	Processes []ConnectionProcess `json:"processes,omitempty"`
+	Denials   []Denial            `json:"denials,omitempty"`
+}
+
+// ConnectionActivity returns the activity of the connections involving
+// the given snap, or of all of them if snapName is empty.
+func (client *Client) ConnectionActivity(snapName string) ([]ConnectionActivity, error) {
+	q := url.Values{}
+	if snapName != "" {
+		q.Set("snap", snapName)
+	}
+	var conns []ConnectionActivity
+	_, err := client.doSync("GET", "/v2/connections/activity", q, nil, nil, &conns)
+	return conns, err
+}
+
+// ConnectionActivityJob starts computing the activity of the
+// connections, as ConnectionActivity does, as a query job in the
+// background. It returns the ID of the job, whose result is a list of
+// ConnectionActivity once ready.
+func (client *Client) ConnectionActivityJob(snapName string) (jobID string, err error) {
+	q := url.Values{"async": []string{"true"}}
+	if snapName != "" {
+		q.Set("snap", snapName)
+	}
+	return client.doAsyncJob("GET", "/v2/connections/activity", q)
+}
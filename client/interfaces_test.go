@@ -21,6 +21,8 @@ package client_test
 
 import (
 	"encoding/json"
+	"net/url"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -102,6 +104,19 @@ func (cs *clientSuite) TestClientInterfacesSelectedDetails(c *check.C) {
 				"name": "iface-a",
 				"summary": "the A iface",
 				"doc-url": "http://example.org/ifaces/a",
+				"plug-attrs": [{
+					"name": "mode",
+					"type": "string",
+					"description": "how the thing is used",
+					"enum": ["ro", "rw"]
+				}],
+				"slot-attrs": [{
+					"name": "path",
+					"type": "string",
+					"required": true,
+					"path": true,
+					"patterns": "a device node"
+				}],
 				"plugs": [{
 					"snap": "consumer",
 					"plug": "plug",
@@ -131,6 +146,12 @@ func (cs *clientSuite) TestClientInterfacesSelectedDetails(c *check.C) {
 			DocURL:  "http://example.org/ifaces/a",
 			Plugs:   []client.Plug{{Snap: "consumer", Name: "plug", Interface: "iface-a"}},
 			Slots:   []client.Slot{{Snap: "producer", Name: "slot", Interface: "iface-a"}},
+			PlugAttrs: []client.InterfaceAttr{
+				{Name: "mode", Type: "string", Description: "how the thing is used", Enum: []string{"ro", "rw"}},
+			},
+			SlotAttrs: []client.InterfaceAttr{
+				{Name: "path", Type: "string", Required: true, Path: true, Patterns: "a device node"},
+			},
 		},
 	})
 }
@@ -261,12 +282,103 @@ func (cs *clientSuite) TestClientConnect(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientConnectWithAttrs(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.ConnectWithAttrs("producer", "plug", "consumer", "slot", map[string]interface{}{"path": "/dev/ttyUSB1"})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body["action"], check.Equals, "connect")
+	c.Check(body["attrs"], check.DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB1"})
+}
+
+func (cs *clientSuite) TestClientConnectDryRun(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"apparmor": {"snap.consumer.app": "/dev/ttyUSB1 rw,"}
+		}
+	}`
+	snippets, err := cs.cli.ConnectDryRun("consumer", "plug", "producer", "slot", map[string]interface{}{"path": "/dev/ttyUSB1"})
+	c.Assert(err, check.IsNil)
+	c.Check(snippets, check.DeepEquals, map[string]map[string]string{
+		"apparmor": {"snap.consumer.app": "/dev/ttyUSB1 rw,"},
+	})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "connect",
+		"plugs": []interface{}{
+			map[string]interface{}{"snap": "consumer", "plug": "plug"},
+		},
+		"slots": []interface{}{
+			map[string]interface{}{"snap": "producer", "slot": "slot"},
+		},
+		"attrs":   map[string]interface{}{"path": "/dev/ttyUSB1"},
+		"dry-run": true,
+	})
+}
+
+func (cs *clientSuite) TestClientForgetConnections(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"forgotten": 2}
+	}`
+	n, err := cs.cli.ForgetConnections("consumer")
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 2)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "forget-connections",
+		"snap":   "consumer",
+	})
+}
+
 func (cs *clientSuite) TestClientDisconnectCallsEndpoint(c *check.C) {
 	cs.cli.Disconnect("producer", "plug", "consumer", "slot")
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
 }
 
+func (cs *clientSuite) TestClientAutoConnect(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.AutoConnect("consumer")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/interfaces")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "auto-connect",
+		"snap":   "consumer",
+	})
+}
+
 func (cs *clientSuite) TestClientDisconnect(c *check.C) {
 	cs.rsp = `{
 		"type": "async",
@@ -297,3 +409,207 @@ func (cs *clientSuite) TestClientDisconnect(c *check.C) {
 		},
 	})
 }
+
+func (cs *clientSuite) TestClientEstablishedConnections(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{"plug": {"snap": "canonical-pi2", "plug": "pin-13"}, "slot": {"snap": "keyboard-lights", "slot": "capslock-led"}, "interface": "bool-file", "manual": true, "slot-label": "Caps Lock LED", "slot-labels": {"fr": "LED Verr Maj"}, "category": "hardware"},
+			{"plug": {"snap": "foo", "plug": "network"}, "slot": {"snap": "core", "slot": "network"}, "interface": "network"}
+		]
+	}`
+	conns, err := cs.cli.EstablishedConnections()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections")
+	c.Check(conns, check.DeepEquals, []client.Connection{
+		{
+			Plug:       client.PlugRef{Snap: "canonical-pi2", Name: "pin-13"},
+			Slot:       client.SlotRef{Snap: "keyboard-lights", Name: "capslock-led"},
+			Interface:  "bool-file",
+			Manual:     true,
+			SlotLabel:  "Caps Lock LED",
+			SlotLabels: map[string]string{"fr": "LED Verr Maj"},
+			Category:   "hardware",
+		}, {
+			Plug:      client.PlugRef{Snap: "foo", Name: "network"},
+			Slot:      client.SlotRef{Snap: "core", Name: "network"},
+			Interface: "network",
+		},
+	})
+}
+
+func (cs *clientSuite) TestLocalizedLabel(c *check.C) {
+	labels := map[string]string{"fr": "Prendre des photos", "pt_BR": "Tirar fotos"}
+	c.Check(client.LocalizedLabel("Take photos", labels, "pt_BR"), check.Equals, "Tirar fotos")
+	c.Check(client.LocalizedLabel("Take photos", labels, "fr_CA"), check.Equals, "Prendre des photos")
+	c.Check(client.LocalizedLabel("Take photos", labels, "pt_PT"), check.Equals, "Take photos")
+	c.Check(client.LocalizedLabel("Take photos", labels, ""), check.Equals, "Take photos")
+	c.Check(client.LocalizedLabel("Take photos", nil, "fr"), check.Equals, "Take photos")
+}
+
+func (cs *clientSuite) TestClientConnectionHistory(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{"time": "2018-06-01T10:00:00Z", "action": "connect", "by": "user", "plug": {"snap": "foo", "plug": "camera"}, "slot": {"snap": "core", "slot": "camera"}, "interface": "camera", "slot-attrs": {"usb-vendor": 1}}
+		]
+	}`
+	events, err := cs.cli.ConnectionHistory("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections/history")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"snap": []string{"foo"}})
+	c.Check(events, check.DeepEquals, []client.ConnectionEvent{{
+		Time:      time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC),
+		Action:    "connect",
+		By:        "user",
+		Plug:      client.PlugRef{Snap: "foo", Name: "camera"},
+		Slot:      client.SlotRef{Snap: "core", Name: "camera"},
+		Interface: "camera",
+		SlotAttrs: map[string]interface{}{"usb-vendor": json.Number("1")},
+	}})
+
+	_, err = cs.cli.ConnectionHistory("")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
+
+func (cs *clientSuite) TestClientConnectionActivity(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{"plug": {"snap": "foo", "plug": "camera"}, "slot": {"snap": "core", "slot": "camera"}, "interface": "camera",
+			 "processes": [{"pid": 42, "snap": "foo", "side": "plug", "label": "snap.foo.app (enforce)"}],
+			 "denials": [{"time": "2018-06-01T10:00:00Z", "profile": "snap.foo.app", "operation": "open", "name": "/dev/video1", "pid": 42, "comm": "app", "requested-mask": "r", "denied-mask": "r"}]}
+		]
+	}`
+	conns, err := cs.cli.ConnectionActivity("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections/activity")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"snap": []string{"foo"}})
+	c.Check(conns, check.DeepEquals, []client.ConnectionActivity{{
+		Plug:      client.PlugRef{Snap: "foo", Name: "camera"},
+		Slot:      client.SlotRef{Snap: "core", Name: "camera"},
+		Interface: "camera",
+		Processes: []client.ConnectionProcess{{Pid: 42, Snap: "foo", Side: "plug", Label: "snap.foo.app (enforce)"}},
+		Denials: []client.Denial{{
+			Time:          time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC),
+			Profile:       "snap.foo.app",
+			Operation:     "open",
+			Name:          "/dev/video1",
+			Pid:           42,
+			Comm:          "app",
+			RequestedMask: "r",
+			DeniedMask:    "r",
+		}},
+	}})
+
+	_, err = cs.cli.ConnectionActivity("")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
+
+func (cs *clientSuite) TestClientConnectBatch(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.ConnectBatch([]client.Connection{{
+		Plug:      client.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      client.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "bool-file",
+		Manual:    true,
+	}})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "connect",
+		"connections": []interface{}{
+			map[string]interface{}{
+				"plug": map[string]interface{}{"snap": "consumer", "plug": "plug"},
+				"slot": map[string]interface{}{"snap": "producer", "slot": "slot"},
+			},
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientDisconnectBatch(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.DisconnectBatch([]client.Connection{{
+		Plug: client.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot: client.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "disconnect",
+		"connections": []interface{}{
+			map[string]interface{}{
+				"plug": map[string]interface{}{"snap": "consumer", "plug": "plug"},
+				"slot": map[string]interface{}{"snap": "producer", "slot": "slot"},
+			},
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientConnectionsBatch(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.ConnectionsBatch([]client.ConnectionOperation{{
+		Action: "disconnect",
+		Plug:   client.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:   client.SlotRef{Snap: "producer", Name: "slot"},
+	}, {
+		Action: "connect",
+		Plug:   client.PlugRef{Snap: "other", Name: "plug"},
+		Slot:   client.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "batch",
+		"operations": []interface{}{
+			map[string]interface{}{
+				"action": "disconnect",
+				"plug":   map[string]interface{}{"snap": "consumer", "plug": "plug"},
+				"slot":   map[string]interface{}{"snap": "producer", "slot": "slot"},
+			},
+			map[string]interface{}{
+				"action": "connect",
+				"plug":   map[string]interface{}{"snap": "other", "plug": "plug"},
+				"slot":   map[string]interface{}{"snap": "producer", "slot": "slot"},
+			},
+		},
+	})
+}
@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// A Job is a read-only query computed in the background by snapd. Its
+// outcome is kept for a while once ready, and then forgotten.
+type Job struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Ready  bool   `json:"ready"`
+	Err    string `json:"err,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time,omitempty"`
+	ReadyTime time.Time `json:"ready-time,omitempty"`
+
+	result json.RawMessage
+}
+
+// ErrJobNotReady is returned when asking for the result of a job
+// still being computed.
+var ErrJobNotReady = fmt.Errorf("job is not ready")
+
+// Result unmarshals into value the result of the job.
+func (j *Job) Result(value interface{}) error {
+	if !j.Ready {
+		return ErrJobNotReady
+	}
+	if j.Err != "" {
+		return fmt.Errorf("%s", j.Err)
+	}
+	return json.Unmarshal(j.result, value)
+}
+
+type jobAndResult struct {
+	Job
+	Result json.RawMessage `json:"result"`
+}
+
+// Job returns the job with the given ID, with its result when ready.
+func (client *Client) Job(id string) (*Job, error) {
+	var jr jobAndResult
+	if _, err := client.doSync("GET", "/v2/jobs/"+id, nil, nil, nil, &jr); err != nil {
+		return nil, err
+	}
+
+	jr.Job.result = jr.Result
+	return &jr.Job, nil
+}
+
+// doAsyncJob asks for a query to be computed as a job, returning the
+// ID of the job.
+func (client *Client) doAsyncJob(method, path string, query url.Values) (jobID string, err error) {
+	var rsp response
+
+	if err := client.do(method, path, query, nil, nil, &rsp); err != nil {
+		return "", err
+	}
+	if err := rsp.err(); err != nil {
+		return "", err
+	}
+	if rsp.Type != "async" {
+		return "", fmt.Errorf("expected async response for %q on %q, got %q", method, path, rsp.Type)
+	}
+	if rsp.Job == "" {
+		return "", fmt.Errorf("async response without job reference")
+	}
+
+	return rsp.Job, nil
+}
@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientConnectionActivityJob(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "job": "7", "result": {"resource": "/v2/jobs/7"}}`
+	jobID, err := cs.cli.ConnectionActivityJob("consumer")
+	c.Assert(err, check.IsNil)
+	c.Check(jobID, check.Equals, "7")
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections/activity")
+	c.Check(cs.req.URL.Query().Get("async"), check.Equals, "true")
+	c.Check(cs.req.URL.Query().Get("snap"), check.Equals, "consumer")
+}
+
+func (cs *clientSuite) TestClientConnectionActivityJobNoJob(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+	_, err := cs.cli.ConnectionActivityJob("")
+	c.Check(err, check.ErrorMatches, `expected async response for "GET" on "/v2/connections/activity", got "sync"`)
+}
+
+func (cs *clientSuite) TestClientJob(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id": "7",
+  "kind": "connections-activity",
+  "status": "Done",
+  "ready": true,
+  "spawn-time": "2016-04-21T01:02:03Z",
+  "ready-time": "2016-04-21T01:02:04Z",
+  "result": [{"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}, "interface": "test"}]
+}}`
+	job, err := cs.cli.Job("7")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/jobs/7")
+	c.Check(job.ID, check.Equals, "7")
+	c.Check(job.Kind, check.Equals, "connections-activity")
+	c.Check(job.Status, check.Equals, "Done")
+	c.Check(job.Ready, check.Equals, true)
+	c.Check(job.SpawnTime, check.Equals, time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	c.Check(job.ReadyTime, check.Equals, time.Date(2016, 04, 21, 1, 2, 4, 0, time.UTC))
+
+	var conns []client.ConnectionActivity
+	c.Assert(job.Result(&conns), check.IsNil)
+	c.Check(conns, check.DeepEquals, []client.ConnectionActivity{{
+		Plug:      client.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      client.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}})
+}
+
+func (cs *clientSuite) TestClientJobNotReady(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"id": "7", "kind": "connections-activity", "status": "Doing", "ready": false}}`
+	job, err := cs.cli.Job("7")
+	c.Assert(err, check.IsNil)
+	var conns []client.ConnectionActivity
+	c.Check(job.Result(&conns), check.Equals, client.ErrJobNotReady)
+}
+
+func (cs *clientSuite) TestClientJobError(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"id": "7", "kind": "connections-activity", "status": "Error", "ready": true, "err": "boom"}}`
+	job, err := cs.cli.Job("7")
+	c.Assert(err, check.IsNil)
+	var conns []client.ConnectionActivity
+	c.Check(job.Result(&conns), check.ErrorMatches, "boom")
+}
+
+func (cs *clientSuite) TestClientJobNotFound(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "cannot find job with id \"7\""}}`
+	_, err := cs.cli.Job("7")
+	c.Check(err, check.ErrorMatches, `cannot find job with id "7"`)
+}
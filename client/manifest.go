@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Manifest describes the snaps, connections and configuration a device
+// should have.
+type Manifest struct {
+	Snaps       map[string]*ManifestSnap          `json:"snaps,omitempty"`
+	Connections []Connection                      `json:"connections,omitempty"`
+	Config      map[string]map[string]interface{} `json:"config,omitempty"`
+}
+
+// ManifestSnap is a snap of a manifest, to be installed or refreshed to
+// the given channel or revision if it isn't on those already.
+type ManifestSnap struct {
+	Channel  string `json:"channel,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	DevMode  bool   `json:"devmode,omitempty"`
+	JailMode bool   `json:"jailmode,omitempty"`
+	Classic  bool   `json:"classic,omitempty"`
+}
+
+// ManifestPlan is what applying a manifest would do.
+type ManifestPlan struct {
+	ChangePlan
+	// DeferredConnections are the connections involving snaps the
+	// manifest installs, which are only made by applying it again once
+	// they are installed.
+	DeferredConnections []Connection `json:"deferred-connections,omitempty"`
+}
+
+type manifestAction struct {
+	Manifest *Manifest `json:"manifest"`
+	DryRun   bool      `json:"dry-run,omitempty"`
+}
+
+func manifestBody(m *Manifest, dryRun bool) (*bytes.Buffer, error) {
+	data, err := json.Marshal(&manifestAction{Manifest: m, DryRun: dryRun})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal manifest: %v", err)
+	}
+	return bytes.NewBuffer(data), nil
+}
+
+// ApplyManifest brings the device to the state described by the
+// manifest, in a single change.
+func (client *Client) ApplyManifest(m *Manifest) (changeID string, err error) {
+	body, err := manifestBody(m, false)
+	if err != nil {
+		return "", err
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	return client.doAsync("POST", "/v2/manifest", nil, headers, body)
+}
+
+// PlanManifest returns what applying the manifest would do, without
+// doing it.
+func (client *Client) PlanManifest(m *Manifest) (*ManifestPlan, error) {
+	body, err := manifestBody(m, true)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	var plan ManifestPlan
+	if _, err := client.doSync("POST", "/v2/manifest", nil, headers, body, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
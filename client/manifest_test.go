@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package client_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+var testManifest = &client.Manifest{
+	Snaps: map[string]*client.ManifestSnap{
+		"foo": {Channel: "beta"},
+		"bar": {Revision: "7"},
+	},
+	Connections: []client.Connection{{
+		Plug: client.PlugRef{Snap: "foo", Name: "plug"},
+		Slot: client.SlotRef{Snap: "bar", Name: "slot"},
+	}},
+	Config: map[string]map[string]interface{}{
+		"foo": {"a": 1},
+	},
+}
+
+func (cs *clientSuite) checkManifestBody(c *check.C, dryRun bool) {
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/manifest")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	expected := map[string]interface{}{
+		"manifest": map[string]interface{}{
+			"snaps": map[string]interface{}{
+				"foo": map[string]interface{}{"channel": "beta"},
+				"bar": map[string]interface{}{"revision": "7"},
+			},
+			"connections": []interface{}{map[string]interface{}{
+				"plug": map[string]interface{}{"snap": "foo", "plug": "plug"},
+				"slot": map[string]interface{}{"snap": "bar", "slot": "slot"},
+			}},
+			"config": map[string]interface{}{
+				"foo": map[string]interface{}{"a": 1.0},
+			},
+		},
+	}
+	if dryRun {
+		expected["dry-run"] = true
+	}
+	c.Check(jsonBody, check.DeepEquals, expected)
+}
+
+func (cs *clientSuite) TestClientApplyManifest(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "42"}`
+	id, err := cs.cli.ApplyManifest(testManifest)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "42")
+	cs.checkManifestBody(c, false)
+}
+
+func (cs *clientSuite) TestClientPlanManifest(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "kind": "apply-manifest",
+  "summary": "Apply manifest to 1 snap",
+  "snaps": ["foo"],
+  "tasks": [{"kind": "run-hook", "summary": "Run configure hook of \"foo\" snap"}],
+  "download-size": 0,
+  "deferred-connections": [{"plug": {"snap": "foo", "plug": "plug"}, "slot": {"snap": "bar", "slot": "slot"}}]
+}}`
+	plan, err := cs.cli.PlanManifest(testManifest)
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, &client.ManifestPlan{
+		ChangePlan: client.ChangePlan{
+			Kind:    "apply-manifest",
+			Summary: "Apply manifest to 1 snap",
+			Snaps:   []string{"foo"},
+			Tasks:   []*client.PlannedTask{{Kind: "run-hook", Summary: `Run configure hook of "foo" snap`}},
+		},
+		DeferredConnections: []client.Connection{{
+			Plug: client.PlugRef{Snap: "foo", Name: "plug"},
+			Slot: client.SlotRef{Snap: "bar", Name: "slot"},
+		}},
+	})
+	cs.checkManifestBody(c, true)
+}
@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// TaskMetrics describes how long running the tasks of a kind took
+// since snapd started and how often it failed. The percentiles and
+// maximum are over the most recent runs.
+type TaskMetrics struct {
+	Name     string        `json:"name"`
+	Count    int           `json:"count"`
+	Failures int           `json:"failures"`
+	Total    time.Duration `json:"total"`
+	P50      time.Duration `json:"p50"`
+	P90      time.Duration `json:"p90"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+}
+
+// Metrics holds the metrics collected by snapd since it started.
+type Metrics struct {
+	Tasks []TaskMetrics `json:"tasks"`
+}
+
+// Metrics returns the metrics collected by snapd since it started.
+func (client *Client) Metrics() (*Metrics, error) {
+	var m Metrics
+	if _, err := client.doSync("GET", "/v2/metrics", nil, nil, nil, &m); err != nil {
+		return nil, fmt.Errorf("cannot get metrics: %v", err)
+	}
+	return &m, nil
+}
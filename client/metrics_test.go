@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientMetrics(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"tasks": [
+  {"name": "setup-profiles", "count": 3, "failures": 1, "total": 6000000000, "p50": 2000000000, "p90": 3000000000, "p99": 3000000000, "max": 3000000000}
+]}}`
+	m, err := cs.cli.Metrics()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/metrics")
+	c.Check(m, check.DeepEquals, &client.Metrics{
+		Tasks: []client.TaskMetrics{{
+			Name:     "setup-profiles",
+			Count:    3,
+			Failures: 1,
+			Total:    6 * time.Second,
+			P50:      2 * time.Second,
+			P90:      3 * time.Second,
+			P99:      3 * time.Second,
+			Max:      3 * time.Second,
+		}},
+	})
+}
+
+func (cs *clientSuite) TestClientMetricsError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 500, "result": {"message": "boom"}}`
+	_, err := cs.cli.Metrics()
+	c.Check(err, check.ErrorMatches, `cannot get metrics: boom`)
+}
@@ -0,0 +1,55 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// SnapOwner is the snap that owns a path or a running process.
+type SnapOwner struct {
+	Snap     string        `json:"snap"`
+	Revision snap.Revision `json:"revision"`
+	// App is the app of the snap a process belongs to, when known.
+	App string `json:"app,omitempty"`
+}
+
+func (client *Client) owner(q url.Values) (*SnapOwner, error) {
+	var owner SnapOwner
+	if _, err := client.doSync("GET", "/v2/routine/owner", q, nil, nil, &owner); err != nil {
+		return nil, err
+	}
+	return &owner, nil
+}
+
+// PathOwner returns the snap, and revision of it, whose mount
+// directory holds the given absolute path.
+func (client *Client) PathOwner(path string) (*SnapOwner, error) {
+	return client.owner(url.Values{"path": {path}})
+}
+
+// PidOwner returns the snap, and the app of it if known, the process
+// with the given pid is running as part of.
+func (client *Client) PidOwner(pid int) (*SnapOwner, error) {
+	return client.owner(url.Values{"pid": {strconv.Itoa(pid)}})
+}
@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientPathOwner(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"snap": "foo", "revision": "2"}}`
+	owner, err := cs.cli.PathOwner("/snap/foo/2/bin/foo")
+	c.Assert(err, check.IsNil)
+	c.Check(owner, check.DeepEquals, &client.SnapOwner{Snap: "foo", Revision: snap.R(2)})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/routine/owner")
+	c.Check(cs.req.URL.Query().Get("path"), check.Equals, "/snap/foo/2/bin/foo")
+}
+
+func (cs *clientSuite) TestClientPidOwner(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"snap": "foo", "revision": "2", "app": "svc"}}`
+	owner, err := cs.cli.PidOwner(42)
+	c.Assert(err, check.IsNil)
+	c.Check(owner, check.DeepEquals, &client.SnapOwner{Snap: "foo", Revision: snap.R(2), App: "svc"})
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/routine/owner")
+	c.Check(cs.req.URL.Query().Get("pid"), check.Equals, "42")
+}
+
+func (cs *clientSuite) TestClientOwnerError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "no snap owns path \"/etc\""}}`
+	_, err := cs.cli.PathOwner("/etc")
+	c.Check(err, check.ErrorMatches, `no snap owns path "/etc"`)
+}
@@ -46,17 +46,27 @@ type Snap struct {
 	Version         string        `json:"version"`
 	Channel         string        `json:"channel"`
 	TrackingChannel string        `json:"tracking-channel"`
+	BranchExpiry    string        `json:"branch-expiry,omitempty"`
+	ClosedBranch    bool          `json:"closed-branch,omitempty"`
 	Revision        snap.Revision `json:"revision"`
 	Confinement     string        `json:"confinement"`
 	Private         bool          `json:"private"`
 	DevMode         bool          `json:"devmode"`
 	JailMode        bool          `json:"jailmode"`
 	TryMode         bool          `json:"trymode"`
+	ForeignArch     bool          `json:"foreign-arch,omitempty"`
 	Apps            []AppInfo     `json:"apps"`
 	Broken          string        `json:"broken"`
 	Contact         string        `json:"contact"`
 	License         string        `json:"license,omitempty"`
 
+	// PublisherValidation is how far the store vouches for the
+	// publisher: "verified", "starred" or "unproven".
+	PublisherValidation string `json:"publisher-validation,omitempty"`
+	PublisherContact    string `json:"publisher-contact,omitempty"`
+	// PublisherSnaps are the other snaps of the same publisher.
+	PublisherSnaps []string `json:"publisher-snaps,omitempty"`
+
 	Prices      map[string]float64 `json:"prices"`
 	Screenshots []Screenshot       `json:"screenshots"`
 
@@ -65,6 +75,8 @@ type Snap struct {
 
 	// The ordered list of tracks that contains channels
 	Tracks []string
+	// DefaultTrack is the track the publisher has set as default
+	DefaultTrack string `json:"default-track,omitempty"`
 }
 
 type Screenshot struct {
@@ -140,6 +152,36 @@ func (client *Client) List(names []string, opts *ListOptions) ([]*Snap, error) {
 	return snaps, nil
 }
 
+// RefreshHold explains why a pending refresh is held back.
+type RefreshHold struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// RefreshCandidate describes an update available for an installed snap.
+type RefreshCandidate struct {
+	Name            string        `json:"name"`
+	CurrentRevision snap.Revision `json:"current-revision"`
+	Revision        snap.Revision `json:"revision"`
+	Version         string        `json:"version"`
+	Channel         string        `json:"channel"`
+	DownloadSize    int64         `json:"download-size,omitempty"`
+	Held            []RefreshHold `json:"held,omitempty"`
+}
+
+// RefreshCandidates returns the updates available for the installed
+// snaps, with the reasons any of them would not be auto-refreshed.
+func (client *Client) RefreshCandidates() ([]*RefreshCandidate, error) {
+	q := make(url.Values)
+	q.Set("select", "refresh-candidates")
+
+	var cands []*RefreshCandidate
+	if _, err := client.doSync("GET", "/v2/snaps", q, nil, nil, &cands); err != nil {
+		return nil, fmt.Errorf("cannot list refresh candidates: %s", err)
+	}
+	return cands, nil
+}
+
 // Sections returns the list of existing snap sections in the store
 func (client *Client) Sections() ([]string, error) {
 	var sections []string
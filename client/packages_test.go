@@ -28,6 +28,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 func (cs *clientSuite) TestClientSnapsCallsEndpoint(c *check.C) {
@@ -37,6 +38,40 @@ func (cs *clientSuite) TestClientSnapsCallsEndpoint(c *check.C) {
 	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{})
 }
 
+func (cs *clientSuite) TestClientRefreshCandidates(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+		"name": "foo",
+		"current-revision": "7",
+		"revision": "11",
+		"version": "1.1",
+		"channel": "stable",
+		"download-size": 4096,
+		"held": [{"reason": "validation", "message": "no validation by \"bar\""}]
+	}]}`
+	cands, err := cs.cli.RefreshCandidates()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"refresh-candidates"},
+	})
+	c.Check(cands, check.DeepEquals, []*client.RefreshCandidate{{
+		Name:            "foo",
+		CurrentRevision: snap.R(7),
+		Revision:        snap.R(11),
+		Version:         "1.1",
+		Channel:         "stable",
+		DownloadSize:    4096,
+		Held:            []client.RefreshHold{{Reason: "validation", Message: `no validation by "bar"`}},
+	}})
+}
+
+func (cs *clientSuite) TestClientRefreshCandidatesError(c *check.C) {
+	cs.rsp = `{"type": "error", "result": {"message": "boom"}, "status-code": 500}`
+	_, err := cs.cli.RefreshCandidates()
+	c.Check(err, check.ErrorMatches, `cannot list refresh candidates: boom`)
+}
+
 func (cs *clientSuite) TestClientFindRefreshSetsQuery(c *check.C) {
 	_, _, _ = cs.cli.Find(&client.FindOptions{
 		Refresh: true,
@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ChangePlan describes what a snap operation would do to the system.
+type ChangePlan struct {
+	Kind    string         `json:"kind"`
+	Summary string         `json:"summary"`
+	Snaps   []string       `json:"snaps"`
+	Tasks   []*PlannedTask `json:"tasks"`
+	// DownloadSize is the total of the sizes of the snaps to download.
+	DownloadSize int64 `json:"download-size"`
+	// Restart is "daemon" or "system" if the operation would restart
+	// snapd or the system.
+	Restart string `json:"restart,omitempty"`
+	// Services are the services the operation would stop or restart.
+	Services []string `json:"services,omitempty"`
+}
+
+// PlannedTask is one of the tasks of a ChangePlan.
+type PlannedTask struct {
+	Kind    string `json:"kind"`
+	Summary string `json:"summary"`
+	Snap    string `json:"snap,omitempty"`
+	Lanes   []int  `json:"lanes,omitempty"`
+	// WaitFor holds the indices in the plan of the tasks this one
+	// waits for.
+	WaitFor []int `json:"wait-for,omitempty"`
+}
+
+type planData struct {
+	Action string   `json:"action"`
+	Snaps  []string `json:"snaps,omitempty"`
+	*SnapOptions
+}
+
+// Plan returns what the given action ("install", "refresh", "remove", ...)
+// on the named snaps would do, without doing it. Options are only
+// supported for single-snap actions.
+func (client *Client) Plan(action string, names []string, options *SnapOptions) (*ChangePlan, error) {
+	if options != nil && len(names) != 1 {
+		return nil, fmt.Errorf("cannot use options for multi-action") // (yet)
+	}
+	data, err := json.Marshal(&planData{
+		Action:      action,
+		Snaps:       names,
+		SnapOptions: options,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal snap action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan ChangePlan
+	if _, err := client.doSync("POST", "/v2/plan", nil, headers, bytes.NewBuffer(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
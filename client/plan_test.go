@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientPlan(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "kind": "refresh-snap",
+  "summary": "Refresh \"foo\" snap",
+  "snaps": ["foo"],
+  "tasks": [
+    {"kind": "download-snap", "summary": "Download foo", "snap": "foo", "lanes": [1]},
+    {"kind": "link-snap", "summary": "Link foo", "snap": "foo", "lanes": [1], "wait-for": [0]}
+  ],
+  "download-size": 4096,
+  "restart": "daemon",
+  "services": ["foo.svc"]
+}}`
+	plan, err := cs.cli.Plan("refresh", []string{"foo"}, &client.SnapOptions{Channel: "edge"})
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, &client.ChangePlan{
+		Kind:    "refresh-snap",
+		Summary: `Refresh "foo" snap`,
+		Snaps:   []string{"foo"},
+		Tasks: []*client.PlannedTask{
+			{Kind: "download-snap", Summary: "Download foo", Snap: "foo", Lanes: []int{1}},
+			{Kind: "link-snap", Summary: "Link foo", Snap: "foo", Lanes: []int{1}, WaitFor: []int{0}},
+		},
+		DownloadSize: 4096,
+		Restart:      "daemon",
+		Services:     []string{"foo.svc"},
+	})
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/plan")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":  "refresh",
+		"snaps":   []interface{}{"foo"},
+		"channel": "edge",
+	})
+}
+
+func (cs *clientSuite) TestClientPlanManyWithOptions(c *check.C) {
+	_, err := cs.cli.Plan("refresh", []string{"foo", "bar"}, &client.SnapOptions{Channel: "edge"})
+	c.Check(err, check.ErrorMatches, "cannot use options for multi-action")
+}
@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PromptRequest is an access of a snap waiting for the user to decide
+// about it.
+type PromptRequest struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Snap       string    `json:"snap"`
+	App        string    `json:"app"`
+	Interface  string    `json:"interface"`
+	Path       string    `json:"path"`
+	Permission string    `json:"permission"`
+}
+
+// PromptReply is the answer of the user to a prompt request.
+type PromptReply struct {
+	// Outcome is either "allow" or "deny".
+	Outcome string `json:"outcome"`
+	// Lifespan is either "single", to answer just the request, or
+	// "forever", to also record a rule answering the matching
+	// requests from then on.
+	Lifespan string `json:"lifespan,omitempty"`
+	// PathPattern is the pattern of the paths the recorded rule is
+	// about, by default just the path of the request.
+	PathPattern string `json:"path-pattern,omitempty"`
+}
+
+// PromptRule is a remembered answer to the prompts for the accesses of
+// a snap matching it.
+type PromptRule struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	UID         uint32    `json:"uid"`
+	Snap        string    `json:"snap"`
+	Interface   string    `json:"interface"`
+	PathPattern string    `json:"path-pattern"`
+	Permission  string    `json:"permission,omitempty"`
+	Outcome     string    `json:"outcome"`
+}
+
+// PromptRequests returns the accesses of snaps waiting for the user to
+// decide about them.
+func (client *Client) PromptRequests() ([]*PromptRequest, error) {
+	var requests []*PromptRequest
+	if _, err := client.doSync("GET", "/v2/prompting/requests", nil, nil, nil, &requests); err != nil {
+		return nil, fmt.Errorf("cannot list prompt requests: %v", err)
+	}
+	return requests, nil
+}
+
+// ReplyToPrompt answers the prompt request with the given id, returning
+// the rule recorded, if any.
+func (client *Client) ReplyToPrompt(id string, reply *PromptReply) (*PromptRule, error) {
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+	var rule *PromptRule
+	if _, err := client.doSync("POST", "/v2/prompting/requests/"+id, nil, nil, bytes.NewReader(b), &rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// PromptRules returns the prompt rules about the given snap, or all
+// snaps if snapName is empty.
+func (client *Client) PromptRules(snapName string) ([]*PromptRule, error) {
+	var query url.Values
+	if snapName != "" {
+		query = url.Values{"snap": []string{snapName}}
+	}
+	var rules []*PromptRule
+	if _, err := client.doSync("GET", "/v2/prompting/rules", query, nil, nil, &rules); err != nil {
+		return nil, fmt.Errorf("cannot list prompt rules: %v", err)
+	}
+	return rules, nil
+}
+
+type promptRuleAction struct {
+	Action string `json:"action"`
+	ID     string `json:"id"`
+}
+
+// RemovePromptRule removes the prompt rule with the given id.
+func (client *Client) RemovePromptRule(id string) error {
+	b, err := json.Marshal(&promptRuleAction{Action: "remove", ID: id})
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("POST", "/v2/prompting/rules", nil, nil, bytes.NewReader(b), nil)
+	return err
+}
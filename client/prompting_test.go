@@ -0,0 +1,117 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientPromptRequests(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"id": "1", "snap": "foo", "app": "app", "interface": "camera", "path": "/dev/video0", "permission": "read"}]}`
+	requests, err := cs.cli.PromptRequests()
+	c.Assert(err, check.IsNil)
+	c.Check(requests, check.DeepEquals, []*client.PromptRequest{{
+		ID:         "1",
+		Snap:       "foo",
+		App:        "app",
+		Interface:  "camera",
+		Path:       "/dev/video0",
+		Permission: "read",
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/requests")
+}
+
+func (cs *clientSuite) TestClientReplyToPrompt(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"id": "7", "snap": "foo", "interface": "home", "path-pattern": "/home/user/**", "outcome": "allow"}}`
+	rule, err := cs.cli.ReplyToPrompt("1", &client.PromptReply{
+		Outcome:     "allow",
+		Lifespan:    "forever",
+		PathPattern: "/home/user/**",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(rule, check.DeepEquals, &client.PromptRule{
+		ID:          "7",
+		Snap:        "foo",
+		Interface:   "home",
+		PathPattern: "/home/user/**",
+		Outcome:     "allow",
+	})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/requests/1")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"outcome":      "allow",
+		"lifespan":     "forever",
+		"path-pattern": "/home/user/**",
+	})
+}
+
+func (cs *clientSuite) TestClientReplyToPromptSingle(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	rule, err := cs.cli.ReplyToPrompt("1", &client.PromptReply{Outcome: "deny"})
+	c.Assert(err, check.IsNil)
+	c.Check(rule, check.IsNil)
+}
+
+func (cs *clientSuite) TestClientPromptRules(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"id": "7", "uid": 1000, "snap": "foo", "interface": "home", "path-pattern": "/home/user/**", "outcome": "deny"}]}`
+	rules, err := cs.cli.PromptRules("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(rules, check.DeepEquals, []*client.PromptRule{{
+		ID:          "7",
+		UID:         1000,
+		Snap:        "foo",
+		Interface:   "home",
+		PathPattern: "/home/user/**",
+		Outcome:     "deny",
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/rules")
+	c.Check(cs.req.URL.Query().Get("snap"), check.Equals, "foo")
+}
+
+func (cs *clientSuite) TestClientRemovePromptRule(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	err := cs.cli.RemovePromptRule("7")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/prompting/rules")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "remove",
+		"id":     "7",
+	})
+}
+
+func (cs *clientSuite) TestClientPromptRequestsError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 403, "result": {"message": "cannot determine the user of the request"}}`
+	_, err := cs.cli.PromptRequests()
+	c.Check(err, check.ErrorMatches, `cannot list prompt requests: cannot determine the user of the request`)
+}
@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Secrets returns the names of the secrets stored in snapd.
+func (client *Client) Secrets() ([]string, error) {
+	var names []string
+	if _, err := client.doSync("GET", "/v2/secrets", nil, nil, nil, &names); err != nil {
+		return nil, fmt.Errorf("cannot list secrets: %v", err)
+	}
+	return names, nil
+}
+
+type secretAction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+}
+
+func (client *Client) doSecretAction(action *secretAction) error {
+	b, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("POST", "/v2/secrets", nil, nil, bytes.NewReader(b), nil)
+	return err
+}
+
+// SetSecret stores value as the secret with the given name.
+func (client *Client) SetSecret(name, value string) error {
+	return client.doSecretAction(&secretAction{Action: "set", Name: name, Value: value})
+}
+
+// RemoveSecret removes the secret with the given name.
+func (client *Client) RemoveSecret(name string) error {
+	return client.doSecretAction(&secretAction{Action: "remove", Name: name})
+}
@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+)
+
+func (cs *clientSuite) TestClientSecrets(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": ["api-token", "db-password"]}`
+	names, err := cs.cli.Secrets()
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"api-token", "db-password"})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/secrets")
+}
+
+func (cs *clientSuite) TestClientSetSecret(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	err := cs.cli.SetSecret("db-password", "s3cr3t")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/secrets")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "set",
+		"name":   "db-password",
+		"value":  "s3cr3t",
+	})
+}
+
+func (cs *clientSuite) TestClientRemoveSecret(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	err := cs.cli.RemoveSecret("db-password")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+
+	var body map[string]interface{}
+	err = json.NewDecoder(cs.req.Body).Decode(&body)
+	c.Assert(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "remove",
+		"name":   "db-password",
+	})
+}
+
+func (cs *clientSuite) TestClientRemoveSecretError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "cannot find secret \"foo\""}}`
+	err := cs.cli.RemoveSecret("foo")
+	c.Check(err, check.ErrorMatches, `cannot find secret "foo"`)
+}
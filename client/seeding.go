@@ -0,0 +1,48 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+// SeedingSnap holds the progress of seeding one snap.
+type SeedingSnap struct {
+	Name      string `json:"name"`
+	Essential bool   `json:"essential,omitempty"`
+	Status    string `json:"status"`
+	Done      int    `json:"done"`
+	Total     int    `json:"total"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SeedingStatus describes how far seeding the system is.
+type SeedingStatus struct {
+	Seeded bool          `json:"seeded"`
+	Phase  string        `json:"phase"`
+	Change string        `json:"change,omitempty"`
+	Snaps  []SeedingSnap `json:"snaps,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// Seeding returns the status of seeding the system.
+func (client *Client) Seeding() (*SeedingStatus, error) {
+	var status SeedingStatus
+	if _, err := client.doSync("GET", "/v2/seeding", nil, nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
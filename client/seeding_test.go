@@ -0,0 +1,49 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientSeeding(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "seeded": false,
+  "phase": "install",
+  "change": "1",
+  "snaps": [
+    {"name": "core", "essential": true, "status": "Done", "done": 5, "total": 5},
+    {"name": "foo", "status": "Error", "done": 3, "total": 5, "error": "cannot mount"}
+  ]}}`
+	status, err := cs.cli.Seeding()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/seeding")
+	c.Check(status, check.DeepEquals, &client.SeedingStatus{
+		Phase:  "install",
+		Change: "1",
+		Snaps: []client.SeedingSnap{
+			{Name: "core", Essential: true, Status: "Done", Done: 5, Total: 5},
+			{Name: "foo", Status: "Error", Done: 3, Total: 5, Error: "cannot mount"},
+		},
+	})
+}
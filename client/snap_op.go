@@ -32,12 +32,22 @@ import (
 type SnapOptions struct {
 	Channel          string `json:"channel,omitempty"`
 	Revision         string `json:"revision,omitempty"`
+	Source           string `json:"source,omitempty"`
+	Store            string `json:"store,omitempty"`
+	BranchExpiry     string `json:"branch-expiry,omitempty"`
 	DevMode          bool   `json:"devmode,omitempty"`
 	JailMode         bool   `json:"jailmode,omitempty"`
 	Classic          bool   `json:"classic,omitempty"`
 	Dangerous        bool   `json:"dangerous,omitempty"`
 	IgnoreValidation bool   `json:"ignore-validation,omitempty"`
 	Unaliased        bool   `json:"unaliased,omitempty"`
+	ForeignArch      bool   `json:"foreign-arch,omitempty"`
+	// ForceBreaks removes the snaps even if installed snaps depend on
+	// them.
+	ForceBreaks bool `json:"force-breaks,omitempty"`
+	// WithConfig restores, when reverting, the configuration the
+	// snap had with the revision reverted to.
+	WithConfig bool `json:"with-config,omitempty"`
 }
 
 func (opts *SnapOptions) writeModeFields(mw *multipart.Writer) error {
@@ -49,6 +59,7 @@ func (opts *SnapOptions) writeModeFields(mw *multipart.Writer) error {
 		{"classic", opts.Classic},
 		{"jailmode", opts.JailMode},
 		{"dangerous", opts.Dangerous},
+		{"foreign-arch", opts.ForeignArch},
 	}
 	for _, o := range fields {
 		if !o.b {
@@ -71,8 +82,9 @@ type actionData struct {
 }
 
 type multiActionData struct {
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
+	Action      string   `json:"action"`
+	Snaps       []string `json:"snaps,omitempty"`
+	ForceBreaks bool     `json:"force-breaks,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -94,6 +106,50 @@ func (client *Client) RemoveMany(names []string, options *SnapOptions) (changeID
 	return client.doMultiSnapAction("remove", names, options)
 }
 
+// BrokenConnection is a connection that removing a snap would break.
+type BrokenConnection struct {
+	Plug      PlugRef `json:"plug"`
+	Slot      SlotRef `json:"slot"`
+	Interface string  `json:"interface"`
+}
+
+// RemovalImpact tells which installed snaps depend on a snap, and so
+// would break if it were removed.
+type RemovalImpact struct {
+	Snap string `json:"snap"`
+	// BaseOf lists the snaps using the snap as their base.
+	BaseOf []string `json:"base-of,omitempty"`
+	// DefaultProviderOf lists the snaps with content plugs naming the
+	// snap as their default provider.
+	DefaultProviderOf []string `json:"default-provider-of,omitempty"`
+	// ContentConsumers lists the snaps with plugs connected to the
+	// content slots of the snap.
+	ContentConsumers []string `json:"content-consumers,omitempty"`
+	// Connections lists the plugs of other snaps connected to the slots
+	// of the snap.
+	Connections []BrokenConnection `json:"connections,omitempty"`
+}
+
+// RemovalImpact returns what removing the snap with the given name would
+// break, without removing it.
+func (client *Client) RemovalImpact(name string) (*RemovalImpact, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"action":  "remove",
+		"dry-run": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal snap action: %s", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	var impact RemovalImpact
+	if _, err := client.doSync("POST", fmt.Sprintf("/v2/snaps/%s", name), nil, headers, bytes.NewBuffer(data), &impact); err != nil {
+		return nil, err
+	}
+	return &impact, nil
+}
+
 // Refresh refreshes the snap with the given name (switching it to track
 // the given channel if given).
 func (client *Client) Refresh(name string, options *SnapOptions) (changeID string, err error) {
@@ -124,10 +180,15 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 
 var ErrDangerousNotApplicable = fmt.Errorf("dangerous option only meaningful when installing from a local file")
 
+var ErrForeignArchNotApplicable = fmt.Errorf("foreign-arch option only meaningful when installing from a local file")
+
 func (client *Client) doSnapAction(actionName string, snapName string, options *SnapOptions) (changeID string, err error) {
 	if options != nil && options.Dangerous {
 		return "", ErrDangerousNotApplicable
 	}
+	if options != nil && options.ForeignArch {
+		return "", ErrForeignArchNotApplicable
+	}
 	action := actionData{
 		Action:      actionName,
 		SnapOptions: options,
@@ -146,13 +207,16 @@ func (client *Client) doSnapAction(actionName string, snapName string, options *
 }
 
 func (client *Client) doMultiSnapAction(actionName string, snaps []string, options *SnapOptions) (changeID string, err error) {
-	if options != nil {
-		return "", fmt.Errorf("cannot use options for multi-action") // (yet)
-	}
 	action := multiActionData{
 		Action: actionName,
 		Snaps:  snaps,
 	}
+	if options != nil {
+		if *options != (SnapOptions{ForceBreaks: options.ForceBreaks}) {
+			return "", fmt.Errorf("cannot use options for multi-action") // (yet)
+		}
+		action.ForceBreaks = options.ForceBreaks
+	}
 	data, err := json.Marshal(&action)
 	if err != nil {
 		return "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
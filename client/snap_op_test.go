@@ -172,6 +172,58 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRemoveManyForceBreaks(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	_, err := cs.cli.RemoveMany([]string{pkgName}, &client.SnapOptions{ForceBreaks: true})
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":       "remove",
+		"snaps":        []interface{}{pkgName},
+		"force-breaks": true,
+	})
+
+	_, err = cs.cli.RemoveMany([]string{pkgName}, &client.SnapOptions{ForceBreaks: true, Channel: "beta"})
+	c.Check(err, check.ErrorMatches, "cannot use options for multi-action")
+}
+
+func (cs *clientSuite) TestClientRemovalImpact(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"snap": "producer",
+			"base-of": ["app-one"],
+			"content-consumers": ["app-one"],
+			"connections": [{"plug": {"snap": "app-one", "plug": "data"}, "slot": {"snap": "producer", "slot": "data"}, "interface": "content"}]
+		}
+	}`
+	impact, err := cs.cli.RemovalImpact("producer")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/producer")
+	var jsonBody map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":  "remove",
+		"dry-run": true,
+	})
+	c.Check(impact, check.DeepEquals, &client.RemovalImpact{
+		Snap:             "producer",
+		BaseOf:           []string{"app-one"},
+		ContentConsumers: []string{"app-one"},
+		Connections: []client.BrokenConnection{{
+			Plug:      client.PlugRef{Snap: "app-one", Name: "data"},
+			Slot:      client.SlotRef{Snap: "producer", Name: "data"},
+			Interface: "content",
+		}},
+	})
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
@@ -235,6 +287,33 @@ func (cs *clientSuite) TestClientOpInstallDangerous(c *check.C) {
 	c.Assert(err, check.NotNil)
 }
 
+func (cs *clientSuite) TestClientOpInstallForeignArch(c *check.C) {
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	snap := filepath.Join(c.MkDir(), "foo.snap")
+	err := ioutil.WriteFile(snap, []byte("snap-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	opts := client.SnapOptions{
+		ForeignArch: true,
+	}
+
+	// InstallPath takes ForeignArch
+	_, err = cs.cli.InstallPath(snap, &opts)
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"foreign-arch\"\r\n\r\ntrue\r\n.*")
+
+	// Install does not
+	_, err = cs.cli.Install("foo", &opts)
+	c.Assert(err, check.Equals, client.ErrForeignArchNotApplicable)
+}
+
 func formToMap(c *check.C, mr *multipart.Reader) map[string]string {
 	formData := map[string]string{}
 	for {
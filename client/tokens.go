@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Token describes a token allowing local automation to use some scopes
+// of the API until it expires. Scopes are of the form "<METHOD> <path>",
+// where the path can end with "*" to cover the paths it is a prefix of.
+type Token struct {
+	ID      int       `json:"id"`
+	Label   string    `json:"label,omitempty"`
+	Scopes  []string  `json:"scopes"`
+	Expires time.Time `json:"expires"`
+	// UID is the user the token is accepted from.
+	UID uint32 `json:"uid"`
+	// Token is the secret to set in Config.Token, it is only given
+	// when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+type tokenAction struct {
+	Action    string   `json:"action"`
+	ID        int      `json:"id,omitempty"`
+	Label     string   `json:"label,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ExpiresIn string   `json:"expires-in,omitempty"`
+	User      string   `json:"user,omitempty"`
+}
+
+func (client *Client) tokenAction(action *tokenAction, result interface{}) error {
+	b, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("POST", "/v2/tokens", nil, nil, bytes.NewReader(b), result)
+	return err
+}
+
+// CreateToken creates a token with the given scopes, expiring after the
+// given duration. The token is accepted from the user with the given name
+// or uid, or from the user creating it if user is empty.
+func (client *Client) CreateToken(label, user string, scopes []string, expiresIn time.Duration) (*Token, error) {
+	var token Token
+	if err := client.tokenAction(&tokenAction{
+		Action:    "create",
+		Label:     label,
+		Scopes:    scopes,
+		ExpiresIn: expiresIn.String(),
+		User:      user,
+	}, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Tokens lists the tokens that have not expired, without their secrets.
+func (client *Client) Tokens() ([]*Token, error) {
+	var tokens []*Token
+	if _, err := client.doSync("GET", "/v2/tokens", nil, nil, nil, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeToken revokes the token with the given ID.
+func (client *Client) RevokeToken(id int) error {
+	return client.tokenAction(&tokenAction{Action: "revoke", ID: id}, nil)
+}
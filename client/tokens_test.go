@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientCreateToken(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id": 1,
+  "label": "ci",
+  "scopes": ["GET /v2/changes"],
+  "expires": "2018-04-01T12:00:00Z",
+  "uid": 1000,
+  "token": "s3cr3t"}}`
+	token, err := cs.cli.CreateToken("ci", "someuser", []string{"GET /v2/changes"}, 90*time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/tokens")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var action map[string]interface{}
+	c.Assert(json.Unmarshal(body, &action), check.IsNil)
+	c.Check(action, check.DeepEquals, map[string]interface{}{
+		"action":     "create",
+		"label":      "ci",
+		"scopes":     []interface{}{"GET /v2/changes"},
+		"expires-in": "1h30m0s",
+		"user":       "someuser",
+	})
+	c.Check(token, check.DeepEquals, &client.Token{
+		ID:      1,
+		Label:   "ci",
+		Scopes:  []string{"GET /v2/changes"},
+		Expires: time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC),
+		UID:     1000,
+		Token:   "s3cr3t",
+	})
+}
+
+func (cs *clientSuite) TestClientTokens(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+  "id": 1,
+  "scopes": ["GET /v2/changes"],
+  "expires": "2018-04-01T12:00:00Z"}]}`
+	tokens, err := cs.cli.Tokens()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/tokens")
+	c.Check(tokens, check.DeepEquals, []*client.Token{{
+		ID:      1,
+		Scopes:  []string{"GET /v2/changes"},
+		Expires: time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC),
+	}})
+}
+
+func (cs *clientSuite) TestClientRevokeToken(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+	err := cs.cli.RevokeToken(3)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/tokens")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"revoke","id":3}`)
+}
@@ -6,29 +6,105 @@
 #include <errno.h>
 #include <fcntl.h>
 #include <limits.h>
+#include <stdio.h>
 #include <string.h>
 #include <sys/stat.h>
 #include <sys/types.h>
+#include <sys/vfs.h>
 #include <unistd.h>
 
 #include "cleanup-funcs.h"
 #include "string-utils.h"
 #include "utils.h"
 
+#ifndef CGROUP2_SUPER_MAGIC
+#define CGROUP2_SUPER_MAGIC 0x63677270
+#endif
+
+static const char *cgroup_dir = "/sys/fs/cgroup";
 static const char *freezer_cgroup_dir = "/sys/fs/cgroup/freezer";
 
+bool sc_cgroup_is_v2(void)
+{
+	struct statfs buf;
+	if (statfs(cgroup_dir, &buf) < 0) {
+		if (errno == ENOENT) {
+			return false;
+		}
+		die("cannot statfs %s", cgroup_dir);
+	}
+	return buf.f_type == CGROUP2_SUPER_MAGIC;
+}
+
+// sc_cgroup_v2_own_group stores in buf the directory of the group the calling
+// process belongs to in the unified hierarchy, as listed by the "0::" entry of
+// /proc/self/cgroup.
+static void sc_cgroup_v2_own_group(char *buf, size_t buf_size)
+{
+	FILE *f SC_CLEANUP(sc_cleanup_file) = NULL;
+	f = fopen("/proc/self/cgroup", "r");
+	if (f == NULL) {
+		die("cannot open /proc/self/cgroup");
+	}
+	char line[PATH_MAX + 8];
+	while (fgets(line, sizeof line, f) != NULL) {
+		if (strncmp(line, "0::/", strlen("0::/")) != 0) {
+			continue;
+		}
+		// Chop the trailing newline and the "0::" prefix.
+		char *path = line + strlen("0::");
+		size_t len = strlen(path);
+		if (len > 0 && path[len - 1] == '\n') {
+			path[len - 1] = '\0';
+		}
+		if (strcmp(path, "/") == 0) {
+			path = "";
+		}
+		sc_must_snprintf(buf, buf_size, "%s%s", cgroup_dir, path);
+		return;
+	}
+	die("cannot find the unified cgroup of the current process");
+}
+
 void sc_cgroup_freezer_join(const char *snap_name, pid_t pid)
 {
-	// Format the name of the cgroup hierarchy. 
+	// Format the name of the cgroup hierarchy.
 	char buf[PATH_MAX];
 	sc_must_snprintf(buf, sizeof buf, "snap.%s", snap_name);
 
+	// Pick the hierarchy to track the processes of the snap in. With
+	// cgroup v2 freezing is available in every group, so the snap gets a
+	// group of its own below the one the process was started in, which
+	// systemd delegated to the session or service, instead of one at the
+	// root that systemd does not know about. Otherwise the v1 freezer
+	// controller is used, as long as it is there at all.
+	char parent_dir[PATH_MAX];
+	const char *procs_file = NULL;
+	if (sc_cgroup_is_v2()) {
+		sc_cgroup_v2_own_group(parent_dir, sizeof parent_dir);
+		procs_file = "cgroup.procs";
+		// Processes started from within the snap, such as another app of
+		// it, already are in the group of the snap.
+		char suffix[PATH_MAX];
+		sc_must_snprintf(suffix, sizeof suffix, "/%s", buf);
+		if (sc_endswith(parent_dir, suffix)) {
+			parent_dir[strlen(parent_dir) - strlen(suffix)] = '\0';
+		}
+	} else if (access(freezer_cgroup_dir, F_OK) == 0) {
+		sc_must_snprintf(parent_dir, sizeof parent_dir, "%s",
+				 freezer_cgroup_dir);
+		procs_file = "tasks";
+	} else {
+		debug("no freezer cgroup available, not tracking processes of snap %s", snap_name);
+		return;
+	}
+
 	// Open the freezer cgroup directory.
 	int cgroup_fd SC_CLEANUP(sc_cleanup_close) = -1;
-	cgroup_fd = open(freezer_cgroup_dir,
+	cgroup_fd = open(parent_dir,
 			 O_PATH | O_DIRECTORY | O_NOFOLLOW | O_CLOEXEC);
 	if (cgroup_fd < 0) {
-		die("cannot open freezer cgroup (%s)", freezer_cgroup_dir);
+		die("cannot open freezer cgroup (%s)", parent_dir);
 	}
 	// Create the freezer hierarchy for the given snap.
 	if (mkdirat(cgroup_fd, buf, 0755) < 0 && errno != EEXIST) {
@@ -48,12 +124,12 @@ void sc_cgroup_freezer_join(const char *snap_name, pid_t pid)
 	if (fchownat(hierarchy_fd, "", 0, 0, AT_EMPTY_PATH) < 0) {
 		die("cannot change owner of freezer cgroup hierarchy for snap %s to root.root", snap_name);
 	}
-	// Open the tasks file.
+	// Open the tasks file, or the cgroup.procs one with cgroup v2.
 	int tasks_fd SC_CLEANUP(sc_cleanup_close) = -1;
-	tasks_fd = openat(hierarchy_fd, "tasks",
+	tasks_fd = openat(hierarchy_fd, procs_file,
 			  O_WRONLY | O_NOFOLLOW | O_CLOEXEC);
 	if (tasks_fd < 0) {
-		die("cannot open tasks file for freezer cgroup hierarchy for snap %s", snap_name);
+		die("cannot open %s file for freezer cgroup hierarchy for snap %s", procs_file, snap_name);
 	}
 	// Write the process (task) number to the tasks file. Linux task IDs are
 	// limited to 2^29 so a long int is enough to represent it.
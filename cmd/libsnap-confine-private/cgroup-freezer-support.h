@@ -1,9 +1,19 @@
 #ifndef SC_CGROUP_FREEZER_SUPPORT_H
 #define SC_CGROUP_FREEZER_SUPPORT_H
 
+#include <stdbool.h>
 #include <sys/types.h>
 #include "error.h"
 
+/**
+ * Check if the cgroup hierarchy is the unified (v2) one.
+ *
+ * On hybrid systems /sys/fs/cgroup still holds the v1 controllers, with the
+ * unified hierarchy mounted below it, so this only returns true when the
+ * system uses cgroup v2 exclusively.
+ **/
+bool sc_cgroup_is_v2(void);
+
 /**
  * Join the freezer cgroup for the given snap.
  *
@@ -18,8 +28,19 @@
  * processes that originate from the given snap. Examining that file one can
  * reliably determine if the set is empty or not.
  *
+ * With cgroup v2 there is no freezer controller to join, freezing is built
+ * into every group of the unified hierarchy. The task is then moved to a
+ * "snap.$snap_name" group created below the group it was started in, that is
+ * inside the subtree systemd delegated to the session or service, and the
+ * processes are listed in its "cgroup.procs" file. A snap may thus have such
+ * a group in several places of the hierarchy.
+ *
+ * If neither is available, as on hybrid systems without the v1 freezer
+ * controller, the processes of the snap are not tracked.
+ *
  * For more details please review:
  * https://www.kernel.org/doc/Documentation/cgroup-v1/freezer-subsystem.txt
+ * https://www.kernel.org/doc/Documentation/cgroup-v2.txt
 **/
 void sc_cgroup_freezer_join(const char *snap_name, pid_t pid);
 
@@ -60,6 +60,7 @@ apps:
  app:
   command: run-app cmd-arg1
   stop-command: stop-app
+  reload-command: reload-app
   post-stop-command: post-stop-app
   environment:
    BASE_PATH: /some/path
@@ -106,6 +107,7 @@ func (s *snapExecSuite) TestFindCommand(c *C) {
 	}{
 		{cmd: "", expected: `run-app cmd-arg1`},
 		{cmd: "stop", expected: "stop-app"},
+		{cmd: "reload", expected: "reload-app"},
 		{cmd: "post-stop", expected: "post-stop-app"},
 	} {
 		cmd, err := findCommand(info.Apps["app"], t.cmd)
@@ -28,6 +28,7 @@ package main
 //#include <errno.h>
 //#include <linux/can.h>
 //#include <linux/netlink.h>
+//#include <linux/perf_event.h>
 //#include <sched.h>
 //#include <search.h>
 //#include <stdbool.h>
@@ -83,6 +84,10 @@ package main
 //#define PR_GET_THP_DISABLE 42
 //#endif				// PR_GET_THP_DISABLE
 //
+//#ifndef PERF_FLAG_FD_CLOEXEC
+//#define PERF_FLAG_FD_CLOEXEC (1UL << 3)
+//#endif				// PERF_FLAG_FD_CLOEXEC
+//
 //#ifndef PR_MPX_ENABLE_MANAGEMENT
 //#define PR_MPX_ENABLE_MANAGEMENT 43
 //#endif
@@ -329,6 +334,12 @@ var seccompResolver = map[string]uint64{
 	"CLONE_NEWUSER": syscall.CLONE_NEWUSER,
 	"CLONE_NEWUTS":  syscall.CLONE_NEWUTS,
 
+	// man 2 perf_event_open
+	"PERF_FLAG_FD_NO_GROUP": C.PERF_FLAG_FD_NO_GROUP,
+	"PERF_FLAG_FD_OUTPUT":   C.PERF_FLAG_FD_OUTPUT,
+	"PERF_FLAG_PID_CGROUP":  C.PERF_FLAG_PID_CGROUP,
+	"PERF_FLAG_FD_CLOEXEC":  C.PERF_FLAG_FD_CLOEXEC,
+
 	// man 4 tty_ioctl
 	"TIOCSTI": syscall.TIOCSTI,
 
@@ -449,6 +460,19 @@ func (sc *SeccompData) SetArgs(args [6]uint64) {
 }
 
 func readNumber(token string) (uint64, error) {
+	// flags can be OR-ed together, as in
+	// PERF_FLAG_FD_CLOEXEC|PERF_FLAG_FD_NO_GROUP
+	if strings.Contains(token, "|") {
+		var value uint64
+		for _, flag := range strings.Split(token, "|") {
+			flagValue, err := readNumber(flag)
+			if err != nil {
+				return 0, err
+			}
+			value |= flagValue
+		}
+		return value, nil
+	}
 	if value, ok := seccompResolver[token]; ok {
 		return value, nil
 	}
@@ -724,6 +724,28 @@ func (s *snapSeccompSuite) TestRestrictionsWorkingArgsTermios(c *C) {
 	}
 }
 
+func (s *snapSeccompSuite) TestRestrictionsWorkingArgsPerfEvent(c *C) {
+	// PERF_FLAG_FD_CLOEXEC is 8, PERF_FLAG_FD_NO_GROUP 1 and
+	// PERF_FLAG_FD_OUTPUT 2
+	for _, t := range []struct {
+		seccompWhitelist string
+		bpfInput         string
+		expected         int
+	}{
+		// good input
+		{"perf_event_open - - - - 0", "perf_event_open;native;-,-,-,-,0", main.SeccompRetAllow},
+		{"perf_event_open - - - - PERF_FLAG_FD_CLOEXEC", "perf_event_open;native;-,-,-,-,PERF_FLAG_FD_CLOEXEC", main.SeccompRetAllow},
+		{"perf_event_open - - - - PERF_FLAG_FD_NO_GROUP", "perf_event_open;native;-,-,-,-,PERF_FLAG_FD_NO_GROUP", main.SeccompRetAllow},
+		{"perf_event_open - - - - PERF_FLAG_FD_CLOEXEC|PERF_FLAG_FD_NO_GROUP", "perf_event_open;native;-,-,-,-,9", main.SeccompRetAllow},
+		// bad input
+		{"perf_event_open - - - - 0", "perf_event_open;native;-,-,-,-,PERF_FLAG_PID_CGROUP", main.SeccompRetKill},
+		{"perf_event_open - - - - PERF_FLAG_FD_CLOEXEC", "perf_event_open;native;-,-,-,-,PERF_FLAG_FD_OUTPUT", main.SeccompRetKill},
+		{"perf_event_open - - - - PERF_FLAG_FD_CLOEXEC|PERF_FLAG_FD_NO_GROUP", "perf_event_open;native;-,-,-,-,10", main.SeccompRetKill},
+	} {
+		s.runBpf(c, t.seccompWhitelist, t.bpfInput, t.expected)
+	}
+}
+
 func (s *snapSeccompSuite) TestRestrictionsWorkingArgsUidGid(c *C) {
 	for _, t := range []struct {
 		seccompWhitelist string
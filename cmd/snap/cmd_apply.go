@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/strutil"
+)
+
+type cmdApply struct {
+	DryRun      bool `long:"dry-run"`
+	Positionals struct {
+		Manifest flags.Filename `required:"1"`
+	} `positional-args:"true"`
+}
+
+var shortApplyHelp = i18n.G("Brings the device to the state described by a manifest")
+var longApplyHelp = i18n.G(`
+The apply command installs and refreshes snaps, makes connections and
+sets configuration as needed for the device to match the given manifest,
+all in one change. Snaps, connections and configuration the manifest
+doesn't mention are left alone.
+
+A manifest looks like:
+
+    snaps:
+      foo:
+        channel: beta
+      bar:
+        revision: 7
+    connections:
+      - plug: foo:camera
+        slot: core:camera
+    config:
+      foo:
+        some.key: some value
+
+Connections involving snaps the manifest installs are made by applying
+it again once they are installed.
+
+With --dry-run, the command only lists what it would do.
+`)
+
+func init() {
+	addCommand("apply", shortApplyHelp, longApplyHelp, func() flags.Commander {
+		return &cmdApply{}
+	}, map[string]string{
+		"dry-run": i18n.G("Only list what applying the manifest would do"),
+	}, []argDesc{{
+		name: i18n.G("<manifest>"),
+		desc: i18n.G("The manifest to apply"),
+	}})
+}
+
+// manifestYAML is the format of the manifests read by 'snap apply'.
+type manifestYAML struct {
+	Snaps       map[string]*manifestSnapYAML      `yaml:"snaps"`
+	Connections []profileConnection               `yaml:"connections"`
+	Config      map[string]map[string]interface{} `yaml:"config"`
+}
+
+type manifestSnapYAML struct {
+	Channel  string `yaml:"channel"`
+	Revision string `yaml:"revision"`
+	DevMode  bool   `yaml:"devmode"`
+	JailMode bool   `yaml:"jailmode"`
+	Classic  bool   `yaml:"classic"`
+}
+
+// readManifest reads the manifest at the given path.
+func readManifest(path string) (*client.Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read manifest: %v"), err)
+	}
+	var my manifestYAML
+	if err := yaml.Unmarshal(data, &my); err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot parse manifest %q: %v"), path, err)
+	}
+
+	m := &client.Manifest{}
+	if len(my.Snaps) > 0 {
+		m.Snaps = make(map[string]*client.ManifestSnap, len(my.Snaps))
+		for name, s := range my.Snaps {
+			if s == nil {
+				s = &manifestSnapYAML{}
+			}
+			m.Snaps[name] = &client.ManifestSnap{
+				Channel:  s.Channel,
+				Revision: s.Revision,
+				DevMode:  s.DevMode,
+				JailMode: s.JailMode,
+				Classic:  s.Classic,
+			}
+		}
+	}
+	m.Connections, err = profileConnections(my.Connections, fmt.Sprintf(i18n.G("manifest %q"), path))
+	if err != nil {
+		return nil, err
+	}
+	if len(my.Config) > 0 {
+		m.Config = make(map[string]map[string]interface{}, len(my.Config))
+		for name, conf := range my.Config {
+			m.Config[name] = make(map[string]interface{}, len(conf))
+			for key, value := range conf {
+				// yaml decodes maps with interface{} keys, which
+				// can't be sent as JSON
+				value, err := jsonableYAMLValue(value)
+				if err != nil {
+					return nil, fmt.Errorf(i18n.G("invalid value of %s.%s in manifest %q: %v"), name, key, path, err)
+				}
+				m.Config[name][key] = value
+			}
+		}
+	}
+	return m, nil
+}
+
+// jsonableYAMLValue returns the given value decoded from yaml with its
+// maps turned into maps with string keys.
+func jsonableYAMLValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", k)
+			}
+			item, err := jsonableYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = item
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := jsonableYAMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = item
+		}
+		return l, nil
+	}
+	return v, nil
+}
+
+func (x *cmdApply) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	m, err := readManifest(string(x.Positionals.Manifest))
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	if x.DryRun {
+		plan, err := cli.PlanManifest(m)
+		if err != nil {
+			return err
+		}
+		showManifestPlan(plan)
+		return nil
+	}
+
+	id, err := cli.ApplyManifest(m)
+	if err != nil {
+		return err
+	}
+	chg, err := wait(cli, id)
+	if err != nil {
+		return err
+	}
+
+	var deferred []client.Connection
+	if err := chg.Get("deferred-connections", &deferred); err != nil && err != client.ErrNoData {
+		return err
+	}
+	if len(deferred) > 0 {
+		fmt.Fprintln(Stdout, i18n.G("The manifest needs to be applied again to make these connections:"))
+		showManifestConnections(deferred)
+	}
+	return nil
+}
+
+func showManifestPlan(plan *client.ManifestPlan) {
+	if len(plan.Tasks) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("The device matches the manifest already."))
+	} else {
+		fmt.Fprintf(Stdout, "%s:\n", plan.Summary)
+		for _, t := range plan.Tasks {
+			fmt.Fprintf(Stdout, "  - %s\n", t.Summary)
+		}
+		if plan.DownloadSize > 0 {
+			fmt.Fprintf(Stdout, i18n.G("Download size: %s\n"), strutil.SizeToStr(plan.DownloadSize))
+		}
+		if plan.Restart != "" {
+			fmt.Fprintf(Stdout, i18n.G("Restarts: %s\n"), plan.Restart)
+		}
+	}
+	if len(plan.DeferredConnections) > 0 {
+		fmt.Fprintln(Stdout, i18n.G("The manifest would need to be applied again to make these connections:"))
+		showManifestConnections(plan.DeferredConnections)
+	}
+}
+
+func showManifestConnections(conns []client.Connection) {
+	for _, conn := range conns {
+		fmt.Fprintf(Stdout, "  - %s:%s to %s:%s\n", conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name)
+	}
+}
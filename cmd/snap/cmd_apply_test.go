@@ -0,0 +1,164 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+const testManifestYaml = `
+snaps:
+  foo:
+    channel: beta
+  bar:
+    revision: 7
+  baz:
+connections:
+- plug: foo:plug
+  slot: bar:slot
+config:
+  foo:
+    a: 1
+    b:
+      c: [true, {d: x}]
+`
+
+var testManifestJSON = map[string]interface{}{
+	"snaps": map[string]interface{}{
+		"foo": map[string]interface{}{"channel": "beta"},
+		"bar": map[string]interface{}{"revision": "7"},
+		"baz": map[string]interface{}{},
+	},
+	"connections": []interface{}{
+		map[string]interface{}{
+			"plug": map[string]interface{}{"snap": "foo", "plug": "plug"},
+			"slot": map[string]interface{}{"snap": "bar", "slot": "slot"},
+		},
+	},
+	"config": map[string]interface{}{
+		"foo": map[string]interface{}{
+			"a": json.Number("1"),
+			"b": map[string]interface{}{
+				"c": []interface{}{true, map[string]interface{}{"d": "x"}},
+			},
+		},
+	},
+}
+
+func (s *SnapSuite) writeManifest(c *C, content string) string {
+	path := filepath.Join(c.MkDir(), "manifest.yaml")
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	return path
+}
+
+func (s *SnapSuite) TestApply(c *C) {
+	manifest := s.writeManifest(c, testManifestYaml)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/manifest":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"manifest": testManifestJSON,
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {"deferred-connections": [
+{"plug": {"snap": "baz", "plug": "plug"}, "slot": {"snap": "bar", "slot": "slot"}}]}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser().ParseArgs([]string{"apply", manifest})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"The manifest needs to be applied again to make these connections:\n"+
+		"  - baz:plug to bar:slot\n")
+}
+
+func (s *SnapSuite) TestApplyDryRun(c *C) {
+	manifest := s.writeManifest(c, testManifestYaml)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/manifest")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+			"manifest": testManifestJSON,
+			"dry-run":  true,
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+"kind": "apply-manifest",
+"summary": "Apply manifest to 2 snaps",
+"snaps": ["bar", "foo"],
+"tasks": [
+  {"kind": "download-snap", "summary": "Download snap \"bar\" (7) from channel \"stable\""},
+  {"kind": "run-hook", "summary": "Run configure hook of \"foo\" snap"}
+],
+"download-size": 2048,
+"deferred-connections": [{"plug": {"snap": "baz", "plug": "plug"}, "slot": {"snap": "bar", "slot": "slot"}}]
+}}`)
+	})
+	_, err := Parser().ParseArgs([]string{"apply", "--dry-run", manifest})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Apply manifest to 2 snaps:\n"+
+		"  - Download snap \"bar\" (7) from channel \"stable\"\n"+
+		"  - Run configure hook of \"foo\" snap\n"+
+		"Download size: 2kB\n"+
+		"The manifest would need to be applied again to make these connections:\n"+
+		"  - baz:plug to bar:slot\n")
+}
+
+func (s *SnapSuite) TestApplyDryRunNothingToDo(c *C) {
+	manifest := s.writeManifest(c, "snaps: {foo: {}}")
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"kind": "apply-manifest", "summary": "Apply manifest to 0 snaps", "snaps": [], "tasks": []}}`)
+	})
+	_, err := Parser().ParseArgs([]string{"apply", "--dry-run", manifest})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "The device matches the manifest already.\n")
+}
+
+func (s *SnapSuite) TestApplyErrors(c *C) {
+	for _, t := range []struct {
+		content string
+		err     string
+	}{
+		{"snaps: [", `cannot parse manifest ".*": .*`},
+		{"connections:\n- plug: foo\n  slot: bar:slot\n", `invalid plug in manifest ".*": "foo" \(want snap:plug\)`},
+		{"config:\n  foo:\n    a: {1: x}\n", `invalid value of foo.a in manifest ".*": key 1 is not a string`},
+	} {
+		_, err := Parser().ParseArgs([]string{"apply", s.writeManifest(c, t.content)})
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.content))
+	}
+
+	_, err := Parser().ParseArgs([]string{"apply", filepath.Join(c.MkDir(), "missing.yaml")})
+	c.Check(err, ErrorMatches, `cannot read manifest: .*`)
+}
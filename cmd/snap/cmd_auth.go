@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdAuth struct{}
+
+var shortAuthHelp = i18n.G("Manages tokens for local automation")
+var longAuthHelp = i18n.G(`
+The auth command contains sub-commands to manage tokens that let
+automation running as a regular user use parts of the snapd API that
+otherwise need root.
+
+A token is used by setting SNAPD_AUTH_TOKEN in the environment of snap
+commands, or by sending it in an "Authorization: Token <token>" header.
+`)
+
+type cmdCreateToken struct {
+	Label      string        `long:"label"`
+	User       string        `long:"user"`
+	ExpiresIn  time.Duration `long:"expires-in" default:"1h"`
+	Positional struct {
+		Scopes []string `required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortCreateTokenHelp = i18n.G("Create a token for local automation")
+var longCreateTokenHelp = i18n.G(`
+The create-token command creates a token allowing requests to the given
+scopes of the snapd API until it expires, and prints it.
+
+A scope is a method and an API path, such as "GET /v2/changes"; a path
+ending with "*" covers all the paths it is a prefix of, as in
+"POST /v2/snaps/*".
+
+The token is only accepted from processes of the user given with --user,
+by name or uid, and by default from processes of the user creating it.
+`)
+
+type cmdTokens struct{}
+
+var shortTokensHelp = i18n.G("List the tokens for local automation")
+var longTokensHelp = i18n.G(`
+The tokens command lists the tokens that have not expired yet.
+`)
+
+type cmdRevokeToken struct {
+	Positional struct {
+		ID int `required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortRevokeTokenHelp = i18n.G("Revoke a token for local automation")
+var longRevokeTokenHelp = i18n.G(`
+The revoke-token command revokes the token with the given ID, as listed
+by 'snap auth tokens'.
+`)
+
+func init() {
+	addAuthCommand("create-token", shortCreateTokenHelp, longCreateTokenHelp, func() flags.Commander {
+		return &cmdCreateToken{}
+	}, map[string]string{
+		"label":      i18n.G("A label to tell the token apart"),
+		"user":       i18n.G("The user the token is accepted from"),
+		"expires-in": i18n.G("How long the token is valid for"),
+	}, []argDesc{{
+		name: i18n.G("<scope>"),
+		desc: i18n.G("A method and API path the token allows"),
+	}})
+	addAuthCommand("tokens", shortTokensHelp, longTokensHelp, func() flags.Commander {
+		return &cmdTokens{}
+	}, nil, nil)
+	addAuthCommand("revoke-token", shortRevokeTokenHelp, longRevokeTokenHelp, func() flags.Commander {
+		return &cmdRevokeToken{}
+	}, nil, []argDesc{{
+		name: i18n.G("<id>"),
+		desc: i18n.G("The ID of the token"),
+	}})
+}
+
+func (x *cmdCreateToken) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	token, err := Client().CreateToken(x.Label, x.User, x.Positional.Scopes, x.ExpiresIn)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(Stdout, token.Token)
+	return nil
+}
+
+func (x *cmdTokens) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	tokens, err := Client().Tokens()
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No tokens."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("ID\tLabel\tExpires\tScopes"))
+	for _, token := range tokens {
+		label := token.Label
+		if label == "" {
+			label = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", token.ID, label, token.Expires.UTC().Format(time.RFC3339), strings.Join(token.Scopes, ", "))
+	}
+	w.Flush()
+	return nil
+}
+
+func (x *cmdRevokeToken) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	return Client().RevokeToken(x.Positional.ID)
+}
@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestAuthCreateToken(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/tokens")
+		var action map[string]interface{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&action), check.IsNil)
+		c.Check(action, check.DeepEquals, map[string]interface{}{
+			"action":     "create",
+			"label":      "ci",
+			"scopes":     []interface{}{"GET /v2/changes", "POST /v2/snaps/*"},
+			"expires-in": "30m0s",
+			"user":       "someuser",
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": {"id": 1, "label": "ci", "scopes": ["GET /v2/changes", "POST /v2/snaps/*"], "expires": "2018-04-01T12:00:00Z", "token": "s3cr3t"}}`)
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"auth", "create-token", "--label=ci", "--user=someuser", "--expires-in=30m", "GET /v2/changes", "POST /v2/snaps/*"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "s3cr3t\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestAuthCreateTokenDefaultExpiry(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		var action map[string]interface{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&action), check.IsNil)
+		c.Check(action["expires-in"], check.Equals, "1h0m0s")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"id": 1, "scopes": ["GET /v2/changes"], "expires": "2018-04-01T12:00:00Z", "token": "s3cr3t"}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"auth", "create-token", "GET /v2/changes"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "s3cr3t\n")
+}
+
+func (s *SnapSuite) TestAuthTokens(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/tokens")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+{"id": 1, "label": "ci", "scopes": ["GET /v2/changes", "POST /v2/snaps/*"], "expires": "2018-04-01T12:00:00Z"},
+{"id": 3, "scopes": ["GET /v2/snaps"], "expires": "2018-04-02T12:00:00Z"}
+]}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"auth", "tokens"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `ID   Label  Expires               Scopes
+1    ci     2018-04-01T12:00:00Z  GET /v2/changes, POST /v2/snaps/*
+3    -      2018-04-02T12:00:00Z  GET /v2/snaps
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestAuthTokensNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"auth", "tokens"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No tokens.\n")
+}
+
+func (s *SnapSuite) TestAuthRevokeToken(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/tokens")
+		var action map[string]interface{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&action), check.IsNil)
+		c.Check(action, check.DeepEquals, map[string]interface{}{
+			"action": "revoke",
+			"id":     3.0,
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": null}`)
+		n++
+	})
+	_, err := snap.Parser().ParseArgs([]string{"auth", "revoke-token", "3"})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "")
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdCleanup struct {
+	Orphans bool `long:"orphans" description:"Clean up data left behind by snaps, or snap revisions, that are no longer installed"`
+	DryRun  bool `long:"dry-run" description:"Only report what would be removed"`
+}
+
+var shortCleanupHelp = i18n.G("(internal) clean up data snapd no longer needs")
+var longCleanupHelp = i18n.G(`
+The cleanup command removes data that snapd no longer needs.
+
+With --orphans, it removes the /var/snap/<snap> and ~/snap/<snap>
+directories, the snap files in /var/lib/snapd/snaps and the mount units
+left behind by snaps, or revisions of snaps, that are no longer
+installed.
+`)
+
+func init() {
+	addDebugCommand("cleanup", shortCleanupHelp, longCleanupHelp, func() flags.Commander {
+		return &cmdCleanup{}
+	})
+}
+
+type orphan struct {
+	Kind string `json:"kind"`
+	Snap string `json:"snap"`
+	Path string `json:"path"`
+}
+
+func (x *cmdCleanup) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if !x.Orphans {
+		return fmt.Errorf(i18n.G("nothing to clean up, please use --orphans"))
+	}
+
+	params := map[string]bool{"dry-run": x.DryRun}
+	var orphans []orphan
+	if err := Client().Debug("cleanup-orphans", params, &orphans); err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No orphaned snap data found."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Kind\tSnap\tPath"))
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", o.Kind, o.Snap, o.Path)
+	}
+	w.Flush()
+
+	if x.DryRun {
+		fmt.Fprintf(Stdout, i18n.NG("%d orphan would be removed.\n", "%d orphans would be removed.\n", uint32(len(orphans))), len(orphans))
+	} else {
+		fmt.Fprintf(Stdout, i18n.NG("Removed %d orphan.\n", "Removed %d orphans.\n", uint32(len(orphans))), len(orphans))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockCleanupOrphans(c *check.C, expectedBody string, result string) *int {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug")
+			data, err := ioutil.ReadAll(r.Body)
+			c.Check(err, check.IsNil)
+			c.Check(string(data), check.Equals, expectedBody)
+			fmt.Fprintf(w, `{"type": "sync", "result": %s}`, result)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	return &n
+}
+
+const orphansJSON = `[
+{"kind": "blob", "snap": "foo", "path": "/var/lib/snapd/snaps/foo_1.snap"},
+{"kind": "data", "snap": "foo", "path": "/var/snap/foo"}
+]`
+
+func (s *SnapSuite) TestCleanupOrphans(c *check.C) {
+	n := s.mockCleanupOrphans(c, `{"action":"cleanup-orphans","params":{"dry-run":false}}`, orphansJSON)
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "cleanup", "--orphans"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(*n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `Kind  Snap  Path
+blob  foo   /var/lib/snapd/snaps/foo_1.snap
+data  foo   /var/snap/foo
+Removed 2 orphans.
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestCleanupOrphansDryRun(c *check.C) {
+	s.mockCleanupOrphans(c, `{"action":"cleanup-orphans","params":{"dry-run":true}}`, orphansJSON)
+	_, err := snap.Parser().ParseArgs([]string{"debug", "cleanup", "--orphans", "--dry-run"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Kind  Snap  Path
+blob  foo   /var/lib/snapd/snaps/foo_1.snap
+data  foo   /var/snap/foo
+2 orphans would be removed.
+`)
+}
+
+func (s *SnapSuite) TestCleanupOrphansNothingFound(c *check.C) {
+	s.mockCleanupOrphans(c, `{"action":"cleanup-orphans","params":{"dry-run":false}}`, `[]`)
+	_, err := snap.Parser().ParseArgs([]string{"debug", "cleanup", "--orphans"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No orphaned snap data found.\n")
+}
+
+func (s *SnapSuite) TestCleanupNeedsOrphans(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "cleanup"})
+	c.Assert(err, check.ErrorMatches, "nothing to clean up, please use --orphans")
+}
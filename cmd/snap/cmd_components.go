@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdInstallComponent struct {
+	waitMixin
+	Dangerous   bool `long:"dangerous"`
+	Positionals struct {
+		File string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+type cmdRefreshComponent struct {
+	waitMixin
+	Dangerous   bool `long:"dangerous"`
+	Positionals struct {
+		File string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+type cmdRemoveComponent struct {
+	waitMixin
+	Positionals struct {
+		Name string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortInstallComponentHelp = i18n.G("Install a component of an installed snap")
+var longInstallComponentHelp = i18n.G(`
+The install-component command installs the component in the given file
+for the snap it belongs to, which must already be installed. If the
+component is already installed it is refreshed.
+
+Components carry no signatures yet, so --dangerous is required.
+`)
+
+var shortRefreshComponentHelp = i18n.G("Refresh an installed component of a snap")
+var longRefreshComponentHelp = i18n.G(`
+The refresh-component command replaces an installed component with the
+one in the given file.
+
+Components carry no signatures yet, so --dangerous is required.
+`)
+
+var shortRemoveComponentHelp = i18n.G("Remove a component of a snap")
+var longRemoveComponentHelp = i18n.G(`
+The remove-component command removes the given <snap>+<component>
+component from the system.
+`)
+
+var componentDangerousDesc = i18n.G("Install the given component file even though it cannot be verified")
+
+func init() {
+	addCommand("install-component", shortInstallComponentHelp, longInstallComponentHelp, func() flags.Commander {
+		return &cmdInstallComponent{}
+	}, waitDescs.also(map[string]string{
+		"dangerous": componentDangerousDesc,
+	}), []argDesc{
+		{name: i18n.G("<file>")},
+	})
+	addCommand("refresh-component", shortRefreshComponentHelp, longRefreshComponentHelp, func() flags.Commander {
+		return &cmdRefreshComponent{}
+	}, waitDescs.also(map[string]string{
+		"dangerous": componentDangerousDesc,
+	}), []argDesc{
+		{name: i18n.G("<file>")},
+	})
+	addCommand("remove-component", shortRemoveComponentHelp, longRemoveComponentHelp, func() flags.Commander {
+		return &cmdRemoveComponent{}
+	}, waitDescs, []argDesc{
+		{name: i18n.G("<snap>+<component>")},
+	})
+}
+
+func (x *cmdInstallComponent) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	id, err := cli.InstallComponentPath(x.Positionals.File, &client.ComponentOptions{Dangerous: x.Dangerous})
+	if err != nil {
+		return err
+	}
+	return x.waitComponentChange(cli, id, i18n.G("Component %s installed\n"))
+}
+
+func (x *cmdRefreshComponent) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	id, err := cli.RefreshComponentPath(x.Positionals.File, &client.ComponentOptions{Dangerous: x.Dangerous})
+	if err != nil {
+		return err
+	}
+	return x.waitComponentChange(cli, id, i18n.G("Component %s refreshed\n"))
+}
+
+func (x *cmdRemoveComponent) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	id, err := cli.RemoveComponent(x.Positionals.Name)
+	if err != nil {
+		return err
+	}
+	return x.waitComponentChange(cli, id, i18n.G("Component %s removed\n"))
+}
+
+// waitComponentChange waits for the component change and reports the
+// component it was about using msg.
+func (wmx *waitMixin) waitComponentChange(cli *client.Client, id, msg string) error {
+	chg, err := wmx.wait(cli, id)
+	if err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	var snapName, compName string
+	chg.Get("snap-name", &snapName)
+	chg.Get("component-name", &compName)
+	fmt.Fprintf(Stdout, msg, snapName+"+"+compName)
+	return nil
+}
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) testSideloadComponent(c *C, cmd, action, done string) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/components":
+			c.Check(r.Method, Equals, "POST")
+			form := testForm(r, c)
+			defer form.RemoveAll()
+			c.Check(form.Value["action"], DeepEquals, []string{action})
+			c.Check(form.Value["dangerous"], DeepEquals, []string{"true"})
+			name, filename, body := formFile(form, c)
+			c.Check(name, Equals, "component")
+			c.Check(filename, Equals, "foo+debug.comp")
+			c.Check(string(body), Equals, "comp-data")
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		case "/v2/changes/42":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {"snap-name": "foo", "component-name": "debug"}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	compPath := filepath.Join(c.MkDir(), "foo+debug.comp")
+	c.Assert(ioutil.WriteFile(compPath, []byte("comp-data"), 0644), IsNil)
+
+	rest, err := snap.Parser().ParseArgs([]string{cmd, "--dangerous", compPath})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Component foo+debug "+done+"\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestInstallComponent(c *C) {
+	s.testSideloadComponent(c, "install-component", "install", "installed")
+}
+
+func (s *SnapSuite) TestRefreshComponent(c *C) {
+	s.testSideloadComponent(c, "refresh-component", "refresh", "refreshed")
+}
+
+func (s *SnapSuite) TestRemoveComponent(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/components":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "remove",
+				"name":   "foo+debug",
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		case "/v2/changes/42":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {"snap-name": "foo", "component-name": "debug"}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"remove-component", "foo+debug"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Component foo+debug removed\n")
+}
+
+func (s *SnapSuite) TestRemoveComponentNoWait(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/components")
+		fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"remove-component", "--no-wait", "foo+debug"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "42\n")
+}
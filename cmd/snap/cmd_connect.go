@@ -20,14 +20,27 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/jsonutil"
 
 	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
 )
 
 type cmdConnect struct {
+	Auto        bool     `long:"auto"`
+	FromFile    string   `long:"from-file"`
+	Batch       bool     `long:"batch"`
+	DryRun      bool     `long:"dry-run"`
+	Attrs       []string `short:"o"`
 	Positionals struct {
-		PlugSpec connectPlugSpec `required:"yes"`
+		PlugSpec connectPlugSpec
 		SlotSpec connectSlotSpec
 	} `positional-args:"true"`
 }
@@ -51,22 +64,88 @@ $ snap connect <snap>:<plug>
 
 Connects the provided plug to the slot in the core snap with a name matching
 the plug name.
+
+$ snap connect -o <attr>=<value> <snap>:<plug> <snap>:<slot>
+
+Connects the provided plug to the given slot, with the attribute of the slot
+overridden for this connection only, for interfaces that support it. For
+example, a serial-port connection can be restricted to a single device.
+
+$ snap connect --dry-run <snap>:<plug> <snap>:<slot>
+
+Shows the security rules connecting the plug to the slot would add, for each
+security backend, without making the connection.
+
+$ snap connect --auto <snap>
+
+Connects all the plugs and slots of the snap that the auto-connection policy
+allows but that are not connected yet, for example after the snap declaration
+was updated.
+
+$ snap connect --from-file <profile>
+
+Makes the connections of the given connection profile, as written by
+'snap connections --export', skipping those already made.
+
+$ snap connect --batch <snap>:<plug> <snap>:<slot>...
+
+Makes the connections of all the given pairs of plugs and slots in a single
+change, setting up the security profiles of each snap involved only once,
+skipping those already made. If one of the connections cannot be made, none
+is.
+
+$ snap connect --batch <file>
+
+Makes and breaks the connections listed in the given YAML file in a single
+change, the disconnections coming first, for example:
+
+operations:
+- {action: disconnect, plug: consumer:plug, slot: producer:slot}
+- {action: connect, plug: consumer:plug, slot: other:slot}
 `)
 
 func init() {
 	addCommand("connect", shortConnectHelp, longConnectHelp, func() flags.Commander {
 		return &cmdConnect{}
-	}, nil, []argDesc{
+	}, map[string]string{
+		"auto":      i18n.G("Connect everything the policy allows for the given snap"),
+		"from-file": i18n.G("Make the connections of the given connection profile"),
+		"batch":     i18n.G("Make the connections of the given pairs of plugs and slots in one go"),
+		"o":         i18n.G("Override an attribute of the slot for the connection (attr=value)"),
+		"dry-run":   i18n.G("Show the security rules the connection would add, without connecting"),
+	}, []argDesc{
 		{name: i18n.G("<snap>:<plug>")},
 		{name: i18n.G("<snap>:<slot>")},
 	})
 }
 
 func (x *cmdConnect) Execute(args []string) error {
+	if x.Batch {
+		if x.FromFile != "" || x.Auto || x.DryRun || len(x.Attrs) > 0 {
+			return fmt.Errorf(i18n.G("--batch cannot be used with --auto, --from-file, --dry-run or -o"))
+		}
+		return x.connectBatch(args)
+	}
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
 
+	if (x.FromFile != "" || x.Auto) && len(x.Attrs) > 0 {
+		return fmt.Errorf(i18n.G("-o cannot be used with --auto or --from-file"))
+	}
+	if (x.FromFile != "" || x.Auto) && x.DryRun {
+		return fmt.Errorf(i18n.G("--dry-run cannot be used with --auto or --from-file"))
+	}
+	if x.FromFile != "" {
+		return x.connectFromFile()
+	}
+	if x.Auto {
+		return x.autoConnect()
+	}
+	if x.Positionals.PlugSpec.Snap == "" && x.Positionals.PlugSpec.Name == "" {
+		return fmt.Errorf(i18n.G("the required argument `<snap>:<plug>` was not provided"))
+	}
+
 	// snap connect <plug> <snap>[:<slot>]
 	if x.Positionals.PlugSpec.Snap != "" && x.Positionals.PlugSpec.Name == "" {
 		// Move the value of .Snap to .Name and keep .Snap empty
@@ -74,8 +153,235 @@ func (x *cmdConnect) Execute(args []string) error {
 		x.Positionals.PlugSpec.Snap = ""
 	}
 
+	attrs, err := parseConnectAttrs(x.Attrs)
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	if x.DryRun {
+		snippets, err := cli.ConnectDryRun(x.Positionals.PlugSpec.Snap, x.Positionals.PlugSpec.Name, x.Positionals.SlotSpec.Snap, x.Positionals.SlotSpec.Name, attrs)
+		if err != nil {
+			return err
+		}
+		showConnectionSnippets(snippets)
+		return nil
+	}
+	id, err := cli.ConnectWithAttrs(x.Positionals.PlugSpec.Snap, x.Positionals.PlugSpec.Name, x.Positionals.SlotSpec.Snap, x.Positionals.SlotSpec.Name, attrs)
+	if err != nil {
+		return err
+	}
+
+	_, err = wait(cli, id)
+	return err
+}
+
+// showConnectionSnippets prints the security rules a connection would
+// add, grouped by security backend and by what they apply to.
+func showConnectionSnippets(snippets map[string]map[string]string) {
+	if len(snippets) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("The connection would not add any security rules."))
+		return
+	}
+	backends := make([]string, 0, len(snippets))
+	for backend := range snippets {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	for _, backend := range backends {
+		keys := make([]string, 0, len(snippets[backend]))
+		for key := range snippets[backend] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(Stdout, "%s (%s):\n", backend, key)
+			for _, line := range strings.Split(snippets[backend][key], "\n") {
+				fmt.Fprintf(Stdout, "  %s\n", line)
+			}
+		}
+	}
+}
+
+// parseConnectAttrs parses attr=value pairs, the values being taken as
+// JSON when they are valid JSON and as strings otherwise, like snap set
+// does.
+func parseConnectAttrs(pairs []string) (map[string]interface{}, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	attrs := make(map[string]interface{}, len(pairs))
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf(i18n.G("invalid attribute: %q (want attr=value)"), pair)
+		}
+		var value interface{}
+		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
+			attrs[parts[0]] = parts[1]
+		} else {
+			attrs[parts[0]] = value
+		}
+	}
+	return attrs, nil
+}
+
+func (x *cmdConnect) autoConnect() error {
+	plugSpec, slotSpec := x.Positionals.PlugSpec, x.Positionals.SlotSpec
+	if plugSpec.Snap == "" || plugSpec.Name != "" || slotSpec.Snap != "" || slotSpec.Name != "" {
+		return fmt.Errorf(i18n.G("--auto requires a single snap name"))
+	}
+
+	cli := Client()
+	id, err := cli.AutoConnect(plugSpec.Snap)
+	if err != nil {
+		return err
+	}
+
+	chg, err := wait(cli, id)
+	if err != nil {
+		return err
+	}
+
+	var connected []client.AutoConnection
+	if err := chg.Get("connected", &connected); err != nil && err != client.ErrNoData {
+		return err
+	}
+	if len(connected) == 0 {
+		fmt.Fprintf(Stdout, i18n.G("No new connections for snap %q.\n"), plugSpec.Snap)
+		return nil
+	}
+	fmt.Fprintln(Stdout, i18n.G("Connected:"))
+	for _, conn := range connected {
+		fmt.Fprintf(Stdout, "  - %s:%s to %s:%s\n", conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name)
+	}
+	return nil
+}
+
+func (x *cmdConnect) connectBatch(args []string) error {
+	plugSpec, slotSpec := x.Positionals.PlugSpec, x.Positionals.SlotSpec
+	if len(args) == 0 && plugSpec.Snap != "" && plugSpec.Name == "" && slotSpec.Snap == "" && slotSpec.Name == "" {
+		// snap connect --batch <file>
+		return x.connectBatchFile(plugSpec.Snap)
+	}
+
+	conns, err := batchConnections(x.Positionals.PlugSpec.SnapAndName, x.Positionals.SlotSpec.SnapAndName, args)
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	id, err := cli.ConnectBatch(conns)
+	if err != nil {
+		return err
+	}
+
+	_, err = wait(cli, id)
+	return err
+}
+
+// batchConnections returns the connections given as pairs of plugs and
+// slots to --batch, the first pair being in the positional arguments and
+// the others in the remaining ones.
+func batchConnections(plug, slot SnapAndName, rest []string) ([]client.Connection, error) {
+	specs := []SnapAndName{plug, slot}
+	for _, arg := range rest {
+		var spec SnapAndName
+		if err := spec.UnmarshalFlag(arg); err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs)%2 != 0 || slot.Snap == "" && slot.Name == "" {
+		return nil, fmt.Errorf(i18n.G("--batch requires pairs of plugs and slots"))
+	}
+	conns := make([]client.Connection, 0, len(specs)/2)
+	for i := 0; i < len(specs); i += 2 {
+		plug, slot := specs[i], specs[i+1]
+		if plug.Snap == "" || plug.Name == "" {
+			return nil, fmt.Errorf(i18n.G("invalid plug %q for --batch (want snap:plug)"), plug.Snap+plug.Name)
+		}
+		conns = append(conns, client.Connection{
+			Plug: client.PlugRef{Snap: plug.Snap, Name: plug.Name},
+			Slot: client.SlotRef{Snap: slot.Snap, Name: slot.Name},
+		})
+	}
+	return conns, nil
+}
+
+// batchOperation is a connection made or broken by a batch file.
+type batchOperation struct {
+	Action string `yaml:"action"`
+	Plug   string `yaml:"plug"`
+	Slot   string `yaml:"slot"`
+}
+
+type batchFile struct {
+	Operations []batchOperation `yaml:"operations"`
+}
+
+// readBatchFile reads the connections to make and break listed in the
+// given batch file.
+func readBatchFile(path string) ([]client.ConnectionOperation, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read batch file: %v"), err)
+	}
+	var batch batchFile
+	if err := yaml.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot parse batch file %q: %v"), path, err)
+	}
+	if len(batch.Operations) == 0 {
+		return nil, fmt.Errorf(i18n.G("batch file %q has no operations"), path)
+	}
+	source := fmt.Sprintf(i18n.G("batch file %q"), path)
+	ops := make([]client.ConnectionOperation, 0, len(batch.Operations))
+	for _, op := range batch.Operations {
+		if op.Action != "connect" && op.Action != "disconnect" {
+			return nil, fmt.Errorf(i18n.G("invalid action in %s: %q (want connect or disconnect)"), source, op.Action)
+		}
+		conns, err := profileConnections([]profileConnection{{Plug: op.Plug, Slot: op.Slot}}, source)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, client.ConnectionOperation{
+			Action: op.Action,
+			Plug:   conns[0].Plug,
+			Slot:   conns[0].Slot,
+		})
+	}
+	return ops, nil
+}
+
+func (x *cmdConnect) connectBatchFile(path string) error {
+	ops, err := readBatchFile(path)
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	id, err := cli.ConnectionsBatch(ops)
+	if err != nil {
+		return err
+	}
+
+	_, err = wait(cli, id)
+	return err
+}
+
+func (x *cmdConnect) connectFromFile() error {
+	plugSpec, slotSpec := x.Positionals.PlugSpec, x.Positionals.SlotSpec
+	if x.Auto || plugSpec.Snap != "" || plugSpec.Name != "" || slotSpec.Snap != "" || slotSpec.Name != "" {
+		return fmt.Errorf(i18n.G("--from-file cannot be used with other arguments"))
+	}
+
+	conns, err := readConnectionProfile(x.FromFile)
+	if err != nil {
+		return err
+	}
+
 	cli := Client()
-	id, err := cli.Connect(x.Positionals.PlugSpec.Snap, x.Positionals.PlugSpec.Name, x.Positionals.SlotSpec.Snap, x.Positionals.SlotSpec.Name)
+	id, err := cli.ConnectBatch(conns)
 	if err != nil {
 		return err
 	}
@@ -20,9 +20,12 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/jessevdk/go-flags"
 	. "gopkg.in/check.v1"
@@ -33,7 +36,7 @@ import (
 
 func (s *SnapSuite) TestConnectHelp(c *C) {
 	msg := `Usage:
-  snap.test [OPTIONS] connect [<snap>:<plug>] [<snap>:<slot>]
+  snap.test [OPTIONS] connect [connect-OPTIONS] [<snap>:<plug>] [<snap>:<slot>]
 
 The connect command connects a plug to a slot.
 It may be called in the following ways:
@@ -53,11 +56,60 @@ $ snap connect <snap>:<plug>
 Connects the provided plug to the slot in the core snap with a name matching
 the plug name.
 
+$ snap connect -o <attr>=<value> <snap>:<plug> <snap>:<slot>
+
+Connects the provided plug to the given slot, with the attribute of the slot
+overridden for this connection only, for interfaces that support it. For
+example, a serial-port connection can be restricted to a single device.
+
+$ snap connect --dry-run <snap>:<plug> <snap>:<slot>
+
+Shows the security rules connecting the plug to the slot would add, for each
+security backend, without making the connection.
+
+$ snap connect --auto <snap>
+
+Connects all the plugs and slots of the snap that the auto-connection policy
+allows but that are not connected yet, for example after the snap declaration
+was updated.
+
+$ snap connect --from-file <profile>
+
+Makes the connections of the given connection profile, as written by
+'snap connections --export', skipping those already made.
+
+$ snap connect --batch <snap>:<plug> <snap>:<slot>...
+
+Makes the connections of all the given pairs of plugs and slots in a single
+change, setting up the security profiles of each snap involved only once,
+skipping those already made. If one of the connections cannot be made, none
+is.
+
+$ snap connect --batch <file>
+
+Makes and breaks the connections listed in the given YAML file in a single
+change, the disconnections coming first, for example:
+
+operations:
+- {action: disconnect, plug: consumer:plug, slot: producer:slot}
+- {action: connect, plug: consumer:plug, slot: other:slot}
+
 Application Options:
       --version            Print the version and exit
 
 Help Options:
   -h, --help               Show this help message
+
+[connect command options]
+          --auto           Connect everything the policy allows for the given
+                           snap
+          --from-file=     Make the connections of the given connection profile
+          --batch          Make the connections of the given pairs of plugs and
+                           slots in one go
+          --dry-run        Show the security rules the connection would add,
+                           without connecting
+      -o=                  Override an attribute of the slot for the connection
+                           (attr=value)
 `
 	rest, err := Parser().ParseArgs([]string{"connect", "--help"})
 	c.Assert(err.Error(), Equals, msg)
@@ -97,6 +149,326 @@ func (s *SnapSuite) TestConnectExplicitEverything(c *C) {
 	c.Assert(rest, DeepEquals, []string{})
 }
 
+func (s *SnapSuite) TestConnectWithAttrs(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			body := DecodedRequestBody(c, r)
+			c.Check(body["action"], Equals, "connect")
+			c.Check(body["attrs"], DeepEquals, map[string]interface{}{
+				"path":  "/dev/ttyUSB1",
+				"count": json.Number("2"),
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser().ParseArgs([]string{"connect", "-o", "path=/dev/ttyUSB1", "-o", "count=2", "producer:plug", "core:slot"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+}
+
+func (s *SnapSuite) TestConnectWithAttrsErrors(c *C) {
+	_, err := Parser().ParseArgs([]string{"connect", "-o", "path", "producer:plug", "core:slot"})
+	c.Check(err, ErrorMatches, `invalid attribute: "path" \(want attr=value\)`)
+	_, err = Parser().ParseArgs([]string{"connect", "-o", "path=/dev/ttyUSB1", "--auto", "producer"})
+	c.Check(err, ErrorMatches, "-o cannot be used with --auto or --from-file")
+}
+
+func (s *SnapSuite) TestConnectDryRun(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			body := DecodedRequestBody(c, r)
+			c.Check(body["action"], Equals, "connect")
+			c.Check(body["dry-run"], Equals, true)
+			c.Check(body["attrs"], DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB1"})
+			fmt.Fprintln(w, `{"type":"sync", "result":{
+				"udev": {"producer": "KERNEL==\"ttyUSB1\""},
+				"apparmor": {"snap.producer.app": "/dev/ttyUSB1 rw,\n/run/lock/ rw,", "snap.core.hook.configure": "/dev/ttyUSB1 r,"}
+			}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser().ParseArgs([]string{"connect", "--dry-run", "-o", "path=/dev/ttyUSB1", "producer:plug", "core:slot"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `apparmor (snap.core.hook.configure):
+  /dev/ttyUSB1 r,
+apparmor (snap.producer.app):
+  /dev/ttyUSB1 rw,
+  /run/lock/ rw,
+udev (producer):
+  KERNEL=="ttyUSB1"
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectDryRunNothing(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "result":{}}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connect", "--dry-run", "producer:plug", "core:slot"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "The connection would not add any security rules.\n")
+}
+
+func (s *SnapSuite) TestConnectDryRunErrors(c *C) {
+	_, err := Parser().ParseArgs([]string{"connect", "--dry-run", "--auto", "producer"})
+	c.Check(err, ErrorMatches, "--dry-run cannot be used with --auto or --from-file")
+	_, err = Parser().ParseArgs([]string{"connect", "--dry-run", "--from-file", "profile.yaml"})
+	c.Check(err, ErrorMatches, "--dry-run cannot be used with --auto or --from-file")
+}
+
+func (s *SnapSuite) TestConnectAuto(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "auto-connect",
+				"snap":   "consumer",
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {"connected": [{"plug": {"snap": "consumer", "plug": "network"}, "slot": {"snap": "core", "slot": "network"}}, {"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}}]}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser().ParseArgs([]string{"connect", "--auto", "consumer"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `Connected:
+  - consumer:network to core:network
+  - consumer:plug to producer:slot
+`)
+}
+
+func (s *SnapSuite) TestConnectAutoNothingNew(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {"connected": []}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	_, err := Parser().ParseArgs([]string{"connect", "--auto", "consumer"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "No new connections for snap \"consumer\".\n")
+}
+
+func (s *SnapSuite) TestConnectAutoErrors(c *C) {
+	for _, args := range [][]string{
+		{"connect", "--auto", "consumer:plug"},
+		{"connect", "--auto", "consumer", "producer"},
+		{"connect", "--auto", ":plug"},
+	} {
+		_, err := Parser().ParseArgs(args)
+		c.Check(err, ErrorMatches, "--auto requires a single snap name", Commentf("%v", args))
+	}
+}
+
+func (s *SnapSuite) TestConnectMissingPlug(c *C) {
+	_, err := Parser().ParseArgs([]string{"connect"})
+	c.Check(err, ErrorMatches, "the required argument `<snap>:<plug>` was not provided")
+}
+
+func (s *SnapSuite) TestConnectFromFile(c *C) {
+	profile := filepath.Join(c.MkDir(), "profile.yaml")
+	err := ioutil.WriteFile(profile, []byte(`connections:
+- plug: consumer:plug
+  slot: producer:slot
+- plug: consumer:network
+  slot: :network
+`), 0644)
+	c.Assert(err, IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/connections":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "connect",
+				"connections": []interface{}{
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer", "plug": "plug"},
+						"slot": map[string]interface{}{"snap": "producer", "slot": "slot"},
+					},
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer", "plug": "network"},
+						"slot": map[string]interface{}{"snap": "", "slot": "network"},
+					},
+				},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser().ParseArgs([]string{"connect", "--from-file", profile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+}
+
+func (s *SnapSuite) TestConnectFromFileErrors(c *C) {
+	dir := c.MkDir()
+	n := 0
+	profile := func(content string) string {
+		n++
+		path := filepath.Join(dir, fmt.Sprintf("profile%d.yaml", n))
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+		return path
+	}
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"connect", "--from-file", filepath.Join(dir, "missing.yaml")}, `cannot read connection profile: .*`},
+		{[]string{"connect", "--from-file", profile("connections: [")}, `cannot parse connection profile ".*": .*`},
+		{[]string{"connect", "--from-file", profile("connections: []")}, `connection profile ".*" has no connections`},
+		{[]string{"connect", "--from-file", profile("connections:\n- plug: consumer\n  slot: producer:slot\n")}, `invalid plug in connection profile ".*": "consumer" \(want snap:plug\)`},
+		{[]string{"connect", "--from-file", profile("connections:\n- plug: consumer:plug\n")}, `invalid slot in connection profile ".*": "" \(want snap:slot or snap\)`},
+		{[]string{"connect", "--from-file", profile("connections: []"), "consumer:plug"}, `--from-file cannot be used with other arguments`},
+		{[]string{"connect", "--auto", "--from-file", profile("connections: []")}, `--from-file cannot be used with other arguments`},
+	} {
+		_, err := Parser().ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
+
+func (s *SnapSuite) TestConnectBatch(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/connections":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "connect",
+				"connections": []interface{}{
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer", "plug": "plug"},
+						"slot": map[string]interface{}{"snap": "producer", "slot": "slot"},
+					},
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer", "plug": "network"},
+						"slot": map[string]interface{}{"snap": "", "slot": "network"},
+					},
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer2", "plug": "plug"},
+						"slot": map[string]interface{}{"snap": "producer", "slot": ""},
+					},
+				},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	_, err := Parser().ParseArgs([]string{"connect", "--batch", "consumer:plug", "producer:slot", "consumer:network", ":network", "consumer2:plug", "producer"})
+	c.Assert(err, IsNil)
+}
+
+func (s *SnapSuite) TestConnectBatchErrors(c *C) {
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"connect", "--batch"}, `--batch requires pairs of plugs and slots`},
+		{[]string{"connect", "--batch", "consumer:plug"}, `--batch requires pairs of plugs and slots`},
+		{[]string{"connect", "--batch", "consumer:plug", "producer:slot", "consumer:network"}, `--batch requires pairs of plugs and slots`},
+		{[]string{"connect", "--batch", "consumer:plug", "producer:slot", "consumer", "producer"}, `invalid plug "consumer" for --batch \(want snap:plug\)`},
+		{[]string{"connect", "--batch", "consumer:plug", "producer:slot", "consumer:", "producer"}, `invalid value: "consumer:" \(want snap:name or snap\)`},
+		{[]string{"connect", "--batch", "--auto", "consumer:plug", "producer:slot"}, `--batch cannot be used with --auto, --from-file, --dry-run or -o`},
+		{[]string{"connect", "--batch", "-o", "a=b", "consumer:plug", "producer:slot"}, `--batch cannot be used with --auto, --from-file, --dry-run or -o`},
+	} {
+		_, err := Parser().ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
+
+func (s *SnapSuite) TestConnectBatchFile(c *C) {
+	batch := filepath.Join(c.MkDir(), "batch.yaml")
+	err := ioutil.WriteFile(batch, []byte(`operations:
+- {action: disconnect, plug: consumer:plug, slot: producer:slot}
+- action: connect
+  plug: consumer:plug
+  slot: other
+`), 0644)
+	c.Assert(err, IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/connections":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "batch",
+				"operations": []interface{}{
+					map[string]interface{}{
+						"action": "disconnect",
+						"plug":   map[string]interface{}{"snap": "consumer", "plug": "plug"},
+						"slot":   map[string]interface{}{"snap": "producer", "slot": "slot"},
+					},
+					map[string]interface{}{
+						"action": "connect",
+						"plug":   map[string]interface{}{"snap": "consumer", "plug": "plug"},
+						"slot":   map[string]interface{}{"snap": "other", "slot": ""},
+					},
+				},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	_, err = Parser().ParseArgs([]string{"connect", "--batch", batch})
+	c.Assert(err, IsNil)
+}
+
+func (s *SnapSuite) TestConnectBatchFileErrors(c *C) {
+	dir := c.MkDir()
+	n := 0
+	batch := func(content string) string {
+		n++
+		path := filepath.Join(dir, fmt.Sprintf("batch%d.yaml", n))
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+		return path
+	}
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"connect", "--batch", filepath.Join(dir, "missing.yaml")}, `cannot read batch file: .*`},
+		{[]string{"connect", "--batch", batch("operations: [")}, `cannot parse batch file ".*": .*`},
+		{[]string{"connect", "--batch", batch("operations: []")}, `batch file ".*" has no operations`},
+		{[]string{"connect", "--batch", batch("operations:\n- action: refresh\n  plug: consumer:plug\n  slot: producer:slot\n")}, `invalid action in batch file ".*": "refresh" \(want connect or disconnect\)`},
+		{[]string{"connect", "--batch", batch("operations:\n- action: connect\n  plug: consumer\n  slot: producer:slot\n")}, `invalid plug in batch file ".*": "consumer" \(want snap:plug\)`},
+	} {
+		_, err := Parser().ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
+
 func (s *SnapSuite) TestConnectExplicitPlugImplicitSlot(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -327,3 +699,50 @@ func (s *SnapSuite) TestConnectCompletion(c *C) {
 	c.Assert(s.Stdout(), Equals, "")
 	c.Assert(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestConnectCompletionGreedyPlugs(c *C) {
+	greedy := false
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Assert(r.Method, Equals, "GET")
+			if r.URL.Query().Get("select") == "all" {
+				EncodeResponseBody(c, w, map[string]interface{}{
+					"type": "sync",
+					"result": []*client.Interface{
+						{Name: "bool-file", GreedyPlugs: greedy},
+						{Name: "x11"},
+					},
+				})
+				return
+			}
+			EncodeResponseBody(c, w, map[string]interface{}{
+				"type":   "sync",
+				"result": fortestingConnectionList,
+			})
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	os.Setenv("GO_FLAGS_COMPLETION", "verbose")
+	defer os.Unsetenv("GO_FLAGS_COMPLETION")
+	// what snapd tells changes below
+	defer MockCompletionCacheTTL(0)()
+
+	var expected []flags.Completion
+	parser := Parser()
+	parser.CompletionHandler = func(obtained []flags.Completion) {
+		c.Check(obtained, DeepEquals, expected)
+	}
+
+	// the plug is connected already
+	expected = nil
+	_, err := parser.ParseArgs([]string{"connect", "keyboard-lights:"})
+	c.Assert(err, IsNil)
+
+	// but it can take more connections
+	greedy = true
+	expected = []flags.Completion{{Item: "keyboard-lights:capslock-led", Description: "plug"}}
+	_, err = parser.ParseArgs([]string{"connect", "keyboard-lights:"})
+	c.Assert(err, IsNil)
+}
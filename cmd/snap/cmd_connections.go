@@ -0,0 +1,311 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdConnections struct {
+	Export      bool `long:"export"`
+	History     bool `long:"history"`
+	Activity    bool `long:"activity"`
+	Positionals struct {
+		Snap installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"true"`
+}
+
+var shortConnectionsHelp = i18n.G("Lists the connections between plugs and slots")
+var longConnectionsHelp = i18n.G(`
+The connections command lists the connections established between plugs
+and slots, noting those that were made manually rather than by the
+auto-connection policy. Connections are grouped by the category of their
+plug or slot: hardware, privacy and system, then uncategorised ones.
+
+$ snap connections --export > profile.yaml
+
+Writes the manual connections to a connection profile, which can be applied
+to another device with 'snap connect --from-file profile.yaml'.
+
+$ snap connections --history <snap>
+
+Lists when the plugs and slots of the snap were connected and disconnected,
+and whether that was done by a user, by the auto-connection policy, by the
+administrator's auto-connection rules, as devices were plugged in and out
+(hotplug) or as snaps were removed.
+
+$ snap connections --activity <snap>
+
+Lists the processes of the snap, and of the snaps it is connected to,
+currently running with the access granted by each connection, along with
+the accesses recently denied to them. This helps telling whether
+disconnecting would break something that is running right now. As the
+processes and denials can be those of any user, this needs root.
+`)
+
+func init() {
+	addCommand("connections", shortConnectionsHelp, longConnectionsHelp, func() flags.Commander {
+		return &cmdConnections{}
+	}, map[string]string{
+		"export":   i18n.G("Write the manual connections as a connection profile"),
+		"history":  i18n.G("List the past connections and disconnections"),
+		"activity": i18n.G("List the processes using the connections and their recent denials"),
+	}, []argDesc{{
+		name: "<snap>",
+		desc: i18n.G("Only list the connections of the given snap"),
+	}})
+}
+
+// connectionProfile is a set of connections to make, as written by
+// 'snap connections --export' and read by 'snap connect --from-file'.
+type connectionProfile struct {
+	Connections []profileConnection `yaml:"connections"`
+}
+
+// profileConnection holds the plug and slot of a connection, each as
+// <snap>:<name>.
+type profileConnection struct {
+	Plug string `yaml:"plug"`
+	Slot string `yaml:"slot"`
+}
+
+func (x *cmdConnections) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName := string(x.Positionals.Snap)
+	if x.History {
+		if x.Export {
+			return fmt.Errorf(i18n.G("cannot export the connection history"))
+		}
+		return showConnectionHistory(snapName)
+	}
+	if x.Activity {
+		if x.Export {
+			return fmt.Errorf(i18n.G("cannot export the connection activity"))
+		}
+		return showConnectionActivity(snapName)
+	}
+
+	conns, err := Client().EstablishedConnections()
+	if err != nil {
+		return err
+	}
+	if snapName != "" {
+		snapConns := make([]client.Connection, 0, len(conns))
+		for _, conn := range conns {
+			if conn.Plug.Snap == snapName || conn.Slot.Snap == snapName {
+				snapConns = append(snapConns, conn)
+			}
+		}
+		conns = snapConns
+	}
+
+	if x.Export {
+		return exportConnections(conns)
+	}
+
+	if len(conns) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No connections."))
+		return nil
+	}
+	sort.Stable(byCategory(conns))
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Category\tInterface\tPlug\tSlot\tNotes"))
+	for _, conn := range conns {
+		category := conn.Category
+		if category == "" {
+			category = "-"
+		}
+		notes := "-"
+		if conn.Manual {
+			notes = "manual"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s:%s\t%s:%s\t%s\n", category, conn.Interface, conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name, notes)
+	}
+	return nil
+}
+
+// categoryOrder is the order in which connections are grouped by
+// category; connections without one come last.
+var categoryOrder = map[string]int{
+	"hardware": 0,
+	"privacy":  1,
+	"system":   2,
+}
+
+func categoryRank(category string) int {
+	if rank, ok := categoryOrder[category]; ok {
+		return rank
+	}
+	return len(categoryOrder)
+}
+
+type byCategory []client.Connection
+
+func (c byCategory) Len() int      { return len(c) }
+func (c byCategory) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCategory) Less(i, j int) bool {
+	return categoryRank(c[i].Category) < categoryRank(c[j].Category)
+}
+
+func showConnectionHistory(snapName string) error {
+	events, err := Client().ConnectionHistory(snapName)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No connection history."))
+		return nil
+	}
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Time\tAction\tBy\tInterface\tPlug\tSlot"))
+	for _, ev := range events {
+		iface := ev.Interface
+		if iface == "" {
+			iface = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s:%s\t%s:%s\n", ev.Time.UTC().Format(time.RFC3339), ev.Action, ev.By, iface, ev.Plug.Snap, ev.Plug.Name, ev.Slot.Snap, ev.Slot.Name)
+	}
+	return nil
+}
+
+func showConnectionActivity(snapName string) error {
+	conns, err := Client().ConnectionActivity(snapName)
+	if err != nil {
+		return err
+	}
+	if len(conns) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No connections."))
+		return nil
+	}
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tProcesses\tDenials"))
+	var denied []client.ConnectionActivity
+	for _, conn := range conns {
+		procs := "-"
+		if len(conn.Processes) > 0 {
+			pids := make([]string, len(conn.Processes))
+			for i, proc := range conn.Processes {
+				pids[i] = strconv.Itoa(proc.Pid)
+			}
+			procs = strings.Join(pids, ",")
+		}
+		denials := "-"
+		if len(conn.Denials) > 0 {
+			denials = strconv.Itoa(len(conn.Denials))
+			denied = append(denied, conn)
+		}
+		fmt.Fprintf(w, "%s\t%s:%s\t%s:%s\t%s\t%s\n", conn.Interface, conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name, procs, denials)
+	}
+	w.Flush()
+
+	if len(denied) == 0 {
+		return nil
+	}
+	fmt.Fprintln(Stdout)
+	w = tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Time\tPlug\tProfile\tOperation\tName\tDenied"))
+	for _, conn := range denied {
+		for _, d := range conn.Denials {
+			name := d.Name
+			if name == "" {
+				name = "-"
+			}
+			mask := d.DeniedMask
+			if mask == "" {
+				mask = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s:%s\t%s\t%s\t%s\t%s\n", d.Time.UTC().Format(time.RFC3339), conn.Plug.Snap, conn.Plug.Name, d.Profile, d.Operation, name, mask)
+		}
+	}
+	return nil
+}
+
+func exportConnections(conns []client.Connection) error {
+	profile := connectionProfile{Connections: []profileConnection{}}
+	for _, conn := range conns {
+		if !conn.Manual {
+			continue
+		}
+		profile.Connections = append(profile.Connections, profileConnection{
+			Plug: conn.Plug.Snap + ":" + conn.Plug.Name,
+			Slot: conn.Slot.Snap + ":" + conn.Slot.Name,
+		})
+	}
+	out, err := yaml.Marshal(&profile)
+	if err != nil {
+		return err
+	}
+	_, err = Stdout.Write(out)
+	return err
+}
+
+// readConnectionProfile reads the connections of the given connection
+// profile.
+func readConnectionProfile(path string) ([]client.Connection, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read connection profile: %v"), err)
+	}
+	var profile connectionProfile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot parse connection profile %q: %v"), path, err)
+	}
+	if len(profile.Connections) == 0 {
+		return nil, fmt.Errorf(i18n.G("connection profile %q has no connections"), path)
+	}
+	return profileConnections(profile.Connections, fmt.Sprintf(i18n.G("connection profile %q"), path))
+}
+
+// profileConnections turns the connections read from the given source,
+// such as a connection profile, into client connections.
+func profileConnections(pcs []profileConnection, source string) ([]client.Connection, error) {
+	conns := make([]client.Connection, 0, len(pcs))
+	for _, pc := range pcs {
+		var plug, slot SnapAndName
+		if err := plug.UnmarshalFlag(pc.Plug); err != nil || plug.Snap == "" || plug.Name == "" {
+			return nil, fmt.Errorf(i18n.G("invalid plug in %s: %q (want snap:plug)"), source, pc.Plug)
+		}
+		if err := slot.UnmarshalFlag(pc.Slot); err != nil {
+			return nil, fmt.Errorf(i18n.G("invalid slot in %s: %q (want snap:slot or snap)"), source, pc.Slot)
+		}
+		conns = append(conns, client.Connection{
+			Plug: client.PlugRef{Snap: plug.Snap, Name: plug.Name},
+			Slot: client.SlotRef{Snap: slot.Snap, Name: slot.Name},
+		})
+	}
+	return conns, nil
+}
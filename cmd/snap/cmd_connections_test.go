@@ -0,0 +1,200 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+const connectionsResponse = `{
+	"type": "sync",
+	"result": [
+		{"plug": {"snap": "canonical-pi2", "plug": "pin-13"}, "slot": {"snap": "keyboard-lights", "slot": "capslock-led"}, "interface": "bool-file", "manual": true},
+		{"plug": {"snap": "foo", "plug": "network"}, "slot": {"snap": "core", "slot": "network"}, "interface": "network"}
+	]
+}`
+
+func (s *SnapSuite) TestConnections(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		fmt.Fprintln(w, connectionsResponse)
+	})
+	rest, err := Parser().ParseArgs([]string{"connections"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Category  Interface  Plug                  Slot                          Notes\n"+
+		"-         bool-file  canonical-pi2:pin-13  keyboard-lights:capslock-led  manual\n"+
+		"-         network    foo:network           core:network                  -\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsGroupedByCategory(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{
+	"type": "sync",
+	"result": [
+		{"plug": {"snap": "foo", "plug": "network"}, "slot": {"snap": "core", "slot": "network"}, "interface": "network"},
+		{"plug": {"snap": "foo", "plug": "camera"}, "slot": {"snap": "core", "slot": "camera"}, "interface": "camera", "category": "privacy"},
+		{"plug": {"snap": "foo", "plug": "shutdown"}, "slot": {"snap": "core", "slot": "shutdown"}, "interface": "shutdown", "category": "system"},
+		{"plug": {"snap": "foo", "plug": "joystick"}, "slot": {"snap": "core", "slot": "joystick"}, "interface": "joystick", "category": "hardware"},
+		{"plug": {"snap": "foo", "plug": "audio-record"}, "slot": {"snap": "core", "slot": "audio-record"}, "interface": "audio-record", "category": "privacy"}
+	]
+}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Category  Interface     Plug              Slot               Notes\n"+
+		"hardware  joystick      foo:joystick      core:joystick      -\n"+
+		"privacy   camera        foo:camera        core:camera        -\n"+
+		"privacy   audio-record  foo:audio-record  core:audio-record  -\n"+
+		"system    shutdown      foo:shutdown      core:shutdown      -\n"+
+		"-         network       foo:network       core:network       -\n")
+}
+
+func (s *SnapSuite) TestConnectionsNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No connections.\n")
+}
+
+func (s *SnapSuite) TestConnectionsOfSnap(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, connectionsResponse)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Category  Interface  Plug         Slot          Notes\n"+
+		"-         network    foo:network  core:network  -\n")
+}
+
+func (s *SnapSuite) TestConnectionsHistory(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections/history")
+		c.Check(r.URL.Query().Get("snap"), Equals, "foo")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+			{"time": "2018-06-01T10:00:00Z", "action": "connect", "by": "auto-connect", "plug": {"snap": "foo", "plug": "network"}, "slot": {"snap": "core", "slot": "network"}, "interface": "network"},
+			{"time": "2018-06-02T11:30:00+01:00", "action": "connect", "by": "user", "plug": {"snap": "foo", "plug": "camera"}, "slot": {"snap": "core", "slot": "camera"}, "interface": "camera"},
+			{"time": "2018-06-03T10:00:00Z", "action": "disconnect", "by": "removal", "plug": {"snap": "foo", "plug": "camera"}, "slot": {"snap": "core", "slot": "camera"}}
+		]}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "--history", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Time                  Action      By            Interface  Plug         Slot\n"+
+		"2018-06-01T10:00:00Z  connect     auto-connect  network    foo:network  core:network\n"+
+		"2018-06-02T10:30:00Z  connect     user          camera     foo:camera   core:camera\n"+
+		"2018-06-03T10:00:00Z  disconnect  removal       -          foo:camera   core:camera\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsHistoryNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.RawQuery, Equals, "")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "--history"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No connection history.\n")
+}
+
+func (s *SnapSuite) TestConnectionsHistoryExport(c *C) {
+	_, err := Parser().ParseArgs([]string{"connections", "--history", "--export"})
+	c.Assert(err, ErrorMatches, "cannot export the connection history")
+}
+
+func (s *SnapSuite) TestConnectionsActivity(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections/activity")
+		c.Check(r.URL.Query().Get("snap"), Equals, "foo")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+			{"plug": {"snap": "foo", "plug": "camera"}, "slot": {"snap": "core", "slot": "camera"}, "interface": "camera",
+			 "processes": [{"pid": 42, "snap": "foo", "side": "plug", "label": "snap.foo.app (enforce)"}, {"pid": 43, "snap": "foo", "side": "plug"}],
+			 "denials": [{"time": "2018-06-01T10:00:00Z", "profile": "snap.foo.app", "operation": "open", "name": "/dev/video1", "pid": 42, "denied-mask": "r"}]},
+			{"plug": {"snap": "foo", "plug": "network"}, "slot": {"snap": "core", "slot": "network"}, "interface": "network"}
+		]}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "--activity", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Interface  Plug         Slot          Processes  Denials\n"+
+		"camera     foo:camera   core:camera   42,43      1\n"+
+		"network    foo:network  core:network  -          -\n"+
+		"\n"+
+		"Time                  Plug        Profile       Operation  Name         Denied\n"+
+		"2018-06-01T10:00:00Z  foo:camera  snap.foo.app  open       /dev/video1  r\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsActivityNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.RawQuery, Equals, "")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "--activity"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No connections.\n")
+}
+
+func (s *SnapSuite) TestConnectionsActivityExport(c *C) {
+	_, err := Parser().ParseArgs([]string{"connections", "--activity", "--export"})
+	c.Assert(err, ErrorMatches, "cannot export the connection activity")
+}
+
+func (s *SnapSuite) TestConnectionsExport(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		fmt.Fprintln(w, connectionsResponse)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "--export"})
+	c.Assert(err, IsNil)
+	// only the manual connections are exported
+	c.Check(s.Stdout(), Equals, `connections:
+- plug: canonical-pi2:pin-13
+  slot: keyboard-lights:capslock-led
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsExportNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"connections", "--export"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "connections: []\n")
+}
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdConnectivity struct {
+	Positionals struct {
+		Plug connectPlugSpec `positional-arg-name:"<snap>:<plug>" required:"1"`
+	} `positional-args:"true"`
+}
+
+var shortConnectivityHelp = i18n.G("(internal) explain how a plug is auto-connected")
+var longConnectivityHelp = i18n.G(`
+The connectivity command explains whether the given plug is auto-connected,
+and to which slots, going through the slots of its interface and telling
+for each one why it is or is not a candidate for the auto-connection.
+
+The explanation is based on the current policy, which may differ from the
+one in place when the snaps were installed.
+`)
+
+func init() {
+	addDebugCommand("connectivity", shortConnectivityHelp, longConnectivityHelp, func() flags.Commander {
+		return &cmdConnectivity{}
+	})
+}
+
+type slotConnectivity struct {
+	Slot struct {
+		Snap string `json:"snap"`
+		Name string `json:"slot"`
+	} `json:"slot"`
+	Candidate bool   `json:"candidate"`
+	Reason    string `json:"reason"`
+	Connected bool   `json:"connected"`
+	Undesired bool   `json:"undesired"`
+}
+
+type plugConnectivity struct {
+	Interface string              `json:"interface"`
+	Greedy    bool                `json:"greedy"`
+	Verdict   string              `json:"verdict"`
+	Slots     []*slotConnectivity `json:"slots"`
+}
+
+func (x *cmdConnectivity) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	plug := x.Positionals.Plug
+	if plug.Name == "" {
+		return fmt.Errorf(i18n.G("please provide the plug as <snap>:<plug>"))
+	}
+
+	params := map[string]string{"snap": plug.Snap, "plug": plug.Name}
+	var pc plugConnectivity
+	if err := Client().Debug("connectivity", params, &pc); err != nil {
+		return err
+	}
+
+	iface := pc.Interface
+	if pc.Greedy {
+		iface = fmt.Sprintf(i18n.G("%s, greedy"), iface)
+	}
+	fmt.Fprintf(Stdout, "%s:%s (%s): %s\n", plug.Snap, plug.Name, iface, pc.Verdict)
+	if len(pc.Slots) == 0 {
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Slot\tCandidate\tNotes"))
+	for _, sc := range pc.Slots {
+		candidate := i18n.G("no")
+		if sc.Candidate {
+			candidate = i18n.G("yes")
+		}
+		var notes []string
+		if sc.Connected {
+			notes = append(notes, i18n.G("connected"))
+		}
+		if sc.Undesired {
+			notes = append(notes, i18n.G("disconnected by the user"))
+		}
+		if sc.Reason != "" {
+			notes = append(notes, sc.Reason)
+		}
+		if len(notes) == 0 {
+			notes = []string{"-"}
+		}
+		fmt.Fprintf(w, "%s:%s\t%s\t%s\n", sc.Slot.Snap, sc.Slot.Name, candidate, strings.Join(notes, "; "))
+	}
+	return nil
+}
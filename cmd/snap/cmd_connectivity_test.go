@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestConnectivity(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug")
+			data, err := ioutil.ReadAll(r.Body)
+			c.Check(err, check.IsNil)
+			c.Check(string(data), check.Equals, `{"action":"connectivity","params":{"plug":"plug","snap":"consumer"}}`)
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+"plug": {"snap": "consumer", "plug": "plug"},
+"interface": "test",
+"verdict": "auto-connects to producer:slot",
+"slots": [
+  {"slot": {"snap": "other", "slot": "slot"}, "candidate": false, "reason": "auto-connection not allowed by slot rule of interface \"test\""},
+  {"slot": {"snap": "producer", "slot": "slot"}, "candidate": true, "connected": true}
+]}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "connectivity", "consumer:plug"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `consumer:plug (test): auto-connects to producer:slot
+Slot           Candidate  Notes
+other:slot     no         auto-connection not allowed by slot rule of interface "test"
+producer:slot  yes        connected
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestConnectivityNeedsPlug(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "connectivity", "consumer"})
+	c.Assert(err, check.ErrorMatches, `please provide the plug as <snap>:<plug>`)
+}
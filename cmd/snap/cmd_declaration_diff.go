@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDeclarationDiff struct {
+	Positionals struct {
+		Snap installedSnapName `positional-arg-name:"<snap>" required:"1"`
+	} `positional-args:"true"`
+}
+
+var shortDeclarationDiffHelp = i18n.G("(internal) show the connections a snap-declaration refresh changed")
+var longDeclarationDiffHelp = i18n.G(`
+The declaration-diff command shows the connections of the given snap that
+the last refresh of its snap-declaration allowed, disallowed, or changed
+the auto-connection of, as they were also reported in the log of snapd.
+
+Connections that are no longer allowed are disconnected when the
+interfaces.disconnect-disallowed core option is set to true.
+`)
+
+func init() {
+	addDebugCommand("declaration-diff", shortDeclarationDiffHelp, longDeclarationDiffHelp, func() flags.Commander {
+		return &cmdDeclarationDiff{}
+	})
+}
+
+type declarationConnChange struct {
+	Plug struct {
+		Snap string `json:"snap"`
+		Name string `json:"plug"`
+	} `json:"plug"`
+	Slot struct {
+		Snap string `json:"snap"`
+		Name string `json:"slot"`
+	} `json:"slot"`
+	Interface      string `json:"interface"`
+	WasAllowed     bool   `json:"was-allowed"`
+	Allowed        bool   `json:"allowed"`
+	WasAutoConnect bool   `json:"was-auto-connect"`
+	AutoConnect    bool   `json:"auto-connect"`
+	Connected      bool   `json:"connected"`
+	Disconnected   bool   `json:"disconnected"`
+}
+
+type declarationDiff struct {
+	OldRevision int                      `json:"old-revision"`
+	NewRevision int                      `json:"new-revision"`
+	Changes     []*declarationConnChange `json:"changes"`
+}
+
+func (x *cmdDeclarationDiff) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName := string(x.Positionals.Snap)
+	params := map[string]string{"snap": snapName}
+	var diff declarationDiff
+	if err := Client().Debug("declaration-diff", params, &diff); err != nil {
+		return err
+	}
+	if len(diff.Changes) == 0 {
+		fmt.Fprintf(Stderr, i18n.G("No refresh of the snap-declaration of %q changed its connections.\n"), snapName)
+		return nil
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("The snap-declaration of %q was refreshed from revision %d to %d.\n"), snapName, diff.OldRevision, diff.NewRevision)
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Plug\tSlot\tInterface\tChange\tNotes"))
+	for _, change := range diff.Changes {
+		var what []string
+		if change.WasAllowed != change.Allowed {
+			if change.Allowed {
+				what = append(what, i18n.G("now allowed"))
+			} else {
+				what = append(what, i18n.G("no longer allowed"))
+			}
+		}
+		if change.WasAutoConnect != change.AutoConnect {
+			if change.AutoConnect {
+				what = append(what, i18n.G("now auto-connected"))
+			} else {
+				what = append(what, i18n.G("no longer auto-connected"))
+			}
+		}
+		notes := "-"
+		switch {
+		case change.Disconnected:
+			notes = i18n.G("disconnected")
+		case change.Connected:
+			notes = i18n.G("connected")
+		}
+		fmt.Fprintf(w, "%s:%s\t%s:%s\t%s\t%s\t%s\n", change.Plug.Snap, change.Plug.Name, change.Slot.Snap, change.Slot.Name, change.Interface, strings.Join(what, ", "), notes)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDeclarationDiff(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug")
+			data, err := ioutil.ReadAll(r.Body)
+			c.Check(err, check.IsNil)
+			c.Check(string(data), check.Equals, `{"action":"declaration-diff","params":{"snap":"producer"}}`)
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+"snap": "producer",
+"old-revision": 1,
+"new-revision": 2,
+"changes": [
+  {"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}, "interface": "test",
+   "was-allowed": true, "allowed": false, "was-auto-connect": true, "auto-connect": false, "connected": true, "disconnected": true},
+  {"plug": {"snap": "other", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}, "interface": "test",
+   "was-allowed": true, "allowed": true, "was-auto-connect": false, "auto-connect": true}
+]}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "declaration-diff", "producer"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `The snap-declaration of "producer" was refreshed from revision 1 to 2.
+Plug           Slot           Interface  Change                                       Notes
+consumer:plug  producer:slot  test       no longer allowed, no longer auto-connected  disconnected
+other:plug     producer:slot  test       now auto-connected                           -
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDeclarationDiffNoChanges(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"snap": "producer"}}`)
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "declaration-diff", "producer"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No refresh of the snap-declaration of \"producer\" changed its connections.\n")
+}
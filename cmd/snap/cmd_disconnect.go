@@ -28,6 +28,7 @@ import (
 )
 
 type cmdDisconnect struct {
+	Batch       bool `long:"batch"`
 	Positionals struct {
 		Offer disconnectSlotOrPlugSpec `required:"true"`
 		Use   disconnectSlotSpec
@@ -47,18 +48,29 @@ $ snap disconnect <snap>:<slot or plug>
 
 Disconnects everything from the provided plug or slot.
 The snap name may be omitted for the core snap.
+
+$ snap disconnect --batch <snap>:<plug> <snap>:<slot>...
+
+Breaks the connections of all the given pairs of plugs and slots in a single
+change, setting up the security profiles of each snap involved only once,
+skipping those not made. If one of the connections cannot be broken, none is.
 `)
 
 func init() {
 	addCommand("disconnect", shortDisconnectHelp, longDisconnectHelp, func() flags.Commander {
 		return &cmdDisconnect{}
-	}, nil, []argDesc{
+	}, map[string]string{
+		"batch": i18n.G("Break the connections of the given pairs of plugs and slots in one go"),
+	}, []argDesc{
 		{name: i18n.G("<snap>:<plug>")},
 		{name: i18n.G("<snap>:<slot>")},
 	})
 }
 
 func (x *cmdDisconnect) Execute(args []string) error {
+	if x.Batch {
+		return x.disconnectBatch(args)
+	}
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
@@ -85,3 +97,19 @@ func (x *cmdDisconnect) Execute(args []string) error {
 	_, err = wait(cli, id)
 	return err
 }
+
+func (x *cmdDisconnect) disconnectBatch(args []string) error {
+	conns, err := batchConnections(x.Positionals.Offer.SnapAndName, x.Positionals.Use.SnapAndName, args)
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	id, err := cli.DisconnectBatch(conns)
+	if err != nil {
+		return err
+	}
+
+	_, err = wait(cli, id)
+	return err
+}
@@ -32,7 +32,7 @@ import (
 
 func (s *SnapSuite) TestDisconnectHelp(c *C) {
 	msg := `Usage:
-  snap.test [OPTIONS] disconnect [<snap>:<plug>] [<snap>:<slot>]
+  snap.test [OPTIONS] disconnect [disconnect-OPTIONS] [<snap>:<plug>] [<snap>:<slot>]
 
 The disconnect command disconnects a plug from a slot.
 It may be called in the following ways:
@@ -46,17 +46,57 @@ $ snap disconnect <snap>:<slot or plug>
 Disconnects everything from the provided plug or slot.
 The snap name may be omitted for the core snap.
 
+$ snap disconnect --batch <snap>:<plug> <snap>:<slot>...
+
+Breaks the connections of all the given pairs of plugs and slots in a single
+change, setting up the security profiles of each snap involved only once,
+skipping those not made. If one of the connections cannot be broken, none is.
+
 Application Options:
       --version            Print the version and exit
 
 Help Options:
   -h, --help               Show this help message
+
+[disconnect command options]
+          --batch          Break the connections of the given pairs of plugs
+                           and slots in one go
 `
 	rest, err := Parser().ParseArgs([]string{"disconnect", "--help"})
 	c.Assert(err.Error(), Equals, msg)
 	c.Assert(rest, DeepEquals, []string{})
 }
 
+func (s *SnapSuite) TestDisconnectBatch(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/connections":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "disconnect",
+				"connections": []interface{}{
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer", "plug": "plug"},
+						"slot": map[string]interface{}{"snap": "producer", "slot": "slot"},
+					},
+					map[string]interface{}{
+						"plug": map[string]interface{}{"snap": "consumer", "plug": "network"},
+						"slot": map[string]interface{}{"snap": "", "slot": "network"},
+					},
+				},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	_, err := Parser().ParseArgs([]string{"disconnect", "--batch", "consumer:plug", "producer:slot", "consumer:network", ":network"})
+	c.Assert(err, IsNil)
+}
+
 func (s *SnapSuite) TestDisconnectExplicitEverything(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -116,7 +116,7 @@ func (x *cmdDownload) Execute(args []string) error {
 	fmt.Fprintf(Stderr, i18n.G("Fetching snap %q\n"), snapName)
 	dlOpts := image.DownloadOptions{
 		TargetDir: "", // cwd
-		Channel:   x.Channel,
+		Channel:   string(x.Channel),
 	}
 	snapPath, snapInfo, err := tsto.DownloadSnap(snapName, revision, &dlOpts)
 	if err != nil {
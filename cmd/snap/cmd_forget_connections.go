@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+
+	"github.com/jessevdk/go-flags"
+)
+
+type cmdForgetConnections struct {
+	Positionals struct {
+		Snap anySnapName `required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortForgetConnectionsHelp = i18n.G("Forget the connections kept for a removed snap")
+var longForgetConnectionsHelp = i18n.G(`
+The forget-connections command drops the connections kept after the removal
+of the given snap, or of the snaps it was connected to, so that they are not
+restored if the snap is installed again.
+
+Connections are only kept when the interfaces.removal-grace-period core
+option is set, for that long.
+`)
+
+func init() {
+	addCommand("forget-connections", shortForgetConnectionsHelp, longForgetConnectionsHelp, func() flags.Commander {
+		return &cmdForgetConnections{}
+	}, nil, []argDesc{
+		{name: "<snap>"},
+	})
+}
+
+func (x *cmdForgetConnections) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName := string(x.Positionals.Snap)
+	n, err := Client().ForgetConnections(snapName)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Fprintf(Stdout, i18n.G("No connections kept for snap %q.\n"), snapName)
+		return nil
+	}
+	fmt.Fprintf(Stdout, i18n.NG("Forgot %d connection kept for snap %q.\n", "Forgot %d connections kept for snap %q.\n", uint32(n)), n, snapName)
+	return nil
+}
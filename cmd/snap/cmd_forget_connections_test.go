@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestForgetConnections(c *C) {
+	forgotten := 2
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/interfaces")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+			"action": "forget-connections",
+			"snap":   "some-snap",
+		})
+		fmt.Fprintf(w, `{"type":"sync", "result":{"forgotten": %d}}`, forgotten)
+	})
+	rest, err := Parser().ParseArgs([]string{"forget-connections", "some-snap"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Forgot 2 connections kept for snap \"some-snap\".\n")
+	c.Check(s.Stderr(), Equals, "")
+
+	s.ResetStdStreams()
+	forgotten = 0
+	_, err = Parser().ParseArgs([]string{"forget-connections", "some-snap"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "No connections kept for snap \"some-snap\".\n")
+}
+
+func (s *SnapSuite) TestForgetConnectionsRequiresSnap(c *C) {
+	_, err := Parser().ParseArgs([]string{"forget-connections"})
+	c.Assert(err, ErrorMatches, "the required argument `<snap>` was not provided")
+}
@@ -100,6 +100,34 @@ func maybePrintID(w io.Writer, snap *client.Snap) {
 	}
 }
 
+// publisherBadge returns the mark shown next to the publisher name for
+// the store's validation of the publisher, if any.
+func publisherBadge(validation string) string {
+	switch validation {
+	case "verified":
+		return "✓"
+	case "starred":
+		return "✪"
+	}
+	return ""
+}
+
+// maybePrintPublisher prints the publisher with its validation badge and
+// contact, and the other snaps it publishes, using the store details
+// when there are some as those are not kept locally.
+func maybePrintPublisher(w io.Writer, both, remote *client.Snap) {
+	if remote == nil {
+		remote = &client.Snap{}
+	}
+	fmt.Fprintf(w, "publisher:\t%s%s\n", both.Developer, publisherBadge(remote.PublisherValidation))
+	if remote.PublisherContact != "" {
+		fmt.Fprintf(w, "publisher-contact:\t%s\n", strings.TrimPrefix(remote.PublisherContact, "mailto:"))
+	}
+	if len(remote.PublisherSnaps) > 0 {
+		fmt.Fprintf(w, "publisher-snaps:\t%s\n", strings.Join(remote.PublisherSnaps, ", "))
+	}
+}
+
 func tryDirect(w io.Writer, path string, verbose bool) bool {
 	path = norm(path)
 
@@ -309,7 +337,7 @@ func (x *infoCmd) Execute([]string) error {
 		fmt.Fprintf(w, "summary:\t%s\n", formatSummary(both.Summary))
 		// TODO: have publisher; use publisher here,
 		// and additionally print developer if publisher != developer
-		fmt.Fprintf(w, "publisher:\t%s\n", both.Developer)
+		maybePrintPublisher(w, both, remote)
 		if both.Contact != "" {
 			fmt.Fprintf(w, "contact:\t%s\n", strings.TrimPrefix(both.Contact, "mailto:"))
 		}
@@ -345,7 +373,18 @@ func (x *infoCmd) Execute([]string) error {
 				notes = NotesFromLocal(local)
 			}
 
-			fmt.Fprintf(w, "tracking:\t%s\n", local.TrackingChannel)
+			if local.ClosedBranch {
+				fmt.Fprintf(w, "tracking:\t%s (branch closed)\n", local.TrackingChannel)
+			} else {
+				fmt.Fprintf(w, "tracking:\t%s\n", local.TrackingChannel)
+			}
+			if local.BranchExpiry != "" || local.ClosedBranch {
+				branchExpiry := local.BranchExpiry
+				if branchExpiry == "" {
+					branchExpiry = "notify"
+				}
+				fmt.Fprintf(w, "branch-expiry:\t%s\n", branchExpiry)
+			}
 			fmt.Fprintf(w, "installed:\t%s\t(%s)\t%s\t%s\n", local.Version, local.Revision, strutil.SizeToStr(local.InstalledSize), notes)
 			fmt.Fprintf(w, "refreshed:\t%s\n", local.InstallDate)
 		}
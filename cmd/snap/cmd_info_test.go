@@ -90,6 +90,34 @@ func (s *SnapSuite) TestMaybePrintCommandsNoCommands(c *check.C) {
 	}
 }
 
+func (s *SnapSuite) TestMaybePrintPublisher(c *check.C) {
+	local := &client.Snap{Developer: "canonical"}
+	remote := &client.Snap{
+		Developer:           "canonical",
+		PublisherValidation: "verified",
+		PublisherContact:    "mailto:snaps@canonical.com",
+		PublisherSnaps:      []string{"core", "lxd"},
+	}
+
+	var buf bytes.Buffer
+	snap.MaybePrintPublisher(&buf, local, remote)
+	c.Check(buf.String(), check.Equals, `publisher:	canonical✓
+publisher-contact:	snaps@canonical.com
+publisher-snaps:	core, lxd
+`)
+
+	buf.Reset()
+	remote.PublisherValidation = "starred"
+	remote.PublisherContact = ""
+	remote.PublisherSnaps = nil
+	snap.MaybePrintPublisher(&buf, local, remote)
+	c.Check(buf.String(), check.Equals, "publisher:\tcanonical✪\n")
+
+	buf.Reset()
+	snap.MaybePrintPublisher(&buf, local, nil)
+	c.Check(buf.String(), check.Equals, "publisher:\tcanonical\n")
+}
+
 func (s *SnapSuite) TestInfoPriced(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -185,3 +213,53 @@ snap-id: mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 }
+
+const mockLocalClosedBranchJSON = `
+{
+  "type": "sync",
+  "status-code": 200,
+  "status": "OK",
+  "result": {
+    "channel": "stable",
+    "confinement": "strict",
+    "description": "GNU hello prints a friendly greeting. This is part of the snapcraft tour at https://snapcraft.io/",
+    "developer": "canonical",
+    "id": "mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6",
+    "install-date": "2006-01-02T22:04:07.123456789Z",
+    "installed-size": 1024,
+    "name": "hello",
+    "revision": "100",
+    "status": "active",
+    "summary": "The GNU Hello snap",
+    "type": "app",
+    "version": "2.10",
+    "tracking-channel": "stable/hotfix",
+    "branch-expiry": "stay",
+    "closed-branch": true
+  }
+}
+`
+
+func (s *SnapSuite) TestInfoClosedBranch(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			fmt.Fprintln(w, mockInfoJSON)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/hello")
+			fmt.Fprintln(w, mockLocalClosedBranchJSON)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d (%v)", n+1, r)
+		}
+
+		n++
+	})
+	_, err := snap.Parser().ParseArgs([]string{"info", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Matches, `(?ms).*^tracking: +stable/hotfix \(branch closed\)\nbranch-expiry: +stay\ninstalled: .*`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
@@ -32,9 +32,10 @@ import (
 )
 
 type cmdInterface struct {
-	ShowAttrs   bool `long:"attrs"`
-	ShowAll     bool `long:"all"`
-	Positionals struct {
+	ShowAttrs      bool `long:"attrs"`
+	ShowAll        bool `long:"all"`
+	ShowDeprecated bool `long:"deprecated"`
+	Positionals    struct {
 		Interface interfaceName `skip-help:"true"`
 	} `positional-args:"true"`
 }
@@ -45,14 +46,17 @@ The interface command shows details of snap interfaces.
 
 If no interface name is provided, a list of interface names with at least
 one connection is shown, or a list of all interfaces if --all is provided.
+The deprecated interfaces, and what replaces them, are listed with
+--deprecated.
 `)
 
 func init() {
 	addCommand("interface", shortInterfaceHelp, longInterfaceHelp, func() flags.Commander {
 		return &cmdInterface{}
 	}, map[string]string{
-		"attrs": i18n.G("Show interface attributes"),
-		"all":   i18n.G("Include unused interfaces"),
+		"attrs":      i18n.G("Show interface attributes"),
+		"all":        i18n.G("Include unused interfaces"),
+		"deprecated": i18n.G("Only list deprecated interfaces"),
 	}, []argDesc{{
 		name: i18n.G("<interface>"),
 		desc: i18n.G("Show details of a specific interface"),
@@ -64,6 +68,13 @@ func (x *cmdInterface) Execute(args []string) error {
 		return ErrExtraArgs
 	}
 
+	if x.ShowDeprecated {
+		if x.Positionals.Interface != "" {
+			return fmt.Errorf(i18n.G("cannot use --deprecated with an interface name"))
+		}
+		return x.showDeprecatedInterfaces()
+	}
+
 	if x.Positionals.Interface != "" {
 		// Show one interface in detail.
 		name := string(x.Positionals.Interface)
@@ -110,12 +121,24 @@ func (x *cmdInterface) showOneInterface(iface *client.Interface) {
 	if iface.DocURL != "" {
 		fmt.Fprintf(w, "documentation:\t%s\n", iface.DocURL)
 	}
+	if iface.Deprecated {
+		if iface.ReplacedBy != "" {
+			fmt.Fprintf(w, "deprecated:\treplaced by %s\n", iface.ReplacedBy)
+		} else {
+			fmt.Fprintf(w, "deprecated:\tyes\n")
+		}
+	}
+	if x.ShowAttrs {
+		x.showAttrSchema(w, "plug-attributes", iface.PlugAttrs)
+		x.showAttrSchema(w, "slot-attributes", iface.SlotAttrs)
+		x.showAttrSchema(w, "connection-attributes", iface.ConnectionAttrs)
+	}
 	if len(iface.Plugs) > 0 {
 		fmt.Fprintf(w, "plugs:\n")
 		for _, plug := range iface.Plugs {
 			var labelPart string
-			if plug.Label != "" {
-				labelPart = fmt.Sprintf(" (%s)", plug.Label)
+			if label := client.LocalizedLabel(plug.Label, plug.Labels, i18n.Language()); label != "" {
+				labelPart = fmt.Sprintf(" (%s)", label)
 			}
 			if plug.Name == iface.Name {
 				fmt.Fprintf(w, "  - %s%s", plug.Snap, labelPart)
@@ -136,8 +159,8 @@ func (x *cmdInterface) showOneInterface(iface *client.Interface) {
 		fmt.Fprintf(w, "slots:\n")
 		for _, slot := range iface.Slots {
 			var labelPart string
-			if slot.Label != "" {
-				labelPart = fmt.Sprintf(" (%s)", slot.Label)
+			if label := client.LocalizedLabel(slot.Label, slot.Labels, i18n.Language()); label != "" {
+				labelPart = fmt.Sprintf(" (%s)", label)
 			}
 			if slot.Name == iface.Name {
 				fmt.Fprintf(w, "  - %s%s", slot.Snap, labelPart)
@@ -165,6 +188,56 @@ func (x *cmdInterface) showManyInterfaces(infos []*client.Interface) {
 	}
 }
 
+// showAttrSchema describes the attributes the plugs, slots or connections
+// of the interface can have, as told by the heading.
+func (x *cmdInterface) showAttrSchema(w io.Writer, heading string, attrs []client.InterfaceAttr) {
+	if len(attrs) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", heading)
+	for _, attr := range attrs {
+		kind := attr.Type
+		if attr.Required {
+			kind += ", required"
+		}
+		if attr.Description == "" {
+			fmt.Fprintf(w, "  %s:\t(%s)\n", attr.Name, kind)
+		} else {
+			fmt.Fprintf(w, "  %s:\t%s (%s)\n", attr.Name, attr.Description, kind)
+		}
+	}
+}
+
+// showDeprecatedInterfaces lists the deprecated interfaces along with
+// the interfaces replacing them.
+func (x *cmdInterface) showDeprecatedInterfaces() error {
+	ifaces, err := Client().Interfaces(nil)
+	if err != nil {
+		return err
+	}
+	var deprecated []*client.Interface
+	for _, iface := range ifaces {
+		if iface.Deprecated {
+			deprecated = append(deprecated, iface)
+		}
+	}
+	if len(deprecated) == 0 {
+		return fmt.Errorf(i18n.G("no deprecated interfaces found"))
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Name\tReplaced by\tSummary"))
+	for _, iface := range deprecated {
+		replacedBy := iface.ReplacedBy
+		if replacedBy == "" {
+			replacedBy = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", iface.Name, replacedBy, iface.Summary)
+	}
+	return nil
+}
+
 func (x *cmdInterface) showAttrs(w io.Writer, attrs map[string]interface{}, indent string) {
 	if len(attrs) == 0 {
 		return
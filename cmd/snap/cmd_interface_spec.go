@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdInterfaceSpec struct {
+	Backend     string `long:"backend" description:"Only show the rules of the given security backend"`
+	Positionals struct {
+		Snap installedSnapName `positional-arg-name:"<snap>" required:"1"`
+	} `positional-args:"true"`
+}
+
+var shortInterfaceSpecHelp = i18n.G("(internal) show the security rules the interfaces of a snap add")
+var longInterfaceSpecHelp = i18n.G(`
+The interface-spec command shows the rules the plugs and slots of the given
+snap add, with its current connections, to each of its security profiles:
+apparmor, seccomp, udev, mount, systemd, dbus and kmod. The rules are
+grouped by what they apply to, an application or hook for the apparmor,
+seccomp and dbus backends, a service for the systemd backend and the whole
+snap for the others.
+
+Only the rules added by interfaces are shown, not the base templates the
+profiles are made from.
+`)
+
+func init() {
+	addDebugCommand("interface-spec", shortInterfaceSpecHelp, longInterfaceSpecHelp, func() flags.Commander {
+		return &cmdInterfaceSpec{}
+	})
+}
+
+func (x *cmdInterfaceSpec) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName := string(x.Positionals.Snap)
+	params := map[string]string{"snap": snapName}
+	var specs map[string]map[string]string
+	if err := Client().Debug("interface-spec", params, &specs); err != nil {
+		return err
+	}
+	if x.Backend != "" {
+		specs = map[string]map[string]string{x.Backend: specs[x.Backend]}
+	}
+
+	backends := make([]string, 0, len(specs))
+	for backend, snippets := range specs {
+		if len(snippets) > 0 {
+			backends = append(backends, backend)
+		}
+	}
+	if len(backends) == 0 {
+		fmt.Fprintf(Stderr, i18n.G("The interfaces of %q add no security rules.\n"), snapName)
+		return nil
+	}
+	sort.Strings(backends)
+
+	first := true
+	for _, backend := range backends {
+		keys := make([]string, 0, len(specs[backend]))
+		for key := range specs[backend] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !first {
+				fmt.Fprintln(Stdout)
+			}
+			first = false
+			fmt.Fprintf(Stdout, "%s: %s\n", backend, key)
+			for _, line := range strings.Split(specs[backend][key], "\n") {
+				fmt.Fprintf(Stdout, "  %s\n", line)
+			}
+		}
+	}
+	return nil
+}
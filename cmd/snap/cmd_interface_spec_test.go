@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const interfaceSpecResponse = `{"type": "sync", "result": {
+"apparmor": {"snap.foo.app": "/dev/bar r,\n/dev/foo rw,", "snap.foo.hook.configure": "/dev/foo rw,"},
+"udev": {"foo": "KERNEL==\"foo\", TAG+=\"snap_foo_app\""}
+}}`
+
+func (s *SnapSuite) TestInterfaceSpec(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug")
+			data, err := ioutil.ReadAll(r.Body)
+			c.Check(err, check.IsNil)
+			c.Check(string(data), check.Equals, `{"action":"interface-spec","params":{"snap":"foo"}}`)
+			fmt.Fprintln(w, interfaceSpecResponse)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "interface-spec", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `apparmor: snap.foo.app
+  /dev/bar r,
+  /dev/foo rw,
+
+apparmor: snap.foo.hook.configure
+  /dev/foo rw,
+
+udev: foo
+  KERNEL=="foo", TAG+="snap_foo_app"
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestInterfaceSpecBackend(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, interfaceSpecResponse)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"debug", "interface-spec", "--backend=udev", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `udev: foo
+  KERNEL=="foo", TAG+="snap_foo_app"
+`)
+
+	s.ResetStdStreams()
+	_, err = snap.Parser().ParseArgs([]string{"debug", "interface-spec", "--backend=seccomp", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "The interfaces of \"foo\" add no security rules.\n")
+}
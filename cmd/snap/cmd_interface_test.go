@@ -39,6 +39,8 @@ The interface command shows details of snap interfaces.
 
 If no interface name is provided, a list of interface names with at least
 one connection is shown, or a list of all interfaces if --all is provided.
+The deprecated interfaces, and what replaces them, are listed with
+--deprecated.
 
 Application Options:
       --version          Print the version and exit
@@ -49,6 +51,7 @@ Help Options:
 [interface command options]
           --attrs        Show interface attributes
           --all          Include unused interfaces
+          --deprecated   Only list deprecated interfaces
 
 [interface command arguments]
   <interface>:           Show details of a specific interface
@@ -162,6 +165,58 @@ func (s *SnapSuite) TestInterfaceListAll(c *C) {
 	c.Assert(s.Stderr(), Equals, "")
 }
 
+func (s *SnapSuite) TestInterfaceListDeprecated(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/interfaces")
+		c.Check(r.URL.RawQuery, Equals, "select=all")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type": "sync",
+			"result": []*client.Interface{{
+				Name:    "network",
+				Summary: "allows access to the network",
+			}, {
+				Name:       "old-network",
+				Summary:    "allows access to the network, the old way",
+				Deprecated: true,
+				ReplacedBy: "network",
+			}, {
+				Name:       "unused",
+				Summary:    "just an unused interface, nothing to see here",
+				Deprecated: true,
+			}},
+		})
+	})
+	rest, err := Parser().ParseArgs([]string{"interface", "--deprecated"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Name         Replaced by  Summary\n" +
+		"old-network  network      allows access to the network, the old way\n" +
+		"unused       -            just an unused interface, nothing to see here\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestInterfaceListDeprecatedNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type": "sync",
+			"result": []*client.Interface{{
+				Name:    "network",
+				Summary: "allows access to the network",
+			}},
+		})
+	})
+	_, err := Parser().ParseArgs([]string{"interface", "--deprecated"})
+	c.Assert(err, ErrorMatches, "no deprecated interfaces found")
+}
+
+func (s *SnapSuite) TestInterfaceDeprecatedWithName(c *C) {
+	_, err := Parser().ParseArgs([]string{"interface", "--deprecated", "network"})
+	c.Assert(err, ErrorMatches, "cannot use --deprecated with an interface name")
+}
+
 func (s *SnapSuite) TestInterfaceDetails(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.Method, Equals, "GET")
@@ -173,9 +228,11 @@ func (s *SnapSuite) TestInterfaceDetails(c *C) {
 		EncodeResponseBody(c, w, map[string]interface{}{
 			"type": "sync",
 			"result": []*client.Interface{{
-				Name:    "network",
-				Summary: "allows access to the network",
-				DocURL:  "http://example.org/about-the-network-interface",
+				Name:       "network",
+				Summary:    "allows access to the network",
+				DocURL:     "http://example.org/about-the-network-interface",
+				Deprecated: true,
+				ReplacedBy: "network-v2",
 				Plugs: []client.Plug{
 					{Snap: "deepin-music", Name: "network"},
 					{Snap: "http", Name: "network"},
@@ -191,6 +248,7 @@ func (s *SnapSuite) TestInterfaceDetails(c *C) {
 		"name:          network\n" +
 		"summary:       allows access to the network\n" +
 		"documentation: http://example.org/about-the-network-interface\n" +
+		"deprecated:    replaced by network-v2\n" +
 		"plugs:\n" +
 		"  - deepin-music\n" +
 		"  - http\n" +
@@ -213,6 +271,13 @@ func (s *SnapSuite) TestInterfaceDetailsAndAttrs(c *C) {
 			"result": []*client.Interface{{
 				Name:    "serial-port",
 				Summary: "allows providing or using a specific serial port",
+				SlotAttrs: []client.InterfaceAttr{
+					{Name: "path", Type: "string", Required: true, Description: "path of the device node"},
+					{Name: "usb-vendor", Type: "int"},
+				},
+				ConnectionAttrs: []client.InterfaceAttr{
+					{Name: "path", Type: "string", Description: "device node the plug is restricted to"},
+				},
 				Plugs: []client.Plug{
 					{Snap: "minicom", Name: "serial-port"},
 				},
@@ -235,6 +300,11 @@ func (s *SnapSuite) TestInterfaceDetailsAndAttrs(c *C) {
 	expectedStdout := "" +
 		"name:    serial-port\n" +
 		"summary: allows providing or using a specific serial port\n" +
+		"slot-attributes:\n" +
+		"  path:       path of the device node (string, required)\n" +
+		"  usb-vendor: (int)\n" +
+		"connection-attributes:\n" +
+		"  path: device node the plug is restricted to (string)\n" +
 		"plugs:\n" +
 		"  - minicom\n" +
 		"slots:\n" +
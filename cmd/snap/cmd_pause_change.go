@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdPauseChange struct{ changeIDMixin }
+
+type cmdResumeChange struct{ changeIDMixin }
+
+var shortPauseChangeHelp = i18n.G("Pause a pending change")
+
+var longPauseChangeHelp = i18n.G(`
+The pause-change command stops a change from starting any further tasks.
+Tasks that are already running are allowed to finish. The change carries
+on from where it stopped once resumed with resume-change.
+`)
+
+var shortResumeChangeHelp = i18n.G("Resume a paused change")
+
+var longResumeChangeHelp = i18n.G(`
+The resume-change command lets a change paused with pause-change carry on
+with its pending tasks.
+`)
+
+func init() {
+	addCommand("pause-change",
+		shortPauseChangeHelp,
+		longPauseChangeHelp,
+		func() flags.Commander {
+			return &cmdPauseChange{}
+		},
+		changeIDMixinOptDesc,
+		changeIDMixinArgDesc,
+	)
+	addCommand("resume-change",
+		shortResumeChangeHelp,
+		longResumeChangeHelp,
+		func() flags.Commander {
+			return &cmdResumeChange{}
+		},
+		changeIDMixinOptDesc,
+		changeIDMixinArgDesc,
+	)
+}
+
+func (x *cmdPauseChange) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	id, err := x.GetChangeID(cli)
+	if err != nil {
+		return err
+	}
+	_, err = cli.Pause(id)
+	return err
+}
+
+func (x *cmdResumeChange) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	id, err := x.GetChangeID(cli)
+	if err != nil {
+		return err
+	}
+	_, err = cli.Resume(id)
+	return err
+}
@@ -36,17 +36,38 @@ type cmdPrepareImage struct {
 
 	ExtraSnaps []string `long:"extra-snaps"`
 	Channel    string   `long:"channel" default:"stable"`
+	Customize  string   `long:"customize"`
 }
 
+var longPrepareImageHelp = i18n.G(`
+The prepare-image command prepares a snappy image for the given model.
+
+With --customize a YAML file can be given to customize the image, e.g.:
+
+    console-conf: disabled
+    cloud-init-user-data: |
+      #cloud-config
+      ...
+    defaults:
+      <snap-name>:
+        <key>: <value>
+
+console-conf can be set to "disabled" so that it does not run on first
+boot. cloud-init-user-data is seeded for cloud-init (as cloud-config or
+a script). defaults sets default configuration for snaps in the image,
+overriding the one from the gadget.
+`)
+
 func init() {
 	cmd := addCommand("prepare-image",
 		i18n.G("Prepare a snappy image"),
-		i18n.G("Prepare a snappy image"),
+		longPrepareImageHelp,
 		func() flags.Commander {
 			return &cmdPrepareImage{}
 		}, map[string]string{
 			"extra-snaps": "Extra snaps to be installed",
 			"channel":     "The channel to use",
+			"customize":   "Customizations file to embed into the image",
 		}, []argDesc{
 			{
 				name: i18n.G("<model-assertion>"),
@@ -69,5 +90,13 @@ func (x *cmdPrepareImage) Execute(args []string) error {
 		Snaps:           x.ExtraSnaps,
 	}
 
+	if x.Customize != "" {
+		cust, err := image.ReadCustomizations(x.Customize)
+		if err != nil {
+			return err
+		}
+		opts.Customizations = cust
+	}
+
 	return image.Prepare(opts)
 }
@@ -0,0 +1,39 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestPrepareImageBadCustomizations(c *check.C) {
+	d := c.MkDir()
+	custFn := filepath.Join(d, "customize.yaml")
+	err := ioutil.WriteFile(custFn, []byte("console-conf: enabled\n"), 0644)
+	c.Assert(err, check.IsNil)
+
+	_, err = snap.Parser().ParseArgs([]string{"prepare-image", "--customize", custFn, filepath.Join(d, "model"), d})
+	c.Assert(err, check.ErrorMatches, `cannot use console-conf customization "enabled": only "disabled" is supported`)
+}
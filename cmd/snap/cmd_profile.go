@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdProfile struct {
+	Kind     string        `long:"kind" default:"cpu" choice:"cpu" choice:"heap" choice:"block" description:"Kind of profile to capture"`
+	Duration time.Duration `long:"duration" default:"30s" description:"How long to capture CPU and blocking profiles for"`
+	Output   string        `long:"output" description:"Where to save the profile (defaults to its name, in the current directory)"`
+}
+
+var shortProfileHelp = i18n.G("(internal) capture a profile of snapd")
+var longProfileHelp = i18n.G(`
+The profile command captures a profile of the running snapd, of the CPU
+use or of the blocking of its goroutines for the given duration, or of
+the objects on its heap, for use with "go tool pprof".
+
+The profile is kept in /var/lib/snapd/debug and saved to the current
+directory, or to the file given with --output.
+`)
+
+func init() {
+	addDebugCommand("profile", shortProfileHelp, longProfileHelp, func() flags.Commander {
+		return &cmdProfile{}
+	})
+}
+
+func (x *cmdProfile) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if x.Kind != "heap" && x.Duration < time.Second {
+		return fmt.Errorf(i18n.G("cannot capture profile for less than a second"))
+	}
+
+	params := map[string]interface{}{
+		"kind":     x.Kind,
+		"duration": int(x.Duration / time.Second),
+	}
+	var profile struct {
+		Name string `json:"name"`
+	}
+	if x.Kind != "heap" {
+		fmt.Fprintf(Stderr, i18n.G("Capturing %s profile for %v...\n"), x.Kind, x.Duration)
+	}
+	cli := Client()
+	if err := cli.Debug("profile", params, &profile); err != nil {
+		return err
+	}
+
+	r, err := cli.DebugProfile(profile.Name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	output := x.Output
+	if output == "" {
+		output = profile.Name
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot save profile: %v"), err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf(i18n.G("cannot save profile: %v"), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf(i18n.G("cannot save profile: %v"), err)
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Saved %s profile to %s.\n"), x.Kind, output)
+	return nil
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockProfile(c *check.C, expectedBody string) *int {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug")
+			data, err := ioutil.ReadAll(r.Body)
+			c.Check(err, check.IsNil)
+			c.Check(string(data), check.Equals, expectedBody)
+			fmt.Fprintln(w, `{"type": "sync", "result": {"name": "cpu-20181010-101010.000000.pprof", "kind": "cpu"}}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug/profiles/cpu-20181010-101010.000000.pprof")
+			fmt.Fprint(w, "profile data")
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	return &n
+}
+
+func (s *SnapSuite) TestProfile(c *check.C) {
+	n := s.mockProfile(c, `{"action":"profile","params":{"duration":10,"kind":"cpu"}}`)
+	output := filepath.Join(c.MkDir(), "snapd.pprof")
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "profile", "--duration=10s", "--output", output})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(*n, check.Equals, 2)
+	c.Check(s.Stdout(), check.Equals, fmt.Sprintf("Saved cpu profile to %s.\n", output))
+	c.Check(s.Stderr(), check.Equals, "Capturing cpu profile for 10s...\n")
+	data, err := ioutil.ReadFile(output)
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Equals, "profile data")
+}
+
+func (s *SnapSuite) TestProfileTooShort(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "profile", "--duration=10ms"})
+	c.Assert(err, check.ErrorMatches, "cannot capture profile for less than a second")
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdRoutineConsoleConf struct {
+	Positional struct {
+		Action string `positional-arg-name:"<action>"`
+	} `positional-args:"yes"`
+}
+
+var shortRoutineConsoleConfHelp = i18n.G("Control and report the state of console-conf")
+var longRoutineConsoleConfHelp = i18n.G(`
+The console-conf command prints the state of console-conf, the first-boot
+configuration tool of Ubuntu Core: pending, started, completed or disabled.
+
+When given an action, one of start, stop or disable, it records that
+console-conf was started or that it ran to completion, or it prevents
+console-conf from running at all, and then prints the resulting state.
+`)
+
+func init() {
+	addRoutineCommand("console-conf", shortRoutineConsoleConfHelp, longRoutineConsoleConfHelp, func() flags.Commander {
+		return &cmdRoutineConsoleConf{}
+	})
+}
+
+func (x *cmdRoutineConsoleConf) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var info *client.ConsoleConfInfo
+	var err error
+	switch x.Positional.Action {
+	case "":
+		info, err = Client().ConsoleConf()
+	case "start", "stop", "disable":
+		info, err = Client().ConsoleConfAction(x.Positional.Action)
+	default:
+		return fmt.Errorf(i18n.G("unknown console-conf action %q"), x.Positional.Action)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(Stdout, info.Status)
+	return nil
+}
@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestRoutineConsoleConfStatus(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/console-conf")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"status": "pending"}}`)
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"routine", "console-conf"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "pending\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestRoutineConsoleConfAction(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/console-conf")
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, IsNil)
+		c.Check(string(body), Equals, `{"action":"disable"}`)
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"status": "disabled"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "console-conf", "disable"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "disabled\n")
+}
+
+func (s *SnapSuite) TestRoutineConsoleConfUnknownAction(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"routine", "console-conf", "frobble"})
+	c.Assert(err, ErrorMatches, `unknown console-conf action "frobble"`)
+}
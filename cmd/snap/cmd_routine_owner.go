@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdRoutineOwner struct {
+	Pid        int `long:"pid" description:"Look up the snap of the running process with this pid"`
+	Positional struct {
+		Path string `positional-arg-name:"<path>"`
+	} `positional-args:"yes"`
+}
+
+var shortRoutineOwnerHelp = i18n.G("Print the snap that owns a path or a process")
+var longRoutineOwnerHelp = i18n.G(`
+The owner command prints the snap, and the revision of it, that owns the
+given path under the snap mount directory, or with --pid the snap the
+given running process belongs to, together with its app when known.
+`)
+
+func init() {
+	addRoutineCommand("owner", shortRoutineOwnerHelp, longRoutineOwnerHelp, func() flags.Commander {
+		return &cmdRoutineOwner{}
+	})
+}
+
+func (x *cmdRoutineOwner) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if (x.Positional.Path == "") == (x.Pid == 0) {
+		return fmt.Errorf(i18n.G("please provide either a path or --pid"))
+	}
+
+	var owner *client.SnapOwner
+	var err error
+	if x.Pid != 0 {
+		owner, err = Client().PidOwner(x.Pid)
+	} else {
+		owner, err = Client().PathOwner(x.Positional.Path)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "snap: %s\n", owner.Snap)
+	fmt.Fprintf(Stdout, "revision: %s\n", owner.Revision)
+	if owner.App != "" {
+		fmt.Fprintf(Stdout, "app: %s\n", owner.App)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestRoutineOwnerPath(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/routine/owner")
+		c.Check(r.URL.Query().Get("path"), Equals, "/snap/foo/current/bin/foo")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"snap": "foo", "revision": "2"}}`)
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"routine", "owner", "/snap/foo/current/bin/foo"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "snap: foo\nrevision: 2\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestRoutineOwnerPid(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/routine/owner")
+		c.Check(r.URL.Query().Get("pid"), Equals, "42")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"snap": "foo", "revision": "2", "app": "svc"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "owner", "--pid", "42"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "snap: foo\nrevision: 2\napp: svc\n")
+}
+
+func (s *SnapSuite) TestRoutineOwnerNotFound(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprintln(w, `{"type":"error", "status-code": 404, "result": {"message": "no snap owns path \"/etc\""}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"routine", "owner", "/etc"})
+	c.Assert(err, ErrorMatches, `no snap owns path "/etc"`)
+}
+
+func (s *SnapSuite) TestRoutineOwnerNeedsPathOrPid(c *C) {
+	for _, args := range [][]string{
+		{"routine", "owner"},
+		{"routine", "owner", "--pid", "42", "/snap/foo"},
+	} {
+		_, err := snap.Parser().ParseArgs(args)
+		c.Assert(err, ErrorMatches, "please provide either a path or --pid")
+	}
+}
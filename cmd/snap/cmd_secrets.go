@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n"
+
+	"github.com/jessevdk/go-flags"
+)
+
+type cmdSecrets struct{}
+
+type cmdSetSecret struct {
+	Positionals struct {
+		Name string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+type cmdRemoveSecret struct {
+	Positionals struct {
+		Name string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortSecretsHelp = i18n.G("List the secrets stored in snapd")
+var longSecretsHelp = i18n.G(`
+The secrets command lists the names of the secrets stored in snapd. The
+values of the secrets are never displayed.
+`)
+
+var shortSetSecretHelp = i18n.G("Store a secret in snapd")
+var longSetSecretHelp = i18n.G(`
+The set-secret command stores the value read from standard input as the
+named secret, sealing it with the backend configured via the
+secrets.backend and secrets.command core options.
+
+Snaps can read the secret with "snapctl secret get" if they have a
+connected secrets plug listing its name.
+`)
+
+var shortRemoveSecretHelp = i18n.G("Remove a secret from snapd")
+var longRemoveSecretHelp = i18n.G(`
+The remove-secret command removes the named secret from snapd.
+`)
+
+func init() {
+	addCommand("secrets", shortSecretsHelp, longSecretsHelp, func() flags.Commander {
+		return &cmdSecrets{}
+	}, nil, nil)
+	addCommand("set-secret", shortSetSecretHelp, longSetSecretHelp, func() flags.Commander {
+		return &cmdSetSecret{}
+	}, nil, []argDesc{
+		{name: i18n.G("<name>")},
+	})
+	addCommand("remove-secret", shortRemoveSecretHelp, longRemoveSecretHelp, func() flags.Commander {
+		return &cmdRemoveSecret{}
+	}, nil, []argDesc{
+		{name: i18n.G("<name>")},
+	})
+}
+
+func (x *cmdSecrets) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	names, err := Client().Secrets()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No secrets are stored."))
+		return nil
+	}
+	for _, name := range names {
+		fmt.Fprintln(Stdout, name)
+	}
+	return nil
+}
+
+func (x *cmdSetSecret) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	value, err := ioutil.ReadAll(Stdin)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read secret value: %v"), err)
+	}
+	// "echo s3cr3t | snap set-secret ..." should not store the newline
+	return Client().SetSecret(x.Positionals.Name, strings.TrimSuffix(string(value), "\n"))
+}
+
+func (x *cmdRemoveSecret) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	return Client().RemoveSecret(x.Positionals.Name)
+}
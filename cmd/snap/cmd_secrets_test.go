@@ -0,0 +1,83 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSecrets(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/secrets")
+		fmt.Fprintln(w, `{"type":"sync", "result":["api-token", "db-password"]}`)
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"secrets"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "api-token\ndb-password\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSecretsEmpty(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "result":[]}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"secrets"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No secrets are stored.\n")
+}
+
+func (s *SnapSuite) TestSetSecret(c *C) {
+	s.stdin.WriteString("s3cr3t\n")
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/secrets")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+			"action": "set",
+			"name":   "db-password",
+			"value":  "s3cr3t",
+		})
+		fmt.Fprintln(w, `{"type":"sync", "result":null}`)
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"set-secret", "db-password"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *SnapSuite) TestRemoveSecret(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+			"action": "remove",
+			"name":   "db-password",
+		})
+		fmt.Fprintln(w, `{"type":"error", "status-code": 404, "result":{"message":"cannot find secret \"db-password\""}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"remove-secret", "db-password"})
+	c.Assert(err, ErrorMatches, `cannot find secret "db-password"`)
+}
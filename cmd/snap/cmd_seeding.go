@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdSeeding struct {
+	Wait bool `long:"wait" description:"Wait until the system is seeded, or seeding fails"`
+}
+
+var shortSeedingHelp = i18n.G("(internal) show the progress of seeding the system")
+var longSeedingHelp = i18n.G(`
+The seeding command shows how far seeding the system on first boot is,
+with the phase it is in and the progress of each of the snaps being
+seeded. The core, kernel and gadget snaps are marked as essential.
+
+With --wait, it blocks until the system is seeded, failing if seeding
+fails.
+`)
+
+func init() {
+	addDebugCommand("seeding", shortSeedingHelp, longSeedingHelp, func() flags.Commander {
+		return &cmdSeeding{}
+	})
+}
+
+func (x *cmdSeeding) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	var status *client.SeedingStatus
+	for {
+		var err error
+		status, err = cli.Seeding()
+		if err != nil {
+			return err
+		}
+		if !x.Wait || status.Seeded || status.Phase == "error" {
+			break
+		}
+		time.Sleep(pollTime)
+	}
+
+	seeded := i18n.G("no")
+	if status.Seeded {
+		seeded = i18n.G("yes")
+	}
+	fmt.Fprintf(Stdout, i18n.G("seeded: %s\n"), seeded)
+	fmt.Fprintf(Stdout, i18n.G("phase: %s\n"), status.Phase)
+	if len(status.Snaps) > 0 {
+		w := tabWriter()
+		fmt.Fprintln(w, i18n.G("Snap\tStatus\tProgress\tNotes"))
+		for _, sn := range status.Snaps {
+			notes := "-"
+			switch {
+			case sn.Error != "":
+				notes = sn.Error
+			case sn.Essential:
+				notes = i18n.G("essential")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\n", sn.Name, sn.Status, sn.Done, sn.Total, notes)
+		}
+		w.Flush()
+	}
+
+	if x.Wait && !status.Seeded {
+		return fmt.Errorf(i18n.G("cannot seed the system: %s"), status.Error)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSeeding(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/seeding")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"seeded": false, "phase": "install", "change": "1", "snaps": [
+{"name": "core", "essential": true, "status": "Done", "done": 8, "total": 8},
+{"name": "foo", "status": "Doing", "done": 2, "total": 8}
+]}}`)
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "seeding"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `seeded: no
+phase: install
+Snap  Status  Progress  Notes
+core  Done    8/8       essential
+foo   Doing   2/8       -
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestSeedingWait(c *check.C) {
+	restore := snap.MockPollTime(time.Millisecond)
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/seeding")
+		switch n {
+		case 0, 1:
+			fmt.Fprintln(w, `{"type": "sync", "result": {"seeded": false, "phase": "mark-seeded", "change": "1"}}`)
+		case 2:
+			fmt.Fprintln(w, `{"type": "sync", "result": {"seeded": true, "phase": "done", "change": "1"}}`)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+	_, err := snap.Parser().ParseArgs([]string{"debug", "seeding", "--wait"})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 3)
+	c.Check(s.Stdout(), check.Equals, "seeded: yes\nphase: done\n")
+}
+
+func (s *SnapSuite) TestSeedingWaitError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"seeded": false, "phase": "error", "change": "1", "error": "cannot perform the following tasks:\n- Mount snap \"foo\" (cannot mount)", "snaps": [
+{"name": "foo", "status": "Error", "done": 8, "total": 8, "error": "cannot mount"}
+]}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"debug", "seeding", "--wait"})
+	c.Assert(err, check.ErrorMatches, `(?s)cannot seed the system: cannot perform the following tasks:.*`)
+	c.Check(s.Stdout(), check.Equals, `seeded: no
+phase: error
+Snap  Status  Progress  Notes
+foo   Error   8/8       cannot mount
+`)
+}
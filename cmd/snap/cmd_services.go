@@ -57,7 +57,9 @@ func init() {
 
 	addCommand("start", shortStartHelp, "", func() flags.Commander { return &svcStart{} }, nil, nil)
 	addCommand("stop", shortStopHelp, "", func() flags.Commander { return &svcStop{} }, nil, nil)
-	addCommand("restart", shortRestartHelp, "", func() flags.Commander { return &svcRestart{} }, nil, nil)
+	addCommand("restart", shortRestartHelp, "", func() flags.Commander { return &svcRestart{} }, waitDescs.also(map[string]string{
+		"reload": i18n.G("If the service has a reload command, use it instead of restarting"),
+	}), nil)
 }
 
 func svcNames(s []serviceName) []string {
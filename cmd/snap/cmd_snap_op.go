@@ -36,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/strutil"
 )
 
 func lastLogStr(logs []string) string {
@@ -71,6 +72,8 @@ func (wmx *waitMixin) wait(cli *client.Client, id string) (*client.Change, error
 }
 
 func wait(cli *client.Client, id string) (*client.Change, error) {
+	invalidateCompletionCache()
+
 	pb := progress.NewTextProgress()
 	defer func() {
 		pb.Finished()
@@ -158,6 +161,13 @@ var (
 
 var longInstallHelp = i18n.G(`
 The install command installs the named snap in the system.
+
+With --foreign-arch, a snap file built for another architecture can be
+installed, for instance to test an armhf snap on an amd64 machine. Its
+binaries are then run by the emulator qemu-user registers with the kernel's
+binfmt_misc, which needs to be registered with the fix-binary (F) flag, as
+the qemu-user-static package does. 'snap list' notes such snaps as
+foreign-arch.
 `)
 
 var longRemoveHelp = i18n.G(`
@@ -166,6 +176,12 @@ The remove command removes the named snap from the system.
 By default all the snap revisions are removed, including their data and the common
 data directory. When a --revision option is passed only the specified revision is
 removed.
+
+The remove command refuses to remove a snap other installed snaps depend on:
+those using it as their base or as the default provider of their content, and
+those with plugs connected to its slots. The --dry-run option lists them
+without removing anything, and the --force-breaks option removes the snap
+anyway.
 `)
 
 var longRefreshHelp = i18n.G(`
@@ -196,8 +212,10 @@ and the snap can easily be enabled again.
 type cmdRemove struct {
 	waitMixin
 
-	Revision   string `long:"revision"`
-	Positional struct {
+	Revision    string `long:"revision"`
+	ForceBreaks bool   `long:"force-breaks"`
+	DryRun      bool   `long:"dry-run"`
+	Positional  struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
 }
@@ -236,6 +254,9 @@ func (x *cmdRemove) removeMany(opts *client.SnapOptions) error {
 
 	cli := Client()
 	changeID, err := cli.RemoveMany(names, opts)
+	if e, ok := err.(*client.Error); ok && e.Kind == client.ErrorKindSnapHasDependents {
+		_, err = errorToCmdMessage("", err, opts)
+	}
 	if err != nil {
 		return err
 	}
@@ -270,8 +291,43 @@ func (x *cmdRemove) removeMany(opts *client.SnapOptions) error {
 
 }
 
+// showRemovalImpact lists what removing the named snap would break.
+func showRemovalImpact(name string) error {
+	impact, err := Client().RemovalImpact(name)
+	if err != nil {
+		return err
+	}
+	if len(impact.BaseOf)+len(impact.DefaultProviderOf)+len(impact.Connections) == 0 {
+		fmt.Fprintf(Stdout, i18n.G("Removing %q would not break any installed snap.\n"), name)
+		return nil
+	}
+	fmt.Fprintf(Stdout, i18n.G("Removing %q would break:\n"), name)
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Snap\tDependency"))
+	for _, dependent := range impact.BaseOf {
+		fmt.Fprintf(w, "%s\t%s\n", dependent, i18n.G("base"))
+	}
+	for _, dependent := range impact.DefaultProviderOf {
+		fmt.Fprintf(w, "%s\t%s\n", dependent, i18n.G("default content provider"))
+	}
+	for _, conn := range impact.Connections {
+		// TRANSLATORS: the first two %s are a plug and a slot as <snap>:<name>, the last one an interface
+		dep := fmt.Sprintf(i18n.G("connection %s to %s (%s)"), conn.Plug.Snap+":"+conn.Plug.Name, conn.Slot.Snap+":"+conn.Slot.Name, conn.Interface)
+		fmt.Fprintf(w, "%s\t%s\n", conn.Plug.Snap, dep)
+	}
+	return nil
+}
+
 func (x *cmdRemove) Execute([]string) error {
-	opts := &client.SnapOptions{Revision: x.Revision}
+	if x.DryRun {
+		if len(x.Positional.Snaps) != 1 || x.Revision != "" {
+			return errors.New(i18n.G("a single snap name, and no revision, is needed for --dry-run"))
+		}
+		return showRemovalImpact(string(x.Positional.Snaps[0]))
+	}
+
+	opts := &client.SnapOptions{Revision: x.Revision, ForceBreaks: x.ForceBreaks}
 	if len(x.Positional.Snaps) == 1 {
 		return x.removeOne(opts)
 	}
@@ -279,11 +335,14 @@ func (x *cmdRemove) Execute([]string) error {
 	if x.Revision != "" {
 		return errors.New(i18n.G("a single snap name is needed to specify the revision"))
 	}
+	if x.ForceBreaks {
+		return x.removeMany(&client.SnapOptions{ForceBreaks: true})
+	}
 	return x.removeMany(nil)
 }
 
 type channelMixin struct {
-	Channel string `long:"channel"`
+	Channel channelName `long:"channel"`
 
 	// shortcuts
 	EdgeChannel      bool `long:"edge"`
@@ -329,10 +388,10 @@ func (mx *channelMixin) setChannelFromCommandline() error {
 		if mx.Channel != "" {
 			return fmt.Errorf("Please specify a single channel")
 		}
-		mx.Channel = ch.chName
+		mx.Channel = channelName(ch.chName)
 	}
 
-	if !strings.Contains(mx.Channel, "/") && mx.Channel != "" && mx.Channel != "edge" && mx.Channel != "beta" && mx.Channel != "candidate" && mx.Channel != "stable" {
+	if !strings.Contains(string(mx.Channel), "/") && mx.Channel != "" && mx.Channel != "edge" && mx.Channel != "beta" && mx.Channel != "candidate" && mx.Channel != "stable" {
 		// shortcut to jump to a different track, e.g.
 		// snap install foo --channel=3.4 # implies 3.4/stable
 		mx.Channel += "/stable"
@@ -415,6 +474,8 @@ type cmdInstall struct {
 	channelMixin
 	modeMixin
 	Revision string `long:"revision"`
+	Source   string `long:"source"`
+	Store    string `long:"store"`
 
 	Dangerous bool `long:"dangerous"`
 	// alias for --dangerous, deprecated but we need to support it
@@ -423,6 +484,8 @@ type cmdInstall struct {
 
 	Unaliased bool `long:"unaliased"`
 
+	ForeignArch bool `long:"foreign-arch"`
+
 	Positional struct {
 		Snaps []remoteSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes" required:"yes"`
@@ -547,10 +610,13 @@ func (x *cmdInstall) Execute([]string) error {
 
 	dangerous := x.Dangerous || x.ForceDangerous
 	opts := &client.SnapOptions{
-		Channel:   x.Channel,
-		Revision:  x.Revision,
-		Dangerous: dangerous,
-		Unaliased: x.Unaliased,
+		Channel:     string(x.Channel),
+		Revision:    x.Revision,
+		Source:      x.Source,
+		Store:       x.Store,
+		Dangerous:   dangerous,
+		Unaliased:   x.Unaliased,
+		ForeignArch: x.ForeignArch,
 	}
 	x.setModes(opts)
 
@@ -566,6 +632,15 @@ func (x *cmdInstall) Execute([]string) error {
 	if x.asksForMode() || x.asksForChannel() {
 		return errors.New(i18n.G("a single snap name is needed to specify mode or channel flags"))
 	}
+	if x.Source != "" {
+		return errors.New(i18n.G("a single snap name is needed to specify a source"))
+	}
+	if x.Store != "" {
+		return errors.New(i18n.G("a single snap name is needed to specify a store"))
+	}
+	if x.ForeignArch {
+		return errors.New(i18n.G("a single snap file is needed to specify the foreign-arch flag"))
+	}
 
 	return x.installMany(names, nil)
 }
@@ -578,8 +653,11 @@ type cmdRefresh struct {
 
 	Revision         string `long:"revision"`
 	List             bool   `long:"list"`
+	Pending          bool   `long:"pending"`
+	Verbose          bool   `long:"verbose"`
 	Time             bool   `long:"time"`
 	IgnoreValidation bool   `long:"ignore-validation"`
+	BranchExpiry     string `long:"branch-expiry" choice:"notify" choice:"fall-back" choice:"stay"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -650,7 +728,10 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	} else {
 		fmt.Fprintf(Stdout, "last: n/a\n")
 	}
-	if sysinfo.Refresh.Next != "" {
+	if sysinfo.Refresh.Schedule == "managed" {
+		// auto-refreshes are disabled, an external agent does them
+		fmt.Fprintf(Stdout, "next: n/a (managed)\n")
+	} else if sysinfo.Refresh.Next != "" {
 		fmt.Fprintf(Stdout, "next: %s\n", sysinfo.Refresh.Next)
 	} else {
 		fmt.Fprintf(Stdout, "next: n/a\n")
@@ -676,9 +757,82 @@ func (x *cmdRefresh) listRefresh() error {
 	w := tabWriter()
 	defer w.Flush()
 
+	offDefaultTrack := false
 	fmt.Fprintln(w, i18n.G("Name\tVersion\tRev\tDeveloper\tNotes"))
 	for _, snap := range snaps {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", snap.Name, snap.Version, snap.Revision, snap.Developer, NotesFromRemote(snap, nil))
+		notes := NotesFromRemote(snap, nil)
+		// for refreshes the store reports the channel being tracked
+		if snap.DefaultTrack != "" && channelTrack(snap.Channel) != snap.DefaultTrack {
+			notes.OffDefaultTrack = true
+			offDefaultTrack = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", snap.Name, snap.Version, snap.Revision, snap.Developer, notes)
+	}
+
+	if offDefaultTrack {
+		w.Flush()
+		fmt.Fprintln(Stderr, i18n.G(`
+Some snaps are not following the default track chosen by their publisher
+(see "off-default-track" above); use 'snap switch --to-default-track <snap>'
+to move them to it.`))
+	}
+
+	return nil
+}
+
+func (x *cmdRefresh) listPending() error {
+	cli := Client()
+	cands, err := cli.RefreshCandidates()
+	if err != nil {
+		return err
+	}
+	if len(cands) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	if x.Verbose {
+		for i, cand := range cands {
+			if i > 0 {
+				fmt.Fprintln(w, "---")
+			}
+			fmt.Fprintf(w, "name:\t%s\n", cand.Name)
+			fmt.Fprintf(w, "current:\t%s\n", cand.CurrentRevision)
+			fmt.Fprintf(w, "candidate:\t%s (%s)\n", cand.Version, cand.Revision)
+			fmt.Fprintf(w, "channel:\t%s\n", cand.Channel)
+			if cand.DownloadSize > 0 {
+				fmt.Fprintf(w, "size:\t%s\n", strutil.SizeToStr(cand.DownloadSize))
+			}
+			if len(cand.Held) == 0 {
+				fmt.Fprintln(w, "held:\t-")
+				continue
+			}
+			fmt.Fprintln(w, "held:")
+			for _, hold := range cand.Held {
+				fmt.Fprintf(w, "  - %s: %s\n", hold.Reason, hold.Message)
+			}
+		}
+		return nil
+	}
+
+	fmt.Fprintln(w, i18n.G("Name\tCurrent\tRev\tVersion\tChannel\tSize\tHeld"))
+	for _, cand := range cands {
+		size := "-"
+		if cand.DownloadSize > 0 {
+			size = strutil.SizeToStr(cand.DownloadSize)
+		}
+		held := "-"
+		if len(cand.Held) > 0 {
+			reasons := make([]string, len(cand.Held))
+			for i, hold := range cand.Held {
+				reasons[i] = hold.Reason
+			}
+			held = strings.Join(reasons, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", cand.Name, cand.CurrentRevision, cand.Revision, cand.Version, cand.Channel, size, held)
 	}
 
 	return nil
@@ -708,6 +862,17 @@ func (x *cmdRefresh) Execute([]string) error {
 		return x.listRefresh()
 	}
 
+	if x.Pending {
+		if x.asksForMode() || x.asksForChannel() {
+			return errors.New(i18n.G("--pending does not take mode nor channel flags"))
+		}
+
+		return x.listPending()
+	}
+	if x.Verbose {
+		return errors.New(i18n.G("--verbose can only be used with --pending"))
+	}
+
 	if len(x.Positional.Snaps) == 0 && os.Getenv("SNAP_REFRESH_FROM_TIMER") == "1" {
 		fmt.Fprintf(Stdout, "Ignoring `snap refresh` from the systemd timer")
 		return nil
@@ -719,9 +884,10 @@ func (x *cmdRefresh) Execute([]string) error {
 	}
 	if len(x.Positional.Snaps) == 1 {
 		opts := &client.SnapOptions{
-			Channel:          x.Channel,
+			Channel:          string(x.Channel),
 			IgnoreValidation: x.IgnoreValidation,
 			Revision:         x.Revision,
+			BranchExpiry:     x.BranchExpiry,
 		}
 		x.setModes(opts)
 		return x.refreshOne(names[0], opts)
@@ -735,6 +901,10 @@ func (x *cmdRefresh) Execute([]string) error {
 		return errors.New(i18n.G("a single snap name must be specified when ignoring validation"))
 	}
 
+	if x.BranchExpiry != "" {
+		return errors.New(i18n.G("a single snap name is needed to specify a branch expiry policy"))
+	}
+
 	return x.refreshMany(names, nil)
 }
 
@@ -893,6 +1063,7 @@ type cmdRevert struct {
 
 	modeMixin
 	Revision   string `long:"revision"`
+	WithConfig bool   `long:"with-config"`
 	Positional struct {
 		Snap installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes" required:"yes"`
@@ -906,6 +1077,10 @@ and will use the original data that was associated with that revision,
 discarding any data changes that were done by the latest revision. As
 an exception, data which the snap explicitly chooses to share across
 revisions is not touched by the revert process.
+
+The configuration of the snap is kept as it is, unless --with-config is
+given, in which case the configuration the snap had when it was last
+using the previous revision is restored as well.
 `)
 
 func (x *cmdRevert) Execute(args []string) error {
@@ -919,7 +1094,7 @@ func (x *cmdRevert) Execute(args []string) error {
 
 	cli := Client()
 	name := string(x.Positional.Snap)
-	opts := &client.SnapOptions{Revision: x.Revision}
+	opts := &client.SnapOptions{Revision: x.Revision, WithConfig: x.WithConfig}
 	x.setModes(opts)
 	changeID, err := cli.Revert(name, opts)
 	if err != nil {
@@ -952,27 +1127,82 @@ var shortSwitchHelp = i18n.G("Switches snap to a different channel")
 var longSwitchHelp = i18n.G(`
 The switch command switches the given snap to a different channel without
 doing a refresh.
+
+With --to-default-track the snap is switched to the same risk level (and
+branch) on the track its publisher currently marks as the default one.
 `)
 
 type cmdSwitch struct {
 	channelMixin
 
+	ToDefaultTrack bool `long:"to-default-track"`
+
 	Positional struct {
 		Snap installedSnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
 }
 
+// channelTrack returns the track of the given channel, "latest" if
+// the channel does not name one explicitly.
+func channelTrack(channel string) string {
+	track := strings.SplitN(channel, "/", 2)[0]
+	if track == "" || strutil.ListContains(channelRisks, track) {
+		return "latest"
+	}
+	return track
+}
+
+// channelOnTrack returns the given channel moved to the given track,
+// keeping its risk level and branch.
+func channelOnTrack(channel, track string) string {
+	rest := channel
+	if parts := strings.SplitN(channel, "/", 2); !strutil.ListContains(channelRisks, parts[0]) {
+		rest = "stable"
+		if len(parts) == 2 {
+			rest = parts[1]
+		}
+	}
+	return track + "/" + rest
+}
+
+// defaultTrackChannel works out the channel the given installed snap
+// should switch to in order to follow its publisher's default track.
+func defaultTrackChannel(cli *client.Client, name string) (string, error) {
+	local, _, err := cli.Snap(name)
+	if err != nil {
+		return "", err
+	}
+	remote, _, err := cli.FindOne(name)
+	if err != nil {
+		return "", err
+	}
+	if remote.DefaultTrack == "" {
+		return "", fmt.Errorf(i18n.G("snap %q has no default track set by its publisher"), name)
+	}
+	return channelOnTrack(local.TrackingChannel, remote.DefaultTrack), nil
+}
+
 func (x cmdSwitch) Execute(args []string) error {
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
 	}
-	if x.Channel == "" {
+	if x.ToDefaultTrack && x.Channel != "" {
+		return fmt.Errorf(i18n.G("cannot use --to-default-track together with a channel"))
+	}
+	if x.Channel == "" && !x.ToDefaultTrack {
 		return fmt.Errorf("missing --channel=<channel-name> parameter")
 	}
 
 	cli := Client()
 	name := string(x.Positional.Snap)
 	channel := string(x.Channel)
+	if x.ToDefaultTrack {
+		var err error
+		channel, err = defaultTrackChannel(cli, name)
+		if err != nil {
+			return err
+		}
+	}
 	opts := &client.SnapOptions{
 		Channel: channel,
 	}
@@ -991,27 +1221,41 @@ func (x cmdSwitch) Execute(args []string) error {
 
 func init() {
 	addCommand("remove", shortRemoveHelp, longRemoveHelp, func() flags.Commander { return &cmdRemove{} },
-		waitDescs.also(map[string]string{"revision": i18n.G("Remove only the given revision")}), nil)
+		waitDescs.also(map[string]string{
+			"revision":     i18n.G("Remove only the given revision"),
+			"force-breaks": i18n.G("Remove the snap even if installed snaps depend on it"),
+			"dry-run":      i18n.G("List what removing the snap would break, without removing it"),
+		}), nil)
 	addCommand("install", shortInstallHelp, longInstallHelp, func() flags.Commander { return &cmdInstall{} },
 		waitDescs.also(channelDescs).also(modeDescs).also(map[string]string{
 			"revision":        i18n.G("Install the given revision of a snap, to which you must have developer access"),
 			"dangerous":       i18n.G("Install the given snap file even if there are no pre-acknowledged signatures for it, meaning it was not verified and could be dangerous (--devmode implies this)"),
 			"force-dangerous": i18n.G("Alias for --dangerous (DEPRECATED)"),
 			"unaliased":       i18n.G("Install the given snap without enabling its automatic aliases"),
+			"foreign-arch":    i18n.G("Install the given snap file even if it is built for another architecture, to run under emulation (needs qemu-user registered with binfmt_misc)"),
+			"source":          i18n.G("Install the given snap from an OCI registry (oci://<registry>/<repository>[:<tag>|@<digest>]) instead of the store"),
+			"store":           i18n.G("Install the given snap from the store with this id instead of the device one, and keep refreshing it from there"),
 		}), nil)
 	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() flags.Commander { return &cmdRefresh{} },
 		waitDescs.also(channelDescs).also(modeDescs).also(map[string]string{
 			"revision":          i18n.G("Refresh to the given revision"),
 			"list":              i18n.G("Show available snaps for refresh but do not perform a refresh"),
+			"pending":           i18n.G("Show available refreshes and what holds them back, but do not perform a refresh"),
+			"verbose":           i18n.G("Show details for each pending refresh (with --pending)"),
 			"time":              i18n.G("Show auto refresh information but do not perform a refresh"),
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the refresh"),
+			"branch-expiry":     i18n.G("What to do when the tracked branch is closed: keep tracking it and refresh from where the store falls back (notify, the default), track where the store falls back (fall-back), or do not refresh (stay)"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
 	addCommand("disable", shortDisableHelp, longDisableHelp, func() flags.Commander { return &cmdDisable{} }, waitDescs, nil)
 	addCommand("revert", shortRevertHelp, longRevertHelp, func() flags.Commander { return &cmdRevert{} }, waitDescs.also(modeDescs).also(map[string]string{
-		"revision": "Revert to the given revision",
+		"revision":    "Revert to the given revision",
+		"with-config": i18n.G("Also restore the configuration the snap had with that revision"),
+	}), nil)
+	addCommand("switch", shortSwitchHelp, longSwitchHelp, func() flags.Commander { return &cmdSwitch{} }, channelDescs.also(map[string]string{
+		// TRANSLATORS: This should probably not start with a lowercase letter.
+		"to-default-track": i18n.G("Switch to the track the publisher set as default"),
 	}), nil)
-	addCommand("switch", shortSwitchHelp, longSwitchHelp, func() flags.Commander { return &cmdSwitch{} }, nil, nil)
 
 }
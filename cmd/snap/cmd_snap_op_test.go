@@ -31,6 +31,7 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/jessevdk/go-flags"
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
@@ -287,6 +288,44 @@ func (s *SnapOpSuite) TestInstallUnaliased(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestInstallSource(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "install",
+			"source": "oci://registry.example.com/acme/foo:1.0",
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"install", "--source", "oci://registry.example.com/acme/foo:1.0", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from 'bar' installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallStore(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "install",
+			"store":  "other-store",
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"install", "--store", "other-store", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from 'bar' installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
 func testForm(r *http.Request, c *check.C) *multipart.Form {
 	contentType := r.Header.Get("Content-Type")
 	mediaType, params, err := mime.ParseMediaType(contentType)
@@ -381,6 +420,38 @@ func (s *SnapOpSuite) TestInstallPathDevMode(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestInstallPathForeignArch(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		form := testForm(r, c)
+		defer form.RemoveAll()
+
+		c.Check(form.Value["action"], check.DeepEquals, []string{"install"})
+		c.Check(form.Value["foreign-arch"], check.DeepEquals, []string{"true"})
+		c.Check(form.Value["snap-path"], check.NotNil)
+		c.Check(form.Value, check.HasLen, 3)
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	snapPath := filepath.Join(c.MkDir(), "foo.snap")
+	err := ioutil.WriteFile(snapPath, []byte("snap-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	rest, err := snap.Parser().ParseArgs([]string{"install", "--foreign-arch", snapPath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 1.0 from 'bar' installed`)
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestInstallForeignArchFromStore(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"install", "--foreign-arch", "foo"})
+	c.Assert(err, check.ErrorMatches, "foreign-arch option only meaningful when installing from a local file")
+
+	_, err = snap.Parser().ParseArgs([]string{"install", "--foreign-arch", "foo", "bar"})
+	c.Assert(err, check.ErrorMatches, "a single snap file is needed to specify the foreign-arch flag")
+}
+
 func (s *SnapOpSuite) TestInstallPathClassic(c *check.C) {
 	s.srv.checker = func(r *http.Request) {
 		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
@@ -506,6 +577,26 @@ func (s *SnapOpSuite) TestRevertClassic(c *check.C) {
 	s.runRevertTest(c, &client.SnapOptions{Classic: true})
 }
 
+func (s *SnapOpSuite) TestRevertWithConfig(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":      "revert",
+			"with-config": true,
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+
+	rest, err := snap.Parser().ParseArgs([]string{"revert", "--with-config", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "foo reverted to 1.0\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
 func (s *SnapOpSuite) TestRevertMissingName(c *check.C) {
 	_, err := snap.Parser().ParseArgs([]string{"revert"})
 	c.Assert(err, check.NotNil)
@@ -538,6 +629,109 @@ foo +4.2update1 +17 +bar +-.*
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshListOffDefaultTrack(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "refresh")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+{"name": "bar", "status": "active", "version": "2.1", "developer": "baz", "revision":3, "channel": "2.x/stable", "default-track": "2.x"},
+{"name": "foo", "status": "active", "version": "4.2update1", "developer": "bar", "revision":17, "channel": "stable", "default-track": "4.x"}
+]}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--list"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `Name +Version +Rev +Developer +Notes
+bar +2.1 +3 +baz +-
+foo +4.2update1 +17 +bar +off-default-track
+`)
+	c.Check(s.Stderr(), check.Matches, `(?s).*'snap switch --to-default-track <snap>'.*`)
+	c.Check(n, check.Equals, 1)
+}
+
+const pendingRefreshesJSON = `{"type": "sync", "result": [
+	{"name": "foo", "current-revision": "7", "revision": "11", "version": "1.1", "channel": "stable", "download-size": 4096000,
+	 "held": [{"reason": "devmode", "message": "snaps in devmode are not refreshed automatically"}, {"reason": "validation", "message": "no validation by \"bar\""}]},
+	{"name": "quux", "current-revision": "1", "revision": "2", "version": "2.0", "channel": "edge"}
+]}`
+
+func (s *SnapSuite) TestRefreshPending(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "refresh-candidates")
+			fmt.Fprintln(w, pendingRefreshesJSON)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--pending"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `Name +Current +Rev +Version +Channel +Size +Held
+foo +7 +11 +1.1 +stable +4MB +devmode,validation
+quux +1 +2 +2.0 +edge +- +-
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRefreshPendingVerbose(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, pendingRefreshesJSON)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--pending", "--verbose"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `name:       foo
+current:    7
+candidate:  1.1 (11)
+channel:    stable
+size:       4MB
+held:
+  - devmode: snaps in devmode are not refreshed automatically
+  - validation: no validation by "bar"
+---
+name:       quux
+current:    1
+candidate:  2.0 (2)
+channel:    edge
+held:       -
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestRefreshPendingNothing(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--pending"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+}
+
+func (s *SnapSuite) TestRefreshPendingErrors(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--pending", "--beta"})
+	c.Check(err, check.ErrorMatches, "--pending does not take .* flags")
+	_, err = snap.Parser().ParseArgs([]string{"refresh", "--verbose"})
+	c.Check(err, check.ErrorMatches, "--verbose can only be used with --pending")
+}
+
 func (s *SnapSuite) TestRefreshTime(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -564,6 +758,22 @@ next: 2017-04-26T00:58:00+0200
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshTimeManaged(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"schedule": "managed", "last": "2017-04-25T17:35:00+0200"}}}`)
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `schedule: managed
+last: 2017-04-25T17:35:00+0200
+next: n/a (managed)
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
 func (s *SnapSuite) TestRefreshListErr(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser().ParseArgs([]string{"refresh", "--list", "--beta"})
@@ -657,6 +867,20 @@ func (s *SnapOpSuite) TestRefreshOneIgnoreValidation(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *SnapOpSuite) TestRefreshOneBranchExpiry(c *check.C) {
+	s.RedirectClientToTestServer(s.srv.handle)
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/one")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":        "refresh",
+			"branch-expiry": "stay",
+		})
+	}
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--branch-expiry=stay", "one"})
+	c.Assert(err, check.IsNil)
+}
+
 func (s *SnapOpSuite) TestRefreshOneModeErr(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser().ParseArgs([]string{"refresh", "--jailmode", "--devmode", "one"})
@@ -687,6 +911,12 @@ func (s *SnapOpSuite) TestRefreshManyIgnoreValidation(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `a single snap name must be specified when ignoring validation`)
 }
 
+func (s *SnapOpSuite) TestRefreshManyBranchExpiry(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--branch-expiry=stay", "one", "two"})
+	c.Assert(err, check.ErrorMatches, `a single snap name is needed to specify a branch expiry policy`)
+}
+
 func (s *SnapOpSuite) TestRefreshAllModeFlags(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser().ParseArgs([]string{"refresh", "--devmode"})
@@ -922,12 +1152,115 @@ func (s *SnapOpSuite) TestRemoveMany(c *check.C) {
 	c.Check(n, check.Equals, total)
 }
 
+func (s *SnapOpSuite) TestRemoveDependents(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/producer")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "remove",
+		})
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "snap \"producer\" is used by \"consumer\"", "kind": "snap-has-dependents"}, "status-code": 400}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"remove", "producer"})
+	c.Assert(err, check.NotNil)
+	c.Check(err.Error(), check.Equals, `snap "producer" is used by "consumer" (see what would break with "snap
+       remove --dry-run", or use --force-breaks to remove it anyway)`)
+}
+
+func (s *SnapOpSuite) TestRemoveForceBreaks(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps/producer":
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":       "remove",
+				"force-breaks": true,
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case "/v2/snaps":
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":       "remove",
+				"snaps":        []interface{}{"producer", "other"},
+				"force-breaks": true,
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		default:
+			c.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"remove", "--no-wait", "--force-breaks", "producer"})
+	c.Assert(err, check.IsNil)
+	_, err = snap.Parser().ParseArgs([]string{"remove", "--no-wait", "--force-breaks", "producer", "other"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapOpSuite) TestRemoveDryRun(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		switch r.URL.Path {
+		case "/v2/snaps/producer":
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":  "remove",
+				"dry-run": true,
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+				"snap": "producer",
+				"base-of": ["app-one"],
+				"default-provider-of": ["app-one"],
+				"content-consumers": ["app-one"],
+				"connections": [
+					{"plug": {"snap": "app-one", "plug": "data"}, "slot": {"snap": "producer", "slot": "data"}, "interface": "content"},
+					{"plug": {"snap": "app-two", "plug": "tst"}, "slot": {"snap": "producer", "slot": "tst"}, "interface": "test"}
+				]}}`)
+		case "/v2/snaps/app-two":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"snap": "app-two"}}`)
+		default:
+			c.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"remove", "--dry-run", "producer"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Removing "producer" would break:
+Snap     Dependency
+app-one  base
+app-one  default content provider
+app-one  connection app-one:data to producer:data (content)
+app-two  connection app-two:tst to producer:tst (test)
+`)
+
+	s.ResetStdStreams()
+	_, err = snap.Parser().ParseArgs([]string{"remove", "--dry-run", "app-two"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Removing \"app-two\" would not break any installed snap.\n")
+
+	_, err = snap.Parser().ParseArgs([]string{"remove", "--dry-run", "producer", "app-two"})
+	c.Check(err, check.ErrorMatches, "a single snap name, and no revision, is needed for --dry-run")
+	_, err = snap.Parser().ParseArgs([]string{"remove", "--dry-run", "--revision=2", "producer"})
+	c.Check(err, check.ErrorMatches, "a single snap name, and no revision, is needed for --dry-run")
+}
+
 func (s *SnapOpSuite) TestInstallManyChannel(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser().ParseArgs([]string{"install", "--beta", "one", "two"})
 	c.Assert(err, check.ErrorMatches, `a single snap name is needed to specify mode or channel flags`)
 }
 
+func (s *SnapOpSuite) TestInstallManySource(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"install", "--source", "oci://registry.example.com/acme/foo", "one", "two"})
+	c.Assert(err, check.ErrorMatches, `a single snap name is needed to specify a source`)
+}
+
+func (s *SnapOpSuite) TestInstallManyStore(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"install", "--store", "other-store", "one", "two"})
+	c.Assert(err, check.ErrorMatches, `a single snap name is needed to specify a store`)
+}
+
 func (s *SnapOpSuite) TestInstallManyMixFileAndStore(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser().ParseArgs([]string{"install", "store-snap", "./local.snap"})
@@ -1030,6 +1363,84 @@ func (s *SnapOpSuite) TestSwitchHappy(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestSwitchToDefaultTrack(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"name": "foo", "status": "active", "tracking-channel": "1.x/beta/fix"}}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			c.Check(r.URL.Query().Get("name"), check.Equals, "foo")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "available", "default-track": "2.x"}]}`)
+		case 2:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":  "switch",
+				"channel": "2.x/beta/fix",
+			})
+			fmt.Fprintln(w, `{"type": "async", "change": "42", "status-code": 202}`)
+		case 3:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 4 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"switch", "--to-default-track", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*"foo" switched to the "2.x/beta/fix" channel`)
+	c.Check(n, check.Equals, 4)
+}
+
+func (s *SnapOpSuite) TestSwitchToDefaultTrackUnset(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			fmt.Fprintln(w, `{"type": "sync", "result": {"name": "foo", "status": "active", "tracking-channel": "stable"}}`)
+		case 1:
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "available"}]}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"switch", "--to-default-track", "foo"})
+	c.Assert(err, check.ErrorMatches, `snap "foo" has no default track set by its publisher`)
+}
+
+func (s *SnapOpSuite) TestSwitchToDefaultTrackWithChannel(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"switch", "--to-default-track", "--beta", "foo"})
+	c.Assert(err, check.ErrorMatches, `cannot use --to-default-track together with a channel`)
+}
+
+func (s *SnapOpSuite) TestChannelOnTrack(c *check.C) {
+	for _, t := range []struct {
+		channel, track, expected string
+	}{
+		{"", "2.x", "2.x/stable"},
+		{"stable", "2.x", "2.x/stable"},
+		{"edge/fix", "2.x", "2.x/edge/fix"},
+		{"latest/candidate", "2.x", "2.x/candidate"},
+		{"1.x", "2.x", "2.x/stable"},
+		{"1.x/beta/fix", "latest", "latest/beta/fix"},
+	} {
+		c.Check(snap.ChannelOnTrack(t.channel, t.track), check.Equals, t.expected, check.Commentf("%q", t.channel))
+	}
+	c.Check(snap.ChannelTrack("beta"), check.Equals, "latest")
+	c.Check(snap.ChannelTrack("2.x/beta"), check.Equals, "2.x")
+}
+
 func (s *SnapOpSuite) TestSwitchUnhappy(c *check.C) {
 	_, err := snap.Parser().ParseArgs([]string{"switch"})
 	c.Assert(err, check.ErrorMatches, "the required argument `<snap>` was not provided")
@@ -1039,3 +1450,84 @@ func (s *SnapOpSuite) TestSwitchAlsoUnhappy(c *check.C) {
 	_, err := snap.Parser().ParseArgs([]string{"switch", "foo"})
 	c.Assert(err, check.ErrorMatches, `missing --channel=<channel-name> parameter`)
 }
+
+func (s *SnapOpSuite) TestChannelCompletion(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		switch r.URL.Path {
+		case "/v2/find":
+			if r.URL.Query().Get("name") != "foo" {
+				w.WriteHeader(404)
+				fmt.Fprintln(w, `{"type": "error", "status-code": 404, "result": {"message": "not found", "kind": "snap-not-found"}}`)
+				return
+			}
+			fmt.Fprintln(w, `{"type": "sync", "result": [{
+				"name": "foo",
+				"tracks": ["latest", "2.0"],
+				"channels": {
+					"latest/stable": {"version": "1.0", "revision": "1"},
+					"latest/edge": {"version": "1.1", "revision": "3"},
+					"2.0/beta": {"version": "2.0", "revision": "2"}
+				}
+			}]}`)
+		case "/v2/snaps/bar":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"name": "bar", "tracking-channel": "beta"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	os.Setenv("GO_FLAGS_COMPLETION", "verbose")
+	defer os.Unsetenv("GO_FLAGS_COMPLETION")
+
+	var expected []flags.Completion
+	parser := snap.Parser()
+	parser.CompletionHandler = func(obtained []flags.Completion) {
+		c.Check(obtained, check.DeepEquals, expected)
+	}
+
+	for _, t := range []struct {
+		args     []string
+		expected []flags.Completion
+	}{{
+		// the channels of the snap in the store, closed ones with
+		// what they follow
+		args: []string{"install", "foo", "--channel="},
+		expected: []flags.Completion{
+			{Item: "--channel=2.0/beta", Description: "2.0 (2)"},
+			{Item: "--channel=2.0/edge", Description: "closed, follows 2.0/beta"},
+			{Item: "--channel=beta", Description: "closed, follows stable"},
+			{Item: "--channel=candidate", Description: "closed, follows stable"},
+			{Item: "--channel=edge", Description: "1.1 (3)"},
+			{Item: "--channel=stable", Description: "1.0 (1)"},
+		},
+	}, {
+		args: []string{"refresh", "foo", "--channel", "latest/"},
+		expected: []flags.Completion{
+			{Item: "latest/beta", Description: "closed, follows latest/stable"},
+			{Item: "latest/candidate", Description: "closed, follows latest/stable"},
+			{Item: "latest/edge", Description: "1.1 (3)"},
+			{Item: "latest/stable", Description: "1.0 (1)"},
+		},
+	}, {
+		// no snap given, any risk goes
+		args: []string{"refresh", "--channel", ""},
+		expected: []flags.Completion{
+			{Item: "beta"},
+			{Item: "candidate"},
+			{Item: "edge"},
+			{Item: "stable"},
+		},
+	}, {
+		// not in the store, the tracked channel is known locally
+		args: []string{"switch", "bar", "--channel", "b"},
+		expected: []flags.Completion{
+			{Item: "beta", Description: "tracked"},
+		},
+	}} {
+		expected = t.expected
+		restore := snap.MockCompletionArgs(t.args)
+		_, err := parser.ParseArgs(t.args)
+		restore()
+		c.Assert(err, check.IsNil, check.Commentf("%v", t.args))
+	}
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdStartupTimings struct{}
+
+func init() {
+	addDebugCommand("startup-timings",
+		"(internal) show how long the steps of starting snapd took",
+		"(internal) show how long the steps of starting snapd took",
+		func() flags.Commander {
+			return &cmdStartupTimings{}
+		})
+}
+
+type startupSpan struct {
+	Label    string        `json:"label"`
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+func fmtSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+func (x *cmdStartupTimings) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	var spans []startupSpan
+	if err := Client().Debug("startup-timings", nil, &spans); err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Step\tStart\tDuration"))
+	var total time.Duration
+	for _, span := range spans {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", span.Label, fmtSeconds(span.Start), fmtSeconds(span.Duration))
+		if end := span.Start + span.Duration; end > total {
+			total = end
+		}
+	}
+	fmt.Fprintf(w, "%s\t\t%s\n", i18n.G("total"), fmtSeconds(total))
+	return nil
+}
@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdTaskMetrics struct{}
+
+func init() {
+	addDebugCommand("task-metrics",
+		"(internal) show how long running the tasks of each kind took",
+		"(internal) show how long running the tasks of each kind took since snapd started, and how often it failed",
+		func() flags.Commander {
+			return &cmdTaskMetrics{}
+		})
+}
+
+func (x *cmdTaskMetrics) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	m, err := Client().Metrics()
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Task\tCount\tFailures\tp50\tp90\tp99\tMax"))
+	for _, t := range m.Tasks {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", t.Name, t.Count, t.Failures, fmtSeconds(t.P50), fmtSeconds(t.P90), fmtSeconds(t.P99), fmtSeconds(t.Max))
+	}
+	return nil
+}
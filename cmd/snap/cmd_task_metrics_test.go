@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestTaskMetrics(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/metrics")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"tasks": [
+{"name": "link-snap", "count": 2, "failures": 0, "total": 3000000, "p50": 1000000, "p90": 2000000, "p99": 2000000, "max": 2000000},
+{"name": "setup-profiles", "count": 2, "failures": 1, "total": 3500000000, "p50": 1500000000, "p90": 2000000000, "p99": 2000000000, "max": 2000000000}
+]}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"debug", "task-metrics"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `Task            Count  Failures  p50     p90     p99     Max
+link-snap       2      0         0.001s  0.002s  0.002s  0.002s
+setup-profiles  2      1         1.500s  2.000s  2.000s  2.000s
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
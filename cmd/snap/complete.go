@@ -17,7 +17,11 @@ import (
 type installedSnapName string
 
 func (s installedSnapName) Complete(match string) []flags.Completion {
-	snaps, err := Client().List(nil, nil)
+	var snaps []*client.Snap
+	err := cachedCompletionData("snaps", &snaps, func() (err error) {
+		snaps, err = Client().List(nil, nil)
+		return err
+	})
 	if err != nil {
 		return nil
 	}
@@ -110,7 +114,11 @@ func (s anySnapName) Complete(match string) []flags.Completion {
 type changeID string
 
 func (s changeID) Complete(match string) []flags.Completion {
-	changes, err := Client().Changes(&client.ChangesOptions{Selector: client.ChangesAll})
+	var changes []*client.Change
+	err := cachedCompletionData("changes", &changes, func() (err error) {
+		changes, err = Client().Changes(&client.ChangesOptions{Selector: client.ChangesAll})
+		return err
+	})
 	if err != nil {
 		return nil
 	}
@@ -252,17 +260,51 @@ func (spec *interfaceSpec) connFilter(numConns int) bool {
 	return false
 }
 
+// plugFilter is like connFilter, except that greedy plugs can always take
+// more connections.
+func (spec *interfaceSpec) plugFilter(plug *client.Plug, greedy map[string]bool) bool {
+	if spec.disconnected && greedy[plug.Interface] {
+		return true
+	}
+	return spec.connFilter(len(plug.Connections))
+}
+
+// greedyPlugInterfaces returns the interfaces whose plugs can be connected
+// to several slots, if snapd tells.
+func greedyPlugInterfaces() map[string]bool {
+	ifaces, err := completionInterfaces()
+	if err != nil {
+		return nil
+	}
+	greedy := make(map[string]bool)
+	for _, iface := range ifaces {
+		if iface.GreedyPlugs {
+			greedy[iface.Name] = true
+		}
+	}
+	return greedy
+}
+
 func (spec *interfaceSpec) Complete(match string) []flags.Completion {
 	// Parse what the user typed so far, it can be either
 	// nothing (""), a "snap", a "snap:" or a "snap:name".
 	parts := strings.SplitN(match, ":", 2)
 
 	// Ask snapd about available interfaces.
-	ifaces, err := Client().Connections()
+	var ifaces client.Connections
+	err := cachedCompletionData("connections", &ifaces, func() (err error) {
+		ifaces, err = Client().Connections()
+		return err
+	})
 	if err != nil {
 		return nil
 	}
 
+	var greedy map[string]bool
+	if spec.plugs && spec.disconnected {
+		greedy = greedyPlugInterfaces()
+	}
+
 	snaps := make(map[string]bool)
 
 	var ret []flags.Completion
@@ -279,8 +321,9 @@ func (spec *interfaceSpec) Complete(match string) []flags.Completion {
 		// like that.
 		snapPrefix := parts[0]
 		if spec.plugs {
-			for _, plug := range ifaces.Plugs {
-				if strings.HasPrefix(plug.Snap, snapPrefix) && spec.connFilter(len(plug.Connections)) {
+			for i := range ifaces.Plugs {
+				plug := &ifaces.Plugs[i]
+				if strings.HasPrefix(plug.Snap, snapPrefix) && spec.plugFilter(plug, greedy) {
 					snaps[plug.Snap] = true
 				}
 			}
@@ -301,11 +344,9 @@ func (spec *interfaceSpec) Complete(match string) []flags.Completion {
 				if spec.connected && snapName == "" {
 					actualName = "core"
 				}
-				for _, plug := range ifaces.Plugs {
-					if plug.Snap == actualName && strings.HasPrefix(plug.Name, prefix) && spec.connFilter(len(plug.Connections)) {
-						// TODO: in the future annotate plugs that can take
-						// multiple connection sensibly and don't skip those even
-						// if they have connections already.
+				for i := range ifaces.Plugs {
+					plug := &ifaces.Plugs[i]
+					if plug.Snap == actualName && strings.HasPrefix(plug.Name, prefix) && spec.plugFilter(plug, greedy) {
 						ret = append(ret, flags.Completion{Item: fmt.Sprintf("%s:%s", snapName, plug.Name), Description: "plug"})
 					}
 				}
@@ -325,8 +366,9 @@ func (spec *interfaceSpec) Complete(match string) []flags.Completion {
 	snaps:
 		for snapName := range snaps {
 			if spec.plugs {
-				for _, plug := range ifaces.Plugs {
-					if plug.Snap == snapName && spec.connFilter(len(plug.Connections)) {
+				for i := range ifaces.Plugs {
+					plug := &ifaces.Plugs[i]
+					if plug.Snap == snapName && spec.plugFilter(plug, greedy) {
 						ret = append(ret, flags.Completion{Item: fmt.Sprintf("%s:", snapName)})
 						continue snaps
 					}
@@ -346,10 +388,19 @@ func (spec *interfaceSpec) Complete(match string) []flags.Completion {
 	return ret
 }
 
+// completionInterfaces returns the interfaces known to snapd.
+func completionInterfaces() (ifaces []*client.Interface, err error) {
+	err = cachedCompletionData("interfaces", &ifaces, func() (err error) {
+		ifaces, err = Client().Interfaces(nil)
+		return err
+	})
+	return ifaces, err
+}
+
 type interfaceName string
 
 func (s interfaceName) Complete(match string) []flags.Completion {
-	ifaces, err := Client().Interfaces(nil)
+	ifaces, err := completionInterfaces()
 	if err != nil {
 		return nil
 	}
@@ -367,8 +418,11 @@ func (s interfaceName) Complete(match string) []flags.Completion {
 type appName string
 
 func (s appName) Complete(match string) []flags.Completion {
-	cli := Client()
-	apps, err := cli.Apps(nil, client.AppOptions{})
+	var apps []*client.AppInfo
+	err := cachedCompletionData("apps", &apps, func() (err error) {
+		apps, err = Client().Apps(nil, client.AppOptions{})
+		return err
+	})
 	if err != nil {
 		return nil
 	}
@@ -391,8 +445,11 @@ func (s appName) Complete(match string) []flags.Completion {
 type serviceName string
 
 func (s serviceName) Complete(match string) []flags.Completion {
-	cli := Client()
-	apps, err := cli.Apps(nil, client.AppOptions{Service: true})
+	var apps []*client.AppInfo
+	err := cachedCompletionData("services", &apps, func() (err error) {
+		apps, err = Client().Apps(nil, client.AppOptions{Service: true})
+		return err
+	})
 	if err != nil {
 		return nil
 	}
@@ -416,7 +473,11 @@ func (s serviceName) Complete(match string) []flags.Completion {
 type aliasOrSnap string
 
 func (s aliasOrSnap) Complete(match string) []flags.Completion {
-	aliases, err := Client().Aliases()
+	var aliases map[string]map[string]client.AliasStatus
+	err := cachedCompletionData("aliases", &aliases, func() (err error) {
+		aliases, err = Client().Aliases()
+		return err
+	})
 	if err != nil {
 		return nil
 	}
@@ -436,3 +497,97 @@ func (s aliasOrSnap) Complete(match string) []flags.Completion {
 	}
 	return ret
 }
+
+// channelRisks are the risk levels of the channels of a track, from the
+// most to the least stable.
+var channelRisks = []string{"stable", "candidate", "beta", "edge"}
+
+// completionArgs returns the command line being completed, without the
+// name of the program.
+var completionArgs = func() []string {
+	if len(os.Args) < 2 {
+		return nil
+	}
+	return os.Args[1:]
+}
+
+// completionSnapNames returns the snap names given so far on the command
+// line being completed, that is the arguments after the command that are
+// neither options, nor the values of options, nor the word being completed.
+func completionSnapNames() []string {
+	args := completionArgs()
+	if len(args) < 2 {
+		return nil
+	}
+	args = args[1 : len(args)-1]
+	var names []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--channel" || arg == "--revision":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			names = append(names, arg)
+		}
+	}
+	return names
+}
+
+type channelName string
+
+func (s channelName) Complete(match string) []flags.Completion {
+	cli := Client()
+
+	var tracking string
+	if names := completionSnapNames(); len(names) == 1 {
+		if remote, _, err := cli.FindOne(names[0]); err == nil {
+			return completeStoreChannels(remote, match)
+		}
+		// the store cannot be reached, use what is known locally
+		if local, _, err := cli.Snap(names[0]); err == nil {
+			tracking = local.TrackingChannel
+		}
+	}
+
+	var ret []flags.Completion
+	if tracking != "" && strings.HasPrefix(tracking, match) {
+		ret = append(ret, flags.Completion{Item: tracking, Description: "tracked"})
+	}
+	for _, risk := range channelRisks {
+		if risk != tracking && strings.HasPrefix(risk, match) {
+			ret = append(ret, flags.Completion{Item: risk})
+		}
+	}
+	return ret
+}
+
+// completeStoreChannels completes the channels of the snap as found in
+// the store. Closed channels are offered along with the channel they
+// fall back to, while closed channels without such a fallback are left
+// out.
+func completeStoreChannels(remote *client.Snap, match string) []flags.Completion {
+	var ret []flags.Completion
+	for _, track := range remote.Tracks {
+		var fallback string
+		for _, risk := range channelRisks {
+			chName := track + "/" + risk
+			item := chName
+			if track == "latest" && !strings.HasPrefix(match, "latest/") {
+				item = risk
+			}
+			var desc string
+			if ch, ok := remote.Channels[chName]; ok {
+				desc = fmt.Sprintf("%s (%s)", ch.Version, ch.Revision)
+				fallback = item
+			} else if fallback != "" {
+				desc = fmt.Sprintf("closed, follows %s", fallback)
+			} else {
+				continue
+			}
+			if strings.HasPrefix(item, match) {
+				ret = append(ret, flags.Completion{Item: item, Description: desc})
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// completionCacheTTL is for how long the data obtained from snapd for
+// completion is reused, so that pressing TAB repeatedly stays fast when
+// snapd is busy.
+var completionCacheTTL = 10 * time.Second
+
+// completionCacheDir returns the directory caching, for the current user,
+// the data obtained from snapd for completion.
+var completionCacheDir = func() (string, error) {
+	if cacheHome := osGetenv("XDG_CACHE_HOME"); filepath.IsAbs(cacheHome) {
+		return filepath.Join(cacheHome, "snap", "completion"), nil
+	}
+	usr, err := userCurrent()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".cache", "snap", "completion"), nil
+}
+
+// cachedCompletionData decodes into v the data cached under the given key
+// if it is recent enough, and otherwise calls fetch to obtain it from
+// snapd, caching what fetch put into v. Failing to use the cache is not
+// an error, the data is then simply obtained from snapd.
+func cachedCompletionData(key string, v interface{}, fetch func() error) error {
+	dir, err := completionCacheDir()
+	if err != nil {
+		return fetch()
+	}
+	fn := filepath.Join(dir, key+".json")
+	if st, err := os.Stat(fn); err == nil && time.Since(st.ModTime()) < completionCacheTTL {
+		if data, err := ioutil.ReadFile(fn); err == nil && json.Unmarshal(data, v) == nil {
+			return nil
+		}
+	}
+
+	if err := fetch(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil
+	}
+	osutil.AtomicWriteFile(fn, data, 0600, 0)
+	return nil
+}
+
+// invalidateCompletionCache drops the data cached for completion, as it
+// is outdated once a change is made.
+func invalidateCompletionCache() {
+	if dir, err := completionCacheDir(); err == nil {
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestCompletionCache(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps":
+			n++
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo"}, {"name": "bar"}]}`)
+		case "/v2/changes/42":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	cacheDir := filepath.Join(c.MkDir(), "completion")
+	defer snap.MockCompletionCacheDir(cacheDir)()
+	os.Setenv("GO_FLAGS_COMPLETION", "verbose")
+	defer os.Unsetenv("GO_FLAGS_COMPLETION")
+
+	parser := snap.Parser()
+	parser.CompletionHandler = func(obtained []flags.Completion) {
+		c.Check(obtained, DeepEquals, []flags.Completion{{Item: "foo"}})
+	}
+
+	// snapd is asked once, then the cached list is used
+	for i := 0; i < 3; i++ {
+		_, err := parser.ParseArgs([]string{"get", "f"})
+		c.Assert(err, IsNil)
+	}
+	c.Check(n, Equals, 1)
+	c.Check(osutil.FileExists(filepath.Join(cacheDir, "snaps.json")), Equals, true)
+
+	// waiting for a change drops the cache
+	_, err := snap.Wait(snap.Client(), "42")
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(cacheDir), Equals, false)
+	_, err = parser.ParseArgs([]string{"get", "f"})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+
+	// and so does time
+	defer snap.MockCompletionCacheTTL(0)()
+	_, err = parser.ParseArgs([]string{"get", "f"})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 3)
+}
@@ -158,6 +158,10 @@ If you understand and want to proceed repeat the command including --classic.
 		isError = false
 		usesSnapName = false
 		msg = err.Message
+	case client.ErrorKindSnapHasDependents:
+		usesSnapName = false
+		// TRANSLATORS: %s is an error message (e.g. “snap "foo" is used by "bar"”)
+		msg = fmt.Sprintf(i18n.G(`%s (see what would break with "snap remove --dry-run", or use --force-breaks to remove it anyway)`), err.Message)
 	default:
 		usesSnapName = false
 		msg = err.Message
@@ -32,15 +32,18 @@ import (
 var RunMain = run
 
 var (
-	CreateUserDataDirs = createUserDataDirs
-	SnapRunApp         = snapRunApp
-	SnapRunHook        = snapRunHook
-	Wait               = wait
-	ResolveApp         = resolveApp
-	IsReexeced         = isReexeced
-	MaybePrintServices = maybePrintServices
-	MaybePrintCommands = maybePrintCommands
-	SortByPath         = sortByPath
+	CreateUserDataDirs  = createUserDataDirs
+	SnapRunApp          = snapRunApp
+	SnapRunHook         = snapRunHook
+	Wait                = wait
+	ResolveApp          = resolveApp
+	IsReexeced          = isReexeced
+	MaybePrintServices  = maybePrintServices
+	MaybePrintCommands  = maybePrintCommands
+	MaybePrintPublisher = maybePrintPublisher
+	SortByPath          = sortByPath
+	ChannelTrack        = channelTrack
+	ChannelOnTrack      = channelOnTrack
 )
 
 func MockPollTime(d time.Duration) (restore func()) {
@@ -128,3 +131,29 @@ func AliasInfoLess(snapName1, alias1, cmd1, snapName2, alias2, cmd2 string) bool
 func AssertTypeNameCompletion(match string) []flags.Completion {
 	return assertTypeName("").Complete(match)
 }
+
+func MockCompletionArgs(args []string) (restore func()) {
+	completionArgsOrig := completionArgs
+	completionArgs = func() []string { return args }
+	return func() {
+		completionArgs = completionArgsOrig
+	}
+}
+
+func MockCompletionCacheDir(dir string) (restore func()) {
+	completionCacheDirOrig := completionCacheDir
+	completionCacheDir = func() (string, error) {
+		return dir, nil
+	}
+	return func() {
+		completionCacheDir = completionCacheDirOrig
+	}
+}
+
+func MockCompletionCacheTTL(d time.Duration) (restore func()) {
+	d0 := completionCacheTTL
+	completionCacheTTL = d
+	return func() {
+		completionCacheTTL = d0
+	}
+}
@@ -83,6 +83,12 @@ var commands []*cmdInfo
 // debugCommands holds information about all debug commands.
 var debugCommands []*cmdInfo
 
+// routineCommands holds information about all internal commands.
+var routineCommands []*cmdInfo
+
+// authCommands holds information about all auth commands.
+var authCommands []*cmdInfo
+
 // addCommand replaces parser.addCommand() in a way that is compatible with
 // re-constructing a pristine parser.
 func addCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
@@ -112,6 +118,36 @@ func addDebugCommand(name, shortHelp, longHelp string, builder func() flags.Comm
 	return info
 }
 
+// addRoutineCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "routine" commands.
+func addRoutineCommand(name, shortHelp, longHelp string, builder func() flags.Commander) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+	}
+	routineCommands = append(routineCommands, info)
+	return info
+}
+
+// addAuthCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding auth commands.
+func addAuthCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	authCommands = append(authCommands, info)
+	return info
+}
+
 type parserSetter interface {
 	setParser(*flags.Parser)
 }
@@ -137,6 +173,44 @@ func lintArg(cmdName, optName, desc, origDesc string) {
 	}
 }
 
+// describeCommand sets the descriptions of the options and arguments
+// of the command, checking they are all given.
+func describeCommand(cmd *flags.Command, c *cmdInfo) {
+	opts := cmd.Options()
+	if c.optDescs != nil && len(opts) != len(c.optDescs) {
+		logger.Panicf("wrong number of option descriptions for %s: expected %d, got %d", c.name, len(opts), len(c.optDescs))
+	}
+	for _, opt := range opts {
+		name := opt.LongName
+		if name == "" {
+			name = string(opt.ShortName)
+		}
+		desc, ok := c.optDescs[name]
+		if !(c.optDescs == nil || ok) {
+			logger.Panicf("%s missing description for %s", c.name, name)
+		}
+		lintDesc(c.name, name, desc, opt.Description)
+		if desc != "" {
+			opt.Description = desc
+		}
+	}
+
+	args := cmd.Args()
+	if c.argDescs != nil && len(args) != len(c.argDescs) {
+		logger.Panicf("wrong number of argument descriptions for %s: expected %d, got %d", c.name, len(args), len(c.argDescs))
+	}
+	for i, arg := range args {
+		name, desc := arg.Name, ""
+		if c.argDescs != nil {
+			name = c.argDescs[i].name
+			desc = c.argDescs[i].desc
+		}
+		lintArg(c.name, name, desc, arg.Description)
+		arg.Name = name
+		arg.Description = desc
+	}
+}
+
 // Parser creates and populates a fresh parser.
 // Since commands have local state a fresh parser is required to isolate tests
 // from each other.
@@ -174,39 +248,7 @@ snaps on the system. Start with 'snap list' to see installed snaps.
 			cmd.Aliases = append(cmd.Aliases, c.alias)
 		}
 
-		opts := cmd.Options()
-		if c.optDescs != nil && len(opts) != len(c.optDescs) {
-			logger.Panicf("wrong number of option descriptions for %s: expected %d, got %d", c.name, len(opts), len(c.optDescs))
-		}
-		for _, opt := range opts {
-			name := opt.LongName
-			if name == "" {
-				name = string(opt.ShortName)
-			}
-			desc, ok := c.optDescs[name]
-			if !(c.optDescs == nil || ok) {
-				logger.Panicf("%s missing description for %s", c.name, name)
-			}
-			lintDesc(c.name, name, desc, opt.Description)
-			if desc != "" {
-				opt.Description = desc
-			}
-		}
-
-		args := cmd.Args()
-		if c.argDescs != nil && len(args) != len(c.argDescs) {
-			logger.Panicf("wrong number of argument descriptions for %s: expected %d, got %d", c.name, len(args), len(c.argDescs))
-		}
-		for i, arg := range args {
-			name, desc := arg.Name, ""
-			if c.argDescs != nil {
-				name = c.argDescs[i].name
-				desc = c.argDescs[i].desc
-			}
-			lintArg(c.name, name, desc, arg.Description)
-			arg.Name = name
-			arg.Description = desc
-		}
+		describeCommand(cmd, c)
 	}
 	// Add the debug command
 	debugCommand, err := parser.AddCommand("debug", shortDebugHelp, longDebugHelp, &cmdDebug{})
@@ -222,6 +264,34 @@ snaps on the system. Start with 'snap list' to see installed snaps.
 		}
 		cmd.Hidden = c.hidden
 	}
+	// Add the auth command
+	authCommand, err := parser.AddCommand("auth", shortAuthHelp, longAuthHelp, &cmdAuth{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "auth", err)
+	}
+	// Add all the sub-commands of the auth command
+	for _, c := range authCommands {
+		cmd, err := authCommand.AddCommand(c.name, c.shortHelp, strings.TrimSpace(c.longHelp), c.builder())
+		if err != nil {
+			logger.Panicf("cannot add auth command %q: %v", c.name, err)
+		}
+		cmd.Hidden = c.hidden
+		describeCommand(cmd, c)
+	}
+	// Add the internal command
+	routineCommand, err := parser.AddCommand("routine", shortRoutineHelp, longRoutineHelp, &cmdRoutine{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "routine", err)
+	}
+	routineCommand.Hidden = true
+	// Add all the sub-commands of the routine command
+	for _, c := range routineCommands {
+		cmd, err := routineCommand.AddCommand(c.name, c.shortHelp, strings.TrimSpace(c.longHelp), c.builder())
+		if err != nil {
+			logger.Panicf("cannot add routine command %q: %v", c.name, err)
+		}
+		cmd.Hidden = c.hidden
+	}
 	return parser
 }
 
@@ -231,6 +301,8 @@ var ClientConfig = client.Config{
 	Socket: dirs.SnapdSocket,
 	// Allow interactivity if we have a terminal
 	Interactive: terminal.IsTerminal(0),
+	// Use a token from 'snap auth create-token' if given one
+	Token: os.Getenv("SNAPD_AUTH_TOKEN"),
 }
 
 // Client returns a new client using ClientConfig as configuration.
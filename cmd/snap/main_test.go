@@ -74,6 +74,7 @@ func (s *BaseSnapSuite) SetUpTest(c *C) {
 	snap.ReadPassword = s.readPassword
 	s.AuthFile = filepath.Join(c.MkDir(), "json")
 	os.Setenv(TestAuthFileEnvKey, s.AuthFile)
+	s.AddCleanup(snap.MockCompletionCacheDir(c.MkDir()))
 }
 
 func (s *BaseSnapSuite) TearDownTest(c *C) {
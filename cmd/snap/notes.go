@@ -60,6 +60,12 @@ type Notes struct {
 	TryMode  bool
 	Disabled bool
 	Broken   bool
+	// ForeignArch is set for snaps of another architecture, running
+	// under emulation.
+	ForeignArch bool
+	// OffDefaultTrack is set for snaps not following the track their
+	// publisher set as the default one.
+	OffDefaultTrack bool
 }
 
 func NotesFromChannelSnapInfo(ref *snap.ChannelSnapInfo) *Notes {
@@ -93,6 +99,8 @@ func NotesFromLocal(snp *client.Snap) *Notes {
 		TryMode:  snp.TryMode,
 		Disabled: snp.Status != client.StatusActive,
 		Broken:   snp.Broken != "",
+
+		ForeignArch: snp.ForeignArch,
 	}
 }
 
@@ -155,6 +163,14 @@ func (n *Notes) String() string {
 		ns = append(ns, i18n.G("broken"))
 	}
 
+	if n.ForeignArch {
+		ns = append(ns, "foreign-arch")
+	}
+
+	if n.OffDefaultTrack {
+		ns = append(ns, "off-default-track")
+	}
+
 	if len(ns) == 0 {
 		return "-"
 	}
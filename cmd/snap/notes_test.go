@@ -82,6 +82,13 @@ func (notesSuite) TestNotesBroken(c *check.C) {
 	}).String(), check.Equals, "broken")
 }
 
+func (notesSuite) TestNotesForeignArch(c *check.C) {
+	c.Check((&snap.Notes{
+		ForeignArch: true,
+	}).String(), check.Equals, "foreign-arch")
+	c.Check(snap.NotesFromLocal(&client.Snap{ForeignArch: true}).ForeignArch, check.Equals, true)
+}
+
 func (notesSuite) TestNotesNothing(c *check.C) {
 	c.Check((&snap.Notes{}).String(), check.Equals, "-")
 }
@@ -28,7 +28,7 @@ import (
 
 	"github.com/snapcore/snapd/cmd"
 	"github.com/snapcore/snapd/daemon"
-	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/systemd"
@@ -39,14 +39,18 @@ func init() {
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "WARNING: failed to activate logging: %s\n", err)
 	}
-	// set here to avoid accidental submits in e.g. unit tests
-	errtracker.CrashDbURLBase = "https://daisy.ubuntu.com/"
-	errtracker.SnapdVersion = cmd.Version
+	errreport.SnapdVersion = cmd.Version
 }
 
 func main() {
 	cmd.ExecInCoreSnap()
 	if err := run(); err != nil {
+		if err == daemon.ErrRestartSocket {
+			// snapd.service is set up not to restart snapd
+			// when it exits with this status, leaving it to
+			// socket activation
+			os.Exit(42)
+		}
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
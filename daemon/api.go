@@ -20,6 +20,8 @@
 package daemon
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -32,9 +34,12 @@ import (
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/pprof"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -42,12 +47,15 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/cgroup"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -57,6 +65,8 @@ import (
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/promptstate"
+	"github.com/snapcore/snapd/overlord/secretstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
@@ -64,6 +74,7 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/oci"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/systemd"
 )
@@ -71,18 +82,26 @@ import (
 var api = []*Command{
 	rootCmd,
 	sysInfoCmd,
+	seedingCmd,
 	loginCmd,
 	logoutCmd,
+	tokensCmd,
 	appIconCmd,
 	findCmd,
 	snapsCmd,
 	snapCmd,
+	componentsCmd,
 	snapConfCmd,
 	interfacesCmd,
+	connectionsCmd,
+	connectionsHistoryCmd,
+	connectionsActivityCmd,
+	diskUsageCmd,
 	assertsCmd,
 	assertsFindManyCmd,
 	stateChangeCmd,
 	stateChangesCmd,
+	jobCmd,
 	createUserCmd,
 	buyCmd,
 	readyToBuyCmd,
@@ -93,6 +112,16 @@ var api = []*Command{
 	appsCmd,
 	logsCmd,
 	debugCmd,
+	debugProfileCmd,
+	consoleConfCmd,
+	secretsCmd,
+	promptRequestsCmd,
+	promptRequestCmd,
+	promptRulesCmd,
+	metricsCmd,
+	routineOwnerCmd,
+	planCmd,
+	manifestCmd,
 }
 
 var (
@@ -108,6 +137,12 @@ var (
 		GET:     sysInfo,
 	}
 
+	seedingCmd = &Command{
+		Path:    "/v2/seeding",
+		GuestOK: true,
+		GET:     getSeeding,
+	}
+
 	loginCmd = &Command{
 		Path:     "/v2/login",
 		POST:     loginUser,
@@ -120,6 +155,12 @@ var (
 		UserOK: true,
 	}
 
+	tokensCmd = &Command{
+		Path: "/v2/tokens",
+		GET:  getTokens,
+		POST: postTokens,
+	}
+
 	appIconCmd = &Command{
 		Path:   "/v2/icons/{name}/icon",
 		UserOK: true,
@@ -161,6 +202,12 @@ var (
 		GET:    getLogs,
 	}
 
+	componentsCmd = &Command{
+		Path:     "/v2/components",
+		PolkitOK: "io.snapcraft.snapd.manage",
+		POST:     postComponents,
+	}
+
 	snapConfCmd = &Command{
 		Path: "/v2/snaps/{name}/conf",
 		GET:  getSnapConf,
@@ -174,6 +221,31 @@ var (
 		POST:   changeInterfaces,
 	}
 
+	connectionsCmd = &Command{
+		Path:   "/v2/connections",
+		UserOK: true,
+		GET:    getConnections,
+		POST:   postConnections,
+	}
+
+	connectionsHistoryCmd = &Command{
+		Path:   "/v2/connections/history",
+		UserOK: true,
+		GET:    getConnectionsHistory,
+	}
+
+	// root only: the processes and denials are those of all users
+	connectionsActivityCmd = &Command{
+		Path: "/v2/connections/activity",
+		GET:  getConnectionsActivity,
+	}
+
+	diskUsageCmd = &Command{
+		Path:   "/v2/disk-usage",
+		UserOK: true,
+		GET:    getDiskUsage,
+	}
+
 	// TODO: allow to post assertions for UserOK? they are verified anyway
 	assertsCmd = &Command{
 		Path:   "/v2/assertions",
@@ -192,7 +264,7 @@ var (
 		Path:   "/v2/changes/{id}",
 		UserOK: true,
 		GET:    getChange,
-		POST:   abortChange,
+		POST:   postChange,
 	}
 
 	stateChangesCmd = &Command{
@@ -201,11 +273,28 @@ var (
 		GET:    getChanges,
 	}
 
+	jobCmd = &Command{
+		Path:   "/v2/jobs/{id}",
+		UserOK: true,
+		GET:    getJob,
+	}
+
 	debugCmd = &Command{
 		Path: "/v2/debug",
 		POST: postDebug,
 	}
 
+	debugProfileCmd = &Command{
+		Path: "/v2/debug/profiles/{name}",
+		GET:  getDebugProfile,
+	}
+
+	consoleConfCmd = &Command{
+		Path: "/v2/console-conf",
+		GET:  getConsoleConf,
+		POST: postConsoleConf,
+	}
+
 	createUserCmd = &Command{
 		Path:   "/v2/create-user",
 		UserOK: false,
@@ -248,6 +337,53 @@ var (
 		GET:    getAliases,
 		POST:   changeAliases,
 	}
+
+	secretsCmd = &Command{
+		Path: "/v2/secrets",
+		GET:  getSecrets,
+		POST: postSecrets,
+	}
+
+	promptRequestsCmd = &Command{
+		Path:   "/v2/prompting/requests",
+		UserOK: true,
+		GET:    getPromptRequests,
+	}
+
+	promptRequestCmd = &Command{
+		Path:       "/v2/prompting/requests/{id}",
+		UserPostOK: true,
+		POST:       postPromptRequest,
+	}
+
+	promptRulesCmd = &Command{
+		Path:       "/v2/prompting/rules",
+		UserOK:     true,
+		UserPostOK: true,
+		GET:        getPromptRules,
+		POST:       postPromptRules,
+	}
+
+	metricsCmd = &Command{
+		Path: "/v2/metrics",
+		GET:  getMetrics,
+	}
+
+	routineOwnerCmd = &Command{
+		Path:   "/v2/routine/owner",
+		UserOK: true,
+		GET:    getRoutineOwner,
+	}
+
+	planCmd = &Command{
+		Path: "/v2/plan",
+		POST: postPlan,
+	}
+
+	manifestCmd = &Command{
+		Path: "/v2/manifest",
+		POST: postManifest,
+	}
 )
 
 func tbd(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -290,6 +426,9 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 			Last:     formatRefreshTime(lastRefresh),
 			Next:     formatRefreshTime(nextRefresh),
 		},
+		"sandbox-features": map[string][]string{
+			"cgroup": cgroup.Features(),
+		},
 	}
 	// NOTE: Right now we don't have a good way to differentiate if we
 	// only have partial confinement (ala AppArmor disabled and Seccomp
@@ -305,6 +444,18 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(m, nil)
 }
 
+func getSeeding(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	status, err := devicestate.Seeding(st)
+	if err != nil {
+		return InternalError("cannot get seeding status: %v", err)
+	}
+	return SyncResponse(status, nil)
+}
+
 // userResponseData contains the data releated to user creation/login/query
 type userResponseData struct {
 	ID       int      `json:"id,omitempty"`
@@ -443,6 +594,147 @@ func logoutUser(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(nil, nil)
 }
 
+// tokenInfo describes a token, its secret is only given when creating it
+type tokenInfo struct {
+	ID      int       `json:"id"`
+	Label   string    `json:"label,omitempty"`
+	Scopes  []string  `json:"scopes"`
+	Expires time.Time `json:"expires"`
+	UID     uint32    `json:"uid"`
+	Token   string    `json:"token,omitempty"`
+}
+
+func getTokens(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	tokens, err := auth.Tokens(st)
+	if err != nil {
+		return InternalError("cannot list tokens: %v", err)
+	}
+	infos := make([]tokenInfo, len(tokens))
+	for i, token := range tokens {
+		infos[i] = tokenInfo{
+			ID:      token.ID,
+			Label:   token.Label,
+			Scopes:  token.Scopes,
+			Expires: token.Expires,
+			UID:     token.UID,
+		}
+	}
+	return SyncResponse(infos, nil)
+}
+
+// tokenAction is an action performed on tokens
+type tokenAction struct {
+	Action    string   `json:"action"`
+	ID        int      `json:"id"`
+	Label     string   `json:"label"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn string   `json:"expires-in"`
+	// User is the name or uid of the user the token is accepted
+	// from, it defaults to the one creating the token.
+	User string `json:"user"`
+}
+
+func postTokens(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a tokenAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a token action: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	switch a.Action {
+	case "create":
+		if a.User != "" {
+			uid, err := tokenUser(a.User)
+			if err != nil {
+				return BadRequest("cannot create a token for user %q: %v", a.User, err)
+			}
+			return createToken(st, &a, uid)
+		}
+		// by default tokens are bound to the user creating them
+		_, uid, err := ucrednetGet(r.RemoteAddr)
+		if err != nil {
+			return Forbidden("cannot create a token without knowing the user requesting it")
+		}
+		return createToken(st, &a, uid)
+	case "revoke":
+		if err := auth.RemoveToken(st, a.ID); err != nil {
+			return NotFound("cannot revoke token %d: %v", a.ID, err)
+		}
+		return SyncResponse(nil, nil)
+	default:
+		return BadRequest("unsupported token action: %q", a.Action)
+	}
+}
+
+// tokenUser returns the uid of the user with the given name or uid.
+func tokenUser(name string) (uint32, error) {
+	if uid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(uid), nil
+	}
+	u, err := userLookup(name)
+	if err != nil {
+		return 0, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uid %q", u.Uid)
+	}
+	return uint32(uid), nil
+}
+
+func createToken(st *state.State, a *tokenAction, uid uint32) Response {
+	if len(a.Scopes) == 0 {
+		return BadRequest("cannot create a token without scopes")
+	}
+	for _, scope := range a.Scopes {
+		if err := auth.ValidateTokenScope(scope); err != nil {
+			return BadRequest("%v", err)
+		}
+		// tokens must not allow getting more tokens
+		probe := &auth.TokenState{Scopes: []string{scope}}
+		for _, method := range []string{"GET", "POST"} {
+			if probe.Allows(method, "/v2/tokens") {
+				return BadRequest("cannot create a token with scope %q covering /v2/tokens", scope)
+			}
+		}
+	}
+	expiresIn, err := time.ParseDuration(a.ExpiresIn)
+	if err != nil || expiresIn <= 0 {
+		return BadRequest("cannot create a token expiring in %q: invalid duration", a.ExpiresIn)
+	}
+
+	token, secret, err := auth.NewToken(st, a.Label, a.Scopes, uid, time.Now().Add(expiresIn))
+	if err != nil {
+		return InternalError("cannot create token: %v", err)
+	}
+	return SyncResponse(&tokenInfo{
+		ID:      token.ID,
+		Label:   token.Label,
+		Scopes:  token.Scopes,
+		Expires: token.Expires,
+		UID:     token.UID,
+		Token:   secret,
+	}, nil)
+}
+
+// TokenFromRequest returns the token the request is authorized with, if
+// valid. It requires the state to be locked
+func TokenFromRequest(st *state.State, req *http.Request) (*auth.TokenState, error) {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Token ") {
+		return nil, auth.ErrInvalidAuth
+	}
+	return auth.CheckToken(st, strings.TrimSpace(header[len("Token "):]))
+}
+
 // UserFromRequest extracts user information from request and return the respective user in state, if valid
 // It requires the state to be locked
 func UserFromRequest(st *state.State, req *http.Request) (*auth.UserState, error) {
@@ -643,8 +935,23 @@ func findOne(c *Command, r *http.Request, user *auth.UserState, name string) Res
 		Sources:           []string{"store"},
 	}
 
+	result := mapRemote(snapInfo)
+	if snapInfo.PublisherID != "" {
+		// the other snaps of the publisher help deciding whether to
+		// trust it, but aren't worth failing over
+		others, err := theStore.PublisherSnaps(snapInfo.PublisherID, user)
+		if err != nil {
+			logger.Noticef("cannot get the snaps of publisher %q: %v", snapInfo.Publisher, err)
+		}
+		for _, other := range others {
+			if other != snapInfo.Name() {
+				result.PublisherSnaps = append(result.PublisherSnaps, other)
+			}
+		}
+	}
+
 	results := make([]*json.RawMessage, 1)
-	data, err := json.Marshal(webify(mapRemote(snapInfo), r.URL.String()))
+	data, err := json.Marshal(webify(result, r.URL.String()))
 	if err != nil {
 		return InternalError(err.Error())
 	}
@@ -691,6 +998,34 @@ func storeUpdates(c *Command, r *http.Request, user *auth.UserState) Response {
 	return sendStorePackages(route, nil, updates)
 }
 
+func refreshCandidates(c *Command, r *http.Request, user *auth.UserState) Response {
+	state := c.d.overlord.State()
+	state.Lock()
+	pending, err := snapstatePendingRefreshes(state, user)
+	state.Unlock()
+	if err != nil {
+		return InternalError("cannot list refresh candidates: %v", err)
+	}
+
+	results := make([]*client.RefreshCandidate, len(pending))
+	for i, pr := range pending {
+		cand := &client.RefreshCandidate{
+			Name:            pr.Name,
+			CurrentRevision: pr.Current,
+			Revision:        pr.Candidate.Revision,
+			Version:         pr.Candidate.Version,
+			Channel:         pr.Channel,
+			DownloadSize:    pr.Candidate.Size,
+		}
+		for _, hold := range pr.Held {
+			cand.Held = append(cand.Held, client.RefreshHold{Reason: hold.Reason, Message: hold.Message})
+		}
+		results[i] = cand
+	}
+
+	return SyncResponse(results, &Meta{Sources: []string{"store"}})
+}
+
 func sendStorePackages(route *mux.Route, meta *Meta, found []*snap.Info) Response {
 	results := make([]*json.RawMessage, 0, len(found))
 	for _, x := range found {
@@ -732,6 +1067,8 @@ func getSnapsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 		all = true
 	case "enabled", "":
 		all = false
+	case "refresh-candidates":
+		return refreshCandidates(c, r, user)
 	default:
 		return BadRequest("invalid select parameter: %q", sel)
 	}
@@ -800,21 +1137,72 @@ type snapInstruction struct {
 	Action           string        `json:"action"`
 	Channel          string        `json:"channel"`
 	Revision         snap.Revision `json:"revision"`
+	Source           string        `json:"source"`
+	Store            string        `json:"store"`
+	BranchExpiry     string        `json:"branch-expiry"`
 	DevMode          bool          `json:"devmode"`
 	JailMode         bool          `json:"jailmode"`
 	Classic          bool          `json:"classic"`
 	IgnoreValidation bool          `json:"ignore-validation"`
 	Unaliased        bool          `json:"unaliased"`
+	// ForceBreaks removes snaps even if installed snaps depend on them.
+	ForceBreaks bool `json:"force-breaks"`
+	// DryRun reports what removing the snap would break, instead of
+	// removing it.
+	DryRun bool `json:"dry-run"`
+	// WithConfig restores, when reverting, the configuration the snap
+	// had with the revision reverted to.
+	WithConfig bool `json:"with-config"`
 	// dropping support temporarely until flag confusion is sorted,
 	// this isn't supported by client atm anyway
 	LeaveOld bool         `json:"temp-dropped-leave-old"`
 	License  *licenseData `json:"license"`
 	Snaps    []string     `json:"snaps"`
+	// SnapOptions are the options of the snaps of multi-snap
+	// operations, by snap name.
+	SnapOptions map[string]*snapInstructionOptions `json:"snap-options"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
 }
 
+// snapInstructionOptions are the options of one of the snaps of a
+// multi-snap operation.
+type snapInstructionOptions struct {
+	Channel  string        `json:"channel"`
+	Revision snap.Revision `json:"revision"`
+	DevMode  bool          `json:"devmode"`
+	JailMode bool          `json:"jailmode"`
+	Classic  bool          `json:"classic"`
+}
+
+// manyOptions returns the options of the snaps of a multi-snap
+// operation, which must be among the snaps of the instruction.
+func (inst *snapInstruction) manyOptions() (map[string]*snapstate.SnapOptions, error) {
+	if len(inst.SnapOptions) == 0 {
+		return nil, nil
+	}
+	opts := make(map[string]*snapstate.SnapOptions, len(inst.SnapOptions))
+	for name, o := range inst.SnapOptions {
+		if !strutil.ListContains(inst.Snaps, name) {
+			return nil, fmt.Errorf("cannot use options of snap %q not part of the operation", name)
+		}
+		if o == nil {
+			continue
+		}
+		flags, err := modeFlags(o.DevMode, o.JailMode, o.Classic)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use options of snap %q: %v", name, err)
+		}
+		opts[name] = &snapstate.SnapOptions{
+			Channel:  o.Channel,
+			Revision: o.Revision,
+			Flags:    flags,
+		}
+	}
+	return opts, nil
+}
+
 func (inst *snapInstruction) modeFlags() (snapstate.Flags, error) {
 	return modeFlags(inst.DevMode, inst.JailMode, inst.Classic)
 }
@@ -831,17 +1219,25 @@ func (inst *snapInstruction) installFlags() (snapstate.Flags, error) {
 }
 
 var (
-	snapstateInstall           = snapstate.Install
-	snapstateInstallPath       = snapstate.InstallPath
-	snapstateRefreshCandidates = snapstate.RefreshCandidates
-	snapstateTryPath           = snapstate.TryPath
-	snapstateUpdate            = snapstate.Update
-	snapstateUpdateMany        = snapstate.UpdateMany
-	snapstateInstallMany       = snapstate.InstallMany
-	snapstateRemoveMany        = snapstate.RemoveMany
-	snapstateRevert            = snapstate.Revert
-	snapstateRevertToRevision  = snapstate.RevertToRevision
-	snapstateSwitch            = snapstate.Switch
+	snapstateInstall             = snapstate.Install
+	snapstateInstallFromStore    = snapstate.InstallFromStore
+	snapstateInstallPath         = snapstate.InstallPath
+	snapstateInstallFromRegistry = snapstate.InstallFromRegistry
+	snapstateRefreshCandidates   = snapstate.RefreshCandidates
+	snapstatePendingRefreshes    = snapstate.PendingRefreshes
+	snapstateTryPath             = snapstate.TryPath
+	snapstateUpdate              = snapstate.Update
+	snapstateUpdateMany          = snapstate.UpdateMany
+	snapstateInstallMany         = snapstate.InstallMany
+	snapstateRemoveMany          = snapstate.RemoveMany
+	snapstateRevert              = snapstate.Revert
+	snapstateRevertToRevision    = snapstate.RevertToRevision
+	snapstateSwitch              = snapstate.Switch
+	snapstateSetBranchExpiry     = snapstate.SetBranchExpiry
+
+	snapstateInstallComponentPath = snapstate.InstallComponentPath
+	snapstateRefreshComponentPath = snapstate.RefreshComponentPath
+	snapstateRemoveComponent      = snapstate.RemoveComponent
 
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
 )
@@ -884,7 +1280,12 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (msg string, updated
 		return "", nil, nil, err
 	}
 
-	updated, tasksets, err = snapstateUpdateMany(st, inst.Snaps, inst.userID)
+	opts, err := inst.manyOptions()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	updated, tasksets, err = snapstateUpdateMany(st, inst.Snaps, inst.userID, opts)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -918,13 +1319,53 @@ func verifySnapInstructions(inst *snapInstruction) error {
 				return fmt.Errorf(`cannot install "ubuntu-core", please use "core" instead`)
 			}
 		}
+	default:
+		if inst.Source != "" {
+			return fmt.Errorf("snap source can only be specified when installing")
+		}
+		if inst.Store != "" {
+			return fmt.Errorf("snap store can only be specified when installing")
+		}
+	}
+
+	if inst.BranchExpiry != "" && inst.Action != "refresh" {
+		return fmt.Errorf("branch expiry policy can only be specified when refreshing")
+	}
+
+	if (inst.ForceBreaks || inst.DryRun) && inst.Action != "remove" {
+		return fmt.Errorf("force-breaks and dry-run can only be specified when removing")
+	}
+
+	if inst.WithConfig && inst.Action != "revert" {
+		return fmt.Errorf("with-config can only be specified when reverting")
+	}
+
+	if inst.Store != "" && inst.Source != "" {
+		return fmt.Errorf("cannot specify both a snap source and a store")
+	}
+
+	if inst.Source != "" {
+		if !strings.HasPrefix(inst.Source, oci.Scheme) {
+			return fmt.Errorf("unsupported snap source %q", inst.Source)
+		}
+		if !inst.Revision.Unset() {
+			return fmt.Errorf("cannot specify a revision when installing from %q", inst.Source)
+		}
+		if _, err := oci.ParseReference(inst.Source); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func snapInstallMany(inst *snapInstruction, st *state.State) (msg string, installed []string, tasksets []*state.TaskSet, err error) {
-	installed, tasksets, err = snapstateInstallMany(st, inst.Snaps, inst.userID)
+	opts, err := inst.manyOptions()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	installed, tasksets, err = snapstateInstallMany(st, inst.Snaps, inst.userID, opts)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -949,9 +1390,18 @@ func snapInstall(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 		return "", nil, err
 	}
 
+	if inst.Source != "" {
+		return snapInstallFromRegistry(inst, st, flags)
+	}
+
 	logger.Noticef("Installing snap %q revision %s", inst.Snaps[0], inst.Revision)
 
-	tset, err := snapstateInstall(st, inst.Snaps[0], inst.Channel, inst.Revision, inst.userID, flags)
+	var tset *state.TaskSet
+	if inst.Store != "" {
+		tset, err = snapstateInstallFromStore(st, inst.Snaps[0], inst.Store, inst.Channel, inst.Revision, inst.userID, flags)
+	} else {
+		tset, err = snapstateInstall(st, inst.Snaps[0], inst.Channel, inst.Revision, inst.userID, flags)
+	}
 	if err != nil {
 		return "", nil, err
 	}
@@ -963,6 +1413,26 @@ func snapInstall(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 	return msg, []*state.TaskSet{tset}, nil
 }
 
+// snapInstallFromRegistry installs a snap published as an OCI artifact,
+// together with the assertions needed to verify it, in a container
+// registry. The snap is downloaded and verified by the change.
+func snapInstallFromRegistry(inst *snapInstruction, st *state.State, flags snapstate.Flags) (string, []*state.TaskSet, error) {
+	ref, err := oci.ParseReference(inst.Source)
+	if err != nil {
+		return "", nil, err
+	}
+
+	logger.Noticef("Installing snap %q from %s", inst.Snaps[0], ref)
+
+	tset, err := snapstateInstallFromRegistry(st, inst.Snaps[0], ref.String(), inst.Channel, inst.userID, flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf(i18n.G("Install %q snap from %q"), inst.Snaps[0], ref.String())
+	return msg, []*state.TaskSet{tset}, nil
+}
+
 func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
 	// TODO: bail if revision is given (and != current?), *or* behave as with install --revision?
 	flags, err := inst.modeFlags()
@@ -973,6 +1443,12 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 		flags.IgnoreValidation = true
 	}
 
+	if inst.BranchExpiry != "" {
+		if err := snapstateSetBranchExpiry(st, inst.Snaps[0], inst.BranchExpiry); err != nil {
+			return "", nil, err
+		}
+	}
+
 	// we need refreshed snap-declarations to enforce refresh-control as best as we can
 	if err = assertstateRefreshSnapDeclarations(st, inst.userID); err != nil {
 		return "", nil, err
@@ -1011,6 +1487,43 @@ func snapRemoveMany(inst *snapInstruction, st *state.State) (msg string, removed
 	return msg, removed, tasksets, nil
 }
 
+// snapRemovalImpact returns what removing the given snap would break.
+func snapRemovalImpact(ifacemgr *ifacestate.InterfaceManager, name string) (*ifacestate.RemovalImpact, error) {
+	impact, err := ifacemgr.RemovalImpact(name)
+	if err == state.ErrNoState {
+		return nil, &snap.NotInstalledError{Snap: name, Rev: snap.R(0)}
+	}
+	return impact, err
+}
+
+// checkRemovalImpact refuses to remove snaps installed snaps depend on,
+// unless forced to. Snaps removed together may depend on each other.
+func checkRemovalImpact(ifacemgr *ifacestate.InterfaceManager, inst *snapInstruction) error {
+	if inst.ForceBreaks {
+		return nil
+	}
+	for _, name := range inst.Snaps {
+		impact, err := snapRemovalImpact(ifacemgr, name)
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			// removing it will report it's not installed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var dependents []string
+		for _, dependent := range impact.Dependents() {
+			if !strutil.ListContains(inst.Snaps, dependent) {
+				dependents = append(dependents, dependent)
+			}
+		}
+		if len(dependents) > 0 {
+			return &ifacestate.SnapHasDependentsError{Snap: name, Dependents: dependents}
+		}
+	}
+	return nil
+}
+
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
 	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision)
 	if err != nil {
@@ -1028,6 +1541,7 @@ func snapRevert(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 	if err != nil {
 		return "", nil, err
 	}
+	flags.RevertConfig = inst.WithConfig
 
 	if inst.Revision.Unset() {
 		ts, err = snapstateRevert(st, inst.Snaps[0], flags)
@@ -1122,6 +1636,8 @@ func (inst *snapInstruction) errToResponse(err error) Response {
 			kind = errorKindSnapNeedsClassic
 		case *snapstate.SnapNeedsClassicSystemError:
 			kind = errorKindSnapNeedsClassicSystem
+		case *ifacestate.SnapHasDependentsError:
+			kind = errorKindSnapHasDependents
 		default:
 			return BadRequest("cannot %s %q: %v", inst.Action, inst.Snaps[0], err)
 		}
@@ -1166,6 +1682,20 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("unknown action %s", inst.Action)
 	}
 
+	ifacemgr := c.d.overlord.InterfaceManager()
+	if inst.DryRun {
+		impact, err := snapRemovalImpact(ifacemgr, inst.Snaps[0])
+		if err != nil {
+			return inst.errToResponse(err)
+		}
+		return SyncResponse(impact, nil)
+	}
+	if inst.Action == "remove" && inst.Revision.Unset() {
+		if err := checkRemovalImpact(ifacemgr, &inst); err != nil {
+			return inst.errToResponse(err)
+		}
+	}
+
 	msg, tsets, err := impl(&inst, state)
 	if err != nil {
 		return inst.errToResponse(err)
@@ -1261,6 +1791,9 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 	if inst.Channel != "" || !inst.Revision.Unset() || inst.DevMode || inst.JailMode {
 		return BadRequest("unsupported option provided for multi-snap operation")
 	}
+	if inst.Action == "remove" && len(inst.SnapOptions) != 0 {
+		return BadRequest("unsupported option provided for multi-snap operation")
+	}
 
 	st := c.d.overlord.State()
 	st.Lock()
@@ -1270,20 +1803,22 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 		inst.userID = user.ID
 	}
 
-	var msg string
-	var affected []string
-	var tsets []*state.TaskSet
-	var err error
-	switch inst.Action {
-	case "refresh":
-		msg, affected, tsets, err = snapUpdateMany(&inst, st)
-	case "install":
-		msg, affected, tsets, err = snapInstallMany(&inst, st)
-	case "remove":
-		msg, affected, tsets, err = snapRemoveMany(&inst, st)
-	default:
+	impl := inst.dispatchForMany()
+	if impl == nil {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
+	if inst.DryRun {
+		return BadRequest("dry-run is only supported for a single snap")
+	}
+	if inst.Action == "remove" {
+		if err := checkRemovalImpact(c.d.overlord.InterfaceManager(), &inst); err != nil {
+			if _, ok := err.(*ifacestate.SnapHasDependentsError); ok {
+				return inst.errToResponse(err)
+			}
+			return InternalError("cannot %s %q: %v", inst.Action, inst.Snaps, err)
+		}
+	}
+	msg, affected, tsets, err := impl(&inst, st)
 	if err != nil {
 		return InternalError("cannot %s %q: %v", inst.Action, inst.Snaps, err)
 	}
@@ -1301,6 +1836,18 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 	return AsyncResponse(nil, &Meta{Change: chg.ID()})
 }
 
+type snapManyActionFunc func(*snapInstruction, *state.State) (msg string, affected []string, tasksets []*state.TaskSet, err error)
+
+var snapInstructionManyDispTable = map[string]snapManyActionFunc{
+	"install": snapInstallMany,
+	"refresh": snapUpdateMany,
+	"remove":  snapRemoveMany,
+}
+
+func (inst *snapInstruction) dispatchForMany() snapManyActionFunc {
+	return snapInstructionManyDispTable[inst.Action]
+}
+
 func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
 	contentType := r.Header.Get("Content-Type")
 
@@ -1333,6 +1880,7 @@ func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return BadRequest(err.Error())
 	}
+	flags.ForeignArch = isTrue(form, "foreign-arch")
 
 	if len(form.Value["action"]) > 0 && form.Value["action"][0] == "try" {
 		if len(form.Value["snap-path"]) == 0 {
@@ -1464,42 +2012,194 @@ func unsafeReadSnapInfoImpl(snapPath string) (*snap.Info, error) {
 
 var unsafeReadSnapInfo = unsafeReadSnapInfoImpl
 
-func iconGet(st *state.State, name string) Response {
-	about, err := localSnapInfo(st, name)
+func unsafeReadComponentInfoImpl(compPath string) (*snap.ComponentInfo, error) {
+	compf, err := snap.Open(compPath)
 	if err != nil {
-		if err == errNoSnap {
-			return SnapNotFound(name, err)
-		}
-		return InternalError("%v", err)
+		return nil, err
 	}
+	return snap.ReadComponentInfoFromContainer(compf)
+}
 
-	path := filepath.Clean(snapIcon(about.info))
-	if !strings.HasPrefix(path, dirs.SnapMountDir) {
-		// XXX: how could this happen?
-		return BadRequest("requested icon is not in snap path")
-	}
+var unsafeReadComponentInfo = unsafeReadComponentInfoImpl
 
-	return FileResponse(path)
+// componentInstruction is an action performed on an installed component
+type componentInstruction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
 }
 
-func appIconGet(c *Command, r *http.Request, user *auth.UserState) Response {
-	vars := muxVars(r)
-	name := vars["name"]
+func postComponents(c *Command, r *http.Request, user *auth.UserState) Response {
+	contentType := r.Header.Get("Content-Type")
 
-	return iconGet(c.d.overlord.State(), name)
-}
+	if contentType == "application/json" {
+		return componentOp(c, r, user)
+	}
 
-func splitQS(qs string) []string {
-	qsl := strings.Split(qs, ",")
-	split := make([]string, 0, len(qsl))
-	for _, elem := range qsl {
-		elem = strings.TrimSpace(elem)
-		if len(elem) > 0 {
-			split = append(split, elem)
-		}
+	if !strings.HasPrefix(contentType, "multipart/") {
+		return BadRequest("unknown content type: %s", contentType)
 	}
 
-	return split
+	// POSTs to sideload components must be a multipart/form-data file upload.
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return BadRequest("cannot parse POST body: %v", err)
+	}
+
+	form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(maxReadBuflen)
+	if err != nil {
+		return BadRequest("cannot read POST form: %v", err)
+	}
+	defer form.RemoveAll()
+
+	action := "install"
+	if len(form.Value["action"]) > 0 {
+		action = form.Value["action"][0]
+	}
+	var installComponentPath func(*state.State, string, snapstate.ComponentFlags) (*state.TaskSet, error)
+	var msgFmt string
+	switch action {
+	case "install":
+		installComponentPath = snapstateInstallComponentPath
+		msgFmt = i18n.G("Install %q component from file %q")
+	case "refresh":
+		installComponentPath = snapstateRefreshComponentPath
+		msgFmt = i18n.G("Refresh %q component from file %q")
+	default:
+		return BadRequest("unsupported component action: %q", action)
+	}
+
+	// components carry no assertions yet
+	if !isTrue(form, "dangerous") {
+		return BadRequest("cannot install component without --dangerous: components cannot be verified")
+	}
+
+	fheaders := form.File["component"]
+	if len(fheaders) == 0 {
+		return BadRequest(`cannot find "component" file field in provided multipart/form-data payload`)
+	}
+	compBody, err := fheaders[0].Open()
+	if err != nil {
+		return BadRequest(`cannot open uploaded "component" file: %v`, err)
+	}
+	defer compBody.Close()
+	origPath := fheaders[0].Filename
+
+	// we are in charge of the tempfile life cycle until we hand it off to the change
+	changeTriggered := false
+	// if you change this prefix, look for it in the tests
+	tmpf, err := ioutil.TempFile("", "snapd-sideload-comp-")
+	if err != nil {
+		return InternalError("cannot create temporary file: %v", err)
+	}
+	tempPath := tmpf.Name()
+	defer func() {
+		if !changeTriggered {
+			os.Remove(tempPath)
+		}
+	}()
+
+	if _, err := io.Copy(tmpf, compBody); err != nil {
+		tmpf.Close()
+		return InternalError("cannot copy request into temporary file: %v", err)
+	}
+	tmpf.Sync()
+	tmpf.Close()
+
+	ci, err := unsafeReadComponentInfo(tempPath)
+	if err != nil {
+		return BadRequest("cannot read component file: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	tset, err := installComponentPath(st, tempPath, snapstate.ComponentFlags{RemoveComponentPath: true})
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	msg := fmt.Sprintf(msgFmt, ci.FullName(), origPath)
+	chg := newChange(st, action+"-component", msg, []*state.TaskSet{tset}, []string{ci.SnapName})
+	chg.Set("api-data", map[string]string{"snap-name": ci.SnapName, "component-name": ci.Name})
+
+	ensureStateSoon(st)
+
+	// only when the unlock succeeds (as opposed to panicing) is the handoff done
+	// but this is good enough
+	changeTriggered = true
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
+func componentOp(c *Command, r *http.Request, user *auth.UserState) Response {
+	var inst componentInstruction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("cannot decode request body into a component instruction: %v", err)
+	}
+	if inst.Action != "remove" {
+		return BadRequest("unsupported component action: %q", inst.Action)
+	}
+	snapName, compName := snap.SplitSnapComponent(inst.Name)
+	if snapName == "" || compName == "" {
+		return BadRequest("cannot remove component %q: expected <snap>+<component>", inst.Name)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	tset, err := snapstateRemoveComponent(st, snapName, compName)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	msg := fmt.Sprintf(i18n.G("Remove %q component"), inst.Name)
+	chg := newChange(st, "remove-component", msg, []*state.TaskSet{tset}, []string{snapName})
+	chg.Set("api-data", map[string]string{"snap-name": snapName, "component-name": compName})
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
+func iconGet(st *state.State, name string) Response {
+	about, err := localSnapInfo(st, name)
+	if err != nil {
+		if err == errNoSnap {
+			return SnapNotFound(name, err)
+		}
+		return InternalError("%v", err)
+	}
+
+	path := filepath.Clean(snapIcon(about.info))
+	if !strings.HasPrefix(path, dirs.SnapMountDir) {
+		// XXX: how could this happen?
+		return BadRequest("requested icon is not in snap path")
+	}
+
+	return FileResponse(path)
+}
+
+func appIconGet(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	name := vars["name"]
+
+	return iconGet(c.d.overlord.State(), name)
+}
+
+func splitQS(qs string) []string {
+	qsl := strings.Split(qs, ",")
+	split := make([]string, 0, len(qsl))
+	for _, elem := range qsl {
+		elem = strings.TrimSpace(elem)
+		if len(elem) > 0 {
+			split = append(split, elem)
+		}
+	}
+
+	return split
 }
 
 func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -1643,6 +2343,15 @@ type interfaceAction struct {
 	Action string     `json:"action"`
 	Plugs  []plugJSON `json:"plugs,omitempty"`
 	Slots  []slotJSON `json:"slots,omitempty"`
+	// Snap is the snap to operate on for the "auto-connect" and
+	// "forget-connections" actions
+	Snap string `json:"snap,omitempty"`
+	// Attrs override the attributes of the slot for the "connect"
+	// action, when the interface supports it
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+	// DryRun asks for the security rules the "connect" action would add
+	// instead of making the connection
+	DryRun bool `json:"dry-run,omitempty"`
 }
 
 func snapNamesFromConns(conns []interfaces.ConnRef) []string {
@@ -1660,7 +2369,8 @@ func snapNamesFromConns(conns []interfaces.ConnRef) []string {
 }
 
 // changeInterfaces controls the interfaces system.
-// Plugs can be connected to and disconnected from slots.
+// Plugs can be connected to and disconnected from slots, and all the
+// plugs and slots of a snap can be auto-connected as the policy allows.
 // When enableInternalInterfaceActions is true plugs and slots can also be
 // explicitly added and removed.
 func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -1672,6 +2382,12 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	if a.Action == "" {
 		return BadRequest("interface action not specified")
 	}
+	if a.Action == "auto-connect" {
+		return autoConnectSnap(c, &a)
+	}
+	if a.Action == "forget-connections" {
+		return forgetConnections(c, &a)
+	}
 	if !c.d.enableInternalInterfaceActions && a.Action != "connect" && a.Action != "disconnect" {
 		return BadRequest("internal interface actions are disabled")
 	}
@@ -1684,6 +2400,12 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	if len(a.Plugs) == 0 || len(a.Slots) == 0 {
 		return BadRequest("at least one plug and slot is required")
 	}
+	if len(a.Attrs) > 0 && a.Action != "connect" {
+		return BadRequest("attributes can only be given when connecting")
+	}
+	if a.DryRun && a.Action != "connect" {
+		return BadRequest("dry-run is only supported when connecting")
+	}
 
 	var summary string
 	var err error
@@ -1700,10 +2422,22 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 		var connRef interfaces.ConnRef
 		repo := c.d.overlord.InterfaceManager().Repository()
 		connRef, err = repo.ResolveConnect(a.Plugs[0].Snap, a.Plugs[0].Name, a.Slots[0].Snap, a.Slots[0].Name)
+		if err == nil {
+			plug := repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name)
+			slot := repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			err = interfaces.ValidateConnectionAttrs(repo.Interface(plug.Interface), plug, slot, a.Attrs)
+		}
+		if err == nil && a.DryRun {
+			snippets, err := ifacestate.ConnectionSnippets(repo, connRef, a.Attrs)
+			if err != nil {
+				return BadRequest("%v", err)
+			}
+			return SyncResponse(snippets, nil)
+		}
 		if err == nil {
 			var ts *state.TaskSet
 			summary = fmt.Sprintf("Connect %s:%s to %s:%s", connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
-			ts, err = ifacestate.Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			ts, err = ifacestate.ConnectWithAttrs(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name, a.Attrs)
 			tasksets = append(tasksets, ts)
 			affected = snapNamesFromConns([]interfaces.ConnRef{connRef})
 		}
@@ -1736,113 +2470,587 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	return AsyncResponse(nil, &Meta{Change: change.ID()})
 }
 
-func getAssertTypeNames(c *Command, r *http.Request, user *auth.UserState) Response {
-	return SyncResponse(map[string][]string{
-		"types": asserts.TypeNames(),
-	}, nil)
-}
+func autoConnectSnap(c *Command, a *interfaceAction) Response {
+	if a.Snap == "" {
+		return BadRequest("snap name is required to auto-connect")
+	}
+	if len(a.Plugs) != 0 || len(a.Slots) != 0 {
+		return BadRequest("cannot auto-connect specific plugs or slots")
+	}
 
-func doAssert(c *Command, r *http.Request, user *auth.UserState) Response {
-	batch := assertstate.NewBatch()
-	_, err := batch.AddStream(r.Body)
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	ts, err := ifacestate.AutoConnect(st, a.Snap)
 	if err != nil {
-		return BadRequest("cannot decode request body into assertions: %v", err)
+		return BadRequest("%v", err)
 	}
 
-	state := c.d.overlord.State()
-	state.Lock()
-	defer state.Unlock()
+	summary := fmt.Sprintf("Auto-connect plugs and slots of snap %q", a.Snap)
+	change := newChange(st, "auto-connect-snap", summary, []*state.TaskSet{ts}, []string{a.Snap})
 
-	if err := batch.Commit(state); err != nil {
-		return BadRequest("assert failed: %v", err)
-	}
-	// TODO: what more info do we want to return on success?
-	return &resp{
-		Type:   ResponseTypeSync,
-		Status: 200,
-	}
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: change.ID()})
 }
 
-func assertsFindMany(c *Command, r *http.Request, user *auth.UserState) Response {
-	assertTypeName := muxVars(r)["assertType"]
-	assertType := asserts.Type(assertTypeName)
-	if assertType == nil {
-		return BadRequest("invalid assert type: %q", assertTypeName)
+// forgetConnections drops the connections kept after the removal of the
+// snap, or of the snaps it was connected to, so they are not restored.
+func forgetConnections(c *Command, a *interfaceAction) Response {
+	if a.Snap == "" {
+		return BadRequest("snap name is required to forget connections")
 	}
-	headers := map[string]string{}
-	q := r.URL.Query()
-	for k := range q {
-		headers[k] = q.Get(k)
+	if len(a.Plugs) != 0 || len(a.Slots) != 0 {
+		return BadRequest("cannot forget specific plugs or slots")
 	}
 
-	state := c.d.overlord.State()
-	state.Lock()
-	db := assertstate.DB(state)
-	state.Unlock()
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
 
-	assertions, err := db.FindMany(assertType, headers)
-	if asserts.IsNotFound(err) {
-		return AssertResponse(nil, true)
-	} else if err != nil {
-		return InternalError("searching assertions failed: %v", err)
+	n, err := ifacestate.ForgetConnections(st, a.Snap)
+	if err != nil {
+		return InternalError("%v", err)
 	}
-	return AssertResponse(assertions, true)
+	return SyncResponse(map[string]int{"forgotten": n}, nil)
 }
 
-type changeInfo struct {
-	ID      string      `json:"id"`
-	Kind    string      `json:"kind"`
-	Summary string      `json:"summary"`
-	Status  string      `json:"status"`
-	Tasks   []*taskInfo `json:"tasks,omitempty"`
-	Ready   bool        `json:"ready"`
-	Err     string      `json:"err,omitempty"`
+// connectionJSON aids in marshaling connections into JSON.
+type connectionJSON struct {
+	Plug      interfaces.PlugRef `json:"plug"`
+	Slot      interfaces.SlotRef `json:"slot"`
+	Interface string             `json:"interface,omitempty"`
+	Manual    bool               `json:"manual,omitempty"`
+
+	PlugLabel  string            `json:"plug-label,omitempty"`
+	PlugLabels map[string]string `json:"plug-labels,omitempty"`
+	SlotLabel  string            `json:"slot-label,omitempty"`
+	SlotLabels map[string]string `json:"slot-labels,omitempty"`
+	// Category is the category of the plug, or else of the slot.
+	Category string `json:"category,omitempty"`
+}
 
-	SpawnTime time.Time  `json:"spawn-time,omitempty"`
-	ReadyTime *time.Time `json:"ready-time,omitempty"`
+// getConnections lists the established connections, telling the manual
+// ones apart from those made by the auto-connection policy.
+func getConnections(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
 
-	Data map[string]*json.RawMessage `json:"data,omitempty"`
+	connStates, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	repo := c.d.overlord.InterfaceManager().Repository()
+	conns := make([]connectionJSON, 0, len(connStates))
+	for _, cs := range connStates {
+		conn := connectionJSON{
+			Plug:      cs.Ref.PlugRef,
+			Slot:      cs.Ref.SlotRef,
+			Interface: cs.Interface,
+			Manual:    !cs.Auto,
+		}
+		if plug := repo.Plug(cs.Ref.PlugRef.Snap, cs.Ref.PlugRef.Name); plug != nil {
+			conn.PlugLabel = plug.Label
+			conn.PlugLabels = plug.Labels
+			conn.Category = plug.Category
+		}
+		if slot := repo.Slot(cs.Ref.SlotRef.Snap, cs.Ref.SlotRef.Name); slot != nil {
+			conn.SlotLabel = slot.Label
+			conn.SlotLabels = slot.Labels
+			if conn.Category == "" {
+				conn.Category = slot.Category
+			}
+		}
+		conns = append(conns, conn)
+	}
+	return SyncResponse(conns, nil)
 }
 
-type taskInfo struct {
-	ID       string           `json:"id"`
-	Kind     string           `json:"kind"`
-	Summary  string           `json:"summary"`
-	Status   string           `json:"status"`
-	Log      []string         `json:"log,omitempty"`
-	Progress taskInfoProgress `json:"progress"`
+// getConnectionsHistory lists the recorded connection events, oldest
+// first, limited to those of the snap given with the snap parameter.
+func getConnectionsHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapName := r.URL.Query().Get("snap")
 
-	SpawnTime time.Time  `json:"spawn-time,omitempty"`
-	ReadyTime *time.Time `json:"ready-time,omitempty"`
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	events, err := ifacestate.ConnectionHistory(st, snapName)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if events == nil {
+		events = []*ifacestate.ConnectionEvent{}
+	}
+	return SyncResponse(events, nil)
 }
 
-type taskInfoProgress struct {
-	Label string `json:"label"`
-	Done  int    `json:"done"`
-	Total int    `json:"total"`
+// processJSON is a running process of the snap on one side of a
+// connection.
+type processJSON struct {
+	Pid  int    `json:"pid"`
+	Snap string `json:"snap"`
+	// Side is either "plug" or "slot".
+	Side string `json:"side"`
+	// Label is the apparmor label of the process, when it could be read.
+	Label string `json:"label,omitempty"`
 }
 
-func change2changeInfo(chg *state.Change) *changeInfo {
-	status := chg.Status()
-	chgInfo := &changeInfo{
-		ID:      chg.ID(),
-		Kind:    chg.Kind(),
-		Summary: chg.Summary(),
-		Status:  status.String(),
-		Ready:   status.Ready(),
+// connectionActivityJSON is a connection with the processes running
+// under the security profiles it affects, and the recent denials of
+// those profiles.
+type connectionActivityJSON struct {
+	Plug      interfaces.PlugRef `json:"plug"`
+	Slot      interfaces.SlotRef `json:"slot"`
+	Interface string             `json:"interface"`
+	Processes []processJSON      `json:"processes,omitempty"`
+	Denials   []*apparmor.Denial `json:"denials,omitempty"`
+}
 
-		SpawnTime: chg.SpawnTime(),
+// recentDenialsCount is how many kernel log messages are searched for
+// apparmor denials.
+const recentDenialsCount = 1000
+
+var (
+	cgroupPidsOfSnap      = cgroup.PidsOfSnap
+	apparmorProcessLabel  = apparmor.ProcessLabel
+	apparmorRecentDenials = apparmor.RecentDenials
+)
+
+// connectionProcesses returns the processes of the given snap running
+// under one of the given security tags. Processes whose label cannot
+// be read are assumed to be affected.
+func connectionProcesses(snapName, side string, tags []string) ([]processJSON, error) {
+	pids, err := cgroupPidsOfSnap(snapName)
+	if err != nil {
+		return nil, err
 	}
-	readyTime := chg.ReadyTime()
-	if !readyTime.IsZero() {
-		chgInfo.ReadyTime = &readyTime
+	var procs []processJSON
+	for _, pid := range pids {
+		label, err := apparmorProcessLabel(pid)
+		if err == nil && !strutil.ListContains(tags, apparmor.LabelProfile(label)) {
+			continue
+		}
+		procs = append(procs, processJSON{Pid: pid, Snap: snapName, Side: side, Label: label})
 	}
-	if err := chg.Err(); err != nil {
-		chgInfo.Err = err.Error()
+	return procs, nil
+}
+
+// getConnectionsActivity lists the connections, limited to those of
+// the snap given with the snap parameter, with the processes currently
+// running with the access they grant and the recent apparmor denials
+// of their profiles. Going through all the processes can be slow, so
+// this can be asked for as a query job with async=true.
+func getConnectionsActivity(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapName := r.URL.Query().Get("snap")
+	return maybeAsyncQuery(c, r, "connections-activity", func() Response {
+		return connectionsActivity(c, snapName)
+	})
+}
+
+func connectionsActivity(c *Command, snapName string) Response {
+	type connTags struct {
+		conn     connectionActivityJSON
+		plugTags []string
+		slotTags []string
 	}
+	var found []connTags
 
-	tasks := chg.Tasks()
-	taskInfos := make([]*taskInfo, len(tasks))
+	st := c.d.overlord.State()
+	st.Lock()
+	connStates, err := ifacestate.ConnectionStates(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	repo := c.d.overlord.InterfaceManager().Repository()
+	for _, cs := range connStates {
+		plugRef, slotRef := cs.Ref.PlugRef, cs.Ref.SlotRef
+		if snapName != "" && plugRef.Snap != snapName && slotRef.Snap != snapName {
+			continue
+		}
+		ct := connTags{conn: connectionActivityJSON{
+			Plug:      plugRef,
+			Slot:      slotRef,
+			Interface: cs.Interface,
+		}}
+		// the plug or slot is missing if its snap was removed
+		if plug := repo.Plug(plugRef.Snap, plugRef.Name); plug != nil {
+			ct.plugTags = plug.SecurityTags()
+		}
+		if slot := repo.Slot(slotRef.Snap, slotRef.Name); slot != nil {
+			ct.slotTags = slot.SecurityTags()
+		}
+		found = append(found, ct)
+	}
+
+	denials, err := apparmorRecentDenials(recentDenialsCount)
+	if err != nil {
+		// not all systems keep a kernel log
+		logger.Noticef("cannot obtain recent apparmor denials: %v", err)
+	}
+
+	conns := make([]connectionActivityJSON, 0, len(found))
+	for _, ct := range found {
+		conn := ct.conn
+		for _, side := range []struct {
+			snap, name string
+			tags       []string
+		}{
+			{conn.Plug.Snap, "plug", ct.plugTags},
+			{conn.Slot.Snap, "slot", ct.slotTags},
+		} {
+			if len(side.tags) == 0 {
+				continue
+			}
+			procs, err := connectionProcesses(side.snap, side.name, side.tags)
+			if err != nil {
+				return InternalError("cannot list the processes of snap %q: %v", side.snap, err)
+			}
+			conn.Processes = append(conn.Processes, procs...)
+			for _, d := range denials {
+				if strutil.ListContains(side.tags, d.Profile) {
+					conn.Denials = append(conn.Denials, d)
+				}
+			}
+		}
+		conns = append(conns, conn)
+	}
+	return SyncResponse(conns, nil)
+}
+
+// diskUsageJSON is the disk space used by a snap, in bytes.
+type diskUsageJSON struct {
+	Snap string `json:"snap"`
+	// SnapSize is the size of the snap files of all its revisions
+	SnapSize uint64 `json:"snap-size"`
+	// DataSize is the size of its system data, of all its revisions
+	DataSize uint64 `json:"data-size"`
+}
+
+var osutilDirSize = osutil.DirSize
+
+// getDiskUsage reports the disk space used by the snaps, or only the
+// one given with the snap parameter. Going through the data of the
+// snaps can be slow, so this can be asked for as a query job with
+// async=true.
+func getDiskUsage(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapName := r.URL.Query().Get("snap")
+	return maybeAsyncQuery(c, r, "disk-usage", func() Response {
+		return diskUsage(c, snapName)
+	})
+}
+
+func diskUsage(c *Command, snapName string) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	snapStates, err := snapstate.All(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if snapName != "" {
+		snapst, ok := snapStates[snapName]
+		if !ok {
+			return SnapNotFound(snapName, fmt.Errorf("snap %q is not installed", snapName))
+		}
+		snapStates = map[string]*snapstate.SnapState{snapName: snapst}
+	}
+
+	names := make([]string, 0, len(snapStates))
+	for name := range snapStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	usage := make([]diskUsageJSON, 0, len(names))
+	for _, name := range names {
+		snapst := snapStates[name]
+		u := diskUsageJSON{Snap: name}
+		for _, si := range snapst.Sequence {
+			size, err := osutilDirSize(snap.MinimalPlaceInfo(name, si.Revision).MountFile())
+			if err != nil {
+				return InternalError("cannot get the size of snap %q: %v", name, err)
+			}
+			u.SnapSize += size
+		}
+		size, err := osutilDirSize(filepath.Join(dirs.SnapDataDir, name))
+		if err != nil {
+			return InternalError("cannot get the size of the data of snap %q: %v", name, err)
+		}
+		u.DataSize = size
+		usage = append(usage, u)
+	}
+	return SyncResponse(usage, nil)
+}
+
+// connectionsAction is an action performed on many connections at once.
+// The "batch" action performs instead each of the given operations.
+type connectionsAction struct {
+	Action      string                    `json:"action"`
+	Connections []connectionJSON          `json:"connections"`
+	Operations  []connectionOperationJSON `json:"operations"`
+}
+
+// connectionOperationJSON is a connection made or broken by a batch of
+// connections actions.
+type connectionOperationJSON struct {
+	Action string             `json:"action"`
+	Plug   interfaces.PlugRef `json:"plug"`
+	Slot   interfaces.SlotRef `json:"slot"`
+}
+
+// postConnections makes or breaks many connections at once, such as
+// those of a connection profile exported from another device, in a single
+// change setting up the security profiles of each affected snap only once.
+// Connections already made, or not made when disconnecting, are skipped.
+func postConnections(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a connectionsAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a connections action: %v", err)
+	}
+	if a.Action == "batch" {
+		return connectionsBatch(c, a.Operations)
+	}
+	if a.Action != "connect" && a.Action != "disconnect" {
+		return BadRequest("unsupported connections action: %q", a.Action)
+	}
+	if len(a.Connections) == 0 {
+		return BadRequest("at least one connection is required")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	repo := c.d.overlord.InterfaceManager().Repository()
+	connRefs := make([]interfaces.ConnRef, 0, len(a.Connections))
+	for _, conn := range a.Connections {
+		if a.Action == "disconnect" {
+			refs, err := repo.ResolveDisconnect(conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name)
+			if err != nil {
+				return BadRequest("cannot disconnect %s:%s from %s:%s: %v", conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name, err)
+			}
+			connRefs = append(connRefs, refs...)
+			continue
+		}
+		connRef, err := repo.ResolveConnect(conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name)
+		if err != nil {
+			return BadRequest("cannot connect %s:%s to %s:%s: %v", conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name, err)
+		}
+		connRefs = append(connRefs, connRef)
+	}
+
+	var ts *state.TaskSet
+	var err error
+	if a.Action == "disconnect" {
+		ts, err = ifacestate.DisconnectBatch(st, connRefs)
+	} else {
+		ts, err = ifacestate.ConnectBatch(st, connRefs)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	var tasksets []*state.TaskSet
+	n := 0
+	if ts != nil {
+		tasksets = append(tasksets, ts)
+		for _, t := range ts.Tasks() {
+			if t.Kind() == a.Action {
+				n++
+			}
+		}
+	}
+	var summary string
+	if a.Action == "disconnect" {
+		summary = fmt.Sprintf(i18n.NG("Disconnect %d plug", "Disconnect %d plugs", uint32(n)), n)
+	} else {
+		summary = fmt.Sprintf(i18n.NG("Connect %d plug", "Connect %d plugs", uint32(n)), n)
+	}
+	change := newChange(st, a.Action+"-snap", summary, tasksets, snapNamesFromConns(connRefs))
+	if ts == nil {
+		// nothing left to do
+		change.SetStatus(state.DoneStatus)
+	}
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: change.ID()})
+}
+
+// connectionsBatch breaks and makes many connections in a single change,
+// the disconnections coming first, and sets up the security profiles of
+// each affected snap only once at the end.
+func connectionsBatch(c *Command, ops []connectionOperationJSON) Response {
+	if len(ops) == 0 {
+		return BadRequest("at least one operation is required")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	repo := c.d.overlord.InterfaceManager().Repository()
+	var connect, disconnect []interfaces.ConnRef
+	for _, op := range ops {
+		switch op.Action {
+		case "connect":
+			connRef, err := repo.ResolveConnect(op.Plug.Snap, op.Plug.Name, op.Slot.Snap, op.Slot.Name)
+			if err != nil {
+				return BadRequest("cannot connect %s:%s to %s:%s: %v", op.Plug.Snap, op.Plug.Name, op.Slot.Snap, op.Slot.Name, err)
+			}
+			connect = append(connect, connRef)
+		case "disconnect":
+			refs, err := repo.ResolveDisconnect(op.Plug.Snap, op.Plug.Name, op.Slot.Snap, op.Slot.Name)
+			if err != nil {
+				return BadRequest("cannot disconnect %s:%s from %s:%s: %v", op.Plug.Snap, op.Plug.Name, op.Slot.Snap, op.Slot.Name, err)
+			}
+			disconnect = append(disconnect, refs...)
+		default:
+			return BadRequest("unsupported connection operation: %q", op.Action)
+		}
+	}
+
+	ts, err := ifacestate.ConnectionsBatch(st, connect, disconnect)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	var tasksets []*state.TaskSet
+	n := 0
+	if ts != nil {
+		tasksets = append(tasksets, ts)
+		for _, t := range ts.Tasks() {
+			if t.Kind() == "connect" || t.Kind() == "disconnect" {
+				n++
+			}
+		}
+	}
+	summary := fmt.Sprintf(i18n.NG("Change %d connection", "Change %d connections", uint32(n)), n)
+	change := newChange(st, "connections-batch", summary, tasksets, snapNamesFromConns(append(disconnect, connect...)))
+	if ts == nil {
+		// nothing left to do
+		change.SetStatus(state.DoneStatus)
+	}
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: change.ID()})
+}
+
+func getAssertTypeNames(c *Command, r *http.Request, user *auth.UserState) Response {
+	return SyncResponse(map[string][]string{
+		"types": asserts.TypeNames(),
+	}, nil)
+}
+
+func doAssert(c *Command, r *http.Request, user *auth.UserState) Response {
+	batch := assertstate.NewBatch()
+	_, err := batch.AddStream(r.Body)
+	if err != nil {
+		return BadRequest("cannot decode request body into assertions: %v", err)
+	}
+
+	state := c.d.overlord.State()
+	state.Lock()
+	defer state.Unlock()
+
+	if err := batch.Commit(state); err != nil {
+		return BadRequest("assert failed: %v", err)
+	}
+	// TODO: what more info do we want to return on success?
+	return &resp{
+		Type:   ResponseTypeSync,
+		Status: 200,
+	}
+}
+
+func assertsFindMany(c *Command, r *http.Request, user *auth.UserState) Response {
+	assertTypeName := muxVars(r)["assertType"]
+	assertType := asserts.Type(assertTypeName)
+	if assertType == nil {
+		return BadRequest("invalid assert type: %q", assertTypeName)
+	}
+	headers := map[string]string{}
+	q := r.URL.Query()
+	for k := range q {
+		headers[k] = q.Get(k)
+	}
+
+	state := c.d.overlord.State()
+	state.Lock()
+	db := assertstate.DB(state)
+	state.Unlock()
+
+	assertions, err := db.FindMany(assertType, headers)
+	if asserts.IsNotFound(err) {
+		return AssertResponse(nil, true)
+	} else if err != nil {
+		return InternalError("searching assertions failed: %v", err)
+	}
+	return AssertResponse(assertions, true)
+}
+
+type changeInfo struct {
+	ID      string      `json:"id"`
+	Kind    string      `json:"kind"`
+	Summary string      `json:"summary"`
+	Status  string      `json:"status"`
+	Tasks   []*taskInfo `json:"tasks,omitempty"`
+	Ready   bool        `json:"ready"`
+	Paused  bool        `json:"paused,omitempty"`
+	Err     string      `json:"err,omitempty"`
+
+	SpawnTime time.Time  `json:"spawn-time,omitempty"`
+	ReadyTime *time.Time `json:"ready-time,omitempty"`
+
+	Data map[string]*json.RawMessage `json:"data,omitempty"`
+}
+
+type taskInfo struct {
+	ID       string           `json:"id"`
+	Kind     string           `json:"kind"`
+	Summary  string           `json:"summary"`
+	Status   string           `json:"status"`
+	Log      []string         `json:"log,omitempty"`
+	Progress taskInfoProgress `json:"progress"`
+
+	SpawnTime time.Time  `json:"spawn-time,omitempty"`
+	ReadyTime *time.Time `json:"ready-time,omitempty"`
+}
+
+type taskInfoProgress struct {
+	Label string `json:"label"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+func change2changeInfo(chg *state.Change) *changeInfo {
+	status := chg.Status()
+	chgInfo := &changeInfo{
+		ID:      chg.ID(),
+		Kind:    chg.Kind(),
+		Summary: chg.Summary(),
+		Status:  status.String(),
+		Ready:   status.Ready(),
+		Paused:  chg.IsPaused(),
+
+		SpawnTime: chg.SpawnTime(),
+	}
+	readyTime := chg.ReadyTime()
+	if !readyTime.IsZero() {
+		chgInfo.ReadyTime = &readyTime
+	}
+	if err := chg.Err(); err != nil {
+		chgInfo.Err = err.Error()
+	}
+
+	tasks := chg.Tasks()
+	taskInfos := make([]*taskInfo, len(tasks))
 	for j, t := range tasks {
 		label, done, total := t.Progress()
 
@@ -1943,7 +3151,7 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(chgInfos, nil)
 }
 
-func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
+func postChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
 	state.Lock()
@@ -1962,20 +3170,36 @@ func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("cannot decode data from request body: %v", err)
 	}
 
-	if reqData.Action != "abort" {
+	switch reqData.Action {
+	case "abort", "pause", "resume":
+	default:
 		return BadRequest("change action %q is unsupported", reqData.Action)
 	}
 
 	if chg.Status().Ready() {
-		return BadRequest("cannot abort change %s with nothing pending", chID)
+		return BadRequest("cannot %s change %s with nothing pending", reqData.Action, chID)
+	}
+
+	switch reqData.Action {
+	case "abort":
+		// flag the change
+		chg.Abort()
+		// actually ask to proceed with the abort
+		ensureStateSoon(state)
+	case "pause":
+		if chg.IsPaused() {
+			return BadRequest("change %s is already paused", chID)
+		}
+		// tasks already running finish, no new ones are started
+		chg.Pause()
+	case "resume":
+		if !chg.IsPaused() {
+			return BadRequest("cannot resume change %s that is not paused", chID)
+		}
+		chg.Resume()
+		ensureStateSoon(state)
 	}
 
-	// flag the change
-	chg.Abort()
-
-	// actually ask to proceed with the abort
-	ensureStateSoon(state)
-
 	return SyncResponse(change2changeInfo(chg), nil)
 }
 
@@ -2278,6 +3502,17 @@ func convertBuyError(err error) Response {
 
 type debugAction struct {
 	Action string `json:"action"`
+	Params struct {
+		// DryRun is used by cleanup-orphans to only report
+		DryRun bool `json:"dry-run"`
+		// Kind and Duration (in seconds) are used by profile
+		Kind     string `json:"kind"`
+		Duration int    `json:"duration"`
+		// Snap and Plug are used by connectivity, Snap by
+		// interface-spec and declaration-diff
+		Snap string `json:"snap"`
+		Plug string `json:"plug"`
+	} `json:"params"`
 }
 
 func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -2287,6 +3522,11 @@ func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("cannot decode request body into a debug action: %v", err)
 	}
 
+	if a.Action == "profile" {
+		// the state is not locked while profiling
+		return captureProfile(a.Params.Kind, time.Duration(a.Params.Duration)*time.Second)
+	}
+
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
@@ -2303,13 +3543,207 @@ func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return SyncResponse(map[string]interface{}{
 			"base-declaration": string(asserts.Encode(bd)),
 		}, nil)
+	case "startup-timings":
+		return SyncResponse(c.d.overlord.StartupTimings().Spans(), nil)
+	case "cleanup-orphans":
+		snapMgr := c.d.overlord.SnapManager()
+		orphans, err := snapMgr.FindOrphans()
+		if err != nil {
+			return InternalError("cannot find orphaned snap data: %v", err)
+		}
+		if !a.Params.DryRun {
+			if err := snapMgr.RemoveOrphans(orphans); err != nil {
+				return InternalError("cannot remove orphaned snap data: %v", err)
+			}
+		}
+		if orphans == nil {
+			orphans = []*snapstate.Orphan{}
+		}
+		return SyncResponse(orphans, nil)
+	case "connectivity":
+		pc, err := c.d.overlord.InterfaceManager().ExplainConnectivity(a.Params.Snap, a.Params.Plug)
+		if err != nil {
+			return BadRequest("cannot explain connectivity: %v", err)
+		}
+		return SyncResponse(pc, nil)
+	case "interface-spec":
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, a.Params.Snap, &snapst); err == state.ErrNoState {
+			return BadRequest("cannot get interface specifications: %v", &snap.NotInstalledError{Snap: a.Params.Snap})
+		} else if err != nil {
+			return InternalError("cannot get state of snap %q: %v", a.Params.Snap, err)
+		}
+		snippets, err := ifacestate.SnapSnippets(c.d.overlord.InterfaceManager().Repository(), a.Params.Snap)
+		if err != nil {
+			return InternalError("cannot generate the interface specifications of snap %q: %v", a.Params.Snap, err)
+		}
+		return SyncResponse(snippets, nil)
+	case "declaration-diff":
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, a.Params.Snap, &snapst); err == state.ErrNoState {
+			return BadRequest("cannot get snap declaration changes: %v", &snap.NotInstalledError{Snap: a.Params.Snap})
+		} else if err != nil {
+			return InternalError("cannot get state of snap %q: %v", a.Params.Snap, err)
+		}
+		diff, err := c.d.overlord.InterfaceManager().DeclarationDiff(a.Params.Snap)
+		if err != nil {
+			return InternalError("cannot get snap declaration changes of snap %q: %v", a.Params.Snap, err)
+		}
+		if diff == nil {
+			diff = &ifacestate.DeclarationDiff{Snap: a.Params.Snap}
+		}
+		return SyncResponse(diff, nil)
 	default:
 		return BadRequest("unknown debug action: %v", a.Action)
 	}
 }
 
-func postBuy(c *Command, r *http.Request, user *auth.UserState) Response {
-	var opts store.BuyOptions
+const (
+	defaultProfileDuration = 30 * time.Second
+	// the request is blocked while the profile is captured
+	maxProfileDuration = 5 * time.Minute
+)
+
+var (
+	// profiling is set while a profile is captured, as the runtime
+	// does not support capturing several CPU profiles at once
+	profiling int32
+
+	profileSleep = time.Sleep
+)
+
+// captureProfile captures a profile of the daemon of the given kind,
+// saving it under dirs.SnapDebugDir. CPU and blocking profiles cover the
+// given duration, while heap profiles are a snapshot of the live objects.
+func captureProfile(kind string, duration time.Duration) Response {
+	switch kind {
+	case "cpu", "heap", "block":
+	default:
+		return BadRequest("unknown profile kind %q", kind)
+	}
+	if duration == 0 {
+		duration = defaultProfileDuration
+	}
+	if duration < 0 || duration > maxProfileDuration {
+		return BadRequest("profile duration must be positive and at most %v", maxProfileDuration)
+	}
+	if !atomic.CompareAndSwapInt32(&profiling, 0, 1) {
+		return Conflict("cannot capture profile: another profile is being captured")
+	}
+	defer atomic.StoreInt32(&profiling, 0)
+
+	if err := os.MkdirAll(dirs.SnapDebugDir, 0700); err != nil {
+		return InternalError("cannot capture profile: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.pprof", kind, time.Now().UTC().Format("20060102-150405.000000"))
+	path := filepath.Join(dirs.SnapDebugDir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return InternalError("cannot capture profile: %v", err)
+	}
+
+	switch kind {
+	case "cpu":
+		err = pprof.StartCPUProfile(f)
+		if err == nil {
+			profileSleep(duration)
+			pprof.StopCPUProfile()
+		}
+	case "block":
+		runtime.SetBlockProfileRate(1)
+		profileSleep(duration)
+		runtime.SetBlockProfileRate(0)
+		err = pprof.Lookup("block").WriteTo(f, 0)
+	case "heap":
+		// get up to date statistics
+		runtime.GC()
+		err = pprof.Lookup("heap").WriteTo(f, 0)
+	}
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(path)
+		return InternalError("cannot capture profile: %v", err)
+	}
+
+	return SyncResponse(map[string]interface{}{
+		"name": name,
+		"kind": kind,
+	}, nil)
+}
+
+func getDebugProfile(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+	if name != filepath.Base(name) || !strings.HasSuffix(name, ".pprof") {
+		return BadRequest("invalid profile name %q", name)
+	}
+	path := filepath.Join(dirs.SnapDebugDir, name)
+	if !osutil.FileExists(path) {
+		return NotFound("cannot find profile %q", name)
+	}
+	return FileResponse(path)
+}
+
+type consoleConfAction struct {
+	Action string `json:"action"`
+}
+
+func getConsoleConf(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	status, err := devicestate.ConsoleConfStatus(st)
+	if err != nil {
+		return BadRequest("cannot get console-conf status: %v", err)
+	}
+
+	return SyncResponse(map[string]interface{}{
+		"status": status,
+	}, nil)
+}
+
+func postConsoleConf(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a consoleConfAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a console-conf action: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var err error
+	switch a.Action {
+	case "start":
+		err = devicestate.ConsoleConfStart(st)
+	case "stop":
+		err = devicestate.ConsoleConfStop(st)
+	case "disable":
+		err = devicestate.DisableConsoleConf(st)
+	default:
+		return BadRequest("unknown console-conf action: %v", a.Action)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	status, err := devicestate.ConsoleConfStatus(st)
+	if err != nil {
+		return InternalError("cannot get console-conf status: %v", err)
+	}
+
+	return SyncResponse(map[string]interface{}{
+		"status": status,
+	}, nil)
+}
+
+func postBuy(c *Command, r *http.Request, user *auth.UserState) Response {
+	var opts store.BuyOptions
 
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&opts)
@@ -2688,3 +4122,705 @@ func postApps(c *Command, r *http.Request, user *auth.UserState) Response {
 	st.EnsureBefore(0)
 	return AsyncResponse(nil, &Meta{Change: chg.ID()})
 }
+
+// getSecrets lists the names of the stored secrets, never their values.
+func getSecrets(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	names, err := secretstate.Names(st)
+	if err != nil {
+		return InternalError("cannot list secrets: %v", err)
+	}
+	return SyncResponse(names, nil)
+}
+
+// secretAction is an action performed on a secret
+type secretAction struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+}
+
+func postSecrets(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a secretAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a secret action: %v", err)
+	}
+	if err := secretstate.ValidateName(a.Name); err != nil {
+		return BadRequest("%v", err)
+	}
+
+	st := c.d.overlord.State()
+	var err error
+	switch a.Action {
+	case "set":
+		// the backend may run a command, so not with the state locked
+		err = secretstate.Set(st, a.Name, []byte(a.Value))
+	case "remove":
+		st.Lock()
+		err = secretstate.Remove(st, a.Name)
+		st.Unlock()
+		if _, ok := err.(*secretstate.ErrNotFound); ok {
+			return NotFound("%v", err)
+		}
+	default:
+		return BadRequest("unsupported secret action: %q", a.Action)
+	}
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	return SyncResponse(nil, nil)
+}
+
+// promptingUID returns the user prompting requests are about, who is
+// the one making the request.
+func promptingUID(r *http.Request) (uint32, Response) {
+	_, uid, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return 0, Forbidden("cannot determine the user of the request")
+	}
+	return uid, nil
+}
+
+func getPromptRequests(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptingUID(r)
+	if rsp != nil {
+		return rsp
+	}
+	return SyncResponse(c.d.overlord.PromptManager().Requests(uid), nil)
+}
+
+func postPromptRequest(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptingUID(r)
+	if rsp != nil {
+		return rsp
+	}
+	var reply promptstate.Reply
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&reply); err != nil {
+		return BadRequest("cannot decode request body into a prompt reply: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	rule, err := c.d.overlord.PromptManager().Reply(uid, muxVars(r)["id"], &reply)
+	if _, ok := err.(*promptstate.ErrNotFound); ok {
+		return NotFound("%v", err)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	return SyncResponse(rule, nil)
+}
+
+func getPromptRules(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptingUID(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	rules, err := promptstate.Rules(st, uid, r.URL.Query().Get("snap"))
+	if err != nil {
+		return InternalError("cannot list prompt rules: %v", err)
+	}
+	return SyncResponse(rules, nil)
+}
+
+// promptRuleAction is an action performed on a prompt rule
+type promptRuleAction struct {
+	Action string `json:"action"`
+	ID     string `json:"id"`
+}
+
+func postPromptRules(c *Command, r *http.Request, user *auth.UserState) Response {
+	uid, rsp := promptingUID(r)
+	if rsp != nil {
+		return rsp
+	}
+	var a promptRuleAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a prompt rule action: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	switch a.Action {
+	case "remove":
+		err := promptstate.RemoveRule(st, uid, a.ID)
+		if _, ok := err.(*promptstate.ErrNotFound); ok {
+			return NotFound("%v", err)
+		}
+		if err != nil {
+			return InternalError("%v", err)
+		}
+	default:
+		return BadRequest("unsupported prompt rule action: %q", a.Action)
+	}
+	return SyncResponse(nil, nil)
+}
+
+// metricsInfo holds the metrics collected since snapd started.
+type metricsInfo struct {
+	// Tasks has how long running the tasks of each kind took
+	// and how often it failed.
+	Tasks []metrics.Summary `json:"tasks"`
+}
+
+func getMetrics(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	return SyncResponse(&metricsInfo{
+		Tasks: st.TaskMetrics().Summaries(),
+	}, nil)
+}
+
+// snapOwner is the snap that owns a path or a running process.
+type snapOwner struct {
+	Snap     string        `json:"snap"`
+	Revision snap.Revision `json:"revision"`
+	// App is the app of the snap the process belongs to, when known
+	App string `json:"app,omitempty"`
+}
+
+// pathOwner returns the name and revision, as found in the path, of
+// the snap whose mount directory holds the given path. The revision
+// is unset if the path does not go past the snap name.
+func pathOwner(path string) (name, rev string) {
+	rel, err := filepath.Rel(dirs.SnapMountDir, filepath.Clean(path))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", ""
+	}
+	parts := strings.SplitN(rel, "/", 3)
+	if len(parts) > 1 {
+		rev = parts[1]
+	}
+	return parts[0], rev
+}
+
+// pidOwner returns the name of the snap, and of the app if known, the
+// process with the given pid is running as, going by its cgroups.
+// Apps are in the snap.<snap> freezer cgroup, or group of the unified
+// hierarchy, services are run in a snap.<snap>.<app>.service unit.
+func pidOwner(pid int) (name, app string, err error) {
+	f, err := os.Open(filepath.Join(dirs.GlobalRootDir, "/proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, group := range strings.Split(fields[2], "/") {
+			if !strings.HasPrefix(group, "snap.") {
+				continue
+			}
+			parts := strings.Split(group, ".")
+			switch {
+			case len(parts) == 2:
+				name = parts[1]
+			case len(parts) == 4 && parts[3] == "service":
+				return parts[1], parts[2], nil
+			}
+		}
+	}
+	return name, "", scanner.Err()
+}
+
+func getRoutineOwner(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	path := query.Get("path")
+	pidStr := query.Get("pid")
+	if (path == "") == (pidStr == "") {
+		return BadRequest("exactly one of path or pid must be given")
+	}
+
+	var owner snapOwner
+	var rev string
+	if path != "" {
+		if !filepath.IsAbs(path) {
+			return BadRequest("cannot find owner of path %q: not an absolute path", path)
+		}
+		owner.Snap, rev = pathOwner(path)
+		if owner.Snap == "" {
+			return NotFound("no snap owns path %q", path)
+		}
+	} else {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid <= 0 {
+			return BadRequest("invalid pid %q", pidStr)
+		}
+		owner.Snap, owner.App, err = pidOwner(pid)
+		if os.IsNotExist(err) {
+			return NotFound("no process with pid %d", pid)
+		}
+		if err != nil {
+			return InternalError("cannot find owner of process %d: %v", pid, err)
+		}
+		if owner.Snap == "" {
+			return NotFound("process %d is not running as part of a snap", pid)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, owner.Snap, &snapst)
+	if err == state.ErrNoState {
+		if path != "" {
+			return NotFound("no snap owns path %q", path)
+		}
+		return NotFound("process %s is not running as part of an installed snap", pidStr)
+	}
+	if err != nil {
+		return InternalError("cannot find owner: %v", err)
+	}
+
+	// processes are taken to run the current revision
+	owner.Revision = snapst.Current
+	if rev != "" && rev != "current" {
+		owner.Revision, err = snap.ParseRevision(rev)
+		if err != nil || snapst.LastIndex(owner.Revision) < 0 {
+			return NotFound("no snap owns path %q", path)
+		}
+	}
+
+	return SyncResponse(&owner, nil)
+}
+
+// changePlan describes what a snap operation would do, without doing it.
+type changePlan struct {
+	Kind    string         `json:"kind"`
+	Summary string         `json:"summary"`
+	Snaps   []string       `json:"snaps"`
+	Tasks   []*plannedTask `json:"tasks"`
+	// DownloadSize is the total of the sizes of the snaps to download.
+	DownloadSize int64 `json:"download-size"`
+	// Restart is "daemon" or "system" if the operation would restart
+	// snapd or the system.
+	Restart string `json:"restart,omitempty"`
+	// Services are the services the operation would stop or restart.
+	Services []string `json:"services,omitempty"`
+}
+
+type plannedTask struct {
+	Kind    string `json:"kind"`
+	Summary string `json:"summary"`
+	Snap    string `json:"snap,omitempty"`
+	Lanes   []int  `json:"lanes,omitempty"`
+	// WaitFor holds the indices in the plan of the tasks this one
+	// waits for.
+	WaitFor []int `json:"wait-for,omitempty"`
+}
+
+func postPlan(c *Command, r *http.Request, user *auth.UserState) Response {
+	decoder := json.NewDecoder(r.Body)
+	var inst snapInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("cannot decode request body into snap instruction: %v", err)
+	}
+	// the tracked branch expiry policy is recorded right away and
+	// doesn't change what a refresh does, so there's nothing to plan
+	inst.BranchExpiry = ""
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if user != nil {
+		inst.userID = user.ID
+	}
+
+	var msg string
+	var affected []string
+	var tsets []*state.TaskSet
+	if len(inst.Snaps) == 1 {
+		if err := verifySnapInstructions(&inst); err != nil {
+			return BadRequest("%s", err)
+		}
+		impl := inst.dispatch()
+		if impl == nil {
+			return BadRequest("unknown action %s", inst.Action)
+		}
+		var err error
+		msg, tsets, err = impl(&inst, st)
+		if err != nil {
+			return inst.errToResponse(err)
+		}
+		affected = inst.Snaps
+	} else {
+		if inst.Channel != "" || !inst.Revision.Unset() || inst.DevMode || inst.JailMode {
+			return BadRequest("unsupported option provided for multi-snap operation")
+		}
+		impl := inst.dispatchForMany()
+		if impl == nil {
+			return BadRequest("unsupported multi-snap operation %q", inst.Action)
+		}
+		var err error
+		msg, affected, tsets, err = impl(&inst, st)
+		if err != nil {
+			return InternalError("cannot %s %q: %v", inst.Action, inst.Snaps, err)
+		}
+	}
+
+	plan := planChange(st, inst.Action+"-snap", msg, affected, tsets)
+
+	return SyncResponse(plan, nil)
+}
+
+// planChange describes the change the given task sets would make up,
+// and then discards their tasks.
+func planChange(st *state.State, kind, summary string, snapNames []string, tsets []*state.TaskSet) *changePlan {
+	var tasks []*state.Task
+	for _, ts := range tsets {
+		tasks = append(tasks, ts.Tasks()...)
+	}
+	defer st.DiscardTasks(tasks)
+
+	plan := &changePlan{
+		Kind:    kind,
+		Summary: summary,
+		Snaps:   snapNames,
+		Tasks:   make([]*plannedTask, len(tasks)),
+	}
+	if plan.Snaps == nil {
+		plan.Snaps = []string{}
+	}
+
+	index := make(map[string]int, len(tasks))
+	for i, t := range tasks {
+		index[t.ID()] = i
+	}
+	for i, t := range tasks {
+		pt := &plannedTask{
+			Kind:    t.Kind(),
+			Summary: t.Summary(),
+			Lanes:   t.Lanes(),
+		}
+		for _, wt := range t.WaitTasks() {
+			if j, ok := index[wt.ID()]; ok {
+				pt.WaitFor = append(pt.WaitFor, j)
+			}
+		}
+		plan.Tasks[i] = pt
+
+		// the tasks aren't linked to a change, so the task holding
+		// the snap setup can't be looked up through the state
+		var snapsup snapstate.SnapSetup
+		setupTask := t
+		var id string
+		if t.Get("snap-setup-task", &id) == nil {
+			if j, ok := index[id]; ok {
+				setupTask = tasks[j]
+			}
+		}
+		if setupTask.Get("snap-setup", &snapsup) != nil {
+			continue
+		}
+		pt.Snap = snapsup.Name()
+		switch t.Kind() {
+		case "download-snap":
+			if snapsup.DownloadInfo != nil {
+				plan.DownloadSize += snapsup.DownloadInfo.Size
+			}
+		case "stop-snap-services":
+			info, err := snapstate.CurrentInfo(st, pt.Snap)
+			if err != nil {
+				continue
+			}
+			for _, app := range info.Services() {
+				plan.Services = append(plan.Services, app.Snap.Name()+"."+app.Name)
+			}
+		case "link-snap":
+			info, err := snapstate.CurrentInfo(st, pt.Snap)
+			if err != nil {
+				// new snaps are not expected to need a restart
+				continue
+			}
+			switch {
+			case !release.OnClassic && (info.Type == snap.TypeOS || info.Type == snap.TypeKernel):
+				plan.Restart = "system"
+			case release.OnClassic && info.Type == snap.TypeOS && plan.Restart == "":
+				plan.Restart = "daemon"
+			}
+		}
+	}
+	sort.Strings(plan.Services)
+
+	return plan
+}
+
+// deviceManifest describes the snaps, connections and configuration a
+// device should have.
+type deviceManifest struct {
+	Snaps       map[string]*snapInstructionOptions `json:"snaps"`
+	Connections []connectionJSON                   `json:"connections"`
+	Config      map[string]map[string]interface{}  `json:"config"`
+}
+
+type manifestAction struct {
+	Manifest deviceManifest `json:"manifest"`
+	DryRun   bool           `json:"dry-run"`
+}
+
+// manifestPlan is what applying a manifest would do.
+type manifestPlan struct {
+	*changePlan
+	// DeferredConnections are the connections of the manifest involving
+	// snaps it installs, which can only be made by applying it again
+	// once they are installed.
+	DeferredConnections []connectionJSON `json:"deferred-connections,omitempty"`
+}
+
+// postManifest brings the device to the state described by a manifest,
+// installing or refreshing snaps, making connections and setting
+// configuration as needed, all in one change. With dry-run it instead
+// returns the plan of that change.
+func postManifest(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a manifestAction
+	if err := jsonutil.DecodeWithNumber(r.Body, &a); err != nil {
+		return BadRequest("cannot decode request body into a manifest action: %v", err)
+	}
+	m := &a.Manifest
+	for name := range m.Snaps {
+		if err := snap.ValidateName(name); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
+	for name := range m.Config {
+		if err := snap.ValidateName(name); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var userID int
+	if user != nil {
+		userID = user.ID
+	}
+
+	tsets, affected, deferred, err := manifestTaskSets(st, c.d.overlord.InterfaceManager().Repository(), m, userID)
+	if err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf(i18n.NG("Apply manifest to %d snap", "Apply manifest to %d snaps", uint32(len(affected))), len(affected))
+	if a.DryRun {
+		return SyncResponse(&manifestPlan{
+			changePlan:          planChange(st, "apply-manifest", summary, affected, tsets),
+			DeferredConnections: deferred,
+		}, nil)
+	}
+
+	chg := newChange(st, "apply-manifest", summary, tsets, affected)
+	if len(deferred) > 0 {
+		chg.Set("api-data", map[string]interface{}{"deferred-connections": deferred})
+	}
+	if len(tsets) == 0 {
+		// the device matches the manifest already
+		chg.SetStatus(state.DoneStatus)
+	}
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
+// manifestTaskSets returns the task sets bringing the device to the state
+// described by the manifest, the snaps they affect and the connections
+// that can't be made yet.
+func manifestTaskSets(st *state.State, repo *interfaces.Repository, m *deviceManifest, userID int) ([]*state.TaskSet, []string, []connectionJSON, Response) {
+	names := make([]string, 0, len(m.Snaps))
+	for name := range m.Snaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var toInstall, toUpdate []string
+	for _, name := range names {
+		want := m.Snaps[name]
+		if want == nil {
+			want = &snapInstructionOptions{}
+			m.Snaps[name] = want
+		}
+		var snapst snapstate.SnapState
+		err := snapstate.Get(st, name, &snapst)
+		if err == state.ErrNoState {
+			toInstall = append(toInstall, name)
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, InternalError("%v", err)
+		}
+		if (want.Channel != "" && want.Channel != snapst.Channel) || (!want.Revision.Unset() && want.Revision != snapst.Current) {
+			toUpdate = append(toUpdate, name)
+		}
+	}
+
+	var snapTasksets []*state.TaskSet
+	var affected []string
+	if len(toInstall) > 0 {
+		inst := &snapInstruction{Action: "install", Snaps: toInstall, userID: userID}
+		inst.SnapOptions = manifestSnapOptions(m.Snaps, toInstall)
+		opts, err := inst.manyOptions()
+		if err != nil {
+			return nil, nil, nil, BadRequest("%v", err)
+		}
+		installed, tsets, err := snapstateInstallMany(st, toInstall, userID, opts)
+		if err != nil {
+			return nil, nil, nil, inst.errToResponse(err)
+		}
+		affected = append(affected, installed...)
+		snapTasksets = append(snapTasksets, tsets...)
+	}
+	// updating no snaps at all would mean updating all of them
+	if len(toUpdate) > 0 {
+		inst := &snapInstruction{Action: "refresh", Snaps: toUpdate, userID: userID}
+		inst.SnapOptions = manifestSnapOptions(m.Snaps, toUpdate)
+		opts, err := inst.manyOptions()
+		if err != nil {
+			return nil, nil, nil, BadRequest("%v", err)
+		}
+		updated, tsets, err := snapstateUpdateMany(st, toUpdate, userID, opts)
+		if err != nil {
+			return nil, nil, nil, inst.errToResponse(err)
+		}
+		affected = append(affected, updated...)
+		snapTasksets = append(snapTasksets, tsets...)
+	}
+
+	// connections and configuration are only changed once the snaps
+	// are in place
+	var tasksets []*state.TaskSet
+	addTaskSets := func(tss ...*state.TaskSet) {
+		for _, ts := range tss {
+			for _, sts := range snapTasksets {
+				ts.WaitAll(sts)
+			}
+			tasksets = append(tasksets, ts)
+		}
+	}
+
+	var deferred []connectionJSON
+	var connRefs []interfaces.ConnRef
+	for _, conn := range m.Connections {
+		if strutil.ListContains(toInstall, conn.Plug.Snap) || strutil.ListContains(toInstall, conn.Slot.Snap) {
+			deferred = append(deferred, conn)
+			continue
+		}
+		connRef, err := repo.ResolveConnect(conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name)
+		if err != nil {
+			return nil, nil, nil, BadRequest("cannot connect %s:%s to %s:%s: %v", conn.Plug.Snap, conn.Plug.Name, conn.Slot.Snap, conn.Slot.Name, err)
+		}
+		connRefs = append(connRefs, connRef)
+	}
+	connTs, err := ifacestate.ConnectBatch(st, connRefs)
+	if err != nil {
+		return nil, nil, nil, BadRequest("%v", err)
+	}
+	if connTs != nil {
+		addTaskSets(connTs)
+		affected = append(affected, snapNamesFromConns(connRefs)...)
+	}
+
+	configNames := make([]string, 0, len(m.Config))
+	for name := range m.Config {
+		configNames = append(configNames, name)
+	}
+	sort.Strings(configNames)
+	tr := config.NewTransaction(st)
+	for _, name := range configNames {
+		installing := strutil.ListContains(toInstall, name)
+		if !installing {
+			var snapst snapstate.SnapState
+			if err := snapstate.Get(st, name, &snapst); err != nil {
+				if err == state.ErrNoState {
+					return nil, nil, nil, SnapNotFound(name, err)
+				}
+				return nil, nil, nil, InternalError("%v", err)
+			}
+		}
+		patch := make(map[string]interface{})
+		for key, value := range m.Config[name] {
+			if !installing {
+				same, err := sameConfigValue(tr, name, key, value)
+				if err != nil {
+					return nil, nil, nil, BadRequest("%v", err)
+				}
+				if same {
+					continue
+				}
+			}
+			patch[key] = value
+		}
+		if len(patch) == 0 {
+			continue
+		}
+		addTaskSets(configstate.Configure(st, name, patch, 0))
+		affected = append(affected, name)
+	}
+
+	seen := make(map[string]bool, len(affected))
+	snapNames := make([]string, 0, len(affected))
+	for _, name := range affected {
+		if !seen[name] {
+			seen[name] = true
+			snapNames = append(snapNames, name)
+		}
+	}
+	sort.Strings(snapNames)
+
+	return append(snapTasksets, tasksets...), snapNames, deferred, nil
+}
+
+// manifestSnapOptions returns the options of the given snaps of a manifest.
+func manifestSnapOptions(snaps map[string]*snapInstructionOptions, names []string) map[string]*snapInstructionOptions {
+	opts := make(map[string]*snapInstructionOptions, len(names))
+	for _, name := range names {
+		opts[name] = snaps[name]
+	}
+	return opts
+}
+
+// sameConfigValue returns whether the option of the snap is set to the
+// given value already.
+func sameConfigValue(tr *config.Transaction, snapName, key string, value interface{}) (bool, error) {
+	var current interface{}
+	if err := tr.Get(snapName, key, &current); err != nil {
+		if config.IsNoOption(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	// values are compared through their JSON form, which doesn't
+	// depend on how numbers were decoded
+	a, err := json.Marshal(current)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
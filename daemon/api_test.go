@@ -51,16 +51,23 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/cgroup"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/promptstate"
+	"github.com/snapcore/snapd/overlord/secretstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
@@ -80,6 +87,8 @@ type apiBaseSuite struct {
 	err               error
 	vars              map[string]string
 	storeSearch       store.Search
+	publisherID       string
+	publisherSnaps    []string
 	suggestedCurrency string
 	d                 *Daemon
 	user              *auth.UserState
@@ -122,6 +131,13 @@ func (s *apiBaseSuite) Find(search *store.Search, user *auth.UserState) ([]*snap
 	return s.rsnaps, s.err
 }
 
+func (s *apiBaseSuite) PublisherSnaps(publisherID string, user *auth.UserState) ([]string, error) {
+	s.publisherID = publisherID
+	s.user = user
+
+	return s.publisherSnaps, nil
+}
+
 func (s *apiBaseSuite) LookupRefresh(snap *store.RefreshCandidate, user *auth.UserState) (*snap.Info, error) {
 	s.refreshCandidates = []*store.RefreshCandidate{snap}
 	s.user = user
@@ -219,6 +235,8 @@ func (s *apiBaseSuite) SetUpTest(c *check.C) {
 	s.rsnaps = nil
 	s.suggestedCurrency = ""
 	s.storeSearch = store.Search{}
+	s.publisherID = ""
+	s.publisherSnaps = nil
 	s.err = nil
 	s.vars = nil
 	s.user = nil
@@ -235,15 +253,22 @@ func (s *apiBaseSuite) SetUpTest(c *check.C) {
 
 	assertstateRefreshSnapDeclarations = nil
 	snapstateInstall = nil
+	snapstateInstallFromStore = nil
 	snapstateInstallMany = nil
 	snapstateInstallPath = nil
+	snapstateInstallFromRegistry = nil
 	snapstateRefreshCandidates = nil
+	snapstatePendingRefreshes = nil
 	snapstateRemoveMany = nil
 	snapstateRevert = nil
 	snapstateRevertToRevision = nil
+	snapstateSetBranchExpiry = nil
 	snapstateTryPath = nil
 	snapstateUpdate = nil
 	snapstateUpdateMany = nil
+	snapstateInstallComponentPath = nil
+	snapstateRefreshComponentPath = nil
+	snapstateRemoveComponent = nil
 }
 
 func (s *apiBaseSuite) TearDownTest(c *check.C) {
@@ -251,20 +276,28 @@ func (s *apiBaseSuite) TearDownTest(c *check.C) {
 	s.d = nil
 	s.restoreBackends()
 	unsafeReadSnapInfo = unsafeReadSnapInfoImpl
+	unsafeReadComponentInfo = unsafeReadComponentInfoImpl
 	ensureStateSoon = ensureStateSoonImpl
 	dirs.SetRootDir("")
 
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
 	snapstateInstall = snapstate.Install
+	snapstateInstallFromStore = snapstate.InstallFromStore
 	snapstateInstallMany = snapstate.InstallMany
 	snapstateInstallPath = snapstate.InstallPath
+	snapstateInstallFromRegistry = snapstate.InstallFromRegistry
 	snapstateRefreshCandidates = snapstate.RefreshCandidates
+	snapstatePendingRefreshes = snapstate.PendingRefreshes
 	snapstateRemoveMany = snapstate.RemoveMany
 	snapstateRevert = snapstate.Revert
 	snapstateRevertToRevision = snapstate.RevertToRevision
+	snapstateSetBranchExpiry = snapstate.SetBranchExpiry
 	snapstateTryPath = snapstate.TryPath
 	snapstateUpdate = snapstate.Update
 	snapstateUpdateMany = snapstate.UpdateMany
+	snapstateInstallComponentPath = snapstate.InstallComponentPath
+	snapstateRefreshComponentPath = snapstate.RefreshComponentPath
+	snapstateRemoveComponent = snapstate.RemoveComponent
 }
 
 func (s *apiBaseSuite) daemon(c *check.C) *Daemon {
@@ -698,7 +731,9 @@ func (s *apiSuite) TestListIncludesAll(c *check.C) {
 		"errClassicDevmodeConflict",
 		// snapInstruction vars:
 		"snapInstructionDispTable",
+		"snapInstructionManyDispTable",
 		"snapstateInstall",
+		"snapstateInstallFromStore",
 		"snapstateUpdate",
 		"snapstateInstallPath",
 		"snapstateTryPath",
@@ -706,16 +741,31 @@ func (s *apiSuite) TestListIncludesAll(c *check.C) {
 		"snapstateInstallMany",
 		"snapstateRemoveMany",
 		"snapstateRefreshCandidates",
+		"snapstatePendingRefreshes",
 		"snapstateRevert",
 		"snapstateRevertToRevision",
 		"snapstateSwitch",
+		"snapstateSetBranchExpiry",
+		"snapstateInstallComponentPath",
+		"snapstateRefreshComponentPath",
+		"snapstateRemoveComponent",
 		"assertstateRefreshSnapDeclarations",
 		"unsafeReadSnapInfo",
+		"unsafeReadComponentInfo",
+		"snapstateInstallFromRegistry",
 		"osutilAddUser",
 		"setupLocalUser",
 		"storeUserInfo",
 		"postCreateUserUcrednetGet",
 		"ensureStateSoon",
+		"defaultProfileDuration",
+		"maxProfileDuration",
+		"profileSleep",
+		"recentDenialsCount",
+		"cgroupPidsOfSnap",
+		"apparmorProcessLabel",
+		"apparmorRecentDenials",
+		"osutilDirSize",
 	}
 	c.Check(found, check.Equals, len(api)+len(exceptions),
 		check.Commentf(`At a glance it looks like you've not added all the Commands defined in api to the api list. If that is not the case, please add the exception to the "exceptions" list in this test.`))
@@ -760,6 +810,8 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 	defer restore()
 	restore = release.MockForcedDevmode(true)
 	defer restore()
+	restore = cgroup.MockVersion(cgroup.V2, nil)
+	defer restore()
 
 	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
 	c.Check(rec.Code, check.Equals, 200)
@@ -781,6 +833,9 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 		"refresh": map[string]interface{}{
 			"schedule": "",
 		},
+		"sandbox-features": map[string]interface{}{
+			"cgroup": []interface{}{"v2", "freezer-v2"},
+		},
 		"confinement": "partial",
 	}
 	var rsp resp
@@ -794,6 +849,45 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
+func (s *apiSuite) TestSeeding(c *check.C) {
+	c.Check(seedingCmd.Path, check.Equals, "/v2/seeding")
+	c.Check(seedingCmd.POST, check.IsNil)
+	c.Assert(seedingCmd.GET, check.NotNil)
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("seeded", false)
+	chg := st.NewChange("seed", "Initialize system state")
+	t := st.NewTask("prerequisites", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "core"}})
+	chg.AddTask(t)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/seeding", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSeeding(seedingCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &devicestate.SeedingStatus{
+		Phase:  "install-essential",
+		Change: chg.ID(),
+		Snaps: []devicestate.SeedingSnap{
+			{Name: "core", Essential: true, Status: "Do", Total: 1},
+		},
+	})
+
+	st.Lock()
+	st.Set("seeded", true)
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	rsp = getSeeding(seedingCmd, req, nil).(*resp)
+	status := rsp.Result.(*devicestate.SeedingStatus)
+	c.Check(status.Seeded, check.Equals, true)
+	c.Check(status.Phase, check.Equals, "done")
+	c.Check(status.Snaps[0].Status, check.Equals, "Done")
+}
+
 func (s *apiSuite) makeMyAppsServer(statusCode int, data string) *httptest.Server {
 	mockMyAppsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(statusCode)
@@ -1435,6 +1529,58 @@ func (s *apiSuite) TestFindRefreshes(c *check.C) {
 	c.Check(s.refreshCandidates, check.HasLen, 1)
 }
 
+func (s *apiSuite) TestSnapsRefreshCandidates(c *check.C) {
+	s.daemon(c)
+
+	var gotUser *auth.UserState
+	snapstatePendingRefreshes = func(st *state.State, user *auth.UserState) ([]*snapstate.PendingRefresh, error) {
+		gotUser = user
+		return []*snapstate.PendingRefresh{{
+			Name:    "foo",
+			Current: snap.R(7),
+			Channel: "stable",
+			Candidate: &snap.Info{
+				SideInfo:     snap.SideInfo{RealName: "foo", Revision: snap.R(11)},
+				Version:      "1.1",
+				DownloadInfo: snap.DownloadInfo{Size: 4096},
+			},
+			Held: []snapstate.RefreshHold{{Reason: snapstate.RefreshHeldByDevMode, Message: "snaps in devmode are not refreshed automatically"}},
+		}}, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snaps?select=refresh-candidates", nil)
+	c.Assert(err, check.IsNil)
+
+	user := &auth.UserState{ID: 1}
+	rsp := getSnapsInfo(snapsCmd, req, user).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(gotUser, check.Equals, user)
+	c.Check(rsp.Result, check.DeepEquals, []*client.RefreshCandidate{{
+		Name:            "foo",
+		CurrentRevision: snap.R(7),
+		Revision:        snap.R(11),
+		Version:         "1.1",
+		Channel:         "stable",
+		DownloadSize:    4096,
+		Held:            []client.RefreshHold{{Reason: "devmode", Message: "snaps in devmode are not refreshed automatically"}},
+	}})
+}
+
+func (s *apiSuite) TestSnapsRefreshCandidatesError(c *check.C) {
+	s.daemon(c)
+
+	snapstatePendingRefreshes = func(*state.State, *auth.UserState) ([]*snapstate.PendingRefresh, error) {
+		return nil, errors.New("boom")
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snaps?select=refresh-candidates", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSnapsInfo(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, "cannot list refresh candidates: boom")
+}
+
 func (s *apiSuite) TestFindRefreshSideloaded(c *check.C) {
 	snapstateRefreshCandidates = snapstate.RefreshCandidates
 	s.daemon(c)
@@ -1547,6 +1693,36 @@ func (s *apiSuite) TestFindOne(c *check.C) {
 	m := snaps[0]["channels"].(map[string]interface{})["stable"].(map[string]interface{})
 
 	c.Check(m["revision"], check.Equals, "42")
+	c.Check(s.publisherID, check.Equals, "")
+	c.Check(snaps[0]["publisher-snaps"], check.IsNil)
+}
+
+func (s *apiSuite) TestFindOnePublisherDetails(c *check.C) {
+	s.daemon(c)
+
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			RealName: "store",
+		},
+		PublisherID:         "foo-id",
+		Publisher:           "foo",
+		PublisherValidation: "verified",
+		PublisherContact:    "foo@example.com",
+	}}
+	s.publisherSnaps = []string{"other", "store", "yet-another"}
+
+	req, err := http.NewRequest("GET", "/v2/find?name=store", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+
+	c.Check(s.publisherID, check.Equals, "foo-id")
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["developer"], check.Equals, "foo")
+	c.Check(snaps[0]["publisher-validation"], check.Equals, "verified")
+	c.Check(snaps[0]["publisher-contact"], check.Equals, "foo@example.com")
+	c.Check(snaps[0]["publisher-snaps"], check.DeepEquals, []interface{}{"other", "yet-another"})
 }
 
 func (s *apiSuite) TestFindOneNotFound(c *check.C) {
@@ -2040,6 +2216,27 @@ func (s *apiSuite) TestSideloadSnapDevMode(c *check.C) {
 	c.Check(chgSummary, check.Equals, `Install "local" snap from file "x"`)
 }
 
+func (s *apiSuite) TestSideloadSnapForeignArch(c *check.C) {
+	body := "" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"snap\"; filename=\"x\"\r\n" +
+		"\r\n" +
+		"xyzzy\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"foreign-arch\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"dangerous\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"----hello--\r\n"
+	head := map[string]string{"Content-Type": "multipart/thing; boundary=--hello--"}
+	flags := snapstate.Flags{ForeignArch: true, RemoveSnapPath: true}
+	chgSummary := s.sideloadCheck(c, body, head, flags)
+	c.Check(chgSummary, check.Equals, `Install "local" snap from file "x"`)
+}
+
 func (s *apiSuite) TestSideloadSnapJailMode(c *check.C) {
 	body := "" +
 		"----hello--\r\n" +
@@ -2184,6 +2381,65 @@ func (s *apiSuite) TestLocalInstallSnapDeriveSideInfo(c *check.C) {
 	})
 }
 
+func (s *apiSuite) TestInstallFromRegistry(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	st := d.overlord.State()
+
+	snapstateInstallFromRegistry = func(s *state.State, name, source, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Check(name, check.Equals, "x")
+		c.Check(source, check.Equals, "oci://registry.example.com/acme/x:latest")
+		c.Check(channel, check.Equals, "beta")
+		c.Check(flags, check.Equals, snapstate.Flags{})
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	s.vars = map[string]string{"name": "x"}
+	buf := bytes.NewBufferString(`{"action": "install", "channel": "beta", "source": "oci://registry.example.com/acme/x"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/x", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Install "x" snap from "oci://registry.example.com/acme/x:latest"`)
+	c.Check(chg.Tasks(), check.HasLen, 1)
+}
+
+func (s *apiSuite) TestInstallFromRegistryErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	snapstateInstallFromRegistry = func(s *state.State, name, source, channel string, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		return nil, &snap.AlreadyInstalledError{Snap: name}
+	}
+
+	s.vars = map[string]string{"name": "x"}
+	for _, t := range []struct {
+		action string
+		source string
+		extra  string
+		err    string
+	}{
+		{"install", "oci://registry.example.com/acme/x:1.0", "", `snap "x" is already installed`},
+		{"install", "https://example.com/x.snap", "", `unsupported snap source "https://example.com/x.snap"`},
+		{"install", "oci://x", "", `cannot parse OCI reference "oci://x": missing registry`},
+		{"install", "oci://registry.example.com/acme/x:1.0", `, "revision": "2"`, `cannot specify a revision when installing from "oci://registry.example.com/acme/x:1.0"`},
+		{"refresh", "oci://registry.example.com/acme/x:1.0", "", `snap source can only be specified when installing`},
+	} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": %q, "source": %q%s}`, t.action, t.source, t.extra))
+		req, err := http.NewRequest("POST", "/v2/snaps/x", buf)
+		c.Assert(err, check.IsNil)
+
+		rsp := postSnap(snapCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
+}
+
 func (s *apiSuite) TestSideloadSnapNoSignaturesDangerOff(c *check.C) {
 	body := "" +
 		"----hello--\r\n" +
@@ -2444,6 +2700,168 @@ func (s *apiSuite) sideloadCheck(c *check.C, content string, head map[string]str
 	return chg.Summary()
 }
 
+func (s *apiSuite) sideloadComponentCheck(c *check.C, action string) *state.Change {
+	d := s.daemonWithOverlordMock(c)
+
+	unsafeReadComponentInfo = func(path string) (*snap.ComponentInfo, error) {
+		return &snap.ComponentInfo{SnapName: "local", Name: "debug"}, nil
+	}
+	var installed []string
+	fakeInstallComponentPath := func(s *state.State, path string, flags snapstate.ComponentFlags) (*state.TaskSet, error) {
+		c.Check(flags, check.Equals, snapstate.ComponentFlags{RemoveComponentPath: true})
+
+		bs, err := ioutil.ReadFile(path)
+		c.Check(err, check.IsNil)
+		c.Check(string(bs), check.Equals, "xyzzy")
+
+		installed = append(installed, path)
+		return state.NewTaskSet(s.NewTask("fake-install-component", "Doing a fake install")), nil
+	}
+	snapstateInstallComponentPath = func(s *state.State, path string, flags snapstate.ComponentFlags) (*state.TaskSet, error) {
+		c.Check(action, check.Equals, "install")
+		return fakeInstallComponentPath(s, path, flags)
+	}
+	snapstateRefreshComponentPath = func(s *state.State, path string, flags snapstate.ComponentFlags) (*state.TaskSet, error) {
+		c.Check(action, check.Equals, "refresh")
+		return fakeInstallComponentPath(s, path, flags)
+	}
+
+	body := "" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"action\"\r\n" +
+		"\r\n" +
+		action + "\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"dangerous\"\r\n" +
+		"\r\n" +
+		"true\r\n" +
+		"----hello--\r\n" +
+		"Content-Disposition: form-data; name=\"component\"; filename=\"local+debug.comp\"\r\n" +
+		"\r\n" +
+		"xyzzy\r\n" +
+		"----hello--\r\n"
+	req, err := http.NewRequest("POST", "/v2/components", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+
+	rsp := postComponents(componentsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Assert(installed, check.HasLen, 1)
+	c.Check(installed[0], check.Matches, ".*/snapd-sideload-comp-.*")
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"local"})
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"snap-name":      "local",
+		"component-name": "debug",
+	})
+	return chg
+}
+
+func (s *apiSuite) TestSideloadComponent(c *check.C) {
+	chg := s.sideloadComponentCheck(c, "install")
+	c.Check(chg.Kind(), check.Equals, "install-component")
+	c.Check(chg.Summary(), check.Equals, `Install "local+debug" component from file "local+debug.comp"`)
+}
+
+func (s *apiSuite) TestSideloadComponentRefresh(c *check.C) {
+	chg := s.sideloadComponentCheck(c, "refresh")
+	c.Check(chg.Kind(), check.Equals, "refresh-component")
+	c.Check(chg.Summary(), check.Equals, `Refresh "local+debug" component from file "local+debug.comp"`)
+}
+
+func (s *apiSuite) TestSideloadComponentErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, t := range []struct {
+		action, dangerous, file, err string
+	}{
+		{"frobnicate", "true", "component", `unsupported component action: "frobnicate"`},
+		{"install", "false", "component", `cannot install component without --dangerous: .*`},
+		{"install", "true", "snap", `cannot find "component" file field in provided multipart/form-data payload`},
+	} {
+		body := "" +
+			"----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"action\"\r\n" +
+			"\r\n" +
+			t.action + "\r\n" +
+			"----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"dangerous\"\r\n" +
+			"\r\n" +
+			t.dangerous + "\r\n" +
+			"----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"" + t.file + "\"; filename=\"local+debug.comp\"\r\n" +
+			"\r\n" +
+			"xyzzy\r\n" +
+			"----hello--\r\n"
+		req, err := http.NewRequest("POST", "/v2/components", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+
+		rsp := postComponents(componentsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
+
+func (s *apiSuite) TestRemoveComponent(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+
+	snapstateRemoveComponent = func(s *state.State, snapName, compName string) (*state.TaskSet, error) {
+		c.Check(snapName, check.Equals, "local")
+		c.Check(compName, check.Equals, "debug")
+		return state.NewTaskSet(s.NewTask("fake-remove-component", "Doing a fake remove")), nil
+	}
+
+	req, err := http.NewRequest("POST", "/v2/components", strings.NewReader(`{"action": "remove", "name": "local+debug"}`))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postComponents(componentsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "remove-component")
+	c.Check(chg.Summary(), check.Equals, `Remove "local+debug" component`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"local"})
+}
+
+func (s *apiSuite) TestRemoveComponentErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, t := range []struct {
+		body, err string
+	}{
+		{`{"action": "install", "name": "local+debug"}`, `unsupported component action: "install"`},
+		{`{"action": "remove", "name": "local"}`, `cannot remove component "local": expected <snap>\+<component>`},
+		{`{"action": "remove"`, `cannot decode request body into a component instruction: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/components", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := postComponents(componentsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
+
 func (s *apiSuite) runGetConf(c *check.C, keys []string, statusCode int) map[string]interface{} {
 	s.vars = map[string]string{"name": "test-snap"}
 	req, err := http.NewRequest("GET", "/v2/snaps/test-snap/conf?keys="+strings.Join(keys, ","), nil)
@@ -2811,6 +3229,60 @@ func (s *apiSuite) TestRefresh(c *check.C) {
 	c.Check(summary, check.Equals, `Refresh "some-snap" snap`)
 }
 
+func (s *apiSuite) TestRefreshBranchExpiry(c *check.C) {
+	var calledPolicy string
+	snapstateSetBranchExpiry = func(s *state.State, name, policy string) error {
+		c.Check(name, check.Equals, "some-snap")
+		calledPolicy = policy
+		return nil
+	}
+	snapstateUpdate = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Check(calledPolicy, check.Equals, "stay")
+		t := s.NewTask("fake-refresh-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
+		return nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:       "refresh",
+		BranchExpiry: "stay",
+		Snaps:        []string{"some-snap"},
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+	c.Check(calledPolicy, check.Equals, "stay")
+}
+
+func (s *apiSuite) TestRefreshBranchExpiryInvalid(c *check.C) {
+	snapstateSetBranchExpiry = func(s *state.State, name, policy string) error {
+		return fmt.Errorf("invalid branch expiry policy %q", policy)
+	}
+	snapstateUpdate = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Fatalf("unexpected refresh")
+		return nil, nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action:       "refresh",
+		BranchExpiry: "potato",
+		Snaps:        []string{"some-snap"},
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.ErrorMatches, `invalid branch expiry policy "potato"`)
+}
+
 func (s *apiSuite) TestRefreshDevMode(c *check.C) {
 	var calledFlags snapstate.Flags
 	calledUserID := 0
@@ -2922,7 +3394,7 @@ func (s *apiSuite) TestRefreshIgnoreValidation(c *check.C) {
 
 func (s *apiSuite) TestPostSnapsOp(c *check.C) {
 	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 0)
 		t := s.NewTask("fake-refresh-all", "Refreshing everything")
 		return []string{"fake1", "fake2"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2967,7 +3439,7 @@ func (s *apiSuite) TestRefreshAll(c *check.C) {
 	} {
 		refreshSnapDecls = false
 
-		snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+		snapstateUpdateMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
 			c.Check(names, check.HasLen, 0)
 			t := s.NewTask("fake-refresh-all", "Refreshing everything")
 			return tst.snaps, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2991,7 +3463,7 @@ func (s *apiSuite) TestRefreshAllNoChanges(c *check.C) {
 		return assertstate.RefreshSnapDeclarations(s, userID)
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 0)
 		return nil, nil, nil
 	}
@@ -3014,7 +3486,7 @@ func (s *apiSuite) TestRefreshMany(c *check.C) {
 		return nil
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
 		t := s.NewTask("fake-refresh-2", "Refreshing two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3039,7 +3511,7 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 		return nil
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 1)
 		t := s.NewTask("fake-refresh-1", "Refreshing one")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3058,7 +3530,7 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 }
 
 func (s *apiSuite) TestInstallMany(c *check.C) {
-	snapstateInstallMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateInstallMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
 		t := s.NewTask("fake-install-2", "Install two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3075,32 +3547,227 @@ func (s *apiSuite) TestInstallMany(c *check.C) {
 	c.Check(installs, check.DeepEquals, inst.Snaps)
 }
 
-func (s *apiSuite) TestRemoveMany(c *check.C) {
-	snapstateRemoveMany = func(s *state.State, names []string) ([]string, []*state.TaskSet, error) {
-		c.Check(names, check.HasLen, 2)
-		t := s.NewTask("fake-remove-2", "Remove two")
+func (s *apiSuite) TestInstallManyWithOptions(c *check.C) {
+	var calledOpts map[string]*snapstate.SnapOptions
+	snapstateInstallMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
+		calledOpts = opts
+		t := s.NewTask("fake-install-2", "Install two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
 	}
 
 	d := s.daemon(c)
-	inst := &snapInstruction{Action: "remove", Snaps: []string{"foo", "bar"}}
+	buf := bytes.NewBufferString(`{"action": "install", "snaps": ["foo", "bar"], "snap-options": {"foo": {"channel": "beta", "revision": "42", "devmode": true}}}`)
+	var inst snapInstruction
+	c.Assert(json.NewDecoder(buf).Decode(&inst), check.IsNil)
 	st := d.overlord.State()
 	st.Lock()
-	summary, removes, _, err := snapRemoveMany(inst, st)
+	_, installs, _, err := snapInstallMany(&inst, st)
 	st.Unlock()
 	c.Assert(err, check.IsNil)
-	c.Check(summary, check.Equals, `Remove snaps "foo", "bar"`)
-	c.Check(removes, check.DeepEquals, inst.Snaps)
+	c.Check(installs, check.DeepEquals, []string{"foo", "bar"})
+	c.Check(calledOpts, check.DeepEquals, map[string]*snapstate.SnapOptions{
+		"foo": {Channel: "beta", Revision: snap.R(42), Flags: snapstate.Flags{DevMode: true}},
+	})
 }
 
-func (s *apiSuite) TestInstallFails(c *check.C) {
-	snapstateInstall = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
-		t := s.NewTask("fake-install-snap-error", "Install task")
-		return state.NewTaskSet(t), nil
+func (s *apiSuite) TestInstallManyWithOptionsErrors(c *check.C) {
+	snapstateInstallMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil, nil
 	}
 
-	d := s.daemonWithFakeSnapManager(c)
-
+	d := s.daemon(c)
+	st := d.overlord.State()
+	for _, t := range []struct {
+		opts map[string]*snapInstructionOptions
+		err  string
+	}{
+		{map[string]*snapInstructionOptions{"baz": {Channel: "beta"}}, `cannot use options of snap "baz" not part of the operation`},
+		{map[string]*snapInstructionOptions{"foo": {DevMode: true, JailMode: true}}, `cannot use options of snap "foo": cannot use devmode and jailmode flags together`},
+	} {
+		inst := &snapInstruction{Action: "install", Snaps: []string{"foo", "bar"}, SnapOptions: t.opts}
+		st.Lock()
+		_, _, _, err := snapInstallMany(inst, st)
+		st.Unlock()
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
+func (s *apiSuite) TestUpdateManyWithOptions(c *check.C) {
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+	var calledOpts map[string]*snapstate.SnapOptions
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
+		calledOpts = opts
+		t := s.NewTask("fake-refresh-2", "Refreshing two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action: "refresh",
+		Snaps:  []string{"foo", "bar"},
+		SnapOptions: map[string]*snapInstructionOptions{
+			"bar": {Revision: snap.R(7), Classic: true},
+		},
+	}
+	st := d.overlord.State()
+	st.Lock()
+	_, updates, _, err := snapUpdateMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(updates, check.DeepEquals, inst.Snaps)
+	c.Check(calledOpts, check.DeepEquals, map[string]*snapstate.SnapOptions{
+		"bar": {Revision: snap.R(7), Flags: snapstate.Flags{Classic: true}},
+	})
+}
+
+func (s *apiSuite) TestPostSnapsOpRemoveWithOptions(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "remove", "snaps": ["foo"], "snap-options": {"foo": {"channel": "beta"}}}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "unsupported option provided for multi-snap operation")
+}
+
+func (s *apiSuite) mockDependentSnaps(c *check.C) {
+	s.daemon(c)
+	ensureStateSoon = func(*state.State) {}
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	st.Unlock()
+}
+
+func (s *apiSuite) TestPostSnapRemoveDryRun(c *check.C) {
+	s.mockDependentSnaps(c)
+
+	s.vars = map[string]string{"name": "producer"}
+	req, err := http.NewRequest("POST", "/v2/snaps/producer", bytes.NewBufferString(`{"action": "remove", "dry-run": true}`))
+	c.Assert(err, check.IsNil)
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &ifacestate.RemovalImpact{
+		Snap: "producer",
+		Connections: []ifacestate.BrokenConnection{{
+			Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+			Interface: "test",
+		}},
+	})
+
+	s.vars = map[string]string{"name": "unknown"}
+	req, err = http.NewRequest("POST", "/v2/snaps/unknown", bytes.NewBufferString(`{"action": "remove", "dry-run": true}`))
+	c.Assert(err, check.IsNil)
+	rsp = postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Kind, check.Equals, errorKindSnapNotInstalled)
+
+	req, err = http.NewRequest("POST", "/v2/snaps/producer", bytes.NewBufferString(`{"action": "refresh", "dry-run": true}`))
+	c.Assert(err, check.IsNil)
+	rsp = postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "force-breaks and dry-run can only be specified when removing")
+}
+
+func (s *apiSuite) TestPostSnapRemoveDependents(c *check.C) {
+	s.mockDependentSnaps(c)
+
+	snapInstructionDispTable["remove"] = func(*snapInstruction, *state.State) (string, []*state.TaskSet, error) {
+		return "Remove", nil, nil
+	}
+	defer func() {
+		snapInstructionDispTable["remove"] = snapRemove
+	}()
+
+	s.vars = map[string]string{"name": "producer"}
+	req, err := http.NewRequest("POST", "/v2/snaps/producer", bytes.NewBufferString(`{"action": "remove"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Kind, check.Equals, errorKindSnapHasDependents)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "producer" is used by "consumer"`)
+
+	req, err = http.NewRequest("POST", "/v2/snaps/producer", bytes.NewBufferString(`{"action": "remove", "force-breaks": true}`))
+	c.Assert(err, check.IsNil)
+	rsp = postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	// nothing depends on the consumer
+	s.vars = map[string]string{"name": "consumer"}
+	req, err = http.NewRequest("POST", "/v2/snaps/consumer", bytes.NewBufferString(`{"action": "remove"}`))
+	c.Assert(err, check.IsNil)
+	rsp = postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+}
+
+func (s *apiSuite) TestPostSnapsRemoveDependents(c *check.C) {
+	s.mockDependentSnaps(c)
+
+	snapstateRemoveMany = func(s *state.State, names []string) ([]string, []*state.TaskSet, error) {
+		return names, nil, nil
+	}
+
+	for _, t := range []struct {
+		body string
+		kind errorKind
+	}{
+		{`{"action": "remove", "snaps": ["producer"]}`, errorKindSnapHasDependents},
+		{`{"action": "remove", "snaps": ["producer"], "force-breaks": true}`, ""},
+		// removed together
+		{`{"action": "remove", "snaps": ["producer", "consumer"]}`, ""},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+		rsp := postSnaps(snapsCmd, req, nil).(*resp)
+		if t.kind == "" {
+			c.Check(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf(t.body))
+		} else {
+			c.Check(rsp.Status, check.Equals, 400, check.Commentf(t.body))
+			c.Check(rsp.Result.(*errorResult).Kind, check.Equals, t.kind, check.Commentf(t.body))
+		}
+	}
+}
+
+func (s *apiSuite) TestRemoveMany(c *check.C) {
+	snapstateRemoveMany = func(s *state.State, names []string) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.HasLen, 2)
+		t := s.NewTask("fake-remove-2", "Remove two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{Action: "remove", Snaps: []string{"foo", "bar"}}
+	st := d.overlord.State()
+	st.Lock()
+	summary, removes, _, err := snapRemoveMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(summary, check.Equals, `Remove snaps "foo", "bar"`)
+	c.Check(removes, check.DeepEquals, inst.Snaps)
+}
+
+func (s *apiSuite) TestInstallFails(c *check.C) {
+	snapstateInstall = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		t := s.NewTask("fake-install-snap-error", "Install task")
+		return state.NewTaskSet(t), nil
+	}
+
+	d := s.daemonWithFakeSnapManager(c)
+
 	buf := bytes.NewBufferString(`{"action": "install"}`)
 	req, err := http.NewRequest("POST", "/v2/snaps/hello-world", buf)
 	c.Assert(err, check.IsNil)
@@ -3151,6 +3818,56 @@ func (s *apiSuite) TestInstallLeaveOld(c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *apiSuite) TestInstallFromStore(c *check.C) {
+	var calledStoreID string
+
+	snapstateInstall = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Fatalf("unexpected call to Install")
+		return nil, nil
+	}
+	snapstateInstallFromStore = func(s *state.State, name, storeID, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledStoreID = storeID
+
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action: "install",
+		Store:  "other-store",
+		Snaps:  []string{"fake"},
+	}
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
+
+	c.Check(calledStoreID, check.Equals, "other-store")
+}
+
+func (s *apiSuite) TestVerifySnapInstructionsStore(c *check.C) {
+	for _, t := range []struct {
+		inst *snapInstruction
+		err  string
+	}{
+		{&snapInstruction{Action: "install", Store: "other-store"}, ""},
+		{&snapInstruction{Action: "refresh", Store: "other-store"}, `snap store can only be specified when installing`},
+		{&snapInstruction{Action: "install", Store: "other-store", Source: "oci://registry.example.com/acme/x:1.0"}, `cannot specify both a snap source and a store`},
+		{&snapInstruction{Action: "refresh", BranchExpiry: "stay"}, ""},
+		{&snapInstruction{Action: "switch", BranchExpiry: "stay"}, `branch expiry policy can only be specified when refreshing`},
+	} {
+		err := verifySnapInstructions(t.inst)
+		if t.err == "" {
+			c.Check(err, check.IsNil)
+		} else {
+			c.Check(err, check.ErrorMatches, t.err)
+		}
+	}
+}
+
 func (s *apiSuite) TestInstallDevMode(c *check.C) {
 	var calledFlags snapstate.Flags
 
@@ -3243,6 +3960,7 @@ func (s *apiSuite) testRevertSnap(inst *snapInstruction, c *check.C) {
 
 	instFlags, err := inst.modeFlags()
 	c.Assert(err, check.IsNil)
+	instFlags.RevertConfig = inst.WithConfig
 
 	snapstateRevert = func(s *state.State, name string, flags snapstate.Flags) (*state.TaskSet, error) {
 		c.Check(flags, check.Equals, instFlags)
@@ -3288,6 +4006,15 @@ func (s *apiSuite) TestRevertSnapClassic(c *check.C) {
 	s.testRevertSnap(&snapInstruction{Classic: true}, c)
 }
 
+func (s *apiSuite) TestRevertSnapWithConfig(c *check.C) {
+	s.testRevertSnap(&snapInstruction{WithConfig: true}, c)
+}
+
+func (s *apiSuite) TestWithConfigOnlyForRevert(c *check.C) {
+	inst := &snapInstruction{Action: "refresh", Snaps: []string{"some-snap"}, WithConfig: true}
+	c.Check(verifySnapInstructions(inst), check.ErrorMatches, "with-config can only be specified when reverting")
+}
+
 func (s *apiSuite) TestRevertSnapToRevision(c *check.C) {
 	s.testRevertSnap(&snapInstruction{Revision: snap.R(1)}, c)
 }
@@ -3545,2715 +4272,4823 @@ func (s *apiSuite) TestConnectPlugSuccess(c *check.C) {
 	c.Check(slot.Connections[0], check.DeepEquals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
 }
 
-func (s *apiSuite) TestConnectPlugFailureInterfaceMismatch(c *check.C) {
+func (s *apiSuite) TestConnectPlugWithAttrs(c *check.C) {
 	d := s.daemon(c)
 
-	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
-	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "different"})
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{
+			ConnectionAttrs: interfaces.AttrSchema{{Name: "path", Type: interfaces.AttrString}},
+		},
+	})
 	s.mockSnap(c, consumerYaml)
-	s.mockSnap(c, differentProducerYaml)
+	s.mockSnap(c, producerYaml)
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
 	action := &interfaceAction{
 		Action: "connect",
 		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
 		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+		Attrs:  map[string]interface{}{"path": "/dev/foo"},
 	}
 	text, err := json.Marshal(action)
 	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
 	c.Assert(err, check.IsNil)
 	rec := httptest.NewRecorder()
 	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
+	c.Check(rec.Code, check.Equals, 202)
 	var body map[string]interface{}
 	err = json.Unmarshal(rec.Body.Bytes(), &body)
 	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "cannot connect consumer:plug (\"test\" interface) to producer:slot (\"different\" interface)",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
-	repo := d.overlord.InterfaceManager().Repository()
-	plug := repo.Plug("consumer", "plug")
-	slot := repo.Slot("producer", "slot")
-	c.Assert(plug.Connections, check.HasLen, 0)
-	c.Assert(slot.Connections, check.HasLen, 0)
+	id := body["change"].(string)
+
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), check.IsNil)
+	connStates, err := ifacestate.ConnectionStates(st)
+	c.Assert(err, check.IsNil)
+	c.Assert(connStates, check.HasLen, 1)
+	c.Check(connStates[0].Attrs, check.DeepEquals, map[string]interface{}{"path": "/dev/foo"})
 }
 
-func (s *apiSuite) TestConnectPlugFailureNoSuchPlug(c *check.C) {
-	d := s.daemon(c)
+func (s *apiSuite) TestConnectPlugWithUnsupportedAttrs(c *check.C) {
+	s.daemon(c)
 
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
-	// there is no consumer, no plug defined
-	s.mockSnap(c, producerYaml)
 	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
 
-	action := &interfaceAction{
-		Action: "connect",
-		Plugs:  []plugJSON{{Snap: "consumer", Name: "missingplug"}},
-		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	for _, action := range []string{"connect", "disconnect"} {
+		text, err := json.Marshal(&interfaceAction{
+			Action: action,
+			Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+			Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+			Attrs:  map[string]interface{}{"path": "/dev/foo"},
+		})
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, 400)
+		if action == "connect" {
+			c.Check(rsp.Result.(*errorResult).Message, check.Equals, "test connections do not support attributes")
+		} else {
+			c.Check(rsp.Result.(*errorResult).Message, check.Equals, "attributes can only be given when connecting")
+		}
 	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
-
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "snap \"consumer\" has no plug named \"missingplug\"",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
-
-	repo := d.overlord.InterfaceManager().Repository()
-	slot := repo.Slot("producer", "slot")
-	c.Assert(slot.Connections, check.HasLen, 0)
 }
 
-func (s *apiSuite) TestConnectPlugFailureNoSuchSlot(c *check.C) {
+func (s *apiSuite) TestConnectPlugDryRun(c *check.C) {
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{&udev.Backend{}})
+	defer restore()
 	d := s.daemon(c)
 
-	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		UDevConnectedPlugCallback: func(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+			spec.AddSnippet(`KERNEL=="foo"`)
+			return nil
+		},
+	})
 	s.mockSnap(c, consumerYaml)
 	s.mockSnap(c, producerYaml)
-	// there is no producer, no slot defined
 
-	action := &interfaceAction{
+	text, err := json.Marshal(&interfaceAction{
 		Action: "connect",
 		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
-		Slots:  []slotJSON{{Snap: "producer", Name: "missingslot"}},
-	}
-	text, err := json.Marshal(action)
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+		DryRun: true,
+	})
 	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
-
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "snap \"producer\" has no slot named \"missingslot\"",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
+	rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]map[string]string{
+		"udev": {"consumer": `KERNEL=="foo"`},
+	})
 
+	// nothing was connected
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
 	repo := d.overlord.InterfaceManager().Repository()
-	plug := repo.Plug("consumer", "plug")
-	c.Assert(plug.Connections, check.HasLen, 0)
+	c.Check(repo.Plug("consumer", "plug").Connections, check.HasLen, 0)
 }
 
-func (s *apiSuite) testDisconnect(c *check.C, plugSnap, plugName, slotSnap, slotName string) {
-	d := s.daemon(c)
-
-	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
-	s.mockSnap(c, consumerYaml)
-	s.mockSnap(c, producerYaml)
-
-	repo := d.overlord.InterfaceManager().Repository()
-	connRef := interfaces.ConnRef{
-		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
-		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
-	}
-	c.Assert(repo.Connect(connRef), check.IsNil)
-
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+func (s *apiSuite) TestDisconnectPlugDryRun(c *check.C) {
+	s.daemon(c)
 
-	action := &interfaceAction{
+	text, err := json.Marshal(&interfaceAction{
 		Action: "disconnect",
-		Plugs:  []plugJSON{{Snap: plugSnap, Name: plugName}},
-		Slots:  []slotJSON{{Snap: slotSnap, Name: slotName}},
-	}
-	text, err := json.Marshal(action)
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+		DryRun: true,
+	})
 	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
+	rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "dry-run is only supported when connecting")
+}
+
+func (s *apiSuite) TestGetConnections(c *check.C) {
+	d := s.daemon(c)
 
 	st := d.overlord.State()
 	st.Lock()
-	chg := st.Change(id)
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":   map[string]interface{}{"interface": "test"},
+		"consumer:network core:network": map[string]interface{}{"interface": "network", "auto": true},
+	})
 	st.Unlock()
-	c.Assert(chg, check.NotNil)
 
-	<-chg.Ready()
+	req, err := http.NewRequest("GET", "/v2/connections", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getConnections(connectionsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []connectionJSON{{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "network"},
+		Slot:      interfaces.SlotRef{Snap: "core", Name: "network"},
+		Interface: "network",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+		Manual:    true,
+	}})
+}
+
+func (s *apiSuite) TestGetConnectionsLabelsAndCategory(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, `name: consumer
+version: 1
+apps:
+ app:
+plugs:
+ plug:
+  interface: test
+  label: Take photos
+  labels:
+   fr: Prendre des photos
+`)
+	s.mockSnap(c, `name: producer
+version: 1
+apps:
+ app:
+slots:
+ slot:
+  interface: test
+  label: Camera
+  category: privacy
+`)
 
+	st := d.overlord.State()
 	st.Lock()
-	err = chg.Err()
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
 	st.Unlock()
-	c.Assert(err, check.IsNil)
 
-	plug := repo.Plug("consumer", "plug")
-	slot := repo.Slot("producer", "slot")
-	c.Assert(plug.Connections, check.HasLen, 0)
-	c.Assert(slot.Connections, check.HasLen, 0)
+	req, err := http.NewRequest("GET", "/v2/connections", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getConnections(connectionsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []connectionJSON{{
+		Plug:       interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:       interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface:  "test",
+		Manual:     true,
+		PlugLabel:  "Take photos",
+		PlugLabels: map[string]string{"fr": "Prendre des photos"},
+		SlotLabel:  "Camera",
+		Category:   "privacy",
+	}})
 }
 
-func (s *apiSuite) TestDisconnectPlugSuccess(c *check.C) {
-	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
-}
+func (s *apiSuite) TestGetConnectionsHistory(c *check.C) {
+	d := s.daemon(c)
 
-func (s *apiSuite) TestDisconnectPlugSuccessWithEmptyPlug(c *check.C) {
-	s.testDisconnect(c, "", "", "producer", "slot")
-}
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("conns-history", []map[string]interface{}{{
+		"time":      "2018-06-01T10:00:00Z",
+		"action":    "connect",
+		"by":        "user",
+		"plug":      map[string]string{"snap": "consumer", "plug": "camera"},
+		"slot":      map[string]string{"snap": "core", "slot": "camera"},
+		"interface": "camera",
+	}, {
+		"time":      "2018-06-02T10:00:00Z",
+		"action":    "connect",
+		"by":        "auto-connect",
+		"plug":      map[string]string{"snap": "other", "plug": "network"},
+		"slot":      map[string]string{"snap": "core", "slot": "network"},
+		"interface": "network",
+	}})
+	st.Unlock()
 
-func (s *apiSuite) TestDisconnectPlugSuccessWithEmptySlot(c *check.C) {
-	s.testDisconnect(c, "consumer", "plug", "", "")
+	req, err := http.NewRequest("GET", "/v2/connections/history?snap=consumer", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getConnectionsHistory(connectionsHistoryCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []*ifacestate.ConnectionEvent{{
+		Time:      time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC),
+		Action:    "connect",
+		By:        "user",
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "camera"},
+		Slot:      interfaces.SlotRef{Snap: "core", Name: "camera"},
+		Interface: "camera",
+	}})
+
+	req, err = http.NewRequest("GET", "/v2/connections/history", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getConnectionsHistory(connectionsHistoryCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.HasLen, 2)
+
+	req, err = http.NewRequest("GET", "/v2/connections/history?snap=unknown", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getConnectionsHistory(connectionsHistoryCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []*ifacestate.ConnectionEvent{})
 }
 
-func (s *apiSuite) TestDisconnectPlugFailureNoSuchPlug(c *check.C) {
-	s.daemon(c)
+func (s *apiSuite) TestGetConnectionsActivity(c *check.C) {
+	d := s.daemon(c)
 
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
-	// there is no consumer, no plug defined
+	s.mockSnap(c, consumerYaml)
 	s.mockSnap(c, producerYaml)
 
-	action := &interfaceAction{
-		Action: "disconnect",
-		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
-		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+		"other:plug producer:slot":    map[string]interface{}{"interface": "test"},
+	})
+	st.Unlock()
+
+	defer func(f func(string) ([]int, error)) { cgroupPidsOfSnap = f }(cgroupPidsOfSnap)
+	cgroupPidsOfSnap = func(snapName string) ([]int, error) {
+		switch snapName {
+		case "consumer":
+			return []int{10, 11, 12}, nil
+		case "producer":
+			return []int{20}, nil
+		}
+		return nil, nil
 	}
-	text, err := json.Marshal(action)
+	defer func(f func(int) (string, error)) { apparmorProcessLabel = f }(apparmorProcessLabel)
+	apparmorProcessLabel = func(pid int) (string, error) {
+		switch pid {
+		case 10:
+			return "snap.consumer.app (enforce)", nil
+		case 11:
+			return "snap.consumer.hook.configure (enforce)", nil
+		case 20:
+			return "snap.producer.app (complain)", nil
+		}
+		return "", fmt.Errorf("no process with pid %d", pid)
+	}
+	denial := &apparmor.Denial{Profile: "snap.consumer.app", Operation: "open", Name: "/dev/video0"}
+	defer func(f func(int) ([]*apparmor.Denial, error)) { apparmorRecentDenials = f }(apparmorRecentDenials)
+	apparmorRecentDenials = func(n int) ([]*apparmor.Denial, error) {
+		c.Check(n, check.Equals, recentDenialsCount)
+		return []*apparmor.Denial{
+			{Profile: "snap.other.app", Operation: "open", Name: "/etc/shadow"},
+			denial,
+		}, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/connections/activity?snap=consumer", nil)
 	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	rsp := getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []connectionActivityJSON{{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+		Processes: []processJSON{
+			{Pid: 10, Snap: "consumer", Side: "plug", Label: "snap.consumer.app (enforce)"},
+			// the label of 12 could not be read
+			{Pid: 12, Snap: "consumer", Side: "plug"},
+			{Pid: 20, Snap: "producer", Side: "slot", Label: "snap.producer.app (complain)"},
+		},
+		Denials: []*apparmor.Denial{denial},
+	}})
+
+	// the snap of the plug is gone, only the slot side is known
+	req, err = http.NewRequest("GET", "/v2/connections/activity?snap=other", nil)
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "snap \"consumer\" has no plug named \"plug\"",
+	rsp = getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []connectionActivityJSON{{
+		Plug:      interfaces.PlugRef{Snap: "other", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+		Processes: []processJSON{
+			{Pid: 20, Snap: "producer", Side: "slot", Label: "snap.producer.app (complain)"},
 		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
+	}})
+
+	// denials are not available everywhere
+	apparmorRecentDenials = func(int) ([]*apparmor.Denial, error) {
+		return nil, fmt.Errorf("journalctl not found")
+	}
+	req, err = http.NewRequest("GET", "/v2/connections/activity", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.HasLen, 2)
+
+	cgroupPidsOfSnap = func(string) ([]int, error) {
+		return nil, fmt.Errorf("boom")
+	}
+	rsp = getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot list the processes of snap "(consumer|producer)": boom`)
 }
 
-func (s *apiSuite) TestDisconnectPlugFailureNoSuchSlot(c *check.C) {
+func (s *apiSuite) TestGetConnectionsActivityRootOnly(c *check.C) {
 	s.daemon(c)
 
-	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
-	s.mockSnap(c, consumerYaml)
-	// there is no producer, no slot defined
-
-	action := &interfaceAction{
-		Action: "disconnect",
-		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
-		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
-	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	// the processes and denials of all users are not for everyone to see
+	req, err := http.NewRequest("GET", "/v2/connections/activity", nil)
 	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;" + req.RemoteAddr
 	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-
-	c.Check(rec.Code, check.Equals, 400)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "snap \"producer\" has no slot named \"slot\"",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
+	connectionsActivityCmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 401)
+}
+
+// waitJob waits for the given job to be ready, asking as root
+func (s *apiSuite) waitJob(c *check.C, jobID string) *jobInfo {
+	req, err := http.NewRequest("GET", "/v2/jobs/"+jobID, nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;"
+	s.vars = map[string]string{"id": jobID}
+	for i := 0; i < 500; i++ {
+		rsp := getJob(jobCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+		info := rsp.Result.(*jobInfo)
+		if info.Ready {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("job %s did not become ready", jobID)
+	return nil
 }
 
-func (s *apiSuite) TestDisconnectPlugFailureNotConnected(c *check.C) {
-	s.daemon(c)
+func (s *apiSuite) TestGetConnectionsActivityAsync(c *check.C) {
+	d := s.daemon(c)
 
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
 	s.mockSnap(c, producerYaml)
 
-	action := &interfaceAction{
-		Action: "disconnect",
-		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
-		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	st.Unlock()
+
+	defer func(f func(string) ([]int, error)) { cgroupPidsOfSnap = f }(cgroupPidsOfSnap)
+	cgroupPidsOfSnap = func(snapName string) ([]int, error) {
+		return nil, nil
 	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	defer func(f func(int) ([]*apparmor.Denial, error)) { apparmorRecentDenials = f }(apparmorRecentDenials)
+	apparmorRecentDenials = func(n int) ([]*apparmor.Denial, error) {
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/connections/activity?async=true", nil)
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	rsp := getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(rsp.Status, check.Equals, 202)
+	c.Assert(rsp.Meta, check.NotNil)
+	jobID := rsp.Meta.Job
+	c.Check(jobID, check.Not(check.Equals), "")
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"resource": "/v2/jobs/" + jobID})
+
+	info := s.waitJob(c, jobID)
+	c.Check(info.ID, check.Equals, jobID)
+	c.Check(info.Kind, check.Equals, "connections-activity")
+	c.Check(info.Status, check.Equals, "Done")
+	c.Check(info.ReadyTime, check.NotNil)
+	c.Check(info.Result, check.DeepEquals, []connectionActivityJSON{{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}})
+	c.Check(d.jobs.busy(), check.Equals, false)
 
-	c.Check(rec.Code, check.Equals, 400)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "cannot disconnect consumer:plug from producer:slot, it is not connected",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
+	// errors of the query are reported by the job
+	cgroupPidsOfSnap = func(string) ([]int, error) {
+		return nil, fmt.Errorf("boom")
+	}
+	rsp = getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	info = s.waitJob(c, rsp.Meta.Job)
+	c.Check(info.Status, check.Equals, "Error")
+	c.Check(info.Err, check.Matches, `cannot list the processes of snap "(consumer|producer)": boom`)
+	c.Check(info.Result, check.IsNil)
 }
 
-func (s *apiSuite) TestUnsupportedInterfaceRequest(c *check.C) {
-	buf := bytes.NewBuffer([]byte(`garbage`))
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "cannot decode request body into an interface action: invalid character 'g' looking for beginning of value",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
+func (s *apiSuite) TestGetJobExpires(c *check.C) {
+	d := s.daemon(c)
+
+	now := time.Now()
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	jobID, err := d.jobs.start("test", 1000, func() Response {
+		return SyncResponse("result", nil)
 	})
-}
+	c.Assert(err, check.IsNil)
+	info := s.waitJob(c, jobID)
+	c.Check(info.Result, check.Equals, "result")
 
-func (s *apiSuite) TestMissingInterfaceAction(c *check.C) {
-	action := &interfaceAction{}
-	text, err := json.Marshal(action)
+	// still there right before expiring
+	now = now.Add(queryJobExpiry)
+	s.waitJob(c, jobID)
+
+	now = now.Add(time.Second)
+	req, err := http.NewRequest("GET", "/v2/jobs/"+jobID, nil)
 	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	rsp := getJob(jobCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, fmt.Sprintf("cannot find job with id %q", jobID))
+}
+
+func (s *apiSuite) TestGetJobOtherUser(c *check.C) {
+	d := s.daemon(c)
+
+	jobID, err := d.jobs.start("test", 1000, func() Response {
+		return SyncResponse("result", nil)
+	})
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "interface action not specified",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
-	})
+	s.waitJob(c, jobID)
+
+	for _, t := range []struct {
+		peer   string
+		status int
+	}{
+		{"pid=100;uid=1000;", 200},
+		{"pid=100;uid=0;", 200},
+		// other users cannot tell it from a job that doesn't exist
+		{"pid=100;uid=1001;", 404},
+		{"", 404},
+	} {
+		req, err := http.NewRequest("GET", "/v2/jobs/"+jobID, nil)
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = t.peer
+		rsp := getJob(jobCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, t.status, check.Commentf("%q", t.peer))
+	}
 }
 
-func (s *apiSuite) TestUnsupportedInterfaceAction(c *check.C) {
-	s.daemon(c)
-	action := &interfaceAction{Action: "foo"}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
-	c.Check(rec.Code, check.Equals, 400)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	c.Check(body, check.DeepEquals, map[string]interface{}{
-		"result": map[string]interface{}{
-			"message": "unsupported interface action: \"foo\"",
-		},
-		"status":      "Bad Request",
-		"status-code": 400.0,
-		"type":        "error",
+func (s *apiSuite) TestQueryJobTimeout(c *check.C) {
+	d := s.daemon(c)
+
+	now := time.Now()
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return now }
+
+	release := make(chan struct{})
+	defer close(release)
+	jobID, err := d.jobs.start("test", 1000, func() Response {
+		<-release
+		return SyncResponse("result", nil)
 	})
-}
+	c.Assert(err, check.IsNil)
+	c.Check(d.jobs.busy(), check.Equals, true)
 
-func (s *apiSuite) TestGetAsserts(c *check.C) {
-	s.daemon(c)
-	resp := assertsCmd.GET(assertsCmd, nil, nil).(*resp)
-	c.Check(resp.Status, check.Equals, 200)
-	c.Check(resp.Type, check.Equals, ResponseTypeSync)
-	c.Check(resp.Result, check.DeepEquals, map[string][]string{"types": asserts.TypeNames()})
-}
+	now = now.Add(queryJobTimeout + time.Second)
+	c.Check(d.jobs.busy(), check.Equals, false)
+	info := s.waitJob(c, jobID)
+	c.Check(info.Status, check.Equals, "Error")
+	c.Check(info.Err, check.Equals, fmt.Sprintf(`query "test" timed out after %v`, queryJobTimeout))
 
-func assertAdd(st *state.State, a asserts.Assertion) {
-	st.Lock()
-	defer st.Unlock()
-	err := assertstate.Add(st, a)
-	if err != nil {
-		panic(err)
-	}
+	// and it expires like any other job
+	now = now.Add(queryJobExpiry + time.Second)
+	c.Check(d.jobs.info(jobID, 0), check.IsNil)
 }
 
-func (s *apiSuite) TestAssertOK(c *check.C) {
-	// Setup
+func (s *apiSuite) TestQueryJobsLimits(c *check.C) {
 	d := s.daemon(c)
-	st := d.overlord.State()
-	// add store key
-	assertAdd(st, s.storeSigning.StoreAccountKey(""))
 
-	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
-	buf := bytes.NewBuffer(asserts.Encode(acct))
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	release := make(chan struct{})
+	defer close(release)
+	query := func() Response {
+		<-release
+		return SyncResponse(nil, nil)
+	}
+
+	for i := 0; i < maxQueryJobsPerUser; i++ {
+		_, err := d.jobs.start("test", 1000, query)
+		c.Assert(err, check.IsNil)
+	}
+	_, err := d.jobs.start("test", 1000, query)
+	c.Check(err, check.Equals, errTooManyQueryJobs)
+
+	// other users can still start jobs, up to the overall limit
+	for uid := uint32(1001); d.jobs.runningLocked() < maxQueryJobs; uid++ {
+		_, err := d.jobs.start("test", uid, query)
+		c.Assert(err, check.IsNil)
+	}
+	_, err = d.jobs.start("test", 2000, query)
+	c.Check(err, check.Equals, errTooManyQueryJobs)
+
+	// which is reported by the API
+	req, err := http.NewRequest("GET", "/v2/connections/activity?async=true", nil)
 	c.Assert(err, check.IsNil)
-	rsp := doAssert(assertsCmd, req, nil).(*resp)
-	// Verify (external)
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Status, check.Equals, 200)
-	// Verify (internal)
-	st.Lock()
-	defer st.Unlock()
-	_, err = assertstate.DB(st).Find(asserts.AccountType, map[string]string{
-		"account-id": acct.AccountID(),
-	})
-	c.Check(err, check.IsNil)
+	rsp := getConnectionsActivity(connectionsActivityCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 429)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "too many queries are running, try again later")
 }
 
-func (s *apiSuite) TestAssertStreamOK(c *check.C) {
-	// Setup
+func (s *apiSuite) TestGetDiskUsage(c *check.C) {
 	d := s.daemon(c)
+
 	st := d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(1)}, {RealName: "foo", Revision: snap.R(2)}},
+		Current:  snap.R(2),
+	})
+	snapstate.Set(st, "bar", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "bar", Revision: snap.R(5)}},
+		Current:  snap.R(5),
+	})
+	st.Unlock()
 
-	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
-	buf := &bytes.Buffer{}
-	enc := asserts.NewEncoder(buf)
-	err := enc.Encode(acct)
-	c.Assert(err, check.IsNil)
-	err = enc.Encode(s.storeSigning.StoreAccountKey(""))
+	defer func(f func(string) (uint64, error)) { osutilDirSize = f }(osutilDirSize)
+	osutilDirSize = func(path string) (uint64, error) {
+		switch path {
+		case filepath.Join(dirs.SnapBlobDir, "foo_1.snap"):
+			return 10, nil
+		case filepath.Join(dirs.SnapBlobDir, "foo_2.snap"):
+			return 20, nil
+		case filepath.Join(dirs.SnapBlobDir, "bar_5.snap"):
+			return 5, nil
+		case filepath.Join(dirs.SnapDataDir, "foo"):
+			return 100, nil
+		case filepath.Join(dirs.SnapDataDir, "bar"):
+			return 0, nil
+		}
+		c.Fatalf("unexpected path %q", path)
+		return 0, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/disk-usage", nil)
 	c.Assert(err, check.IsNil)
+	rsp := getDiskUsage(diskUsageCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []diskUsageJSON{
+		{Snap: "bar", SnapSize: 5, DataSize: 0},
+		{Snap: "foo", SnapSize: 30, DataSize: 100},
+	})
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	req, err = http.NewRequest("GET", "/v2/disk-usage?snap=foo&async=true", nil)
 	c.Assert(err, check.IsNil)
-	rsp := doAssert(assertsCmd, req, nil).(*resp)
-	// Verify (external)
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Status, check.Equals, 200)
-	// Verify (internal)
-	st.Lock()
-	defer st.Unlock()
-	_, err = assertstate.DB(st).Find(asserts.AccountType, map[string]string{
-		"account-id": acct.AccountID(),
+	rsp = getDiskUsage(diskUsageCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	info := s.waitJob(c, rsp.Meta.Job)
+	c.Check(info.Kind, check.Equals, "disk-usage")
+	c.Check(info.Status, check.Equals, "Done")
+	c.Check(info.Result, check.DeepEquals, []diskUsageJSON{
+		{Snap: "foo", SnapSize: 30, DataSize: 100},
 	})
-	c.Check(err, check.IsNil)
-}
 
-func (s *apiSuite) TestAssertInvalid(c *check.C) {
-	// Setup
-	buf := bytes.NewBufferString("blargh")
-	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	req, err = http.NewRequest("GET", "/v2/disk-usage?snap=baz", nil)
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	// Execute
-	assertsCmd.POST(assertsCmd, req, nil).ServeHTTP(rec, req)
-	// Verify (external)
-	c.Check(rec.Code, check.Equals, 400)
-	c.Check(rec.Body.String(), testutil.Contains,
-		"cannot decode request body into assertions")
+	rsp = getDiskUsage(diskUsageCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+
+	osutilDirSize = func(path string) (uint64, error) {
+		return 0, fmt.Errorf("boom")
+	}
+	req, err = http.NewRequest("GET", "/v2/disk-usage?snap=bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getDiskUsage(diskUsageCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot get the size of snap "bar": boom`)
 }
 
-func (s *apiSuite) TestAssertError(c *check.C) {
-	s.daemon(c)
-	// Setup
-	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
-	buf := bytes.NewBuffer(asserts.Encode(acct))
-	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+func (s *apiSuite) postConnections(c *check.C, action *connectionsAction) *resp {
+	text, err := json.Marshal(action)
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	// Execute
-	assertsCmd.POST(assertsCmd, req, nil).ServeHTTP(rec, req)
-	// Verify (external)
-	c.Check(rec.Code, check.Equals, 400)
-	c.Check(rec.Body.String(), testutil.Contains, "assert failed")
+	req, err := http.NewRequest("POST", "/v2/connections", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	return postConnections(connectionsCmd, req, nil).(*resp)
 }
 
-func (s *apiSuite) TestAssertsFindManyAll(c *check.C) {
-	// Setup
+func (s *apiSuite) TestPostConnections(c *check.C) {
 	d := s.daemon(c)
-	// add store key
-	st := d.overlord.State()
-	assertAdd(st, s.storeSigning.StoreAccountKey(""))
-	acct := assertstest.NewAccount(s.storeSigning, "developer1", map[string]interface{}{
-		"account-id": "developer1-id",
-	}, "")
-	assertAdd(st, acct)
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/assertions/account", nil)
-	c.Assert(err, check.IsNil)
-	s.vars = map[string]string{"assertType": "account"}
-	rec := httptest.NewRecorder()
-	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
-	// Verify
-	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
-	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/x.ubuntu.assertion; bundle=y")
-	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "4")
-	dec := asserts.NewDecoder(rec.Body)
-	a1, err := dec.Decode()
-	c.Assert(err, check.IsNil)
-	c.Check(a1.Type(), check.Equals, asserts.AccountType)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
 
-	a2, err := dec.Decode()
-	c.Assert(err, check.IsNil)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-	a3, err := dec.Decode()
-	c.Assert(err, check.IsNil)
+	rsp := s.postConnections(c, &connectionsAction{
+		Action: "connect",
+		Connections: []connectionJSON{{
+			Plug: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}},
+	})
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
 
-	a4, err := dec.Decode()
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "connect-snap")
+	c.Check(chg.Summary(), check.Equals, "Connect 1 plug")
+	st.Unlock()
+
+	<-chg.Ready()
+
+	st.Lock()
+	err := chg.Err()
+	st.Unlock()
 	c.Assert(err, check.IsNil)
 
-	_, err = dec.Decode()
-	c.Assert(err, check.Equals, io.EOF)
+	repo := d.overlord.InterfaceManager().Repository()
+	plug := repo.Plug("consumer", "plug")
+	c.Assert(plug.Connections, check.HasLen, 1)
+	c.Check(plug.Connections[0], check.DeepEquals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
 
-	ids := []string{a1.(*asserts.Account).AccountID(), a2.(*asserts.Account).AccountID(), a3.(*asserts.Account).AccountID(), a4.(*asserts.Account).AccountID()}
-	sort.Strings(ids)
-	c.Check(ids, check.DeepEquals, []string{"can0nical", "canonical", "developer1-id", "generic"})
+	// the connection is made already the second time around
+	rsp = s.postConnections(c, &connectionsAction{
+		Action: "connect",
+		Connections: []connectionJSON{{
+			Plug: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot: interfaces.SlotRef{Snap: "producer"},
+		}},
+	})
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
+	st.Lock()
+	chg = st.Change(rsp.Change)
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	c.Check(chg.Tasks(), check.HasLen, 0)
+	st.Unlock()
 }
 
-func (s *apiSuite) TestAssertsFindManyFilter(c *check.C) {
-	// Setup
+func (s *apiSuite) TestPostConnectionsDisconnect(c *check.C) {
 	d := s.daemon(c)
-	// add store key
-	st := d.overlord.State()
-	assertAdd(st, s.storeSigning.StoreAccountKey(""))
-	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
-	assertAdd(st, acct)
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/assertions/account?username=developer1", nil)
-	c.Assert(err, check.IsNil)
-	s.vars = map[string]string{"assertType": "account"}
-	rec := httptest.NewRecorder()
-	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
-	// Verify
-	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
-	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "1")
-	dec := asserts.NewDecoder(rec.Body)
-	a1, err := dec.Decode()
-	c.Assert(err, check.IsNil)
-	c.Check(a1.Type(), check.Equals, asserts.AccountType)
-	c.Check(a1.(*asserts.Account).Username(), check.Equals, "developer1")
-	c.Check(a1.(*asserts.Account).AccountID(), check.Equals, acct.AccountID())
-	_, err = dec.Decode()
-	c.Check(err, check.Equals, io.EOF)
-}
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	repo := d.overlord.InterfaceManager().Repository()
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	c.Assert(repo.Connect(connRef), check.IsNil)
 
-func (s *apiSuite) TestAssertsFindManyNoResults(c *check.C) {
-	// Setup
-	d := s.daemon(c)
-	// add store key
 	st := d.overlord.State()
-	assertAdd(st, s.storeSigning.StoreAccountKey(""))
-	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
-	assertAdd(st, acct)
+	st.Lock()
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	st.Unlock()
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/assertions/account?username=xyzzyx", nil)
-	c.Assert(err, check.IsNil)
-	s.vars = map[string]string{"assertType": "account"}
-	rec := httptest.NewRecorder()
-	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
-	// Verify
-	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
-	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "0")
-	dec := asserts.NewDecoder(rec.Body)
-	_, err = dec.Decode()
-	c.Check(err, check.Equals, io.EOF)
-}
-
-func (s *apiSuite) TestAssertsInvalidType(c *check.C) {
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/assertions/foo", nil)
-	c.Assert(err, check.IsNil)
-	s.vars = map[string]string{"assertType": "foo"}
-	rec := httptest.NewRecorder()
-	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
-	// Verify
-	c.Check(rec.Code, check.Equals, 400)
-	c.Check(rec.Body.String(), testutil.Contains, "invalid assert type")
-}
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-func setupChanges(st *state.State) []string {
-	chg1 := st.NewChange("install", "install...")
-	chg1.Set("snap-names", []string{"funky-snap-name"})
-	t1 := st.NewTask("download", "1...")
-	t2 := st.NewTask("activate", "2...")
-	chg1.AddAll(state.NewTaskSet(t1, t2))
-	t1.Logf("l11")
-	t1.Logf("l12")
-	chg2 := st.NewChange("remove", "remove..")
-	t3 := st.NewTask("unlink", "1...")
-	chg2.AddTask(t3)
-	t3.SetStatus(state.ErrorStatus)
-	t3.Errorf("rm failed")
+	rsp := s.postConnections(c, &connectionsAction{
+		Action: "disconnect",
+		Connections: []connectionJSON{{
+			Plug: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}},
+	})
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
 
-	return []string{chg1.ID(), chg2.ID(), t1.ID(), t2.ID(), t3.ID()}
-}
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "disconnect-snap")
+	c.Check(chg.Summary(), check.Equals, "Disconnect 1 plug")
+	var kinds []string
+	for _, t := range chg.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, check.DeepEquals, []string{"run-hook", "run-hook", "disconnect", "update-profiles", "update-profiles"})
+	st.Unlock()
 
-func (s *apiSuite) TestStateChangesDefaultToInProgress(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+	<-chg.Ready()
 
-	// Setup
-	d := newTestDaemon(c)
-	st := d.overlord.State()
 	st.Lock()
-	setupChanges(st)
+	err := chg.Err()
 	st.Unlock()
-
-	// Execute
-	req, err := http.NewRequest("GET", "/v2/changes", nil)
 	c.Assert(err, check.IsNil)
-	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(repo.Plug("consumer", "plug").Connections, check.HasLen, 0)
+}
 
-	// Verify
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Result, check.HasLen, 1)
+func (s *apiSuite) TestPostConnectionsErrors(c *check.C) {
+	s.daemon(c)
 
-	res, err := rsp.MarshalJSON()
-	c.Assert(err, check.IsNil)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+	for _, t := range []struct {
+		action *connectionsAction
+		err    string
+	}{
+		{&connectionsAction{Action: "refresh"}, `unsupported connections action: "refresh"`},
+		{&connectionsAction{Action: "connect"}, `at least one connection is required`},
+		{&connectionsAction{Action: "disconnect", Connections: []connectionJSON{{
+			Plug: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}}}, `cannot disconnect consumer:plug from producer:slot: cannot disconnect consumer:plug from producer:slot, it is not connected`},
+		{&connectionsAction{Action: "connect", Connections: []connectionJSON{{
+			Plug: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}, {
+			Plug: interfaces.PlugRef{Snap: "consumer", Name: "missing"},
+			Slot: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}}}, `cannot connect consumer:missing to producer:slot: snap "consumer" has no plug named "missing"`},
+	} {
+		rsp := s.postConnections(c, t.action)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
 }
 
-func (s *apiSuite) TestStateChangesInProgress(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+func (s *apiSuite) TestPostConnectionsBatch(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, `
+name: other
+version: 1
+apps:
+ app:
+plugs:
+ plug:
+  interface: test
+`)
+
+	repo := d.overlord.InterfaceManager().Repository()
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	c.Assert(repo.Connect(connRef), check.IsNil)
 
-	// Setup
-	d := newTestDaemon(c)
 	st := d.overlord.State()
 	st.Lock()
-	setupChanges(st)
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
 	st.Unlock()
 
-	// Execute
-	req, err := http.NewRequest("GET", "/v2/changes?select=in-progress", nil)
-	c.Assert(err, check.IsNil)
-	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
-
-	// Verify
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Result, check.HasLen, 1)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-	res, err := rsp.MarshalJSON()
-	c.Assert(err, check.IsNil)
+	rsp := s.postConnections(c, &connectionsAction{
+		Action: "batch",
+		Operations: []connectionOperationJSON{{
+			Action: "connect",
+			Plug:   interfaces.PlugRef{Snap: "other", Name: "plug"},
+			Slot:   interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}, {
+			Action: "disconnect",
+			Plug:   interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:   interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}},
+	})
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
-}
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "connections-batch")
+	c.Check(chg.Summary(), check.Equals, "Change 2 connections")
+	var kinds []string
+	for _, t := range chg.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	// disconnections come first, and the profiles are set up once
+	c.Check(kinds, check.DeepEquals, []string{
+		"run-hook", "run-hook", "disconnect",
+		"run-hook", "run-hook", "connect",
+		"update-profiles", "update-profiles", "update-profiles",
+		"run-hook", "run-hook",
+	})
+	st.Unlock()
 
-func (s *apiSuite) TestStateChangesAll(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+	<-chg.Ready()
 
-	// Setup
-	d := newTestDaemon(c)
-	st := d.overlord.State()
 	st.Lock()
-	setupChanges(st)
+	err := chg.Err()
 	st.Unlock()
-
-	// Execute
-	req, err := http.NewRequest("GET", "/v2/changes?select=all", nil)
 	c.Assert(err, check.IsNil)
-	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(repo.Plug("consumer", "plug").Connections, check.HasLen, 0)
+	c.Check(repo.Plug("other", "plug").Connections, check.DeepEquals, []interfaces.SlotRef{{Snap: "producer", Name: "slot"}})
+}
 
-	// Verify
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Result, check.HasLen, 2)
+func (s *apiSuite) TestPostConnectionsBatchErrors(c *check.C) {
+	s.daemon(c)
 
-	res, err := rsp.MarshalJSON()
-	c.Assert(err, check.IsNil)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"err":"[^"]+".*`)
+	for _, t := range []struct {
+		ops []connectionOperationJSON
+		err string
+	}{
+		{nil, `at least one operation is required`},
+		{[]connectionOperationJSON{{
+			Action: "refresh",
+			Plug:   interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:   interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}}, `unsupported connection operation: "refresh"`},
+		{[]connectionOperationJSON{{
+			Action: "connect",
+			Plug:   interfaces.PlugRef{Snap: "consumer", Name: "missing"},
+			Slot:   interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}}, `cannot connect consumer:missing to producer:slot: snap "consumer" has no plug named "missing"`},
+		{[]connectionOperationJSON{{
+			Action: "disconnect",
+			Plug:   interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:   interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}}, `cannot disconnect consumer:plug from producer:slot: cannot disconnect consumer:plug from producer:slot, it is not connected`},
+	} {
+		rsp := s.postConnections(c, &connectionsAction{Action: "batch", Operations: t.ops})
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
 }
 
-func (s *apiSuite) TestStateChangesReady(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+func (s *apiSuite) TestAutoConnectSnap(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	action := &interfaceAction{
+		Action: "auto-connect",
+		Snap:   "consumer",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
 
-	// Setup
-	d := newTestDaemon(c)
 	st := d.overlord.State()
 	st.Lock()
-	setupChanges(st)
+	chg := st.Change(rsp.Change)
 	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-	// Execute
-	req, err := http.NewRequest("GET", "/v2/changes?select=ready", nil)
-	c.Assert(err, check.IsNil)
-	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	<-chg.Ready()
 
-	// Verify
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Result, check.HasLen, 1)
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Err(), check.IsNil)
+	c.Check(chg.Kind(), check.Equals, "auto-connect-snap")
+	c.Check(chg.Summary(), check.Equals, `Auto-connect plugs and slots of snap "consumer"`)
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), check.Equals, "auto-connect")
 
-	res, err := rsp.MarshalJSON()
-	c.Assert(err, check.IsNil)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"consumer"})
+}
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"err":"[^"]+".*`)
+func (s *apiSuite) TestAutoConnectSnapErrors(c *check.C) {
+	s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+
+	for _, t := range []struct {
+		action *interfaceAction
+		err    string
+	}{
+		{&interfaceAction{Action: "auto-connect"}, `snap name is required to auto-connect`},
+		{&interfaceAction{Action: "auto-connect", Snap: "consumer", Plugs: []plugJSON{{Snap: "consumer", Name: "plug"}}}, `cannot auto-connect specific plugs or slots`},
+		{&interfaceAction{Action: "auto-connect", Snap: "other"}, `snap "other" is not installed`},
+	} {
+		text, err := json.Marshal(t.action)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
 }
 
-func (s *apiSuite) TestStateChangesForSnapName(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+func (s *apiSuite) TestForgetConnections(c *check.C) {
+	d := s.daemon(c)
 
-	// Setup
-	d := newTestDaemon(c)
 	st := d.overlord.State()
 	st.Lock()
-	setupChanges(st)
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "interfaces.removal-grace-period", "24h"), check.IsNil)
+	tr.Commit()
+	st.Set("removed-conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "removed": time.Now()},
+		"other:plug producer:slot":    map[string]interface{}{"interface": "test", "removed": time.Now()},
+	})
 	st.Unlock()
 
-	// Execute
-	req, err := http.NewRequest("GET", "/v2/changes?for=funky-snap-name&select=all", nil)
+	text, err := json.Marshal(&interfaceAction{Action: "forget-connections", Snap: "consumer"})
 	c.Assert(err, check.IsNil)
-	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]int{"forgotten": 1})
 
-	// Verify
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Result, check.FitsTypeOf, []*changeInfo(nil))
+	st.Lock()
+	defer st.Unlock()
+	var removed map[string]interface{}
+	c.Assert(st.Get("removed-conns", &removed), check.IsNil)
+	c.Check(removed, check.HasLen, 1)
+	c.Check(removed["other:plug producer:slot"], check.NotNil)
+}
 
-	res := rsp.Result.([]*changeInfo)
-	c.Assert(res, check.HasLen, 1)
-	c.Check(res[0].Kind, check.Equals, `install`)
+func (s *apiSuite) TestForgetConnectionsErrors(c *check.C) {
+	s.daemon(c)
 
-	_, err = rsp.MarshalJSON()
-	c.Assert(err, check.IsNil)
+	for _, t := range []struct {
+		action *interfaceAction
+		err    string
+	}{
+		{&interfaceAction{Action: "forget-connections"}, `snap name is required to forget connections`},
+		{&interfaceAction{Action: "forget-connections", Snap: "consumer", Plugs: []plugJSON{{Snap: "consumer", Name: "plug"}}}, `cannot forget specific plugs or slots`},
+	} {
+		text, err := json.Marshal(t.action)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
 }
 
-func (s *apiSuite) TestStateChange(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+func (s *apiSuite) TestConnectPlugFailureInterfaceMismatch(c *check.C) {
+	d := s.daemon(c)
 
-	// Setup
-	d := newTestDaemon(c)
-	st := d.overlord.State()
-	st.Lock()
-	ids := setupChanges(st)
-	chg := st.Change(ids[0])
-	chg.Set("api-data", map[string]int{"n": 42})
-	st.Unlock()
-	s.vars = map[string]string{"id": ids[0]}
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "different"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, differentProducerYaml)
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/change/"+ids[0], nil)
+	action := &interfaceAction{
+		Action: "connect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
 	c.Assert(err, check.IsNil)
-	rsp := getChange(stateChangeCmd, req, nil).(*resp)
 	rec := httptest.NewRecorder()
-	rsp.ServeHTTP(rec, req)
-
-	// Verify
-	c.Check(rec.Code, check.Equals, 200)
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.NotNil)
-
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
 	var body map[string]interface{}
 	err = json.Unmarshal(rec.Body.Bytes(), &body)
 	c.Check(err, check.IsNil)
-	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
-		"id":         ids[0],
-		"kind":       "install",
-		"summary":    "install...",
-		"status":     "Do",
-		"ready":      false,
-		"spawn-time": "2016-04-21T01:02:03Z",
-		"tasks": []interface{}{
-			map[string]interface{}{
-				"id":         ids[2],
-				"kind":       "download",
-				"summary":    "1...",
-				"status":     "Do",
-				"log":        []interface{}{"2016-04-21T01:02:03Z INFO l11", "2016-04-21T01:02:03Z INFO l12"},
-				"progress":   map[string]interface{}{"label": "", "done": 0., "total": 1.},
-				"spawn-time": "2016-04-21T01:02:03Z",
-			},
-			map[string]interface{}{
-				"id":         ids[3],
-				"kind":       "activate",
-				"summary":    "2...",
-				"status":     "Do",
-				"progress":   map[string]interface{}{"label": "", "done": 0., "total": 1.},
-				"spawn-time": "2016-04-21T01:02:03Z",
-			},
-		},
-		"data": map[string]interface{}{
-			"n": float64(42),
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "cannot connect consumer:plug (\"test\" interface) to producer:slot (\"different\" interface)",
 		},
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
 	})
+	repo := d.overlord.InterfaceManager().Repository()
+	plug := repo.Plug("consumer", "plug")
+	slot := repo.Slot("producer", "slot")
+	c.Assert(plug.Connections, check.HasLen, 0)
+	c.Assert(slot.Connections, check.HasLen, 0)
 }
 
-func (s *apiSuite) TestStateChangeAbort(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
-
-	soon := 0
-	ensureStateSoon = func(st *state.State) {
-		soon++
-	}
-
-	// Setup
-	d := newTestDaemon(c)
-	st := d.overlord.State()
-	st.Lock()
-	ids := setupChanges(st)
-	st.Unlock()
-	s.vars = map[string]string{"id": ids[0]}
+func (s *apiSuite) TestConnectPlugFailureNoSuchPlug(c *check.C) {
+	d := s.daemon(c)
 
-	buf := bytes.NewBufferString(`{"action": "abort"}`)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	// there is no consumer, no plug defined
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, consumerYaml)
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/changes/"+ids[0], buf)
+	action := &interfaceAction{
+		Action: "connect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "missingplug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
 	c.Assert(err, check.IsNil)
-	rsp := abortChange(stateChangeCmd, req, nil).(*resp)
 	rec := httptest.NewRecorder()
-	rsp.ServeHTTP(rec, req)
-
-	// Ensure scheduled
-	c.Check(soon, check.Equals, 1)
-
-	// Verify
-	c.Check(rec.Code, check.Equals, 200)
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.NotNil)
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
 
 	var body map[string]interface{}
 	err = json.Unmarshal(rec.Body.Bytes(), &body)
 	c.Check(err, check.IsNil)
-	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
-		"id":         ids[0],
-		"kind":       "install",
-		"summary":    "install...",
-		"status":     "Hold",
-		"ready":      true,
-		"spawn-time": "2016-04-21T01:02:03Z",
-		"ready-time": "2016-04-21T01:02:03Z",
-		"tasks": []interface{}{
-			map[string]interface{}{
-				"id":         ids[2],
-				"kind":       "download",
-				"summary":    "1...",
-				"status":     "Hold",
-				"log":        []interface{}{"2016-04-21T01:02:03Z INFO l11", "2016-04-21T01:02:03Z INFO l12"},
-				"progress":   map[string]interface{}{"label": "", "done": 1., "total": 1.},
-				"spawn-time": "2016-04-21T01:02:03Z",
-				"ready-time": "2016-04-21T01:02:03Z",
-			},
-			map[string]interface{}{
-				"id":         ids[3],
-				"kind":       "activate",
-				"summary":    "2...",
-				"status":     "Hold",
-				"progress":   map[string]interface{}{"label": "", "done": 1., "total": 1.},
-				"spawn-time": "2016-04-21T01:02:03Z",
-				"ready-time": "2016-04-21T01:02:03Z",
-			},
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "snap \"consumer\" has no plug named \"missingplug\"",
 		},
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
 	})
-}
 
-func (s *apiSuite) TestStateChangeAbortIsReady(c *check.C) {
-	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
-	defer restore()
+	repo := d.overlord.InterfaceManager().Repository()
+	slot := repo.Slot("producer", "slot")
+	c.Assert(slot.Connections, check.HasLen, 0)
+}
 
-	// Setup
-	d := newTestDaemon(c)
-	st := d.overlord.State()
-	st.Lock()
-	ids := setupChanges(st)
-	st.Change(ids[0]).SetStatus(state.DoneStatus)
-	st.Unlock()
-	s.vars = map[string]string{"id": ids[0]}
+func (s *apiSuite) TestConnectPlugFailureNoSuchSlot(c *check.C) {
+	d := s.daemon(c)
 
-	buf := bytes.NewBufferString(`{"action": "abort"}`)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	// there is no producer, no slot defined
 
-	// Execute
-	req, err := http.NewRequest("POST", "/v2/changes/"+ids[0], buf)
+	action := &interfaceAction{
+		Action: "connect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "missingslot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
 	c.Assert(err, check.IsNil)
-	rsp := abortChange(stateChangeCmd, req, nil).(*resp)
 	rec := httptest.NewRecorder()
-	rsp.ServeHTTP(rec, req)
-
-	// Verify
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
 	c.Check(rec.Code, check.Equals, 400)
-	c.Check(rsp.Status, check.Equals, 400)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result, check.NotNil)
 
 	var body map[string]interface{}
 	err = json.Unmarshal(rec.Body.Bytes(), &body)
 	c.Check(err, check.IsNil)
-	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
-		"message": fmt.Sprintf("cannot abort change %s with nothing pending", ids[0]),
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "snap \"producer\" has no slot named \"missingslot\"",
+		},
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
 	})
+
+	repo := d.overlord.InterfaceManager().Repository()
+	plug := repo.Plug("consumer", "plug")
+	c.Assert(plug.Connections, check.HasLen, 0)
 }
 
-const validBuyInput = `{
-		  "snap-id": "the-snap-id-1234abcd",
-		  "snap-name": "the snap name",
-		  "price": 1.23,
-		  "currency": "EUR"
-		}`
+func (s *apiSuite) testDisconnect(c *check.C, plugSnap, plugName, slotSnap, slotName string) {
+	d := s.daemon(c)
 
-var validBuyOptions = &store.BuyOptions{
-	SnapID:   "the-snap-id-1234abcd",
-	Price:    1.23,
-	Currency: "EUR",
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	repo := d.overlord.InterfaceManager().Repository()
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	c.Assert(repo.Connect(connRef), check.IsNil)
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	action := &interfaceAction{
+		Action: "disconnect",
+		Plugs:  []plugJSON{{Snap: plugSnap, Name: plugName}},
+		Slots:  []slotJSON{{Snap: slotSnap, Name: slotName}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	err = chg.Err()
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	plug := repo.Plug("consumer", "plug")
+	slot := repo.Slot("producer", "slot")
+	c.Assert(plug.Connections, check.HasLen, 0)
+	c.Assert(slot.Connections, check.HasLen, 0)
 }
 
-var buyTests = []struct {
-	input                string
-	result               *store.BuyResult
-	err                  error
-	expectedStatus       int
-	expectedResult       interface{}
-	expectedResponseType ResponseType
-	expectedBuyOptions   *store.BuyOptions
-}{
-	{
-		// Success
-		input: validBuyInput,
-		result: &store.BuyResult{
-			State: "Complete",
-		},
-		expectedStatus: 200,
-		expectedResult: &store.BuyResult{
-			State: "Complete",
-		},
-		expectedResponseType: ResponseTypeSync,
-		expectedBuyOptions:   validBuyOptions,
-	},
-	{
-		// Fail with internal error
-		input: `{
-		  "snap-id": "the-snap-id-1234abcd",
-		  "price": 1.23,
-		  "currency": "EUR"
-		}`,
-		err:                  fmt.Errorf("internal error banana"),
-		expectedStatus:       500,
-		expectedResponseType: ResponseTypeError,
-		expectedResult: &errorResult{
-			Message: "internal error banana",
+func (s *apiSuite) TestDisconnectPlugSuccess(c *check.C) {
+	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
+}
+
+func (s *apiSuite) TestDisconnectPlugSuccessWithEmptyPlug(c *check.C) {
+	s.testDisconnect(c, "", "", "producer", "slot")
+}
+
+func (s *apiSuite) TestDisconnectPlugSuccessWithEmptySlot(c *check.C) {
+	s.testDisconnect(c, "consumer", "plug", "", "")
+}
+
+func (s *apiSuite) TestDisconnectPlugFailureNoSuchPlug(c *check.C) {
+	s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	// there is no consumer, no plug defined
+	s.mockSnap(c, producerYaml)
+
+	action := &interfaceAction{
+		Action: "disconnect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "snap \"consumer\" has no plug named \"plug\"",
 		},
-		expectedBuyOptions: &store.BuyOptions{
-			SnapID:   "the-snap-id-1234abcd",
-			Price:    1.23,
-			Currency: "EUR",
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
+	})
+}
+
+func (s *apiSuite) TestDisconnectPlugFailureNoSuchSlot(c *check.C) {
+	s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	// there is no producer, no slot defined
+
+	action := &interfaceAction{
+		Action: "disconnect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "snap \"producer\" has no slot named \"slot\"",
 		},
-	},
-	{
-		// Fail with unauthenticated error
-		input:                validBuyInput,
-		err:                  store.ErrUnauthenticated,
-		expectedStatus:       400,
-		expectedResponseType: ResponseTypeError,
-		expectedResult: &errorResult{
-			Message: "you need to log in first",
-			Kind:    "login-required",
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
+	})
+}
+
+func (s *apiSuite) TestDisconnectPlugFailureNotConnected(c *check.C) {
+	s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	action := &interfaceAction{
+		Action: "disconnect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "cannot disconnect consumer:plug from producer:slot, it is not connected",
 		},
-		expectedBuyOptions: validBuyOptions,
-	},
-	{
-		// Fail with TOS not accepted
-		input:                validBuyInput,
-		err:                  store.ErrTOSNotAccepted,
-		expectedStatus:       400,
-		expectedResponseType: ResponseTypeError,
-		expectedResult: &errorResult{
-			Message: "terms of service not accepted",
-			Kind:    "terms-not-accepted",
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
+	})
+}
+
+func (s *apiSuite) TestUnsupportedInterfaceRequest(c *check.C) {
+	buf := bytes.NewBuffer([]byte(`garbage`))
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "cannot decode request body into an interface action: invalid character 'g' looking for beginning of value",
 		},
-		expectedBuyOptions: validBuyOptions,
-	},
-	{
-		// Fail with no payment methods
-		input:                validBuyInput,
-		err:                  store.ErrNoPaymentMethods,
-		expectedStatus:       400,
-		expectedResponseType: ResponseTypeError,
-		expectedResult: &errorResult{
-			Message: "no payment methods",
-			Kind:    "no-payment-methods",
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
+	})
+}
+
+func (s *apiSuite) TestMissingInterfaceAction(c *check.C) {
+	action := &interfaceAction{}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "interface action not specified",
 		},
-		expectedBuyOptions: validBuyOptions,
-	},
-	{
-		// Fail with payment declined
-		input:                validBuyInput,
-		err:                  store.ErrPaymentDeclined,
-		expectedStatus:       400,
-		expectedResponseType: ResponseTypeError,
-		expectedResult: &errorResult{
-			Message: "payment declined",
-			Kind:    "payment-declined",
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
+	})
+}
+
+func (s *apiSuite) TestUnsupportedInterfaceAction(c *check.C) {
+	s.daemon(c)
+	action := &interfaceAction{Action: "foo"}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": "unsupported interface action: \"foo\"",
 		},
-		expectedBuyOptions: validBuyOptions,
-	},
+		"status":      "Bad Request",
+		"status-code": 400.0,
+		"type":        "error",
+	})
 }
 
-func (s *apiSuite) TestBuySnap(c *check.C) {
-	for _, test := range buyTests {
-		s.buyResult = test.result
-		s.err = test.err
+func (s *apiSuite) TestGetAsserts(c *check.C) {
+	s.daemon(c)
+	resp := assertsCmd.GET(assertsCmd, nil, nil).(*resp)
+	c.Check(resp.Status, check.Equals, 200)
+	c.Check(resp.Type, check.Equals, ResponseTypeSync)
+	c.Check(resp.Result, check.DeepEquals, map[string][]string{"types": asserts.TypeNames()})
+}
+
+func assertAdd(st *state.State, a asserts.Assertion) {
+	st.Lock()
+	defer st.Unlock()
+	err := assertstate.Add(st, a)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (s *apiSuite) TestAssertOK(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	st := d.overlord.State()
+	// add store key
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	buf := bytes.NewBuffer(asserts.Encode(acct))
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	c.Assert(err, check.IsNil)
+	rsp := doAssert(assertsCmd, req, nil).(*resp)
+	// Verify (external)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	// Verify (internal)
+	st.Lock()
+	defer st.Unlock()
+	_, err = assertstate.DB(st).Find(asserts.AccountType, map[string]string{
+		"account-id": acct.AccountID(),
+	})
+	c.Check(err, check.IsNil)
+}
+
+func (s *apiSuite) TestAssertStreamOK(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	st := d.overlord.State()
+
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	buf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(buf)
+	err := enc.Encode(acct)
+	c.Assert(err, check.IsNil)
+	err = enc.Encode(s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, check.IsNil)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	c.Assert(err, check.IsNil)
+	rsp := doAssert(assertsCmd, req, nil).(*resp)
+	// Verify (external)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	// Verify (internal)
+	st.Lock()
+	defer st.Unlock()
+	_, err = assertstate.DB(st).Find(asserts.AccountType, map[string]string{
+		"account-id": acct.AccountID(),
+	})
+	c.Check(err, check.IsNil)
+}
+
+func (s *apiSuite) TestAssertInvalid(c *check.C) {
+	// Setup
+	buf := bytes.NewBufferString("blargh")
+	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	// Execute
+	assertsCmd.POST(assertsCmd, req, nil).ServeHTTP(rec, req)
+	// Verify (external)
+	c.Check(rec.Code, check.Equals, 400)
+	c.Check(rec.Body.String(), testutil.Contains,
+		"cannot decode request body into assertions")
+}
+
+func (s *apiSuite) TestAssertError(c *check.C) {
+	s.daemon(c)
+	// Setup
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	buf := bytes.NewBuffer(asserts.Encode(acct))
+	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	// Execute
+	assertsCmd.POST(assertsCmd, req, nil).ServeHTTP(rec, req)
+	// Verify (external)
+	c.Check(rec.Code, check.Equals, 400)
+	c.Check(rec.Body.String(), testutil.Contains, "assert failed")
+}
+
+func (s *apiSuite) TestAssertsFindManyAll(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	// add store key
+	st := d.overlord.State()
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", map[string]interface{}{
+		"account-id": "developer1-id",
+	}, "")
+	assertAdd(st, acct)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions/account", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"assertType": "account"}
+	rec := httptest.NewRecorder()
+	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
+	// Verify
+	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
+	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/x.ubuntu.assertion; bundle=y")
+	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "4")
+	dec := asserts.NewDecoder(rec.Body)
+	a1, err := dec.Decode()
+	c.Assert(err, check.IsNil)
+	c.Check(a1.Type(), check.Equals, asserts.AccountType)
+
+	a2, err := dec.Decode()
+	c.Assert(err, check.IsNil)
+
+	a3, err := dec.Decode()
+	c.Assert(err, check.IsNil)
+
+	a4, err := dec.Decode()
+	c.Assert(err, check.IsNil)
+
+	_, err = dec.Decode()
+	c.Assert(err, check.Equals, io.EOF)
+
+	ids := []string{a1.(*asserts.Account).AccountID(), a2.(*asserts.Account).AccountID(), a3.(*asserts.Account).AccountID(), a4.(*asserts.Account).AccountID()}
+	sort.Strings(ids)
+	c.Check(ids, check.DeepEquals, []string{"can0nical", "canonical", "developer1-id", "generic"})
+}
+
+func (s *apiSuite) TestAssertsFindManyFilter(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	// add store key
+	st := d.overlord.State()
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	assertAdd(st, acct)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions/account?username=developer1", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"assertType": "account"}
+	rec := httptest.NewRecorder()
+	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
+	// Verify
+	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
+	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "1")
+	dec := asserts.NewDecoder(rec.Body)
+	a1, err := dec.Decode()
+	c.Assert(err, check.IsNil)
+	c.Check(a1.Type(), check.Equals, asserts.AccountType)
+	c.Check(a1.(*asserts.Account).Username(), check.Equals, "developer1")
+	c.Check(a1.(*asserts.Account).AccountID(), check.Equals, acct.AccountID())
+	_, err = dec.Decode()
+	c.Check(err, check.Equals, io.EOF)
+}
+
+func (s *apiSuite) TestAssertsFindManyNoResults(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	// add store key
+	st := d.overlord.State()
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	assertAdd(st, acct)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions/account?username=xyzzyx", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"assertType": "account"}
+	rec := httptest.NewRecorder()
+	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
+	// Verify
+	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
+	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "0")
+	dec := asserts.NewDecoder(rec.Body)
+	_, err = dec.Decode()
+	c.Check(err, check.Equals, io.EOF)
+}
+
+func (s *apiSuite) TestAssertsInvalidType(c *check.C) {
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions/foo", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"assertType": "foo"}
+	rec := httptest.NewRecorder()
+	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
+	// Verify
+	c.Check(rec.Code, check.Equals, 400)
+	c.Check(rec.Body.String(), testutil.Contains, "invalid assert type")
+}
+
+func setupChanges(st *state.State) []string {
+	chg1 := st.NewChange("install", "install...")
+	chg1.Set("snap-names", []string{"funky-snap-name"})
+	t1 := st.NewTask("download", "1...")
+	t2 := st.NewTask("activate", "2...")
+	chg1.AddAll(state.NewTaskSet(t1, t2))
+	t1.Logf("l11")
+	t1.Logf("l12")
+	chg2 := st.NewChange("remove", "remove..")
+	t3 := st.NewTask("unlink", "1...")
+	chg2.AddTask(t3)
+	t3.SetStatus(state.ErrorStatus)
+	t3.Errorf("rm failed")
+
+	return []string{chg1.ID(), chg2.ID(), t1.ID(), t2.ID(), t3.ID()}
+}
+
+func (s *apiSuite) TestStateChangesDefaultToInProgress(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 1)
+
+	res, err := rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+}
+
+func (s *apiSuite) TestStateChangesInProgress(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?select=in-progress", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 1)
+
+	res, err := rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+}
+
+func (s *apiSuite) TestStateChangesAll(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?select=all", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 2)
+
+	res, err := rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"err":"[^"]+".*`)
+}
+
+func (s *apiSuite) TestStateChangesReady(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?select=ready", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 1)
+
+	res, err := rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"err":"[^"]+".*`)
+}
+
+func (s *apiSuite) TestStateChangesForSnapName(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?for=funky-snap-name&select=all", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, []*changeInfo(nil))
+
+	res := rsp.Result.([]*changeInfo)
+	c.Assert(res, check.HasLen, 1)
+	c.Check(res[0].Kind, check.Equals, `install`)
+
+	_, err = rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *apiSuite) TestStateChange(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	chg := st.Change(ids[0])
+	chg.Set("api-data", map[string]int{"n": 42})
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[0]}
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/change/"+ids[0], nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChange(stateChangeCmd, req, nil).(*resp)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+
+	// Verify
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.NotNil)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"id":         ids[0],
+		"kind":       "install",
+		"summary":    "install...",
+		"status":     "Do",
+		"ready":      false,
+		"spawn-time": "2016-04-21T01:02:03Z",
+		"tasks": []interface{}{
+			map[string]interface{}{
+				"id":         ids[2],
+				"kind":       "download",
+				"summary":    "1...",
+				"status":     "Do",
+				"log":        []interface{}{"2016-04-21T01:02:03Z INFO l11", "2016-04-21T01:02:03Z INFO l12"},
+				"progress":   map[string]interface{}{"label": "", "done": 0., "total": 1.},
+				"spawn-time": "2016-04-21T01:02:03Z",
+			},
+			map[string]interface{}{
+				"id":         ids[3],
+				"kind":       "activate",
+				"summary":    "2...",
+				"status":     "Do",
+				"progress":   map[string]interface{}{"label": "", "done": 0., "total": 1.},
+				"spawn-time": "2016-04-21T01:02:03Z",
+			},
+		},
+		"data": map[string]interface{}{
+			"n": float64(42),
+		},
+	})
+}
+
+func (s *apiSuite) TestStateChangeAbort(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	soon := 0
+	ensureStateSoon = func(st *state.State) {
+		soon++
+	}
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[0]}
+
+	buf := bytes.NewBufferString(`{"action": "abort"}`)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/changes/"+ids[0], buf)
+	c.Assert(err, check.IsNil)
+	rsp := postChange(stateChangeCmd, req, nil).(*resp)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+
+	// Ensure scheduled
+	c.Check(soon, check.Equals, 1)
+
+	// Verify
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.NotNil)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"id":         ids[0],
+		"kind":       "install",
+		"summary":    "install...",
+		"status":     "Hold",
+		"ready":      true,
+		"spawn-time": "2016-04-21T01:02:03Z",
+		"ready-time": "2016-04-21T01:02:03Z",
+		"tasks": []interface{}{
+			map[string]interface{}{
+				"id":         ids[2],
+				"kind":       "download",
+				"summary":    "1...",
+				"status":     "Hold",
+				"log":        []interface{}{"2016-04-21T01:02:03Z INFO l11", "2016-04-21T01:02:03Z INFO l12"},
+				"progress":   map[string]interface{}{"label": "", "done": 1., "total": 1.},
+				"spawn-time": "2016-04-21T01:02:03Z",
+				"ready-time": "2016-04-21T01:02:03Z",
+			},
+			map[string]interface{}{
+				"id":         ids[3],
+				"kind":       "activate",
+				"summary":    "2...",
+				"status":     "Hold",
+				"progress":   map[string]interface{}{"label": "", "done": 1., "total": 1.},
+				"spawn-time": "2016-04-21T01:02:03Z",
+				"ready-time": "2016-04-21T01:02:03Z",
+			},
+		},
+	})
+}
+
+func (s *apiSuite) TestStateChangeAbortIsReady(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Change(ids[0]).SetStatus(state.DoneStatus)
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[0]}
+
+	buf := bytes.NewBufferString(`{"action": "abort"}`)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/changes/"+ids[0], buf)
+	c.Assert(err, check.IsNil)
+	rsp := postChange(stateChangeCmd, req, nil).(*resp)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+
+	// Verify
+	c.Check(rec.Code, check.Equals, 400)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result, check.NotNil)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"message": fmt.Sprintf("cannot abort change %s with nothing pending", ids[0]),
+	})
+}
+
+func (s *apiSuite) TestStateChangePauseResume(c *check.C) {
+	soon := 0
+	ensureStateSoon = func(st *state.State) {
+		soon++
+	}
+
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[0]}
+
+	post := func(action string) *resp {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": %q}`, action))
+		req, err := http.NewRequest("POST", "/v2/changes/"+ids[0], buf)
+		c.Assert(err, check.IsNil)
+		return postChange(stateChangeCmd, req, nil).(*resp)
+	}
+
+	rsp := post("pause")
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result.(*changeInfo).Paused, check.Equals, true)
+	c.Check(soon, check.Equals, 0)
+
+	st.Lock()
+	c.Check(st.Change(ids[0]).IsPaused(), check.Equals, true)
+	st.Unlock()
+
+	rsp = post("pause")
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, fmt.Sprintf("change %s is already paused", ids[0]))
+
+	rsp = post("resume")
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result.(*changeInfo).Paused, check.Equals, false)
+	c.Check(soon, check.Equals, 1)
+
+	st.Lock()
+	c.Check(st.Change(ids[0]).IsPaused(), check.Equals, false)
+	st.Unlock()
+
+	rsp = post("resume")
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, fmt.Sprintf("cannot resume change %s that is not paused", ids[0]))
+}
+
+func (s *apiSuite) TestStateChangePauseIsReady(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Change(ids[0]).SetStatus(state.DoneStatus)
+	st.Unlock()
+	s.vars = map[string]string{"id": ids[0]}
+
+	buf := bytes.NewBufferString(`{"action": "pause"}`)
+	req, err := http.NewRequest("POST", "/v2/changes/"+ids[0], buf)
+	c.Assert(err, check.IsNil)
+	rsp := postChange(stateChangeCmd, req, nil).(*resp)
+
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, fmt.Sprintf("cannot pause change %s with nothing pending", ids[0]))
+}
+
+const validBuyInput = `{
+		  "snap-id": "the-snap-id-1234abcd",
+		  "snap-name": "the snap name",
+		  "price": 1.23,
+		  "currency": "EUR"
+		}`
+
+var validBuyOptions = &store.BuyOptions{
+	SnapID:   "the-snap-id-1234abcd",
+	Price:    1.23,
+	Currency: "EUR",
+}
+
+var buyTests = []struct {
+	input                string
+	result               *store.BuyResult
+	err                  error
+	expectedStatus       int
+	expectedResult       interface{}
+	expectedResponseType ResponseType
+	expectedBuyOptions   *store.BuyOptions
+}{
+	{
+		// Success
+		input: validBuyInput,
+		result: &store.BuyResult{
+			State: "Complete",
+		},
+		expectedStatus: 200,
+		expectedResult: &store.BuyResult{
+			State: "Complete",
+		},
+		expectedResponseType: ResponseTypeSync,
+		expectedBuyOptions:   validBuyOptions,
+	},
+	{
+		// Fail with internal error
+		input: `{
+		  "snap-id": "the-snap-id-1234abcd",
+		  "price": 1.23,
+		  "currency": "EUR"
+		}`,
+		err:                  fmt.Errorf("internal error banana"),
+		expectedStatus:       500,
+		expectedResponseType: ResponseTypeError,
+		expectedResult: &errorResult{
+			Message: "internal error banana",
+		},
+		expectedBuyOptions: &store.BuyOptions{
+			SnapID:   "the-snap-id-1234abcd",
+			Price:    1.23,
+			Currency: "EUR",
+		},
+	},
+	{
+		// Fail with unauthenticated error
+		input:                validBuyInput,
+		err:                  store.ErrUnauthenticated,
+		expectedStatus:       400,
+		expectedResponseType: ResponseTypeError,
+		expectedResult: &errorResult{
+			Message: "you need to log in first",
+			Kind:    "login-required",
+		},
+		expectedBuyOptions: validBuyOptions,
+	},
+	{
+		// Fail with TOS not accepted
+		input:                validBuyInput,
+		err:                  store.ErrTOSNotAccepted,
+		expectedStatus:       400,
+		expectedResponseType: ResponseTypeError,
+		expectedResult: &errorResult{
+			Message: "terms of service not accepted",
+			Kind:    "terms-not-accepted",
+		},
+		expectedBuyOptions: validBuyOptions,
+	},
+	{
+		// Fail with no payment methods
+		input:                validBuyInput,
+		err:                  store.ErrNoPaymentMethods,
+		expectedStatus:       400,
+		expectedResponseType: ResponseTypeError,
+		expectedResult: &errorResult{
+			Message: "no payment methods",
+			Kind:    "no-payment-methods",
+		},
+		expectedBuyOptions: validBuyOptions,
+	},
+	{
+		// Fail with payment declined
+		input:                validBuyInput,
+		err:                  store.ErrPaymentDeclined,
+		expectedStatus:       400,
+		expectedResponseType: ResponseTypeError,
+		expectedResult: &errorResult{
+			Message: "payment declined",
+			Kind:    "payment-declined",
+		},
+		expectedBuyOptions: validBuyOptions,
+	},
+}
+
+func (s *apiSuite) TestBuySnap(c *check.C) {
+	for _, test := range buyTests {
+		s.buyResult = test.result
+		s.err = test.err
+
+		buf := bytes.NewBufferString(test.input)
+		req, err := http.NewRequest("POST", "/v2/buy", buf)
+		c.Assert(err, check.IsNil)
+
+		state := snapCmd.d.overlord.State()
+		state.Lock()
+		user, err := auth.NewUser(state, "username", "email@test.com", "macaroon", []string{"discharge"})
+		state.Unlock()
+		c.Check(err, check.IsNil)
+
+		rsp := postBuy(buyCmd, req, user).(*resp)
+
+		c.Check(rsp.Status, check.Equals, test.expectedStatus)
+		c.Check(rsp.Type, check.Equals, test.expectedResponseType)
+		c.Assert(rsp.Result, check.FitsTypeOf, test.expectedResult)
+		c.Check(rsp.Result, check.DeepEquals, test.expectedResult)
+
+		c.Check(s.buyOptions, check.DeepEquals, test.expectedBuyOptions)
+		c.Check(s.user, check.Equals, user)
+	}
+}
+
+func (s *apiSuite) TestIsTrue(c *check.C) {
+	form := &multipart.Form{}
+	c.Check(isTrue(form, "foo"), check.Equals, false)
+	for _, f := range []string{"", "false", "0", "False", "f", "try"} {
+		form.Value = map[string][]string{"foo": {f}}
+		c.Check(isTrue(form, "foo"), check.Equals, false, check.Commentf("expected %q to be false", f))
+	}
+	for _, t := range []string{"true", "1", "True", "t"} {
+		form.Value = map[string][]string{"foo": {t}}
+		c.Check(isTrue(form, "foo"), check.Equals, true, check.Commentf("expected %q to be true", t))
+	}
+}
+
+var readyToBuyTests = []struct {
+	input    error
+	status   int
+	respType interface{}
+	response interface{}
+}{
+	{
+		// Success
+		input:    nil,
+		status:   200,
+		respType: ResponseTypeSync,
+		response: true,
+	},
+	{
+		// Not accepted TOS
+		input:    store.ErrTOSNotAccepted,
+		status:   400,
+		respType: ResponseTypeError,
+		response: &errorResult{
+			Message: "terms of service not accepted",
+			Kind:    errorKindTermsNotAccepted,
+		},
+	},
+	{
+		// No payment methods
+		input:    store.ErrNoPaymentMethods,
+		status:   400,
+		respType: ResponseTypeError,
+		response: &errorResult{
+			Message: "no payment methods",
+			Kind:    errorKindNoPaymentMethods,
+		},
+	},
+}
+
+func (s *apiSuite) TestReadyToBuy(c *check.C) {
+	for _, test := range readyToBuyTests {
+		s.err = test.input
+
+		req, err := http.NewRequest("GET", "/v2/buy/ready", nil)
+		c.Assert(err, check.IsNil)
+
+		state := snapCmd.d.overlord.State()
+		state.Lock()
+		user, err := auth.NewUser(state, "username", "email@test.com", "macaroon", []string{"discharge"})
+		state.Unlock()
+		c.Check(err, check.IsNil)
+
+		rsp := readyToBuy(readyToBuyCmd, req, user).(*resp)
+		c.Check(rsp.Status, check.Equals, test.status)
+		c.Check(rsp.Type, check.Equals, test.respType)
+		c.Assert(rsp.Result, check.FitsTypeOf, test.response)
+		c.Check(rsp.Result, check.DeepEquals, test.response)
+	}
+}
+
+var _ = check.Suite(&postCreateUserSuite{})
+
+type postCreateUserSuite struct {
+	apiBaseSuite
+
+	mockUserHome string
+}
+
+func (s *postCreateUserSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.daemon(c)
+	postCreateUserUcrednetGet = func(string) (uint32, uint32, error) {
+		return 100, 0, nil
+	}
+	s.mockUserHome = c.MkDir()
+	userLookup = mkUserLookup(s.mockUserHome)
+}
+
+func (s *postCreateUserSuite) TearDownTest(c *check.C) {
+	s.apiBaseSuite.TearDownTest(c)
+
+	postCreateUserUcrednetGet = ucrednetGet
+	userLookup = user.Lookup
+	osutilAddUser = osutil.AddUser
+	storeUserInfo = store.UserInfo
+}
+
+func mkUserLookup(userHomeDir string) func(string) (*user.User, error) {
+	return func(username string) (*user.User, error) {
+		cur, err := user.Current()
+		cur.Username = username
+		cur.HomeDir = userHomeDir
+		return cur, err
+	}
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserNoSSHKeys(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	storeUserInfo = func(user string) (*store.User, error) {
+		c.Check(user, check.Equals, "popper@lse.ac.uk")
+		return &store.User{
+			Username:         "karl",
+			OpenIDIdentifier: "xxyyzz",
+		}, nil
+	}
+
+	buf := bytes.NewBufferString(`{"email": "popper@lse.ac.uk"}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot create user for "popper@lse.ac.uk": no ssh keys found`)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUser(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	storeUserInfo = func(user string) (*store.User, error) {
+		c.Check(user, check.Equals, "popper@lse.ac.uk")
+		return &store.User{
+			Username:         "karl",
+			SSHKeys:          []string{"ssh1", "ssh2"},
+			OpenIDIdentifier: "xxyyzz",
+		}, nil
+	}
+	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
+		c.Check(username, check.Equals, "karl")
+		c.Check(opts.SSHKeys, check.DeepEquals, []string{"ssh1", "ssh2"})
+		c.Check(opts.Gecos, check.Equals, "popper@lse.ac.uk,xxyyzz")
+		c.Check(opts.Sudoer, check.Equals, false)
+		return nil
+	}
+
+	buf := bytes.NewBufferString(`{"email": "popper@lse.ac.uk"}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	expected := &userResponseData{
+		Username: "karl",
+		SSHKeys:  []string{"ssh1", "ssh2"},
+	}
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.FitsTypeOf, expected)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+
+	// user was setup in state
+	state := s.d.overlord.State()
+	state.Lock()
+	user, err := auth.User(state, 1)
+	state.Unlock()
+	c.Check(err, check.IsNil)
+	c.Check(user.Username, check.Equals, "karl")
+	c.Check(user.Email, check.Equals, "popper@lse.ac.uk")
+	c.Check(user.Macaroon, check.NotNil)
+	// auth saved to user home dir
+	outfile := filepath.Join(s.mockUserHome, ".snap", "auth.json")
+	c.Check(osutil.FileExists(outfile), check.Equals, true)
+	content, err := ioutil.ReadFile(outfile)
+	c.Check(err, check.IsNil)
+	c.Check(string(content), check.Equals, fmt.Sprintf(`{"macaroon":"%s"}`, user.Macaroon))
+}
+
+func (s *postCreateUserSuite) TestGetUserDetailsFromAssertionModelNotFound(c *check.C) {
+	st := s.d.overlord.State()
+	email := "foo@example.com"
+
+	username, opts, err := getUserDetailsFromAssertion(st, email)
+	c.Check(username, check.Equals, "")
+	c.Check(opts, check.IsNil)
+	c.Check(err, check.ErrorMatches, `cannot add system-user "foo@example.com": cannot get model assertion: no state entry for key`)
+}
+
+func (s *postCreateUserSuite) setupSigner(accountID string, signerPrivKey asserts.PrivateKey) *assertstest.SigningDB {
+	st := s.d.overlord.State()
+
+	// create fake brand signature
+	signerSigning := assertstest.NewSigningDB(accountID, signerPrivKey)
+
+	signerAcct := assertstest.NewAccount(s.storeSigning, accountID, map[string]interface{}{
+		"account-id":   accountID,
+		"verification": "certified",
+	}, "")
+	s.storeSigning.Add(signerAcct)
+	assertAdd(st, signerAcct)
+
+	signerAccKey := assertstest.NewAccountKey(s.storeSigning, signerAcct, nil, signerPrivKey.PublicKey(), "")
+	s.storeSigning.Add(signerAccKey)
+	assertAdd(st, signerAccKey)
+
+	return signerSigning
+}
+
+var (
+	brandPrivKey, _   = assertstest.GenerateKey(752)
+	partnerPrivKey, _ = assertstest.GenerateKey(752)
+	unknownPrivKey, _ = assertstest.GenerateKey(752)
+)
+
+func (s *postCreateUserSuite) makeSystemUsers(c *check.C, systemUsers []map[string]interface{}) {
+	st := s.d.overlord.State()
+
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+
+	brandSigning := s.setupSigner("my-brand", brandPrivKey)
+	partnerSigning := s.setupSigner("partner", partnerPrivKey)
+	unknownSigning := s.setupSigner("unknown", unknownPrivKey)
+
+	signers := map[string]*assertstest.SigningDB{
+		"my-brand": brandSigning,
+		"partner":  partnerSigning,
+		"unknown":  unknownSigning,
+	}
+
+	model, err := brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":                "16",
+		"authority-id":          "my-brand",
+		"brand-id":              "my-brand",
+		"model":                 "my-model",
+		"architecture":          "amd64",
+		"gadget":                "pc",
+		"kernel":                "pc-kernel",
+		"required-snaps":        []interface{}{"required-snap1"},
+		"system-user-authority": []interface{}{"my-brand", "partner"},
+		"timestamp":             time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	model = model.(*asserts.Model)
+
+	// now add model related stuff to the system
+	assertAdd(st, model)
+
+	for _, suMap := range systemUsers {
+		su, err := signers[suMap["authority-id"].(string)].Sign(asserts.SystemUserType, suMap, nil, "")
+		c.Assert(err, check.IsNil)
+		su = su.(*asserts.SystemUser)
+		// now add system-user assertion to the system
+		assertAdd(st, su)
+	}
+	// create fake device
+	st.Lock()
+	err = auth.SetDevice(st, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-model",
+		Serial: "serialserial",
+	})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+}
+
+var goodUser = map[string]interface{}{
+	"authority-id": "my-brand",
+	"brand-id":     "my-brand",
+	"email":        "foo@bar.com",
+	"series":       []interface{}{"16", "18"},
+	"models":       []interface{}{"my-model", "other-model"},
+	"name":         "Boring Guy",
+	"username":     "guy",
+	"password":     "$6$salt$hash",
+	"since":        time.Now().Format(time.RFC3339),
+	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
+}
+
+var partnerUser = map[string]interface{}{
+	"authority-id": "partner",
+	"brand-id":     "my-brand",
+	"email":        "p@partner.com",
+	"series":       []interface{}{"16", "18"},
+	"models":       []interface{}{"my-model"},
+	"name":         "Partner Guy",
+	"username":     "partnerguy",
+	"password":     "$6$salt$hash",
+	"since":        time.Now().Format(time.RFC3339),
+	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
+}
+
+var badUser = map[string]interface{}{
+	// bad user (not valid for this model)
+	"authority-id": "my-brand",
+	"brand-id":     "my-brand",
+	"email":        "foobar@bar.com",
+	"series":       []interface{}{"16", "18"},
+	"models":       []interface{}{"non-of-the-models-i-have"},
+	"name":         "Random Gal",
+	"username":     "gal",
+	"password":     "$6$salt$hash",
+	"since":        time.Now().Format(time.RFC3339),
+	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
+}
+
+var unknownUser = map[string]interface{}{
+	"authority-id": "unknown",
+	"brand-id":     "my-brand",
+	"email":        "x@partner.com",
+	"series":       []interface{}{"16", "18"},
+	"models":       []interface{}{"my-model"},
+	"name":         "XGuy",
+	"username":     "xguy",
+	"password":     "$6$salt$hash",
+	"since":        time.Now().Format(time.RFC3339),
+	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
+}
+
+func (s *postCreateUserSuite) TestGetUserDetailsFromAssertionHappy(c *check.C) {
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+
+	// ensure that if we query the details from the assert DB we get
+	// the expected user
+	st := s.d.overlord.State()
+	username, opts, err := getUserDetailsFromAssertion(st, "foo@bar.com")
+	c.Check(username, check.Equals, "guy")
+	c.Check(opts, check.DeepEquals, &osutil.AddUserOptions{
+		Gecos:    "foo@bar.com,Boring Guy",
+		Password: "$6$salt$hash",
+	})
+	c.Check(err, check.IsNil)
+}
+
+// FIXME: These tests all look similar, with small deltas. Would be
+// nice to transform them into a table that is just the deltas, and
+// run on a loop.
+func (s *postCreateUserSuite) TestPostCreateUserFromAssertion(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+
+	// mock the calls that create the user
+	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
+		c.Check(username, check.Equals, "guy")
+		c.Check(opts.Gecos, check.Equals, "foo@bar.com,Boring Guy")
+		c.Check(opts.Sudoer, check.Equals, false)
+		c.Check(opts.Password, check.Equals, "$6$salt$hash")
+		return nil
+	}
+
+	defer func() {
+		osutilAddUser = osutil.AddUser
+	}()
+
+	// do it!
+	buf := bytes.NewBufferString(`{"email": "foo@bar.com","known":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	expected := &userResponseData{
+		Username: "guy",
+	}
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.FitsTypeOf, expected)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+
+	// ensure the user was added to the state
+	st := s.d.overlord.State()
+	st.Lock()
+	users, err := auth.Users(st)
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+	c.Check(users, check.HasLen, 1)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnown(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser, partnerUser, badUser, unknownUser})
+
+	// mock the calls that create the user
+	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
+		switch username {
+		case "guy":
+			c.Check(opts.Gecos, check.Equals, "foo@bar.com,Boring Guy")
+		case "partnerguy":
+			c.Check(opts.Gecos, check.Equals, "p@partner.com,Partner Guy")
+		default:
+			c.Logf("unexpected username %q", username)
+			c.Fail()
+		}
+		c.Check(opts.Sudoer, check.Equals, false)
+		c.Check(opts.Password, check.Equals, "$6$salt$hash")
+		return nil
+	}
+	defer func() {
+		osutilAddUser = osutil.AddUser
+	}()
+
+	// do it!
+	buf := bytes.NewBufferString(`{"known":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	// note that we get a list here instead of a single
+	// userResponseData item
+	c.Check(rsp.Result, check.FitsTypeOf, []userResponseData{})
+	seen := map[string]bool{}
+	for _, u := range rsp.Result.([]userResponseData) {
+		seen[u.Username] = true
+		c.Check(u, check.DeepEquals, userResponseData{Username: u.Username})
+	}
+	c.Check(seen, check.DeepEquals, map[string]bool{
+		"guy":        true,
+		"partnerguy": true,
+	})
+
+	// ensure the user was added to the state
+	st := s.d.overlord.State()
+	st.Lock()
+	users, err := auth.Users(st)
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+	c.Check(users, check.HasLen, 2)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnownClassicErrors(c *check.C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+
+	postCreateUserUcrednetGet = func(string) (uint32, uint32, error) {
+		return 100, 0, nil
+	}
+	defer func() {
+		postCreateUserUcrednetGet = ucrednetGet
+	}()
+
+	// do it!
+	buf := bytes.NewBufferString(`{"known":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot create user: device is a classic system`)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnownButOwnedErrors(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+
+	st := s.d.overlord.State()
+	st.Lock()
+	_, err := auth.NewUser(st, "username", "email@test.com", "macaroon", []string{"discharge"})
+	st.Unlock()
+	c.Check(err, check.IsNil)
+
+	// do it!
+	buf := bytes.NewBufferString(`{"known":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot create user: device already managed`)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnownButOwned(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+
+	st := s.d.overlord.State()
+	st.Lock()
+	_, err := auth.NewUser(st, "username", "email@test.com", "macaroon", []string{"discharge"})
+	st.Unlock()
+	c.Check(err, check.IsNil)
+
+	// mock the calls that create the user
+	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
+		c.Check(username, check.Equals, "guy")
+		c.Check(opts.Gecos, check.Equals, "foo@bar.com,Boring Guy")
+		c.Check(opts.Sudoer, check.Equals, false)
+		c.Check(opts.Password, check.Equals, "$6$salt$hash")
+		return nil
+	}
+	defer func() {
+		osutilAddUser = osutil.AddUser
+	}()
+
+	// do it!
+	buf := bytes.NewBufferString(`{"known":true,"force-managed":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+
+	// note that we get a list here instead of a single
+	// userResponseData item
+	expected := []userResponseData{
+		{Username: "guy"},
+	}
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.FitsTypeOf, expected)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+}
+
+func (s *postCreateUserSuite) TestUsersEmpty(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/users", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getUsers(usersCmd, req, nil).(*resp)
+
+	expected := []userResponseData{}
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.FitsTypeOf, expected)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+}
+
+func (s *postCreateUserSuite) TestUsersHasUser(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	u, err := auth.NewUser(st, "someuser", "mymail@test.com", "macaroon", []string{"discharge"})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/users", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getUsers(usersCmd, req, nil).(*resp)
+
+	expected := []userResponseData{
+		{ID: u.ID, Username: u.Username, Email: u.Email},
+	}
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.FitsTypeOf, expected)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+}
+
+func (s *postCreateUserSuite) TestSysInfoIsManaged(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	_, err := auth.NewUser(st, "someuser", "mymail@test.com", "macaroon", []string{"discharge"})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := sysInfo(sysInfoCmd, req, nil).(*resp)
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(map[string]interface{})["managed"], check.Equals, true)
+}
+
+// aliases
+
+func (s *apiSuite) TestAliasSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+	c.Assert(err, check.IsNil)
+	d := s.daemon(c)
+
+	s.mockSnap(c, aliasYaml)
+
+	oldAutoAliases := snapstate.AutoAliases
+	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
+		return nil, nil
+	}
+	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-		buf := bytes.NewBufferString(test.input)
-		req, err := http.NewRequest("POST", "/v2/buy", buf)
-		c.Assert(err, check.IsNil)
+	action := &aliasAction{
+		Action: "alias",
+		Snap:   "alias-snap",
+		App:    "app",
+		Alias:  "alias1",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
 
-		state := snapCmd.d.overlord.State()
-		state.Lock()
-		user, err := auth.NewUser(state, "username", "email@test.com", "macaroon", []string{"discharge"})
-		state.Unlock()
-		c.Check(err, check.IsNil)
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-		rsp := postBuy(buyCmd, req, user).(*resp)
+	<-chg.Ready()
 
-		c.Check(rsp.Status, check.Equals, test.expectedStatus)
-		c.Check(rsp.Type, check.Equals, test.expectedResponseType)
-		c.Assert(rsp.Result, check.FitsTypeOf, test.expectedResult)
-		c.Check(rsp.Result, check.DeepEquals, test.expectedResult)
+	st.Lock()
+	err = chg.Err()
+	st.Unlock()
+	c.Assert(err, check.IsNil)
 
-		c.Check(s.buyOptions, check.DeepEquals, test.expectedBuyOptions)
-		c.Check(s.user, check.Equals, user)
-	}
+	// sanity check
+	c.Check(osutil.IsSymlink(filepath.Join(dirs.SnapBinariesDir, "alias1")), check.Equals, true)
 }
 
-func (s *apiSuite) TestIsTrue(c *check.C) {
-	form := &multipart.Form{}
-	c.Check(isTrue(form, "foo"), check.Equals, false)
-	for _, f := range []string{"", "false", "0", "False", "f", "try"} {
-		form.Value = map[string][]string{"foo": {f}}
-		c.Check(isTrue(form, "foo"), check.Equals, false, check.Commentf("expected %q to be false", f))
+func (s *apiSuite) TestAliasErrors(c *check.C) {
+	s.daemon(c)
+
+	errScenarios := []struct {
+		mangle func(*aliasAction)
+		err    string
+	}{
+		{func(a *aliasAction) { a.Action = "" }, `unsupported alias action: ""`},
+		{func(a *aliasAction) { a.Action = "what" }, `unsupported alias action: "what"`},
+		{func(a *aliasAction) { a.Snap = "lalala" }, `cannot find snap "lalala"`},
+		{func(a *aliasAction) { a.Alias = ".foo" }, `invalid alias name: ".foo"`},
+		{func(a *aliasAction) { a.Aliases = []string{"baz"} }, `cannot interpret request, snaps can no longer be expected to declare their aliases`},
 	}
-	for _, t := range []string{"true", "1", "True", "t"} {
-		form.Value = map[string][]string{"foo": {t}}
-		c.Check(isTrue(form, "foo"), check.Equals, true, check.Commentf("expected %q to be true", t))
+
+	for _, scen := range errScenarios {
+		action := &aliasAction{
+			Action: "alias",
+			Snap:   "alias-snap",
+			App:    "app",
+			Alias:  "alias1",
+		}
+		scen.mangle(action)
+
+		text, err := json.Marshal(action)
+		c.Assert(err, check.IsNil)
+		buf := bytes.NewBuffer(text)
+		req, err := http.NewRequest("POST", "/v2/aliases", buf)
+		c.Assert(err, check.IsNil)
+
+		rsp := changeAliases(aliasesCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, scen.err)
 	}
 }
 
-var readyToBuyTests = []struct {
-	input    error
-	status   int
-	respType interface{}
-	response interface{}
-}{
-	{
-		// Success
-		input:    nil,
-		status:   200,
-		respType: ResponseTypeSync,
-		response: true,
-	},
-	{
-		// Not accepted TOS
-		input:    store.ErrTOSNotAccepted,
-		status:   400,
-		respType: ResponseTypeError,
-		response: &errorResult{
-			Message: "terms of service not accepted",
-			Kind:    errorKindTermsNotAccepted,
-		},
-	},
-	{
-		// No payment methods
-		input:    store.ErrNoPaymentMethods,
-		status:   400,
-		respType: ResponseTypeError,
-		response: &errorResult{
-			Message: "no payment methods",
-			Kind:    errorKindNoPaymentMethods,
-		},
-	},
-}
+func (s *apiSuite) TestUnaliasSnapSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+	c.Assert(err, check.IsNil)
+	d := s.daemon(c)
 
-func (s *apiSuite) TestReadyToBuy(c *check.C) {
-	for _, test := range readyToBuyTests {
-		s.err = test.input
+	s.mockSnap(c, aliasYaml)
 
-		req, err := http.NewRequest("GET", "/v2/buy/ready", nil)
-		c.Assert(err, check.IsNil)
+	oldAutoAliases := snapstate.AutoAliases
+	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
+		return nil, nil
+	}
+	defer func() { snapstate.AutoAliases = oldAutoAliases }()
 
-		state := snapCmd.d.overlord.State()
-		state.Lock()
-		user, err := auth.NewUser(state, "username", "email@test.com", "macaroon", []string{"discharge"})
-		state.Unlock()
-		c.Check(err, check.IsNil)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-		rsp := readyToBuy(readyToBuyCmd, req, user).(*resp)
-		c.Check(rsp.Status, check.Equals, test.status)
-		c.Check(rsp.Type, check.Equals, test.respType)
-		c.Assert(rsp.Result, check.FitsTypeOf, test.response)
-		c.Check(rsp.Result, check.DeepEquals, test.response)
+	action := &aliasAction{
+		Action: "unalias",
+		Snap:   "alias-snap",
 	}
-}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
 
-var _ = check.Suite(&postCreateUserSuite{})
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	c.Check(chg.Summary(), check.Equals, `Disable all aliases for snap "alias-snap"`)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-type postCreateUserSuite struct {
-	apiBaseSuite
+	<-chg.Ready()
 
-	mockUserHome string
+	st.Lock()
+	defer st.Unlock()
+	err = chg.Err()
+	c.Assert(err, check.IsNil)
+
+	// sanity check
+	var snapst snapstate.SnapState
+	err = snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	c.Check(snapst.AutoAliasesDisabled, check.Equals, true)
 }
 
-func (s *postCreateUserSuite) SetUpTest(c *check.C) {
-	s.apiBaseSuite.SetUpTest(c)
+func (s *apiSuite) TestUnaliasDWIMSnapSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+	c.Assert(err, check.IsNil)
+	d := s.daemon(c)
 
-	s.daemon(c)
-	postCreateUserUcrednetGet = func(string) (uint32, uint32, error) {
-		return 100, 0, nil
+	s.mockSnap(c, aliasYaml)
+
+	oldAutoAliases := snapstate.AutoAliases
+	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
+		return nil, nil
 	}
-	s.mockUserHome = c.MkDir()
-	userLookup = mkUserLookup(s.mockUserHome)
-}
+	defer func() { snapstate.AutoAliases = oldAutoAliases }()
 
-func (s *postCreateUserSuite) TearDownTest(c *check.C) {
-	s.apiBaseSuite.TearDownTest(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-	postCreateUserUcrednetGet = ucrednetGet
-	userLookup = user.Lookup
-	osutilAddUser = osutil.AddUser
-	storeUserInfo = store.UserInfo
+	action := &aliasAction{
+		Action: "unalias",
+		Snap:   "alias-snap",
+		Alias:  "alias-snap",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	c.Check(chg.Summary(), check.Equals, `Disable all aliases for snap "alias-snap"`)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	err = chg.Err()
+	c.Assert(err, check.IsNil)
+
+	// sanity check
+	var snapst snapstate.SnapState
+	err = snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	c.Check(snapst.AutoAliasesDisabled, check.Equals, true)
 }
 
-func mkUserLookup(userHomeDir string) func(string) (*user.User, error) {
-	return func(username string) (*user.User, error) {
-		cur, err := user.Current()
-		cur.Username = username
-		cur.HomeDir = userHomeDir
-		return cur, err
-	}
-}
+func (s *apiSuite) TestUnaliasAliasSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+	c.Assert(err, check.IsNil)
+	d := s.daemon(c)
 
-func (s *postCreateUserSuite) TestPostCreateUserNoSSHKeys(c *check.C) {
-	restore := release.MockOnClassic(false)
-	defer restore()
+	s.mockSnap(c, aliasYaml)
 
-	storeUserInfo = func(user string) (*store.User, error) {
-		c.Check(user, check.Equals, "popper@lse.ac.uk")
-		return &store.User{
-			Username:         "karl",
-			OpenIDIdentifier: "xxyyzz",
-		}, nil
+	oldAutoAliases := snapstate.AutoAliases
+	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
+		return nil, nil
 	}
+	defer func() { snapstate.AutoAliases = oldAutoAliases }()
 
-	buf := bytes.NewBufferString(`{"email": "popper@lse.ac.uk"}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	action := &aliasAction{
+		Action: "alias",
+		Snap:   "alias-snap",
+		App:    "app",
+		Alias:  "alias1",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
 	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot create user for "popper@lse.ac.uk": no ssh keys found`)
-}
+	<-chg.Ready()
 
-func (s *postCreateUserSuite) TestPostCreateUser(c *check.C) {
-	restore := release.MockOnClassic(false)
-	defer restore()
+	st.Lock()
+	err = chg.Err()
+	st.Unlock()
+	c.Assert(err, check.IsNil)
 
-	storeUserInfo = func(user string) (*store.User, error) {
-		c.Check(user, check.Equals, "popper@lse.ac.uk")
-		return &store.User{
-			Username:         "karl",
-			SSHKeys:          []string{"ssh1", "ssh2"},
-			OpenIDIdentifier: "xxyyzz",
-		}, nil
-	}
-	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
-		c.Check(username, check.Equals, "karl")
-		c.Check(opts.SSHKeys, check.DeepEquals, []string{"ssh1", "ssh2"})
-		c.Check(opts.Gecos, check.Equals, "popper@lse.ac.uk,xxyyzz")
-		c.Check(opts.Sudoer, check.Equals, false)
-		return nil
+	// unalias
+	action = &aliasAction{
+		Action: "unalias",
+		Alias:  "alias1",
 	}
-
-	buf := bytes.NewBufferString(`{"email": "popper@lse.ac.uk"}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	text, err = json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf = bytes.NewBuffer(text)
+	req, err = http.NewRequest("POST", "/v2/aliases", buf)
 	c.Assert(err, check.IsNil)
+	rec = httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id = body["change"].(string)
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	st.Lock()
+	chg = st.Change(id)
+	c.Check(chg.Summary(), check.Equals, `Remove manual alias "alias1" for snap "alias-snap"`)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-	expected := &userResponseData{
-		Username: "karl",
-		SSHKeys:  []string{"ssh1", "ssh2"},
-	}
+	<-chg.Ready()
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.FitsTypeOf, expected)
-	c.Check(rsp.Result, check.DeepEquals, expected)
+	st.Lock()
+	defer st.Unlock()
+	err = chg.Err()
+	c.Assert(err, check.IsNil)
 
-	// user was setup in state
-	state := s.d.overlord.State()
-	state.Lock()
-	user, err := auth.User(state, 1)
-	state.Unlock()
-	c.Check(err, check.IsNil)
-	c.Check(user.Username, check.Equals, "karl")
-	c.Check(user.Email, check.Equals, "popper@lse.ac.uk")
-	c.Check(user.Macaroon, check.NotNil)
-	// auth saved to user home dir
-	outfile := filepath.Join(s.mockUserHome, ".snap", "auth.json")
-	c.Check(osutil.FileExists(outfile), check.Equals, true)
-	content, err := ioutil.ReadFile(outfile)
-	c.Check(err, check.IsNil)
-	c.Check(string(content), check.Equals, fmt.Sprintf(`{"macaroon":"%s"}`, user.Macaroon))
+	// sanity check
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBinariesDir, "alias1")), check.Equals, false)
 }
 
-func (s *postCreateUserSuite) TestGetUserDetailsFromAssertionModelNotFound(c *check.C) {
-	st := s.d.overlord.State()
-	email := "foo@example.com"
+func (s *apiSuite) TestUnaliasDWIMAliasSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+	c.Assert(err, check.IsNil)
+	d := s.daemon(c)
 
-	username, opts, err := getUserDetailsFromAssertion(st, email)
-	c.Check(username, check.Equals, "")
-	c.Check(opts, check.IsNil)
-	c.Check(err, check.ErrorMatches, `cannot add system-user "foo@example.com": cannot get model assertion: no state entry for key`)
-}
+	s.mockSnap(c, aliasYaml)
 
-func (s *postCreateUserSuite) setupSigner(accountID string, signerPrivKey asserts.PrivateKey) *assertstest.SigningDB {
-	st := s.d.overlord.State()
+	oldAutoAliases := snapstate.AutoAliases
+	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
+		return nil, nil
+	}
+	defer func() { snapstate.AutoAliases = oldAutoAliases }()
 
-	// create fake brand signature
-	signerSigning := assertstest.NewSigningDB(accountID, signerPrivKey)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
 
-	signerAcct := assertstest.NewAccount(s.storeSigning, accountID, map[string]interface{}{
-		"account-id":   accountID,
-		"verification": "certified",
-	}, "")
-	s.storeSigning.Add(signerAcct)
-	assertAdd(st, signerAcct)
+	action := &aliasAction{
+		Action: "alias",
+		Snap:   "alias-snap",
+		App:    "app",
+		Alias:  "alias1",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
 
-	signerAccKey := assertstest.NewAccountKey(s.storeSigning, signerAcct, nil, signerPrivKey.PublicKey(), "")
-	s.storeSigning.Add(signerAccKey)
-	assertAdd(st, signerAccKey)
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-	return signerSigning
-}
+	<-chg.Ready()
 
-var (
-	brandPrivKey, _   = assertstest.GenerateKey(752)
-	partnerPrivKey, _ = assertstest.GenerateKey(752)
-	unknownPrivKey, _ = assertstest.GenerateKey(752)
-)
+	st.Lock()
+	err = chg.Err()
+	st.Unlock()
+	c.Assert(err, check.IsNil)
 
-func (s *postCreateUserSuite) makeSystemUsers(c *check.C, systemUsers []map[string]interface{}) {
-	st := s.d.overlord.State()
+	// DWIM unalias an alias
+	action = &aliasAction{
+		Action: "unalias",
+		Snap:   "alias1",
+		Alias:  "alias1",
+	}
+	text, err = json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf = bytes.NewBuffer(text)
+	req, err = http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec = httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id = body["change"].(string)
 
-	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+	st.Lock()
+	chg = st.Change(id)
+	c.Check(chg.Summary(), check.Equals, `Remove manual alias "alias1" for snap "alias-snap"`)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
 
-	brandSigning := s.setupSigner("my-brand", brandPrivKey)
-	partnerSigning := s.setupSigner("partner", partnerPrivKey)
-	unknownSigning := s.setupSigner("unknown", unknownPrivKey)
+	<-chg.Ready()
 
-	signers := map[string]*assertstest.SigningDB{
-		"my-brand": brandSigning,
-		"partner":  partnerSigning,
-		"unknown":  unknownSigning,
-	}
+	st.Lock()
+	defer st.Unlock()
+	err = chg.Err()
+	c.Assert(err, check.IsNil)
 
-	model, err := brandSigning.Sign(asserts.ModelType, map[string]interface{}{
-		"series":                "16",
-		"authority-id":          "my-brand",
-		"brand-id":              "my-brand",
-		"model":                 "my-model",
-		"architecture":          "amd64",
-		"gadget":                "pc",
-		"kernel":                "pc-kernel",
-		"required-snaps":        []interface{}{"required-snap1"},
-		"system-user-authority": []interface{}{"my-brand", "partner"},
-		"timestamp":             time.Now().Format(time.RFC3339),
-	}, nil, "")
+	// sanity check
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBinariesDir, "alias1")), check.Equals, false)
+}
+
+func (s *apiSuite) TestPreferSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
 	c.Assert(err, check.IsNil)
-	model = model.(*asserts.Model)
+	d := s.daemon(c)
 
-	// now add model related stuff to the system
-	assertAdd(st, model)
+	s.mockSnap(c, aliasYaml)
 
-	for _, suMap := range systemUsers {
-		su, err := signers[suMap["authority-id"].(string)].Sign(asserts.SystemUserType, suMap, nil, "")
-		c.Assert(err, check.IsNil)
-		su = su.(*asserts.SystemUser)
-		// now add system-user assertion to the system
-		assertAdd(st, su)
+	oldAutoAliases := snapstate.AutoAliases
+	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
+		return nil, nil
 	}
-	// create fake device
+	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	action := &aliasAction{
+		Action: "prefer",
+		Snap:   "alias-snap",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.overlord.State()
 	st.Lock()
-	err = auth.SetDevice(st, &auth.DeviceState{
-		Brand:  "my-brand",
-		Model:  "my-model",
-		Serial: "serialserial",
-	})
+	chg := st.Change(id)
+	c.Check(chg.Summary(), check.Equals, `Prefer aliases of snap "alias-snap"`)
 	st.Unlock()
-	c.Assert(err, check.IsNil)
-}
+	c.Assert(chg, check.NotNil)
 
-var goodUser = map[string]interface{}{
-	"authority-id": "my-brand",
-	"brand-id":     "my-brand",
-	"email":        "foo@bar.com",
-	"series":       []interface{}{"16", "18"},
-	"models":       []interface{}{"my-model", "other-model"},
-	"name":         "Boring Guy",
-	"username":     "guy",
-	"password":     "$6$salt$hash",
-	"since":        time.Now().Format(time.RFC3339),
-	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
-}
+	<-chg.Ready()
 
-var partnerUser = map[string]interface{}{
-	"authority-id": "partner",
-	"brand-id":     "my-brand",
-	"email":        "p@partner.com",
-	"series":       []interface{}{"16", "18"},
-	"models":       []interface{}{"my-model"},
-	"name":         "Partner Guy",
-	"username":     "partnerguy",
-	"password":     "$6$salt$hash",
-	"since":        time.Now().Format(time.RFC3339),
-	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
-}
+	st.Lock()
+	defer st.Unlock()
+	err = chg.Err()
+	c.Assert(err, check.IsNil)
 
-var badUser = map[string]interface{}{
-	// bad user (not valid for this model)
-	"authority-id": "my-brand",
-	"brand-id":     "my-brand",
-	"email":        "foobar@bar.com",
-	"series":       []interface{}{"16", "18"},
-	"models":       []interface{}{"non-of-the-models-i-have"},
-	"name":         "Random Gal",
-	"username":     "gal",
-	"password":     "$6$salt$hash",
-	"since":        time.Now().Format(time.RFC3339),
-	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
+	// sanity check
+	var snapst snapstate.SnapState
+	err = snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	c.Check(snapst.AutoAliasesDisabled, check.Equals, false)
 }
 
-var unknownUser = map[string]interface{}{
-	"authority-id": "unknown",
-	"brand-id":     "my-brand",
-	"email":        "x@partner.com",
-	"series":       []interface{}{"16", "18"},
-	"models":       []interface{}{"my-model"},
-	"name":         "XGuy",
-	"username":     "xguy",
-	"password":     "$6$salt$hash",
-	"since":        time.Now().Format(time.RFC3339),
-	"until":        time.Now().Add(24 * 30 * time.Hour).Format(time.RFC3339),
-}
+func (s *apiSuite) TestAliases(c *check.C) {
+	d := s.daemon(c)
 
-func (s *postCreateUserSuite) TestGetUserDetailsFromAssertionHappy(c *check.C) {
-	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+	st := d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "alias-snap1", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap1", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias1": {Manual: "cmd1x", Auto: "cmd1"},
+			"alias2": {Auto: "cmd2"},
+		},
+	})
+	snapstate.Set(st, "alias-snap2", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap2", Revision: snap.R(12)},
+		},
+		Current:             snap.R(12),
+		Active:              true,
+		AutoAliasesDisabled: true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias2": {Auto: "cmd2"},
+			"alias3": {Manual: "cmd3"},
+			"alias4": {Manual: "cmd4x", Auto: "cmd4"},
+		},
+	})
+	st.Unlock()
 
-	// ensure that if we query the details from the assert DB we get
-	// the expected user
-	st := s.d.overlord.State()
-	username, opts, err := getUserDetailsFromAssertion(st, "foo@bar.com")
-	c.Check(username, check.Equals, "guy")
-	c.Check(opts, check.DeepEquals, &osutil.AddUserOptions{
-		Gecos:    "foo@bar.com,Boring Guy",
-		Password: "$6$salt$hash",
+	req, err := http.NewRequest("GET", "/v2/aliases", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getAliases(aliasesCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]map[string]aliasStatus{
+		"alias-snap1": {
+			"alias1": {
+				Command: "alias-snap1.cmd1x",
+				Status:  "manual",
+				Manual:  "cmd1x",
+				Auto:    "cmd1",
+			},
+			"alias2": {
+				Command: "alias-snap1.cmd2",
+				Status:  "auto",
+				Auto:    "cmd2",
+			},
+		},
+		"alias-snap2": {
+			"alias2": {
+				Command: "alias-snap2.cmd2",
+				Status:  "disabled",
+				Auto:    "cmd2",
+			},
+			"alias3": {
+				Command: "alias-snap2.cmd3",
+				Status:  "manual",
+				Manual:  "cmd3",
+			},
+			"alias4": {
+				Command: "alias-snap2.cmd4x",
+				Status:  "manual",
+				Manual:  "cmd4x",
+				Auto:    "cmd4",
+			},
+		},
 	})
-	c.Check(err, check.IsNil)
+
 }
 
-// FIXME: These tests all look similar, with small deltas. Would be
-// nice to transform them into a table that is just the deltas, and
-// run on a loop.
-func (s *postCreateUserSuite) TestPostCreateUserFromAssertion(c *check.C) {
-	restore := release.MockOnClassic(false)
-	defer restore()
+func (s *apiSuite) TestInstallUnaliased(c *check.C) {
+	var calledFlags snapstate.Flags
 
-	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+	snapstateInstall = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		calledFlags = flags
 
-	// mock the calls that create the user
-	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
-		c.Check(username, check.Equals, "guy")
-		c.Check(opts.Gecos, check.Equals, "foo@bar.com,Boring Guy")
-		c.Check(opts.Sudoer, check.Equals, false)
-		c.Check(opts.Password, check.Equals, "$6$salt$hash")
-		return nil
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
 	}
 
-	defer func() {
-		osutilAddUser = osutil.AddUser
-	}()
+	d := s.daemon(c)
+	inst := &snapInstruction{
+		Action: "install",
+		// Install the snap without enabled automatic aliases
+		Unaliased: true,
+		Snaps:     []string{"fake"},
+	}
 
-	// do it!
-	buf := bytes.NewBufferString(`{"email": "foo@bar.com","known":true}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
-	c.Assert(err, check.IsNil)
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	_, _, err := inst.dispatch()(inst, st)
+	c.Check(err, check.IsNil)
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	c.Check(calledFlags.Unaliased, check.Equals, true)
+}
 
-	expected := &userResponseData{
-		Username: "guy",
-	}
+func (s *apiSuite) TestSplitQS(c *check.C) {
+	c.Check(splitQS("foo,bar"), check.DeepEquals, []string{"foo", "bar"})
+	c.Check(splitQS("foo , bar"), check.DeepEquals, []string{"foo", "bar"})
+	c.Check(splitQS("foo ,, bar"), check.DeepEquals, []string{"foo", "bar"})
+	c.Check(splitQS(""), check.HasLen, 0)
+	c.Check(splitQS(","), check.HasLen, 0)
+}
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.FitsTypeOf, expected)
-	c.Check(rsp.Result, check.DeepEquals, expected)
+var _ = check.Suite(&postDebugSuite{})
 
-	// ensure the user was added to the state
-	st := s.d.overlord.State()
-	st.Lock()
-	users, err := auth.Users(st)
-	c.Assert(err, check.IsNil)
-	st.Unlock()
-	c.Check(users, check.HasLen, 1)
+type postDebugSuite struct {
+	apiBaseSuite
 }
 
-func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnown(c *check.C) {
-	restore := release.MockOnClassic(false)
-	defer restore()
-
-	s.makeSystemUsers(c, []map[string]interface{}{goodUser, partnerUser, badUser, unknownUser})
+func (s *postDebugSuite) TestPostDebugEnsureStateSoon(c *check.C) {
+	s.daemonWithOverlordMock(c)
 
-	// mock the calls that create the user
-	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
-		switch username {
-		case "guy":
-			c.Check(opts.Gecos, check.Equals, "foo@bar.com,Boring Guy")
-		case "partnerguy":
-			c.Check(opts.Gecos, check.Equals, "p@partner.com,Partner Guy")
-		default:
-			c.Logf("unexpected username %q", username)
-			c.Fail()
-		}
-		c.Check(opts.Sudoer, check.Equals, false)
-		c.Check(opts.Password, check.Equals, "$6$salt$hash")
-		return nil
+	soon := 0
+	ensureStateSoon = func(st *state.State) {
+		soon++
+		ensureStateSoonImpl(st)
 	}
-	defer func() {
-		osutilAddUser = osutil.AddUser
-	}()
 
-	// do it!
-	buf := bytes.NewBufferString(`{"known":true}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	buf := bytes.NewBufferString(`{"action": "ensure-state-soon"}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
 
 	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	// note that we get a list here instead of a single
-	// userResponseData item
-	c.Check(rsp.Result, check.FitsTypeOf, []userResponseData{})
-	seen := map[string]bool{}
-	for _, u := range rsp.Result.([]userResponseData) {
-		seen[u.Username] = true
-		c.Check(u, check.DeepEquals, userResponseData{Username: u.Username})
-	}
-	c.Check(seen, check.DeepEquals, map[string]bool{
-		"guy":        true,
-		"partnerguy": true,
-	})
-
-	// ensure the user was added to the state
-	st := s.d.overlord.State()
-	st.Lock()
-	users, err := auth.Users(st)
-	c.Assert(err, check.IsNil)
-	st.Unlock()
-	c.Check(users, check.HasLen, 2)
+	c.Check(rsp.Result, check.Equals, true)
+	c.Check(soon, check.Equals, 1)
 }
 
-func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnownClassicErrors(c *check.C) {
-	restore := release.MockOnClassic(true)
-	defer restore()
-
-	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
-
-	postCreateUserUcrednetGet = func(string) (uint32, uint32, error) {
-		return 100, 0, nil
-	}
-	defer func() {
-		postCreateUserUcrednetGet = ucrednetGet
-	}()
+func (s *postDebugSuite) TestPostDebugGetBaseDeclaration(c *check.C) {
+	_ = s.daemon(c)
 
-	// do it!
-	buf := bytes.NewBufferString(`{"known":true}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	buf := bytes.NewBufferString(`{"action": "get-base-declaration"}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot create user: device is a classic system`)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(map[string]interface{})["base-declaration"],
+		testutil.Contains, "type: base-declaration")
 }
 
-func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnownButOwnedErrors(c *check.C) {
-	restore := release.MockOnClassic(false)
-	defer restore()
+func (s *apiSuite) TestGetMetrics(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	d.overlord.State().TaskMetrics().Record("setup-profiles", 2*time.Second, true)
 
-	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+	req, err := http.NewRequest("GET", "/v2/metrics", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getMetrics(metricsCmd, req, nil).(*resp)
 
-	st := s.d.overlord.State()
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &metricsInfo{
+		Tasks: []metrics.Summary{{
+			Name:     "setup-profiles",
+			Count:    1,
+			Failures: 1,
+			Total:    2 * time.Second,
+			P50:      2 * time.Second,
+			P90:      2 * time.Second,
+			P99:      2 * time.Second,
+			Max:      2 * time.Second,
+		}},
+	})
+}
+
+func (s *apiSuite) mockOwnerSnap(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	st := d.overlord.State()
 	st.Lock()
-	_, err := auth.NewUser(st, "username", "email@test.com", "macaroon", []string{"discharge"})
-	st.Unlock()
-	c.Check(err, check.IsNil)
+	defer st.Unlock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(1)},
+			{RealName: "foo", Revision: snap.R(2)},
+		},
+		Current: snap.R(2),
+	})
+}
 
-	// do it!
-	buf := bytes.NewBufferString(`{"known":true}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+func (s *apiSuite) getRoutineOwner(c *check.C, query string) *resp {
+	req, err := http.NewRequest("GET", "/v2/routine/owner?"+query, nil)
 	c.Assert(err, check.IsNil)
+	return getRoutineOwner(routineOwnerCmd, req, nil).(*resp)
+}
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+func (s *apiSuite) TestRoutineOwnerPath(c *check.C) {
+	s.mockOwnerSnap(c)
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot create user: device already managed`)
+	for _, t := range []struct {
+		path string
+		rev  snap.Revision
+	}{
+		{"/snap/foo/1/bin/foo", snap.R(1)},
+		{"/snap/foo/2", snap.R(2)},
+		{"/snap/foo/current/meta/snap.yaml", snap.R(2)},
+		{"/snap/foo", snap.R(2)},
+		{"/snap/foo/1/../2/bin", snap.R(2)},
+	} {
+		path := filepath.Join(dirs.GlobalRootDir, t.path)
+		rsp := s.getRoutineOwner(c, url.Values{"path": {path}}.Encode())
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf(t.path))
+		c.Check(rsp.Result, check.DeepEquals, &snapOwner{Snap: "foo", Revision: t.rev}, check.Commentf(t.path))
+	}
 }
 
-func (s *postCreateUserSuite) TestPostCreateUserFromAssertionAllKnownButOwned(c *check.C) {
-	restore := release.MockOnClassic(false)
-	defer restore()
+func (s *apiSuite) TestRoutineOwnerPathNotOwned(c *check.C) {
+	s.mockOwnerSnap(c)
 
-	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+	for _, path := range []string{"/snap/foo/3/bin", "/snap/foo/potato", "/snap/bar/1", "/snap", "/etc/passwd", "/snap/foo/../bar/1"} {
+		path := filepath.Join(dirs.GlobalRootDir, path)
+		rsp := s.getRoutineOwner(c, url.Values{"path": {path}}.Encode())
+		c.Check(rsp.Status, check.Equals, 404, check.Commentf(path))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, fmt.Sprintf("no snap owns path %q", path))
+	}
+}
 
-	st := s.d.overlord.State()
-	st.Lock()
-	_, err := auth.NewUser(st, "username", "email@test.com", "macaroon", []string{"discharge"})
-	st.Unlock()
-	c.Check(err, check.IsNil)
+func (s *apiSuite) TestRoutineOwnerPid(c *check.C) {
+	s.mockOwnerSnap(c)
 
-	// mock the calls that create the user
-	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
-		c.Check(username, check.Equals, "guy")
-		c.Check(opts.Gecos, check.Equals, "foo@bar.com,Boring Guy")
-		c.Check(opts.Sudoer, check.Equals, false)
-		c.Check(opts.Password, check.Equals, "$6$salt$hash")
-		return nil
+	for i, t := range []struct {
+		cgroup string
+		app    string
+	}{
+		{"2:freezer:/snap.foo\n1:name=systemd:/user.slice/user-1000.slice/session-2.scope\n", ""},
+		{"3:freezer:/\n1:name=systemd:/system.slice/snap.foo.svc.service\n", "svc"},
+		// unified hierarchy
+		{"0::/snap.foo\n", ""},
+		{"0::/system.slice/snap.foo.svc.service\n", "svc"},
+	} {
+		pid := 100 + i
+		cgroup := filepath.Join(dirs.GlobalRootDir, "/proc", strconv.Itoa(pid), "cgroup")
+		c.Assert(os.MkdirAll(filepath.Dir(cgroup), 0755), check.IsNil)
+		c.Assert(ioutil.WriteFile(cgroup, []byte(t.cgroup), 0644), check.IsNil)
+
+		rsp := s.getRoutineOwner(c, fmt.Sprintf("pid=%d", pid))
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+		c.Check(rsp.Result, check.DeepEquals, &snapOwner{Snap: "foo", Revision: snap.R(2), App: t.app})
 	}
-	defer func() {
-		osutilAddUser = osutil.AddUser
-	}()
+}
 
-	// do it!
-	buf := bytes.NewBufferString(`{"known":true,"force-managed":true}`)
-	req, err := http.NewRequest("POST", "/v2/create-user", buf)
-	c.Assert(err, check.IsNil)
+func (s *apiSuite) TestRoutineOwnerPidNotOwned(c *check.C) {
+	s.mockOwnerSnap(c)
 
-	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	cgroup := filepath.Join(dirs.GlobalRootDir, "/proc/100/cgroup")
+	c.Assert(os.MkdirAll(filepath.Dir(cgroup), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(cgroup, []byte("2:freezer:/\n1:name=systemd:/system.slice/cron.service\n"), 0644), check.IsNil)
+	cgroup = filepath.Join(dirs.GlobalRootDir, "/proc/101/cgroup")
+	c.Assert(os.MkdirAll(filepath.Dir(cgroup), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(cgroup, []byte("2:freezer:/snap.bar\n"), 0644), check.IsNil)
 
-	// note that we get a list here instead of a single
-	// userResponseData item
-	expected := []userResponseData{
-		{Username: "guy"},
+	for _, t := range []struct {
+		query  string
+		status int
+		msg    string
+	}{
+		{"pid=100", 404, "process 100 is not running as part of a snap"},
+		{"pid=101", 404, "process 101 is not running as part of an installed snap"},
+		{"pid=102", 404, "no process with pid 102"},
+		{"pid=potato", 400, `invalid pid "potato"`},
+		{"pid=-1", 400, `invalid pid "-1"`},
+		{"", 400, "exactly one of path or pid must be given"},
+		{"pid=1&path=/snap/foo", 400, "exactly one of path or pid must be given"},
+		{"path=snap/foo", 400, `cannot find owner of path "snap/foo": not an absolute path`},
+	} {
+		rsp := s.getRoutineOwner(c, t.query)
+		c.Check(rsp.Status, check.Equals, t.status, check.Commentf(t.query))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.msg, check.Commentf(t.query))
 	}
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.FitsTypeOf, expected)
-	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
-func (s *postCreateUserSuite) TestUsersEmpty(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/users", nil)
+func (s *postDebugSuite) TestPostDebugStartupTimings(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	timings := d.overlord.StartupTimings()
+	timings.Record("some-step", time.Now(), time.Second)
+
+	buf := bytes.NewBufferString(`{"action": "startup-timings"}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
 
-	rsp := getUsers(usersCmd, req, nil).(*resp)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
 
-	expected := []userResponseData{}
 	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.FitsTypeOf, expected)
-	c.Check(rsp.Result, check.DeepEquals, expected)
+	c.Check(rsp.Result, check.DeepEquals, timings.Spans())
 }
 
-func (s *postCreateUserSuite) TestUsersHasUser(c *check.C) {
-	st := s.d.overlord.State()
-	st.Lock()
-	u, err := auth.NewUser(st, "someuser", "mymail@test.com", "macaroon", []string{"discharge"})
-	st.Unlock()
-	c.Assert(err, check.IsNil)
+func (s *postDebugSuite) TestPostDebugCleanupOrphans(c *check.C) {
+	s.daemon(c)
 
-	req, err := http.NewRequest("GET", "/v2/users", nil)
-	c.Assert(err, check.IsNil)
+	orphanDir := filepath.Join(dirs.SnapDataDir, "orphan-snap")
+	c.Assert(os.MkdirAll(orphanDir, 0755), check.IsNil)
 
-	rsp := getUsers(usersCmd, req, nil).(*resp)
+	for _, dryRun := range []bool{true, false} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "cleanup-orphans", "params": {"dry-run": %v}}`, dryRun))
+		req, err := http.NewRequest("POST", "/v2/debug", buf)
+		c.Assert(err, check.IsNil)
 
-	expected := []userResponseData{
-		{ID: u.ID, Username: u.Username, Email: u.Email},
+		rsp := postDebug(debugCmd, req, nil).(*resp)
+
+		c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+		c.Check(rsp.Result, check.DeepEquals, []*snapstate.Orphan{
+			{Kind: "data", Snap: "orphan-snap", Path: orphanDir},
+		})
+		c.Check(osutil.IsDirectory(orphanDir), check.Equals, dryRun)
 	}
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.FitsTypeOf, expected)
-	c.Check(rsp.Result, check.DeepEquals, expected)
-}
 
-func (s *postCreateUserSuite) TestSysInfoIsManaged(c *check.C) {
-	st := s.d.overlord.State()
-	st.Lock()
-	_, err := auth.NewUser(st, "someuser", "mymail@test.com", "macaroon", []string{"discharge"})
-	st.Unlock()
+	// nothing left
+	buf := bytes.NewBufferString(`{"action": "cleanup-orphans"}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Result, check.DeepEquals, []*snapstate.Orphan{})
+}
 
-	req, err := http.NewRequest("GET", "/v2/system-info", nil)
-	c.Assert(err, check.IsNil)
+func (s *apiSuite) TestPostDebugConnectivity(c *check.C) {
+	s.daemon(c)
 
-	rsp := sysInfo(sysInfoCmd, req, nil).(*resp)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result.(map[string]interface{})["managed"], check.Equals, true)
-}
+	buf := bytes.NewBufferString(`{"action": "connectivity", "params": {"snap": "consumer", "plug": "plug"}}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
 
-// aliases
+	rsp := postDebug(debugCmd, req, nil).(*resp)
 
-func (s *apiSuite) TestAliasSuccess(c *check.C) {
-	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
-	c.Assert(err, check.IsNil)
-	d := s.daemon(c)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+	c.Check(rsp.Result, check.DeepEquals, &ifacestate.PlugConnectivity{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Interface: "test",
+		Verdict:   "auto-connects to producer:slot",
+		Slots: []*ifacestate.SlotConnectivity{{
+			Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+			Candidate: true,
+		}},
+	})
 
-	s.mockSnap(c, aliasYaml)
+	buf = bytes.NewBufferString(`{"action": "connectivity", "params": {"snap": "consumer", "plug": "unknown"}}`)
+	req, err = http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
+	rsp = postDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot explain connectivity: snap "consumer" has no "unknown" plug`)
+}
 
-	oldAutoAliases := snapstate.AutoAliases
-	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
-		return nil, nil
-	}
-	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+func (s *apiSuite) TestPostDebugInterfaceSpec(c *check.C) {
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{&udev.Backend{}})
+	defer restore()
+	s.daemon(c)
 
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		UDevPermanentPlugCallback: func(spec *udev.Specification, plug *interfaces.Plug) error {
+			spec.AddSnippet(`KERNEL=="foo"`)
+			return nil
+		},
+	})
+	s.mockSnap(c, consumerYaml)
 
-	action := &aliasAction{
-		Action: "alias",
-		Snap:   "alias-snap",
-		App:    "app",
-		Alias:  "alias1",
-	}
-	text, err := json.Marshal(action)
+	buf := bytes.NewBufferString(`{"action": "interface-spec", "params": {"snap": "consumer"}}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+	c.Check(rsp.Result, check.DeepEquals, map[string]map[string]string{
+		"udev": {"consumer": `KERNEL=="foo"`},
+	})
+
+	buf = bytes.NewBufferString(`{"action": "interface-spec", "params": {"snap": "unknown"}}`)
+	req, err = http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
+	rsp = postDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot get interface specifications: snap "unknown" is not installed`)
+}
 
+func (s *apiSuite) TestPostDebugDeclarationDiff(c *check.C) {
+	d := s.daemon(c)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+
+	buf := bytes.NewBufferString(`{"action": "declaration-diff", "params": {"snap": "consumer"}}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+	c.Check(rsp.Result, check.DeepEquals, &ifacestate.DeclarationDiff{Snap: "consumer"})
+
+	diff := &ifacestate.DeclarationDiff{
+		Snap:        "consumer",
+		OldRevision: 1,
+		NewRevision: 2,
+		Changes: []*ifacestate.DeclarationConnChange{{
+			Plug:       interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:       interfaces.SlotRef{Snap: "producer", Name: "slot"},
+			Interface:  "test",
+			WasAllowed: true,
+		}},
+	}
 	st := d.overlord.State()
 	st.Lock()
-	chg := st.Change(id)
+	st.Set("declaration-diffs", map[string]*ifacestate.DeclarationDiff{"consumer": diff})
 	st.Unlock()
-	c.Assert(chg, check.NotNil)
-
-	<-chg.Ready()
 
-	st.Lock()
-	err = chg.Err()
-	st.Unlock()
+	buf = bytes.NewBufferString(`{"action": "declaration-diff", "params": {"snap": "consumer"}}`)
+	req, err = http.NewRequest("POST", "/v2/debug", buf)
 	c.Assert(err, check.IsNil)
+	rsp = postDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+	c.Check(rsp.Result, check.DeepEquals, diff)
 
-	// sanity check
-	c.Check(osutil.IsSymlink(filepath.Join(dirs.SnapBinariesDir, "alias1")), check.Equals, true)
+	buf = bytes.NewBufferString(`{"action": "declaration-diff", "params": {"snap": "unknown"}}`)
+	req, err = http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
+	rsp = postDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot get snap declaration changes: snap "unknown" is not installed`)
 }
 
-func (s *apiSuite) TestAliasErrors(c *check.C) {
+func (s *postDebugSuite) TestPostDebugProfile(c *check.C) {
 	s.daemon(c)
 
-	errScenarios := []struct {
-		mangle func(*aliasAction)
-		err    string
-	}{
-		{func(a *aliasAction) { a.Action = "" }, `unsupported alias action: ""`},
-		{func(a *aliasAction) { a.Action = "what" }, `unsupported alias action: "what"`},
-		{func(a *aliasAction) { a.Snap = "lalala" }, `cannot find snap "lalala"`},
-		{func(a *aliasAction) { a.Alias = ".foo" }, `invalid alias name: ".foo"`},
-		{func(a *aliasAction) { a.Aliases = []string{"baz"} }, `cannot interpret request, snaps can no longer be expected to declare their aliases`},
+	var slept []time.Duration
+	profileSleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { profileSleep = time.Sleep }()
+
+	for _, kind := range []string{"cpu", "heap", "block"} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "profile", "params": {"kind": %q, "duration": 2}}`, kind))
+		req, err := http.NewRequest("POST", "/v2/debug", buf)
+		c.Assert(err, check.IsNil)
+
+		rsp := postDebug(debugCmd, req, nil).(*resp)
+
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+		result := rsp.Result.(map[string]interface{})
+		c.Check(result["kind"], check.Equals, kind)
+		name := result["name"].(string)
+		c.Check(name, check.Matches, kind+`-[0-9]{8}-[0-9]{6}\.[0-9]{6}\.pprof`)
+		c.Check(osutil.FileExists(filepath.Join(dirs.SnapDebugDir, name)), check.Equals, true)
 	}
+	// the heap profile is a snapshot
+	c.Check(slept, check.DeepEquals, []time.Duration{2 * time.Second, 2 * time.Second})
+}
 
-	for _, scen := range errScenarios {
-		action := &aliasAction{
-			Action: "alias",
-			Snap:   "alias-snap",
-			App:    "app",
-			Alias:  "alias1",
-		}
-		scen.mangle(action)
+func (s *postDebugSuite) TestPostDebugProfileErrors(c *check.C) {
+	s.daemon(c)
 
-		text, err := json.Marshal(action)
-		c.Assert(err, check.IsNil)
-		buf := bytes.NewBuffer(text)
-		req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	profileSleep = func(time.Duration) {}
+	defer func() { profileSleep = time.Sleep }()
+
+	for _, t := range []struct {
+		params string
+		status int
+		err    string
+	}{
+		{`{"kind": "foo"}`, 400, `unknown profile kind "foo"`},
+		{`{"kind": "cpu", "duration": -1}`, 400, `profile duration must be positive and at most 5m0s`},
+		{`{"kind": "cpu", "duration": 3600}`, 400, `profile duration must be positive and at most 5m0s`},
+	} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "profile", "params": %s}`, t.params))
+		req, err := http.NewRequest("POST", "/v2/debug", buf)
 		c.Assert(err, check.IsNil)
 
-		rsp := changeAliases(aliasesCmd, req, nil).(*resp)
-		c.Check(rsp.Type, check.Equals, ResponseTypeError)
-		c.Check(rsp.Status, check.Equals, 400)
-		c.Check(rsp.Result.(*errorResult).Message, check.Matches, scen.err)
+		rsp := postDebug(debugCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, t.status)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
 	}
+
+	profiling = 1
+	defer func() { profiling = 0 }()
+	buf := bytes.NewBufferString(`{"action": "profile", "params": {"kind": "heap"}}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
+	rsp := postDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 409)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot capture profile: another profile is being captured")
 }
 
-func (s *apiSuite) TestUnaliasSnapSuccess(c *check.C) {
-	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+func (s *apiSuite) TestGetDebugProfile(c *check.C) {
+	s.daemon(c)
+
+	c.Assert(os.MkdirAll(dirs.SnapDebugDir, 0700), check.IsNil)
+	path := filepath.Join(dirs.SnapDebugDir, "cpu-20181010-101010.000000.pprof")
+	c.Assert(ioutil.WriteFile(path, []byte("profile"), 0600), check.IsNil)
+
+	s.vars = map[string]string{"name": "cpu-20181010-101010.000000.pprof"}
+	req, err := http.NewRequest("GET", "/v2/debug/profiles/cpu-20181010-101010.000000.pprof", nil)
 	c.Assert(err, check.IsNil)
-	d := s.daemon(c)
+	rsp := getDebugProfile(debugProfileCmd, req, nil)
+	c.Check(rsp, check.Equals, FileResponse(path))
 
-	s.mockSnap(c, aliasYaml)
+	s.vars = map[string]string{"name": "other.pprof"}
+	rsp = getDebugProfile(debugProfileCmd, req, nil)
+	c.Check(rsp.(*resp).Status, check.Equals, 404)
 
-	oldAutoAliases := snapstate.AutoAliases
-	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
-		return nil, nil
-	}
-	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+	s.vars = map[string]string{"name": "../state.json"}
+	rsp = getDebugProfile(debugProfileCmd, req, nil)
+	c.Check(rsp.(*resp).Status, check.Equals, 400)
+}
 
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+var _ = check.Suite(&consoleConfSuite{})
 
-	action := &aliasAction{
-		Action: "unalias",
-		Snap:   "alias-snap",
-	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/aliases", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
+type consoleConfSuite struct {
+	apiBaseSuite
 
-	st := d.overlord.State()
-	st.Lock()
-	chg := st.Change(id)
-	c.Check(chg.Summary(), check.Equals, `Disable all aliases for snap "alias-snap"`)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
+	restoreOnClassic func()
+}
 
-	<-chg.Ready()
+func (s *consoleConfSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.restoreOnClassic = release.MockOnClassic(false)
+}
 
-	st.Lock()
-	defer st.Unlock()
-	err = chg.Err()
-	c.Assert(err, check.IsNil)
+func (s *consoleConfSuite) TearDownTest(c *check.C) {
+	s.restoreOnClassic()
+	s.apiBaseSuite.TearDownTest(c)
+}
 
-	// sanity check
-	var snapst snapstate.SnapState
-	err = snapstate.Get(st, "alias-snap", &snapst)
+func (s *consoleConfSuite) TestGetConsoleConf(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	req, err := http.NewRequest("GET", "/v2/console-conf", nil)
 	c.Assert(err, check.IsNil)
-	c.Check(snapst.AutoAliasesDisabled, check.Equals, true)
+
+	rsp := getConsoleConf(consoleConfCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"status": "pending",
+	})
 }
 
-func (s *apiSuite) TestUnaliasDWIMSnapSuccess(c *check.C) {
-	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
-	c.Assert(err, check.IsNil)
-	d := s.daemon(c)
+func (s *consoleConfSuite) TestPostConsoleConf(c *check.C) {
+	s.daemonWithOverlordMock(c)
 
-	s.mockSnap(c, aliasYaml)
+	for _, t := range []struct {
+		action string
+		status string
+	}{
+		{"start", "started"},
+		{"stop", "completed"},
+		{"disable", "disabled"},
+	} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": %q}`, t.action))
+		req, err := http.NewRequest("POST", "/v2/console-conf", buf)
+		c.Assert(err, check.IsNil)
 
-	oldAutoAliases := snapstate.AutoAliases
-	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
-		return nil, nil
+		rsp := postConsoleConf(consoleConfCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf(t.action))
+		c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+			"status": t.status,
+		})
 	}
-	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+	c.Check(osutil.FileExists(dirs.ConsoleConfCompleteFile), check.Equals, true)
+}
 
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+func (s *consoleConfSuite) TestPostConsoleConfErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
 
-	action := &aliasAction{
-		Action: "unalias",
-		Snap:   "alias-snap",
-		Alias:  "alias-snap",
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "frobble"}`, `unknown console-conf action: frobble`},
+		{`{"action": "disable"}`, ``},
+		{`{"action": "start"}`, `cannot start console-conf: console-conf is disabled`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/console-conf", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+
+		rsp := postConsoleConf(consoleConfCmd, req, nil).(*resp)
+		if t.err == "" {
+			c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+			continue
+		}
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
 	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/aliases", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
+}
 
-	st := d.overlord.State()
-	st.Lock()
-	chg := st.Change(id)
-	c.Check(chg.Summary(), check.Equals, `Disable all aliases for snap "alias-snap"`)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
+type appSuite struct {
+	apiBaseSuite
+	cmd *testutil.MockCmd
 
-	<-chg.Ready()
+	infoA, infoB, infoC, infoD *snap.Info
+}
 
-	st.Lock()
-	defer st.Unlock()
-	err = chg.Err()
-	c.Assert(err, check.IsNil)
+var _ = check.Suite(&appSuite{})
 
-	// sanity check
-	var snapst snapstate.SnapState
-	err = snapstate.Get(st, "alias-snap", &snapst)
-	c.Assert(err, check.IsNil)
-	c.Check(snapst.AutoAliasesDisabled, check.Equals, true)
+func (s *appSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.cmd = testutil.MockCommand(c, "systemctl", "").Also("journalctl", "")
+	s.daemon(c)
+	s.infoA = s.mkInstalledInState(c, s.d, "snap-a", "dev", "v1", snap.R(1), true, "apps: {svc1: {daemon: simple}, svc2: {daemon: simple, reload-command: x}}")
+	s.infoB = s.mkInstalledInState(c, s.d, "snap-b", "dev", "v1", snap.R(1), true, "apps: {svc3: {daemon: simple}, cmd1: {}}")
+	s.infoC = s.mkInstalledInState(c, s.d, "snap-c", "dev", "v1", snap.R(1), true, "")
+	s.infoD = s.mkInstalledInState(c, s.d, "snap-d", "dev", "v1", snap.R(1), true, "apps: {cmd2: {}, cmd3: {}}")
+	s.d.overlord.Loop()
 }
 
-func (s *apiSuite) TestUnaliasAliasSuccess(c *check.C) {
-	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+func (s *appSuite) TearDownTest(c *check.C) {
+	s.d.overlord.Stop()
+	s.cmd.Restore()
+	s.apiBaseSuite.TearDownTest(c)
+}
+
+func (s *appSuite) TestSplitAppName(c *check.C) {
+	type T struct {
+		name string
+		snap string
+		app  string
+	}
+
+	for _, x := range []T{
+		{name: "foo.bar", snap: "foo", app: "bar"},
+		{name: "foo", snap: "foo", app: ""},
+		{name: "foo.bar.baz", snap: "foo", app: "bar.baz"},
+		{name: ".", snap: "", app: ""}, // SISO
+	} {
+		snap, app := splitAppName(x.name)
+		c.Check(x.snap, check.Equals, snap, check.Commentf(x.name))
+		c.Check(x.app, check.Equals, app, check.Commentf(x.name))
+	}
+}
+
+func (s *appSuite) TestGetAppsInfo(c *check.C) {
+	svcNames := []string{"snap-a.svc1", "snap-a.svc2", "snap-b.svc3"}
+	for _, name := range svcNames {
+		s.sysctlBufs = append(s.sysctlBufs, []byte(fmt.Sprintf(`
+Id=snap.%s.service
+Type=simple
+ActiveState=active
+UnitFileState=enabled
+`[1:], name)))
+	}
+
+	req, err := http.NewRequest("GET", "/v2/apps", nil)
 	c.Assert(err, check.IsNil)
-	d := s.daemon(c)
 
-	s.mockSnap(c, aliasYaml)
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
+	apps := rsp.Result.([]client.AppInfo)
+	c.Assert(apps, check.HasLen, 6)
 
-	oldAutoAliases := snapstate.AutoAliases
-	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
-		return nil, nil
+	for _, name := range svcNames {
+		snap, app := splitAppName(name)
+		c.Check(apps, testutil.DeepContains, client.AppInfo{
+			Snap:    snap,
+			Name:    app,
+			Daemon:  "simple",
+			Active:  true,
+			Enabled: true,
+		})
+	}
+
+	for _, name := range []string{"snap-b.cmd1", "snap-d.cmd2", "snap-d.cmd3"} {
+		snap, app := splitAppName(name)
+		c.Check(apps, testutil.DeepContains, client.AppInfo{
+			Snap: snap,
+			Name: app,
+		})
+	}
+
+	appNames := make([]string, len(apps))
+	for i, app := range apps {
+		appNames[i] = app.Snap + "." + app.Name
 	}
-	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
+}
 
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+func (s *appSuite) TestGetAppsInfoNames(c *check.C) {
 
-	action := &aliasAction{
-		Action: "alias",
-		Snap:   "alias-snap",
-		App:    "app",
-		Alias:  "alias1",
-	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	req, err := http.NewRequest("GET", "/v2/apps?names=snap-d", nil)
 	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
 
-	st := d.overlord.State()
-	st.Lock()
-	chg := st.Change(id)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
+	apps := rsp.Result.([]client.AppInfo)
+	c.Assert(apps, check.HasLen, 2)
 
-	<-chg.Ready()
+	for _, name := range []string{"snap-d.cmd2", "snap-d.cmd3"} {
+		snap, app := splitAppName(name)
+		c.Check(apps, testutil.DeepContains, client.AppInfo{
+			Snap: snap,
+			Name: app,
+		})
+	}
 
-	st.Lock()
-	err = chg.Err()
-	st.Unlock()
-	c.Assert(err, check.IsNil)
+	appNames := make([]string, len(apps))
+	for i, app := range apps {
+		appNames[i] = app.Snap + "." + app.Name
+	}
+	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
+}
 
-	// unalias
-	action = &aliasAction{
-		Action: "unalias",
-		Alias:  "alias1",
+func (s *appSuite) TestGetAppsInfoServices(c *check.C) {
+	svcNames := []string{"snap-a.svc1", "snap-a.svc2", "snap-b.svc3"}
+	for _, name := range svcNames {
+		s.sysctlBufs = append(s.sysctlBufs, []byte(fmt.Sprintf(`
+Id=snap.%s.service
+Type=simple
+ActiveState=active
+UnitFileState=enabled
+`[1:], name)))
 	}
-	text, err = json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf = bytes.NewBuffer(text)
-	req, err = http.NewRequest("POST", "/v2/aliases", buf)
+
+	req, err := http.NewRequest("GET", "/v2/apps?select=service", nil)
 	c.Assert(err, check.IsNil)
-	rec = httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id = body["change"].(string)
 
-	st.Lock()
-	chg = st.Change(id)
-	c.Check(chg.Summary(), check.Equals, `Remove manual alias "alias1" for snap "alias-snap"`)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
+	svcs := rsp.Result.([]client.AppInfo)
+	c.Assert(svcs, check.HasLen, 3)
 
-	<-chg.Ready()
+	for _, name := range svcNames {
+		snap, app := splitAppName(name)
+		c.Check(svcs, testutil.DeepContains, client.AppInfo{
+			Snap:    snap,
+			Name:    app,
+			Daemon:  "simple",
+			Active:  true,
+			Enabled: true,
+		})
+	}
 
-	st.Lock()
-	defer st.Unlock()
-	err = chg.Err()
+	appNames := make([]string, len(svcs))
+	for i, svc := range svcs {
+		appNames[i] = svc.Snap + "." + svc.Name
+	}
+	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
+}
+
+func (s *appSuite) TestGetAppsInfoBadSelect(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/apps?select=potato", nil)
 	c.Assert(err, check.IsNil)
 
-	// sanity check
-	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBinariesDir, "alias1")), check.Equals, false)
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 400)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
 }
 
-func (s *apiSuite) TestUnaliasDWIMAliasSuccess(c *check.C) {
-	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+func (s *appSuite) TestGetAppsInfoBadName(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/apps?names=potato", nil)
 	c.Assert(err, check.IsNil)
-	d := s.daemon(c)
 
-	s.mockSnap(c, aliasYaml)
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 404)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+}
 
-	oldAutoAliases := snapstate.AutoAliases
-	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
-		return nil, nil
+func (s *appSuite) TestAppInfosForOne(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{"snap-a.svc1"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.IsNil)
+	c.Assert(appInfos, check.HasLen, 1)
+	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[0].Name, check.Equals, "svc1")
+}
+
+func (s *appSuite) TestAppInfosForAll(c *check.C) {
+	type T struct {
+		opts  appInfoOptions
+		snaps []*snap.Info
+		names []string
 	}
-	defer func() { snapstate.AutoAliases = oldAutoAliases }()
 
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+	for _, t := range []T{
+		{
+			opts:  appInfoOptions{service: true},
+			names: []string{"svc1", "svc2", "svc3"},
+			snaps: []*snap.Info{s.infoA, s.infoA, s.infoB},
+		},
+		{
+			opts:  appInfoOptions{},
+			names: []string{"svc1", "svc2", "cmd1", "svc3", "cmd2", "cmd3"},
+			snaps: []*snap.Info{s.infoA, s.infoA, s.infoB, s.infoB, s.infoD, s.infoD},
+		},
+	} {
+		c.Assert(len(t.names), check.Equals, len(t.snaps), check.Commentf("%s", t.opts))
 
-	action := &aliasAction{
-		Action: "alias",
-		Snap:   "alias-snap",
-		App:    "app",
-		Alias:  "alias1",
+		st := s.d.overlord.State()
+		appInfos, rsp := appInfosFor(st, nil, t.opts)
+		c.Assert(rsp, check.IsNil, check.Commentf("%s", t.opts))
+		names := make([]string, len(appInfos))
+		for i, appInfo := range appInfos {
+			names[i] = appInfo.Name
+		}
+		c.Assert(names, check.DeepEquals, t.names, check.Commentf("%s", t.opts))
+
+		for i := range appInfos {
+			c.Check(appInfos[i].Snap, check.DeepEquals, t.snaps[i], check.Commentf("%s: %s", t.opts, t.names[i]))
+		}
 	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/aliases", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
+}
 
-	st := d.overlord.State()
-	st.Lock()
-	chg := st.Change(id)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
+func (s *appSuite) TestAppInfosForOneSnap(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{"snap-a"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.IsNil)
+	c.Assert(appInfos, check.HasLen, 2)
+	sort.Sort(bySnapApp(appInfos))
 
-	<-chg.Ready()
+	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[0].Name, check.Equals, "svc1")
+	c.Check(appInfos[1].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[1].Name, check.Equals, "svc2")
+}
 
-	st.Lock()
-	err = chg.Err()
-	st.Unlock()
-	c.Assert(err, check.IsNil)
+func (s *appSuite) TestAppInfosForMixedArgs(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{"snap-a", "snap-a.svc1"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.IsNil)
+	c.Assert(appInfos, check.HasLen, 2)
+	sort.Sort(bySnapApp(appInfos))
 
-	// DWIM unalias an alias
-	action = &aliasAction{
-		Action: "unalias",
-		Snap:   "alias1",
-		Alias:  "alias1",
-	}
-	text, err = json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf = bytes.NewBuffer(text)
-	req, err = http.NewRequest("POST", "/v2/aliases", buf)
-	c.Assert(err, check.IsNil)
-	rec = httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id = body["change"].(string)
+	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[0].Name, check.Equals, "svc1")
+	c.Check(appInfos[1].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[1].Name, check.Equals, "svc2")
+}
+
+func (s *appSuite) TestAppInfosCleanupAndSorted(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{
+		"snap-b.svc3",
+		"snap-a.svc2",
+		"snap-a.svc1",
+		"snap-a.svc2",
+		"snap-b.svc3",
+		"snap-a.svc1",
+		"snap-b",
+		"snap-a",
+	}, appInfoOptions{service: true})
+	c.Assert(rsp, check.IsNil)
+	c.Assert(appInfos, check.HasLen, 3)
+	sort.Sort(bySnapApp(appInfos))
 
-	st.Lock()
-	chg = st.Change(id)
-	c.Check(chg.Summary(), check.Equals, `Remove manual alias "alias1" for snap "alias-snap"`)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
+	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[0].Name, check.Equals, "svc1")
+	c.Check(appInfos[1].Snap, check.DeepEquals, s.infoA)
+	c.Check(appInfos[1].Name, check.Equals, "svc2")
+	c.Check(appInfos[2].Snap, check.DeepEquals, s.infoB)
+	c.Check(appInfos[2].Name, check.Equals, "svc3")
+}
 
-	<-chg.Ready()
+func (s *appSuite) TestAppInfosForAppless(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{"snap-c"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.FitsTypeOf, &resp{})
+	c.Check(rsp.(*resp).Status, check.Equals, 404)
+	c.Check(rsp.(*resp).Result.(*errorResult).Kind, check.Equals, errorKindAppNotFound)
+	c.Assert(appInfos, check.IsNil)
+}
 
-	st.Lock()
-	defer st.Unlock()
-	err = chg.Err()
-	c.Assert(err, check.IsNil)
+func (s *appSuite) TestAppInfosForMissingApp(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{"snap-c.whatever"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.FitsTypeOf, &resp{})
+	c.Check(rsp.(*resp).Status, check.Equals, 404)
+	c.Check(rsp.(*resp).Result.(*errorResult).Kind, check.Equals, errorKindAppNotFound)
+	c.Assert(appInfos, check.IsNil)
+}
 
-	// sanity check
-	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBinariesDir, "alias1")), check.Equals, false)
+func (s *appSuite) TestAppInfosForMissingSnap(c *check.C) {
+	st := s.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, []string{"snap-x"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.FitsTypeOf, &resp{})
+	c.Check(rsp.(*resp).Status, check.Equals, 404)
+	c.Check(rsp.(*resp).Result.(*errorResult).Kind, check.Equals, errorKindSnapNotFound)
+	c.Assert(appInfos, check.IsNil)
 }
 
-func (s *apiSuite) TestPreferSuccess(c *check.C) {
-	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+func (s *appSuite) TestLogs(c *check.C) {
+	s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader(`
+{"MESSAGE": "hello1", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "42"}
+{"MESSAGE": "hello2", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "44"}
+{"MESSAGE": "hello3", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "46"}
+{"MESSAGE": "hello4", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "48"}
+{"MESSAGE": "hello5", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "50"}
+	`))}
+
+	req, err := http.NewRequest("GET", "/v2/logs?names=snap-a.svc2&n=42&follow=false", nil)
 	c.Assert(err, check.IsNil)
-	d := s.daemon(c)
 
-	s.mockSnap(c, aliasYaml)
+	rec := httptest.NewRecorder()
+	getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
 
-	oldAutoAliases := snapstate.AutoAliases
-	snapstate.AutoAliases = func(*state.State, *snap.Info) (map[string]string, error) {
-		return nil, nil
-	}
-	defer func() { snapstate.AutoAliases = oldAutoAliases }()
+	c.Check(s.jctlSvcses, check.DeepEquals, [][]string{{"snap.snap-a.svc2.service"}})
+	c.Check(s.jctlNs, check.DeepEquals, []string{"42"})
+	c.Check(s.jctlFollows, check.DeepEquals, []bool{false})
 
-	d.overlord.Loop()
-	defer d.overlord.Stop()
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/json-seq")
+	c.Check(rec.Body.String(), check.Equals, `
+{"timestamp":"1970-01-01T00:00:00.000042Z","message":"hello1","sid":"xyzzy","pid":"42"}
+{"timestamp":"1970-01-01T00:00:00.000044Z","message":"hello2","sid":"xyzzy","pid":"42"}
+{"timestamp":"1970-01-01T00:00:00.000046Z","message":"hello3","sid":"xyzzy","pid":"42"}
+{"timestamp":"1970-01-01T00:00:00.000048Z","message":"hello4","sid":"xyzzy","pid":"42"}
+{"timestamp":"1970-01-01T00:00:00.00005Z","message":"hello5","sid":"xyzzy","pid":"42"}
+`[1:])
+}
 
-	action := &aliasAction{
-		Action: "prefer",
-		Snap:   "alias-snap",
+func (s *appSuite) TestLogsN(c *check.C) {
+	type T struct {
+		in  string
+		out string
 	}
-	text, err := json.Marshal(action)
-	c.Assert(err, check.IsNil)
-	buf := bytes.NewBuffer(text)
-	req, err := http.NewRequest("POST", "/v2/aliases", buf)
-	c.Assert(err, check.IsNil)
-	rec := httptest.NewRecorder()
-	aliasesCmd.POST(aliasesCmd, req, nil).ServeHTTP(rec, req)
-	c.Assert(rec.Code, check.Equals, 202)
-	var body map[string]interface{}
-	err = json.Unmarshal(rec.Body.Bytes(), &body)
-	c.Check(err, check.IsNil)
-	id := body["change"].(string)
-
-	st := d.overlord.State()
-	st.Lock()
-	chg := st.Change(id)
-	c.Check(chg.Summary(), check.Equals, `Prefer aliases of snap "alias-snap"`)
-	st.Unlock()
-	c.Assert(chg, check.NotNil)
 
-	<-chg.Ready()
+	for _, t := range []T{
+		{in: "", out: "10"},
+		{in: "0", out: "0"},
+		{in: "-1", out: "all"},
+		{in: strconv.Itoa(math.MinInt32), out: "all"},
+		{in: strconv.Itoa(math.MaxInt32), out: strconv.Itoa(math.MaxInt32)},
+	} {
 
-	st.Lock()
-	defer st.Unlock()
-	err = chg.Err()
-	c.Assert(err, check.IsNil)
+		s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader(""))}
+		s.jctlNs = nil
 
-	// sanity check
-	var snapst snapstate.SnapState
-	err = snapstate.Get(st, "alias-snap", &snapst)
-	c.Assert(err, check.IsNil)
-	c.Check(snapst.AutoAliasesDisabled, check.Equals, false)
-}
+		req, err := http.NewRequest("GET", "/v2/logs?n="+t.in, nil)
+		c.Assert(err, check.IsNil)
 
-func (s *apiSuite) TestAliases(c *check.C) {
-	d := s.daemon(c)
+		rec := httptest.NewRecorder()
+		getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
 
-	st := d.overlord.State()
-	st.Lock()
-	snapstate.Set(st, "alias-snap1", &snapstate.SnapState{
-		Sequence: []*snap.SideInfo{
-			{RealName: "alias-snap1", Revision: snap.R(11)},
-		},
-		Current: snap.R(11),
-		Active:  true,
-		Aliases: map[string]*snapstate.AliasTarget{
-			"alias1": {Manual: "cmd1x", Auto: "cmd1"},
-			"alias2": {Auto: "cmd2"},
-		},
-	})
-	snapstate.Set(st, "alias-snap2", &snapstate.SnapState{
-		Sequence: []*snap.SideInfo{
-			{RealName: "alias-snap2", Revision: snap.R(12)},
-		},
-		Current:             snap.R(12),
-		Active:              true,
-		AutoAliasesDisabled: true,
-		Aliases: map[string]*snapstate.AliasTarget{
-			"alias2": {Auto: "cmd2"},
-			"alias3": {Manual: "cmd3"},
-			"alias4": {Manual: "cmd4x", Auto: "cmd4"},
-		},
-	})
-	st.Unlock()
+		c.Check(s.jctlNs, check.DeepEquals, []string{t.out})
+	}
+}
 
-	req, err := http.NewRequest("GET", "/v2/aliases", nil)
+func (s *appSuite) TestLogsBadN(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/logs?n=hello", nil)
 	c.Assert(err, check.IsNil)
 
-	rsp := getAliases(aliasesCmd, req, nil).(*resp)
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Status, check.Equals, 200)
-	c.Check(rsp.Result, check.DeepEquals, map[string]map[string]aliasStatus{
-		"alias-snap1": {
-			"alias1": {
-				Command: "alias-snap1.cmd1x",
-				Status:  "manual",
-				Manual:  "cmd1x",
-				Auto:    "cmd1",
-			},
-			"alias2": {
-				Command: "alias-snap1.cmd2",
-				Status:  "auto",
-				Auto:    "cmd2",
-			},
-		},
-		"alias-snap2": {
-			"alias2": {
-				Command: "alias-snap2.cmd2",
-				Status:  "disabled",
-				Auto:    "cmd2",
-			},
-			"alias3": {
-				Command: "alias-snap2.cmd3",
-				Status:  "manual",
-				Manual:  "cmd3",
-			},
-			"alias4": {
-				Command: "alias-snap2.cmd4x",
-				Status:  "manual",
-				Manual:  "cmd4x",
-				Auto:    "cmd4",
-			},
-		},
-	})
-
+	rsp := getLogs(logsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 400)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
 }
 
-func (s *apiSuite) TestInstallUnaliased(c *check.C) {
-	var calledFlags snapstate.Flags
-
-	snapstateInstall = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
-		calledFlags = flags
-
-		t := s.NewTask("fake-install-snap", "Doing a fake install")
-		return state.NewTaskSet(t), nil
-	}
-
-	d := s.daemon(c)
-	inst := &snapInstruction{
-		Action: "install",
-		// Install the snap without enabled automatic aliases
-		Unaliased: true,
-		Snaps:     []string{"fake"},
+func (s *appSuite) TestLogsFollow(c *check.C) {
+	s.jctlRCs = []io.ReadCloser{
+		ioutil.NopCloser(strings.NewReader("")),
+		ioutil.NopCloser(strings.NewReader("")),
+		ioutil.NopCloser(strings.NewReader("")),
 	}
 
-	st := d.overlord.State()
-	st.Lock()
-	defer st.Unlock()
-	_, _, err := inst.dispatch()(inst, st)
-	c.Check(err, check.IsNil)
+	reqT, err := http.NewRequest("GET", "/v2/logs?follow=true", nil)
+	c.Assert(err, check.IsNil)
+	reqF, err := http.NewRequest("GET", "/v2/logs?follow=false", nil)
+	c.Assert(err, check.IsNil)
+	reqN, err := http.NewRequest("GET", "/v2/logs", nil)
+	c.Assert(err, check.IsNil)
 
-	c.Check(calledFlags.Unaliased, check.Equals, true)
-}
+	rec := httptest.NewRecorder()
+	getLogs(logsCmd, reqT, nil).ServeHTTP(rec, reqT)
+	getLogs(logsCmd, reqF, nil).ServeHTTP(rec, reqF)
+	getLogs(logsCmd, reqN, nil).ServeHTTP(rec, reqN)
 
-func (s *apiSuite) TestSplitQS(c *check.C) {
-	c.Check(splitQS("foo,bar"), check.DeepEquals, []string{"foo", "bar"})
-	c.Check(splitQS("foo , bar"), check.DeepEquals, []string{"foo", "bar"})
-	c.Check(splitQS("foo ,, bar"), check.DeepEquals, []string{"foo", "bar"})
-	c.Check(splitQS(""), check.HasLen, 0)
-	c.Check(splitQS(","), check.HasLen, 0)
+	c.Check(s.jctlFollows, check.DeepEquals, []bool{true, false, false})
 }
 
-var _ = check.Suite(&postDebugSuite{})
+func (s *appSuite) TestLogsBadFollow(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/logs?follow=hello", nil)
+	c.Assert(err, check.IsNil)
 
-type postDebugSuite struct {
-	apiBaseSuite
+	rsp := getLogs(logsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 400)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
 }
 
-func (s *postDebugSuite) TestPostDebugEnsureStateSoon(c *check.C) {
-	s.daemonWithOverlordMock(c)
+func (s *appSuite) TestLogsBadName(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/logs?names=hello", nil)
+	c.Assert(err, check.IsNil)
 
-	soon := 0
-	ensureStateSoon = func(st *state.State) {
-		soon++
-		ensureStateSoonImpl(st)
-	}
+	rsp := getLogs(logsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 404)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+}
 
-	buf := bytes.NewBufferString(`{"action": "ensure-state-soon"}`)
-	req, err := http.NewRequest("POST", "/v2/debug", buf)
+func (s *appSuite) TestLogsSad(c *check.C) {
+	s.jctlErrs = []error{errors.New("potato")}
+	req, err := http.NewRequest("GET", "/v2/logs", nil)
 	c.Assert(err, check.IsNil)
 
-	rsp := postDebug(debugCmd, req, nil).(*resp)
-
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result, check.Equals, true)
-	c.Check(soon, check.Equals, 1)
+	rsp := getLogs(logsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 500)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
 }
 
-func (s *postDebugSuite) TestPostDebugGetBaseDeclaration(c *check.C) {
-	_ = s.daemon(c)
+func (s *appSuite) testPostApps(c *check.C, inst appInstruction, systemctlCall []string) *state.Change {
+	postBody, err := json.Marshal(inst)
+	c.Assert(err, check.IsNil)
 
-	buf := bytes.NewBufferString(`{"action": "get-base-declaration"}`)
-	req, err := http.NewRequest("POST", "/v2/debug", buf)
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBuffer(postBody))
 	c.Assert(err, check.IsNil)
 
-	rsp := postDebug(debugCmd, req, nil).(*resp)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(rsp.Change, check.Matches, `[0-9]+`)
 
-	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Check(rsp.Result.(map[string]interface{})["base-declaration"],
-		testutil.Contains, "type: base-declaration")
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Tasks(), check.HasLen, 1)
+
+	st.Unlock()
+	<-chg.Ready()
+	st.Lock()
+
+	c.Check(s.cmd.Calls(), check.DeepEquals, [][]string{systemctlCall})
+	return chg
 }
 
-type appSuite struct {
-	apiBaseSuite
-	cmd *testutil.MockCmd
+func (s *appSuite) TestPostAppsStartOne(c *check.C) {
+	inst := appInstruction{Action: "start", Names: []string{"snap-a.svc2"}}
+	expected := []string{"systemctl", "start", "snap.snap-a.svc2.service"}
+	s.testPostApps(c, inst, expected)
+}
 
-	infoA, infoB, infoC, infoD *snap.Info
+func (s *appSuite) TestPostAppsStartTwo(c *check.C) {
+	inst := appInstruction{Action: "start", Names: []string{"snap-a"}}
+	expected := []string{"systemctl", "start", "snap.snap-a.svc1.service", "snap.snap-a.svc2.service"}
+	chg := s.testPostApps(c, inst, expected)
+	// check the summary expands the snap into actual apps
+	c.Check(chg.Summary(), check.Equals, "start of [snap-a.svc1 snap-a.svc2]")
 }
 
-var _ = check.Suite(&appSuite{})
+func (s *appSuite) TestPostAppsStartThree(c *check.C) {
+	inst := appInstruction{Action: "start", Names: []string{"snap-a", "snap-b"}}
+	expected := []string{"systemctl", "start", "snap.snap-a.svc1.service", "snap.snap-a.svc2.service", "snap.snap-b.svc3.service"}
+	chg := s.testPostApps(c, inst, expected)
+	// check the summary expands the snap into actual apps
+	c.Check(chg.Summary(), check.Equals, "start of [snap-a.svc1 snap-a.svc2 snap-b.svc3]")
+}
 
-func (s *appSuite) SetUpTest(c *check.C) {
-	s.apiBaseSuite.SetUpTest(c)
-	s.cmd = testutil.MockCommand(c, "systemctl", "").Also("journalctl", "")
-	s.daemon(c)
-	s.infoA = s.mkInstalledInState(c, s.d, "snap-a", "dev", "v1", snap.R(1), true, "apps: {svc1: {daemon: simple}, svc2: {daemon: simple, reload-command: x}}")
-	s.infoB = s.mkInstalledInState(c, s.d, "snap-b", "dev", "v1", snap.R(1), true, "apps: {svc3: {daemon: simple}, cmd1: {}}")
-	s.infoC = s.mkInstalledInState(c, s.d, "snap-c", "dev", "v1", snap.R(1), true, "")
-	s.infoD = s.mkInstalledInState(c, s.d, "snap-d", "dev", "v1", snap.R(1), true, "apps: {cmd2: {}, cmd3: {}}")
-	s.d.overlord.Loop()
+func (s *appSuite) TestPosetAppsStop(c *check.C) {
+	inst := appInstruction{Action: "stop", Names: []string{"snap-a.svc2"}}
+	expected := []string{"systemctl", "stop", "snap.snap-a.svc2.service"}
+	s.testPostApps(c, inst, expected)
 }
 
-func (s *appSuite) TearDownTest(c *check.C) {
-	s.d.overlord.Stop()
-	s.cmd.Restore()
-	s.apiBaseSuite.TearDownTest(c)
+func (s *appSuite) TestPosetAppsRestart(c *check.C) {
+	inst := appInstruction{Action: "restart", Names: []string{"snap-a.svc2"}}
+	expected := []string{"systemctl", "restart", "snap.snap-a.svc2.service"}
+	s.testPostApps(c, inst, expected)
 }
 
-func (s *appSuite) TestSplitAppName(c *check.C) {
-	type T struct {
-		name string
-		snap string
-		app  string
-	}
+func (s *appSuite) TestPosetAppsReload(c *check.C) {
+	inst := appInstruction{Action: "restart", Names: []string{"snap-a.svc2"}}
+	inst.Reload = true
+	expected := []string{"systemctl", "reload-or-restart", "snap.snap-a.svc2.service"}
+	s.testPostApps(c, inst, expected)
+}
 
-	for _, x := range []T{
-		{name: "foo.bar", snap: "foo", app: "bar"},
-		{name: "foo", snap: "foo", app: ""},
-		{name: "foo.bar.baz", snap: "foo", app: "bar.baz"},
-		{name: ".", snap: "", app: ""}, // SISO
-	} {
-		snap, app := splitAppName(x.name)
-		c.Check(x.snap, check.Equals, snap, check.Commentf(x.name))
-		c.Check(x.app, check.Equals, app, check.Commentf(x.name))
-	}
+func (s *appSuite) TestPosetAppsEnableNow(c *check.C) {
+	inst := appInstruction{Action: "start", Names: []string{"snap-a.svc2"}}
+	inst.Enable = true
+	expected := []string{"systemctl", "enable", "--now", "snap.snap-a.svc2.service"}
+	s.testPostApps(c, inst, expected)
 }
 
-func (s *appSuite) TestGetAppsInfo(c *check.C) {
-	svcNames := []string{"snap-a.svc1", "snap-a.svc2", "snap-b.svc3"}
-	for _, name := range svcNames {
-		s.sysctlBufs = append(s.sysctlBufs, []byte(fmt.Sprintf(`
-Id=snap.%s.service
-Type=simple
-ActiveState=active
-UnitFileState=enabled
-`[1:], name)))
-	}
+func (s *appSuite) TestPosetAppsDisableNow(c *check.C) {
+	inst := appInstruction{Action: "stop", Names: []string{"snap-a.svc2"}}
+	inst.Disable = true
+	expected := []string{"systemctl", "disable", "--now", "snap.snap-a.svc2.service"}
+	s.testPostApps(c, inst, expected)
+}
 
-	req, err := http.NewRequest("GET", "/v2/apps", nil)
+func (s *appSuite) TestPostAppsBadJSON(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`'junk`))
 	c.Assert(err, check.IsNil)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, ".*cannot decode request body.*")
+}
 
-	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
-	apps := rsp.Result.([]client.AppInfo)
-	c.Assert(apps, check.HasLen, 6)
+func (s *appSuite) TestPostAppsBadOp(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"random": "json"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, ".*cannot perform operation on services without a list of services.*")
+}
 
-	for _, name := range svcNames {
-		snap, app := splitAppName(name)
-		c.Check(apps, testutil.DeepContains, client.AppInfo{
-			Snap:    snap,
-			Name:    app,
-			Daemon:  "simple",
-			Active:  true,
-			Enabled: true,
-		})
-	}
+func (s *appSuite) TestPostAppsBadSnap(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "stop", "names": ["snap-c"]}`))
+	c.Assert(err, check.IsNil)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "snap-c" has no services`)
+}
 
-	for _, name := range []string{"snap-b.cmd1", "snap-d.cmd2", "snap-d.cmd3"} {
-		snap, app := splitAppName(name)
-		c.Check(apps, testutil.DeepContains, client.AppInfo{
-			Snap: snap,
-			Name: app,
-		})
-	}
+func (s *appSuite) TestPostAppsBadApp(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "stop", "names": ["snap-a.what"]}`))
+	c.Assert(err, check.IsNil)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "snap-a" has no service "what"`)
+}
 
-	appNames := make([]string, len(apps))
-	for i, app := range apps {
-		appNames[i] = app.Snap + "." + app.Name
-	}
-	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
+func (s *appSuite) TestPostAppsBadAction(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "discombobulate", "names": ["snap-a.svc1"]}`))
+	c.Assert(err, check.IsNil)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `unknown action "discombobulate"`)
 }
 
-func (s *appSuite) TestGetAppsInfoNames(c *check.C) {
+func (s *appSuite) TestPostAppsConflict(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	locked := true
+	defer func() {
+		if locked {
+			st.Unlock()
+		}
+	}()
 
-	req, err := http.NewRequest("GET", "/v2/apps?names=snap-d", nil)
+	ts, err := snapstate.Remove(st, "snap-a", snap.R(0))
 	c.Assert(err, check.IsNil)
+	// need a change to make the tasks visible
+	st.NewChange("enable", "...").AddAll(ts)
+	st.Unlock()
+	locked = false
 
-	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 200)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
-	apps := rsp.Result.([]client.AppInfo)
-	c.Assert(apps, check.HasLen, 2)
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "start", "names": ["snap-a.svc1"]}`))
+	c.Assert(err, check.IsNil)
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "snap-a" has changes in progress`)
+}
 
-	for _, name := range []string{"snap-d.cmd2", "snap-d.cmd3"} {
-		snap, app := splitAppName(name)
-		c.Check(apps, testutil.DeepContains, client.AppInfo{
-			Snap: snap,
-			Name: app,
-		})
-	}
+func (s *apiSuite) TestSecrets(c *check.C) {
+	d := s.daemon(c)
 
-	appNames := make([]string, len(apps))
-	for i, app := range apps {
-		appNames[i] = app.Snap + "." + app.Name
-	}
-	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
-}
+	for _, a := range []secretAction{
+		{Action: "set", Name: "db-password", Value: "s3cr3t"},
+		{Action: "set", Name: "api-token", Value: "t0k3n"},
+		{Action: "remove", Name: "api-token"},
+	} {
+		text, err := json.Marshal(a)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/secrets", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
 
-func (s *appSuite) TestGetAppsInfoServices(c *check.C) {
-	svcNames := []string{"snap-a.svc1", "snap-a.svc2", "snap-b.svc3"}
-	for _, name := range svcNames {
-		s.sysctlBufs = append(s.sysctlBufs, []byte(fmt.Sprintf(`
-Id=snap.%s.service
-Type=simple
-ActiveState=active
-UnitFileState=enabled
-`[1:], name)))
+		rsp := postSecrets(secretsCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
 	}
 
-	req, err := http.NewRequest("GET", "/v2/apps?select=service", nil)
+	st := d.overlord.State()
+	value, err := secretstate.Get(st, "db-password")
 	c.Assert(err, check.IsNil)
+	c.Check(string(value), check.Equals, "s3cr3t")
 
-	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 200)
+	req, err := http.NewRequest("GET", "/v2/secrets", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSecrets(secretsCmd, req, nil).(*resp)
 	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
-	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
-	svcs := rsp.Result.([]client.AppInfo)
-	c.Assert(svcs, check.HasLen, 3)
+	c.Check(rsp.Result, check.DeepEquals, []string{"db-password"})
+}
 
-	for _, name := range svcNames {
-		snap, app := splitAppName(name)
-		c.Check(svcs, testutil.DeepContains, client.AppInfo{
-			Snap:    snap,
-			Name:    app,
-			Daemon:  "simple",
-			Active:  true,
-			Enabled: true,
-		})
+func (s *apiSuite) TestSecretsErrors(c *check.C) {
+	s.daemon(c)
+
+	errScenarios := []struct {
+		action secretAction
+		status int
+		err    string
+	}{
+		{secretAction{Action: "", Name: "foo"}, 400, `unsupported secret action: ""`},
+		{secretAction{Action: "what", Name: "foo"}, 400, `unsupported secret action: "what"`},
+		{secretAction{Action: "set", Name: "Foo"}, 400, `invalid secret name "Foo"`},
+		{secretAction{Action: "remove", Name: "foo"}, 404, `cannot find secret "foo"`},
 	}
 
-	appNames := make([]string, len(svcs))
-	for i, svc := range svcs {
-		appNames[i] = svc.Snap + "." + svc.Name
+	for _, scen := range errScenarios {
+		text, err := json.Marshal(scen.action)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/secrets", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+
+		rsp := postSecrets(secretsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, scen.status)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, scen.err)
 	}
-	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
 }
 
-func (s *appSuite) TestGetAppsInfoBadSelect(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/apps?select=potato", nil)
+func (s *apiSuite) TestTokens(c *check.C) {
+	d := s.daemon(c)
+
+	defer func() { userLookup = user.Lookup }()
+	userLookup = func(username string) (*user.User, error) {
+		c.Check(username, check.Equals, "someuser")
+		return &user.User{Username: username, Uid: "1000"}, nil
+	}
+
+	// root creates the token for the user running the automation
+	text, err := json.Marshal(tokenAction{Action: "create", Label: "ci", Scopes: []string{"GET /v2/changes", "POST /v2/snaps/*"}, ExpiresIn: "1h", User: "someuser"})
 	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/tokens", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;"
+	rsp := postTokens(tokensCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+	created := rsp.Result.(*tokenInfo)
+	c.Check(created.ID, check.Equals, 1)
+	c.Check(created.UID, check.Equals, uint32(1000))
+	c.Check(created.Label, check.Equals, "ci")
+	c.Check(created.Token, check.Not(check.Equals), "")
+	c.Check(created.Expires.After(time.Now().Add(59*time.Minute)), check.Equals, true)
 
-	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 400)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
-}
+	st := d.overlord.State()
+	st.Lock()
+	token, err := auth.CheckToken(st, created.Token)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(token.Scopes, check.DeepEquals, []string{"GET /v2/changes", "POST /v2/snaps/*"})
+	c.Check(token.UID, check.Equals, uint32(1000))
 
-func (s *appSuite) TestGetAppsInfoBadName(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/apps?names=potato", nil)
+	// the secret is not listed
+	req, err = http.NewRequest("GET", "/v2/tokens", nil)
 	c.Assert(err, check.IsNil)
+	rsp = getTokens(tokensCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	listed := rsp.Result.([]tokenInfo)
+	c.Assert(listed, check.HasLen, 1)
+	c.Check(listed[0].ID, check.Equals, 1)
+	c.Check(listed[0].Label, check.Equals, "ci")
+	c.Check(listed[0].Scopes, check.DeepEquals, []string{"GET /v2/changes", "POST /v2/snaps/*"})
+	c.Check(listed[0].Expires.Equal(created.Expires), check.Equals, true)
+	c.Check(listed[0].UID, check.Equals, uint32(1000))
+	c.Check(listed[0].Token, check.Equals, "")
 
-	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 404)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
-}
+	text, err = json.Marshal(tokenAction{Action: "revoke", ID: 1})
+	c.Assert(err, check.IsNil)
+	req, err = http.NewRequest("POST", "/v2/tokens", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rsp = postTokens(tokensCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
 
-func (s *appSuite) TestAppInfosForOne(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{"snap-a.svc1"}, appInfoOptions{service: true})
-	c.Assert(rsp, check.IsNil)
-	c.Assert(appInfos, check.HasLen, 1)
-	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[0].Name, check.Equals, "svc1")
+	st.Lock()
+	_, err = auth.CheckToken(st, created.Token)
+	st.Unlock()
+	c.Check(err, check.Equals, auth.ErrInvalidAuth)
 }
 
-func (s *appSuite) TestAppInfosForAll(c *check.C) {
-	type T struct {
-		opts  appInfoOptions
-		snaps []*snap.Info
-		names []string
-	}
+func (s *apiSuite) TestTokensUser(c *check.C) {
+	s.daemon(c)
 
-	for _, t := range []T{
-		{
-			opts:  appInfoOptions{service: true},
-			names: []string{"svc1", "svc2", "svc3"},
-			snaps: []*snap.Info{s.infoA, s.infoA, s.infoB},
-		},
-		{
-			opts:  appInfoOptions{},
-			names: []string{"svc1", "svc2", "cmd1", "svc3", "cmd2", "cmd3"},
-			snaps: []*snap.Info{s.infoA, s.infoA, s.infoB, s.infoB, s.infoD, s.infoD},
-		},
+	for _, t := range []struct {
+		user string
+		uid  uint32
+	}{
+		// a uid needs no lookup
+		{"1001", 1001},
+		// by default, the user creating the token
+		{"", 0},
 	} {
-		c.Assert(len(t.names), check.Equals, len(t.snaps), check.Commentf("%s", t.opts))
-
-		st := s.d.overlord.State()
-		appInfos, rsp := appInfosFor(st, nil, t.opts)
-		c.Assert(rsp, check.IsNil, check.Commentf("%s", t.opts))
-		names := make([]string, len(appInfos))
-		for i, appInfo := range appInfos {
-			names[i] = appInfo.Name
-		}
-		c.Assert(names, check.DeepEquals, t.names, check.Commentf("%s", t.opts))
-
-		for i := range appInfos {
-			c.Check(appInfos[i].Snap, check.DeepEquals, t.snaps[i], check.Commentf("%s: %s", t.opts, t.names[i]))
-		}
+		text, err := json.Marshal(tokenAction{Action: "create", Scopes: []string{"GET /v2/changes"}, ExpiresIn: "1h", User: t.user})
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/tokens", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = "pid=100;uid=0;"
+		rsp := postTokens(tokensCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+		c.Check(rsp.Result.(*tokenInfo).UID, check.Equals, t.uid, check.Commentf("%q", t.user))
 	}
 }
 
-func (s *appSuite) TestAppInfosForOneSnap(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{"snap-a"}, appInfoOptions{service: true})
-	c.Assert(rsp, check.IsNil)
-	c.Assert(appInfos, check.HasLen, 2)
-	sort.Sort(bySnapApp(appInfos))
+func (s *apiSuite) TestTokensErrors(c *check.C) {
+	s.daemon(c)
 
-	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[0].Name, check.Equals, "svc1")
-	c.Check(appInfos[1].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[1].Name, check.Equals, "svc2")
-}
+	errScenarios := []struct {
+		action tokenAction
+		status int
+		err    string
+	}{
+		{tokenAction{Action: "what"}, 400, `unsupported token action: "what"`},
+		{tokenAction{Action: "create", ExpiresIn: "1h"}, 400, `cannot create a token without scopes`},
+		{tokenAction{Action: "create", Scopes: []string{"GET changes"}, ExpiresIn: "1h"}, 400, `invalid token scope "GET changes".*`},
+		{tokenAction{Action: "create", Scopes: []string{"POST /v2/*"}, ExpiresIn: "1h"}, 400, `cannot create a token with scope "POST /v2/\*" covering /v2/tokens`},
+		{tokenAction{Action: "create", Scopes: []string{"GET /v2/changes"}}, 400, `cannot create a token expiring in "": invalid duration`},
+		{tokenAction{Action: "create", Scopes: []string{"GET /v2/changes"}, ExpiresIn: "-1h"}, 400, `cannot create a token expiring in "-1h": invalid duration`},
+		{tokenAction{Action: "create", Scopes: []string{"GET /v2/changes"}, ExpiresIn: "1h", User: "nosuchuser"}, 400, `cannot create a token for user "nosuchuser": .*`},
+		{tokenAction{Action: "revoke", ID: 42}, 404, `cannot revoke token 42: invalid token`},
+	}
 
-func (s *appSuite) TestAppInfosForMixedArgs(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{"snap-a", "snap-a.svc1"}, appInfoOptions{service: true})
-	c.Assert(rsp, check.IsNil)
-	c.Assert(appInfos, check.HasLen, 2)
-	sort.Sort(bySnapApp(appInfos))
+	defer func() { userLookup = user.Lookup }()
+	userLookup = func(username string) (*user.User, error) {
+		return nil, user.UnknownUserError(username)
+	}
 
-	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[0].Name, check.Equals, "svc1")
-	c.Check(appInfos[1].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[1].Name, check.Equals, "svc2")
-}
+	text, err := json.Marshal(tokenAction{Action: "create", Scopes: []string{"GET /v2/changes"}, ExpiresIn: "1h"})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/tokens", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rsp := postTokens(tokensCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 403)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot create a token without knowing the user requesting it")
 
-func (s *appSuite) TestAppInfosCleanupAndSorted(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{
-		"snap-b.svc3",
-		"snap-a.svc2",
-		"snap-a.svc1",
-		"snap-a.svc2",
-		"snap-b.svc3",
-		"snap-a.svc1",
-		"snap-b",
-		"snap-a",
-	}, appInfoOptions{service: true})
-	c.Assert(rsp, check.IsNil)
-	c.Assert(appInfos, check.HasLen, 3)
-	sort.Sort(bySnapApp(appInfos))
+	for _, scen := range errScenarios {
+		text, err := json.Marshal(scen.action)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/tokens", bytes.NewBuffer(text))
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = "pid=100;uid=0;"
 
-	c.Check(appInfos[0].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[0].Name, check.Equals, "svc1")
-	c.Check(appInfos[1].Snap, check.DeepEquals, s.infoA)
-	c.Check(appInfos[1].Name, check.Equals, "svc2")
-	c.Check(appInfos[2].Snap, check.DeepEquals, s.infoB)
-	c.Check(appInfos[2].Name, check.Equals, "svc3")
+		rsp := postTokens(tokensCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, scen.status)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, scen.err)
+	}
 }
 
-func (s *appSuite) TestAppInfosForAppless(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{"snap-c"}, appInfoOptions{service: true})
-	c.Assert(rsp, check.FitsTypeOf, &resp{})
-	c.Check(rsp.(*resp).Status, check.Equals, 404)
-	c.Check(rsp.(*resp).Result.(*errorResult).Kind, check.Equals, errorKindAppNotFound)
-	c.Assert(appInfos, check.IsNil)
+type fakePromptListener struct {
+	ch chan *promptstate.Notification
 }
 
-func (s *appSuite) TestAppInfosForMissingApp(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{"snap-c.whatever"}, appInfoOptions{service: true})
-	c.Assert(rsp, check.FitsTypeOf, &resp{})
-	c.Check(rsp.(*resp).Status, check.Equals, 404)
-	c.Check(rsp.(*resp).Result.(*errorResult).Kind, check.Equals, errorKindAppNotFound)
-	c.Assert(appInfos, check.IsNil)
+func (l *fakePromptListener) Notifications() <-chan *promptstate.Notification {
+	return l.ch
 }
 
-func (s *appSuite) TestAppInfosForMissingSnap(c *check.C) {
-	st := s.d.overlord.State()
-	appInfos, rsp := appInfosFor(st, []string{"snap-x"}, appInfoOptions{service: true})
-	c.Assert(rsp, check.FitsTypeOf, &resp{})
-	c.Check(rsp.(*resp).Status, check.Equals, 404)
-	c.Check(rsp.(*resp).Result.(*errorResult).Kind, check.Equals, errorKindSnapNotFound)
-	c.Assert(appInfos, check.IsNil)
+func (l *fakePromptListener) Close() error {
+	close(l.ch)
+	return nil
 }
 
-func (s *appSuite) TestLogs(c *check.C) {
-	s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader(`
-{"MESSAGE": "hello1", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "42"}
-{"MESSAGE": "hello2", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "44"}
-{"MESSAGE": "hello3", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "46"}
-{"MESSAGE": "hello4", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "48"}
-{"MESSAGE": "hello5", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "50"}
-	`))}
-
-	req, err := http.NewRequest("GET", "/v2/logs?names=snap-a.svc2&n=42&follow=false", nil)
-	c.Assert(err, check.IsNil)
+// promptingDaemon returns a daemon with a prompt manager listening to
+// the returned channel.
+func (s *apiSuite) promptingDaemon(c *check.C) (d *Daemon, notifications chan<- *promptstate.Notification, cleanup func()) {
+	d = s.daemon(c)
+	st := d.overlord.State()
+	mgr := d.overlord.PromptManager()
 
-	rec := httptest.NewRecorder()
-	getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
+	listener := &fakePromptListener{ch: make(chan *promptstate.Notification)}
+	restore := promptstate.MockListener(listener)
 
-	c.Check(s.jctlSvcses, check.DeepEquals, [][]string{{"snap.snap-a.svc2.service"}})
-	c.Check(s.jctlNs, check.DeepEquals, []string{"42"})
-	c.Check(s.jctlFollows, check.DeepEquals, []bool{false})
+	st.Lock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "prompting.enabled", true), check.IsNil)
+	tr.Commit()
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
 
-	c.Check(rec.Code, check.Equals, 200)
-	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/json-seq")
-	c.Check(rec.Body.String(), check.Equals, `
-{"timestamp":"1970-01-01T00:00:00.000042Z","message":"hello1","sid":"xyzzy","pid":"42"}
-{"timestamp":"1970-01-01T00:00:00.000044Z","message":"hello2","sid":"xyzzy","pid":"42"}
-{"timestamp":"1970-01-01T00:00:00.000046Z","message":"hello3","sid":"xyzzy","pid":"42"}
-{"timestamp":"1970-01-01T00:00:00.000048Z","message":"hello4","sid":"xyzzy","pid":"42"}
-{"timestamp":"1970-01-01T00:00:00.00005Z","message":"hello5","sid":"xyzzy","pid":"42"}
-`[1:])
+	return d, listener.ch, func() {
+		mgr.Stop()
+		restore()
+	}
 }
 
-func (s *appSuite) TestLogsN(c *check.C) {
-	type T struct {
-		in  string
-		out string
+func (s *apiSuite) waitPromptRequests(c *check.C, d *Daemon, uid uint32, n int) []*promptstate.Request {
+	for i := 0; i < 100; i++ {
+		if requests := d.overlord.PromptManager().Requests(uid); len(requests) == n {
+			return requests
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
+	c.Fatalf("timeout waiting for %d prompt requests", n)
+	return nil
+}
 
-	for _, t := range []T{
-		{in: "", out: "10"},
-		{in: "0", out: "0"},
-		{in: "-1", out: "all"},
-		{in: strconv.Itoa(math.MinInt32), out: "all"},
-		{in: strconv.Itoa(math.MaxInt32), out: strconv.Itoa(math.MaxInt32)},
-	} {
-
-		s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader(""))}
-		s.jctlNs = nil
+func (s *apiSuite) TestPromptRequestsAndReply(c *check.C) {
+	d, notifications, cleanup := s.promptingDaemon(c)
+	defer cleanup()
+
+	replies := make(chan bool, 1)
+	notifications <- &promptstate.Notification{
+		Label:      "snap.foo.app",
+		UID:        1000,
+		Interface:  "camera",
+		Path:       "/dev/video0",
+		Permission: "read",
+		Reply: func(allow bool) error {
+			replies <- allow
+			return nil
+		},
+	}
+	s.waitPromptRequests(c, d, 1000, 1)
 
-		req, err := http.NewRequest("GET", "/v2/logs?n="+t.in, nil)
-		c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("GET", "/v2/prompting/requests", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;"
+	rsp := getPromptRequests(promptRequestsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	requests := rsp.Result.([]*promptstate.Request)
+	c.Assert(requests, check.HasLen, 1)
+	c.Check(requests[0].ID, check.Equals, "1")
+	c.Check(requests[0].Snap, check.Equals, "foo")
+	c.Check(requests[0].Path, check.Equals, "/dev/video0")
+
+	// other users don't see it
+	req.RemoteAddr = "pid=100;uid=1001;"
+	rsp = getPromptRequests(promptRequestsCmd, req, nil).(*resp)
+	c.Check(rsp.Result, check.HasLen, 0)
+
+	req, err = http.NewRequest("POST", "/v2/prompting/requests/1", bytes.NewBufferString(`{"outcome": "allow", "lifespan": "forever", "path-pattern": "/dev/video*"}`))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;"
+	s.vars = map[string]string{"id": "1"}
+	rsp = postPromptRequest(promptRequestCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+	rule := rsp.Result.(*promptstate.Rule)
+	c.Check(rule.PathPattern, check.Equals, "/dev/video*")
+	c.Check(rule.Outcome, check.Equals, "allow")
+	c.Check(<-replies, check.Equals, true)
+
+	req, err = http.NewRequest("GET", "/v2/prompting/rules?snap=foo", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;"
+	rsp = getPromptRules(promptRulesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	rules := rsp.Result.([]*promptstate.Rule)
+	c.Assert(rules, check.HasLen, 1)
+	c.Check(rules[0].ID, check.Equals, rule.ID)
+	c.Check(rules[0].PathPattern, check.Equals, "/dev/video*")
 
-		rec := httptest.NewRecorder()
-		getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
+	req, err = http.NewRequest("POST", "/v2/prompting/rules", bytes.NewBufferString(`{"action": "remove", "id": "1"}`))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;"
+	rsp = postPromptRules(promptRulesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
 
-		c.Check(s.jctlNs, check.DeepEquals, []string{t.out})
-	}
+	st := d.overlord.State()
+	st.Lock()
+	rules, err = promptstate.Rules(st, 0, "")
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(rules, check.HasLen, 0)
 }
 
-func (s *appSuite) TestLogsBadN(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/logs?n=hello", nil)
+func (s *apiSuite) TestPromptingErrors(c *check.C) {
+	_, _, cleanup := s.promptingDaemon(c)
+	defer cleanup()
+
+	// the user must be known
+	req, err := http.NewRequest("GET", "/v2/prompting/requests", nil)
 	c.Assert(err, check.IsNil)
+	rsp := getPromptRequests(promptRequestsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 403)
 
-	rsp := getLogs(logsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 400)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+	for _, t := range []struct {
+		f      ResponseFunc
+		cmd    *Command
+		body   string
+		status int
+		err    string
+	}{
+		{postPromptRequest, promptRequestCmd, `{"outcome": "allow"}`, 404, `cannot find prompt request "42"`},
+		{postPromptRequest, promptRequestCmd, `{"outcome": "maybe"}`, 400, `invalid prompt outcome "maybe"`},
+		{postPromptRequest, promptRequestCmd, `}`, 400, `cannot decode request body into a prompt reply: .*`},
+		{postPromptRules, promptRulesCmd, `{"action": "remove", "id": "42"}`, 404, `cannot find prompt rule "42"`},
+		{postPromptRules, promptRulesCmd, `{"action": "add"}`, 400, `unsupported prompt rule action: "add"`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/prompting/...", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = "pid=100;uid=1000;"
+		s.vars = map[string]string{"id": "42"}
+		rsp := t.f(t.cmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, t.status)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
 }
 
-func (s *appSuite) TestLogsFollow(c *check.C) {
-	s.jctlRCs = []io.ReadCloser{
-		ioutil.NopCloser(strings.NewReader("")),
-		ioutil.NopCloser(strings.NewReader("")),
-		ioutil.NopCloser(strings.NewReader("")),
+func (s *apiSuite) TestPostPlanRefresh(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(1), true, "apps: {svc: {daemon: simple}, cmd: {}}")
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+	snapstateUpdate = func(st *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Check(name, check.Equals, "foo")
+		download := st.NewTask("download-snap", "Download foo")
+		download.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo:     &snap.SideInfo{RealName: "foo", Revision: snap.R(2)},
+			DownloadInfo: &snap.DownloadInfo{Size: 4096},
+		})
+		stop := st.NewTask("stop-snap-services", "Stop foo services")
+		stop.Set("snap-setup-task", download.ID())
+		stop.WaitFor(download)
+		link := st.NewTask("link-snap", "Link foo")
+		link.Set("snap-setup-task", download.ID())
+		link.WaitFor(stop)
+		ts := state.NewTaskSet(download, stop, link)
+		ts.JoinLane(st.NewLane())
+		return ts, nil
 	}
 
-	reqT, err := http.NewRequest("GET", "/v2/logs?follow=true", nil)
-	c.Assert(err, check.IsNil)
-	reqF, err := http.NewRequest("GET", "/v2/logs?follow=false", nil)
-	c.Assert(err, check.IsNil)
-	reqN, err := http.NewRequest("GET", "/v2/logs", nil)
+	buf := bytes.NewBufferString(`{"action": "refresh", "snaps": ["foo"], "branch-expiry": "stay"}`)
+	req, err := http.NewRequest("POST", "/v2/plan", buf)
 	c.Assert(err, check.IsNil)
 
-	rec := httptest.NewRecorder()
-	getLogs(logsCmd, reqT, nil).ServeHTTP(rec, reqT)
-	getLogs(logsCmd, reqF, nil).ServeHTTP(rec, reqF)
-	getLogs(logsCmd, reqN, nil).ServeHTTP(rec, reqN)
+	rsp := postPlan(planCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &changePlan{
+		Kind:    "refresh-snap",
+		Summary: `Refresh "foo" snap`,
+		Snaps:   []string{"foo"},
+		Tasks: []*plannedTask{
+			{Kind: "download-snap", Summary: "Download foo", Snap: "foo", Lanes: []int{1}},
+			{Kind: "stop-snap-services", Summary: "Stop foo services", Snap: "foo", Lanes: []int{1}, WaitFor: []int{0}},
+			{Kind: "link-snap", Summary: "Link foo", Snap: "foo", Lanes: []int{1}, WaitFor: []int{1}},
+		},
+		DownloadSize: 4096,
+		Services:     []string{"foo.svc"},
+	})
 
-	c.Check(s.jctlFollows, check.DeepEquals, []bool{true, false, false})
+	// nothing is left behind, not even the branch expiry policy
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "foo", &snapst), check.IsNil)
+	c.Check(snapst.BranchExpiry, check.Equals, "")
 }
 
-func (s *appSuite) TestLogsBadFollow(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/logs?follow=hello", nil)
-	c.Assert(err, check.IsNil)
+func (s *apiSuite) TestPostPlanRefreshCoreOnCore(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "core", "canonical", "v1", snap.R(1), true, "type: os")
+	restore := release.MockOnClassic(false)
+	defer restore()
 
-	rsp := getLogs(logsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 400)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
-}
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+	snapstateUpdate = func(st *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		link := st.NewTask("link-snap", "Link core")
+		link.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{RealName: "core", Revision: snap.R(2)},
+		})
+		return state.NewTaskSet(link), nil
+	}
 
-func (s *appSuite) TestLogsBadName(c *check.C) {
-	req, err := http.NewRequest("GET", "/v2/logs?names=hello", nil)
+	buf := bytes.NewBufferString(`{"action": "refresh", "snaps": ["core"]}`)
+	req, err := http.NewRequest("POST", "/v2/plan", buf)
 	c.Assert(err, check.IsNil)
 
-	rsp := getLogs(logsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 404)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+	rsp := postPlan(planCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result.(*changePlan).Restart, check.Equals, "system")
 }
 
-func (s *appSuite) TestLogsSad(c *check.C) {
-	s.jctlErrs = []error{errors.New("potato")}
-	req, err := http.NewRequest("GET", "/v2/logs", nil)
-	c.Assert(err, check.IsNil)
-
-	rsp := getLogs(logsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 500)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
-}
+func (s *apiSuite) TestPostPlanMany(c *check.C) {
+	d := s.daemon(c)
 
-func (s *appSuite) testPostApps(c *check.C, inst appInstruction, systemctlCall []string) *state.Change {
-	postBody, err := json.Marshal(inst)
-	c.Assert(err, check.IsNil)
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+	snapstateUpdateMany = func(st *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.HasLen, 0)
+		t := st.NewTask("fake-refresh-2", "Refreshing two")
+		return []string{"foo", "bar"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
 
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBuffer(postBody))
+	buf := bytes.NewBufferString(`{"action": "refresh"}`)
+	req, err := http.NewRequest("POST", "/v2/plan", buf)
 	c.Assert(err, check.IsNil)
 
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Assert(rsp.Status, check.Equals, 202)
-	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
-	c.Check(rsp.Change, check.Matches, `[0-9]+`)
+	rsp := postPlan(planCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, &changePlan{
+		Kind:    "refresh-snap",
+		Summary: `Refresh snaps "foo", "bar"`,
+		Snaps:   []string{"foo", "bar"},
+		Tasks:   []*plannedTask{{Kind: "fake-refresh-2", Summary: "Refreshing two", Lanes: []int{0}}},
+	})
 
-	st := s.d.overlord.State()
+	st := d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
-	chg := st.Change(rsp.Change)
-	c.Assert(chg, check.NotNil)
-	c.Check(chg.Tasks(), check.HasLen, 1)
+	c.Check(st.TaskCount(), check.Equals, 0)
+}
 
-	st.Unlock()
-	<-chg.Ready()
-	st.Lock()
+func (s *apiSuite) TestPostPlanErrors(c *check.C) {
+	s.daemon(c)
 
-	c.Check(s.cmd.Calls(), check.DeepEquals, [][]string{systemctlCall})
-	return chg
+	for _, scen := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "frobnicate", "snaps": ["foo"]}`, `unknown action frobnicate`},
+		{`{"action": "enable", "snaps": ["foo", "bar"]}`, `unsupported multi-snap operation "enable"`},
+		{`{"action": "refresh", "snaps": ["foo", "bar"], "channel": "edge"}`, `unsupported option provided for multi-snap operation`},
+		{`{"action": `, `cannot decode request body into snap instruction: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/plan", bytes.NewBufferString(scen.body))
+		c.Assert(err, check.IsNil)
+		rsp := postPlan(planCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, scen.err)
+	}
 }
 
-func (s *appSuite) TestPostAppsStartOne(c *check.C) {
-	inst := appInstruction{Action: "start", Names: []string{"snap-a.svc2"}}
-	expected := []string{"systemctl", "start", "snap.snap-a.svc2.service"}
-	s.testPostApps(c, inst, expected)
+func (s *apiSuite) postManifest(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/manifest", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	return postManifest(manifestCmd, req, nil).(*resp)
 }
 
-func (s *appSuite) TestPostAppsStartTwo(c *check.C) {
-	inst := appInstruction{Action: "start", Names: []string{"snap-a"}}
-	expected := []string{"systemctl", "start", "snap.snap-a.svc1.service", "snap.snap-a.svc2.service"}
-	chg := s.testPostApps(c, inst, expected)
-	// check the summary expands the snap into actual apps
-	c.Check(chg.Summary(), check.Equals, "start of [snap-a.svc1 snap-a.svc2]")
-}
+func (s *apiSuite) TestPostManifestDryRun(c *check.C) {
+	d := s.daemon(c)
 
-func (s *appSuite) TestPostAppsStartThree(c *check.C) {
-	inst := appInstruction{Action: "start", Names: []string{"snap-a", "snap-b"}}
-	expected := []string{"systemctl", "start", "snap.snap-a.svc1.service", "snap.snap-a.svc2.service", "snap.snap-b.svc3.service"}
-	chg := s.testPostApps(c, inst, expected)
-	// check the summary expands the snap into actual apps
-	c.Check(chg.Summary(), check.Equals, "start of [snap-a.svc1 snap-a.svc2 snap-b.svc3]")
-}
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
 
-func (s *appSuite) TestPosetAppsStop(c *check.C) {
-	inst := appInstruction{Action: "stop", Names: []string{"snap-a.svc2"}}
-	expected := []string{"systemctl", "stop", "snap.snap-a.svc2.service"}
-	s.testPostApps(c, inst, expected)
-}
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("consumer", "a", 1)
+	tr.Commit()
+	st.Unlock()
 
-func (s *appSuite) TestPosetAppsRestart(c *check.C) {
-	inst := appInstruction{Action: "restart", Names: []string{"snap-a.svc2"}}
-	expected := []string{"systemctl", "restart", "snap.snap-a.svc2.service"}
-	s.testPostApps(c, inst, expected)
-}
+	snapstateInstallMany = func(st *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"other"})
+		c.Check(opts, check.DeepEquals, map[string]*snapstate.SnapOptions{"other": {Revision: snap.R(7)}})
+		return names, []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-install", "Install other"))}, nil
+	}
+	snapstateUpdateMany = func(st *state.State, names []string, userID int, opts map[string]*snapstate.SnapOptions) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"consumer"})
+		c.Check(opts, check.DeepEquals, map[string]*snapstate.SnapOptions{"consumer": {Channel: "beta"}})
+		return names, []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-refresh", "Refresh consumer"))}, nil
+	}
+
+	rsp := s.postManifest(c, `{"dry-run": true, "manifest": {
+"snaps": {"consumer": {"channel": "beta"}, "producer": {"revision": "1"}, "other": {"revision": "7"}},
+"connections": [
+  {"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}},
+  {"plug": {"snap": "other", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}}],
+"config": {"consumer": {"a": 1, "b": "x"}, "producer": {"a": 1}, "other": {"c": true}}}}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync, check.Commentf("%v", rsp.Result))
+
+	plan := rsp.Result.(*manifestPlan)
+	c.Check(plan.Kind, check.Equals, "apply-manifest")
+	c.Check(plan.Summary, check.Equals, "Apply manifest to 3 snaps")
+	c.Check(plan.Snaps, check.DeepEquals, []string{"consumer", "other", "producer"})
+	var kinds []string
+	for _, t := range plan.Tasks {
+		kinds = append(kinds, t.Kind)
+		if t.Kind != "fake-install" && t.Kind != "fake-refresh" {
+			c.Check(t.WaitFor, check.Not(check.HasLen), 0, check.Commentf("%s", t.Summary))
+		}
+	}
+	c.Check(kinds, check.DeepEquals, []string{
+		"fake-install", "fake-refresh",
+		"run-hook", "run-hook", "connect", "update-profiles", "update-profiles", "run-hook", "run-hook",
+		"run-hook", "run-hook", "run-hook",
+	})
+	c.Check(plan.Tasks[9].Summary, check.Equals, `Run configure hook of "consumer" snap`)
+	c.Check(plan.Tasks[10].Summary, check.Equals, `Run configure hook of "other" snap`)
+	c.Check(plan.Tasks[11].Summary, check.Equals, `Run configure hook of "producer" snap`)
+	c.Check(plan.DeferredConnections, check.DeepEquals, []connectionJSON{{
+		Plug: interfaces.PlugRef{Snap: "other", Name: "plug"},
+		Slot: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
 
-func (s *appSuite) TestPosetAppsReload(c *check.C) {
-	inst := appInstruction{Action: "restart", Names: []string{"snap-a.svc2"}}
-	inst.Reload = true
-	expected := []string{"systemctl", "reload-or-restart", "snap.snap-a.svc2.service"}
-	s.testPostApps(c, inst, expected)
+	// nothing is left behind
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.TaskCount(), check.Equals, 0)
 }
 
-func (s *appSuite) TestPosetAppsEnableNow(c *check.C) {
-	inst := appInstruction{Action: "start", Names: []string{"snap-a.svc2"}}
-	inst.Enable = true
-	expected := []string{"systemctl", "enable", "--now", "snap.snap-a.svc2.service"}
-	s.testPostApps(c, inst, expected)
-}
+func (s *apiSuite) TestPostManifestApply(c *check.C) {
+	d := s.daemon(c)
+	ensureStateSoon = func(st *state.State) {}
 
-func (s *appSuite) TestPosetAppsDisableNow(c *check.C) {
-	inst := appInstruction{Action: "stop", Names: []string{"snap-a.svc2"}}
-	inst.Disable = true
-	expected := []string{"systemctl", "disable", "--now", "snap.snap-a.svc2.service"}
-	s.testPostApps(c, inst, expected)
-}
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
 
-func (s *appSuite) TestPostAppsBadJSON(c *check.C) {
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`'junk`))
-	c.Assert(err, check.IsNil)
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Check(rsp.Status, check.Equals, 400)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Matches, ".*cannot decode request body.*")
-}
+	rsp := s.postManifest(c, `{"manifest": {"snaps": {"consumer": {}}, "config": {"consumer": {"a": 1}}}}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
 
-func (s *appSuite) TestPostAppsBadOp(c *check.C) {
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"random": "json"}`))
-	c.Assert(err, check.IsNil)
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Check(rsp.Status, check.Equals, 400)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Matches, ".*cannot perform operation on services without a list of services.*")
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "apply-manifest")
+	c.Check(chg.Summary(), check.Equals, "Apply manifest to 1 snap")
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].Summary(), check.Equals, `Run configure hook of "consumer" snap`)
 }
 
-func (s *appSuite) TestPostAppsBadSnap(c *check.C) {
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "stop", "names": ["snap-c"]}`))
-	c.Assert(err, check.IsNil)
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Check(rsp.Status, check.Equals, 404)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "snap-c" has no services`)
-}
+func (s *apiSuite) TestPostManifestNothingToDo(c *check.C) {
+	d := s.daemon(c)
+	ensureStateSoon = func(st *state.State) {}
 
-func (s *appSuite) TestPostAppsBadApp(c *check.C) {
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "stop", "names": ["snap-a.what"]}`))
-	c.Assert(err, check.IsNil)
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Check(rsp.Status, check.Equals, 404)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "snap-a" has no service "what"`)
-}
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
 
-func (s *appSuite) TestPostAppsBadAction(c *check.C) {
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "discombobulate", "names": ["snap-a.svc1"]}`))
-	c.Assert(err, check.IsNil)
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Check(rsp.Status, check.Equals, 400)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `unknown action "discombobulate"`)
-}
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("consumer", "a", map[string]interface{}{"b": 1})
+	tr.Commit()
+	st.Unlock()
+
+	rsp := s.postManifest(c, `{"manifest": {"snaps": {"consumer": {"revision": "1"}}, "config": {"consumer": {"a": {"b": 1}}}}}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
 
-func (s *appSuite) TestPostAppsConflict(c *check.C) {
-	st := s.d.overlord.State()
 	st.Lock()
-	locked := true
-	defer func() {
-		if locked {
-			st.Unlock()
-		}
-	}()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Tasks(), check.HasLen, 0)
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+}
 
-	ts, err := snapstate.Remove(st, "snap-a", snap.R(0))
-	c.Assert(err, check.IsNil)
-	// need a change to make the tasks visible
-	st.NewChange("enable", "...").AddAll(ts)
-	st.Unlock()
-	locked = false
+func (s *apiSuite) TestPostManifestErrors(c *check.C) {
+	s.daemon(c)
 
-	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(`{"action": "start", "names": ["snap-a.svc1"]}`))
-	c.Assert(err, check.IsNil)
-	rsp := postApps(appsCmd, req, nil).(*resp)
-	c.Check(rsp.Status, check.Equals, 500)
-	c.Check(rsp.Type, check.Equals, ResponseTypeError)
-	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `snap "snap-a" has changes in progress`)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	for _, t := range []struct {
+		body   string
+		status int
+		err    string
+	}{
+		{`{"manifest": `, 400, `cannot decode request body into a manifest action: .*`},
+		{`{"manifest": {"snaps": {"Foo": {}}}}`, 400, `invalid snap name: "Foo"`},
+		{`{"manifest": {"config": {"foo_": {}}}}`, 400, `invalid snap name: "foo_"`},
+		{`{"manifest": {"config": {"missing": {"a": 1}}}}`, 404, `no state entry for key`},
+		{`{"manifest": {"connections": [{"plug": {"snap": "consumer", "plug": "missing"}, "slot": {"snap": "producer", "slot": "slot"}}]}}`, 400,
+			`cannot connect consumer:missing to producer:slot: snap "consumer" has no plug named "missing"`},
+	} {
+		rsp := s.postManifest(c, t.body)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, t.status)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
 }
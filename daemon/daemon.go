@@ -20,15 +20,18 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	unix "syscall"
 	"time"
 
@@ -38,16 +41,24 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/errreportstate"
+	"github.com/snapcore/snapd/overlord/standby"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/polkit"
+	"github.com/snapcore/snapd/systemd"
 )
 
+// ErrRestartSocket is returned by Stop when the daemon stopped to go into
+// standby, leaving it to socket activation to start it again.
+var ErrRestartSocket = errors.New("daemon stopped to use socket activation")
+
 // A Daemon listens for requests and routes them to the right command
 type Daemon struct {
 	Version       string
@@ -58,8 +69,17 @@ type Daemon struct {
 	snapServe     *shutdownServer
 	tomb          tomb.Tomb
 	router        *mux.Router
+	// socketActivated is whether the snapd socket was handed over by
+	// systemd, which is needed to go into standby.
+	socketActivated bool
+	standbyOpinions *standby.StandbyOpinions
+	// restartSocket is set to 1 by the restart handler, atomically, when
+	// snapd is stopped to be started again by socket activation
+	restartSocket int32
 	// enableInternalInterfaceActions controls if adding and removing slots and plugs is allowed.
 	enableInternalInterfaceActions bool
+	// jobs are the read-only queries being computed in the background
+	jobs queryJobs
 }
 
 // A ResponseFunc handles one of the individual verbs for a method
@@ -77,6 +97,8 @@ type Command struct {
 	GuestOK bool
 	// can non-admin GET?
 	UserOK bool
+	// can non-admin POST? only for handlers acting on data of the user
+	UserPostOK bool
 	// is this path accessible on the snapd-snap socket?
 	SnapOK bool
 
@@ -116,6 +138,10 @@ func (c *Command) canAccess(r *http.Request, user *auth.UserState) bool {
 		}
 	}
 
+	if r.Method == "POST" && isUser && c.UserPostOK {
+		return true
+	}
+
 	// Remaining admin checks rely on identifying peer uid
 	if !isUser {
 		return false
@@ -149,14 +175,71 @@ func (c *Command) canAccess(r *http.Request, user *auth.UserState) bool {
 	return false
 }
 
+// allowedByToken returns whether the token allows the request. Tokens
+// are only accepted on the main socket, from processes of the user they
+// were issued for: the snap socket doesn't identify its peers, so a
+// confined snap that got hold of a token can't use it.
+func (c *Command) allowedByToken(r *http.Request, token *auth.TokenState) bool {
+	if token == nil || !token.Allows(r.Method, r.URL.Path) {
+		return false
+	}
+	_, uid, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	return uid == token.UID
+}
+
+var (
+	errReportSink    = errreportstate.Sink
+	errReportSending = errreportstate.Sending
+)
+
+// recoverPanic turns a panic in a request handler into an internal
+// error response, and reports it to the given sink, if any. The sink is
+// looked up before running the handler as the handler might have left
+// the state locked.
+func (c *Command) recoverPanic(w http.ResponseWriter, r *http.Request, sink *errreport.Sink) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	stack := debug.Stack()
+	logger.Noticef("Panic serving %s %s: %v\n%s", r.Method, c.Path, v, stack)
+
+	if *sink != nil {
+		report := errreport.New("panic", "", fmt.Sprintf("panic serving %s %s: %v", r.Method, c.Path, v), fmt.Sprintf("panic:%s %s:%v\n%s", r.Method, c.Path, v, stack), nil)
+		// don't wait for the report to go out before answering
+		go func(sink errreport.Sink) {
+			if id, err := sink.Send(report.Filtered()); err == nil {
+				logger.Noticef("Reported panic serving %s %s as %s", r.Method, c.Path, id)
+			} else {
+				logger.Debugf("Cannot report panic: %s", err)
+			}
+		}(*sink)
+	}
+
+	InternalError("internal error serving %s %s", r.Method, c.Path).ServeHTTP(w, r)
+}
+
 func (c *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var reportSink errreport.Sink
+	defer c.recoverPanic(w, r, &reportSink)
+
 	state := c.d.overlord.State()
 	state.Lock()
 	// TODO Look at the error and fail if there's an attempt to authenticate with invalid data.
 	user, _ := UserFromRequest(state, r)
+	token, _ := TokenFromRequest(state, r)
+	sink, err := errReportSink(state)
 	state.Unlock()
+	if err == nil {
+		reportSink = sink
+	} else if err != errreportstate.ErrDisabled {
+		logger.Debugf("Cannot report panics: %s", err)
+	}
 
-	if !c.canAccess(r, user) {
+	if !c.allowedByToken(r, token) && !c.canAccess(r, user) {
 		Unauthorized("access denied").ServeHTTP(w, r)
 		return
 	}
@@ -257,6 +340,8 @@ func getListener(socketPath string, listenerMap map[string]net.Listener) (net.Li
 // Init sets up the Daemon's internal workings.
 // Don't call more than once.
 func (d *Daemon) Init() error {
+	defer d.overlord.StartupTimings().StartSpan("daemon-init")()
+
 	listeners, err := activation.Listeners(false)
 	if err != nil {
 		return err
@@ -268,6 +353,8 @@ func (d *Daemon) Init() error {
 		listenerMap[listener.Addr().String()] = listener
 	}
 
+	_, d.socketActivated = listenerMap[dirs.SnapdSocket]
+
 	// The SnapdSocket is required-- without it, die.
 	if listener, err := getListener(dirs.SnapdSocket, listenerMap); err == nil {
 		d.snapdListener = &ucrednetListener{listener}
@@ -354,6 +441,18 @@ func (srv *shutdownServer) trackConn(conn net.Conn, state http.ConnState) {
 	srv.conns[conn] = state
 }
 
+// idle returns whether no connection is being served a request.
+func (srv *shutdownServer) idle() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, state := range srv.conns {
+		if state != http.StateIdle {
+			return false
+		}
+	}
+	return true
+}
+
 func (srv *shutdownServer) finishShutdown() error {
 	toutC := time.After(shutdownTimeout)
 
@@ -388,6 +487,8 @@ var shutdownMsg = i18n.G("reboot scheduled to update the system - temporarily ca
 
 // Start the Daemon
 func (d *Daemon) Start() {
+	defer d.overlord.StartupTimings().StartSpan("daemon-start")()
+
 	// die when asked to restart (systemd should get us back up!)
 	d.overlord.SetRestartHandler(func(t state.RestartType) {
 		switch t {
@@ -398,6 +499,13 @@ func (d *Daemon) Start() {
 			if out, err := cmd.CombinedOutput(); err != nil {
 				logger.Noticef("%s", osutil.OutputErr(out, err))
 			}
+		case state.StopDaemon:
+			if err := d.scheduleWakeup(); err != nil {
+				// socket activation will still start it again
+				logger.Noticef("%v", err)
+			}
+			atomic.StoreInt32(&d.restartSocket, 1)
+			d.tomb.Kill(nil)
 		default:
 			logger.Noticef("internal error: restart handler called with unknown restart type: %v", t)
 			d.tomb.Kill(nil)
@@ -412,6 +520,13 @@ func (d *Daemon) Start() {
 	// the loop runs in its own goroutine
 	d.overlord.Loop()
 
+	// snapd can only stop when idle if something starts it again
+	if d.socketActivated {
+		d.standbyOpinions = standby.New(d.overlord.State())
+		d.standbyOpinions.AddOpinion(d)
+		d.standbyOpinions.Start()
+	}
+
 	d.tomb.Go(func() error {
 		if d.snapListener != nil {
 			d.tomb.Go(func() error {
@@ -433,6 +548,9 @@ func (d *Daemon) Start() {
 
 // Stop shuts down the Daemon
 func (d *Daemon) Stop() error {
+	if d.standbyOpinions != nil {
+		d.standbyOpinions.Stop()
+	}
 	d.tomb.Kill(nil)
 	d.snapdListener.Close()
 	if d.snapListener != nil {
@@ -446,7 +564,75 @@ func (d *Daemon) Stop() error {
 
 	d.overlord.Stop()
 
-	return d.tomb.Wait()
+	if err := d.tomb.Wait(); err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&d.restartSocket) == 1 {
+		return ErrRestartSocket
+	}
+	return nil
+}
+
+// CanStandby returns whether no request is being served, nor any
+// query job computed or error report sent.
+func (d *Daemon) CanStandby() bool {
+	if d.jobs.busy() {
+		return false
+	}
+	if errReportSending() {
+		return false
+	}
+	if d.snapServe != nil && !d.snapServe.idle() {
+		return false
+	}
+	return d.snapdServe.idle()
+}
+
+var (
+	// maxStandby bounds how long snapd stays stopped, so that the
+	// housekeeping of the ensure loop still happens.
+	maxStandby = 24 * time.Hour
+	// minStandby is how long snapd stays stopped at least, unless
+	// started by socket activation.
+	minStandby = 5 * time.Minute
+
+	systemdScheduleStart = systemd.ScheduleStart
+)
+
+// nextWakeup returns when snapd needs to run again for the work the
+// managers schedule, such as refreshing snaps or removing expired
+// snapshots.
+func (d *Daemon) nextWakeup() time.Time {
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	return wakeupTime(time.Now(), d.overlord.NextWakeups()...)
+}
+
+// wakeupTime returns the earliest of the scheduled times, ignoring unset
+// ones, within the bounds of minStandby and maxStandby from now.
+func wakeupTime(now time.Time, scheduled ...time.Time) time.Time {
+	next := now.Add(maxStandby)
+	for _, t := range scheduled {
+		if !t.IsZero() && t.Before(next) {
+			next = t
+		}
+	}
+	if next.Before(now.Add(minStandby)) {
+		next = now.Add(minStandby)
+	}
+	return next
+}
+
+// scheduleWakeup sets up a systemd timer starting snapd when the ensure
+// loop would have work to do, as nothing else would start it then.
+func (d *Daemon) scheduleWakeup() error {
+	next := d.nextWakeup()
+	if err := systemdScheduleStart("snapd-wakeup", "snapd.service", next); err != nil {
+		return err
+	}
+	logger.Noticef("Scheduled snapd to start again at %s.", next.Format(time.RFC3339))
+	return nil
 }
 
 // Dying is a tomb-ish thing
@@ -23,6 +23,7 @@ import (
 	"fmt"
 
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net"
@@ -39,10 +40,13 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/polkit"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -140,6 +144,120 @@ func (s *daemonSuite) TestCommandMethodDispatch(c *check.C) {
 	c.Check(rec.Code, check.Equals, 405)
 }
 
+func (s *daemonSuite) TestCommandTokenAccess(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	_, secret, err := auth.NewToken(st, "", []string{"POST /v2/snaps/*"}, 1000, time.Now().Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	_, expired, err := auth.NewToken(st, "", []string{"POST /v2/snaps/*"}, 1000, time.Now().Add(-time.Hour))
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	cmd := &Command{Path: "/v2/snaps/{name}", d: d}
+	mck := &mockHandler{cmd: cmd}
+	cmd.POST = mkRF(c, cmd, mck)
+	cmd.GET = cmd.POST
+
+	for _, t := range []struct {
+		method, path, token, peer string
+		code                      int
+	}{
+		{"POST", "/v2/snaps/foo", secret, "pid=100;uid=1000;", 200},
+		// out of scope
+		{"GET", "/v2/snaps/foo", secret, "pid=100;uid=1000;", 401},
+		{"POST", "/v2/snaps", secret, "pid=100;uid=1000;", 401},
+		// unknown or expired
+		{"POST", "/v2/snaps/foo", "other", "pid=100;uid=1000;", 401},
+		{"POST", "/v2/snaps/foo", expired, "pid=100;uid=1000;", 401},
+		// another user
+		{"POST", "/v2/snaps/foo", secret, "pid=100;uid=1001;", 401},
+		// the snap socket, whose peers are not identified
+		{"POST", "/v2/snaps/foo", secret, "", 401},
+	} {
+		req, err := http.NewRequest(t.method, t.path, nil)
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = t.peer + req.RemoteAddr
+		req.Header.Set("Authorization", "Token "+t.token)
+
+		rec := httptest.NewRecorder()
+		cmd.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, t.code, check.Commentf("%s %s", t.method, t.path))
+	}
+}
+
+type chanSink chan *errreport.Report
+
+func (s chanSink) Send(r *errreport.Report) (string, error) {
+	s <- r
+	return "some-id", nil
+}
+
+func (s *daemonSuite) testCommandPanicIsReported(c *check.C, lock bool) {
+	reported := make(chanSink, 1)
+	oldErrReportSink := errReportSink
+	errReportSink = func(st *state.State) (errreport.Sink, error) {
+		return reported, nil
+	}
+	defer func() { errReportSink = oldErrReportSink }()
+
+	d := newTestDaemon(c)
+	cmd := &Command{Path: "/v2/potato", d: d}
+	cmd.GET = func(*Command, *http.Request, *auth.UserState) Response {
+		if lock {
+			d.overlord.State().Lock()
+		}
+		panic("boom /home/jdoe")
+	}
+
+	req, err := http.NewRequest("GET", "", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;" + req.RemoteAddr
+
+	rec := httptest.NewRecorder()
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 500)
+	var rsp map[string]interface{}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp["result"], check.DeepEquals, map[string]interface{}{
+		"message": "internal error serving GET /v2/potato",
+	})
+
+	select {
+	case r := <-reported:
+		c.Check(r.Kind, check.Equals, "panic")
+		c.Check(r.Message, check.Equals, "panic serving GET /v2/potato: boom /home/<user>")
+		c.Check(r.DuplicateSignature, check.Matches, "(?s)panic:GET /v2/potato:boom /home/<user>\n.*daemon.*")
+	case <-time.After(5 * time.Second):
+		c.Fatal("panic was not reported")
+	}
+}
+
+func (s *daemonSuite) TestCommandPanicIsReported(c *check.C) {
+	s.testCommandPanicIsReported(c, false)
+}
+
+func (s *daemonSuite) TestCommandPanicWithStateLockedIsReported(c *check.C) {
+	s.testCommandPanicIsReported(c, true)
+}
+
+func (s *daemonSuite) TestCommandPanicNotReportedWhenDisabled(c *check.C) {
+	cmd := &Command{Path: "/v2/potato", d: newTestDaemon(c)}
+	cmd.GET = func(*Command, *http.Request, *auth.UserState) Response {
+		panic("boom")
+	}
+
+	req, err := http.NewRequest("GET", "", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;" + req.RemoteAddr
+
+	rec := httptest.NewRecorder()
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 500)
+	_, err = os.Stat(dirs.SnapErrorReportsDir)
+	c.Check(os.IsNotExist(err), check.Equals, true)
+}
+
 func (s *daemonSuite) TestGuestAccess(c *check.C) {
 	get := &http.Request{Method: "GET"}
 	put := &http.Request{Method: "PUT"}
@@ -190,6 +308,13 @@ func (s *daemonSuite) TestUserAccess(c *check.C) {
 	c.Check(cmd.canAccess(get, nil), check.Equals, true)
 	c.Check(cmd.canAccess(put, nil), check.Equals, false)
 
+	post := &http.Request{Method: "POST", RemoteAddr: "pid=100;uid=42;"}
+	cmd = &Command{d: newTestDaemon(c), UserPostOK: true}
+	c.Check(cmd.canAccess(get, nil), check.Equals, false)
+	c.Check(cmd.canAccess(put, nil), check.Equals, false)
+	c.Check(cmd.canAccess(post, nil), check.Equals, true)
+	c.Check(cmd.canAccess(&http.Request{Method: "POST"}, nil), check.Equals, false)
+
 	// Since this request has a RemoteAddr, it must be coming from the snapd
 	// socket instead of the snap one. In that case, SnapOK should have no
 	// bearing on the default behavior, which is to deny access.
@@ -435,6 +560,133 @@ func (s *daemonSuite) TestRestartWiring(c *check.C) {
 	}
 }
 
+func (s *daemonSuite) TestStopDaemonWiring(c *check.C) {
+	d := newTestDaemon(c)
+	// mark as already seeded
+	s.markSeeded(d)
+
+	var scheduled time.Time
+	oldScheduleStart := systemdScheduleStart
+	systemdScheduleStart = func(timerName, serviceName string, t time.Time) error {
+		c.Check(timerName, check.Equals, "snapd-wakeup")
+		c.Check(serviceName, check.Equals, "snapd.service")
+		scheduled = t
+		return nil
+	}
+	defer func() { systemdScheduleStart = oldScheduleStart }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	d.Start()
+	d.overlord.State().RequestRestart(state.StopDaemon)
+
+	select {
+	case <-d.Dying():
+	case <-time.After(2 * time.Second):
+		c.Fatal("RequestRestart -> overlord -> Kill chain didn't work")
+	}
+	c.Check(d.Stop(), check.Equals, ErrRestartSocket)
+	// nothing is scheduled yet, so snapd only wakes up for housekeeping
+	c.Check(scheduled.After(time.Now().Add(maxStandby-time.Minute)), check.Equals, true)
+}
+
+func (s *daemonSuite) TestStandbyNeedsSocketActivation(c *check.C) {
+	d := newTestDaemon(c)
+	s.markSeeded(d)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	d.Start()
+	c.Check(d.standbyOpinions, check.IsNil)
+	c.Check(d.Stop(), check.IsNil)
+
+	d = newTestDaemon(c)
+	s.markSeeded(d)
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+	d.socketActivated = true
+
+	d.Start()
+	c.Check(d.standbyOpinions, check.NotNil)
+	c.Check(d.Stop(), check.IsNil)
+}
+
+func (s *daemonSuite) TestCanStandby(c *check.C) {
+	d := newTestDaemon(c)
+	d.snapdServe = newShutdownServer(nil, nil)
+	c.Check(d.CanStandby(), check.Equals, true)
+
+	var conn1, conn2 net.Conn
+	conn1, conn2 = net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	d.snapdServe.trackConn(conn1, http.StateActive)
+	c.Check(d.CanStandby(), check.Equals, false)
+	d.snapdServe.trackConn(conn1, http.StateIdle)
+	c.Check(d.CanStandby(), check.Equals, true)
+
+	d.snapServe = newShutdownServer(nil, nil)
+	d.snapServe.trackConn(conn2, http.StateNew)
+	c.Check(d.CanStandby(), check.Equals, false)
+	d.snapServe.trackConn(conn2, http.StateClosed)
+	c.Check(d.CanStandby(), check.Equals, true)
+
+	// a query job is being computed
+	release := make(chan struct{})
+	_, err := d.jobs.start("test", 1000, func() Response {
+		<-release
+		return SyncResponse(nil, nil)
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(d.CanStandby(), check.Equals, false)
+	close(release)
+	for i := 0; i < 500 && d.jobs.busy(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Check(d.CanStandby(), check.Equals, true)
+
+	// an error report is being sent
+	defer func(f func() bool) { errReportSending = f }(errReportSending)
+	errReportSending = func() bool { return true }
+	c.Check(d.CanStandby(), check.Equals, false)
+}
+
+func (s *daemonSuite) TestNextWakeup(c *check.C) {
+	d := newTestDaemon(c)
+
+	// nothing is scheduled
+	now := time.Now()
+	c.Check(d.nextWakeup().After(now.Add(maxStandby-time.Minute)), check.Equals, true)
+
+	// a snapshot expires
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("snapshots", []*snapstate.Snapshot{{SetID: 1, Snap: "foo", Revision: snap.R(1), Time: now, Expiry: now.Add(3 * time.Hour)}})
+	st.Unlock()
+	c.Check(d.nextWakeup().Equal(now.Add(3*time.Hour)), check.Equals, true)
+}
+
+func (s *daemonSuite) TestWakeupTime(c *check.C) {
+	now := time.Now()
+
+	// nothing scheduled
+	c.Check(wakeupTime(now), check.Equals, now.Add(maxStandby))
+	c.Check(wakeupTime(now, time.Time{}, time.Time{}), check.Equals, now.Add(maxStandby))
+	// the earliest scheduled work
+	c.Check(wakeupTime(now, now.Add(3*time.Hour), now.Add(2*time.Hour)), check.Equals, now.Add(2*time.Hour))
+	c.Check(wakeupTime(now, now.Add(3*time.Hour), time.Time{}), check.Equals, now.Add(3*time.Hour))
+	// work due now
+	c.Check(wakeupTime(now, now.Add(-time.Hour)), check.Equals, now.Add(minStandby))
+	// work far ahead
+	c.Check(wakeupTime(now, now.Add(2*maxStandby)), check.Equals, now.Add(maxStandby))
+}
+
 func (s *daemonSuite) TestGracefulStop(c *check.C) {
 	d := newTestDaemon(c)
 
@@ -0,0 +1,233 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/auth"
+)
+
+var (
+	// queryJobExpiry is how long the outcome of a query job is kept
+	// around once it is ready, waiting for the client to fetch it.
+	queryJobExpiry = 10 * time.Minute
+	// queryJobTimeout is how long a query job can run before it is
+	// given up on, freeing its slot.
+	queryJobTimeout = 5 * time.Minute
+
+	// maxQueryJobs bounds the number of query jobs running at the same
+	// time, and maxQueryJobsPerUser those started by any single user.
+	maxQueryJobs        = 8
+	maxQueryJobsPerUser = 2
+
+	timeNow = time.Now
+)
+
+var errTooManyQueryJobs = errors.New("too many queries are running, try again later")
+
+// A queryJob is a read-only query, such as a report that is slow to put
+// together, computed in the background. Unlike changes, query jobs do
+// not touch the state and are not persisted: they live in memory until
+// they expire.
+type queryJob struct {
+	id        string
+	kind      string
+	uid       uint32
+	spawnTime time.Time
+	readyTime time.Time
+	// rsp is the outcome of the query, nil while it is running
+	rsp *resp
+}
+
+// queryJobs holds the query jobs of the daemon.
+type queryJobs struct {
+	mu     sync.Mutex
+	lastID int
+	jobs   map[string]*queryJob
+	// running counts the running jobs of each user
+	running map[uint32]int
+}
+
+// start runs the given query in the background as a new job of the
+// given kind on behalf of the given user, and returns the ID of the
+// job. It fails if too many jobs are running already.
+func (qj *queryJobs) start(kind string, uid uint32, query func() Response) (string, error) {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+
+	qj.pruneLocked()
+	if qj.runningLocked() >= maxQueryJobs || qj.running[uid] >= maxQueryJobsPerUser {
+		return "", errTooManyQueryJobs
+	}
+	if qj.jobs == nil {
+		qj.jobs = make(map[string]*queryJob)
+		qj.running = make(map[uint32]int)
+	}
+	qj.lastID++
+	job := &queryJob{
+		id:        strconv.Itoa(qj.lastID),
+		kind:      kind,
+		uid:       uid,
+		spawnTime: timeNow(),
+	}
+	qj.jobs[job.id] = job
+	qj.running[uid]++
+
+	go func() {
+		rsp, ok := query().(*resp)
+		if !ok {
+			rsp = InternalError("query %q did not produce a JSON result", kind).(*resp)
+		}
+
+		qj.mu.Lock()
+		defer qj.mu.Unlock()
+		if job.rsp != nil {
+			// timed out already
+			return
+		}
+		qj.finishLocked(job, rsp)
+	}()
+
+	return job.id, nil
+}
+
+// finishLocked records the outcome of the given running job.
+func (qj *queryJobs) finishLocked(job *queryJob, rsp *resp) {
+	job.rsp = rsp
+	job.readyTime = timeNow()
+	qj.running[job.uid]--
+	if qj.running[job.uid] == 0 {
+		delete(qj.running, job.uid)
+	}
+}
+
+func (qj *queryJobs) runningLocked() int {
+	n := 0
+	for _, running := range qj.running {
+		n += running
+	}
+	return n
+}
+
+// pruneLocked gives up on the jobs that have been running for longer
+// than queryJobTimeout, and forgets the jobs that have been ready for
+// longer than queryJobExpiry.
+func (qj *queryJobs) pruneLocked() {
+	now := timeNow()
+	for id, job := range qj.jobs {
+		if job.rsp == nil && job.spawnTime.Before(now.Add(-queryJobTimeout)) {
+			qj.finishLocked(job, InternalError("query %q timed out after %v", job.kind, queryJobTimeout).(*resp))
+		}
+		if job.rsp != nil && job.readyTime.Before(now.Add(-queryJobExpiry)) {
+			delete(qj.jobs, id)
+		}
+	}
+}
+
+// busy returns whether any query job is still running.
+func (qj *queryJobs) busy() bool {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+
+	qj.pruneLocked()
+	return qj.runningLocked() > 0
+}
+
+type jobInfo struct {
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind"`
+	Status    string      `json:"status"`
+	Ready     bool        `json:"ready"`
+	Err       string      `json:"err,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+	SpawnTime time.Time   `json:"spawn-time,omitempty"`
+	ReadyTime *time.Time  `json:"ready-time,omitempty"`
+}
+
+// info returns the representation of the job with the given ID in the
+// API, or nil if there is no such job, it expired, or it was started by
+// another user than the given one; root gets to see all the jobs.
+func (qj *queryJobs) info(id string, uid uint32) *jobInfo {
+	qj.mu.Lock()
+	defer qj.mu.Unlock()
+
+	qj.pruneLocked()
+	job := qj.jobs[id]
+	if job == nil || (uid != 0 && uid != job.uid) {
+		return nil
+	}
+
+	info := &jobInfo{
+		ID:        job.id,
+		Kind:      job.kind,
+		Status:    "Doing",
+		SpawnTime: job.spawnTime,
+	}
+	if job.rsp == nil {
+		return info
+	}
+	readyTime := job.readyTime
+	info.Ready = true
+	info.ReadyTime = &readyTime
+	if job.rsp.Type == ResponseTypeError {
+		info.Status = "Error"
+		if res, ok := job.rsp.Result.(*errorResult); ok {
+			info.Err = res.Message
+		}
+		return info
+	}
+	info.Status = "Done"
+	info.Result = job.rsp.Result
+	return info
+}
+
+// maybeAsyncQuery runs the given read-only query in the background if
+// the request asks for it with async=true, answering with the job to
+// poll for the result; otherwise the query is answered right away.
+func maybeAsyncQuery(c *Command, r *http.Request, kind string, query func() Response) Response {
+	if r.URL.Query().Get("async") != "true" {
+		return query()
+	}
+	// requests without credentials all count against the nobody user
+	_, uid, _ := ucrednetGet(r.RemoteAddr)
+	jobID, err := c.d.jobs.start(kind, uid, query)
+	if err != nil {
+		return TooManyRequests("%v", err)
+	}
+	return AsyncResponse(map[string]interface{}{
+		"resource": "/v2/jobs/" + jobID,
+	}, &Meta{Job: jobID})
+}
+
+func getJob(c *Command, r *http.Request, user *auth.UserState) Response {
+	jobID := muxVars(r)["id"]
+	// as when starting them, requests without credentials are nobody's
+	_, uid, _ := ucrednetGet(r.RemoteAddr)
+	info := c.d.jobs.info(jobID, uid)
+	if info == nil {
+		return NotFound("cannot find job with id %q", jobID)
+	}
+	return SyncResponse(info, nil)
+}
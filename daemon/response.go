@@ -68,6 +68,7 @@ type Meta struct {
 	Paging            *Paging  `json:"paging,omitempty"`
 	SuggestedCurrency string   `json:"suggested-currency,omitempty"`
 	Change            string   `json:"change,omitempty"`
+	Job               string   `json:"job,omitempty"`
 }
 
 type Paging struct {
@@ -142,6 +143,8 @@ const (
 	errorKindSnapNeedsDevMode       = errorKind("snap-needs-devmode")
 	errorKindSnapNeedsClassic       = errorKind("snap-needs-classic")
 	errorKindSnapNeedsClassicSystem = errorKind("snap-needs-classic-system")
+
+	errorKindSnapHasDependents = errorKind("snap-has-dependents")
 )
 
 type errorValue interface{}
@@ -315,6 +318,7 @@ var (
 	NotImplemented   = makeErrorResponder(501)
 	Forbidden        = makeErrorResponder(403)
 	Conflict         = makeErrorResponder(409)
+	TooManyRequests  = makeErrorResponder(429)
 )
 
 // SnapNotFound is an error responder used when an operation is
@@ -28,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
@@ -339,10 +340,13 @@ func mapLocal(about aboutSnap) *client.Snap {
 		Version:         localSnap.Version,
 		Channel:         localSnap.Channel,
 		TrackingChannel: snapst.Channel,
+		BranchExpiry:    snapst.BranchExpiry,
+		ClosedBranch:    snapst.TrackingClosedBranch(),
 		Confinement:     string(localSnap.Confinement),
 		DevMode:         snapst.DevMode,
 		TryMode:         snapst.TryMode,
 		JailMode:        snapst.JailMode,
+		ForeignArch:     !arch.IsSupportedArchitecture(localSnap.Architectures),
 		Private:         localSnap.Private,
 		Apps:            apps,
 		Broken:          localSnap.Broken,
@@ -396,6 +400,10 @@ func mapRemote(remoteSnap *snap.Info) *client.Snap {
 		Prices:       remoteSnap.Prices,
 		Channels:     remoteSnap.Channels,
 		Tracks:       remoteSnap.Tracks,
+		DefaultTrack: remoteSnap.DefaultTrack,
+
+		PublisherValidation: remoteSnap.PublisherValidation,
+		PublisherContact:    remoteSnap.PublisherContact,
 	}
 
 	return result
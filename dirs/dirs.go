@@ -37,6 +37,8 @@ var (
 	DistroLibExecDir string
 
 	SnapBlobDir               string
+	SnapPreDownloadDir        string
+	SnapshotsDir              string
 	SnapDataDir               string
 	SnapDataHomeGlob          string
 	SnapAppArmorDir           string
@@ -63,7 +65,12 @@ var (
 	SnapTrustedAccountKey string
 	SnapAssertsSpoolDir   string
 
-	SnapStateFile string
+	SnapStateFile      string
+	SnapTaskLogsDir    string
+	SnapBackupViewsDir string
+
+	SnapErrorReportsDir string
+	SnapDebugDir        string
 
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -83,8 +90,13 @@ var (
 	SystemApparmorDir      string
 	SystemApparmorCacheDir string
 
+	SnapInterfacesPolicyFile string
+	SnapRegistriesFile       string
+
 	CloudMetaDataFile string
 
+	ConsoleConfCompleteFile string
+
 	ClassicDir string
 
 	XdgRuntimeDirBase string
@@ -182,6 +194,8 @@ func SetRootDir(rootdir string) {
 	SnapMountPolicyDir = filepath.Join(rootdir, snappyDir, "mount")
 	SnapMetaDir = filepath.Join(rootdir, snappyDir, "meta")
 	SnapBlobDir = filepath.Join(rootdir, snappyDir, "snaps")
+	SnapPreDownloadDir = filepath.Join(SnapBlobDir, "pre-download")
+	SnapshotsDir = filepath.Join(rootdir, snappyDir, "snapshots")
 	SnapDesktopFilesDir = filepath.Join(rootdir, snappyDir, "desktop", "applications")
 	SnapRunDir = filepath.Join(rootdir, "/run/snapd")
 	SnapRunNsDir = filepath.Join(SnapRunDir, "/ns")
@@ -196,6 +210,8 @@ func SetRootDir(rootdir string) {
 	SnapAssertsSpoolDir = filepath.Join(rootdir, "run/snapd/auto-import")
 
 	SnapStateFile = filepath.Join(rootdir, snappyDir, "state.json")
+	SnapTaskLogsDir = filepath.Join(rootdir, snappyDir, "task-logs")
+	SnapBackupViewsDir = filepath.Join(rootdir, snappyDir, "backup-views")
 
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
 	SnapNamesFile = filepath.Join(SnapCacheDir, "names")
@@ -204,6 +220,9 @@ func SetRootDir(rootdir string) {
 	SnapSeedDir = filepath.Join(rootdir, snappyDir, "seed")
 	SnapDeviceDir = filepath.Join(rootdir, snappyDir, "device")
 
+	SnapErrorReportsDir = filepath.Join(rootdir, snappyDir, "error-reports")
+	SnapDebugDir = filepath.Join(rootdir, snappyDir, "debug")
+
 	SnapRepairDir = filepath.Join(rootdir, snappyDir, "repair")
 	SnapRepairStateFile = filepath.Join(SnapRepairDir, "repair.json")
 	SnapRepairRunDir = filepath.Join(SnapRepairDir, "run")
@@ -217,8 +236,13 @@ func SetRootDir(rootdir string) {
 	SystemApparmorDir = filepath.Join(rootdir, "/etc/apparmor.d")
 	SystemApparmorCacheDir = filepath.Join(rootdir, "/etc/apparmor.d/cache")
 
+	SnapInterfacesPolicyFile = filepath.Join(rootdir, "/etc/snapd/interfaces-policy.yaml")
+	SnapRegistriesFile = filepath.Join(rootdir, "/etc/snapd/registries.yaml")
+
 	CloudMetaDataFile = filepath.Join(rootdir, "/var/lib/cloud/seed/nocloud-net/meta-data")
 
+	ConsoleConfCompleteFile = filepath.Join(rootdir, "/var/lib/console-conf/complete")
+
 	SnapUdevRulesDir = filepath.Join(rootdir, "/etc/udev/rules.d")
 
 	SnapKModModulesDir = filepath.Join(rootdir, "/etc/modules-load.d/")
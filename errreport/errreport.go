@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package errreport builds structured reports about errors in snapd
+// and delivers them through pluggable sinks.
+package errreport
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
+)
+
+var (
+	// SnapdVersion is the version of snapd put into reports.
+	SnapdVersion string
+
+	timeNow = time.Now
+)
+
+// Report is a structured error report.
+type Report struct {
+	// Kind is what failed, e.g. "snap", "hook" or "panic".
+	Kind string `json:"kind"`
+	// Snap is the snap involved, if any.
+	Snap    string `json:"snap,omitempty"`
+	Message string `json:"message"`
+	// DuplicateSignature identifies reports about the same problem.
+	DuplicateSignature string            `json:"duplicate-signature,omitempty"`
+	Extra              map[string]string `json:"extra,omitempty"`
+
+	Date           time.Time `json:"date"`
+	SnapdVersion   string    `json:"snapd-version"`
+	Architecture   string    `json:"architecture"`
+	DistroRelease  string    `json:"distro-release"`
+	KernelVersion  string    `json:"kernel-version"`
+	DidSnapdReExec bool      `json:"did-snapd-reexec"`
+}
+
+// New returns a report of the given kind with the details about the
+// system filled in.
+func New(kind, snap, msg, dupSig string, extra map[string]string) *Report {
+	return &Report{
+		Kind:               kind,
+		Snap:               snap,
+		Message:            msg,
+		DuplicateSignature: dupSig,
+		Extra:              extra,
+
+		Date:           timeNow(),
+		SnapdVersion:   SnapdVersion,
+		Architecture:   arch.UbuntuArchitecture(),
+		DistroRelease:  fmt.Sprintf("%s %s", release.ReleaseInfo.ID, release.ReleaseInfo.VersionID),
+		KernelVersion:  release.KernelVersion(),
+		DidSnapdReExec: osutil.GetenvBool("SNAP_DID_REEXEC"),
+	}
+}
+
+var privacyFilters = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// credentials, e.g. from authorization headers
+	{regexp.MustCompile(`\b(root|discharge)="[^"]*"`), `$1="<redacted>"`},
+	{regexp.MustCompile(`(?i)\bbearer\s+\S+`), `Bearer <redacted>`},
+	// user names in home directories
+	{regexp.MustCompile(`/home/[^/\s"']+`), `/home/<user>`},
+	{regexp.MustCompile(`[[:alnum:]._%+-]+@[[:alnum:].-]+\.[[:alpha:]]{2,}`), `<email>`},
+	{regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`), `<ip>`},
+}
+
+func filterPrivate(s string) string {
+	for _, f := range privacyFilters {
+		s = f.re.ReplaceAllString(s, f.repl)
+	}
+	return s
+}
+
+// Filtered returns a copy of the report with private information,
+// like credentials, user names, e-mail and IP addresses, removed from
+// the message, the duplicate signature and the extra values.
+func (r *Report) Filtered() *Report {
+	fr := *r
+	fr.Message = filterPrivate(r.Message)
+	fr.DuplicateSignature = filterPrivate(r.DuplicateSignature)
+	if r.Extra != nil {
+		fr.Extra = make(map[string]string, len(r.Extra))
+		for k, v := range r.Extra {
+			fr.Extra[k] = filterPrivate(v)
+		}
+	}
+	return &fr
+}
@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package errreport_test
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// Hook up check.v1 into the "go test" runner
+func Test(t *testing.T) { TestingT(t) }
+
+type errreportSuite struct {
+	testutil.BaseTest
+
+	now time.Time
+}
+
+var _ = Suite(&errreportSuite{})
+
+func (s *errreportSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.now = time.Date(2017, 10, 2, 12, 30, 0, 0, time.UTC)
+	s.AddCleanup(errreport.MockTimeNow(func() time.Time { return s.now }))
+	s.AddCleanup(release.MockReleaseInfo(&release.OS{ID: "ubuntu", VersionID: "16.04"}))
+
+	oldVersion := errreport.SnapdVersion
+	errreport.SnapdVersion = "2.28"
+	s.AddCleanup(func() { errreport.SnapdVersion = oldVersion })
+}
+
+func (s *errreportSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *errreportSuite) TestNew(c *C) {
+	r := errreport.New("snap", "some-snap", "something broke", "sig", map[string]string{"Channel": "stable"})
+	c.Check(r, DeepEquals, &errreport.Report{
+		Kind:               "snap",
+		Snap:               "some-snap",
+		Message:            "something broke",
+		DuplicateSignature: "sig",
+		Extra:              map[string]string{"Channel": "stable"},
+
+		Date:          s.now,
+		SnapdVersion:  "2.28",
+		Architecture:  arch.UbuntuArchitecture(),
+		DistroRelease: "ubuntu 16.04",
+		KernelVersion: release.KernelVersion(),
+	})
+}
+
+func (s *errreportSuite) TestFiltered(c *C) {
+	r := errreport.New("snap", "some-snap", "cannot read /home/jdoe/snap/foo: sent to foo@example.com from 10.0.0.1",
+		`Authorization: Macaroon root="secret", discharge="other-secret"`,
+		map[string]string{"Header": "Authorization: Bearer s3cr3t"})
+
+	fr := r.Filtered()
+	c.Check(fr.Message, Equals, "cannot read /home/<user>/snap/foo: sent to <email> from <ip>")
+	c.Check(fr.DuplicateSignature, Equals, `Authorization: Macaroon root="<redacted>", discharge="<redacted>"`)
+	c.Check(fr.Extra, DeepEquals, map[string]string{"Header": "Authorization: Bearer <redacted>"})
+	c.Check(fr.Snap, Equals, "some-snap")
+	c.Check(fr.Date, Equals, s.now)
+
+	// the original report is left alone
+	c.Check(r.Message, Equals, "cannot read /home/jdoe/snap/foo: sent to foo@example.com from 10.0.0.1")
+	c.Check(r.Extra["Header"], Equals, "Authorization: Bearer s3cr3t")
+}
@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package errreport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// A Sink delivers reports somewhere.
+type Sink interface {
+	// Send delivers the report and returns an identifier for it.
+	Send(r *Report) (id string, err error)
+}
+
+type httpSink struct {
+	url string
+}
+
+// NewHTTPSink returns a sink that posts reports as JSON to the given
+// URL. The endpoint can reply with an identifier for the report in the
+// body.
+func NewHTTPSink(url string) Sink {
+	return &httpSink{url: url}
+}
+
+func (s *httpSink) Send(r *Report) (string, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", httputil.UserAgent())
+
+	resp, err := httputil.NewHTTPClient(nil).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("cannot upload error report, return code: %d", resp.StatusCode)
+	}
+	id, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(id)), nil
+}
+
+type spoolSink struct {
+	dir string
+}
+
+// NewSpoolSink returns a sink that writes reports as JSON files into
+// the given directory, for them to be collected later.
+func NewSpoolSink(dir string) Sink {
+	return &spoolSink{dir: dir}
+}
+
+func (s *spoolSink) Send(r *Report) (string, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	id := fmt.Sprintf("%s-%x", r.Date.UTC().Format("20060102T150405Z"), sum[:8])
+	if err := osutil.AtomicWriteFile(filepath.Join(s.dir, id+".json"), body, 0600, 0); err != nil {
+		return "", err
+	}
+	return id, nil
+}
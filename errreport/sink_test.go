@@ -0,0 +1,84 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package errreport_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/errreport"
+)
+
+func (s *errreportSuite) TestHTTPSink(c *C) {
+	r := errreport.New("hook", "some-snap", "hook failed", "sig", nil)
+
+	n := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		c.Check(req.Method, Equals, "POST")
+		c.Check(req.URL.Path, Equals, "/reports")
+		c.Check(req.Header.Get("Content-Type"), Equals, "application/json")
+
+		var got errreport.Report
+		c.Assert(json.NewDecoder(req.Body).Decode(&got), IsNil)
+		c.Check(got.Kind, Equals, "hook")
+		c.Check(got.Snap, Equals, "some-snap")
+		c.Check(got.Message, Equals, "hook failed")
+		c.Check(got.SnapdVersion, Equals, "2.28")
+
+		w.Write([]byte("report-id\n"))
+	}))
+	defer server.Close()
+
+	id, err := errreport.NewHTTPSink(server.URL + "/reports").Send(r)
+	c.Assert(err, IsNil)
+	c.Check(id, Equals, "report-id")
+	c.Check(n, Equals, 1)
+}
+
+func (s *errreportSuite) TestHTTPSinkError(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	_, err := errreport.NewHTTPSink(server.URL).Send(errreport.New("snap", "", "msg", "", nil))
+	c.Check(err, ErrorMatches, "cannot upload error report, return code: 500")
+}
+
+func (s *errreportSuite) TestSpoolSink(c *C) {
+	dir := filepath.Join(c.MkDir(), "spool")
+	r := errreport.New("snap", "some-snap", "something broke", "sig", nil)
+
+	id, err := errreport.NewSpoolSink(dir).Send(r)
+	c.Assert(err, IsNil)
+	c.Check(id, Matches, `20171002T123000Z-[0-9a-f]{16}`)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, id+".json"))
+	c.Assert(err, IsNil)
+	var got errreport.Report
+	c.Assert(json.Unmarshal(data, &got), IsNil)
+	c.Check(&got, DeepEquals, r)
+}
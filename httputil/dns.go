@@ -0,0 +1,329 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httputil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DNS resolution modes for DNSConfig.
+const (
+	// DNSOverHTTPS resolves with DNS queries sent to an HTTPS endpoint, as
+	// per RFC 8484.
+	DNSOverHTTPS = "https"
+	// DNSOverTLS resolves with DNS queries sent over a TLS connection, as
+	// per RFC 7858.
+	DNSOverTLS = "tls"
+)
+
+// DNSConfig tells how to resolve the names of the hosts to connect to
+// instead of going through the resolver of the system.
+type DNSConfig struct {
+	// Mode is either DNSOverHTTPS or DNSOverTLS. The system resolver is
+	// used if it is empty.
+	Mode string
+	// Server is the URL of the endpoint for DNSOverHTTPS, or the
+	// host[:port] of the resolver for DNSOverTLS (on port 853 by
+	// default). Using an IP address avoids relying on the resolver of
+	// the system to find the server itself.
+	Server string
+}
+
+// Validate checks that the configuration is usable.
+func (conf *DNSConfig) Validate() error {
+	switch conf.Mode {
+	case "":
+		return nil
+	case DNSOverHTTPS:
+		u, err := url.Parse(conf.Server)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid DNS-over-HTTPS server %q: must be an https URL", conf.Server)
+		}
+	case DNSOverTLS:
+		if conf.Server == "" {
+			return fmt.Errorf("invalid DNS-over-TLS server: must not be empty")
+		}
+	default:
+		return fmt.Errorf("unsupported DNS resolution mode %q", conf.Mode)
+	}
+	return nil
+}
+
+var (
+	dnsTimeout = 10 * time.Second
+	// dnsTLSConfig is the TLS configuration to talk to the DNS servers,
+	// the default one if nil.
+	dnsTLSConfig *tls.Config
+)
+
+// LookupIP returns the IPv4 then the IPv6 addresses of the host as
+// resolved by the configured server.
+func (conf *DNSConfig) LookupIP(host string) ([]net.IP, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	if conf.Mode == "" {
+		return net.LookupIP(host)
+	}
+	var ips []net.IP
+	var firstErr error
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		found, err := conf.lookup(host, qtype)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		ips = append(ips, found...)
+	}
+	if len(ips) == 0 {
+		if firstErr != nil {
+			return nil, fmt.Errorf("cannot resolve %q: %v", host, firstErr)
+		}
+		return nil, fmt.Errorf("cannot resolve %q: no such host", host)
+	}
+	return ips, nil
+}
+
+func (conf *DNSConfig) lookup(host string, qtype uint16) ([]net.IP, error) {
+	// DNS-over-HTTPS asks for an id of 0 to be friendly to caches
+	var id uint16
+	if conf.Mode == DNSOverTLS {
+		id = uint16(rand.Intn(1 << 16))
+	}
+	query, err := dnsQuery(id, host, qtype)
+	if err != nil {
+		return nil, err
+	}
+	var answer []byte
+	if conf.Mode == DNSOverHTTPS {
+		answer, err = conf.exchangeHTTPS(query)
+	} else {
+		answer, err = conf.exchangeTLS(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseDNSAnswer(answer, id, qtype)
+}
+
+func (conf *DNSConfig) exchangeHTTPS(query []byte) ([]byte, error) {
+	req, err := http.NewRequest("POST", conf.Server, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	transport := newDefaultTransport()
+	transport.TLSClientConfig = dnsTLSConfig
+	client := &http.Client{Transport: transport, Timeout: dnsTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned %q", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
+
+func (conf *DNSConfig) exchangeTLS(query []byte) ([]byte, error) {
+	server := conf.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "853")
+	}
+	dialer := &net.Dialer{Timeout: dnsTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", server, dnsTLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	// messages over streams are prefixed with their length
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var size uint16
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, size)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+
+	dnsHeaderSize = 12
+)
+
+var errInvalidDNSAnswer = errors.New("invalid DNS answer")
+
+// dnsQuery returns a DNS message asking for the records of the given
+// type of the host.
+func dnsQuery(id uint16, host string, qtype uint16) ([]byte, error) {
+	var buf bytes.Buffer
+	// header: id, recursion desired, one question
+	binary.Write(&buf, binary.BigEndian, []uint16{id, 0x0100, 1, 0, 0, 0})
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid host name %q", host)
+		}
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+	binary.Write(&buf, binary.BigEndian, []uint16{qtype, dnsClassIN})
+	return buf.Bytes(), nil
+}
+
+// skipDNSName returns the offset right after the (possibly compressed)
+// name starting at the given offset of the message.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errInvalidDNSAnswer
+		}
+		size := int(msg[off])
+		switch {
+		case size == 0:
+			return off + 1, nil
+		case size&0xc0 == 0xc0:
+			// pointer to a name elsewhere, which ends the name
+			if off+2 > len(msg) {
+				return 0, errInvalidDNSAnswer
+			}
+			return off + 2, nil
+		}
+		off += 1 + size
+	}
+}
+
+// parseDNSAnswer returns the addresses of the given type found in the
+// answer to the query with the given id.
+func parseDNSAnswer(msg []byte, id uint16, qtype uint16) ([]net.IP, error) {
+	if len(msg) < dnsHeaderSize {
+		return nil, errInvalidDNSAnswer
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != id {
+		return nil, fmt.Errorf("DNS answer does not match the query")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	switch rcode := flags & 0xf; rcode {
+	case 0:
+	case 3:
+		// no such domain
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("DNS query failed with code %d", rcode)
+	}
+	questions := int(binary.BigEndian.Uint16(msg[4:6]))
+	answers := int(binary.BigEndian.Uint16(msg[6:8]))
+
+	off := dnsHeaderSize
+	for i := 0; i < questions; i++ {
+		var err error
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		// type and class
+		off += 4
+	}
+	var ips []net.IP
+	for i := 0; i < answers; i++ {
+		var err error
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		// type, class, ttl and length of the data
+		if off+10 > len(msg) {
+			return nil, errInvalidDNSAnswer
+		}
+		rtype := binary.BigEndian.Uint16(msg[off : off+2])
+		size := int(binary.BigEndian.Uint16(msg[off+8 : off+10]))
+		off += 10
+		if off+size > len(msg) {
+			return nil, errInvalidDNSAnswer
+		}
+		// CNAME records are skipped, those of their target follow
+		if rtype == qtype && (size == net.IPv4len || size == net.IPv6len) {
+			ips = append(ips, net.IP(append([]byte(nil), msg[off:off+size]...)))
+		}
+		off += size
+	}
+	return ips, nil
+}
+
+// dnsDial returns a function dialing the given addresses after resolving
+// their host with the DNS configuration the given function returns.
+func dnsDial(dnsConfig func() (*DNSConfig, error)) func(network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return func(network, addr string) (net.Conn, error) {
+		conf, err := dnsConfig()
+		if err != nil {
+			return nil, err
+		}
+		if conf == nil || conf.Mode == "" {
+			return dialer.Dial(network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.Dial(network, addr)
+		}
+		ips, err := conf.LookupIP(host)
+		if err != nil {
+			return nil, err
+		}
+		var firstErr error
+		for _, ip := range ips {
+			conn, err := dialer.Dial(network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil, firstErr
+	}
+}
@@ -0,0 +1,226 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httputil_test
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/httputil"
+)
+
+type dnsSuite struct {
+	restore func()
+}
+
+var _ = Suite(&dnsSuite{})
+
+func (s *dnsSuite) SetUpTest(c *C) {
+	s.restore = httputil.MockDNSTLSConfig(&tls.Config{InsecureSkipVerify: true})
+}
+
+func (s *dnsSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+// dnsAnswer answers the query with the given addresses, after a CNAME
+// record to check that those are skipped.
+func dnsAnswer(c *C, query []byte, ips ...net.IP) []byte {
+	qtype := binary.BigEndian.Uint16(query[len(query)-4:])
+	answer := append([]byte(nil), query...)
+	// response, recursion available
+	binary.BigEndian.PutUint16(answer[2:4], 0x8180)
+	// a CNAME to the name of the question, then the addresses with a
+	// pointer to the question as name
+	records := 1
+	answer = append(answer, 0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60, 0, 2, 0xc0, 12)
+	for _, ip := range ips {
+		data := ip.To4()
+		if qtype == 28 {
+			data = ip.To16()
+		} else if data == nil {
+			continue
+		}
+		if qtype == 28 && ip.To4() != nil {
+			continue
+		}
+		answer = append(answer, 0xc0, 12, 0, byte(qtype), 0, 1, 0, 0, 0, 60, 0, byte(len(data)))
+		answer = append(answer, data...)
+		records++
+	}
+	binary.BigEndian.PutUint16(answer[6:8], uint16(records))
+	return answer
+}
+
+func (s *dnsSuite) TestQueryAndAnswer(c *C) {
+	query, err := httputil.DNSQuery(42, "api.snapcraft.io.", 1)
+	c.Assert(err, IsNil)
+	c.Check(query, DeepEquals, []byte{
+		0, 42, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0,
+		3, 'a', 'p', 'i', 9, 's', 'n', 'a', 'p', 'c', 'r', 'a', 'f', 't', 2, 'i', 'o', 0,
+		0, 1, 0, 1,
+	})
+
+	ips, err := httputil.ParseDNSAnswer(dnsAnswer(c, query, net.ParseIP("10.1.2.3"), net.ParseIP("::1")), 42, 1)
+	c.Assert(err, IsNil)
+	c.Check(ips, DeepEquals, []net.IP{net.IP{10, 1, 2, 3}})
+
+	_, err = httputil.ParseDNSAnswer(dnsAnswer(c, query), 43, 1)
+	c.Check(err, ErrorMatches, "DNS answer does not match the query")
+	_, err = httputil.ParseDNSAnswer(dnsAnswer(c, query, net.ParseIP("10.1.2.3"))[:40], 42, 1)
+	c.Check(err, ErrorMatches, "invalid DNS answer")
+
+	_, err = httputil.DNSQuery(42, "api..snapcraft.io", 1)
+	c.Check(err, ErrorMatches, `invalid host name "api..snapcraft.io"`)
+}
+
+func (s *dnsSuite) TestValidate(c *C) {
+	for _, t := range []struct {
+		conf httputil.DNSConfig
+		err  string
+	}{
+		{httputil.DNSConfig{}, ""},
+		{httputil.DNSConfig{Mode: "https", Server: "https://1.1.1.1/dns-query"}, ""},
+		{httputil.DNSConfig{Mode: "https", Server: "1.1.1.1"}, `invalid DNS-over-HTTPS server "1.1.1.1": must be an https URL`},
+		{httputil.DNSConfig{Mode: "tls", Server: "1.1.1.1"}, ""},
+		{httputil.DNSConfig{Mode: "tls"}, "invalid DNS-over-TLS server: must not be empty"},
+		{httputil.DNSConfig{Mode: "udp", Server: "1.1.1.1"}, `unsupported DNS resolution mode "udp"`},
+	} {
+		err := t.conf.Validate()
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%v", t.conf))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%v", t.conf))
+		}
+	}
+}
+
+func (s *dnsSuite) TestLookupOverHTTPS(c *C) {
+	var hosts []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/dns-query")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/dns-message")
+		query, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		hosts = append(hosts, string(query[13:16]))
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(c, query, net.ParseIP("10.1.2.3"), net.ParseIP("fd00::1")))
+	}))
+	defer server.Close()
+
+	conf := &httputil.DNSConfig{Mode: "https", Server: server.URL + "/dns-query"}
+	ips, err := conf.LookupIP("api.snapcraft.io")
+	c.Assert(err, IsNil)
+	c.Check(ips, DeepEquals, []net.IP{net.IP{10, 1, 2, 3}, net.ParseIP("fd00::1")})
+	c.Check(hosts, DeepEquals, []string{"api", "api"})
+}
+
+func (s *dnsSuite) TestLookupOverHTTPSError(c *C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(502)
+	}))
+	defer server.Close()
+
+	conf := &httputil.DNSConfig{Mode: "https", Server: server.URL}
+	_, err := conf.LookupIP("api.snapcraft.io")
+	c.Check(err, ErrorMatches, `cannot resolve "api.snapcraft.io": DNS-over-HTTPS server returned "502 Bad Gateway"`)
+}
+
+// serveDNSOverTLS answers the queries sent over TLS on the returned
+// address with the given addresses.
+func serveDNSOverTLS(c *C, ips ...net.IP) (addr string, stop func()) {
+	// borrow the certificate of the test HTTPS server
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: server.TLS.Certificates})
+	c.Assert(err, IsNil)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var size uint16
+				if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+					return
+				}
+				query := make([]byte, size)
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				answer := dnsAnswer(c, query, ips...)
+				binary.Write(conn, binary.BigEndian, uint16(len(answer)))
+				conn.Write(answer)
+			}()
+		}
+	}()
+	return l.Addr().String(), func() { l.Close() }
+}
+
+func (s *dnsSuite) TestLookupOverTLS(c *C) {
+	addr, stop := serveDNSOverTLS(c, net.ParseIP("10.1.2.3"))
+	defer stop()
+
+	conf := &httputil.DNSConfig{Mode: "tls", Server: addr}
+	ips, err := conf.LookupIP("api.snapcraft.io")
+	c.Assert(err, IsNil)
+	c.Check(ips, DeepEquals, []net.IP{net.IP{10, 1, 2, 3}})
+}
+
+func (s *dnsSuite) TestClientResolvesWithDNSConfig(c *C) {
+	addr, stop := serveDNSOverTLS(c, net.ParseIP("127.0.0.1"))
+	defer stop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Host, Equals, "store.invalid:"+r.Host[len("store.invalid:"):])
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	c.Assert(err, IsNil)
+	_, port, err := net.SplitHostPort(u.Host)
+	c.Assert(err, IsNil)
+
+	calls := 0
+	cli := httputil.NewHTTPClient(&httputil.ClientOpts{
+		DNS: func() (*httputil.DNSConfig, error) {
+			calls++
+			return &httputil.DNSConfig{Mode: "tls", Server: addr}, nil
+		},
+	})
+	resp, err := cli.Get("http://store.invalid:" + port + "/")
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Equals, "hello")
+	c.Check(calls, Equals, 1)
+}
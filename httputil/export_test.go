@@ -19,6 +19,10 @@
 
 package httputil
 
+import (
+	"crypto/tls"
+)
+
 var (
 	GetFlags              = (*LoggedTransport).getFlags
 	StripUnsafeRunes      = stripUnsafeRunes
@@ -32,3 +36,16 @@ func MockUserAgent(mock string) (restore func()) {
 		userAgent = old
 	}
 }
+
+var (
+	DNSQuery       = dnsQuery
+	ParseDNSAnswer = parseDNSAnswer
+)
+
+func MockDNSTLSConfig(conf *tls.Config) (restore func()) {
+	old := dnsTLSConfig
+	dnsTLSConfig = conf
+	return func() {
+		dnsTLSConfig = old
+	}
+}
@@ -92,6 +92,10 @@ type ClientOpts struct {
 	Timeout    time.Duration
 	TLSConfig  *tls.Config
 	MayLogBody bool
+
+	// DNS returns how to resolve the hosts to connect to, checked
+	// before each new connection. The system resolver is used if nil.
+	DNS func() (*DNSConfig, error)
 }
 
 // NewHTTPCLient returns a new http.Client with a LoggedTransport, a
@@ -103,6 +107,9 @@ func NewHTTPClient(opts *ClientOpts) *http.Client {
 
 	transport := newDefaultTransport()
 	transport.TLSClientConfig = opts.TLSConfig
+	if opts.DNS != nil {
+		setDial(transport, dnsDial(opts.DNS))
+	}
 
 	return &http.Client{
 		Transport: &LoggedTransport{
@@ -22,6 +22,7 @@
 package httputil
 
 import (
+	"net"
 	"net/http"
 	"time"
 )
@@ -39,3 +40,8 @@ func newDefaultTransport() *http.Transport {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 }
+
+// setDial makes the transport use the given function to dial.
+func setDial(tr *http.Transport, dial func(network, addr string) (net.Conn, error)) {
+	tr.Dial = dial
+}
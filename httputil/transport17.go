@@ -22,6 +22,8 @@
 package httputil
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"time"
 )
@@ -41,3 +43,10 @@ func newDefaultTransport() *http.Transport {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 }
+
+// setDial makes the transport use the given function to dial.
+func setDial(tr *http.Transport, dial func(network, addr string) (net.Conn, error)) {
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dial(network, addr)
+	}
+}
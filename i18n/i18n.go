@@ -38,6 +38,7 @@ import (
 var (
 	TEXTDOMAIN   = "snappy"
 	locale       gettext.Catalog
+	language     string
 	translations gettext.Translations
 )
 
@@ -92,9 +93,16 @@ func setLocale(loc string) {
 	loc = strings.Split(loc, "@")[0]
 	loc = strings.Split(loc, ".")[0]
 
+	language = loc
 	locale = translations.Locale(loc)
 }
 
+// Language returns the language of the current locale, such as "de_DE",
+// to pick translations of texts that do not come from message catalogs.
+func Language() string {
+	return language
+}
+
 // G is the shorthand for Gettext
 func G(msgid string) string {
 	return locale.Gettext(msgid)
@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Customizations holds the per-image customizations embedded by
+// Prepare, usually read from a customization file with
+// ReadCustomizations. The file is YAML of the form:
+//
+//	console-conf: disabled
+//	cloud-init-user-data: |
+//	  #cloud-config
+//	  ...
+//	defaults:
+//	  <snap-name>:
+//	    <key>: <value>
+type Customizations struct {
+	// ConsoleConf can be set to "disabled" so that console-conf
+	// does not run on first boot.
+	ConsoleConf string `yaml:"console-conf,omitempty"`
+	// CloudInitUserData is seeded into the image for cloud-init
+	// through its NoCloud datasource. It must be either cloud-config
+	// or a script.
+	CloudInitUserData string `yaml:"cloud-init-user-data,omitempty"`
+	// Defaults holds default configuration for snaps in the image
+	// (snap name => key => value), it overrides the gadget defaults.
+	Defaults map[string]map[string]interface{} `yaml:"defaults,omitempty"`
+}
+
+// ReadCustomizations reads and validates the customization file fn.
+func ReadCustomizations(fn string) (*Customizations, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read customizations: %s", err)
+	}
+
+	var cust Customizations
+	if err := yaml.Unmarshal(data, &cust); err != nil {
+		return nil, fmt.Errorf("cannot parse customizations %q: %s", fn, err)
+	}
+	if err := cust.validate(); err != nil {
+		return nil, err
+	}
+	return &cust, nil
+}
+
+func (cust *Customizations) validate() error {
+	switch cust.ConsoleConf {
+	case "", "disabled":
+		// ok
+	default:
+		return fmt.Errorf(`cannot use console-conf customization %q: only "disabled" is supported`, cust.ConsoleConf)
+	}
+
+	userData := cust.CloudInitUserData
+	switch {
+	case userData == "", strings.HasPrefix(userData, "#!"):
+		// ok
+	case strings.HasPrefix(userData, "#cloud-config\n"):
+		var cfg map[string]interface{}
+		if err := yaml.Unmarshal([]byte(userData), &cfg); err != nil {
+			return fmt.Errorf("cannot parse cloud-init user data: %s", err)
+		}
+	default:
+		return fmt.Errorf(`cannot use cloud-init user data: must start with "#cloud-config" or "#!"`)
+	}
+
+	for name := range cust.Defaults {
+		if err := snap.ValidateName(name); err != nil {
+			return fmt.Errorf("cannot use defaults: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// cloud-init NoCloud datasource seed, relative to the root dir
+const noCloudSeedDir = "/var/lib/cloud/seed/nocloud-net"
+
+// customizeImage applies the customizations that are not part of the
+// seed to the image under dirs.GlobalRootDir.
+func customizeImage(cust *Customizations) error {
+	if cust.ConsoleConf == "disabled" {
+		if err := os.MkdirAll(filepath.Dir(dirs.ConsoleConfCompleteFile), 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(dirs.ConsoleConfCompleteFile, nil, 0644, 0); err != nil {
+			return err
+		}
+	}
+
+	if cust.CloudInitUserData != "" {
+		seedDir := filepath.Join(dirs.GlobalRootDir, noCloudSeedDir)
+		if err := os.MkdirAll(seedDir, 0755); err != nil {
+			return err
+		}
+		// NoCloud needs meta-data to be present, the instance-id
+		// is all it requires
+		metaData := []byte("instance-id: nocloud-static\n")
+		if err := osutil.AtomicWriteFile(filepath.Join(seedDir, "meta-data"), metaData, 0644, 0); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(filepath.Join(seedDir, "user-data"), []byte(cust.CloudInitUserData), 0600, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/osutil"
+)
+
+func (s *imageSuite) TestReadCustomizations(c *C) {
+	fn := filepath.Join(c.MkDir(), "customize.yaml")
+	err := ioutil.WriteFile(fn, []byte(`
+console-conf: disabled
+cloud-init-user-data: |
+  #cloud-config
+  hostname: foo
+defaults:
+  core:
+    service:
+      rsyslog:
+        disable: true
+  some-snap:
+    key: value
+`), 0644)
+	c.Assert(err, IsNil)
+
+	cust, err := image.ReadCustomizations(fn)
+	c.Assert(err, IsNil)
+	c.Check(cust.ConsoleConf, Equals, "disabled")
+	c.Check(cust.CloudInitUserData, Equals, "#cloud-config\nhostname: foo\n")
+	c.Check(cust.Defaults, HasLen, 2)
+	c.Check(cust.Defaults["some-snap"], DeepEquals, map[string]interface{}{"key": "value"})
+}
+
+func (s *imageSuite) TestReadCustomizationsErrors(c *C) {
+	fn := filepath.Join(c.MkDir(), "customize.yaml")
+
+	_, err := image.ReadCustomizations(fn)
+	c.Check(err, ErrorMatches, `cannot read customizations: .*`)
+
+	for _, t := range []struct {
+		content string
+		err     string
+	}{
+		{"console-conf: [", `cannot parse customizations ".*": .*`},
+		{"console-conf: enabled", `cannot use console-conf customization "enabled": only "disabled" is supported`},
+		{"cloud-init-user-data: foo", `cannot use cloud-init user data: must start with "#cloud-config" or "#!"`},
+		{"cloud-init-user-data: \"#cloud-config\\nfoo: [\"", `cannot parse cloud-init user data: .*`},
+		{"defaults:\n  Bad_Name:\n    key: value", `cannot use defaults: invalid snap name: "Bad_Name"`},
+		{"defaults:\n  some-snap: foo", `(?s)cannot parse customizations ".*": .*`},
+	} {
+		err := ioutil.WriteFile(fn, []byte(t.content), 0644)
+		c.Assert(err, IsNil)
+		_, err = image.ReadCustomizations(fn)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.content))
+	}
+}
+
+func (s *imageSuite) TestPrepareValidatesCustomizations(c *C) {
+	err := image.Prepare(&image.Options{
+		ModelFile:      filepath.Join(c.MkDir(), "model"),
+		Customizations: &image.Customizations{ConsoleConf: "maybe"},
+	})
+	c.Check(err, ErrorMatches, `cannot use console-conf customization "maybe": .*`)
+}
+
+func (s *imageSuite) TestCustomizeImage(c *C) {
+	targetDir := c.MkDir()
+	dirs.SetRootDir(targetDir)
+	defer dirs.SetRootDir("/")
+
+	err := image.CustomizeImage(&image.Customizations{
+		ConsoleConf:       "disabled",
+		CloudInitUserData: "#cloud-config\nhostname: foo\n",
+	})
+	c.Assert(err, IsNil)
+
+	c.Check(osutil.FileExists(filepath.Join(targetDir, "var/lib/console-conf/complete")), Equals, true)
+
+	userData, err := ioutil.ReadFile(filepath.Join(targetDir, "var/lib/cloud/seed/nocloud-net/user-data"))
+	c.Assert(err, IsNil)
+	c.Check(string(userData), Equals, "#cloud-config\nhostname: foo\n")
+	metaData, err := ioutil.ReadFile(filepath.Join(targetDir, "var/lib/cloud/seed/nocloud-net/meta-data"))
+	c.Assert(err, IsNil)
+	c.Check(string(metaData), Equals, "instance-id: nocloud-static\n")
+}
+
+func (s *imageSuite) TestCustomizeImageNothing(c *C) {
+	targetDir := c.MkDir()
+	dirs.SetRootDir(targetDir)
+	defer dirs.SetRootDir("/")
+
+	err := image.CustomizeImage(&image.Customizations{})
+	c.Assert(err, IsNil)
+
+	c.Check(osutil.FileExists(filepath.Join(targetDir, "var/lib/console-conf/complete")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(targetDir, "var/lib/cloud")), Equals, false)
+}
@@ -33,6 +33,7 @@ var (
 	DownloadUnpackGadget = downloadUnpackGadget
 	BootstrapToRootDir   = bootstrapToRootDir
 	InstallCloudConfig   = installCloudConfig
+	CustomizeImage       = customizeImage
 )
 
 func (tsto *ToolingStore) User() *auth.UserState {
@@ -35,7 +35,6 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/partition"
-	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/squashfs"
 	"github.com/snapcore/snapd/strutil"
@@ -52,6 +51,9 @@ type Options struct {
 	Channel         string
 	ModelFile       string
 	GadgetUnpackDir string
+
+	// Customizations to embed into the image, if any.
+	Customizations *Customizations
 }
 
 type localInfos struct {
@@ -122,14 +124,19 @@ func localSnaps(tsto *ToolingStore, opts *Options) (*localInfos, error) {
 }
 
 func Prepare(opts *Options) error {
+	if opts.Customizations != nil {
+		if err := opts.Customizations.validate(); err != nil {
+			return err
+		}
+	}
+
 	model, err := decodeModelAssertion(opts)
 	if err != nil {
 		return err
 	}
 
-	// TODO: might make sense to support this later
-	if model.Classic() {
-		return fmt.Errorf("cannot prepare image of a classic model")
+	if err := validateModel(model); err != nil {
+		return err
 	}
 
 	tsto, err := NewToolingStoreFromModel(model)
@@ -142,11 +149,6 @@ func Prepare(opts *Options) error {
 		return err
 	}
 
-	// FIXME: limitation until we can pass series parametrized much more
-	if model.Series() != release.Series {
-		return fmt.Errorf("model with series %q != %q unsupported", model.Series(), release.Series)
-	}
-
 	if err := downloadUnpackGadget(tsto, model, opts, local); err != nil {
 		return err
 	}
@@ -175,10 +177,8 @@ func decodeModelAssertion(opts *Options) (*asserts.Model, error) {
 		return nil, fmt.Errorf("assertion in %q is not a model assertion", fn)
 	}
 
-	for _, rsvd := range reserved {
-		if modela.Header(rsvd) != nil {
-			return nil, fmt.Errorf("model assertion cannot have reserved/unsupported header %q set", rsvd)
-		}
+	if err := checkReservedHeaders(modela); err != nil {
+		return nil, err
 	}
 
 	return modela, nil
@@ -352,19 +352,8 @@ func bootstrapToRootDir(tsto *ToolingStore, model *asserts.Model, opts *Options,
 			if err != nil {
 				return err
 			}
-			var kind string
-			switch typ {
-			case snap.TypeKernel:
-				kind = "kernel"
-			case snap.TypeGadget:
-				kind = "gadget"
-			}
-			if kind != "" { // kernel or gadget
-				// TODO: share helpers with devicestate if the policy becomes much more complicated
-				publisher := snapDecl.PublisherID()
-				if publisher != model.BrandID() && publisher != "canonical" {
-					return fmt.Errorf("cannot use %s %q published by %q for model by %q", kind, name, publisher, model.BrandID())
-				}
+			if err := checkPublisher(model, info, snapDecl.PublisherID()); err != nil {
+				return err
 			}
 		} else {
 			locals = append(locals, name)
@@ -393,6 +382,15 @@ func bootstrapToRootDir(tsto *ToolingStore, model *asserts.Model, opts *Options,
 			Unasserted: info.SnapID == "",
 		})
 	}
+	if opts.Customizations != nil && len(opts.Customizations.Defaults) > 0 {
+		for name := range opts.Customizations.Defaults {
+			if !seen[name] {
+				return fmt.Errorf("cannot set defaults for snap %q: not part of the image", name)
+			}
+		}
+		seedYaml.Defaults = opts.Customizations.Defaults
+	}
+
 	if len(locals) > 0 {
 		fmt.Fprintf(Stderr, "WARNING: %s were installed from local snaps disconnected from a store and cannot be refreshed subsequently!\n", strutil.Quoted(locals))
 	}
@@ -436,6 +434,12 @@ func bootstrapToRootDir(tsto *ToolingStore, model *asserts.Model, opts *Options,
 		return err
 	}
 
+	if opts.Customizations != nil {
+		if err := customizeImage(opts.Customizations); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
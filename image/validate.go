@@ -0,0 +1,212 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SeedSnap describes a snap that is going to be put into a seed, for
+// the benefit of ValidateSeed.
+type SeedSnap struct {
+	Info *snap.Info
+	// Publisher is the account-id of the publisher from the
+	// snap-declaration of the snap, empty for unasserted snaps.
+	Publisher string
+}
+
+// ValidateOptions holds the inputs of ValidateSeed.
+type ValidateOptions struct {
+	// Model is the model assertion the seed is for. If it is nil
+	// the model assertion is read from ModelFile instead.
+	Model     *asserts.Model
+	ModelFile string
+
+	Snaps []*SeedSnap
+}
+
+// MissingSnapError reports a snap needed by the model that is not
+// part of the seed.
+type MissingSnapError struct {
+	Snap string
+	// Role is one of "core", "kernel", "gadget" or "required".
+	Role string
+}
+
+func (e *MissingSnapError) Error() string {
+	if e.Role == "required" {
+		return fmt.Sprintf("cannot find required snap %q", e.Snap)
+	}
+	return fmt.Sprintf("cannot find %s snap %q", e.Role, e.Snap)
+}
+
+// SnapTypeError reports a snap whose type does not match the role the
+// model gives it.
+type SnapTypeError struct {
+	Snap     string
+	Expected snap.Type
+	Type     snap.Type
+}
+
+func (e *SnapTypeError) Error() string {
+	return fmt.Sprintf("cannot use snap %q of type %q as the model %s", e.Snap, e.Type, e.Expected)
+}
+
+// PublisherMismatchError reports a kernel or gadget snap published by
+// someone other than the brand of the model or canonical.
+type PublisherMismatchError struct {
+	Kind      string
+	Snap      string
+	Publisher string
+	Brand     string
+}
+
+func (e *PublisherMismatchError) Error() string {
+	return fmt.Sprintf("cannot use %s %q published by %q for model by %q", e.Kind, e.Snap, e.Publisher, e.Brand)
+}
+
+// ValidationError is returned by ValidateSeed with all the problems
+// found in the seed.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("cannot validate seed: %v", e.Errors[0])
+	}
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = fmt.Sprintf("- %v", err)
+	}
+	return fmt.Sprintf("cannot validate seed:\n%s", strings.Join(msgs, "\n"))
+}
+
+func checkReservedHeaders(model *asserts.Model) error {
+	for _, rsvd := range reserved {
+		if model.Header(rsvd) != nil {
+			return fmt.Errorf("model assertion cannot have reserved/unsupported header %q set", rsvd)
+		}
+	}
+	return nil
+}
+
+// validateModel checks that a seed can be prepared for the model.
+func validateModel(model *asserts.Model) error {
+	// TODO: might make sense to support this later
+	if model.Classic() {
+		return fmt.Errorf("cannot prepare image of a classic model")
+	}
+
+	// FIXME: limitation until we can pass series parametrized much more
+	if model.Series() != release.Series {
+		return fmt.Errorf("model with series %q != %q unsupported", model.Series(), release.Series)
+	}
+
+	return nil
+}
+
+// checkPublisher checks that a kernel or gadget snap comes from the
+// brand of the model or from canonical.
+func checkPublisher(model *asserts.Model, info *snap.Info, publisher string) error {
+	var kind string
+	switch info.Type {
+	case snap.TypeKernel:
+		kind = "kernel"
+	case snap.TypeGadget:
+		kind = "gadget"
+	default:
+		return nil
+	}
+	// TODO: share helpers with devicestate if the policy becomes much more complicated
+	if publisher != model.BrandID() && publisher != "canonical" {
+		return &PublisherMismatchError{
+			Kind:      kind,
+			Snap:      info.Name(),
+			Publisher: publisher,
+			Brand:     model.BrandID(),
+		}
+	}
+	return nil
+}
+
+// ValidateSeed checks the snaps meant to go into a seed against the
+// constraints of the model, without fetching or writing anything. It
+// is meant for tools building images on their own. Problems with the
+// model itself are returned as is, problems with the snaps are all
+// collected into a *ValidationError.
+func ValidateSeed(opts *ValidateOptions) error {
+	model := opts.Model
+	if model == nil {
+		var err error
+		model, err = decodeModelAssertion(&Options{ModelFile: opts.ModelFile})
+		if err != nil {
+			return err
+		}
+	} else if err := checkReservedHeaders(model); err != nil {
+		return err
+	}
+	if err := validateModel(model); err != nil {
+		return err
+	}
+
+	byName := make(map[string]*SeedSnap, len(opts.Snaps))
+	for _, sn := range opts.Snaps {
+		byName[sn.Info.Name()] = sn
+	}
+
+	var errs []error
+	expect := func(name, role string, typ snap.Type) {
+		sn := byName[name]
+		if sn == nil {
+			errs = append(errs, &MissingSnapError{Snap: name, Role: role})
+			return
+		}
+		if typ != "" && sn.Info.Type != typ {
+			errs = append(errs, &SnapTypeError{Snap: name, Expected: typ, Type: sn.Info.Type})
+		}
+	}
+	expect(defaultCore, "core", snap.TypeOS)
+	expect(model.Kernel(), "kernel", snap.TypeKernel)
+	expect(model.Gadget(), "gadget", snap.TypeGadget)
+	for _, name := range model.RequiredSnaps() {
+		expect(name, "required", "")
+	}
+
+	for _, sn := range opts.Snaps {
+		if sn.Info.SnapID == "" {
+			// unasserted snaps have no publisher to check
+			continue
+		}
+		if err := checkPublisher(model, sn.Info, sn.Publisher); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
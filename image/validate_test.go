@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/snap"
+)
+
+func seedSnap(c *C, snapYaml, snapID, publisher string) *image.SeedSnap {
+	info, err := snap.InfoFromSnapYaml([]byte(snapYaml))
+	c.Assert(err, IsNil)
+	info.SnapID = snapID
+	return &image.SeedSnap{Info: info, Publisher: publisher}
+}
+
+func (s *imageSuite) TestValidateSeedHappy(c *C) {
+	err := image.ValidateSeed(&image.ValidateOptions{
+		Model: s.model,
+		Snaps: []*image.SeedSnap{
+			seedSnap(c, packageCore, "core-id", "canonical"),
+			seedSnap(c, packageKernel, "pc-kernel-id", "my-brand"),
+			seedSnap(c, packageGadget, "pc-id", "canonical"),
+			seedSnap(c, requiredSnap1, "", ""),
+		},
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *imageSuite) TestValidateSeedModelFile(c *C) {
+	fn := filepath.Join(c.MkDir(), "model.assertion")
+	err := ioutil.WriteFile(fn, asserts.Encode(s.model), 0644)
+	c.Assert(err, IsNil)
+
+	err = image.ValidateSeed(&image.ValidateOptions{
+		ModelFile: fn,
+		Snaps: []*image.SeedSnap{
+			seedSnap(c, packageCore, "", ""),
+			seedSnap(c, packageKernel, "", ""),
+			seedSnap(c, packageGadget, "", ""),
+			seedSnap(c, requiredSnap1, "", ""),
+		},
+	})
+	c.Check(err, IsNil)
+
+	err = image.ValidateSeed(&image.ValidateOptions{
+		ModelFile: filepath.Join(c.MkDir(), "missing"),
+	})
+	c.Check(err, ErrorMatches, `cannot read model assertion: .*`)
+}
+
+func (s *imageSuite) TestValidateSeedErrors(c *C) {
+	err := image.ValidateSeed(&image.ValidateOptions{
+		Model: s.model,
+		Snaps: []*image.SeedSnap{
+			seedSnap(c, packageCore, "core-id", "canonical"),
+			seedSnap(c, packageKernel, "pc-kernel-id", "other"),
+			seedSnap(c, "name: pc\nversion: 1.0\n", "", ""),
+		},
+	})
+	c.Assert(err, FitsTypeOf, &image.ValidationError{})
+	c.Check(err, ErrorMatches, `cannot validate seed:
+- cannot use snap "pc" of type "app" as the model gadget
+- cannot find required snap "required-snap1"
+- cannot use kernel "pc-kernel" published by "other" for model by "my-brand"`)
+
+	errs := err.(*image.ValidationError).Errors
+	c.Assert(errs, HasLen, 3)
+	c.Check(errs[0], DeepEquals, &image.SnapTypeError{Snap: "pc", Expected: snap.TypeGadget, Type: snap.TypeApp})
+	c.Check(errs[1], DeepEquals, &image.MissingSnapError{Snap: "required-snap1", Role: "required"})
+	c.Check(errs[2], DeepEquals, &image.PublisherMismatchError{Kind: "kernel", Snap: "pc-kernel", Publisher: "other", Brand: "my-brand"})
+}
+
+func (s *imageSuite) TestValidateSeedSingleError(c *C) {
+	err := image.ValidateSeed(&image.ValidateOptions{
+		Model: s.model,
+		Snaps: []*image.SeedSnap{
+			seedSnap(c, packageKernel, "", ""),
+			seedSnap(c, packageGadget, "", ""),
+			seedSnap(c, requiredSnap1, "", ""),
+		},
+	})
+	c.Check(err, ErrorMatches, `cannot validate seed: cannot find core snap "core"`)
+}
+
+func (s *imageSuite) TestValidateSeedClassicModel(c *C) {
+	model, err := s.brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"authority-id": "my-brand",
+		"brand-id":     "my-brand",
+		"model":        "my-classic",
+		"classic":      "true",
+		"timestamp":    "2017-01-01T00:00:00Z",
+	}, nil, "")
+	c.Assert(err, IsNil)
+
+	err = image.ValidateSeed(&image.ValidateOptions{Model: model.(*asserts.Model)})
+	c.Check(err, ErrorMatches, `cannot prepare image of a classic model`)
+}
@@ -259,6 +259,14 @@ func addContent(securityTag string, snapInfo *snap.Info, opts interfaces.Confine
 		}
 		return ""
 	})
+	// The rules of the interfaces that can be prompted for are prefixed
+	// by ###PROMPT### in the snippets, making the kernel ask about them
+	// when prompting is enabled.
+	prompt := ""
+	if opts.Prompting {
+		prompt = "prompt "
+	}
+	policy = strings.Replace(policy, "###PROMPT###", prompt, -1)
 
 	content[securityTag] = &osutil.FileState{
 		Content: []byte(policy),
@@ -335,6 +335,16 @@ var combineSnippetsScenarios = []combineSnippetsScenario{{
 	opts:    interfaces.ConfinementOptions{},
 	snippet: "snippet",
 	content: commonPrefix + "\nprofile \"snap.samba.smbd\" (attach_disconnected) {\nsnippet\n}\n",
+}, {
+	// Rules that can be prompted for are allowed outright without prompting.
+	opts:    interfaces.ConfinementOptions{},
+	snippet: "###PROMPT###/dev/video[0-9]* rw,",
+	content: commonPrefix + "\nprofile \"snap.samba.smbd\" (attach_disconnected) {\n/dev/video[0-9]* rw,\n}\n",
+}, {
+	// Prompting makes the kernel ask about the rules that can be prompted for.
+	opts:    interfaces.ConfinementOptions{Prompting: true},
+	snippet: "###PROMPT###/dev/video[0-9]* rw,",
+	content: commonPrefix + "\nprofile \"snap.samba.smbd\" (attach_disconnected) {\nprompt /dev/video[0-9]* rw,\n}\n",
 }, {
 	// DevMode switches apparmor to non-enforcing (complain) mode.
 	opts:    interfaces.ConfinementOptions{DevMode: true},
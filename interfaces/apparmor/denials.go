@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// ProcessLabel returns the apparmor label the given process is confined
+// by, such as "snap.foo.bar (enforce)", or "unconfined".
+func ProcessLabel(pid int) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/proc", strconv.Itoa(pid), "attr/current"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(string(data), "\x00")), nil
+}
+
+// LabelProfile returns the name of the profile of the given label, that
+// is the label without its mode.
+func LabelProfile(label string) string {
+	if i := strings.LastIndex(label, " ("); i >= 0 && strings.HasSuffix(label, ")") {
+		return label[:i]
+	}
+	return label
+}
+
+// Denial is an access denied by apparmor, as logged by the kernel.
+type Denial struct {
+	Time          time.Time `json:"time"`
+	Profile       string    `json:"profile"`
+	Operation     string    `json:"operation,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Pid           int       `json:"pid,omitempty"`
+	Comm          string    `json:"comm,omitempty"`
+	RequestedMask string    `json:"requested-mask,omitempty"`
+	DeniedMask    string    `json:"denied-mask,omitempty"`
+}
+
+var auditField = regexp.MustCompile(`([a-z_]+)=("[^"]*"|[^ ]*)`)
+
+// ParseDenial parses a kernel audit message about an access denied by
+// apparmor. It returns false if the message is about something else.
+func ParseDenial(msg string) (*Denial, bool) {
+	if !strings.Contains(msg, `apparmor="DENIED"`) {
+		return nil, false
+	}
+	var d Denial
+	for _, m := range auditField.FindAllStringSubmatch(msg, -1) {
+		value := strings.Trim(m[2], `"`)
+		switch m[1] {
+		case "profile":
+			d.Profile = value
+		case "operation":
+			d.Operation = value
+		case "name":
+			d.Name = value
+		case "pid":
+			d.Pid, _ = strconv.Atoi(value)
+		case "comm":
+			d.Comm = value
+		case "requested_mask":
+			d.RequestedMask = value
+		case "denied_mask":
+			d.DeniedMask = value
+		}
+	}
+	if d.Profile == "" {
+		return nil, false
+	}
+	return &d, true
+}
+
+// kernelLog streams the last n messages of the kernel log as JSON.
+var kernelLog = func(n int) (io.ReadCloser, error) {
+	return osutil.StreamCommand("journalctl", "-k", "-o", "json", "-n", strconv.Itoa(n), "--no-pager")
+}
+
+// RecentDenials returns the apparmor denials found among the last n
+// messages of the kernel log, oldest first.
+func RecentDenials(n int) ([]*Denial, error) {
+	r, err := kernelLog(n)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the kernel log: %v", err)
+	}
+	defer r.Close()
+
+	var denials []*Denial
+	dec := json.NewDecoder(r)
+	for {
+		var entry map[string]interface{}
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("cannot decode the kernel log: %v", err)
+		}
+		// journalctl outputs binary fields as arrays of bytes; those
+		// are not audit messages, so only keep the string ones
+		log := make(systemd.Log, len(entry))
+		for k, v := range entry {
+			if s, ok := v.(string); ok {
+				log[k] = s
+			}
+		}
+		d, ok := ParseDenial(log.Message())
+		if !ok {
+			continue
+		}
+		d.Time, _ = log.Time()
+		denials = append(denials, d)
+	}
+	return denials, nil
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+type denialsSuite struct{}
+
+var _ = Suite(&denialsSuite{})
+
+func (s *denialsSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (s *denialsSuite) TestProcessLabel(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	attr := filepath.Join(dirs.GlobalRootDir, "/proc/42/attr/current")
+	c.Assert(os.MkdirAll(filepath.Dir(attr), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(attr, []byte("snap.foo.bar (enforce)\n\x00"), 0644), IsNil)
+
+	label, err := apparmor.ProcessLabel(42)
+	c.Assert(err, IsNil)
+	c.Check(label, Equals, "snap.foo.bar (enforce)")
+	c.Check(apparmor.LabelProfile(label), Equals, "snap.foo.bar")
+	c.Check(apparmor.LabelProfile("unconfined"), Equals, "unconfined")
+
+	_, err = apparmor.ProcessLabel(43)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *denialsSuite) TestParseDenial(c *C) {
+	d, ok := apparmor.ParseDenial(`audit: type=1400 audit(1528975520.123:45): apparmor="DENIED" operation="open" profile="snap.foo.bar" name="/etc/shadow" pid=1234 comm="bar" requested_mask="r" denied_mask="r" fsuid=1000 ouid=0`)
+	c.Assert(ok, Equals, true)
+	c.Check(d, DeepEquals, &apparmor.Denial{
+		Profile:       "snap.foo.bar",
+		Operation:     "open",
+		Name:          "/etc/shadow",
+		Pid:           1234,
+		Comm:          "bar",
+		RequestedMask: "r",
+		DeniedMask:    "r",
+	})
+
+	_, ok = apparmor.ParseDenial(`audit: type=1400 audit(1528975520.123:46): apparmor="ALLOWED" operation="open" profile="snap.foo.bar" name="/etc/hosts"`)
+	c.Check(ok, Equals, false)
+	_, ok = apparmor.ParseDenial(`usb 1-1: new high-speed USB device number 2`)
+	c.Check(ok, Equals, false)
+}
+
+func (s *denialsSuite) TestRecentDenials(c *C) {
+	var n int
+	restore := apparmor.MockKernelLog(func(lines int) (io.ReadCloser, error) {
+		n = lines
+		return ioutil.NopCloser(strings.NewReader(`{"__REALTIME_TIMESTAMP":"1528975520123456","MESSAGE":"audit: type=1400 audit(1528975520.123:45): apparmor=\"DENIED\" operation=\"open\" profile=\"snap.foo.bar\" name=\"/etc/shadow\" pid=1234 comm=\"bar\" requested_mask=\"r\" denied_mask=\"r\""}
+{"__REALTIME_TIMESTAMP":"1528975521000000","MESSAGE":[1,2,3]}
+{"__REALTIME_TIMESTAMP":"1528975522000000","MESSAGE":"usb 1-1: new high-speed USB device number 2"}
+`)), nil
+	})
+	defer restore()
+
+	denials, err := apparmor.RecentDenials(100)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 100)
+	c.Assert(denials, HasLen, 1)
+	c.Check(denials[0].Time.Equal(time.Unix(1528975520, 123456000)), Equals, true)
+	c.Check(denials[0].Profile, Equals, "snap.foo.bar")
+	c.Check(denials[0].Name, Equals, "/etc/shadow")
+}
+
+func (s *denialsSuite) TestRecentDenialsError(c *C) {
+	restore := apparmor.MockKernelLog(func(int) (io.ReadCloser, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	_, err := apparmor.RecentDenials(100)
+	c.Check(err, ErrorMatches, "cannot read the kernel log: boom")
+}
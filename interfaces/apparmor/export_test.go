@@ -20,6 +20,8 @@
 package apparmor
 
 import (
+	"io"
+
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -47,3 +49,10 @@ func MockClassicTemplate(fakeTemplate string) (restore func()) {
 	classicTemplate = fakeTemplate
 	return func() { classicTemplate = orig }
 }
+
+// MockKernelLog replaces the function streaming the kernel log.
+func MockKernelLog(f func(n int) (io.ReadCloser, error)) (restore func()) {
+	old := kernelLog
+	kernelLog = f
+	return func() { kernelLog = old }
+}
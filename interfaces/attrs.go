@@ -0,0 +1,236 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package interfaces
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+// AttrType is the type of the value of an attribute.
+type AttrType string
+
+const (
+	AttrString     AttrType = "string"
+	AttrBool       AttrType = "bool"
+	AttrInt        AttrType = "int"
+	AttrStringList AttrType = "list-of-strings"
+	AttrIntList    AttrType = "list-of-ints"
+)
+
+// AttrSpec describes an attribute of plugs or slots and the values it
+// can take.
+type AttrSpec struct {
+	Name        string   `json:"name"`
+	Type        AttrType `json:"type"`
+	Required    bool     `json:"required,omitempty"`
+	Description string   `json:"description,omitempty"`
+
+	// Enum lists the values a string attribute can take.
+	Enum []string `json:"enum,omitempty"`
+	// Path is set for string attributes holding an absolute path, which
+	// is cleaned before being matched against Patterns.
+	Path bool `json:"path,omitempty"`
+	// Patterns are the regular expressions a string attribute must match
+	// one of, as summarized by PatternsDescription in error messages.
+	Patterns            []*regexp.Regexp `json:"-"`
+	PatternsDescription string           `json:"patterns,omitempty"`
+	// Min and Max bound the value of an int attribute, or of the items
+	// of a list of ints, when set.
+	Min *int64 `json:"min,omitempty"`
+	Max *int64 `json:"max,omitempty"`
+}
+
+// AttrSchema describes the attributes of the plugs or the slots of an
+// interface. Attributes not described are left to the interface to check.
+type AttrSchema []AttrSpec
+
+// Validate checks the attributes of a plug or slot (as told by kind) of
+// the given interface against the schema.
+func (schema AttrSchema) Validate(ifaceName, kind string, attrs map[string]interface{}) error {
+	for i := range schema {
+		spec := &schema[i]
+		value, ok := attrs[spec.Name]
+		if !ok {
+			if spec.Required {
+				return fmt.Errorf("%s %s must contain the %s attribute", ifaceName, kind, spec.Name)
+			}
+			continue
+		}
+		if err := spec.validate(value); err != nil {
+			return fmt.Errorf("%s %s attribute %q %v", ifaceName, kind, spec.Name, err)
+		}
+	}
+	return nil
+}
+
+func (schema AttrSchema) has(name string) bool {
+	for i := range schema {
+		if schema[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (spec *AttrSpec) validate(value interface{}) error {
+	switch spec.Type {
+	case AttrString:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		return spec.validateString(s)
+	case AttrBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a bool")
+		}
+	case AttrInt:
+		n, ok := attrInt(value)
+		if !ok {
+			return fmt.Errorf("must be an int")
+		}
+		return spec.validateInt(n)
+	case AttrIntList:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be a list of ints")
+		}
+		for _, item := range list {
+			n, ok := attrInt(item)
+			if !ok {
+				return fmt.Errorf("must be a list of ints")
+			}
+			if err := spec.validateInt(n); err != nil {
+				return err
+			}
+		}
+	case AttrStringList:
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("must be a list of strings")
+		}
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("must be a list of strings")
+			}
+			if err := spec.validateString(s); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("has unknown type %q", spec.Type)
+	}
+	return nil
+}
+
+func attrInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func (spec *AttrSpec) validateInt(n int64) error {
+	if spec.Min != nil && n < *spec.Min || spec.Max != nil && n > *spec.Max {
+		return fmt.Errorf("must be %s (got %d)", spec.describeRange(), n)
+	}
+	return nil
+}
+
+func (spec *AttrSpec) validateString(s string) error {
+	if s == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if len(spec.Enum) > 0 && !strutil.ListContains(spec.Enum, s) {
+		return fmt.Errorf("must be one of %s (got %q)", strutil.Quoted(spec.Enum), s)
+	}
+	if spec.Path {
+		if !filepath.IsAbs(s) {
+			return fmt.Errorf("must be an absolute path (got %q)", s)
+		}
+		s = filepath.Clean(s)
+	}
+	if len(spec.Patterns) == 0 {
+		return nil
+	}
+	for _, pattern := range spec.Patterns {
+		if pattern.MatchString(s) {
+			return nil
+		}
+	}
+	if spec.PatternsDescription != "" {
+		return fmt.Errorf("must be %s (got %q)", spec.PatternsDescription, s)
+	}
+	return fmt.Errorf("has an invalid value %q", s)
+}
+
+func (spec *AttrSpec) describeRange() string {
+	switch {
+	case spec.Min != nil && spec.Max != nil:
+		return fmt.Sprintf("between %d and %d", *spec.Min, *spec.Max)
+	case spec.Min != nil:
+		return fmt.Sprintf("at least %d", *spec.Min)
+	default:
+		return fmt.Sprintf("at most %d", *spec.Max)
+	}
+}
+
+// Doc returns a human readable description of the attributes of the
+// schema, one per line, suitable for documenting the interface.
+func (schema AttrSchema) Doc() string {
+	var buf bytes.Buffer
+	for _, spec := range schema {
+		fmt.Fprintf(&buf, "%s (%s", spec.Name, spec.Type)
+		if spec.Required {
+			buf.WriteString(", required")
+		}
+		buf.WriteString(")")
+		if spec.Description != "" {
+			fmt.Fprintf(&buf, ": %s", spec.Description)
+		}
+		var constraints []string
+		if len(spec.Enum) > 0 {
+			constraints = append(constraints, "one of "+strutil.Quoted(spec.Enum))
+		}
+		if spec.Path {
+			constraints = append(constraints, "an absolute path")
+		}
+		if spec.PatternsDescription != "" {
+			constraints = append(constraints, spec.PatternsDescription)
+		}
+		if spec.Min != nil || spec.Max != nil {
+			constraints = append(constraints, spec.describeRange())
+		}
+		if len(constraints) > 0 {
+			fmt.Fprintf(&buf, " [%s]", strings.Join(constraints, "; "))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package interfaces_test
+
+import (
+	"regexp"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/snap"
+)
+
+type AttrsSuite struct{}
+
+var _ = Suite(&AttrsSuite{})
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+var testSchema = AttrSchema{{
+	Name:     "path",
+	Type:     AttrString,
+	Required: true,
+	Path:     true,
+	Patterns: []*regexp.Regexp{
+		regexp.MustCompile("^/dev/tty[0-9]+$"),
+		regexp.MustCompile("^/dev/ttyUSB[0-9]+$"),
+	},
+	PatternsDescription: "a serial device",
+}, {
+	Name: "mode",
+	Type: AttrString,
+	Enum: []string{"read", "write"},
+}, {
+	Name: "enabled",
+	Type: AttrBool,
+}, {
+	Name: "speed",
+	Type: AttrInt,
+	Min:  int64Ptr(1),
+	Max:  int64Ptr(9600),
+}, {
+	Name: "names",
+	Type: AttrStringList,
+	Enum: []string{"foo", "bar"},
+}, {
+	Name: "ports",
+	Type: AttrIntList,
+	Max:  int64Ptr(3),
+}}
+
+func (s *AttrsSuite) TestValidateHappy(c *C) {
+	c.Check(testSchema.Validate("iface", "slot", map[string]interface{}{
+		"path": "/dev/tty1",
+	}), IsNil)
+	c.Check(testSchema.Validate("iface", "slot", map[string]interface{}{
+		"path":    "/dev/../dev/ttyUSB0",
+		"mode":    "read",
+		"enabled": true,
+		"speed":   int64(9600),
+		"names":   []interface{}{"foo", "bar"},
+		"ports":   []interface{}{int64(0), 3},
+		"other":   "not described",
+	}), IsNil)
+	c.Check(AttrSchema(nil).Validate("iface", "slot", nil), IsNil)
+}
+
+func (s *AttrsSuite) TestValidateErrors(c *C) {
+	for _, t := range []struct {
+		attrs map[string]interface{}
+		err   string
+	}{
+		{nil, `iface slot must contain the path attribute`},
+		{map[string]interface{}{"path": 42}, `iface slot attribute "path" must be a string`},
+		{map[string]interface{}{"path": ""}, `iface slot attribute "path" must not be empty`},
+		{map[string]interface{}{"path": "dev/tty1"}, `iface slot attribute "path" must be an absolute path \(got "dev/tty1"\)`},
+		{map[string]interface{}{"path": "/dev/sda"}, `iface slot attribute "path" must be a serial device \(got "/dev/sda"\)`},
+		{map[string]interface{}{"path": "/dev/tty1", "mode": "exec"}, `iface slot attribute "mode" must be one of "read", "write" \(got "exec"\)`},
+		{map[string]interface{}{"path": "/dev/tty1", "enabled": "yes"}, `iface slot attribute "enabled" must be a bool`},
+		{map[string]interface{}{"path": "/dev/tty1", "speed": "fast"}, `iface slot attribute "speed" must be an int`},
+		{map[string]interface{}{"path": "/dev/tty1", "speed": int64(0)}, `iface slot attribute "speed" must be between 1 and 9600 \(got 0\)`},
+		{map[string]interface{}{"path": "/dev/tty1", "speed": 9601}, `iface slot attribute "speed" must be between 1 and 9600 \(got 9601\)`},
+		{map[string]interface{}{"path": "/dev/tty1", "names": "foo"}, `iface slot attribute "names" must be a list of strings`},
+		{map[string]interface{}{"path": "/dev/tty1", "names": []interface{}{"foo", 1}}, `iface slot attribute "names" must be a list of strings`},
+		{map[string]interface{}{"path": "/dev/tty1", "names": []interface{}{"baz"}}, `iface slot attribute "names" must be one of "foo", "bar" \(got "baz"\)`},
+		{map[string]interface{}{"path": "/dev/tty1", "ports": int64(1)}, `iface slot attribute "ports" must be a list of ints`},
+		{map[string]interface{}{"path": "/dev/tty1", "ports": []interface{}{int64(1), "2"}}, `iface slot attribute "ports" must be a list of ints`},
+		{map[string]interface{}{"path": "/dev/tty1", "ports": []interface{}{int64(4)}}, `iface slot attribute "ports" must be at most 3 \(got 4\)`},
+	} {
+		c.Check(testSchema.Validate("iface", "slot", t.attrs), ErrorMatches, t.err, Commentf("%v", t.attrs))
+	}
+}
+
+func (s *AttrsSuite) TestValidateRangeBounds(c *C) {
+	schema := AttrSchema{{Name: "n", Type: AttrInt, Min: int64Ptr(0)}}
+	c.Check(schema.Validate("iface", "plug", map[string]interface{}{"n": -1}), ErrorMatches,
+		`iface plug attribute "n" must be at least 0 \(got -1\)`)
+	schema = AttrSchema{{Name: "n", Type: AttrInt, Max: int64Ptr(10)}}
+	c.Check(schema.Validate("iface", "plug", map[string]interface{}{"n": 11}), ErrorMatches,
+		`iface plug attribute "n" must be at most 10 \(got 11\)`)
+}
+
+func (s *AttrsSuite) TestDoc(c *C) {
+	c.Check(testSchema.Doc(), Equals, `path (string, required) [an absolute path; a serial device]
+mode (string) [one of "read", "write"]
+enabled (bool)
+speed (int) [between 1 and 9600]
+names (list-of-strings) [one of "foo", "bar"]
+ports (list-of-ints) [at most 3]
+`)
+	schema := AttrSchema{{Name: "n", Type: AttrInt, Description: "the answer"}}
+	c.Check(schema.Doc(), Equals, "n (int): the answer\n")
+}
+
+func (s *AttrsSuite) TestSanitizeChecksSchema(c *C) {
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "iface",
+		InterfaceStaticInfo: StaticInfo{
+			PlugAttrs: AttrSchema{{Name: "enabled", Type: AttrBool}},
+			SlotAttrs: testSchema,
+		},
+	}
+	plug := &Plug{PlugInfo: &snap.PlugInfo{
+		Snap:      &snap.Info{SuggestedName: "consumer"},
+		Name:      "plug",
+		Interface: "iface",
+		Attrs:     map[string]interface{}{"enabled": "yes"},
+	}}
+	c.Check(plug.Sanitize(iface), ErrorMatches, `iface plug attribute "enabled" must be a bool`)
+	plug.Attrs["enabled"] = true
+	c.Check(plug.Sanitize(iface), IsNil)
+
+	slot := &Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "producer"},
+		Name:      "slot",
+		Interface: "iface",
+	}}
+	c.Check(slot.Sanitize(iface), ErrorMatches, `iface slot must contain the path attribute`)
+	slot.Attrs = map[string]interface{}{"path": "/dev/tty1"}
+	c.Check(slot.Sanitize(iface), IsNil)
+}
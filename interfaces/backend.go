@@ -55,6 +55,9 @@ import (
 //
 // The Classic flag switches the layout of the mount namespace so that there's
 // no "chroot" to the core snap.
+//
+// The Prompting flag makes the security systems supporting it ask about the
+// accesses granted by the interfaces that can be prompted for.
 type ConfinementOptions struct {
 	// DevMode flag switches confinement to non-enforcing mode.
 	DevMode bool
@@ -62,6 +65,9 @@ type ConfinementOptions struct {
 	JailMode bool
 	// Classic flag switches the core snap "chroot" off.
 	Classic bool
+	// Prompting flag makes the accesses of interfaces supporting it be
+	// asked about rather than allowed outright.
+	Prompting bool
 }
 
 // SecurityBackend abstracts interactions between the interface system and the
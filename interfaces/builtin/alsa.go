@@ -19,6 +19,18 @@
 
 package builtin
 
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
 const alsaSummary = `allows access to raw ALSA devices`
 
 const alsaBaseDeclarationSlots = `
@@ -26,6 +38,7 @@ const alsaBaseDeclarationSlots = `
     allow-installation:
       slot-snap-type:
         - core
+        - gadget
     deny-auto-connection: true
 `
 
@@ -56,8 +69,143 @@ KERNEL=="seq",                   TAG+="###CONNECTED_SECURITY_TAGS###"
 SUBSYSTEM=="sound", KERNEL=="card[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"
 `
 
+const alsaCardsConnectedPlugAppArmor = `
+# Description: Allow access to the ALSA devices of some sound cards only.
+
+/dev/snd/  r,
+/dev/snd/controlC###CARDS### rw,
+/dev/snd/hwC###CARDS###D[0-9]* rw,
+/dev/snd/pcmC###CARDS###D[0-9]*[cp] rw,
+/dev/snd/midiC###CARDS###D[0-9]* rw,
+/dev/snd/timer rw,
+/dev/snd/seq rw,
+
+/run/udev/data/c116:[0-9]* r, # alsa
+/run/udev/data/+sound:card###CARDS### r,
+
+# Allow access to the alsa state dir
+/var/lib/alsa/{,*}         r,
+
+# Allow access to the alsa /proc entries of the cards
+@{PROC}/asound/   r,
+@{PROC}/asound/{cards,devices,pcm,timers,version} r,
+@{PROC}/asound/card###CARDS###/** rw,
+`
+
+const alsaCardsConnectedPlugUDev = `
+KERNEL=="controlC###CARD###",        TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="hwC###CARD###D[0-9]*",      TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="pcmC###CARD###D[0-9]*[cp]", TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="midiC###CARD###D[0-9]*",    TAG+="###CONNECTED_SECURITY_TAGS###"
+SUBSYSTEM=="sound", KERNEL=="card###CARD###", TAG+="###CONNECTED_SECURITY_TAGS###"
+`
+
+const alsaSharedConnectedPlugUDev = `
+KERNEL=="timer",                 TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="seq",                   TAG+="###CONNECTED_SECURITY_TAGS###"
+`
+
+// ALSA supports up to 32 sound cards.
+var (
+	alsaMinCard int64 = 0
+	alsaMaxCard int64 = 31
+)
+
+// alsaConfigPath is where the configuration generated for the snaps
+// restricted to some cards is mounted, for alsa-lib to pick it up.
+const alsaConfigPath = "/etc/asound.conf"
+
+// alsaInterface gives access to all the sound cards through the implicit
+// slot of the core snap, or to the cards listed in the cards attribute
+// of the slot, as declared by the gadget of audio appliances. Snaps
+// restricted to some cards get an asound.conf making the first of them
+// their default card.
+type alsaInterface struct {
+	commonInterface
+}
+
+// SanitizeSlot checks the slot is on the core or gadget snap.
+func (iface *alsaInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	return sanitizeSlotReservedForOSOrGadget(iface, slot)
+}
+
+// alsaCards returns the cards the slot is restricted to, if any.
+func alsaCards(slot *interfaces.Slot) []int64 {
+	list, _ := slot.Attrs["cards"].([]interface{})
+	cards := make([]int64, 0, len(list))
+	for _, item := range list {
+		switch card := item.(type) {
+		case int64:
+			cards = append(cards, card)
+		case int:
+			cards = append(cards, int64(card))
+		}
+	}
+	return cards
+}
+
+// alsaCardsPattern returns an apparmor pattern matching the given cards.
+func alsaCardsPattern(cards []int64) string {
+	numbers := make([]string, len(cards))
+	for i, card := range cards {
+		numbers[i] = strconv.FormatInt(card, 10)
+	}
+	if len(numbers) == 1 {
+		return numbers[0]
+	}
+	return "{" + strings.Join(numbers, ",") + "}"
+}
+
+func (iface *alsaInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	cards := alsaCards(slot)
+	if len(cards) == 0 {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, plugAttrs, slot, slotAttrs)
+	}
+	spec.AddSnippet(strings.Replace(alsaCardsConnectedPlugAppArmor, "###CARDS###", alsaCardsPattern(cards), -1))
+	return nil
+}
+
+func (iface *alsaInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	cards := alsaCards(slot)
+	if len(cards) == 0 {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, plugAttrs, slot, slotAttrs)
+	}
+	var rules bytes.Buffer
+	for _, card := range cards {
+		rules.WriteString(strings.Replace(alsaCardsConnectedPlugUDev, "###CARD###", strconv.FormatInt(card, 10), -1))
+	}
+	rules.WriteString(alsaSharedConnectedPlugUDev)
+	for appName := range plug.Apps {
+		tag := udevSnapSecurityName(plug.Snap.Name(), appName)
+		spec.AddSnippet(strings.Replace(rules.String(), "###CONNECTED_SECURITY_TAGS###", tag, -1))
+	}
+	return nil
+}
+
+// MountConnectedPlug gives the snaps restricted to some cards their own
+// alsa configuration.
+func (iface *alsaInterface) MountConnectedPlug(spec *mount.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	cards := alsaCards(slot)
+	if len(cards) == 0 {
+		return nil
+	}
+	var conf bytes.Buffer
+	fmt.Fprintf(&conf, "# Generated by snapd for the connection to %s:%s, which only\n", slot.Snap.Name(), slot.Name)
+	fmt.Fprintf(&conf, "# gives access to cards %s.\n", alsaCardsPattern(cards))
+	fmt.Fprintf(&conf, "defaults.pcm.card %d\n", cards[0])
+	fmt.Fprintf(&conf, "defaults.ctl.card %d\n", cards[0])
+	if err := spec.AddFile("asound.conf", conf.Bytes()); err != nil {
+		return err
+	}
+	return spec.AddMountEntry(mount.Entry{
+		Name:    mount.GeneratedFilePath(plug.Snap.Name(), "asound.conf"),
+		Dir:     alsaConfigPath,
+		Options: []string{"bind", "ro"},
+	})
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&alsaInterface{commonInterface{
 		name:                  "alsa",
 		summary:               alsaSummary,
 		implicitOnCore:        true,
@@ -65,6 +213,12 @@ func init() {
 		baseDeclarationSlots:  alsaBaseDeclarationSlots,
 		connectedPlugAppArmor: alsaConnectedPlugAppArmor,
 		connectedPlugUDev:     alsaConnectedPlugUDev,
-		reservedForOS:         true,
-	})
+		slotAttrs: interfaces.AttrSchema{{
+			Name:        "cards",
+			Type:        interfaces.AttrIntList,
+			Description: "numbers of the sound cards the connected snaps are restricted to, the first one being their default card (all cards when unset)",
+			Min:         &alsaMinCard,
+			Max:         &alsaMaxCard,
+		}},
+	}})
 }
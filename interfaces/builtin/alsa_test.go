@@ -25,15 +25,17 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
 type AlsaInterfaceSuite struct {
-	iface interfaces.Interface
-	slot  *interfaces.Slot
-	plug  *interfaces.Plug
+	iface      interfaces.Interface
+	slot       *interfaces.Slot
+	gadgetSlot *interfaces.Slot
+	plug       *interfaces.Plug
 }
 
 var _ = Suite(&AlsaInterfaceSuite{
@@ -52,9 +54,18 @@ slots:
   alsa:
 `
 
+const alsaGadgetYaml = `name: gadget
+type: gadget
+slots:
+  speakers:
+    interface: alsa
+    cards: [0, 2]
+`
+
 func (s *AlsaInterfaceSuite) SetUpTest(c *C) {
 	s.plug = MockPlug(c, alsaConsumerYaml, nil, "alsa")
 	s.slot = MockSlot(c, alsaCoreYaml, nil, "alsa")
+	s.gadgetSlot = MockSlot(c, alsaGadgetYaml, nil, "speakers")
 }
 
 func (s *AlsaInterfaceSuite) TestName(c *C) {
@@ -69,7 +80,18 @@ func (s *AlsaInterfaceSuite) TestSanitizeSlot(c *C) {
 		Interface: "alsa",
 	}}
 	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
-		"alsa slots are reserved for the core snap")
+		"alsa slots are reserved for the core and gadget snaps")
+	c.Assert(s.gadgetSlot.Sanitize(s.iface), IsNil)
+
+	for _, cards := range []interface{}{"0", []interface{}{"0"}, []interface{}{int64(32)}, []interface{}{int64(-1)}} {
+		slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "gadget", Type: snap.TypeGadget},
+			Name:      "speakers",
+			Interface: "alsa",
+			Attrs:     map[string]interface{}{"cards": cards},
+		}}
+		c.Check(slot.Sanitize(s.iface), ErrorMatches, `alsa slot attribute "cards" must be .*`, Commentf("%v", cards))
+	}
 }
 
 func (s *AlsaInterfaceSuite) TestSanitizePlug(c *C) {
@@ -83,6 +105,61 @@ func (s *AlsaInterfaceSuite) TestAppArmorSpec(c *C) {
 	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/snd/* rw,")
 }
 
+func (s *AlsaInterfaceSuite) TestAppArmorSpecCards(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.gadgetSlot, nil), IsNil)
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, Not(testutil.Contains), "/dev/snd/* rw,")
+	c.Check(snippet, testutil.Contains, "/dev/snd/pcmC{0,2}D[0-9]*[cp] rw,")
+	c.Check(snippet, testutil.Contains, "/dev/snd/controlC{0,2} rw,")
+	c.Check(snippet, testutil.Contains, "@{PROC}/asound/card{0,2}/** rw,")
+
+	slot := MockSlot(c, `name: gadget
+type: gadget
+slots:
+  speakers:
+    interface: alsa
+    cards: [1]
+`, nil, "speakers")
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, slot, nil), IsNil)
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/snd/controlC1 rw,")
+}
+
+func (s *AlsaInterfaceSuite) TestUDevSpecCards(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.gadgetSlot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 1)
+	snippet := spec.Snippets()[0]
+	c.Check(snippet, testutil.Contains, `KERNEL=="pcmC0D[0-9]*[cp]", TAG+="snap_consumer_app"`)
+	c.Check(snippet, testutil.Contains, `KERNEL=="pcmC2D[0-9]*[cp]", TAG+="snap_consumer_app"`)
+	c.Check(snippet, testutil.Contains, `KERNEL=="timer",                 TAG+="snap_consumer_app"`)
+	c.Check(snippet, Not(testutil.Contains), `pcmC[0-9]*`)
+}
+
+func (s *AlsaInterfaceSuite) TestMountSpec(c *C) {
+	// no restriction, no generated configuration
+	spec := &mount.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Check(spec.MountEntries(), HasLen, 0)
+	c.Check(spec.Files(), HasLen, 0)
+
+	spec = &mount.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.gadgetSlot, nil), IsNil)
+	c.Check(spec.MountEntries(), DeepEquals, []mount.Entry{{
+		Name:    mount.GeneratedFilePath("consumer", "asound.conf"),
+		Dir:     "/etc/asound.conf",
+		Options: []string{"bind", "ro"},
+	}})
+	c.Check(spec.Files(), DeepEquals, map[string][]byte{
+		"asound.conf": []byte(`# Generated by snapd for the connection to gadget:speakers, which only
+# gives access to cards {0,2}.
+defaults.pcm.card 0
+defaults.ctl.card 0
+`),
+	})
+}
+
 func (s *AlsaInterfaceSuite) TestUDevpec(c *C) {
 	spec := &udev.Specification{}
 	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
@@ -0,0 +1,210 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/dbus"
+	"github.com/snapcore/snapd/release"
+)
+
+const avahiPublishBaseDeclarationSlots = `
+  avahi-publish:
+    allow-installation:
+      slot-snap-type:
+        - app
+        - core
+    deny-auto-connection: true
+    deny-connection:
+      on-classic: false
+`
+
+const avahiPublishSummary = `allows publishing services on a local network via the mDNS/DNS-SD protocol suite`
+
+const avahiPublishConnectedSlotAppArmor = `
+# Description: allows the plugging snap to publish services via mDNS/DNS-SD
+# without browsing or resolving other hosts and services.
+#include <abstractions/dbus-strict>
+
+dbus (receive)
+    bus=system
+    path=/
+    interface=org.freedesktop.DBus.Peer
+    member=Ping
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/
+    interface=org.freedesktop.Avahi.Server
+    member={GetVersionString,GetAPIVersion,GetHostName,GetHostNameFqdn,GetDomainName,GetState,GetLocalServiceCookie,GetAlternativeServiceName,EntryGroupNew}
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    interface=org.freedesktop.Avahi.Server
+    member=StateChanged
+    peer=(name=org.freedesktop.Avahi, label=###PLUG_SECURITY_TAGS###),
+
+# EntryGroup, to publish services only
+dbus (receive)
+    bus=system
+    path=/Client*/EntryGroup*
+    interface=org.freedesktop.Avahi.EntryGroup
+    member={Free,Commit,Reset,GetState,IsEmpty,UpdateServiceTxt,AddService,AddServiceSubtype}
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    interface=org.freedesktop.Avahi.EntryGroup
+    member=StateChanged
+    peer=(name=org.freedesktop.Avahi, label=###PLUG_SECURITY_TAGS###),
+`
+
+const avahiPublishConnectedPlugAppArmor = `
+# Description: allows publishing services via mDNS/DNS-SD. Browsing and
+# resolving are not allowed, use avahi-observe for that.
+
+#include <abstractions/dbus-strict>
+dbus (send)
+    bus=system
+    path=/
+    interface=org.freedesktop.DBus.Peer
+    member=Ping
+    peer=(name=org.freedesktop.Avahi,label=###SLOT_SECURITY_TAGS###),
+
+# Allow the server queries needed to publish and to handle name collisions
+dbus (send)
+    bus=system
+    path=/
+    interface=org.freedesktop.Avahi.Server
+    member={GetVersionString,GetAPIVersion,GetHostName,GetHostNameFqdn,GetDomainName,GetState,GetLocalServiceCookie,GetAlternativeServiceName}
+    peer=(name=org.freedesktop.Avahi,label=###SLOT_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    interface=org.freedesktop.Avahi.Server
+    member=StateChanged
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+# EntryGroup
+dbus (send)
+    bus=system
+    path=/
+    interface=org.freedesktop.Avahi.Server
+    member=EntryGroupNew
+    peer=(name=org.freedesktop.Avahi, label=###SLOT_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/Client*/EntryGroup*
+    interface=org.freedesktop.Avahi.EntryGroup
+    member={Free,Commit,Reset}
+    peer=(name=org.freedesktop.Avahi, label=###SLOT_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/Client*/EntryGroup*
+    interface=org.freedesktop.Avahi.EntryGroup
+    member={GetState,IsEmpty,UpdateServiceTxt}
+    peer=(name=org.freedesktop.Avahi, label=###SLOT_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/Client*/EntryGroup*
+    interface=org.freedesktop.Avahi.EntryGroup
+    member=Add{Service,ServiceSubtype}
+    peer=(name=org.freedesktop.Avahi, label=###SLOT_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/Client*/EntryGroup*
+    interface=org.freedesktop.Avahi.EntryGroup
+    peer=(label=###SLOT_SECURITY_TAGS###),
+`
+
+type avahiPublishInterface struct{}
+
+func (iface *avahiPublishInterface) Name() string {
+	return "avahi-publish"
+}
+
+func (iface *avahiPublishInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              avahiPublishSummary,
+		ImplicitOnClassic:    true,
+		BaseDeclarationSlots: avahiPublishBaseDeclarationSlots,
+	}
+}
+
+func (iface *avahiPublishInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	old := "###SLOT_SECURITY_TAGS###"
+	var new string
+	if release.OnClassic {
+		// If we're running on classic Avahi will be part
+		// of the OS snap and will run unconfined.
+		new = "unconfined"
+	} else {
+		new = slotAppLabelExpr(slot)
+	}
+	snippet := strings.Replace(avahiPublishConnectedPlugAppArmor, old, new, -1)
+	spec.AddSnippet(snippet)
+	return nil
+}
+
+func (iface *avahiPublishInterface) AppArmorPermanentSlot(spec *apparmor.Specification, slot *interfaces.Slot) error {
+	if !release.OnClassic {
+		// NOTE: this is using avahi-observe permanent slot as it contains
+		// base declarations for running as the avahi service.
+		spec.AddSnippet(avahiObservePermanentSlotAppArmor)
+	}
+	return nil
+}
+
+func (iface *avahiPublishInterface) AppArmorConnectedSlot(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	if !release.OnClassic {
+		old := "###PLUG_SECURITY_TAGS###"
+		new := plugAppLabelExpr(plug)
+		snippet := strings.Replace(avahiPublishConnectedSlotAppArmor, old, new, -1)
+		spec.AddSnippet(snippet)
+	}
+	return nil
+}
+
+func (iface *avahiPublishInterface) DBusPermanentSlot(spec *dbus.Specification, slot *interfaces.Slot) error {
+	if !release.OnClassic {
+		// NOTE: this is using avahi-observe permanent slot as it contains
+		// base declarations for running as the avahi service.
+		spec.AddSnippet(avahiObservePermanentSlotDBus)
+	}
+	return nil
+}
+
+func (iface *avahiPublishInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&avahiPublishInterface{})
+}
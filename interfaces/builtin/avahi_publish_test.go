@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/dbus"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type AvahiPublishInterfaceSuite struct {
+	iface    interfaces.Interface
+	plug     *interfaces.Plug
+	appSlot  *interfaces.Slot
+	coreSlot *interfaces.Slot
+}
+
+var _ = Suite(&AvahiPublishInterfaceSuite{
+	iface: builtin.MustInterface("avahi-publish"),
+})
+
+const avahiPublishConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [avahi-publish]
+`
+
+const avahiPublishProducerYaml = `name: producer
+apps:
+ app:
+  slots: [avahi-publish]
+`
+
+const avahiPublishCoreYaml = `name: core
+slots:
+  avahi-publish:
+`
+
+func (s *AvahiPublishInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, avahiPublishConsumerYaml, nil, "avahi-publish")
+	s.appSlot = MockSlot(c, avahiPublishProducerYaml, nil, "avahi-publish")
+	s.coreSlot = MockSlot(c, avahiPublishCoreYaml, nil, "avahi-publish")
+}
+
+func (s *AvahiPublishInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "avahi-publish")
+}
+
+func (s *AvahiPublishInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.coreSlot.Sanitize(s.iface), IsNil)
+	c.Assert(s.appSlot.Sanitize(s.iface), IsNil)
+}
+
+func (s *AvahiPublishInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *AvahiPublishInterfaceSuite) TestAppArmorSpec(c *C) {
+	// on a core system with avahi slot coming from a regular app snap.
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	// connected plug to app slot
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.appSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, `peer=(label="snap.producer.app"),`)
+	c.Check(snippet, testutil.Contains, `member=EntryGroupNew`)
+	c.Check(snippet, testutil.Contains, `interface=org.freedesktop.Avahi.EntryGroup`)
+	// publishing does not imply observing nor controlling the server
+	c.Check(snippet, Not(testutil.Contains), `Resolve`)
+	c.Check(snippet, Not(testutil.Contains), `Browser`)
+	c.Check(snippet, Not(testutil.Contains), `member=Set*`)
+	c.Check(snippet, Not(testutil.Contains), `member=Get*`)
+
+	// connected app slot to plug
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, nil, s.appSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.producer.app"})
+	snippet = spec.SnippetForTag("snap.producer.app")
+	c.Check(snippet, testutil.Contains, `peer=(label="snap.consumer.app"),`)
+	c.Check(snippet, testutil.Contains, `interface=org.freedesktop.Avahi.EntryGroup`)
+	c.Check(snippet, testutil.Contains, `member={Free,Commit,Reset,GetState,IsEmpty,UpdateServiceTxt,AddService,AddServiceSubtype}`)
+	c.Check(snippet, Not(testutil.Contains), `Resolver`)
+	c.Check(snippet, Not(testutil.Contains), `Browser`)
+	// only what the plug is allowed to call
+	c.Check(snippet, Not(testutil.Contains), `Set*`)
+	c.Check(snippet, Not(testutil.Contains), `AddAddress`)
+	c.Check(snippet, Not(testutil.Contains), `AddRecord`)
+
+	// permanent app slot
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.appSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.producer.app"})
+	c.Assert(spec.SnippetForTag("snap.producer.app"), testutil.Contains, `dbus (bind)
+    bus=system
+    name="org.freedesktop.Avahi",`)
+
+	// on a classic system with avahi slot coming from the core snap.
+	restore = release.MockOnClassic(true)
+	defer restore()
+
+	// connected plug to core slot
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "peer=(label=unconfined),")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `member=EntryGroupNew`)
+
+	// connected core slot to plug
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), HasLen, 0)
+
+	// permanent core slot
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlot), IsNil)
+	c.Assert(spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *AvahiPublishInterfaceSuite) TestDBusSpec(c *C) {
+	// on a core system with avahi slot coming from a regular app snap.
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	spec := &dbus.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.appSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.producer.app"})
+	c.Assert(spec.SnippetForTag("snap.producer.app"), testutil.Contains, `<allow own="org.freedesktop.Avahi"/>`)
+
+	// on a classic system with avahi slot coming from the core snap.
+	restore = release.MockOnClassic(true)
+	defer restore()
+
+	spec = &dbus.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlot), IsNil)
+	c.Assert(spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *AvahiPublishInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows publishing services on a local network via the mDNS/DNS-SD protocol suite`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "avahi-publish")
+}
+
+func (s *AvahiPublishInterfaceSuite) TestAutoConnect(c *C) {
+	c.Assert(s.iface.AutoConnect(s.plug, s.coreSlot), Equals, true)
+	c.Assert(s.iface.AutoConnect(s.plug, s.appSlot), Equals, true)
+}
+
+func (s *AvahiPublishInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,219 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/release"
+)
+
+const bluetoothLESummary = `allows using Bluetooth Low Energy devices through bluez`
+
+const bluetoothLEBaseDeclarationSlots = `
+  bluetooth-le:
+    allow-installation:
+      slot-snap-type:
+        - app
+        - core
+    deny-auto-connection: true
+    deny-connection:
+      -
+        on-classic: false
+      -
+        plug-attributes:
+          raw-hci: true
+`
+
+const bluetoothLEConnectedPlugAppArmor = `
+# Description: Allow using Bluetooth Low Energy devices: scanning,
+# advertising and GATT, both as a client and as a server, through bluez.
+# Classic Bluetooth, pairing and agents are not allowed.
+
+# L2CAP sockets, for ATT. Raw HCI sockets would also reach classic
+# Bluetooth and are only allowed with the raw-hci plug attribute. No
+# net_admin, so controller management stays with bluez.
+network bluetooth seqpacket,
+
+/sys/class/bluetooth/           r,
+/sys/devices/**/bluetooth/      r,
+/sys/devices/**/bluetooth/**    r,
+
+#include <abstractions/dbus-strict>
+
+# Discover the adapters, devices and GATT attributes
+dbus (send)
+    bus=system
+    path=/
+    interface=org.freedesktop.DBus.ObjectManager
+    member=GetManagedObjects
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (receive)
+    bus=system
+    path=/
+    interface=org.freedesktop.DBus.ObjectManager
+    member=Interfaces{Added,Removed}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (send)
+    bus=system
+    path=/org/bluez{,/**}
+    interface=org.freedesktop.DBus.Properties
+    member=Get{,All}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (receive)
+    bus=system
+    path=/org/bluez{,/**}
+    interface=org.freedesktop.DBus.Properties
+    member=PropertiesChanged
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+# Scan for LE devices and connect to them, but not pair with them
+dbus (send)
+    bus=system
+    path=/org/bluez/hci[0-9]*
+    interface=org.bluez.Adapter1
+    member={StartDiscovery,StopDiscovery,SetDiscoveryFilter,GetDiscoveryFilters}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (send)
+    bus=system
+    path=/org/bluez/hci[0-9]*/dev_*
+    interface=org.bluez.Device1
+    member={Connect,Disconnect}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+# GATT client
+dbus (send)
+    bus=system
+    path=/org/bluez/hci[0-9]*/dev_*/**
+    interface=org.bluez.Gatt{Characteristic,Descriptor}1
+    member={ReadValue,WriteValue,StartNotify,StopNotify,AcquireWrite,AcquireNotify}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+# GATT server and advertising
+dbus (send)
+    bus=system
+    path=/org/bluez/hci[0-9]*
+    interface=org.bluez.GattManager1
+    member={RegisterApplication,UnregisterApplication}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (send)
+    bus=system
+    path=/org/bluez/hci[0-9]*
+    interface=org.bluez.LEAdvertisingManager1
+    member={RegisterAdvertisement,UnregisterAdvertisement}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+# Let bluez call into the GATT applications and advertisements exported
+# by the snap
+dbus (receive)
+    bus=system
+    interface=org.bluez.{GattService1,GattCharacteristic1,GattDescriptor1,LEAdvertisement1}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (receive)
+    bus=system
+    interface=org.freedesktop.DBus.{ObjectManager,Properties}
+    peer=(label=###SLOT_SECURITY_TAGS###),
+dbus (send)
+    bus=system
+    interface=org.freedesktop.DBus.Properties
+    member=PropertiesChanged
+    peer=(label=###SLOT_SECURITY_TAGS###),
+`
+
+const bluetoothLEConnectedPlugAppArmorRawHCI = `
+# Unprivileged HCI sockets, for scanning and advertising without going
+# through bluez
+network bluetooth raw,
+`
+
+const bluetoothLEConnectedSlotAppArmor = `
+# Allow connected clients to use Bluetooth Low Energy through the service,
+# the clients are restricted on their side.
+dbus (receive, send)
+    bus=system
+    peer=(label=###PLUG_SECURITY_TAGS###),
+`
+
+const bluetoothLEConnectedPlugSecComp = `
+# Description: Allow using Bluetooth Low Energy devices.
+bind
+`
+
+type bluetoothLEInterface struct{}
+
+func (iface *bluetoothLEInterface) Name() string {
+	return "bluetooth-le"
+}
+
+func (iface *bluetoothLEInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              bluetoothLESummary,
+		ImplicitOnClassic:    true,
+		BaseDeclarationSlots: bluetoothLEBaseDeclarationSlots,
+		PlugAttrs: interfaces.AttrSchema{{
+			Name:        "raw-hci",
+			Type:        interfaces.AttrBool,
+			Description: "allow raw HCI sockets, which also reach classic Bluetooth",
+		}},
+	}
+}
+
+func (iface *bluetoothLEInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	old := "###SLOT_SECURITY_TAGS###"
+	var new string
+	if release.OnClassic {
+		new = "unconfined"
+	} else {
+		new = slotAppLabelExpr(slot)
+	}
+	snippet := strings.Replace(bluetoothLEConnectedPlugAppArmor, old, new, -1)
+	spec.AddSnippet(snippet)
+	if rawHCI, _ := plug.Attrs["raw-hci"].(bool); rawHCI {
+		spec.AddSnippet(bluetoothLEConnectedPlugAppArmorRawHCI)
+	}
+	return nil
+}
+
+func (iface *bluetoothLEInterface) AppArmorConnectedSlot(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	if !release.OnClassic {
+		old := "###PLUG_SECURITY_TAGS###"
+		new := plugAppLabelExpr(plug)
+		snippet := strings.Replace(bluetoothLEConnectedSlotAppArmor, old, new, -1)
+		spec.AddSnippet(snippet)
+	}
+	return nil
+}
+
+func (iface *bluetoothLEInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	spec.AddSnippet(bluetoothLEConnectedPlugSecComp)
+	return nil
+}
+
+func (iface *bluetoothLEInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&bluetoothLEInterface{})
+}
@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type BluetoothLEInterfaceSuite struct {
+	iface    interfaces.Interface
+	appSlot  *interfaces.Slot
+	coreSlot *interfaces.Slot
+	plug     *interfaces.Plug
+}
+
+var _ = Suite(&BluetoothLEInterfaceSuite{
+	iface: builtin.MustInterface("bluetooth-le"),
+})
+
+const bluetoothLEConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [bluetooth-le]
+`
+
+const bluetoothLEProducerYaml = `name: producer
+apps:
+ app:
+  slots: [bluetooth-le]
+`
+
+const bluetoothLECoreYaml = `name: core
+slots:
+  bluetooth-le:
+`
+
+func (s *BluetoothLEInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, bluetoothLEConsumerYaml, nil, "bluetooth-le")
+	s.appSlot = MockSlot(c, bluetoothLEProducerYaml, nil, "bluetooth-le")
+	s.coreSlot = MockSlot(c, bluetoothLECoreYaml, nil, "bluetooth-le")
+}
+
+func (s *BluetoothLEInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "bluetooth-le")
+}
+
+func (s *BluetoothLEInterfaceSuite) TestAppArmorSpec(c *C) {
+	// on a core system with the slot coming from the bluez snap
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.appSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, `peer=(label="snap.producer.app"),`)
+	c.Check(snippet, testutil.Contains, "network bluetooth seqpacket,\n")
+	c.Check(snippet, Not(testutil.Contains), "network bluetooth raw,\n")
+	c.Check(snippet, testutil.Contains, "interface=org.bluez.LEAdvertisingManager1\n")
+	c.Check(snippet, testutil.Contains, "member={Connect,Disconnect}\n")
+	// no classic pairing, agents or controller management
+	c.Check(snippet, Not(testutil.Contains), "Pair")
+	c.Check(snippet, Not(testutil.Contains), "Agent")
+	c.Check(snippet, Not(testutil.Contains), "capability net_admin")
+
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, nil, s.appSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.producer.app"})
+	c.Check(spec.SnippetForTag("snap.producer.app"), testutil.Contains, `peer=(label="snap.consumer.app"),`)
+
+	// on a classic system with the slot coming from the core snap
+	restore = release.MockOnClassic(true)
+	defer restore()
+
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "peer=(label=unconfined),")
+
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *BluetoothLEInterfaceSuite) TestAppArmorSpecRawHCI(c *C) {
+	plug := MockPlug(c, `name: consumer
+plugs:
+ bluetooth-le:
+  raw-hci: true
+apps:
+ app:
+  plugs: [bluetooth-le]
+`, nil, "bluetooth-le")
+	c.Assert(plug.Sanitize(s.iface), IsNil)
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, nil, s.coreSlot, nil), IsNil)
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "network bluetooth raw,\n")
+}
+
+func (s *BluetoothLEInterfaceSuite) TestSanitizePlugRawHCI(c *C) {
+	plug := MockPlug(c, `name: consumer
+plugs:
+ bluetooth-le:
+  raw-hci: yes-please
+apps:
+ app:
+  plugs: [bluetooth-le]
+`, nil, "bluetooth-le")
+	c.Check(plug.Sanitize(s.iface), ErrorMatches, `.*raw-hci.*`)
+}
+
+func (s *BluetoothLEInterfaceSuite) TestSecCompSpec(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "bind\n")
+}
+
+func (s *BluetoothLEInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows using Bluetooth Low Energy devices through bluez`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "bluetooth-le")
+}
+
+func (s *BluetoothLEInterfaceSuite) TestAutoConnect(c *C) {
+	c.Assert(s.iface.AutoConnect(s.plug, s.coreSlot), Equals, true)
+	c.Assert(s.iface.AutoConnect(s.plug, s.appSlot), Equals, true)
+}
+
+func (s *BluetoothLEInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -56,6 +56,15 @@ func (iface *boolFileInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              boolFileSummary,
 		BaseDeclarationSlots: boolFileBaseDeclarationSlots,
+		SlotAttrs: interfaces.AttrSchema{{
+			Name:                "path",
+			Type:                interfaces.AttrString,
+			Required:            true,
+			Description:         "file with bool semantics the plugs can write to",
+			Path:                true,
+			Patterns:            boolFileAllowedPathPatterns,
+			PatternsDescription: "the path of a LED brightness or GPIO value",
+		}},
 	}
 }
 
@@ -68,22 +77,6 @@ var boolFileAllowedPathPatterns = []*regexp.Regexp{
 	boolFileGPIOValuePattern,
 }
 
-// SanitizeSlot checks and possibly modifies a slot.
-// Valid "bool-file" slots must contain the attribute "path".
-func (iface *boolFileInterface) SanitizeSlot(slot *interfaces.Slot) error {
-	path, ok := slot.Attrs["path"].(string)
-	if !ok || path == "" {
-		return fmt.Errorf("bool-file must contain the path attribute")
-	}
-	path = filepath.Clean(path)
-	for _, pattern := range boolFileAllowedPathPatterns {
-		if pattern.MatchString(path) {
-			return nil
-		}
-	}
-	return fmt.Errorf("bool-file can only point at LED brightness or GPIO value")
-}
-
 func (iface *boolFileInterface) AppArmorPermanentSlot(spec *apparmor.Specification, slot *interfaces.Slot) error {
 	gpioSnippet := `
 /sys/class/gpio/export rw,
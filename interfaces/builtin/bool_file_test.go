@@ -109,13 +109,13 @@ func (s *BoolFileInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(s.gpioSlot.Sanitize(s.iface), IsNil)
 	// Slots without the "path" attribute are rejected.
 	c.Assert(s.missingPathSlot.Sanitize(s.iface), ErrorMatches,
-		"bool-file must contain the path attribute")
-	// Slots without the "path" attribute are rejected.
+		"bool-file slot must contain the path attribute")
+	// Slots with paths escaping the allowed directories are rejected.
 	c.Assert(s.parentDirPathSlot.Sanitize(s.iface), ErrorMatches,
-		"bool-file can only point at LED brightness or GPIO value")
+		`bool-file slot attribute "path" must be the path of a LED brightness or GPIO value \(got "/sys/class/value"\)`)
 	// Slots with incorrect value of the "path" attribute are rejected.
 	c.Assert(s.badPathSlot.Sanitize(s.iface), ErrorMatches,
-		"bool-file can only point at LED brightness or GPIO value")
+		`bool-file slot attribute "path" must be an absolute path \(got "path"\)`)
 }
 
 func (s *BoolFileInterfaceSuite) TestSanitizePlug(c *C) {
@@ -20,8 +20,6 @@
 package builtin
 
 import (
-	"fmt"
-
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/seccomp"
@@ -261,21 +259,14 @@ func (iface *browserSupportInterface) StaticInfo() interfaces.StaticInfo {
 		ImplicitOnCore:       true,
 		ImplicitOnClassic:    true,
 		BaseDeclarationSlots: browserSupportBaseDeclarationSlots,
+		PlugAttrs: interfaces.AttrSchema{{
+			Name:        "allow-sandbox",
+			Type:        interfaces.AttrBool,
+			Description: "allow the browser to set up its own sandbox",
+		}},
 	}
 }
 
-func (iface *browserSupportInterface) SanitizePlug(plug *interfaces.Plug) error {
-	// It's fine if allow-sandbox isn't specified, but it it is,
-	// it needs to be bool
-	if v, ok := plug.Attrs["allow-sandbox"]; ok {
-		if _, ok = v.(bool); !ok {
-			return fmt.Errorf("browser-support plug requires bool with 'allow-sandbox'")
-		}
-	}
-
-	return nil
-}
-
 func (iface *browserSupportInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
 	allowSandbox, _ := plug.Attrs["allow-sandbox"].(bool)
 	spec.AddSnippet(browserSupportConnectedPlugAppArmor)
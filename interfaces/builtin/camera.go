@@ -19,6 +19,14 @@
 
 package builtin
 
+import (
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
 const cameraSummary = `allows access to all cameras`
 
 const cameraBaseDeclarationSlots = `
@@ -30,8 +38,9 @@ const cameraBaseDeclarationSlots = `
 `
 
 const cameraConnectedPlugAppArmor = `
-# Until we have proper device assignment, allow access to all cameras
-/dev/video[0-9]* rw,
+# Until we have proper device assignment, allow access to all cameras, asking
+# about the accesses when prompting is enabled
+###PROMPT###/dev/video[0-9]* rw,
 
 # Allow detection of cameras. Leaks plugged in USB device info
 /sys/bus/usb/devices/ r,
@@ -44,8 +53,60 @@ const cameraConnectedPlugAppArmor = `
 
 const cameraConnectedPlugUDev = `KERNEL=="video[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"`
 
+// cameraInterface gives access to cameras, either to all of them through
+// the implicit slot of the core snap or to a single USB camera through the
+// slot created when it is plugged in.
+type cameraInterface struct {
+	commonInterface
+}
+
+// HotplugDeviceDetected creates a slot for USB video capture devices as
+// they are plugged in.
+func (iface *cameraInterface) HotplugDeviceDetected(devinfo *interfaces.HotplugDeviceInfo) (*interfaces.HotplugSlotSpec, error) {
+	bus, _ := devinfo.Property("ID_BUS")
+	caps, _ := devinfo.Property("ID_V4L_CAPABILITIES")
+	if devinfo.Subsystem() != "video4linux" || bus != "usb" || !strings.Contains(caps, ":capture:") {
+		return nil, nil
+	}
+	vendorID, _ := devinfo.Property("ID_VENDOR_ID")
+	productID, _ := devinfo.Property("ID_MODEL_ID")
+	usbVendor, err := strconv.ParseInt(vendorID, 16, 64)
+	if err != nil {
+		return nil, nil
+	}
+	usbProduct, err := strconv.ParseInt(productID, 16, 64)
+	if err != nil {
+		return nil, nil
+	}
+	product, _ := devinfo.Property("ID_V4L_PRODUCT")
+	return &interfaces.HotplugSlotSpec{
+		Name:  interfaces.SuggestHotplugSlotName(product, iface.Name()),
+		Label: product,
+		Attrs: map[string]interface{}{
+			"path":        devinfo.DeviceName(),
+			"usb-vendor":  usbVendor,
+			"usb-product": usbProduct,
+		},
+	}, nil
+}
+
+// UDevConnectedPlug tags only the camera of the slot for hotplugged
+// cameras, and all of them otherwise.
+func (iface *cameraInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	usbVendor, vOk := slot.Attrs["usb-vendor"].(int64)
+	usbProduct, pOk := slot.Attrs["usb-product"].(int64)
+	if !vOk || !pOk {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, plugAttrs, slot, slotAttrs)
+	}
+	for appName := range plug.Apps {
+		tag := udevSnapSecurityName(plug.Snap.Name(), appName)
+		spec.AddSnippet(udevUsbDeviceSnippet("video4linux", usbVendor, usbProduct, "TAG", tag))
+	}
+	return nil
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&cameraInterface{commonInterface{
 		name:                  "camera",
 		summary:               cameraSummary,
 		implicitOnCore:        true,
@@ -54,5 +115,5 @@ func init() {
 		connectedPlugAppArmor: cameraConnectedPlugAppArmor,
 		connectedPlugUDev:     cameraConnectedPlugUDev,
 		reservedForOS:         true,
-	})
+	}})
 }
@@ -90,6 +90,60 @@ func (s *CameraInterfaceSuite) TestUDevSpec(c *C) {
 	c.Assert(spec.Snippets()[0], testutil.Contains, `KERNEL=="video[0-9]*", TAG+="snap_consumer_app"`)
 }
 
+func (s *CameraInterfaceSuite) TestUDevSpecHotplugged(c *C) {
+	slot := MockSlot(c, `name: core
+type: os
+slots:
+  hd-pro-webcam-c920:
+    interface: camera
+    path: /dev/video0
+    usb-vendor: 0x046d
+    usb-product: 0x082d
+`, nil, "hd-pro-webcam-c920")
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 1)
+	c.Assert(spec.Snippets()[0], Equals, `IMPORT{builtin}="usb_id"
+SUBSYSTEM=="video4linux", SUBSYSTEMS=="usb", ATTRS{idVendor}=="046d", ATTRS{idProduct}=="082d", TAG+="snap_consumer_app"`)
+}
+
+func (s *CameraInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	handler, ok := s.iface.(interfaces.HotplugDeviceHandler)
+	c.Assert(ok, Equals, true)
+
+	props := map[string]string{
+		"DEVPATH":             "/devices/pci0000:00/0000:00:14.0/usb1/1-3/1-3:1.0/video4linux/video0",
+		"DEVNAME":             "/dev/video0",
+		"SUBSYSTEM":           "video4linux",
+		"ID_BUS":              "usb",
+		"ID_VENDOR_ID":        "046d",
+		"ID_MODEL_ID":         "082d",
+		"ID_V4L_PRODUCT":      "HD Pro Webcam C920",
+		"ID_V4L_CAPABILITIES": ":capture:",
+	}
+	devinfo, err := interfaces.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	spec, err := handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec, DeepEquals, &interfaces.HotplugSlotSpec{
+		Name:  "hd-pro-webcam-c920",
+		Label: "HD Pro Webcam C920",
+		Attrs: map[string]interface{}{
+			"path":        "/dev/video0",
+			"usb-vendor":  int64(0x046d),
+			"usb-product": int64(0x082d),
+		},
+	})
+
+	// metadata nodes of the same camera are left alone
+	props["ID_V4L_CAPABILITIES"] = ":"
+	devinfo, err = interfaces.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	spec, err = handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec, IsNil)
+}
+
 func (s *CameraInterfaceSuite) TestStaticInfo(c *C) {
 	si := interfaces.StaticInfoOf(s.iface)
 	c.Assert(si.ImplicitOnCore, Equals, true)
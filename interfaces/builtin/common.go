@@ -41,12 +41,18 @@ type commonInterface struct {
 	summary string
 	docURL  string
 
+	deprecated bool
+	replacedBy string
+
 	implicitOnCore    bool
 	implicitOnClassic bool
 
 	baseDeclarationPlugs string
 	baseDeclarationSlots string
 
+	plugAttrs interfaces.AttrSchema
+	slotAttrs interfaces.AttrSchema
+
 	connectedPlugAppArmor  string
 	connectedPlugSecComp   string
 	connectedPlugUDev      string
@@ -73,6 +79,10 @@ func (iface *commonInterface) StaticInfo() interfaces.StaticInfo {
 		ImplicitOnClassic:    iface.implicitOnClassic,
 		BaseDeclarationPlugs: iface.baseDeclarationPlugs,
 		BaseDeclarationSlots: iface.baseDeclarationSlots,
+		PlugAttrs:            iface.plugAttrs,
+		SlotAttrs:            iface.slotAttrs,
+		Deprecated:           iface.deprecated,
+		ReplacedBy:           iface.replacedBy,
 	}
 }
 
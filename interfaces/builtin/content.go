@@ -61,6 +61,32 @@ func (iface *contentInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              contentSummary,
 		BaseDeclarationSlots: contentBaseDeclarationSlots,
+		PlugAttrs: interfaces.AttrSchema{{
+			Name:        "content",
+			Type:        interfaces.AttrString,
+			Description: "kind of content consumed, matched against that of slots (defaults to the plug name)",
+		}, {
+			Name:        "target",
+			Type:        interfaces.AttrString,
+			Description: "where the content is mounted, relative to the snap unless starting with $SNAP, $SNAP_DATA or $SNAP_COMMON",
+		}, {
+			Name:        "default-provider",
+			Type:        interfaces.AttrString,
+			Description: "snap to install to provide the content when no other snap does",
+		}},
+		SlotAttrs: interfaces.AttrSchema{{
+			Name:        "content",
+			Type:        interfaces.AttrString,
+			Description: "kind of content provided, matched against that of plugs (defaults to the slot name)",
+		}, {
+			Name:        "read",
+			Type:        interfaces.AttrStringList,
+			Description: "directories shared read-only",
+		}, {
+			Name:        "write",
+			Type:        interfaces.AttrStringList,
+			Description: "directories shared read-write",
+		}},
 	}
 }
 
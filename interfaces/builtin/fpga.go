@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
+const fpgaSummary = `allows managing and reconfiguring FPGA devices`
+
+const fpgaBaseDeclarationSlots = `
+  fpga:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-auto-connection: true
+`
+
+const fpgaConnectedPlugAppArmor = `
+# Description: Can manage FPGA devices, through the device nodes of the
+# Xilinx and Intel (DFL, OPAE) drivers, whose ioctls are allowed by the rw
+# rule below.
+
+/sys/class/fpga_manager/ r,
+/sys/class/fpga_bridge/ r,
+/sys/class/fpga_region/ r,
+/sys/devices/**/fpga_bridge/br[0-9]*/** r,
+/sys/devices/**/fpga_region/region[0-9]*/** r,
+
+###FPGA_DEVICES### rw,
+`
+
+const fpgaManagerConnectedPlugAppArmor = `
+# Reconfigure the FPGA through the fpga manager of the kernel, with a
+# bitstream it loads from the firmware directory.
+/sys/devices/**/fpga_manager/###FPGA_MANAGER###/** r,
+/sys/devices/**/fpga_manager/###FPGA_MANAGER###/{firmware,flags} w,
+/sys/devices/**/fpga_bridge/br[0-9]*/set w,
+`
+
+// Device nodes of the FPGA drivers: the fpga manager, the management
+// engines and ports of the Intel DFL and OPAE drivers, and the device
+// configuration interface of Xilinx Zynq.
+const fpgaDevicesGlob = `/dev/{fpga[0-9]*,dfl-{fme,port}.[0-9]*,intel-fpga-{fme,port}.[0-9]*,xdevcfg}`
+
+var fpgaConnectedPlugUDevKernels = []string{
+	"fpga[0-9]*",
+	"dfl-fme.[0-9]*",
+	"dfl-port.[0-9]*",
+	"intel-fpga-fme.[0-9]*",
+	"intel-fpga-port.[0-9]*",
+	"xdevcfg",
+}
+
+var fpgaDevicePattern = regexp.MustCompile(`^/dev/(fpga[0-9]+|(dfl|intel-fpga)-(fme|port)\.[0-9]+|xdevcfg)$`)
+
+// fpgaInterface gives access to all the FPGA devices, or to the one
+// named by the path attribute of the slot.
+type fpgaInterface struct {
+	commonInterface
+}
+
+// SanitizeSlot checks that the slot is provided by the core or gadget
+// snap, its path being checked against the schema.
+func (iface *fpgaInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	return sanitizeSlotReservedForOSOrGadget(iface, slot)
+}
+
+func (iface *fpgaInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	devices, manager := fpgaDevicesGlob, "fpga[0-9]*"
+	if path, ok := slot.Attrs["path"].(string); ok {
+		// the sysfs reconfiguration nodes are those of the fpga
+		// manager, there are none for the nodes of the other drivers
+		devices = filepath.Clean(path)
+		manager = ""
+		if name := filepath.Base(devices); strings.HasPrefix(name, "fpga") {
+			manager = name
+		}
+	}
+	spec.AddSnippet(strings.Replace(fpgaConnectedPlugAppArmor, "###FPGA_DEVICES###", devices, -1))
+	if manager != "" {
+		spec.AddSnippet(strings.Replace(fpgaManagerConnectedPlugAppArmor, "###FPGA_MANAGER###", manager, -1))
+	}
+	return nil
+}
+
+func (iface *fpgaInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	kernels := fpgaConnectedPlugUDevKernels
+	if path, ok := slot.Attrs["path"].(string); ok {
+		kernels = []string{filepath.Base(filepath.Clean(path))}
+	}
+	for appName := range plug.Apps {
+		tag := udevSnapSecurityName(plug.Snap.Name(), appName)
+		for _, kernel := range kernels {
+			spec.AddSnippet(fmt.Sprintf(`KERNEL=="%s", TAG+="%s"`, kernel, tag))
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerIface(&fpgaInterface{commonInterface{
+		name:                 "fpga",
+		summary:              fpgaSummary,
+		implicitOnCore:       true,
+		implicitOnClassic:    true,
+		baseDeclarationSlots: fpgaBaseDeclarationSlots,
+		slotAttrs: interfaces.AttrSchema{{
+			Name:                "path",
+			Type:                interfaces.AttrString,
+			Description:         "device node of the FPGA the slot is restricted to",
+			Path:                true,
+			Patterns:            []*regexp.Regexp{fpgaDevicePattern},
+			PatternsDescription: "an FPGA device node such as /dev/fpga0 or /dev/dfl-port.0",
+		}},
+	}})
+}
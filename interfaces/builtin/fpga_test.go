@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type FpgaInterfaceSuite struct {
+	iface       interfaces.Interface
+	coreSlot    *interfaces.Slot
+	managerSlot *interfaces.Slot
+	portSlot    *interfaces.Slot
+	plug        *interfaces.Plug
+}
+
+var _ = Suite(&FpgaInterfaceSuite{
+	iface: builtin.MustInterface("fpga"),
+})
+
+func (s *FpgaInterfaceSuite) SetUpTest(c *C) {
+	s.coreSlot = &interfaces.Slot{
+		SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+			Name:      "fpga",
+			Interface: "fpga",
+		},
+	}
+	gadgetSnap := snaptest.MockInfo(c, `
+name: gadget
+type: gadget
+slots:
+  fabric:
+    interface: fpga
+    path: /dev/fpga0
+  accelerator:
+    interface: fpga
+    path: /dev/dfl-port.1
+`, nil)
+	s.managerSlot = &interfaces.Slot{SlotInfo: gadgetSnap.Slots["fabric"]}
+	s.portSlot = &interfaces.Slot{SlotInfo: gadgetSnap.Slots["accelerator"]}
+	plugSnap := snaptest.MockInfo(c, `
+name: fpga-manager
+apps:
+  app:
+    command: foo
+    plugs: [fpga]
+`, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["fpga"]}
+}
+
+func (s *FpgaInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "fpga")
+}
+
+func (s *FpgaInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.coreSlot.Sanitize(s.iface), IsNil)
+	c.Assert(s.managerSlot.Sanitize(s.iface), IsNil)
+	c.Assert(s.portSlot.Sanitize(s.iface), IsNil)
+
+	info := snaptest.MockInfo(c, `
+name: gadget
+type: gadget
+slots:
+  fabric:
+    interface: fpga
+    path: /dev/sda
+`, nil)
+	slot := &interfaces.Slot{SlotInfo: info.Slots["fabric"]}
+	c.Check(slot.Sanitize(s.iface), ErrorMatches, `fpga slot attribute "path" must be an FPGA device node such as /dev/fpga0 or /dev/dfl-port.0 \(got "/dev/sda"\)`)
+
+	slot = &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "fpga",
+		Interface: "fpga",
+	}}
+	c.Check(slot.Sanitize(s.iface), ErrorMatches, "fpga slots are reserved for the core and gadget snaps")
+}
+
+func (s *FpgaInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *FpgaInterfaceSuite) TestAppArmorSpecAllDevices(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.fpga-manager.app"})
+	snippet := spec.SnippetForTag("snap.fpga-manager.app")
+	c.Check(snippet, testutil.Contains, "/dev/{fpga[0-9]*,dfl-{fme,port}.[0-9]*,intel-fpga-{fme,port}.[0-9]*,xdevcfg} rw,\n")
+	c.Check(snippet, testutil.Contains, "/sys/devices/**/fpga_manager/fpga[0-9]*/{firmware,flags} w,\n")
+}
+
+func (s *FpgaInterfaceSuite) TestAppArmorSpecManager(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.managerSlot, nil), IsNil)
+	snippet := spec.SnippetForTag("snap.fpga-manager.app")
+	c.Check(snippet, testutil.Contains, "/dev/fpga0 rw,\n")
+	c.Check(snippet, testutil.Contains, "/sys/devices/**/fpga_manager/fpga0/{firmware,flags} w,\n")
+	c.Check(snippet, Not(testutil.Contains), "dfl-")
+}
+
+func (s *FpgaInterfaceSuite) TestAppArmorSpecPort(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.portSlot, nil), IsNil)
+	snippet := spec.SnippetForTag("snap.fpga-manager.app")
+	c.Check(snippet, testutil.Contains, "/dev/dfl-port.1 rw,\n")
+	c.Check(snippet, Not(testutil.Contains), "/{firmware,flags} w,")
+}
+
+func (s *FpgaInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.coreSlot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 6)
+	c.Check(spec.Snippets(), testutil.Contains, `KERNEL=="dfl-fme.[0-9]*", TAG+="snap_fpga-manager_app"`)
+
+	spec = &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.portSlot, nil), IsNil)
+	c.Check(spec.Snippets(), DeepEquals, []string{`KERNEL=="dfl-port.1", TAG+="snap_fpga-manager_app"`})
+}
+
+func (s *FpgaInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, true)
+	c.Check(si.ImplicitOnClassic, Equals, true)
+	c.Check(si.Summary, Equals, "allows managing and reconfiguring FPGA devices")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "fpga")
+}
+
+func (s *FpgaInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -58,6 +58,11 @@ func (iface *gpioInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              gpioSummary,
 		BaseDeclarationSlots: gpioBaseDeclarationSlots,
+		SlotAttrs: interfaces.AttrSchema{{
+			Name:        "number",
+			Type:        interfaces.AttrInt,
+			Description: "number of the gpio line, as exported in /sys/class/gpio",
+		}},
 	}
 }
 
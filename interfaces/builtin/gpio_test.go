@@ -106,7 +106,7 @@ func (s *GpioInterfaceSuite) TestSanitizeSlotGadgetSnap(c *C) {
 
 	// slots with number attribute that isnt a number
 	c.Assert(s.gadgetBadNumberSlot.Sanitize(s.iface), ErrorMatches,
-		"gpio slot number attribute must be an int")
+		`gpio slot attribute "number" must be an int`)
 }
 
 func (s *GpioInterfaceSuite) TestSanitizeSlotOsSnap(c *C) {
@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const gpuComputeSummary = `allows access to GPU compute devices and drivers`
+
+const gpuComputeBaseDeclarationSlots = `
+  gpu-compute:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const gpuComputeConnectedPlugAppArmor = `
+# Description: Can run compute workloads on the GPUs with the CUDA, ROCm and
+# oneAPI (Level Zero, OpenCL) stacks, without the display access of the
+# opengl interface. The ioctls of the drivers are allowed by the default
+# seccomp policy once the device nodes can be opened.
+
+# NVIDIA CUDA, its libraries being exposed by snap-confine
+/dev/nvidia[0-9]* rw,
+/dev/nvidiactl rw,
+/dev/nvidia-uvm rw,
+/dev/nvidia-uvm-tools rw,
+/dev/nvidia-caps/ r,
+/dev/nvidia-caps/nvidia-cap[0-9]* r,
+@{PROC}/driver/nvidia/params r,
+@{PROC}/driver/nvidia/capabilities/** r,
+@{PROC}/driver/nvidia/gpus/** r,
+@{PROC}/modules r,
+unix (send, receive) type=dgram peer=(addr="@nvidia[0-9a-f]*"),
+/var/lib/snapd/lib/gl/ r,
+/var/lib/snapd/lib/gl/** rm,
+/var/lib/snapd/hostfs/{,usr/}lib{,32,64,x32}/{,@{multiarch}/}libcuda*.so{,.*} rm,
+/var/lib/snapd/hostfs/{,usr/}lib{,32,64,x32}/{,@{multiarch}/}libnvidia*.so{,.*} rm,
+/var/lib/snapd/hostfs/{,usr/}lib{,32,64,x32}/{,@{multiarch}/}libnvrtc*.so{,.*} rm,
+
+# AMD ROCm, through the kernel fusion driver and the render nodes
+/dev/kfd rw,
+/sys/devices/virtual/kfd/kfd/** r,
+/var/lib/snapd/hostfs/opt/rocm{,-[0-9]*}/ r,
+/var/lib/snapd/hostfs/opt/rocm{,-[0-9]*}/lib{,64}/** rm,
+/var/lib/snapd/hostfs/{,usr/}lib{,32,64,x32}/{,@{multiarch}/}lib{amdhip64,hsa-runtime64,hsakmt}.so{,.*} rm,
+
+# Intel oneAPI Level Zero and the OpenCL ICDs of all the vendors
+/etc/OpenCL/vendors/ r,
+/etc/OpenCL/vendors/*.icd r,
+/var/lib/snapd/hostfs/{,usr/}lib{,32,64,x32}/{,@{multiarch}/}libze_*.so{,.*} rm,
+/var/lib/snapd/hostfs/{,usr/}lib{,32,64,x32}/{,@{multiarch}/}lib{OpenCL,igdrcl}.so{,.*} rm,
+
+# Render nodes, without the display nodes
+/dev/dri/ r,
+/dev/dri/by-path/ r,
+/dev/dri/renderD[0-9]* rw,
+/sys/devices/**/drm/renderD[0-9]*/** r,
+
+# Topology of the GPUs
+/sys/bus/pci/devices/ r,
+/sys/devices/pci[0-9]*/**/config r,
+/sys/devices/pci[0-9]*/**/{,subsystem_}device r,
+/sys/devices/pci[0-9]*/**/{,subsystem_}vendor r,
+/sys/devices/pci[0-9]*/**/{numa_node,local_cpulist,resource} r,
+/run/udev/data/+pci:[0-9]* r,
+/run/udev/data/c226:[0-9]* r,  # 226 drm
+`
+
+// As with the opengl interface, the nvidia modules don't use sysfs and their
+// device nodes are added by snap-confine.
+const gpuComputeConnectedPlugUDev = `
+SUBSYSTEM=="drm", KERNEL=="renderD[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="kfd", TAG+="###CONNECTED_SECURITY_TAGS###"
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "gpu-compute",
+		summary:               gpuComputeSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  gpuComputeBaseDeclarationSlots,
+		connectedPlugAppArmor: gpuComputeConnectedPlugAppArmor,
+		connectedPlugUDev:     gpuComputeConnectedPlugUDev,
+		reservedForOS:         true,
+	})
+}
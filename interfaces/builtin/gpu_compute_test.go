@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type GpuComputeInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&GpuComputeInterfaceSuite{
+	iface: builtin.MustInterface("gpu-compute"),
+})
+
+const gpuComputeConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [gpu-compute]
+`
+
+const gpuComputeCoreYaml = `name: core
+type: os
+slots:
+  gpu-compute:
+`
+
+func (s *GpuComputeInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, gpuComputeConsumerYaml, nil, "gpu-compute")
+	s.slot = MockSlot(c, gpuComputeCoreYaml, nil, "gpu-compute")
+}
+
+func (s *GpuComputeInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "gpu-compute")
+}
+
+func (s *GpuComputeInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "gpu-compute",
+		Interface: "gpu-compute",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"gpu-compute slots are reserved for the core snap")
+}
+
+func (s *GpuComputeInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *GpuComputeInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/dev/nvidia-uvm rw,\n")
+	c.Check(snippet, testutil.Contains, "/dev/kfd rw,\n")
+	c.Check(snippet, testutil.Contains, "/dev/dri/renderD[0-9]* rw,\n")
+	// no display access
+	c.Check(snippet, Not(testutil.Contains), "/dev/dri/card")
+	c.Check(snippet, Not(testutil.Contains), "lib{GL,EGL}")
+}
+
+func (s *GpuComputeInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 1)
+	c.Check(spec.Snippets()[0], testutil.Contains, `SUBSYSTEM=="drm", KERNEL=="renderD[0-9]*", TAG+="snap_consumer_app"`)
+	c.Check(spec.Snippets()[0], testutil.Contains, `KERNEL=="kfd", TAG+="snap_consumer_app"`)
+}
+
+func (s *GpuComputeInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows access to GPU compute devices and drivers`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "gpu-compute")
+}
+
+func (s *GpuComputeInterfaceSuite) TestAutoConnect(c *C) {
+	// the policy denies the auto-connection, not the interface
+	c.Assert(s.iface.AutoConnect(s.plug, s.slot), Equals, true)
+}
+
+func (s *GpuComputeInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -173,6 +173,42 @@ func (iface *hidrawInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bo
 	return true
 }
 
+// hidrawUSBDevicePathPattern matches the sysfs path of the hidraw node of
+// a USB HID device, capturing the path of the USB interface and the vendor
+// and product ids of the device.
+var hidrawUSBDevicePathPattern = regexp.MustCompile(`^(/devices/.*/usb[0-9]+/.+)/0003:([0-9A-F]{4}):([0-9A-F]{4})\.[0-9A-F]+/hidraw/hidraw[0-9]+$`)
+
+// HotplugDeviceDetected creates a slot for USB HID devices as they are
+// plugged in.
+func (iface *hidrawInterface) HotplugDeviceDetected(devinfo *interfaces.HotplugDeviceInfo) (*interfaces.HotplugSlotSpec, error) {
+	if devinfo.Subsystem() != "hidraw" || !hidrawDeviceNodePattern.MatchString(devinfo.DeviceName()) {
+		return nil, nil
+	}
+	m := hidrawUSBDevicePathPattern.FindStringSubmatch(devinfo.DevicePath())
+	if m == nil {
+		return nil, nil
+	}
+	vendor, product := strings.ToLower(m[2]), strings.ToLower(m[3])
+	return &interfaces.HotplugSlotSpec{
+		Name:  interfaces.SuggestHotplugSlotName(fmt.Sprintf("hidraw-%s-%s", vendor, product), iface.Name()),
+		Label: fmt.Sprintf("USB HID device %s:%s", vendor, product),
+		Attrs: map[string]interface{}{
+			"path": devinfo.DeviceName(),
+		},
+	}, nil
+}
+
+// HotplugKey identifies hidraw devices by their vendor and product ids and
+// the port they are plugged in, as udev doesn't import the usb_id
+// properties for them.
+func (iface *hidrawInterface) HotplugKey(devinfo *interfaces.HotplugDeviceInfo) (string, error) {
+	m := hidrawUSBDevicePathPattern.FindStringSubmatch(devinfo.DevicePath())
+	if m == nil {
+		return "", nil
+	}
+	return interfaces.HashHotplugKey(devinfo.Subsystem(), m[1], m[2], m[3]), nil
+}
+
 func (iface *hidrawInterface) hasUsbAttrs(slot *interfaces.Slot) bool {
 	if _, ok := slot.Attrs["usb-vendor"]; ok {
 		return true
@@ -250,3 +250,54 @@ func (s *HidrawInterfaceSuite) TestConnectedPlugAppArmorSnippets(c *C) {
 func (s *HidrawInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	handler, ok := s.iface.(interfaces.HotplugDeviceHandler)
+	c.Assert(ok, Equals, true)
+
+	devinfo, err := interfaces.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2.1/1-2.1:1.0/0003:046D:C52B.0003/hidraw/hidraw0",
+		"DEVNAME":   "/dev/hidraw0",
+		"SUBSYSTEM": "hidraw",
+	})
+	c.Assert(err, IsNil)
+	spec, err := handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec, DeepEquals, &interfaces.HotplugSlotSpec{
+		Name:  "hidraw-046d-c52b",
+		Label: "USB HID device 046d:c52b",
+		Attrs: map[string]interface{}{"path": "/dev/hidraw0"},
+	})
+
+	// bluetooth HID devices are left alone
+	devinfo, err = interfaces.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/virtual/misc/uhid/0005:046D:B01A.0004/hidraw/hidraw1",
+		"DEVNAME":   "/dev/hidraw1",
+		"SUBSYSTEM": "hidraw",
+	})
+	c.Assert(err, IsNil)
+	spec, err = handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec, IsNil)
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugKey(c *C) {
+	key := func(devpath string) string {
+		devinfo, err := interfaces.NewHotplugDeviceInfo(map[string]string{
+			"DEVPATH":   devpath,
+			"DEVNAME":   "/dev/hidraw0",
+			"SUBSYSTEM": "hidraw",
+		})
+		c.Assert(err, IsNil)
+		key, err := interfaces.HotplugKey(s.iface, devinfo)
+		c.Assert(err, IsNil)
+		return key
+	}
+
+	// the instance number of the HID device and of the node don't matter
+	c.Check(key("/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/0003:046D:C52B.0003/hidraw/hidraw0"),
+		Equals, key("/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/0003:046D:C52B.0007/hidraw/hidraw3"))
+	// the port does
+	c.Check(key("/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/0003:046D:C52B.0003/hidraw/hidraw0"),
+		Not(Equals), key("/devices/pci0000:00/0000:00:14.0/usb1/1-3/1-3:1.0/0003:046D:C52B.0003/hidraw/hidraw0"))
+}
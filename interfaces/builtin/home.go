@@ -41,13 +41,14 @@ const homeConnectedPlugAppArmor = `
 owner @{HOME}/ r,
 
 # Allow read/write access to all files in @{HOME}, except snap application
-# data in @{HOME}/snaps and toplevel hidden directories in @{HOME}.
-owner @{HOME}/[^s.]**             rwk,
-owner @{HOME}/s[^n]**             rwk,
-owner @{HOME}/sn[^a]**            rwk,
-owner @{HOME}/sna[^p]**           rwk,
+# data in @{HOME}/snaps and toplevel hidden directories in @{HOME}. The
+# accesses are asked about when prompting is enabled.
+###PROMPT###owner @{HOME}/[^s.]**             rwk,
+###PROMPT###owner @{HOME}/s[^n]**             rwk,
+###PROMPT###owner @{HOME}/sn[^a]**            rwk,
+###PROMPT###owner @{HOME}/sna[^p]**           rwk,
 # Allow creating a few files not caught above
-owner @{HOME}/{s,sn,sna}{,/} rwk,
+###PROMPT###owner @{HOME}/{s,sn,sna}{,/} rwk,
 
 # Allow access to gvfs mounts for files owned by the user (including hidden
 # files; only allow writes to files, not the mount point).
@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const hostnameControlSummary = `allows setting the system hostname`
+
+const hostnameControlBaseDeclarationSlots = `
+  hostname-control:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const hostnameControlConnectedPlugAppArmor = `
+# Description: Can set the static and pretty hostnames via the hostnamed
+# D-Bus interface, without direct access to /etc/hostname, see:
+# https://www.freedesktop.org/wiki/Software/systemd/hostnamed/
+
+#include <abstractions/dbus-strict>
+
+/etc/hostname r,
+
+# Introspection of org.freedesktop.hostname1
+dbus (send)
+    bus=system
+    path=/org/freedesktop/hostname1
+    interface=org.freedesktop.DBus.Introspectable
+    member=Introspect
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/hostname1
+    interface=org.freedesktop.hostname1
+    member="Set{,Pretty}Hostname"
+    peer=(label=unconfined),
+
+# Read all properties from hostname1
+dbus (send)
+    bus=system
+    path=/org/freedesktop/hostname1
+    interface=org.freedesktop.DBus.Properties
+    member=Get{,All}
+    peer=(label=unconfined),
+
+# Receive hostname1 property changed events
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/hostname1
+    interface=org.freedesktop.DBus.Properties
+    member=PropertiesChanged
+    peer=(label=unconfined),
+
+# As the core snap ships the hostnamectl utility we can also allow
+# clients to use it now that they have access to the relevant
+# D-Bus methods for setting the hostname via hostnamectl's
+# set-hostname command.
+/usr/bin/hostnamectl{,.real} ixr,
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "hostname-control",
+		summary:               hostnameControlSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  hostnameControlBaseDeclarationSlots,
+		connectedPlugAppArmor: hostnameControlConnectedPlugAppArmor,
+		reservedForOS:         true,
+	})
+}
@@ -62,6 +62,11 @@ func (iface *i2cInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              i2cSummary,
 		BaseDeclarationSlots: i2cBaseDeclarationSlots,
+		SlotAttrs: interfaces.AttrSchema{{
+			Name:        "path",
+			Type:        interfaces.AttrString,
+			Description: "device node of the i2c bus, such as /dev/i2c-1",
+		}},
 	}
 }
 
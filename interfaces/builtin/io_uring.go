@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const ioUringSummary = `allows using io_uring for asynchronous I/O`
+
+const ioUringBaseDeclarationSlots = `
+  io-uring:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const ioUringConnectedPlugAppArmor = `
+# Description: Can use io_uring for asynchronous storage and network I/O.
+# Whether io_uring is disabled, or reserved to a group, by the kernel
+@{PROC}/sys/kernel/io_uring_disabled r,
+@{PROC}/sys/kernel/io_uring_group r,
+`
+
+const ioUringConnectedPlugSecComp = `
+# Description: Can use io_uring for asynchronous storage and network I/O.
+# io_uring has been the source of many kernel vulnerabilities, hence it is
+# not in the default policy.
+io_uring_setup
+io_uring_enter
+io_uring_register
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "io-uring",
+		summary:               ioUringSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  ioUringBaseDeclarationSlots,
+		connectedPlugAppArmor: ioUringConnectedPlugAppArmor,
+		connectedPlugSecComp:  ioUringConnectedPlugSecComp,
+		reservedForOS:         true,
+	})
+}
@@ -19,6 +19,14 @@
 
 package builtin
 
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
 const joystickSummary = `allows access to joystick devices`
 
 const joystickBaseDeclarationSlots = `
@@ -40,8 +48,48 @@ const joystickConnectedPlugAppArmor = `
 
 const joystickConnectedPlugUDev = `KERNEL=="js[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"`
 
+const joystickForceFeedbackConnectedPlugAppArmor = `
+# Description: Allow writing force-feedback effects, such as rumble, to
+# joysticks. The legacy joystick devices don't support force-feedback so
+# this is done through the event devices (/dev/input/event*). The device
+# cgroup limits those to the event devices of joysticks.
+/dev/input/event[0-9]* rw,
+/run/udev/data/c13:{6[4-9],[7-9][0-9],[1-9][0-9][0-9]*} r,
+`
+
+const joystickForceFeedbackConnectedPlugUDev = `KERNEL=="event[0-9]*", SUBSYSTEM=="input", ENV{ID_INPUT_JOYSTICK}=="1", TAG+="###CONNECTED_SECURITY_TAGS###"`
+
+// joystickInterface gives access to joysticks, along with their
+// force-feedback effects for plugs with the force-feedback attribute.
+type joystickInterface struct {
+	commonInterface
+}
+
+func (iface *joystickInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	spec.AddSnippet(joystickConnectedPlugAppArmor)
+	if forceFeedback, _ := plug.Attrs["force-feedback"].(bool); forceFeedback {
+		spec.AddSnippet(joystickForceFeedbackConnectedPlugAppArmor)
+	}
+	return nil
+}
+
+func (iface *joystickInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	if err := iface.commonInterface.UDevConnectedPlug(spec, plug, plugAttrs, slot, slotAttrs); err != nil {
+		return err
+	}
+	if forceFeedback, _ := plug.Attrs["force-feedback"].(bool); !forceFeedback {
+		return nil
+	}
+	old := "###CONNECTED_SECURITY_TAGS###"
+	for appName := range plug.Apps {
+		tag := udevSnapSecurityName(plug.Snap.Name(), appName)
+		spec.AddSnippet(strings.Replace(joystickForceFeedbackConnectedPlugUDev, old, tag, -1))
+	}
+	return nil
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&joystickInterface{commonInterface{
 		name:                  "joystick",
 		summary:               joystickSummary,
 		implicitOnCore:        true,
@@ -50,5 +98,10 @@ func init() {
 		connectedPlugAppArmor: joystickConnectedPlugAppArmor,
 		connectedPlugUDev:     joystickConnectedPlugUDev,
 		reservedForOS:         true,
-	})
+		plugAttrs: interfaces.AttrSchema{{
+			Name:        "force-feedback",
+			Type:        interfaces.AttrBool,
+			Description: "allow writing force-feedback effects to joysticks",
+		}},
+	}})
 }
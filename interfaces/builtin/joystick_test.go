@@ -46,6 +46,15 @@ apps:
   plugs: [joystick]
 `
 
+const joystickForceFeedbackConsumerYaml = `name: consumer
+plugs:
+ joystick:
+  force-feedback: true
+apps:
+ app:
+  plugs: [joystick]
+`
+
 const joystickCoreYaml = `name: core
 type: os
 slots:
@@ -90,6 +99,43 @@ func (s *JoystickInterfaceSuite) TestUDevSpec(c *C) {
 	c.Assert(spec.Snippets()[0], testutil.Contains, `KERNEL=="js[0-9]*", TAG+="snap_consumer_app"`)
 }
 
+func (s *JoystickInterfaceSuite) TestSanitizePlugForceFeedback(c *C) {
+	plug := MockPlug(c, joystickForceFeedbackConsumerYaml, nil, "joystick")
+	c.Assert(plug.Sanitize(s.iface), IsNil)
+
+	const mockPlugSnapInfoYaml = `name: consumer
+plugs:
+ joystick:
+  force-feedback: "yes"
+apps:
+ app:
+  plugs: [joystick]
+`
+	plug = MockPlug(c, mockPlugSnapInfoYaml, nil, "joystick")
+	c.Assert(plug.Sanitize(s.iface), ErrorMatches, `joystick plug attribute "force-feedback" must be a bool`)
+}
+
+func (s *JoystickInterfaceSuite) TestAppArmorSpecForceFeedback(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), `/dev/input/event[0-9]* rw,`)
+
+	plug := MockPlug(c, joystickForceFeedbackConsumerYaml, nil, "joystick")
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `/dev/input/js{[0-9],[12][0-9],3[01]} rw,`)
+	c.Assert(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `/dev/input/event[0-9]* rw,`)
+}
+
+func (s *JoystickInterfaceSuite) TestUDevSpecForceFeedback(c *C) {
+	plug := MockPlug(c, joystickForceFeedbackConsumerYaml, nil, "joystick")
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets(), testutil.Contains, `KERNEL=="js[0-9]*", TAG+="snap_consumer_app"`)
+	c.Assert(spec.Snippets(), testutil.Contains, `KERNEL=="event[0-9]*", SUBSYSTEM=="input", ENV{ID_INPUT_JOYSTICK}=="1", TAG+="snap_consumer_app"`)
+}
+
 func (s *JoystickInterfaceSuite) TestStaticInfo(c *C) {
 	si := interfaces.StaticInfoOf(s.iface)
 	c.Assert(si.ImplicitOnCore, Equals, true)
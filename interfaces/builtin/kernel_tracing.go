@@ -0,0 +1,96 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const kernelTracingSummary = `allows read access to kernel tracing and profiling facilities`
+
+const kernelTracingBaseDeclarationPlugs = `
+  kernel-tracing:
+    allow-installation: false
+    deny-auto-connection: true
+`
+
+const kernelTracingBaseDeclarationSlots = `
+  kernel-tracing:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const kernelTracingConnectedPlugAppArmor = `
+# Description: Can read kernel trace buffers via tracefs and profile the
+# system with perf events. This is restricted because it gives privileged
+# insight into all processes on the system and should only be used with
+# trusted apps. What can be profiled is still limited by perf_event_paranoid,
+# as no capability is granted.
+
+  # tracefs, either mounted on its own or below debugfs
+  /sys/kernel/tracing/ r,
+  /sys/kernel/tracing/** r,
+  /sys/kernel/debug/tracing/ r,
+  /sys/kernel/debug/tracing/** r,
+
+  # perf events
+  @{PROC}/sys/kernel/perf_event_paranoid r,
+  @{PROC}/sys/kernel/perf_event_max_sample_rate r,
+  @{PROC}/sys/kernel/perf_event_max_stack r,
+  @{PROC}/sys/kernel/perf_event_mlock_kb r,
+  @{PROC}/sys/kernel/kptr_restrict r,
+  @{PROC}/kallsyms r,
+  /sys/bus/event_source/devices/ r,
+  /sys/bus/event_source/devices/** r,
+  /sys/devices/**/events/ r,
+  /sys/devices/**/events/** r,
+  /sys/devices/**/format/ r,
+  /sys/devices/**/format/** r,
+  /sys/devices/system/cpu/ r,
+  /sys/devices/system/cpu/** r,
+`
+
+// perf_event_open(attr, pid, cpu, group_fd, flags) is only allowed with
+// PERF_FLAG_FD_CLOEXEC and PERF_FLAG_FD_NO_GROUP, alone or together, so that
+// snaps cannot monitor whole cgroups (PERF_FLAG_PID_CGROUP) nor redirect the
+// output of other events (PERF_FLAG_FD_OUTPUT).
+const kernelTracingConnectedPlugSecComp = `
+# Description: Can read kernel trace buffers via tracefs and profile the
+# system with perf events. This is restricted because it gives privileged
+# insight into all processes on the system and should only be used with
+# trusted apps.
+
+perf_event_open - - - - 0
+perf_event_open - - - - PERF_FLAG_FD_CLOEXEC
+perf_event_open - - - - PERF_FLAG_FD_NO_GROUP
+perf_event_open - - - - PERF_FLAG_FD_CLOEXEC|PERF_FLAG_FD_NO_GROUP
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "kernel-tracing",
+		summary:               kernelTracingSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationPlugs:  kernelTracingBaseDeclarationPlugs,
+		baseDeclarationSlots:  kernelTracingBaseDeclarationSlots,
+		connectedPlugAppArmor: kernelTracingConnectedPlugAppArmor,
+		connectedPlugSecComp:  kernelTracingConnectedPlugSecComp,
+		reservedForOS:         true,
+	})
+}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type KernelTracingInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&KernelTracingInterfaceSuite{
+	iface: builtin.MustInterface("kernel-tracing"),
+})
+
+func (s *KernelTracingInterfaceSuite) SetUpTest(c *C) {
+	const mockPlugSnapInfo = `name: other
+version: 1.0
+apps:
+ app:
+  command: foo
+  plugs: [kernel-tracing]
+`
+	s.slot = &interfaces.Slot{
+		SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+			Name:      "kernel-tracing",
+			Interface: "kernel-tracing",
+		},
+	}
+	plugSnap := snaptest.MockInfo(c, mockPlugSnapInfo, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["kernel-tracing"]}
+}
+
+func (s *KernelTracingInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "kernel-tracing")
+}
+
+func (s *KernelTracingInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "kernel-tracing",
+		Interface: "kernel-tracing",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches, "kernel-tracing slots are reserved for the core snap")
+}
+
+func (s *KernelTracingInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *KernelTracingInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.other.app"})
+	c.Check(spec.SnippetForTag("snap.other.app"), testutil.Contains, "/sys/kernel/tracing/** r,")
+	c.Check(spec.SnippetForTag("snap.other.app"), testutil.Contains, "/sys/kernel/debug/tracing/** r,")
+	c.Check(spec.SnippetForTag("snap.other.app"), Not(testutil.Contains), "/sys/kernel/debug/tracing/** rw,")
+	c.Check(spec.SnippetForTag("snap.other.app"), Not(testutil.Contains), "capability sys_admin")
+}
+
+func (s *KernelTracingInterfaceSuite) TestSecCompSpec(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.other.app"})
+	c.Check(spec.SnippetForTag("snap.other.app"), testutil.Contains, "perf_event_open - - - - PERF_FLAG_FD_CLOEXEC\n")
+	c.Check(spec.SnippetForTag("snap.other.app"), testutil.Contains, "perf_event_open - - - - PERF_FLAG_FD_CLOEXEC|PERF_FLAG_FD_NO_GROUP\n")
+	c.Check(spec.SnippetForTag("snap.other.app"), Not(testutil.Contains), "\nperf_event_open\n")
+}
+
+func (s *KernelTracingInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows read access to kernel tracing and profiling facilities`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "kernel-tracing")
+}
+
+func (s *KernelTracingInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const krb5ConfSummary = `allows Kerberos single sign-on using the system configuration and the user's credentials cache`
+
+const krb5ConfBaseDeclarationSlots = `
+  krb5-conf:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const krb5ConfConnectedPlugAppArmor = `
+# Description: Can authenticate with Kerberos on behalf of the user, as
+# enterprise browsers and mail clients do for single sign-on. This gives
+# read access to the Kerberos and SSSD client configuration, and access to
+# the credentials cache of the user running the snap.
+
+# Kerberos configuration
+/etc/krb5.conf r,
+/etc/krb5.conf.d/ r,
+/etc/krb5.conf.d/* r,
+
+# KDC and realm hints published by SSSD for its locator plugin
+/var/lib/sss/pubconf/ r,
+/var/lib/sss/pubconf/** r,
+
+# The SSSD NSS responder socket, used to look up the principal of a user
+/var/lib/sss/pipes/nss rw,
+
+# File and directory credentials caches of the invoking user. Caches in /tmp
+# are already covered by the snap's private /tmp.
+owner /run/user/[0-9]*/krb5cc* rwk,
+owner /run/user/[0-9]*/krb5cc/ rw,
+owner /run/user/[0-9]*/krb5cc/* rwk,
+
+# KCM credentials caches, as provided by sssd-kcm or Heimdal's kcm
+/{,var/}run/.heim_org.h5l.kcm-socket rw,
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "krb5-conf",
+		summary:               krb5ConfSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  krb5ConfBaseDeclarationSlots,
+		connectedPlugAppArmor: krb5ConfConnectedPlugAppArmor,
+		reservedForOS:         true,
+	})
+}
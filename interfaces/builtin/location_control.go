@@ -116,6 +116,29 @@ dbus (send)
     interface=org.freedesktop.DBus.Properties
     member=PropertiesChanged
     peer=(label=###PLUG_SECURITY_TAGS###),
+
+# Allow clients to register as the GeoClue agent, authorizing the
+# location-observe clients with the accuracy of their plugs
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Manager
+    interface=org.freedesktop.GeoClue2.Manager
+    member=AddAgent
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Agent
+    interface=org.freedesktop.GeoClue2.Agent
+    member=AuthorizeApp
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Agent
+    interface=org.freedesktop.DBus.Properties
+    member="{Get,GetAll}"
+    peer=(label=###PLUG_SECURITY_TAGS###),
 `
 
 const locationControlConnectedPlugAppArmor = `
@@ -181,6 +204,29 @@ dbus (send)
     interface=org.freedesktop.DBus.Introspectable
     member=Introspect
     peer=(label=###SLOT_SECURITY_TAGS###),
+
+# Allow registering as the GeoClue agent, which decides of the accuracy
+# of the locations given to location-observe clients
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Manager
+    interface=org.freedesktop.GeoClue2.Manager
+    member=AddAgent
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Agent
+    interface=org.freedesktop.GeoClue2.Agent
+    member=AuthorizeApp
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Agent
+    interface=org.freedesktop.DBus.Properties
+    member="{Get,GetAll}"
+    peer=(label=###SLOT_SECURITY_TAGS###),
 `
 
 const locationControlPermanentSlotDBus = `
@@ -189,6 +235,7 @@ const locationControlPermanentSlotDBus = `
     <allow send_destination="com.ubuntu.location.Service"/>
     <allow send_interface="com.ubuntu.location.Service"/>
     <allow send_interface="com.ubuntu.location.Service.Provider"/>
+    <allow send_interface="org.freedesktop.GeoClue2.Agent"/>
 </policy>
 `
 
@@ -198,6 +245,8 @@ const locationControlConnectedPlugDBus = `
     <allow send_destination="com.ubuntu.location.Service"/>
     <allow send_interface="com.ubuntu.location.Service"/>
     <allow receive_interface="com.ubuntu.location.Service.Provider"/>
+    <allow send_destination="org.freedesktop.GeoClue2"/>
+    <allow receive_interface="org.freedesktop.GeoClue2.Agent"/>
 </policy>
 `
 
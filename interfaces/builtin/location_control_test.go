@@ -209,6 +209,18 @@ func (s *LocationControlInterfaceSuite) TestConnectedSlotSnippetUsesPlugLabelOne
 	c.Assert(apparmorSpec.SnippetForTag("snap.location.app2"), testutil.Contains, `peer=(label="snap.location.app"),`)
 }
 
+func (s *LocationControlInterfaceSuite) TestGeoClueAgent(c *C) {
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.location-consumer.app"), testutil.Contains, "interface=org.freedesktop.GeoClue2.Manager\n    member=AddAgent\n")
+
+	apparmorSpec = &apparmor.Specification{}
+	err = apparmorSpec.AddConnectedSlot(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.location.app2"), testutil.Contains, "interface=org.freedesktop.GeoClue2.Agent\n    member=AuthorizeApp\n")
+}
+
 func (s *LocationControlInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
@@ -63,6 +63,10 @@ dbus (bind)
     bus=system
     name="com.ubuntu.location.Service",
 
+dbus (bind)
+    bus=system
+    name="org.freedesktop.GeoClue2",
+
 dbus (receive, send)
     bus=system
     path=/com/ubuntu/location/Service{,/**}
@@ -234,6 +238,79 @@ dbus (send)
     peer=(label=###SLOT_SECURITY_TAGS###),
 `
 
+// GeoClue leaves it to its agent to decide of the accuracy of the
+// locations its clients get. Only location-control plugs can register
+// the agent, which limits each client snap to the accuracy attribute of
+// its location-observe plug.
+const locationObserveConnectedSlotGeoClueAppArmor = `
+# Allow clients to use GeoClue
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Manager
+    interface=org.freedesktop.GeoClue2.Manager
+    member="{GetClient,CreateClient,DeleteClient}"
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Client/**
+    interface=org.freedesktop.GeoClue2.Client
+    member="{Start,Stop}"
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/{Manager,Client/**,Location/**}
+    interface=org.freedesktop.DBus.Properties
+    member="{Get,GetAll,Set}"
+    peer=(label=###PLUG_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Client/**
+    interface=org.freedesktop.GeoClue2.Client
+    member=LocationUpdated
+    peer=(label=###PLUG_SECURITY_TAGS###),
+`
+
+const locationObserveConnectedPlugGeoClueAppArmor = `
+# Allow using GeoClue, with the accuracy of ###ACCURACY### locations at best
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Manager
+    interface=org.freedesktop.GeoClue2.Manager
+    member="{GetClient,CreateClient,DeleteClient}"
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Client/**
+    interface=org.freedesktop.GeoClue2.Client
+    member="{Start,Stop}"
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/{Manager,Client/**,Location/**}
+    interface=org.freedesktop.DBus.Properties
+    member="{Get,GetAll,Set}"
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Client/**
+    interface=org.freedesktop.GeoClue2.Client
+    member=LocationUpdated
+    peer=(label=###SLOT_SECURITY_TAGS###),
+
+# The agent decides of the accuracy given to clients
+deny dbus (send)
+    bus=system
+    path=/org/freedesktop/GeoClue2/Manager
+    interface=org.freedesktop.GeoClue2.Manager
+    member=AddAgent,
+`
+
 const locationObservePermanentSlotDBus = `
 <policy user="root">
     <allow own="com.ubuntu.location.Service"/>
@@ -242,6 +319,8 @@ const locationObservePermanentSlotDBus = `
     <allow send_destination="com.ubuntu.location.Service.Session"/>
     <allow send_interface="com.ubuntu.location.Service"/>
     <allow send_interface="com.ubuntu.location.Service.Session"/>
+    <allow own="org.freedesktop.GeoClue2"/>
+    <allow send_destination="org.freedesktop.GeoClue2"/>
 </policy>
 `
 
@@ -252,9 +331,15 @@ const locationObserveConnectedPlugDBus = `
     <allow send_destination="com.ubuntu.location.Service.Session"/>
     <allow send_interface="com.ubuntu.location.Service"/>
     <allow send_interface="com.ubuntu.location.Service.Session"/>
+    <deny own="org.freedesktop.GeoClue2"/>
+    <allow send_destination="org.freedesktop.GeoClue2"/>
 </policy>
 `
 
+// locationAccuracies are the accuracies location-observe plugs can ask
+// for, from the coarsest to the most accurate, as GeoClue levels them.
+var locationAccuracies = []string{"city", "neighborhood", "exact"}
+
 type locationObserveInterface struct{}
 
 func (iface *locationObserveInterface) Name() string {
@@ -265,9 +350,24 @@ func (iface *locationObserveInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              locationObserveSummary,
 		BaseDeclarationSlots: locationObserveBaseDeclarationSlots,
+		PlugAttrs: interfaces.AttrSchema{{
+			Name:        "accuracy",
+			Type:        interfaces.AttrString,
+			Description: "best accuracy of the locations given to the snap (defaults to exact)",
+			Enum:        locationAccuracies,
+		}},
 	}
 }
 
+// plugAccuracy returns the best accuracy of the locations the plug can
+// get, exact unless told otherwise.
+func (iface *locationObserveInterface) plugAccuracy(plug *interfaces.Plug) string {
+	if accuracy, ok := plug.Attrs["accuracy"].(string); ok {
+		return accuracy
+	}
+	return "exact"
+}
+
 func (iface *locationObserveInterface) DBusConnectedPlug(spec *dbus.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
 	spec.AddSnippet(locationObserveConnectedPlugDBus)
 	return nil
@@ -283,6 +383,9 @@ func (iface *locationObserveInterface) AppArmorConnectedPlug(spec *apparmor.Spec
 	new := slotAppLabelExpr(slot)
 	snippet := strings.Replace(locationObserveConnectedPlugAppArmor, old, new, -1)
 	spec.AddSnippet(snippet)
+	snippet = strings.Replace(locationObserveConnectedPlugGeoClueAppArmor, old, new, -1)
+	snippet = strings.Replace(snippet, "###ACCURACY###", iface.plugAccuracy(plug), -1)
+	spec.AddSnippet(snippet)
 	return nil
 }
 
@@ -296,6 +399,7 @@ func (iface *locationObserveInterface) AppArmorConnectedSlot(spec *apparmor.Spec
 	new := plugAppLabelExpr(plug)
 	snippet := strings.Replace(locationObserveConnectedSlotAppArmor, old, new, -1)
 	spec.AddSnippet(snippet)
+	spec.AddSnippet(strings.Replace(locationObserveConnectedSlotGeoClueAppArmor, old, new, -1))
 	return nil
 }
 
@@ -20,6 +20,8 @@
 package builtin_test
 
 import (
+	"fmt"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces"
@@ -203,6 +205,56 @@ func (s *LocationObserveInterfaceSuite) TestConnectedSlotSnippetUsesPlugLabelOne
 	c.Assert(apparmorSpec.SnippetForTag("snap.location.app2"), testutil.Contains, `peer=(label="snap.location.app"),`)
 }
 
+func (s *LocationObserveInterfaceSuite) TestSanitizePlugAccuracy(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+
+	const mockPlugSnapInfoYaml = `name: weather
+plugs:
+ location-observe:
+  accuracy: %s
+apps:
+ app:
+  plugs: [location-observe]
+`
+	plug := MockPlug(c, fmt.Sprintf(mockPlugSnapInfoYaml, "city"), nil, "location-observe")
+	c.Assert(plug.Sanitize(s.iface), IsNil)
+
+	plug = MockPlug(c, fmt.Sprintf(mockPlugSnapInfoYaml, "street"), nil, "location-observe")
+	c.Assert(plug.Sanitize(s.iface), ErrorMatches,
+		`location-observe plug attribute "accuracy" must be one of "city", "neighborhood", "exact" \(got "street"\)`)
+}
+
+func (s *LocationObserveInterfaceSuite) TestConnectedPlugGeoClue(c *C) {
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	snippet := apparmorSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "# Allow using GeoClue, with the accuracy of exact locations at best\n")
+	c.Check(snippet, testutil.Contains, "interface=org.freedesktop.GeoClue2.Client\n")
+	c.Check(snippet, testutil.Contains, "deny dbus (send)\n    bus=system\n    path=/org/freedesktop/GeoClue2/Manager\n    interface=org.freedesktop.GeoClue2.Manager\n    member=AddAgent,\n")
+
+	plug := MockPlug(c, `name: weather
+plugs:
+ location-observe:
+  accuracy: city
+apps:
+ app:
+  plugs: [location-observe]
+`, nil, "location-observe")
+	apparmorSpec = &apparmor.Specification{}
+	err = apparmorSpec.AddConnectedPlug(s.iface, plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.weather.app"), testutil.Contains, "# Allow using GeoClue, with the accuracy of city locations at best\n")
+}
+
+func (s *LocationObserveInterfaceSuite) TestConnectedSlotGeoClue(c *C) {
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedSlot(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	snippet := apparmorSpec.SnippetForTag("snap.location.app2")
+	c.Check(snippet, testutil.Contains, "member=LocationUpdated\n    peer=(label=\"snap.other.app\"),\n")
+}
+
 func (s *LocationObserveInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
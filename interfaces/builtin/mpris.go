@@ -161,6 +161,8 @@ func (iface *mprisInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              mprisSummary,
 		BaseDeclarationSlots: mprisBaseDeclarationSlots,
+		// a plug can control all the players
+		GreedyPlugs: true,
 	}
 }
 
@@ -67,6 +67,10 @@ func (s *MprisInterfaceSuite) TestName(c *C) {
 	c.Assert(s.iface.Name(), Equals, "mpris")
 }
 
+func (s *MprisInterfaceSuite) TestGreedyPlugs(c *C) {
+	c.Check(interfaces.StaticInfoOf(s.iface).GreedyPlugs, Equals, true)
+}
+
 func (s *MprisInterfaceSuite) TestGetName(c *C) {
 	const mockSnapYaml = `name: mpris-client
 version: 1.0
@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+const pipewireSummary = `allows playing and, when granted, capturing audio and video through PipeWire`
+
+const pipewireBaseDeclarationSlots = `
+  pipewire:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection:
+      -
+        plug-attributes:
+          audio-capture: true
+      -
+        plug-attributes:
+          screen-capture: true
+      -
+        plug-attributes:
+          video-capture: true
+`
+
+const pipewireConnectedPlugAppArmor = `
+# Description: Can connect to the PipeWire daemon of the user session to play
+# audio and video. Audio capture goes through the same socket, so it cannot
+# be told apart here: it is left to the PipeWire session manager, which can
+# check the audio-capture attribute of this connection.
+
+owner /run/user/[0-9]*/ r,
+owner /run/user/[0-9]*/pipewire-0 rw,
+
+# Buffers shared with the daemon, for the clients not using memfds
+/{run,dev}/shm/pipewire-* mrwk,
+
+# Client side configuration
+/etc/pipewire/ r,
+/etc/pipewire/** r,
+`
+
+const pipewireScreenCaptureConnectedPlugAppArmor = `
+# Description: Can ask the desktop, through the screencast portal, for a
+# PipeWire stream of the screen or of a window. The user picks what to share.
+
+#include <abstractions/dbus-session-strict>
+
+dbus (send)
+    bus=session
+    path=/org/freedesktop/portal/desktop
+    interface=org.freedesktop.portal.ScreenCast
+    member={CreateSession,SelectSources,Start,OpenPipeWireRemote}
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=session
+    path=/org/freedesktop/portal/desktop
+    interface=org.freedesktop.DBus.Properties
+    member=Get
+    peer=(label=unconfined),
+
+dbus (receive)
+    bus=session
+    path=/org/freedesktop/portal/desktop/request/**
+    interface=org.freedesktop.portal.Request
+    member=Response
+    peer=(label=unconfined),
+
+dbus (send,receive)
+    bus=session
+    path=/org/freedesktop/portal/desktop/session/**
+    interface=org.freedesktop.portal.Session
+    peer=(label=unconfined),
+`
+
+const pipewireVideoCaptureConnectedPlugAppArmor = `
+# Description: Can ask the desktop, through the camera portal, for a PipeWire
+# remote giving access to the cameras.
+
+#include <abstractions/dbus-session-strict>
+
+dbus (send)
+    bus=session
+    path=/org/freedesktop/portal/desktop
+    interface=org.freedesktop.portal.Camera
+    member={AccessCamera,OpenPipeWireRemote}
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=session
+    path=/org/freedesktop/portal/desktop
+    interface=org.freedesktop.DBus.Properties
+    member=Get
+    peer=(label=unconfined),
+
+dbus (receive)
+    bus=session
+    path=/org/freedesktop/portal/desktop/request/**
+    interface=org.freedesktop.portal.Request
+    member=Response
+    peer=(label=unconfined),
+`
+
+// pipewireInterface gives access to the PipeWire daemon of the user
+// session, with the capture capabilities selected by plug attributes.
+type pipewireInterface struct {
+	commonInterface
+}
+
+func (iface *pipewireInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	spec.AddSnippet(pipewireConnectedPlugAppArmor)
+	if screenCapture, _ := plug.Attrs["screen-capture"].(bool); screenCapture {
+		spec.AddSnippet(pipewireScreenCaptureConnectedPlugAppArmor)
+	}
+	if videoCapture, _ := plug.Attrs["video-capture"].(bool); videoCapture {
+		spec.AddSnippet(pipewireVideoCaptureConnectedPlugAppArmor)
+	}
+	return nil
+}
+
+func init() {
+	registerIface(&pipewireInterface{commonInterface{
+		name:                 "pipewire",
+		summary:              pipewireSummary,
+		implicitOnClassic:    true,
+		baseDeclarationSlots: pipewireBaseDeclarationSlots,
+		reservedForOS:        true,
+		plugAttrs: interfaces.AttrSchema{{
+			Name:        "audio-capture",
+			Type:        interfaces.AttrBool,
+			Description: "allow recording audio, enforced by the PipeWire session manager",
+		}, {
+			Name:        "screen-capture",
+			Type:        interfaces.AttrBool,
+			Description: "allow capturing the screen through the screencast portal",
+		}, {
+			Name:        "video-capture",
+			Type:        interfaces.AttrBool,
+			Description: "allow capturing video from cameras through the camera portal",
+		}},
+	}})
+}
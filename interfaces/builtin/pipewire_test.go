@@ -0,0 +1,130 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type PipewireInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&PipewireInterfaceSuite{
+	iface: builtin.MustInterface("pipewire"),
+})
+
+const pipewireConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [pipewire]
+`
+
+const pipewireCaptureConsumerYaml = `name: consumer
+plugs:
+ pipewire:
+  audio-capture: true
+  screen-capture: true
+  video-capture: true
+apps:
+ app:
+  plugs: [pipewire]
+`
+
+const pipewireCoreYaml = `name: core
+type: os
+slots:
+  pipewire:
+`
+
+func (s *PipewireInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, pipewireConsumerYaml, nil, "pipewire")
+	s.slot = MockSlot(c, pipewireCoreYaml, nil, "pipewire")
+}
+
+func (s *PipewireInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "pipewire")
+}
+
+func (s *PipewireInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "pipewire",
+		Interface: "pipewire",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"pipewire slots are reserved for the core snap")
+}
+
+func (s *PipewireInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+	plug := MockPlug(c, pipewireCaptureConsumerYaml, nil, "pipewire")
+	c.Assert(plug.Sanitize(s.iface), IsNil)
+
+	const mockPlugSnapInfoYaml = `name: consumer
+plugs:
+ pipewire:
+  video-capture: 1
+apps:
+ app:
+  plugs: [pipewire]
+`
+	plug = MockPlug(c, mockPlugSnapInfoYaml, nil, "pipewire")
+	c.Assert(plug.Sanitize(s.iface), ErrorMatches, `pipewire plug attribute "video-capture" must be a bool`)
+}
+
+func (s *PipewireInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "owner /run/user/[0-9]*/pipewire-0 rw,\n")
+	c.Check(snippet, Not(testutil.Contains), "org.freedesktop.portal.ScreenCast")
+	c.Check(snippet, Not(testutil.Contains), "org.freedesktop.portal.Camera")
+
+	plug := MockPlug(c, pipewireCaptureConsumerYaml, nil, "pipewire")
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, nil, s.slot, nil), IsNil)
+	snippet = spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "owner /run/user/[0-9]*/pipewire-0 rw,\n")
+	c.Check(snippet, testutil.Contains, "interface=org.freedesktop.portal.ScreenCast\n")
+	c.Check(snippet, testutil.Contains, "interface=org.freedesktop.portal.Camera\n")
+}
+
+func (s *PipewireInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows playing and, when granted, capturing audio and video through PipeWire`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "pipewire")
+}
+
+func (s *PipewireInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,166 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/systemd"
+)
+
+const pkcs11Summary = `allows use of PKCS#11 tokens provided by the host`
+
+const pkcs11BaseDeclarationSlots = `
+  pkcs11:
+    allow-installation:
+      slot-snap-type:
+        - gadget
+        - core
+    deny-auto-connection: true
+`
+
+const pkcs11ConnectedPlugAppArmor = `
+# Description: Can use the PKCS#11 tokens the host exports over a p11-kit
+# server socket. The tokens themselves, and the devices backing them, stay
+# with the server on the host.
+
+# p11-kit client configuration and modules
+/etc/pkcs11/ r,
+/etc/pkcs11/** r,
+/usr/share/p11-kit/modules/ r,
+/usr/share/p11-kit/modules/* r,
+`
+
+const pkcs11ConnectedPlugAppArmorSocket = `
+# p11-kit server socket
+%s rw,
+`
+
+// pkcs11SocketPattern matches the p11-kit server sockets slots may
+// point to.
+var pkcs11SocketPattern = regexp.MustCompile(`^/run/p11-kit/[a-zA-Z0-9_.-]+$`)
+
+// pkcs11TokenLabelPattern matches the token labels slots may name, at
+// most 32 characters as in CK_TOKEN_INFO.
+var pkcs11TokenLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9 _.-]{1,32}$`)
+
+// pkcs11Interface gives access to the PKCS#11 tokens of the host named
+// by the "token-labels" slot attribute. Each slot gets its own p11-kit
+// server, run on the host with access to the devices backing the
+// tokens, which only exports those tokens on the socket named by the
+// "pkcs11-socket" slot attribute: plugs only get to use that socket.
+type pkcs11Interface struct{}
+
+func (iface *pkcs11Interface) Name() string {
+	return "pkcs11"
+}
+
+func (iface *pkcs11Interface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              pkcs11Summary,
+		BaseDeclarationSlots: pkcs11BaseDeclarationSlots,
+	}
+}
+
+func (iface *pkcs11Interface) String() string {
+	return iface.Name()
+}
+
+func (iface *pkcs11Interface) SanitizeSlot(slot *interfaces.Slot) error {
+	if err := sanitizeSlotReservedForOSOrGadget(iface, slot); err != nil {
+		return err
+	}
+
+	socket, ok := slot.Attrs["pkcs11-socket"].(string)
+	if !ok || socket == "" {
+		return fmt.Errorf("%s slot must have a pkcs11-socket attribute", iface.Name())
+	}
+	if filepath.Clean(socket) != socket || !pkcs11SocketPattern.MatchString(socket) {
+		return fmt.Errorf("%s pkcs11-socket attribute must be a socket in /run/p11-kit/", iface.Name())
+	}
+
+	if _, err := pkcs11TokenLabels(slot); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// pkcs11TokenLabels returns the labels of the tokens the slot exports.
+func pkcs11TokenLabels(slot *interfaces.Slot) ([]string, error) {
+	values, ok := slot.Attrs["token-labels"].([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("pkcs11 slot must have a token-labels attribute listing the tokens it exports")
+	}
+	labels := make([]string, len(values))
+	for i, value := range values {
+		label, ok := value.(string)
+		if !ok || !pkcs11TokenLabelPattern.MatchString(label) {
+			return nil, fmt.Errorf("pkcs11 token-labels attribute has invalid label %q", value)
+		}
+		labels[i] = label
+	}
+	return labels, nil
+}
+
+func (iface *pkcs11Interface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	socket, ok := slot.Attrs["pkcs11-socket"].(string)
+	if !ok {
+		return nil
+	}
+	spec.AddSnippet(pkcs11ConnectedPlugAppArmor)
+	spec.AddSnippet(fmt.Sprintf(pkcs11ConnectedPlugAppArmorSocket, filepath.Clean(socket)))
+	return nil
+}
+
+func (iface *pkcs11Interface) SystemdPermanentSlot(spec *systemd.Specification, slot *interfaces.Slot) error {
+	socket, ok := slot.Attrs["pkcs11-socket"].(string)
+	if !ok {
+		return nil
+	}
+	labels, err := pkcs11TokenLabels(slot)
+	if err != nil {
+		return err
+	}
+	tokens := make([]string, len(labels))
+	for i, label := range labels {
+		tokens[i] = "pkcs11:token=" + strings.Replace(label, " ", "%20", -1)
+	}
+	serviceName := interfaces.InterfaceServiceName(slot.Snap.Name(), "pkcs11-"+slot.Name)
+	service := &systemd.Service{
+		Description: fmt.Sprintf("p11-kit server for the pkcs11 slot %s of %s", slot.Name, slot.Snap.Name()),
+		ExecStart:   fmt.Sprintf("/usr/bin/p11-kit server --foreground --name %s %s", filepath.Clean(socket), strings.Join(tokens, " ")),
+	}
+	return spec.AddService(serviceName, service)
+}
+
+func (iface *pkcs11Interface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+func init() {
+	registerIface(&pkcs11Interface{})
+}
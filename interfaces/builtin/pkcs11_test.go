@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/systemd"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type Pkcs11InterfaceSuite struct {
+	iface interfaces.Interface
+
+	gadgetSlots map[string]*interfaces.Slot
+	appSlot     *interfaces.Slot
+	plug        *interfaces.Plug
+}
+
+var _ = Suite(&Pkcs11InterfaceSuite{
+	iface: builtin.MustInterface("pkcs11"),
+})
+
+func (s *Pkcs11InterfaceSuite) SetUpTest(c *C) {
+	gadgetSnapInfo := snaptest.MockInfo(c, `
+name: some-device
+type: gadget
+slots:
+  hsm:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/pkcs11-hsm
+    token-labels: [signing, Device Keys]
+  no-socket:
+    interface: pkcs11
+    token-labels: [signing]
+  bad-socket:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/../pcscd/pcscd.comm
+    token-labels: [signing]
+  other-dir-socket:
+    interface: pkcs11
+    pkcs11-socket: /tmp/pkcs11
+    token-labels: [signing]
+  no-labels:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/pkcs11-hsm
+  empty-labels:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/pkcs11-hsm
+    token-labels: []
+  bad-label:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/pkcs11-hsm
+    token-labels: ["signing;id=1"]
+  long-label:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/pkcs11-hsm
+    token-labels: [a-label-much-longer-than-32-characters]
+`, nil)
+	s.gadgetSlots = make(map[string]*interfaces.Slot)
+	for name, slotInfo := range gadgetSnapInfo.Slots {
+		s.gadgetSlots[name] = &interfaces.Slot{SlotInfo: slotInfo}
+	}
+
+	appSnapInfo := snaptest.MockInfo(c, `
+name: some-app
+slots:
+  hsm:
+    interface: pkcs11
+    pkcs11-socket: /run/p11-kit/pkcs11-hsm
+    token-labels: [signing]
+`, nil)
+	s.appSlot = &interfaces.Slot{SlotInfo: appSnapInfo.Slots["hsm"]}
+
+	consumingSnapInfo := snaptest.MockInfo(c, `
+name: client-snap
+apps:
+  signer:
+    command: foo
+    plugs: [pkcs11]
+`, nil)
+	s.plug = &interfaces.Plug{PlugInfo: consumingSnapInfo.Plugs["pkcs11"]}
+}
+
+func (s *Pkcs11InterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "pkcs11")
+}
+
+func (s *Pkcs11InterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.gadgetSlots["hsm"].Sanitize(s.iface), IsNil)
+}
+
+func (s *Pkcs11InterfaceSuite) TestSanitizeBadSlots(c *C) {
+	for name, err := range map[string]string{
+		"no-socket":        "pkcs11 slot must have a pkcs11-socket attribute",
+		"bad-socket":       "pkcs11 pkcs11-socket attribute must be a socket in /run/p11-kit/",
+		"other-dir-socket": "pkcs11 pkcs11-socket attribute must be a socket in /run/p11-kit/",
+		"no-labels":        "pkcs11 slot must have a token-labels attribute listing the tokens it exports",
+		"empty-labels":     "pkcs11 slot must have a token-labels attribute listing the tokens it exports",
+		"bad-label":        `pkcs11 token-labels attribute has invalid label "signing;id=1"`,
+		"long-label":       `pkcs11 token-labels attribute has invalid label "a-label-much-longer-than-32-characters"`,
+	} {
+		c.Check(s.gadgetSlots[name].Sanitize(s.iface), ErrorMatches, err, Commentf(name))
+	}
+	c.Check(s.appSlot.Sanitize(s.iface), ErrorMatches, "pkcs11 slots are reserved for the core and gadget snaps")
+}
+
+func (s *Pkcs11InterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *Pkcs11InterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.gadgetSlots["hsm"], nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.signer"})
+	snippet := spec.SnippetForTag("snap.client-snap.signer")
+	c.Check(snippet, testutil.Contains, "# p11-kit server socket\n/run/p11-kit/pkcs11-hsm rw,\n")
+	c.Check(snippet, testutil.Contains, "/etc/pkcs11/** r,\n")
+	// devices backing the tokens are only accessed by the server
+	c.Check(snippet, Not(testutil.Contains), "/dev/tpm")
+}
+
+func (s *Pkcs11InterfaceSuite) TestSystemdSpec(c *C) {
+	// each slot gets a server only exporting the tokens it names
+	spec := &systemd.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.gadgetSlots["hsm"]), IsNil)
+	c.Check(spec.Services(), DeepEquals, map[string]*systemd.Service{
+		"snap.some-device.interface.pkcs11-hsm.service": {
+			Description: "p11-kit server for the pkcs11 slot hsm of some-device",
+			ExecStart:   "/usr/bin/p11-kit server --foreground --name /run/p11-kit/pkcs11-hsm pkcs11:token=signing pkcs11:token=Device%20Keys",
+		},
+	})
+
+	// nothing for the plugs
+	spec = &systemd.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.gadgetSlots["hsm"], nil), IsNil)
+	c.Check(spec.Services(), HasLen, 0)
+}
+
+func (s *Pkcs11InterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.gadgetSlots["hsm"], nil), IsNil)
+	c.Check(spec.Snippets(), HasLen, 0)
+}
+
+func (s *Pkcs11InterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, false)
+	c.Check(si.ImplicitOnClassic, Equals, false)
+	c.Check(si.Summary, Equals, "allows use of PKCS#11 tokens provided by the host")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "pkcs11")
+}
+
+func (s *Pkcs11InterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *Pkcs11InterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,87 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const powerProfilesControlSummary = `allows holding and switching power profiles via power-profiles-daemon`
+
+const powerProfilesControlBaseDeclarationSlots = `
+  power-profiles-control:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const powerProfilesControlConnectedPlugAppArmor = `
+# Description: Can query and switch the active power profile, and hold a
+# profile for the lifetime of the application, through power-profiles-daemon.
+
+#include <abstractions/dbus-strict>
+
+# Read and set the ActiveProfile property, list the available profiles
+# and the current holds.
+dbus (send)
+    bus=system
+    path=/org/freedesktop/UPower/PowerProfiles
+    interface=org.freedesktop.DBus.Properties
+    member={Get,GetAll,Set}
+    peer=(label=unconfined),
+
+dbus (send)
+    bus=system
+    path=/org/freedesktop/UPower/PowerProfiles
+    interface=org.freedesktop.UPower.PowerProfiles
+    member={HoldProfile,ReleaseProfile}
+    peer=(label=unconfined),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/UPower/PowerProfiles
+    interface=org.freedesktop.DBus.Properties
+    member=PropertiesChanged
+    peer=(label=unconfined),
+
+dbus (receive)
+    bus=system
+    path=/org/freedesktop/UPower/PowerProfiles
+    interface=org.freedesktop.UPower.PowerProfiles
+    member=ProfileReleased
+    peer=(label=unconfined),
+
+# Allow clients to introspect
+dbus (send)
+    bus=system
+    path=/org/freedesktop/UPower/PowerProfiles
+    interface=org.freedesktop.DBus.Introspectable
+    member=Introspect
+    peer=(label=unconfined),
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "power-profiles-control",
+		summary:               powerProfilesControlSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  powerProfilesControlBaseDeclarationSlots,
+		connectedPlugAppArmor: powerProfilesControlConnectedPlugAppArmor,
+		reservedForOS:         true,
+	})
+}
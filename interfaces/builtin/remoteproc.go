@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const remoteprocSummary = `allows loading the firmware of and controlling co-processors`
+
+const remoteprocBaseDeclarationSlots = `
+  remoteproc:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const remoteprocConnectedPlugAppArmor = `
+# Description: Can load the firmware of the auxiliary cores managed by the
+# remoteproc framework of the kernel (Cortex-M cores of i.MX SoCs, DSPs...)
+# and start and stop them.
+
+/sys/class/remoteproc/ r,
+/sys/devices/**/remoteproc/remoteproc[0-9]*/ r,
+/sys/devices/**/remoteproc/remoteproc[0-9]*/{name,uevent} r,
+/sys/devices/**/remoteproc/remoteproc[0-9]*/{firmware,state,recovery,coredump} rw,
+
+# The firmware written to the firmware attribute is looked up by the kernel
+# in its firmware search path, where the snap may only stage files in a
+# directory of its own, named as snap.<snap name>/<file> in the attribute.
+/lib/firmware/updates/ rw,
+/lib/firmware/updates/snap.@{SNAP_NAME}/ rw,
+/lib/firmware/updates/snap.@{SNAP_NAME}/** rw,
+
+# Character devices controlling the state of the cores, the core being
+# stopped when the device is closed, unless told otherwise with an ioctl.
+/dev/remoteproc[0-9]* rw,
+`
+
+const remoteprocConnectedPlugUDev = `KERNEL=="remoteproc[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "remoteproc",
+		summary:               remoteprocSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  remoteprocBaseDeclarationSlots,
+		connectedPlugAppArmor: remoteprocConnectedPlugAppArmor,
+		connectedPlugUDev:     remoteprocConnectedPlugUDev,
+		reservedForOS:         true,
+	})
+}
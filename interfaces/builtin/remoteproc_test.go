@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type RemoteprocInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&RemoteprocInterfaceSuite{
+	iface: builtin.MustInterface("remoteproc"),
+})
+
+const remoteprocConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [remoteproc]
+`
+
+const remoteprocCoreYaml = `name: core
+type: os
+slots:
+  remoteproc:
+`
+
+func (s *RemoteprocInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, remoteprocConsumerYaml, nil, "remoteproc")
+	s.slot = MockSlot(c, remoteprocCoreYaml, nil, "remoteproc")
+}
+
+func (s *RemoteprocInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "remoteproc")
+}
+
+func (s *RemoteprocInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "remoteproc",
+		Interface: "remoteproc",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"remoteproc slots are reserved for the core snap")
+}
+
+func (s *RemoteprocInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *RemoteprocInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/sys/devices/**/remoteproc/remoteproc[0-9]*/{firmware,state,recovery,coredump} rw,\n")
+	c.Check(snippet, testutil.Contains, "/lib/firmware/updates/snap.@{SNAP_NAME}/** rw,\n")
+	c.Check(snippet, Not(testutil.Contains), "firmware_class")
+	c.Check(snippet, testutil.Contains, "/dev/remoteproc[0-9]* rw,\n")
+}
+
+func (s *RemoteprocInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Check(spec.Snippets(), DeepEquals, []string{`KERNEL=="remoteproc[0-9]*", TAG+="snap_consumer_app"`})
+}
+
+func (s *RemoteprocInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows loading the firmware of and controlling co-processors`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "remoteproc")
+}
+
+func (s *RemoteprocInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
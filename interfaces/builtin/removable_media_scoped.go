@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+const removableMediaScopedSummary = `allows access to specific mounted removable storage`
+
+const removableMediaScopedBaseDeclarationSlots = `
+  removable-media-scoped:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-auto-connection: true
+`
+
+const removableMediaScopedConnectedPlugAppArmor = `
+# Description: Can access the removable storage filesystems mounted on the
+# mountpoints of the slot.
+`
+
+// Mountpoints of removable storage, the characters having a meaning in
+// AppArmor globs being excluded.
+var removableMediaScopedMountpointPattern = regexp.MustCompile(`^/(media|run/media|mnt)(/[^/"*?{}\[\]^@,]+)+$`)
+
+// removableMediaScopedInterface gives access to the removable storage
+// mounted on the mountpoints listed by the slot, rather than to all of
+// it as the removable-media interface does. The access is only mediated
+// by AppArmor.
+type removableMediaScopedInterface struct {
+	commonInterface
+}
+
+// SanitizeSlot checks that the slot is provided by the core or gadget
+// snap and lists at least one mountpoint, its mountpoints being checked
+// against the schema.
+func (iface *removableMediaScopedInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	if err := sanitizeSlotReservedForOSOrGadget(iface, slot); err != nil {
+		return err
+	}
+	if len(removableMediaScopedMountpoints(slot)) == 0 {
+		return fmt.Errorf("removable-media-scoped slot must list at least one mountpoint")
+	}
+	return nil
+}
+
+func (iface *removableMediaScopedInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	access := "rw"
+	if removableMediaScopedReadOnly(slot) {
+		access = "r"
+	}
+	spec.AddSnippet(removableMediaScopedConnectedPlugAppArmor)
+	for _, mountpoint := range removableMediaScopedMountpoints(slot) {
+		spec.AddSnippet(fmt.Sprintf("\"%s/\" r,\n\"%s/**\" %s,\n", mountpoint, mountpoint, access))
+	}
+	return nil
+}
+
+// removableMediaScopedMountpoints returns the cleaned mountpoints listed
+// by the slot.
+func removableMediaScopedMountpoints(slot *interfaces.Slot) []string {
+	list, _ := slot.Attrs["mountpoints"].([]interface{})
+	mountpoints := make([]string, 0, len(list))
+	for _, item := range list {
+		if mountpoint, ok := item.(string); ok {
+			mountpoints = append(mountpoints, filepath.Clean(mountpoint))
+		}
+	}
+	return mountpoints
+}
+
+func removableMediaScopedReadOnly(slot *interfaces.Slot) bool {
+	readOnly, _ := slot.Attrs["read-only"].(bool)
+	return readOnly
+}
+
+func init() {
+	registerIface(&removableMediaScopedInterface{commonInterface{
+		name:                 "removable-media-scoped",
+		summary:              removableMediaScopedSummary,
+		baseDeclarationSlots: removableMediaScopedBaseDeclarationSlots,
+		slotAttrs: interfaces.AttrSchema{{
+			Name:                "mountpoints",
+			Type:                interfaces.AttrStringList,
+			Required:            true,
+			Description:         "mountpoints of the removable storage the slot gives access to",
+			Path:                true,
+			Patterns:            []*regexp.Regexp{removableMediaScopedMountpointPattern},
+			PatternsDescription: "a mountpoint under /media, /run/media or /mnt",
+		}, {
+			Name:        "read-only",
+			Type:        interfaces.AttrBool,
+			Description: "whether the removable storage can only be read",
+		}},
+	}})
+}
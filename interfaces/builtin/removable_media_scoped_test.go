@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type RemovableMediaScopedInterfaceSuite struct {
+	iface        interfaces.Interface
+	slot         *interfaces.Slot
+	readOnlySlot *interfaces.Slot
+	plug         *interfaces.Plug
+}
+
+var _ = Suite(&RemovableMediaScopedInterfaceSuite{
+	iface: builtin.MustInterface("removable-media-scoped"),
+})
+
+func (s *RemovableMediaScopedInterfaceSuite) SetUpTest(c *C) {
+	gadgetSnap := snaptest.MockInfo(c, `
+name: gadget
+type: gadget
+slots:
+  sdcard:
+    interface: removable-media-scoped
+    mountpoints: [/media/sdcard, /run/media/usb-disk/]
+  cdrom:
+    interface: removable-media-scoped
+    mountpoints: [/mnt/cdrom]
+    read-only: true
+`, nil)
+	s.slot = &interfaces.Slot{SlotInfo: gadgetSnap.Slots["sdcard"]}
+	s.readOnlySlot = &interfaces.Slot{SlotInfo: gadgetSnap.Slots["cdrom"]}
+	plugSnap := snaptest.MockInfo(c, `
+name: client-snap
+apps:
+  app:
+    command: foo
+    plugs: [removable-media-scoped]
+`, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["removable-media-scoped"]}
+}
+
+func (s *RemovableMediaScopedInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "removable-media-scoped")
+}
+
+func (s *RemovableMediaScopedInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	c.Assert(s.readOnlySlot.Sanitize(s.iface), IsNil)
+
+	for _, t := range []struct {
+		attrs string
+		err   string
+	}{
+		{"", `removable-media-scoped slot must contain the mountpoints attribute`},
+		{"mountpoints: []", `removable-media-scoped slot must list at least one mountpoint`},
+		{"mountpoints: /media/usb", `removable-media-scoped slot attribute "mountpoints" must be a list of strings`},
+		{"mountpoints: [media/usb]", `removable-media-scoped slot attribute "mountpoints" must be an absolute path \(got "media/usb"\)`},
+		{"mountpoints: [/media]", `removable-media-scoped slot attribute "mountpoints" must be a mountpoint under /media, /run/media or /mnt \(got "/media"\)`},
+		{"mountpoints: [/media/../home]", `removable-media-scoped slot attribute "mountpoints" must be a mountpoint under /media, /run/media or /mnt \(got "/home"\)`},
+		{"mountpoints: [/media/*]", `removable-media-scoped slot attribute "mountpoints" must be a mountpoint under /media, /run/media or /mnt \(got "/media/\*"\)`},
+		{"mountpoints: [/media/usb]\n    read-only: yes please", `removable-media-scoped slot attribute "read-only" must be a bool`},
+	} {
+		info := snaptest.MockInfo(c, `
+name: gadget
+type: gadget
+slots:
+  media:
+    interface: removable-media-scoped
+    `+t.attrs+`
+`, nil)
+		slot := &interfaces.Slot{SlotInfo: info.Slots["media"]}
+		c.Check(slot.Sanitize(s.iface), ErrorMatches, t.err, Commentf(t.attrs))
+	}
+
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "media",
+		Interface: "removable-media-scoped",
+		Attrs:     map[string]interface{}{"mountpoints": []interface{}{"/media/usb"}},
+	}}
+	c.Check(slot.Sanitize(s.iface), ErrorMatches, "removable-media-scoped slots are reserved for the core and gadget snaps")
+}
+
+func (s *RemovableMediaScopedInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *RemovableMediaScopedInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.app"})
+	snippet := spec.SnippetForTag("snap.client-snap.app")
+	c.Check(snippet, testutil.Contains, "\"/media/sdcard/\" r,\n\"/media/sdcard/**\" rw,\n")
+	c.Check(snippet, testutil.Contains, "\"/run/media/usb-disk/\" r,\n\"/run/media/usb-disk/**\" rw,\n")
+	c.Check(snippet, Not(testutil.Contains), "/{,run/}media/*/")
+
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.readOnlySlot, nil), IsNil)
+	snippet = spec.SnippetForTag("snap.client-snap.app")
+	c.Check(snippet, testutil.Contains, "\"/mnt/cdrom/\" r,\n\"/mnt/cdrom/**\" r,\n")
+	c.Check(snippet, Not(testutil.Contains), "rw,")
+}
+
+func (s *RemovableMediaScopedInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, false)
+	c.Check(si.ImplicitOnClassic, Equals, false)
+	c.Check(si.Summary, Equals, "allows access to specific mounted removable storage")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "removable-media-scoped")
+}
+
+func (s *RemovableMediaScopedInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+const secretsSummary = `allows reading specific secrets stored in snapd`
+
+const secretsConnectedPlugAppArmor = `
+# Description: Allow reading the secrets listed in the plug via snapctl.
+# The secrets themselves are only ever handed out by snapd.
+
+/usr/bin/snapctl ixr,
+`
+
+const secretsBaseDeclarationSlots = `
+  secrets:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+// secretsInterface grants access to the secrets listed in the "secrets"
+// attribute of the plug, which snaps read with "snapctl secret get".
+type secretsInterface struct{}
+
+func (iface *secretsInterface) Name() string {
+	return "secrets"
+}
+
+func (iface *secretsInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              secretsSummary,
+		ImplicitOnCore:       true,
+		ImplicitOnClassic:    true,
+		BaseDeclarationSlots: secretsBaseDeclarationSlots,
+	}
+}
+
+func (iface *secretsInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	return sanitizeSlotReservedForOS(iface, slot)
+}
+
+func (iface *secretsInterface) SanitizePlug(plug *interfaces.Plug) error {
+	names, ok := plug.Attrs["secrets"].([]interface{})
+	if !ok || len(names) == 0 {
+		return fmt.Errorf("secrets plug requires a non-empty list of names in 'secrets'")
+	}
+	for _, name := range names {
+		if s, ok := name.(string); !ok || s == "" {
+			return fmt.Errorf("secrets plug requires 'secrets' to contain non-empty strings")
+		}
+	}
+	return nil
+}
+
+func (iface *secretsInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	spec.AddSnippet(secretsConnectedPlugAppArmor)
+	return nil
+}
+
+func (iface *secretsInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&secretsInterface{})
+}
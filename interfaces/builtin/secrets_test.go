@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type SecretsInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+const secretsMockPlugSnapInfoYaml = `name: consumer
+version: 1.0
+plugs:
+ secrets:
+  secrets: [db-password, api-token]
+apps:
+ app:
+  command: foo
+  plugs: [secrets]
+`
+
+var _ = Suite(&SecretsInterfaceSuite{
+	iface: builtin.MustInterface("secrets"),
+})
+
+func (s *SecretsInterfaceSuite) SetUpTest(c *C) {
+	s.slot = &interfaces.Slot{
+		SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+			Name:      "secrets",
+			Interface: "secrets",
+		},
+	}
+	plugSnap := snaptest.MockInfo(c, secretsMockPlugSnapInfoYaml, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["secrets"]}
+}
+
+func (s *SecretsInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "secrets")
+}
+
+func (s *SecretsInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "secrets",
+		Interface: "secrets",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches, "secrets slots are reserved for the core snap")
+}
+
+func (s *SecretsInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *SecretsInterfaceSuite) TestSanitizePlugBadSecrets(c *C) {
+	for _, t := range []struct {
+		attr string
+		err  string
+	}{
+		{"", "secrets plug requires a non-empty list of names in 'secrets'"},
+		{"secrets: []", "secrets plug requires a non-empty list of names in 'secrets'"},
+		{"secrets: db-password", "secrets plug requires a non-empty list of names in 'secrets'"},
+		{"secrets: [1]", "secrets plug requires 'secrets' to contain non-empty strings"},
+		{`secrets: [""]`, "secrets plug requires 'secrets' to contain non-empty strings"},
+	} {
+		info := snaptest.MockInfo(c, "name: consumer\nversion: 1.0\nplugs:\n secrets:\n  interface: secrets\n  "+t.attr+"\n", nil)
+		plug := &interfaces.Plug{PlugInfo: info.Plugs["secrets"]}
+		c.Check(plug.Sanitize(s.iface), ErrorMatches, t.err, Commentf(t.attr))
+	}
+}
+
+func (s *SecretsInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/usr/bin/snapctl ixr,")
+}
+
+func (s *SecretsInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows reading specific secrets stored in snapd`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "secrets")
+}
+
+func (s *SecretsInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(s.plug, s.slot), Equals, true)
+}
+
+func (s *SecretsInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+)
+
+const serialConsoleSummary = `allows owning a serial console of the device`
+
+const serialConsoleBaseDeclarationSlots = `
+  serial-console:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-auto-connection: true
+`
+
+const serialConsoleConnectedPlugAppArmor = `
+# Description: Can own a serial console of the device, as kiosk or
+# diagnostic snaps do on embedded devices.
+
+# Find out which consoles the kernel is using
+/sys/class/tty/console/active r,
+/sys/devices/virtual/tty/console/active r,
+
+###CONSOLE### rw,
+`
+
+// serialConsoleInterface is the type of the serial-console interface.
+// Unlike serial-port, it is about the built-in UARTs used as the system
+// console, which a getty is usually running on.
+type serialConsoleInterface struct{}
+
+// Pattern of the device nodes of the built-in UARTs that can be used as
+// console, ttySX on PCs and ttyAMAX on ARM boards.
+var serialConsoleNodePattern = regexp.MustCompile("^/dev/tty(S|AMA)[0-9]+$")
+
+// Directories where systemd units, or its getty generator, enable the
+// gettys of the serial consoles.
+var gettyWantsDirs = []string{
+	"/etc/systemd/system/getty.target.wants",
+	"/run/systemd/generator/getty.target.wants",
+	"/lib/systemd/system/getty.target.wants",
+}
+
+func (iface *serialConsoleInterface) Name() string {
+	return "serial-console"
+}
+
+func (iface *serialConsoleInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              serialConsoleSummary,
+		BaseDeclarationSlots: serialConsoleBaseDeclarationSlots,
+		SlotAttrs: interfaces.AttrSchema{{
+			Name:                "path",
+			Type:                interfaces.AttrString,
+			Required:            true,
+			Description:         "device node of the serial console",
+			Path:                true,
+			Patterns:            []*regexp.Regexp{serialConsoleNodePattern},
+			PatternsDescription: "a serial console device node such as /dev/ttyS0 or /dev/ttyAMA0",
+		}},
+	}
+}
+
+func (iface *serialConsoleInterface) String() string {
+	return iface.Name()
+}
+
+// SanitizeSlot checks that the slot is provided by the core or gadget
+// snap, its path being checked against the schema.
+func (iface *serialConsoleInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	return sanitizeSlotReservedForOSOrGadget(iface, slot)
+}
+
+// CheckConnection refuses to hand the console over to a snap while a
+// getty is enabled on it, as they would then fight over it.
+func (iface *serialConsoleInterface) CheckConnection(plug *interfaces.Plug, slot *interfaces.Slot) error {
+	path, _ := slot.Attrs["path"].(string)
+	unit := fmt.Sprintf("serial-getty@%s.service", filepath.Base(path))
+	for _, dir := range gettyWantsDirs {
+		if _, err := os.Lstat(filepath.Join(dirs.GlobalRootDir, dir, unit)); err == nil {
+			return fmt.Errorf("cannot connect to serial console %s: it is in use by %s, disable it first", path, unit)
+		}
+	}
+	return nil
+}
+
+func (iface *serialConsoleInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	path, ok := slot.Attrs["path"].(string)
+	if !ok {
+		return nil
+	}
+	snippet := strings.Replace(serialConsoleConnectedPlugAppArmor, "###CONSOLE###", filepath.Clean(path), -1)
+	spec.AddSnippet(snippet)
+	return nil
+}
+
+func (iface *serialConsoleInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&serialConsoleInterface{})
+}
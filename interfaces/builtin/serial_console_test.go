@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type SerialConsoleInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&SerialConsoleInterfaceSuite{
+	iface: builtin.MustInterface("serial-console"),
+})
+
+func (s *SerialConsoleInterfaceSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	gadgetSnap := snaptest.MockInfo(c, `
+name: gadget
+type: gadget
+slots:
+  console:
+    interface: serial-console
+    path: /dev/ttyAMA0
+`, nil)
+	s.slot = &interfaces.Slot{SlotInfo: gadgetSnap.Slots["console"]}
+	plugSnap := snaptest.MockInfo(c, `
+name: kiosk
+apps:
+  app:
+    command: foo
+    plugs: [serial-console]
+`, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["serial-console"]}
+}
+
+func (s *SerialConsoleInterfaceSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (s *SerialConsoleInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "serial-console")
+}
+
+func (s *SerialConsoleInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{{
+		"name: gadget\ntype: gadget\nslots:\n  console:\n    interface: serial-console\n",
+		"serial-console slot must contain the path attribute",
+	}, {
+		"name: gadget\ntype: gadget\nslots:\n  console:\n    interface: serial-console\n    path: /dev/ttyUSB0\n",
+		`serial-console slot attribute "path" must be a serial console device node such as /dev/ttyS0 or /dev/ttyAMA0 \(got "/dev/ttyUSB0"\)`,
+	}, {
+		"name: some-snap\nslots:\n  console:\n    interface: serial-console\n    path: /dev/ttyS0\n",
+		"serial-console slots are reserved for the core and gadget snaps",
+	}} {
+		info := snaptest.MockInfo(c, t.yaml, nil)
+		slot := &interfaces.Slot{SlotInfo: info.Slots["console"]}
+		c.Check(slot.Sanitize(s.iface), ErrorMatches, t.err)
+	}
+}
+
+func (s *SerialConsoleInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *SerialConsoleInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.kiosk.app"})
+	c.Check(spec.SnippetForTag("snap.kiosk.app"), testutil.Contains, "/dev/ttyAMA0 rw,\n")
+	c.Check(spec.SnippetForTag("snap.kiosk.app"), testutil.Contains, "/sys/class/tty/console/active r,\n")
+}
+
+func (s *SerialConsoleInterfaceSuite) TestCheckConnection(c *C) {
+	c.Check(interfaces.CheckConnection(s.iface, s.plug, s.slot), IsNil)
+
+	// a getty enabled on another console does not matter
+	wants := filepath.Join(dirs.GlobalRootDir, "/run/systemd/generator/getty.target.wants")
+	c.Assert(os.MkdirAll(wants, 0755), IsNil)
+	c.Assert(os.Symlink("/lib/systemd/system/serial-getty@.service", filepath.Join(wants, "serial-getty@ttyS0.service")), IsNil)
+	c.Check(interfaces.CheckConnection(s.iface, s.plug, s.slot), IsNil)
+
+	c.Assert(os.Symlink("/lib/systemd/system/serial-getty@.service", filepath.Join(wants, "serial-getty@ttyAMA0.service")), IsNil)
+	c.Check(interfaces.CheckConnection(s.iface, s.plug, s.slot), ErrorMatches,
+		`cannot connect to serial console /dev/ttyAMA0: it is in use by serial-getty@ttyAMA0.service, disable it first`)
+}
+
+func (s *SerialConsoleInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, false)
+	c.Check(si.ImplicitOnClassic, Equals, false)
+	c.Check(si.Summary, Equals, "allows owning a serial console of the device")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "serial-console")
+}
+
+func (s *SerialConsoleInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(s.plug, s.slot), Equals, true)
+}
+
+func (s *SerialConsoleInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -53,6 +53,27 @@ func (iface *serialPortInterface) StaticInfo() interfaces.StaticInfo {
 	return interfaces.StaticInfo{
 		Summary:              serialPortSummary,
 		BaseDeclarationSlots: serialPortBaseDeclarationSlots,
+		SlotAttrs: interfaces.AttrSchema{{
+			Name:        "path",
+			Type:        interfaces.AttrString,
+			Description: "device node of the serial port, or where to link to it for usb devices",
+		}, {
+			Name:        "usb-vendor",
+			Type:        interfaces.AttrInt,
+			Description: "vendor id of the usb serial adapter",
+		}, {
+			Name:        "usb-product",
+			Type:        interfaces.AttrInt,
+			Description: "product id of the usb serial adapter",
+		}},
+		ConnectionAttrs: interfaces.AttrSchema{{
+			Name:                "path",
+			Type:                interfaces.AttrString,
+			Description:         "device node the plug is restricted to among those of the slot",
+			Path:                true,
+			Patterns:            []*regexp.Regexp{serialDeviceNodePattern},
+			PatternsDescription: "a valid serial device node",
+		}},
 	}
 }
 
@@ -122,6 +143,16 @@ func (iface *serialPortInterface) SanitizeSlot(slot *interfaces.Slot) error {
 	return nil
 }
 
+// ValidateConnectionAttrs checks that the path given when connecting
+// narrows down a slot identifying the device by its usb vendor and
+// product, as other slots are limited to a single device node already.
+func (iface *serialPortInterface) ValidateConnectionAttrs(plug *interfaces.Plug, slot *interfaces.Slot, attrs map[string]interface{}) error {
+	if _, ok := attrs["path"]; ok && !iface.hasUsbAttrs(slot) {
+		return fmt.Errorf("serial-port connection path attribute requires a slot with usb-vendor and usb-product attributes")
+	}
+	return nil
+}
+
 func (iface *serialPortInterface) UDevPermanentSlot(spec *udev.Specification, slot *interfaces.Slot) error {
 	usbVendor, vOk := slot.Attrs["usb-vendor"].(int64)
 	if !vOk {
@@ -141,6 +172,11 @@ func (iface *serialPortInterface) UDevPermanentSlot(spec *udev.Specification, sl
 
 func (iface *serialPortInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
 	if iface.hasUsbAttrs(slot) {
+		// The connection can be restricted to a single device node
+		if path, ok := slotAttrs["path"].(string); ok && path != "" {
+			spec.AddSnippet(fmt.Sprintf("%s rw,", filepath.Clean(path)))
+			return nil
+		}
 		// This apparmor rule is an approximation of serialDeviceNodePattern
 		// (AARE is different than regex, so we must approximate).
 		// UDev tagging and device cgroups will restrict down to the specific device
@@ -179,6 +215,26 @@ func (iface *serialPortInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot
 	return true
 }
 
+// HotplugDeviceDetected creates a slot for USB serial adapters as they
+// are plugged in. Built-in UARTs are left to static slots of the gadget.
+func (iface *serialPortInterface) HotplugDeviceDetected(devinfo *interfaces.HotplugDeviceInfo) (*interfaces.HotplugSlotSpec, error) {
+	bus, _ := devinfo.Property("ID_BUS")
+	if devinfo.Subsystem() != "tty" || bus != "usb" || !serialDeviceNodePattern.MatchString(devinfo.DeviceName()) {
+		return nil, nil
+	}
+	model, _ := devinfo.Property("ID_MODEL_FROM_DATABASE")
+	if model == "" {
+		model, _ = devinfo.Property("ID_MODEL")
+	}
+	return &interfaces.HotplugSlotSpec{
+		Name:  interfaces.SuggestHotplugSlotName(model, iface.Name()),
+		Label: model,
+		Attrs: map[string]interface{}{
+			"path": devinfo.DeviceName(),
+		},
+	}, nil
+}
+
 func (iface *serialPortInterface) hasUsbAttrs(slot *interfaces.Slot) bool {
 	if _, ok := slot.Attrs["usb-vendor"]; ok {
 		return true
@@ -210,6 +210,18 @@ func (s *SerialPortInterfaceSuite) TestName(c *C) {
 	c.Assert(s.iface.Name(), Equals, "serial-port")
 }
 
+func (s *SerialPortInterfaceSuite) TestStaticInfoAttrs(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	var names []string
+	for _, spec := range si.SlotAttrs {
+		c.Check(spec.Description, Not(Equals), "")
+		names = append(names, spec.Name)
+	}
+	c.Check(names, DeepEquals, []string{"path", "usb-vendor", "usb-product"})
+	c.Assert(si.ConnectionAttrs, HasLen, 1)
+	c.Check(si.ConnectionAttrs[0].Name, Equals, "path")
+}
+
 func (s *SerialPortInterfaceSuite) TestSanitizeCoreSnapSlots(c *C) {
 	for _, slot := range []*interfaces.Slot{s.testSlot1, s.testSlot2, s.testSlot3, s.testSlot4, s.testSlot5, s.testSlot6, s.testSlot7} {
 		c.Assert(slot.Sanitize(s.iface), IsNil)
@@ -325,6 +337,69 @@ func (s *SerialPortInterfaceSuite) TestConnectedPlugAppArmorSnippets(c *C) {
 	checkConnectedPlugSnippet(s.testPlugPort2, s.testUDev2, expectedSnippet9)
 }
 
+func (s *SerialPortInterfaceSuite) TestConnectedPlugAppArmorConnectionPath(c *C) {
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, s.testPlugPort1, nil, s.testUDev1, map[string]interface{}{"path": "/dev/ttyUSB1/"})
+	c.Assert(err, IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.app-accessing-2-ports"), Equals, `/dev/ttyUSB1 rw,`)
+}
+
+func (s *SerialPortInterfaceSuite) TestValidateConnectionAttrs(c *C) {
+	attrs := map[string]interface{}{"path": "/dev/ttyUSB1"}
+	c.Check(interfaces.ValidateConnectionAttrs(s.iface, s.testPlugPort1, s.testUDev1, attrs), IsNil)
+	c.Check(interfaces.ValidateConnectionAttrs(s.iface, s.testPlugPort1, s.testSlot1, attrs), ErrorMatches,
+		"serial-port connection path attribute requires a slot with usb-vendor and usb-product attributes")
+
+	attrs = map[string]interface{}{"path": "/dev/sda"}
+	c.Check(interfaces.ValidateConnectionAttrs(s.iface, s.testPlugPort1, s.testUDev1, attrs), ErrorMatches,
+		`serial-port connection attribute "path" must be a valid serial device node \(got "/dev/sda"\)`)
+
+	attrs = map[string]interface{}{"usb-vendor": 1}
+	c.Check(interfaces.ValidateConnectionAttrs(s.iface, s.testPlugPort1, s.testUDev1, attrs), ErrorMatches,
+		`serial-port connections do not support attribute "usb-vendor"`)
+}
+
 func (s *SerialPortInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *SerialPortInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	handler, ok := s.iface.(interfaces.HotplugDeviceHandler)
+	c.Assert(ok, Equals, true)
+
+	props := map[string]string{
+		"DEVPATH":                "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0",
+		"DEVNAME":                "/dev/ttyUSB0",
+		"SUBSYSTEM":              "tty",
+		"ID_BUS":                 "usb",
+		"ID_MODEL":               "FT232R_USB_UART",
+		"ID_MODEL_FROM_DATABASE": "FT232 Serial (UART) IC",
+	}
+	devinfo, err := interfaces.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	spec, err := handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec, DeepEquals, &interfaces.HotplugSlotSpec{
+		Name:  "ft232-serial-uart-ic",
+		Label: "FT232 Serial (UART) IC",
+		Attrs: map[string]interface{}{"path": "/dev/ttyUSB0"},
+	})
+
+	delete(props, "ID_MODEL_FROM_DATABASE")
+	devinfo, err = interfaces.NewHotplugDeviceInfo(props)
+	c.Assert(err, IsNil)
+	spec, err = handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec.Name, Equals, "ft232r-usb-uart")
+
+	// built-in UARTs are left alone
+	devinfo, err = interfaces.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/platform/serial8250/tty/ttyS0",
+		"DEVNAME":   "/dev/ttyS0",
+		"SUBSYSTEM": "tty",
+	})
+	c.Assert(err, IsNil)
+	spec, err = handler.HotplugDeviceDetected(devinfo)
+	c.Assert(err, IsNil)
+	c.Check(spec, IsNil)
+}
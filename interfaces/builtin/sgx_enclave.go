@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
+const sgxEnclaveSummary = `allows running Intel SGX enclaves`
+
+const sgxEnclaveBaseDeclarationSlots = `
+  sgx-enclave:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const sgxEnclaveConnectedPlugAppArmor = `
+# Description: Can create and run Intel SGX enclaves, as confidential
+# computing runtimes do. The enclave pages are mapped executable from the
+# device.
+
+# The in-kernel driver, and the out-of-tree DCAP and legacy drivers
+/dev/sgx_enclave rwm,
+/dev/sgx/ r,
+/dev/sgx/enclave rwm,
+/dev/isgx rwm,
+
+# The architectural enclaves of the platform, such as the quoting enclave,
+# are run by the AESM service of the host
+/{,var/}run/aesmd/aesm.socket rw,
+`
+
+const sgxEnclaveConnectedPlugUDev = `SUBSYSTEM=="misc", KERNEL=="sgx_enclave", TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="isgx", TAG+="###CONNECTED_SECURITY_TAGS###"`
+
+const sgxEnclaveProvisionConnectedPlugAppArmor = `
+# Description: Can create provisioning enclaves, which access the
+# provisioning key of the platform to obtain attestation keys.
+/dev/sgx_provision rw,
+/dev/sgx/provision rw,
+`
+
+const sgxEnclaveProvisionConnectedPlugUDev = `SUBSYSTEM=="misc", KERNEL=="sgx_provision", TAG+="###CONNECTED_SECURITY_TAGS###"`
+
+// sgxEnclaveInterface gives access to SGX enclaves, along with the
+// provisioning key of the platform for plugs with the provision attribute.
+type sgxEnclaveInterface struct {
+	commonInterface
+}
+
+func (iface *sgxEnclaveInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	spec.AddSnippet(sgxEnclaveConnectedPlugAppArmor)
+	if provision, _ := plug.Attrs["provision"].(bool); provision {
+		spec.AddSnippet(sgxEnclaveProvisionConnectedPlugAppArmor)
+	}
+	return nil
+}
+
+func (iface *sgxEnclaveInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	if err := iface.commonInterface.UDevConnectedPlug(spec, plug, plugAttrs, slot, slotAttrs); err != nil {
+		return err
+	}
+	if provision, _ := plug.Attrs["provision"].(bool); !provision {
+		return nil
+	}
+	old := "###CONNECTED_SECURITY_TAGS###"
+	for appName := range plug.Apps {
+		tag := udevSnapSecurityName(plug.Snap.Name(), appName)
+		spec.AddSnippet(strings.Replace(sgxEnclaveProvisionConnectedPlugUDev, old, tag, -1))
+	}
+	return nil
+}
+
+func init() {
+	registerIface(&sgxEnclaveInterface{commonInterface{
+		name:                  "sgx-enclave",
+		summary:               sgxEnclaveSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  sgxEnclaveBaseDeclarationSlots,
+		connectedPlugAppArmor: sgxEnclaveConnectedPlugAppArmor,
+		connectedPlugUDev:     sgxEnclaveConnectedPlugUDev,
+		reservedForOS:         true,
+		plugAttrs: interfaces.AttrSchema{{
+			Name:        "provision",
+			Type:        interfaces.AttrBool,
+			Description: "allow creating provisioning enclaves, which access the provisioning key of the platform",
+		}},
+	}})
+}
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type SgxEnclaveInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&SgxEnclaveInterfaceSuite{
+	iface: builtin.MustInterface("sgx-enclave"),
+})
+
+const sgxEnclaveConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [sgx-enclave]
+`
+
+const sgxEnclaveProvisionConsumerYaml = `name: consumer
+plugs:
+ sgx-enclave:
+  provision: true
+apps:
+ app:
+  plugs: [sgx-enclave]
+`
+
+const sgxEnclaveCoreYaml = `name: core
+type: os
+slots:
+  sgx-enclave:
+`
+
+func (s *SgxEnclaveInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, sgxEnclaveConsumerYaml, nil, "sgx-enclave")
+	s.slot = MockSlot(c, sgxEnclaveCoreYaml, nil, "sgx-enclave")
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "sgx-enclave")
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "sgx-enclave",
+		Interface: "sgx-enclave",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"sgx-enclave slots are reserved for the core snap")
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+	plug := MockPlug(c, sgxEnclaveProvisionConsumerYaml, nil, "sgx-enclave")
+	c.Assert(plug.Sanitize(s.iface), IsNil)
+
+	const mockPlugSnapInfoYaml = `name: consumer
+plugs:
+ sgx-enclave:
+  provision: "yes"
+apps:
+ app:
+  plugs: [sgx-enclave]
+`
+	plug = MockPlug(c, mockPlugSnapInfoYaml, nil, "sgx-enclave")
+	c.Assert(plug.Sanitize(s.iface), ErrorMatches, `sgx-enclave plug attribute "provision" must be a bool`)
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/dev/sgx_enclave rwm,\n")
+	c.Check(snippet, Not(testutil.Contains), "/dev/sgx_provision")
+
+	plug := MockPlug(c, sgxEnclaveProvisionConsumerYaml, nil, "sgx-enclave")
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, nil, s.slot, nil), IsNil)
+	snippet = spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/dev/sgx_enclave rwm,\n")
+	c.Check(snippet, testutil.Contains, "/dev/sgx_provision rw,\n")
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 1)
+	c.Check(spec.Snippets()[0], testutil.Contains, `SUBSYSTEM=="misc", KERNEL=="sgx_enclave", TAG+="snap_consumer_app"`)
+
+	plug := MockPlug(c, sgxEnclaveProvisionConsumerYaml, nil, "sgx-enclave")
+	spec = &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Check(spec.Snippets(), testutil.Contains, `SUBSYSTEM=="misc", KERNEL=="sgx_provision", TAG+="snap_consumer_app"`)
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows running Intel SGX enclaves`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "sgx-enclave")
+}
+
+func (s *SgxEnclaveInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const sshKeysObserveSummary = `allows reading the authorized SSH keys of managed users`
+
+const sshKeysObserveBaseDeclarationSlots = `
+  ssh-keys-observe:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+// The users created by snapd on Ubuntu Core (with "snap create-user" or
+// from system-user assertions) are kept in the extrausers database, with
+// their homes in /home. Only their authorized_keys are readable, not the
+// rest of their homes.
+const sshKeysObserveConnectedPlugAppArmor = `
+# Description: Can list the users managed by snapd and read their
+# authorized SSH keys, to audit who can log into the device.
+
+/var/lib/extrausers/passwd r,
+
+# authorized_keys are private to their users
+capability dac_read_search,
+
+/home/*/.ssh/ r,
+/home/*/.ssh/authorized_keys r,
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "ssh-keys-observe",
+		summary:               sshKeysObserveSummary,
+		implicitOnCore:        true,
+		baseDeclarationSlots:  sshKeysObserveBaseDeclarationSlots,
+		connectedPlugAppArmor: sshKeysObserveConnectedPlugAppArmor,
+		reservedForOS:         true,
+	})
+}
@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type SSHKeysObserveInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+const sshKeysObserveMockPlugSnapInfoYaml = `name: consumer
+version: 1.0
+apps:
+ app:
+  command: foo
+  plugs: [ssh-keys-observe]
+`
+
+var _ = Suite(&SSHKeysObserveInterfaceSuite{
+	iface: builtin.MustInterface("ssh-keys-observe"),
+})
+
+func (s *SSHKeysObserveInterfaceSuite) SetUpTest(c *C) {
+	s.slot = &interfaces.Slot{
+		SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+			Name:      "ssh-keys-observe",
+			Interface: "ssh-keys-observe",
+		},
+	}
+	plugSnap := snaptest.MockInfo(c, sshKeysObserveMockPlugSnapInfoYaml, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["ssh-keys-observe"]}
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "ssh-keys-observe")
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "ssh-keys-observe",
+		Interface: "ssh-keys-observe",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"ssh-keys-observe slots are reserved for the core snap")
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/var/lib/extrausers/passwd r,")
+	c.Check(snippet, testutil.Contains, "/home/*/.ssh/authorized_keys r,")
+	c.Check(snippet, Not(testutil.Contains), "/home/*/.ssh/** r,")
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, `allows reading the authorized SSH keys of managed users`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "ssh-keys-observe")
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(s.plug, s.slot), Equals, true)
+}
+
+func (s *SSHKeysObserveInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package builtin
+
+const systemBackupSummary = `allows reading /etc and /var for backing them up`
+
+const systemBackupBaseDeclarationSlots = `
+  system-backup:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const systemBackupConnectedPlugAppArmor = `
+# Description: Can read all of /etc and /var, either live through the host
+# file system or through the read-only view snapd prepares on request with
+# 'snapctl backup-view create', so that they can be backed up consistently.
+
+# Read files regardless of their permissions
+capability dac_read_search,
+
+/var/lib/snapd/hostfs/{etc,var}/ r,
+/var/lib/snapd/hostfs/{etc,var}/** r,
+
+# The snapshots of a view hold the whole subvolume of /etc and /var, often
+# the root file system, but only /etc and /var can be read from them. Each
+# snapshot is named after its subvolume: "root" for /, "var" for /var.
+/var/lib/snapd/backup-views/@{SNAP_NAME}/ r,
+/var/lib/snapd/backup-views/@{SNAP_NAME}/{etc,var}/ r,
+/var/lib/snapd/backup-views/@{SNAP_NAME}/{etc,var}/** r,
+/var/lib/snapd/backup-views/@{SNAP_NAME}/*/ r,
+/var/lib/snapd/backup-views/@{SNAP_NAME}/*/{etc,var}/ r,
+/var/lib/snapd/backup-views/@{SNAP_NAME}/*/{etc,var}/** r,
+
+/usr/bin/snapctl ixr,
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "system-backup",
+		summary:               systemBackupSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  systemBackupBaseDeclarationSlots,
+		connectedPlugAppArmor: systemBackupConnectedPlugAppArmor,
+		reservedForOS:         true,
+	})
+}
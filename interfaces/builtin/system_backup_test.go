@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type SystemBackupInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+const systemBackupMockPlugSnapInfoYaml = `name: consumer
+version: 1.0
+apps:
+ app:
+  command: foo
+  plugs: [system-backup]
+`
+
+var _ = Suite(&SystemBackupInterfaceSuite{
+	iface: builtin.MustInterface("system-backup"),
+})
+
+func (s *SystemBackupInterfaceSuite) SetUpTest(c *C) {
+	s.slot = &interfaces.Slot{
+		SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+			Name:      "system-backup",
+			Interface: "system-backup",
+		},
+	}
+	plugSnap := snaptest.MockInfo(c, systemBackupMockPlugSnapInfoYaml, nil)
+	s.plug = &interfaces.Plug{PlugInfo: plugSnap.Plugs["system-backup"]}
+}
+
+func (s *SystemBackupInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "system-backup")
+}
+
+func (s *SystemBackupInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "system-backup",
+		Interface: "system-backup",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"system-backup slots are reserved for the core snap")
+}
+
+func (s *SystemBackupInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *SystemBackupInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil)
+	c.Assert(err, IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/var/lib/snapd/hostfs/{etc,var}/** r,")
+	c.Check(snippet, testutil.Contains, "/var/lib/snapd/backup-views/@{SNAP_NAME}/*/{etc,var}/** r,")
+	// the rest of the snapshotted subvolume, such as /home, is not readable
+	c.Check(snippet, Not(testutil.Contains), "/var/lib/snapd/backup-views/@{SNAP_NAME}/** r,")
+	c.Check(snippet, Not(testutil.Contains), "/var/lib/snapd/backup-views/@{SNAP_NAME}/*/** r,")
+	c.Check(snippet, testutil.Contains, "/usr/bin/snapctl ixr,")
+}
+
+func (s *SystemBackupInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows reading /etc and /var for backing them up`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "system-backup")
+}
+
+func (s *SystemBackupInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(s.plug, s.slot), Equals, true)
+}
+
+func (s *SystemBackupInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,237 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
+const u2fDevicesSummary = `allows access to U2F devices`
+
+const u2fDevicesBaseDeclarationSlots = `
+  u2f-devices:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+type u2fDevice struct {
+	Name, VendorIDPattern, ProductIDPattern string
+}
+
+// u2fDevices is the list of known U2F devices. Plugs may narrow access to
+// some of them by listing their keys in the "devices" attribute.
+var u2fDevices = map[string]u2fDevice{
+	"yubico": {
+		Name:             "Yubico YubiKey",
+		VendorIDPattern:  "1050",
+		ProductIDPattern: "0113|0114|0115|0116|0120|0200|0402|0403|0406|0407|0410",
+	},
+	"happlink": {
+		Name:             "Happlink (formerly Plug-Up) Security KEY",
+		VendorIDPattern:  "2581",
+		ProductIDPattern: "f1d0",
+	},
+	"neowave": {
+		Name:             "Neowave Keydo and Keydo AES",
+		VendorIDPattern:  "1e0d",
+		ProductIDPattern: "f1d0|f1ae",
+	},
+	"hypersecu": {
+		Name:             "HyperSecu HyperFIDO",
+		VendorIDPattern:  "096e|2ccf",
+		ProductIDPattern: "0880",
+	},
+	"feitian": {
+		Name:             "Feitian ePass FIDO",
+		VendorIDPattern:  "096e",
+		ProductIDPattern: "0850|0852|0853|0854|0856|0858|085a|085b",
+	},
+	"jacarta": {
+		Name:             "JaCarta U2F",
+		VendorIDPattern:  "24dc",
+		ProductIDPattern: "0101",
+	},
+	"u2f-zero": {
+		Name:             "U2F Zero",
+		VendorIDPattern:  "10c4",
+		ProductIDPattern: "8acf",
+	},
+	"vasco": {
+		Name:             "VASCO SeccureClick",
+		VendorIDPattern:  "1a44",
+		ProductIDPattern: "00bb",
+	},
+	"bluink": {
+		Name:             "Bluink Key",
+		VendorIDPattern:  "2abe",
+		ProductIDPattern: "1002",
+	},
+	"thetis": {
+		Name:             "Thetis Key",
+		VendorIDPattern:  "1ea8",
+		ProductIDPattern: "f025",
+	},
+	"nitrokey": {
+		Name:             "Nitrokey FIDO U2F",
+		VendorIDPattern:  "20a0",
+		ProductIDPattern: "4287",
+	},
+	"google-titan": {
+		Name:             "Google Titan U2F",
+		VendorIDPattern:  "18d1",
+		ProductIDPattern: "5026",
+	},
+	"ledger": {
+		Name:             "Ledger Nano S / Nano X / Blue",
+		VendorIDPattern:  "2c97",
+		ProductIDPattern: "0000|0001|0004|0005|0015|1005|1015|4005|4015",
+	},
+}
+
+const u2fDevicesConnectedPlugAppArmor = `
+# Description: Allow write access to U2F hidraw devices.
+# Use a glob rule and rely on the device cgroup for mediation.
+/dev/hidraw* rw,
+
+# char 234-254 are used for dynamic assignment, which U2F devices are
+/run/udev/data/c23[4-9]:* r,
+/run/udev/data/c24[0-9]:* r,
+/run/udev/data/c25[0-4]:* r,
+
+# misc required accesses
+/run/udev/data/+power_supply:hid* r,
+/run/udev/data/c14:[0-9]* r,
+/sys/devices/**/usb*/**/report_descriptor r,
+`
+
+// u2fDevicesInterface gives access to the hidraw nodes of U2F security
+// keys. By default all the known devices are accessible; the optional
+// "devices" plug attribute limits that to the listed ones.
+type u2fDevicesInterface struct{}
+
+func (iface *u2fDevicesInterface) Name() string {
+	return "u2f-devices"
+}
+
+func (iface *u2fDevicesInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              u2fDevicesSummary,
+		ImplicitOnCore:       true,
+		ImplicitOnClassic:    true,
+		BaseDeclarationSlots: u2fDevicesBaseDeclarationSlots,
+	}
+}
+
+func (iface *u2fDevicesInterface) String() string {
+	return iface.Name()
+}
+
+func (iface *u2fDevicesInterface) SanitizeSlot(slot *interfaces.Slot) error {
+	return sanitizeSlotReservedForOS(iface, slot)
+}
+
+func (iface *u2fDevicesInterface) SanitizePlug(plug *interfaces.Plug) error {
+	_, err := u2fDevicesForPlug(plug.Attrs)
+	return err
+}
+
+// u2fDevicesForPlug returns the keys of the devices the plug with the
+// given attributes may access, in a stable order.
+func u2fDevicesForPlug(attrs map[string]interface{}) ([]string, error) {
+	var keys []string
+	attr, ok := attrs["devices"]
+	if !ok {
+		for key := range u2fDevices {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+	devices, ok := attr.([]interface{})
+	if !ok || len(devices) == 0 {
+		return nil, fmt.Errorf("u2f-devices devices attribute must be a non-empty list of device names")
+	}
+	seen := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		key, ok := device.(string)
+		if !ok {
+			return nil, fmt.Errorf("u2f-devices devices attribute must be a non-empty list of device names")
+		}
+		if _, ok := u2fDevices[key]; !ok {
+			return nil, fmt.Errorf("u2f-devices devices attribute contains unknown device %q (known devices: %s)", key, u2fDeviceKeys())
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (iface *u2fDevicesInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	spec.AddSnippet(u2fDevicesConnectedPlugAppArmor)
+	return nil
+}
+
+func (iface *u2fDevicesInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+	keys, err := u2fDevicesForPlug(plug.Attrs)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		device := u2fDevices[key]
+		for appName := range plug.Apps {
+			tag := udevSnapSecurityName(plug.Snap.Name(), appName)
+			spec.AddSnippet(fmt.Sprintf(`# %s
+SUBSYSTEM=="hidraw", KERNEL=="hidraw*", ATTRS{idVendor}=="%s", ATTRS{idProduct}=="%s", TAG+="%s"`,
+				device.Name, device.VendorIDPattern, device.ProductIDPattern, tag))
+		}
+	}
+	return nil
+}
+
+func (iface *u2fDevicesInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+func init() {
+	registerIface(&u2fDevicesInterface{})
+}
+
+// u2fDeviceKeys returns the keys of the known U2F devices, for messages.
+func u2fDeviceKeys() string {
+	var keys []string
+	for key := range u2fDevices {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type U2FDevicesInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+	plugs map[string]*interfaces.Plug
+}
+
+var _ = Suite(&U2FDevicesInterfaceSuite{
+	iface: builtin.MustInterface("u2f-devices"),
+})
+
+func (s *U2FDevicesInterfaceSuite) SetUpTest(c *C) {
+	s.slot = &interfaces.Slot{
+		SlotInfo: &snap.SlotInfo{
+			Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+			Name:      "u2f-devices",
+			Interface: "u2f-devices",
+		},
+	}
+	plugSnap := snaptest.MockInfo(c, `
+name: client-snap
+plugs:
+  any-key:
+    interface: u2f-devices
+  some-keys:
+    interface: u2f-devices
+    devices: [yubico, ledger, yubico]
+  unknown-key:
+    interface: u2f-devices
+    devices: [yubico, frobnicator]
+  empty-keys:
+    interface: u2f-devices
+    devices: []
+  bad-keys:
+    interface: u2f-devices
+    devices: yubico
+apps:
+  app:
+    command: foo
+    plugs: [any-key, some-keys]
+`, nil)
+	s.plugs = make(map[string]*interfaces.Plug)
+	for name, plugInfo := range plugSnap.Plugs {
+		s.plugs[name] = &interfaces.Plug{PlugInfo: plugInfo}
+	}
+	s.plug = s.plugs["any-key"]
+}
+
+func (s *U2FDevicesInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "u2f-devices")
+}
+
+func (s *U2FDevicesInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "u2f-devices",
+		Interface: "u2f-devices",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"u2f-devices slots are reserved for the core snap")
+}
+
+func (s *U2FDevicesInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Check(s.plugs["any-key"].Sanitize(s.iface), IsNil)
+	c.Check(s.plugs["some-keys"].Sanitize(s.iface), IsNil)
+}
+
+func (s *U2FDevicesInterfaceSuite) TestSanitizeBadPlugs(c *C) {
+	for name, err := range map[string]string{
+		"unknown-key": `u2f-devices devices attribute contains unknown device "frobnicator" \(known devices: .*\)`,
+		"empty-keys":  "u2f-devices devices attribute must be a non-empty list of device names",
+		"bad-keys":    "u2f-devices devices attribute must be a non-empty list of device names",
+	} {
+		c.Check(s.plugs[name].Sanitize(s.iface), ErrorMatches, err, Commentf(name))
+	}
+}
+
+func (s *U2FDevicesInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.app"})
+	c.Check(spec.SnippetForTag("snap.client-snap.app"), testutil.Contains, "/dev/hidraw* rw,\n")
+	c.Check(spec.SnippetForTag("snap.client-snap.app"), testutil.Contains, "/run/udev/data/c24[0-9]:* r,\n")
+}
+
+func (s *U2FDevicesInterfaceSuite) TestUDevSpecAllDevices(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 13)
+	c.Check(spec.Snippets(), testutil.Contains, `# Yubico YubiKey
+SUBSYSTEM=="hidraw", KERNEL=="hidraw*", ATTRS{idVendor}=="1050", ATTRS{idProduct}=="0113|0114|0115|0116|0120|0200|0402|0403|0406|0407|0410", TAG+="snap_client-snap_app"`)
+}
+
+func (s *U2FDevicesInterfaceSuite) TestUDevSpecSomeDevices(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plugs["some-keys"], nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), DeepEquals, []string{
+		`# Ledger Nano S / Nano X / Blue
+SUBSYSTEM=="hidraw", KERNEL=="hidraw*", ATTRS{idVendor}=="2c97", ATTRS{idProduct}=="0000|0001|0004|0005|0015|1005|1015|4005|4015", TAG+="snap_client-snap_app"`,
+		`# Yubico YubiKey
+SUBSYSTEM=="hidraw", KERNEL=="hidraw*", ATTRS{idVendor}=="1050", ATTRS{idProduct}=="0113|0114|0115|0116|0120|0200|0402|0403|0406|0407|0410", TAG+="snap_client-snap_app"`,
+	})
+}
+
+func (s *U2FDevicesInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, true)
+	c.Check(si.ImplicitOnClassic, Equals, true)
+	c.Check(si.Summary, Equals, "allows access to U2F devices")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "u2f-devices")
+}
+
+func (s *U2FDevicesInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *U2FDevicesInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const userdbQuerySummary = `allows resolving users and groups through systemd-userdb`
+
+const userdbQueryBaseDeclarationSlots = `
+  userdb-query:
+    allow-installation:
+      slot-snap-type:
+        - core
+`
+
+const userdbQueryConnectedPlugAppArmor = `
+# Description: Can look up the users and groups that systemd-userdb provides,
+# such as those of systemd-homed or of LDAP bridges, the way the default
+# policy allows reading /etc/passwd and /etc/group. The records are only
+# queried; registering a userdb service is not allowed.
+
+# The varlink sockets of the userdb services, queried with
+# io.systemd.UserDatabase.GetUserRecord, GetGroupRecord and GetMemberships
+/run/systemd/userdb/ r,
+/run/systemd/userdb/io.systemd.* rw,
+
+# The drop-in JSON user and group records, but not their privileged
+# sections, such as password hashes
+/{etc,run,usr/lib}/userdb/ r,
+/{etc,run,usr/lib}/userdb/*.{user,group,membership} r,
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "userdb-query",
+		summary:               userdbQuerySummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  userdbQueryBaseDeclarationSlots,
+		connectedPlugAppArmor: userdbQueryConnectedPlugAppArmor,
+		reservedForOS:         true,
+	})
+}
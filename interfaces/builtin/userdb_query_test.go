@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type UserdbQueryInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&UserdbQueryInterfaceSuite{
+	iface: builtin.MustInterface("userdb-query"),
+})
+
+const userdbQueryConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [userdb-query]
+`
+
+const userdbQueryCoreYaml = `name: core
+type: os
+slots:
+  userdb-query:
+`
+
+func (s *UserdbQueryInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, userdbQueryConsumerYaml, nil, "userdb-query")
+	s.slot = MockSlot(c, userdbQueryCoreYaml, nil, "userdb-query")
+}
+
+func (s *UserdbQueryInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "userdb-query")
+}
+
+func (s *UserdbQueryInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "userdb-query",
+		Interface: "userdb-query",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"userdb-query slots are reserved for the core snap")
+}
+
+func (s *UserdbQueryInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *UserdbQueryInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/run/systemd/userdb/io.systemd.* rw,\n")
+	c.Check(snippet, testutil.Contains, "/{etc,run,usr/lib}/userdb/*.{user,group,membership} r,\n")
+	c.Check(snippet, Not(testutil.Contains), "privileged}")
+}
+
+func (s *UserdbQueryInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows resolving users and groups through systemd-userdb`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "userdb-query")
+}
+
+func (s *UserdbQueryInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
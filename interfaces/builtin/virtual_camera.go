@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const virtualCameraSummary = `allows creating and feeding virtual cameras`
+
+const virtualCameraBaseDeclarationSlots = `
+  virtual-camera:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const virtualCameraConnectedPlugAppArmor = `
+# Description: Can create, configure and write frames to the virtual video
+# devices of the v4l2loopback module, which other applications see as
+# cameras. Access to the actual cameras is given by the camera interface:
+# while the rule below covers all the video devices, only the loopback
+# devices are tagged for the device cgroup of the snap.
+/dev/video[0-9]* rw,
+
+# Control device adding and removing loopback devices
+/dev/v4l2loopback rw,
+
+/run/udev/data/c81:[0-9]* r, # video4linux (/dev/video*, etc)
+/sys/class/video4linux/ r,
+/sys/devices/virtual/video4linux/video[0-9]*/** r,
+/sys/devices/virtual/misc/v4l2loopback/** r,
+/sys/module/v4l2loopback/parameters/* r,
+`
+
+// The loopback devices are the only video4linux devices without a parent
+// device.
+const virtualCameraConnectedPlugUDev = `
+SUBSYSTEM=="video4linux", KERNEL=="video[0-9]*", DEVPATH=="/devices/virtual/video4linux/*", TAG+="###CONNECTED_SECURITY_TAGS###"
+KERNEL=="v4l2loopback", TAG+="###CONNECTED_SECURITY_TAGS###"
+`
+
+var virtualCameraConnectedPlugKMod = []string{"v4l2loopback"}
+
+func init() {
+	registerIface(&commonInterface{
+		name:                     "virtual-camera",
+		summary:                  virtualCameraSummary,
+		implicitOnCore:           true,
+		implicitOnClassic:        true,
+		baseDeclarationSlots:     virtualCameraBaseDeclarationSlots,
+		connectedPlugAppArmor:    virtualCameraConnectedPlugAppArmor,
+		connectedPlugUDev:        virtualCameraConnectedPlugUDev,
+		connectedPlugKModModules: virtualCameraConnectedPlugKMod,
+		reservedForOS:            true,
+	})
+}
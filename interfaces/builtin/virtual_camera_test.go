@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type VirtualCameraInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&VirtualCameraInterfaceSuite{
+	iface: builtin.MustInterface("virtual-camera"),
+})
+
+const virtualCameraConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [virtual-camera]
+`
+
+const virtualCameraCoreYaml = `name: core
+type: os
+slots:
+  virtual-camera:
+`
+
+func (s *VirtualCameraInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, virtualCameraConsumerYaml, nil, "virtual-camera")
+	s.slot = MockSlot(c, virtualCameraCoreYaml, nil, "virtual-camera")
+}
+
+func (s *VirtualCameraInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "virtual-camera")
+}
+
+func (s *VirtualCameraInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "virtual-camera",
+		Interface: "virtual-camera",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"virtual-camera slots are reserved for the core snap")
+}
+
+func (s *VirtualCameraInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *VirtualCameraInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/dev/video[0-9]* rw,\n")
+	c.Check(snippet, testutil.Contains, "/dev/v4l2loopback rw,\n")
+}
+
+func (s *VirtualCameraInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 1)
+	snippet := spec.Snippets()[0]
+	c.Check(snippet, testutil.Contains, `SUBSYSTEM=="video4linux", KERNEL=="video[0-9]*", DEVPATH=="/devices/virtual/video4linux/*", TAG+="snap_consumer_app"`)
+	c.Check(snippet, testutil.Contains, `KERNEL=="v4l2loopback", TAG+="snap_consumer_app"`)
+	// the actual cameras are not tagged
+	c.Check(snippet, Not(testutil.Contains), `KERNEL=="video[0-9]*", TAG+=`)
+}
+
+func (s *VirtualCameraInterfaceSuite) TestKModSpec(c *C) {
+	spec := &kmod.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Check(spec.Modules(), DeepEquals, map[string]bool{"v4l2loopback": true})
+}
+
+func (s *VirtualCameraInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows creating and feeding virtual cameras`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "virtual-camera")
+}
+
+func (s *VirtualCameraInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const vsockSummary = `allows communicating with virtual machines and their hypervisor over vsock`
+
+const vsockBaseDeclarationSlots = `
+  vsock:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const vsockConnectedPlugAppArmor = `
+# Description: Can use AF_VSOCK sockets to talk to virtual machines from the
+# host, or to the hypervisor from a guest (eg, the agents of Firecracker or
+# cloud-hypervisor).
+
+network vsock stream,
+network vsock dgram,
+network vsock seqpacket,
+
+# IOCTL_VM_SOCKETS_GET_LOCAL_CID, to find out the CID to bind to
+/dev/vsock rw,
+`
+
+const vsockConnectedPlugSecComp = `
+# Description: Can use AF_VSOCK sockets to talk to virtual machines and their
+# hypervisor.
+bind
+socket AF_VSOCK
+`
+
+func init() {
+	registerIface(&commonInterface{
+		name:                  "vsock",
+		summary:               vsockSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  vsockBaseDeclarationSlots,
+		connectedPlugAppArmor: vsockConnectedPlugAppArmor,
+		connectedPlugSecComp:  vsockConnectedPlugSecComp,
+		reservedForOS:         true,
+	})
+}
@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+const zfsSupportSummary = `allows managing ZFS pools and datasets`
+
+const zfsSupportBaseDeclarationSlots = `
+  zfs-support:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const zfsSupportConnectedPlugAppArmor = `
+# Description: Can manage ZFS pools and datasets, as storage appliances do.
+# This gives privileged access to the block devices backing the pools and
+# to everything stored in them.
+
+# The zpool and zfs commands drive the kernel module with ioctls on /dev/zfs
+/dev/zfs rw,
+capability sys_admin,
+
+# Pools are made of block devices and provide zvols
+/dev/sd[a-z]* rw,
+/dev/vd[a-z]* rw,
+/dev/nvme[0-9]*n[0-9]* rw,
+/dev/disk/ r,
+/dev/disk/** r,
+/dev/zd[0-9]* rw,
+/dev/zvol/ r,
+/dev/zvol/** r,
+/run/udev/data/b[0-9]*:[0-9]* r,
+/sys/block/ r,
+/sys/devices/**/block/** r,
+
+# The state of the kernel modules, and their tunables
+/proc/spl/ r,
+/proc/spl/** r,
+/sys/module/{zfs,spl}/ r,
+/sys/module/{zfs,spl}/** r,
+/sys/module/{zfs,spl}/parameters/* w,
+
+# The cache of the imported pools and the identity of the host
+/etc/zfs/ r,
+/etc/zfs/** rwk,
+/etc/hostid r,
+
+# Mounting and unmounting datasets
+mount fstype=zfs,
+umount,
+/proc/self/mounts r,
+/proc/*/mountinfo r,
+`
+
+const zfsSupportConnectedPlugSecComp = `
+# Description: Can manage ZFS pools and datasets.
+
+mount
+umount
+umount2
+`
+
+const zfsSupportConnectedPlugUDev = `KERNEL=="zfs", TAG+="###CONNECTED_SECURITY_TAGS###"
+SUBSYSTEM=="block", KERNEL=="sd[a-z]*", TAG+="###CONNECTED_SECURITY_TAGS###"
+SUBSYSTEM=="block", KERNEL=="vd[a-z]*", TAG+="###CONNECTED_SECURITY_TAGS###"
+SUBSYSTEM=="block", KERNEL=="nvme[0-9]*n[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"
+SUBSYSTEM=="block", KERNEL=="zd[0-9]*", TAG+="###CONNECTED_SECURITY_TAGS###"`
+
+var zfsSupportConnectedPlugKmod = []string{`zfs`}
+
+func init() {
+	registerIface(&commonInterface{
+		name:                     "zfs-support",
+		summary:                  zfsSupportSummary,
+		implicitOnCore:           true,
+		implicitOnClassic:        true,
+		baseDeclarationSlots:     zfsSupportBaseDeclarationSlots,
+		connectedPlugAppArmor:    zfsSupportConnectedPlugAppArmor,
+		connectedPlugSecComp:     zfsSupportConnectedPlugSecComp,
+		connectedPlugUDev:        zfsSupportConnectedPlugUDev,
+		connectedPlugKModModules: zfsSupportConnectedPlugKmod,
+		reservedForOS:            true,
+	})
+}
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type ZfsSupportInterfaceSuite struct {
+	iface interfaces.Interface
+	slot  *interfaces.Slot
+	plug  *interfaces.Plug
+}
+
+var _ = Suite(&ZfsSupportInterfaceSuite{
+	iface: builtin.MustInterface("zfs-support"),
+})
+
+const zfsSupportConsumerYaml = `name: consumer
+apps:
+ app:
+  plugs: [zfs-support]
+`
+
+const zfsSupportCoreYaml = `name: core
+type: os
+slots:
+  zfs-support:
+`
+
+func (s *ZfsSupportInterfaceSuite) SetUpTest(c *C) {
+	s.plug = MockPlug(c, zfsSupportConsumerYaml, nil, "zfs-support")
+	s.slot = MockSlot(c, zfsSupportCoreYaml, nil, "zfs-support")
+}
+
+func (s *ZfsSupportInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "zfs-support")
+}
+
+func (s *ZfsSupportInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(s.slot.Sanitize(s.iface), IsNil)
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "zfs-support",
+		Interface: "zfs-support",
+	}}
+	c.Assert(slot.Sanitize(s.iface), ErrorMatches,
+		"zfs-support slots are reserved for the core snap")
+}
+
+func (s *ZfsSupportInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(s.plug.Sanitize(s.iface), IsNil)
+}
+
+func (s *ZfsSupportInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/dev/zfs rw,\n")
+	c.Check(snippet, testutil.Contains, "/sys/module/{zfs,spl}/parameters/* w,\n")
+	c.Check(snippet, testutil.Contains, "mount fstype=zfs,\n")
+}
+
+func (s *ZfsSupportInterfaceSuite) TestSecCompSpec(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "umount2\n")
+}
+
+func (s *ZfsSupportInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 1)
+	snippet := spec.Snippets()[0]
+	c.Check(snippet, testutil.Contains, `KERNEL=="zfs", TAG+="snap_consumer_app"`)
+	c.Check(snippet, testutil.Contains, `SUBSYSTEM=="block", KERNEL=="zd[0-9]*", TAG+="snap_consumer_app"`)
+}
+
+func (s *ZfsSupportInterfaceSuite) TestKModSpec(c *C) {
+	spec := &kmod.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, nil, s.slot, nil), IsNil)
+	c.Check(spec.Modules(), DeepEquals, map[string]bool{"zfs": true})
+}
+
+func (s *ZfsSupportInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, true)
+	c.Assert(si.ImplicitOnClassic, Equals, true)
+	c.Assert(si.Summary, Equals, `allows managing ZFS pools and datasets`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "zfs-support")
+}
+
+func (s *ZfsSupportInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
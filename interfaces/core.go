@@ -22,6 +22,7 @@ package interfaces
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/snapcore/snapd/snap"
@@ -44,6 +45,9 @@ func (plug *Plug) Sanitize(iface Interface) error {
 		return fmt.Errorf("cannot sanitize plug %q (interface %q) using interface %q",
 			plug.Ref(), plug.Interface, iface.Name())
 	}
+	if err := StaticInfoOf(iface).PlugAttrs.Validate(iface.Name(), "plug", plug.Attrs); err != nil {
+		return err
+	}
 	var err error
 	if iface, ok := iface.(PlugSanitizer); ok {
 		err = iface.SanitizePlug(plug)
@@ -79,6 +83,9 @@ func (slot *Slot) Sanitize(iface Interface) error {
 		return fmt.Errorf("cannot sanitize slot %q (interface %q) using interface %q",
 			slot.Ref(), slot.Interface, iface.Name())
 	}
+	if err := StaticInfoOf(iface).SlotAttrs.Validate(iface.Name(), "slot", slot.Attrs); err != nil {
+		return err
+	}
 	var err error
 	if iface, ok := iface.(SlotSanitizer); ok {
 		err = iface.SanitizeSlot(slot)
@@ -110,6 +117,14 @@ type Info struct {
 	DocURL  string
 	Plugs   []*snap.PlugInfo
 	Slots   []*snap.SlotInfo
+
+	PlugAttrs       AttrSchema
+	SlotAttrs       AttrSchema
+	ConnectionAttrs AttrSchema
+
+	GreedyPlugs bool
+	Deprecated  bool
+	ReplacedBy  string
 }
 
 // ConnRef holds information about plug and slot reference that form a particular connection.
@@ -121,6 +136,9 @@ type ConnRef struct {
 type Connection struct {
 	plugInfo *snap.PlugInfo
 	slotInfo *snap.SlotInfo
+	// slotAttrs are the attributes given when connecting, overriding
+	// those of the slot.
+	slotAttrs map[string]interface{}
 }
 
 func (conn *Connection) Interface() string {
@@ -193,6 +211,90 @@ type StaticInfo struct {
 	BaseDeclarationPlugs string
 	// BaseDeclarationSlots defines an optional extension to the base-declaration assertion relevant for this interface.
 	BaseDeclarationSlots string
+
+	// PlugAttrs describes the attributes of plugs, checked when they are sanitized.
+	PlugAttrs AttrSchema `json:"plug-attrs,omitempty"`
+	// SlotAttrs describes the attributes of slots, checked when they are sanitized.
+	SlotAttrs AttrSchema `json:"slot-attrs,omitempty"`
+	// ConnectionAttrs describes the attributes that can be given when
+	// connecting, overriding those of the slot for that connection only.
+	// Interfaces describing none don't support overrides.
+	ConnectionAttrs AttrSchema `json:"connection-attrs,omitempty"`
+
+	// GreedyPlugs tells that a plug can sensibly be connected to several
+	// slots at once, in which case it is auto-connected to all the
+	// candidate slots rather than to none when there are several.
+	GreedyPlugs bool `json:"greedy-plugs,omitempty"`
+
+	// Deprecated marks interfaces only kept for the snaps still using
+	// them, ReplacedBy naming the interface to use instead, if any.
+	Deprecated bool   `json:"deprecated,omitempty"`
+	ReplacedBy string `json:"replaced-by,omitempty"`
+}
+
+// DeprecationNotice returns a message telling that the interface is
+// deprecated, and what to use instead, or "" if it is not deprecated.
+func DeprecationNotice(iface Interface) string {
+	si := StaticInfoOf(iface)
+	if !si.Deprecated {
+		return ""
+	}
+	if si.ReplacedBy != "" {
+		return fmt.Sprintf("interface %q is deprecated, use %q instead", iface.Name(), si.ReplacedBy)
+	}
+	return fmt.Sprintf("interface %q is deprecated", iface.Name())
+}
+
+// ConnectionAttrsValidator can be implemented by interfaces supporting
+// connection attributes that have more to check than their schema, such
+// as whether they narrow down what the slot grants.
+type ConnectionAttrsValidator interface {
+	ValidateConnectionAttrs(plug *Plug, slot *Slot, attrs map[string]interface{}) error
+}
+
+// ValidateConnectionAttrs checks the attributes given when connecting the
+// plug to the slot with the given interface.
+func ValidateConnectionAttrs(iface Interface, plug *Plug, slot *Slot, attrs map[string]interface{}) error {
+	if len(attrs) == 0 {
+		return nil
+	}
+	schema := StaticInfoOf(iface).ConnectionAttrs
+	if len(schema) == 0 {
+		return fmt.Errorf("%s connections do not support attributes", iface.Name())
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !schema.has(name) {
+			return fmt.Errorf("%s connections do not support attribute %q", iface.Name(), name)
+		}
+	}
+	if err := schema.Validate(iface.Name(), "connection", attrs); err != nil {
+		return err
+	}
+	if iface, ok := iface.(ConnectionAttrsValidator); ok {
+		return iface.ValidateConnectionAttrs(plug, slot, attrs)
+	}
+	return nil
+}
+
+// ConnectionChecker can be implemented by interfaces that need to look at
+// the system before letting a plug be connected to a slot, such as to
+// find out whether the device of the slot is already in use.
+type ConnectionChecker interface {
+	CheckConnection(plug *Plug, slot *Slot) error
+}
+
+// CheckConnection checks whether the plug can be connected to the slot
+// with the given interface right now.
+func CheckConnection(iface Interface, plug *Plug, slot *Slot) error {
+	if iface, ok := iface.(ConnectionChecker); ok {
+		return iface.CheckConnection(plug, slot)
+	}
+	return nil
 }
 
 // StaticInfoOf returns the static-info of the given interface.
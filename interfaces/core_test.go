@@ -220,3 +220,28 @@ slots:
 		InterfaceName: "other",
 	}), ErrorMatches, `cannot sanitize slot "snap:slot" \(interface "iface"\) using interface "other"`)
 }
+
+func (s *CoreSuite) TestValidateConnectionAttrs(c *C) {
+	iface := &ifacetest.TestInterface{InterfaceName: "iface"}
+	c.Check(ValidateConnectionAttrs(iface, nil, nil, nil), IsNil)
+	c.Check(ValidateConnectionAttrs(iface, nil, nil, map[string]interface{}{"path": "/dev/foo"}), ErrorMatches,
+		"iface connections do not support attributes")
+
+	iface.InterfaceStaticInfo.ConnectionAttrs = AttrSchema{{Name: "path", Type: AttrString, Path: true}}
+	c.Check(ValidateConnectionAttrs(iface, nil, nil, map[string]interface{}{"path": "/dev/foo"}), IsNil)
+	c.Check(ValidateConnectionAttrs(iface, nil, nil, map[string]interface{}{"path": "/dev/foo", "other": 1}), ErrorMatches,
+		`iface connections do not support attribute "other"`)
+	c.Check(ValidateConnectionAttrs(iface, nil, nil, map[string]interface{}{"path": 42}), ErrorMatches,
+		`iface connection attribute "path" .*`)
+}
+
+func (s *CoreSuite) TestDeprecationNotice(c *C) {
+	iface := &ifacetest.TestInterface{InterfaceName: "iface"}
+	c.Check(DeprecationNotice(iface), Equals, "")
+
+	iface.InterfaceStaticInfo.Deprecated = true
+	c.Check(DeprecationNotice(iface), Equals, `interface "iface" is deprecated`)
+
+	iface.InterfaceStaticInfo.ReplacedBy = "other"
+	c.Check(DeprecationNotice(iface), Equals, `interface "iface" is deprecated, use "other" instead`)
+}
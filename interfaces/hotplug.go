@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HotplugDeviceInfo carries the udev properties of a device that was
+// plugged in or removed.
+type HotplugDeviceInfo struct {
+	properties map[string]string
+}
+
+// NewHotplugDeviceInfo returns the device info for the given udev
+// properties, which must at least have the path of the device (DEVPATH)
+// and its subsystem (SUBSYSTEM).
+func NewHotplugDeviceInfo(properties map[string]string) (*HotplugDeviceInfo, error) {
+	for _, prop := range []string{"DEVPATH", "SUBSYSTEM"} {
+		if properties[prop] == "" {
+			return nil, fmt.Errorf("cannot create hotplug device info: missing %s property", prop)
+		}
+	}
+	return &HotplugDeviceInfo{properties: properties}, nil
+}
+
+// DevicePath returns the path of the device in sysfs, relative to /sys,
+// as found in the DEVPATH property.
+func (h *HotplugDeviceInfo) DevicePath() string {
+	return h.properties["DEVPATH"]
+}
+
+// DeviceName returns the path of the device node, such as /dev/ttyUSB0,
+// as found in the DEVNAME property. Not all devices have one.
+func (h *HotplugDeviceInfo) DeviceName() string {
+	return h.properties["DEVNAME"]
+}
+
+// Subsystem returns the kernel subsystem of the device, such as tty.
+func (h *HotplugDeviceInfo) Subsystem() string {
+	return h.properties["SUBSYSTEM"]
+}
+
+// Property returns the value of the given udev property.
+func (h *HotplugDeviceInfo) Property(name string) (string, bool) {
+	value, ok := h.properties[name]
+	return value, ok
+}
+
+// String returns a description of the device for messages.
+func (h *HotplugDeviceInfo) String() string {
+	if name := h.DeviceName(); name != "" {
+		return name
+	}
+	return filepath.Join("/sys", h.DevicePath())
+}
+
+// HotplugSlotSpec describes the slot an interface wants to be created on
+// the core snap for a plugged in device.
+type HotplugSlotSpec struct {
+	// Name is the preferred name of the slot; it is made valid and
+	// unique before being used.
+	Name  string
+	Label string
+	Attrs map[string]interface{}
+}
+
+// HotplugDeviceHandler can be implemented by interfaces that create slots
+// for devices as they are plugged in, instead of relying on static slots
+// in the gadget snap.
+type HotplugDeviceHandler interface {
+	// HotplugDeviceDetected returns the slot to create for the device,
+	// or nil if the interface has no interest in it.
+	HotplugDeviceDetected(devinfo *HotplugDeviceInfo) (*HotplugSlotSpec, error)
+}
+
+// HotplugKeyHandler can be implemented by hotplug capable interfaces
+// that know better than the default how to tell their devices apart.
+type HotplugKeyHandler interface {
+	// HotplugKey returns a key identifying the device, stable across
+	// unplugging and plugging it in again.
+	HotplugKey(devinfo *HotplugDeviceInfo) (string, error)
+}
+
+// HotplugKey returns the key identifying the given device for the given
+// interface, derived by the interface itself if it implements
+// HotplugKeyHandler, or from the udev properties of the device otherwise.
+func HotplugKey(iface Interface, devinfo *HotplugDeviceInfo) (string, error) {
+	if handler, ok := iface.(HotplugKeyHandler); ok {
+		key, err := handler.HotplugKey(devinfo)
+		if err != nil {
+			return "", err
+		}
+		if key != "" {
+			return key, nil
+		}
+	}
+	return DefaultHotplugKey(devinfo), nil
+}
+
+// DefaultHotplugKey derives the key of the device from the properties
+// the usb_id and path_id udev builtins set: the vendor and model of the
+// device with either its serial number or, lacking one, the port it is
+// plugged in. Devices without those are identified by their path in
+// sysfs.
+func DefaultHotplugKey(devinfo *HotplugDeviceInfo) string {
+	var props []string
+	if _, ok := devinfo.Property("ID_SERIAL_SHORT"); ok {
+		props = []string{"ID_VENDOR_ID", "ID_MODEL_ID", "ID_SERIAL_SHORT", "ID_USB_INTERFACE_NUM"}
+	} else if _, ok := devinfo.Property("ID_PATH"); ok {
+		props = []string{"ID_VENDOR_ID", "ID_MODEL_ID", "ID_PATH"}
+	} else {
+		props = []string{"DEVPATH"}
+	}
+	values := make([]string, 0, len(props)+1)
+	values = append(values, devinfo.Subsystem())
+	for _, prop := range props {
+		value, _ := devinfo.Property(prop)
+		values = append(values, prop+"="+value)
+	}
+	return HashHotplugKey(values...)
+}
+
+// HashHotplugKey turns the given values identifying a device into a key.
+func HashHotplugKey(values ...string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(values, "\x00"))))
+}
+
+var invalidSlotNameChars = regexp.MustCompile("[^a-z0-9]+")
+
+// SuggestHotplugSlotName turns the given string, typically the model of
+// a device, into something usable as a slot name, or returns the
+// fallback if nothing is left of it.
+func SuggestHotplugSlotName(name, fallback string) string {
+	name = invalidSlotNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.TrimLeft(strings.Trim(name, "-"), "0123456789-")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+)
+
+type HotplugSuite struct{}
+
+var _ = Suite(&HotplugSuite{})
+
+func (s *HotplugSuite) TestNewHotplugDeviceInfo(c *C) {
+	devinfo, err := NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0",
+		"DEVNAME":   "/dev/ttyUSB0",
+		"SUBSYSTEM": "tty",
+		"ID_MODEL":  "FT232R_USB_UART",
+	})
+	c.Assert(err, IsNil)
+	c.Check(devinfo.DevicePath(), Equals, "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0")
+	c.Check(devinfo.DeviceName(), Equals, "/dev/ttyUSB0")
+	c.Check(devinfo.Subsystem(), Equals, "tty")
+	c.Check(devinfo.String(), Equals, "/dev/ttyUSB0")
+	model, ok := devinfo.Property("ID_MODEL")
+	c.Check(ok, Equals, true)
+	c.Check(model, Equals, "FT232R_USB_UART")
+	_, ok = devinfo.Property("ID_SERIAL")
+	c.Check(ok, Equals, false)
+
+	devinfo, err = NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/virtual/net/can0",
+		"SUBSYSTEM": "net",
+	})
+	c.Assert(err, IsNil)
+	c.Check(devinfo.String(), Equals, "/sys/devices/virtual/net/can0")
+
+	_, err = NewHotplugDeviceInfo(map[string]string{"SUBSYSTEM": "tty"})
+	c.Check(err, ErrorMatches, "cannot create hotplug device info: missing DEVPATH property")
+	_, err = NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/devices/foo"})
+	c.Check(err, ErrorMatches, "cannot create hotplug device info: missing SUBSYSTEM property")
+}
+
+func (s *HotplugSuite) TestDefaultHotplugKey(c *C) {
+	key := func(props map[string]string) string {
+		props["SUBSYSTEM"] = "tty"
+		devinfo, err := NewHotplugDeviceInfo(props)
+		c.Assert(err, IsNil)
+		return DefaultHotplugKey(devinfo)
+	}
+	usb := func(port, serial string) map[string]string {
+		props := map[string]string{
+			"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb1/" + port + "/tty/ttyUSB0",
+			"ID_VENDOR_ID": "0403",
+			"ID_MODEL_ID":  "6001",
+			"ID_PATH":      "pci-0000:00:14.0-usb-0:" + port,
+		}
+		if serial != "" {
+			props["ID_SERIAL_SHORT"] = serial
+		}
+		return props
+	}
+
+	// devices with a serial number are the same whichever port they use
+	c.Check(key(usb("1-1", "A123")), Equals, key(usb("1-2", "A123")))
+	c.Check(key(usb("1-1", "A123")), Not(Equals), key(usb("1-1", "B456")))
+	// others are told apart by their port
+	c.Check(key(usb("1-1", "")), Equals, key(usb("1-1", "")))
+	c.Check(key(usb("1-1", "")), Not(Equals), key(usb("1-2", "")))
+	// and lacking that by their path
+	c.Check(key(map[string]string{"DEVPATH": "/devices/foo"}), Not(Equals), key(map[string]string{"DEVPATH": "/devices/bar"}))
+	c.Check(key(usb("1-1", "A123")), HasLen, 64)
+}
+
+type keyedInterface struct {
+	ifacetest.TestInterface
+	key string
+	err error
+}
+
+func (iface *keyedInterface) HotplugKey(devinfo *HotplugDeviceInfo) (string, error) {
+	return iface.key, iface.err
+}
+
+func (s *HotplugSuite) TestHotplugKey(c *C) {
+	devinfo, err := NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/devices/foo", "SUBSYSTEM": "tty"})
+	c.Assert(err, IsNil)
+
+	key, err := HotplugKey(&ifacetest.TestInterface{InterfaceName: "test"}, devinfo)
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, DefaultHotplugKey(devinfo))
+
+	key, err = HotplugKey(&keyedInterface{key: "some-key"}, devinfo)
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, "some-key")
+
+	// interfaces may fall back to the default key
+	key, err = HotplugKey(&keyedInterface{}, devinfo)
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, DefaultHotplugKey(devinfo))
+
+	_, err = HotplugKey(&keyedInterface{err: fmt.Errorf("boom")}, devinfo)
+	c.Check(err, ErrorMatches, "boom")
+}
+
+func (s *HotplugSuite) TestSuggestHotplugSlotName(c *C) {
+	for _, t := range []struct{ name, suggested string }{
+		{"FT232R_USB_UART", "ft232r-usb-uart"},
+		{"HD Pro Webcam C920", "hd-pro-webcam-c920"},
+		{"--Some  Device--", "some-device"},
+		{"3D Camera", "d-camera"},
+		{"", "fallback"},
+		{"1234", "fallback"},
+		{"äöü", "fallback"},
+	} {
+		name := SuggestHotplugSlotName(t.name, "fallback")
+		c.Check(name, Equals, t.suggested, Commentf(t.name))
+		c.Check(ValidateName(name), IsNil)
+	}
+}
@@ -67,7 +67,7 @@ func (s *TestInterfaceSuite) TestName(c *C) {
 }
 
 func (s *TestInterfaceSuite) TestStaticInfo(c *C) {
-	c.Assert(interfaces.StaticInfoOf(s.iface), Equals, interfaces.StaticInfo{
+	c.Assert(interfaces.StaticInfoOf(s.iface), DeepEquals, interfaces.StaticInfo{
 		Summary: "summary",
 	})
 }
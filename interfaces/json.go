@@ -31,6 +31,8 @@ type plugJSON struct {
 	Attrs       map[string]interface{} `json:"attrs,omitempty"`
 	Apps        []string               `json:"apps,omitempty"`
 	Label       string                 `json:"label,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Category    string                 `json:"category,omitempty"`
 	Connections []SlotRef              `json:"connections,omitempty"`
 }
 
@@ -47,6 +49,8 @@ func (plug *Plug) MarshalJSON() ([]byte, error) {
 		Attrs:       plug.Attrs,
 		Apps:        names,
 		Label:       plug.Label,
+		Labels:      plug.Labels,
+		Category:    plug.Category,
 		Connections: plug.Connections,
 	})
 }
@@ -59,6 +63,8 @@ type slotJSON struct {
 	Attrs       map[string]interface{} `json:"attrs,omitempty"`
 	Apps        []string               `json:"apps,omitempty"`
 	Label       string                 `json:"label,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty"`
+	Category    string                 `json:"category,omitempty"`
 	Connections []PlugRef              `json:"connections,omitempty"`
 }
 
@@ -75,6 +81,8 @@ func (slot *Slot) MarshalJSON() ([]byte, error) {
 		Attrs:       slot.Attrs,
 		Apps:        names,
 		Label:       slot.Label,
+		Labels:      slot.Labels,
+		Category:    slot.Category,
 		Connections: slot.Connections,
 	})
 }
@@ -86,6 +94,14 @@ type interfaceInfoJSON struct {
 	DocURL  string      `json:"doc-url,omitempty"`
 	Plugs   []*plugJSON `json:"plugs,omitempty"`
 	Slots   []*slotJSON `json:"slots,omitempty"`
+
+	PlugAttrs       AttrSchema `json:"plug-attrs,omitempty"`
+	SlotAttrs       AttrSchema `json:"slot-attrs,omitempty"`
+	ConnectionAttrs AttrSchema `json:"connection-attrs,omitempty"`
+
+	GreedyPlugs bool   `json:"greedy-plugs,omitempty"`
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	ReplacedBy  string `json:"replaced-by,omitempty"`
 }
 
 // MarshalJSON returns the JSON encoding of Info.
@@ -93,19 +109,23 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 	plugs := make([]*plugJSON, 0, len(info.Plugs))
 	for _, plug := range info.Plugs {
 		plugs = append(plugs, &plugJSON{
-			Snap:  plug.Snap.Name(),
-			Name:  plug.Name,
-			Attrs: plug.Attrs,
-			Label: plug.Label,
+			Snap:     plug.Snap.Name(),
+			Name:     plug.Name,
+			Attrs:    plug.Attrs,
+			Label:    plug.Label,
+			Labels:   plug.Labels,
+			Category: plug.Category,
 		})
 	}
 	slots := make([]*slotJSON, 0, len(info.Slots))
 	for _, slot := range info.Slots {
 		slots = append(slots, &slotJSON{
-			Snap:  slot.Snap.Name(),
-			Name:  slot.Name,
-			Attrs: slot.Attrs,
-			Label: slot.Label,
+			Snap:     slot.Snap.Name(),
+			Name:     slot.Name,
+			Attrs:    slot.Attrs,
+			Label:    slot.Label,
+			Labels:   slot.Labels,
+			Category: slot.Category,
 		})
 	}
 	return json.Marshal(&interfaceInfoJSON{
@@ -114,5 +134,13 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 		DocURL:  info.DocURL,
 		Plugs:   plugs,
 		Slots:   slots,
+
+		PlugAttrs:       info.PlugAttrs,
+		SlotAttrs:       info.SlotAttrs,
+		ConnectionAttrs: info.ConnectionAttrs,
+
+		GreedyPlugs: info.GreedyPlugs,
+		Deprecated:  info.Deprecated,
+		ReplacedBy:  info.ReplacedBy,
 	})
 }
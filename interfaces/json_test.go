@@ -45,7 +45,9 @@ var _ = Suite(&JSONSuite{
 					Name: "app-name",
 				},
 			},
-			Label: "label",
+			Label:    "label",
+			Labels:   map[string]string{"fr": "étiquette"},
+			Category: "hardware",
 		},
 		Connections: []SlotRef{{
 			Snap: "other-snap-name",
@@ -85,6 +87,8 @@ func (s *JSONSuite) TestPlugMarshalJSON(c *C) {
 		"attrs":     map[string]interface{}{"key": "value"},
 		"apps":      []interface{}{"app-name"},
 		"label":     "label",
+		"labels":    map[string]interface{}{"fr": "étiquette"},
+		"category":  "hardware",
 		"connections": []interface{}{
 			map[string]interface{}{"snap": "other-snap-name", "slot": "slot-name"},
 		},
@@ -117,6 +121,9 @@ func (s *JSONSuite) TestInfoMarshalJSON(c *C) {
 		DocURL:  "http://example.org/",
 		Plugs:   []*snap.PlugInfo{s.plug.PlugInfo},
 		Slots:   []*snap.SlotInfo{s.slot.SlotInfo},
+
+		Deprecated: true,
+		ReplacedBy: "other-iface",
 	}
 	data, err := json.Marshal(ifaceInfo)
 	c.Assert(err, IsNil)
@@ -129,10 +136,12 @@ func (s *JSONSuite) TestInfoMarshalJSON(c *C) {
 		"doc-url": "http://example.org/",
 		"plugs": []interface{}{
 			map[string]interface{}{
-				"snap":  "snap-name",
-				"plug":  "plug-name",
-				"attrs": map[string]interface{}{"key": "value"},
-				"label": "label",
+				"snap":     "snap-name",
+				"plug":     "plug-name",
+				"attrs":    map[string]interface{}{"key": "value"},
+				"label":    "label",
+				"labels":   map[string]interface{}{"fr": "étiquette"},
+				"category": "hardware",
 			},
 		},
 		"slots": []interface{}{
@@ -143,5 +152,37 @@ func (s *JSONSuite) TestInfoMarshalJSON(c *C) {
 				"label": "label",
 			},
 		},
+		"deprecated":  true,
+		"replaced-by": "other-iface",
+	})
+}
+
+func (s *JSONSuite) TestInfoMarshalJSONWithAttrs(c *C) {
+	ifaceInfo := &Info{
+		Name: "iface",
+		SlotAttrs: AttrSchema{{
+			Name:                "path",
+			Type:                AttrString,
+			Required:            true,
+			Path:                true,
+			PatternsDescription: "a serial device",
+		}},
+	}
+	data, err := json.Marshal(ifaceInfo)
+	c.Assert(err, IsNil)
+	var repr map[string]interface{}
+	err = json.Unmarshal(data, &repr)
+	c.Assert(err, IsNil)
+	c.Check(repr, DeepEquals, map[string]interface{}{
+		"name": "iface",
+		"slot-attrs": []interface{}{
+			map[string]interface{}{
+				"name":     "path",
+				"type":     "string",
+				"required": true,
+				"path":     true,
+				"patterns": "a serial device",
+			},
+		},
 	})
 }
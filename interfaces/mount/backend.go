@@ -33,6 +33,7 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
@@ -66,6 +67,9 @@ func (b *Backend) Setup(snapInfo *snap.Info, confinement interfaces.ConfinementO
 	if _, _, err := osutil.EnsureDirState(dir, glob, content); err != nil {
 		return fmt.Errorf("cannot synchronize mount configuration files for snap %q: %s", snapName, err)
 	}
+	if err := ensureGeneratedFiles(snapName, spec.(*Specification).files); err != nil {
+		return err
+	}
 	if err := UpdateSnapNamespace(snapName); err != nil {
 		return fmt.Errorf("cannot update mount namespace of snap %q: %s", snapName, err)
 	}
@@ -81,6 +85,45 @@ func (b *Backend) Remove(snapName string) error {
 	if err != nil {
 		return fmt.Errorf("cannot synchronize mount configuration files for snap %q: %s", snapName, err)
 	}
+	return ensureGeneratedFiles(snapName, nil)
+}
+
+// generatedFilesDir returns the directory holding the files generated
+// for the given snap.
+func generatedFilesDir(snapName string) string {
+	return filepath.Join(dirs.SnapMountPolicyDir, fmt.Sprintf("snap.%s.files", snapName))
+}
+
+// GeneratedFilePath returns the path of the file with the given name
+// generated for the given snap, to bind mount in its mount namespace.
+func GeneratedFilePath(snapName, name string) string {
+	return filepath.Join(generatedFilesDir(snapName), name)
+}
+
+// ensureGeneratedFiles synchronizes the files generated for the given
+// snap with those of its specification, removing their directory when
+// there are none.
+func ensureGeneratedFiles(snapName string, files map[string][]byte) error {
+	dir := generatedFilesDir(snapName)
+	if len(files) == 0 {
+		if _, _, err := osutil.EnsureDirState(dir, "*", nil); err != nil {
+			return fmt.Errorf("cannot remove generated files of snap %q: %s", snapName, err)
+		}
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove generated files of snap %q: %s", snapName, err)
+		}
+		return nil
+	}
+	content := make(map[string]*osutil.FileState, len(files))
+	for name, data := range files {
+		content[name] = &osutil.FileState{Content: data, Mode: 0644}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory for generated files %q: %s", dir, err)
+	}
+	if _, _, err := osutil.EnsureDirState(dir, "*", content); err != nil {
+		return fmt.Errorf("cannot synchronize generated files of snap %q: %s", snapName, err)
+	}
 	return nil
 }
 
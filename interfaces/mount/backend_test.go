@@ -161,6 +161,26 @@ func (s *backendSuite) TestSetupSetsupSimple(c *C) {
 	}
 }
 
+func (s *backendSuite) TestSetupGeneratedFiles(c *C) {
+	path := mount.GeneratedFilePath("snap-name", "asound.conf")
+	c.Check(path, Equals, filepath.Join(dirs.SnapMountPolicyDir, "snap.snap-name.files/asound.conf"))
+	s.Iface.MountPermanentPlugCallback = func(spec *mount.Specification, plug *interfaces.Plug) error {
+		if err := spec.AddFile("asound.conf", []byte("pcm.!default hw:1\n")); err != nil {
+			return err
+		}
+		return spec.AddMountEntry(mount.Entry{Name: path, Dir: "/etc/asound.conf", Options: []string{"bind", "ro"}})
+	}
+
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, mockSnapYaml, 0)
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "pcm.!default hw:1\n")
+
+	// the files go away with the snap
+	s.RemoveSnap(c, snapInfo)
+	c.Check(osutil.FileExists(filepath.Dir(path)), Equals, false)
+}
+
 func (s *backendSuite) TestSetupSetsupWithoutDir(c *C) {
 	s.Iface.MountPermanentPlugCallback = func(spec *mount.Specification, plug *interfaces.Plug) error {
 		return spec.AddMountEntry(mount.Entry{})
@@ -20,6 +20,8 @@
 package mount
 
 import (
+	"fmt"
+
 	"github.com/snapcore/snapd/interfaces"
 )
 
@@ -30,6 +32,7 @@ import (
 // setup process.
 type Specification struct {
 	mountEntries []Entry
+	files        map[string][]byte
 }
 
 // AddMountEntry adds a new mount entry.
@@ -45,6 +48,28 @@ func (spec *Specification) MountEntries() []Entry {
 	return result
 }
 
+// AddFile adds a file generated for the snap, to be bind mounted from
+// the path given by GeneratedFilePath over a file of its mount namespace.
+func (spec *Specification) AddFile(name string, content []byte) error {
+	if spec.files == nil {
+		spec.files = make(map[string][]byte)
+	}
+	if _, ok := spec.files[name]; ok {
+		return fmt.Errorf("cannot generate file %q twice", name)
+	}
+	spec.files[name] = content
+	return nil
+}
+
+// Files returns a copy of the added files, by name.
+func (spec *Specification) Files() map[string][]byte {
+	result := make(map[string][]byte, len(spec.files))
+	for name, content := range spec.files {
+		result[name] = content
+	}
+	return result
+}
+
 // Implementation of methods required by interfaces.Specification
 
 // AddConnectedPlug records mount-specific side-effects of having a connected plug.
@@ -80,6 +80,13 @@ func (s *specSuite) TestSmoke(c *C) {
 	c.Assert(s.spec.MountEntries(), DeepEquals, []mount.Entry{ent0, ent1})
 }
 
+func (s *specSuite) TestAddFile(c *C) {
+	c.Assert(s.spec.Files(), HasLen, 0)
+	c.Assert(s.spec.AddFile("asound.conf", []byte("content")), IsNil)
+	c.Assert(s.spec.Files(), DeepEquals, map[string][]byte{"asound.conf": []byte("content")})
+	c.Assert(s.spec.AddFile("asound.conf", []byte("other")), ErrorMatches, `cannot generate file "asound.conf" twice`)
+}
+
 // The mount.Specification can be used through the interfaces.Specification interface
 func (s *specSuite) TestSpecificationIface(c *C) {
 	var r interfaces.Specification = s.spec
@@ -151,12 +151,14 @@ func (s *baseDeclSuite) TestAutoConnection(c *C) {
 		"online-accounts-service": true,
 		"opengl":                  true,
 		"optical-drive":           true,
+		"pipewire":                true,
 		"pulseaudio":              true,
 		"screen-inhibit-control":  true,
 		"ubuntu-download-manager": true,
 		"unity7":                  true,
 		"unity8":                  true,
 		"upower-observe":          true,
+		"userdb-query":            true,
 		"wayland":                 true,
 		"x11":                     true,
 	}
@@ -211,6 +213,22 @@ func (s *baseDeclSuite) TestInterimAutoConnectionHome(c *C) {
 	c.Check(err, ErrorMatches, `auto-connection denied by slot rule of interface \"home\"`)
 }
 
+func (s *baseDeclSuite) TestAutoConnectionPipewireCapture(c *C) {
+	cand := s.connectCand(c, "pipewire", "", "")
+	c.Check(cand.CheckAutoConnect(), IsNil)
+
+	for _, attr := range []string{"audio-capture", "screen-capture", "video-capture"} {
+		plugYaml := fmt.Sprintf(`name: plug-snap
+plugs:
+  pipewire:
+    %s: true
+`, attr)
+		cand := s.connectCand(c, "pipewire", "", plugYaml)
+		err := cand.CheckAutoConnect()
+		c.Check(err, ErrorMatches, `auto-connection denied by slot rule of interface \"pipewire\"`, Commentf(attr))
+	}
+}
+
 func (s *baseDeclSuite) TestAutoConnectionSnapdControl(c *C) {
 	cand := s.connectCand(c, "snapd-control", "", "")
 	err := cand.CheckAutoConnect()
@@ -458,9 +476,12 @@ var (
 
 	slotInstallation = map[string][]string{
 		// other
+		"alsa":                    {"core", "gadget"},
 		"autopilot-introspection": {"core"},
 		"avahi-control":           {"app", "core"},
 		"avahi-observe":           {"app", "core"},
+		"avahi-publish":           {"app", "core"},
+		"bluetooth-le":            {"app", "core"},
 		"bluez":                   {"app", "core"},
 		"bool-file":               {"core", "gadget"},
 		"browser-support":         {"core"},
@@ -468,6 +489,7 @@ var (
 		"core-support":            {"core"},
 		"dbus":                    {"app"},
 		"docker-support":          {"core"},
+		"fpga":                    {"core", "gadget"},
 		"fwupd":                   {"app"},
 		"gpio":                    {"core", "gadget"},
 		"greengrass-support":      {"core"},
@@ -487,8 +509,11 @@ var (
 		"network-status":          {"app"},
 		"ofono":                   {"app", "core"},
 		"online-accounts-service": {"app"},
+		"pkcs11":                  {"core", "gadget"},
 		"ppp":         {"core"},
 		"pulseaudio":  {"app", "core"},
+		"removable-media-scoped": {"core", "gadget"},
+		"serial-console": {"core", "gadget"},
 		"serial-port": {"core", "gadget"},
 		"spi":         {"core", "gadget"},
 		"storage-framework-service": {"app"},
@@ -577,6 +602,7 @@ func (s *baseDeclSuite) TestPlugInstallation(c *C) {
 		"docker-support":        true,
 		"greengrass-support":    true,
 		"kernel-module-control": true,
+		"kernel-tracing":        true,
 		"kubernetes-support":    true,
 		"lxd-support":           true,
 		"snapd-control":         true,
@@ -691,6 +717,22 @@ func (s *baseDeclSuite) TestConnectionOnClassic(c *C) {
 	}
 }
 
+func (s *baseDeclSuite) TestConnectionBluetoothLERawHCI(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	cand := s.connectCand(c, "bluetooth-le", "", "")
+	c.Check(cand.Check(), IsNil)
+
+	// raw HCI access needs to be allowed on a case-by-case basis
+	cand = s.connectCand(c, "bluetooth-le", "", `name: plug-snap
+plugs:
+  bluetooth-le:
+    raw-hci: true
+`)
+	c.Check(cand.Check(), NotNil)
+}
+
 func (s *baseDeclSuite) TestSanity(c *C) {
 	all := builtin.Interfaces()
 
@@ -703,6 +745,7 @@ func (s *baseDeclSuite) TestSanity(c *C) {
 		"docker-support":        true,
 		"greengrass-support":    true,
 		"kernel-module-control": true,
+		"kernel-tracing":        true,
 		"kubernetes-support":    true,
 		"lxd-support":           true,
 		"snapd-control":         true,
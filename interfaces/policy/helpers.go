@@ -80,6 +80,25 @@ func checkOnClassic(c *asserts.OnClassicConstraint) error {
 	return nil
 }
 
+func checkDeviceScope(c *asserts.DeviceScopeConstraint, model *asserts.Model) error {
+	if c == nil {
+		return nil
+	}
+	if model == nil { // without a model nothing about the device matches
+		return fmt.Errorf("cannot match on-store/on-brand/on-model without model")
+	}
+	if err := checkID("store", model.Store(), c.Store, nil); err != nil {
+		return err
+	}
+	if err := checkID("brand", model.BrandID(), c.Brand, nil); err != nil {
+		return err
+	}
+	if err := checkID("model", model.BrandID()+"/"+model.Model(), c.Model, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
 func checkPlugConnectionConstraints1(connc *ConnectCandidate, cstrs *asserts.PlugConnectionConstraints) error {
 	if err := cstrs.PlugAttributes.Check(connc.plugAttrs(), connc); err != nil {
 		return err
@@ -102,6 +121,9 @@ func checkPlugConnectionConstraints1(connc *ConnectCandidate, cstrs *asserts.Plu
 	if err := checkOnClassic(cstrs.OnClassic); err != nil {
 		return err
 	}
+	if err := checkDeviceScope(cstrs.DeviceScope, connc.Model); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -142,6 +164,9 @@ func checkSlotConnectionConstraints1(connc *ConnectCandidate, cstrs *asserts.Slo
 	if err := checkOnClassic(cstrs.OnClassic); err != nil {
 		return err
 	}
+	if err := checkDeviceScope(cstrs.DeviceScope, connc.Model); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -160,7 +185,7 @@ func checkSlotConnectionConstraints(connc *ConnectCandidate, cstrs []*asserts.Sl
 	return firstErr
 }
 
-func checkSlotInstallationConstraints1(slot *snap.SlotInfo, cstrs *asserts.SlotInstallationConstraints) error {
+func checkSlotInstallationConstraints1(ic *InstallCandidate, slot *snap.SlotInfo, cstrs *asserts.SlotInstallationConstraints) error {
 	// TODO: allow evaluated attr constraints here too?
 	if err := cstrs.SlotAttributes.Check(slot.Attrs, nil); err != nil {
 		return err
@@ -171,14 +196,17 @@ func checkSlotInstallationConstraints1(slot *snap.SlotInfo, cstrs *asserts.SlotI
 	if err := checkOnClassic(cstrs.OnClassic); err != nil {
 		return err
 	}
+	if err := checkDeviceScope(cstrs.DeviceScope, ic.Model); err != nil {
+		return err
+	}
 	return nil
 }
 
-func checkSlotInstallationConstraints(slot *snap.SlotInfo, cstrs []*asserts.SlotInstallationConstraints) error {
+func checkSlotInstallationConstraints(ic *InstallCandidate, slot *snap.SlotInfo, cstrs []*asserts.SlotInstallationConstraints) error {
 	var firstErr error
 	// OR of constraints
 	for _, cstrs1 := range cstrs {
-		err := checkSlotInstallationConstraints1(slot, cstrs1)
+		err := checkSlotInstallationConstraints1(ic, slot, cstrs1)
 		if err == nil {
 			return nil
 		}
@@ -189,7 +217,7 @@ func checkSlotInstallationConstraints(slot *snap.SlotInfo, cstrs []*asserts.Slot
 	return firstErr
 }
 
-func checkPlugInstallationConstraints1(plug *snap.PlugInfo, cstrs *asserts.PlugInstallationConstraints) error {
+func checkPlugInstallationConstraints1(ic *InstallCandidate, plug *snap.PlugInfo, cstrs *asserts.PlugInstallationConstraints) error {
 	// TODO: allow evaluated attr constraints here too?
 	if err := cstrs.PlugAttributes.Check(plug.Attrs, nil); err != nil {
 		return err
@@ -200,14 +228,17 @@ func checkPlugInstallationConstraints1(plug *snap.PlugInfo, cstrs *asserts.PlugI
 	if err := checkOnClassic(cstrs.OnClassic); err != nil {
 		return err
 	}
+	if err := checkDeviceScope(cstrs.DeviceScope, ic.Model); err != nil {
+		return err
+	}
 	return nil
 }
 
-func checkPlugInstallationConstraints(plug *snap.PlugInfo, cstrs []*asserts.PlugInstallationConstraints) error {
+func checkPlugInstallationConstraints(ic *InstallCandidate, plug *snap.PlugInfo, cstrs []*asserts.PlugInstallationConstraints) error {
 	var firstErr error
 	// OR of constraints
 	for _, cstrs1 := range cstrs {
-		err := checkPlugInstallationConstraints1(plug, cstrs1)
+		err := checkPlugInstallationConstraints1(ic, plug, cstrs1)
 		if err == nil {
 			return nil
 		}
@@ -1599,3 +1599,99 @@ func (s *policySuite) TestDollarMissingConnection(c *C) {
 	}
 	c.Check(cand.Check(), IsNil)
 }
+
+func mockModel(c *C, brand, model, store string) *asserts.Model {
+	storeHeader := ""
+	if store != "" {
+		storeHeader = "store: " + store + "\n"
+	}
+	a, err := asserts.Decode([]byte(`type: model
+authority-id: ` + brand + `
+series: 16
+brand-id: ` + brand + `
+model: ` + model + `
+architecture: amd64
+gadget: pc
+kernel: pc-kernel
+` + storeHeader + `timestamp: 2016-09-30T12:00:00Z
+sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij
+
+AXNpZw==`))
+	c.Assert(err, IsNil)
+	return a.(*asserts.Model)
+}
+
+func (s *policySuite) TestDeviceScopeCheckAutoConnect(c *C) {
+	a, err := asserts.Decode([]byte(`type: base-declaration
+authority-id: canonical
+series: 16
+plugs:
+  plug-on-store:
+    allow-auto-connection:
+      on-store:
+        - my-store
+  plug-on-brand:
+    allow-auto-connection:
+      on-brand:
+        - my-brand
+slots:
+  slot-on-model:
+    deny-auto-connection:
+      on-model:
+        - my-brand/denied-model
+timestamp: 2016-09-30T12:00:00Z
+sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij
+
+AXNpZw==`))
+	c.Assert(err, IsNil)
+	baseDecl := a.(*asserts.BaseDeclaration)
+
+	plugSnap := snaptest.MockInfo(c, `
+name: plug-snap
+plugs:
+  plug-on-store:
+  plug-on-brand:
+  slot-on-model:
+`, nil)
+	slotSnap := snaptest.MockInfo(c, `
+name: slot-snap
+slots:
+  plug-on-store:
+  plug-on-brand:
+  slot-on-model:
+`, nil)
+
+	tests := []struct {
+		iface string
+		model *asserts.Model
+		err   string // "" => no error
+	}{
+		{"plug-on-store", mockModel(c, "my-brand", "my-model", "my-store"), ""},
+		{"plug-on-store", mockModel(c, "my-brand", "my-model", "other-store"), `auto-connection not allowed by plug rule of interface "plug-on-store"`},
+		{"plug-on-store", mockModel(c, "my-brand", "my-model", ""), "auto-connection not allowed.*"},
+		{"plug-on-store", nil, "auto-connection not allowed.*"},
+		{"plug-on-brand", mockModel(c, "my-brand", "my-model", ""), ""},
+		{"plug-on-brand", mockModel(c, "other-brand", "my-model", ""), "auto-connection not allowed.*"},
+		{"slot-on-model", mockModel(c, "my-brand", "my-model", ""), ""},
+		{"slot-on-model", mockModel(c, "my-brand", "denied-model", ""), `auto-connection denied by slot rule of interface "slot-on-model"`},
+		{"slot-on-model", mockModel(c, "other-brand", "denied-model", ""), ""},
+		{"slot-on-model", nil, ""},
+	}
+
+	for _, t := range tests {
+		cand := policy.ConnectCandidate{
+			Plug:            plugSnap.Plugs[t.iface],
+			Slot:            slotSnap.Slots[t.iface],
+			BaseDeclaration: baseDecl,
+			Model:           t.model,
+		}
+		err := cand.CheckAutoConnect()
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+		// only auto-connection is constrained
+		c.Check(cand.Check(), IsNil)
+	}
+}
@@ -100,12 +100,19 @@ func (r *Repository) interfaceInfo(iface Interface, opts *InfoOptions) *Info {
 	si := StaticInfoOf(iface)
 	ifaceName := iface.Name()
 	ii := &Info{
-		Name:    ifaceName,
-		Summary: si.Summary,
+		Name:        ifaceName,
+		Summary:     si.Summary,
+		GreedyPlugs: si.GreedyPlugs,
+		Deprecated:  si.Deprecated,
+		ReplacedBy:  si.ReplacedBy,
 	}
 	if opts != nil && opts.Doc {
 		// Collect documentation URL
 		ii.DocURL = si.DocURL
+		// Collect the description of the attributes
+		ii.PlugAttrs = si.PlugAttrs
+		ii.SlotAttrs = si.SlotAttrs
+		ii.ConnectionAttrs = si.ConnectionAttrs
 	}
 	if opts != nil && opts.Plugs {
 		// Collect all plugs of this interface type.
@@ -548,6 +555,13 @@ func (r *Repository) ResolveDisconnect(plugSnapName, plugName, slotSnapName, slo
 // Connect establishes a connection between a plug and a slot.
 // The plug and the slot must have the same interface.
 func (r *Repository) Connect(ref ConnRef) error {
+	return r.ConnectWithAttrs(ref, nil)
+}
+
+// ConnectWithAttrs connects the plug to the slot like Connect, with the
+// given attributes overriding those of the slot for the connection. The
+// attributes are expected to be validated already.
+func (r *Repository) ConnectWithAttrs(ref ConnRef, slotAttrs map[string]interface{}) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
@@ -583,7 +597,7 @@ func (r *Repository) Connect(ref ConnRef) error {
 	if r.plugSlots[plug] == nil {
 		r.plugSlots[plug] = make(map[*Slot]*Connection)
 	}
-	conn := &Connection{plugInfo: plug.PlugInfo, slotInfo: slot.SlotInfo}
+	conn := &Connection{plugInfo: plug.PlugInfo, slotInfo: slot.SlotInfo, slotAttrs: slotAttrs}
 	r.slotPlugs[slot][plug] = conn
 	r.plugSlots[plug][slot] = conn
 	slot.Connections = append(slot.Connections, PlugRef{plug.Snap.Name(), plug.Name})
@@ -794,8 +808,8 @@ func (r *Repository) SnapSpecification(securitySystem SecuritySystem, snapName s
 		if err := spec.AddPermanentSlot(iface, slot); err != nil {
 			return nil, err
 		}
-		for plug := range r.slotPlugs[slot] {
-			if err := spec.AddConnectedSlot(iface, plug, nil, slot, nil); err != nil {
+		for plug, conn := range r.slotPlugs[slot] {
+			if err := spec.AddConnectedSlot(iface, plug, nil, slot, conn.slotAttrs); err != nil {
 				return nil, err
 			}
 		}
@@ -806,8 +820,8 @@ func (r *Repository) SnapSpecification(securitySystem SecuritySystem, snapName s
 		if err := spec.AddPermanentPlug(iface, plug); err != nil {
 			return nil, err
 		}
-		for slot := range r.plugSlots[plug] {
-			if err := spec.AddConnectedPlug(iface, plug, nil, slot, nil); err != nil {
+		for slot, conn := range r.plugSlots[plug] {
+			if err := spec.AddConnectedPlug(iface, plug, nil, slot, conn.slotAttrs); err != nil {
 				return nil, err
 			}
 		}
@@ -815,6 +829,44 @@ func (r *Repository) SnapSpecification(securitySystem SecuritySystem, snapName s
 	return spec, nil
 }
 
+// ConnectionSpecifications returns specifications of the given security
+// system holding only what connecting the plug to the slot would add to
+// the security of the plug snap and of the slot snap respectively, the
+// given attributes overriding those of the slot. The connection is not
+// made.
+func (r *Repository) ConnectionSpecifications(securitySystem SecuritySystem, ref ConnRef, slotAttrs map[string]interface{}) (plugSpec, slotSpec Specification, err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	backend := r.backends[securitySystem]
+	if backend == nil {
+		return nil, nil, fmt.Errorf("cannot handle connection %s, security system %q is not known", ref.ID(), securitySystem)
+	}
+	plug := r.plugs[ref.PlugRef.Snap][ref.PlugRef.Name]
+	if plug == nil {
+		return nil, nil, fmt.Errorf("cannot connect plug %q from snap %q, no such plug", ref.PlugRef.Name, ref.PlugRef.Snap)
+	}
+	slot := r.slots[ref.SlotRef.Snap][ref.SlotRef.Name]
+	if slot == nil {
+		return nil, nil, fmt.Errorf("cannot connect plug to slot %q from snap %q, no such slot", ref.SlotRef.Name, ref.SlotRef.Snap)
+	}
+	if slot.Interface != plug.Interface {
+		return nil, nil, fmt.Errorf(`cannot connect plug "%s:%s" (interface %q) to "%s:%s" (interface %q)`,
+			ref.PlugRef.Snap, ref.PlugRef.Name, plug.Interface, ref.SlotRef.Snap, ref.SlotRef.Name, slot.Interface)
+	}
+	iface := r.ifaces[plug.Interface]
+
+	plugSpec = backend.NewSpecification()
+	if err := plugSpec.AddConnectedPlug(iface, plug, nil, slot, slotAttrs); err != nil {
+		return nil, nil, err
+	}
+	slotSpec = backend.NewSpecification()
+	if err := slotSpec.AddConnectedSlot(iface, plug, nil, slot, slotAttrs); err != nil {
+		return nil, nil, err
+	}
+	return plugSpec, slotSpec, nil
+}
+
 // BadInterfacesError is returned when some snap interfaces could not be registered.
 // Those interfaces not mentioned in the error were successfully registered.
 type BadInterfacesError struct {
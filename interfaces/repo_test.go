@@ -1357,6 +1357,75 @@ func (s *RepositorySuite) TestSnapSpecification(c *C) {
 	})
 }
 
+func (s *RepositorySuite) TestSnapSpecificationConnectionAttrs(c *C) {
+	var plugSide, slotSide []map[string]interface{}
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "interface",
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *Plug, plugAttrs map[string]interface{}, slot *Slot, slotAttrs map[string]interface{}) error {
+			plugSide = append(plugSide, slotAttrs)
+			return nil
+		},
+		TestConnectedSlotCallback: func(spec *ifacetest.Specification, plug *Plug, plugAttrs map[string]interface{}, slot *Slot, slotAttrs map[string]interface{}) error {
+			slotSide = append(slotSide, slotAttrs)
+			return nil
+		},
+	}
+	repo := s.emptyRepo
+	backend := &ifacetest.TestSecurityBackend{BackendName: testSecurity}
+	c.Assert(repo.AddBackend(backend), IsNil)
+	c.Assert(repo.AddInterface(iface), IsNil)
+	c.Assert(repo.AddPlug(s.plug), IsNil)
+	c.Assert(repo.AddSlot(s.slot), IsNil)
+
+	attrs := map[string]interface{}{"path": "/dev/foo"}
+	connRef := ConnRef{PlugRef: s.plug.Ref(), SlotRef: s.slot.Ref()}
+	c.Assert(repo.ConnectWithAttrs(connRef, attrs), IsNil)
+
+	_, err := repo.SnapSpecification(testSecurity, s.plug.Snap.Name())
+	c.Assert(err, IsNil)
+	_, err = repo.SnapSpecification(testSecurity, s.slot.Snap.Name())
+	c.Assert(err, IsNil)
+	// both sides see the attributes given when connecting
+	c.Check(plugSide, DeepEquals, []map[string]interface{}{attrs})
+	c.Check(slotSide, DeepEquals, []map[string]interface{}{attrs})
+}
+
+func (s *RepositorySuite) TestConnectionSpecifications(c *C) {
+	repo := s.emptyRepo
+	backend := &ifacetest.TestSecurityBackend{BackendName: testSecurity}
+	c.Assert(repo.AddBackend(backend), IsNil)
+	c.Assert(repo.AddInterface(testInterface), IsNil)
+	c.Assert(repo.AddPlug(s.plug), IsNil)
+	c.Assert(repo.AddSlot(s.slot), IsNil)
+
+	connRef := ConnRef{PlugRef: s.plug.Ref(), SlotRef: s.slot.Ref()}
+	plugSpec, slotSpec, err := repo.ConnectionSpecifications(testSecurity, connRef, nil)
+	c.Assert(err, IsNil)
+	// only the connection-specific snippets are there
+	c.Check(plugSpec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"connection-specific plug snippet"})
+	c.Check(slotSpec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"connection-specific slot snippet"})
+
+	// the connection is not made
+	c.Check(repo.Plug(s.plug.Snap.Name(), s.plug.Name).Connections, HasLen, 0)
+}
+
+func (s *RepositorySuite) TestConnectionSpecificationsErrors(c *C) {
+	repo := s.emptyRepo
+	connRef := ConnRef{PlugRef: s.plug.Ref(), SlotRef: s.slot.Ref()}
+	_, _, err := repo.ConnectionSpecifications(testSecurity, connRef, nil)
+	c.Check(err, ErrorMatches, `cannot handle connection consumer:plug producer:slot, security system "test" is not known`)
+
+	backend := &ifacetest.TestSecurityBackend{BackendName: testSecurity}
+	c.Assert(repo.AddBackend(backend), IsNil)
+	c.Assert(repo.AddInterface(testInterface), IsNil)
+	_, _, err = repo.ConnectionSpecifications(testSecurity, connRef, nil)
+	c.Check(err, ErrorMatches, `cannot connect plug "plug" from snap "consumer", no such plug`)
+
+	c.Assert(repo.AddPlug(s.plug), IsNil)
+	_, _, err = repo.ConnectionSpecifications(testSecurity, connRef, nil)
+	c.Check(err, ErrorMatches, `cannot connect plug to slot "slot" from snap "producer", no such slot`)
+}
+
 func (s *RepositorySuite) TestSnapSpecificationFailureWithConnectionSnippets(c *C) {
 	var testSecurity SecuritySystem = "security"
 	backend := &ifacetest.TestSecurityBackend{BackendName: testSecurity}
@@ -1852,7 +1921,7 @@ func (s *RepositorySuite) TestInfo(c *C) {
 	// Add some test interfaces.
 	i1 := &ifacetest.TestInterface{InterfaceName: "i1", InterfaceStaticInfo: StaticInfo{Summary: "i1 summary", DocURL: "http://example.com/i1"}}
 	i2 := &ifacetest.TestInterface{InterfaceName: "i2", InterfaceStaticInfo: StaticInfo{Summary: "i2 summary", DocURL: "http://example.com/i2"}}
-	i3 := &ifacetest.TestInterface{InterfaceName: "i3", InterfaceStaticInfo: StaticInfo{Summary: "i3 summary", DocURL: "http://example.com/i3"}}
+	i3 := &ifacetest.TestInterface{InterfaceName: "i3", InterfaceStaticInfo: StaticInfo{Summary: "i3 summary", DocURL: "http://example.com/i3", Deprecated: true, ReplacedBy: "i1"}}
 	c.Assert(r.AddInterface(i1), IsNil)
 	c.Assert(r.AddInterface(i2), IsNil)
 	c.Assert(r.AddInterface(i3), IsNil)
@@ -1891,7 +1960,7 @@ slots:
 	c.Assert(infos, DeepEquals, []*Info{
 		{Name: "i1", Summary: "i1 summary"},
 		{Name: "i2", Summary: "i2 summary"},
-		{Name: "i3", Summary: "i3 summary"},
+		{Name: "i3", Summary: "i3 summary", Deprecated: true, ReplacedBy: "i1"},
 	})
 
 	// We can choose specific interfaces, unknown names are just skipped.
@@ -227,6 +227,8 @@ func (s *backendSuite) TestRealDefaultTemplateIsNormallyUsed(c *C) {
 	} {
 		c.Assert(string(data), testutil.Contains, line)
 	}
+	// io_uring is only allowed by the io-uring interface
+	c.Check(string(data), Not(testutil.Contains), "io_uring_setup")
 }
 
 type combineSnippetsScenario struct {
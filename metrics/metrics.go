@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package metrics collects how long repeated operations, like the
+// tasks run by snapd, take and how often they fail.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxSamples is how many of the most recent durations of an
+// operation are kept to compute its percentiles.
+const maxSamples = 1000
+
+// Summary describes the recorded runs of an operation.
+type Summary struct {
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Failures int    `json:"failures"`
+	// Total is the time spent in all the recorded runs.
+	Total time.Duration `json:"total"`
+	// The percentiles and maximum are over the most recent runs.
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+type series struct {
+	count    int
+	failures int
+	total    time.Duration
+	// samples is a ring of the most recent durations
+	samples []time.Duration
+	next    int
+}
+
+// Registry collects the runs of operations by name, it can be used
+// from different goroutines.
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// New returns a new empty registry.
+func New() *Registry {
+	return &Registry{series: make(map[string]*series)}
+}
+
+// Record records a run of the named operation that took the given
+// duration, and whether it failed.
+func (r *Registry) Record(name string, duration time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.series[name]
+	if s == nil {
+		s = &series{}
+		r.series[name] = s
+	}
+	s.count++
+	if failed {
+		s.failures++
+	}
+	s.total += duration
+	if len(s.samples) < maxSamples {
+		s.samples = append(s.samples, duration)
+	} else {
+		s.samples[s.next] = duration
+		s.next = (s.next + 1) % maxSamples
+	}
+}
+
+// percentile returns the p-th percentile of the sorted durations,
+// using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+
+// Summaries returns a summary of the runs of each operation recorded
+// so far, ordered by name.
+func (r *Registry) Summaries() []Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summaries := make([]Summary, 0, len(r.series))
+	for name, s := range r.series {
+		sorted := make([]time.Duration, len(s.samples))
+		copy(sorted, s.samples)
+		sort.Sort(byDuration(sorted))
+		summaries = append(summaries, Summary{
+			Name:     name,
+			Count:    s.count,
+			Failures: s.failures,
+			Total:    s.total,
+			P50:      percentile(sorted, 50),
+			P90:      percentile(sorted, 90),
+			P99:      percentile(sorted, 99),
+			Max:      sorted[len(sorted)-1],
+		})
+	}
+	sort.Sort(byName(summaries))
+	return summaries
+}
+
+type byName []Summary
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/metrics"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type metricsSuite struct{}
+
+var _ = Suite(&metricsSuite{})
+
+func (s *metricsSuite) TestEmpty(c *C) {
+	c.Check(metrics.New().Summaries(), HasLen, 0)
+}
+
+func (s *metricsSuite) TestSummaries(c *C) {
+	r := metrics.New()
+	// 1ms..100ms, in reverse to check they get sorted
+	for i := 100; i > 0; i-- {
+		r.Record("setup-profiles", time.Duration(i)*time.Millisecond, i%10 == 0)
+	}
+	r.Record("link-snap", 3*time.Second, false)
+
+	c.Check(r.Summaries(), DeepEquals, []metrics.Summary{
+		{
+			Name:  "link-snap",
+			Count: 1,
+			Total: 3 * time.Second,
+			P50:   3 * time.Second,
+			P90:   3 * time.Second,
+			P99:   3 * time.Second,
+			Max:   3 * time.Second,
+		}, {
+			Name:     "setup-profiles",
+			Count:    100,
+			Failures: 10,
+			Total:    5050 * time.Millisecond,
+			P50:      50 * time.Millisecond,
+			P90:      90 * time.Millisecond,
+			P99:      99 * time.Millisecond,
+			Max:      100 * time.Millisecond,
+		},
+	})
+}
+
+func (s *metricsSuite) TestPercentilesUseRecentRuns(c *C) {
+	r := metrics.New()
+	for i := 0; i < 1000; i++ {
+		r.Record("foo", time.Hour, false)
+	}
+	for i := 0; i < 1000; i++ {
+		r.Record("foo", time.Second, true)
+	}
+
+	summaries := r.Summaries()
+	c.Assert(summaries, HasLen, 1)
+	c.Check(summaries[0].Count, Equals, 2000)
+	c.Check(summaries[0].Failures, Equals, 1000)
+	c.Check(summaries[0].Total, Equals, 1000*time.Hour+1000*time.Second)
+	c.Check(summaries[0].P99, Equals, time.Second)
+	c.Check(summaries[0].Max, Equals, time.Second)
+}
+
+func (s *metricsSuite) TestConcurrentRecord(c *C) {
+	r := metrics.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Record("foo", time.Millisecond, false)
+			}
+		}()
+	}
+	wg.Wait()
+
+	summaries := r.Summaries()
+	c.Assert(summaries, HasLen, 1)
+	c.Check(summaries[0].Count, Equals, 1000)
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+var syscallStatfs = syscall.Statfs
+
+// DiskSpaceInfo describes the filesystem holding a given path.
+type DiskSpaceInfo struct {
+	// Device identifies the filesystem, so that paths on the same
+	// filesystem can be grouped together.
+	Device uint64
+	// Available is the number of bytes available to unprivileged users.
+	Available uint64
+}
+
+// DiskSpace returns information about the filesystem holding path. If
+// path does not exist yet the closest existing parent is used instead,
+// as that is where the path would end up being created.
+func DiskSpace(path string) (*DiskSpaceInfo, error) {
+	path = filepath.Clean(path)
+	for {
+		var st syscall.Stat_t
+		err := syscall.Stat(path, &st)
+		if err == nil {
+			var fs syscall.Statfs_t
+			if err := syscallStatfs(path, &fs); err != nil {
+				return nil, &os.PathError{Op: "statfs", Path: path, Err: err}
+			}
+			return &DiskSpaceInfo{
+				Device:    uint64(st.Dev),
+				Available: uint64(fs.Bavail) * uint64(fs.Bsize),
+			}, nil
+		}
+		if err != syscall.ENOENT && err != syscall.ENOTDIR {
+			return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return nil, &os.PathError{Op: "stat", Path: path, Err: err}
+		}
+		path = parent
+	}
+}
+
+// DirSize returns the combined size of all the files under path. A
+// missing path has a size of zero. Symlinks are not followed.
+func DirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
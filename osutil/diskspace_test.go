@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+type diskSpaceSuite struct{}
+
+var _ = Suite(&diskSpaceSuite{})
+
+func (s *diskSpaceSuite) TestDiskSpaceMissingPathUsesParent(c *C) {
+	d := c.MkDir()
+	var statfsPath string
+	restore := osutil.MockSyscallStatfs(func(path string, fs *syscall.Statfs_t) error {
+		statfsPath = path
+		fs.Bavail = 10
+		fs.Bsize = 4096
+		return nil
+	})
+	defer restore()
+
+	info, err := osutil.DiskSpace(filepath.Join(d, "not", "there"))
+	c.Assert(err, IsNil)
+	c.Check(statfsPath, Equals, d)
+	c.Check(info.Available, Equals, uint64(40960))
+
+	var st syscall.Stat_t
+	c.Assert(syscall.Stat(d, &st), IsNil)
+	c.Check(info.Device, Equals, uint64(st.Dev))
+}
+
+func (s *diskSpaceSuite) TestDiskSpaceStatfsError(c *C) {
+	d := c.MkDir()
+	restore := osutil.MockSyscallStatfs(func(string, *syscall.Statfs_t) error {
+		return syscall.EIO
+	})
+	defer restore()
+
+	_, err := osutil.DiskSpace(d)
+	c.Check(err, ErrorMatches, `statfs .*: input/output error`)
+}
+
+func (s *diskSpaceSuite) TestDirSize(c *C) {
+	d := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(d, "sub"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "a"), make([]byte, 10), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "sub", "b"), make([]byte, 32), 0644), IsNil)
+	c.Assert(os.Symlink("a", filepath.Join(d, "link")), IsNil)
+
+	size, err := osutil.DirSize(d)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(42))
+}
+
+func (s *diskSpaceSuite) TestDirSizeMissing(c *C) {
+	size, err := osutil.DirSize(filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(0))
+}
@@ -104,3 +104,11 @@ func SetUnsafeIO(b bool) func() {
 		snapdUnsafeIO = oldSnapdUnsafeIO
 	}
 }
+
+func MockSyscallStatfs(f func(string, *syscall.Statfs_t) error) func() {
+	oldSyscallStatfs := syscallStatfs
+	syscallStatfs = f
+	return func() {
+		syscallStatfs = oldSyscallStatfs
+	}
+}
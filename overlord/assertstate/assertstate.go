@@ -23,6 +23,7 @@
 package assertstate
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"strings"
@@ -136,12 +137,30 @@ func findError(format string, ref *asserts.Ref, err error) error {
 	}
 }
 
+// SnapDeclarationsChanged, when set, is called by RefreshSnapDeclarations
+// with the previous revisions, indexed by snap name, of the snap
+// declarations that were replaced by the refresh.
+var SnapDeclarationsChanged func(st *state.State, previous map[string]*asserts.SnapDeclaration)
+
 // RefreshSnapDeclarations refetches all the current snap declarations and their prerequisites.
 func RefreshSnapDeclarations(s *state.State, userID int) error {
 	snapStates, err := snapstate.All(s)
 	if err != nil {
 		return nil
 	}
+	// the snap declarations as they were before the refresh, by snap ID
+	previous := make(map[string]*asserts.SnapDeclaration)
+	snapNames := make(map[string]string)
+	for snapName, snapst := range snapStates {
+		info, err := snapst.CurrentInfo()
+		if err != nil || info.SnapID == "" {
+			continue
+		}
+		snapNames[info.SnapID] = snapName
+		if snapDecl, err := SnapDeclaration(s, info.SnapID); err == nil {
+			previous[info.SnapID] = snapDecl
+		}
+	}
 	fetching := func(f asserts.Fetcher) error {
 		for _, snapst := range snapStates {
 			info, err := snapst.CurrentInfo()
@@ -157,7 +176,27 @@ func RefreshSnapDeclarations(s *state.State, userID int) error {
 		}
 		return nil
 	}
-	return doFetch(s, userID, fetching)
+	if err := doFetch(s, userID, fetching); err != nil {
+		return err
+	}
+
+	if SnapDeclarationsChanged == nil {
+		return nil
+	}
+	changed := make(map[string]*asserts.SnapDeclaration)
+	for snapID, prevDecl := range previous {
+		snapDecl, err := SnapDeclaration(s, snapID)
+		if err != nil {
+			continue
+		}
+		if snapDecl.Revision() != prevDecl.Revision() {
+			changed[snapNames[snapID]] = prevDecl
+		}
+	}
+	if len(changed) > 0 {
+		SnapDeclarationsChanged(s, changed)
+	}
+	return nil
 }
 
 type refreshControlError struct {
@@ -318,6 +357,45 @@ func Publisher(s *state.State, snapID string) (*asserts.Account, error) {
 	return a.(*asserts.Account), nil
 }
 
+// SnapVerity returns the dm-verity root hash and salt of the snap file
+// with the given digest, as found in its snap-revision assertion if it is
+// present in the system assertion database. They are empty if the
+// assertion carries none.
+func SnapVerity(s *state.State, snapSHA3_384 string) (rootHash, salt string, err error) {
+	a, err := DB(s).Find(asserts.SnapRevisionType, map[string]string{
+		"snap-sha3-384": snapSHA3_384,
+	})
+	if asserts.IsNotFound(err) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	snapRev := a.(*asserts.SnapRevision)
+	return snapRev.VerityRootHash(), snapRev.VeritySalt(), nil
+}
+
+// VerifyRegistrySnap adds the assertions published in a registry
+// alongside the snap file at snapPath to the system assertion database,
+// and returns the side info of the snap they verify it as.
+func VerifyRegistrySnap(s *state.State, snapPath string, assertions []byte) (*snap.SideInfo, error) {
+	batch := NewBatch()
+	if _, err := batch.AddStream(bytes.NewReader(assertions)); err != nil {
+		return nil, fmt.Errorf("cannot decode assertions of snap: %v", err)
+	}
+	if err := batch.Commit(s); err != nil {
+		return nil, fmt.Errorf("cannot add assertions of snap: %v", err)
+	}
+	si, err := snapasserts.DeriveSideInfo(snapPath, DB(s))
+	if asserts.IsNotFound(err) {
+		return nil, fmt.Errorf("cannot find signatures with metadata for snap")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
 // AutoAliases returns the explicit automatic aliases alias=>app mapping for the given installed snap.
 func AutoAliases(s *state.State, info *snap.Info) (map[string]string, error) {
 	if info.SnapID == "" {
@@ -358,6 +436,10 @@ func delayedCrossMgrInit() {
 	snapstate.AutoRefreshAssertions = AutoRefreshAssertions
 	// hook retrieving auto-aliases into snapstate logic
 	snapstate.AutoAliases = AutoAliases
+	// hook looking up asserted dm-verity root hashes into snapstate
+	snapstate.SnapVerity = SnapVerity
+	// hook verifying snaps from registries into snapstate
+	snapstate.VerifyRegistrySnap = VerifyRegistrySnap
 }
 
 // AutoRefreshAssertions tries to refresh all assertions
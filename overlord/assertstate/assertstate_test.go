@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -705,6 +706,58 @@ func (s *assertMgrSuite) TestRefreshSnapDeclarations(c *C) {
 	c.Check(a.(*asserts.SnapDeclaration).Revision(), Equals, 1)
 }
 
+func (s *assertMgrSuite) TestRefreshSnapDeclarationsReportsChanged(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapDeclFoo := s.snapDecl(c, "foo", nil)
+	snapDeclBar := s.snapDecl(c, "bar", nil)
+
+	s.stateFromDecl(snapDeclFoo, snap.R(7))
+	s.stateFromDecl(snapDeclBar, snap.R(3))
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, snapDeclFoo)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, snapDeclBar)
+	c.Assert(err, IsNil)
+
+	var called int
+	var previous map[string]*asserts.SnapDeclaration
+	restore := assertstate.MockSnapDeclarationsChanged(func(st *state.State, prev map[string]*asserts.SnapDeclaration) {
+		called++
+		previous = prev
+	})
+	defer restore()
+
+	// nothing changed
+	err = assertstate.RefreshSnapDeclarations(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Check(called, Equals, 0)
+
+	headers := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "foo-id",
+		"snap-name":    "foo",
+		"publisher-id": s.dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+		"revision":     "1",
+	}
+	snapDeclFoo1, err := s.storeSigning.Sign(asserts.SnapDeclarationType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = s.storeSigning.Add(snapDeclFoo1)
+	c.Assert(err, IsNil)
+
+	err = assertstate.RefreshSnapDeclarations(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Assert(called, Equals, 1)
+	c.Assert(previous, HasLen, 1)
+	c.Check(previous["foo"].Revision(), Equals, 0)
+}
+
 func (s *assertMgrSuite) TestValidateRefreshesNothing(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -954,6 +1007,99 @@ func (s *assertMgrSuite) TestSnapDeclaration(c *C) {
 	c.Check(snapDecl.SnapName(), Equals, "foo")
 }
 
+func (s *assertMgrSuite) TestSnapVerity(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.snapDecl(c, "foo", nil))
+	c.Assert(err, IsNil)
+
+	for _, rev := range []int{10, 11} {
+		headers := map[string]interface{}{
+			"snap-id":       "foo-id",
+			"snap-sha3-384": makeDigest(rev),
+			"snap-size":     fmt.Sprintf("%d", len(fakeSnap(rev))),
+			"snap-revision": fmt.Sprintf("%d", rev),
+			"developer-id":  s.dev1Acct.AccountID(),
+			"timestamp":     time.Now().Format(time.RFC3339),
+		}
+		if rev == 11 {
+			headers["snap-verity-root-hash"] = strings.Repeat("ab", 32)
+			headers["snap-verity-salt"] = "ef01"
+		}
+		snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, headers, nil, "")
+		c.Assert(err, IsNil)
+		err = assertstate.Add(s.state, snapRev)
+		c.Assert(err, IsNil)
+	}
+
+	// unknown
+	rootHash, salt, err := assertstate.SnapVerity(s.state, makeDigest(12))
+	c.Assert(err, IsNil)
+	c.Check(rootHash, Equals, "")
+	c.Check(salt, Equals, "")
+
+	// without dm-verity data
+	rootHash, salt, err = assertstate.SnapVerity(s.state, makeDigest(10))
+	c.Assert(err, IsNil)
+	c.Check(rootHash, Equals, "")
+	c.Check(salt, Equals, "")
+
+	rootHash, salt, err = assertstate.SnapVerity(s.state, makeDigest(11))
+	c.Assert(err, IsNil)
+	c.Check(rootHash, Equals, strings.Repeat("ab", 32))
+	c.Check(salt, Equals, "ef01")
+}
+
+func (s *assertMgrSuite) TestVerifyRegistrySnap(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	dir := c.MkDir()
+	snapPath := filepath.Join(dir, "foo.snap")
+	c.Assert(ioutil.WriteFile(snapPath, fakeSnap(41), 0644), IsNil)
+	snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-id":       "foo-id",
+		"snap-sha3-384": makeDigest(41),
+		"snap-size":     fmt.Sprintf("%d", len(fakeSnap(41))),
+		"snap-revision": "41",
+		"developer-id":  s.dev1Acct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+
+	var buf bytes.Buffer
+	enc := asserts.NewEncoder(&buf)
+	for _, a := range []asserts.Assertion{s.storeSigning.StoreAccountKey(""), s.dev1Acct, s.snapDecl(c, "foo", nil), snapRev} {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+
+	si, err := assertstate.VerifyRegistrySnap(s.state, snapPath, buf.Bytes())
+	c.Assert(err, IsNil)
+	c.Check(si, DeepEquals, &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(41),
+	})
+
+	// the assertions are now in the database
+	_, err = assertstate.SnapDeclaration(s.state, "foo-id")
+	c.Check(err, IsNil)
+
+	// other snap files are not verified by them
+	otherPath := filepath.Join(dir, "other.snap")
+	c.Assert(ioutil.WriteFile(otherPath, fakeSnap(42), 0644), IsNil)
+	_, err = assertstate.VerifyRegistrySnap(s.state, otherPath, nil)
+	c.Check(err, ErrorMatches, `cannot find signatures with metadata for snap`)
+
+	_, err = assertstate.VerifyRegistrySnap(s.state, snapPath, []byte("garbage"))
+	c.Check(err, ErrorMatches, `cannot decode assertions of snap: .*`)
+}
+
 func (s *assertMgrSuite) TestAutoAliasesTemporaryFallback(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
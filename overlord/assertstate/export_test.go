@@ -19,7 +19,18 @@
 
 package assertstate
 
+import (
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
 // expose for testing
 var (
 	DoFetch = doFetch
 )
+
+func MockSnapDeclarationsChanged(f func(st *state.State, previous map[string]*asserts.SnapDeclaration)) (restore func()) {
+	old := SnapDeclarationsChanged
+	SnapDeclarationsChanged = f
+	return func() { SnapDeclarationsChanged = old }
+}
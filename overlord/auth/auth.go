@@ -40,6 +40,9 @@ type AuthState struct {
 	Users       []UserState  `json:"users"`
 	Device      *DeviceState `json:"device,omitempty"`
 	MacaroonKey []byte       `json:"macaroon-key,omitempty"`
+
+	LastTokenID int          `json:"last-token-id,omitempty"`
+	Tokens      []TokenState `json:"tokens,omitempty"`
 }
 
 // DeviceState represents the device's identity and store credentials
@@ -62,6 +65,84 @@ type UserState struct {
 	Discharges      []string `json:"discharges,omitempty"`
 	StoreMacaroon   string   `json:"store-macaroon,omitempty"`
 	StoreDischarges []string `json:"store-discharges,omitempty"`
+	// StoreAuthSealed is set when the store macaroon and discharges
+	// are kept sealed out of the state, see SetStoreAuthSealed. They
+	// are only available once unsealed, see SetUnsealedStoreAuth.
+	StoreAuthSealed bool `json:"store-auth-sealed,omitempty"`
+}
+
+// StoreAuth is the store macaroon and discharges of a user.
+type StoreAuth struct {
+	Macaroon   string   `json:"macaroon"`
+	Discharges []string `json:"discharges,omitempty"`
+}
+
+func (a *StoreAuth) equal(macaroon string, discharges []string) bool {
+	if a.Macaroon != macaroon || len(a.Discharges) != len(discharges) {
+		return false
+	}
+	for i := range discharges {
+		if a.Discharges[i] != discharges[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type unsealedStoreAuthKey struct{}
+
+// unsealedStoreAuth returns the sealed store auth of users that was
+// unsealed, which is only kept in memory, keyed by user id.
+func unsealedStoreAuth(st *state.State) map[int]*StoreAuth {
+	unsealed, _ := st.Cached(unsealedStoreAuthKey{}).(map[int]*StoreAuth)
+	if unsealed == nil {
+		unsealed = make(map[int]*StoreAuth)
+		st.Cache(unsealedStoreAuthKey{}, unsealed)
+	}
+	return unsealed
+}
+
+// fillStoreAuth puts back the unsealed store auth of the user, if any.
+func fillStoreAuth(st *state.State, user *UserState) {
+	if !user.StoreAuthSealed {
+		return
+	}
+	if a := unsealedStoreAuth(st)[user.ID]; a != nil {
+		user.StoreMacaroon = a.Macaroon
+		user.StoreDischarges = a.Discharges
+	}
+}
+
+// SetStoreAuthSealed records that the store auth of the given user is
+// now kept sealed elsewhere, leaving it out of the state, and keeps it
+// unsealed in memory.
+func SetStoreAuthSealed(st *state.State, userID int, a *StoreAuth) error {
+	var authStateData AuthState
+
+	err := st.Get("auth", &authStateData)
+	if err != nil {
+		return err
+	}
+
+	for i := range authStateData.Users {
+		user := &authStateData.Users[i]
+		if user.ID == userID {
+			user.StoreAuthSealed = true
+			user.StoreMacaroon = ""
+			user.StoreDischarges = nil
+			unsealedStoreAuth(st)[userID] = a
+			st.Set("auth", authStateData)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid user")
+}
+
+// SetUnsealedStoreAuth makes available the store auth of the given user
+// that was unsealed.
+func SetUnsealedStoreAuth(st *state.State, userID int, a *StoreAuth) {
+	unsealedStoreAuth(st)[userID] = a
 }
 
 // MacaroonSerialize returns a store-compatible serialized representation of the given macaroon
@@ -172,6 +253,7 @@ func RemoveUser(st *state.State, userID int) error {
 			authStateData.Users[i] = authStateData.Users[n]
 			authStateData.Users[n] = UserState{}
 			authStateData.Users = authStateData.Users[:n]
+			delete(unsealedStoreAuth(st), userID)
 			st.Set("auth", authStateData)
 			return nil
 		}
@@ -194,6 +276,7 @@ func Users(st *state.State) ([]*UserState, error) {
 	users := make([]*UserState, len(authStateData.Users))
 	for i := range authStateData.Users {
 		users[i] = &authStateData.Users[i]
+		fillStoreAuth(st, users[i])
 	}
 	return users, nil
 }
@@ -209,6 +292,7 @@ func User(st *state.State, id int) (*UserState, error) {
 
 	for _, user := range authStateData.Users {
 		if user.ID == id {
+			fillStoreAuth(st, &user)
 			return &user, nil
 		}
 	}
@@ -227,6 +311,24 @@ func UpdateUser(st *state.State, user *UserState) error {
 	for i := range authStateData.Users {
 		if authStateData.Users[i].ID == user.ID {
 			authStateData.Users[i] = *user
+			if user.StoreAuthSealed {
+				unsealed := unsealedStoreAuth(st)
+				stored := &authStateData.Users[i]
+				a := unsealed[user.ID]
+				switch {
+				case a != nil && a.equal(user.StoreMacaroon, user.StoreDischarges):
+					// unchanged, keep it sealed
+				case stored.StoreMacaroon != "":
+					// changed, keep it in the state until
+					// it is sealed again
+					stored.StoreAuthSealed = false
+					delete(unsealed, user.ID)
+				}
+				if stored.StoreAuthSealed {
+					stored.StoreMacaroon = ""
+					stored.StoreDischarges = nil
+				}
+			}
 			st.Set("auth", authStateData)
 			return nil
 		}
@@ -324,6 +426,7 @@ NextUser:
 				continue NextUser
 			}
 		}
+		fillStoreAuth(st, &user)
 		return &user, nil
 	}
 	return nil, ErrInvalidAuth
@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// TokenState represents a token allowing local automation running as
+// the given user to use the given scopes of the API until it expires.
+type TokenState struct {
+	ID      int       `json:"id"`
+	Label   string    `json:"label,omitempty"`
+	Scopes  []string  `json:"scopes"`
+	Expires time.Time `json:"expires"`
+	// UID is the user the token was issued for, it is only accepted
+	// from processes of that user.
+	UID uint32 `json:"uid"`
+	// SecretHash is the hex encoded SHA256 of the secret, which is
+	// only handed out when the token is created.
+	SecretHash string `json:"secret-hash"`
+}
+
+var tokenMethods = map[string]bool{
+	"GET":    true,
+	"POST":   true,
+	"PUT":    true,
+	"DELETE": true,
+}
+
+// ValidateTokenScope checks that the scope is of the form
+// "<METHOD> <path>", where the path is an API path that can end with
+// "*" to also match any path it is a prefix of.
+func ValidateTokenScope(scope string) error {
+	fields := strings.Fields(scope)
+	if len(fields) != 2 || !tokenMethods[fields[0]] || !strings.HasPrefix(fields[1], "/v2/") || strings.Contains(strings.TrimSuffix(fields[1], "*"), "*") {
+		return fmt.Errorf("invalid token scope %q, expected <METHOD> /v2/<path>[*]", scope)
+	}
+	return nil
+}
+
+// Allows returns whether the token allows requests with the given
+// method to the given path.
+func (t *TokenState) Allows(method, path string) bool {
+	for _, scope := range t.Scopes {
+		fields := strings.Fields(scope)
+		if len(fields) != 2 || fields[0] != method {
+			continue
+		}
+		if fields[1] == path {
+			return true
+		}
+		if prefix := strings.TrimSuffix(fields[1], "*"); prefix != fields[1] && strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashTokenSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// NewToken tracks a new token for the given user with the given scopes,
+// expiring at the given time, and returns it along with its secret.
+// Expired tokens are dropped.
+func NewToken(st *state.State, label string, scopes []string, uid uint32, expires time.Time) (*TokenState, string, error) {
+	for _, scope := range scopes {
+		if err := ValidateTokenScope(scope); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var authStateData AuthState
+	err := st.Get("auth", &authStateData)
+	if err == state.ErrNoState {
+		authStateData = AuthState{}
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	// the key is only random bytes, it makes as good a secret
+	key, err := generateMacaroonKey()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(key)
+
+	now := time.Now()
+	tokens := authStateData.Tokens[:0]
+	for _, token := range authStateData.Tokens {
+		if token.Expires.After(now) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	authStateData.LastTokenID++
+	token := TokenState{
+		ID:         authStateData.LastTokenID,
+		Label:      label,
+		Scopes:     scopes,
+		Expires:    expires,
+		UID:        uid,
+		SecretHash: hashTokenSecret(secret),
+	}
+	authStateData.Tokens = append(tokens, token)
+
+	st.Set("auth", authStateData)
+
+	return &token, secret, nil
+}
+
+// Tokens returns the tokens that have not expired yet.
+func Tokens(st *state.State) ([]*TokenState, error) {
+	var authStateData AuthState
+
+	err := st.Get("auth", &authStateData)
+	if err == state.ErrNoState {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var tokens []*TokenState
+	for i := range authStateData.Tokens {
+		if authStateData.Tokens[i].Expires.After(now) {
+			tokens = append(tokens, &authStateData.Tokens[i])
+		}
+	}
+	return tokens, nil
+}
+
+// RemoveToken revokes a token given its ID.
+func RemoveToken(st *state.State, id int) error {
+	var authStateData AuthState
+
+	err := st.Get("auth", &authStateData)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	for i := range authStateData.Tokens {
+		if authStateData.Tokens[i].ID == id {
+			authStateData.Tokens = append(authStateData.Tokens[:i], authStateData.Tokens[i+1:]...)
+			st.Set("auth", authStateData)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid token")
+}
+
+// CheckToken returns the TokenState for the given secret, if it has not
+// expired.
+func CheckToken(st *state.State, secret string) (*TokenState, error) {
+	var authStateData AuthState
+	err := st.Get("auth", &authStateData)
+	if err != nil {
+		return nil, ErrInvalidAuth
+	}
+
+	hash := hashTokenSecret(secret)
+	now := time.Now()
+	for _, token := range authStateData.Tokens {
+		if token.SecretHash == hash && token.Expires.After(now) {
+			return &token, nil
+		}
+	}
+	return nil, ErrInvalidAuth
+}
@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package auth_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+)
+
+func (as *authSuite) TestValidateTokenScope(c *C) {
+	for _, scope := range []string{"GET /v2/changes", "POST /v2/snaps/foo", "PUT /v2/snaps/*", "DELETE /v2/foo"} {
+		c.Check(auth.ValidateTokenScope(scope), IsNil, Commentf(scope))
+	}
+	for _, scope := range []string{"", "GET", "GET /v1/changes", "PATCH /v2/changes", "get /v2/changes", "GET /v2/*/conf", "GET /v2/changes extra"} {
+		c.Check(auth.ValidateTokenScope(scope), ErrorMatches, `invalid token scope .*`, Commentf(scope))
+	}
+}
+
+func (as *authSuite) TestTokenAllows(c *C) {
+	token := &auth.TokenState{Scopes: []string{"GET /v2/changes", "POST /v2/snaps/*"}}
+
+	c.Check(token.Allows("GET", "/v2/changes"), Equals, true)
+	c.Check(token.Allows("POST", "/v2/changes"), Equals, false)
+	c.Check(token.Allows("GET", "/v2/changes/1"), Equals, false)
+	c.Check(token.Allows("POST", "/v2/snaps/foo"), Equals, true)
+	c.Check(token.Allows("POST", "/v2/snaps/"), Equals, true)
+	c.Check(token.Allows("POST", "/v2/snaps"), Equals, false)
+	c.Check(token.Allows("GET", "/v2/snaps/foo"), Equals, false)
+}
+
+func (as *authSuite) TestNewTokenCheckToken(c *C) {
+	as.state.Lock()
+	defer as.state.Unlock()
+
+	expires := time.Now().Add(time.Hour)
+	token, secret, err := auth.NewToken(as.state, "ci", []string{"GET /v2/changes"}, 1000, expires)
+	c.Assert(err, IsNil)
+	c.Check(token.ID, Equals, 1)
+	c.Check(token.Label, Equals, "ci")
+	c.Check(token.Scopes, DeepEquals, []string{"GET /v2/changes"})
+	c.Check(token.Expires.Equal(expires), Equals, true)
+	c.Check(token.UID, Equals, uint32(1000))
+	c.Check(secret, Not(Equals), "")
+	c.Check(token.SecretHash, Not(Equals), secret)
+
+	checked, err := auth.CheckToken(as.state, secret)
+	c.Assert(err, IsNil)
+	c.Check(checked.ID, Equals, 1)
+
+	_, err = auth.CheckToken(as.state, "other")
+	c.Check(err, Equals, auth.ErrInvalidAuth)
+
+	// the tokens live alongside the users
+	_, err = auth.NewUser(as.state, "username", "email@test.com", "macaroon", nil)
+	c.Assert(err, IsNil)
+	tokens, err := auth.Tokens(as.state)
+	c.Assert(err, IsNil)
+	c.Assert(tokens, HasLen, 1)
+	c.Check(tokens[0].ID, Equals, 1)
+}
+
+func (as *authSuite) TestNewTokenInvalidScope(c *C) {
+	as.state.Lock()
+	defer as.state.Unlock()
+
+	_, _, err := auth.NewToken(as.state, "", []string{"GET /v2/changes", "GET changes"}, 1000, time.Now().Add(time.Hour))
+	c.Assert(err, ErrorMatches, `invalid token scope "GET changes", expected <METHOD> /v2/<path>\[\*\]`)
+
+	tokens, err := auth.Tokens(as.state)
+	c.Assert(err, IsNil)
+	c.Check(tokens, HasLen, 0)
+}
+
+func (as *authSuite) TestExpiredTokens(c *C) {
+	as.state.Lock()
+	defer as.state.Unlock()
+
+	_, expired, err := auth.NewToken(as.state, "", []string{"GET /v2/changes"}, 1000, time.Now().Add(-time.Second))
+	c.Assert(err, IsNil)
+
+	_, err = auth.CheckToken(as.state, expired)
+	c.Check(err, Equals, auth.ErrInvalidAuth)
+	tokens, err := auth.Tokens(as.state)
+	c.Assert(err, IsNil)
+	c.Check(tokens, HasLen, 0)
+
+	// expired tokens are dropped when creating new ones
+	token, _, err := auth.NewToken(as.state, "", []string{"GET /v2/changes"}, 1000, time.Now().Add(time.Hour))
+	c.Assert(err, IsNil)
+	c.Check(token.ID, Equals, 2)
+
+	var authStateData auth.AuthState
+	err = as.state.Get("auth", &authStateData)
+	c.Assert(err, IsNil)
+	c.Assert(authStateData.Tokens, HasLen, 1)
+	c.Check(authStateData.Tokens[0].ID, Equals, 2)
+}
+
+func (as *authSuite) TestRemoveToken(c *C) {
+	as.state.Lock()
+	defer as.state.Unlock()
+
+	_, secret, err := auth.NewToken(as.state, "", []string{"GET /v2/changes"}, 1000, time.Now().Add(time.Hour))
+	c.Assert(err, IsNil)
+
+	err = auth.RemoveToken(as.state, 2)
+	c.Check(err, ErrorMatches, "invalid token")
+
+	err = auth.RemoveToken(as.state, 1)
+	c.Assert(err, IsNil)
+
+	_, err = auth.CheckToken(as.state, secret)
+	c.Check(err, Equals, auth.ErrInvalidAuth)
+}
@@ -0,0 +1,381 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+// Package backupstate coordinates the read-only views of /etc and /var
+// that snaps with a connected system-backup plug request before backing
+// them up, so that they don't race with the live files.
+package backupstate
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// viewMaxAge is how long views are kept before snapd removes them, in
+// case the snap that requested them doesn't.
+const viewMaxAge = 24 * time.Hour
+
+// backedUpDirs are the directories views are made of.
+var backedUpDirs = []string{"/etc", "/var"}
+
+// hostfsDir is where the root file system of the host is found in the
+// mount namespace of snaps.
+const hostfsDir = "/var/lib/snapd/hostfs"
+
+// View is a read-only view of the directories to back up.
+type View struct {
+	Snap string     `json:"snap"`
+	Time time.Time  `json:"time"`
+	Dirs []*ViewDir `json:"dirs"`
+	// Snapshots are the snapshots backing the view, removed along with
+	// it.
+	Snapshots []string `json:"snapshots,omitempty"`
+}
+
+// ViewDir is one of the directories of a view.
+type ViewDir struct {
+	Source string `json:"source"`
+	// Path is where the snap finds the directory.
+	Path string `json:"path"`
+	// Snapshot is whether Path is in a read-only snapshot taken when
+	// the view was created, rather than the live directory.
+	Snapshot bool `json:"snapshot"`
+}
+
+// snapshotter takes and removes read-only snapshots of file systems.
+type snapshotter interface {
+	// SubvolumeOf returns the root of the subvolume holding the given
+	// directory, or "" if its file system doesn't support snapshots.
+	SubvolumeOf(dir string) (string, error)
+	// Snapshot takes a read-only snapshot of the subvolume at target.
+	Snapshot(subvolume, target string) error
+	// Delete removes the given snapshot.
+	Delete(snapshot string) error
+}
+
+const (
+	// btrfsSuperMagic is the type of btrfs file systems, as reported
+	// by statfs.
+	btrfsSuperMagic = 0x9123683e
+	// btrfsSubvolumeInode is the inode number of the root directory
+	// of all btrfs subvolumes.
+	btrfsSubvolumeInode = 256
+)
+
+// btrfsSnapshotter takes snapshots of btrfs subvolumes.
+type btrfsSnapshotter struct{}
+
+func (btrfsSnapshotter) SubvolumeOf(dir string) (string, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return "", err
+	}
+	if fs.Type != btrfsSuperMagic {
+		return "", nil
+	}
+	for {
+		var st syscall.Stat_t
+		if err := syscall.Stat(dir, &st); err != nil {
+			return "", err
+		}
+		if st.Ino == btrfsSubvolumeInode {
+			return dir, nil
+		}
+		if dir == "/" {
+			return "", nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func runBtrfs(args ...string) error {
+	output, err := exec.Command("btrfs", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot run btrfs %s: %v", strings.Join(args, " "), osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+func (btrfsSnapshotter) Snapshot(subvolume, target string) error {
+	return runBtrfs("subvolume", "snapshot", "-r", subvolume, target)
+}
+
+func (btrfsSnapshotter) Delete(snapshot string) error {
+	return runBtrfs("subvolume", "delete", snapshot)
+}
+
+var backend snapshotter = btrfsSnapshotter{}
+
+func getViews(st *state.State) (map[string]*View, error) {
+	var views map[string]*View
+	err := st.Get("backup-views", &views)
+	if err == state.ErrNoState {
+		return make(map[string]*View), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// snapCanBackUp returns whether the snap has a connected system-backup
+// plug.
+func snapCanBackUp(st *state.State, snapName string) (bool, error) {
+	plugs, err := ifacestate.ConnectedPlugs(st, snapName, "system-backup")
+	if err != nil {
+		return false, err
+	}
+	return len(plugs) > 0, nil
+}
+
+// snapshotName returns the name of the snapshot of the given subvolume
+// in the directory of a view.
+func snapshotName(subvolume string) string {
+	name := strings.Replace(strings.Trim(subvolume, "/"), "/", "-", -1)
+	if name == "" {
+		return "root"
+	}
+	return name
+}
+
+// viewsMu serialises the creation and removal of views, as taking and
+// deleting snapshots is done without holding the state lock.
+var viewsMu sync.Mutex
+
+// popView forgets the view of the snap, returning it if there was one.
+func popView(st *state.State, snapName string) (*View, error) {
+	views, err := getViews(st)
+	if err != nil {
+		return nil, err
+	}
+	view := views[snapName]
+	if view != nil {
+		delete(views, snapName)
+		st.Set("backup-views", views)
+	}
+	return view, nil
+}
+
+// CreateView creates a read-only view of /etc and /var for the snap to
+// back up, replacing the one it had. The directories on file systems
+// supporting it are snapshotted, along with the rest of their subvolume
+// of which the snap can only read /etc and /var, the others are seen
+// live. It must be called without holding the state lock.
+func CreateView(st *state.State, snapName string) (*View, error) {
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+
+	st.Lock()
+	ok, err := snapCanBackUp(st, snapName)
+	var old *View
+	if err == nil && ok {
+		old, err = popView(st, snapName)
+	}
+	st.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("snap %q has no connected system-backup plug", snapName)
+	}
+	if old != nil {
+		removeView(old)
+	}
+
+	view, err := makeView(snapName)
+	if err != nil {
+		return nil, err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	views, err := getViews(st)
+	if err != nil {
+		removeView(view)
+		return nil, err
+	}
+	views[snapName] = view
+	st.Set("backup-views", views)
+	return view, nil
+}
+
+// makeView takes the snapshots of the view of the snap.
+func makeView(snapName string) (*View, error) {
+	viewDir := filepath.Join(dirs.SnapBackupViewsDir, snapName)
+	if err := os.MkdirAll(viewDir, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create backup view: %v", err)
+	}
+	view := &View{Snap: snapName, Time: time.Now()}
+	// directories in the same subvolume share its snapshot, which
+	// keeps them consistent with each other too
+	snapshots := make(map[string]string)
+	for _, source := range backedUpDirs {
+		dir := &ViewDir{
+			Source: source,
+			Path:   filepath.Join(hostfsDir, source),
+		}
+		view.Dirs = append(view.Dirs, dir)
+
+		hostDir := filepath.Join(dirs.GlobalRootDir, source)
+		subvolume, err := backend.SubvolumeOf(hostDir)
+		if err != nil || subvolume == "" {
+			if err != nil {
+				logger.Noticef("cannot snapshot %s for backup, using it live: %v", source, err)
+			}
+			continue
+		}
+		snapshot, ok := snapshots[subvolume]
+		if !ok {
+			snapshot = filepath.Join(viewDir, snapshotName(strings.TrimPrefix(subvolume, dirs.GlobalRootDir)))
+			if err := backend.Snapshot(subvolume, snapshot); err != nil {
+				logger.Noticef("cannot snapshot %s for backup, using it live: %v", source, err)
+				continue
+			}
+			snapshots[subvolume] = snapshot
+			view.Snapshots = append(view.Snapshots, snapshot)
+		}
+		rel, err := filepath.Rel(subvolume, hostDir)
+		if err != nil {
+			removeView(view)
+			return nil, err
+		}
+		dir.Path = filepath.Join("/", strings.TrimPrefix(snapshot, dirs.GlobalRootDir), rel)
+		dir.Snapshot = true
+	}
+
+	return view, nil
+}
+
+// removeView removes the snapshots backing the view, logging the errors.
+func removeView(view *View) {
+	for _, snapshot := range view.Snapshots {
+		if err := backend.Delete(snapshot); err != nil {
+			logger.Noticef("cannot remove backup view of snap %q: %v", view.Snap, err)
+		}
+	}
+	viewDir := filepath.Join(dirs.SnapBackupViewsDir, view.Snap)
+	if err := os.Remove(viewDir); err != nil && !os.IsNotExist(err) {
+		logger.Noticef("cannot remove backup view of snap %q: %v", view.Snap, err)
+	}
+}
+
+// RemoveView removes the view of the snap, if it has one. It must be
+// called without holding the state lock.
+func RemoveView(st *state.State, snapName string) error {
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+
+	st.Lock()
+	view, err := popView(st, snapName)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	if view != nil {
+		removeView(view)
+	}
+	return nil
+}
+
+// BackupManager removes the views that are too old, or whose snap lost
+// its connected system-backup plug.
+type BackupManager struct {
+	state *state.State
+}
+
+// Manager returns a new BackupManager.
+func Manager(st *state.State) *BackupManager {
+	return &BackupManager{state: st}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *BackupManager) Ensure() error {
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+
+	m.state.Lock()
+	stale, err := m.popStaleViews()
+	m.state.Unlock()
+	for _, view := range stale {
+		removeView(view)
+	}
+	return err
+}
+
+// NextWakeup returns when the oldest view becomes stale and needs to be
+// removed. It implements overlord.WakeupScheduler.
+// The caller should be holding the state lock.
+func (m *BackupManager) NextWakeup() time.Time {
+	var next time.Time
+	views, err := getViews(m.state)
+	if err != nil {
+		return next
+	}
+	for _, view := range views {
+		if stale := view.Time.Add(viewMaxAge); next.IsZero() || stale.Before(next) {
+			next = stale
+		}
+	}
+	return next
+}
+
+// popStaleViews forgets the views that are too old or whose snap can't
+// back up anymore, returning them.
+func (m *BackupManager) popStaleViews() ([]*View, error) {
+	views, err := getViews(m.state)
+	if err != nil {
+		return nil, err
+	}
+	var stale []*View
+	now := time.Now()
+	for snapName, view := range views {
+		if now.Sub(view.Time) < viewMaxAge {
+			ok, err := snapCanBackUp(m.state, snapName)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+		}
+		stale = append(stale, view)
+		delete(views, snapName)
+	}
+	if len(stale) > 0 {
+		m.state.Set("backup-views", views)
+	}
+	return stale, nil
+}
+
+// Stop implements StateManager.Stop.
+func (m *BackupManager) Stop() {}
+
+// Wait implements StateManager.Wait.
+func (m *BackupManager) Wait() {}
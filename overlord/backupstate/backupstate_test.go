@@ -0,0 +1,264 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package backupstate_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/backupstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func TestBackupState(t *testing.T) { TestingT(t) }
+
+// fakeSnapshotter pretends the directories under the root listed in
+// subvolumes are btrfs subvolumes.
+type fakeSnapshotter struct {
+	root       string
+	subvolumes []string
+	fail       bool
+	snapshots  []string
+	deleted    []string
+	state      *state.State
+}
+
+func (f *fakeSnapshotter) SubvolumeOf(dir string) (string, error) {
+	for _, subvolume := range f.subvolumes {
+		subvolume = filepath.Join(f.root, subvolume)
+		if rel, err := filepath.Rel(subvolume, dir); err == nil && rel[0] != '.' || dir == subvolume {
+			return subvolume, nil
+		}
+	}
+	return "", nil
+}
+
+func (f *fakeSnapshotter) Snapshot(subvolume, target string) error {
+	if f.state != nil {
+		// the state lock is not held while snapshotting
+		f.state.Lock()
+		f.state.Unlock()
+	}
+	if f.fail {
+		return fmt.Errorf("boom")
+	}
+	f.snapshots = append(f.snapshots, subvolume+" "+target)
+	return os.Mkdir(target, 0700)
+}
+
+func (f *fakeSnapshotter) Delete(snapshot string) error {
+	f.deleted = append(f.deleted, snapshot)
+	return os.Remove(snapshot)
+}
+
+type backupStateSuite struct {
+	state   *state.State
+	backend *fakeSnapshotter
+	restore func()
+}
+
+var _ = Suite(&backupStateSuite{})
+
+const backupSnapYaml = `name: backup
+version: 1
+plugs:
+ system-backup:
+`
+
+func (s *backupStateSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.backend = &fakeSnapshotter{root: dirs.GlobalRootDir, subvolumes: []string{"/"}}
+	s.restore = backupstate.MockBackend(s.backend)
+
+	s.state = state.New(nil)
+	s.backend.state = s.state
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "backup", Revision: snap.R(1)}
+	snaptest.MockSnap(c, backupSnapYaml, "", si)
+	snapstate.Set(s.state, "backup", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	s.state.Set("conns", map[string]interface{}{
+		"backup:system-backup core:system-backup": map[string]interface{}{"interface": "system-backup"},
+	})
+}
+
+func (s *backupStateSuite) TearDownTest(c *C) {
+	s.restore()
+	dirs.SetRootDir("")
+}
+
+func (s *backupStateSuite) TestSnapshotName(c *C) {
+	c.Check(backupstate.SnapshotName("/"), Equals, "root")
+	c.Check(backupstate.SnapshotName("/var"), Equals, "var")
+	c.Check(backupstate.SnapshotName("/var/lib/"), Equals, "var-lib")
+}
+
+func (s *backupStateSuite) TestCreateViewSharedSubvolume(c *C) {
+	view, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+	c.Check(view.Snap, Equals, "backup")
+	c.Check(view.Dirs, DeepEquals, []*backupstate.ViewDir{
+		{Source: "/etc", Path: "/var/lib/snapd/backup-views/backup/root/etc", Snapshot: true},
+		{Source: "/var", Path: "/var/lib/snapd/backup-views/backup/root/var", Snapshot: true},
+	})
+	snapshot := filepath.Join(dirs.SnapBackupViewsDir, "backup/root")
+	c.Check(view.Snapshots, DeepEquals, []string{snapshot})
+	// /etc and /var are consistent with each other
+	c.Check(s.backend.snapshots, DeepEquals, []string{dirs.GlobalRootDir + " " + snapshot})
+	c.Check(osutil.IsDirectory(snapshot), Equals, true)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var views map[string]*backupstate.View
+	c.Assert(s.state.Get("backup-views", &views), IsNil)
+	c.Assert(views["backup"], NotNil)
+	c.Check(views["backup"].Snapshots, DeepEquals, view.Snapshots)
+}
+
+func (s *backupStateSuite) TestCreateViewMixed(c *C) {
+	s.backend.subvolumes = []string{"/var"}
+
+	view, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+	c.Check(view.Dirs, DeepEquals, []*backupstate.ViewDir{
+		{Source: "/etc", Path: "/var/lib/snapd/hostfs/etc"},
+		{Source: "/var", Path: "/var/lib/snapd/backup-views/backup/var", Snapshot: true},
+	})
+}
+
+func (s *backupStateSuite) TestCreateViewSnapshotFails(c *C) {
+	s.backend.fail = true
+
+	view, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+	c.Check(view.Dirs, DeepEquals, []*backupstate.ViewDir{
+		{Source: "/etc", Path: "/var/lib/snapd/hostfs/etc"},
+		{Source: "/var", Path: "/var/lib/snapd/hostfs/var"},
+	})
+	c.Check(view.Snapshots, HasLen, 0)
+}
+
+func (s *backupStateSuite) TestCreateViewNotConnected(c *C) {
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{})
+	s.state.Unlock()
+
+	_, err := backupstate.CreateView(s.state, "backup")
+	c.Check(err, ErrorMatches, `snap "backup" has no connected system-backup plug`)
+	c.Check(s.backend.snapshots, HasLen, 0)
+}
+
+func (s *backupStateSuite) TestCreateViewReplaces(c *C) {
+	_, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+	_, err = backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+
+	snapshot := filepath.Join(dirs.SnapBackupViewsDir, "backup/root")
+	c.Check(s.backend.snapshots, HasLen, 2)
+	c.Check(s.backend.deleted, DeepEquals, []string{snapshot})
+	c.Check(osutil.IsDirectory(snapshot), Equals, true)
+}
+
+func (s *backupStateSuite) TestRemoveView(c *C) {
+	// nothing to remove
+	c.Assert(backupstate.RemoveView(s.state, "backup"), IsNil)
+
+	_, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+	c.Assert(backupstate.RemoveView(s.state, "backup"), IsNil)
+
+	c.Check(s.backend.deleted, DeepEquals, []string{filepath.Join(dirs.SnapBackupViewsDir, "backup/root")})
+	_, err = os.Stat(filepath.Join(dirs.SnapBackupViewsDir, "backup"))
+	c.Check(os.IsNotExist(err), Equals, true)
+	s.state.Lock()
+	defer s.state.Unlock()
+	var views map[string]*backupstate.View
+	c.Assert(s.state.Get("backup-views", &views), IsNil)
+	c.Check(views, HasLen, 0)
+}
+
+func (s *backupStateSuite) TestEnsureRemovesStaleViews(c *C) {
+	mgr := backupstate.Manager(s.state)
+
+	_, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+
+	// the view is fresh and the plug connected
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(s.backend.deleted, HasLen, 0)
+
+	// too old
+	s.state.Lock()
+	var views map[string]*backupstate.View
+	c.Assert(s.state.Get("backup-views", &views), IsNil)
+	views["backup"].Time = time.Now().Add(-25 * time.Hour)
+	s.state.Set("backup-views", views)
+	s.state.Unlock()
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(s.backend.deleted, HasLen, 1)
+
+	// disconnected
+	_, err = backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{})
+	s.state.Unlock()
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(s.backend.deleted, HasLen, 2)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var left map[string]*backupstate.View
+	c.Assert(s.state.Get("backup-views", &left), IsNil)
+	c.Check(left, HasLen, 0)
+}
+
+func (s *backupStateSuite) TestNextWakeup(c *C) {
+	mgr := backupstate.Manager(s.state)
+
+	s.state.Lock()
+	c.Check(mgr.NextWakeup().IsZero(), Equals, true)
+	s.state.Unlock()
+
+	view, err := backupstate.CreateView(s.state, "backup")
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(mgr.NextWakeup().Equal(view.Time.Add(24*time.Hour)), Equals, true)
+}
@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+)
+
+// The possible states of console-conf, the first-boot configuration
+// tool used on Ubuntu Core.
+const (
+	ConsoleConfPending   = "pending"
+	ConsoleConfStarted   = "started"
+	ConsoleConfCompleted = "completed"
+	ConsoleConfDisabled  = "disabled"
+)
+
+type consoleConfState struct {
+	Started  time.Time `json:"started,omitempty"`
+	Disabled bool      `json:"disabled,omitempty"`
+}
+
+func getConsoleConfState(st *state.State) (*consoleConfState, error) {
+	var ccs consoleConfState
+	err := st.Get("console-conf", &ccs)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return &ccs, nil
+}
+
+func errConsoleConfOnClassic() error {
+	return fmt.Errorf("console-conf is not used on classic systems")
+}
+
+// ConsoleConfStatus returns the current state of console-conf, one of
+// ConsoleConfPending, ConsoleConfStarted, ConsoleConfCompleted or
+// ConsoleConfDisabled.
+func ConsoleConfStatus(st *state.State) (string, error) {
+	if release.OnClassic {
+		return "", errConsoleConfOnClassic()
+	}
+	ccs, err := getConsoleConfState(st)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case ccs.Disabled:
+		return ConsoleConfDisabled, nil
+	case osutil.FileExists(dirs.ConsoleConfCompleteFile):
+		return ConsoleConfCompleted, nil
+	case !ccs.Started.IsZero():
+		return ConsoleConfStarted, nil
+	}
+	return ConsoleConfPending, nil
+}
+
+func writeConsoleConfComplete() error {
+	if err := os.MkdirAll(filepath.Dir(dirs.ConsoleConfCompleteFile), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(dirs.ConsoleConfCompleteFile, nil, 0644, 0)
+}
+
+// ConsoleConfStart records that console-conf was started.
+func ConsoleConfStart(st *state.State) error {
+	status, err := ConsoleConfStatus(st)
+	if err != nil {
+		return err
+	}
+	if status == ConsoleConfCompleted || status == ConsoleConfDisabled {
+		return fmt.Errorf("cannot start console-conf: console-conf is %s", status)
+	}
+	st.Set("console-conf", &consoleConfState{Started: time.Now()})
+	return nil
+}
+
+// ConsoleConfStop records that console-conf ran to completion, so that
+// it will not be run again.
+func ConsoleConfStop(st *state.State) error {
+	status, err := ConsoleConfStatus(st)
+	if err != nil {
+		return err
+	}
+	if status == ConsoleConfDisabled {
+		return fmt.Errorf("cannot stop console-conf: console-conf is disabled")
+	}
+	return writeConsoleConfComplete()
+}
+
+// DisableConsoleConf prevents console-conf from running, as done by
+// factories for preinstalled images.
+func DisableConsoleConf(st *state.State) error {
+	if release.OnClassic {
+		return errConsoleConfOnClassic()
+	}
+	ccs, err := getConsoleConfState(st)
+	if err != nil {
+		return err
+	}
+	if err := writeConsoleConfComplete(); err != nil {
+		return err
+	}
+	ccs.Disabled = true
+	st.Set("console-conf", ccs)
+	return nil
+}
@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+)
+
+type consoleConfSuite struct {
+	state *state.State
+
+	restoreOnClassic func()
+}
+
+var _ = Suite(&consoleConfSuite{})
+
+func (s *consoleConfSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	s.restoreOnClassic = release.MockOnClassic(false)
+}
+
+func (s *consoleConfSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	s.restoreOnClassic()
+}
+
+func (s *consoleConfSuite) status(c *C) string {
+	status, err := devicestate.ConsoleConfStatus(s.state)
+	c.Assert(err, IsNil)
+	return status
+}
+
+func (s *consoleConfSuite) TestStartStop(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.status(c), Equals, devicestate.ConsoleConfPending)
+
+	c.Assert(devicestate.ConsoleConfStart(s.state), IsNil)
+	c.Check(s.status(c), Equals, devicestate.ConsoleConfStarted)
+	c.Check(osutil.FileExists(dirs.ConsoleConfCompleteFile), Equals, false)
+
+	c.Assert(devicestate.ConsoleConfStop(s.state), IsNil)
+	c.Check(s.status(c), Equals, devicestate.ConsoleConfCompleted)
+	c.Check(osutil.FileExists(dirs.ConsoleConfCompleteFile), Equals, true)
+
+	err := devicestate.ConsoleConfStart(s.state)
+	c.Check(err, ErrorMatches, "cannot start console-conf: console-conf is completed")
+}
+
+func (s *consoleConfSuite) TestDisable(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(devicestate.DisableConsoleConf(s.state), IsNil)
+	c.Check(s.status(c), Equals, devicestate.ConsoleConfDisabled)
+	c.Check(osutil.FileExists(dirs.ConsoleConfCompleteFile), Equals, true)
+
+	err := devicestate.ConsoleConfStart(s.state)
+	c.Check(err, ErrorMatches, "cannot start console-conf: console-conf is disabled")
+	err = devicestate.ConsoleConfStop(s.state)
+	c.Check(err, ErrorMatches, "cannot stop console-conf: console-conf is disabled")
+}
+
+func (s *consoleConfSuite) TestOnClassic(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := devicestate.ConsoleConfStatus(s.state)
+	c.Check(err, ErrorMatches, "console-conf is not used on classic systems")
+	err = devicestate.DisableConsoleConf(s.state)
+	c.Check(err, ErrorMatches, "console-conf is not used on classic systems")
+}
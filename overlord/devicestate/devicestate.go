@@ -124,6 +124,16 @@ func canAutoRefresh(st *state.State) (bool, error) {
 	return true, nil
 }
 
+// canManageRefreshes returns whether the model allows auto-refreshes
+// to be disabled in favour of an external agent
+func canManageRefreshes(st *state.State) bool {
+	model, err := Model(st)
+	if err != nil {
+		return false
+	}
+	return model.RefreshMode() == "managed"
+}
+
 func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, flags snapstate.Flags) error {
 	kind := ""
 	var currentInfo func(*state.State) (*snap.Info, error)
@@ -196,4 +206,5 @@ func delayedCrossMgrInit() {
 		snapstate.AddCheckSnapCallback(checkGadgetOrKernel)
 	})
 	snapstate.CanAutoRefresh = canAutoRefresh
+	snapstate.CanManageRefreshes = canManageRefreshes
 }
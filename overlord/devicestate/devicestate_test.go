@@ -1801,6 +1801,37 @@ func (s *deviceMgrSuite) TestCanAutoRefreshOnCore(c *C) {
 	c.Check(canAutoRefresh(), Equals, false)
 }
 
+func (s *deviceMgrSuite) TestCanManageRefreshes(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// no model -> no managed refreshes
+	c.Check(devicestate.CanManageRefreshes(s.state), Equals, false)
+
+	auth.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]string{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	c.Check(devicestate.CanManageRefreshes(s.state), Equals, false)
+
+	auth.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc-managed",
+	})
+	s.makeModelAssertionInState(c, "canonical", "pc-managed", map[string]string{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"refresh-mode": "managed",
+	})
+	c.Check(devicestate.CanManageRefreshes(s.state), Equals, true)
+}
+
 func (s *deviceMgrSuite) TestCanAutoRefreshNoSerialFallback(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -442,6 +442,93 @@ func (s *FirstBootTestSuite) TestPopulateFromSeedHappy(c *C) {
 	c.Check(seeded, Equals, true)
 }
 
+func (s *FirstBootTestSuite) TestSeedingStatus(c *C) {
+	bootloader := boottest.NewMockBootloader("mock", c.MkDir())
+	partition.ForceBootloader(bootloader)
+	defer partition.ForceBootloader(nil)
+	bootloader.SetBootVars(map[string]string{
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "pc-kernel_1.snap",
+	})
+
+	st := s.overlord.State()
+	st.Lock()
+	status, err := devicestate.Seeding(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, &devicestate.SeedingStatus{Phase: "waiting"})
+
+	chg := s.makeBecomeOperationalChange(c, st)
+
+	st.Lock()
+	status, err = devicestate.Seeding(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(status.Seeded, Equals, false)
+	c.Check(status.Phase, Equals, "install-essential")
+	c.Check(status.Change, Equals, chg.ID())
+	var names []string
+	for _, sn := range status.Snaps {
+		names = append(names, sn.Name)
+		c.Check(sn.Essential, Equals, sn.Name == "core" || sn.Name == "pc-kernel" || sn.Name == "pc")
+		c.Check(sn.Status, Equals, "Do")
+		c.Check(sn.Done, Equals, 0)
+		c.Check(sn.Total > 0, Equals, true)
+	}
+	c.Check(names, DeepEquals, []string{"core", "pc-kernel", "pc", "foo", "local"})
+
+	err = s.overlord.Settle(settleTimeout)
+	c.Assert(err, IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), IsNil)
+
+	status, err = devicestate.Seeding(st)
+	c.Assert(err, IsNil)
+	c.Check(status.Seeded, Equals, true)
+	c.Check(status.Phase, Equals, "done")
+	c.Check(status.Error, Equals, "")
+	c.Assert(status.Snaps, HasLen, 5)
+	for _, sn := range status.Snaps {
+		c.Check(sn.Status, Equals, "Done", Commentf("%s", sn.Name))
+		c.Check(sn.Done, Equals, sn.Total)
+	}
+}
+
+func (s *FirstBootTestSuite) TestSeedingStatusError(c *C) {
+	st := s.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("seed", "...")
+	t1 := st.NewTask("prerequisites", "...")
+	t1.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "core"}})
+	t1.SetStatus(state.DoneStatus)
+	chg.AddTask(t1)
+	t2 := st.NewTask("mount-snap", "...")
+	t2.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "core"}})
+	t2.Errorf("cannot mount")
+	t2.SetStatus(state.ErrorStatus)
+	chg.AddTask(t2)
+	t3 := st.NewTask("mark-seeded", "...")
+	t3.SetStatus(state.HoldStatus)
+	chg.AddTask(t3)
+
+	status, err := devicestate.Seeding(st)
+	c.Assert(err, IsNil)
+	c.Check(status.Seeded, Equals, false)
+	c.Check(status.Phase, Equals, "error")
+	c.Check(status.Error, Matches, `(?s)cannot perform the following tasks:.*`)
+	c.Assert(status.Snaps, HasLen, 1)
+	c.Check(status.Snaps[0].Name, Equals, "core")
+	c.Check(status.Snaps[0].Essential, Equals, true)
+	c.Check(status.Snaps[0].Status, Equals, "Error")
+	c.Check(status.Snaps[0].Done, Equals, 2)
+	c.Check(status.Snaps[0].Total, Equals, 2)
+	c.Check(status.Snaps[0].Error, Matches, `.* ERROR cannot mount`)
+}
+
 func (s *FirstBootTestSuite) TestPopulateFromSeedMissingBootloader(c *C) {
 	st0 := s.overlord.State()
 	st0.Lock()
@@ -0,0 +1,192 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// The phases seeding goes through, in order.
+const (
+	SeedingWaiting            = "waiting"
+	SeedingInstallEssential   = "install-essential"
+	SeedingConfigureEssential = "configure-essential"
+	SeedingInstall            = "install"
+	SeedingMarkSeeded         = "mark-seeded"
+	SeedingDone               = "done"
+	SeedingError              = "error"
+)
+
+// SeedingSnap holds the progress of seeding one snap.
+type SeedingSnap struct {
+	Name string `json:"name"`
+	// Essential is set for the core, kernel and gadget snaps, which
+	// are installed and configured before any other snap.
+	Essential bool   `json:"essential,omitempty"`
+	Status    string `json:"status"`
+	Done      int    `json:"done"`
+	Total     int    `json:"total"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SeedingStatus describes how far seeding the system is.
+type SeedingStatus struct {
+	Seeded bool          `json:"seeded"`
+	Phase  string        `json:"phase"`
+	Change string        `json:"change,omitempty"`
+	Snaps  []SeedingSnap `json:"snaps,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+func seedChange(st *state.State) *state.Change {
+	var seedChg *state.Change
+	for _, chg := range st.Changes() {
+		if chg.Kind() != "seed" {
+			continue
+		}
+		if seedChg == nil || chg.SpawnTime().After(seedChg.SpawnTime()) {
+			seedChg = chg
+		}
+	}
+	return seedChg
+}
+
+// seedingTaskSnap returns the name of the snap the given task of the
+// seed change acts on, if any, and whether it configures it.
+func seedingTaskSnap(t *state.Task) (name string, configure bool) {
+	if t.Kind() == "run-hook" {
+		var hooksup hookstate.HookSetup
+		if err := t.Get("hook-setup", &hooksup); err != nil {
+			return "", false
+		}
+		return hooksup.Snap, hooksup.Hook == "configure"
+	}
+	snapsup, err := snapstate.TaskSnapSetup(t)
+	if err != nil || snapsup.SideInfo == nil {
+		return "", false
+	}
+	return snapsup.Name(), false
+}
+
+func essentialSnaps(st *state.State) map[string]bool {
+	essential := map[string]bool{"core": true}
+	model, err := Model(st)
+	if err != nil {
+		return essential
+	}
+	if kernel := model.Kernel(); kernel != "" {
+		essential[kernel] = true
+	}
+	if gadget := model.Gadget(); gadget != "" {
+		essential[gadget] = true
+	}
+	return essential
+}
+
+func lastLog(t *state.Task) string {
+	log := t.Log()
+	if len(log) == 0 {
+		return ""
+	}
+	return log[len(log)-1]
+}
+
+// Seeding returns the status of seeding the system, with the progress
+// of each of the snaps being seeded.
+func Seeding(st *state.State) (*SeedingStatus, error) {
+	status := &SeedingStatus{Phase: SeedingWaiting}
+	err := st.Get("seeded", &status.Seeded)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if status.Seeded {
+		status.Phase = SeedingDone
+	}
+
+	chg := seedChange(st)
+	if chg == nil {
+		return status, nil
+	}
+	status.Change = chg.ID()
+
+	essential := essentialSnaps(st)
+	snaps := make(map[string]*SeedingSnap)
+	var order []string
+	for _, t := range chg.Tasks() {
+		tStatus := t.Status()
+		name, configure := seedingTaskSnap(t)
+		if !status.Seeded && status.Phase == SeedingWaiting && !tStatus.Ready() {
+			// the first task still to run tells the phase
+			switch {
+			case t.Kind() == "mark-seeded":
+				status.Phase = SeedingMarkSeeded
+			case essential[name] && configure:
+				status.Phase = SeedingConfigureEssential
+			case essential[name]:
+				status.Phase = SeedingInstallEssential
+			case name != "":
+				status.Phase = SeedingInstall
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		sn := snaps[name]
+		if sn == nil {
+			sn = &SeedingSnap{Name: name, Essential: essential[name]}
+			snaps[name] = sn
+			order = append(order, name)
+		}
+		sn.Total++
+		if tStatus.Ready() {
+			sn.Done++
+		}
+		if tStatus == state.ErrorStatus && sn.Error == "" {
+			sn.Error = lastLog(t)
+		}
+	}
+
+	for _, name := range order {
+		sn := snaps[name]
+		switch {
+		case sn.Error != "":
+			sn.Status = state.ErrorStatus.String()
+		case sn.Done == sn.Total:
+			sn.Status = state.DoneStatus.String()
+		case sn.Done > 0:
+			sn.Status = state.DoingStatus.String()
+		default:
+			sn.Status = state.DoStatus.String()
+		}
+		status.Snaps = append(status.Snaps, *sn)
+	}
+
+	if chg.Status() == state.ErrorStatus || chg.Status() == state.UndoingStatus {
+		status.Phase = SeedingError
+		if err := chg.Err(); err != nil {
+			status.Error = err.Error()
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/errreportstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var errReport = errreportstate.Report
+
+// reportTaskError reports the failure of a task, see
+// state.SetTaskErrorHandler. Hooks are left out as the hook manager
+// reports their failures along with their output.
+func reportTaskError(t *state.Task, err error) {
+	if t.Kind() == "run-hook" {
+		return
+	}
+	var snapName string
+	if snapsup, e := snapstate.TaskSnapSetup(t); e == nil && snapsup.SideInfo != nil {
+		snapName = snapsup.SideInfo.RealName
+	}
+	chg := t.Change()
+	msg := fmt.Sprintf("task %q of change %q failed: %v", t.Kind(), chg.Kind(), err)
+	dupSig := fmt.Sprintf("task:%s:%s:%v", chg.Kind(), t.Kind(), err)
+	extra := map[string]string{
+		"TaskKind":   t.Kind(),
+		"ChangeKind": chg.Kind(),
+	}
+	r := errreport.New("task", snapName, msg, dupSig, extra)
+	st := t.State()
+	// the state is locked by the task runner
+	go func() {
+		if id, err := errReport(st, r); err == nil {
+			logger.Noticef("Reported failure of task %q as %s", r.Extra["TaskKind"], id)
+		} else if err != errreportstate.ErrDisabled {
+			logger.Debugf("Cannot report task failure: %s", err)
+		}
+	}()
+}
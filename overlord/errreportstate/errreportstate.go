@@ -0,0 +1,93 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package errreportstate decides whether and where error reports
+// from snapd are sent, based on the core configuration.
+package errreportstate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ErrDisabled is returned by Report when error reporting is not
+// enabled in the core configuration.
+var ErrDisabled = errors.New("error reporting is disabled")
+
+// Sink returns the sink configured via the error-reporting.enabled
+// and error-reporting.endpoint core options. Reports are only sent
+// once error-reporting.enabled is set to true; without an endpoint
+// they are spooled into dirs.SnapErrorReportsDir. ErrDisabled is
+// returned if error reporting is not enabled.
+// Note that the state must be locked by the caller.
+func Sink(st *state.State) (errreport.Sink, error) {
+	var enabled bool
+	var endpoint string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "error-reporting.enabled", &enabled); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrDisabled
+	}
+	if err := tr.Get("core", "error-reporting.endpoint", &endpoint); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if endpoint == "" {
+		return errreport.NewSpoolSink(dirs.SnapErrorReportsDir), nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid error-reporting.endpoint %q: must be a http or https URL", endpoint)
+	}
+	return errreport.NewHTTPSink(endpoint), nil
+}
+
+// sending counts the reports being sent.
+var sending int32
+
+// Sending returns whether reports are being sent, which would be lost
+// if snapd stopped.
+func Sending() bool {
+	return atomic.LoadInt32(&sending) > 0
+}
+
+// Report sends the report, with private information filtered out, to
+// the configured sink and returns its identifier there. ErrDisabled is
+// returned if error reporting is not enabled.
+// Note that the state must not be locked by the caller.
+func Report(st *state.State, r *errreport.Report) (string, error) {
+	atomic.AddInt32(&sending, 1)
+	defer atomic.AddInt32(&sending, -1)
+
+	st.Lock()
+	sink, err := Sink(st)
+	st.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return sink.Send(r.Filtered())
+}
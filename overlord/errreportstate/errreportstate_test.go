@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package errreportstate_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/errreportstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func TestErrReportState(t *testing.T) { TestingT(t) }
+
+type errReportStateSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&errReportStateSuite{})
+
+func (s *errReportStateSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+}
+
+func (s *errReportStateSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *errReportStateSuite) setConfig(c *C, key string, value interface{}) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", key, value), IsNil)
+	tr.Commit()
+}
+
+func (s *errReportStateSuite) TestDisabledByDefault(c *C) {
+	_, err := errreportstate.Report(s.state, errreport.New("snap", "foo", "msg", "sig", nil))
+	c.Check(err, Equals, errreportstate.ErrDisabled)
+
+	s.setConfig(c, "error-reporting.enabled", false)
+	_, err = errreportstate.Report(s.state, errreport.New("snap", "foo", "msg", "sig", nil))
+	c.Check(err, Equals, errreportstate.ErrDisabled)
+
+	c.Check(osutil.IsDirectory(dirs.SnapErrorReportsDir), Equals, false)
+}
+
+func (s *errReportStateSuite) TestSpoolByDefault(c *C) {
+	s.setConfig(c, "error-reporting.enabled", true)
+
+	id, err := errreportstate.Report(s.state, errreport.New("snap", "foo", "cannot open /home/jdoe/foo", "sig", nil))
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(filepath.Join(dirs.SnapErrorReportsDir, id+".json"))
+	c.Assert(err, IsNil)
+	var r errreport.Report
+	c.Assert(json.Unmarshal(data, &r), IsNil)
+	c.Check(r.Snap, Equals, "foo")
+	c.Check(r.Message, Equals, "cannot open /home/<user>/foo")
+}
+
+func (s *errReportStateSuite) TestEndpoint(c *C) {
+	var r errreport.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Check(json.NewDecoder(req.Body).Decode(&r), IsNil)
+		w.Write([]byte("some-id"))
+	}))
+	defer server.Close()
+
+	s.setConfig(c, "error-reporting.enabled", true)
+	s.setConfig(c, "error-reporting.endpoint", server.URL)
+
+	id, err := errreportstate.Report(s.state, errreport.New("hook", "foo", "mail foo@example.com", "sig", nil))
+	c.Assert(err, IsNil)
+	c.Check(id, Equals, "some-id")
+	c.Check(r.Kind, Equals, "hook")
+	c.Check(r.Message, Equals, "mail <email>")
+}
+
+func (s *errReportStateSuite) TestSending(c *C) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(received)
+		<-release
+		w.Write([]byte("some-id"))
+	}))
+	defer server.Close()
+
+	s.setConfig(c, "error-reporting.enabled", true)
+	s.setConfig(c, "error-reporting.endpoint", server.URL)
+
+	c.Check(errreportstate.Sending(), Equals, false)
+	done := make(chan struct{})
+	go func() {
+		errreportstate.Report(s.state, errreport.New("hook", "foo", "boom", "sig", nil))
+		close(done)
+	}()
+	<-received
+	c.Check(errreportstate.Sending(), Equals, true)
+	close(release)
+	<-done
+	c.Check(errreportstate.Sending(), Equals, false)
+}
+
+func (s *errReportStateSuite) TestInvalidEndpoint(c *C) {
+	s.setConfig(c, "error-reporting.enabled", true)
+	s.setConfig(c, "error-reporting.endpoint", "ftp://example.com")
+
+	_, err := errreportstate.Report(s.state, errreport.New("snap", "foo", "msg", "sig", nil))
+	c.Check(err, ErrorMatches, `invalid error-reporting.endpoint "ftp://example.com": must be a http or https URL`)
+}
@@ -22,11 +22,22 @@ package overlord
 import (
 	"time"
 
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
 )
 
+var ReportTaskError = reportTaskError
+
+// MockErrReport replaces the function sending error reports for tests.
+func MockErrReport(f func(*state.State, *errreport.Report) (string, error)) (restore func()) {
+	old := errReport
+	errReport = f
+	return func() { errReport = old }
+}
+
 // MockEnsureInterval sets the overlord ensure interval for tests.
 func MockEnsureInterval(d time.Duration) (restore func()) {
 	old := ensureInterval
@@ -49,6 +60,19 @@ func MockPruneInterval(prunei, prunew, abortw time.Duration) (restore func()) {
 	}
 }
 
+// MockStuckWatchdog sets the interval of the stuck tasks watchdog and its
+// default threshold for tests.
+func MockStuckWatchdog(interval, threshold time.Duration) (restore func()) {
+	oldInterval := stuckCheckInterval
+	oldThreshold := defaultStuckThreshold
+	stuckCheckInterval = interval
+	defaultStuckThreshold = threshold
+	return func() {
+		stuckCheckInterval = oldInterval
+		defaultStuckThreshold = oldThreshold
+	}
+}
+
 // MockEnsureNext sets o.ensureNext for tests.
 func MockEnsureNext(o *Overlord, t time.Time) {
 	o.ensureNext = t
@@ -66,3 +90,28 @@ func MockSetupStore(new func(*state.State, auth.AuthContext) error) (restore fun
 		setupStore = storestate.SetupStore
 	}
 }
+
+// NewTaskLogArchive returns the task log archive the overlord sets up
+// in the state, keeping its files in dir.
+func NewTaskLogArchive(dir string) state.LogArchive {
+	return &taskLogArchive{dir: dir}
+}
+
+// MockExtras resets the registered extra task kinds and managers for
+// tests.
+func MockExtras() (restore func()) {
+	oldTaskKinds := extraTaskKinds
+	oldManagers := extraManagers
+	extraTaskKinds = nil
+	extraManagers = nil
+	return func() {
+		extraTaskKinds = oldTaskKinds
+		extraManagers = oldManagers
+	}
+}
+
+// AddExtras adds the registered extra task kinds and managers to an
+// overlord created with Mock.
+func (o *Overlord) AddExtras(hookMgr *hookstate.HookManager) error {
+	return o.addExtras(o.State(), hookMgr)
+}
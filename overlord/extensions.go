@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Downstream distributions can add task kinds and state managers to the
+// overlord without patching it, by registering them before the overlord
+// is created, from the init function of a package only built in with
+// their own build tag, for instance with a cmd/snapd/vendor_acme.go of:
+//
+//   // +build acme
+//
+//   package main
+//
+//   import _ "example.com/acme/snapd-acme"
+//
+// where snapd-acme calls RegisterExtraTaskKind and RegisterExtraManager.
+
+// ExtraTaskKind is a task kind added to the overlord by a downstream
+// distribution. Its name must not clash with the kinds of snapd itself,
+// so it is best prefixed with the name of the distribution.
+type ExtraTaskKind struct {
+	Kind string
+	Do   state.HandlerFunc
+	Undo state.HandlerFunc
+	// AffectedSnaps, if set, returns the snaps the task operates on,
+	// making it conflict with the other changes on those snaps the same
+	// way the tasks of snapd do.
+	AffectedSnaps snapstate.AffectedSnapsFunc
+}
+
+// ExtraManagerBuilder builds a state manager added to the overlord by a
+// downstream distribution.
+type ExtraManagerBuilder func(st *state.State, hookMgr *hookstate.HookManager) (StateManager, error)
+
+type extraManager struct {
+	name  string
+	build ExtraManagerBuilder
+}
+
+var (
+	extraTaskKinds []ExtraTaskKind
+	extraManagers  []extraManager
+)
+
+// RegisterExtraTaskKind registers a task kind to be run by the overlord
+// created afterwards. It panics if the kind is already registered.
+func RegisterExtraTaskKind(kind ExtraTaskKind) {
+	if kind.Kind == "" || kind.Do == nil {
+		panic("internal error: extra task kinds need a kind and a do handler")
+	}
+	for _, k := range extraTaskKinds {
+		if k.Kind == kind.Kind {
+			panic(fmt.Sprintf("internal error: extra task kind %q is already registered", kind.Kind))
+		}
+	}
+	extraTaskKinds = append(extraTaskKinds, kind)
+}
+
+// RegisterExtraManager registers a builder of a state manager to be added
+// to the overlord created afterwards, after the managers of snapd. It
+// panics if a manager with the same name is already registered.
+func RegisterExtraManager(name string, build ExtraManagerBuilder) {
+	for _, m := range extraManagers {
+		if m.name == name {
+			panic(fmt.Sprintf("internal error: extra manager %q is already registered", name))
+		}
+	}
+	extraManagers = append(extraManagers, extraManager{name: name, build: build})
+}
+
+// extraTaskManager runs the tasks of the extra task kinds.
+type extraTaskManager struct {
+	runner *state.TaskRunner
+}
+
+// Ensure is part of the overlord.StateManager interface.
+func (m *extraTaskManager) Ensure() error {
+	m.runner.Ensure()
+	return nil
+}
+
+// Wait is part of the overlord.StateManager interface.
+func (m *extraTaskManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop is part of the overlord.StateManager interface.
+func (m *extraTaskManager) Stop() {
+	m.runner.Stop()
+}
+
+// addExtras adds the registered task kinds and managers to the overlord.
+func (o *Overlord) addExtras(s *state.State, hookMgr *hookstate.HookManager) error {
+	if len(extraTaskKinds) > 0 {
+		runner := state.NewTaskRunner(s)
+		for _, kind := range extraTaskKinds {
+			runner.AddHandler(kind.Kind, kind.Do, kind.Undo)
+			if kind.AffectedSnaps != nil {
+				snapstate.AddAffectedSnapsByKind(kind.Kind, kind.AffectedSnaps)
+			}
+		}
+		o.addManager(&extraTaskManager{runner: runner})
+	}
+	for _, m := range extraManagers {
+		var mgr StateManager
+		err := o.timed(m.name, func() (err error) {
+			mgr, err = m.build(s, hookMgr)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("cannot create %s manager: %v", m.name, err)
+		}
+		o.addManager(mgr)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ctlcmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/backupstate"
+)
+
+type backupViewCommand struct {
+	baseCommand
+
+	Positional struct {
+		Action string `positional-arg-name:"<action>" description:"the action to perform (create or remove)"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortBackupViewHelp = i18n.G("The backup-view command manages read-only views of /etc and /var to back up.")
+var longBackupViewHelp = i18n.G(`
+The backup-view command asks snapd for a read-only view of /etc and /var,
+so that they can be backed up without racing with changes to the live
+files, and prints where to find each directory:
+
+    $ snapctl backup-view create
+    [
+    	{
+    		"source": "/etc",
+    		"path": "/var/lib/snapd/backup-views/foo/root/etc",
+    		"snapshot": true
+    	},
+    	...
+    ]
+
+Directories on file systems supporting snapshots are seen as they were
+when the view was created, the others are seen live through the host file
+system. Creating a view replaces the previous one. Once the backup is
+done, the view should be removed with:
+
+    $ snapctl backup-view remove
+
+Views are otherwise removed after a day. The snap must have a connected
+plug of the system-backup interface.
+`)
+
+func init() {
+	addCommand("backup-view", shortBackupViewHelp, longBackupViewHelp, func() command {
+		return &backupViewCommand{}
+	})
+}
+
+func (c *backupViewCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot manage backup view without a context")
+	}
+
+	// snapshots are taken and removed without holding the state lock
+	st := context.State()
+	switch c.Positional.Action {
+	case "create":
+		view, err := backupstate.CreateView(st, context.SnapName())
+		if err != nil {
+			return err
+		}
+		bytes, err := json.MarshalIndent(view.Dirs, "", "\t")
+		if err != nil {
+			return err
+		}
+		c.printf("%s\n", string(bytes))
+		return nil
+	case "remove":
+		return backupstate.RemoveView(st, context.SnapName())
+	}
+	return fmt.Errorf(i18n.G("unknown backup-view action %q"), c.Positional.Action)
+}
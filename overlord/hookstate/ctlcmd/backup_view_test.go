@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type backupViewSuite struct {
+	state       *state.State
+	mockContext *hookstate.Context
+}
+
+var _ = Suite(&backupViewSuite{})
+
+const backupViewYaml = `name: test-snap
+version: 1
+plugs:
+ system-backup:
+`
+
+func (s *backupViewSuite) SetUpTest(c *C) {
+	// the root has no /etc nor /var, which are seen live then
+	dirs.SetRootDir(c.MkDir())
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, backupViewYaml, "", si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	s.state.Set("conns", map[string]interface{}{
+		"test-snap:system-backup core:system-backup": map[string]interface{}{"interface": "system-backup"},
+	})
+
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+}
+
+func (s *backupViewSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *backupViewSuite) TestBackupViewCreateAndRemove(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"backup-view", "create"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `[
+	{
+		"source": "/etc",
+		"path": "/var/lib/snapd/hostfs/etc",
+		"snapshot": false
+	},
+	{
+		"source": "/var",
+		"path": "/var/lib/snapd/hostfs/var",
+		"snapshot": false
+	}
+]
+`)
+	c.Check(string(stderr), Equals, "")
+
+	var views map[string]interface{}
+	s.state.Lock()
+	c.Assert(s.state.Get("backup-views", &views), IsNil)
+	s.state.Unlock()
+	c.Check(views["test-snap"], NotNil)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"backup-view", "remove"})
+	c.Assert(err, IsNil)
+
+	views = nil
+	s.state.Lock()
+	c.Assert(s.state.Get("backup-views", &views), IsNil)
+	s.state.Unlock()
+	c.Check(views, HasLen, 0)
+}
+
+func (s *backupViewSuite) TestBackupViewNotConnected(c *C) {
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{})
+	s.state.Unlock()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"backup-view", "create"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has no connected system-backup plug`)
+}
+
+func (s *backupViewSuite) TestBackupViewUnknownAction(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"backup-view", "list"})
+	c.Check(err, ErrorMatches, `unknown backup-view action "list"`)
+}
+
+func (s *backupViewSuite) TestBackupViewWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"backup-view", "create"})
+	c.Check(err, ErrorMatches, ".*cannot manage backup view without a context.*")
+}
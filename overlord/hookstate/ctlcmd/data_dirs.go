@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+type dataDirsCommand struct {
+	baseCommand
+}
+
+var shortDataDirsHelp = i18n.G("The data-dirs command prints the data directories to migrate.")
+var longDataDirsHelp = i18n.G(`
+The data-dirs command prints, from the migrate-data hook, the system data
+directories of the revision being refreshed from and of the one being
+refreshed to, along with the data directory shared by both:
+
+    $ snapctl data-dirs
+    {
+    	"common": "/var/snap/foo/common",
+    	"new": "/var/snap/foo/12",
+    	"old": "/var/snap/foo/11"
+    }
+
+The data of the old revision was already copied to the new directory, the
+hook is expected to transform it in place.
+`)
+
+func init() {
+	addCommand("data-dirs", shortDataDirsHelp, longDataDirsHelp, func() command {
+		return &dataDirsCommand{}
+	})
+}
+
+func (c *dataDirsCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil || context.HookName() != "migrate-data" {
+		return fmt.Errorf("cannot use data-dirs outside of the migrate-data hook")
+	}
+
+	st := context.State()
+	st.Lock()
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, context.SnapName(), &snapst)
+	st.Unlock()
+	if err != nil {
+		return fmt.Errorf("cannot get state of snap %q: %v", context.SnapName(), err)
+	}
+
+	oldInfo := snap.MinimalPlaceInfo(context.SnapName(), snapst.Current)
+	newInfo := snap.MinimalPlaceInfo(context.SnapName(), context.SnapRevision())
+	bytes, err := json.MarshalIndent(map[string]string{
+		"old":    oldInfo.DataDir(),
+		"new":    newInfo.DataDir(),
+		"common": newInfo.CommonDataDir(),
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	c.printf("%s\n", string(bytes))
+	return nil
+}
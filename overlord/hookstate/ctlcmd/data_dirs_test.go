@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type dataDirsSuite struct {
+	st *state.State
+}
+
+var _ = Suite(&dataDirsSuite{})
+
+func (s *dataDirsSuite) SetUpTest(c *C) {
+	dirs.SetRootDir("/")
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(s.st, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+}
+
+func (s *dataDirsSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *dataDirsSuite) mockContext(c *C, hook string) *hookstate.Context {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(2), Hook: hook}
+	context, err := hookstate.NewContext(task, s.st, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	return context
+}
+
+func (s *dataDirsSuite) TestDataDirs(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext(c, "migrate-data"), []string{"data-dirs"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `{
+	"common": "/var/snap/test-snap/common",
+	"new": "/var/snap/test-snap/2",
+	"old": "/var/snap/test-snap/1"
+}
+`)
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *dataDirsSuite) TestDataDirsOutsideMigrateDataHook(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext(c, "configure"), []string{"data-dirs"})
+	c.Check(err, ErrorMatches, "cannot use data-dirs outside of the migrate-data hook")
+
+	_, _, err = ctlcmd.Run(nil, []string{"data-dirs"})
+	c.Check(err, ErrorMatches, "cannot use data-dirs outside of the migrate-data hook")
+}
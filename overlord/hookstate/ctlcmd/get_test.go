@@ -290,6 +290,36 @@ func (s *getAttrSuite) TestForcePlugOrSlotMutuallyExclusive(c *C) {
 	c.Check(string(stderr), Equals, "")
 }
 
+func (s *getAttrSuite) TestGetAttributesInDisconnectHooks(c *C) {
+	st := s.mockPlugHookContext.State()
+	var attrsTaskID string
+	s.mockPlugHookContext.Lock()
+	c.Assert(s.mockPlugHookContext.Get("attrs-task", &attrsTaskID), IsNil)
+	s.mockPlugHookContext.Unlock()
+
+	for _, t := range []struct {
+		hook, plugOrSlot, attr, expected string
+	}{
+		{"disconnect-plug-aplug", ":aplug", "aattr", "foo\n"},
+		{"disconnect-slot-bslot", ":bslot", "battr", "bar\n"},
+	} {
+		st.Lock()
+		task := st.NewTask("run-hook", "my test task")
+		st.Unlock()
+		setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: t.hook}
+		context, err := hookstate.NewContext(task, st, setup, s.mockHandler, "")
+		c.Assert(err, IsNil)
+		context.Lock()
+		context.Set("attrs-task", attrsTaskID)
+		context.Unlock()
+
+		stdout, stderr, err := ctlcmd.Run(context, []string{"get", t.plugOrSlot, t.attr})
+		c.Check(err, IsNil)
+		c.Check(string(stdout), Equals, t.expected)
+		c.Check(string(stderr), Equals, "")
+	}
+}
+
 func (s *getAttrSuite) TestPlugOrSlotEmpty(c *C) {
 	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":", "foo"})
 	c.Check(err.Error(), Equals, "plug or slot name not provided")
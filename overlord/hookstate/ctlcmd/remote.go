@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type remoteCommand struct {
+	baseCommand
+
+	Positional struct {
+		PlugOrSlotSpec string   `positional-arg-name:":<plug|slot>" required:"yes"`
+		Keys           []string `positional-arg-name:"<keys>" description:"keys of the details to print"`
+	} `positional-args:"yes"`
+}
+
+var shortRemoteHelp = i18n.G("The remote command prints details about the other end of a connection.")
+var longRemoteHelp = i18n.G(`
+The remote command prints, from the prepare- and connect- interface hooks,
+details about the snap at the other end of the connection being made, for
+the hook to decide whether to accept it:
+
+    $ snapctl remote :myslot
+    {
+    	"name": "myplug",
+    	"publisher": "canonical",
+    	"publisher-validation": "verified",
+    	"snap": "other-snap",
+    	"static-attrs": {
+    		"usb-vendor": 4660
+    	}
+    }
+
+The static attributes are the ones the remote plug or slot declares, before
+any change by its own prepare- hook. A single detail may be printed with:
+
+    $ snapctl remote :myslot publisher
+    canonical
+
+The publisher is empty, and its validation "unproven", for snaps that do
+not come from the store.
+`)
+
+func init() {
+	addCommand("remote", shortRemoteHelp, longRemoteHelp, func() command {
+		return &remoteCommand{}
+	})
+}
+
+func (c *remoteCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot get remote details without a context")
+	}
+
+	hookType, err := interfaceHookType(context.HookName())
+	if err != nil {
+		return fmt.Errorf(i18n.G("remote details can only be read during the execution of interface hooks"))
+	}
+
+	spec := c.Positional.PlugOrSlotSpec
+	if !strings.HasPrefix(spec, ":") || len(spec) == 1 {
+		return fmt.Errorf(i18n.G("plug or slot must be given as :<plug|slot>, not %q"), spec)
+	}
+	plugOrSlot := spec[1:]
+
+	attrsTask, err := attributesTask(context)
+	if err != nil {
+		return err
+	}
+
+	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook || hookType == disconnectPlugHook)
+	if err := validatePlugOrSlot(attrsTask, isPlugSide, plugOrSlot); err != nil {
+		return err
+	}
+
+	st := context.State()
+	st.Lock()
+	details, err := remoteDetails(attrsTask, isPlugSide)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(c.Positional.Keys) == 0 {
+		return c.printJSON(details)
+	}
+	picked := make(map[string]interface{}, len(c.Positional.Keys))
+	for _, key := range c.Positional.Keys {
+		value, ok := details[key]
+		if !ok {
+			return fmt.Errorf(i18n.G("unknown remote detail %q"), key)
+		}
+		picked[key] = value
+	}
+	if len(c.Positional.Keys) == 1 {
+		value := picked[c.Positional.Keys[0]]
+		if s, ok := value.(string); ok {
+			c.printf("%s\n", s)
+			return nil
+		}
+		return c.printJSON(value)
+	}
+	return c.printJSON(picked)
+}
+
+func (c *remoteCommand) printJSON(v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	c.printf("%s\n", string(bytes))
+	return nil
+}
+
+// remoteDetails returns the details of the other end of the connection
+// the given attributes task is making, the slot one when plugSide is
+// set and the plug one otherwise.
+func remoteDetails(attrsTask *state.Task, plugSide bool) (map[string]interface{}, error) {
+	var snapName, name, which string
+	if plugSide {
+		var slotRef interfaces.SlotRef
+		if err := attrsTask.Get("slot", &slotRef); err != nil {
+			return nil, fmt.Errorf(i18n.G("internal error: cannot find slot data in the appropriate task"))
+		}
+		snapName, name, which = slotRef.Snap, slotRef.Name, "slot-static-attrs"
+	} else {
+		var plugRef interfaces.PlugRef
+		if err := attrsTask.Get("plug", &plugRef); err != nil {
+			return nil, fmt.Errorf(i18n.G("internal error: cannot find plug data in the appropriate task"))
+		}
+		snapName, name, which = plugRef.Snap, plugRef.Name, "plug-static-attrs"
+	}
+
+	attrs := make(map[string]interface{})
+	if err := attrsTask.Get(which, &attrs); err != nil && err != state.ErrNoState {
+		return nil, fmt.Errorf(i18n.G("internal error: cannot get %s from appropriate task"), which)
+	}
+
+	publisher, validation := "", "unproven"
+	info, err := snapstate.CurrentInfo(attrsTask.State(), snapName)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot get details of snap %q: %v"), snapName, err)
+	}
+	if info.SnapID != "" {
+		acct, err := assertstate.Publisher(attrsTask.State(), info.SnapID)
+		if err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot get the publisher of snap %q: %v"), snapName, err)
+		}
+		publisher = acct.Username()
+		if acct.IsCertified() {
+			validation = "verified"
+		}
+	}
+
+	return map[string]interface{}{
+		"snap":                 snapName,
+		"name":                 name,
+		"publisher":            publisher,
+		"publisher-validation": validation,
+		"static-attrs":         attrs,
+	}, nil
+}
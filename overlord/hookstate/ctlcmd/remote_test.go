@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type remoteSuite struct {
+	st        *state.State
+	attrsTask *state.Task
+}
+
+var _ = Suite(&remoteSuite{})
+
+func (s *remoteSuite) SetUpTest(c *C) {
+	storeSigning := assertstest.NewStoreStack("canonical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(storeSigning.StoreAccountKey("")), IsNil)
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+	assertstate.ReplaceDB(s.st, db)
+
+	// the plug snap comes from the store, the slot one does not
+	acct := assertstest.NewAccount(storeSigning, "acme", map[string]interface{}{
+		"account-id": "acmeid",
+		"validation": "certified",
+	}, "")
+	c.Assert(db.Add(acct), IsNil)
+	plugSnapID := "a" + strings.Repeat("id", 16)[1:]
+	snapDecl, err := storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-name":    "a",
+		"publisher-id": "acmeid",
+		"snap-id":      plugSnapID,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(snapDecl), IsNil)
+
+	for name, snapID := range map[string]string{"a": plugSnapID, "b": ""} {
+		si := &snap.SideInfo{RealName: name, SnapID: snapID, Revision: snap.R(1)}
+		snapstate.Set(s.st, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{si},
+			Current:  si.Revision,
+		})
+	}
+
+	chg := s.st.NewChange("mychange", "mychange")
+	s.attrsTask = s.st.NewTask("connect", "my connect task")
+	s.attrsTask.Set("plug", &interfaces.PlugRef{Snap: "a", Name: "aplug"})
+	s.attrsTask.Set("slot", &interfaces.SlotRef{Snap: "b", Name: "bslot"})
+	// the attributes as changed by the prepare- hooks
+	s.attrsTask.Set("plug-attrs", map[string]interface{}{"aattr": "changed"})
+	s.attrsTask.Set("slot-attrs", map[string]interface{}{"battr": "changed"})
+	s.attrsTask.Set("plug-static-attrs", map[string]interface{}{"aattr": "foo"})
+	s.attrsTask.Set("slot-static-attrs", map[string]interface{}{"battr": "bar"})
+	chg.AddTask(s.attrsTask)
+}
+
+func (s *remoteSuite) mockContext(c *C, snapName, hook string) *hookstate.Context {
+	s.st.Lock()
+	task := s.st.NewTask("run-hook", "my test task")
+	s.st.Unlock()
+	setup := &hookstate.HookSetup{Snap: snapName, Revision: snap.R(1), Hook: hook}
+	context, err := hookstate.NewContext(task, s.st, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	context.Set("attrs-task", s.attrsTask.ID())
+	return context
+}
+
+func (s *remoteSuite) TestRemoteOfSlotSide(c *C) {
+	context := s.mockContext(c, "b", "prepare-slot-bslot")
+	stdout, stderr, err := ctlcmd.Run(context, []string{"remote", ":bslot"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `{
+	"name": "aplug",
+	"publisher": "acme",
+	"publisher-validation": "verified",
+	"snap": "a",
+	"static-attrs": {
+		"aattr": "foo"
+	}
+}
+`)
+	c.Check(string(stderr), Equals, "")
+
+	stdout, _, err = ctlcmd.Run(context, []string{"remote", ":bslot", "publisher"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "acme\n")
+
+	stdout, _, err = ctlcmd.Run(context, []string{"remote", ":bslot", "snap", "publisher-validation"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "{\n\t\"publisher-validation\": \"verified\",\n\t\"snap\": \"a\"\n}\n")
+}
+
+func (s *remoteSuite) TestRemoteOfPlugSideUnasserted(c *C) {
+	context := s.mockContext(c, "a", "connect-plug-aplug")
+	stdout, _, err := ctlcmd.Run(context, []string{"remote", ":aplug", "static-attrs"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "{\n\t\"battr\": \"bar\"\n}\n")
+
+	stdout, _, err = ctlcmd.Run(context, []string{"remote", ":aplug", "publisher", "publisher-validation"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "{\n\t\"publisher\": \"\",\n\t\"publisher-validation\": \"unproven\"\n}\n")
+}
+
+func (s *remoteSuite) TestRemoteErrors(c *C) {
+	context := s.mockContext(c, "b", "connect-slot-bslot")
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"remote", "bslot"}, `plug or slot must be given as :<plug|slot>, not "bslot"`},
+		{[]string{"remote", ":"}, `plug or slot must be given as :<plug|slot>, not ":"`},
+		{[]string{"remote", ":aplug"}, `unknown plug or slot "aplug"`},
+		{[]string{"remote", ":bslot", "foo"}, `unknown remote detail "foo"`},
+	} {
+		_, _, err := ctlcmd.Run(context, t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+
+	_, _, err := ctlcmd.Run(s.mockContext(c, "b", "configure"), []string{"remote", ":bslot"})
+	c.Check(err, ErrorMatches, "remote details can only be read during the execution of interface hooks")
+
+	_, _, err = ctlcmd.Run(nil, []string{"remote", ":bslot"})
+	c.Check(err, ErrorMatches, "cannot get remote details without a context")
+}
@@ -0,0 +1,84 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/secretstate"
+)
+
+type secretCommand struct {
+	baseCommand
+
+	Positional struct {
+		Action string `positional-arg-name:"<action>" description:"the action to perform (get)"`
+		Name   string `positional-arg-name:"<name>" description:"the name of the secret"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortSecretHelp = i18n.G("The secret command prints secrets the snap was granted access to.")
+var longSecretHelp = i18n.G(`
+The secret command prints the value of a secret stored in snapd.
+
+    $ snapctl secret get db-password
+    s3cr3t
+
+The snap must have a connected plug of the secrets interface listing the
+secret name in its "secrets" attribute.
+`)
+
+func init() {
+	addCommand("secret", shortSecretHelp, longSecretHelp, func() command {
+		return &secretCommand{}
+	})
+}
+
+func (c *secretCommand) Execute(args []string) error {
+	if c.Positional.Action != "get" {
+		return fmt.Errorf(i18n.G("unknown secret action %q"), c.Positional.Action)
+	}
+
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot get secret without a context")
+	}
+
+	st := context.State()
+	st.Lock()
+	name := c.Positional.Name
+	ok, err := secretstate.SnapCanRead(st, context.SnapName(), name)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("snap %q has no access to secret %q", context.SnapName(), name)
+	}
+
+	// the backend may run a command, so not with the state locked
+	value, err := secretstate.Get(st, name)
+	if err != nil {
+		return err
+	}
+	c.printf("%s\n", value)
+	return nil
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/secretstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type secretSuite struct {
+	mockContext *hookstate.Context
+}
+
+var _ = Suite(&secretSuite{})
+
+const secretConsumerYaml = `name: test-snap
+version: 1
+plugs:
+ secrets:
+  secrets: [db-password, missing]
+`
+
+func (s *secretSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, secretConsumerYaml, "", si)
+	snapstate.Set(st, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	st.Set("conns", map[string]interface{}{
+		"test-snap:secrets core:secrets": map[string]interface{}{"interface": "secrets"},
+	})
+	st.Unlock()
+	c.Assert(secretstate.Set(st, "db-password", []byte("s3cr3t")), IsNil)
+	c.Assert(secretstate.Set(st, "api-token", []byte("t0k3n")), IsNil)
+	st.Lock()
+
+	task := st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, st, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+}
+
+func (s *secretSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *secretSuite) TestSecretGet(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"secret", "get", "db-password"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "s3cr3t\n")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *secretSuite) TestSecretGetNoAccess(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"secret", "get", "api-token"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has no access to secret "api-token"`)
+}
+
+func (s *secretSuite) TestSecretGetMissing(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"secret", "get", "missing"})
+	c.Check(err, ErrorMatches, `cannot find secret "missing"`)
+}
+
+func (s *secretSuite) TestSecretUnknownAction(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"secret", "set", "db-password"})
+	c.Check(err, ErrorMatches, `unknown secret action "set"`)
+}
+
+func (s *secretSuite) TestSecretWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"secret", "get", "db-password"})
+	c.Check(err, ErrorMatches, ".*cannot get secret without a context.*")
+}
@@ -21,6 +21,9 @@ package hookstate
 
 import (
 	"time"
+
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 func MockReadlink(f func(string) (string, error)) func() {
@@ -39,8 +42,19 @@ func MockDefaultHookTimeout(timeout time.Duration) func() {
 	}
 }
 
-func MockErrtrackerReport(mock func(string, string, string, map[string]string) (string, error)) (restore func()) {
-	prev := errtrackerReport
-	errtrackerReport = mock
-	return func() { errtrackerReport = prev }
+func MockErrReport(mock func(*state.State, *errreport.Report) (string, error)) (restore func()) {
+	prev := errReport
+	errReport = mock
+	return func() { errReport = prev }
+}
+
+func MockNetworkWait(timeout, retryInterval time.Duration) (restore func()) {
+	oldTimeout := networkWaitTimeout
+	oldRetryInterval := networkRetryInterval
+	networkWaitTimeout = timeout
+	networkRetryInterval = retryInterval
+	return func() {
+		networkWaitTimeout = oldTimeout
+		networkRetryInterval = oldRetryInterval
+	}
 }
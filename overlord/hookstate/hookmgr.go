@@ -31,11 +31,13 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
-	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/errreportstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/storestate"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -201,12 +203,24 @@ func hookSetup(task *state.Task) (*HookSetup, *snapstate.SnapState, error) {
 func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	task.State().Lock()
 	hooksup, snapst, err := hookSetup(task)
+	var snapsup *snapstate.SnapSetup
+	if err == nil {
+		snapsup, _ = snapstate.TaskSnapSetup(task)
+	}
 	task.State().Unlock()
 	if err != nil {
 		return err
 	}
 
-	info, err := snapst.CurrentInfo()
+	var info *snap.Info
+	if snapsup != nil && snapsup.SideInfo != nil && !snapsup.Revision().Unset() && snapsup.Revision() != snapst.Current {
+		// hooks running while a new revision is being set up, before
+		// it's made current, run from that revision
+		info, err = snap.ReadInfo(hooksup.Snap, snapsup.SideInfo)
+		hooksup.Revision = snapsup.Revision()
+	} else {
+		info, err = snapst.CurrentInfo()
+	}
 	if err != nil {
 		return fmt.Errorf("cannot read %q snap details: %v", hooksup.Snap, err)
 	}
@@ -216,6 +230,15 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 		return fmt.Errorf("snap %q has no %q hook", hooksup.Snap, hooksup.Hook)
 	}
 
+	// on timeout the hook fails as if it had run and failed
+	var networkErr error
+	if hookExists && info.Hooks[hooksup.Hook].RequiresNetwork {
+		networkErr = waitForNetwork(task, hooksup)
+		if _, ok := networkErr.(*state.Retry); ok {
+			return networkErr
+		}
+	}
+
 	context, err := NewContext(task, task.State(), hooksup, nil, "")
 	if err != nil {
 		return err
@@ -257,9 +280,13 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	}
 
 	if hookExists {
-		output, err := runHook(context, tomb)
+		var output []byte
+		err := networkErr
+		if err == nil {
+			output, err = runHook(context, tomb)
+		}
 		if err != nil {
-			if hooksup.TrackError {
+			if hooksup.TrackError && networkErr == nil {
 				trackHookError(context, output, err)
 			}
 			err = osutil.OutputErr(output, err)
@@ -290,6 +317,55 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	return nil
 }
 
+// networkWaitTimeout is how long a hook declaring requires-network
+// waits for network connectivity before failing.
+var networkWaitTimeout = 10 * time.Minute
+
+// networkRetryInterval is how often connectivity is checked again
+// while a hook waits for it.
+var networkRetryInterval = 30 * time.Second
+
+// waitForNetwork returns nil once the store can be reached, a
+// state.Retry to check again later or, once the hook has been waiting
+// for longer than networkWaitTimeout, an error.
+func waitForNetwork(task *state.Task, hooksup *HookSetup) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	theStore := storestate.Store(st)
+	st.Unlock()
+	status, err := theStore.ConnectivityCheck()
+	st.Lock()
+	if err != nil {
+		logger.Debugf("Cannot check network connectivity: %v", err)
+	}
+	if err == nil && len(status) > 0 {
+		connected := true
+		for _, reachable := range status {
+			connected = connected && reachable
+		}
+		if connected {
+			return nil
+		}
+	}
+
+	var since time.Time
+	err = task.Get("network-wait-since", &since)
+	if err == state.ErrNoState {
+		since = time.Now()
+		task.Set("network-wait-since", since)
+		task.Logf("Waiting for network connectivity before running hook %q", hooksup.Hook)
+	} else if err != nil {
+		return err
+	}
+
+	if time.Since(since) >= networkWaitTimeout {
+		return fmt.Errorf("no network connectivity after waiting for %v", networkWaitTimeout)
+	}
+	return &state.Retry{After: networkRetryInterval}
+}
+
 func runHookImpl(c *Context, tomb *tomb.Tomb) ([]byte, error) {
 	return runHookAndWait(c.SnapName(), c.SnapRevision(), c.HookName(), c.ID(), c.Timeout(), tomb)
 }
@@ -349,7 +425,7 @@ func runHookAndWait(snapName string, revision snap.Revision, hookName, hookConte
 	return osutil.RunAndWait(argv, env, timeout, tomb)
 }
 
-var errtrackerReport = errtracker.Report
+var errReport = errreportstate.Report
 
 func trackHookError(context *Context, output []byte, err error) {
 	errmsg := fmt.Sprintf("hook %s in snap %q failed: %v", context.HookName(), context.SnapName(), osutil.OutputErr(output, err))
@@ -360,10 +436,11 @@ func trackHookError(context *Context, output []byte, err error) {
 	if context.setup.IgnoreError {
 		extra["IgnoreError"] = "1"
 	}
-	oopsid, err := errtrackerReport(context.SnapName(), errmsg, dupSig, extra)
+	r := errreport.New("hook", context.SnapName(), errmsg, dupSig, extra)
+	reportid, err := errReport(context.State(), r)
 	if err == nil {
-		logger.Noticef("Reported hook failure from %q for snap %q as %s", context.HookName(), context.SnapName(), oopsid)
-	} else {
+		logger.Noticef("Reported hook failure from %q for snap %q as %s", context.HookName(), context.SnapName(), reportid)
+	} else if err != errreportstate.ErrDisabled {
 		logger.Debugf("Cannot report hook failure: %s", err)
 	}
 }
@@ -20,6 +20,7 @@ package hookstate
 import (
 	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -29,6 +30,8 @@ import (
 func init() {
 	snapstate.SetupInstallHook = SetupInstallHook
 	snapstate.SetupPostRefreshHook = SetupPostRefreshHook
+	snapstate.SetupCheckHealthHook = SetupCheckHealthHook
+	snapstate.SetupMigrateDataHook = SetupMigrateDataHook
 	snapstate.SetupRemoveHook = SetupRemoveHook
 }
 
@@ -58,6 +61,42 @@ func SetupPostRefreshHook(st *state.State, snapName string) *state.Task {
 	return task
 }
 
+// SetupCheckHealthHook returns a task running the check-health hook of
+// a refreshed snap, if present. The hook has the given timeout to exit
+// successfully, otherwise the snap is considered unhealthy and the
+// refresh is undone.
+func SetupCheckHealthHook(st *state.State, snapName string, timeout time.Duration) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "check-health",
+		Optional: true,
+		Timeout:  timeout,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run check-health hook of %q snap if present"), hooksup.Snap)
+	task := HookTask(st, summary, hooksup, nil)
+
+	return task
+}
+
+// SetupMigrateDataHook returns a task running the migrate-data hook of
+// a snap being refreshed across epochs, if present. The hook runs from
+// the new revision before it's made current, and can find the data
+// directories of both revisions with "snapctl data-dirs". If it fails
+// the refresh is undone.
+func SetupMigrateDataHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "migrate-data",
+		Optional: true,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run migrate-data hook of %q snap if present"), hooksup.Snap)
+	task := HookTask(st, summary, hooksup, nil)
+
+	return task
+}
+
 type snapHookHandler struct {
 }
 
@@ -73,6 +112,17 @@ func (h *snapHookHandler) Error(err error) error {
 	return nil
 }
 
+// checkHealthHandler turns a failure of the check-health hook into an
+// error explaining that the refresh is being undone.
+type checkHealthHandler struct {
+	snapHookHandler
+	context *Context
+}
+
+func (h *checkHealthHandler) Error(err error) error {
+	return fmt.Errorf("snap %q reported unhealthy after refresh, reverting to the previous revision: %v", h.context.SnapName(), err)
+}
+
 func SetupRemoveHook(st *state.State, snapName string) *state.Task {
 	hooksup := &HookSetup{
 		Snap:        snapName,
@@ -95,4 +145,8 @@ func setupHooks(hookMgr *HookManager) {
 	hookMgr.Register(regexp.MustCompile("^install$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^post-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^migrate-data$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^check-health$"), func(context *Context) Handler {
+		return &checkHealthHandler{context: context}
+	})
 }
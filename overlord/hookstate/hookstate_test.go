@@ -25,6 +25,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -32,13 +33,16 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/storestate"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store/storetest"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -129,7 +133,7 @@ func (s *hookManagerSuite) SetUpTest(c *C) {
 		s.context = context
 		return s.mockHandler
 	})
-	s.AddCleanup(hookstate.MockErrtrackerReport(func(string, string, string, map[string]string) (string, error) {
+	s.AddCleanup(hookstate.MockErrReport(func(*state.State, *errreport.Report) (string, error) {
 		return "", nil
 	}))
 }
@@ -216,6 +220,27 @@ func (s *hookManagerSuite) TestHookTaskEnsure(c *C) {
 	c.Check(s.change.Status(), Equals, state.DoneStatus)
 }
 
+func (s *hookManagerSuite) TestHookTaskEnsureFromNewRevision(c *C) {
+	s.state.Lock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", SnapID: "some-snap-id", Revision: snap.R(2)}
+	snaptest.MockSnap(c, snapYaml, snapContents, sideInfo)
+	s.task.Set("snap-setup", &snapstate.SnapSetup{SideInfo: sideInfo})
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(s.context, NotNil)
+	c.Check(s.context.SnapRevision(), Equals, snap.R(2))
+	c.Check(s.command.Calls(), DeepEquals, [][]string{{
+		"snap", "run", "--hook", "configure", "-r", "2", "test-snap",
+	}})
+	c.Check(s.change.Status(), Equals, state.DoneStatus)
+}
+
 func (s *hookManagerSuite) TestHookTaskInitializesContext(c *C) {
 	s.manager.Ensure()
 	s.manager.Wait()
@@ -667,13 +692,15 @@ func (s *hookManagerSuite) TestHookTaskHandlerReportsErrorIfRequested(c *C) {
 	s.task.Set("hook-setup", &hooksup)
 	s.state.Unlock()
 
-	errtrackerCalled := false
-	hookstate.MockErrtrackerReport(func(snap, errmsg, dupSig string, extra map[string]string) (string, error) {
-		c.Check(snap, Equals, "test-snap")
-		c.Check(errmsg, Equals, "hook configure in snap \"test-snap\" failed: hook failed at user request")
-		c.Check(dupSig, Equals, "hook:test-snap:configure:exit status 1\nhook failed at user request\n")
+	errReportCalled := false
+	hookstate.MockErrReport(func(st *state.State, r *errreport.Report) (string, error) {
+		c.Check(st, Equals, s.state)
+		c.Check(r.Kind, Equals, "hook")
+		c.Check(r.Snap, Equals, "test-snap")
+		c.Check(r.Message, Equals, "hook configure in snap \"test-snap\" failed: hook failed at user request")
+		c.Check(r.DuplicateSignature, Equals, "hook:test-snap:configure:exit status 1\nhook failed at user request\n")
 
-		errtrackerCalled = true
+		errReportCalled = true
 		return "some-oopsid", nil
 	})
 
@@ -688,7 +715,7 @@ func (s *hookManagerSuite) TestHookTaskHandlerReportsErrorIfRequested(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	c.Check(errtrackerCalled, Equals, true)
+	c.Check(errReportCalled, Equals, true)
 }
 
 func (s *hookManagerSuite) TestHookTasksForSameSnapAreSerialized(c *C) {
@@ -842,3 +869,161 @@ func (s *hookManagerSuite) TestHookTasksForDifferentSnapsRunConcurrently(c *C) {
 	c.Assert(testSnap1HookCalls, Equals, 1)
 	c.Assert(testSnap2HookCalls, Equals, 1)
 }
+
+func (s *hookManagerSuite) TestCheckHealthHookUnhealthy(c *C) {
+	cmd := testutil.MockCommand(c, "snap", ">&2 echo 'database unreachable'; exit 1")
+	defer cmd.Restore()
+
+	s.state.Lock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, "name: test-snap\nversion: 1.0\nhooks:\n    check-health:\n", snapContents, sideInfo)
+	task := hookstate.SetupCheckHealthHook(s.state, "test-snap", 90*time.Second)
+	change := s.state.NewChange("refresh", "refresh a snap")
+	change.AddTask(task)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(cmd.Calls(), testutil.DeepContains, []string{
+		"snap", "run", "--hook", "check-health", "-r", "unset", "test-snap",
+	})
+	c.Check(task.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*snap "test-snap" reported unhealthy after refresh, reverting to the previous revision: .*database unreachable.*`)
+}
+
+func (s *hookManagerSuite) TestCheckHealthHookTimeout(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task := hookstate.SetupCheckHealthHook(s.state, "test-snap", 90*time.Second)
+	c.Check(task.Summary(), Equals, `Run check-health hook of "test-snap" snap if present`)
+
+	var hooksup hookstate.HookSetup
+	c.Assert(task.Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup, DeepEquals, hookstate.HookSetup{
+		Snap:     "test-snap",
+		Hook:     "check-health",
+		Optional: true,
+		Timeout:  90 * time.Second,
+	})
+}
+
+type connectivityStore struct {
+	storetest.Store
+
+	mu        sync.Mutex
+	reachable bool
+}
+
+func (s *connectivityStore) setReachable(reachable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reachable = reachable
+}
+
+func (s *connectivityStore) ConnectivityCheck() (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]bool{
+		"api.snapcraft.io":       true,
+		"assertions.example.com": s.reachable,
+	}, nil
+}
+
+func (s *hookManagerSuite) mockRequiresNetwork(c *C, reachable bool) *connectivityStore {
+	sto := &connectivityStore{reachable: reachable}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	storestate.ReplaceStore(s.state, sto)
+	sideInfo := &snap.SideInfo{RealName: "test-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, "name: test-snap\nversion: 1.0\nhooks:\n    configure:\n        requires-network: true\n", snapContents, sideInfo)
+
+	return sto
+}
+
+func (s *hookManagerSuite) TestHookRequiresNetworkConnected(c *C) {
+	s.mockRequiresNetwork(c, true)
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.command.Calls(), HasLen, 1)
+	c.Check(s.mockHandler.DoneCalled, Equals, true)
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	c.Check(s.task.Log(), HasLen, 0)
+}
+
+func (s *hookManagerSuite) TestHookRequiresNetworkWaits(c *C) {
+	restore := hookstate.MockNetworkWait(time.Hour, time.Millisecond)
+	defer restore()
+	sto := s.mockRequiresNetwork(c, false)
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	c.Check(s.command.Calls(), HasLen, 0)
+	c.Check(s.mockHandler.BeforeCalled, Equals, false)
+	c.Check(s.task.Status(), Equals, state.DoingStatus)
+	checkTaskLogContains(c, s.task, `.*Waiting for network connectivity before running hook "configure"`)
+	s.state.Unlock()
+
+	sto.setReachable(true)
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.command.Calls(), HasLen, 1)
+	c.Check(s.mockHandler.DoneCalled, Equals, true)
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	c.Check(s.task.Log(), HasLen, 1)
+}
+
+func (s *hookManagerSuite) TestHookRequiresNetworkTimeout(c *C) {
+	restore := hookstate.MockNetworkWait(time.Nanosecond, time.Millisecond)
+	defer restore()
+	s.mockRequiresNetwork(c, false)
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.command.Calls(), HasLen, 0)
+	c.Check(s.mockHandler.BeforeCalled, Equals, true)
+	c.Check(s.mockHandler.ErrorCalled, Equals, true)
+	c.Check(s.mockHandler.DoneCalled, Equals, false)
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	c.Check(s.change.Err(), ErrorMatches, `(?s).*run hook "configure": no network connectivity after waiting for 1ns.*`)
+}
+
+func (s *hookManagerSuite) TestHookRequiresNetworkTimeoutIgnoreError(c *C) {
+	restore := hookstate.MockNetworkWait(time.Nanosecond, time.Millisecond)
+	defer restore()
+	s.mockRequiresNetwork(c, false)
+
+	s.state.Lock()
+	var hooksup hookstate.HookSetup
+	s.task.Get("hook-setup", &hooksup)
+	hooksup.IgnoreError = true
+	s.task.Set("hook-setup", &hooksup)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.command.Calls(), HasLen, 0)
+	c.Check(s.mockHandler.DoneCalled, Equals, true)
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	checkTaskLogContains(c, s.task, `.*ignoring failure in hook "configure": no network connectivity.*`)
+}
@@ -0,0 +1,132 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+)
+
+// SlotConnectivity tells whether a slot is a candidate for the
+// auto-connection of a plug and, if not, why.
+type SlotConnectivity struct {
+	Slot      interfaces.SlotRef `json:"slot"`
+	Candidate bool               `json:"candidate"`
+	// Reason is why the slot is not a candidate.
+	Reason    string `json:"reason,omitempty"`
+	Connected bool   `json:"connected,omitempty"`
+	// Undesired is set when the user disconnected the slot from the
+	// plug after it was auto-connected.
+	Undesired bool `json:"undesired,omitempty"`
+}
+
+// PlugConnectivity explains whether a plug is auto-connected, and to
+// which slots.
+type PlugConnectivity struct {
+	Plug      interfaces.PlugRef  `json:"plug"`
+	Interface string              `json:"interface"`
+	Greedy    bool                `json:"greedy,omitempty"`
+	Verdict   string              `json:"verdict"`
+	Slots     []*SlotConnectivity `json:"slots,omitempty"`
+}
+
+// ExplainConnectivity explains how the given plug is auto-connected,
+// checking each slot of its interface against the auto-connection rules
+// of the declarations and of the interface itself as they are now,
+// which may differ from when the snaps were installed.
+//
+// The state must be locked by the caller.
+func (m *InterfaceManager) ExplainConnectivity(snapName, plugName string) (*PlugConnectivity, error) {
+	st := m.state
+	plug := m.repo.Plug(snapName, plugName)
+	if plug == nil {
+		return nil, fmt.Errorf("snap %q has no %q plug", snapName, plugName)
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	undesired, err := getUndesiredConns(st)
+	if err != nil {
+		return nil, err
+	}
+	autochecker, err := newAutoConnectChecker(st, m.sitePolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &PlugConnectivity{
+		Plug:      plug.Ref(),
+		Interface: plug.Interface,
+		Greedy:    m.greedyPlugs(plug.Interface),
+	}
+	iface := m.repo.Interface(plug.Interface)
+	var candidates, wanted []string
+	for _, slot := range m.repo.AllSlots(plug.Interface) {
+		connRef := interfaces.ConnRef{PlugRef: plug.Ref(), SlotRef: slot.Ref()}
+		_, connected := conns[connRef.ID()]
+		sc := &SlotConnectivity{
+			Slot:      slot.Ref(),
+			Connected: connected,
+		}
+		pc.Slots = append(pc.Slots, sc)
+
+		ic, err := autochecker.connectCandidate(plug, slot)
+		if err != nil {
+			sc.Reason = err.Error()
+			continue
+		}
+		if err := autochecker.checkAutoConnect(ic); err != nil {
+			sc.Reason = err.Error()
+			continue
+		}
+		if iface == nil || !iface.AutoConnect(plug, slot) {
+			sc.Reason = fmt.Sprintf("interface %q does not auto-connect the plug to this slot", plug.Interface)
+			continue
+		}
+		sc.Candidate = true
+		sc.Undesired = undesired[connRef.ID()]
+		candidates = append(candidates, slot.Ref().String())
+		if !sc.Undesired {
+			wanted = append(wanted, slot.Ref().String())
+		}
+	}
+
+	for _, adminConn := range adminAutoConnections(st) {
+		adminSnapName, adminPlugName := splitSnapAndName(adminConn.Plug)
+		if adminSnapName == snapName && adminPlugName == plugName {
+			pc.Verdict = fmt.Sprintf("the administrator declared its connection to %s with interfaces.auto-connect", adminConn.Slot)
+			return pc, nil
+		}
+	}
+	switch {
+	case len(candidates) == 0:
+		pc.Verdict = "no slot is a candidate for auto-connection"
+	case len(candidates) > 1 && !pc.Greedy:
+		pc.Verdict = fmt.Sprintf("not auto-connected as several slots are candidates: %s", strings.Join(candidates, ", "))
+	case len(wanted) == 0:
+		pc.Verdict = "not auto-connected again after the user disconnected it"
+	default:
+		pc.Verdict = fmt.Sprintf("auto-connects to %s", strings.Join(wanted, ", "))
+	}
+	return pc, nil
+}
@@ -0,0 +1,285 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// DeclarationConnChange is a connection involving a snap that its
+// refreshed snap declaration allows or auto-connects differently.
+type DeclarationConnChange struct {
+	Plug      interfaces.PlugRef `json:"plug"`
+	Slot      interfaces.SlotRef `json:"slot"`
+	Interface string             `json:"interface"`
+	// WasAllowed and Allowed tell whether the connection was and is
+	// allowed by the declarations, WasAutoConnect and AutoConnect
+	// whether it was and is auto-connected.
+	WasAllowed     bool `json:"was-allowed"`
+	Allowed        bool `json:"allowed"`
+	WasAutoConnect bool `json:"was-auto-connect"`
+	AutoConnect    bool `json:"auto-connect"`
+	Connected      bool `json:"connected,omitempty"`
+	// Disconnected is set when the connection was disconnected as it
+	// is no longer allowed, as asked by the
+	// interfaces.disconnect-disallowed core option.
+	Disconnected bool `json:"disconnected,omitempty"`
+}
+
+func (c *DeclarationConnChange) String() string {
+	var what string
+	switch {
+	case c.WasAllowed && !c.Allowed:
+		what = "is no longer allowed"
+	case !c.WasAllowed && c.Allowed:
+		what = "is now allowed"
+	case c.WasAutoConnect && !c.AutoConnect:
+		what = "is no longer auto-connected"
+	default:
+		what = "is now auto-connected"
+	}
+	return fmt.Sprintf("%s to %s %s", c.Plug, c.Slot, what)
+}
+
+// DeclarationDiff lists the connections involving a snap whose outcome
+// changed when its snap declaration was refreshed.
+type DeclarationDiff struct {
+	Snap        string                   `json:"snap"`
+	OldRevision int                      `json:"old-revision"`
+	NewRevision int                      `json:"new-revision"`
+	Changes     []*DeclarationConnChange `json:"changes,omitempty"`
+}
+
+func getDeclarationDiffs(st *state.State) (map[string]*DeclarationDiff, error) {
+	var diffs map[string]*DeclarationDiff
+	err := st.Get("declaration-diffs", &diffs)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if diffs == nil {
+		diffs = make(map[string]*DeclarationDiff)
+	}
+	return diffs, nil
+}
+
+// DeclarationDiff returns the changes the last refresh of the snap
+// declaration of the given snap made to its connections, or nil if no
+// refresh changed them.
+//
+// The state must be locked by the caller.
+func (m *InterfaceManager) DeclarationDiff(snapName string) (*DeclarationDiff, error) {
+	diffs, err := getDeclarationDiffs(m.state)
+	if err != nil {
+		return nil, err
+	}
+	return diffs[snapName], nil
+}
+
+// disconnectDisallowed tells whether the connections that refreshed snap
+// declarations no longer allow are to be disconnected.
+func disconnectDisallowed(st *state.State) bool {
+	var disconnect bool
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "interfaces.disconnect-disallowed", &disconnect)
+	if err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot use interfaces.disconnect-disallowed configuration: %v", err)
+		return false
+	}
+	return disconnect
+}
+
+// snapDeclarationsChanged warns about the connections that refreshed snap
+// declarations allow or auto-connect differently, given the previous
+// declarations by snap name, and records them for inspection.
+//
+// The state must be locked by the caller.
+func (m *InterfaceManager) snapDeclarationsChanged(st *state.State, previous map[string]*asserts.SnapDeclaration) {
+	diffs, err := getDeclarationDiffs(st)
+	if err != nil {
+		logger.Noticef("cannot get snap declaration changes: %v", err)
+		return
+	}
+	snapNames := make([]string, 0, len(previous))
+	for snapName := range previous {
+		snapNames = append(snapNames, snapName)
+	}
+	sort.Strings(snapNames)
+
+	disconnect := disconnectDisallowed(st)
+	var disallowed []interfaces.ConnRef
+	var disallowedChanges []*DeclarationConnChange
+	for _, snapName := range snapNames {
+		diff, err := m.diffDeclaration(snapName, previous[snapName])
+		if err != nil {
+			logger.Noticef("cannot compare the snap declarations of %q: %v", snapName, err)
+			continue
+		}
+		if len(diff.Changes) == 0 {
+			delete(diffs, snapName)
+			continue
+		}
+		diffs[snapName] = diff
+		for _, change := range diff.Changes {
+			logger.Noticef("WARNING: with revision %d of its snap declaration, for snap %q %s", diff.NewRevision, snapName, change)
+			if disconnect && change.Connected && change.WasAllowed && !change.Allowed {
+				disallowed = append(disallowed, interfaces.ConnRef{PlugRef: change.Plug, SlotRef: change.Slot})
+				disallowedChanges = append(disallowedChanges, change)
+			}
+		}
+	}
+
+	if len(disallowed) > 0 {
+		ts, err := DisconnectBatch(st, disallowed)
+		if err != nil {
+			logger.Noticef("cannot disconnect the connections no longer allowed: %v", err)
+		} else if ts != nil {
+			chg := st.NewChange("disconnect", i18n.G("Disconnect connections no longer allowed by snap declarations"))
+			chg.AddAll(ts)
+			for _, change := range disallowedChanges {
+				change.Disconnected = true
+			}
+			st.EnsureBefore(0)
+		}
+	}
+	st.Set("declaration-diffs", diffs)
+}
+
+// diffDeclaration compares the outcome of the connections involving the
+// given snap under its previous and its current snap declaration.
+func (m *InterfaceManager) diffDeclaration(snapName string, oldDecl *asserts.SnapDeclaration) (*DeclarationDiff, error) {
+	st := m.state
+	newDecl, err := assertstate.SnapDeclaration(st, oldDecl.SnapID())
+	if err != nil {
+		return nil, err
+	}
+	baseDecl, err := assertstate.BaseDeclaration(st)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot find base declaration: %v", err)
+	}
+	model, err := deviceModel(st)
+	if err != nil {
+		return nil, err
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+
+	// declaration returns the declaration of the given snap, the snap
+	// whose declaration changed getting the given one
+	declaration := func(otherSnapName, snapID string, snapDecl *asserts.SnapDeclaration) (*asserts.SnapDeclaration, error) {
+		if otherSnapName == snapName {
+			return snapDecl, nil
+		}
+		if snapID == "" {
+			return nil, nil
+		}
+		decl, err := assertstate.SnapDeclaration(st, snapID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", otherSnapName, err)
+		}
+		return decl, nil
+	}
+	outcome := func(plug *interfaces.Plug, slot *interfaces.Slot, snapDecl *asserts.SnapDeclaration) (allowed, autoConnect bool, err error) {
+		plugDecl, err := declaration(plug.Snap.Name(), plug.Snap.SnapID, snapDecl)
+		if err != nil {
+			return false, false, err
+		}
+		slotDecl, err := declaration(slot.Snap.Name(), slot.Snap.SnapID, snapDecl)
+		if err != nil {
+			return false, false, err
+		}
+		ic := &policy.ConnectCandidate{
+			Plug:                plug.PlugInfo,
+			PlugSnapDeclaration: plugDecl,
+			Slot:                slot.SlotInfo,
+			SlotSnapDeclaration: slotDecl,
+			BaseDeclaration:     baseDecl,
+			Model:               model,
+		}
+		// snaps installed without declarations are not checked
+		allowed = plugDecl == nil || slotDecl == nil || ic.Check() == nil
+		iface := m.repo.Interface(plug.Interface)
+		autoConnect = ic.CheckAutoConnect() == nil && iface != nil && iface.AutoConnect(plug, slot)
+		return allowed, autoConnect, nil
+	}
+
+	diff := &DeclarationDiff{
+		Snap:        snapName,
+		OldRevision: oldDecl.Revision(),
+		NewRevision: newDecl.Revision(),
+	}
+	compare := func(plug *interfaces.Plug, slot *interfaces.Slot) error {
+		wasAllowed, wasAutoConnect, err := outcome(plug, slot, oldDecl)
+		if err != nil {
+			return err
+		}
+		allowed, autoConnect, err := outcome(plug, slot, newDecl)
+		if err != nil {
+			return err
+		}
+		if wasAllowed == allowed && wasAutoConnect == autoConnect {
+			return nil
+		}
+		connRef := interfaces.ConnRef{PlugRef: plug.Ref(), SlotRef: slot.Ref()}
+		_, connected := conns[connRef.ID()]
+		diff.Changes = append(diff.Changes, &DeclarationConnChange{
+			Plug:           plug.Ref(),
+			Slot:           slot.Ref(),
+			Interface:      plug.Interface,
+			WasAllowed:     wasAllowed,
+			Allowed:        allowed,
+			WasAutoConnect: wasAutoConnect,
+			AutoConnect:    autoConnect,
+			Connected:      connected,
+		})
+		return nil
+	}
+
+	for _, plug := range m.repo.Plugs(snapName) {
+		for _, slot := range m.repo.AllSlots(plug.Interface) {
+			if err := compare(plug, slot); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, slot := range m.repo.Slots(snapName) {
+		for _, plug := range m.repo.AllPlugs(slot.Interface) {
+			if plug.Snap.Name() == snapName {
+				// compared above
+				continue
+			}
+			if err := compare(plug, slot); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return diff, nil
+}
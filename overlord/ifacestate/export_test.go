@@ -22,11 +22,14 @@ import (
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
 var (
-	AddImplicitSlots = addImplicitSlots
+	AddImplicitSlots        = addImplicitSlots
+	RecordConnectionEvent   = (*InterfaceManager).recordConnectionEvent
+	SnapDeclarationsChanged = (*InterfaceManager).snapDeclarationsChanged
 )
 
 func MockConflictPredicate(pred func(string) bool) (restore func()) {
@@ -44,3 +47,21 @@ func (m *InterfaceManager) AddForeignTaskHandlers() {
 	}
 	m.runner.AddHandler("error-trigger", erroringHandler, nil)
 }
+
+func MockCreateUDevMonitor(f func(udevmonitor.DeviceAddedFunc, udevmonitor.DeviceRemovedFunc) udevmonitor.Interface) (restore func()) {
+	old := createUDevMonitor
+	createUDevMonitor = f
+	return func() { createUDevMonitor = old }
+}
+
+func MockPromptingEnabled(f func(st *state.State) (bool, error)) (restore func()) {
+	old := promptingEnabled
+	promptingEnabled = f
+	return func() { promptingEnabled = old }
+}
+
+func MockMaxConnectionHistory(max int) (restore func()) {
+	old := maxConnectionHistory
+	maxConnectionHistory = max
+	return func() { maxConnectionHistory = old }
+}
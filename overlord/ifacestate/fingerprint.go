@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/dbus"
+	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/systemd"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
+// profileFingerprint returns a digest of what the given backend sets up
+// for the snap: its revision, its confinement options and the rules of
+// its specification. Two equal fingerprints mean the backend would write
+// the same profiles again. The fingerprint is empty for backends whose
+// rules are not known.
+func profileFingerprint(repo *interfaces.Repository, backend interfaces.SecurityBackend, snapInfo *snap.Info, opts interfaces.ConfinementOptions) (string, error) {
+	snapName := snapInfo.Name()
+	spec, err := repo.SnapSpecification(backend.Name(), snapName)
+	if err != nil {
+		return "", err
+	}
+	switch spec.(type) {
+	case *apparmor.Specification, *seccomp.Specification, *dbus.Specification, *udev.Specification, *kmod.Specification, *mount.Specification, *systemd.Specification:
+	default:
+		// the rules of other backends cannot be told apart
+		return "", nil
+	}
+
+	rules := make(map[string]string)
+	addSpecSnippets(rules, snapName, spec)
+	if spec, ok := spec.(*mount.Specification); ok {
+		for name, content := range spec.Files() {
+			rules["file:"+name] = string(content)
+		}
+	}
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%+v\x00", snapName, snapInfo.Revision, opts)
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s\x00%s\x00", key, rules[key])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setFingerprint records the fingerprint of the profiles the backend set
+// up for the snap, an empty one forgetting it.
+func (m *InterfaceManager) setFingerprint(snapName string, backend interfaces.SecuritySystem, fingerprint string) {
+	if fingerprint == "" {
+		delete(m.fingerprints[snapName], backend)
+		return
+	}
+	if m.fingerprints == nil {
+		m.fingerprints = make(map[string]map[interfaces.SecuritySystem]string)
+	}
+	if m.fingerprints[snapName] == nil {
+		m.fingerprints[snapName] = make(map[interfaces.SecuritySystem]string)
+	}
+	m.fingerprints[snapName][backend] = fingerprint
+}
@@ -36,12 +36,14 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-// confinementOptions returns interfaces.ConfinementOptions from snapstate.Flags.
-func confinementOptions(flags snapstate.Flags) interfaces.ConfinementOptions {
+// confinementOptions returns interfaces.ConfinementOptions from snapstate.Flags
+// and whether prompting is enabled.
+func (m *InterfaceManager) confinementOptions(flags snapstate.Flags) interfaces.ConfinementOptions {
 	return interfaces.ConfinementOptions{
-		DevMode:  flags.DevMode,
-		JailMode: flags.JailMode,
-		Classic:  flags.Classic,
+		DevMode:   flags.DevMode,
+		JailMode:  flags.JailMode,
+		Classic:   flags.Classic,
+		Prompting: m.prompting,
 	}
 }
 
@@ -64,8 +66,10 @@ func (m *InterfaceManager) setupAffectedSnaps(task *state.Task, affectingSnap st
 			return err
 		}
 		addImplicitSlots(affectedSnapInfo)
-		opts := confinementOptions(snapst.Flags)
-		if err := m.setupSnapSecurity(task, affectedSnapInfo, opts); err != nil {
+		opts := m.confinementOptions(snapst.Flags)
+		// only what the interfaces of the affected snaps add to its
+		// profiles may have changed
+		if err := m.updateSnapSecurity(task, affectedSnapInfo, opts); err != nil {
 			return err
 		}
 	}
@@ -120,7 +124,7 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, tomb *tomb.Tomb) er
 		}
 	}
 
-	opts := confinementOptions(snapsup.Flags)
+	opts := m.confinementOptions(snapsup.Flags)
 	return m.setupProfilesForSnap(task, tomb, snapInfo, opts)
 }
 
@@ -138,6 +142,15 @@ func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb,
 	// - restore connections based on what is kept in the state
 	//   - if a connection cannot be restored then remove it from the state
 	// - setup the security of all the affected snaps
+	var hotplugSlots []*snap.SlotInfo
+	if snapInfo.Type == snap.TypeOS {
+		// keep the slots of the devices currently plugged in
+		slots, err := m.presentHotplugSlots(snapName)
+		if err != nil {
+			return err
+		}
+		hotplugSlots = slots
+	}
 	disconnectedSnaps, err := m.repo.DisconnectSnap(snapName)
 	if err != nil {
 		return err
@@ -154,11 +167,17 @@ func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb,
 			return err
 		}
 	}
+	if snapInfo.Type != snap.TypeOS {
+		// the implicit slots of the core snap are not its doing
+		m.logDeprecatedInterfaces(task, snapInfo)
+	}
+	m.readdHotplugSlots(snapInfo, hotplugSlots)
+	if err := m.restoreRemovedConns(task, snapName); err != nil {
+		return err
+	}
 	if err := m.reloadConnections(snapName); err != nil {
 		return err
 	}
-	// FIXME: here we should not reconnect auto-connect plug/slot
-	// pairs that were explicitly disconnected by the user
 	connectedSnaps, err := m.autoConnect(task, snapName, nil)
 	if err != nil {
 		return err
@@ -262,7 +281,7 @@ func (m *InterfaceManager) undoSetupProfiles(task *state.Task, tomb *tomb.Tomb)
 		if err != nil {
 			return err
 		}
-		opts := confinementOptions(snapst.Flags)
+		opts := m.confinementOptions(snapst.Flags)
 		return m.setupProfilesForSnap(task, tomb, snapInfo, opts)
 	}
 }
@@ -301,9 +320,17 @@ func (m *InterfaceManager) doDiscardConns(task *state.Task, _ *tomb.Tomb) error
 		if connRef.PlugRef.Snap == snapName || connRef.SlotRef.Snap == snapName {
 			removed[id] = conns[id]
 			delete(conns, id)
+			if err := m.recordConnectionEvent(st, "disconnect", ByRemoval, connRef, removed[id].Interface); err != nil {
+				return err
+			}
 		}
 	}
+	undesiredIDs, err := retainConns(st, snapName, snapSetup.SideInfo.SnapID, removed)
+	if err != nil {
+		return err
+	}
 	task.Set("removed", removed)
+	task.Set("undesired", undesiredIDs)
 	setConns(st, conns)
 	return nil
 }
@@ -319,6 +346,12 @@ func (m *InterfaceManager) undoDiscardConns(task *state.Task, _ *tomb.Tomb) erro
 		return err
 	}
 
+	var undesiredIDs []string
+	err = task.Get("undesired", &undesiredIDs)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
 	conns, err := getConns(st)
 	if err != nil {
 		return err
@@ -327,8 +360,12 @@ func (m *InterfaceManager) undoDiscardConns(task *state.Task, _ *tomb.Tomb) erro
 	for id, connState := range removed {
 		conns[id] = connState
 	}
+	if err := unretainConns(st, removed, undesiredIDs); err != nil {
+		return err
+	}
 	setConns(st, conns)
 	task.Set("removed", nil)
+	task.Set("undesired", nil)
 	return nil
 }
 
@@ -379,6 +416,10 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return fmt.Errorf("internal error: cannot find base declaration: %v", err)
 	}
+	model, err := deviceModel(st)
+	if err != nil {
+		return err
+	}
 
 	// check the connection against the declarations' rules
 	ic := policy.ConnectCandidate{
@@ -387,6 +428,7 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		Slot:                slot.SlotInfo,
 		SlotSnapDeclaration: slotDecl,
 		BaseDeclaration:     baseDecl,
+		Model:               model,
 	}
 
 	// if either of plug or slot snaps don't have a declaration it
@@ -398,8 +440,27 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 			return err
 		}
 	}
+	// the site policy applies to all snaps
+	if err := m.sitePolicy().checkConnect(plug.Interface); err != nil {
+		return err
+	}
 
-	err = m.repo.Connect(connRef)
+	var attrs map[string]interface{}
+	if err := task.Get("connection-attrs", &attrs); err != nil && err != state.ErrNoState {
+		return err
+	}
+	iface := m.repo.Interface(plug.Interface)
+	if err := interfaces.ValidateConnectionAttrs(iface, plug, slot, attrs); err != nil {
+		return err
+	}
+	if err := interfaces.CheckConnection(iface, plug, slot); err != nil {
+		return err
+	}
+	if notice := interfaces.DeprecationNotice(iface); notice != "" {
+		task.Logf("%s", notice)
+	}
+
+	err = m.repo.ConnectWithAttrs(connRef, attrs)
 	if err != nil {
 		return err
 	}
@@ -414,18 +475,262 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	slotOpts := confinementOptions(slotSnapst.Flags)
-	if err := m.setupSnapSecurity(task, slot.Snap, slotOpts); err != nil {
+	delayedSetupProfiles, err := delayedSetupProfiles(task)
+	if err != nil {
 		return err
 	}
-	plugOpts := confinementOptions(plugSnapst.Flags)
-	if err := m.setupSnapSecurity(task, plug.Snap, plugOpts); err != nil {
+	// in a batch the profiles are set up by update-profiles tasks
+	// once all the connections are made
+	if !delayedSetupProfiles {
+		slotOpts := m.confinementOptions(slotSnapst.Flags)
+		if err := m.updateSnapSecurity(task, slot.Snap, slotOpts); err != nil {
+			return err
+		}
+		plugOpts := m.confinementOptions(plugSnapst.Flags)
+		if err := m.updateSnapSecurity(task, plug.Snap, plugOpts); err != nil {
+			return err
+		}
+	}
+
+	conns[connRef.ID()] = connState{Interface: plug.Interface, Attrs: attrs}
+	setConns(st, conns)
+
+	undesired, err := getUndesiredConns(st)
+	if err != nil {
 		return err
 	}
+	if undesired[connRef.ID()] {
+		delete(undesired, connRef.ID())
+		setUndesiredConns(st, undesired)
+	}
+
+	return m.recordConnectionEvent(st, "connect", ByUser, connRef, plug.Interface)
+}
+
+func delayedSetupProfiles(task *state.Task) (bool, error) {
+	var delayed bool
+	if err := task.Get("delayed-setup-profiles", &delayed); err != nil && err != state.ErrNoState {
+		return false, err
+	}
+	return delayed, nil
+}
+
+// undoConnect breaks a connection made as part of a batch when another
+// part of it failed. Connections made on their own are not undone, even
+// when their hooks fail.
+func (m *InterfaceManager) undoConnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
 
-	conns[connRef.ID()] = connState{Interface: plug.Interface}
+	delayed, err := delayedSetupProfiles(task)
+	if err != nil || !delayed {
+		return err
+	}
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	cstate, ok := conns[connRef.ID()]
+	if !ok {
+		return nil
+	}
+	if err := m.repo.Disconnect(plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name); err != nil {
+		return err
+	}
+	delete(conns, connRef.ID())
 	setConns(st, conns)
+	// the profiles of the batch may have been set up already
+	if err := m.setupAffectedSnaps(task, "", []string{slotRef.Snap, plugRef.Snap}); err != nil {
+		return err
+	}
+	return m.recordConnectionEvent(st, "disconnect", ByUser, connRef, cstate.Interface)
+}
+
+// autoConnection describes a connection made by an auto-connect task,
+// as recorded in the "connected" entry of the "api-data" of its change.
+type autoConnection struct {
+	Plug interfaces.PlugRef `json:"plug"`
+	Slot interfaces.SlotRef `json:"slot"`
+}
+
+func (m *InterfaceManager) doAutoConnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, err := snapstate.TaskSnapSetup(task)
+	if err != nil {
+		return err
+	}
+	snapName := snapsup.Name()
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		return err
+	}
+	snapInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	addImplicitSlots(snapInfo)
+
+	oldConns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	affectedSnaps, err := m.autoConnect(task, snapName, nil)
+	if err != nil {
+		return err
+	}
+	newConns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	var connected []*autoConnection
+	for id := range newConns {
+		if _, ok := oldConns[id]; ok {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		connected = append(connected, &autoConnection{Plug: connRef.PlugRef, Slot: connRef.SlotRef})
+	}
+	sort.Sort(byAutoConnection(connected))
+	if err := autoConnectTrace(task, connected); err != nil {
+		return err
+	}
+	if len(connected) == 0 {
+		task.Logf("No new connections for snap %q", snapName)
+		return nil
+	}
+	// for undoing them
+	task.Set("auto-connected", connected)
+
+	opts := m.confinementOptions(snapst.Flags)
+	if err := m.setupAutoConnected(task, snapInfo, opts, affectedSnaps); err != nil {
+		if uerr := m.disconnectAutoConnected(task); uerr != nil {
+			task.Errorf("cannot undo the auto-connections: %v", uerr)
+		}
+		return err
+	}
+	return nil
+}
 
+// setupAutoConnected sets up the security profiles of the given snap and
+// of the snaps affected by its auto-connections.
+func (m *InterfaceManager) setupAutoConnected(task *state.Task, snapInfo *snap.Info, opts interfaces.ConfinementOptions, affectedSnaps []string) error {
+	snapName := snapInfo.Name()
+	if err := m.setupSnapSecurity(task, snapInfo, opts); err != nil {
+		return err
+	}
+	affectedSet := make(map[string]bool)
+	for _, name := range affectedSnaps {
+		affectedSet[name] = true
+	}
+	affectedSnaps = make([]string, 0, len(affectedSet))
+	for name := range affectedSet {
+		affectedSnaps = append(affectedSnaps, name)
+	}
+	sort.Strings(affectedSnaps)
+	return m.setupAffectedSnaps(task, snapName, affectedSnaps)
+}
+
+// undoAutoConnect breaks the connections the auto-connect task made
+// when another part of its change failed.
+func (m *InterfaceManager) undoAutoConnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	return m.disconnectAutoConnected(task)
+}
+
+// disconnectAutoConnected breaks the connections recorded by the given
+// auto-connect task, and sets up the profiles of the snaps they involve
+// again.
+func (m *InterfaceManager) disconnectAutoConnected(task *state.Task) error {
+	st := task.State()
+	var connected []*autoConnection
+	err := task.Get("auto-connected", &connected)
+	if err == state.ErrNoState {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	affectedSet := make(map[string]bool)
+	for _, ac := range connected {
+		connRef := interfaces.ConnRef{PlugRef: ac.Plug, SlotRef: ac.Slot}
+		cstate, ok := conns[connRef.ID()]
+		if !ok {
+			continue
+		}
+		if err := m.repo.Disconnect(ac.Plug.Snap, ac.Plug.Name, ac.Slot.Snap, ac.Slot.Name); err != nil {
+			return err
+		}
+		delete(conns, connRef.ID())
+		setConns(st, conns)
+		if err := m.recordConnectionEvent(st, "disconnect", ByAutoConnect, connRef, cstate.Interface); err != nil {
+			return err
+		}
+		affectedSet[ac.Plug.Snap] = true
+		affectedSet[ac.Slot.Snap] = true
+	}
+	task.Clear("auto-connected")
+
+	affectedSnaps := make([]string, 0, len(affectedSet))
+	for name := range affectedSet {
+		affectedSnaps = append(affectedSnaps, name)
+	}
+	sort.Strings(affectedSnaps)
+	return m.setupAffectedSnaps(task, "", affectedSnaps)
+}
+
+type byAutoConnection []*autoConnection
+
+func (c byAutoConnection) Len() int      { return len(c) }
+func (c byAutoConnection) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byAutoConnection) Less(i, j int) bool {
+	if c[i].Plug != c[j].Plug {
+		return c[i].Plug.String() < c[j].Plug.String()
+	}
+	return c[i].Slot.String() < c[j].Slot.String()
+}
+
+// autoConnectTrace records the connections made by the given task in
+// the change, so that they can be reported to the user.
+func autoConnectTrace(task *state.Task, connected []*autoConnection) error {
+	chg := task.Change()
+	if chg == nil {
+		return nil
+	}
+	var data map[string]interface{}
+	err := chg.Get("api-data", &data)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if len(data) == 0 {
+		data = make(map[string]interface{})
+	}
+	if connected == nil {
+		connected = []*autoConnection{}
+	}
+	data["connected"] = connected
+	chg.Set("api-data", data)
 	return nil
 }
 
@@ -462,22 +767,103 @@ func (m *InterfaceManager) doDisconnect(task *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return fmt.Errorf("snapd changed, please retry the operation: %v", err)
 	}
-	for _, snapst := range snapStates {
-		snapInfo, err := snapst.CurrentInfo()
+	delayed, err := delayedSetupProfiles(task)
+	if err != nil {
+		return err
+	}
+	// in a batch the profiles are set up by update-profiles tasks
+	// once all the connections are broken
+	if !delayed {
+		for _, snapst := range snapStates {
+			snapInfo, err := snapst.CurrentInfo()
+			if err != nil {
+				return err
+			}
+			opts := m.confinementOptions(snapst.Flags)
+			if err := m.updateSnapSecurity(task, snapInfo, opts); err != nil {
+				return err
+			}
+		}
+	}
+
+	conn := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	cstate := conns[conn.ID()]
+	delete(conns, conn.ID())
+	if delayed {
+		// for undoing the batch
+		task.Set("old-conn", cstate)
+	}
+
+	setConns(st, conns)
+	if cstate.Auto {
+		// the user doesn't want the connection, don't auto-connect
+		// it again
+		undesired, err := getUndesiredConns(st)
 		if err != nil {
 			return err
 		}
-		opts := confinementOptions(snapst.Flags)
-		if err := m.setupSnapSecurity(task, snapInfo, opts); err != nil {
+		undesired[conn.ID()] = true
+		setUndesiredConns(st, undesired)
+	}
+	return m.recordConnectionEvent(st, "disconnect", ByUser, conn, cstate.Interface)
+}
+
+// undoDisconnect restores a connection broken as part of a batch when
+// another part of it failed.
+func (m *InterfaceManager) undoDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var cstate connState
+	err := task.Get("old-conn", &cstate)
+	if err == state.ErrNoState {
+		// not part of a batch, or the snaps were gone already
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	if err := m.repo.ConnectWithAttrs(connRef, cstate.Attrs); err != nil {
+		return err
+	}
+	conns[connRef.ID()] = cstate
+	setConns(st, conns)
+	if cstate.Auto {
+		undesired, err := getUndesiredConns(st)
+		if err != nil {
 			return err
 		}
+		delete(undesired, connRef.ID())
+		setUndesiredConns(st, undesired)
+	}
+	if err := m.setupAffectedSnaps(task, "", []string{slotRef.Snap, plugRef.Snap}); err != nil {
+		return err
 	}
+	return m.recordConnectionEvent(st, "connect", ByUser, connRef, cstate.Interface)
+}
 
-	conn := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
-	delete(conns, conn.ID())
+// doUpdateProfiles sets up the security profiles of a snap once a batch
+// of connections involving it was made or broken.
+func (m *InterfaceManager) doUpdateProfiles(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
 
-	setConns(st, conns)
-	return nil
+	var snapName string
+	if err := task.Get("snap", &snapName); err != nil {
+		return err
+	}
+	return m.setupAffectedSnaps(task, "", []string{snapName})
 }
 
 // transitionConnectionsCoreMigration will transition all connections
@@ -21,6 +21,7 @@ package ifacestate
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/snapcore/snapd/asserts"
@@ -30,11 +31,18 @@ import (
 	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/promptstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 )
 
+// promptingEnabled returns whether the profiles should ask about the
+// accesses through the prompted interfaces.
+var promptingEnabled = promptstate.Enabled
+
 func (m *InterfaceManager) initialize(extraInterfaces []interfaces.Interface, extraBackends []interfaces.SecurityBackend) error {
 	m.state.Lock()
 	defer m.state.Unlock()
@@ -54,12 +62,35 @@ func (m *InterfaceManager) initialize(extraInterfaces []interfaces.Interface, ex
 	if err := m.reloadConnections(""); err != nil {
 		return err
 	}
+	prompting, err := promptingEnabled(m.state)
+	if err != nil {
+		logger.Noticef("cannot tell whether prompting is enabled: %v", err)
+	}
+	m.prompting = prompting
 	if err := m.regenerateAllSecurityProfiles(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ensurePrompting regenerates the security profiles of all snaps when
+// prompting gets enabled or disabled, as their rules ask about accesses
+// only with it enabled.
+func (m *InterfaceManager) ensurePrompting() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	prompting, err := promptingEnabled(m.state)
+	if err != nil {
+		return err
+	}
+	if prompting == m.prompting {
+		return nil
+	}
+	m.prompting = prompting
+	return m.regenerateAllSecurityProfiles()
+}
+
 func (m *InterfaceManager) addInterfaces(extra []interfaces.Interface) error {
 	for _, iface := range builtin.Interfaces() {
 		if err := m.repo.AddInterface(iface); err != nil {
@@ -134,7 +165,7 @@ func (m *InterfaceManager) regenerateAllSecurityProfiles() error {
 		}
 
 		// Compute confinement options
-		opts := confinementOptions(snapst.Flags)
+		opts := m.confinementOptions(snapst.Flags)
 
 		// For each backend:
 		for _, backend := range securityBackends {
@@ -194,7 +225,7 @@ func (m *InterfaceManager) reloadConnections(snapName string) error {
 	if err != nil {
 		return err
 	}
-	for id := range conns {
+	for id, conn := range conns {
 		connRef, err := interfaces.ParseConnRef(id)
 		if err != nil {
 			return err
@@ -202,7 +233,7 @@ func (m *InterfaceManager) reloadConnections(snapName string) error {
 		if snapName != "" && connRef.PlugRef.Snap != snapName && connRef.SlotRef.Snap != snapName {
 			continue
 		}
-		if err := m.repo.Connect(connRef); err != nil {
+		if err := m.repo.ConnectWithAttrs(connRef, conn.Attrs); err != nil {
 			logger.Noticef("%s", err)
 		}
 	}
@@ -210,23 +241,47 @@ func (m *InterfaceManager) reloadConnections(snapName string) error {
 }
 
 func (m *InterfaceManager) setupSnapSecurity(task *state.Task, snapInfo *snap.Info, opts interfaces.ConfinementOptions) error {
+	return m.setupSnapBackends(task, snapInfo, opts, false)
+}
+
+// updateSnapSecurity sets up the security of the snap like
+// setupSnapSecurity, skipping the backends whose profiles for it would
+// not change, as after connecting or disconnecting interfaces only some
+// backends care about.
+func (m *InterfaceManager) updateSnapSecurity(task *state.Task, snapInfo *snap.Info, opts interfaces.ConfinementOptions) error {
+	return m.setupSnapBackends(task, snapInfo, opts, true)
+}
+
+func (m *InterfaceManager) setupSnapBackends(task *state.Task, snapInfo *snap.Info, opts interfaces.ConfinementOptions, onlyChanged bool) error {
 	st := task.State()
 	snapName := snapInfo.Name()
 
 	for _, backend := range m.repo.Backends() {
+		fingerprint, err := profileFingerprint(m.repo, backend, snapInfo, opts)
+		if err != nil {
+			// let the backend report it
+			fingerprint = ""
+		}
+		if onlyChanged && fingerprint != "" && m.fingerprints[snapName][backend.Name()] == fingerprint {
+			logger.Debugf("skipping unchanged %s profiles of snap %q", backend.Name(), snapName)
+			continue
+		}
 		st.Unlock()
-		err := backend.Setup(snapInfo, opts, m.repo)
+		err = backend.Setup(snapInfo, opts, m.repo)
 		st.Lock()
 		if err != nil {
+			m.setFingerprint(snapName, backend.Name(), "")
 			task.Errorf("cannot setup %s for snap %q: %s", backend.Name(), snapName, err)
 			return err
 		}
+		m.setFingerprint(snapName, backend.Name(), fingerprint)
 	}
 	return nil
 }
 
 func (m *InterfaceManager) removeSnapSecurity(task *state.Task, snapName string) error {
 	st := task.State()
+	delete(m.fingerprints, snapName)
 	for _, backend := range m.repo.Backends() {
 		st.Unlock()
 		err := backend.Remove(snapName)
@@ -240,25 +295,49 @@ func (m *InterfaceManager) removeSnapSecurity(task *state.Task, snapName string)
 }
 
 type connState struct {
-	Auto      bool   `json:"auto,omitempty"`
-	Interface string `json:"interface,omitempty"`
+	Auto      bool                   `json:"auto,omitempty"`
+	Interface string                 `json:"interface,omitempty"`
+	Attrs     map[string]interface{} `json:"attrs,omitempty"`
 }
 
 type autoConnectChecker struct {
 	st       *state.State
 	cache    map[string]*asserts.SnapDeclaration
 	baseDecl *asserts.BaseDeclaration
+	model    *asserts.Model
+	policy   *sitePolicy
+	// overridden notes, by connection, where the site policy decided
+	// otherwise than the declarations
+	overridden map[string]string
 }
 
-func newAutoConnectChecker(s *state.State) (*autoConnectChecker, error) {
+// deviceModel returns the model of the device, or nil if the device
+// is not seeded yet, for the on-store, on-brand and on-model
+// constraints of the declarations.
+func deviceModel(st *state.State) (*asserts.Model, error) {
+	model, err := devicestate.Model(st)
+	if err == state.ErrNoState {
+		return nil, nil
+	}
+	return model, err
+}
+
+func newAutoConnectChecker(s *state.State, policy *sitePolicy) (*autoConnectChecker, error) {
 	baseDecl, err := assertstate.BaseDeclaration(s)
 	if err != nil {
 		return nil, fmt.Errorf("internal error: cannot find base declaration: %v", err)
 	}
+	model, err := deviceModel(s)
+	if err != nil {
+		return nil, err
+	}
 	return &autoConnectChecker{
-		st:       s,
-		cache:    make(map[string]*asserts.SnapDeclaration),
-		baseDecl: baseDecl,
+		st:         s,
+		cache:      make(map[string]*asserts.SnapDeclaration),
+		baseDecl:   baseDecl,
+		model:      model,
+		policy:     policy,
+		overridden: make(map[string]string),
 	}, nil
 }
 
@@ -275,14 +354,13 @@ func (c *autoConnectChecker) snapDeclaration(snapID string) (*asserts.SnapDeclar
 	return snapDecl, nil
 }
 
-func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot) bool {
+func (c *autoConnectChecker) connectCandidate(plug *interfaces.Plug, slot *interfaces.Slot) (*policy.ConnectCandidate, error) {
 	var plugDecl *asserts.SnapDeclaration
 	if plug.Snap.SnapID != "" {
 		var err error
 		plugDecl, err = c.snapDeclaration(plug.Snap.SnapID)
 		if err != nil {
-			logger.Noticef("error: cannot find snap declaration for %q: %v", plug.Snap.Name(), err)
-			return false
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap.Name(), err)
 		}
 	}
 
@@ -291,21 +369,212 @@ func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot)
 		var err error
 		slotDecl, err = c.snapDeclaration(slot.Snap.SnapID)
 		if err != nil {
-			logger.Noticef("error: cannot find snap declaration for %q: %v", slot.Snap.Name(), err)
-			return false
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap.Name(), err)
 		}
 	}
 
-	// check the connection against the declarations' rules
-	ic := policy.ConnectCandidate{
+	return &policy.ConnectCandidate{
 		Plug:                plug.PlugInfo,
 		PlugSnapDeclaration: plugDecl,
 		Slot:                slot.SlotInfo,
 		SlotSnapDeclaration: slotDecl,
 		BaseDeclaration:     c.baseDecl,
+		Model:               c.model,
+	}, nil
+}
+
+func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot) bool {
+	ic, err := c.connectCandidate(plug, slot)
+	if err != nil {
+		logger.Noticef("error: %v", err)
+		return false
+	}
+
+	return c.checkAutoConnect(ic) == nil
+}
+
+// checkAutoConnect checks an auto-connection against the rules of the
+// declarations, unless the site policy decides otherwise.
+func (c *autoConnectChecker) checkAutoConnect(ic *policy.ConnectCandidate) error {
+	declErr := ic.CheckAutoConnect()
+	ifaceName := ic.Plug.Interface
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: ic.Plug.Snap.Name(), Name: ic.Plug.Name},
+		SlotRef: interfaces.SlotRef{Snap: ic.Slot.Snap.Name(), Name: ic.Slot.Name},
+	}
+	var err error
+	switch {
+	case c.policy.invalid != nil:
+		err = c.policy.invalid
+	case c.policy.forbidden(ifaceName):
+		err = fmt.Errorf("interface %q is forbidden by the site policy", ifaceName)
+	case c.policy.manual(ifaceName):
+		err = fmt.Errorf("interface %q is only connected manually by the site policy", ifaceName)
+	case declErr != nil && c.policy.autoConnect(ifaceName):
+		// the connection itself must still be allowed, as with
+		// "snap connect"
+		if ic.PlugSnapDeclaration != nil && ic.SlotSnapDeclaration != nil {
+			if ic.Check() != nil {
+				return declErr
+			}
+		}
+		c.overridden[connRef.ID()] = fmt.Sprintf("auto connect %s to %s: interface %q is auto-connected by the site policy", connRef.PlugRef, connRef.SlotRef, ifaceName)
+		return nil
+	default:
+		return declErr
+	}
+	if declErr == nil {
+		c.overridden[connRef.ID()] = fmt.Sprintf("cannot auto connect %s to %s: %v", connRef.PlugRef, connRef.SlotRef, err)
+	}
+	return err
+}
+
+// logOverridden notes in the log of the task where the site policy
+// changed what the declarations would have auto-connected.
+func (c *autoConnectChecker) logOverridden(task *state.Task) {
+	notes := make([]string, 0, len(c.overridden))
+	for _, note := range c.overridden {
+		notes = append(notes, note)
+	}
+	sort.Strings(notes)
+	for _, note := range notes {
+		task.Logf("%s", note)
+	}
+}
+
+// checkAdmin checks a connection declared by the administrator the same
+// way as a connection requested with "snap connect": against the
+// connection rules of the declarations rather than their auto-connection
+// rules, and not at all for snaps installed without declarations.
+func (c *autoConnectChecker) checkAdmin(plug *interfaces.Plug, slot *interfaces.Slot) error {
+	if err := c.policy.checkConnect(plug.Interface); err != nil {
+		return err
+	}
+	ic, err := c.connectCandidate(plug, slot)
+	if err != nil {
+		return err
+	}
+	if ic.PlugSnapDeclaration == nil || ic.SlotSnapDeclaration == nil {
+		return nil
+	}
+	return ic.Check()
+}
+
+// adminAutoConnection is a connection the device administrator wants
+// made as soon as both sides of it are installed, as declared in the
+// interfaces.auto-connect core option, for instance with:
+//
+//   snap set core interfaces.auto-connect='[{"plug": "foo:serial", "slot": "core:serial-port-1"}]'
+//
+// As with "snap connect", the slot can be given as ":slot", to use the
+// core snap, or as "snap" alone when it has a single matching slot.
+type adminAutoConnection struct {
+	Plug string `json:"plug"`
+	Slot string `json:"slot"`
+}
+
+func splitSnapAndName(s string) (snapName, name string) {
+	if i := strings.Index(s, ":"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// adminAutoConnections returns the connections declared in the
+// interfaces.auto-connect core option. Unreadable values are ignored so
+// that a mistake in the option doesn't prevent snaps from installing.
+func adminAutoConnections(st *state.State) []adminAutoConnection {
+	var conns []adminAutoConnection
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "interfaces.auto-connect", &conns)
+	if err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot use interfaces.auto-connect configuration: %v", err)
+		return nil
+	}
+	return conns
+}
+
+// adminAutoConnect makes the connections declared by the administrator
+// involving the given snap, returning the names of the affected snaps
+// and the plugs the administrator has a say about.
+func (m *InterfaceManager) adminAutoConnect(task *state.Task, snapName string, blacklist map[string]bool, conns map[string]connState, autochecker *autoConnectChecker) ([]string, map[interfaces.PlugRef]bool, error) {
+	var affectedSnapNames []string
+	adminPlugs := make(map[interfaces.PlugRef]bool)
+	for _, adminConn := range adminAutoConnections(task.State()) {
+		plugSnapName, plugName := splitSnapAndName(adminConn.Plug)
+		slotSnapName, slotName := splitSnapAndName(adminConn.Slot)
+		// the plug is reserved for the declared slot, even when it
+		// is not installed yet
+		adminPlugs[interfaces.PlugRef{Snap: plugSnapName, Name: plugName}] = true
+		connRef, err := m.repo.ResolveConnect(plugSnapName, plugName, slotSnapName, slotName)
+		if err != nil {
+			// the other side may well not be installed yet
+			if plugSnapName == snapName || slotSnapName == snapName {
+				task.Logf("cannot auto connect %s to %s: %s (administrator auto-connection)", adminConn.Plug, adminConn.Slot, err)
+			}
+			continue
+		}
+		switch {
+		case connRef.PlugRef.Snap == snapName && !blacklist[connRef.PlugRef.Name]:
+		case connRef.SlotRef.Snap == snapName && !blacklist[connRef.SlotRef.Name]:
+		default:
+			continue
+		}
+		key := connRef.ID()
+		if _, ok := conns[key]; ok {
+			continue
+		}
+		plug := m.repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name)
+		slot := m.repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if err := autochecker.checkAdmin(plug, slot); err != nil {
+			task.Logf("cannot auto connect %s to %s: %s (administrator auto-connection)", connRef.PlugRef, connRef.SlotRef, err)
+			continue
+		}
+		if err := m.repo.Connect(connRef); err != nil {
+			task.Logf("cannot auto connect %s to %s: %s (administrator auto-connection)", connRef.PlugRef, connRef.SlotRef, err)
+			continue
+		}
+		affectedSnapNames = append(affectedSnapNames, connRef.PlugRef.Snap)
+		affectedSnapNames = append(affectedSnapNames, connRef.SlotRef.Snap)
+		conns[key] = connState{Interface: plug.Interface, Auto: true}
+		if err := m.recordConnectionEvent(task.State(), "connect", ByAdministrator, connRef, plug.Interface); err != nil {
+			return nil, nil, err
+		}
 	}
+	return affectedSnapNames, adminPlugs, nil
+}
+
+// greedyPlugs returns whether the plugs of the given interface can be
+// connected to several slots at once.
+func (m *InterfaceManager) greedyPlugs(ifaceName string) bool {
+	iface := m.repo.Interface(ifaceName)
+	return iface != nil && interfaces.StaticInfoOf(iface).GreedyPlugs
+}
 
-	return ic.CheckAutoConnect() == nil
+// logDeprecatedInterfaces notes in the log of the task the deprecated
+// interfaces used by the plugs and slots of the snap.
+func (m *InterfaceManager) logDeprecatedInterfaces(task *state.Task, snapInfo *snap.Info) {
+	used := make(map[string]bool)
+	for _, plug := range snapInfo.Plugs {
+		used[plug.Interface] = true
+	}
+	for _, slot := range snapInfo.Slots {
+		used[slot.Interface] = true
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		iface := m.repo.Interface(name)
+		if iface == nil {
+			continue
+		}
+		if notice := interfaces.DeprecationNotice(iface); notice != "" {
+			task.Logf("%s", notice)
+		}
+	}
 }
 
 // autoConnect connects the given snap to viable candidates returning the list
@@ -313,7 +582,6 @@ func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot)
 // specific interfaces (blacklist entries are plug or slot names).
 func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blacklist map[string]bool) ([]string, error) {
 	var conns map[string]connState
-	var affectedSnapNames []string
 	err := task.State().Get("conns", &conns)
 	if err != nil && err != state.ErrNoState {
 		return nil, err
@@ -321,15 +589,26 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 	if conns == nil {
 		conns = make(map[string]connState)
 	}
+	undesired, err := getUndesiredConns(task.State())
+	if err != nil {
+		return nil, err
+	}
 
-	autochecker, err := newAutoConnectChecker(task.State())
+	autochecker, err := newAutoConnectChecker(task.State(), m.sitePolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	// Connections declared by the administrator come first, and the plugs
+	// they concern are left alone by the declaration-based ones.
+	affectedSnapNames, adminPlugs, err := m.adminAutoConnect(task, snapName, blacklist, conns, autochecker)
 	if err != nil {
 		return nil, err
 	}
 
 	// Auto-connect all the plugs
 	for _, plug := range m.repo.Plugs(snapName) {
-		if blacklist[plug.Name] {
+		if blacklist[plug.Name] || adminPlugs[plug.Ref()] {
 			continue
 		}
 		candidates := m.repo.AutoConnectCandidateSlots(snapName, plug.Name, autochecker.check)
@@ -348,7 +627,10 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 				candidates = candidates[0:1]
 			}
 		}
-		if len(candidates) != 1 {
+		// Greedy plugs are connected to all the candidates, the others
+		// only when there is no ambiguity.
+		greedy := m.greedyPlugs(plug.Interface)
+		if len(candidates) != 1 && !greedy {
 			crefs := make([]string, 0, len(candidates))
 			for _, candidate := range candidates {
 				crefs = append(crefs, candidate.Ref().String())
@@ -356,21 +638,29 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 			task.Logf("cannot auto connect %s (plug auto-connection), candidates found: %q", plug.Ref(), strings.Join(crefs, ", "))
 			continue
 		}
-		slot := candidates[0]
-		connRef := interfaces.ConnRef{PlugRef: plug.Ref(), SlotRef: slot.Ref()}
-		key := connRef.ID()
-		if _, ok := conns[key]; ok {
-			// Suggested connection already exist so don't clobber it.
-			// NOTE: we don't log anything here as this is a normal and common condition.
-			continue
-		}
-		if err := m.repo.Connect(connRef); err != nil {
-			task.Logf("cannot auto connect %s to %s: %s (plug auto-connection)", connRef.PlugRef, connRef.SlotRef, err)
-			continue
+		for _, slot := range candidates {
+			connRef := interfaces.ConnRef{PlugRef: plug.Ref(), SlotRef: slot.Ref()}
+			key := connRef.ID()
+			if _, ok := conns[key]; ok {
+				// Suggested connection already exist so don't clobber it.
+				// NOTE: we don't log anything here as this is a normal and common condition.
+				continue
+			}
+			if undesired[key] {
+				// The user disconnected it, leave it alone.
+				continue
+			}
+			if err := m.repo.Connect(connRef); err != nil {
+				task.Logf("cannot auto connect %s to %s: %s (plug auto-connection)", connRef.PlugRef, connRef.SlotRef, err)
+				continue
+			}
+			affectedSnapNames = append(affectedSnapNames, connRef.PlugRef.Snap)
+			affectedSnapNames = append(affectedSnapNames, connRef.SlotRef.Snap)
+			conns[key] = connState{Interface: plug.Interface, Auto: true}
+			if err := m.recordConnectionEvent(task.State(), "connect", ByAutoConnect, connRef, plug.Interface); err != nil {
+				return nil, err
+			}
 		}
-		affectedSnapNames = append(affectedSnapNames, connRef.PlugRef.Snap)
-		affectedSnapNames = append(affectedSnapNames, connRef.SlotRef.Snap)
-		conns[key] = connState{Interface: plug.Interface, Auto: true}
 	}
 	// Auto-connect all the slots
 	for _, slot := range m.repo.Slots(snapName) {
@@ -383,12 +673,16 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 		}
 
 		for _, plug := range candidates {
+			if adminPlugs[plug.Ref()] {
+				continue
+			}
 			// make sure slot is the only viable
-			// connection for plug, same check as if we were
-			// considering auto-connections from plug
+			// connection for plug, unless the plug is greedy,
+			// same check as if we were considering
+			// auto-connections from plug
 			candSlots := m.repo.AutoConnectCandidateSlots(plug.Snap.Name(), plug.Name, autochecker.check)
 
-			if len(candSlots) != 1 || candSlots[0].Ref() != slot.Ref() {
+			if !m.greedyPlugs(plug.Interface) && (len(candSlots) != 1 || candSlots[0].Ref() != slot.Ref()) {
 				crefs := make([]string, 0, len(candSlots))
 				for _, candidate := range candSlots {
 					crefs = append(crefs, candidate.Ref().String())
@@ -404,6 +698,10 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 				// NOTE: we don't log anything here as this is a normal and common condition.
 				continue
 			}
+			if undesired[key] {
+				// The user disconnected it, leave it alone.
+				continue
+			}
 			if err := m.repo.Connect(connRef); err != nil {
 				task.Logf("cannot auto connect %s to %s: %s (slot auto-connection)", connRef.PlugRef, connRef.SlotRef, err)
 				continue
@@ -411,9 +709,13 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 			affectedSnapNames = append(affectedSnapNames, connRef.PlugRef.Snap)
 			affectedSnapNames = append(affectedSnapNames, connRef.SlotRef.Snap)
 			conns[key] = connState{Interface: plug.Interface, Auto: true}
+			if err := m.recordConnectionEvent(task.State(), "connect", ByAutoConnect, connRef, plug.Interface); err != nil {
+				return nil, err
+			}
 		}
 	}
 
+	autochecker.logOverridden(task)
 	task.State().Set("conns", conns)
 	return affectedSnapNames, nil
 }
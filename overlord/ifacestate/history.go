@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ifacestate
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Initiators of the connection events.
+const (
+	ByUser          = "user"
+	ByAutoConnect   = "auto-connect"
+	ByAdministrator = "administrator"
+	ByHotplug       = "hotplug"
+	ByRemoval       = "removal"
+	ByReinstall     = "reinstall"
+)
+
+// maxConnectionHistory bounds how many connection events are kept, the
+// oldest ones being dropped first.
+var maxConnectionHistory = 1000
+
+// ConnectionEvent records a connection being made or broken.
+type ConnectionEvent struct {
+	Time time.Time `json:"time"`
+	// Action is either "connect" or "disconnect".
+	Action string `json:"action"`
+	// By is what initiated the event, one of the By* constants.
+	By        string                 `json:"by"`
+	Plug      interfaces.PlugRef     `json:"plug"`
+	Slot      interfaces.SlotRef     `json:"slot"`
+	Interface string                 `json:"interface,omitempty"`
+	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
+}
+
+func getConnectionHistory(st *state.State) ([]*ConnectionEvent, error) {
+	var events []*ConnectionEvent
+	err := st.Get("conns-history", &events)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ConnectionHistory returns the recorded connection events involving
+// the given snap, or all of them if snapName is empty, oldest first.
+func ConnectionHistory(st *state.State, snapName string) ([]*ConnectionEvent, error) {
+	events, err := getConnectionHistory(st)
+	if err != nil {
+		return nil, err
+	}
+	if snapName == "" {
+		return events, nil
+	}
+	var snapEvents []*ConnectionEvent
+	for _, ev := range events {
+		if ev.Plug.Snap == snapName || ev.Slot.Snap == snapName {
+			snapEvents = append(snapEvents, ev)
+		}
+	}
+	return snapEvents, nil
+}
+
+// recordConnectionEvent appends an event about the given connection to
+// the connection history, taking the interface and attributes of the
+// plug and slot from the repository when they are still there.
+func (m *InterfaceManager) recordConnectionEvent(st *state.State, action, by string, connRef interfaces.ConnRef, ifaceName string) error {
+	events, err := getConnectionHistory(st)
+	if err != nil {
+		return err
+	}
+	ev := &ConnectionEvent{
+		Time:      time.Now(),
+		Action:    action,
+		By:        by,
+		Plug:      connRef.PlugRef,
+		Slot:      connRef.SlotRef,
+		Interface: ifaceName,
+	}
+	if plug := m.repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name); plug != nil {
+		ev.Interface = plug.Interface
+		ev.PlugAttrs = plug.Attrs
+	}
+	if slot := m.repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name); slot != nil {
+		ev.SlotAttrs = slot.Attrs
+	}
+	events = append(events, ev)
+	if len(events) > maxConnectionHistory {
+		events = events[len(events)-maxConnectionHistory:]
+	}
+	st.Set("conns-history", events)
+	return nil
+}
@@ -0,0 +1,433 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var createUDevMonitor = udevmonitor.New
+
+// hotplugSlotInfo is what is remembered of the slots created on the core
+// snap for hotplugged devices, so that a device gets the same slot, and
+// with it its connections, when plugged in again.
+type hotplugSlotInfo struct {
+	Name       string `json:"name"`
+	Interface  string `json:"interface"`
+	HotplugKey string `json:"hotplug-key"`
+	// DevicePath is the sysfs path of the device while it is plugged in.
+	DevicePath string `json:"device-path,omitempty"`
+}
+
+func getHotplugSlots(st *state.State) (map[string]*hotplugSlotInfo, error) {
+	var slots map[string]*hotplugSlotInfo
+	err := st.Get("hotplug-slots", &slots)
+	if err != nil && err != state.ErrNoState {
+		return nil, fmt.Errorf("cannot obtain hotplug slots: %v", err)
+	}
+	if slots == nil {
+		slots = make(map[string]*hotplugSlotInfo)
+	}
+	return slots, nil
+}
+
+func setHotplugSlots(st *state.State, slots map[string]*hotplugSlotInfo) {
+	st.Set("hotplug-slots", slots)
+}
+
+// hotplugEnabled returns whether the experimental.hotplug core option is
+// set, asking for slots to be created for devices as they are plugged in.
+func hotplugEnabled(st *state.State) (bool, error) {
+	var enabled bool
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "experimental.hotplug", &enabled)
+	if err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// ensureUDevMonitor starts monitoring devices once hotplug is enabled.
+func (m *InterfaceManager) ensureUDevMonitor() {
+	if m.udevMon != nil || m.udevMonFailed {
+		return
+	}
+
+	m.state.Lock()
+	enabled, err := hotplugEnabled(m.state)
+	m.state.Unlock()
+	if err != nil {
+		logger.Noticef("cannot read hotplug configuration: %v", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	mon := createUDevMonitor(m.hotplugDeviceAdded, m.hotplugDeviceRemoved)
+	if err := mon.Connect(); err != nil {
+		logger.Noticef("cannot enable hotplug: %v", err)
+		m.udevMonFailed = true
+		return
+	}
+	if err := mon.Run(); err != nil {
+		mon.Stop()
+		logger.Noticef("cannot enable hotplug: %v", err)
+		m.udevMonFailed = true
+		return
+	}
+	m.udevMon = mon
+}
+
+// hotplugDeviceAdded is called by the udev monitor for the devices found
+// when it starts and for those plugged in afterwards. A change creating
+// slots for the device is made if some interfaces are interested in it.
+func (m *InterfaceManager) hotplugDeviceAdded(properties map[string]string) {
+	devinfo, err := interfaces.NewHotplugDeviceInfo(properties)
+	if err != nil {
+		logger.Debugf("%v", err)
+		return
+	}
+
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var tasks []*state.Task
+	for _, iface := range builtin.Interfaces() {
+		handler, ok := iface.(interfaces.HotplugDeviceHandler)
+		if !ok {
+			continue
+		}
+		spec, err := handler.HotplugDeviceDetected(devinfo)
+		if err != nil {
+			logger.Noticef("cannot handle device %s with interface %q: %v", devinfo, iface.Name(), err)
+			continue
+		}
+		if spec == nil {
+			continue
+		}
+		t := st.NewTask("hotplug-add-slot", fmt.Sprintf(i18n.G("Create %s slot for device %s"), iface.Name(), devinfo))
+		t.Set("interface", iface.Name())
+		t.Set("device-properties", properties)
+		tasks = append(tasks, t)
+	}
+	if len(tasks) == 0 {
+		return
+	}
+
+	chg := st.NewChange("hotplug-add", fmt.Sprintf(i18n.G("Add slots for device %s"), devinfo))
+	chg.AddAll(state.NewTaskSet(tasks...))
+	st.EnsureBefore(0)
+}
+
+// hotplugDeviceRemoved is called by the udev monitor when a device is
+// unplugged. A change removing the slots of the device is made if it had
+// some.
+func (m *InterfaceManager) hotplugDeviceRemoved(properties map[string]string) {
+	devinfo, err := interfaces.NewHotplugDeviceInfo(properties)
+	if err != nil {
+		logger.Debugf("%v", err)
+		return
+	}
+
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		logger.Noticef("cannot handle removal of device %s: %v", devinfo, err)
+		return
+	}
+	var names []string
+	for name, slot := range slots {
+		if slot.DevicePath == devinfo.DevicePath() {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+
+	var tasks []*state.Task
+	for _, name := range names {
+		t := st.NewTask("hotplug-remove-slot", fmt.Sprintf(i18n.G("Remove slot %q of device %s"), name, devinfo))
+		t.Set("slot", name)
+		tasks = append(tasks, t)
+	}
+	chg := st.NewChange("hotplug-remove", fmt.Sprintf(i18n.G("Remove slots of device %s"), devinfo))
+	chg.AddAll(state.NewTaskSet(tasks...))
+	st.EnsureBefore(0)
+}
+
+// hotplugSlotName returns a name for a new slot of the snap, based on
+// the one suggested by the interface but unique and valid.
+func (m *InterfaceManager) hotplugSlotName(snapInfo *snap.Info, suggested, ifaceName string, slots map[string]*hotplugSlotInfo) string {
+	if interfaces.ValidateName(suggested) != nil {
+		suggested = ifaceName
+	}
+	taken := func(name string) bool {
+		_, known := slots[name]
+		_, isSlot := snapInfo.Slots[name]
+		_, isPlug := snapInfo.Plugs[name]
+		return known || isSlot || isPlug || m.repo.Slot(snapInfo.Name(), name) != nil
+	}
+	name := suggested
+	for i := 1; taken(name); i++ {
+		name = fmt.Sprintf("%s-%d", suggested, i)
+	}
+	return name
+}
+
+func (m *InterfaceManager) doHotplugAddSlot(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var ifaceName string
+	if err := task.Get("interface", &ifaceName); err != nil {
+		return err
+	}
+	var properties map[string]string
+	if err := task.Get("device-properties", &properties); err != nil {
+		return err
+	}
+	devinfo, err := interfaces.NewHotplugDeviceInfo(properties)
+	if err != nil {
+		return err
+	}
+	iface := m.repo.Interface(ifaceName)
+	handler, ok := iface.(interfaces.HotplugDeviceHandler)
+	if !ok {
+		return fmt.Errorf("internal error: interface %q does not support hotplug", ifaceName)
+	}
+	spec, err := handler.HotplugDeviceDetected(devinfo)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		task.Logf("Device %s is not handled by interface %q anymore.", devinfo, ifaceName)
+		return nil
+	}
+	key, err := interfaces.HotplugKey(iface, devinfo)
+	if err != nil {
+		return err
+	}
+
+	coreInfo, err := snapstate.CoreInfo(st)
+	if err != nil {
+		return err
+	}
+	coreName := coreInfo.Name()
+
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		return err
+	}
+	var hotplugSlot *hotplugSlotInfo
+	for _, slot := range slots {
+		if slot.Interface == ifaceName && slot.HotplugKey == key {
+			hotplugSlot = slot
+			break
+		}
+	}
+	if hotplugSlot == nil {
+		name := m.hotplugSlotName(coreInfo, spec.Name, ifaceName, slots)
+		hotplugSlot = &hotplugSlotInfo{
+			Name:       name,
+			Interface:  ifaceName,
+			HotplugKey: key,
+		}
+		slots[name] = hotplugSlot
+	}
+	hotplugSlot.DevicePath = devinfo.DevicePath()
+	setHotplugSlots(st, slots)
+
+	// the device may have been plugged in again before its removal was
+	// handled, in which case the slot is replaced
+	affectedSnaps, err := m.removeHotplugSlot(coreName, hotplugSlot.Name)
+	if err != nil {
+		return err
+	}
+	slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+		Snap:      coreInfo,
+		Name:      hotplugSlot.Name,
+		Interface: ifaceName,
+		Label:     spec.Label,
+		Attrs:     spec.Attrs,
+	}}
+	if err := m.repo.AddSlot(slot); err != nil {
+		return err
+	}
+	task.Logf("Created slot %q for device %s.", hotplugSlot.Name, devinfo)
+
+	// restore the connections the slot had when the device was unplugged
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	for id := range conns {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		if connRef.SlotRef != slot.Ref() {
+			continue
+		}
+		if err := m.repo.ConnectWithAttrs(connRef, conns[id].Attrs); err != nil {
+			task.Logf("Cannot restore connection %s: %v", id, err)
+			continue
+		}
+		if err := m.recordConnectionEvent(st, "connect", ByHotplug, connRef, conns[id].Interface); err != nil {
+			return err
+		}
+		affectedSnaps = append(affectedSnaps, connRef.PlugRef.Snap)
+	}
+
+	return m.setupAffectedSnaps(task, coreName, uniqueSorted(affectedSnaps))
+}
+
+func (m *InterfaceManager) doHotplugRemoveSlot(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var slotName string
+	if err := task.Get("slot", &slotName); err != nil {
+		return err
+	}
+	coreInfo, err := snapstate.CoreInfo(st)
+	if err != nil {
+		return err
+	}
+
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		return err
+	}
+	// the slot is kept, for the device to get it back when plugged in
+	// again, as are its connections in the state
+	if slot, ok := slots[slotName]; ok {
+		slot.DevicePath = ""
+		setHotplugSlots(st, slots)
+	}
+
+	if m.repo.Slot(coreInfo.Name(), slotName) != nil {
+		connRefs, err := m.repo.Connected(coreInfo.Name(), slotName)
+		if err != nil {
+			return err
+		}
+		for _, connRef := range connRefs {
+			if err := m.recordConnectionEvent(st, "disconnect", ByHotplug, connRef, ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	affectedSnaps, err := m.removeHotplugSlot(coreInfo.Name(), slotName)
+	if err != nil {
+		return err
+	}
+	task.Logf("Removed slot %q.", slotName)
+	return m.setupAffectedSnaps(task, coreInfo.Name(), uniqueSorted(affectedSnaps))
+}
+
+// removeHotplugSlot disconnects and removes the slot from the repository,
+// if it is there, returning the snaps that were connected to it.
+func (m *InterfaceManager) removeHotplugSlot(snapName, slotName string) ([]string, error) {
+	if m.repo.Slot(snapName, slotName) == nil {
+		return nil, nil
+	}
+	connRefs, err := m.repo.Connected(snapName, slotName)
+	if err != nil {
+		return nil, err
+	}
+	m.repo.DisconnectAll(connRefs)
+	if err := m.repo.RemoveSlot(snapName, slotName); err != nil {
+		return nil, err
+	}
+	snapNames := make([]string, 0, len(connRefs))
+	for _, connRef := range connRefs {
+		snapNames = append(snapNames, connRef.PlugRef.Snap)
+	}
+	return snapNames, nil
+}
+
+// presentHotplugSlots returns the slots created for the hotplugged devices
+// currently plugged in, so they can be kept while the given snap, which
+// is the core snap, is refreshed.
+func (m *InterfaceManager) presentHotplugSlots(snapName string) ([]*snap.SlotInfo, error) {
+	slots, err := getHotplugSlots(m.state)
+	if err != nil {
+		return nil, err
+	}
+	var present []*snap.SlotInfo
+	for name := range slots {
+		if slot := m.repo.Slot(snapName, name); slot != nil {
+			present = append(present, slot.SlotInfo)
+		}
+	}
+	return present, nil
+}
+
+// readdHotplugSlots adds back the given slots of hotplugged devices to the
+// refreshed core snap.
+func (m *InterfaceManager) readdHotplugSlots(snapInfo *snap.Info, slotInfos []*snap.SlotInfo) {
+	for _, slotInfo := range slotInfos {
+		slot := &interfaces.Slot{SlotInfo: &snap.SlotInfo{
+			Snap:      snapInfo,
+			Name:      slotInfo.Name,
+			Interface: slotInfo.Interface,
+			Label:     slotInfo.Label,
+			Attrs:     slotInfo.Attrs,
+		}}
+		if err := m.repo.AddSlot(slot); err != nil {
+			logger.Noticef("cannot add back slot %q of hotplugged device: %v", slotInfo.Name, err)
+		}
+	}
+}
+
+func uniqueSorted(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
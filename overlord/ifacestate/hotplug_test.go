@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type fakeUDevMonitor struct {
+	added   udevmonitor.DeviceAddedFunc
+	removed udevmonitor.DeviceRemovedFunc
+	running bool
+}
+
+func (m *fakeUDevMonitor) Connect() error { return nil }
+func (m *fakeUDevMonitor) Run() error     { m.running = true; return nil }
+func (m *fakeUDevMonitor) Stop() error    { m.running = false; return nil }
+
+var serialPortConsumerYaml = `
+name: consumer
+version: 1
+plugs:
+ serial:
+  interface: serial-port
+`
+
+var ftdiProperties = map[string]string{
+	"ACTION":                 "add",
+	"DEVPATH":                "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0",
+	"DEVNAME":                "/dev/ttyUSB0",
+	"SUBSYSTEM":              "tty",
+	"ID_BUS":                 "usb",
+	"ID_VENDOR_ID":           "0403",
+	"ID_MODEL_ID":            "6001",
+	"ID_MODEL":               "FT232R_USB_UART",
+	"ID_SERIAL_SHORT":        "A50285BI",
+	"ID_USB_INTERFACE_NUM":   "00",
+	"ID_MODEL_FROM_DATABASE": "FT232 Serial (UART) IC",
+}
+
+func mockUDevMonitor() (mon *fakeUDevMonitor, restore func()) {
+	mon = &fakeUDevMonitor{}
+	restore = ifacestate.MockCreateUDevMonitor(func(added udevmonitor.DeviceAddedFunc, removed udevmonitor.DeviceRemovedFunc) udevmonitor.Interface {
+		mon.added = added
+		mon.removed = removed
+		return mon
+	})
+	return mon, restore
+}
+
+func (s *interfaceManagerSuite) enableHotplug(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "experimental.hotplug", true), IsNil)
+	tr.Commit()
+}
+
+func (s *interfaceManagerSuite) TestHotplugDisabled(c *C) {
+	mon, restore := mockUDevMonitor()
+	defer restore()
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.running, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestHotplugAddRemoveDevice(c *C) {
+	mon, restore := mockUDevMonitor()
+	defer restore()
+	s.enableHotplug(c)
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, serialPortConsumerYaml)
+	mgr := s.manager(c)
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(mon.running, Equals, true)
+
+	// devices no interface cares about are ignored
+	mon.added(map[string]string{"DEVPATH": "/devices/virtual/misc/fuse", "DEVNAME": "/dev/fuse", "SUBSYSTEM": "misc"})
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	s.state.Unlock()
+
+	mon.added(ftdiProperties)
+	s.settle(c)
+
+	s.state.Lock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "hotplug-add")
+	c.Check(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+	var slots map[string]map[string]interface{}
+	c.Assert(s.state.Get("hotplug-slots", &slots), IsNil)
+	c.Assert(slots, HasLen, 1)
+	c.Check(slots["ft232-serial-uart-ic"]["interface"], Equals, "serial-port")
+	c.Check(slots["ft232-serial-uart-ic"]["device-path"], Equals, ftdiProperties["DEVPATH"])
+	s.state.Unlock()
+
+	slot := mgr.Repository().Slot("ubuntu-core", "ft232-serial-uart-ic")
+	c.Assert(slot, NotNil)
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB0"})
+	c.Check(slot.Label, Equals, "FT232 Serial (UART) IC")
+
+	// connect the slot as a user would
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:serial ubuntu-core:ft232-serial-uart-ic": map[string]interface{}{"interface": "serial-port"},
+	})
+	s.state.Unlock()
+	c.Assert(mgr.Repository().Connect(interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "serial"},
+		SlotRef: interfaces.SlotRef{Snap: "ubuntu-core", Name: "ft232-serial-uart-ic"},
+	}), IsNil)
+
+	// unplugging the device removes the slot but keeps its connection
+	s.secBackend.SetupCalls = nil
+	mon.removed(map[string]string{"ACTION": "remove", "DEVPATH": ftdiProperties["DEVPATH"], "SUBSYSTEM": "tty"})
+	s.settle(c)
+
+	c.Check(mgr.Repository().Slot("ubuntu-core", "ft232-serial-uart-ic"), IsNil)
+	c.Assert(s.secBackend.SetupCalls, HasLen, 1)
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.Name(), Equals, "consumer")
+	s.state.Lock()
+	c.Assert(s.state.Changes(), HasLen, 2)
+	slots = nil
+	c.Assert(s.state.Get("hotplug-slots", &slots), IsNil)
+	c.Check(slots["ft232-serial-uart-ic"]["device-path"], IsNil)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 1)
+	s.state.Unlock()
+
+	// plugging it in again, even on another port, brings back the slot
+	// and its connection
+	s.secBackend.SetupCalls = nil
+	props := make(map[string]string)
+	for k, v := range ftdiProperties {
+		props[k] = v
+	}
+	props["DEVPATH"] = "/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/ttyUSB1/tty/ttyUSB1"
+	props["DEVNAME"] = "/dev/ttyUSB1"
+	mon.added(props)
+	s.settle(c)
+
+	slot = mgr.Repository().Slot("ubuntu-core", "ft232-serial-uart-ic")
+	c.Assert(slot, NotNil)
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB1"})
+	connected, err := mgr.Repository().Connected("ubuntu-core", "ft232-serial-uart-ic")
+	c.Assert(err, IsNil)
+	c.Check(connected, HasLen, 1)
+	c.Assert(s.secBackend.SetupCalls, HasLen, 1)
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.Name(), Equals, "consumer")
+	s.state.Lock()
+	slots = nil
+	c.Assert(s.state.Get("hotplug-slots", &slots), IsNil)
+	c.Check(slots, HasLen, 1)
+	s.state.Unlock()
+}
+
+func (s *interfaceManagerSuite) TestHotplugSlotNamesAreUnique(c *C) {
+	mon, restore := mockUDevMonitor()
+	defer restore()
+	s.enableHotplug(c)
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+
+	mon.added(ftdiProperties)
+	props := make(map[string]string)
+	for k, v := range ftdiProperties {
+		props[k] = v
+	}
+	props["DEVPATH"] = "/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/ttyUSB1/tty/ttyUSB1"
+	props["DEVNAME"] = "/dev/ttyUSB1"
+	props["ID_SERIAL_SHORT"] = "A50285BJ"
+	mon.added(props)
+	s.settle(c)
+
+	c.Check(mgr.Repository().Slot("ubuntu-core", "ft232-serial-uart-ic"), NotNil)
+	c.Check(mgr.Repository().Slot("ubuntu-core", "ft232-serial-uart-ic-1"), NotNil)
+}
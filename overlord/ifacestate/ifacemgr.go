@@ -22,7 +22,10 @@ package ifacestate
 import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/backends"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -33,6 +36,20 @@ type InterfaceManager struct {
 	state  *state.State
 	runner *state.TaskRunner
 	repo   *interfaces.Repository
+
+	// udevMon reports devices as they are plugged in and removed
+	// once hotplug is enabled
+	udevMon       udevmonitor.Interface
+	udevMonFailed bool
+
+	// fingerprints of the profiles set up by each backend for each
+	// snap, to skip setting them up again when they would not change
+	fingerprints map[string]map[interfaces.SecuritySystem]string
+
+	// prompting is whether the profiles are set up asking about the
+	// accesses through the prompted interfaces, protected by the state
+	// lock
+	prompting bool
 }
 
 // Manager returns a new InterfaceManager.
@@ -54,17 +71,23 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, extraInterfaces
 	if err := m.initialize(extraInterfaces, extraBackends); err != nil {
 		return nil, err
 	}
+	// warn about the connections refreshed snap declarations change
+	assertstate.SnapDeclarationsChanged = m.snapDeclarationsChanged
 
 	// interface tasks might touch more than the immediate task target snap, serialize them
 	runner.SetBlocked(func(_ *state.Task, running []*state.Task) bool {
 		return len(running) != 0
 	})
 
-	runner.AddHandler("connect", m.doConnect, nil)
-	runner.AddHandler("disconnect", m.doDisconnect, nil)
+	runner.AddHandler("connect", m.doConnect, m.undoConnect)
+	runner.AddHandler("disconnect", m.doDisconnect, m.undoDisconnect)
+	runner.AddHandler("update-profiles", m.doUpdateProfiles, nil)
+	runner.AddHandler("auto-connect", m.doAutoConnect, m.undoAutoConnect)
 	runner.AddHandler("setup-profiles", m.doSetupProfiles, m.undoSetupProfiles)
 	runner.AddHandler("remove-profiles", m.doRemoveProfiles, m.doSetupProfiles)
 	runner.AddHandler("discard-conns", m.doDiscardConns, m.undoDiscardConns)
+	runner.AddHandler("hotplug-add-slot", m.doHotplugAddSlot, nil)
+	runner.AddHandler("hotplug-remove-slot", m.doHotplugRemoveSlot, nil)
 
 	// helper for ubuntu-core -> core
 	runner.AddHandler("transition-ubuntu-core", m.doTransitionUbuntuCore, m.undoTransitionUbuntuCore)
@@ -74,6 +97,10 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, extraInterfaces
 
 // Ensure implements StateManager.Ensure.
 func (m *InterfaceManager) Ensure() error {
+	m.ensureUDevMonitor()
+	if err := m.ensurePrompting(); err != nil {
+		logger.Noticef("cannot update security profiles for prompting: %v", err)
+	}
 	m.runner.Ensure()
 	return nil
 }
@@ -85,8 +112,13 @@ func (m *InterfaceManager) Wait() {
 
 // Stop implements StateManager.Stop.
 func (m *InterfaceManager) Stop() {
+	if m.udevMon != nil {
+		if err := m.udevMon.Stop(); err != nil {
+			logger.Noticef("cannot stop udev monitor: %v", err)
+		}
+		m.udevMon = nil
+	}
 	m.runner.Stop()
-
 }
 
 // Repository returns the interface repository used internally by the manager.
@@ -23,7 +23,9 @@ package ifacestate
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
@@ -36,16 +38,23 @@ import (
 )
 
 var noConflictOnConnectTasks = func(kind string) bool {
-	return kind != "connect" && kind != "disconnect"
+	return kind != "connect" && kind != "disconnect" && kind != "update-profiles"
 }
 
 // Connect returns a set of tasks for connecting an interface.
 //
 func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
-	if err := snapstate.CheckChangeConflict(st, plugSnap, noConflictOnConnectTasks, nil); err != nil {
+	return ConnectWithAttrs(st, plugSnap, plugName, slotSnap, slotName, nil)
+}
+
+// ConnectWithAttrs returns a set of tasks for connecting an interface like
+// Connect, with the given attributes overriding those of the slot for the
+// connection. The interface must support them.
+func ConnectWithAttrs(st *state.State, plugSnap, plugName, slotSnap, slotName string, attrs map[string]interface{}) (*state.TaskSet, error) {
+	if err := snapstate.CheckChangeConflictIgnoringPreparation(st, plugSnap, noConflictOnConnectTasks); err != nil {
 		return nil, err
 	}
-	if err := snapstate.CheckChangeConflict(st, slotSnap, noConflictOnConnectTasks, nil); err != nil {
+	if err := snapstate.CheckChangeConflictIgnoringPreparation(st, slotSnap, noConflictOnConnectTasks); err != nil {
 		return nil, err
 	}
 
@@ -64,6 +73,8 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 	// 'snapctl set' can only modify own attributes (plug's attributes in the *-plug-* hook and
 	// slot's attributes in the *-slot-* hook).
 	// 'snapctl get' can read both slot's and plug's attributes.
+	// 'snapctl remote' can read the snap, publisher and static attributes
+	// of the other end of the connection.
 	summary := fmt.Sprintf(i18n.G("Connect %s:%s to %s:%s"),
 		plugSnap, plugName, slotSnap, slotName)
 	connectInterface := st.NewTask("connect", summary)
@@ -95,6 +106,9 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 	if err := setInitialConnectAttributes(connectInterface, plugSnap, plugName, slotSnap, slotName); err != nil {
 		return nil, err
 	}
+	if len(attrs) > 0 {
+		connectInterface.Set("connection-attrs", attrs)
+	}
 	connectInterface.WaitFor(prepareSlotConnection)
 
 	connectSlotHookSetup := &hookstate.HookSetup{
@@ -122,6 +136,7 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 
 func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName string, slotSnap string, slotName string) error {
 	// Set initial interface attributes for the plug and slot snaps in connect task.
+	// The static ones are kept aside as the prepare- hooks may change the others.
 	var snapst snapstate.SnapState
 	var err error
 
@@ -135,6 +150,7 @@ func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName strin
 	}
 	if plug, ok := snapInfo.Plugs[plugName]; ok {
 		ts.Set("plug-attrs", plug.Attrs)
+		ts.Set("plug-static-attrs", plug.Attrs)
 	} else {
 		return fmt.Errorf("snap %q has no plug named %q", plugSnap, plugName)
 	}
@@ -149,6 +165,7 @@ func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName strin
 	addImplicitSlots(snapInfo)
 	if slot, ok := snapInfo.Slots[slotName]; ok {
 		ts.Set("slot-attrs", slot.Attrs)
+		ts.Set("slot-static-attrs", slot.Attrs)
 	} else {
 		return fmt.Errorf("snap %q has no slot named %q", slotSnap, slotName)
 	}
@@ -158,21 +175,166 @@ func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName strin
 
 // Disconnect returns a set of tasks for  disconnecting an interface.
 func Disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
-	if err := snapstate.CheckChangeConflict(st, plugSnap, noConflictOnConnectTasks, nil); err != nil {
+	if err := snapstate.CheckChangeConflictIgnoringPreparation(st, plugSnap, noConflictOnConnectTasks); err != nil {
 		return nil, err
 	}
-	if err := snapstate.CheckChangeConflict(st, slotSnap, noConflictOnConnectTasks, nil); err != nil {
+	if err := snapstate.CheckChangeConflictIgnoringPreparation(st, slotSnap, noConflictOnConnectTasks); err != nil {
 		return nil, err
 	}
 
+	// Create a series of tasks:
+	//  - disconnect-plug-<plug> hook
+	//  - disconnect-slot-<slot> hook
+	//  - disconnect task
+	// The hooks run while the connection is still made, so that the snaps
+	// can deregister from each other, and can read the final attributes
+	// of the connection with 'snapctl get'. They are given a bounded time
+	// and their failure doesn't prevent the disconnection.
 	summary := fmt.Sprintf(i18n.G("Disconnect %s:%s from %s:%s"),
 		plugSnap, plugName, slotSnap, slotName)
 	task := st.NewTask("disconnect", summary)
 	task.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
 	task.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
+	if err := setFinalConnectionAttributes(task, plugSnap, plugName, slotSnap, slotName); err != nil {
+		return nil, err
+	}
+
+	initialContext := make(map[string]interface{})
+	initialContext["attrs-task"] = task.ID()
+
+	disconnectPlugHookSetup := &hookstate.HookSetup{
+		Snap:        plugSnap,
+		Hook:        "disconnect-plug-" + plugName,
+		Optional:    true,
+		Timeout:     disconnectHookTimeout,
+		IgnoreError: true,
+	}
+	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), disconnectPlugHookSetup.Hook, disconnectPlugHookSetup.Snap)
+	disconnectPlugConnection := hookstate.HookTask(st, summary, disconnectPlugHookSetup, initialContext)
+
+	disconnectSlotHookSetup := &hookstate.HookSetup{
+		Snap:        slotSnap,
+		Hook:        "disconnect-slot-" + slotName,
+		Optional:    true,
+		Timeout:     disconnectHookTimeout,
+		IgnoreError: true,
+	}
+	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), disconnectSlotHookSetup.Hook, disconnectSlotHookSetup.Snap)
+	disconnectSlotConnection := hookstate.HookTask(st, summary, disconnectSlotHookSetup, initialContext)
+	disconnectSlotConnection.WaitFor(disconnectPlugConnection)
+
+	task.WaitFor(disconnectSlotConnection)
+
+	return state.NewTaskSet(disconnectPlugConnection, disconnectSlotConnection, task), nil
+}
+
+// disconnectHookTimeout is how long the disconnect hooks of the plug and
+// of the slot may run each.
+var disconnectHookTimeout = 30 * time.Second
+
+// setFinalConnectionAttributes sets in the disconnect task the attributes
+// of the plug and of the slot as connected, for the disconnect hooks, the
+// static ones being kept aside as for connect. The attributes of a plug or
+// slot that is gone are left empty.
+func setFinalConnectionAttributes(task *state.Task, plugSnap, plugName, slotSnap, slotName string) error {
+	st := task.State()
+	plugAttrs := map[string]interface{}{}
+	slotStaticAttrs := map[string]interface{}{}
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, plugSnap, &snapst); err == nil {
+		if snapInfo, err := snapst.CurrentInfo(); err == nil {
+			if plug, ok := snapInfo.Plugs[plugName]; ok {
+				plugAttrs = plug.Attrs
+			}
+		}
+	} else if err != state.ErrNoState {
+		return err
+	}
+	if err := snapstate.Get(st, slotSnap, &snapst); err == nil {
+		if snapInfo, err := snapst.CurrentInfo(); err == nil {
+			addImplicitSlots(snapInfo)
+			if slot, ok := snapInfo.Slots[slotName]; ok {
+				slotStaticAttrs = slot.Attrs
+			}
+		}
+	} else if err != state.ErrNoState {
+		return err
+	}
+	slotAttrs := make(map[string]interface{}, len(slotStaticAttrs))
+	for k, v := range slotStaticAttrs {
+		slotAttrs[k] = v
+	}
+
+	// the attributes of the slot overridden for the connection
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: plugSnap, Name: plugName},
+		SlotRef: interfaces.SlotRef{Snap: slotSnap, Name: slotName},
+	}
+	for k, v := range conns[connRef.ID()].Attrs {
+		slotAttrs[k] = v
+	}
+
+	task.Set("plug-attrs", plugAttrs)
+	task.Set("plug-static-attrs", plugAttrs)
+	task.Set("slot-attrs", slotAttrs)
+	task.Set("slot-static-attrs", slotStaticAttrs)
+	return nil
+}
+
+// AutoConnect returns a set of tasks for connecting the plugs and slots
+// of the given snap that the auto-connection policy allows but that
+// are not connected yet, e.g. after its snap declaration was updated.
+func AutoConnect(st *state.State, snapName string) (*state.TaskSet, error) {
+	if err := snapstate.CheckChangeConflict(st, snapName, noConflictOnConnectTasks, nil); err != nil {
+		return nil, err
+	}
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, snapName, &snapst)
+	if err == state.ErrNoState {
+		return nil, fmt.Errorf("snap %q is not installed", snapName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf(i18n.G("Auto-connect plugs and slots of snap %q"), snapName)
+	task := st.NewTask("auto-connect", summary)
+	task.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: snapName},
+	})
 	return state.NewTaskSet(task), nil
 }
 
+// ConnectedPlugs returns the names of the plugs of the given snap
+// that are connected using the given interface.
+func ConnectedPlugs(st *state.State, snapName, ifaceName string) ([]string, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	var plugs []string
+	for id, conn := range conns {
+		if conn.Interface != ifaceName {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		if connRef.PlugRef.Snap == snapName {
+			plugs = append(plugs, connRef.PlugRef.Name)
+		}
+	}
+	sort.Strings(plugs)
+	return plugs, nil
+}
+
 // CheckInterfaces checks whether plugs and slots of snap are allowed for installation.
 func CheckInterfaces(st *state.State, snapInfo *snap.Info) error {
 	// XXX: addImplicitSlots is really a brittle interface
@@ -193,10 +355,16 @@ func CheckInterfaces(st *state.State, snapInfo *snap.Info) error {
 		return fmt.Errorf("cannot find snap declaration for %q: %v", snapInfo.Name(), err)
 	}
 
+	model, err := deviceModel(st)
+	if err != nil {
+		return err
+	}
+
 	ic := policy.InstallCandidate{
 		Snap:            snapInfo,
 		SnapDeclaration: snapDecl,
 		BaseDeclaration: baseDecl,
+		Model:           model,
 	}
 
 	return ic.Check()
@@ -210,5 +378,180 @@ func delayedCrossMgrInit() {
 		snapstate.AddCheckSnapCallback(func(st *state.State, snapInfo, _ *snap.Info, _ snapstate.Flags) error {
 			return CheckInterfaces(st, snapInfo)
 		})
+		// the profiles updates of batches conflict like the
+		// connect tasks before them
+		snapstate.AddAffectedSnapsByKind("update-profiles", func(t *state.Task) ([]string, error) {
+			var snapName string
+			if err := t.Get("snap", &snapName); err != nil {
+				return nil, err
+			}
+			return []string{snapName}, nil
+		})
 	})
 }
+
+// ConnectionState describes a connection recorded in the state.
+type ConnectionState struct {
+	Ref       interfaces.ConnRef
+	Interface string
+	// Auto is whether the connection was made by the auto-connection
+	// policy rather than manually.
+	Auto bool
+	// Attrs are the attributes given when connecting, overriding those
+	// of the slot.
+	Attrs map[string]interface{}
+}
+
+// ConnectionStates returns the connections recorded in the state,
+// sorted by plug and then by slot.
+func ConnectionStates(st *state.State) ([]*ConnectionState, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	connStates := make([]*ConnectionState, 0, len(ids))
+	for _, id := range ids {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		connStates = append(connStates, &ConnectionState{
+			Ref:       connRef,
+			Interface: conns[id].Interface,
+			Auto:      conns[id].Auto,
+			Attrs:     conns[id].Attrs,
+		})
+	}
+	return connStates, nil
+}
+
+// ConnectBatch returns a set of tasks for making the given connections
+// in one go. The connect tasks only update the interfaces repository, the
+// security profiles of each affected snap are then set up once, and only
+// then do the connect hooks run. The batch is all or nothing: failing to
+// make one of the connections undoes the others. Connections that are
+// already made, or given twice, are skipped, and nil is returned if none
+// is left.
+func ConnectBatch(st *state.State, connRefs []interfaces.ConnRef) (*state.TaskSet, error) {
+	return ConnectionsBatch(st, connRefs, nil)
+}
+
+// DisconnectBatch returns a set of tasks for breaking the given
+// connections in one go, setting up the security profiles of each
+// affected snap once at the end. As with ConnectBatch, the batch is all or
+// nothing. Connections that are not made, or given twice, are skipped,
+// and nil is returned if none is left.
+func DisconnectBatch(st *state.State, connRefs []interfaces.ConnRef) (*state.TaskSet, error) {
+	return ConnectionsBatch(st, nil, connRefs)
+}
+
+// ConnectionsBatch returns a set of tasks for breaking the connections of
+// disconnect and then making those of connect in one go, setting up the
+// security profiles of each affected snap once, before the connect hooks
+// run. As with ConnectBatch, the batch is all or nothing. Connections that
+// are already as asked, or given twice, are skipped, and nil is returned
+// if none is left.
+func ConnectionsBatch(st *state.State, connect, disconnect []interfaces.ConnRef) (*state.TaskSet, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	ts := state.NewTaskSet()
+	var last *state.Task
+	var batched []interfaces.ConnRef
+	disconnected := make(map[string]bool, len(disconnect))
+	for _, connRef := range disconnect {
+		id := connRef.ID()
+		if _, ok := conns[id]; !ok || disconnected[id] {
+			continue
+		}
+		disconnected[id] = true
+		disconnectTs, err := Disconnect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		// the disconnect-plug and disconnect-slot hooks, then disconnect
+		tasks := disconnectTs.Tasks()
+		tasks[2].Set("delayed-setup-profiles", true)
+		if last != nil {
+			tasks[0].WaitFor(last)
+		}
+		last = tasks[2]
+		ts.AddAll(disconnectTs)
+		batched = append(batched, connRef)
+	}
+
+	var hookTasks []*state.Task
+	connected := make(map[string]bool, len(connect))
+	for _, connRef := range connect {
+		id := connRef.ID()
+		if _, ok := conns[id]; ok && !disconnected[id] || connected[id] {
+			continue
+		}
+		connected[id] = true
+		connectTs, err := Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		// prepare-plug, prepare-slot and connect, then the connect-slot
+		// and connect-plug hooks
+		tasks := connectTs.Tasks()
+		tasks[2].Set("delayed-setup-profiles", true)
+		if last != nil {
+			tasks[0].WaitFor(last)
+		}
+		last = tasks[2]
+		ts.AddAll(state.NewTaskSet(tasks[:3]...))
+		hookTasks = append(hookTasks, tasks[3:]...)
+		batched = append(batched, connRef)
+	}
+	if len(batched) == 0 {
+		return nil, nil
+	}
+
+	profileTasks := batchProfilesTasks(st, batched, last)
+	ts.AddAll(state.NewTaskSet(profileTasks...))
+	for i, t := range hookTasks {
+		if i == 0 {
+			t.WaitFor(profileTasks[len(profileTasks)-1])
+		} else if i%2 == 0 {
+			// the hooks of the previous connection
+			t.WaitFor(hookTasks[i-1])
+		}
+		ts.AddTask(t)
+	}
+	return ts, nil
+}
+
+// batchProfilesTasks returns the tasks setting up, one after the other
+// once the given task is done, the security profiles of the snaps of the
+// given connections.
+func batchProfilesTasks(st *state.State, connRefs []interfaces.ConnRef, after *state.Task) []*state.Task {
+	seen := make(map[string]bool)
+	var snapNames []string
+	for _, connRef := range connRefs {
+		for _, snapName := range []string{connRef.SlotRef.Snap, connRef.PlugRef.Snap} {
+			if !seen[snapName] {
+				seen[snapName] = true
+				snapNames = append(snapNames, snapName)
+			}
+		}
+	}
+	sort.Strings(snapNames)
+	tasks := make([]*state.Task, 0, len(snapNames))
+	for _, snapName := range snapNames {
+		summary := fmt.Sprintf(i18n.G("Update security profiles of snap %q"), snapName)
+		t := st.NewTask("update-profiles", summary)
+		t.Set("snap", snapName)
+		t.WaitFor(after)
+		after = t
+		tasks = append(tasks, t)
+	}
+	return tasks
+}
@@ -21,6 +21,9 @@ package ifacestate_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -31,9 +34,13 @@ import (
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -135,6 +142,41 @@ func (s *interfaceManagerSuite) TestSmoke(c *C) {
 	mgr.Wait()
 }
 
+func (s *interfaceManagerSuite) TestEnsureRegeneratesProfilesForPrompting(c *C) {
+	aaBackend := &ifacetest.TestSecurityBackend{BackendName: interfaces.SecurityAppArmor}
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{aaBackend})
+	defer restore()
+	prompting := false
+	restore = ifacestate.MockPromptingEnabled(func(*state.State) (bool, error) {
+		return prompting, nil
+	})
+	defer restore()
+	s.mockSnap(c, consumerYaml)
+
+	mgr := s.manager(c)
+	c.Assert(aaBackend.SetupCalls, HasLen, 1)
+	c.Check(aaBackend.SetupCalls[0].Options.Prompting, Equals, false)
+
+	// nothing changed, nothing is set up again
+	aaBackend.SetupCalls = nil
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(aaBackend.SetupCalls, HasLen, 0)
+
+	// the profiles ask about accesses once prompting is enabled
+	prompting = true
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(aaBackend.SetupCalls, HasLen, 1)
+	c.Check(aaBackend.SetupCalls[0].SnapInfo.Name(), Equals, "consumer")
+	c.Check(aaBackend.SetupCalls[0].Options.Prompting, Equals, true)
+
+	// and no longer once it is disabled
+	prompting = false
+	aaBackend.SetupCalls = nil
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(aaBackend.SetupCalls, HasLen, 1)
+	c.Check(aaBackend.SetupCalls[0].Options.Prompting, Equals, false)
+}
+
 func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
@@ -182,6 +224,13 @@ func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	err = task.Get("slot-attrs", &attrs)
 	c.Assert(err, IsNil)
 	c.Assert(attrs["attr2"], Equals, "value2")
+	// and kept aside as the static ones
+	err = task.Get("plug-static-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs["attr1"], Equals, "value1")
+	err = task.Get("slot-static-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs["attr2"], Equals, "value2")
 	i++
 	task = ts.Tasks()[i]
 	c.Check(task.Kind(), Equals, "run-hook")
@@ -261,6 +310,37 @@ func (s *interfaceManagerSuite) TestConnectDoesntConflict(c *C) {
 	c.Assert(err, ErrorMatches, `snap "consumer" has changes in progress`)
 }
 
+func (s *interfaceManagerSuite) TestConnectDuringDownload(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("refresh-snap", "...")
+	download := s.state.NewTask("download-snap", "...")
+	download.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "consumer"},
+	})
+	download.SetStatus(state.DoingStatus)
+	chg.AddTask(download)
+	link := s.state.NewTask("link-snap", "...")
+	link.Set("snap-setup-task", download.ID())
+	link.WaitFor(download)
+	chg.AddTask(link)
+
+	_, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+
+	// but not once the snap is being linked
+	download.SetStatus(state.DoneStatus)
+	link.SetStatus(state.DoingStatus)
+	_, err = ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, ErrorMatches, `snap "consumer" has changes in progress`)
+}
+
 func (s *interfaceManagerSuite) TestEnsureProcessesConnectTask(c *C) {
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
@@ -369,6 +449,323 @@ func (s *interfaceManagerSuite) TestConnectTaskNoSuchPlug(c *C) {
 	c.Assert(err, ErrorMatches, `snap "consumer" has no plug named "whatplug"`)
 }
 
+func (s *interfaceManagerSuite) TestConnectionStates(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	connStates, err := ifacestate.ConnectionStates(s.state)
+	c.Assert(err, IsNil)
+	c.Check(connStates, HasLen, 0)
+
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":      map[string]interface{}{"interface": "test", "attrs": map[string]interface{}{"path": "/dev/foo"}},
+		"consumer:otherplug producer:slot": map[string]interface{}{"interface": "test2", "auto": true},
+	})
+	connStates, err = ifacestate.ConnectionStates(s.state)
+	c.Assert(err, IsNil)
+	c.Check(connStates, DeepEquals, []*ifacestate.ConnectionState{{
+		Ref: interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "otherplug"},
+			SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		},
+		Interface: "test2",
+		Auto:      true,
+	}, {
+		Ref: interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		},
+		Interface: "test",
+		Attrs:     map[string]interface{}{"path": "/dev/foo"},
+	}})
+}
+
+func (s *interfaceManagerSuite) TestConnectBatch(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("conns", map[string]interface{}{
+		"consumer2:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	connRef := func(plugSnap, slotSnap string) interfaces.ConnRef {
+		return interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: plugSnap, Name: "plug"},
+			SlotRef: interfaces.SlotRef{Snap: slotSnap, Name: "slot"},
+		}
+	}
+
+	// connections already made, or twice in the batch, are skipped
+	ts, err := ifacestate.ConnectBatch(s.state, []interfaces.ConnRef{
+		connRef("consumer", "producer"),
+		connRef("consumer2", "producer"),
+		connRef("consumer", "producer"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(ts, NotNil)
+	var kinds []string
+	for _, t := range ts.Tasks() {
+		kinds = append(kinds, t.Kind())
+		if t.Kind() == "connect" {
+			var plug interfaces.PlugRef
+			c.Assert(t.Get("plug", &plug), IsNil)
+			c.Check(plug, Equals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+		}
+	}
+	c.Check(kinds, DeepEquals, []string{"run-hook", "run-hook", "connect", "update-profiles", "update-profiles", "run-hook", "run-hook"})
+
+	ts, err = ifacestate.ConnectBatch(s.state, []interfaces.ConnRef{connRef("consumer2", "producer")})
+	c.Assert(err, IsNil)
+	c.Check(ts, IsNil)
+}
+
+// A batch of connections sets up the profiles of each snap once, after
+// all the connections are made and before the connect hooks run.
+func (s *interfaceManagerSuite) TestConnectBatchSetsUpProfilesOnce(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.ConnectBatch(s.state, []interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}, {
+		PlugRef: interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+	c.Assert(err, IsNil)
+	tasks := ts.Tasks()
+	c.Assert(tasks, HasLen, 13)
+	// the hooks of the first connection wait for the last profiles
+	c.Check(tasks[9].WaitTasks(), testutil.Contains, tasks[8])
+	change := s.state.NewChange("connect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 2)
+
+	// each snap was set up once, by the update-profiles tasks
+	var names []string
+	for _, snapInfo := range s.secBackend.SetupCalls {
+		names = append(names, snapInfo.SnapInfo.Name())
+	}
+	c.Check(names, DeepEquals, []string{"consumer", "consumer2", "producer"})
+
+	repo := mgr.Repository()
+	c.Check(repo.Slot("producer", "slot").Connections, HasLen, 2)
+}
+
+// A batch of connections is undone as a whole when one of them fails.
+func (s *interfaceManagerSuite) TestConnectBatchUndo(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.ConnectBatch(s.state, []interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}, {
+		PlugRef: interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("connect", "...")
+	change.AddAll(ts)
+	// make the last task fail
+	tasks := ts.Tasks()
+	terr := s.state.NewTask("error-trigger", "...")
+	terr.WaitFor(tasks[len(tasks)-1])
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+	repo := mgr.Repository()
+	c.Check(repo.Slot("producer", "slot").Connections, HasLen, 0)
+
+	// the profiles were set up again without the connections
+	c.Check(len(s.secBackend.SetupCalls) > 3, Equals, true)
+}
+
+func (s *interfaceManagerSuite) TestDisconnectBatch(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":  map[string]interface{}{"interface": "test"},
+		"consumer2:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.DisconnectBatch(s.state, []interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}, {
+		PlugRef: interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+	c.Assert(err, IsNil)
+	var kinds []string
+	for _, t := range ts.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{
+		"run-hook", "run-hook", "disconnect",
+		"run-hook", "run-hook", "disconnect",
+		"update-profiles", "update-profiles", "update-profiles",
+	})
+	change := s.state.NewChange("disconnect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 0)
+	c.Check(mgr.Repository().Slot("producer", "slot").Connections, HasLen, 0)
+	c.Check(s.secBackend.SetupCalls, HasLen, 3)
+
+	// not connected anymore, so nothing to do
+	ts, err = ifacestate.DisconnectBatch(s.state, []interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+	c.Assert(err, IsNil)
+	c.Check(ts, IsNil)
+}
+
+// A batch breaks its connections first, then makes the others, and sets
+// up the profiles of each snap once.
+func (s *interfaceManagerSuite) TestConnectionsBatch(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+	mgr := s.manager(c)
+
+	connRef := func(plugSnap string) interfaces.ConnRef {
+		return interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: plugSnap, Name: "plug"},
+			SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		}
+	}
+
+	s.state.Lock()
+	ts, err := ifacestate.ConnectionsBatch(s.state, []interfaces.ConnRef{connRef("consumer2")}, []interfaces.ConnRef{connRef("consumer")})
+	c.Assert(err, IsNil)
+	var kinds []string
+	for _, t := range ts.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{
+		"run-hook", "run-hook", "disconnect",
+		"run-hook", "run-hook", "connect",
+		"update-profiles", "update-profiles", "update-profiles",
+		"run-hook", "run-hook",
+	})
+	change := s.state.NewChange("connections-batch", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 1)
+	c.Check(conns["consumer2:plug producer:slot"], NotNil)
+	c.Check(mgr.Repository().Slot("producer", "slot").Connections, DeepEquals, []interfaces.PlugRef{{Snap: "consumer2", Name: "plug"}})
+	c.Check(s.secBackend.SetupCalls, HasLen, 3)
+}
+
+func (s *interfaceManagerSuite) TestConnectionHistory(c *C) {
+	restore := ifacestate.MockMaxConnectionHistory(2)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("conns-history", []map[string]interface{}{
+		{"action": "connect", "by": "user", "plug": map[string]string{"snap": "a", "plug": "p"}, "slot": map[string]string{"snap": "core", "slot": "s"}},
+		{"action": "connect", "by": "user", "plug": map[string]string{"snap": "b", "plug": "p"}, "slot": map[string]string{"snap": "core", "slot": "s"}},
+	})
+
+	events, err := ifacestate.ConnectionHistory(s.state, "a")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Plug, Equals, interfaces.PlugRef{Snap: "a", Name: "p"})
+
+	events, err = ifacestate.ConnectionHistory(s.state, "core")
+	c.Assert(err, IsNil)
+	c.Check(events, HasLen, 2)
+
+	events, err = ifacestate.ConnectionHistory(s.state, "c")
+	c.Assert(err, IsNil)
+	c.Check(events, HasLen, 0)
+
+	// the oldest events are dropped once the history is full
+	s.state.Unlock()
+	mgr := s.manager(c)
+	s.state.Lock()
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "c", Name: "p"},
+		SlotRef: interfaces.SlotRef{Snap: "core", Name: "s"},
+	}
+	c.Assert(ifacestate.RecordConnectionEvent(mgr, s.state, "disconnect", ifacestate.ByUser, connRef, "test"), IsNil)
+
+	events, err = ifacestate.ConnectionHistory(s.state, "")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 2)
+	c.Check(events[0].Plug.Snap, Equals, "b")
+	c.Check(events[1].Plug.Snap, Equals, "c")
+	c.Check(events[1].Interface, Equals, "test")
+}
+
 func (s *interfaceManagerSuite) TestConnectTaskCheckNotAllowed(c *C) {
 	s.testConnectTaskCheck(c, func() {
 		s.mockSnapDecl(c, "consumer", "consumer-publisher", nil)
@@ -423,6 +820,62 @@ func (s *interfaceManagerSuite) TestConnectTaskCheckAllowed(c *C) {
 	})
 }
 
+func (s *interfaceManagerSuite) TestConnectTaskCheckSitePolicyForbid(c *C) {
+	s.testConnectTaskCheck(c, func() {
+		s.mockSnap(c, consumerYaml)
+		s.mockSnap(c, producerYaml)
+		s.mockSitePolicy(c, "forbid: [test]\n")
+	}, func(change *state.Change) {
+		c.Check(change.Err(), ErrorMatches, `(?s).*interface "test" is forbidden by the site policy.*`)
+		c.Check(change.Status(), Equals, state.ErrorStatus)
+
+		repo := s.manager(c).Repository()
+		plug := repo.Plug("consumer", "plug")
+		c.Check(plug.Connections, HasLen, 0)
+	})
+}
+
+func (s *interfaceManagerSuite) TestConnectTaskCheckSitePolicyInvalid(c *C) {
+	s.testConnectTaskCheck(c, func() {
+		s.mockSnap(c, consumerYaml)
+		s.mockSnap(c, producerYaml)
+		s.mockSitePolicy(c, "forbid: [test\n")
+	}, func(change *state.Change) {
+		c.Check(change.Err(), ErrorMatches, `(?s).*cannot use the site interface policy: cannot parse .*/interfaces-policy.yaml: .*`)
+		c.Check(change.Status(), Equals, state.ErrorStatus)
+
+		repo := s.manager(c).Repository()
+		plug := repo.Plug("consumer", "plug")
+		c.Check(plug.Connections, HasLen, 0)
+	})
+}
+
+func (s *interfaceManagerSuite) TestConnectTaskRecordsHistory(c *C) {
+	s.testConnectTaskCheck(c, func() {
+		s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+		s.mockSnap(c, consumerYaml)
+		s.mockSnapDecl(c, "producer", "one-publisher", nil)
+		s.mockSnap(c, producerYaml)
+	}, func(change *state.Change) {
+		c.Assert(change.Err(), IsNil)
+
+		events, err := ifacestate.ConnectionHistory(s.state, "consumer")
+		c.Assert(err, IsNil)
+		c.Assert(events, HasLen, 1)
+		c.Check(events[0].Time.IsZero(), Equals, false)
+		events[0].Time = time.Time{}
+		c.Check(events[0], DeepEquals, &ifacestate.ConnectionEvent{
+			Action:    "connect",
+			By:        ifacestate.ByUser,
+			Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+			Interface: "test",
+			PlugAttrs: map[string]interface{}{"attr1": "value1"},
+			SlotAttrs: map[string]interface{}{"attr2": "value2"},
+		})
+	})
+}
+
 func (s *interfaceManagerSuite) testConnectTaskCheck(c *C, setup func(), check func(*state.Change)) {
 	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
 type: base-declaration
@@ -463,13 +916,22 @@ slots:
 }
 
 func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
 	s.state.Lock()
 	defer s.state.Unlock()
 
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "attrs": map[string]interface{}{"attr2": "override"}},
+	})
+
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
 	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 3)
 
-	task := ts.Tasks()[0]
+	task := ts.Tasks()[2]
 	c.Assert(task.Kind(), Equals, "disconnect")
 	var plug interfaces.PlugRef
 	err = task.Get("plug", &plug)
@@ -481,22 +943,127 @@ func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(slot.Snap, Equals, "producer")
 	c.Assert(slot.Name, Equals, "slot")
+
+	// the final attributes of the connection are there for the hooks
+	for _, t := range []struct {
+		key   string
+		attrs map[string]interface{}
+	}{
+		{"plug-attrs", map[string]interface{}{"attr1": "value1"}},
+		{"slot-attrs", map[string]interface{}{"attr2": "override"}},
+		{"slot-static-attrs", map[string]interface{}{"attr2": "value2"}},
+	} {
+		var attrs map[string]interface{}
+		c.Assert(task.Get(t.key, &attrs), IsNil)
+		c.Check(attrs, DeepEquals, t.attrs, Commentf(t.key))
+	}
+
+	// the disconnect hooks run first, with a bounded time
+	for i, expected := range []hookstate.HookSetup{
+		{Snap: "consumer", Hook: "disconnect-plug-plug", Optional: true, Timeout: 30 * time.Second, IgnoreError: true},
+		{Snap: "producer", Hook: "disconnect-slot-slot", Optional: true, Timeout: 30 * time.Second, IgnoreError: true},
+	} {
+		hookTask := ts.Tasks()[i]
+		c.Check(hookTask.Kind(), Equals, "run-hook")
+		var hookSetup hookstate.HookSetup
+		c.Assert(hookTask.Get("hook-setup", &hookSetup), IsNil)
+		c.Check(hookSetup, Equals, expected)
+		var context map[string]interface{}
+		c.Assert(hookTask.Get("hook-context", &context), IsNil)
+		c.Check(context["attrs-task"], Equals, task.ID())
+	}
+	c.Check(task.WaitTasks(), DeepEquals, []*state.Task{ts.Tasks()[1]})
 }
 
 // Disconnect works when both plug and slot are specified
-func (s *interfaceManagerSuite) TestDisconnectFull(c *C) {
-	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
+// specBackend is a test backend using the specification of a real one,
+// so that the profiles it would set up can be told apart.
+type specBackend struct {
+	ifacetest.TestSecurityBackend
+	newSpec func() interfaces.Specification
 }
 
-func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSnap, slotName string) {
-	// Put two snaps in place They consumer has an plug that can be connected
-	// to slot on the producer.
-	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
-	s.mockSnap(c, consumerYaml)
-	s.mockSnap(c, producerYaml)
+func (b *specBackend) NewSpecification() interfaces.Specification {
+	return b.newSpec()
+}
 
-	// Put a connection in the state so that it automatically gets set up when
-	// we create the manager.
+func setupCallSnaps(b *specBackend) []string {
+	var names []string
+	for _, call := range b.SetupCalls {
+		names = append(names, call.SnapInfo.Name())
+	}
+	return names
+}
+
+func (s *interfaceManagerSuite) TestConnectDisconnectSetsUpChangedProfilesOnly(c *C) {
+	aaBackend := &specBackend{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: interfaces.SecurityAppArmor},
+		newSpec:             func() interfaces.Specification { return &apparmor.Specification{} },
+	}
+	udevBackend := &specBackend{
+		TestSecurityBackend: ifacetest.TestSecurityBackend{BackendName: interfaces.SecurityUDev},
+		newSpec:             func() interfaces.Specification { return &udev.Specification{} },
+	}
+	restore := ifacestate.MockSecurityBackends([]interfaces.SecurityBackend{aaBackend, udevBackend})
+	defer restore()
+
+	// only the udev rules of the plug snap depend on the connection
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		UDevConnectedPlugCallback: func(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+			spec.AddSnippet(`KERNEL=="foo"`)
+			return nil
+		},
+	})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.manager(c)
+
+	run := func(f func(*state.State, string, string, string, string) (*state.TaskSet, error)) {
+		aaBackend.SetupCalls = nil
+		udevBackend.SetupCalls = nil
+		s.state.Lock()
+		ts, err := f(s.state, "consumer", "plug", "producer", "slot")
+		c.Assert(err, IsNil)
+		chg := s.state.NewChange("test", "...")
+		chg.AddAll(ts)
+		s.state.Unlock()
+
+		s.settle(c)
+
+		s.state.Lock()
+		defer s.state.Unlock()
+		c.Assert(chg.Err(), IsNil)
+	}
+
+	// nothing was set up yet, so all the profiles are
+	run(ifacestate.Connect)
+	c.Check(setupCallSnaps(aaBackend), DeepEquals, []string{"producer", "consumer"})
+	c.Check(setupCallSnaps(udevBackend), DeepEquals, []string{"producer", "consumer"})
+
+	// then only the changed ones
+	run(ifacestate.Disconnect)
+	c.Check(setupCallSnaps(aaBackend), HasLen, 0)
+	c.Check(setupCallSnaps(udevBackend), DeepEquals, []string{"consumer"})
+
+	run(ifacestate.Connect)
+	c.Check(setupCallSnaps(aaBackend), HasLen, 0)
+	c.Check(setupCallSnaps(udevBackend), DeepEquals, []string{"consumer"})
+}
+
+func (s *interfaceManagerSuite) TestDisconnectFull(c *C) {
+	s.testDisconnect(c, "consumer", "plug", "producer", "slot")
+}
+
+func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSnap, slotName string) {
+	// Put two snaps in place They consumer has an plug that can be connected
+	// to slot on the producer.
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	// Put a connection in the state so that it automatically gets set up when
+	// we create the manager.
 	s.state.Lock()
 	s.state.Set("conns", map[string]interface{}{
 		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
@@ -510,24 +1077,24 @@ func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSna
 	s.state.Lock()
 	change := s.state.NewChange("disconnect", "...")
 	ts, err := ifacestate.Disconnect(s.state, plugSnap, plugName, slotSnap, slotName)
-	ts.Tasks()[0].Set("snap-setup", &snapstate.SnapSetup{
+	c.Assert(err, IsNil)
+	ts.Tasks()[2].Set("snap-setup", &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: "consumer",
 		},
 	})
 
-	c.Assert(err, IsNil)
 	change.AddAll(ts)
 	s.state.Unlock()
-	mgr.Ensure()
-	mgr.Wait()
+
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	// Ensure that the task succeeded.
 	c.Assert(change.Err(), IsNil)
-	task := change.Tasks()[0]
+	task := change.Tasks()[2]
 	c.Check(task.Kind(), Equals, "disconnect")
 	c.Check(task.Status(), Equals, state.DoneStatus)
 
@@ -554,6 +1121,16 @@ func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSna
 
 	c.Check(s.secBackend.SetupCalls[0].Options, Equals, interfaces.ConfinementOptions{})
 	c.Check(s.secBackend.SetupCalls[1].Options, Equals, interfaces.ConfinementOptions{})
+
+	// Ensure that the disconnection was recorded
+	events, err := ifacestate.ConnectionHistory(s.state, "producer")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Action, Equals, "disconnect")
+	c.Check(events[0].By, Equals, ifacestate.ByUser)
+	c.Check(events[0].Plug, Equals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+	c.Check(events[0].Slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
+	c.Check(events[0].Interface, Equals, "test")
 }
 
 func (s *interfaceManagerSuite) mockIface(c *C, iface interfaces.Interface) {
@@ -617,6 +1194,7 @@ func (s *interfaceManagerSuite) mockSnap(c *C, yamlText string) *snap.Info {
 		Active:   true,
 		Sequence: []*snap.SideInfo{sideInfo},
 		Current:  sideInfo.Revision,
+		SnapType: string(snapInfo.Type),
 	})
 	return snapInfo
 }
@@ -676,6 +1254,7 @@ func (s *interfaceManagerSuite) addDiscardConnsChange(c *C, snapName string) *st
 	snapsup := snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapName,
+			SnapID:   snapName + "-id",
 		},
 	}
 	task.Set("snap-setup", snapsup)
@@ -769,6 +1348,7 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityHonorsDisconnect(c *C) {
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -810,6 +1390,7 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsPlugs(c *C) {
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -838,6 +1419,14 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsPlugs(c *C) {
 	plug := repo.Plug("snap", "network")
 	c.Assert(plug, Not(IsNil))
 	c.Check(plug.Connections, HasLen, 1)
+
+	// Ensure that the auto-connection was recorded.
+	events, err := ifacestate.ConnectionHistory(s.state, "snap")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Action, Equals, "connect")
+	c.Check(events[0].By, Equals, ifacestate.ByAutoConnect)
+	c.Check(events[0].Slot, Equals, interfaces.SlotRef{Snap: "ubuntu-core", Name: "network"})
 }
 
 // The setup-profiles task will auto-connect slots with viable candidates.
@@ -859,6 +1448,7 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsSlots(c *C) {
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -910,6 +1500,7 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsSlotsMultiple
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -965,6 +1556,7 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityNoAutoConnectSlotsIfAlter
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -985,6 +1577,247 @@ func (s *interfaceManagerSuite) TestDoSetupSnapSecurityNoAutoConnectSlotsIfAlter
 	c.Check(conns, HasLen, 0)
 }
 
+// Greedy plugs are auto-connected to all the candidate slots, be it when
+// the snap with the plug or one with the slots is installed.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsGreedyPlugs(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName:       "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{GreedyPlugs: true},
+	})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, producer2Yaml)
+	mgr := s.manager(c)
+
+	snapInfo := s.mockSnap(c, consumerYaml)
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot":  map[string]interface{}{"interface": "test", "auto": true},
+		"consumer:plug producer2:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+}
+
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsSlotsGreedyPlugs(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName:       "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{GreedyPlugs: true},
+	})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producer2Yaml)
+	mgr := s.manager(c)
+
+	// the alternative slot doesn't prevent the connection
+	snapInfo := s.mockSnap(c, producerYaml)
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+}
+
+func (s *interfaceManagerSuite) setAdminAutoConnections(c *C, value interface{}) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "interfaces.auto-connect", value), IsNil)
+	tr.Commit()
+}
+
+func (s *interfaceManagerSuite) runSetupSnapSecurity(c *C, mgr *ifacestate.InterfaceManager, snapInfo *snap.Info) map[string]interface{} {
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
+			Revision: snapInfo.Revision,
+		},
+	})
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	return conns
+}
+
+// The setup-profiles task will make the connections declared by the
+// administrator, even when there are alternative slots.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAdminAutoConnectsPlugs(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, producer2Yaml)
+	s.setAdminAutoConnections(c, []map[string]string{
+		{"plug": "consumer:plug", "slot": "producer2:slot"},
+	})
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer2:slot": map[string]interface{}{
+			"interface": "test", "auto": true,
+		},
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	events, err := ifacestate.ConnectionHistory(s.state, "consumer")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].By, Equals, ifacestate.ByAdministrator)
+	c.Check(events[0].Slot, Equals, interfaces.SlotRef{Snap: "producer2", Name: "slot"})
+}
+
+// The connections declared by the administrator are also made when the
+// snap with the slot is the one being installed, and take precedence
+// over the declaration-based ones.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAdminAutoConnectsSlots(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.setAdminAutoConnections(c, []map[string]string{
+		{"plug": "consumer:plug", "slot": "producer"},
+		{"plug": "consumer2:plug", "slot": "producer2:slot"},
+	})
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, producerYaml)
+
+	// consumer2:plug is left for producer2 to come
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "auto": true,
+		},
+	})
+}
+
+// A broken interfaces.auto-connect option doesn't get in the way of the
+// declaration-based auto-connections.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAdminAutoConnectsInvalid(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.setAdminAutoConnections(c, "consumer:plug producer:slot")
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "auto": true,
+		},
+	})
+}
+
+// A connection declared by the administrator whose slot is missing is
+// not made, nor replaced by a declaration-based one.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAdminAutoConnectsMissingSlot(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.setAdminAutoConnections(c, []map[string]string{
+		{"plug": "consumer:plug", "slot": "producer:other-slot"},
+	})
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) mockSitePolicy(c *C, policy string) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapInterfacesPolicyFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapInterfacesPolicyFile, []byte(policy), 0644), IsNil)
+}
+
+func (s *interfaceManagerSuite) taskLog() []string {
+	s.state.Lock()
+	defer s.state.Unlock()
+	var log []string
+	for _, chg := range s.state.Changes() {
+		for _, t := range chg.Tasks() {
+			log = append(log, t.Log()...)
+		}
+	}
+	return log
+}
+
+// The interfaces the site policy makes manual are not auto-connected,
+// and the task log says so.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecuritySitePolicyManual(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSitePolicy(c, "manual: [test]\n")
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, HasLen, 0)
+	c.Check(strings.Join(s.taskLog(), "\n"), Matches, `(?s).*cannot auto connect consumer:plug to producer:slot: interface "test" is only connected manually by the site policy.*`)
+}
+
+// The interfaces the site policy forbids are not auto-connected even
+// when the administrator declared the connection.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecuritySitePolicyForbid(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.setAdminAutoConnections(c, []map[string]string{
+		{"plug": "consumer:plug", "slot": "producer:slot"},
+	})
+	s.mockSitePolicy(c, "forbid: [test]\n")
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, HasLen, 0)
+	c.Check(strings.Join(s.taskLog(), "\n"), Matches, `(?s).*cannot auto connect consumer:plug to producer:slot: interface "test" is forbidden by the site policy \(administrator auto-connection\).*`)
+}
+
+// Nothing is auto-connected while the site policy is invalid, as it
+// might be forbidding the connections, and the task log says why.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecuritySitePolicyInvalid(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSitePolicy(c, "manual: [test]\nforbid: [test]\n")
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, HasLen, 0)
+	c.Check(strings.Join(s.taskLog(), "\n"), Matches, `(?s).*cannot auto connect consumer:plug to producer:slot: cannot use the site interface policy: invalid .*/interfaces-policy.yaml: interface "test" is in both forbid and manual.*`)
+}
+
+// The interfaces the site policy auto-connects are auto-connected even
+// when the declarations only allow their connection.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecuritySitePolicyAutoConnect(c *C) {
+	s.mockSitePolicy(c, "auto-connect: [test]\n")
+	s.testDoSetupSnapSecurityAutoConnectsDeclBased(c, false, func(conns map[string]interface{}, plug *interfaces.Plug) {
+		c.Check(conns, DeepEquals, map[string]interface{}{
+			"consumer:plug producer:slot": map[string]interface{}{"auto": true, "interface": "test"},
+		})
+		c.Check(plug.Connections, HasLen, 1)
+	})
+	c.Check(strings.Join(s.taskLog(), "\n"), Matches, `(?s).*auto connect consumer:plug to producer:slot: interface "test" is auto-connected by the site policy.*`)
+}
+
 // The setup-profiles task will auto-connect plugs with viable candidates also condidering snap declarations.
 func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsDeclBased(c *C) {
 	s.testDoSetupSnapSecurityAutoConnectsDeclBased(c, true, func(conns map[string]interface{}, plug *interfaces.Plug) {
@@ -1061,54 +1894,240 @@ slots:
 	check(conns, plug)
 }
 
-// The setup-profiles task will only touch connection state for the task it
-// operates on or auto-connects to and will leave other state intact.
-func (s *interfaceManagerSuite) TestDoSetupSnapSecuirtyKeepsExistingConnectionState(c *C) {
-	// Add an OS snap in place.
-	s.mockSnap(c, ubuntuCoreSnapYaml)
+func (s *interfaceManagerSuite) TestAutoConnectTask(c *C) {
+	s.mockSnap(c, consumerYaml)
 
-	// Initialize the manager. This registers the two snaps.
-	mgr := s.manager(c)
+	s.state.Lock()
+	defer s.state.Unlock()
 
-	// Add a sample snap with a "network" plug which should be auto-connected.
-	snapInfo := s.mockSnap(c, sampleSnapYaml)
+	ts, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	task := ts.Tasks()[0]
+	c.Check(task.Kind(), Equals, "auto-connect")
+	c.Check(task.Summary(), Equals, `Auto-connect plugs and slots of snap "consumer"`)
+	snapsup, err := snapstate.TaskSnapSetup(task)
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Name(), Equals, "consumer")
 
-	// Put fake information about connections for another snap into the state.
-	s.state.Lock()
-	s.state.Set("conns", map[string]interface{}{
-		"other-snap:network ubuntu-core:network": map[string]interface{}{
-			"interface": "network",
-		},
-	})
-	s.state.Unlock()
+	_, err = ifacestate.AutoConnect(s.state, "unknown")
+	c.Check(err, ErrorMatches, `snap "unknown" is not installed`)
+}
 
-	// Run the setup-snap-security task and let it finish.
-	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
-		SideInfo: &snap.SideInfo{
-			RealName: snapInfo.Name(),
-			Revision: snapInfo.Revision,
-		},
-	})
-	mgr.Ensure()
-	mgr.Wait()
-	mgr.Stop()
+func (s *interfaceManagerSuite) TestAutoConnectConflict(c *C) {
+	s.mockSnap(c, consumerYaml)
 
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	// Ensure that the task succeeded.
-	c.Assert(change.Status(), Equals, state.DoneStatus)
+	chg := s.state.NewChange("other", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "consumer"}})
+	chg.AddTask(t)
 
-	var conns map[string]interface{}
-	err := s.state.Get("conns", &conns)
-	c.Assert(err, IsNil)
-	c.Check(conns, DeepEquals, map[string]interface{}{
-		// The sample snap was auto-connected, as expected.
-		"snap:network ubuntu-core:network": map[string]interface{}{
-			"interface": "network", "auto": true,
-		},
-		// Connection state for the fake snap is preserved.
-		// The task didn't alter state of other snaps.
+	_, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Check(err, ErrorMatches, `snap "consumer" has changes in progress`)
+}
+
+// mockAutoConnectable sets up a consumer and a producer whose
+// connection the policy allows to auto-connect, but that are not
+// connected yet.
+func (s *interfaceManagerSuite) mockAutoConnectable(c *C) (restore func()) {
+	restore = assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-auto-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+`))
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+	return restore
+}
+
+// The auto-connect task connects what the policy allows now, e.g. after
+// the snap declaration of the consumer appeared.
+func (s *interfaceManagerSuite) TestAutoConnectConnectsNewlyAllowed(c *C) {
+	restore := s.mockAutoConnectable(c)
+	defer restore()
+
+	// the snaps are known but nothing is connected yet
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("auto-connect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	c.Assert(change.Status(), Equals, state.DoneStatus, Commentf("%v", change.Err()))
+
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"auto": true, "interface": "test"},
+	})
+	plug := mgr.Repository().Plug("consumer", "plug")
+	c.Assert(plug, NotNil)
+	c.Check(plug.Connections, HasLen, 1)
+
+	var data map[string]interface{}
+	c.Assert(change.Get("api-data", &data), IsNil)
+	c.Check(data["connected"], DeepEquals, []interface{}{
+		map[string]interface{}{
+			"plug": map[string]interface{}{"snap": "consumer", "plug": "plug"},
+			"slot": map[string]interface{}{"snap": "producer", "slot": "slot"},
+		},
+	})
+
+	// running it again connects nothing new
+	ts, err = ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	change = s.state.NewChange("auto-connect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+	c.Assert(change.Get("api-data", &data), IsNil)
+	c.Check(data["connected"], DeepEquals, []interface{}{})
+}
+
+func (s *interfaceManagerSuite) checkAutoConnectUndone(c *C, mgr *ifacestate.InterfaceManager) {
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+	c.Check(mgr.Repository().Plug("consumer", "plug").Connections, HasLen, 0)
+
+	// the profiles were set up again without the connection
+	c.Assert(s.secBackend.SetupCalls, Not(HasLen), 0)
+	last := s.secBackend.SetupCalls[len(s.secBackend.SetupCalls)-1]
+	c.Check(last.SnapInfo.Name(), Equals, "producer")
+}
+
+// The connections made by the auto-connect task are broken when another
+// task of its change fails.
+func (s *interfaceManagerSuite) TestAutoConnectUndo(c *C) {
+	restore := s.mockAutoConnectable(c)
+	defer restore()
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("auto-connect", "...")
+	change.AddAll(ts)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Status(), Equals, state.ErrorStatus)
+	c.Check(ts.Tasks()[0].Status(), Equals, state.UndoneStatus)
+	s.checkAutoConnectUndone(c, mgr)
+}
+
+// The connections made by the auto-connect task are broken when setting
+// up the profiles for them fails.
+func (s *interfaceManagerSuite) TestAutoConnectSetupFailureDisconnects(c *C) {
+	restore := s.mockAutoConnectable(c)
+	defer restore()
+	mgr := s.manager(c)
+
+	s.secBackend.SetupCallback = func(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) error {
+		if snapInfo.Name() == "producer" && len(repo.Slot("producer", "slot").Connections) != 0 {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	}
+
+	s.state.Lock()
+	ts, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("auto-connect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*boom.*`)
+	s.checkAutoConnectUndone(c, mgr)
+}
+
+// The setup-profiles task will only touch connection state for the task it
+// operates on or auto-connects to and will leave other state intact.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecuirtyKeepsExistingConnectionState(c *C) {
+	// Add an OS snap in place.
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+
+	// Initialize the manager. This registers the two snaps.
+	mgr := s.manager(c)
+
+	// Add a sample snap with a "network" plug which should be auto-connected.
+	snapInfo := s.mockSnap(c, sampleSnapYaml)
+
+	// Put fake information about connections for another snap into the state.
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"other-snap:network ubuntu-core:network": map[string]interface{}{
+			"interface": "network",
+		},
+	})
+	s.state.Unlock()
+
+	// Run the setup-snap-security task and let it finish.
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
+			Revision: snapInfo.Revision,
+		},
+	})
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// Ensure that the task succeeded.
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		// The sample snap was auto-connected, as expected.
+		"snap:network ubuntu-core:network": map[string]interface{}{
+			"interface": "network", "auto": true,
+		},
+		// Connection state for the fake snap is preserved.
+		// The task didn't alter state of other snaps.
 		"other-snap:network ubuntu-core:network": map[string]interface{}{
 			"interface": "network",
 		},
@@ -1127,6 +2146,7 @@ func (s *interfaceManagerSuite) TestDoSetupProfilesAddsImplicitSlots(c *C) {
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -1308,6 +2328,7 @@ func (s *interfaceManagerSuite) TestAutoConnectSetupSecurityForConnectedSlots(c
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -1384,6 +2405,14 @@ func (s *interfaceManagerSuite) testDoDicardConns(c *C, snapName string) {
 	c.Check(removed, DeepEquals, map[string]interface{}{
 		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
 	})
+
+	// And the removal of the connection was recorded.
+	events, err := ifacestate.ConnectionHistory(s.state, snapName)
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Action, Equals, "disconnect")
+	c.Check(events[0].By, Equals, ifacestate.ByRemoval)
+	c.Check(events[0].Interface, Equals, "test")
 }
 
 func (s *interfaceManagerSuite) testUndoDicardConns(c *C, snapName string) {
@@ -1520,6 +2549,131 @@ func (s *interfaceManagerSuite) TestConnectTracksConnectionsInState(c *C) {
 	})
 }
 
+func (s *interfaceManagerSuite) TestConnectWithAttrsTracksAttrsInState(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{
+			ConnectionAttrs: interfaces.AttrSchema{{Name: "path", Type: interfaces.AttrString}},
+		},
+	})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.ConnectWithAttrs(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"path": "/dev/foo"})
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 5)
+
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+			"attrs":     map[string]interface{}{"path": "/dev/foo"},
+		},
+	})
+	c.Check(mgr.Repository().Plug("consumer", "plug").Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestConnectWithAttrsUnsupported(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	_ = s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.ConnectWithAttrs(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"path": "/dev/foo"})
+	c.Assert(err, IsNil)
+
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Err(), ErrorMatches, `(?s).*\(test connections do not support attributes\)`)
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Check(err, Equals, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestConnectLogsDeprecatedInterface(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName:       "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{Deprecated: true, ReplacedBy: "test2"},
+	})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	_ = s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	var found bool
+	for _, t := range change.Tasks() {
+		if t.Kind() != "connect" {
+			continue
+		}
+		found = true
+		c.Assert(t.Log(), HasLen, 1)
+		c.Check(t.Log()[0], Matches, `.* interface "test" is deprecated, use "test2" instead`)
+	}
+	c.Check(found, Equals, true)
+}
+
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityLogsDeprecatedInterfaces(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{
+		InterfaceName:       "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{Deprecated: true},
+	})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	mgr := s.manager(c)
+
+	snapInfo := s.mockSnap(c, consumerYaml)
+	s.runSetupSnapSecurity(c, mgr, snapInfo)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var logs []string
+	for _, t := range s.state.Tasks() {
+		logs = append(logs, t.Log()...)
+	}
+	// the consumer snap also has a plug of an unknown interface
+	c.Assert(logs, HasLen, 2)
+	c.Check(logs[1], Matches, `.* interface "test" is deprecated`)
+}
+
 func (s *interfaceManagerSuite) TestConnectSetsUpSecurity(c *C) {
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
@@ -1568,12 +2722,12 @@ func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	})
 	s.state.Unlock()
 
-	mgr := s.manager(c)
+	s.manager(c)
 
 	s.state.Lock()
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
 	c.Assert(err, IsNil)
-	ts.Tasks()[0].Set("snap-setup", &snapstate.SnapSetup{
+	ts.Tasks()[2].Set("snap-setup", &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: "consumer",
 		},
@@ -1583,9 +2737,7 @@ func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
-	mgr.Stop()
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -1612,12 +2764,12 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	})
 	s.state.Unlock()
 
-	mgr := s.manager(c)
+	s.manager(c)
 
 	s.state.Lock()
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
 	c.Assert(err, IsNil)
-	ts.Tasks()[0].Set("snap-setup", &snapstate.SnapSetup{
+	ts.Tasks()[2].Set("snap-setup", &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: "consumer",
 		},
@@ -1627,9 +2779,7 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
-	mgr.Stop()
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -2044,6 +3194,7 @@ func (s *interfaceManagerSuite) TestAutoConnectDuringCoreTransition(c *C) {
 	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
 		SideInfo: &snap.SideInfo{
 			RealName: snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 		},
 	})
@@ -2075,3 +3226,685 @@ func (s *interfaceManagerSuite) TestAutoConnectDuringCoreTransition(c *C) {
 	c.Assert(plug, Not(IsNil))
 	c.Check(plug.Connections, HasLen, 1)
 }
+
+func (s *interfaceManagerSuite) setRemovalGracePeriod(c *C, value string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "interfaces.removal-grace-period", value), IsNil)
+	tr.Commit()
+}
+
+func (s *interfaceManagerSuite) TestDoDiscardConnsKeepsConnsDuringGracePeriod(c *C) {
+	s.setRemovalGracePeriod(c, "24h")
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":   map[string]interface{}{"interface": "test", "attrs": map[string]interface{}{"path": "/dev/foo"}},
+		"other:plug producer2:slot":     map[string]interface{}{"interface": "test"},
+		"consumer:otherplug core:slot2": map[string]interface{}{"interface": "test2", "auto": true},
+	})
+	s.state.Set("undesired-conns", map[string]bool{
+		"consumer:plug producer2:slot": true,
+		"other:plug producer:slot":     true,
+	})
+	snapstate.Set(s.state, "consumer", &snapstate.SnapState{})
+	snapstate.Set(s.state, "producer", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "producer", SnapID: "producer-id", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	change := s.addDiscardConnsChange(c, "consumer")
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"other:plug producer2:slot": map[string]interface{}{"interface": "test"},
+	})
+	var undesired map[string]bool
+	c.Assert(s.state.Get("undesired-conns", &undesired), IsNil)
+	c.Check(undesired, DeepEquals, map[string]bool{"other:plug producer:slot": true})
+
+	// the connections of the removed snap are kept for a while
+	var removed map[string]map[string]interface{}
+	c.Assert(s.state.Get("removed-conns", &removed), IsNil)
+	c.Assert(removed, HasLen, 3)
+	for id, conn := range removed {
+		c.Check(conn["removed"], NotNil, Commentf(id))
+		delete(conn, "removed")
+	}
+	c.Check(removed, DeepEquals, map[string]map[string]interface{}{
+		"consumer:plug producer:slot": {
+			"interface": "test", "attrs": map[string]interface{}{"path": "/dev/foo"},
+			"plug-snap-id": "consumer-id", "slot-snap-id": "producer-id",
+		},
+		"consumer:otherplug core:slot2": {"interface": "test2", "auto": true, "plug-snap-id": "consumer-id"},
+		"consumer:plug producer2:slot":  {"undesired": true},
+	})
+}
+
+func (s *interfaceManagerSuite) TestUndoDiscardConnsDuringGracePeriod(c *C) {
+	s.setRemovalGracePeriod(c, "24h")
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Set("undesired-conns", map[string]bool{"consumer:plug producer2:slot": true})
+	snapstate.Set(s.state, "consumer", &snapstate.SnapState{})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	change := s.addDiscardConnsChange(c, "consumer")
+	s.state.Lock()
+	change.AddTask(s.state.NewTask("dummy", ""))
+	s.state.Unlock()
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	change.Abort()
+	s.state.Unlock()
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Status(), Equals, state.UndoneStatus)
+
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	var undesired map[string]bool
+	c.Assert(s.state.Get("undesired-conns", &undesired), IsNil)
+	c.Check(undesired, DeepEquals, map[string]bool{"consumer:plug producer2:slot": true})
+	var removed map[string]interface{}
+	c.Check(s.state.Get("removed-conns", &removed), Equals, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestDoDiscardConnsWithoutGracePeriod(c *C) {
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Set("undesired-conns", map[string]bool{"consumer:plug producer2:slot": true})
+	snapstate.Set(s.state, "consumer", &snapstate.SnapState{})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	change := s.addDiscardConnsChange(c, "consumer")
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	// nothing is kept about the removed snap
+	var v interface{}
+	c.Check(s.state.Get("removed-conns", &v), Equals, state.ErrNoState)
+	c.Check(s.state.Get("undesired-conns", &v), Equals, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityRestoresRemovedConns(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.setRemovalGracePeriod(c, "24h")
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, producer2Yaml)
+
+	now := time.Now()
+	s.state.Lock()
+	s.state.Set("removed-conns", map[string]interface{}{
+		// restored with its attributes
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "attrs": map[string]interface{}{"path": "/dev/foo"}, "removed": now.Add(-time.Hour),
+		},
+		// stays disconnected
+		"consumer:plug producer2:slot": map[string]interface{}{"undesired": true, "removed": now.Add(-time.Hour)},
+		// producer3 is not installed (yet)
+		"consumer:otherplug producer3:slot": map[string]interface{}{"interface": "test2", "removed": now.Add(-time.Hour)},
+		// past the grace period
+		"other:plug producer:slot": map[string]interface{}{"interface": "test", "removed": now.Add(-48 * time.Hour)},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "attrs": map[string]interface{}{"path": "/dev/foo"},
+		},
+	})
+	repo := mgr.Repository()
+	c.Check(repo.Plug("consumer", "plug").Connections, DeepEquals, []interfaces.SlotRef{{Snap: "producer", Name: "slot"}})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var undesired map[string]bool
+	c.Assert(s.state.Get("undesired-conns", &undesired), IsNil)
+	c.Check(undesired, DeepEquals, map[string]bool{"consumer:plug producer2:slot": true})
+	var removed map[string]interface{}
+	c.Assert(s.state.Get("removed-conns", &removed), IsNil)
+	c.Check(removed, HasLen, 1)
+	c.Check(removed["consumer:otherplug producer3:slot"], NotNil)
+
+	events, err := ifacestate.ConnectionHistory(s.state, "consumer")
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Action, Equals, "connect")
+	c.Check(events[0].By, Equals, ifacestate.ByReinstall)
+	c.Check(events[0].Slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
+}
+
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityDropsRemovedConnsOfOtherSnaps(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.setRemovalGracePeriod(c, "24h")
+	s.mockSnapDecl(c, "producer", "producer-publisher", nil)
+	producer := s.mockSnap(c, producerYaml)
+
+	now := time.Now()
+	s.state.Lock()
+	s.state.Set("removed-conns", map[string]interface{}{
+		// consumer was installed from the store before
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "attrs": map[string]interface{}{"path": "/dev/foo"},
+			"plug-snap-id": "consumeridididididididididididid", "slot-snap-id": producer.SnapID, "removed": now.Add(-time.Hour),
+		},
+		// the plug was over another interface
+		"consumer:otherplug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-snap-id": producer.SnapID, "removed": now.Add(-time.Hour),
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	// another snap is installed under the same name
+	snapInfo := s.mockSnap(c, consumerYaml)
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	// it is auto-connected afresh rather than restored
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var removed map[string]interface{}
+	c.Check(s.state.Get("removed-conns", &removed), Equals, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityDropsRemovedConnsNotAllowed(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+`))
+	defer restore()
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.setRemovalGracePeriod(c, "24h")
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnapDecl(c, "producer2", "other-publisher", nil)
+	producer := s.mockSnap(c, producerYaml)
+	producer2 := s.mockSnap(c, producer2Yaml)
+	consumerID := "consumeridididididididididididid"
+
+	now := time.Now()
+	s.state.Lock()
+	s.state.Set("removed-conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "plug-snap-id": consumerID, "slot-snap-id": producer.SnapID, "removed": now.Add(-time.Hour),
+		},
+		// the declarations do not allow it (anymore)
+		"consumer:plug producer2:slot": map[string]interface{}{
+			"interface": "test", "plug-snap-id": consumerID, "slot-snap-id": producer2.SnapID, "removed": now.Add(-time.Hour),
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+	c.Assert(snapInfo.SnapID, Equals, consumerID)
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var removed map[string]interface{}
+	c.Check(s.state.Get("removed-conns", &removed), Equals, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestDoSetupSnapSecuritySkipsUndesiredAutoConnections(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, ubuntuCoreSnapYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("undesired-conns", map[string]bool{"consumer:plug producer:slot": true})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	snapInfo := s.mockSnap(c, consumerYaml)
+	// the user disconnected it before
+	conns := s.runSetupSnapSecurity(c, mgr, snapInfo)
+	c.Check(conns, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestDisconnectAutoConnectionMakesItUndesired(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("disconnect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+	var undesired map[string]bool
+	c.Assert(s.state.Get("undesired-conns", &undesired), IsNil)
+	c.Check(undesired, DeepEquals, map[string]bool{"consumer:plug producer:slot": true})
+
+	// connecting it manually makes it desired again
+	ts, err = ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change = s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+	c.Check(s.state.Get("undesired-conns", &undesired), Equals, state.ErrNoState)
+}
+
+func (s *interfaceManagerSuite) TestForgetConnections(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("removed-conns", map[string]interface{}{
+		"consumer:plug producer:slot":  map[string]interface{}{"interface": "test", "removed": time.Now()},
+		"consumer:plug producer2:slot": map[string]interface{}{"undesired": true, "removed": time.Now()},
+		"other:plug producer2:slot":    map[string]interface{}{"interface": "test", "removed": time.Now()},
+	})
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "interfaces.removal-grace-period", "24h"), IsNil)
+	tr.Commit()
+
+	n, err := ifacestate.ForgetConnections(s.state, "consumer")
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+	var removed map[string]interface{}
+	c.Assert(s.state.Get("removed-conns", &removed), IsNil)
+	c.Check(removed, HasLen, 1)
+	c.Check(removed["other:plug producer2:slot"], NotNil)
+
+	n, err = ifacestate.ForgetConnections(s.state, "consumer")
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 0)
+}
+
+func (s *interfaceManagerSuite) mockModel(c *C, model string) {
+	a, err := s.storeSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"brand-id":     "canonical",
+		"model":        model,
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(s.db.Add(a), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(auth.SetDevice(s.state, &auth.DeviceState{Brand: "canonical", Model: model}), IsNil)
+}
+
+var onModelBaseDeclaration = []byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-auto-connection:
+      on-model:
+        - canonical/my-model
+`)
+
+func (s *interfaceManagerSuite) TestAutoConnectOnModel(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration(onModelBaseDeclaration)
+	defer restore()
+	s.mockModel(c, "my-model")
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("auto-connect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Status(), Equals, state.DoneStatus, Commentf("%v", change.Err()))
+	plug := mgr.Repository().Plug("consumer", "plug")
+	c.Assert(plug, NotNil)
+	c.Check(plug.Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestAutoConnectOtherModel(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration(onModelBaseDeclaration)
+	defer restore()
+	s.mockModel(c, "other-model")
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.AutoConnect(s.state, "consumer")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("auto-connect", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Status(), Equals, state.DoneStatus, Commentf("%v", change.Err()))
+	plug := mgr.Repository().Plug("consumer", "plug")
+	c.Assert(plug, NotNil)
+	c.Check(plug.Connections, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestRemovalImpact(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, `name: provider
+version: 1
+slots:
+ data:
+  interface: content
+  read: [$SNAP/data]
+ tst:
+  interface: test
+`)
+	s.mockSnap(c, `name: app-one
+version: 1
+base: provider
+plugs:
+ data:
+  interface: content
+  target: $SNAP/data
+  default-provider: provider:data
+`)
+	s.mockSnap(c, `name: app-two
+version: 1
+plugs:
+ tst:
+  interface: test
+`)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"app-one:data provider:data": map[string]interface{}{"interface": "content"},
+		"app-two:tst provider:tst":   map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ri, err := mgr.RemovalImpact("provider")
+	c.Assert(err, IsNil)
+	c.Check(ri, DeepEquals, &ifacestate.RemovalImpact{
+		Snap:              "provider",
+		BaseOf:            []string{"app-one"},
+		DefaultProviderOf: []string{"app-one"},
+		ContentConsumers:  []string{"app-one"},
+		Connections: []ifacestate.BrokenConnection{{
+			Plug:      interfaces.PlugRef{Snap: "app-one", Name: "data"},
+			Slot:      interfaces.SlotRef{Snap: "provider", Name: "data"},
+			Interface: "content",
+		}, {
+			Plug:      interfaces.PlugRef{Snap: "app-two", Name: "tst"},
+			Slot:      interfaces.SlotRef{Snap: "provider", Name: "tst"},
+			Interface: "test",
+		}},
+	})
+	c.Check(ri.Dependents(), DeepEquals, []string{"app-one", "app-two"})
+
+	// nothing depends on the consumers
+	ri, err = mgr.RemovalImpact("app-two")
+	c.Assert(err, IsNil)
+	c.Check(ri, DeepEquals, &ifacestate.RemovalImpact{Snap: "app-two"})
+	c.Check(ri.Dependents(), HasLen, 0)
+
+	_, err = mgr.RemovalImpact("unknown")
+	c.Check(err, Equals, state.ErrNoState)
+
+	err = &ifacestate.SnapHasDependentsError{Snap: "provider", Dependents: []string{"app-one", "app-two"}}
+	c.Check(err, ErrorMatches, `snap "provider" is used by "app-one", "app-two"`)
+}
+
+func (s *interfaceManagerSuite) TestExplainConnectivity(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration(onModelBaseDeclaration)
+	defer restore()
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+
+	mgr := s.manager(c)
+
+	// without a model the slot is not a candidate
+	s.state.Lock()
+	pc, err := mgr.ExplainConnectivity("consumer", "plug")
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(pc, DeepEquals, &ifacestate.PlugConnectivity{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Interface: "test",
+		Verdict:   "no slot is a candidate for auto-connection",
+		Slots: []*ifacestate.SlotConnectivity{{
+			Slot:   interfaces.SlotRef{Snap: "producer", Name: "slot"},
+			Reason: `auto-connection not allowed by slot rule of interface "test"`,
+		}},
+	})
+
+	s.mockModel(c, "my-model")
+
+	s.state.Lock()
+	pc, err = mgr.ExplainConnectivity("consumer", "plug")
+	c.Assert(err, IsNil)
+	c.Check(pc.Verdict, Equals, "auto-connects to producer:slot")
+	c.Check(pc.Slots, DeepEquals, []*ifacestate.SlotConnectivity{{
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Candidate: true,
+	}})
+
+	// the administrator has the last word
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "interfaces.auto-connect", []map[string]string{{"plug": "consumer:plug", "slot": "producer:slot"}}), IsNil)
+	tr.Commit()
+	pc, err = mgr.ExplainConnectivity("consumer", "plug")
+	c.Assert(err, IsNil)
+	c.Check(pc.Verdict, Equals, "the administrator declared its connection to producer:slot with interfaces.auto-connect")
+
+	_, err = mgr.ExplainConnectivity("consumer", "unknown")
+	c.Check(err, ErrorMatches, `snap "consumer" has no "unknown" plug`)
+	s.state.Unlock()
+}
+
+var autoConnectBaseDeclaration = []byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-auto-connection: true
+`)
+
+func (s *interfaceManagerSuite) TestSnapDeclarationsChanged(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration(autoConnectBaseDeclaration)
+	defer restore()
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	producerID := ("producer" + strings.Repeat("id", 16))[:32]
+	oldDecl, err := assertstate.SnapDeclaration(s.state, producerID)
+	c.Assert(err, IsNil)
+
+	// the same declaration changes nothing
+	ifacestate.SnapDeclarationsChanged(mgr, s.state, map[string]*asserts.SnapDeclaration{"producer": oldDecl})
+	diff, err := mgr.DeclarationDiff("producer")
+	c.Assert(err, IsNil)
+	c.Check(diff, IsNil)
+
+	s.mockSnapDecl(c, "producer", "one-publisher", map[string]interface{}{
+		"format":   "1",
+		"revision": "1",
+		"slots": map[string]interface{}{
+			"test": map[string]interface{}{
+				"deny-connection":      "true",
+				"deny-auto-connection": "true",
+			},
+		},
+	})
+
+	ifacestate.SnapDeclarationsChanged(mgr, s.state, map[string]*asserts.SnapDeclaration{"producer": oldDecl})
+	diff, err = mgr.DeclarationDiff("producer")
+	c.Assert(err, IsNil)
+	c.Check(diff, DeepEquals, &ifacestate.DeclarationDiff{
+		Snap:        "producer",
+		OldRevision: 0,
+		NewRevision: 1,
+		Changes: []*ifacestate.DeclarationConnChange{{
+			Plug:           interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:           interfaces.SlotRef{Snap: "producer", Name: "slot"},
+			Interface:      "test",
+			WasAllowed:     true,
+			WasAutoConnect: true,
+			Connected:      true,
+		}},
+	})
+	c.Check(diff.Changes[0].String(), Equals, "consumer:plug to producer:slot is no longer allowed")
+	// only a warning by default
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestSnapDeclarationsChangedDisconnects(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration(autoConnectBaseDeclaration)
+	defer restore()
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "interfaces.disconnect-disallowed", true), IsNil)
+	tr.Commit()
+
+	producerID := ("producer" + strings.Repeat("id", 16))[:32]
+	oldDecl, err := assertstate.SnapDeclaration(s.state, producerID)
+	c.Assert(err, IsNil)
+	s.mockSnapDecl(c, "producer", "one-publisher", map[string]interface{}{
+		"format":   "1",
+		"revision": "1",
+		"slots": map[string]interface{}{
+			"test": map[string]interface{}{
+				"deny-connection": "true",
+			},
+		},
+	})
+
+	ifacestate.SnapDeclarationsChanged(mgr, s.state, map[string]*asserts.SnapDeclaration{"producer": oldDecl})
+	diff, err := mgr.DeclarationDiff("producer")
+	c.Assert(err, IsNil)
+	c.Assert(diff.Changes, HasLen, 1)
+	c.Check(diff.Changes[0].Disconnected, Equals, true)
+
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "disconnect")
+	c.Check(chg.Summary(), Equals, "Disconnect connections no longer allowed by snap declarations")
+	var kinds []string
+	for _, t := range chg.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{"run-hook", "run-hook", "disconnect", "update-profiles", "update-profiles"})
+}
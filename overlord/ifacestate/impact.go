@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// BrokenConnection is a connection that removing a snap would break.
+type BrokenConnection struct {
+	Plug      interfaces.PlugRef `json:"plug"`
+	Slot      interfaces.SlotRef `json:"slot"`
+	Interface string             `json:"interface"`
+}
+
+// RemovalImpact tells which installed snaps depend on a snap, and so
+// would break if it were removed.
+type RemovalImpact struct {
+	Snap string `json:"snap"`
+	// BaseOf lists the snaps using the snap as their base.
+	BaseOf []string `json:"base-of,omitempty"`
+	// DefaultProviderOf lists the snaps with content plugs naming the
+	// snap as their default provider.
+	DefaultProviderOf []string `json:"default-provider-of,omitempty"`
+	// ContentConsumers lists the snaps with plugs connected to the
+	// content slots of the snap.
+	ContentConsumers []string `json:"content-consumers,omitempty"`
+	// Connections lists the plugs of other snaps connected to the slots
+	// of the snap.
+	Connections []BrokenConnection `json:"connections,omitempty"`
+}
+
+// Dependents returns the names of the snaps that would break if the
+// snap were removed, sorted.
+func (ri *RemovalImpact) Dependents() []string {
+	seen := make(map[string]bool)
+	for _, name := range ri.BaseOf {
+		seen[name] = true
+	}
+	for _, name := range ri.DefaultProviderOf {
+		seen[name] = true
+	}
+	for _, name := range ri.ContentConsumers {
+		seen[name] = true
+	}
+	for _, conn := range ri.Connections {
+		seen[conn.Plug.Snap] = true
+	}
+	dependents := make([]string, 0, len(seen))
+	for name := range seen {
+		dependents = append(dependents, name)
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// SnapHasDependentsError is returned when removing a snap would break
+// the installed snaps depending on it.
+type SnapHasDependentsError struct {
+	Snap       string
+	Dependents []string
+}
+
+func (e *SnapHasDependentsError) Error() string {
+	return fmt.Sprintf("snap %q is used by %s", e.Snap, strutil.Quoted(e.Dependents))
+}
+
+// RemovalImpact tells which installed snaps would break if the given
+// snap were removed: those using it as their base, those naming it as
+// the default provider of their content plugs, and those with plugs
+// connected to its slots.
+//
+// The state must be locked by the caller.
+func (m *InterfaceManager) RemovalImpact(snapName string) (*RemovalImpact, error) {
+	st := m.state
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		return nil, err
+	}
+
+	ri := &RemovalImpact{Snap: snapName}
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(snapStates))
+	for name := range snapStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == snapName {
+			continue
+		}
+		info, err := snapStates[name].CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+		if info.Base == snapName {
+			ri.BaseOf = append(ri.BaseOf, name)
+		}
+	}
+
+	for _, plug := range m.repo.AllPlugs("content") {
+		if plug.Snap.Name() == snapName {
+			continue
+		}
+		provider, _ := plug.Attrs["default-provider"].(string)
+		// the default provider is given as <snap> or <snap>:<slot>
+		if i := strings.IndexRune(provider, ':'); i >= 0 {
+			provider = provider[:i]
+		}
+		if provider == snapName && !strutil.ListContains(ri.DefaultProviderOf, plug.Snap.Name()) {
+			ri.DefaultProviderOf = append(ri.DefaultProviderOf, plug.Snap.Name())
+		}
+	}
+	sort.Strings(ri.DefaultProviderOf)
+
+	connStates, err := ConnectionStates(st)
+	if err != nil {
+		return nil, err
+	}
+	for _, cs := range connStates {
+		plugRef, slotRef := cs.Ref.PlugRef, cs.Ref.SlotRef
+		if slotRef.Snap != snapName || plugRef.Snap == snapName {
+			continue
+		}
+		ri.Connections = append(ri.Connections, BrokenConnection{
+			Plug:      plugRef,
+			Slot:      slotRef,
+			Interface: cs.Interface,
+		})
+		if cs.Interface == "content" && !strutil.ListContains(ri.ContentConsumers, plugRef.Snap) {
+			ri.ContentConsumers = append(ri.ContentConsumers, plugRef.Snap)
+		}
+	}
+	sort.Strings(ri.ContentConsumers)
+	return ri, nil
+}
@@ -0,0 +1,336 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// removedConn is a connection of a removed snap, kept for the grace
+// period set by the interfaces.removal-grace-period core option so that
+// it is restored if the snap is installed again in the meantime. The
+// snap ids of both sides are kept so that it is only restored between
+// the same snaps, and not for another snap installed under the same name.
+type removedConn struct {
+	Auto       bool                   `json:"auto,omitempty"`
+	Interface  string                 `json:"interface,omitempty"`
+	Attrs      map[string]interface{} `json:"attrs,omitempty"`
+	PlugSnapID string                 `json:"plug-snap-id,omitempty"`
+	SlotSnapID string                 `json:"slot-snap-id,omitempty"`
+	// Undesired is set for an auto-connection the user disconnected,
+	// which is to stay disconnected once restored.
+	Undesired bool      `json:"undesired,omitempty"`
+	Removed   time.Time `json:"removed"`
+}
+
+// removalGracePeriod returns how long the connections of removed snaps
+// are kept, as set by the interfaces.removal-grace-period core option (a
+// duration such as "72h"). They are not kept by default nor when the
+// option is invalid.
+func removalGracePeriod(st *state.State) time.Duration {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "interfaces.removal-grace-period", &value)
+	if err != nil {
+		if !config.IsNoOption(err) {
+			logger.Noticef("cannot use interfaces.removal-grace-period configuration: %v", err)
+		}
+		return 0
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period < 0 {
+		logger.Noticef("cannot use interfaces.removal-grace-period configuration: invalid duration %q", value)
+		return 0
+	}
+	return period
+}
+
+// getRemovedConns returns the connections of removed snaps still in
+// their grace period.
+func getRemovedConns(st *state.State) (map[string]*removedConn, error) {
+	var removed map[string]*removedConn
+	err := st.Get("removed-conns", &removed)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if removed == nil {
+		removed = make(map[string]*removedConn)
+	}
+	period := removalGracePeriod(st)
+	now := time.Now()
+	for id, conn := range removed {
+		if now.Sub(conn.Removed) > period {
+			delete(removed, id)
+		}
+	}
+	return removed, nil
+}
+
+func setRemovedConns(st *state.State, removed map[string]*removedConn) {
+	if len(removed) == 0 {
+		st.Set("removed-conns", nil)
+		return
+	}
+	st.Set("removed-conns", removed)
+}
+
+// getUndesiredConns returns the auto-connections the user disconnected,
+// which auto-connection leaves alone.
+func getUndesiredConns(st *state.State) (map[string]bool, error) {
+	var undesired map[string]bool
+	err := st.Get("undesired-conns", &undesired)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if undesired == nil {
+		undesired = make(map[string]bool)
+	}
+	return undesired, nil
+}
+
+func setUndesiredConns(st *state.State, undesired map[string]bool) {
+	if len(undesired) == 0 {
+		st.Set("undesired-conns", nil)
+		return
+	}
+	st.Set("undesired-conns", undesired)
+}
+
+// involvesSnap returns whether the connection with the given id has its
+// plug or its slot on the given snap.
+func involvesSnap(id, snapName string) (bool, error) {
+	connRef, err := interfaces.ParseConnRef(id)
+	if err != nil {
+		return false, err
+	}
+	return connRef.PlugRef.Snap == snapName || connRef.SlotRef.Snap == snapName, nil
+}
+
+// installedSnapID returns the snap id of the current revision of the
+// given snap, or of the removed snap with the given name and id.
+func installedSnapID(st *state.State, name, removedName, removedID string) string {
+	if name == removedName {
+		return removedID
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, name, &snapst); err != nil {
+		return ""
+	}
+	if si := snapst.CurrentSideInfo(); si != nil {
+		return si.SnapID
+	}
+	return ""
+}
+
+// retainConns keeps the given connections of the removed snap, along with
+// the auto-connections of the snap the user disconnected, for the grace
+// period. Without a grace period, only the latter are dropped. The ids of
+// the undesired connections that were moved or dropped are returned.
+func retainConns(st *state.State, snapName, snapID string, conns map[string]connState) ([]string, error) {
+	undesired, err := getUndesiredConns(st)
+	if err != nil {
+		return nil, err
+	}
+	var undesiredIDs []string
+	for id := range undesired {
+		ok, err := involvesSnap(id, snapName)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			undesiredIDs = append(undesiredIDs, id)
+			delete(undesired, id)
+		}
+	}
+	setUndesiredConns(st, undesired)
+
+	if removalGracePeriod(st) == 0 {
+		return undesiredIDs, nil
+	}
+	removed, err := getRemovedConns(st)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for id, conn := range conns {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		removed[id] = &removedConn{
+			Auto:       conn.Auto,
+			Interface:  conn.Interface,
+			Attrs:      conn.Attrs,
+			PlugSnapID: installedSnapID(st, connRef.PlugRef.Snap, snapName, snapID),
+			SlotSnapID: installedSnapID(st, connRef.SlotRef.Snap, snapName, snapID),
+			Removed:    now,
+		}
+	}
+	for _, id := range undesiredIDs {
+		removed[id] = &removedConn{Undesired: true, Removed: now}
+	}
+	setRemovedConns(st, removed)
+	return undesiredIDs, nil
+}
+
+// unretainConns undoes retainConns.
+func unretainConns(st *state.State, conns map[string]connState, undesiredIDs []string) error {
+	removed, err := getRemovedConns(st)
+	if err != nil {
+		return err
+	}
+	undesired, err := getUndesiredConns(st)
+	if err != nil {
+		return err
+	}
+	for id := range conns {
+		delete(removed, id)
+	}
+	for _, id := range undesiredIDs {
+		delete(removed, id)
+		undesired[id] = true
+	}
+	setRemovedConns(st, removed)
+	setUndesiredConns(st, undesired)
+	return nil
+}
+
+// restoreRemovedConns puts back the kept connections of the snap being
+// installed again whose plug and slot are both present, along with the
+// auto-connections the user had disconnected. A connection is only
+// restored between the same snaps, over the same interface, and if the
+// declarations still allow it the way they allowed it to be made; it is
+// dropped otherwise.
+func (m *InterfaceManager) restoreRemovedConns(task *state.Task, snapName string) error {
+	st := task.State()
+	removed, err := getRemovedConns(st)
+	if err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		setRemovedConns(st, nil)
+		return nil
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	undesired, err := getUndesiredConns(st)
+	if err != nil {
+		return err
+	}
+	var autochecker *autoConnectChecker
+	restored := false
+	for id, conn := range removed {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		if connRef.PlugRef.Snap != snapName && connRef.SlotRef.Snap != snapName {
+			continue
+		}
+		restored = true
+		if conn.Undesired {
+			undesired[id] = true
+			delete(removed, id)
+			continue
+		}
+		plug := m.repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name)
+		slot := m.repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if plug == nil || slot == nil {
+			// the other side may come back later
+			continue
+		}
+		if plug.Interface != conn.Interface || slot.Interface != conn.Interface ||
+			plug.Snap.SnapID != conn.PlugSnapID || slot.Snap.SnapID != conn.SlotSnapID {
+			task.Logf("cannot restore connection of %s to %s: not between the same snaps and interface anymore", connRef.PlugRef, connRef.SlotRef)
+			delete(removed, id)
+			continue
+		}
+		if autochecker == nil {
+			autochecker, err = newAutoConnectChecker(st, m.sitePolicy())
+			if err != nil {
+				return err
+			}
+		}
+		if err := autochecker.checkRestore(plug, slot, conn.Auto); err != nil {
+			task.Logf("cannot restore connection of %s to %s: %v", connRef.PlugRef, connRef.SlotRef, err)
+			delete(removed, id)
+			continue
+		}
+		conns[id] = connState{Auto: conn.Auto, Interface: conn.Interface, Attrs: conn.Attrs}
+		delete(removed, id)
+		if err := m.recordConnectionEvent(st, "connect", ByReinstall, connRef, conn.Interface); err != nil {
+			return err
+		}
+	}
+	if restored {
+		setConns(st, conns)
+		setUndesiredConns(st, undesired)
+	}
+	if autochecker != nil {
+		autochecker.logOverridden(task)
+	}
+	setRemovedConns(st, removed)
+	return nil
+}
+
+// checkRestore checks a kept connection against the current declarations
+// and site policy: an auto-connection must still be auto-connected and a
+// manual one must still be allowed to be connected.
+func (c *autoConnectChecker) checkRestore(plug *interfaces.Plug, slot *interfaces.Slot, auto bool) error {
+	if !auto {
+		return c.checkAdmin(plug, slot)
+	}
+	ic, err := c.connectCandidate(plug, slot)
+	if err != nil {
+		return err
+	}
+	return c.checkAutoConnect(ic)
+}
+
+// ForgetConnections drops the connections kept after the removal of the
+// given snap, or of the snaps it was connected to, so that they are not
+// restored, returning how many were dropped.
+func ForgetConnections(st *state.State, snapName string) (int, error) {
+	removed, err := getRemovedConns(st)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for id := range removed {
+		ok, err := involvesSnap(id, snapName)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			delete(removed, id)
+			n++
+		}
+	}
+	setRemovedConns(st, removed)
+	return n, nil
+}
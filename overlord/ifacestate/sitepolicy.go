@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// sitePolicy is the interface policy of the site, as set by the
+// administrator in dirs.SnapInterfacesPolicyFile, for instance:
+//
+//   forbid: [camera]
+//   manual: [network-manager]
+//   auto-connect: [serial-port]
+//
+// The interfaces in forbid cannot be connected at all. Those in manual
+// are never connected automatically, whatever the declarations say,
+// while those in auto-connect are connected automatically whenever the
+// declarations allow the connection, as with "snap connect".
+type sitePolicy struct {
+	Forbid      []string `yaml:"forbid,omitempty"`
+	Manual      []string `yaml:"manual,omitempty"`
+	AutoConnect []string `yaml:"auto-connect,omitempty"`
+
+	// invalid is why the policy set by the administrator cannot be
+	// used, if it cannot: nothing is connected until it is fixed
+	invalid error
+}
+
+func (p *sitePolicy) forbidden(ifaceName string) bool {
+	return strutil.ListContains(p.Forbid, ifaceName)
+}
+
+func (p *sitePolicy) manual(ifaceName string) bool {
+	return strutil.ListContains(p.Manual, ifaceName)
+}
+
+func (p *sitePolicy) autoConnect(ifaceName string) bool {
+	return strutil.ListContains(p.AutoConnect, ifaceName)
+}
+
+// checkConnect returns an error if the policy forbids connecting the
+// given interface.
+func (p *sitePolicy) checkConnect(ifaceName string) error {
+	if p.invalid != nil {
+		return p.invalid
+	}
+	if p.forbidden(ifaceName) {
+		return fmt.Errorf("interface %q is forbidden by the site policy", ifaceName)
+	}
+	return nil
+}
+
+// validate checks that the policy only mentions known interfaces, each
+// of them in a single list.
+func (p *sitePolicy) validate(repo *interfaces.Repository) error {
+	seen := make(map[string]string)
+	for _, list := range []struct {
+		name  string
+		names []string
+	}{
+		{"forbid", p.Forbid},
+		{"manual", p.Manual},
+		{"auto-connect", p.AutoConnect},
+	} {
+		for _, ifaceName := range list.names {
+			if repo.Interface(ifaceName) == nil {
+				return fmt.Errorf("unknown interface %q in %s", ifaceName, list.name)
+			}
+			if other, ok := seen[ifaceName]; ok {
+				return fmt.Errorf("interface %q is in both %s and %s", ifaceName, other, list.name)
+			}
+			seen[ifaceName] = list.name
+		}
+	}
+	return nil
+}
+
+// loadSitePolicy reads and validates the site policy, which is empty
+// when the administrator did not provide one.
+func loadSitePolicy(repo *interfaces.Repository) (*sitePolicy, error) {
+	data, err := ioutil.ReadFile(dirs.SnapInterfacesPolicyFile)
+	if os.IsNotExist(err) {
+		return &sitePolicy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var policy sitePolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", dirs.SnapInterfacesPolicyFile, err)
+	}
+	if err := policy.validate(repo); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", dirs.SnapInterfacesPolicyFile, err)
+	}
+	return &policy, nil
+}
+
+// sitePolicy returns the site policy. An invalid policy forbids all
+// connections, rather than letting through those the administrator
+// meant to forbid, until it is fixed.
+func (m *InterfaceManager) sitePolicy() *sitePolicy {
+	policy, err := loadSitePolicy(m.repo)
+	if err != nil {
+		logger.Noticef("cannot use the site interface policy: %v", err)
+		return &sitePolicy{invalid: fmt.Errorf("cannot use the site interface policy: %v", err)}
+	}
+	return policy
+}
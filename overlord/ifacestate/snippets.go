@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/dbus"
+	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/systemd"
+	"github.com/snapcore/snapd/interfaces/udev"
+)
+
+// ConnectionSnippets returns the security rules connecting the plug to
+// the slot would add, without connecting them, as a map of the name of
+// the security backend to the rules keyed by what they apply to: the
+// security tag of an application or hook for the apparmor, seccomp and
+// dbus backends, the name of the service for the systemd backend and the
+// name of the snap for the others. Backends with nothing to add are left
+// out.
+func ConnectionSnippets(repo *interfaces.Repository, connRef interfaces.ConnRef, attrs map[string]interface{}) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+	for _, backend := range repo.Backends() {
+		plugSpec, slotSpec, err := repo.ConnectionSpecifications(backend.Name(), connRef, attrs)
+		if err != nil {
+			return nil, err
+		}
+		snippets := make(map[string]string)
+		addSpecSnippets(snippets, connRef.PlugRef.Snap, plugSpec)
+		addSpecSnippets(snippets, connRef.SlotRef.Snap, slotSpec)
+		if len(snippets) > 0 {
+			result[string(backend.Name())] = snippets
+		}
+	}
+	return result, nil
+}
+
+// SnapSnippets returns the security rules the plugs and slots of the
+// snap add with its current connections, in the same form as
+// ConnectionSnippets. Those are the rules of the interfaces only, not
+// the rest of the security profiles of the snap.
+func SnapSnippets(repo *interfaces.Repository, snapName string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+	for _, backend := range repo.Backends() {
+		spec, err := repo.SnapSpecification(backend.Name(), snapName)
+		if err != nil {
+			return nil, err
+		}
+		snippets := make(map[string]string)
+		addSpecSnippets(snippets, snapName, spec)
+		if len(snippets) > 0 {
+			result[string(backend.Name())] = snippets
+		}
+	}
+	return result, nil
+}
+
+// addSpecSnippets adds the rules of the specification made for the given
+// snap to the snippets.
+func addSpecSnippets(snippets map[string]string, snapName string, spec interfaces.Specification) {
+	add := func(key string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		if snippets[key] != "" {
+			snippets[key] += "\n"
+		}
+		snippets[key] += strings.Join(lines, "\n")
+	}
+
+	switch spec := spec.(type) {
+	case *apparmor.Specification:
+		for tag, lines := range spec.Snippets() {
+			add(tag, lines)
+		}
+	case *seccomp.Specification:
+		for tag, lines := range spec.Snippets() {
+			add(tag, lines)
+		}
+	case *dbus.Specification:
+		for tag, lines := range spec.Snippets() {
+			add(tag, lines)
+		}
+	case *udev.Specification:
+		add(snapName, spec.Snippets())
+	case *kmod.Specification:
+		modules := make([]string, 0, len(spec.Modules()))
+		for module := range spec.Modules() {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+		add(snapName, modules)
+	case *mount.Specification:
+		var entries []string
+		for _, entry := range spec.MountEntries() {
+			entries = append(entries, entry.String())
+		}
+		add(snapName, entries)
+	case *systemd.Specification:
+		for name, service := range spec.Services() {
+			add(name, []string{service.String()})
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type snippetsSuite struct{}
+
+var _ = Suite(&snippetsSuite{})
+
+const snippetsConsumerYaml = `name: consumer
+apps:
+  app:
+    command: foo
+    plugs: [plug]
+plugs:
+  plug:
+    interface: test
+`
+
+const snippetsProducerYaml = `name: producer
+slots:
+  slot:
+    interface: test
+`
+
+func (snippetsSuite) TestConnectionSnippets(c *C) {
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "test",
+		AppArmorConnectedPlugCallback: func(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+			spec.AddSnippet("/dev/foo rw,")
+			spec.AddSnippet("/dev/bar r,")
+			return nil
+		},
+		AppArmorPermanentPlugCallback: func(spec *apparmor.Specification, plug *interfaces.Plug) error {
+			spec.AddSnippet("permanent")
+			return nil
+		},
+		UDevConnectedSlotCallback: func(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+			spec.AddSnippet(`KERNEL=="` + slotAttrs["path"].(string) + `"`)
+			return nil
+		},
+	}
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddInterface(iface), IsNil)
+	for _, backend := range []interfaces.SecurityBackend{&apparmor.Backend{}, &udev.Backend{}, &kmod.Backend{}} {
+		c.Assert(repo.AddBackend(backend), IsNil)
+	}
+	c.Assert(repo.AddSnap(snaptest.MockInfo(c, snippetsConsumerYaml, nil)), IsNil)
+	c.Assert(repo.AddSnap(snaptest.MockInfo(c, snippetsProducerYaml, nil)), IsNil)
+
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	snippets, err := ifacestate.ConnectionSnippets(repo, connRef, map[string]interface{}{"path": "ttyUSB0"})
+	c.Assert(err, IsNil)
+	// permanent snippets and backends with nothing to add are left out
+	c.Check(snippets, DeepEquals, map[string]map[string]string{
+		"apparmor": {"snap.consumer.app": "/dev/bar r,\n/dev/foo rw,"},
+		"udev":     {"producer": `KERNEL=="ttyUSB0"`},
+	})
+
+	// the connection is not made
+	c.Check(repo.Plug("consumer", "plug").Connections, HasLen, 0)
+}
+
+func (snippetsSuite) TestSnapSnippets(c *C) {
+	iface := &ifacetest.TestInterface{
+		InterfaceName: "test",
+		AppArmorConnectedPlugCallback: func(spec *apparmor.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+			spec.AddSnippet("/dev/foo rw,")
+			return nil
+		},
+		AppArmorPermanentPlugCallback: func(spec *apparmor.Specification, plug *interfaces.Plug) error {
+			spec.AddSnippet("permanent")
+			return nil
+		},
+		UDevConnectedSlotCallback: func(spec *udev.Specification, plug *interfaces.Plug, plugAttrs map[string]interface{}, slot *interfaces.Slot, slotAttrs map[string]interface{}) error {
+			spec.AddSnippet(`KERNEL=="foo"`)
+			return nil
+		},
+	}
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddInterface(iface), IsNil)
+	for _, backend := range []interfaces.SecurityBackend{&apparmor.Backend{}, &udev.Backend{}, &kmod.Backend{}} {
+		c.Assert(repo.AddBackend(backend), IsNil)
+	}
+	c.Assert(repo.AddSnap(snaptest.MockInfo(c, snippetsConsumerYaml, nil)), IsNil)
+	c.Assert(repo.AddSnap(snaptest.MockInfo(c, snippetsProducerYaml, nil)), IsNil)
+
+	// only the permanent snippets while not connected
+	snippets, err := ifacestate.SnapSnippets(repo, "consumer")
+	c.Assert(err, IsNil)
+	c.Check(snippets, DeepEquals, map[string]map[string]string{
+		"apparmor": {"snap.consumer.app": "permanent"},
+	})
+
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	c.Assert(repo.Connect(connRef), IsNil)
+
+	snippets, err = ifacestate.SnapSnippets(repo, "consumer")
+	c.Assert(err, IsNil)
+	c.Check(snippets, DeepEquals, map[string]map[string]string{
+		"apparmor": {"snap.consumer.app": "/dev/foo rw,\npermanent"},
+	})
+	snippets, err = ifacestate.SnapSnippets(repo, "producer")
+	c.Assert(err, IsNil)
+	c.Check(snippets, DeepEquals, map[string]map[string]string{
+		"udev": {"producer": `KERNEL=="foo"`},
+	})
+}
+
+func (snippetsSuite) TestConnectionSnippetsError(c *C) {
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddBackend(&apparmor.Backend{}), IsNil)
+
+	connRef := interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}
+	_, err := ifacestate.ConnectionSnippets(repo, connRef, nil)
+	c.Check(err, ErrorMatches, `cannot connect plug "plug" from snap "consumer", no such plug`)
+}
@@ -0,0 +1,273 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package udevmonitor reports devices as udev adds and removes them.
+package udevmonitor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Interface is the interface of the udev monitor, so that it can be
+// mocked.
+type Interface interface {
+	Connect() error
+	Run() error
+	Stop() error
+}
+
+// DeviceAddedFunc is called with the udev properties of added devices.
+type DeviceAddedFunc func(properties map[string]string)
+
+// DeviceRemovedFunc is called with the udev properties of removed devices.
+type DeviceRemovedFunc func(properties map[string]string)
+
+// Monitor listens to the events udev broadcasts once it is done
+// processing the events of the kernel.
+type Monitor struct {
+	deviceAdded   DeviceAddedFunc
+	deviceRemoved DeviceRemovedFunc
+
+	fd      int
+	started bool
+	tomb    tomb.Tomb
+}
+
+// New returns a monitor calling the given functions as devices are added
+// and removed.
+func New(added DeviceAddedFunc, removed DeviceRemovedFunc) Interface {
+	return &Monitor{
+		deviceAdded:   added,
+		deviceRemoved: removed,
+		fd:            -1,
+	}
+}
+
+// udevEventGroup is the netlink multicast group of the events of udev,
+// the events of the kernel itself being sent to group 1.
+const udevEventGroup = 2
+
+// readTimeout bounds how long Stop waits for the monitor to notice it.
+var readTimeout = 500 * time.Millisecond
+
+// Connect opens the netlink socket udev broadcasts events on.
+func (m *Monitor) Connect() error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return fmt.Errorf("cannot create udev monitor socket: %v", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: udevEventGroup}); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("cannot bind udev monitor socket: %v", err)
+	}
+	// credentials are needed to ignore events not sent by root
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("cannot set up udev monitor socket: %v", err)
+	}
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("cannot set up udev monitor socket: %v", err)
+	}
+	m.fd = fd
+	return nil
+}
+
+// Run reports the devices already present, then the devices added and
+// removed until the monitor is stopped.
+func (m *Monitor) Run() error {
+	if m.fd < 0 {
+		return fmt.Errorf("cannot run udev monitor: not connected")
+	}
+	// the socket is already open so nothing added meanwhile is missed
+	devices, err := enumerateDevices()
+	if err != nil {
+		return err
+	}
+	m.started = true
+	m.tomb.Go(func() error {
+		defer syscall.Close(m.fd)
+
+		for _, properties := range devices {
+			m.deviceAdded(properties)
+		}
+		return m.listen()
+	})
+	return nil
+}
+
+func (m *Monitor) listen() error {
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofUcred))
+	for {
+		select {
+		case <-m.tomb.Dying():
+			return nil
+		default:
+		}
+		n, oobn, _, _, err := syscall.Recvmsg(m.fd, buf, oob, 0)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read udev event: %v", err)
+		}
+		if !sentByRoot(oob[:oobn]) {
+			continue
+		}
+		properties, err := parseUDevEvent(buf[:n])
+		if err != nil {
+			logger.Debugf("ignoring udev event: %v", err)
+			continue
+		}
+		switch properties["ACTION"] {
+		case "add":
+			m.deviceAdded(properties)
+		case "remove":
+			m.deviceRemoved(properties)
+		}
+	}
+}
+
+// Stop stops the monitor and waits for it to be done.
+func (m *Monitor) Stop() error {
+	if !m.started {
+		if m.fd >= 0 {
+			syscall.Close(m.fd)
+			m.fd = -1
+		}
+		return nil
+	}
+	m.tomb.Kill(nil)
+	return m.tomb.Wait()
+}
+
+func sentByRoot(oob []byte) bool {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil || len(msgs) != 1 {
+		return false
+	}
+	cred, err := syscall.ParseUnixCredentials(&msgs[0])
+	return err == nil && cred.Uid == 0
+}
+
+const (
+	udevEventPrefix = "libudev\x00"
+	udevEventMagic  = 0xfeedcafe
+	// the size of the prefix, magic, header size, properties offset
+	// and properties length
+	udevEventMinHeaderSize = 24
+)
+
+// nativeEndian returns the byte order of the host, which is that of the
+// fields of the header of udev events, except for the magic.
+func nativeEndian() binary.ByteOrder {
+	switch runtime.GOARCH {
+	case "s390x", "ppc64", "ppc", "mips", "mips64":
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// parseUDevEvent returns the properties of the device carried by an
+// event sent by udev.
+func parseUDevEvent(buf []byte) (map[string]string, error) {
+	if len(buf) < udevEventMinHeaderSize || string(buf[:len(udevEventPrefix)]) != udevEventPrefix {
+		return nil, fmt.Errorf("not an udev event")
+	}
+	if binary.BigEndian.Uint32(buf[8:12]) != udevEventMagic {
+		return nil, fmt.Errorf("invalid udev event magic")
+	}
+	order := nativeEndian()
+	off := int(order.Uint32(buf[16:20]))
+	size := int(order.Uint32(buf[20:24]))
+	if off < udevEventMinHeaderSize || off+size > len(buf) {
+		return nil, fmt.Errorf("invalid udev event properties (offset %d, length %d, size %d)", off, size, len(buf))
+	}
+	properties := make(map[string]string)
+	for _, prop := range bytes.Split(buf[off:off+size], []byte{0}) {
+		if kv := strings.SplitN(string(prop), "=", 2); len(kv) == 2 {
+			properties[kv[0]] = kv[1]
+		}
+	}
+	return properties, nil
+}
+
+// enumerateDevices returns the properties of the devices udev knows about.
+func enumerateDevices() ([]map[string]string, error) {
+	output, err := exec.Command("udevadm", "info", "--export-db").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cannot enumerate devices: %v", osutil.OutputErr(output, err))
+	}
+	return parseUDevadmExportDB(output), nil
+}
+
+// parseUDevadmExportDB parses the output of "udevadm info --export-db",
+// made of one block of lines per device, such as:
+//
+//   P: /devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0
+//   N: ttyUSB0
+//   E: DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0
+//   E: SUBSYSTEM=tty
+//
+// keeping only the properties ("E:" lines).
+func parseUDevadmExportDB(output []byte) []map[string]string {
+	var devices []map[string]string
+	var properties map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if properties != nil {
+				devices = append(devices, properties)
+			}
+			properties = nil
+			continue
+		}
+		if !strings.HasPrefix(line, "E: ") {
+			continue
+		}
+		kv := strings.SplitN(line[len("E: "):], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if properties == nil {
+			properties = make(map[string]string)
+		}
+		properties[kv[0]] = kv[1]
+	}
+	if properties != nil {
+		devices = append(devices, properties)
+	}
+	return devices
+}
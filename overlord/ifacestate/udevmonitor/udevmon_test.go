@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package udevmonitor_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type udevMonitorSuite struct{}
+
+var _ = Suite(&udevMonitorSuite{})
+
+func mockUDevEvent(order binary.ByteOrder, magic uint32, properties ...string) []byte {
+	var buf bytes.Buffer
+	props := []byte{}
+	for _, prop := range properties {
+		props = append(props, prop...)
+		props = append(props, 0)
+	}
+	buf.WriteString("libudev\x00")
+	binary.Write(&buf, binary.BigEndian, magic)
+	// header size, properties offset and length, filter hashes
+	const headerSize = 40
+	binary.Write(&buf, order, uint32(headerSize))
+	binary.Write(&buf, order, uint32(headerSize))
+	binary.Write(&buf, order, uint32(len(props)))
+	buf.Write(make([]byte, headerSize-buf.Len()))
+	buf.Write(props)
+	return buf.Bytes()
+}
+
+func (s *udevMonitorSuite) TestParseUDevEvent(c *C) {
+	buf := mockUDevEvent(udevmonitor.NativeEndian(), 0xfeedcafe, "ACTION=add", "DEVPATH=/devices/foo/tty/ttyUSB0", "SUBSYSTEM=tty", "ID_MODEL=Some=Model")
+	properties, err := udevmonitor.ParseUDevEvent(buf)
+	c.Assert(err, IsNil)
+	c.Check(properties, DeepEquals, map[string]string{
+		"ACTION":    "add",
+		"DEVPATH":   "/devices/foo/tty/ttyUSB0",
+		"SUBSYSTEM": "tty",
+		"ID_MODEL":  "Some=Model",
+	})
+}
+
+func (s *udevMonitorSuite) TestParseUDevEventErrors(c *C) {
+	_, err := udevmonitor.ParseUDevEvent([]byte("add@/devices/foo\x00ACTION=add\x00"))
+	c.Check(err, ErrorMatches, "not an udev event")
+
+	_, err = udevmonitor.ParseUDevEvent(mockUDevEvent(udevmonitor.NativeEndian(), 0xcafe, "ACTION=add"))
+	c.Check(err, ErrorMatches, "invalid udev event magic")
+
+	buf := mockUDevEvent(udevmonitor.NativeEndian(), 0xfeedcafe, "ACTION=add")
+	_, err = udevmonitor.ParseUDevEvent(buf[:len(buf)-2])
+	c.Check(err, ErrorMatches, `invalid udev event properties \(offset 40, length 11, size 49\)`)
+}
+
+const mockExportDB = `P: /devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0
+N: ttyUSB0
+S: serial/by-id/usb-FTDI_FT232R_USB_UART_A123-if00-port0
+E: DEVNAME=/dev/ttyUSB0
+E: DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0
+E: ID_MODEL=FT232R_USB_UART
+E: SUBSYSTEM=tty
+
+P: /devices/virtual/misc/uhid
+N: uhid
+E: DEVNAME=/dev/uhid
+E: DEVPATH=/devices/virtual/misc/uhid
+E: SUBSYSTEM=misc
+`
+
+func (s *udevMonitorSuite) TestParseUDevadmExportDB(c *C) {
+	c.Check(udevmonitor.ParseUDevadmExportDB([]byte(mockExportDB)), DeepEquals, []map[string]string{{
+		"DEVNAME":   "/dev/ttyUSB0",
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/ttyUSB0/tty/ttyUSB0",
+		"ID_MODEL":  "FT232R_USB_UART",
+		"SUBSYSTEM": "tty",
+	}, {
+		"DEVNAME":   "/dev/uhid",
+		"DEVPATH":   "/devices/virtual/misc/uhid",
+		"SUBSYSTEM": "misc",
+	}})
+	c.Check(udevmonitor.ParseUDevadmExportDB(nil), HasLen, 0)
+}
+
+func (s *udevMonitorSuite) TestEnumerateDevices(c *C) {
+	udevadm := testutil.MockCommand(c, "udevadm", "cat <<'EOF'\n"+mockExportDB+"EOF")
+	defer udevadm.Restore()
+
+	devices, err := udevmonitor.EnumerateDevices()
+	c.Assert(err, IsNil)
+	c.Check(devices, HasLen, 2)
+	c.Check(udevadm.Calls(), DeepEquals, [][]string{{"udevadm", "info", "--export-db"}})
+}
+
+func (s *udevMonitorSuite) TestEnumerateDevicesError(c *C) {
+	udevadm := testutil.MockCommand(c, "udevadm", "echo boom; exit 1")
+	defer udevadm.Restore()
+
+	_, err := udevmonitor.EnumerateDevices()
+	c.Check(err, ErrorMatches, "cannot enumerate devices: boom")
+}
+
+func (s *udevMonitorSuite) TestRunNotConnected(c *C) {
+	mon := udevmonitor.New(nil, nil)
+	c.Check(mon.Run(), ErrorMatches, "cannot run udev monitor: not connected")
+	c.Check(mon.Stop(), IsNil)
+}
@@ -32,15 +32,19 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/timings"
 
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/backupstate"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/promptstate"
+	"github.com/snapcore/snapd/overlord/secretstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
@@ -76,6 +80,9 @@ type Overlord struct {
 	configMgr *configstate.ConfigManager
 	deviceMgr *devicestate.DeviceManager
 	cmdMgr    *cmdstate.CommandManager
+	promptMgr *promptstate.PromptManager
+	// startup
+	startupTimings *timings.Recorder
 }
 
 var setupStore = storestate.SetupStore
@@ -83,8 +90,9 @@ var setupStore = storestate.SetupStore
 // New creates a new Overlord with all its state managers.
 func New() (*Overlord, error) {
 	o := &Overlord{
-		loopTomb: new(tomb.Tomb),
-		inited:   true,
+		loopTomb:       new(tomb.Tomb),
+		inited:         true,
+		startupTimings: timings.New(),
 	}
 
 	backend := &overlordStateBackend{
@@ -92,58 +100,105 @@ func New() (*Overlord, error) {
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
 	}
-	s, err := loadState(backend)
+	var s *state.State
+	err := o.timed("load-state", func() (err error) {
+		s, err = loadState(backend)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	s.Lock()
+	s.SetLogArchive(&taskLogArchive{dir: dirs.SnapTaskLogsDir})
+	s.SetTaskErrorHandler(reportTaskError)
+	s.Unlock()
 
 	o.stateEng = NewStateEngine(s)
 
-	hookMgr, err := hookstate.Manager(s)
+	var hookMgr *hookstate.HookManager
+	err = o.timed("hookstate", func() (err error) {
+		hookMgr, err = hookstate.Manager(s)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	o.addManager(hookMgr)
 
-	snapMgr, err := snapstate.Manager(s)
+	var snapMgr *snapstate.SnapManager
+	err = o.timed("snapstate", func() (err error) {
+		snapMgr, err = snapstate.Manager(s)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	o.addManager(snapMgr)
 
-	assertMgr, err := assertstate.Manager(s)
-	if err != nil {
+	// opening the assertions database does not depend on the other
+	// managers, so do it while the interface manager regenerates
+	// the security profiles of all snaps, which is the bulk of the
+	// startup time
+	var assertMgr *assertstate.AssertManager
+	assertErr := make(chan error, 1)
+	go func() {
+		assertErr <- o.timed("assertstate", func() (err error) {
+			assertMgr, err = assertstate.Manager(s)
+			return err
+		})
+	}()
+
+	var ifaceMgr *ifacestate.InterfaceManager
+	err = o.timed("ifacestate", func() (err error) {
+		ifaceMgr, err = ifacestate.Manager(s, hookMgr, nil, nil)
+		return err
+	})
+	if err := <-assertErr; err != nil {
 		return nil, err
 	}
-	o.addManager(assertMgr)
-
-	ifaceMgr, err := ifacestate.Manager(s, hookMgr, nil, nil)
 	if err != nil {
 		return nil, err
 	}
+
+	o.addManager(hookMgr)
+	o.addManager(snapMgr)
+	o.addManager(assertMgr)
 	o.addManager(ifaceMgr)
 
 	// TODO: this is a bit weird, not actually a StateManager
-	configMgr, err := configstate.Manager(s, hookMgr)
+	err = o.timed("configstate", func() (err error) {
+		o.configMgr, err = configstate.Manager(s, hookMgr)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	o.configMgr = configMgr
 
-	deviceMgr, err := devicestate.Manager(s, hookMgr)
+	var deviceMgr *devicestate.DeviceManager
+	err = o.timed("devicestate", func() (err error) {
+		deviceMgr, err = devicestate.Manager(s, hookMgr)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 	o.addManager(deviceMgr)
 
 	o.addManager(cmdstate.Manager(s))
+	o.addManager(promptstate.Manager(s))
+	o.addManager(backupstate.Manager(s))
+	o.addManager(secretstate.Manager(s))
+
+	if err := o.addExtras(s, hookMgr); err != nil {
+		return nil, err
+	}
 
 	s.Lock()
 	defer s.Unlock()
 
 	// setting up the store
 	authContext := auth.NewAuthContext(s, o.deviceMgr)
-	err = setupStore(s, authContext)
+	err = o.timed("store", func() error {
+		return setupStore(s, authContext)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -155,6 +210,13 @@ func New() (*Overlord, error) {
 	return o, nil
 }
 
+// timed runs f, recording how long it took in the startup timings.
+func (o *Overlord) timed(label string, f func() error) error {
+	done := o.startupTimings.StartSpan(label)
+	defer done()
+	return f()
+}
+
 func (o *Overlord) addManager(mgr StateManager) {
 	switch x := mgr.(type) {
 	case *hookstate.HookManager:
@@ -169,6 +231,8 @@ func (o *Overlord) addManager(mgr StateManager) {
 		o.deviceMgr = x
 	case *cmdstate.CommandManager:
 		o.cmdMgr = x
+	case *promptstate.PromptManager:
+		o.promptMgr = x
 	}
 	o.stateEng.AddManager(mgr)
 }
@@ -270,6 +334,21 @@ func (o *Overlord) Loop() {
 			}
 		}
 	})
+	// the watchdog has its own loop so that it still works when
+	// the ensure loop is what is stuck
+	o.loopTomb.Go(func() error {
+		watchdog := newStuckWatchdog(o.State())
+		ticker := time.NewTicker(stuckCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.loopTomb.Dying():
+				return nil
+			case <-ticker.C:
+				watchdog.check()
+			}
+		}
+	})
 }
 
 // Stop stops the ensure loop and the managers under the StateEngine.
@@ -384,12 +463,32 @@ func (o *Overlord) CommandManager() *cmdstate.CommandManager {
 	return o.cmdMgr
 }
 
+// PromptManager returns the manager responsible for prompting about
+// the accesses of snaps
+func (o *Overlord) PromptManager() *promptstate.PromptManager {
+	return o.promptMgr
+}
+
+// StartupTimings returns the recorder of how long the different steps
+// of starting up took.
+func (o *Overlord) StartupTimings() *timings.Recorder {
+	return o.startupTimings
+}
+
+// NextWakeups returns when the managers have work scheduled next, for
+// which snapd needs to be running.
+// The caller should be holding the state lock.
+func (o *Overlord) NextWakeups() []time.Time {
+	return o.stateEng.NextWakeups()
+}
+
 // Mock creates an Overlord without any managers and with a backend
 // not using disk. Managers can be added with AddManager. For testing.
 func Mock() *Overlord {
 	o := &Overlord{
-		loopTomb: new(tomb.Tomb),
-		inited:   false,
+		loopTomb:       new(tomb.Tomb),
+		inited:         false,
+		startupTimings: timings.New(),
 	}
 	o.stateEng = NewStateEngine(state.New(mockBackend{o: o}))
 	return o
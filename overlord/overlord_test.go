@@ -26,6 +26,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"syscall"
 	"testing"
 	"time"
@@ -34,11 +35,16 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/patch"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -79,6 +85,7 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.HookManager(), NotNil)
 	c.Check(o.DeviceManager(), NotNil)
 	c.Check(o.CommandManager(), NotNil)
+	c.Check(o.PromptManager(), NotNil)
 
 	s := o.State()
 	c.Check(s, NotNil)
@@ -92,6 +99,17 @@ func (ovs *overlordSuite) TestNew(c *C) {
 
 	// store was setup with an auth context
 	c.Check(setupStoreAuthContext, NotNil)
+
+	// the startup steps were timed
+	var labels []string
+	for _, span := range o.StartupTimings().Spans() {
+		labels = append(labels, span.Label)
+	}
+	sort.Strings(labels)
+	c.Check(labels, DeepEquals, []string{
+		"assertstate", "configstate", "devicestate", "hookstate",
+		"ifacestate", "load-state", "snapstate", "store",
+	})
 }
 
 func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
@@ -364,6 +382,53 @@ func (ovs *overlordSuite) TestEnsureLoopMediatedEnsureBeforeOutsideEnsure(c *C)
 	}
 }
 
+func (ovs *overlordSuite) TestNewSetsLogArchive(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	t := st.NewTask("foo", "...")
+	t.Logf("hello")
+	chg := st.NewChange("foo", "...")
+	chg.AddTask(t)
+	chg.SetStatus(state.DoneStatus)
+	logs := t.Log()
+
+	st.Prune(time.Hour, time.Hour, 100)
+
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapTaskLogsDir, chg.ID()+".json.gz")), Equals, true)
+	c.Check(t.Log(), DeepEquals, logs)
+}
+
+func (ovs *overlordSuite) TestTaskLogArchive(c *C) {
+	dir := filepath.Join(c.MkDir(), "task-logs")
+	archive := overlord.NewTaskLogArchive(dir)
+
+	_, err := archive.Load("1")
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	logs := map[string][]string{
+		"1": {"2017-10-10T10:10:10Z INFO one", "2017-10-10T10:10:11Z ERROR two"},
+		"2": {"2017-10-10T10:10:12Z INFO three"},
+	}
+	c.Assert(archive.Store("1", logs), IsNil)
+
+	fi, err := os.Stat(filepath.Join(dir, "1.json.gz"))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0600))
+
+	loaded, err := archive.Load("1")
+	c.Assert(err, IsNil)
+	c.Check(loaded, DeepEquals, logs)
+
+	c.Assert(archive.Discard("1"), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dir, "1.json.gz")), Equals, false)
+	// discarding again is fine
+	c.Check(archive.Discard("1"), IsNil)
+}
+
 func (ovs *overlordSuite) TestEnsureLoopPrune(c *C) {
 	restoreIntv := overlord.MockPruneInterval(200*time.Millisecond, 1000*time.Millisecond, 1000*time.Millisecond)
 	defer restoreIntv()
@@ -422,6 +487,78 @@ func (ovs *overlordSuite) TestEnsureLoopPrune(c *C) {
 	c.Assert(t1.Status(), Equals, state.HoldStatus)
 }
 
+func (ovs *overlordSuite) TestStuckWatchdog(c *C) {
+	restore := overlord.MockStuckWatchdog(20*time.Millisecond, 50*time.Millisecond)
+	defer restore()
+	o := overlord.Mock()
+
+	st := o.State()
+	st.Lock()
+	t1 := st.NewTask("foo", "...")
+	t2 := st.NewTask("bar", "...")
+	chg := st.NewChange("stuck", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	t1.SetStatus(state.DoingStatus)
+	st.Unlock()
+
+	o.Loop()
+
+	var stuck map[string]map[string]interface{}
+	for i := 0; i < 100; i++ {
+		st.Lock()
+		err := chg.Get("stuck-tasks", &stuck)
+		st.Unlock()
+		if err == nil {
+			break
+		}
+		c.Assert(err, Equals, state.ErrNoState)
+		time.Sleep(20 * time.Millisecond)
+	}
+	c.Assert(o.Stop(), IsNil)
+
+	c.Assert(stuck, HasLen, 1)
+	captured := stuck[t1.ID()]
+	c.Assert(captured, NotNil)
+	c.Check(captured["kind"], Equals, "foo")
+	c.Check(captured["status"], Equals, "Doing")
+	c.Check(captured["task"].(map[string]interface{})["id"], Equals, t1.ID())
+	c.Check(captured["goroutines"], Matches, `(?s)goroutine [0-9]+ \[running\]:.*`)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(t1.Log(), HasLen, 1)
+	c.Check(t1.Log()[0], Matches, `.* INFO task stuck in Doing since .*, diagnostics captured in change `+chg.ID())
+	c.Check(t2.Log(), HasLen, 0)
+}
+
+func (ovs *overlordSuite) TestStuckWatchdogDisabled(c *C) {
+	restore := overlord.MockStuckWatchdog(10*time.Millisecond, 10*time.Millisecond)
+	defer restore()
+	o := overlord.Mock()
+
+	st := o.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "changes.stuck-threshold", "0"), IsNil)
+	tr.Commit()
+	t1 := st.NewTask("foo", "...")
+	chg := st.NewChange("stuck", "...")
+	chg.AddTask(t1)
+	t1.SetStatus(state.DoingStatus)
+	st.Unlock()
+
+	o.Loop()
+	time.Sleep(100 * time.Millisecond)
+	c.Assert(o.Stop(), IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	var stuck map[string]interface{}
+	c.Check(chg.Get("stuck-tasks", &stuck), Equals, state.ErrNoState)
+	c.Check(t1.Log(), HasLen, 0)
+}
+
 func (ovs *overlordSuite) TestEnsureLoopPruneRunsMultipleTimes(c *C) {
 	restoreIntv := overlord.MockPruneInterval(100*time.Millisecond, 1000*time.Millisecond, 1*time.Hour)
 	defer restoreIntv()
@@ -738,3 +875,164 @@ func (ovs *overlordSuite) TestRequestRestartHandler(c *C) {
 
 	c.Check(restartRequested, Equals, true)
 }
+
+type extraManager struct {
+	ensureCalled int
+}
+
+func (m *extraManager) Ensure() error {
+	m.ensureCalled++
+	return nil
+}
+
+func (m *extraManager) Wait() {}
+
+func (m *extraManager) Stop() {}
+
+func registerFrobTaskKind(frobbed *[]string) {
+	overlord.RegisterExtraTaskKind(overlord.ExtraTaskKind{
+		Kind: "acme-frob",
+		Do: func(t *state.Task, _ *tomb.Tomb) error {
+			st := t.State()
+			st.Lock()
+			defer st.Unlock()
+			var snapName string
+			if err := t.Get("snap-name", &snapName); err != nil {
+				return err
+			}
+			*frobbed = append(*frobbed, snapName)
+			return nil
+		},
+		AffectedSnaps: func(t *state.Task) ([]string, error) {
+			var snapName string
+			err := t.Get("snap-name", &snapName)
+			return []string{snapName}, err
+		},
+	})
+}
+
+func (ovs *overlordSuite) TestNewWithExtras(c *C) {
+	restore := overlord.MockExtras()
+	defer restore()
+	defer overlord.MockSetupStore(func(*state.State, auth.AuthContext) error { return nil })()
+
+	var frobbed []string
+	registerFrobTaskKind(&frobbed)
+	c.Check(func() {
+		overlord.RegisterExtraTaskKind(overlord.ExtraTaskKind{Kind: "acme-frob", Do: func(*state.Task, *tomb.Tomb) error { return nil }})
+	}, PanicMatches, `internal error: extra task kind "acme-frob" is already registered`)
+
+	overlord.RegisterExtraManager("acmestate", func(st *state.State, hookMgr *hookstate.HookManager) (overlord.StateManager, error) {
+		c.Check(st, NotNil)
+		c.Check(hookMgr, NotNil)
+		return &extraManager{}, nil
+	})
+	c.Check(func() {
+		overlord.RegisterExtraManager("acmestate", nil)
+	}, PanicMatches, `internal error: extra manager "acmestate" is already registered`)
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	var labels []string
+	for _, span := range o.StartupTimings().Spans() {
+		labels = append(labels, span.Label)
+	}
+	c.Check(labels, testutil.Contains, "acmestate")
+
+	// the tasks of the extra kinds honour the snap locking rules
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	t := st.NewTask("acme-frob", "...")
+	t.Set("snap-name", "some-snap")
+	st.NewChange("frob", "...").AddTask(t)
+	c.Check(snapstate.CheckChangeConflict(st, "some-snap", nil, nil), ErrorMatches, `snap "some-snap" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflict(st, "other-snap", nil, nil), IsNil)
+}
+
+func (ovs *overlordSuite) TestExtrasRun(c *C) {
+	restore := overlord.MockExtras()
+	defer restore()
+
+	var frobbed []string
+	registerFrobTaskKind(&frobbed)
+	mgr := &extraManager{}
+	overlord.RegisterExtraManager("acmestate", func(*state.State, *hookstate.HookManager) (overlord.StateManager, error) {
+		return mgr, nil
+	})
+
+	o := overlord.Mock()
+	c.Assert(o.AddExtras(nil), IsNil)
+
+	st := o.State()
+	st.Lock()
+	chg := st.NewChange("frob", "...")
+	t := st.NewTask("acme-frob", "...")
+	t.Set("snap-name", "some-snap")
+	chg.AddTask(t)
+	st.Unlock()
+
+	c.Assert(o.Settle(5*time.Second), IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(frobbed, DeepEquals, []string{"some-snap"})
+	c.Check(mgr.ensureCalled > 0, Equals, true)
+}
+
+func (ovs *overlordSuite) TestNewWithExtraManagerError(c *C) {
+	restore := overlord.MockExtras()
+	defer restore()
+	defer overlord.MockSetupStore(func(*state.State, auth.AuthContext) error { return nil })()
+
+	overlord.RegisterExtraManager("acmestate", func(*state.State, *hookstate.HookManager) (overlord.StateManager, error) {
+		return nil, errors.New("boom")
+	})
+
+	_, err := overlord.New()
+	c.Assert(err, ErrorMatches, "cannot create acmestate manager: boom")
+}
+
+func (ovs *overlordSuite) TestReportTaskError(c *C) {
+	reported := make(chan *errreport.Report, 2)
+	restore := overlord.MockErrReport(func(st *state.State, r *errreport.Report) (string, error) {
+		// the state is not locked anymore
+		st.Lock()
+		st.Unlock()
+		reported <- r
+		return "some-id", nil
+	})
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	chg := st.NewChange("install-snap", "...")
+	t := st.NewTask("mount-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "foo"}})
+	chg.AddTask(t)
+	hook := st.NewTask("run-hook", "...")
+	chg.AddTask(hook)
+
+	overlord.ReportTaskError(hook, errors.New("hook failed"))
+	overlord.ReportTaskError(t, errors.New("boom"))
+	st.Unlock()
+
+	select {
+	case r := <-reported:
+		c.Check(r.Kind, Equals, "task")
+		c.Check(r.Snap, Equals, "foo")
+		c.Check(r.Message, Equals, `task "mount-snap" of change "install-snap" failed: boom`)
+		c.Check(r.DuplicateSignature, Equals, "task:install-snap:mount-snap:boom")
+		c.Check(r.Extra, DeepEquals, map[string]string{"TaskKind": "mount-snap", "ChangeKind": "install-snap"})
+	case <-time.After(5 * time.Second):
+		c.Fatal("task failure was not reported")
+	}
+	// hook failures are reported by the hook manager
+	select {
+	case r := <-reported:
+		c.Errorf("unexpected report: %v", r)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
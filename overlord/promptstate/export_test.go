@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package promptstate
+
+func MockNewListener(f func() (Listener, error)) (restore func()) {
+	old := newListener
+	newListener = f
+	return func() {
+		newListener = old
+	}
+}
+
+func (m *PromptManager) HandleNotification(n *Notification) error {
+	return m.handleNotification(n)
+}
+
+var MatchPath = matchPath
+
+var (
+	NativeEndian   = nativeEndian
+	EncodeResponse = encodeResponse
+	Permission     = permission
+)
+
+type KernelRequest struct {
+	ID          uint64
+	Allow, Deny uint32
+	Label       string
+	UID         uint32
+	Path        string
+}
+
+func ParseKernelRequest(msg []byte) (*KernelRequest, error) {
+	req, err := parseKernelRequest(msg)
+	if req == nil {
+		return nil, err
+	}
+	return &KernelRequest{
+		ID:    req.id,
+		Allow: req.allow,
+		Deny:  req.deny,
+		Label: req.label,
+		UID:   req.suid,
+		Path:  req.path,
+	}, nil
+}
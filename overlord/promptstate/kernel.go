@@ -0,0 +1,370 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package promptstate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+var (
+	// notifyPath is where the kernel takes the prompt client, which
+	// receives the notifications about the accesses hitting prompt
+	// rules and replies to them.
+	notifyPath = "/sys/kernel/security/apparmor/.notify"
+	// permstablePath lists the permissions the policy of the kernel
+	// supports, prompt among them if rules can ask about accesses.
+	permstablePath = "/sys/kernel/security/apparmor/features/policy/permstable32"
+)
+
+// Supported returns whether the kernel can ask about the accesses
+// hitting prompt rules.
+func Supported() bool {
+	if !osutil.FileExists(filepath.Join(dirs.GlobalRootDir, notifyPath)) {
+		return false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, permstablePath))
+	if err != nil {
+		return false
+	}
+	for _, perm := range strings.Fields(string(data)) {
+		if perm == "prompt" {
+			return true
+		}
+	}
+	return false
+}
+
+// the version of the protocol of the notification interface spoken
+const notifyProtocolVersion = 3
+
+// ioctls of the notification interface, _IOW/_IOWR('\xF8', nr, u64)
+const (
+	ioctlSetFilter = 0x4008F800
+	ioctlRecv      = 0xC008F804
+	ioctlSend      = 0xC008F805
+)
+
+// modesetUser makes the notifications of user prompts be sent to the
+// client.
+const modesetUser = 1
+
+// the types of notifications
+const (
+	notifyResponse = 0
+	notifyCancel   = 1
+	notifyOp       = 4
+)
+
+// mediation classes
+const classFile = 2
+
+// file permissions of AppArmor
+const (
+	mayExec   = 1 << 0
+	mayWrite  = 1 << 1
+	mayRead   = 1 << 2
+	mayAppend = 1 << 3
+)
+
+// the sizes of the messages of the notification interface, all packed
+const (
+	msgHeaderSize       = 4                        // length, version
+	msgFilterSize       = msgHeaderSize + 12       // modeset, ns, filter
+	msgNotificationSize = msgHeaderSize + 16       // type, signalled, reserved, id, error
+	msgResponseSize     = msgNotificationSize + 12 // error, allow, deny
+	msgOpSize           = msgNotificationSize + 20 // allow, deny, pid, label, class, op
+	msgFileSize         = msgOpSize + 12           // suid, ouid, name
+	maxMsgSize          = 0xffff
+)
+
+// nativeEndian returns the byte order of the host, which is that of the
+// messages of the notification interface.
+func nativeEndian() binary.ByteOrder {
+	switch runtime.GOARCH {
+	case "s390x", "ppc64", "ppc", "mips", "mips64":
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// kernelRequest is a notification of the kernel about a file access
+// hitting a prompt rule.
+type kernelRequest struct {
+	id uint64
+	// allow are the permissions already allowed, deny those to
+	// decide about
+	allow, deny uint32
+	pid         uint32
+	label       string
+	suid        uint32
+	path        string
+}
+
+// cstring returns the NUL terminated string at the given offset of
+// the message.
+func cstring(msg []byte, off uint32) (string, error) {
+	if off == 0 {
+		return "", nil
+	}
+	if int(off) >= len(msg) {
+		return "", fmt.Errorf("invalid string offset %d in message of %d bytes", off, len(msg))
+	}
+	s := msg[off:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		return string(s[:i]), nil
+	}
+	return "", fmt.Errorf("unterminated string at offset %d", off)
+}
+
+// parseKernelRequest decodes a notification of the kernel. It returns
+// no request for the notifications that do not need a reply.
+func parseKernelRequest(msg []byte) (*kernelRequest, error) {
+	order := nativeEndian()
+	if len(msg) < msgNotificationSize {
+		return nil, fmt.Errorf("message too short (%d bytes)", len(msg))
+	}
+	if length := int(order.Uint16(msg[0:2])); length != len(msg) {
+		return nil, fmt.Errorf("invalid message length %d, got %d bytes", length, len(msg))
+	}
+	if version := order.Uint16(msg[2:4]); version != notifyProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", version)
+	}
+	if ntype := order.Uint16(msg[4:6]); ntype != notifyOp {
+		// cancelled and other notifications are not asking anything
+		return nil, nil
+	}
+	if len(msg) < msgOpSize {
+		return nil, fmt.Errorf("operation message too short (%d bytes)", len(msg))
+	}
+	req := &kernelRequest{
+		id:    order.Uint64(msg[8:16]),
+		allow: order.Uint32(msg[20:24]),
+		deny:  order.Uint32(msg[24:28]),
+		pid:   order.Uint32(msg[28:32]),
+	}
+	var err error
+	if req.label, err = cstring(msg, order.Uint32(msg[32:36])); err != nil {
+		return nil, err
+	}
+	if class := order.Uint16(msg[36:38]); class != classFile {
+		return nil, fmt.Errorf("unsupported mediation class %d", class)
+	}
+	if len(msg) < msgFileSize {
+		return nil, fmt.Errorf("file message too short (%d bytes)", len(msg))
+	}
+	req.suid = order.Uint32(msg[40:44])
+	if req.path, err = cstring(msg, order.Uint32(msg[48:52])); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// encodeResponse encodes the reply to the request with the given id,
+// allowing or denying the permissions asked about.
+func encodeResponse(id uint64, perms uint32, allow bool) []byte {
+	order := nativeEndian()
+	msg := make([]byte, msgResponseSize)
+	order.PutUint16(msg[0:2], msgResponseSize)
+	order.PutUint16(msg[2:4], notifyProtocolVersion)
+	order.PutUint16(msg[4:6], notifyResponse)
+	order.PutUint64(msg[8:16], id)
+	if allow {
+		order.PutUint32(msg[24:28], perms)
+	} else {
+		errno := -int32(syscall.EACCES)
+		order.PutUint32(msg[20:24], uint32(errno))
+		order.PutUint32(msg[28:32], perms)
+	}
+	return msg
+}
+
+// encodeFilter encodes the filter registering as the prompt client.
+func encodeFilter() []byte {
+	order := nativeEndian()
+	msg := make([]byte, msgFilterSize)
+	order.PutUint16(msg[0:2], msgFilterSize)
+	order.PutUint16(msg[2:4], notifyProtocolVersion)
+	order.PutUint32(msg[4:8], modesetUser)
+	return msg
+}
+
+// permission returns the permission a request is about, the strongest
+// one asked for.
+func permission(perms uint32) string {
+	switch {
+	case perms&^(mayExec|mayRead) != 0:
+		return "write"
+	case perms&mayRead != 0:
+		return "read"
+	default:
+		return "execute"
+	}
+}
+
+// interfaceOfPath returns the prompted interface the prompt rule
+// hit by an access to the given path comes from.
+func interfaceOfPath(path string) string {
+	if strings.HasPrefix(path, "/dev/video") {
+		return "camera"
+	}
+	return "home"
+}
+
+func notifyIoctl(fd int, req uintptr, msg []byte) (int, error) {
+	n, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(&msg[0])))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// readTimeout bounds how long closing the listener waits for it to
+// notice.
+var readTimeout = 500 * time.Millisecond
+
+// kernelListener receives the notifications of the kernel about the
+// accesses hitting prompt rules.
+type kernelListener struct {
+	fd            int
+	notifications chan *Notification
+	tomb          tomb.Tomb
+}
+
+func newKernelListener() (Listener, error) {
+	if !Supported() {
+		return nil, ErrNotSupported
+	}
+	path := filepath.Join(dirs.GlobalRootDir, notifyPath)
+	fd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %v", path, err)
+	}
+	if _, err := notifyIoctl(fd, ioctlSetFilter, encodeFilter()); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("cannot register as prompt client: %v", err)
+	}
+	l := &kernelListener{
+		fd:            fd,
+		notifications: make(chan *Notification),
+	}
+	l.tomb.Go(l.run)
+	return l, nil
+}
+
+func (l *kernelListener) Notifications() <-chan *Notification {
+	return l.notifications
+}
+
+func (l *kernelListener) Close() error {
+	l.tomb.Kill(nil)
+	return l.tomb.Wait()
+}
+
+// waitReadable waits for a notification to read, up to readTimeout.
+func (l *kernelListener) waitReadable() (bool, error) {
+	var fds syscall.FdSet
+	// the size of the words of the set depends on the architecture
+	bits := int(unsafe.Sizeof(fds.Bits[0])) * 8
+	fds.Bits[l.fd/bits] |= 1 << uint(l.fd%bits)
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	n, err := syscall.Select(l.fd+1, &fds, nil, nil, &tv)
+	if err == syscall.EINTR {
+		return false, nil
+	}
+	return n > 0, err
+}
+
+func (l *kernelListener) run() error {
+	defer close(l.notifications)
+	defer syscall.Close(l.fd)
+
+	order := nativeEndian()
+	buf := make([]byte, maxMsgSize)
+	for {
+		select {
+		case <-l.tomb.Dying():
+			return nil
+		default:
+		}
+		readable, err := l.waitReadable()
+		if err != nil {
+			return fmt.Errorf("cannot wait for apparmor notifications: %v", err)
+		}
+		if !readable {
+			continue
+		}
+		order.PutUint16(buf[0:2], maxMsgSize)
+		order.PutUint16(buf[2:4], notifyProtocolVersion)
+		n, err := notifyIoctl(l.fd, ioctlRecv, buf)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot receive apparmor notification: %v", err)
+		}
+		req, err := parseKernelRequest(buf[:n])
+		if err != nil {
+			logger.Noticef("ignoring apparmor notification: %v", err)
+			continue
+		}
+		if req == nil {
+			continue
+		}
+		notification := &Notification{
+			Label:      req.label,
+			UID:        req.suid,
+			Interface:  interfaceOfPath(req.path),
+			Path:       req.path,
+			Permission: permission(req.deny),
+			Reply:      l.replier(req),
+		}
+		select {
+		case l.notifications <- notification:
+		case <-l.tomb.Dying():
+			notification.Reply(false)
+			return nil
+		}
+	}
+}
+
+func (l *kernelListener) replier(req *kernelRequest) func(allow bool) error {
+	return func(allow bool) error {
+		resp := encodeResponse(req.id, req.deny, allow)
+		if _, err := notifyIoctl(l.fd, ioctlSend, resp); err != nil {
+			return fmt.Errorf("cannot reply to apparmor notification: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package promptstate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/promptstate"
+)
+
+type kernelSuite struct{}
+
+var _ = Suite(&kernelSuite{})
+
+func (s *kernelSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+// fileOpMessage encodes a notification of the kernel about an access to
+// a file, as apparmor sends them.
+func fileOpMessage(ntype uint16, id uint64, deny uint32, label string, uid uint32, path string) []byte {
+	order := promptstate.NativeEndian()
+	const fileSize = 60
+	msg := make([]byte, fileSize, fileSize+len(label)+len(path)+2)
+	msg = append(msg, label...)
+	msg = append(msg, 0)
+	msg = append(msg, path...)
+	msg = append(msg, 0)
+	order.PutUint16(msg[0:2], uint16(len(msg)))
+	order.PutUint16(msg[2:4], 3)
+	order.PutUint16(msg[4:6], ntype)
+	order.PutUint64(msg[8:16], id)
+	order.PutUint32(msg[24:28], deny)
+	order.PutUint32(msg[28:32], 1234)
+	order.PutUint32(msg[32:36], fileSize)
+	order.PutUint16(msg[36:38], 2)
+	order.PutUint32(msg[40:44], uid)
+	order.PutUint32(msg[48:52], uint32(fileSize+len(label)+1))
+	return msg
+}
+
+func (s *kernelSuite) TestParseKernelRequest(c *C) {
+	msg := fileOpMessage(4, 42, 4, "snap.foo.app", 1000, "/home/user/a.txt")
+	req, err := promptstate.ParseKernelRequest(msg)
+	c.Assert(err, IsNil)
+	c.Check(req, DeepEquals, &promptstate.KernelRequest{
+		ID:    42,
+		Deny:  4,
+		Label: "snap.foo.app",
+		UID:   1000,
+		Path:  "/home/user/a.txt",
+	})
+}
+
+func (s *kernelSuite) TestParseKernelRequestNotAsking(c *C) {
+	// cancelled requests need no reply
+	msg := fileOpMessage(1, 42, 4, "snap.foo.app", 1000, "/home/user/a.txt")
+	req, err := promptstate.ParseKernelRequest(msg)
+	c.Assert(err, IsNil)
+	c.Check(req, IsNil)
+}
+
+func (s *kernelSuite) TestParseKernelRequestErrors(c *C) {
+	msg := fileOpMessage(4, 42, 4, "snap.foo.app", 1000, "/home/user/a.txt")
+
+	_, err := promptstate.ParseKernelRequest(msg[:10])
+	c.Check(err, ErrorMatches, `message too short \(10 bytes\)`)
+
+	_, err = promptstate.ParseKernelRequest(msg[:len(msg)-1])
+	c.Check(err, ErrorMatches, `invalid message length .*`)
+
+	bad := append([]byte(nil), msg...)
+	promptstate.NativeEndian().PutUint16(bad[2:4], 2)
+	_, err = promptstate.ParseKernelRequest(bad)
+	c.Check(err, ErrorMatches, `unsupported protocol version 2`)
+
+	bad = append([]byte(nil), msg...)
+	promptstate.NativeEndian().PutUint16(bad[36:38], 7)
+	_, err = promptstate.ParseKernelRequest(bad)
+	c.Check(err, ErrorMatches, `unsupported mediation class 7`)
+
+	bad = append([]byte(nil), msg...)
+	bad[len(bad)-1] = 'x'
+	_, err = promptstate.ParseKernelRequest(bad)
+	c.Check(err, ErrorMatches, `unterminated string at offset .*`)
+}
+
+func (s *kernelSuite) TestEncodeResponse(c *C) {
+	order := promptstate.NativeEndian()
+
+	msg := promptstate.EncodeResponse(42, 6, true)
+	c.Assert(msg, HasLen, 32)
+	c.Check(order.Uint16(msg[0:2]), Equals, uint16(32))
+	c.Check(order.Uint16(msg[2:4]), Equals, uint16(3))
+	c.Check(order.Uint16(msg[4:6]), Equals, uint16(0))
+	c.Check(order.Uint64(msg[8:16]), Equals, uint64(42))
+	c.Check(order.Uint32(msg[20:24]), Equals, uint32(0))
+	c.Check(order.Uint32(msg[24:28]), Equals, uint32(6))
+	c.Check(order.Uint32(msg[28:32]), Equals, uint32(0))
+
+	msg = promptstate.EncodeResponse(42, 6, false)
+	c.Check(int32(order.Uint32(msg[20:24])), Equals, int32(-13))
+	c.Check(order.Uint32(msg[24:28]), Equals, uint32(0))
+	c.Check(order.Uint32(msg[28:32]), Equals, uint32(6))
+}
+
+func (s *kernelSuite) TestPermission(c *C) {
+	c.Check(promptstate.Permission(4), Equals, "read")
+	c.Check(promptstate.Permission(1), Equals, "execute")
+	c.Check(promptstate.Permission(2|4), Equals, "write")
+	c.Check(promptstate.Permission(8), Equals, "write")
+}
+
+func (s *kernelSuite) TestSupported(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Check(promptstate.Supported(), Equals, false)
+
+	notify := filepath.Join(dirs.GlobalRootDir, "/sys/kernel/security/apparmor/.notify")
+	c.Assert(os.MkdirAll(filepath.Dir(notify), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(notify, nil, 0644), IsNil)
+	c.Check(promptstate.Supported(), Equals, false)
+
+	permstable := filepath.Join(dirs.GlobalRootDir, "/sys/kernel/security/apparmor/features/policy/permstable32")
+	c.Assert(os.MkdirAll(filepath.Dir(permstable), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(permstable, []byte("allow deny audit quiet\n"), 0644), IsNil)
+	c.Check(promptstate.Supported(), Equals, false)
+
+	c.Assert(ioutil.WriteFile(permstable, []byte("allow deny prompt audit quiet\n"), 0644), IsNil)
+	c.Check(promptstate.Supported(), Equals, true)
+}
@@ -0,0 +1,516 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+// Package promptstate implements the mediation of the accesses of snaps
+// through selected interfaces at access time: the kernel asks about the
+// accesses, which are turned into prompt requests answered from the
+// desktop session, and the answers to remember are kept as prompt
+// rules.
+package promptstate
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// promptedInterfaces are the interfaces whose accesses can be mediated
+// by prompting.
+var promptedInterfaces = []string{"camera", "home"}
+
+// The outcomes of prompts.
+const (
+	OutcomeAllow = "allow"
+	OutcomeDeny  = "deny"
+)
+
+// The lifespans of the answers to prompts.
+const (
+	// LifespanSingle answers the single access that was asked about.
+	LifespanSingle = "single"
+	// LifespanForever answers the access and records a rule answering
+	// all the matching ones from then on.
+	LifespanForever = "forever"
+)
+
+// Notification is an access of a snap the kernel asks about.
+type Notification struct {
+	// Label is the AppArmor label of the process, such as
+	// snap.foo.app.
+	Label string
+	// UID is the user the process runs as.
+	UID        uint32
+	Interface  string
+	Path       string
+	Permission string
+	// Reply lets the access through or denies it.
+	Reply func(allow bool) error
+}
+
+// Listener receives the notifications of the kernel about the accesses
+// needing a decision.
+type Listener interface {
+	// Notifications returns the channel the notifications are sent
+	// on, closed once the listener is closed.
+	Notifications() <-chan *Notification
+	Close() error
+}
+
+// ErrNotSupported is returned when the kernel cannot ask about accesses.
+var ErrNotSupported = errors.New("apparmor prompting is not supported by the kernel")
+
+// newListener returns the listener of the notifications of the kernel.
+var newListener = newKernelListener
+
+// MockListener makes prompt managers listen to the given listener
+// instead of the kernel. For testing.
+func MockListener(listener Listener) (restore func()) {
+	old := newListener
+	newListener = func() (Listener, error) {
+		return listener, nil
+	}
+	return func() {
+		newListener = old
+	}
+}
+
+// Request is an access waiting for the user to decide about it.
+type Request struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Snap       string    `json:"snap"`
+	App        string    `json:"app"`
+	Interface  string    `json:"interface"`
+	Path       string    `json:"path"`
+	Permission string    `json:"permission"`
+
+	uid   uint32
+	reply func(allow bool) error
+}
+
+// Rule is a remembered answer to the prompts for the accesses of a snap
+// matching it.
+type Rule struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// UID is the user the rule was recorded for.
+	UID       uint32 `json:"uid"`
+	Snap      string `json:"snap"`
+	Interface string `json:"interface"`
+	// PathPattern is a shell pattern as understood by filepath.Match,
+	// which may also end with /** to match everything in a directory.
+	PathPattern string `json:"path-pattern"`
+	// Permission is the permission the rule is about, if not all of
+	// them.
+	Permission string `json:"permission,omitempty"`
+	Outcome    string `json:"outcome"`
+}
+
+func matchPath(pattern, path string) bool {
+	if dir := strings.TrimSuffix(pattern, "/**"); dir != pattern {
+		return path == dir || strings.HasPrefix(path, dir+"/")
+	}
+	matched, err := filepath.Match(pattern, path)
+	return err == nil && matched
+}
+
+func (rule *Rule) matches(uid uint32, snapName, ifaceName, path, permission string) bool {
+	return rule.UID == uid && rule.Snap == snapName && rule.Interface == ifaceName &&
+		(rule.Permission == "" || rule.Permission == permission) && matchPath(rule.PathPattern, path)
+}
+
+// Rules returns the prompt rules of the given user, or of all users for
+// root, about the given snap, or all snaps if snapName is empty.
+// Note that the state must be locked by the caller.
+func Rules(st *state.State, uid uint32, snapName string) ([]*Rule, error) {
+	var rules []*Rule
+	err := st.Get("prompt-rules", &rules)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	filtered := make([]*Rule, 0, len(rules))
+	for _, rule := range rules {
+		if (uid == 0 || rule.UID == uid) && (snapName == "" || rule.Snap == snapName) {
+			filtered = append(filtered, rule)
+		}
+	}
+	return filtered, nil
+}
+
+// ErrNotFound is returned when a prompt request or rule does not exist.
+type ErrNotFound struct {
+	What string
+	ID   string
+}
+
+func (e *ErrNotFound) Error() string {
+	return fmt.Sprintf("cannot find prompt %s %q", e.What, e.ID)
+}
+
+// RemoveRule removes the prompt rule with the given id, which must be
+// one of the user unless they are root.
+// Note that the state must be locked by the caller.
+func RemoveRule(st *state.State, uid uint32, id string) error {
+	rules, err := Rules(st, 0, "")
+	if err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		if rule.ID == id && (uid == 0 || rule.UID == uid) {
+			st.Set("prompt-rules", append(rules[:i], rules[i+1:]...))
+			return nil
+		}
+	}
+	return &ErrNotFound{What: "rule", ID: id}
+}
+
+// findRule returns the outcome of the rules matching the access, deny
+// rules taking precedence, or "" if none match.
+func findRule(rules []*Rule, uid uint32, snapName, ifaceName, path, permission string) string {
+	outcome := ""
+	for _, rule := range rules {
+		if !rule.matches(uid, snapName, ifaceName, path, permission) {
+			continue
+		}
+		if rule.Outcome == OutcomeDeny {
+			return OutcomeDeny
+		}
+		outcome = rule.Outcome
+	}
+	return outcome
+}
+
+// addRule records a rule for the access asked about by the request.
+func addRule(st *state.State, req *Request, outcome, pathPattern string) (*Rule, error) {
+	rules, err := Rules(st, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	var lastID int
+	err = st.Get("last-prompt-rule-id", &lastID)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	lastID++
+	st.Set("last-prompt-rule-id", lastID)
+
+	rule := &Rule{
+		ID:          strconv.Itoa(lastID),
+		Time:        time.Now(),
+		UID:         req.uid,
+		Snap:        req.Snap,
+		Interface:   req.Interface,
+		PathPattern: pathPattern,
+		Permission:  req.Permission,
+		Outcome:     outcome,
+	}
+	st.Set("prompt-rules", append(rules, rule))
+	return rule, nil
+}
+
+// PromptManager listens to the kernel for accesses to decide about and
+// keeps the requests waiting for an answer.
+type PromptManager struct {
+	state *state.State
+
+	mu            sync.Mutex
+	listener      Listener
+	unsupported   bool
+	requests      map[string]*Request
+	lastRequestID int
+	done          chan struct{}
+}
+
+// Manager returns a new PromptManager.
+func Manager(st *state.State) *PromptManager {
+	return &PromptManager{
+		state:    st,
+		requests: make(map[string]*Request),
+	}
+}
+
+// promptingEnabled returns whether the prompting.enabled core option is
+// set.
+func promptingEnabled(st *state.State) (bool, error) {
+	var enabled bool
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "prompting.enabled", &enabled)
+	if err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// Enabled returns whether the accesses of snaps through the prompted
+// interfaces are asked about, that is whether prompting is enabled and
+// supported by the kernel.
+// Note that the state must be locked by the caller.
+func Enabled(st *state.State) (bool, error) {
+	enabled, err := promptingEnabled(st)
+	if err != nil || !enabled {
+		return false, err
+	}
+	return Supported(), nil
+}
+
+// Ensure is part of the overlord.StateManager interface. It starts or
+// stops listening to the kernel as prompting gets enabled or disabled.
+func (m *PromptManager) Ensure() error {
+	m.state.Lock()
+	enabled, err := promptingEnabled(m.state)
+	m.state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	listening := m.listener != nil
+	unsupported := m.unsupported
+	m.mu.Unlock()
+
+	switch {
+	case enabled && !listening && !unsupported:
+		return m.start()
+	case !enabled && listening:
+		m.Stop()
+	}
+	return nil
+}
+
+func (m *PromptManager) start() error {
+	listener, err := newListener()
+	if err == ErrNotSupported {
+		logger.Noticef("cannot enable prompting: %v", err)
+		m.mu.Lock()
+		m.unsupported = true
+		m.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot listen to apparmor prompts: %v", err)
+	}
+	m.mu.Lock()
+	m.listener = listener
+	m.done = make(chan struct{})
+	m.mu.Unlock()
+
+	go m.run(listener, m.done)
+	return nil
+}
+
+func (m *PromptManager) run(listener Listener, done chan struct{}) {
+	defer close(done)
+	for n := range listener.Notifications() {
+		if err := m.handleNotification(n); err != nil {
+			logger.Noticef("cannot handle apparmor prompt for %q: %v", n.Path, err)
+		}
+	}
+}
+
+// parseLabel returns the snap and app of the AppArmor label of a snap
+// application, such as snap.foo.app.
+func parseLabel(label string) (snapName, appName string, err error) {
+	parts := strings.Split(label, ".")
+	if len(parts) != 3 || parts[0] != "snap" {
+		return "", "", fmt.Errorf("unexpected apparmor label %q", label)
+	}
+	return parts[1], parts[2], nil
+}
+
+func (m *PromptManager) handleNotification(n *Notification) error {
+	snapName, appName, err := parseLabel(n.Label)
+	if err != nil {
+		n.Reply(false)
+		return err
+	}
+	if !strutil.ListContains(promptedInterfaces, n.Interface) {
+		n.Reply(false)
+		return fmt.Errorf("interface %q does not support prompting", n.Interface)
+	}
+
+	m.state.Lock()
+	rules, err := Rules(m.state, n.UID, snapName)
+	m.state.Unlock()
+	if err != nil {
+		n.Reply(false)
+		return err
+	}
+	if outcome := findRule(rules, n.UID, snapName, n.Interface, n.Path, n.Permission); outcome != "" {
+		return n.Reply(outcome == OutcomeAllow)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastRequestID++
+	req := &Request{
+		ID:         strconv.Itoa(m.lastRequestID),
+		Time:       time.Now(),
+		Snap:       snapName,
+		App:        appName,
+		Interface:  n.Interface,
+		Path:       n.Path,
+		Permission: n.Permission,
+		uid:        n.UID,
+		reply:      n.Reply,
+	}
+	m.requests[req.ID] = req
+	return nil
+}
+
+// Requests returns the requests waiting for an answer of the given
+// user, or of all users for root, ordered by id.
+func (m *PromptManager) Requests(uid uint32) []*Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := make([]*Request, 0, len(m.requests))
+	for _, req := range m.requests {
+		if uid == 0 || req.uid == uid {
+			requests = append(requests, req)
+		}
+	}
+	sort.Sort(byID(requests))
+	return requests
+}
+
+type byID []*Request
+
+func (ids byID) Len() int      { return len(ids) }
+func (ids byID) Swap(i, j int) { ids[i], ids[j] = ids[j], ids[i] }
+func (ids byID) Less(i, j int) bool {
+	a, _ := strconv.Atoi(ids[i].ID)
+	b, _ := strconv.Atoi(ids[j].ID)
+	return a < b
+}
+
+// Reply is the answer of the user to a prompt request.
+type Reply struct {
+	Outcome  string `json:"outcome"`
+	Lifespan string `json:"lifespan"`
+	// PathPattern is the pattern of the paths the rule recorded for
+	// the forever lifespan is about, by default just the path of the
+	// request.
+	PathPattern string `json:"path-pattern"`
+}
+
+func (reply *Reply) validate() error {
+	if reply.Outcome != OutcomeAllow && reply.Outcome != OutcomeDeny {
+		return fmt.Errorf("invalid prompt outcome %q", reply.Outcome)
+	}
+	switch reply.Lifespan {
+	case "", LifespanSingle:
+		if reply.PathPattern != "" {
+			return fmt.Errorf("cannot use a path pattern without the %q lifespan", LifespanForever)
+		}
+	case LifespanForever:
+		if reply.PathPattern != "" && !filepath.IsAbs(reply.PathPattern) {
+			return fmt.Errorf("invalid path pattern %q: must be absolute", reply.PathPattern)
+		}
+		if _, err := filepath.Match(strings.TrimSuffix(reply.PathPattern, "/**"), ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %v", reply.PathPattern, err)
+		}
+	default:
+		return fmt.Errorf("invalid prompt lifespan %q", reply.Lifespan)
+	}
+	return nil
+}
+
+// Reply answers the request with the given id, which must be one of the
+// user unless they are root. With the forever lifespan a rule is also
+// recorded, answering the other waiting requests it matches too.
+// Note that the state must be locked by the caller.
+func (m *PromptManager) Reply(uid uint32, id string, reply *Reply) (*Rule, error) {
+	if err := reply.validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req := m.requests[id]
+	if req == nil || (uid != 0 && req.uid != uid) {
+		return nil, &ErrNotFound{What: "request", ID: id}
+	}
+
+	var rule *Rule
+	answered := []*Request{req}
+	if reply.Lifespan == LifespanForever {
+		pathPattern := reply.PathPattern
+		if pathPattern == "" {
+			pathPattern = req.Path
+		}
+		if !matchPath(pathPattern, req.Path) {
+			return nil, fmt.Errorf("path pattern %q does not match the path %q of the request", pathPattern, req.Path)
+		}
+		var err error
+		rule, err = addRule(m.state, req, reply.Outcome, pathPattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range m.requests {
+			if other != req && rule.matches(other.uid, other.Snap, other.Interface, other.Path, other.Permission) {
+				answered = append(answered, other)
+			}
+		}
+	}
+
+	for _, req := range answered {
+		delete(m.requests, req.ID)
+		if err := req.reply(reply.Outcome == OutcomeAllow); err != nil {
+			logger.Noticef("cannot reply to apparmor prompt for %q: %v", req.Path, err)
+		}
+	}
+	return rule, nil
+}
+
+// Wait is part of the overlord.StateManager interface.
+func (m *PromptManager) Wait() {}
+
+// Stop is part of the overlord.StateManager interface. It stops
+// listening to the kernel, denying the requests still waiting.
+func (m *PromptManager) Stop() {
+	m.mu.Lock()
+	listener, done := m.listener, m.done
+	m.listener = nil
+	m.mu.Unlock()
+	if listener == nil {
+		return
+	}
+
+	if err := listener.Close(); err != nil {
+		logger.Noticef("cannot stop listening to apparmor prompts: %v", err)
+	}
+	<-done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, req := range m.requests {
+		delete(m.requests, id)
+		req.reply(false)
+	}
+}
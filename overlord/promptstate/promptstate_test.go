@@ -0,0 +1,302 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package promptstate_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/promptstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func TestPromptState(t *testing.T) { TestingT(t) }
+
+type promptStateSuite struct {
+	state   *state.State
+	mgr     *promptstate.PromptManager
+	replies map[string]bool
+}
+
+var _ = Suite(&promptStateSuite{})
+
+func (s *promptStateSuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+	s.mgr = promptstate.Manager(s.state)
+	s.replies = make(map[string]bool)
+}
+
+func (s *promptStateSuite) notification(uid uint32, label, iface, path, permission string) *promptstate.Notification {
+	return &promptstate.Notification{
+		Label:      label,
+		UID:        uid,
+		Interface:  iface,
+		Path:       path,
+		Permission: permission,
+		Reply: func(allow bool) error {
+			s.replies[path] = allow
+			return nil
+		},
+	}
+}
+
+func (s *promptStateSuite) TestMatchPath(c *C) {
+	for _, t := range []struct {
+		pattern, path string
+		matches       bool
+	}{
+		{"/home/foo/a.txt", "/home/foo/a.txt", true},
+		{"/home/foo/a.txt", "/home/foo/b.txt", false},
+		{"/home/foo/*.txt", "/home/foo/b.txt", true},
+		{"/home/foo/*.txt", "/home/foo/bar/b.txt", false},
+		{"/home/foo/**", "/home/foo", true},
+		{"/home/foo/**", "/home/foo/bar/b.txt", true},
+		{"/home/foo/**", "/home/foobar", false},
+	} {
+		c.Check(promptstate.MatchPath(t.pattern, t.path), Equals, t.matches, Commentf("%v", t))
+	}
+}
+
+func (s *promptStateSuite) TestNotificationBecomesRequest(c *C) {
+	err := s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "home", "/home/user/a.txt", "read"))
+	c.Assert(err, IsNil)
+	c.Check(s.replies, HasLen, 0)
+
+	requests := s.mgr.Requests(1000)
+	c.Assert(requests, HasLen, 1)
+	req := requests[0]
+	c.Check(req.ID, Equals, "1")
+	c.Check(req.Snap, Equals, "foo")
+	c.Check(req.App, Equals, "app")
+	c.Check(req.Interface, Equals, "home")
+	c.Check(req.Path, Equals, "/home/user/a.txt")
+	c.Check(req.Permission, Equals, "read")
+
+	// other users don't see it, root does
+	c.Check(s.mgr.Requests(1001), HasLen, 0)
+	c.Check(s.mgr.Requests(0), HasLen, 1)
+}
+
+func (s *promptStateSuite) TestNotificationDeniedWhenUnexpected(c *C) {
+	err := s.mgr.HandleNotification(s.notification(1000, "unconfined", "home", "/a", "read"))
+	c.Check(err, ErrorMatches, `unexpected apparmor label "unconfined"`)
+	err = s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "network", "/b", "read"))
+	c.Check(err, ErrorMatches, `interface "network" does not support prompting`)
+	c.Check(s.replies, DeepEquals, map[string]bool{"/a": false, "/b": false})
+	c.Check(s.mgr.Requests(0), HasLen, 0)
+}
+
+func (s *promptStateSuite) TestReplySingle(c *C) {
+	c.Assert(s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "camera", "/dev/video0", "read")), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// only the user the request is for can reply
+	_, err := s.mgr.Reply(1001, "1", &promptstate.Reply{Outcome: "allow"})
+	c.Check(err, ErrorMatches, `cannot find prompt request "1"`)
+
+	rule, err := s.mgr.Reply(1000, "1", &promptstate.Reply{Outcome: "allow"})
+	c.Assert(err, IsNil)
+	c.Check(rule, IsNil)
+	c.Check(s.replies, DeepEquals, map[string]bool{"/dev/video0": true})
+	c.Check(s.mgr.Requests(0), HasLen, 0)
+
+	rules, err := promptstate.Rules(s.state, 0, "")
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+}
+
+func (s *promptStateSuite) TestReplyForever(c *C) {
+	c.Assert(s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "home", "/home/user/a.txt", "read")), IsNil)
+	c.Assert(s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "home", "/home/user/b.txt", "read")), IsNil)
+	c.Assert(s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "home", "/home/user/c.png", "read")), IsNil)
+
+	s.state.Lock()
+	rule, err := s.mgr.Reply(1000, "1", &promptstate.Reply{
+		Outcome:     "deny",
+		Lifespan:    "forever",
+		PathPattern: "/home/user/*.txt",
+	})
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(rule.ID, Equals, "1")
+	c.Check(rule.UID, Equals, uint32(1000))
+	c.Check(rule.Snap, Equals, "foo")
+	c.Check(rule.Interface, Equals, "home")
+	c.Check(rule.PathPattern, Equals, "/home/user/*.txt")
+	c.Check(rule.Permission, Equals, "read")
+	c.Check(rule.Outcome, Equals, "deny")
+
+	// the waiting request matching the rule was answered too
+	c.Check(s.replies, DeepEquals, map[string]bool{"/home/user/a.txt": false, "/home/user/b.txt": false})
+	requests := s.mgr.Requests(1000)
+	c.Assert(requests, HasLen, 1)
+	c.Check(requests[0].Path, Equals, "/home/user/c.png")
+
+	// as are the later accesses matching it
+	c.Assert(s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "home", "/home/user/d.txt", "read")), IsNil)
+	c.Check(s.replies["/home/user/d.txt"], Equals, false)
+	c.Check(s.mgr.Requests(1000), HasLen, 1)
+
+	// but not those of other users
+	c.Assert(s.mgr.HandleNotification(s.notification(1001, "snap.foo.app", "home", "/home/user/e.txt", "read")), IsNil)
+	c.Check(s.mgr.Requests(1001), HasLen, 1)
+}
+
+func (s *promptStateSuite) TestReplyErrors(c *C) {
+	c.Assert(s.mgr.HandleNotification(s.notification(1000, "snap.foo.app", "home", "/home/user/a.txt", "read")), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	for _, t := range []struct {
+		reply *promptstate.Reply
+		err   string
+	}{
+		{&promptstate.Reply{Outcome: "maybe"}, `invalid prompt outcome "maybe"`},
+		{&promptstate.Reply{Outcome: "allow", Lifespan: "week"}, `invalid prompt lifespan "week"`},
+		{&promptstate.Reply{Outcome: "allow", PathPattern: "/home/**"}, `cannot use a path pattern without the "forever" lifespan`},
+		{&promptstate.Reply{Outcome: "allow", Lifespan: "forever", PathPattern: "home/**"}, `invalid path pattern "home/\*\*": must be absolute`},
+		{&promptstate.Reply{Outcome: "allow", Lifespan: "forever", PathPattern: "/home/[a"}, `invalid path pattern "/home/\[a": syntax error in pattern`},
+		{&promptstate.Reply{Outcome: "allow", Lifespan: "forever", PathPattern: "/tmp/**"}, `path pattern "/tmp/\*\*" does not match the path "/home/user/a.txt" of the request`},
+	} {
+		_, err := s.mgr.Reply(1000, "1", t.reply)
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(s.mgr.Requests(1000), HasLen, 1)
+}
+
+func (s *promptStateSuite) TestRulesAndRemoveRule(c *C) {
+	for i, uid := range []uint32{1000, 1001} {
+		c.Assert(s.mgr.HandleNotification(s.notification(uid, "snap.foo.app", "home", fmt.Sprintf("/home/%d", uid), "read")), IsNil)
+		s.state.Lock()
+		_, err := s.mgr.Reply(uid, fmt.Sprint(i+1), &promptstate.Reply{Outcome: "allow", Lifespan: "forever"})
+		s.state.Unlock()
+		c.Assert(err, IsNil)
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	rules, err := promptstate.Rules(s.state, 1000, "")
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 1)
+	c.Check(rules[0].PathPattern, Equals, "/home/1000")
+	rules, err = promptstate.Rules(s.state, 0, "")
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 2)
+	rules, err = promptstate.Rules(s.state, 0, "bar")
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+
+	// users can only remove their own rules
+	err = promptstate.RemoveRule(s.state, 1000, "2")
+	c.Check(err, ErrorMatches, `cannot find prompt rule "2"`)
+	c.Check(err, FitsTypeOf, &promptstate.ErrNotFound{})
+	c.Assert(promptstate.RemoveRule(s.state, 1000, "1"), IsNil)
+	c.Assert(promptstate.RemoveRule(s.state, 0, "2"), IsNil)
+	rules, err = promptstate.Rules(s.state, 0, "")
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+}
+
+type fakeListener struct {
+	ch     chan *promptstate.Notification
+	closed bool
+}
+
+func (l *fakeListener) Notifications() <-chan *promptstate.Notification {
+	return l.ch
+}
+
+func (l *fakeListener) Close() error {
+	l.closed = true
+	close(l.ch)
+	return nil
+}
+
+func (s *promptStateSuite) setEnabled(c *C, enabled bool) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "prompting.enabled", enabled), IsNil)
+	tr.Commit()
+}
+
+func (s *promptStateSuite) TestEnsureListensWhenEnabled(c *C) {
+	listener := &fakeListener{ch: make(chan *promptstate.Notification)}
+	started := 0
+	restore := promptstate.MockNewListener(func() (promptstate.Listener, error) {
+		started++
+		return listener, nil
+	})
+	defer restore()
+
+	// nothing happens until enabled
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(started, Equals, 0)
+
+	s.setEnabled(c, true)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(started, Equals, 1)
+
+	listener.ch <- s.notification(1000, "snap.foo.app", "camera", "/dev/video0", "read")
+	for i := 0; i < 100 && len(s.mgr.Requests(1000)) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(s.mgr.Requests(1000), HasLen, 1)
+
+	// disabling stops listening and denies what is still waiting
+	s.setEnabled(c, false)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(listener.closed, Equals, true)
+	c.Check(s.mgr.Requests(1000), HasLen, 0)
+	c.Check(s.replies, DeepEquals, map[string]bool{"/dev/video0": false})
+}
+
+func (s *promptStateSuite) TestEnsureNotSupported(c *C) {
+	started := 0
+	restore := promptstate.MockNewListener(func() (promptstate.Listener, error) {
+		started++
+		return nil, promptstate.ErrNotSupported
+	})
+	defer restore()
+
+	s.setEnabled(c, true)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	// not retried
+	c.Check(started, Equals, 1)
+	s.mgr.Stop()
+}
+
+func (s *promptStateSuite) TestEnsureListenerError(c *C) {
+	restore := promptstate.MockNewListener(func() (promptstate.Listener, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	s.setEnabled(c, true)
+	c.Check(s.mgr.Ensure(), ErrorMatches, "cannot listen to apparmor prompts: boom")
+}
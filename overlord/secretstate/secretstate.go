@@ -0,0 +1,292 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package secretstate implements the storage of named secrets, which
+// snaps can be granted access to via the secrets interface, and the
+// sealing of the store authentication data of the users.
+package secretstate
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Backend seals secret values before they are stored in the state and
+// unseals them when they are read back.
+type Backend interface {
+	// Seal returns the sealed form of value.
+	Seal(value []byte) ([]byte, error)
+	// Unseal returns the value that was sealed into sealed.
+	Unseal(sealed []byte) ([]byte, error)
+}
+
+// stateBackend keeps secrets as they are in the state, which is only
+// readable by root.
+type stateBackend struct{}
+
+func (stateBackend) Seal(value []byte) ([]byte, error)    { return value, nil }
+func (stateBackend) Unseal(sealed []byte) ([]byte, error) { return sealed, nil }
+
+// commandBackend seals secrets by running an external command, for
+// example one using the TPM or a key management service. The command
+// is run as "<command> seal" or "<command> unseal" with the data to
+// transform on its standard input, and must print the result on its
+// standard output within commandTimeout.
+type commandBackend struct {
+	command string
+}
+
+var commandTimeout = 30 * time.Second
+
+func (b *commandBackend) run(action string, input []byte) ([]byte, error) {
+	cmd := exec.Command(b.command, action)
+	// in a process group of its own, so that its children are
+	// killed along with it on timeout
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot %s secret with %q: %v", action, b.command, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(commandTimeout):
+		if err := osutil.KillProcessGroup(cmd); err != nil {
+			return nil, fmt.Errorf("cannot %s secret with %q: cannot abort: %v", action, b.command, err)
+		}
+		<-done
+		return nil, fmt.Errorf("cannot %s secret with %q: exceeded maximum runtime of %s", action, b.command, commandTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot %s secret with %q: %v", action, b.command, osutil.OutputErr(stderr.Bytes(), err))
+	}
+	return stdout.Bytes(), nil
+}
+
+func (b *commandBackend) Seal(value []byte) ([]byte, error) {
+	return b.run("seal", value)
+}
+
+func (b *commandBackend) Unseal(sealed []byte) ([]byte, error) {
+	return b.run("unseal", sealed)
+}
+
+type secretState struct {
+	Backend string `json:"backend"`
+	// Command is the command used to seal the secret, for the
+	// "command" backend.
+	Command string `json:"command,omitempty"`
+	Data    []byte `json:"data"`
+}
+
+func getSecrets(st *state.State) (map[string]*secretState, error) {
+	var secrets map[string]*secretState
+	err := st.Get("secrets", &secrets)
+	if err == state.ErrNoState {
+		return make(map[string]*secretState), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func backendFor(secret *secretState) (Backend, error) {
+	switch secret.Backend {
+	case "state":
+		return stateBackend{}, nil
+	case "command":
+		return &commandBackend{command: secret.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown secrets backend %q", secret.Backend)
+}
+
+// newSecret returns a secret using the backend configured via the
+// secrets.backend and secrets.command core options.
+func newSecret(st *state.State) (*secretState, error) {
+	var backend, command string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "secrets.backend", &backend); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if err := tr.Get("core", "secrets.command", &command); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+
+	switch backend {
+	case "", "state":
+		return &secretState{Backend: "state"}, nil
+	case "command":
+		if command == "" {
+			return nil, fmt.Errorf("cannot use secrets backend %q: secrets.command is not set", backend)
+		}
+		return &secretState{Backend: "command", Command: command}, nil
+	}
+	return nil, fmt.Errorf("unknown secrets backend %q", backend)
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+// ValidateName checks that name is a valid secret name.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	return nil
+}
+
+// ErrNotFound is returned when a secret does not exist.
+type ErrNotFound struct {
+	Name string
+}
+
+func (e *ErrNotFound) Error() string {
+	return fmt.Sprintf("cannot find secret %q", e.Name)
+}
+
+// Set stores value as the secret with the given name, sealed with the
+// configured backend.
+// Note that the state must not be locked by the caller, as the backend
+// may run an external command.
+func Set(st *state.State, name string, value []byte) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	st.Lock()
+	secret, err := newSecret(st)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	backend, err := backendFor(secret)
+	if err != nil {
+		return err
+	}
+	secret.Data, err = backend.Seal(value)
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	secrets, err := getSecrets(st)
+	if err != nil {
+		return err
+	}
+	secrets[name] = secret
+	st.Set("secrets", secrets)
+	return nil
+}
+
+// Get returns the value of the secret with the given name.
+// Note that the state must not be locked by the caller, as the backend
+// may run an external command.
+func Get(st *state.State, name string) ([]byte, error) {
+	st.Lock()
+	secrets, err := getSecrets(st)
+	st.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	secret, ok := secrets[name]
+	if !ok {
+		return nil, &ErrNotFound{Name: name}
+	}
+	backend, err := backendFor(secret)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Unseal(secret.Data)
+}
+
+// Remove removes the secret with the given name.
+// Note that the state must be locked by the caller.
+func Remove(st *state.State, name string) error {
+	secrets, err := getSecrets(st)
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return &ErrNotFound{Name: name}
+	}
+	delete(secrets, name)
+	st.Set("secrets", secrets)
+	return nil
+}
+
+// Names returns the sorted names of all the secrets.
+// Note that the state must be locked by the caller.
+func Names(st *state.State) ([]string, error) {
+	secrets, err := getSecrets(st)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SnapCanRead returns whether the given snap was granted access to the
+// named secret, via a connected plug of the secrets interface listing
+// it in its "secrets" attribute.
+// Note that the state must be locked by the caller.
+func SnapCanRead(st *state.State, snapName, name string) (bool, error) {
+	plugs, err := ifacestate.ConnectedPlugs(st, snapName, "secrets")
+	if err != nil || len(plugs) == 0 {
+		return false, err
+	}
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return false, err
+	}
+	for _, plugName := range plugs {
+		plug, ok := info.Plugs[plugName]
+		if !ok || plug.Interface != "secrets" {
+			continue
+		}
+		names, _ := plug.Attrs["secrets"].([]interface{})
+		for _, n := range names {
+			if n == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
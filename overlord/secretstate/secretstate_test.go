@@ -0,0 +1,248 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secretstate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/secretstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func TestSecretState(t *testing.T) { TestingT(t) }
+
+type secretStateSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&secretStateSuite{})
+
+func (s *secretStateSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+}
+
+func (s *secretStateSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *secretStateSuite) TestSetGetRemove(c *C) {
+	err := secretstate.Set(s.state, "db-password", []byte("s3cr3t"))
+	c.Assert(err, IsNil)
+	err = secretstate.Set(s.state, "api-token", []byte("t0k3n"))
+	c.Assert(err, IsNil)
+
+	value, err := secretstate.Get(s.state, "db-password")
+	c.Assert(err, IsNil)
+	c.Check(string(value), Equals, "s3cr3t")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	names, err := secretstate.Names(s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"api-token", "db-password"})
+
+	err = secretstate.Remove(s.state, "db-password")
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+	_, err = secretstate.Get(s.state, "db-password")
+	s.state.Lock()
+	c.Check(err, ErrorMatches, `cannot find secret "db-password"`)
+	c.Check(err, FitsTypeOf, &secretstate.ErrNotFound{})
+
+	err = secretstate.Remove(s.state, "db-password")
+	c.Check(err, ErrorMatches, `cannot find secret "db-password"`)
+}
+
+func (s *secretStateSuite) TestSetInvalidName(c *C) {
+	for _, name := range []string{"", "Foo", "-foo", "foo-", "foo..bar", "foo/bar"} {
+		err := secretstate.Set(s.state, name, []byte("value"))
+		c.Check(err, ErrorMatches, `invalid secret name .*`, Commentf(name))
+	}
+}
+
+func (s *secretStateSuite) TestCommandBackend(c *C) {
+	cmd := testutil.MockCommand(c, "kms-helper", `tr a-z n-za-m`)
+	defer cmd.Restore()
+	s.setCommandBackend(c, "kms-helper")
+
+	err := secretstate.Set(s.state, "db-password", []byte("secret"))
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"kms-helper", "seal"}})
+
+	// the value is stored sealed
+	var stored map[string]map[string]interface{}
+	s.state.Lock()
+	c.Assert(s.state.Get("secrets", &stored), IsNil)
+	s.state.Unlock()
+	c.Check(stored["db-password"]["backend"], Equals, "command")
+	c.Check(stored["db-password"]["command"], Equals, "kms-helper")
+
+	value, err := secretstate.Get(s.state, "db-password")
+	c.Assert(err, IsNil)
+	c.Check(string(value), Equals, "secret")
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"kms-helper", "seal"}, {"kms-helper", "unseal"}})
+}
+
+func (s *secretStateSuite) TestCommandBackendFailure(c *C) {
+	cmd := testutil.MockCommand(c, "kms-helper", `echo "no key" >&2; exit 1`)
+	defer cmd.Restore()
+	s.setCommandBackend(c, "kms-helper")
+
+	err := secretstate.Set(s.state, "db-password", []byte("secret"))
+	c.Check(err, ErrorMatches, `cannot seal secret with "kms-helper": no key`)
+}
+
+func (s *secretStateSuite) TestCommandBackendTimeout(c *C) {
+	restore := secretstate.MockCommandTimeout(100 * time.Millisecond)
+	defer restore()
+	cmd := testutil.MockCommand(c, "kms-helper", `sleep 10`)
+	defer cmd.Restore()
+	s.setCommandBackend(c, "kms-helper")
+
+	err := secretstate.Set(s.state, "db-password", []byte("secret"))
+	c.Check(err, ErrorMatches, `cannot seal secret with "kms-helper": exceeded maximum runtime of 100ms`)
+}
+
+func (s *secretStateSuite) TestCommandBackendRunsUnlocked(c *C) {
+	dir := c.MkDir()
+	cmd := testutil.MockCommand(c, "kms-helper", fmt.Sprintf(`
+touch %[1]s/started
+while [ ! -e %[1]s/go ]; do sleep 0.01; done
+cat`, dir))
+	defer cmd.Restore()
+	s.setCommandBackend(c, "kms-helper")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- secretstate.Set(s.state, "db-password", []byte("secret"))
+	}()
+	for !osutil.FileExists(filepath.Join(dir, "started")) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the state can be locked while the command runs
+	locked := make(chan bool)
+	go func() {
+		s.state.Lock()
+		s.state.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the state is locked while sealing")
+	}
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "go"), nil, 0644), IsNil)
+	c.Assert(<-done, IsNil)
+	value, err := secretstate.Get(s.state, "db-password")
+	c.Assert(err, IsNil)
+	c.Check(string(value), Equals, "secret")
+}
+
+func (s *secretStateSuite) setCommandBackend(c *C, command string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "secrets.backend", "command")
+	tr.Set("core", "secrets.command", command)
+	tr.Commit()
+}
+
+func (s *secretStateSuite) TestBackendConfigErrors(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "secrets.backend", "command")
+	tr.Commit()
+	s.state.Unlock()
+	err := secretstate.Set(s.state, "db-password", []byte("secret"))
+	c.Check(err, ErrorMatches, `cannot use secrets backend "command": secrets.command is not set`)
+
+	s.state.Lock()
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "secrets.backend", "magic")
+	tr.Commit()
+	s.state.Unlock()
+	err = secretstate.Set(s.state, "db-password", []byte("secret"))
+	c.Check(err, ErrorMatches, `unknown secrets backend "magic"`)
+}
+
+const consumerYaml = `name: consumer
+version: 1
+plugs:
+ secrets:
+  secrets: [db-password]
+ other-secrets:
+  interface: secrets
+  secrets: [api-token]
+`
+
+func (s *secretStateSuite) mockConsumer(c *C, conns map[string]interface{}) {
+	si := &snap.SideInfo{RealName: "consumer", Revision: snap.R(1)}
+	snaptest.MockSnap(c, consumerYaml, "", si)
+	snapstate.Set(s.state, "consumer", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	s.state.Set("conns", conns)
+}
+
+func (s *secretStateSuite) TestSnapCanRead(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockConsumer(c, map[string]interface{}{
+		"consumer:secrets core:secrets": map[string]interface{}{"interface": "secrets"},
+	})
+
+	ok, err := secretstate.SnapCanRead(s.state, "consumer", "db-password")
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, true)
+
+	// listed by a plug that is not connected
+	ok, err = secretstate.SnapCanRead(s.state, "consumer", "api-token")
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, false)
+
+	ok, err = secretstate.SnapCanRead(s.state, "consumer", "other")
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, false)
+
+	// snap without connections
+	ok, err = secretstate.SnapCanRead(s.state, "other-snap", "db-password")
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, false)
+}
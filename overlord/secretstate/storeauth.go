@@ -0,0 +1,188 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secretstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// getStoreAuth returns the sealed store auth of the users, keyed by
+// user id.
+func getStoreAuth(st *state.State) (map[string]*secretState, error) {
+	var sealed map[string]*secretState
+	err := st.Get("sealed-store-auth", &sealed)
+	if err == state.ErrNoState {
+		return make(map[string]*secretState), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sealed, nil
+}
+
+func setStoreAuth(st *state.State, sealed map[string]*secretState) {
+	if len(sealed) == 0 {
+		st.Set("sealed-store-auth", nil)
+		return
+	}
+	st.Set("sealed-store-auth", sealed)
+}
+
+// SecretManager keeps the store authentication data of the users sealed
+// with the configured backend when it is not the state itself, and
+// unseals it for use once snapd is started.
+type SecretManager struct {
+	state *state.State
+}
+
+// Manager returns a new SecretManager.
+func Manager(st *state.State) *SecretManager {
+	return &SecretManager{state: st}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *SecretManager) Ensure() error {
+	st := m.state
+	st.Lock()
+	secret, err := newSecret(st)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	users, err := auth.Users(st)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	sealed, err := getStoreAuth(st)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	// forget what was sealed for users that are gone
+	known := make(map[string]bool, len(users))
+	for _, user := range users {
+		known[strconv.Itoa(user.ID)] = true
+	}
+	for id := range sealed {
+		if !known[id] {
+			delete(sealed, id)
+		}
+	}
+	setStoreAuth(st, sealed)
+	st.Unlock()
+
+	// the backends run without the state lock
+	for _, user := range users {
+		var err error
+		switch {
+		case user.StoreAuthSealed && user.StoreMacaroon == "":
+			err = m.unsealStoreAuth(user.ID, sealed[strconv.Itoa(user.ID)])
+		case !user.StoreAuthSealed && user.StoreMacaroon != "" && secret.Backend != "state":
+			err = m.sealStoreAuth(user, secret)
+		}
+		if err != nil {
+			logger.Noticef("cannot handle the store authentication of user %d: %v", user.ID, err)
+		}
+	}
+	return nil
+}
+
+func (m *SecretManager) unsealStoreAuth(userID int, secret *secretState) error {
+	if secret == nil {
+		return fmt.Errorf("sealed data is missing")
+	}
+	backend, err := backendFor(secret)
+	if err != nil {
+		return err
+	}
+	data, err := backend.Unseal(secret.Data)
+	if err != nil {
+		return err
+	}
+	var a auth.StoreAuth
+	if err := json.Unmarshal(data, &a); err != nil {
+		return err
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	auth.SetUnsealedStoreAuth(m.state, userID, &a)
+	return nil
+}
+
+func (m *SecretManager) sealStoreAuth(user *auth.UserState, secret *secretState) error {
+	a := &auth.StoreAuth{Macaroon: user.StoreMacaroon, Discharges: user.StoreDischarges}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	backend, err := backendFor(secret)
+	if err != nil {
+		return err
+	}
+	sealedData, err := backend.Seal(data)
+	if err != nil {
+		return err
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	cur, err := auth.User(m.state, user.ID)
+	if err != nil {
+		// removed meanwhile
+		return nil
+	}
+	if cur.StoreAuthSealed || cur.StoreMacaroon != a.Macaroon || !sameStrings(cur.StoreDischarges, a.Discharges) {
+		// changed meanwhile, to be sealed next time
+		return nil
+	}
+	sealed, err := getStoreAuth(m.state)
+	if err != nil {
+		return err
+	}
+	sealed[strconv.Itoa(user.ID)] = &secretState{Backend: secret.Backend, Command: secret.Command, Data: sealedData}
+	setStoreAuth(m.state, sealed)
+	return auth.SetStoreAuthSealed(m.state, user.ID, a)
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Stop implements StateManager.Stop.
+func (m *SecretManager) Stop() {}
+
+// Wait implements StateManager.Wait.
+func (m *SecretManager) Wait() {}
@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package secretstate_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/secretstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *secretStateSuite) storedUser(c *C, st *state.State) map[string]interface{} {
+	st.Lock()
+	defer st.Unlock()
+	var authState map[string]interface{}
+	c.Assert(st.Get("auth", &authState), IsNil)
+	users := authState["users"].([]interface{})
+	c.Assert(users, HasLen, 1)
+	return users[0].(map[string]interface{})
+}
+
+func (s *secretStateSuite) storeUser(c *C, st *state.State) *auth.UserState {
+	st.Lock()
+	defer st.Unlock()
+	user, err := auth.User(st, 1)
+	c.Assert(err, IsNil)
+	return user
+}
+
+func (s *secretStateSuite) TestEnsureSealsStoreAuth(c *C) {
+	cmd := testutil.MockCommand(c, "kms-helper", `tr a-z n-za-m`)
+	defer cmd.Restore()
+	s.setCommandBackend(c, "kms-helper")
+
+	s.state.Lock()
+	_, err := auth.NewUser(s.state, "username", "email@test.com", "macaroon", []string{"discharge"})
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	mgr := secretstate.Manager(s.state)
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"kms-helper", "seal"}})
+
+	// the store auth is left out of the state
+	stored := s.storedUser(c, s.state)
+	c.Check(stored["store-macaroon"], IsNil)
+	c.Check(stored["store-discharges"], IsNil)
+	c.Check(stored["store-auth-sealed"], Equals, true)
+	var sealed map[string]map[string]interface{}
+	s.state.Lock()
+	c.Assert(s.state.Get("sealed-store-auth", &sealed), IsNil)
+	s.state.Unlock()
+	c.Check(sealed["1"]["backend"], Equals, "command")
+	c.Check(sealed["1"]["command"], Equals, "kms-helper")
+
+	// but still available
+	user := s.storeUser(c, s.state)
+	c.Check(user.StoreMacaroon, Equals, "macaroon")
+	c.Check(user.StoreDischarges, DeepEquals, []string{"discharge"})
+
+	// nothing more to do
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(cmd.Calls(), HasLen, 1)
+
+	// after a restart it is unsealed again
+	s.state.Lock()
+	data, err := json.Marshal(s.state)
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	st, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	user = s.storeUser(c, st)
+	c.Check(user.StoreMacaroon, Equals, "")
+
+	c.Assert(secretstate.Manager(st).Ensure(), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"kms-helper", "seal"}, {"kms-helper", "unseal"}})
+	user = s.storeUser(c, st)
+	c.Check(user.StoreMacaroon, Equals, "macaroon")
+	c.Check(user.StoreDischarges, DeepEquals, []string{"discharge"})
+}
+
+func (s *secretStateSuite) TestEnsureResealsUpdatedStoreAuth(c *C) {
+	cmd := testutil.MockCommand(c, "kms-helper", `tr a-z n-za-m`)
+	defer cmd.Restore()
+	s.setCommandBackend(c, "kms-helper")
+
+	s.state.Lock()
+	_, err := auth.NewUser(s.state, "username", "email@test.com", "macaroon", []string{"discharge"})
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	mgr := secretstate.Manager(s.state)
+	c.Assert(mgr.Ensure(), IsNil)
+
+	// the store refreshes the discharge
+	authContext := auth.NewAuthContext(s.state, nil)
+	_, err = authContext.UpdateUserAuth(s.storeUser(c, s.state), []string{"new-discharge"})
+	c.Assert(err, IsNil)
+
+	// which stays in the state until it is sealed again
+	stored := s.storedUser(c, s.state)
+	c.Check(stored["store-discharges"], DeepEquals, []interface{}{"new-discharge"})
+	c.Check(stored["store-auth-sealed"], IsNil)
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"kms-helper", "seal"}, {"kms-helper", "seal"}})
+	stored = s.storedUser(c, s.state)
+	c.Check(stored["store-discharges"], IsNil)
+	c.Check(stored["store-auth-sealed"], Equals, true)
+	user := s.storeUser(c, s.state)
+	c.Check(user.StoreDischarges, DeepEquals, []string{"new-discharge"})
+}
+
+func (s *secretStateSuite) TestEnsureStateBackendLeavesStoreAuth(c *C) {
+	s.state.Lock()
+	_, err := auth.NewUser(s.state, "username", "email@test.com", "macaroon", []string{"discharge"})
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	c.Assert(secretstate.Manager(s.state).Ensure(), IsNil)
+
+	stored := s.storedUser(c, s.state)
+	c.Check(stored["store-macaroon"], Equals, "macaroon")
+	c.Check(stored["store-auth-sealed"], IsNil)
+}
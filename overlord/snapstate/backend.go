@@ -27,7 +27,7 @@ import (
 
 type managerBackend interface {
 	// install releated
-	SetupSnap(snapFilePath string, si *snap.SideInfo, meter progress.Meter) error
+	SetupSnap(snapFilePath string, si *snap.SideInfo, verity *backend.VerityOptions, meter progress.Meter) error
 	CopySnapData(newSnap, oldSnap *snap.Info, meter progress.Meter) error
 	LinkSnap(info *snap.Info) error
 	StartServices(svcs []*snap.AppInfo, meter progress.Meter) error
@@ -46,6 +46,18 @@ type managerBackend interface {
 	RemoveSnapCommonData(info *snap.Info) error
 	DiscardSnapNamespace(snapName string) error
 
+	// snapshots
+	SaveSnapshot(setID uint64, info *snap.Info) (string, error)
+
+	// cleanup of orphans
+	RemoveOrphanedMountUnit(mountDir string, meter progress.Meter) error
+
+	// component related
+	SetupComponent(compFilePath string, cpi snap.ComponentPlaceInfo, meter progress.Meter) error
+	LinkComponent(cpi snap.ComponentPlaceInfo) error
+	UnlinkComponent(cpi snap.ComponentPlaceInfo) error
+	RemoveComponentFiles(cpi snap.ComponentPlaceInfo, meter progress.Meter) error
+
 	// alias related
 	UpdateAliases(add []*backend.Alias, remove []*backend.Alias) error
 	RemoveSnapAliases(snapName string) error
@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SetupComponent copies the component file in place and mounts it.
+func (b Backend) SetupComponent(compFilePath string, cpi snap.ComponentPlaceInfo, meter progress.Meter) error {
+	// This assumes that the component was already read and checked
+	// against the snap declaring it.
+	compf, err := snap.Open(compFilePath)
+	if err != nil {
+		return err
+	}
+
+	mountDir := cpi.MountDir()
+	if err := os.MkdirAll(mountDir, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cpi.MountFile()), 0755); err != nil {
+		return err
+	}
+
+	if err := compf.Install(cpi.MountFile(), mountDir); err != nil {
+		return err
+	}
+
+	return addMountUnitFor(cpi.SnapName+"+"+cpi.Name, cpi.MountFile(), mountDir, meter)
+}
+
+// LinkComponent makes the given component revision the current one.
+func (b Backend) LinkComponent(cpi snap.ComponentPlaceInfo) error {
+	currentLink := cpi.CurrentLink()
+	if err := os.Remove(currentLink); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(filepath.Base(cpi.MountDir()), currentLink)
+}
+
+// UnlinkComponent makes the component unavailable to the snap.
+func (b Backend) UnlinkComponent(cpi snap.ComponentPlaceInfo) error {
+	if err := os.Remove(cpi.CurrentLink()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RemoveComponentFiles unmounts the given component revision and removes
+// its files from the disk.
+func (b Backend) RemoveComponentFiles(cpi snap.ComponentPlaceInfo, meter progress.Meter) error {
+	mountDir := cpi.MountDir()
+
+	// this also ensures that the mount unit stops
+	if err := removeMountUnit(mountDir, meter); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(mountDir); err != nil {
+		return err
+	}
+
+	// try to remove the parent dirs, failure is ok, means the
+	// component or other components are still in there
+	os.Remove(cpi.BaseDir())
+	os.Remove(snap.ComponentsDir(cpi.SnapName))
+
+	if err := os.RemoveAll(cpi.MountFile()); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type componentSuite struct {
+	be                backend.Backend
+	nullProgress      progress.NullProgress
+	umount            *testutil.MockCmd
+	systemctlRestorer func()
+}
+
+var _ = Suite(&componentSuite{})
+
+func (s *componentSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc", "systemd", "system", "multi-user.target.wants"), 0755)
+	c.Assert(err, IsNil)
+
+	s.systemctlRestorer = systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		return []byte("ActiveState=inactive\n"), nil
+	})
+
+	s.umount = testutil.MockCommand(c, "umount", "")
+}
+
+func (s *componentSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	s.umount.Restore()
+	s.systemctlRestorer()
+}
+
+func makeTestComponentDir(c *C, compYaml string) string {
+	compDir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(compDir, "meta"), 0755), IsNil)
+	err := ioutil.WriteFile(filepath.Join(compDir, "meta", "component.yaml"), []byte(compYaml), 0644)
+	c.Assert(err, IsNil)
+	return compDir
+}
+
+func (s *componentSuite) TestSetupLinkRemoveComponent(c *C) {
+	compPath := makeTestComponentDir(c, "component: hello+debug\ntype: standard\nversion: 1\n")
+	cpi := snap.ComponentPlaceInfo{SnapName: "hello", Name: "debug", Revision: snap.R(-1)}
+
+	err := s.be.SetupComponent(compPath, cpi, &s.nullProgress)
+	c.Assert(err, IsNil)
+
+	c.Check(osutil.IsDirectory(cpi.MountDir()), Equals, true)
+	c.Check(osutil.FileExists(cpi.MountFile()), Equals, true)
+	mountUnit := systemd.MountUnitPath(dirs.StripRootDir(cpi.MountDir()))
+	content, err := ioutil.ReadFile(mountUnit)
+	c.Assert(err, IsNil)
+	c.Check(string(content), testutil.Contains, "Description=Mount unit for hello+debug")
+
+	err = s.be.LinkComponent(cpi)
+	c.Assert(err, IsNil)
+	target, err := os.Readlink(cpi.CurrentLink())
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "x1")
+
+	err = s.be.UnlinkComponent(cpi)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(cpi.CurrentLink()), Equals, false)
+	// unlinking twice is fine
+	c.Check(s.be.UnlinkComponent(cpi), IsNil)
+
+	err = s.be.RemoveComponentFiles(cpi, &s.nullProgress)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(mountUnit), Equals, false)
+	c.Check(osutil.FileExists(cpi.MountFile()), Equals, false)
+	c.Check(osutil.FileExists(snap.ComponentsDir("hello")), Equals, false)
+}
+
+func (s *componentSuite) TestLinkComponentReplacesCurrent(c *C) {
+	old := snap.ComponentPlaceInfo{SnapName: "hello", Name: "debug", Revision: snap.R(-1)}
+	cur := snap.ComponentPlaceInfo{SnapName: "hello", Name: "debug", Revision: snap.R(-2)}
+	for _, cpi := range []snap.ComponentPlaceInfo{old, cur} {
+		compPath := makeTestComponentDir(c, "component: hello+debug\ntype: standard\nversion: 1\n")
+		c.Assert(s.be.SetupComponent(compPath, cpi, &s.nullProgress), IsNil)
+	}
+
+	c.Assert(s.be.LinkComponent(old), IsNil)
+	c.Assert(s.be.LinkComponent(cur), IsNil)
+	target, err := os.Readlink(cur.CurrentLink())
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "x2")
+
+	// removing the old revision leaves the current one alone
+	c.Assert(s.be.RemoveComponentFiles(old, &s.nullProgress), IsNil)
+	c.Check(osutil.IsDirectory(old.MountDir()), Equals, false)
+	c.Check(osutil.IsDirectory(cur.MountDir()), Equals, true)
+}
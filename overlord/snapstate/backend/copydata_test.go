@@ -476,6 +476,63 @@ func (s *copydataSuite) TestCopyDataPartialFailure(c *C) {
 	}
 }
 
+func (s *copydataSuite) TestCopyDataResumesInterruptedCopy(c *C) {
+	v1 := snaptest.MockSnap(c, helloYaml1, helloContents, &snap.SideInfo{Revision: snap.R(10)})
+	s.populateData(c, snap.R(10))
+	v1data := filepath.Join(dirs.SnapDataDir, "hello/10")
+	for name, content := range map[string]string{"done": "done", "partial": "0123456789", "missing": "missing"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(v1data, name), []byte(content), 0644), IsNil)
+	}
+
+	// the copy to revision 20 was interrupted while writing "partial"
+	v2 := snaptest.MockSnap(c, helloYaml2, helloContents, &snap.SideInfo{Revision: snap.R(20)})
+	v2data := filepath.Join(dirs.SnapDataDir, "hello/20")
+	c.Assert(os.MkdirAll(v2data, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(v2data, "done"), []byte("done"), 0644), IsNil)
+	fi, err := os.Stat(filepath.Join(v1data, "done"))
+	c.Assert(err, IsNil)
+	c.Assert(os.Chtimes(filepath.Join(v2data, "done"), fi.ModTime(), fi.ModTime()), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(v2data, "partial"), []byte("01234"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(v2data+".copying", nil, 0644), IsNil)
+
+	cmd := testutil.MockCommand(c, "cp", `exec /bin/cp "$@"`)
+	defer cmd.Restore()
+
+	err = s.be.CopySnapData(v2, v1, &s.nullProgress)
+	c.Assert(err, IsNil)
+
+	// only what was missing or incomplete was copied again
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"cp", "-av", filepath.Join(v1data, "missing"), filepath.Join(v2data, "missing")},
+		{"cp", "-av", filepath.Join(v1data, "partial"), filepath.Join(v2data, "partial")},
+		{"cp", "-av", filepath.Join(v1data, "random-subdir"), filepath.Join(v2data, "random-subdir")},
+	})
+	for name, content := range map[string]string{"done": "done", "partial": "0123456789", "missing": "missing"} {
+		data, err := ioutil.ReadFile(filepath.Join(v2data, name))
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, content)
+	}
+	c.Check(s.populatedData("20"), Equals, "10\n")
+	c.Check(osutil.FileExists(v2data+".copying"), Equals, false)
+	c.Check(osutil.FileExists(v2data+".old"), Equals, false)
+}
+
+func (s *copydataSuite) TestCopyDataMarksCopyInProgress(c *C) {
+	v1 := snaptest.MockSnap(c, helloYaml1, helloContents, &snap.SideInfo{Revision: snap.R(10)})
+	s.populateData(c, snap.R(10))
+	v2 := snaptest.MockSnap(c, helloYaml2, helloContents, &snap.SideInfo{Revision: snap.R(20)})
+	v2data := filepath.Join(dirs.SnapDataDir, "hello/20")
+
+	cmd := testutil.MockCommand(c, "cp", fmt.Sprintf(`test -e %s.copying || exit 1; exec /bin/cp "$@"`, v2data))
+	defer cmd.Restore()
+
+	err := s.be.CopySnapData(v2, v1, &s.nullProgress)
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), HasLen, 1)
+	c.Check(s.populatedData("20"), Equals, "10\n")
+	c.Check(osutil.FileExists(v2data+".copying"), Equals, false)
+}
+
 func (s *copydataSuite) TestCopyDataSameRevision(c *C) {
 	v1 := snaptest.MockSnap(c, helloYaml1, helloContents, &snap.SideInfo{Revision: snap.R(10)})
 
@@ -29,19 +29,47 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/squashfs"
 	"github.com/snapcore/snapd/systemd"
 )
 
-func addMountUnit(s *snap.Info, meter progress.Meter) error {
-	squashfsPath := dirs.StripRootDir(s.MountFile())
-	whereDir := dirs.StripRootDir(s.MountDir())
+// addMountUnit adds the mount unit of the snap, enforcing the dm-verity
+// data of the snap file when rootHash is set.
+func addMountUnit(s *snap.Info, rootHash string, meter progress.Meter) error {
+	if rootHash != "" {
+		return addVerityMountUnitFor(s.Name(), s.MountFile(), s.MountDir(), rootHash, meter)
+	}
+	return addMountUnitFor(s.Name(), s.MountFile(), s.MountDir(), meter)
+}
+
+func addMountUnitFor(name, mountFile, mountDir string, meter progress.Meter) error {
+	squashfsPath := dirs.StripRootDir(mountFile)
+	whereDir := dirs.StripRootDir(mountDir)
 
 	sysd := systemd.New(dirs.GlobalRootDir, meter)
-	mountUnitName, err := sysd.WriteMountUnitFile(s.Name(), squashfsPath, whereDir, "squashfs")
+	mountUnitName, err := sysd.WriteMountUnitFile(name, squashfsPath, whereDir, "squashfs")
 	if err != nil {
 		return err
 	}
 
+	return startMountUnit(sysd, mountUnitName)
+}
+
+func addVerityMountUnitFor(name, mountFile, mountDir, rootHash string, meter progress.Meter) error {
+	squashfsPath := dirs.StripRootDir(mountFile)
+	hashFile := dirs.StripRootDir(squashfs.VerityHashFile(mountFile))
+	whereDir := dirs.StripRootDir(mountDir)
+
+	sysd := systemd.New(dirs.GlobalRootDir, meter)
+	mountUnitName, err := sysd.WriteVerityMountUnitFile(name, squashfsPath, whereDir, hashFile, rootHash)
+	if err != nil {
+		return err
+	}
+
+	return startMountUnit(sysd, mountUnitName)
+}
+
+func startMountUnit(sysd systemd.Systemd, mountUnitName string) error {
 	// we need to do a daemon-reload here to ensure that systemd really
 	// knows about this new mount unit file
 	if err := sysd.DaemonReload(); err != nil {
@@ -91,3 +119,10 @@ func removeMountUnit(baseDir string, meter progress.Meter) error {
 
 	return nil
 }
+
+// RemoveOrphanedMountUnit stops, disables and removes the mount unit
+// for the given mount directory of a snap revision that is no longer
+// known to the system.
+func (b Backend) RemoveOrphanedMountUnit(mountDir string, meter progress.Meter) error {
+	return removeMountUnit(mountDir, meter)
+}
@@ -72,7 +72,7 @@ func (s *mountunitSuite) TestAddMountUnit(c *C) {
 		Version:       "1.1",
 		Architectures: []string{"all"},
 	}
-	err := backend.AddMountUnit(info, &s.nullProgress)
+	err := backend.AddMountUnit(info, "", &s.nullProgress)
 	c.Assert(err, IsNil)
 
 	// ensure correct mount unit
@@ -94,6 +94,35 @@ WantedBy=multi-user.target
 
 }
 
+func (s *mountunitSuite) TestAddVerityMountUnit(c *C) {
+	info := &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(13),
+		},
+		Version:       "1.1",
+		Architectures: []string{"all"},
+	}
+	err := backend.AddMountUnit(info, "abcd", &s.nullProgress)
+	c.Assert(err, IsNil)
+
+	un := fmt.Sprintf("%s.mount", systemd.EscapeUnitNamePath(filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "foo", "13")))
+	mount, err := ioutil.ReadFile(filepath.Join(dirs.SnapServicesDir, un))
+	c.Assert(err, IsNil)
+	c.Assert(string(mount), Equals, fmt.Sprintf(`[Unit]
+Description=Mount unit for foo
+
+[Mount]
+What=/var/lib/snapd/snaps/foo_13.snap
+Where=%s/foo/13
+Type=squashfs
+Options=nodev,ro,verity.hashdevice=/var/lib/snapd/snaps/foo_13.snap.verity,verity.roothash=abcd
+
+[Install]
+WantedBy=multi-user.target
+`, dirs.StripRootDir(dirs.SnapMountDir)))
+}
+
 func (s *mountunitSuite) TestRemoveMountUnit(c *C) {
 	info := &snap.Info{
 		SideInfo: snap.SideInfo{
@@ -104,7 +133,7 @@ func (s *mountunitSuite) TestRemoveMountUnit(c *C) {
 		Architectures: []string{"all"},
 	}
 
-	err := backend.AddMountUnit(info, &s.nullProgress)
+	err := backend.AddMountUnit(info, "", &s.nullProgress)
 	c.Assert(err, IsNil)
 
 	// ensure we have the files
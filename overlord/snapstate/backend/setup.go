@@ -27,10 +27,22 @@ import (
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/squashfs"
 )
 
+// VerityOptions asks for the snap to be mounted with dm-verity enforcement.
+type VerityOptions struct {
+	// RootHash is the root hash asserted for the snap file, hex
+	// encoded; the computed one is trusted when it is empty.
+	RootHash string
+	// Salt is the salt to compute the hash tree with, hex encoded.
+	Salt string
+}
+
 // SetupSnap does prepare and mount the snap for further processing.
-func (b Backend) SetupSnap(snapFilePath string, sideInfo *snap.SideInfo, meter progress.Meter) error {
+// With verity set the dm-verity data of the snap file is computed and
+// the snap is mounted with it enforced.
+func (b Backend) SetupSnap(snapFilePath string, sideInfo *snap.SideInfo, verity *VerityOptions, meter progress.Meter) error {
 	// This assumes that the snap was already verified or --dangerous was used.
 
 	s, snapf, err := OpenSnapFile(snapFilePath, sideInfo)
@@ -47,8 +59,16 @@ func (b Backend) SetupSnap(snapFilePath string, sideInfo *snap.SideInfo, meter p
 		return err
 	}
 
+	rootHash := ""
+	if verity != nil {
+		rootHash, err = setupVerity(s.MountFile(), verity)
+		if err != nil {
+			return err
+		}
+	}
+
 	// generate the mount unit for the squashfs
-	if err := addMountUnit(s, meter); err != nil {
+	if err := addMountUnit(s, rootHash, meter); err != nil {
 		return err
 	}
 
@@ -61,6 +81,18 @@ func (b Backend) SetupSnap(snapFilePath string, sideInfo *snap.SideInfo, meter p
 	return err
 }
 
+func setupVerity(snapPath string, verity *VerityOptions) (rootHash string, err error) {
+	rootHash, err = squashfs.FormatVerity(snapPath, verity.Salt)
+	if err != nil {
+		return "", err
+	}
+	if verity.RootHash != "" && rootHash != verity.RootHash {
+		os.Remove(squashfs.VerityHashFile(snapPath))
+		return "", fmt.Errorf("cannot mount %q with dm-verity enforcement: root hash %s does not match the asserted %s", snapPath, rootHash, verity.RootHash)
+	}
+	return rootHash, nil
+}
+
 // RemoveSnapFiles removes the snap files from the disk after unmounting the snap.
 func (b Backend) RemoveSnapFiles(s snap.PlaceInfo, typ snap.Type, meter progress.Meter) error {
 	mountDir := s.MountDir()
@@ -92,6 +124,9 @@ func (b Backend) RemoveSnapFiles(s snap.PlaceInfo, typ snap.Type, meter progress
 		if err := os.RemoveAll(snapPath); err != nil {
 			return err
 		}
+		if err := os.Remove(squashfs.VerityHashFile(snapPath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
 	}
 
 	return nil
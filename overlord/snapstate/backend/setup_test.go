@@ -76,7 +76,7 @@ func (s *setupSuite) TestSetupDoUndoSimple(c *C) {
 		Revision: snap.R(14),
 	}
 
-	err := s.be.SetupSnap(snapPath, &si, &s.nullProgress)
+	err := s.be.SetupSnap(snapPath, &si, nil, &s.nullProgress)
 	c.Assert(err, IsNil)
 
 	// after setup the snap file is in the right dir
@@ -105,6 +105,65 @@ func (s *setupSuite) TestSetupDoUndoSimple(c *C) {
 
 }
 
+func (s *setupSuite) TestSetupDoUndoVerity(c *C) {
+	veritysetup := testutil.MockCommand(c, "veritysetup", `
+touch "$4"
+echo "Root hash:      	abcd"
+`)
+	defer veritysetup.Restore()
+
+	snapPath := makeTestSnap(c, helloYaml1)
+
+	si := snap.SideInfo{
+		RealName: "hello",
+		Revision: snap.R(14),
+	}
+
+	err := s.be.SetupSnap(snapPath, &si, &backend.VerityOptions{RootHash: "abcd", Salt: "ef01"}, &s.nullProgress)
+	c.Assert(err, IsNil)
+
+	snapFile := filepath.Join(dirs.SnapBlobDir, "hello_14.snap")
+	c.Check(veritysetup.Calls(), DeepEquals, [][]string{
+		{"veritysetup", "format", "--salt=ef01", snapFile, snapFile + ".verity"},
+	})
+	c.Assert(osutil.FileExists(snapFile+".verity"), Equals, true)
+
+	mup := systemd.MountUnitPath(filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "hello/14"))
+	content, err := ioutil.ReadFile(mup)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Matches, "(?ms).*^Options=nodev,ro,verity.hashdevice=/var/lib/snapd/snaps/hello_14.snap.verity,verity.roothash=abcd$.*")
+
+	minInfo := snap.MinimalPlaceInfo("hello", snap.R(14))
+	err = s.be.UndoSetupSnap(minInfo, "app", &s.nullProgress)
+	c.Assert(err, IsNil)
+
+	c.Assert(osutil.FileExists(snapFile), Equals, false)
+	c.Assert(osutil.FileExists(snapFile+".verity"), Equals, false)
+}
+
+func (s *setupSuite) TestSetupVerityRootHashMismatch(c *C) {
+	veritysetup := testutil.MockCommand(c, "veritysetup", `
+touch "$3"
+echo "Root hash:      	abcd"
+`)
+	defer veritysetup.Restore()
+
+	snapPath := makeTestSnap(c, helloYaml1)
+
+	si := snap.SideInfo{
+		RealName: "hello",
+		Revision: snap.R(14),
+	}
+
+	err := s.be.SetupSnap(snapPath, &si, &backend.VerityOptions{RootHash: "1234"}, &s.nullProgress)
+	c.Assert(err, ErrorMatches, `cannot mount ".*/hello_14.snap" with dm-verity enforcement: root hash abcd does not match the asserted 1234`)
+
+	snapFile := filepath.Join(dirs.SnapBlobDir, "hello_14.snap")
+	c.Check(osutil.FileExists(snapFile+".verity"), Equals, false)
+	l, _ := filepath.Glob(filepath.Join(dirs.SnapServicesDir, "*.mount"))
+	c.Check(l, HasLen, 0)
+}
+
 func (s *setupSuite) TestSetupDoUndoKernelUboot(c *C) {
 	bootloader := boottest.NewMockBootloader("mock", c.MkDir())
 	partition.ForceBootloader(bootloader)
@@ -129,7 +188,7 @@ type: kernel
 		Revision: snap.R(140),
 	}
 
-	err := s.be.SetupSnap(snapPath, &si, &s.nullProgress)
+	err := s.be.SetupSnap(snapPath, &si, nil, &s.nullProgress)
 	c.Assert(err, IsNil)
 	l, _ := filepath.Glob(filepath.Join(bootloader.Dir(), "*"))
 	c.Assert(l, HasLen, 1)
@@ -173,11 +232,11 @@ type: kernel
 		Revision: snap.R(140),
 	}
 
-	err := s.be.SetupSnap(snapPath, &si, &s.nullProgress)
+	err := s.be.SetupSnap(snapPath, &si, nil, &s.nullProgress)
 	c.Assert(err, IsNil)
 
 	// retry run
-	err = s.be.SetupSnap(snapPath, &si, &s.nullProgress)
+	err = s.be.SetupSnap(snapPath, &si, nil, &s.nullProgress)
 	c.Assert(err, IsNil)
 
 	minInfo := snap.MinimalPlaceInfo("kernel", snap.R(140))
@@ -222,7 +281,7 @@ type: kernel
 		Revision: snap.R(140),
 	}
 
-	err := s.be.SetupSnap(snapPath, &si, &s.nullProgress)
+	err := s.be.SetupSnap(snapPath, &si, nil, &s.nullProgress)
 	c.Assert(err, IsNil)
 
 	minInfo := snap.MinimalPlaceInfo("kernel", snap.R(140))
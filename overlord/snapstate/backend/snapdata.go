@@ -22,6 +22,7 @@ package backend
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	unix "syscall"
@@ -38,6 +39,12 @@ func (b Backend) RemoveSnapData(snap *snap.Info) error {
 	if err != nil {
 		return err
 	}
+	// drop the marks of copies interrupted for good
+	for _, dir := range dirs {
+		if err := os.Remove(copyingPath(dir)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 
 	return removeDirs(dirs)
 }
@@ -211,16 +218,38 @@ func clearTrash(path string) error {
 	return nil
 }
 
+// copyingPath returns the path of the file marking that path is being
+// copied, which tells an interrupted copy from data to keep.
+func copyingPath(path string) string {
+	return path + ".copying"
+}
+
 // Lowlevel copy the snap data (but never override existing data)
 func copySnapDataDirectory(oldPath, newPath string) (err error) {
 	if _, err := os.Stat(oldPath); err == nil {
-		if err := trash(newPath); err != nil {
-			return err
+		marker := copyingPath(newPath)
+		resume := osutil.FileExists(marker) && osutil.IsDirectory(newPath)
+		if !resume {
+			if err := trash(newPath); err != nil {
+				return err
+			}
 		}
 
-		if _, err := os.Stat(newPath); err != nil {
-			if err := osutil.CopyFile(oldPath, newPath, osutil.CopyFlagPreserveAll|osutil.CopyFlagSync); err != nil {
-				msg := fmt.Sprintf("cannot copy %q to %q: %v", oldPath, newPath, err)
+		if _, err := os.Stat(newPath); err != nil || resume {
+			var copyErr error
+			if resume {
+				// the copy was interrupted, e.g. by a power
+				// cut, only copy what is still missing
+				logger.Noticef("Resuming the copy of %q to %q", oldPath, newPath)
+				copyErr = resumeCopy(oldPath, newPath)
+			} else {
+				copyErr = ioutil.WriteFile(marker, nil, 0644)
+				if copyErr == nil {
+					copyErr = osutil.CopyFile(oldPath, newPath, osutil.CopyFlagPreserveAll|osutil.CopyFlagSync)
+				}
+			}
+			if copyErr != nil {
+				msg := fmt.Sprintf("cannot copy %q to %q: %v", oldPath, newPath, copyErr)
 				// remove the directory, in case it was a partial success
 				if e := os.RemoveAll(newPath); e != nil && !os.IsNotExist(e) {
 					msg += fmt.Sprintf("; and when trying to remove the partially-copied new data directory: %v", e)
@@ -233,8 +262,12 @@ func copySnapDataDirectory(oldPath, newPath string) (err error) {
 					msg += fmt.Sprintf("; and when trying to restore the old data directory: %v", e)
 				}
 
+				os.Remove(marker)
 				return errors.New(msg)
 			}
+			if err := os.Remove(marker); err != nil {
+				return err
+			}
 		}
 	} else if !os.IsNotExist(err) {
 		return err
@@ -242,3 +275,60 @@ func copySnapDataDirectory(oldPath, newPath string) (err error) {
 
 	return nil
 }
+
+// resumeCopy completes an interrupted copy of oldPath to newPath,
+// copying again only the entries that are missing from newPath or
+// differ from those of oldPath, as a partially written file does in
+// size or modification time.
+func resumeCopy(oldPath, newPath string) error {
+	err := filepath.Walk(oldPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(oldPath, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(newPath, rel)
+		targetInfo, err := os.Lstat(target)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		case info.IsDir() && targetInfo.IsDir():
+			// look for what is missing inside
+			return nil
+		case sameCopy(info, targetInfo):
+			return nil
+		default:
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+		if err := osutil.CopyFile(path, target, osutil.CopyFlagPreserveAll); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// copied with all it holds
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	unix.Sync()
+	return nil
+}
+
+// sameCopy returns whether the entry described by copied looks like a
+// complete copy of the one described by orig.
+func sameCopy(orig, copied os.FileInfo) bool {
+	if orig.Mode() != copied.Mode() {
+		return false
+	}
+	if orig.Mode().IsRegular() {
+		return orig.Size() == copied.Size() && orig.ModTime().Equal(copied.ModTime())
+	}
+	return true
+}
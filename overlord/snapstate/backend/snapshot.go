@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SnapshotPath returns the path of the archive holding the data of the
+// given snap revision in the snapshot set with the given id.
+func SnapshotPath(setID uint64, info *snap.Info) string {
+	return filepath.Join(dirs.SnapshotsDir, fmt.Sprintf("%d_%s_%s.tar.gz", setID, info.Name(), info.Revision))
+}
+
+// SaveSnapshot archives the data of the given snap revision, both the
+// system and the users' one, revisioned and common, as part of the
+// snapshot set with the given id. It returns the path of the archive,
+// in which files are stored relative to the root directory.
+func (b Backend) SaveSnapshot(setID uint64, info *snap.Info) (path string, err error) {
+	dataDirs, err := snapDataDirs(info)
+	if err != nil {
+		return "", err
+	}
+	commonDirs, err := filepath.Glob(info.CommonDataHomeDir())
+	if err != nil {
+		return "", err
+	}
+	dataDirs = append(dataDirs, commonDirs...)
+	dataDirs = append(dataDirs, info.CommonDataDir())
+
+	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
+		return "", err
+	}
+	path = SnapshotPath(setID, info)
+	f, err := ioutil.TempFile(dirs.SnapshotsDir, filepath.Base(path)+".")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, dir := range dataDirs {
+		if !osutil.IsDirectory(dir) {
+			continue
+		}
+		if err := addToArchive(tw, dir); err != nil {
+			return "", fmt.Errorf("cannot archive %q: %v", dir, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// addToArchive adds the tree rooted at dir to the archive.
+func addToArchive(tw *tar.Writer, dir string) error {
+	rootDir := dirs.GlobalRootDir
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		var target string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, target)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootDir, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() && !strings.HasSuffix(hdr.Name, "/") {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+}
@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+)
+
+type snapshotSuite struct {
+	be      backend.Backend
+	tempdir string
+}
+
+var _ = Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+}
+
+func (s *snapshotSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func readArchive(c *C, path string) map[string]string {
+	f, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	c.Assert(err, IsNil)
+	tr := tar.NewReader(gz)
+
+	content := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		data, err := ioutil.ReadAll(tr)
+		c.Assert(err, IsNil)
+		content[hdr.Name] = string(data)
+	}
+	return content
+}
+
+func (s *snapshotSuite) TestSaveSnapshot(c *C) {
+	info := snaptest.MockSnap(c, helloYaml1, helloContents, &snap.SideInfo{Revision: snap.R(10)})
+	for _, dir := range []string{
+		info.DataDir(),
+		info.CommonDataDir(),
+		filepath.Join(s.tempdir, "home/user1/snap/hello/10"),
+	} {
+		c.Assert(os.MkdirAll(dir, 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, "canary"), []byte(dir), 0644), IsNil)
+	}
+
+	path, err := s.be.SaveSnapshot(42, info)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, filepath.Join(dirs.SnapshotsDir, "42_hello_10.tar.gz"))
+	c.Check(backend.SnapshotPath(42, info), Equals, path)
+
+	c.Check(readArchive(c, path), DeepEquals, map[string]string{
+		"home/user1/snap/hello/10/":       "",
+		"home/user1/snap/hello/10/canary": filepath.Join(s.tempdir, "home/user1/snap/hello/10"),
+		"var/snap/hello/10/":              "",
+		"var/snap/hello/10/canary":        info.DataDir(),
+		"var/snap/hello/common/":          "",
+		"var/snap/hello/common/canary":    info.CommonDataDir(),
+	})
+
+	// nothing else is left behind
+	files, err := ioutil.ReadDir(dirs.SnapshotsDir)
+	c.Assert(err, IsNil)
+	c.Check(files, HasLen, 1)
+}
+
+func (s *snapshotSuite) TestSaveSnapshotNoData(c *C) {
+	info := snaptest.MockSnap(c, helloYaml1, helloContents, &snap.SideInfo{Revision: snap.R(10)})
+
+	path, err := s.be.SaveSnapshot(1, info)
+	c.Assert(err, IsNil)
+	c.Check(readArchive(c, path), HasLen, 0)
+}
@@ -47,11 +47,14 @@ type fakeOp struct {
 	sinfo   snap.SideInfo
 	stype   snap.Type
 	cand    store.RefreshCandidate
+	storeID string
 
 	old string
 
 	aliases   []*backend.Alias
 	rmAliases []*backend.Alias
+
+	verity *backend.VerityOptions
 }
 
 type fakeOps []fakeOp
@@ -86,8 +89,10 @@ func (ops fakeOps) First(op string) *fakeOp {
 }
 
 type fakeDownload struct {
-	name     string
-	macaroon string
+	name      string
+	macaroon  string
+	rateLimit int64
+	partial   *store.PartialDownload
 }
 
 type fakeStore struct {
@@ -98,6 +103,10 @@ type fakeStore struct {
 	fakeCurrentProgress int
 	fakeTotalProgress   int
 	state               *state.State
+
+	// downloadInterrupted makes downloads save it as their
+	// progress and fail
+	downloadInterrupted *store.PartialDownload
 }
 
 func (f *fakeStore) pokeStateLock() {
@@ -145,7 +154,7 @@ func (f *fakeStore) SnapInfo(spec store.SnapSpec, user *auth.UserState) (*snap.I
 		Confinement: confinement,
 		Type:        typ,
 	}
-	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-snap", name: spec.Name, revno: spec.Revision})
+	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-snap", name: spec.Name, revno: spec.Revision, storeID: spec.StoreID})
 
 	return info, nil
 }
@@ -178,6 +187,7 @@ func (f *fakeStore) LookupRefresh(cand *store.RefreshCandidate, user *auth.UserS
 
 	revno := snap.R(11)
 	confinement := snap.StrictConfinement
+	channel := cand.Channel
 	switch cand.Channel {
 	case "channel-for-7":
 		revno = snap.R(7)
@@ -185,12 +195,15 @@ func (f *fakeStore) LookupRefresh(cand *store.RefreshCandidate, user *auth.UserS
 		confinement = snap.ClassicConfinement
 	case "channel-for-devmode":
 		confinement = snap.DevModeConfinement
+	case "stable/closed-branch":
+		// the store falls back to the channel the branch was on
+		channel = "stable"
 	}
 
 	info := &snap.Info{
 		SideInfo: snap.SideInfo{
 			RealName: name,
-			Channel:  cand.Channel,
+			Channel:  channel,
 			SnapID:   cand.SnapID,
 			Revision: revno,
 		},
@@ -201,6 +214,9 @@ func (f *fakeStore) LookupRefresh(cand *store.RefreshCandidate, user *auth.UserS
 		Confinement:   confinement,
 		Architectures: []string{"all"},
 	}
+	if cand.Channel == "channel-for-epoch-1" {
+		info.Epoch = "1"
+	}
 
 	var hit snap.Revision
 	if cand.Revision != revno {
@@ -251,22 +267,35 @@ func (f *fakeStore) SuggestedCurrency() string {
 	return "XTS"
 }
 
-func (f *fakeStore) Download(ctx context.Context, name, targetFn string, snapInfo *snap.DownloadInfo, pb progress.Meter, user *auth.UserState) error {
+func (f *fakeStore) Download(ctx context.Context, name, targetFn string, snapInfo *snap.DownloadInfo, pb progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
 	f.pokeStateLock()
 
 	var macaroon string
 	if user != nil {
 		macaroon = user.StoreMacaroon
 	}
+	var rateLimit int64
+	var partial *store.PartialDownload
+	if dlOpts != nil {
+		rateLimit = dlOpts.RateLimit
+		partial = dlOpts.Partial
+	}
 	f.downloads = append(f.downloads, fakeDownload{
-		macaroon: macaroon,
-		name:     name,
+		macaroon:  macaroon,
+		name:      name,
+		rateLimit: rateLimit,
+		partial:   partial,
 	})
 	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-download", name: name})
 
 	pb.SetTotal(float64(f.fakeTotalProgress))
 	pb.Set(float64(f.fakeCurrentProgress))
 
+	if f.downloadInterrupted != nil {
+		dlOpts.SavePartial(f.downloadInterrupted)
+		return fmt.Errorf("download interrupted")
+	}
+
 	return nil
 }
 
@@ -309,16 +338,17 @@ func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo)
 	return &snap.Info{Architectures: []string{"all"}}, nil, nil
 }
 
-func (f *fakeSnappyBackend) SetupSnap(snapFilePath string, si *snap.SideInfo, p progress.Meter) error {
+func (f *fakeSnappyBackend) SetupSnap(snapFilePath string, si *snap.SideInfo, verity *backend.VerityOptions, p progress.Meter) error {
 	p.Notify("setup-snap")
 	revno := snap.R(0)
 	if si != nil {
 		revno = si.Revision
 	}
 	f.ops = append(f.ops, fakeOp{
-		op:    "setup-snap",
-		name:  snapFilePath,
-		revno: revno,
+		op:     "setup-snap",
+		name:   snapFilePath,
+		revno:  revno,
+		verity: verity,
 	})
 	return nil
 }
@@ -507,6 +537,14 @@ func (f *fakeSnappyBackend) RemoveSnapData(info *snap.Info) error {
 	return nil
 }
 
+func (f *fakeSnappyBackend) SaveSnapshot(setID uint64, info *snap.Info) (string, error) {
+	f.ops = append(f.ops, fakeOp{
+		op:   "save-snapshot",
+		name: info.MountDir(),
+	})
+	return backend.SnapshotPath(setID, info), nil
+}
+
 func (f *fakeSnappyBackend) RemoveSnapCommonData(info *snap.Info) error {
 	f.ops = append(f.ops, fakeOp{
 		op:   "remove-snap-common-data",
@@ -523,6 +561,47 @@ func (f *fakeSnappyBackend) DiscardSnapNamespace(snapName string) error {
 	return nil
 }
 
+func (f *fakeSnappyBackend) RemoveOrphanedMountUnit(mountDir string, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "remove-orphaned-mount-unit",
+		name: mountDir,
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) SetupComponent(compFilePath string, cpi snap.ComponentPlaceInfo, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "setup-component",
+		name: cpi.MountDir(),
+		old:  compFilePath,
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) LinkComponent(cpi snap.ComponentPlaceInfo) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "link-component",
+		name: cpi.MountDir(),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) UnlinkComponent(cpi snap.ComponentPlaceInfo) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "unlink-component",
+		name: cpi.MountDir(),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) RemoveComponentFiles(cpi snap.ComponentPlaceInfo, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:   "remove-component-files",
+		name: cpi.MountDir(),
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) Candidate(sideInfo *snap.SideInfo) {
 	var sinfo snap.SideInfo
 	if sideInfo != nil {
@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// The policies for when the branch a snap is tracking gets closed in
+// the store, which then offers revisions from the channel the branch
+// was based on instead.
+const (
+	// BranchExpiryNotify refreshes the snap to what the store offers
+	// but keeps tracking the branch, noting that it is closed. This
+	// is the default.
+	BranchExpiryNotify = "notify"
+	// BranchExpiryFallBack refreshes the snap to what the store
+	// offers and makes it track the channel the branch fell back to.
+	BranchExpiryFallBack = "fall-back"
+	// BranchExpiryStay keeps the snap on its current revision, and
+	// tracking the branch, until the branch is open again.
+	BranchExpiryStay = "stay"
+)
+
+var branchExpiryPolicies = []string{BranchExpiryNotify, BranchExpiryFallBack, BranchExpiryStay}
+
+var channelRisks = []string{"stable", "candidate", "beta", "edge"}
+
+// channelBranch returns the branch of the given [<track>/]<risk>[/<branch>]
+// channel, if any.
+func channelBranch(channel string) string {
+	parts := strings.Split(channel, "/")
+	switch {
+	case len(parts) == 3:
+		return parts[2]
+	case len(parts) == 2 && strutil.ListContains(channelRisks, parts[0]):
+		return parts[1]
+	}
+	return ""
+}
+
+// TrackingClosedBranch returns whether the snap is tracking a branch
+// that was found to be closed in the store.
+func (snapst *SnapState) TrackingClosedBranch() bool {
+	return snapst.ClosedBranch != "" && snapst.ClosedBranch == snapst.Channel
+}
+
+// SetBranchExpiry sets the policy for when the branch the snap is
+// tracking gets closed, one of "notify", "fall-back" or "stay". The
+// empty policy resets it to the default.
+// Note that the state must be locked by the caller.
+func SetBranchExpiry(st *state.State, name, policy string) error {
+	if policy != "" && !strutil.ListContains(branchExpiryPolicies, policy) {
+		return fmt.Errorf("invalid branch expiry policy %q, must be one of %s", policy, strings.Join(branchExpiryPolicies, ", "))
+	}
+	var snapst SnapState
+	err := Get(st, name, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !snapst.IsInstalled() {
+		return fmt.Errorf("cannot find snap %q", name)
+	}
+	snapst.BranchExpiry = policy
+	Set(st, name, &snapst)
+	return nil
+}
+
+// setClosedBranch records the branch as closed for the snap, or that
+// it is not closed anymore if branch is empty, both in snapst and in
+// the state. Only that field is touched in the state so that other
+// changes to it since snapst was read are still detected.
+func setClosedBranch(st *state.State, name string, snapst *SnapState, branch string) error {
+	snapst.ClosedBranch = branch
+	var cursnapst SnapState
+	if err := Get(st, name, &cursnapst); err != nil {
+		return err
+	}
+	if cursnapst.ClosedBranch == branch {
+		return nil
+	}
+	cursnapst.ClosedBranch = branch
+	Set(st, name, &cursnapst)
+	return nil
+}
+
+// checkBranchExpiry applies the branch expiry policy of the snap if
+// the update offered by the store does not come from the branch that
+// is being tracked, meaning the branch was closed. It returns the
+// channel to track from now on, or an error if the snap should not be
+// refreshed.
+func checkBranchExpiry(st *state.State, update *snap.Info, channel string, snapst *SnapState) (string, error) {
+	if channelBranch(channel) == "" || update.Channel == "" {
+		return channel, nil
+	}
+	name := update.Name()
+	if update.Channel == channel {
+		// the branch is open (again)
+		return channel, setClosedBranch(st, name, snapst, "")
+	}
+	if err := setClosedBranch(st, name, snapst, channel); err != nil {
+		return "", err
+	}
+
+	switch snapst.BranchExpiry {
+	case BranchExpiryStay:
+		return "", fmt.Errorf("branch %q is closed, staying on the current revision", channel)
+	case BranchExpiryFallBack:
+		logger.Noticef("Branch %q of snap %q is closed, falling back to %q", channel, name, update.Channel)
+		return update.Channel, nil
+	default:
+		logger.Noticef("Branch %q of snap %q is closed, refreshing from %q", channel, name, update.Channel)
+		return channel, nil
+	}
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) TestChannelBranch(c *C) {
+	for _, t := range []struct {
+		channel, branch string
+	}{
+		{"", ""},
+		{"stable", ""},
+		{"1.0/stable", ""},
+		{"some-channel", ""},
+		{"stable/hotfix", "hotfix"},
+		{"edge/fix-1234", "fix-1234"},
+		{"1.0/beta/hotfix", "hotfix"},
+	} {
+		c.Check(snapstate.ChannelBranch(t.channel), Equals, t.branch, Commentf(t.channel))
+	}
+}
+
+func (s *snapmgrTestSuite) setupBranchSnap(c *C, channel, policy string) {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:       true,
+		Channel:      channel,
+		BranchExpiry: policy,
+		Sequence:     []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:      snap.R(7),
+		SnapType:     "app",
+	})
+}
+
+func (s *snapmgrTestSuite) checkClosedBranch(c *C, closed string) {
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.ClosedBranch, Equals, closed)
+	c.Check(snapst.TrackingClosedBranch(), Equals, closed != "")
+}
+
+func (s *snapmgrTestSuite) TestUpdateClosedBranchNotifyByDefault(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupBranchSnap(c, "stable/closed-branch", "")
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(ts.Tasks()[0].Get("snap-setup", &snapsup), IsNil)
+	// still tracking the branch
+	c.Check(snapsup.Channel, Equals, "stable/closed-branch")
+	c.Check(snapsup.SideInfo.Channel, Equals, "stable")
+
+	s.checkClosedBranch(c, "stable/closed-branch")
+}
+
+func (s *snapmgrTestSuite) TestUpdateClosedBranchFallBack(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupBranchSnap(c, "stable/closed-branch", "fall-back")
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(ts.Tasks()[0].Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.Channel, Equals, "stable")
+
+	s.checkClosedBranch(c, "stable/closed-branch")
+}
+
+func (s *snapmgrTestSuite) TestUpdateClosedBranchStay(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupBranchSnap(c, "stable/closed-branch", "stay")
+
+	_, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot refresh snap "some-snap": branch "stable/closed-branch" is closed, staying on the current revision`)
+	c.Check(s.state.TaskCount(), Equals, 0)
+
+	s.checkClosedBranch(c, "stable/closed-branch")
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyClosedBranchStay(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupBranchSnap(c, "stable/closed-branch", "stay")
+
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+	c.Check(tts, HasLen, 0)
+
+	s.checkClosedBranch(c, "stable/closed-branch")
+}
+
+func (s *snapmgrTestSuite) TestUpdateOpenBranchClearsClosed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupBranchSnap(c, "stable/open-branch", "stay")
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	snapst.ClosedBranch = "stable/open-branch"
+	snapstate.Set(s.state, "some-snap", &snapst)
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(ts.Tasks()[0].Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.Channel, Equals, "stable/open-branch")
+
+	s.checkClosedBranch(c, "")
+}
+
+func (s *snapmgrTestSuite) TestTrackingClosedBranchOnlyForTrackedChannel(c *C) {
+	snapst := &snapstate.SnapState{
+		Channel:      "stable",
+		ClosedBranch: "stable/closed-branch",
+	}
+	c.Check(snapst.TrackingClosedBranch(), Equals, false)
+	snapst.Channel = "stable/closed-branch"
+	c.Check(snapst.TrackingClosedBranch(), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestSetBranchExpiry(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupBranchSnap(c, "stable/hotfix", "")
+
+	for _, policy := range []string{"stay", "fall-back", "notify", ""} {
+		c.Assert(snapstate.SetBranchExpiry(s.state, "some-snap", policy), IsNil)
+		var snapst snapstate.SnapState
+		c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+		c.Check(snapst.BranchExpiry, Equals, policy)
+	}
+
+	err := snapstate.SetBranchExpiry(s.state, "some-snap", "potato")
+	c.Check(err, ErrorMatches, `invalid branch expiry policy "potato", must be one of notify, fall-back, stay`)
+
+	err = snapstate.SetBranchExpiry(s.state, "other-snap", "stay")
+	c.Check(err, ErrorMatches, `cannot find snap "other-snap"`)
+}
@@ -169,7 +169,12 @@ func validateInfoAndFlags(info *snap.Info, snapst *SnapState, flags Flags) error
 
 	// verify we have a valid architecture
 	if !arch.IsSupportedArchitecture(info.Architectures) {
-		return fmt.Errorf("snap %q supported architectures (%s) are incompatible with this system (%s)", info.Name(), strings.Join(info.Architectures, ", "), arch.UbuntuArchitecture())
+		if !flags.ForeignArch {
+			return fmt.Errorf("snap %q supported architectures (%s) are incompatible with this system (%s)", info.Name(), strings.Join(info.Architectures, ", "), arch.UbuntuArchitecture())
+		}
+		if err := checkEmulator(info); err != nil {
+			return err
+		}
 	}
 
 	// check assumes
@@ -180,6 +185,20 @@ func validateInfoAndFlags(info *snap.Info, snapst *SnapState, flags Flags) error
 	return nil
 }
 
+// checkEmulator checks that the binaries of a snap of a foreign
+// architecture can be run under emulation.
+func checkEmulator(info *snap.Info) error {
+	var errs []string
+	for _, a := range info.Architectures {
+		_, err := arch.Emulator(a)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return fmt.Errorf("cannot run snap %q (%s) under emulation: %s", info.Name(), strings.Join(info.Architectures, ", "), strings.Join(errs, "; "))
+}
+
 var openSnapFile = backend.OpenSnapFile
 
 // checkSnap ensures that the snap can be installed.
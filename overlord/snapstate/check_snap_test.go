@@ -22,6 +22,8 @@ package snapstate_test
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 
 	. "gopkg.in/check.v1"
 
@@ -75,6 +77,43 @@ architectures:
 	c.Assert(err.Error(), Equals, errorMsg)
 }
 
+func (s *checkSnapSuite) TestCheckSnapForeignArch(c *C) {
+	const yaml = `name: hello
+version: 1.10
+architectures:
+    - yadayada
+    - armhf
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yaml))
+	c.Assert(err, IsNil)
+
+	var openSnapFile = func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+		return info, nil, nil
+	}
+	restore := snapstate.MockOpenSnapFile(openSnapFile)
+	defer restore()
+
+	oldArch := arch.UbuntuArchitecture()
+	defer arch.SetArchitecture(arch.ArchitectureType(oldArch))
+	arch.SetArchitecture("amd64")
+
+	binfmtDir := c.MkDir()
+	restore = arch.MockBinfmtMiscDir(binfmtDir)
+	defer restore()
+
+	err = snapstate.CheckSnap(s.st, "snap-path", nil, nil, snapstate.Flags{ForeignArch: true})
+	c.Assert(err, ErrorMatches, `cannot run snap "hello" \(yadayada, armhf\) under emulation: cannot emulate unknown architecture "yadayada"; no emulator for armhf binaries is registered with binfmt_misc`)
+
+	err = ioutil.WriteFile(filepath.Join(binfmtDir, "qemu-arm"), []byte("enabled\ninterpreter /usr/bin/qemu-arm-static\nflags: OCF\n"), 0644)
+	c.Assert(err, IsNil)
+	err = snapstate.CheckSnap(s.st, "snap-path", nil, nil, snapstate.Flags{ForeignArch: true})
+	c.Assert(err, IsNil)
+
+	// the flag is still needed
+	err = snapstate.CheckSnap(s.st, "snap-path", nil, nil, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `snap "hello" supported architectures \(yadayada, armhf\) are incompatible with this system \(amd64\)`)
+}
+
 var assumesTests = []struct {
 	version string
 	assumes string
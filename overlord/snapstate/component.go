@@ -0,0 +1,468 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+/* components
+
+Components are optional parts of a snap, like debug symbols, extra
+locales or large models, that are declared in the components section
+of its snap.yaml and shipped in their own files. They are installed,
+refreshed and removed independently of the snap using these tasks:
+
+  * mount-component: copies the component file in place and mounts it
+  * link-component: makes the mounted revision the current one, under
+    /snap/<snap>/components/<component>/current; the revision it
+    replaces is discarded once the change is done and cannot be undone
+  * unlink-component: makes the component unavailable to the snap
+  * discard-component: unmounts a revision and removes its files
+
+The components of a snap are discarded with its last revision.
+*/
+
+// ComponentSetup holds the necessary data to operate on a component.
+type ComponentSetup struct {
+	SnapName string             `json:"snap-name"`
+	Name     string             `json:"name"`
+	Type     snap.ComponentType `json:"type"`
+	Revision snap.Revision      `json:"revision"`
+
+	ComponentPath string `json:"component-path,omitempty"`
+	// RemoveComponentPath flags that the component file is temporary
+	// and is removed once the component is mounted
+	RemoveComponentPath bool `json:"remove-component-path,omitempty"`
+}
+
+// ComponentFlags are used to pass additional flags to operations on
+// components.
+type ComponentFlags struct {
+	// RemoveComponentPath flags that the file passed in is temporary
+	// and should be removed once the component is mounted.
+	RemoveComponentPath bool
+}
+
+func (compsup *ComponentSetup) placeInfo() snap.ComponentPlaceInfo {
+	return snap.ComponentPlaceInfo{
+		SnapName: compsup.SnapName,
+		Name:     compsup.Name,
+		Revision: compsup.Revision,
+	}
+}
+
+// ComponentState holds the state of an installed component.
+type ComponentState struct {
+	Type     snap.ComponentType `json:"type"`
+	Revision snap.Revision      `json:"revision"`
+}
+
+// TaskComponentSetup returns the ComponentSetup held by or referred to
+// by the task.
+func TaskComponentSetup(t *state.Task) (*ComponentSetup, error) {
+	var compsup ComponentSetup
+
+	err := t.Get("component-setup", &compsup)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if err == nil {
+		return &compsup, nil
+	}
+
+	var id string
+	err = t.Get("component-setup-task", &id)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := t.State().Task(id)
+	if err := ts.Get("component-setup", &compsup); err != nil {
+		return nil, err
+	}
+	return &compsup, nil
+}
+
+var readComponentInfo = func(compPath string) (*snap.ComponentInfo, error) {
+	compf, err := snap.Open(compPath)
+	if err != nil {
+		return nil, err
+	}
+	return snap.ReadComponentInfoFromContainer(compf)
+}
+
+func componentSnapSetup(snapName string, snapst *SnapState) *SnapSetup {
+	return &SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: snapName,
+			Revision: snapst.Current,
+		},
+	}
+}
+
+// InstallComponentPath returns a set of tasks for installing, or
+// refreshing, a component of an installed snap from a file path.
+// Note that the state must be locked by the caller.
+func InstallComponentPath(st *state.State, path string, flags ComponentFlags) (*state.TaskSet, error) {
+	ci, err := readComponentInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read component %q: %v", path, err)
+	}
+	return installComponentPath(st, ci, path, flags)
+}
+
+// RefreshComponentPath returns a set of tasks for refreshing an
+// installed component of a snap from a file path.
+// Note that the state must be locked by the caller.
+func RefreshComponentPath(st *state.State, path string, flags ComponentFlags) (*state.TaskSet, error) {
+	ci, err := readComponentInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read component %q: %v", path, err)
+	}
+
+	var snapst SnapState
+	err = Get(st, ci.SnapName, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if snapst.Components[ci.Name] == nil {
+		return nil, fmt.Errorf("cannot refresh component %q: it is not installed", ci.FullName())
+	}
+	return installComponentPath(st, ci, path, flags)
+}
+
+func installComponentPath(st *state.State, ci *snap.ComponentInfo, path string, flags ComponentFlags) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, ci.SnapName, &snapst)
+	if err == state.ErrNoState {
+		return nil, fmt.Errorf("cannot install component %q: snap %q is not installed", ci.FullName(), ci.SnapName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	comp := info.Components[ci.Name]
+	if comp == nil {
+		return nil, fmt.Errorf("cannot install component %q: snap %q does not declare it", ci.FullName(), ci.SnapName)
+	}
+	if comp.Type != ci.Type {
+		return nil, fmt.Errorf("cannot install component %q: type %q does not match the %q type declared by the snap", ci.FullName(), ci.Type, comp.Type)
+	}
+
+	if err := CheckChangeConflict(st, ci.SnapName, nil, nil); err != nil {
+		return nil, err
+	}
+
+	revision := snap.R(-1)
+	old := snapst.Components[ci.Name]
+	if old != nil && old.Revision.Local() {
+		revision = snap.R(old.Revision.N - 1)
+	}
+
+	compsup := &ComponentSetup{
+		SnapName:      ci.SnapName,
+		Name:          ci.Name,
+		Type:          ci.Type,
+		Revision:      revision,
+		ComponentPath: path,
+
+		RemoveComponentPath: flags.RemoveComponentPath,
+	}
+
+	mount := st.NewTask("mount-component", fmt.Sprintf(i18n.G("Mount component %q (%s)"), ci.FullName(), revision))
+	mount.Set("snap-setup", componentSnapSetup(ci.SnapName, &snapst))
+	mount.Set("component-setup", compsup)
+
+	link := st.NewTask("link-component", fmt.Sprintf(i18n.G("Make component %q (%s) available to the snap"), ci.FullName(), revision))
+	link.Set("snap-setup-task", mount.ID())
+	link.Set("component-setup-task", mount.ID())
+	link.WaitFor(mount)
+
+	return state.NewTaskSet(mount, link), nil
+}
+
+// RemoveComponent returns a set of tasks for removing the given
+// component of a snap.
+// Note that the state must be locked by the caller.
+func RemoveComponent(st *state.State, snapName, compName string) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, snapName, &snapst)
+	if err == state.ErrNoState {
+		return nil, fmt.Errorf("cannot find snap %q", snapName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cur := snapst.Components[compName]
+	if cur == nil {
+		return nil, fmt.Errorf("component %q of snap %q is not installed", compName, snapName)
+	}
+
+	if err := CheckChangeConflict(st, snapName, nil, nil); err != nil {
+		return nil, err
+	}
+
+	fullName := snapName + "+" + compName
+	unlink := st.NewTask("unlink-component", fmt.Sprintf(i18n.G("Make component %q (%s) unavailable to the snap"), fullName, cur.Revision))
+	unlink.Set("snap-setup", componentSnapSetup(snapName, &snapst))
+	unlink.Set("component-setup", &ComponentSetup{
+		SnapName: snapName,
+		Name:     compName,
+		Type:     cur.Type,
+		Revision: cur.Revision,
+	})
+
+	discard := st.NewTask("discard-component", fmt.Sprintf(i18n.G("Remove component %q (%s)"), fullName, cur.Revision))
+	discard.Set("snap-setup-task", unlink.ID())
+	discard.Set("component-setup-task", unlink.ID())
+	discard.WaitFor(unlink)
+
+	return state.NewTaskSet(unlink, discard), nil
+}
+
+func componentSetupAndState(t *state.Task) (*ComponentSetup, *SnapState, error) {
+	compsup, err := TaskComponentSetup(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	var snapst SnapState
+	if err := Get(t.State(), compsup.SnapName, &snapst); err != nil {
+		return nil, nil, err
+	}
+	return compsup, &snapst, nil
+}
+
+func (m *SnapManager) doMountComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	compsup, err := TaskComponentSetup(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pb := NewTaskProgressAdapterUnlocked(t)
+	if err := m.backend.SetupComponent(compsup.ComponentPath, compsup.placeInfo(), pb); err != nil {
+		return err
+	}
+
+	if compsup.RemoveComponentPath {
+		if err := os.Remove(compsup.ComponentPath); err != nil {
+			logger.Noticef("Failed to cleanup %s: %s", compsup.ComponentPath, err)
+		}
+	}
+	return nil
+}
+
+func (m *SnapManager) undoMountComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	compsup, err := TaskComponentSetup(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pb := NewTaskProgressAdapterUnlocked(t)
+	return m.backend.RemoveComponentFiles(compsup.placeInfo(), pb)
+}
+
+func (m *SnapManager) doLinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapst, err := componentSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	// remember the revision being replaced, if any, for undo
+	t.Set("old-component", snapst.Components[compsup.Name])
+
+	if err := m.backend.LinkComponent(compsup.placeInfo()); err != nil {
+		return err
+	}
+
+	if snapst.Components == nil {
+		snapst.Components = make(map[string]*ComponentState)
+	}
+	snapst.Components[compsup.Name] = &ComponentState{
+		Type:     compsup.Type,
+		Revision: compsup.Revision,
+	}
+	Set(st, compsup.SnapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) undoLinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapst, err := componentSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	var old *ComponentState
+	if err := t.Get("old-component", &old); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	if old != nil {
+		cpi := compsup.placeInfo()
+		cpi.Revision = old.Revision
+		if err := m.backend.LinkComponent(cpi); err != nil {
+			return err
+		}
+		snapst.Components[compsup.Name] = old
+	} else {
+		if err := m.backend.UnlinkComponent(compsup.placeInfo()); err != nil {
+			return err
+		}
+		delete(snapst.Components, compsup.Name)
+	}
+	Set(st, compsup.SnapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) cleanupLinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if t.Status() != state.DoneStatus {
+		// it failed or was undone, the old revision is current again
+		return nil
+	}
+
+	compsup, err := TaskComponentSetup(t)
+	if err != nil {
+		return err
+	}
+
+	var old *ComponentState
+	if err := t.Get("old-component", &old); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if old == nil || old.Revision == compsup.Revision {
+		return nil
+	}
+
+	cpi := compsup.placeInfo()
+	cpi.Revision = old.Revision
+	pb := NewTaskProgressAdapterLocked(t)
+	return m.backend.RemoveComponentFiles(cpi, pb)
+}
+
+func (m *SnapManager) doUnlinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapst, err := componentSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	if err := m.backend.UnlinkComponent(compsup.placeInfo()); err != nil {
+		return err
+	}
+
+	delete(snapst.Components, compsup.Name)
+	Set(st, compsup.SnapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) undoUnlinkComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, snapst, err := componentSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	if err := m.backend.LinkComponent(compsup.placeInfo()); err != nil {
+		return err
+	}
+
+	if snapst.Components == nil {
+		snapst.Components = make(map[string]*ComponentState)
+	}
+	snapst.Components[compsup.Name] = &ComponentState{
+		Type:     compsup.Type,
+		Revision: compsup.Revision,
+	}
+	Set(st, compsup.SnapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) doDiscardComponent(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	compsup, err := TaskComponentSetup(t)
+	if err != nil {
+		return err
+	}
+
+	pb := NewTaskProgressAdapterLocked(t)
+	if err := m.backend.RemoveComponentFiles(compsup.placeInfo(), pb); err != nil {
+		t.Errorf("cannot remove component file %q, will retry in 3 mins: %s", compsup.SnapName+"+"+compsup.Name, err)
+		return &state.Retry{After: 3 * time.Minute}
+	}
+	return nil
+}
+
+// discardComponents removes the files of all the components of a snap
+// whose last revision is being discarded.
+func (m *SnapManager) discardComponents(snapName string, snapst *SnapState, pb progress.Meter) error {
+	for name, comp := range snapst.Components {
+		cpi := snap.ComponentPlaceInfo{SnapName: snapName, Name: name, Revision: comp.Revision}
+		if err := m.backend.UnlinkComponent(cpi); err != nil {
+			return err
+		}
+		if err := m.backend.RemoveComponentFiles(cpi, pb); err != nil {
+			return err
+		}
+	}
+	snapst.Components = nil
+	return nil
+}
@@ -0,0 +1,383 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// mockComponents makes the installed snaps declare the given standard
+// components and the component files be components of some-snap.
+func (s *snapmgrTestSuite) mockComponents(c *C, components ...string) (restore func()) {
+	restoreReadInfo := snapstate.MockReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err != nil {
+			return nil, err
+		}
+		info.Components = make(map[string]*snap.Component)
+		for _, comp := range components {
+			info.Components[comp] = &snap.Component{Snap: info, Name: comp, Type: snap.StandardComponent}
+		}
+		return info, nil
+	})
+	restoreReadComponentInfo := snapstate.MockReadComponentInfo(func(compPath string) (*snap.ComponentInfo, error) {
+		if compPath == "broken.comp" {
+			return nil, errors.New("boom")
+		}
+		snapName, compName := snap.SplitSnapComponent(filepath.Base(compPath))
+		return &snap.ComponentInfo{
+			SnapName: snapName,
+			Name:     compName,
+			Type:     snap.StandardComponent,
+			Version:  "1.0",
+		}, nil
+	})
+	return func() {
+		restoreReadInfo()
+		restoreReadComponentInfo()
+	}
+}
+
+func (s *snapmgrTestSuite) setSomeSnapWithComponents(components map[string]*snapstate.ComponentState) {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:     true,
+		Sequence:   []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(7)}},
+		Current:    snap.R(7),
+		SnapType:   "app",
+		Components: components,
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathRunThrough(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(nil)
+
+	chg := s.state.NewChange("install-component", "install a component")
+	ts, err := snapstate.InstallComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:   "setup-component",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1"),
+			old:  "/path/to/some-snap+debug",
+		},
+		{
+			op:   "link-component",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1"),
+		},
+	})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[string]*snapstate.ComponentState{
+		"debug": {Type: snap.StandardComponent, Revision: snap.R(-1)},
+	})
+	// the snap itself is untouched
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Check(snapst.Active, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathRefresh(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(map[string]*snapstate.ComponentState{
+		"debug": {Type: snap.StandardComponent, Revision: snap.R(-1)},
+	})
+
+	chg := s.state.NewChange("install-component", "refresh a component")
+	ts, err := snapstate.InstallComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"setup-component", "link-component", "remove-component-files"})
+	c.Check(s.fakeBackend.ops[1].name, Equals, filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x2"))
+	c.Check(s.fakeBackend.ops[2].name, Equals, filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1"))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components["debug"].Revision, Equals, snap.R(-2))
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathUndo(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(map[string]*snapstate.ComponentState{
+		"debug": {Type: snap.StandardComponent, Revision: snap.R(-1)},
+	})
+
+	chg := s.state.NewChange("install-component", "refresh a component")
+	ts, err := snapstate.InstallComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	link := ts.Tasks()[1]
+	c.Assert(link.Kind(), Equals, "link-component")
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(link)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), NotNil)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:   "setup-component",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x2"),
+			old:  "/path/to/some-snap+debug",
+		},
+		{
+			op:   "link-component",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x2"),
+		},
+		{
+			op:   "link-component",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1"),
+		},
+		{
+			op:   "remove-component-files",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x2"),
+		},
+	})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[string]*snapstate.ComponentState{
+		"debug": {Type: snap.StandardComponent, Revision: snap.R(-1)},
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathErrors(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.InstallComponentPath(s.state, "broken.comp", snapstate.ComponentFlags{})
+	c.Check(err, ErrorMatches, `cannot read component "broken.comp": boom`)
+
+	_, err = snapstate.InstallComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Check(err, ErrorMatches, `cannot install component "some-snap\+debug": snap "some-snap" is not installed`)
+
+	s.setSomeSnapWithComponents(nil)
+
+	_, err = snapstate.InstallComponentPath(s.state, "/path/to/some-snap+locales", snapstate.ComponentFlags{})
+	c.Check(err, ErrorMatches, `cannot install component "some-snap\+locales": snap "some-snap" does not declare it`)
+
+	chg := s.state.NewChange("refresh", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "some-snap"}})
+	chg.AddTask(t)
+
+	_, err = snapstate.InstallComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Check(err, ErrorMatches, `snap "some-snap" has changes in progress`)
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathRemovesTemporaryFile(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+
+	compPath := filepath.Join(c.MkDir(), "some-snap+debug")
+	c.Assert(ioutil.WriteFile(compPath, nil, 0644), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(nil)
+
+	chg := s.state.NewChange("install-component", "install a component")
+	ts, err := snapstate.InstallComponentPath(s.state, compPath, snapstate.ComponentFlags{RemoveComponentPath: true})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(osutil.FileExists(compPath), Equals, false)
+}
+
+func (s *snapmgrTestSuite) TestRefreshComponentPath(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(nil)
+
+	_, err := snapstate.RefreshComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Check(err, ErrorMatches, `cannot refresh component "some-snap\+debug": it is not installed`)
+
+	s.setSomeSnapWithComponents(map[string]*snapstate.ComponentState{
+		"debug": {Type: snap.StandardComponent, Revision: snap.R(-1)},
+	})
+
+	ts, err := snapstate.RefreshComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{"mount-component", "link-component"})
+}
+
+func (s *snapmgrTestSuite) TestInstallComponentPathTypeMismatch(c *C) {
+	restore := s.mockComponents(c, "debug")
+	defer restore()
+	restore = snapstate.MockReadComponentInfo(func(compPath string) (*snap.ComponentInfo, error) {
+		return &snap.ComponentInfo{SnapName: "some-snap", Name: "debug", Type: "other", Version: "1.0"}, nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(nil)
+
+	_, err := snapstate.InstallComponentPath(s.state, "/path/to/some-snap+debug", snapstate.ComponentFlags{})
+	c.Check(err, ErrorMatches, `cannot install component "some-snap\+debug": type "other" does not match the "standard" type declared by the snap`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveComponentRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(map[string]*snapstate.ComponentState{
+		"debug":   {Type: snap.StandardComponent, Revision: snap.R(-1)},
+		"locales": {Type: snap.StandardComponent, Revision: snap.R(-3)},
+	})
+
+	chg := s.state.NewChange("remove-component", "remove a component")
+	ts, err := snapstate.RemoveComponent(s.state, "some-snap", "debug")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:   "unlink-component",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1"),
+		},
+		{
+			op:   "remove-component-files",
+			name: filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1"),
+		},
+	})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Components, DeepEquals, map[string]*snapstate.ComponentState{
+		"locales": {Type: snap.StandardComponent, Revision: snap.R(-3)},
+	})
+}
+
+func (s *snapmgrTestSuite) TestRemoveComponentErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.RemoveComponent(s.state, "some-snap", "debug")
+	c.Check(err, ErrorMatches, `cannot find snap "some-snap"`)
+
+	s.setSomeSnapWithComponents(nil)
+	_, err = snapstate.RemoveComponent(s.state, "some-snap", "debug")
+	c.Check(err, ErrorMatches, `component "debug" of snap "some-snap" is not installed`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveSnapDiscardsComponents(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapWithComponents(map[string]*snapstate.ComponentState{
+		"debug": {Type: snap.StandardComponent, Revision: snap.R(-1)},
+	})
+
+	chg := s.state.NewChange("remove", "remove a snap")
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	var unlinked, removed bool
+	for _, op := range s.fakeBackend.ops {
+		if op.name != filepath.Join(dirs.SnapMountDir, "some-snap/components/debug/x1") {
+			continue
+		}
+		switch op.op {
+		case "unlink-component":
+			unlinked = true
+		case "remove-component-files":
+			removed = true
+		}
+	}
+	c.Check(unlinked, Equals, true)
+	c.Check(removed, Equals, true)
+
+	var snapst snapstate.SnapState
+	c.Check(snapstate.Get(s.state, "some-snap", &snapst), Equals, state.ErrNoState)
+}
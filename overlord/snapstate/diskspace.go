@@ -0,0 +1,267 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	osutilDiskSpace = osutil.DiskSpace
+	osutilDirSize   = osutil.DirSize
+)
+
+// DiskSpaceRequirement is a single contribution to the projected disk
+// footprint of a change.
+type DiskSpaceRequirement struct {
+	What string
+	Path string
+	Size uint64
+
+	// dir is the directory whose size is that of the requirement,
+	// measured without holding the state lock
+	dir string
+	// linkedFrom is the file that is hard linked in place rather than
+	// copied when it is on the same filesystem
+	linkedFrom string
+}
+
+// InsufficientDiskSpaceError is returned when a change would need more
+// disk space than is available on one of the filesystems it writes to.
+type InsufficientDiskSpaceError struct {
+	Snaps     []string
+	Path      string
+	Needed    uint64
+	Available uint64
+	Breakdown []DiskSpaceRequirement
+}
+
+func (e *InsufficientDiskSpaceError) Error() string {
+	parts := make([]string, len(e.Breakdown))
+	for i, req := range e.Breakdown {
+		parts[i] = fmt.Sprintf("%s %s", req.What, strutil.SizeToStr(int64(req.Size)))
+	}
+	return fmt.Sprintf("insufficient disk space for %s in %s: need %s (%s) but only %s available",
+		strutil.Quoted(e.Snaps), e.Path, strutil.SizeToStr(int64(e.Needed)), strings.Join(parts, ", "), strutil.SizeToStr(int64(e.Available)))
+}
+
+// dataDirRequirements returns a requirement of the given kind for each of
+// the directories, sized like the directory and placed on the filesystem
+// of its parent.
+func dataDirRequirements(what string, dataDirs []string) []DiskSpaceRequirement {
+	reqs := make([]DiskSpaceRequirement, len(dataDirs))
+	for i, dataDir := range dataDirs {
+		reqs[i] = DiskSpaceRequirement{What: what, Path: filepath.Dir(dataDir), dir: dataDir}
+	}
+	return reqs
+}
+
+// installDiskSpaceRequirements returns what installing the snap described
+// by snapsup on top of snapst is going to write to disk.
+func installDiskSpaceRequirements(snapst *SnapState, snapsup *SnapSetup) ([]DiskSpaceRequirement, error) {
+	var reqs []DiskSpaceRequirement
+
+	revisionIsLocal := snapst.LastIndex(snapsup.Revision()) >= 0
+	if !revisionIsLocal {
+		switch {
+		case snapsup.Flags.TryMode:
+			// nothing is copied, the snap is used in place
+		case snapsup.SnapPath != "":
+			fi, err := os.Stat(snapsup.SnapPath)
+			if err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, DiskSpaceRequirement{What: "snap file", Path: dirs.SnapBlobDir, Size: uint64(fi.Size()), linkedFrom: snapsup.SnapPath})
+		case snapsup.DownloadInfo != nil:
+			if osutil.FileExists(preDownloadPath(snapsup)) {
+				// already downloaded, it only needs to be moved
+				break
+			}
+			reqs = append(reqs, DiskSpaceRequirement{What: "snap file", Path: dirs.SnapBlobDir, Size: uint64(snapsup.DownloadInfo.Size)})
+		}
+	}
+
+	// the data of the current revision is copied, unless reverting
+	if snapst.IsInstalled() && !snapsup.Flags.Revert && snapst.Current != snapsup.Revision() {
+		cur, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+		dataDirs, err := filepath.Glob(cur.DataHomeDir())
+		if err != nil {
+			return nil, err
+		}
+		dataDirs = append(dataDirs, cur.UserDataDir(filepath.Join(dirs.GlobalRootDir, "/root/")), cur.DataDir())
+		reqs = append(reqs, dataDirRequirements("data copy", dataDirs)...)
+	}
+
+	return reqs, nil
+}
+
+// snapshotDiskSpaceRequirements returns what saving the data of the
+// current revision of the snap in an automatic snapshot is going to write
+// to disk, at most, as the snapshot is compressed.
+func snapshotDiskSpaceRequirements(snapst *SnapState) ([]DiskSpaceRequirement, error) {
+	cur, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	var dataDirs []string
+	for _, glob := range []string{cur.DataHomeDir(), cur.CommonDataHomeDir()} {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, err
+		}
+		dataDirs = append(dataDirs, matches...)
+	}
+	dataDirs = append(dataDirs, cur.UserDataDir(filepath.Join(dirs.GlobalRootDir, "/root/")), cur.DataDir(), cur.CommonDataDir())
+
+	reqs := dataDirRequirements("snapshot", dataDirs)
+	for i := range reqs {
+		reqs[i].Path = dirs.SnapshotsDir
+	}
+	return reqs, nil
+}
+
+// checkChangeDiskSpace checks, once for the whole change of the task, that
+// every filesystem the change is going to write to has enough room for
+// all of its snaps together, so that it fails before anything is
+// downloaded, copied or saved instead of running out of space halfway
+// through.
+// Note that the state must be locked by the caller, and that it is
+// unlocked while the data is measured.
+func checkChangeDiskSpace(t *state.Task) error {
+	st := t.State()
+	chg := t.Change()
+
+	var checked bool
+	err := chg.Get("disk-space-checked", &checked)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if checked {
+		return nil
+	}
+
+	var snapNames []string
+	var reqs []DiskSpaceRequirement
+	for _, ct := range chg.Tasks() {
+		var snapReqs []DiskSpaceRequirement
+		switch ct.Kind() {
+		case "prerequisites":
+			snapsup, snapst, err := snapSetupAndState(ct)
+			if err != nil {
+				return err
+			}
+			snapReqs, err = installDiskSpaceRequirements(snapst, snapsup)
+			if err != nil {
+				return fmt.Errorf("cannot compute disk space needed for %q: %v", snapsup.Name(), err)
+			}
+			snapNames = append(snapNames, snapsup.Name())
+		case "save-snapshot":
+			snapsup, snapst, err := snapSetupAndState(ct)
+			if err != nil {
+				return err
+			}
+			snapReqs, err = snapshotDiskSpaceRequirements(snapst)
+			if err != nil {
+				return fmt.Errorf("cannot compute disk space needed for %q: %v", snapsup.Name(), err)
+			}
+			if !strutil.ListContains(snapNames, snapsup.Name()) {
+				snapNames = append(snapNames, snapsup.Name())
+			}
+		}
+		reqs = append(reqs, snapReqs...)
+	}
+	chg.Set("disk-space-checked", true)
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	st.Unlock()
+	defer st.Lock()
+	return checkDiskSpace(snapNames, reqs)
+}
+
+// checkDiskSpace checks that every filesystem the requirements of the
+// given snaps are on has enough room for all of them.
+func checkDiskSpace(snapNames []string, reqs []DiskSpaceRequirement) error {
+	var order []uint64
+	usage := make(map[uint64]*InsufficientDiskSpaceError)
+	for _, req := range reqs {
+		if req.dir != "" {
+			size, err := osutilDirSize(req.dir)
+			if err != nil {
+				return fmt.Errorf("cannot compute disk space needed for %s: %v", strutil.Quoted(snapNames), err)
+			}
+			req.Size = size
+		}
+		if req.Size == 0 {
+			continue
+		}
+		info, err := osutilDiskSpace(req.Path)
+		if err != nil {
+			return fmt.Errorf("cannot compute disk space available for %s: %v", strutil.Quoted(snapNames), err)
+		}
+		if req.linkedFrom != "" {
+			src, err := osutilDiskSpace(req.linkedFrom)
+			if err == nil && src.Device == info.Device {
+				continue
+			}
+		}
+		u := usage[info.Device]
+		if u == nil {
+			u = &InsufficientDiskSpaceError{
+				Snaps:     snapNames,
+				Path:      req.Path,
+				Available: info.Available,
+			}
+			usage[info.Device] = u
+			order = append(order, info.Device)
+		}
+		u.Needed += req.Size
+		merged := false
+		for i := range u.Breakdown {
+			if u.Breakdown[i].What == req.What {
+				u.Breakdown[i].Size += req.Size
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			u.Breakdown = append(u.Breakdown, DiskSpaceRequirement{What: req.What, Path: req.Path, Size: req.Size})
+		}
+	}
+
+	for _, dev := range order {
+		if u := usage[dev]; u.Needed > u.Available {
+			return u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// mockDiskSpace puts the snap blobs and the snap data on different
+// filesystems, each with the given amount of space available.
+func mockDiskSpace(blobAvail, dataAvail uint64) (restore func()) {
+	return snapstate.MockDiskSpace(func(path string) (*osutil.DiskSpaceInfo, error) {
+		if path == dirs.SnapBlobDir || strings.HasPrefix(path, dirs.SnapBlobDir+"/") {
+			return &osutil.DiskSpaceInfo{Device: 1, Available: blobAvail}, nil
+		}
+		return &osutil.DiskSpaceInfo{Device: 2, Available: dataAvail}, nil
+	})
+}
+
+func writeSizedFile(c *C, path string, size int) {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path, make([]byte, size), 0644), IsNil)
+}
+
+// checkChangeDiskSpace checks the disk space needed by a change made of
+// the task sets, as its first task does.
+func (s *snapmgrTestSuite) checkChangeDiskSpace(tss ...*state.TaskSet) error {
+	chg := s.state.NewChange("test", "...")
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	return snapstate.CheckChangeDiskSpace(chg.Tasks()[0])
+}
+
+func (s *snapmgrTestSuite) setSomeSnap(c *C, dataSize int) {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+	writeSizedFile(c, filepath.Join(dirs.SnapDataDir, "some-snap", "7", "data"), dataSize)
+}
+
+func (s *snapmgrTestSuite) TestUpdateInsufficientDiskSpaceForDataCopy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockDiskSpace(1<<30, 4000)
+	defer restore()
+
+	s.setSomeSnap(c, 3000)
+	writeSizedFile(c, filepath.Join(dirs.GlobalRootDir, "home", "user1", "snap", "some-snap", "7", "data"), 2000)
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	err = s.checkChangeDiskSpace(ts)
+	c.Assert(err, ErrorMatches, `insufficient disk space for "some-snap" in .*/home/user1/snap/some-snap: need 5kB \(data copy 5kB\) but only 4kB available`)
+	c.Check(err, FitsTypeOf, &snapstate.InsufficientDiskSpaceError{})
+}
+
+func (s *snapmgrTestSuite) TestUpdateEnoughDiskSpaceForDataCopy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockDiskSpace(1<<30, 6000)
+	defer restore()
+
+	s.setSomeSnap(c, 3000)
+	writeSizedFile(c, filepath.Join(dirs.GlobalRootDir, "home", "user1", "snap", "some-snap", "7", "data"), 2000)
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(s.checkChangeDiskSpace(ts), IsNil)
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyDiskSpaceOfAllSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// each snap fits, but not both
+	restore := mockDiskSpace(1<<30, 5000)
+	defer restore()
+
+	s.setSomeSnap(c, 3000)
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "services-snap", SnapID: "services-snap-id", Revision: snap.R(2)}},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+	writeSizedFile(c, filepath.Join(dirs.SnapDataDir, "services-snap", "2", "data"), 3000)
+
+	updates, tss, err := snapstate.UpdateMany(s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 2)
+	err = s.checkChangeDiskSpace(tss...)
+	c.Assert(err, ErrorMatches, `insufficient disk space for "(some|services)-snap", "(some|services)-snap" in .*: need 6kB \(data copy 6kB\) but only 5kB available`)
+}
+
+func (s *snapmgrTestSuite) TestCheckChangeDiskSpaceOnlyOnce(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockDiskSpace(1<<30, 1000)
+	defer restore()
+
+	s.setSomeSnap(c, 3000)
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("test", "...")
+	chg.AddAll(ts)
+	c.Assert(snapstate.CheckChangeDiskSpace(ts.Tasks()[0]), NotNil)
+	c.Check(snapstate.CheckChangeDiskSpace(ts.Tasks()[0]), IsNil)
+}
+
+func (s *snapmgrTestSuite) TestCheckChangeDiskSpaceWithoutStateLock(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockDiskSpace(1<<30, 1<<30)
+	defer restore()
+	restore = snapstate.MockDirSize(func(path string) (uint64, error) {
+		unlocked := make(chan struct{})
+		go func() {
+			s.state.Lock()
+			s.state.Unlock()
+			close(unlocked)
+		}()
+		select {
+		case <-unlocked:
+		case <-time.After(5 * time.Second):
+			c.Fatalf("the data of snaps is measured with the state locked")
+		}
+		return 0, nil
+	})
+	defer restore()
+
+	s.setSomeSnap(c, 3000)
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(s.checkChangeDiskSpace(ts), IsNil)
+}
+
+func (s *snapmgrTestSuite) TestUpdateDiskSpaceForSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the data is copied within the data filesystem, and saved into the
+	// snapshots one
+	restore := snapstate.MockDiskSpace(func(path string) (*osutil.DiskSpaceInfo, error) {
+		if strings.HasPrefix(path, dirs.SnapshotsDir) {
+			return &osutil.DiskSpaceInfo{Device: 3, Available: 2000}, nil
+		}
+		return &osutil.DiskSpaceInfo{Device: 2, Available: 1 << 30}, nil
+	})
+	defer restore()
+
+	s.enableAutomaticSnapshots(c, "")
+	s.setSomeSnap(c, 3000)
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	err = s.checkChangeDiskSpace(ts)
+	c.Assert(err, ErrorMatches, `insufficient disk space for "some-snap" in .*/var/lib/snapd/snapshots: need 3kB \(snapshot 3kB\) but only 2kB available`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveDiskSpaceForSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockDiskSpace(1<<30, 2000)
+	defer restore()
+
+	s.enableAutomaticSnapshots(c, "")
+	s.setSomeSnap(c, 3000)
+
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("remove", "...")
+	chg.AddAll(ts)
+	// the snapshot is saved by the second task
+	c.Assert(ts.Tasks()[1].Kind(), Equals, "save-snapshot")
+	err = snapstate.CheckChangeDiskSpace(ts.Tasks()[1])
+	c.Assert(err, ErrorMatches, `insufficient disk space for "some-snap" in .*/var/lib/snapd/snapshots: need 3kB \(snapshot 3kB\) but only 2kB available`)
+}
+
+func (s *snapmgrTestSuite) TestRevertNeedsNoDiskSpace(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := mockDiskSpace(0, 0)
+	defer restore()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(7)},
+			{RealName: "some-snap", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+	writeSizedFile(c, filepath.Join(dirs.SnapDataDir, "some-snap", "11", "data"), 3000)
+
+	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(s.checkChangeDiskSpace(ts), IsNil)
+}
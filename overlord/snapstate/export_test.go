@@ -23,10 +23,14 @@ import (
 	"errors"
 	"time"
 
+	"golang.org/x/net/context"
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store/oci"
 )
 
 type ManagerBackend managerBackend
@@ -35,6 +39,12 @@ func SetSnapManagerBackend(s *SnapManager, b ManagerBackend) {
 	s.backend = b
 }
 
+func SetSnapManagerSchedule(m *SnapManager, nextRefresh, nextCatalogRefresh, nextOrphansCleanup time.Time) {
+	m.nextRefresh = nextRefresh
+	m.nextCatalogRefresh = nextCatalogRefresh
+	m.nextOrphansCleanup = nextOrphansCleanup
+}
+
 type ForeignTaskTracker interface {
 	ForeignTask(kind string, status state.Status, snapsup *SnapSetup)
 }
@@ -84,16 +94,33 @@ func MockReadInfo(mock func(name string, si *snap.SideInfo) (*snap.Info, error))
 	return func() { readInfo = old }
 }
 
+func MockCgroupFreezer(freeze, thaw func(snapName string) error) (restore func()) {
+	oldFreeze := cgroupFreezeSnapProcesses
+	oldThaw := cgroupThawSnapProcesses
+	cgroupFreezeSnapProcesses = freeze
+	cgroupThawSnapProcesses = thaw
+	return func() {
+		cgroupFreezeSnapProcesses = oldFreeze
+		cgroupThawSnapProcesses = oldThaw
+	}
+}
+
+func MockOCIDownload(mock func(ctx context.Context, ref *oci.Reference, targetPath string) (*oci.Artifact, error)) (restore func()) {
+	old := ociDownload
+	ociDownload = mock
+	return func() { ociDownload = old }
+}
+
 func MockOpenSnapFile(mock func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error)) (restore func()) {
 	prevOpenSnapFile := openSnapFile
 	openSnapFile = mock
 	return func() { openSnapFile = prevOpenSnapFile }
 }
 
-func MockErrtrackerReport(mock func(string, string, string, map[string]string) (string, error)) (restore func()) {
-	prev := errtrackerReport
-	errtrackerReport = mock
-	return func() { errtrackerReport = prev }
+func MockErrReport(mock func(*state.State, *errreport.Report) (string, error)) (restore func()) {
+	prev := errReport
+	errReport = mock
+	return func() { errReport = prev }
 }
 
 func MockPrerequisitesRetryTimeout(d time.Duration) (restore func()) {
@@ -122,3 +149,58 @@ var (
 	CheckAliasesConflicts = checkAliasesConflicts
 	DisableAliases        = disableAliases
 )
+
+func MockSetupCheckHealthHook(mock func(st *state.State, snapName string, timeout time.Duration) *state.Task) (restore func()) {
+	old := SetupCheckHealthHook
+	SetupCheckHealthHook = mock
+	return func() { SetupCheckHealthHook = old }
+}
+
+func MockIsOnMeteredConnection(mock func() (bool, error)) (restore func()) {
+	old := isOnMeteredConnection
+	isOnMeteredConnection = mock
+	return func() { isOnMeteredConnection = old }
+}
+
+func MockPreDownloadAhead(d time.Duration) (restore func()) {
+	old := preDownloadAhead
+	preDownloadAhead = d
+	return func() { preDownloadAhead = old }
+}
+
+var PreDownloadPath = preDownloadPath
+
+func MockDiskSpace(f func(path string) (*osutil.DiskSpaceInfo, error)) (restore func()) {
+	old := osutilDiskSpace
+	osutilDiskSpace = f
+	return func() { osutilDiskSpace = old }
+}
+
+func MockDirSize(f func(path string) (uint64, error)) (restore func()) {
+	old := osutilDirSize
+	osutilDirSize = f
+	return func() { osutilDirSize = old }
+}
+
+var CheckChangeDiskSpace = checkChangeDiskSpace
+
+func MockReadComponentInfo(mock func(compPath string) (*snap.ComponentInfo, error)) (restore func()) {
+	old := readComponentInfo
+	readComponentInfo = mock
+	return func() { readComponentInfo = old }
+}
+
+var ChannelBranch = channelBranch
+
+// RemoveAffectedSnapsByKind undoes AddAffectedSnapsByKind for tests.
+func RemoveAffectedSnapsByKind(kind string) {
+	delete(affectedSnapsByKind, kind)
+}
+
+func MockVeritySupported(supported bool) (restore func()) {
+	old := veritySupported
+	veritySupported = func() bool { return supported }
+	return func() { veritySupported = old }
+}
+
+var VerityOptions = verityOptions
@@ -34,6 +34,9 @@ type Flags struct {
 
 	// Revert flags the SnapSetup as coming from a revert
 	Revert bool `json:"revert,omitempty"`
+	// RevertConfig is set when reverting to also restore the
+	// configuration the snap had with the revision reverted to.
+	RevertConfig bool `json:"revert-config,omitempty"`
 
 	// RemoveSnapPath is used via InstallPath to flag that the file passed in is temporary and should be removed
 	RemoveSnapPath bool `json:"remove-snap-path,omitempty"`
@@ -53,6 +56,10 @@ type Flags struct {
 	// Unaliased is set to request that no automatic aliases are created
 	// installing the snap.
 	Unaliased bool `json:"unaliased,omitempty"`
+
+	// ForeignArch is set when the user has asked for a snap of another
+	// architecture to be installed, to run under emulation.
+	ForeignArch bool `json:"foreign-arch,omitempty"`
 }
 
 // DevModeAllowed returns whether a snap can be installed with devmode confinement (either set or overridden)
@@ -20,6 +20,7 @@
 package snapstate
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,10 +31,14 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/cgroup"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/errreportstate"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
@@ -149,6 +154,12 @@ func (m *SnapManager) doPrerequisites(t *state.Task, _ *tomb.Tomb) error {
 	st.Lock()
 	defer st.Unlock()
 
+	// the first prerequisites of the change check that there is room
+	// for all of it
+	if err := checkChangeDiskSpace(t); err != nil {
+		return err
+	}
+
 	// check if we need to inject tasks to install core
 	snapsup, _, err := snapSetupAndState(t)
 	if err != nil {
@@ -161,60 +172,139 @@ func (m *SnapManager) doPrerequisites(t *state.Task, _ *tomb.Tomb) error {
 		return nil
 	}
 
+	if err := checkRequiresCycle(st, t.Change(), snapsup); err != nil {
+		return err
+	}
+
 	// check prereqs
 	prereqName := defaultCoreSnapName
 	if snapsup.Base != "" {
 		prereqName = snapsup.Base
 	}
+	required := append([]string{prereqName}, snapsup.Requires...)
+
+	// first find out what is missing, so that nothing gets
+	// queued if we need to wait for something else
+	var missing []string
+	for _, name := range required {
+		needed, err := prereqNeeded(st, name)
+		if err == errPrereqPending {
+			// if something else installs the prereq already we
+			// need to wait for that to either finish
+			// successfully or fail
+			return &state.Retry{After: prerequisitesRetryTimeout}
+		}
+		if err != nil {
+			return err
+		}
+		if needed {
+			missing = append(missing, name)
+		}
+	}
+	var recommended []string
+	for _, name := range snapsup.Recommends {
+		// recommended snaps are never waited for
+		needed, err := prereqNeeded(st, name)
+		if err != nil && err != errPrereqPending {
+			return err
+		}
+		if needed {
+			recommended = append(recommended, name)
+		}
+	}
+
+	var tss []*state.TaskSet
+	for _, name := range missing {
+		// not installed, nor queued for install -> install it
+		ts, err := Install(st, name, defaultBaseSnapsChannel, snap.R(0), snapsup.UserID, Flags{})
+		// something might have triggered an explicit install of the
+		// prereq while the state was unlocked -> deal with that here
+		if _, ok := err.(changeDuringInstallError); ok {
+			return &state.Retry{After: prerequisitesRetryTimeout}
+		}
+		if _, ok := err.(changeConflictError); ok {
+			return &state.Retry{After: prerequisitesRetryTimeout}
+		}
+		if err != nil {
+			if name != prereqName {
+				return fmt.Errorf("cannot install snap %q required by %q: %v", name, snapName, err)
+			}
+			return err
+		}
+		tss = append(tss, ts)
+	}
+	installRecommended(st, snapName, recommended, snapsup.UserID)
+	if len(tss) == 0 {
+		return nil
+	}
 
+	// inject the installs of the prereqs into this change
+	chg := t.Change()
+	for _, ts := range tss {
+		ts.JoinLane(st.NewLane())
+		for _, t := range chg.Tasks() {
+			t.WaitAll(ts)
+		}
+	}
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	// make sure that the new change is committed to the state
+	// together with marking this task done
+	t.SetStatus(state.DoneStatus)
+
+	return nil
+}
+
+// installRecommended queues the installs of the given snaps recommended
+// by the named one in a change of their own, each in its own lane, so
+// that they neither hold back nor fail the install of the snap.
+func installRecommended(st *state.State, snapName string, names []string, userID int) {
+	var tss []*state.TaskSet
+	for _, name := range names {
+		ts, err := Install(st, name, defaultBaseSnapsChannel, snap.R(0), userID, Flags{})
+		if err != nil {
+			logger.Noticef("cannot install snap %q recommended by %q: %v", name, snapName, err)
+			continue
+		}
+		tss = append(tss, ts)
+	}
+	if len(tss) == 0 {
+		return
+	}
+	chg := st.NewChange("install-snap", fmt.Sprintf(i18n.G("Install snaps recommended by %q"), snapName))
+	for _, ts := range tss {
+		ts.JoinLane(st.NewLane())
+		chg.AddAll(ts)
+	}
+	st.EnsureBefore(0)
+}
+
+var errPrereqPending = errors.New("prerequisite is being installed")
+
+// prereqNeeded returns whether the named prerequisite needs to be
+// installed, or errPrereqPending if another change is installing it.
+func prereqNeeded(st *state.State, name string) (bool, error) {
 	var prereqState SnapState
-	err = Get(st, prereqName, &prereqState)
+	err := Get(st, name, &prereqState)
 	// we have the prereq already
 	if err == nil {
-		return nil
+		return false, nil
 	}
 	// if it is a real error, report
 	if err != state.ErrNoState {
-		return err
+		return false, err
 	}
 
 	// check that there is no task that installs the prereq already
-	prereqPending, err := changeInFlight(st, prereqName)
+	prereqPending, err := changeInFlight(st, name)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if prereqPending {
-		// if something else installs core already we need to
-		// wait for that to either finish successfully or fail
-		return &state.Retry{After: prerequisitesRetryTimeout}
-	}
-
-	// not installed, nor queued for install -> install it
-	ts, err := Install(st, prereqName, defaultBaseSnapsChannel, snap.R(0), snapsup.UserID, Flags{})
-	// something might have triggered an explicit install of core while
-	// the state was unlocked -> deal with that here
-	if _, ok := err.(changeDuringInstallError); ok {
-		return &state.Retry{After: prerequisitesRetryTimeout}
-	}
-	if _, ok := err.(changeConflictError); ok {
-		return &state.Retry{After: prerequisitesRetryTimeout}
+		return false, errPrereqPending
 	}
-	if err != nil {
-		return err
-	}
-	ts.JoinLane(st.NewLane())
-
-	// inject install for core into this change
-	chg := t.Change()
-	for _, t := range chg.Tasks() {
-		t.WaitAll(ts)
-	}
-	chg.AddAll(ts)
-	// make sure that the new change is committed to the state
-	// together with marking this task done
-	t.SetStatus(state.DoneStatus)
-
-	return nil
+	return true, nil
 }
 
 func (m *SnapManager) doPrepareSnap(t *state.Task, _ *tomb.Tomb) error {
@@ -304,11 +394,12 @@ func (m *SnapManager) undoPrepareSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	st.Unlock()
-	oopsid, err := errtrackerReport(snapsup.SideInfo.RealName, strings.Join(logMsg, "\n"), strings.Join(dupSig, "\n"), extra)
+	r := errreport.New("snap", snapsup.SideInfo.RealName, strings.Join(logMsg, "\n"), strings.Join(dupSig, "\n"), extra)
+	reportid, err := errReport(st, r)
 	st.Lock()
 	if err == nil {
-		logger.Noticef("Reported install problem for %q as %s", snapsup.SideInfo.RealName, oopsid)
-	} else {
+		logger.Noticef("Reported install problem for %q as %s", snapsup.SideInfo.RealName, reportid)
+	} else if err != errreportstate.ErrDisabled {
 		logger.Debugf("Cannot report problem: %s", err)
 	}
 
@@ -332,6 +423,23 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 		return err
 	}
 
+	// resume the download if it was interrupted, e.g. by a restart
+	var partial *store.PartialDownload
+	st.Lock()
+	perr := t.Get("download-partial", &partial)
+	st.Unlock()
+	if perr != nil && perr != state.ErrNoState {
+		return perr
+	}
+	dlOpts := &store.DownloadOptions{
+		Partial: partial,
+		SavePartial: func(p *store.PartialDownload) {
+			st.Lock()
+			defer st.Unlock()
+			t.Set("download-partial", p)
+		},
+	}
+
 	meter := NewTaskProgressAdapterUnlocked(t)
 	targetFn := snapsup.MountFile()
 	if snapsup.DownloadInfo == nil {
@@ -343,15 +451,20 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 			Name:     snapsup.Name(),
 			Channel:  snapsup.Channel,
 			Revision: snapsup.Revision(),
+			StoreID:  snapsup.StoreID,
 		}
 		storeInfo, err = theStore.SnapInfo(spec, user)
 		if err != nil {
 			return err
 		}
-		err = theStore.Download(tomb.Context(nil), snapsup.Name(), targetFn, &storeInfo.DownloadInfo, meter, user)
+		err = theStore.Download(tomb.Context(nil), snapsup.Name(), targetFn, &storeInfo.DownloadInfo, meter, user, dlOpts)
 		snapsup.SideInfo = &storeInfo.SideInfo
+	} else if usePreDownloaded(snapsup, targetFn) {
+		st.Lock()
+		t.Logf("Using snap downloaded ahead of the refresh")
+		st.Unlock()
 	} else {
-		err = theStore.Download(tomb.Context(nil), snapsup.Name(), targetFn, snapsup.DownloadInfo, meter, user)
+		err = theStore.Download(tomb.Context(nil), snapsup.Name(), targetFn, snapsup.DownloadInfo, meter, user, dlOpts)
 	}
 	if err != nil {
 		return err
@@ -362,11 +475,36 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	// update the snap setup for the follow up tasks
 	st.Lock()
 	t.Set("snap-setup", snapsup)
+	t.Clear("download-partial")
 	st.Unlock()
 
 	return nil
 }
 
+func (m *SnapManager) cleanupDownloadSnap(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if t.Status() == state.DoneStatus {
+		return nil
+	}
+
+	// the download will not be resumed anymore
+	var partial store.PartialDownload
+	if err := t.Get("download-partial", &partial); err != nil {
+		if err == state.ErrNoState {
+			return nil
+		}
+		return err
+	}
+	if err := os.Remove(partial.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	t.Clear("download-partial")
+	return nil
+}
+
 func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 	t.State().Lock()
 	snapsup, snapst, err := snapSetupAndState(t)
@@ -385,10 +523,15 @@ func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	verity, err := verityOptions(t.State(), snapsup)
+	if err != nil {
+		return err
+	}
+
 	pb := NewTaskProgressAdapterUnlocked(t)
 	// TODO Use snapsup.Revision() to obtain the right info to mount
 	//      instead of assuming the candidate is the right one.
-	if err := m.backend.SetupSnap(snapsup.SnapPath, snapsup.SideInfo, pb); err != nil {
+	if err := m.backend.SetupSnap(snapsup.SnapPath, snapsup.SideInfo, verity, pb); err != nil {
 		return err
 	}
 
@@ -455,9 +598,14 @@ func (m *SnapManager) doUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	snapst.Active = false
 
+	// keep the running apps from seeing the snap change below them,
+	// they are thawed again once a revision is linked back
+	freezeSnapProcesses(t, snapsup.Name())
+
 	pb := NewTaskProgressAdapterLocked(t)
 	err = m.backend.UnlinkSnap(oldInfo, pb)
 	if err != nil {
+		thawSnapProcesses(t, snapsup.Name())
 		return err
 	}
 
@@ -466,6 +614,27 @@ func (m *SnapManager) doUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+var (
+	cgroupFreezeSnapProcesses = cgroup.FreezeSnapProcesses
+	cgroupThawSnapProcesses   = cgroup.ThawSnapProcesses
+)
+
+// freezeSnapProcesses freezes the running processes of the given snap
+// while it is being refreshed. This is best effort, not every system
+// can freeze processes.
+func freezeSnapProcesses(t *state.Task, snapName string) {
+	if err := cgroupFreezeSnapProcesses(snapName); err != nil && err != cgroup.ErrNoFreezer {
+		t.Logf("cannot freeze processes of snap %q: %v", snapName, err)
+	}
+}
+
+// thawSnapProcesses thaws the processes frozen by freezeSnapProcesses.
+func thawSnapProcesses(t *state.Task, snapName string) {
+	if err := cgroupThawSnapProcesses(snapName); err != nil && err != cgroup.ErrNoFreezer {
+		t.Errorf("cannot thaw processes of snap %q: %v", snapName, err)
+	}
+}
+
 func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -487,6 +656,7 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	snapst.Active = true
 	err = m.backend.LinkSnap(oldInfo)
+	thawSnapProcesses(t, snapsup.Name())
 	if err != nil {
 		return err
 	}
@@ -643,6 +813,9 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	if snapsup.Channel != "" {
 		snapst.Channel = snapsup.Channel
 	}
+	if snapsup.StoreID != "" {
+		snapst.StoreID = snapsup.StoreID
+	}
 	oldTryMode := snapst.TryMode
 	snapst.TryMode = snapsup.TryMode
 	oldDevMode := snapst.DevMode
@@ -672,12 +845,18 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 			t.Errorf("cannot cleanup failed attempt at making snap %q available to the system: %v", snapsup.Name(), err)
 		}
 	}
+	if !oldCurrent.Unset() {
+		// either way the apps must not stay frozen, undoing the
+		// unlink of the old revision links and thaws it again
+		thawSnapProcesses(t, snapsup.Name())
+	}
 	if err != nil {
 		return err
 	}
 
-	// Restore configuration of the target revision (if available) on revert
-	if snapsup.Revert {
+	// Restore configuration of the target revision (if available) on revert,
+	// if asked to
+	if snapsup.Revert && snapsup.RevertConfig {
 		if err = config.RestoreRevisionConfig(st, snapsup.Name(), snapsup.Revision()); err != nil {
 			return err
 		}
@@ -802,7 +981,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	if len(snapst.Sequence) == 1 {
+	if len(snapst.Sequence) == 1 || (isRevert && snapsup.RevertConfig) {
 		if err = config.RestoreRevisionConfig(st, snapsup.Name(), oldCurrent); err != nil {
 			return err
 		}
@@ -1006,6 +1185,10 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		return &state.Retry{After: 3 * time.Minute}
 	}
 	if len(snapst.Sequence) == 0 {
+		if err := m.discardComponents(snapsup.Name(), snapst, pb); err != nil {
+			t.Errorf("cannot remove components of snap %q, will retry in 3 mins: %s", snapsup.Name(), err)
+			return &state.Retry{After: 3 * time.Minute}
+		}
 		// Remove configuration associated with this snap.
 		err = config.DeleteSnapConfig(st, snapsup.Name())
 		if err != nil {
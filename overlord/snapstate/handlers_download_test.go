@@ -20,12 +20,20 @@
 package snapstate_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
 )
 
 type downloadSnapSuite struct {
@@ -158,6 +166,82 @@ func (s *downloadSnapSuite) TestDoDownloadSnapNormal(c *C) {
 	c.Check(t.Status(), Equals, state.DoneStatus)
 }
 
+func (s *downloadSnapSuite) TestDoDownloadSnapResumesPartial(c *C) {
+	s.state.Lock()
+
+	partial := &store.PartialDownload{Path: "/some/path.partial", Offset: 42, ETag: "etag"}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "mySnapID",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	// saved by a download interrupted by a restart
+	t.Set("download-partial", partial)
+	s.state.NewChange("dummy", "...").AddTask(t)
+
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	c.Assert(s.fakeStore.downloads, HasLen, 1)
+	c.Check(s.fakeStore.downloads[0].partial, DeepEquals, partial)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	// nothing left to resume
+	var saved store.PartialDownload
+	c.Check(t.Get("download-partial", &saved), Equals, state.ErrNoState)
+}
+
+func (s *downloadSnapSuite) TestDoDownloadSnapInterruptedCleansUpPartial(c *C) {
+	partialPath := filepath.Join(c.MkDir(), "foo_11.snap.partial")
+	err := ioutil.WriteFile(partialPath, []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+	s.fakeStore.downloadInterrupted = &store.PartialDownload{Path: partialPath, Offset: 3}
+
+	s.state.Lock()
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "mySnapID",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.IsReady(), Equals, true)
+	c.Check(t.IsClean(), Equals, true)
+
+	// the saved partial download was removed as it won't be resumed
+	c.Check(osutil.FileExists(partialPath), Equals, false)
+	var saved store.PartialDownload
+	c.Check(t.Get("download-partial", &saved), Equals, state.ErrNoState)
+}
+
 func (s *downloadSnapSuite) TestDoUndoDownloadSnap(c *C) {
 	s.state.Lock()
 	si := &snap.SideInfo{
@@ -197,3 +281,87 @@ func (s *downloadSnapSuite) TestDoUndoDownloadSnap(c *C) {
 	c.Assert(err, Equals, state.ErrNoState)
 
 }
+
+func (s *downloadSnapSuite) TestDoDownloadSnapUsesPreDownloaded(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	snapsup := &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "mySnapID",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	}
+	preDownloaded := snapstate.PreDownloadPath(snapsup)
+	c.Assert(os.MkdirAll(filepath.Dir(preDownloaded), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(preDownloaded, []byte("snap-data"), 0644), IsNil)
+	digest, size, err := asserts.SnapFileSHA3_384(preDownloaded)
+	c.Assert(err, IsNil)
+	snapsup.DownloadInfo.Sha3_384 = digest
+	snapsup.DownloadInfo.Size = int64(size)
+
+	s.state.Lock()
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", snapsup)
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	// the store was not hit
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+	c.Check(osutil.FileExists(preDownloaded), Equals, false)
+	content, err := ioutil.ReadFile(snapsup.MountFile())
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "snap-data")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(t.Log(), HasLen, 1)
+	c.Check(t.Log()[0], Matches, ".* Using snap downloaded ahead of the refresh")
+}
+
+func (s *downloadSnapSuite) TestDoDownloadSnapDiscardsMismatchedPreDownload(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	snapsup := &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "mySnapID",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+			Sha3_384:    "expected-sha3",
+			Size:        9,
+		},
+	}
+	preDownloaded := snapstate.PreDownloadPath(snapsup)
+	c.Assert(os.MkdirAll(filepath.Dir(preDownloaded), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(preDownloaded, []byte("snap-data"), 0644), IsNil)
+
+	s.state.Lock()
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", snapsup)
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	// downloaded from the store after all
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:   "storesvc-download",
+			name: "foo",
+		},
+	})
+	c.Check(osutil.FileExists(preDownloaded), Equals, false)
+}
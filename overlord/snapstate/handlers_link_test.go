@@ -22,10 +22,12 @@ package snapstate_test
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/cgroup"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -388,6 +390,109 @@ func (s *linkSnapSuite) TestDoUndoUnlinkCurrentSnapCore(c *C) {
 	c.Check(s.stateBackend.restartRequested, DeepEquals, []state.RestartType{state.RestartDaemon})
 }
 
+func mockCgroupFreezer(freezeErr error) (calls *[]string, restore func()) {
+	calls = &[]string{}
+	restore = snapstate.MockCgroupFreezer(func(snapName string) error {
+		*calls = append(*calls, "freeze:"+snapName)
+		return freezeErr
+	}, func(snapName string) error {
+		*calls = append(*calls, "thaw:"+snapName)
+		return freezeErr
+	})
+	return calls, restore
+}
+
+func (s *linkSnapSuite) TestRefreshFreezesAndThawsSnapProcesses(c *C) {
+	calls, restore := mockCgroupFreezer(nil)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(2),
+	}
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		Active:   true,
+	})
+	unlink := s.state.NewTask("unlink-current-snap", "test")
+	unlink.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si2,
+	})
+	link := s.state.NewTask("link-snap", "test")
+	link.Set("snap-setup-task", unlink.ID())
+	link.WaitFor(unlink)
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(unlink)
+	chg.AddTask(link)
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+	s.state.Lock()
+
+	c.Check(unlink.Status(), Equals, state.DoneStatus)
+	c.Check(*calls, DeepEquals, []string{"freeze:foo"})
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+	s.state.Lock()
+
+	c.Check(link.Status(), Equals, state.DoneStatus)
+	c.Check(*calls, DeepEquals, []string{"freeze:foo", "thaw:foo"})
+}
+
+func (s *linkSnapSuite) TestUndoUnlinkCurrentSnapThawsSnapProcesses(c *C) {
+	calls, restore := mockCgroupFreezer(cgroup.ErrNoFreezer)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(2),
+	}
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		Active:   true,
+	})
+	t := s.state.NewTask("unlink-current-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si2,
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	c.Check(*calls, DeepEquals, []string{"freeze:foo", "thaw:foo"})
+	// not being able to freeze is not worth mentioning
+	c.Check(strings.Join(t.Log(), "\n"), Not(Matches), `(?s).*cannot (freeze|thaw).*`)
+}
+
 func (s *linkSnapSuite) TestDoUndoLinkSnapCoreClassic(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
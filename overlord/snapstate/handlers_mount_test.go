@@ -20,13 +20,17 @@
 package snapstate_test
 
 import (
+	"io/ioutil"
 	"path/filepath"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -153,3 +157,74 @@ func (s *mountSnapSuite) TestDoUndoMountSnap(c *C) {
 	})
 
 }
+
+func (s *mountSnapSuite) TestVerityOptions(c *C) {
+	restore := snapstate.MockVeritySupported(true)
+	defer restore()
+	var digest string
+	snapstate.SnapVerity = func(st *state.State, snapSHA3_384 string) (string, string, error) {
+		digest = snapSHA3_384
+		return "abcd", "ef01", nil
+	}
+	defer func() { snapstate.SnapVerity = nil }()
+
+	testSnap := filepath.Join(c.MkDir(), "foo_33.snap")
+	err := ioutil.WriteFile(testSnap, []byte("snap"), 0644)
+	c.Assert(err, IsNil)
+	expectedDigest, _, err := asserts.SnapFileSHA3_384(testSnap)
+	c.Assert(err, IsNil)
+
+	snapsup := &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "foo-id",
+			Revision: snap.R(33),
+		},
+		SnapPath: testSnap,
+	}
+
+	// disabled by default
+	opts, err := snapstate.VerityOptions(s.state, snapsup)
+	c.Assert(err, IsNil)
+	c.Check(opts, IsNil)
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "snaps.verity", true)
+	tr.Commit()
+	s.state.Unlock()
+
+	opts, err = snapstate.VerityOptions(s.state, snapsup)
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &backend.VerityOptions{RootHash: "abcd", Salt: "ef01"})
+	c.Check(digest, Equals, expectedDigest)
+
+	// without a snap-id nothing is asserted
+	snapsup.SideInfo.SnapID = ""
+	opts, err = snapstate.VerityOptions(s.state, snapsup)
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &backend.VerityOptions{})
+
+	// nor used where it is not supported
+	snapstate.MockVeritySupported(false)
+	opts, err = snapstate.VerityOptions(s.state, snapsup)
+	c.Assert(err, IsNil)
+	c.Check(opts, IsNil)
+}
+
+func (s *mountSnapSuite) TestVerityOptionsInvalidConfig(c *C) {
+	restore := snapstate.MockVeritySupported(true)
+	defer restore()
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "snaps.verity", "maybe")
+	tr.Commit()
+	s.state.Unlock()
+
+	opts, err := snapstate.VerityOptions(s.state, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "foo", Revision: snap.R(33)},
+	})
+	c.Assert(err, IsNil)
+	c.Check(opts, IsNil)
+}
@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// defaultCheckHealthTimeout is how long the check-health hook of a
+// refreshed snap has to report it is healthy by default.
+const defaultCheckHealthTimeout = 5 * time.Minute
+
+// getCheckHealthTimeout returns the window the check-health hook of a
+// refreshed snap has to complete successfully before the refresh is
+// undone, as set by the refresh.check-health-timeout core option (a
+// duration such as "90s" or "10m"). Invalid values are ignored.
+func getCheckHealthTimeout(st *state.State) (time.Duration, error) {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "refresh.check-health-timeout", &value)
+	if config.IsNoOption(err) {
+		return defaultCheckHealthTimeout, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		logger.Noticef("cannot use refresh.check-health-timeout configuration: invalid duration %q", value)
+		return defaultCheckHealthTimeout, nil
+	}
+	return timeout, nil
+}
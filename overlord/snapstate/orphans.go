@@ -0,0 +1,301 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+var orphansCleanupDelay = 24 * time.Hour
+
+// The kinds of orphans FindOrphans looks for.
+const (
+	// OrphanData is a /var/snap/<name> directory.
+	OrphanData = "data"
+	// OrphanUserData is a ~/snap/<name> directory.
+	OrphanUserData = "user-data"
+	// OrphanBlob is a snap file, or a partial download of one, in
+	// /var/lib/snapd/snaps.
+	OrphanBlob = "blob"
+	// OrphanMountUnit is the systemd mount unit of a snap revision.
+	OrphanMountUnit = "mount-unit"
+)
+
+// Orphan is something left behind on the system for a snap, or a
+// revision of a snap, that is not in the state.
+type Orphan struct {
+	Kind string `json:"kind"`
+	Snap string `json:"snap"`
+	Path string `json:"path"`
+
+	// mountDir is the directory a mount unit mounts the snap on
+	mountDir string
+}
+
+// snapsInFlight returns the names of the snaps that are affected by
+// changes that are not ready yet. Those can legitimately have files
+// around that are not recorded in their SnapState (yet).
+func snapsInFlight(st *state.State) map[string]bool {
+	inFlight := make(map[string]bool)
+	for _, chg := range st.Changes() {
+		if chg.Status().Ready() {
+			continue
+		}
+		for _, t := range chg.Tasks() {
+			snapsup, err := TaskSnapSetup(t)
+			if err != nil {
+				continue
+			}
+			inFlight[snapsup.Name()] = true
+		}
+	}
+	return inFlight
+}
+
+// orphanedDirs returns the directories matching the glob whose name is
+// not one of the known snaps.
+func orphanedDirs(kind, glob string, known map[string]bool) ([]*Orphan, error) {
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []*Orphan
+	for _, path := range matches {
+		name := filepath.Base(path)
+		if known[name] || !osutil.IsDirectory(path) {
+			continue
+		}
+		orphans = append(orphans, &Orphan{Kind: kind, Snap: name, Path: path})
+	}
+	return orphans, nil
+}
+
+// mountUnitWhere returns the Where= setting of the given mount unit.
+func mountUnitWhere(unit string) (string, error) {
+	f, err := os.Open(unit)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Where=") {
+			return strings.TrimPrefix(line, "Where="), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// FindOrphans returns the data directories, snap files and mount units
+// that are left on the system for snaps, or revisions of snaps, that
+// are not in the state. Snaps with changes in flight are not looked at.
+// The caller should be holding the state lock.
+func (m *SnapManager) FindOrphans() ([]*Orphan, error) {
+	snapStates, err := All(m.state)
+	if err != nil {
+		return nil, err
+	}
+
+	inFlight := snapsInFlight(m.state)
+	// snaps whose data must be left alone, either because they are
+	// installed or because they are being worked on
+	known := make(map[string]bool, len(inFlight)+len(snapStates))
+	for name := range inFlight {
+		known[name] = true
+	}
+	blobs := make(map[string]bool)
+	mountDirs := make(map[string]bool)
+	for name, snapst := range snapStates {
+		known[name] = true
+		for _, si := range snapst.Sequence {
+			info := snap.MinimalPlaceInfo(name, si.Revision)
+			blobs[info.MountFile()] = true
+			mountDirs[info.MountDir()] = true
+		}
+	}
+
+	var orphans []*Orphan
+
+	dataOrphans, err := orphanedDirs(OrphanData, filepath.Join(dirs.SnapDataDir, "*"), known)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, dataOrphans...)
+
+	for _, glob := range []string{
+		filepath.Join(dirs.SnapDataHomeGlob, "*"),
+		filepath.Join(dirs.GlobalRootDir, "/root/snap/*"),
+	} {
+		userDataOrphans, err := orphanedDirs(OrphanUserData, glob, known)
+		if err != nil {
+			return nil, err
+		}
+		orphans = append(orphans, userDataOrphans...)
+	}
+
+	blobFiles, err := ioutil.ReadDir(dirs.SnapBlobDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range blobFiles {
+		if fi.IsDir() {
+			continue
+		}
+		path := filepath.Join(dirs.SnapBlobDir, fi.Name())
+		blob := strings.TrimSuffix(fi.Name(), ".partial")
+		if !strings.HasSuffix(blob, ".snap") || blobs[path] {
+			continue
+		}
+		idx := strings.LastIndex(blob, "_")
+		if idx < 0 {
+			continue
+		}
+		name := blob[:idx]
+		if inFlight[name] {
+			continue
+		}
+		orphans = append(orphans, &Orphan{Kind: OrphanBlob, Snap: name, Path: path})
+	}
+
+	units, err := filepath.Glob(filepath.Join(dirs.SnapServicesDir, "*.mount"))
+	if err != nil {
+		return nil, err
+	}
+	snapMountDir := dirs.StripRootDir(dirs.SnapMountDir)
+	for _, unit := range units {
+		where, err := mountUnitWhere(unit)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(snapMountDir, where)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		// only <name>/<revision> mounts are snap revisions
+		parts := strings.Split(rel, "/")
+		if len(parts) != 2 {
+			continue
+		}
+		if _, err := snap.ParseRevision(parts[1]); err != nil {
+			continue
+		}
+		mountDir := filepath.Join(dirs.SnapMountDir, rel)
+		if mountDirs[mountDir] || inFlight[parts[0]] {
+			continue
+		}
+		orphans = append(orphans, &Orphan{Kind: OrphanMountUnit, Snap: parts[0], Path: unit, mountDir: mountDir})
+	}
+
+	sort.Sort(byKindAndPath(orphans))
+	return orphans, nil
+}
+
+type byKindAndPath []*Orphan
+
+func (o byKindAndPath) Len() int      { return len(o) }
+func (o byKindAndPath) Swap(i, j int) { o[i], o[j] = o[j], o[i] }
+func (o byKindAndPath) Less(i, j int) bool {
+	if o[i].Kind != o[j].Kind {
+		return o[i].Kind < o[j].Kind
+	}
+	return o[i].Path < o[j].Path
+}
+
+// RemoveOrphans removes the given orphans, as returned by FindOrphans,
+// from the system.
+// The caller should be holding the state lock.
+func (m *SnapManager) RemoveOrphans(orphans []*Orphan) error {
+	for _, o := range orphans {
+		var err error
+		switch o.Kind {
+		case OrphanData, OrphanUserData:
+			err = os.RemoveAll(o.Path)
+		case OrphanBlob:
+			err = os.Remove(o.Path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		case OrphanMountUnit:
+			err = m.backend.RemoveOrphanedMountUnit(o.mountDir, &progress.NullProgress{})
+		default:
+			err = fmt.Errorf("internal error: unknown orphan kind %q", o.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot remove %s of snap %q at %q: %v", o.Kind, o.Snap, o.Path, err)
+		}
+	}
+	return nil
+}
+
+// ensureOrphansCleanup looks for orphaned snap data periodically,
+// reports it, and removes it if the cleanup.remove-orphans core option
+// is set.
+func (m *SnapManager) ensureOrphansCleanup() error {
+	// sneakily don't do anything if in testing
+	if CanAutoRefresh == nil {
+		return nil
+	}
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := time.Now()
+	if !m.nextOrphansCleanup.IsZero() && m.nextOrphansCleanup.After(now) {
+		return nil
+	}
+	m.nextOrphansCleanup = now.Add(orphansCleanupDelay)
+
+	orphans, err := m.FindOrphans()
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	var remove bool
+	tr := config.NewTransaction(m.state)
+	if err := tr.Get("core", "cleanup.remove-orphans", &remove); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	for _, o := range orphans {
+		logger.Noticef("Found orphaned %s of snap %q at %q", o.Kind, o.Snap, o.Path)
+	}
+	if !remove {
+		return nil
+	}
+	return m.RemoveOrphans(orphans)
+}
@@ -0,0 +1,214 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func writeMountUnit(c *C, name string, rev snap.Revision) string {
+	where := filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), name, rev.String())
+	unit := systemd.MountUnitPath(where)
+	c.Assert(os.MkdirAll(filepath.Dir(unit), 0755), IsNil)
+	content := fmt.Sprintf("[Unit]\nDescription=Mount unit for %s\n\n[Mount]\nWhere=%s\n", name, where)
+	c.Assert(ioutil.WriteFile(unit, []byte(content), 0644), IsNil)
+	return unit
+}
+
+func touch(c *C, path string) {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path, nil, 0644), IsNil)
+}
+
+// setupOrphans installs some-snap at revisions 1 and 2 and leaves
+// data around for other-snap and for the removed revision 3 of
+// some-snap.
+func (s *snapmgrTestSuite) setupOrphans(c *C) {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(1)},
+			{RealName: "some-snap", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+
+	for _, rev := range []snap.Revision{snap.R(1), snap.R(2), snap.R(3)} {
+		info := snap.MinimalPlaceInfo("some-snap", rev)
+		touch(c, info.MountFile())
+		writeMountUnit(c, "some-snap", rev)
+		c.Assert(os.MkdirAll(info.DataDir(), 0755), IsNil)
+	}
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/home/user1/snap/some-snap"), 0755), IsNil)
+
+	touch(c, filepath.Join(dirs.SnapBlobDir, "other-snap_7.snap"))
+	touch(c, filepath.Join(dirs.SnapBlobDir, "other-snap_8.snap.partial"))
+	writeMountUnit(c, "other-snap", snap.R(7))
+	c.Assert(os.MkdirAll(filepath.Join(dirs.SnapDataDir, "other-snap", "7"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/home/user1/snap/other-snap"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/root/snap/other-snap"), 0755), IsNil)
+
+	// things that are not ours are left alone
+	touch(c, filepath.Join(dirs.SnapBlobDir, "README"))
+	c.Assert(os.MkdirAll(dirs.SnapPreDownloadDir, 0755), IsNil)
+	touch(c, filepath.Join(dirs.SnapServicesDir, "home.mount"))
+}
+
+func (s *snapmgrTestSuite) TestFindOrphans(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupOrphans(c)
+
+	orphans, err := s.snapmgr.FindOrphans()
+	c.Assert(err, IsNil)
+
+	type orphan struct{ kind, snap, path string }
+	var found []orphan
+	for _, o := range orphans {
+		found = append(found, orphan{o.Kind, o.Snap, o.Path})
+	}
+	c.Check(found, DeepEquals, []orphan{
+		{"blob", "other-snap", filepath.Join(dirs.SnapBlobDir, "other-snap_7.snap")},
+		{"blob", "other-snap", filepath.Join(dirs.SnapBlobDir, "other-snap_8.snap.partial")},
+		{"blob", "some-snap", filepath.Join(dirs.SnapBlobDir, "some-snap_3.snap")},
+		{"data", "other-snap", filepath.Join(dirs.SnapDataDir, "other-snap")},
+		{"mount-unit", "other-snap", systemd.MountUnitPath(filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "other-snap", "7"))},
+		{"mount-unit", "some-snap", systemd.MountUnitPath(filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "some-snap", "3"))},
+		{"user-data", "other-snap", filepath.Join(dirs.GlobalRootDir, "/home/user1/snap/other-snap")},
+		{"user-data", "other-snap", filepath.Join(dirs.GlobalRootDir, "/root/snap/other-snap")},
+	})
+}
+
+func (s *snapmgrTestSuite) TestFindOrphansSkipsSnapsInFlight(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupOrphans(c)
+
+	chg := s.state.NewChange("install", "install other-snap")
+	t := s.state.NewTask("download-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "other-snap", Revision: snap.R(8)},
+	})
+	chg.AddTask(t)
+
+	orphans, err := s.snapmgr.FindOrphans()
+	c.Assert(err, IsNil)
+	c.Assert(orphans, HasLen, 2)
+	c.Check(orphans[0].Kind, Equals, "blob")
+	c.Check(orphans[0].Snap, Equals, "some-snap")
+	c.Check(orphans[1].Kind, Equals, "mount-unit")
+	c.Check(orphans[1].Snap, Equals, "some-snap")
+}
+
+func (s *snapmgrTestSuite) TestRemoveOrphans(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupOrphans(c)
+
+	orphans, err := s.snapmgr.FindOrphans()
+	c.Assert(err, IsNil)
+	c.Assert(orphans, HasLen, 8)
+
+	err = s.snapmgr.RemoveOrphans(orphans)
+	c.Assert(err, IsNil)
+
+	for _, o := range orphans {
+		if o.Kind == "mount-unit" {
+			continue
+		}
+		c.Check(osutil.FileExists(o.Path), Equals, false, Commentf(o.Path))
+	}
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{op: "remove-orphaned-mount-unit", name: filepath.Join(dirs.SnapMountDir, "other-snap/7")},
+		{op: "remove-orphaned-mount-unit", name: filepath.Join(dirs.SnapMountDir, "some-snap/3")},
+	})
+
+	// the installed revisions are untouched
+	for _, rev := range []snap.Revision{snap.R(1), snap.R(2)} {
+		info := snap.MinimalPlaceInfo("some-snap", rev)
+		c.Check(osutil.FileExists(info.MountFile()), Equals, true)
+		c.Check(osutil.IsDirectory(info.DataDir()), Equals, true)
+	}
+	c.Check(osutil.IsDirectory(filepath.Join(dirs.GlobalRootDir, "/home/user1/snap/some-snap")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "README")), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestEnsureOrphansCleanupReportsOnly(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.setupOrphans(c)
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	c.Check(logbuf.String(), testutil.Contains, fmt.Sprintf(`Found orphaned data of snap "other-snap" at %q`, filepath.Join(dirs.SnapDataDir, "other-snap")))
+	c.Check(osutil.IsDirectory(filepath.Join(dirs.SnapDataDir, "other-snap")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "some-snap_3.snap")), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestEnsureOrphansCleanupRemoves(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+
+	s.setupOrphans(c)
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "cleanup.remove-orphans", true)
+	tr.Commit()
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	c.Check(osutil.IsDirectory(filepath.Join(dirs.SnapDataDir, "other-snap")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "some-snap_3.snap")), Equals, false)
+
+	// and it is not attempted again until the next day
+	touch(c, filepath.Join(dirs.SnapBlobDir, "some-snap_3.snap"))
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "some-snap_3.snap")), Equals, true)
+}
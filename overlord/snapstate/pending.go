@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Reasons for a pending refresh to be held back, see RefreshHold.
+const (
+	RefreshHeldByValidation = "validation"
+	RefreshHeldByDevMode    = "devmode"
+	RefreshHeldByTryMode    = "try-mode"
+	RefreshHeldByRevert     = "reverted"
+	RefreshHeldByChange     = "change-in-progress"
+)
+
+// RefreshHold explains why a pending refresh is not going to be
+// applied by auto-refresh.
+type RefreshHold struct {
+	Reason  string
+	Message string
+}
+
+// PendingRefresh describes an update available for an installed snap.
+type PendingRefresh struct {
+	Name    string
+	Current snap.Revision
+	// Channel is the channel the snap is tracking.
+	Channel   string
+	Candidate *snap.Info
+	Held      []RefreshHold
+}
+
+// PendingRefreshes returns the updates available for the installed
+// snaps, sorted by name, along with whatever holds each of them back.
+// Note that the state must be locked by the caller.
+func PendingRefreshes(st *state.State, user *auth.UserState) ([]*PendingRefresh, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+
+	// ask for every snap explicitly, so that the ones
+	// auto-refresh skips are listed as well
+	var names []string
+	for name, snapst := range snapStates {
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil || info.SnapID == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	updates, stateByID, err := refreshCandidates(st, names, user, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := 0
+	if user != nil {
+		userID = user.ID
+	}
+
+	pending := make([]*PendingRefresh, 0, len(updates))
+	for _, update := range updates {
+		snapst := stateByID[update.SnapID]
+		pr := &PendingRefresh{
+			Name:      update.Name(),
+			Current:   snapst.Current,
+			Channel:   snapst.Channel,
+			Candidate: update,
+		}
+		hold := func(reason, format string, a ...interface{}) {
+			pr.Held = append(pr.Held, RefreshHold{Reason: reason, Message: fmt.Sprintf(format, a...)})
+		}
+
+		if snapst.DevMode {
+			hold(RefreshHeldByDevMode, "snaps in devmode are not refreshed automatically")
+		}
+		if snapst.TryMode {
+			hold(RefreshHeldByTryMode, "snaps in try mode are not refreshed automatically")
+		}
+		for _, rev := range snapst.Block() {
+			if rev == update.Revision {
+				hold(RefreshHeldByRevert, "revision %s was reverted", rev)
+				break
+			}
+		}
+		if ValidateRefreshes != nil {
+			if _, err := ValidateRefreshes(st, []*snap.Info{update}, userID); err != nil {
+				hold(RefreshHeldByValidation, "%v", err)
+			}
+		}
+		if err := CheckChangeConflict(st, pr.Name, nil, nil); err != nil {
+			hold(RefreshHeldByChange, "%v", err)
+		}
+
+		pending = append(pending, pr)
+	}
+
+	sort.Sort(byPendingName(pending))
+	return pending, nil
+}
+
+type byPendingName []*PendingRefresh
+
+func (bn byPendingName) Len() int           { return len(bn) }
+func (bn byPendingName) Swap(i, j int)      { bn[i], bn[j] = bn[j], bn[i] }
+func (bn byPendingName) Less(i, j int) bool { return bn[i].Name < bn[j].Name }
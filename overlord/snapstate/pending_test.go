@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) TestPendingRefreshesNotHeld(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+	})
+
+	pending, err := snapstate.PendingRefreshes(s.state, s.user)
+	c.Assert(err, IsNil)
+	c.Assert(pending, HasLen, 1)
+	c.Check(pending[0].Name, Equals, "some-snap")
+	c.Check(pending[0].Current, Equals, snap.R(7))
+	c.Check(pending[0].Channel, Equals, "stable")
+	c.Check(pending[0].Candidate.Revision, Equals, snap.R(11))
+	c.Check(pending[0].Held, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestPendingRefreshesHeld(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(11)},
+		},
+		Current: snap.R(7),
+		Flags:   snapstate.Flags{DevMode: true},
+	})
+	// sideloaded snaps have no refreshes
+	snapstate.Set(s.state, "local-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "local-snap", Revision: snap.R(-1)}},
+		Current:  snap.R(-1),
+	})
+
+	snapstate.ValidateRefreshes = func(st *state.State, refreshes []*snap.Info, userID int) ([]*snap.Info, error) {
+		c.Check(userID, Equals, s.user.ID)
+		return nil, errors.New(`cannot refresh "some-snap" to revision 11: no validation by "gating-snap"`)
+	}
+
+	chg := s.state.NewChange("refresh", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "some-snap"}})
+	chg.AddTask(t)
+
+	pending, err := snapstate.PendingRefreshes(s.state, s.user)
+	c.Assert(err, IsNil)
+	c.Assert(pending, HasLen, 1)
+	c.Check(pending[0].Name, Equals, "some-snap")
+	c.Check(pending[0].Held, DeepEquals, []snapstate.RefreshHold{
+		{Reason: snapstate.RefreshHeldByDevMode, Message: "snaps in devmode are not refreshed automatically"},
+		{Reason: snapstate.RefreshHeldByRevert, Message: "revision 11 was reverted"},
+		{Reason: snapstate.RefreshHeldByValidation, Message: `cannot refresh "some-snap" to revision 11: no validation by "gating-snap"`},
+		{Reason: snapstate.RefreshHeldByChange, Message: `snap "some-snap" has changes in progress`},
+	})
+}
+
+func (s *snapmgrTestSuite) TestPendingRefreshesNothingFromStore(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	pending, err := snapstate.PendingRefreshes(s.state, nil)
+	c.Assert(err, IsNil)
+	c.Check(pending, HasLen, 0)
+}
@@ -0,0 +1,273 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/storestate"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// preDownloadAhead is how long before the next scheduled auto-refresh
+// the snaps it will need start being downloaded in the background.
+var preDownloadAhead = 4 * time.Hour
+
+// isOnMeteredConnection asks NetworkManager whether the system is
+// using a metered connection, like a mobile data plan.
+var isOnMeteredConnection = func() (bool, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false, err
+	}
+	nm := conn.Object("org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager")
+	v, err := nm.GetProperty("org.freedesktop.NetworkManager.Metered")
+	if err != nil {
+		return false, err
+	}
+	metered, ok := v.Value().(uint32)
+	if !ok {
+		return false, fmt.Errorf("unexpected type %T of the NetworkManager Metered property", v.Value())
+	}
+	// NM_METERED_YES and NM_METERED_GUESS_YES
+	return metered == 1 || metered == 3, nil
+}
+
+// getRefreshRateLimit returns the maximum speed in bytes per second
+// of background downloads, as set by the refresh.rate-limit core
+// option (a size such as "512kB"), or zero if they are not limited.
+// Invalid values are ignored.
+func getRefreshRateLimit(st *state.State) (int64, error) {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "refresh.rate-limit", &value)
+	if config.IsNoOption(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	limit, err := strutil.ParseByteSize(value)
+	if err != nil {
+		logger.Noticef("cannot use refresh.rate-limit configuration: %v", err)
+		return 0, nil
+	}
+	return limit, nil
+}
+
+// preDownloadPath returns where the snap is stored once downloaded
+// in the background ahead of an auto-refresh.
+func preDownloadPath(snapsup *SnapSetup) string {
+	return filepath.Join(dirs.SnapPreDownloadDir, filepath.Base(snapsup.MountFile()))
+}
+
+// usePreDownloaded moves a complete pre-downloaded copy of the snap,
+// if there is one, to targetFn. A pre-downloaded copy that does not
+// match the expected size and hash is removed.
+func usePreDownloaded(snapsup *SnapSetup, targetFn string) bool {
+	if snapsup.DownloadInfo == nil || snapsup.DownloadInfo.Sha3_384 == "" {
+		return false
+	}
+	path := preDownloadPath(snapsup)
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil || digest != snapsup.DownloadInfo.Sha3_384 || int64(size) != snapsup.DownloadInfo.Size {
+		logger.Noticef("Discarding pre-downloaded snap %q that does not match the expected revision", path)
+		os.Remove(path)
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(targetFn), 0755); err != nil {
+		return false
+	}
+	if err := os.Rename(path, targetFn); err != nil {
+		logger.Noticef("Cannot use pre-downloaded snap %q: %v", path, err)
+		return false
+	}
+	return true
+}
+
+func preDownloadInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "pre-download" && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// abortPreDownloads stops the background downloads still in progress,
+// the foreground refresh takes over from there.
+func abortPreDownloads(st *state.State) {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "pre-download" && !chg.Status().Ready() {
+			chg.Abort()
+		}
+	}
+}
+
+// ensurePreDownloads starts downloading in the background the snaps
+// the next auto-refresh will need, once it is close enough, so that
+// the refresh itself only has to swap the revisions.
+func (m *SnapManager) ensurePreDownloads() error {
+	if m.nextRefresh.IsZero() || m.preDownloadFor.Equal(m.nextRefresh) {
+		return nil
+	}
+	now := time.Now()
+	if !m.nextRefresh.After(now) || m.nextRefresh.Sub(now) > preDownloadAhead {
+		return nil
+	}
+	if preDownloadInFlight(m.state) {
+		return nil
+	}
+	// try only once for each scheduled refresh
+	m.preDownloadFor = m.nextRefresh
+
+	// whether the connection is metered is only checked by the tasks,
+	// asking NetworkManager with the state locked could stall everything
+	return m.launchPreDownload()
+}
+
+func (m *SnapManager) launchPreDownload() error {
+	updates, stateByID, err := refreshCandidates(m.state, nil, nil, nil)
+	if err != nil {
+		logger.Noticef("Cannot prepare pre-download change: %s", err)
+		return err
+	}
+
+	var names []string
+	var tasks []*state.Task
+	wanted := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		snapsup := &SnapSetup{
+			Channel:      stateByID[update.SnapID].Channel,
+			DownloadInfo: &update.DownloadInfo,
+			SideInfo:     &update.SideInfo,
+		}
+		path := preDownloadPath(snapsup)
+		wanted[filepath.Base(path)] = true
+		if _, err := os.Stat(path); err == nil {
+			// already downloaded
+			continue
+		}
+
+		t := m.state.NewTask("pre-download-snap", fmt.Sprintf(i18n.G("Pre-download snap %q (%s) from channel %q"), snapsup.Name(), snapsup.Revision(), snapsup.Channel))
+		t.Set("snap-setup", snapsup)
+		// one download at a time so that they share the rate limit
+		if len(tasks) > 0 {
+			t.WaitFor(tasks[len(tasks)-1])
+		}
+		tasks = append(tasks, t)
+		names = append(names, snapsup.Name())
+	}
+
+	prunePreDownloads(wanted)
+
+	var msg string
+	switch len(names) {
+	case 0:
+		logger.Debugf("No snaps to pre-download found")
+		return nil
+	case 1:
+		msg = fmt.Sprintf(i18n.G("Pre-download snap %q for the next auto-refresh"), names[0])
+	case 2, 3:
+		quoted := strutil.Quoted(names)
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		msg = fmt.Sprintf(i18n.G("Pre-download snaps %s for the next auto-refresh"), quoted)
+	default:
+		msg = fmt.Sprintf(i18n.G("Pre-download %d snaps for the next auto-refresh"), len(names))
+	}
+
+	chg := m.state.NewChange("pre-download", msg)
+	chg.AddAll(state.NewTaskSet(tasks...))
+	chg.Set("snap-names", names)
+
+	return nil
+}
+
+// prunePreDownloads removes pre-downloaded snaps, complete or not,
+// that the next auto-refresh will not use.
+func prunePreDownloads(wanted map[string]bool) {
+	entries, err := filepath.Glob(filepath.Join(dirs.SnapPreDownloadDir, "*"))
+	if err != nil {
+		return
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		if wanted[strings.TrimSuffix(filepath.Base(entry), ".partial")] {
+			continue
+		}
+		if err := os.Remove(entry); err != nil {
+			logger.Noticef("Cannot remove stale pre-downloaded snap %q: %v", entry, err)
+		}
+	}
+}
+
+func (m *SnapManager) doPreDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	snapsup, err := TaskSnapSetup(t)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	theStore := storestate.Store(st)
+	rateLimit, err := getRefreshRateLimit(st)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// the connection could have changed since the change was created
+	if metered, err := isOnMeteredConnection(); err == nil && metered {
+		st.Lock()
+		t.Logf("Skipped on a metered connection")
+		st.Unlock()
+		return nil
+	}
+
+	meter := NewTaskProgressAdapterUnlocked(t)
+	dlOpts := &store.DownloadOptions{RateLimit: rateLimit}
+	err = theStore.Download(tomb.Context(nil), snapsup.Name(), preDownloadPath(snapsup), snapsup.DownloadInfo, meter, nil, dlOpts)
+	if err != nil {
+		// pre-downloading is best effort, the refresh
+		// itself will try again
+		st.Lock()
+		t.Logf("Cannot pre-download snap %q: %v", snapsup.Name(), err)
+		st.Unlock()
+	}
+	return nil
+}
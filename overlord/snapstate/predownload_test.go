@@ -0,0 +1,196 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// setupPreDownload sets up an auto-refresh that is scheduled in the
+// future and an installed snap that the fake store has an update for
+func (s *snapmgrTestSuite) setupPreDownload(c *C) {
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+
+	now := time.Now()
+	s.state.Set("last-refresh", now.Add(-1*time.Hour))
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.schedule", fmt.Sprintf("00:00-%02d:%02d", now.Hour(), now.Minute()))
+	tr.Commit()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		Channel:  "stable",
+		SnapType: "app",
+	})
+}
+
+func (s *snapmgrTestSuite) TestEnsurePreDownloads(c *C) {
+	restore := snapstate.MockPreDownloadAhead(72 * time.Hour)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupPreDownload(c)
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.rate-limit", "512kB")
+	tr.Commit()
+
+	// Ensure() also runs ensureRefreshes()
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	// no refresh yet, but a pre-download of the update
+	c.Check(s.snapmgr.NextRefresh().After(time.Now()), Equals, true)
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+	c.Check(chg.Kind(), Equals, "pre-download")
+	c.Check(chg.Summary(), Equals, `Pre-download snap "some-snap" for the next auto-refresh`)
+	c.Assert(chg.Tasks(), HasLen, 1)
+	t := chg.Tasks()[0]
+	c.Check(t.Kind(), Equals, "pre-download-snap")
+	c.Check(t.Summary(), Equals, `Pre-download snap "some-snap" (11) from channel "stable"`)
+	snapsup, err := snapstate.TaskSnapSetup(t)
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Revision(), Equals, snap.R(11))
+	c.Check(snapsup.DownloadInfo, NotNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeStore.downloads, DeepEquals, []fakeDownload{
+		{name: "some-snap", rateLimit: 512000},
+	})
+
+	// only one pre-download per scheduled refresh
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *snapmgrTestSuite) TestEnsurePreDownloadsTooEarly(c *C) {
+	restore := snapstate.MockPreDownloadAhead(time.Nanosecond)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupPreDownload(c)
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestEnsurePreDownloadsMetered(c *C) {
+	restore := snapstate.MockPreDownloadAhead(72 * time.Hour)
+	defer restore()
+	restore = snapstate.MockIsOnMeteredConnection(func() (bool, error) { return true, nil })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupPreDownload(c)
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	// the task checks the connection, without holding the state lock
+	c.Assert(s.state.Changes(), HasLen, 1)
+	chg := s.state.Changes()[0]
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeStore.downloads, HasLen, 0)
+	c.Check(strings.Join(chg.Tasks()[0].Log(), "\n"), Matches, `.* Skipped on a metered connection`)
+}
+
+func (s *snapmgrTestSuite) TestEnsurePreDownloadsSkipsDownloadedAndPrunes(c *C) {
+	restore := snapstate.MockPreDownloadAhead(72 * time.Hour)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setupPreDownload(c)
+
+	downloaded := snapstate.PreDownloadPath(&snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "some-snap", Revision: snap.R(11)},
+	})
+	stale := filepath.Join(dirs.SnapPreDownloadDir, "some-snap_10.snap")
+	staleGone := filepath.Join(dirs.SnapPreDownloadDir, "gone-snap_3.snap.partial")
+	c.Assert(os.MkdirAll(dirs.SnapPreDownloadDir, 0755), IsNil)
+	for _, fn := range []string{downloaded, stale, staleGone} {
+		c.Assert(ioutil.WriteFile(fn, nil, 0644), IsNil)
+	}
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(osutil.FileExists(downloaded), Equals, true)
+	c.Check(osutil.FileExists(stale), Equals, false)
+	c.Check(osutil.FileExists(staleGone), Equals, false)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshAbortsPreDownloads(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+
+	makeTestRefreshConfig(s.state)
+
+	preDownload := s.state.NewChange("pre-download", "...")
+	preDownload.AddTask(s.state.NewTask("pre-download-snap", "..."))
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	c.Check(preDownload.Status().Ready(), Equals, true)
+	c.Check(preDownload.Status(), Not(Equals), state.ErrorStatus)
+}
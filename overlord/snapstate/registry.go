@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/context"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store/oci"
+)
+
+// VerifyRegistrySnap allows to hook adding the assertions published in a
+// registry alongside a snap to the system assertion database, returning
+// the side info of the snap file they verify.
+var VerifyRegistrySnap func(st *state.State, snapPath string, assertions []byte) (*snap.SideInfo, error)
+
+func ociDownloadImpl(ctx context.Context, ref *oci.Reference, targetPath string) (*oci.Artifact, error) {
+	cfg, err := oci.LoadConfig(ref.Registry)
+	if err != nil {
+		return nil, err
+	}
+	return oci.New(cfg).Download(ctx, ref, targetPath)
+}
+
+var ociDownload = ociDownloadImpl
+
+// InstallFromRegistry returns a set of tasks for installing the snap
+// published as an OCI artifact, together with the assertions needed to
+// verify it, in a container registry. The snap is only downloaded and
+// verified by the tasks.
+// Note that the state must be locked by the caller.
+func InstallFromRegistry(st *state.State, name, source, channel string, userID int, flags Flags) (*state.TaskSet, error) {
+	ref, err := oci.ParseReference(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapst SnapState
+	err = Get(st, name, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if snapst.IsInstalled() {
+		return nil, &snap.AlreadyInstalledError{Snap: name}
+	}
+
+	snapsup := &SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: name},
+		Channel:  channel,
+		UserID:   userID,
+		Flags:    flags.ForSnapSetup(),
+		Registry: ref.String(),
+	}
+
+	return doInstall(st, &snapst, snapsup, maybeCore)
+}
+
+// registryDownloadPath returns where the snap fetched by the given
+// download-oci-snap task is put, until it is mounted.
+func registryDownloadPath(t *state.Task, snapName string) string {
+	return filepath.Join(dirs.SnapBlobDir, fmt.Sprintf(".%s_%s.oci", snapName, t.ID()))
+}
+
+func (m *SnapManager) doDownloadOCISnap(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	snapsup, err := TaskSnapSetup(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ref, err := oci.ParseReference(snapsup.Registry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dirs.SnapBlobDir, 0755); err != nil {
+		return err
+	}
+	targetPath := registryDownloadPath(t, snapsup.Name())
+	artifact, err := ociDownload(tomb.Context(nil), ref, targetPath)
+	if err != nil {
+		return err
+	}
+	removeDownload := true
+	defer func() {
+		if removeDownload {
+			os.Remove(targetPath)
+		}
+	}()
+
+	st.Lock()
+	defer st.Unlock()
+
+	if VerifyRegistrySnap == nil {
+		return fmt.Errorf("internal error: cannot verify snaps from registries")
+	}
+	si, err := VerifyRegistrySnap(st, targetPath, artifact.Assertions)
+	if err != nil {
+		return err
+	}
+	if si.RealName != snapsup.Name() {
+		return fmt.Errorf("cannot install snap %q from %s: artifact contains snap %q", snapsup.Name(), ref, si.RealName)
+	}
+
+	info, _, err := openSnapFile(targetPath, si)
+	if err != nil {
+		return err
+	}
+
+	// what the snap needs is only known now, the prerequisites task
+	// waits for this one
+	snapsup.SideInfo = si
+	snapsup.SnapPath = targetPath
+	snapsup.Flags.RemoveSnapPath = true
+	snapsup.Base = info.Base
+	snapsup.Epoch = info.Epoch
+	snapsup.Requires = info.Requires
+	snapsup.Recommends = info.Recommends
+
+	t.Set("snap-setup", snapsup)
+	removeDownload = false
+	return nil
+}
+
+func (m *SnapManager) undoDownloadOCISnap(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	snapsup, err := TaskSnapSetup(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// mount-snap removes it once it's in place, unless it failed
+	if err := os.Remove(registryDownloadPath(t, snapsup.Name())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return m.undoPrepareSnap(t, tomb)
+}
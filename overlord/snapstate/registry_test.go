@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store/oci"
+)
+
+func (s *snapmgrTestSuite) TestInstallFromRegistryTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.InstallFromRegistry(s.state, "some-snap", "oci://registry.example.com/acme/some-snap", "beta", 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	tasks := ts.Tasks()
+	var kinds []string
+	for _, t := range tasks[:4] {
+		kinds = append(kinds, t.Kind())
+	}
+	// the snap is downloaded before its prerequisites are known
+	c.Check(kinds, DeepEquals, []string{"download-oci-snap", "prerequisites", "mount-snap", "copy-snap-data"})
+	c.Check(tasks[0].Summary(), Equals, `Download snap "some-snap" from "oci://registry.example.com/acme/some-snap:latest"`)
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{tasks[0]})
+	for _, t := range tasks[1:4] {
+		var id string
+		c.Assert(t.Get("snap-setup-task", &id), IsNil)
+		c.Check(id, Equals, tasks[0].ID())
+	}
+
+	snapsup, err := snapstate.TaskSnapSetup(tasks[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Registry, Equals, "oci://registry.example.com/acme/some-snap:latest")
+	c.Check(snapsup.Channel, Equals, "beta")
+	c.Check(snapsup.SnapPath, Equals, "")
+}
+
+func (s *snapmgrTestSuite) TestInstallFromRegistryErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.InstallFromRegistry(s.state, "some-snap", "oci://some-snap", "", 0, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `cannot parse OCI reference "oci://some-snap": missing registry`)
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+	})
+	_, err = snapstate.InstallFromRegistry(s.state, "some-snap", "oci://registry.example.com/acme/some-snap", "", 0, snapstate.Flags{})
+	c.Check(err, DeepEquals, &snap.AlreadyInstalledError{Snap: "some-snap"})
+}
+
+func (s *snapmgrTestSuite) mockRegistry(c *C, snapYaml string, verifiedName string) (restore func()) {
+	restoreDownload := snapstate.MockOCIDownload(func(ctx context.Context, ref *oci.Reference, targetPath string) (*oci.Artifact, error) {
+		c.Check(ref.String(), Equals, "oci://registry.example.com/acme/some-snap:1.0")
+		c.Assert(ioutil.WriteFile(targetPath, []byte("snap-data"), 0600), IsNil)
+		return &oci.Artifact{Assertions: []byte("assertions")}, nil
+	})
+	oldVerify := snapstate.VerifyRegistrySnap
+	snapstate.VerifyRegistrySnap = func(st *state.State, snapPath string, assertions []byte) (*snap.SideInfo, error) {
+		c.Check(string(assertions), Equals, "assertions")
+		c.Check(osutil.FileExists(snapPath), Equals, true)
+		return &snap.SideInfo{RealName: verifiedName, SnapID: "some-snap-id", Revision: snap.R(41)}, nil
+	}
+	restoreOpenSnapFile := snapstate.MockOpenSnapFile(func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+		info, err := snap.InfoFromSnapYaml([]byte(snapYaml))
+		return info, nil, err
+	})
+	return func() {
+		restoreDownload()
+		snapstate.VerifyRegistrySnap = oldVerify
+		restoreOpenSnapFile()
+	}
+}
+
+func (s *snapmgrTestSuite) runDownloadOCISnap(c *C) *state.Task {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	t := s.state.NewTask("download-oci-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "some-snap"},
+		Registry: "oci://registry.example.com/acme/some-snap:1.0",
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+
+	s.state.Unlock()
+	defer s.state.Lock()
+	s.settle(c)
+	return t
+}
+
+func (s *snapmgrTestSuite) TestDoDownloadOCISnap(c *C) {
+	restore := s.mockRegistry(c, "name: some-snap\nversion: 1.0\nbase: core18\nrequires: [other-snap]", "some-snap")
+	defer restore()
+
+	t := s.runDownloadOCISnap(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(t.Status(), Equals, state.DoneStatus)
+
+	snapsup, err := snapstate.TaskSnapSetup(t)
+	c.Assert(err, IsNil)
+	c.Check(snapsup.SideInfo, DeepEquals, &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(41)})
+	c.Check(snapsup.Base, Equals, "core18")
+	c.Check(snapsup.Requires, DeepEquals, []string{"other-snap"})
+	c.Check(snapsup.Flags.RemoveSnapPath, Equals, true)
+	// the snap is kept until it is mounted
+	c.Check(osutil.FileExists(snapsup.SnapPath), Equals, true)
+	os.Remove(snapsup.SnapPath)
+}
+
+func (s *snapmgrTestSuite) TestDoDownloadOCISnapOtherSnap(c *C) {
+	restore := s.mockRegistry(c, "name: other-snap\nversion: 1.0", "other-snap")
+	defer restore()
+
+	t := s.runDownloadOCISnap(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(t.Status(), Equals, state.ErrorStatus)
+	c.Check(strings.Join(t.Log(), "\n"), Matches, `(?s).*cannot install snap "some-snap" from oci://registry.example.com/acme/some-snap:1.0: artifact contains snap "other-snap".*`)
+
+	// nothing is left behind
+	files, err := ioutil.ReadDir(dirs.SnapBlobDir)
+	c.Assert(err, IsNil)
+	c.Check(files, HasLen, 0)
+}
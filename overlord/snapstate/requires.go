@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// requiresGraph returns, for every snap installed or being installed
+// by chg, the snaps it requires.
+func requiresGraph(st *state.State, chg *state.Change) (map[string][]string, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	graph := make(map[string][]string, len(snapStates))
+	for name, snapst := range snapStates {
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			// broken snaps cannot require anything
+			continue
+		}
+		graph[name] = info.Requires
+	}
+	if chg != nil {
+		for _, t := range chg.Tasks() {
+			if t.Kind() != "prerequisites" {
+				continue
+			}
+			snapsup, err := TaskSnapSetup(t)
+			if err != nil {
+				return nil, err
+			}
+			graph[snapsup.Name()] = snapsup.Requires
+		}
+	}
+	return graph, nil
+}
+
+// checkRequiresCycle returns an error if the snap described by snapsup
+// would end up requiring itself, through the snaps that are installed
+// or being installed by chg.
+func checkRequiresCycle(st *state.State, chg *state.Change, snapsup *SnapSetup) error {
+	if len(snapsup.Requires) == 0 {
+		return nil
+	}
+	graph, err := requiresGraph(st, chg)
+	if err != nil {
+		return err
+	}
+	snapName := snapsup.Name()
+	graph[snapName] = snapsup.Requires
+
+	var path []string
+	visited := make(map[string]bool)
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		path = append(path, name)
+		if name == snapName && len(path) > 1 {
+			return true
+		}
+		if !visited[name] {
+			visited[name] = true
+			for _, req := range graph[name] {
+				if visit(req) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(snapName) {
+		return fmt.Errorf("cannot install snap %q: dependency cycle %s", snapName, strings.Join(path, " -> "))
+	}
+	return nil
+}
+
+// checkNotRequired returns an error if any installed snap other than
+// the ones in ignore requires the named snap.
+func checkNotRequired(st *state.State, name string, ignore map[string]bool) error {
+	graph, err := requiresGraph(st, nil)
+	if err != nil {
+		return err
+	}
+	var requiredBy []string
+	for other, requires := range graph {
+		if other == name || ignore[other] {
+			continue
+		}
+		if strutil.ListContains(requires, name) {
+			requiredBy = append(requiredBy, other)
+		}
+	}
+	if len(requiredBy) == 0 {
+		return nil
+	}
+	sort.Strings(requiredBy)
+	return fmt.Errorf("snap %q is required by %s", name, strutil.Quoted(requiredBy))
+}
@@ -0,0 +1,304 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/storestate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+)
+
+// requiresStore adds requires and recommends to the snaps returned
+// by the fake store.
+type requiresStore struct {
+	*fakeStore
+
+	requires   map[string][]string
+	recommends map[string][]string
+}
+
+func (s requiresStore) SnapInfo(spec store.SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	if spec.Name == "missing-snap" {
+		return nil, store.ErrSnapNotFound
+	}
+	info, err := s.fakeStore.SnapInfo(spec, user)
+	if err != nil {
+		return nil, err
+	}
+	info.Requires = s.requires[spec.Name]
+	info.Recommends = s.recommends[spec.Name]
+	return info, nil
+}
+
+// mockRequires makes the installed snaps require the given snaps.
+func (s *snapmgrTestSuite) mockRequires(requires map[string][]string) (restore func()) {
+	return snapstate.MockReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err != nil {
+			return nil, err
+		}
+		info.Requires = requires[name]
+		return info, nil
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallRequiresRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	storestate.ReplaceStore(s.state, requiresStore{
+		fakeStore:  s.fakeStore,
+		requires:   map[string][]string{"some-snap": {"dep-snap"}},
+		recommends: map[string][]string{"some-snap": {"rec-snap", "missing-snap"}},
+	})
+
+	chg := s.state.NewChange("install", "install a snap with dependencies")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(42), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+
+	for _, name := range []string{"some-snap", "dep-snap", "rec-snap"} {
+		var snapst snapstate.SnapState
+		err = snapstate.Get(s.state, name, &snapst)
+		c.Assert(err, IsNil, Commentf(name))
+		c.Check(snapst.Active, Equals, true, Commentf(name))
+	}
+	var snapst snapstate.SnapState
+	c.Check(snapstate.Get(s.state, "missing-snap", &snapst), Equals, state.ErrNoState)
+
+	// the recommended snap is installed by a change of its own
+	for _, t := range chg.Tasks() {
+		if snapsup, err := snapstate.TaskSnapSetup(t); err == nil {
+			c.Check(snapsup.Name(), Not(Equals), "rec-snap")
+		}
+	}
+	var recChg *state.Change
+	for _, other := range s.state.Changes() {
+		if other != chg {
+			c.Assert(recChg, IsNil)
+			recChg = other
+		}
+	}
+	c.Assert(recChg, NotNil)
+	c.Check(recChg.Kind(), Equals, "install-snap")
+	c.Check(recChg.Summary(), Equals, `Install snaps recommended by "some-snap"`)
+	c.Check(recChg.Err(), IsNil)
+}
+
+func (s *snapmgrTestSuite) TestInstallRecommendsFailureDoesNotFailInstall(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	storestate.ReplaceStore(s.state, requiresStore{
+		fakeStore:  s.fakeStore,
+		recommends: map[string][]string{"some-snap": {"rec-snap"}},
+	})
+	s.fakeBackend.linkSnapFailTrigger = filepath.Join(dirs.SnapMountDir, "rec-snap/11")
+
+	chg := s.state.NewChange("install", "install a snap with recommends")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(42), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapstate.Get(s.state, "rec-snap", &snapst), Equals, state.ErrNoState)
+
+	var failed int
+	for _, other := range s.state.Changes() {
+		if other != chg {
+			c.Check(other.Err(), NotNil)
+			failed++
+		}
+	}
+	c.Check(failed, Equals, 1)
+}
+
+func (s *snapmgrTestSuite) TestUpdateIgnoresRecommends(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	storestate.ReplaceStore(s.state, requiresStore{
+		fakeStore:  s.fakeStore,
+		recommends: map[string][]string{"some-snap": {"rec-snap"}},
+	})
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("refresh", "refresh a snap with recommends")
+	chg.AddAll(ts)
+	for _, t := range ts.Tasks() {
+		if snapsup, err := snapstate.TaskSnapSetup(t); err == nil {
+			c.Check(snapsup.Recommends, HasLen, 0)
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallRequiresMissing(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	storestate.ReplaceStore(s.state, requiresStore{
+		fakeStore: s.fakeStore,
+		requires:  map[string][]string{"some-snap": {"missing-snap"}},
+	})
+
+	chg := s.state.NewChange("install", "install a snap with dependencies")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(42), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot install snap "missing-snap" required by "some-snap": snap not found.*`)
+
+	var snapst snapstate.SnapState
+	c.Check(snapstate.Get(s.state, "some-snap", &snapst), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestInstallRequiresCycle(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := s.mockRequires(map[string][]string{
+		"dep-snap":   {"other-snap"},
+		"other-snap": {"some-snap"},
+	})
+	defer restore()
+
+	storestate.ReplaceStore(s.state, requiresStore{
+		fakeStore: s.fakeStore,
+		requires:  map[string][]string{"some-snap": {"dep-snap"}},
+	})
+	for _, name := range []string{"dep-snap", "other-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{{RealName: name, Revision: snap.R(1)}},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+
+	chg := s.state.NewChange("install", "install a snap with dependencies")
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(42), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot install snap "some-snap": dependency cycle some-snap -> dep-snap -> other-snap -> some-snap.*`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveRequired(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := s.mockRequires(map[string][]string{
+		"some-snap":  {"dep-snap"},
+		"other-snap": {"dep-snap"},
+	})
+	defer restore()
+
+	for _, name := range []string{"some-snap", "other-snap", "dep-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{{RealName: name, Revision: snap.R(1)}},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+
+	_, err := snapstate.Remove(s.state, "dep-snap", snap.R(0))
+	c.Check(err, ErrorMatches, `snap "dep-snap" is required by "other-snap", "some-snap"`)
+
+	_, _, err = snapstate.RemoveMany(s.state, []string{"dep-snap", "some-snap"})
+	c.Check(err, ErrorMatches, `snap "dep-snap" is required by "other-snap"`)
+
+	// snaps removed together can require each other
+	removed, _, err := snapstate.RemoveMany(s.state, []string{"dep-snap", "some-snap", "other-snap"})
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"dep-snap", "some-snap", "other-snap"})
+}
+
+func (s *snapmgrTestSuite) TestRemoveRequiredInactiveRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := s.mockRequires(map[string][]string{
+		"some-snap": {"dep-snap"},
+	})
+	defer restore()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	snapstate.Set(s.state, "dep-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "dep-snap", Revision: snap.R(1)},
+			{RealName: "dep-snap", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+
+	// only removing the whole snap is prevented
+	_, err := snapstate.Remove(s.state, "dep-snap", snap.R(1))
+	c.Check(err, IsNil)
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// defaultRetain is the number of revisions of a snap, including the
+// one being installed, that are kept around after a refresh.
+const defaultRetain = 3
+
+// minRetain and maxRetain bound the number of revisions that can be
+// retained; the current revision is always kept so the refresh can be
+// undone.
+const (
+	minRetain = 2
+	maxRetain = 20
+)
+
+// retainPolicy controls how many old revisions of a snap are kept.
+type retainPolicy struct {
+	// Count is the maximum number of revisions kept, including the
+	// one being installed.
+	Count int
+	// MaxSize is, when non-zero, the maximum total size in bytes of
+	// the kept revisions, including the one being installed.
+	MaxSize int64
+}
+
+// getRetainPolicy returns the revision retention policy for the given
+// snap, built from the following core configuration options:
+//
+//	refresh.retain                       number of revisions to keep
+//	refresh.retain-max-size              maximum size in MB of all kept revisions
+//	refresh.snaps.<snap>.retain          per-snap override of refresh.retain
+//	refresh.snaps.<snap>.retain-max-size per-snap override of refresh.retain-max-size
+//
+// Invalid values are ignored.
+func getRetainPolicy(st *state.State, snapName string) (*retainPolicy, error) {
+	policy := &retainPolicy{Count: defaultRetain}
+
+	tr := config.NewTransaction(st)
+	for _, prefix := range []string{"refresh.", fmt.Sprintf("refresh.snaps.%s.", snapName)} {
+		var count, maxSize int
+		err := tr.Get("core", prefix+"retain", &count)
+		if err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		if err == nil {
+			if count < minRetain || count > maxRetain {
+				logger.Noticef("cannot use %sretain configuration: %d is not between %d and %d", prefix, count, minRetain, maxRetain)
+			} else {
+				policy.Count = count
+			}
+		}
+
+		err = tr.Get("core", prefix+"retain-max-size", &maxSize)
+		if err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		if err == nil {
+			if maxSize < 0 {
+				logger.Noticef("cannot use %sretain-max-size configuration: %d is negative", prefix, maxSize)
+			} else {
+				policy.MaxSize = int64(maxSize) * 1024 * 1024
+			}
+		}
+	}
+
+	return policy, nil
+}
+
+// revisionSize returns the size of the installed snap file of the given
+// revision, or 0 if it cannot be determined.
+func revisionSize(name string, revision snap.Revision) int64 {
+	fi, err := os.Stat(snap.MountFile(name, revision))
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// targetSize returns the size of the snap file being installed by
+// snapsup, or 0 if it cannot be determined.
+func targetSize(snapsup *SnapSetup) int64 {
+	if snapsup.DownloadInfo != nil && snapsup.DownloadInfo.Size > 0 {
+		return snapsup.DownloadInfo.Size
+	}
+	if snapsup.SnapPath != "" {
+		if fi, err := os.Stat(snapsup.SnapPath); err == nil {
+			return fi.Size()
+		}
+	}
+	return revisionSize(snapsup.Name(), snapsup.Revision())
+}
+
+// revisionsToDiscard returns the revisions out of seq, which is
+// ordered from oldest to newest and ends with the current revision,
+// that go beyond the given retain policy once the revision of the
+// given size is installed. The current revision is never discarded.
+func revisionsToDiscard(name string, seq []*snap.SideInfo, policy *retainPolicy, newSize int64) []snap.Revision {
+	if len(seq) == 0 {
+		return nil
+	}
+	current := len(seq) - 1
+	keep := policy.Count - 2
+	total := newSize + revisionSize(name, seq[current].Revision)
+	var discard []snap.Revision
+	for i := current - 1; i >= 0; i-- {
+		rev := seq[i].Revision
+		if keep > 0 {
+			total += revisionSize(name, rev)
+			if policy.MaxSize == 0 || total <= policy.MaxSize {
+				keep--
+				continue
+			}
+			// once over the size budget older revisions
+			// are discarded regardless of their size
+			keep = 0
+		}
+		discard = append(discard, rev)
+	}
+	// discard the oldest revisions first
+	for i, j := 0, len(discard)-1; i < j; i, j = i+1, j-1 {
+		discard[i], discard[j] = discard[j], discard[i]
+	}
+	return discard
+}
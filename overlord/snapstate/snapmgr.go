@@ -29,10 +29,10 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
-	"github.com/snapcore/snapd/errtracker"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/errreportstate"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
@@ -64,11 +64,19 @@ import (
 // $ snap set core refresh.schedule=<time spec>
 // and we need to validate the time-spec, ideally internally by
 // intercepting the set call
+//
+// the special "managed" schedule disables auto-refreshes entirely
+// but is only honoured if the model assertion of the device allows
+// it, see CanManageRefreshes
 
 const defaultRefreshSchedule = "00:00-04:59/5:00-10:59/11:00-16:59/17:00-23:59"
 
+// ManagedRefreshSchedule is the refresh.schedule value that disables
+// auto-refreshes, leaving them to an external agent.
+const ManagedRefreshSchedule = "managed"
+
 // overridden in the tests
-var errtrackerReport = errtracker.Report
+var errReport = errreportstate.Report
 var catalogRefreshDelay = 24 * time.Hour
 
 // SnapManager is responsible for the installation and removal of snaps.
@@ -79,8 +87,10 @@ type SnapManager struct {
 	currentRefreshSchedule string
 	nextRefresh            time.Time
 	lastRefreshAttempt     time.Time
+	preDownloadFor         time.Time
 
 	nextCatalogRefresh time.Time
+	nextOrphansCleanup time.Time
 
 	lastUbuntuCoreTransitionAttempt time.Time
 
@@ -93,10 +103,21 @@ type SnapSetup struct {
 	Channel string `json:"channel,omitempty"`
 	UserID  int    `json:"user-id,omitempty"`
 	Base    string `json:"base,omitempty"`
+	// Epoch is the epoch of the snap being installed, if known.
+	Epoch string `json:"epoch,omitempty"`
+	// StoreID is the store the snap comes from if not the device one
+	StoreID string `json:"store-id,omitempty"`
+
+	Requires []string `json:"requires,omitempty"`
+	// Recommends is only set for the first install of a snap.
+	Recommends []string `json:"recommends,omitempty"`
 
 	Flags
 
 	SnapPath string `json:"snap-path,omitempty"`
+	// Registry is the OCI reference of the snap in a container
+	// registry it is installed from, if any.
+	Registry string `json:"registry,omitempty"`
 
 	DownloadInfo *snap.DownloadInfo `json:"download-info,omitempty"`
 	SideInfo     *snap.SideInfo     `json:"side-info,omitempty"`
@@ -135,11 +156,22 @@ type SnapState struct {
 	// (usually while a snap is being operated on or disabled)
 	Current snap.Revision `json:"current"`
 	Channel string        `json:"channel,omitempty"`
+	// StoreID is the store the snap is fetched from if not the
+	// device one, see InstallFromStore
+	StoreID string `json:"store-id,omitempty"`
+	// BranchExpiry is what to do when the branch the snap is
+	// tracking gets closed, see branch.go
+	BranchExpiry string `json:"branch-expiry,omitempty"`
+	// ClosedBranch is the tracked branch that was last found to be
+	// closed in the store
+	ClosedBranch string `json:"closed-branch,omitempty"`
 	Flags
 	// aliases, see aliasesv2.go
 	Aliases             map[string]*AliasTarget `json:"aliases,omitempty"`
 	AutoAliasesDisabled bool                    `json:"auto-aliases-disabled,omitempty"`
 	AliasesPending      bool                    `json:"aliases-pending,omitempty"`
+	// components, see component.go
+	Components map[string]*ComponentState `json:"components,omitempty"`
 }
 
 // Type returns the type of the snap or an error.
@@ -296,9 +328,12 @@ func Manager(st *state.State) (*SnapManager, error) {
 	runner.AddHandler("prerequisites", m.doPrerequisites, nil)
 	runner.AddHandler("prepare-snap", m.doPrepareSnap, m.undoPrepareSnap)
 	runner.AddHandler("download-snap", m.doDownloadSnap, m.undoPrepareSnap)
+	runner.AddHandler("download-oci-snap", m.doDownloadOCISnap, m.undoDownloadOCISnap)
+	runner.AddHandler("pre-download-snap", m.doPreDownloadSnap, nil)
 	runner.AddHandler("mount-snap", m.doMountSnap, m.undoMountSnap)
 	runner.AddHandler("unlink-current-snap", m.doUnlinkCurrentSnap, m.undoUnlinkCurrentSnap)
 	runner.AddHandler("copy-snap-data", m.doCopySnapData, m.undoCopySnapData)
+	runner.AddCleanup("download-snap", m.cleanupDownloadSnap)
 	runner.AddCleanup("copy-snap-data", m.cleanupCopySnapData)
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
 	runner.AddHandler("start-snap-services", m.startSnapServices, m.stopSnapServices)
@@ -314,6 +349,9 @@ func Manager(st *state.State) (*SnapManager, error) {
 	runner.AddHandler("clear-snap", m.doClearSnapData, nil)
 	runner.AddHandler("discard-snap", m.doDiscardSnap, nil)
 
+	// snapshots
+	runner.AddHandler("save-snapshot", m.doSaveSnapshot, m.undoSaveSnapshot)
+
 	// alias related
 	// FIXME: drop the task entirely after a while
 	runner.AddHandler("clear-aliases", func(*state.Task, *tomb.Tomb) error { return nil }, nil)
@@ -330,6 +368,13 @@ func Manager(st *state.State) (*SnapManager, error) {
 	// misc
 	runner.AddHandler("switch-snap", m.doSwitchSnap, nil)
 
+	// components
+	runner.AddHandler("mount-component", m.doMountComponent, m.undoMountComponent)
+	runner.AddHandler("link-component", m.doLinkComponent, m.undoLinkComponent)
+	runner.AddCleanup("link-component", m.cleanupLinkComponent)
+	runner.AddHandler("unlink-component", m.doUnlinkComponent, m.undoUnlinkComponent)
+	runner.AddHandler("discard-component", m.doDiscardComponent, nil)
+
 	// control serialisation
 	runner.SetBlocked(m.blockedTask)
 
@@ -353,6 +398,11 @@ func (m *SnapManager) blockedTask(cand *state.Task, running []*state.Task) bool
 
 var CanAutoRefresh func(st *state.State) (bool, error)
 
+// CanManageRefreshes is a helper set by devicestate that returns
+// whether the device is authorized to have its auto-refreshes
+// disabled with the "managed" refresh schedule.
+var CanManageRefreshes func(st *state.State) bool
+
 func refreshScheduleNoWeekdays(rs []*timeutil.Schedule) error {
 	for _, s := range rs {
 		if s.Weekday != "" {
@@ -370,6 +420,20 @@ func (m *SnapManager) checkRefreshSchedule() ([]*timeutil.Schedule, error) {
 	if err != nil && !config.IsNoOption(err) {
 		return nil, err
 	}
+	if refreshScheduleStr == ManagedRefreshSchedule {
+		if CanManageRefreshes != nil && CanManageRefreshes(m.state) {
+			// no schedule, refreshes are left to an external agent
+			m.nextRefresh = time.Time{}
+			m.currentRefreshSchedule = refreshScheduleStr
+			return nil, nil
+		}
+		// keep the option so that it takes effect once the
+		// device is authorized, but use the default meanwhile
+		if m.currentRefreshSchedule != defaultRefreshSchedule {
+			logger.Noticef("cannot use refresh.schedule configuration: managed refreshes are not allowed by the model")
+		}
+		refreshScheduleStr = defaultRefreshSchedule
+	}
 	refreshSchedule, err := timeutil.ParseSchedule(refreshScheduleStr)
 	if err == nil {
 		err = refreshScheduleNoWeekdays(refreshSchedule)
@@ -399,6 +463,7 @@ func (m *SnapManager) checkRefreshSchedule() ([]*timeutil.Schedule, error) {
 
 func (m *SnapManager) launchAutoRefresh() error {
 	m.lastRefreshAttempt = time.Now()
+	abortPreDownloads(m.state)
 	updated, tasksets, err := AutoRefresh(m.state)
 	if err != nil {
 		logger.Noticef("Cannot prepare auto-refresh change: %s", err)
@@ -473,6 +538,31 @@ func (m *SnapManager) NextCatalogRefresh() time.Time {
 	return m.nextCatalogRefresh
 }
 
+// NextWakeup returns when the snap manager has work to do next: pre-
+// downloading the snaps of the next auto-refresh and the refresh
+// itself, refreshing the catalog, cleaning up orphaned data or
+// removing expired snapshots. It implements overlord.WakeupScheduler.
+// The caller should be holding the state lock.
+func (m *SnapManager) NextWakeup() time.Time {
+	scheduled := []time.Time{m.nextRefresh, m.nextCatalogRefresh, m.nextOrphansCleanup}
+	if !m.nextRefresh.IsZero() && !m.preDownloadFor.Equal(m.nextRefresh) {
+		scheduled = append(scheduled, m.nextRefresh.Add(-preDownloadAhead))
+	}
+	if snapshots, err := Snapshots(m.state); err == nil {
+		for _, s := range snapshots {
+			scheduled = append(scheduled, s.Expiry)
+		}
+	}
+
+	var next time.Time
+	for _, t := range scheduled {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next
+}
+
 // ensureRefreshes ensures that we refresh all installed snaps periodically
 func (m *SnapManager) ensureRefreshes() error {
 	m.state.Lock()
@@ -495,6 +585,10 @@ func (m *SnapManager) ensureRefreshes() error {
 	if err != nil {
 		return err
 	}
+	if refreshSchedule == nil {
+		// refreshes are managed externally
+		return nil
+	}
 
 	// ensure nothing is in flight already
 	if autoRefreshInFlight(m.state) {
@@ -514,6 +608,11 @@ func (m *SnapManager) ensureRefreshes() error {
 		logger.Debugf("Next refresh scheduled for %s.", m.nextRefresh)
 	}
 
+	// fetch ahead of time what the refresh will need
+	if err := m.ensurePreDownloads(); err != nil {
+		return err
+	}
+
 	// Check that we have reasonable delays between unsuccessful attempts.
 	// If the store is under stress we need to make sure we do not
 	// hammer it too often
@@ -712,6 +811,8 @@ func (m *SnapManager) Ensure() error {
 		m.ensureUbuntuCoreTransition(),
 		m.ensureRefreshes(),
 		m.ensureCatalogRefresh(),
+		m.ensureOrphansCleanup(),
+		m.ensureSnapshotsExpired(),
 	}
 
 	m.runner.Ensure()
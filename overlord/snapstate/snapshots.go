@@ -0,0 +1,241 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// defaultSnapshotRetention is how long automatic snapshots are kept
+// unless snapshots.automatic.retention says otherwise.
+const defaultSnapshotRetention = 31 * 24 * time.Hour
+
+// Snapshot is an automatic snapshot of the data of a snap revision,
+// taken before that data is changed or removed.
+type Snapshot struct {
+	// SetID identifies the snapshots taken as part of the same change.
+	SetID    uint64        `json:"set-id"`
+	Snap     string        `json:"snap"`
+	Revision snap.Revision `json:"revision"`
+	Path     string        `json:"path"`
+	Time     time.Time     `json:"time"`
+	Expiry   time.Time     `json:"expiry"`
+}
+
+// automaticSnapshotsEnabled returns whether snapshots.automatic.before-refresh
+// is set, asking for the data of snaps to be saved before they are
+// refreshed or removed.
+func automaticSnapshotsEnabled(st *state.State) (bool, error) {
+	var enabled bool
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "snapshots.automatic.before-refresh", &enabled)
+	if err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// getSnapshotRetention returns how long automatic snapshots are kept, as
+// set by the snapshots.automatic.retention core option (a duration such
+// as "72h"). Invalid values are ignored.
+func getSnapshotRetention(st *state.State) (time.Duration, error) {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "snapshots.automatic.retention", &value)
+	if config.IsNoOption(err) {
+		return defaultSnapshotRetention, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		logger.Noticef("cannot use snapshots.automatic.retention configuration: invalid duration %q", value)
+		return defaultSnapshotRetention, nil
+	}
+	return retention, nil
+}
+
+// newSaveSnapshotTask returns a task saving the data of the current
+// revision of the snap, or nil if automatic snapshots are not enabled.
+func newSaveSnapshotTask(st *state.State, name string) (*state.Task, error) {
+	enabled, err := automaticSnapshotsEnabled(st)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	return st.NewTask("save-snapshot", fmt.Sprintf(i18n.G("Save data of snap %q in automatic snapshot"), name)), nil
+}
+
+// Snapshots returns the automatic snapshots that have not expired yet.
+func Snapshots(st *state.State) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// changeSnapshotSetID returns the id of the snapshot set of the change,
+// allocating one the first time around. The id is also recorded in the
+// "api-data" of the change so clients can find the snapshots.
+func changeSnapshotSetID(chg *state.Change) (uint64, error) {
+	var setID uint64
+	err := chg.Get("snapshot-set-id", &setID)
+	if err == nil {
+		return setID, nil
+	}
+	if err != state.ErrNoState {
+		return 0, err
+	}
+
+	st := chg.State()
+	err = st.Get("last-snapshot-set-id", &setID)
+	if err != nil && err != state.ErrNoState {
+		return 0, err
+	}
+	setID++
+	st.Set("last-snapshot-set-id", setID)
+	chg.Set("snapshot-set-id", setID)
+
+	apiData := make(map[string]interface{})
+	err = chg.Get("api-data", &apiData)
+	if err != nil && err != state.ErrNoState {
+		return 0, err
+	}
+	apiData["snapshot-set-id"] = setID
+	chg.Set("api-data", apiData)
+
+	return setID, nil
+}
+
+func (m *SnapManager) doSaveSnapshot(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// changes removing snaps have no prerequisites checking that
+	// there is room for the snapshots
+	if err := checkChangeDiskSpace(t); err != nil {
+		return err
+	}
+
+	_, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	setID, err := changeSnapshotSetID(t.Change())
+	if err != nil {
+		return err
+	}
+	retention, err := getSnapshotRetention(st)
+	if err != nil {
+		return err
+	}
+
+	st.Unlock()
+	path, err := m.backend.SaveSnapshot(setID, info)
+	st.Lock()
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := Snapshots(st)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	snapshots = append(snapshots, &Snapshot{
+		SetID:    setID,
+		Snap:     info.Name(),
+		Revision: info.Revision,
+		Path:     path,
+		Time:     now,
+		Expiry:   now.Add(retention),
+	})
+	st.Set("snapshots", snapshots)
+	t.Set("snapshot-path", path)
+	t.Logf("Saved snapshot %d of snap %q to %q.", setID, info.Name(), path)
+
+	return nil
+}
+
+func (m *SnapManager) undoSaveSnapshot(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var path string
+	if err := t.Get("snapshot-path", &path); err != nil {
+		if err == state.ErrNoState {
+			return nil
+		}
+		return err
+	}
+	return m.dropSnapshots(func(s *Snapshot) bool { return s.Path == path })
+}
+
+// dropSnapshots removes the automatic snapshots matching the filter.
+func (m *SnapManager) dropSnapshots(filter func(*Snapshot) bool) error {
+	snapshots, err := Snapshots(m.state)
+	if err != nil {
+		return err
+	}
+	kept := snapshots[:0]
+	for _, s := range snapshots {
+		if !filter(s) {
+			kept = append(kept, s)
+			continue
+		}
+		if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("cannot remove snapshot %d of snap %q: %v", s.SetID, s.Snap, err)
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == len(snapshots) {
+		return nil
+	}
+	m.state.Set("snapshots", kept)
+	return nil
+}
+
+// ensureSnapshotsExpired removes the automatic snapshots past their
+// expiry time.
+func (m *SnapManager) ensureSnapshotsExpired() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := time.Now()
+	return m.dropSnapshots(func(s *Snapshot) bool { return s.Expiry.Before(now) })
+}
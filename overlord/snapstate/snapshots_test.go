@@ -0,0 +1,274 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) enableAutomaticSnapshots(c *C, retention string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "snapshots.automatic.before-refresh", true), IsNil)
+	if retention != "" {
+		c.Assert(tr.Set("core", "snapshots.automatic.retention", retention), IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) TestUpdateTasksWithAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.enableAutomaticSnapshots(c, "")
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	kinds := taskKinds(ts.Tasks())
+	c.Assert(kinds[4:9], DeepEquals, []string{
+		"stop-snap-services",
+		"remove-aliases",
+		"unlink-current-snap",
+		"save-snapshot",
+		"copy-snap-data",
+	})
+	c.Check(ts.Tasks()[7].WaitTasks(), DeepEquals, []*state.Task{ts.Tasks()[6]})
+}
+
+func (s *snapmgrTestSuite) TestRemoveTasksWithAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.enableAutomaticSnapshots(c, "")
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0))
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"stop-snap-services",
+		"save-snapshot",
+		"run-hook[remove]",
+		"remove-aliases",
+		"unlink-snap",
+		"remove-profiles",
+		"clear-snap",
+		"discard-snap",
+		"discard-conns",
+	})
+}
+
+func (s *snapmgrTestSuite) TestRemoveInactiveTasksWithAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.enableAutomaticSnapshots(c, "")
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0))
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"save-snapshot",
+		"run-hook[remove]",
+		"clear-snap",
+		"discard-snap",
+		"discard-conns",
+	})
+}
+
+func (s *snapmgrTestSuite) TestRemoveRevisionNoAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.enableAutomaticSnapshots(c, "")
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(5)},
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(5))
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), DeepEquals, []string{"clear-snap", "discard-snap"})
+}
+
+func (s *snapmgrTestSuite) TestDoSaveSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.enableAutomaticSnapshots(c, "72h")
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+	})
+
+	chg := s.state.NewChange("remove-snap", "...")
+	chg.Set("api-data", map[string]interface{}{"snap-names": []string{"foo"}})
+	for _, name := range []string{"foo", "foo"} {
+		t := s.state.NewTask("save-snapshot", "...")
+		t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: name}})
+		chg.AddTask(t)
+	}
+
+	before := time.Now()
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"save-snapshot", "save-snapshot"})
+
+	// the tasks of the change share the snapshot set
+	var setID uint64
+	c.Assert(chg.Get("snapshot-set-id", &setID), IsNil)
+	c.Check(setID, Equals, uint64(1))
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), IsNil)
+	c.Check(apiData, DeepEquals, map[string]interface{}{
+		"snap-names":      []interface{}{"foo"},
+		"snapshot-set-id": 1.0,
+	})
+
+	snapshots, err := snapstate.Snapshots(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 2)
+	c.Check(snapshots[0].SetID, Equals, uint64(1))
+	c.Check(snapshots[0].Snap, Equals, "foo")
+	c.Check(snapshots[0].Revision, Equals, snap.R(11))
+	c.Check(snapshots[0].Path, Equals, filepath.Join(dirs.SnapshotsDir, "1_foo_11.tar.gz"))
+	c.Check(snapshots[0].Expiry.Sub(snapshots[0].Time), Equals, 72*time.Hour)
+	c.Check(snapshots[0].Time.Before(before), Equals, false)
+
+	// a new change gets a new set
+	chg = s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("save-snapshot", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "foo"}})
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Get("snapshot-set-id", &setID), IsNil)
+	c.Check(setID, Equals, uint64(2))
+}
+
+func (s *snapmgrTestSuite) TestUndoSaveSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.enableAutomaticSnapshots(c, "")
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+	})
+
+	chg := s.state.NewChange("remove-snap", "...")
+	t := s.state.NewTask("save-snapshot", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "foo"}})
+	chg.AddTask(t)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	snapshots, err := snapstate.Snapshots(s.state)
+	c.Assert(err, IsNil)
+	c.Check(snapshots, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestEnsureSnapshotsExpired(c *C) {
+	s.state.Lock()
+	now := time.Now()
+	expired := filepath.Join(dirs.SnapshotsDir, "1_foo_1.tar.gz")
+	current := filepath.Join(dirs.SnapshotsDir, "2_foo_2.tar.gz")
+	touch(c, expired)
+	touch(c, current)
+	s.state.Set("snapshots", []*snapstate.Snapshot{
+		{SetID: 1, Snap: "foo", Revision: snap.R(1), Path: expired, Time: now.Add(-2 * time.Hour), Expiry: now.Add(-time.Hour)},
+		{SetID: 2, Snap: "foo", Revision: snap.R(2), Path: current, Time: now, Expiry: now.Add(time.Hour)},
+	})
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapshots, err := snapstate.Snapshots(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 1)
+	c.Check(snapshots[0].SetID, Equals, uint64(2))
+	c.Check(osutil.FileExists(expired), Equals, false)
+	c.Check(osutil.FileExists(current), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestNextWakeup(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Now()
+	c.Check(s.snapmgr.NextWakeup().IsZero(), Equals, true)
+
+	restore := snapstate.MockPreDownloadAhead(time.Hour)
+	defer restore()
+	snapstate.SetSnapManagerSchedule(s.snapmgr, now.Add(10*time.Hour), now.Add(9*time.Hour), now.Add(8*time.Hour))
+	c.Check(s.snapmgr.NextWakeup(), Equals, now.Add(8*time.Hour))
+
+	// the snaps of the next auto-refresh are pre-downloaded ahead
+	restore = snapstate.MockPreDownloadAhead(3 * time.Hour)
+	defer restore()
+	c.Check(s.snapmgr.NextWakeup(), Equals, now.Add(7*time.Hour))
+
+	// snapshots expire
+	s.state.Set("snapshots", []*snapstate.Snapshot{
+		{SetID: 1, Snap: "foo", Revision: snap.R(1), Time: now, Expiry: now.Add(time.Hour)},
+	})
+	c.Check(s.snapmgr.NextWakeup().Equal(now.Add(time.Hour)), Equals, true)
+}
@@ -24,9 +24,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
@@ -64,6 +66,24 @@ func needsMaybeCore(typ snap.Type) int {
 	return 0
 }
 
+// epochChanged returns whether the snap being installed as described by
+// snapsup has a different epoch than the current revision in snapst.
+// An unknown epoch for the snap being installed is taken as unchanged.
+func epochChanged(snapst *SnapState, snapsup *SnapSetup) (bool, error) {
+	if snapsup.Epoch == "" {
+		return false, nil
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return false, err
+	}
+	epoch := info.Epoch
+	if epoch == "" {
+		epoch = "0"
+	}
+	return epoch != snapsup.Epoch, nil
+}
+
 func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int) (*state.TaskSet, error) {
 	if snapsup.Flags.Classic {
 		if !release.OnClassic {
@@ -89,7 +109,7 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 
 	targetRevision := snapsup.Revision()
 	revisionStr := ""
-	if snapsup.SideInfo != nil {
+	if snapsup.SideInfo != nil && snapsup.Registry == "" {
 		revisionStr = fmt.Sprintf(" (%s)", targetRevision)
 	}
 
@@ -97,27 +117,39 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	revisionIsLocal := snapst.LastIndex(targetRevision) >= 0
 
 	prereq := st.NewTask("prerequisites", fmt.Sprintf(i18n.G("Ensure prerequisites for %q are available"), snapsup.Name()))
-	prereq.Set("snap-setup", snapsup)
 
 	var prepare, prev *state.Task
+	var tasks []*state.Task
 	fromStore := false
-	// if we have a local revision here we go back to that
-	if snapsup.SnapPath != "" || revisionIsLocal {
-		prepare = st.NewTask("prepare-snap", fmt.Sprintf(i18n.G("Prepare snap %q%s"), snapsup.SnapPath, revisionStr))
+	if snapsup.Registry != "" {
+		// what a snap from a registry needs is only known once it
+		// is downloaded, so its prerequisites are checked after
+		prepare = st.NewTask("download-oci-snap", fmt.Sprintf(i18n.G("Download snap %q from %q"), snapsup.Name(), snapsup.Registry))
+		prepare.Set("snap-setup", snapsup)
+		prereq.Set("snap-setup-task", prepare.ID())
+		prereq.WaitFor(prepare)
+		tasks = []*state.Task{prepare, prereq}
+		prev = prereq
 	} else {
-		fromStore = true
-		prepare = st.NewTask("download-snap", fmt.Sprintf(i18n.G("Download snap %q%s from channel %q"), snapsup.Name(), revisionStr, snapsup.Channel))
+		prereq.Set("snap-setup", snapsup)
+		// if we have a local revision here we go back to that
+		if snapsup.SnapPath != "" || revisionIsLocal {
+			prepare = st.NewTask("prepare-snap", fmt.Sprintf(i18n.G("Prepare snap %q%s"), snapsup.SnapPath, revisionStr))
+		} else {
+			fromStore = true
+			prepare = st.NewTask("download-snap", fmt.Sprintf(i18n.G("Download snap %q%s from channel %q"), snapsup.Name(), revisionStr, snapsup.Channel))
+		}
+		prepare.Set("snap-setup", snapsup)
+		prepare.WaitFor(prereq)
+		tasks = []*state.Task{prereq, prepare}
+		prev = prepare
 	}
-	prepare.Set("snap-setup", snapsup)
-	prepare.WaitFor(prereq)
 
-	tasks := []*state.Task{prereq, prepare}
 	addTask := func(t *state.Task) {
 		t.Set("snap-setup-task", prepare.ID())
 		t.WaitFor(prev)
 		tasks = append(tasks, t)
 	}
-	prev = prepare
 
 	if fromStore {
 		// fetch and check assertions
@@ -148,6 +180,18 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		prev = unlink
 	}
 
+	// save the data of the current revision, if asked to
+	if snapst.IsInstalled() && !snapsup.Flags.Revert {
+		saveSnapshot, err := newSaveSnapshotTask(st, snapsup.Name())
+		if err != nil {
+			return nil, err
+		}
+		if saveSnapshot != nil {
+			addTask(saveSnapshot)
+			prev = saveSnapshot
+		}
+	}
+
 	// copy-data (needs stopped services by unlink)
 	if !snapsup.Flags.Revert {
 		copyData := st.NewTask("copy-snap-data", fmt.Sprintf(i18n.G("Copy snap %q data"), snapsup.Name()))
@@ -160,6 +204,20 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(setupSecurity)
 	prev = setupSecurity
 
+	// let the new revision migrate the data of the current one when
+	// crossing epochs, before it's made available
+	if snapst.IsInstalled() && !snapsup.Flags.Revert {
+		changed, err := epochChanged(snapst, snapsup)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			migrateDataHook := SetupMigrateDataHook(st, snapsup.Name())
+			addTask(migrateDataHook)
+			prev = migrateDataHook
+		}
+	}
+
 	// finalize (wrappers+current symlink)
 	linkSnap := st.NewTask("link-snap", fmt.Sprintf(i18n.G("Make snap %q%s available to the system"), snapsup.Name(), revisionStr))
 	addTask(linkSnap)
@@ -201,6 +259,19 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(startSnapServices)
 	prev = startSnapServices
 
+	// check that the refreshed snap is healthy, if it fails to say
+	// so in time the whole refresh is undone, going back to the
+	// previous revision
+	if snapst.IsInstalled() && !snapsup.Flags.Revert {
+		timeout, err := getCheckHealthTimeout(st)
+		if err != nil {
+			return nil, err
+		}
+		checkHealthHook := SetupCheckHealthHook(st, snapsup.Name(), timeout)
+		addTask(checkHealthHook)
+		prev = checkHealthHook
+	}
+
 	// Do not do that if we are reverting to a local revision
 	if snapst.IsInstalled() && !snapsup.Flags.Revert {
 		seq := snapst.Sequence
@@ -234,12 +305,15 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		}
 
 		// normal garbage collect
-		for i := 0; i <= currentIndex-2; i++ {
-			si := seq[i]
-			if boot.InUse(snapsup.Name(), si.Revision) {
+		policy, err := getRetainPolicy(st, snapsup.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, rev := range revisionsToDiscard(snapsup.Name(), seq[:currentIndex+1], policy, targetSize(snapsup)) {
+			if boot.InUse(snapsup.Name(), rev) {
 				continue
 			}
-			ts := removeInactiveRevision(st, snapsup.Name(), si.Revision)
+			ts := removeInactiveRevision(st, snapsup.Name(), rev)
 			ts.WaitFor(prev)
 			tasks = append(tasks, ts.Tasks()...)
 			prev = tasks[len(tasks)-1]
@@ -294,6 +368,14 @@ var SetupPostRefreshHook = func(st *state.State, snapName string) *state.Task {
 	panic("internal error: snapstate.SetupPostRefreshHook is unset")
 }
 
+var SetupCheckHealthHook = func(st *state.State, snapName string, timeout time.Duration) *state.Task {
+	panic("internal error: snapstate.SetupCheckHealthHook is unset")
+}
+
+var SetupMigrateDataHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupMigrateDataHook is unset")
+}
+
 var SetupRemoveHook = func(st *state.State, snapName string) *state.Task {
 	panic("internal error: snapstate.SetupRemoveHook is unset")
 }
@@ -311,6 +393,48 @@ var snapTopicalTasks = map[string]bool{
 	"prefer-aliases":     true,
 	"connect":            true,
 	"disconnect":         true,
+	"link-component":     true,
+	"unlink-component":   true,
+}
+
+// AffectedSnapsFunc returns the names of the snaps a task operates on.
+type AffectedSnapsFunc func(t *state.Task) ([]string, error)
+
+var affectedSnapsByKind = make(map[string]AffectedSnapsFunc)
+
+// AddAffectedSnapsByKind registers a function returning the snaps
+// affected by the tasks of the given kind, which then conflict with the
+// other changes on those snaps the same way the snapTopicalTasks do.
+func AddAffectedSnapsByKind(kind string, f AffectedSnapsFunc) {
+	affectedSnapsByKind[kind] = f
+}
+
+// snapPreparationTasks are tasks that prepare a change on a snap
+// without touching the snap on the system.
+var snapPreparationTasks = map[string]bool{
+	"prerequisites":     true,
+	"download-snap":     true,
+	"download-oci-snap": true,
+	"validate-snap":     true,
+}
+
+// changeIsPreparing returns whether chg is still preparing its snaps,
+// like downloading them, without having gone any further.
+func changeIsPreparing(chg *state.Change) bool {
+	preparing := false
+	for _, task := range chg.Tasks() {
+		status := task.Status()
+		if snapPreparationTasks[task.Kind()] {
+			if status == state.DoStatus || status == state.DoingStatus {
+				preparing = true
+			}
+			continue
+		}
+		if status != state.DoStatus && status != state.HoldStatus {
+			return false
+		}
+	}
+	return preparing
 }
 
 func getPlugAndSlotRefs(task *state.Task) (*interfaces.PlugRef, *interfaces.SlotRef, error) {
@@ -340,6 +464,20 @@ func (e changeConflictError) Error() string {
 // It's like CheckChangeConflict, but for multiple snaps, and does not
 // check snapst.
 func CheckChangeConflictMany(st *state.State, snapNames []string, checkConflictPredicate func(taskKind string) bool) error {
+	return checkChangeConflictMany(st, snapNames, checkConflictPredicate, false)
+}
+
+// CheckChangeConflictIgnoringPreparation is like CheckChangeConflict,
+// but it does not consider changes that are still preparing the snap,
+// like downloading it, as conflicting. It is meant for operations that
+// do not alter the snap itself, like connecting its interfaces, and
+// that would otherwise be blocked for the whole duration of a long
+// download. It does not check snapst.
+func CheckChangeConflictIgnoringPreparation(st *state.State, snapName string, checkConflictPredicate func(taskKind string) bool) error {
+	return checkChangeConflictMany(st, []string{snapName}, checkConflictPredicate, true)
+}
+
+func checkChangeConflictMany(st *state.State, snapNames []string, checkConflictPredicate func(taskKind string) bool, ignorePreparing bool) error {
 	snapMap := make(map[string]bool, len(snapNames))
 	for _, k := range snapNames {
 		snapMap[k] = true
@@ -357,7 +495,25 @@ func CheckChangeConflictMany(st *state.State, snapNames []string, checkConflictP
 	for _, task := range st.Tasks() {
 		k := task.Kind()
 		chg := task.Change()
+		if f := affectedSnapsByKind[k]; f != nil && (chg == nil || !chg.Status().Ready()) {
+			if ignorePreparing && chg != nil && changeIsPreparing(chg) {
+				continue
+			}
+			snapNames, err := f(task)
+			if err != nil {
+				return fmt.Errorf("internal error: cannot obtain affected snaps from task: %s", task.Summary())
+			}
+			for _, snapName := range snapNames {
+				if snapMap[snapName] && (checkConflictPredicate == nil || checkConflictPredicate(k)) {
+					return changeConflictError{snapName}
+				}
+			}
+			continue
+		}
 		if snapTopicalTasks[k] && (chg == nil || !chg.Status().Ready()) {
+			if ignorePreparing && chg != nil && changeIsPreparing(chg) {
+				continue
+			}
 			if k == "connect" || k == "disconnect" {
 				plugRef, slotRef, err := getPlugAndSlotRefs(task)
 				if err != nil {
@@ -465,11 +621,18 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, channel string, flags
 
 	snapsup := &SnapSetup{
 		Base:     info.Base,
+		Epoch:    info.Epoch,
+		Requires: info.Requires,
 		SideInfo: si,
 		SnapPath: path,
 		Channel:  channel,
 		Flags:    flags.ForSnapSetup(),
 	}
+	if !snapst.IsInstalled() {
+		// recommended snaps are only installed along with the
+		// first install of the snap
+		snapsup.Recommends = info.Recommends
+	}
 
 	return doInstall(st, &snapst, snapsup, instFlags)
 }
@@ -485,6 +648,14 @@ func TryPath(st *state.State, name, path string, flags Flags) (*state.TaskSet, e
 // Install returns a set of tasks for installing snap.
 // Note that the state must be locked by the caller.
 func Install(st *state.State, name, channel string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
+	return InstallFromStore(st, name, "", channel, revision, userID, flags)
+}
+
+// InstallFromStore returns a set of tasks for installing snap from
+// the given store instead of the device one, if storeID is not
+// empty. The snap stays associated with that store for refreshes.
+// Note that the state must be locked by the caller.
+func InstallFromStore(st *state.State, name, storeID, channel string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
 	if channel == "" {
 		channel = "stable"
 	}
@@ -498,7 +669,13 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 		return nil, &snap.AlreadyInstalledError{Snap: name}
 	}
 
-	info, err := snapInfo(st, name, channel, revision, userID)
+	if storeID != "" {
+		if err := checkStoreOverride(st, storeID); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := snapInfoFromStore(st, name, storeID, channel, revision, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -509,7 +686,11 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 
 	snapsup := &SnapSetup{
 		Channel:      channel,
+		StoreID:      storeID,
 		Base:         info.Base,
+		Epoch:        info.Epoch,
+		Requires:     info.Requires,
+		Recommends:   info.Recommends,
 		UserID:       userID,
 		Flags:        flags.ForSnapSetup(),
 		DownloadInfo: &info.DownloadInfo,
@@ -519,13 +700,49 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 	return doInstall(st, &snapst, snapsup, needsMaybeCore(info.Type))
 }
 
-// InstallMany installs everything from the given list of names.
+// SnapOptions carries the options of one of the snaps installed or
+// refreshed together by InstallMany or UpdateMany.
+type SnapOptions struct {
+	// Channel is the channel to install the snap from, or to switch it
+	// to when refreshing.
+	Channel string
+	// Revision pins the snap to the given revision.
+	Revision snap.Revision
+	// Flags are the flags to install the snap with. When refreshing,
+	// only the confinement flags (DevMode, JailMode and Classic) are
+	// considered, and only if one of them is set.
+	Flags Flags
+}
+
+// snapOptions returns the options given for the named snap, or empty
+// options if there are none.
+func snapOptions(opts map[string]*SnapOptions, name string) *SnapOptions {
+	if o := opts[name]; o != nil {
+		return o
+	}
+	return &SnapOptions{}
+}
+
+// updateFlags returns the flags to refresh the snap with.
+func (o *SnapOptions) updateFlags(snapst *SnapState) Flags {
+	flags := snapst.Flags
+	if o.Flags.DevMode || o.Flags.JailMode || o.Flags.Classic {
+		flags.DevMode = o.Flags.DevMode
+		flags.JailMode = o.Flags.JailMode
+		flags.Classic = o.Flags.Classic
+	}
+	return flags
+}
+
+// InstallMany installs everything from the given list of names, with
+// the options given for each of them, if any.
 // Note that the state must be locked by the caller.
-func InstallMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+func InstallMany(st *state.State, names []string, userID int, opts map[string]*SnapOptions) ([]string, []*state.TaskSet, error) {
 	installed := make([]string, 0, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
 	for _, name := range names {
-		ts, err := Install(st, name, "", snap.R(0), userID, Flags{})
+		o := snapOptions(opts, name)
+		ts, err := Install(st, name, o.Channel, o.Revision, userID, o.Flags)
 		// FIXME: is this expected behavior?
 		if _, ok := err.(*snap.AlreadyInstalledError); ok {
 			continue
@@ -543,11 +760,11 @@ func InstallMany(st *state.State, names []string, userID int) ([]string, []*stat
 // RefreshCandidates gets a list of candidates for update
 // Note that the state must be locked by the caller.
 func RefreshCandidates(st *state.State, user *auth.UserState) ([]*snap.Info, error) {
-	updates, _, err := refreshCandidates(st, nil, user)
+	updates, _, err := refreshCandidates(st, nil, user, nil)
 	return updates, err
 }
 
-func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([]*snap.Info, map[string]*SnapState, error) {
+func refreshCandidates(st *state.State, names []string, user *auth.UserState, opts map[string]*SnapOptions) ([]*snap.Info, map[string]*SnapState, error) {
 	snapStates, err := All(st)
 	if err != nil {
 		return nil, nil, err
@@ -584,15 +801,26 @@ func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([
 			continue
 		}
 
+		o := snapOptions(opts, snapInfo.Name())
+		if !o.Revision.Unset() {
+			// pinned to a revision, not up to the store
+			continue
+		}
+		channel := snapst.Channel
+		if o.Channel != "" {
+			channel = o.Channel
+		}
+
 		stateByID[snapInfo.SnapID] = snapst
 
 		// get confinement preference from the snapstate
 		candidateInfo := &store.RefreshCandidate{
 			// the desired channel (not info.Channel!)
-			Channel:  snapst.Channel,
+			Channel:  channel,
 			SnapID:   snapInfo.SnapID,
 			Revision: snapInfo.Revision,
 			Epoch:    snapInfo.Epoch,
+			StoreID:  snapst.StoreID,
 		}
 
 		if len(names) == 0 {
@@ -619,14 +847,16 @@ var ValidateRefreshes func(st *state.State, refreshes []*snap.Info, userID int)
 
 // UpdateMany updates everything from the given list of names that the
 // store says is updateable. If the list is empty, update everything.
+// The options given for a snap, if any, can switch it to another
+// channel, pin it to a revision or change its confinement.
 // Note that the state must be locked by the caller.
-func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+func UpdateMany(st *state.State, names []string, userID int, opts map[string]*SnapOptions) ([]string, []*state.TaskSet, error) {
 	user, err := userFromUserID(st, userID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	updates, stateByID, err := refreshCandidates(st, names, user)
+	updates, stateByID, err := refreshCandidates(st, names, user, opts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -643,13 +873,102 @@ func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state
 		}
 	}
 
+	pinned, err := pinnedUpdates(st, names, userID, opts, stateByID)
+	if err != nil {
+		return nil, nil, err
+	}
+	updates = append(updates, pinned...)
+
+	channelOf := func(name string, snapst *SnapState) string {
+		if o := snapOptions(opts, name); o.Channel != "" {
+			return o.Channel
+		}
+		return snapst.Channel
+	}
 	params := func(update *snap.Info) (string, Flags, *SnapState) {
 		snapst := stateByID[update.SnapID]
-		return snapst.Channel, snapst.Flags, snapst
+		return channelOf(update.Name(), snapst), snapOptions(opts, update.Name()).updateFlags(snapst), snapst
+	}
+
+	updated, tasksets, err := doUpdate(st, names, updates, params, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// switch the channel of the snaps without updates as needed
+	hasUpdate := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		hasUpdate[update.Name()] = true
+	}
+	for _, name := range optionsSnapNames(names, opts) {
+		if hasUpdate[name] {
+			continue
+		}
+		var snapst SnapState
+		if err := Get(st, name, &snapst); err != nil {
+			if err == state.ErrNoState {
+				continue
+			}
+			return nil, nil, err
+		}
+		if channel := channelOf(name, &snapst); snapst.IsInstalled() && channel != snapst.Channel {
+			tasksets = append(tasksets, switchChannelTaskSet(st, &snapst, channel, nil))
+			if !strutil.ListContains(updated, name) {
+				updated = append(updated, name)
+			}
+		}
+	}
+
+	return updated, tasksets, nil
+}
 
+// optionsSnapNames returns the sorted names of the snaps options apply
+// to: those given options among the names, or all those given options
+// when the names are empty.
+func optionsSnapNames(names []string, opts map[string]*SnapOptions) []string {
+	optNames := make([]string, 0, len(opts))
+	for name := range opts {
+		if len(names) == 0 || strutil.ListContains(names, name) {
+			optNames = append(optNames, name)
+		}
 	}
+	sort.Strings(optNames)
+	return optNames
+}
 
-	return doUpdate(st, names, updates, params, userID)
+// pinnedUpdates returns the updates of the snaps the options pin to a
+// revision other than their current one, adding their states to
+// stateByID.
+func pinnedUpdates(st *state.State, names []string, userID int, opts map[string]*SnapOptions, stateByID map[string]*SnapState) ([]*snap.Info, error) {
+	var updates []*snap.Info
+	for _, name := range optionsSnapNames(names, opts) {
+		o := opts[name]
+		if o == nil || o.Revision.Unset() {
+			continue
+		}
+		var snapst SnapState
+		err := Get(st, name, &snapst)
+		if err != nil && err != state.ErrNoState {
+			return nil, err
+		}
+		if !snapst.IsInstalled() {
+			return nil, fmt.Errorf("cannot find snap %q", name)
+		}
+		if snapst.Current == o.Revision {
+			continue
+		}
+		channel := snapst.Channel
+		if o.Channel != "" {
+			channel = o.Channel
+		}
+		info, err := infoForUpdate(st, &snapst, name, channel, o.Revision, userID, o.updateFlags(&snapst))
+		if err != nil {
+			return nil, err
+		}
+		stateByID[info.SnapID] = &snapst
+		updates = append(updates, info)
+	}
+	return updates, nil
 }
 
 func doUpdate(st *state.State, names []string, updates []*snap.Info, params func(*snap.Info) (channel string, flags Flags, snapst *SnapState), userID int) ([]string, []*state.TaskSet, error) {
@@ -696,6 +1015,15 @@ func doUpdate(st *state.State, names []string, updates []*snap.Info, params func
 	for _, update := range updates {
 		channel, flags, snapst := params(update)
 
+		channel, err := checkBranchExpiry(st, update, channel, snapst)
+		if err != nil {
+			if refreshAll {
+				logger.Noticef("cannot refresh snap %q: %v", update.Name(), err)
+				continue
+			}
+			return nil, nil, fmt.Errorf("cannot refresh snap %q: %v", update.Name(), err)
+		}
+
 		if err := validateInfoAndFlags(update, snapst, flags); err != nil {
 			if refreshAll {
 				logger.Noticef("cannot update %q: %v", update.Name(), err)
@@ -706,6 +1034,9 @@ func doUpdate(st *state.State, names []string, updates []*snap.Info, params func
 
 		snapsup := &SnapSetup{
 			Channel:      channel,
+			StoreID:      snapst.StoreID,
+			Epoch:        update.Epoch,
+			Requires:     update.Requires,
 			UserID:       userID,
 			Flags:        flags.ForSnapSetup(),
 			DownloadInfo: &update.DownloadInfo,
@@ -931,23 +1262,7 @@ func Update(st *state.State, name, channel string, revision snap.Revision, userI
 
 	// see if we need to update the channel
 	if infoErr == store.ErrNoUpdateAvailable && snapst.Channel != channel {
-		snapsup := &SnapSetup{
-			SideInfo: snapst.CurrentSideInfo(),
-			// update the tracked channel
-			Channel: channel,
-		}
-		// Update the current snap channel as well. This ensures that
-		// the UI displays the right values.
-		snapsup.SideInfo.Channel = channel
-
-		switchSnap := st.NewTask("switch-snap-channel", fmt.Sprintf(i18n.G("Switch snap %q from %s to %s"), snapsup.Name(), snapst.Channel, channel))
-		switchSnap.Set("snap-setup", &snapsup)
-
-		switchSnapTs := state.NewTaskSet(switchSnap)
-		for _, ts := range tts {
-			switchSnapTs.WaitAll(ts)
-		}
-		tts = append(tts, switchSnapTs)
+		tts = append(tts, switchChannelTaskSet(st, &snapst, channel, tts))
 	}
 
 	if len(tts) == 0 && len(updates) == 0 {
@@ -961,6 +1276,28 @@ func Update(st *state.State, name, channel string, revision snap.Revision, userI
 	return flat, nil
 }
 
+// switchChannelTaskSet returns a task set switching the installed snap
+// to track the given channel, after the given task sets.
+func switchChannelTaskSet(st *state.State, snapst *SnapState, channel string, after []*state.TaskSet) *state.TaskSet {
+	snapsup := &SnapSetup{
+		SideInfo: snapst.CurrentSideInfo(),
+		// update the tracked channel
+		Channel: channel,
+	}
+	// Update the current snap channel as well. This ensures that
+	// the UI displays the right values.
+	snapsup.SideInfo.Channel = channel
+
+	switchSnap := st.NewTask("switch-snap-channel", fmt.Sprintf(i18n.G("Switch snap %q from %s to %s"), snapsup.Name(), snapst.Channel, channel))
+	switchSnap.Set("snap-setup", &snapsup)
+
+	switchSnapTs := state.NewTaskSet(switchSnap)
+	for _, ts := range after {
+		switchSnapTs.WaitAll(ts)
+	}
+	return switchSnapTs
+}
+
 func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, revision snap.Revision, userID int, flags Flags) (*snap.Info, error) {
 	if revision.Unset() {
 		// good ol' refresh
@@ -988,7 +1325,7 @@ func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, rev
 	}
 	if sideInfo == nil {
 		// refresh from given revision from store
-		return snapInfo(st, name, channel, revision, userID)
+		return snapInfoFromStore(st, name, snapst.StoreID, channel, revision, userID)
 	}
 
 	// refresh-to-local
@@ -1011,7 +1348,7 @@ func AutoRefresh(st *state.State) ([]string, []*state.TaskSet, error) {
 		}
 	}
 
-	return UpdateMany(st, nil, userID)
+	return UpdateMany(st, nil, userID, nil)
 }
 
 // Enable sets a snap to the active state
@@ -1177,6 +1514,10 @@ func canRemove(si *snap.Info, snapst *SnapState, removeAll bool) bool {
 // Remove returns a set of tasks for removing snap.
 // Note that the state must be locked by the caller.
 func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSet, error) {
+	return remove(st, name, revision, nil)
+}
+
+func remove(st *state.State, name string, revision snap.Revision, removing map[string]bool) (*state.TaskSet, error) {
 	var snapst SnapState
 	err := Get(st, name, &snapst)
 	if err != nil && err != state.ErrNoState {
@@ -1225,6 +1566,12 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		return nil, fmt.Errorf("snap %q is not removable", name)
 	}
 
+	if removeAll {
+		if err := checkNotRequired(st, name, removing); err != nil {
+			return nil, err
+		}
+	}
+
 	// main/current SnapSetup
 	snapsup := SnapSetup{
 		SideInfo: &snap.SideInfo{
@@ -1246,10 +1593,15 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		chain = ts
 	}
 
-	var removeHook *state.Task
-	// only run remove hook if uninstalling the snap completely
+	var removeHook, saveSnapshot *state.Task
+	// only run remove hook, and save the data if asked to, if
+	// uninstalling the snap completely
 	if removeAll {
 		removeHook = SetupRemoveHook(st, snapsup.Name())
+		saveSnapshot, err = newSaveSnapshotTask(st, name)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if active { // unlink
@@ -1260,6 +1612,12 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		prev = stopSnapServices
 
 		tasks := []*state.Task{stopSnapServices}
+		if saveSnapshot != nil {
+			saveSnapshot.Set("snap-setup-task", stopSnapServices.ID())
+			saveSnapshot.WaitFor(prev)
+			tasks = append(tasks, saveSnapshot)
+			prev = saveSnapshot
+		}
 		if removeHook != nil {
 			tasks = append(tasks, removeHook)
 			removeHook.WaitFor(prev)
@@ -1280,8 +1638,14 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 
 		tasks = append(tasks, removeAliases, unlink, removeSecurity)
 		addNext(state.NewTaskSet(tasks...))
-	} else if removeHook != nil {
-		addNext(state.NewTaskSet(removeHook))
+	} else {
+		if saveSnapshot != nil {
+			saveSnapshot.Set("snap-setup", snapsup)
+			addNext(state.NewTaskSet(saveSnapshot))
+		}
+		if removeHook != nil {
+			addNext(state.NewTaskSet(removeHook))
+		}
 	}
 
 	if removeAll {
@@ -1295,6 +1659,7 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		discardConns.Set("snap-setup", &SnapSetup{
 			SideInfo: &snap.SideInfo{
 				RealName: name,
+				SnapID:   info.SnapID,
 			},
 		})
 		addNext(state.NewTaskSet(discardConns))
@@ -1328,8 +1693,13 @@ func removeInactiveRevision(st *state.State, name string, revision snap.Revision
 func RemoveMany(st *state.State, names []string) ([]string, []*state.TaskSet, error) {
 	removed := make([]string, 0, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
+	// snaps removed together can require each other
+	removing := make(map[string]bool, len(names))
+	for _, name := range names {
+		removing[name] = true
+	}
 	for _, name := range names {
-		ts, err := Remove(st, name, snap.R(0))
+		ts, err := remove(st, name, snap.R(0), removing)
 		// FIXME: is this expected behavior?
 		if _, ok := err.(*snap.NotInstalledError); ok {
 			continue
@@ -1695,8 +2065,34 @@ func CoreInfo(st *state.State) (*snap.Info, error) {
 	return nil, fmt.Errorf("unexpected number of cores, got %d", len(res))
 }
 
-// ConfigDefaults returns the configuration defaults for the snap specified in the gadget. If gadget is absent or the snap has no snap-id it returns ErrNoState.
+// ConfigDefaults returns the configuration defaults for the snap specified in the gadget, overridden by the ones set in the seed when preparing the image. If there are no defaults for the snap it returns ErrNoState.
 func ConfigDefaults(st *state.State, snapName string) (map[string]interface{}, error) {
+	gadgetDefaults, err := gadgetConfigDefaults(st, snapName)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+
+	seedDefaults, err := seedConfigDefaults(snapName)
+	if err != nil {
+		return nil, err
+	}
+
+	if gadgetDefaults == nil && seedDefaults == nil {
+		return nil, state.ErrNoState
+	}
+
+	defaults := make(map[string]interface{}, len(gadgetDefaults)+len(seedDefaults))
+	for k, v := range gadgetDefaults {
+		defaults[k] = v
+	}
+	for k, v := range seedDefaults {
+		defaults[k] = v
+	}
+	return defaults, nil
+}
+
+// gadgetConfigDefaults returns the configuration defaults for the snap specified in the gadget. If gadget is absent or the snap has no snap-id it returns ErrNoState.
+func gadgetConfigDefaults(st *state.State, snapName string) (map[string]interface{}, error) {
 	gadget, err := GadgetInfo(st)
 	if err != nil {
 		return nil, err
@@ -1725,6 +2121,21 @@ func ConfigDefaults(st *state.State, snapName string) (map[string]interface{}, e
 	return defaults, nil
 }
 
+// seedConfigDefaults returns the configuration defaults for the snap set in the seed.yaml of the image, if any.
+func seedConfigDefaults(snapName string) (map[string]interface{}, error) {
+	seedYamlFile := filepath.Join(dirs.SnapSeedDir, "seed.yaml")
+	if !osutil.FileExists(seedYamlFile) {
+		return nil, nil
+	}
+
+	seed, err := snap.ReadSeedYaml(seedYamlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return seed.Defaults[snapName], nil
+}
+
 func refreshCatalogs(st *state.State, theStore storestate.StoreService) error {
 	st.Unlock()
 	defer st.Lock()
@@ -33,6 +33,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errreport"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
@@ -93,10 +94,14 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 
 	oldSetupInstallHook := snapstate.SetupInstallHook
 	oldSetupPostRefreshHook := snapstate.SetupPostRefreshHook
+	oldSetupCheckHealthHook := snapstate.SetupCheckHealthHook
 	oldSetupRemoveHook := snapstate.SetupRemoveHook
+	oldSetupMigrateDataHook := snapstate.SetupMigrateDataHook
 	snapstate.SetupInstallHook = hookstate.SetupInstallHook
 	snapstate.SetupPostRefreshHook = hookstate.SetupPostRefreshHook
+	snapstate.SetupCheckHealthHook = hookstate.SetupCheckHealthHook
 	snapstate.SetupRemoveHook = hookstate.SetupRemoveHook
+	snapstate.SetupMigrateDataHook = hookstate.SetupMigrateDataHook
 
 	var err error
 	s.snapmgr, err = snapstate.Manager(s.state)
@@ -109,12 +114,16 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 
 	restore1 := snapstate.MockReadInfo(s.fakeBackend.ReadInfo)
 	restore2 := snapstate.MockOpenSnapFile(s.fakeBackend.OpenSnapFile)
+	restore3 := snapstate.MockIsOnMeteredConnection(func() (bool, error) { return false, nil })
 
 	s.reset = func() {
 		snapstate.SetupInstallHook = oldSetupInstallHook
 		snapstate.SetupPostRefreshHook = oldSetupPostRefreshHook
+		snapstate.SetupCheckHealthHook = oldSetupCheckHealthHook
 		snapstate.SetupRemoveHook = oldSetupRemoveHook
+		snapstate.SetupMigrateDataHook = oldSetupMigrateDataHook
 
+		restore3()
 		restore2()
 		restore1()
 		dirs.SetRootDir("/")
@@ -244,9 +253,11 @@ func verifyUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *state.St
 		"set-auto-aliases",
 		"setup-aliases",
 		"run-hook[post-refresh]",
-		"start-snap-services")
+		"start-snap-services",
+		"run-hook[check-health]")
 
-	c.Assert(ts.Tasks()[len(expected)-2].Summary(), Matches, `Run post-refresh hook of .*`)
+	c.Assert(ts.Tasks()[len(expected)-3].Summary(), Matches, `Run post-refresh hook of .*`)
+	c.Assert(ts.Tasks()[len(expected)-1].Summary(), Matches, `Run check-health hook of .*`)
 	for i := 0; i < discards; i++ {
 		expected = append(expected,
 			"clear-snap",
@@ -561,6 +572,97 @@ func (s *snapmgrTestSuite) TestUpdateCreatesGCTasks(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) setRetainConfig(c *C, values map[string]interface{}) {
+	tr := config.NewTransaction(s.state)
+	for k, v := range values {
+		c.Assert(tr.Set("core", k, v), IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) TestUpdateCreatesGCTasksRetain(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(3)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(4)},
+		},
+		Current:  snap.R(4),
+		SnapType: "app",
+	})
+
+	for _, t := range []struct {
+		config   map[string]interface{}
+		discards int
+	}{
+		{map[string]interface{}{"refresh.retain": 2}, 3},
+		{map[string]interface{}{"refresh.retain": 4}, 1},
+		// invalid values are ignored
+		{map[string]interface{}{"refresh.retain": 1}, 2},
+		// per-snap configuration takes precedence
+		{map[string]interface{}{"refresh.snaps.some-snap.retain": 5}, 0},
+		{map[string]interface{}{"refresh.snaps.some-snap.retain": 21}, 2},
+		{map[string]interface{}{"refresh.snaps.some-snap.retain": 2}, 3},
+	} {
+		s.setRetainConfig(c, t.config)
+
+		ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), 0, snapstate.Flags{})
+		c.Assert(err, IsNil)
+		verifyUpdateTasks(c, unlinkBefore|cleanupAfter, t.discards, ts, s.state)
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdateCreatesGCTasksRetainMaxSize(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(3)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(4)},
+		},
+		Current:  snap.R(4),
+		SnapType: "app",
+	})
+
+	c.Assert(os.MkdirAll(dirs.SnapBlobDir, 0755), IsNil)
+	for _, rev := range []snap.Revision{snap.R(1), snap.R(2), snap.R(3), snap.R(4)} {
+		f, err := os.Create(snap.MountFile("some-snap", rev))
+		c.Assert(err, IsNil)
+		c.Assert(f.Truncate(1024*1024), IsNil)
+		f.Close()
+	}
+
+	// would keep all revisions if it wasn't for the size limit
+	s.setRetainConfig(c, map[string]interface{}{
+		"refresh.retain": 5,
+		"refresh.snaps.some-snap.retain-max-size": 2,
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	verifyUpdateTasks(c, unlinkBefore|cleanupAfter, 2, ts, s.state)
+	var revs []snap.Revision
+	for _, t := range ts.Tasks() {
+		if t.Kind() != "clear-snap" {
+			continue
+		}
+		var snapsup snapstate.SnapSetup
+		c.Assert(t.Get("snap-setup", &snapsup), IsNil)
+		revs = append(revs, snapsup.Revision())
+	}
+	c.Check(revs, DeepEquals, []snap.Revision{snap.R(1), snap.R(2)})
+}
+
 func (s *snapmgrTestSuite) TestUpdateCreatesDiscardAfterCurrentTasks(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -600,7 +702,7 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 		SnapType: "app",
 	})
 
-	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, nil)
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 1)
 	c.Check(updates, DeepEquals, []string{"some-snap"})
@@ -610,6 +712,107 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyWithChannelOption(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	opts := map[string]*snapstate.SnapOptions{
+		"some-snap": {Channel: "channel-for-7"},
+	}
+	updates, tts, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, opts)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 1)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+
+	c.Assert(s.fakeBackend.ops, HasLen, 1)
+	c.Check(s.fakeBackend.ops[0].cand.Channel, Equals, "channel-for-7")
+
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Channel, Equals, "channel-for-7")
+	c.Check(snapsup.Revision(), Equals, snap.R(7))
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyWithRevisionOption(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	opts := map[string]*snapstate.SnapOptions{
+		"some-snap": {Revision: snap.R(42), Flags: snapstate.Flags{DevMode: true}},
+	}
+	updates, tts, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, opts)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 1)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+
+	// the pinned revision is asked for, not the refresh candidates
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{{
+		op:    "storesvc-snap",
+		name:  "some-snap",
+		revno: snap.R(42),
+	}})
+
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Channel, Equals, "stable")
+	c.Check(snapsup.Revision(), Equals, snap.R(42))
+	c.Check(snapsup.DevMode, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyWithRevisionOptionNotInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	opts := map[string]*snapstate.SnapOptions{
+		"some-snap": {Revision: snap.R(42)},
+	}
+	_, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, opts)
+	c.Assert(err, ErrorMatches, `cannot find snap "some-snap"`)
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyWithChannelOptionSwitchOnly(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "stable",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	// already at the revision of the channel, only the channel changes
+	opts := map[string]*snapstate.SnapOptions{
+		"some-snap": {Channel: "channel-for-7"},
+	}
+	updates, tts, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, opts)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+	c.Assert(tts, HasLen, 1)
+	c.Assert(tts[0].Tasks(), HasLen, 1)
+	c.Check(tts[0].Tasks()[0].Kind(), Equals, "switch-snap-channel")
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Channel, Equals, "channel-for-7")
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyDevModeConfinementFiltering(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -623,7 +826,7 @@ func (s *snapmgrTestSuite) TestUpdateManyDevModeConfinementFiltering(c *C) {
 	})
 
 	// updated snap is devmode, updatemany doesn't update it
-	_, tts, _ := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID)
+	_, tts, _ := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID, nil)
 	// FIXME: UpdateMany will not error out in this case (daemon catches this case, with a weird error)
 	c.Assert(tts, HasLen, 0)
 }
@@ -645,7 +848,7 @@ func (s *snapmgrTestSuite) TestUpdateManyClassicConfinementFiltering(c *C) {
 	})
 
 	// if a snap installed without --classic gets a classic update it isn't installed
-	_, tts, _ := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID)
+	_, tts, _ := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID, nil)
 	// FIXME: UpdateMany will not error out in this case (daemon catches this case, with a weird error)
 	c.Assert(tts, HasLen, 0)
 }
@@ -668,7 +871,7 @@ func (s *snapmgrTestSuite) TestUpdateManyClassic(c *C) {
 	})
 
 	// snap installed with classic: refresh gets classic
-	_, tts, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID)
+	_, tts, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID, nil)
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 1)
 }
@@ -687,7 +890,7 @@ func (s *snapmgrTestSuite) TestUpdateManyDevMode(c *C) {
 		SnapType: "app",
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0)
+	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, nil)
 	c.Assert(err, IsNil)
 	c.Check(updates, HasLen, 1)
 }
@@ -706,7 +909,7 @@ func (s *snapmgrTestSuite) TestUpdateAllDevMode(c *C) {
 		SnapType: "app",
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, _, err := snapstate.UpdateMany(s.state, nil, 0, nil)
 	c.Assert(err, IsNil)
 	c.Check(updates, HasLen, 0)
 }
@@ -735,7 +938,7 @@ func (s *snapmgrTestSuite) TestUpdateManyValidateRefreshes(c *C) {
 	// hook it up
 	snapstate.ValidateRefreshes = validateRefreshes
 
-	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, nil)
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 1)
 	c.Check(updates, DeepEquals, []string{"some-snap"})
@@ -767,13 +970,13 @@ func (s *snapmgrTestSuite) TestUpdateManyValidateRefreshesUnhappy(c *C) {
 	snapstate.ValidateRefreshes = validateRefreshes
 
 	// refresh all => no error
-	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, nil)
 	c.Assert(err, IsNil)
 	c.Check(tts, HasLen, 0)
 	c.Check(updates, HasLen, 0)
 
 	// refresh some-snap => report error
-	updates, tts, err = snapstate.UpdateMany(s.state, []string{"some-snap"}, 0)
+	updates, tts, err = snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, nil)
 	c.Assert(err, Equals, validateErr)
 	c.Check(tts, HasLen, 0)
 	c.Check(updates, HasLen, 0)
@@ -1105,6 +1308,48 @@ func (s *snapmgrTestSuite) TestUpdateTasks(c *C) {
 	c.Check(snapsup.Channel, Equals, "some-channel")
 }
 
+func (s *snapmgrTestSuite) TestUpdateTasksCrossEpoch(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "channel-for-epoch-1", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	kinds := taskKinds(ts.Tasks())
+	c.Assert(kinds[8:11], DeepEquals, []string{
+		"setup-profiles",
+		"run-hook[migrate-data]",
+		"link-snap",
+	})
+	c.Check(ts.Tasks()[9].Summary(), Equals, `Run migrate-data hook of "some-snap" snap if present`)
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(ts.Tasks()[0].Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.Epoch, Equals, "1")
+}
+
+func (s *snapmgrTestSuite) TestUpdateTasksSameEpoch(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(taskKinds(ts.Tasks()), Not(testutil.Contains), "run-hook[migrate-data]")
+}
+
 func (s *snapmgrTestSuite) TestUpdateTasksCoreSetsIgnoreOnConfigure(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -1593,6 +1838,124 @@ func (s *snapmgrTestSuite) TestInstalling(c *C) {
 	c.Check(snapstate.Installing(s.state), Equals, true)
 }
 
+func (s *snapmgrTestSuite) TestInstallFromStoreNotAllowed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.InstallFromStore(s.state, "some-snap", "other-store", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot use store "other-store": not in the allowed store overrides`)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.allowed-overrides", "some-store, another-store"), IsNil)
+	tr.Commit()
+
+	_, err = snapstate.InstallFromStore(s.state, "some-snap", "other-store", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot use store "other-store": not in the allowed store overrides`)
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestInstallFromStoreRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.allowed-overrides", "some-store,other-store"), IsNil)
+	tr.Commit()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.InstallFromStore(s.state, "some-snap", "other-store", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops[0], DeepEquals, fakeOp{
+		op:      "storesvc-snap",
+		name:    "some-snap",
+		revno:   snap.R(11),
+		storeID: "other-store",
+	})
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(ts.Tasks()[0].Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.StoreID, Equals, "other-store")
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.StoreID, Equals, "other-store")
+}
+
+func (s *snapmgrTestSuite) TestUpdateKeepsStoreID(c *C) {
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		Revision: snap.R(7),
+		SnapID:   "some-snap-id",
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		SnapType: "app",
+		StoreID:  "other-store",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops[0], DeepEquals, fakeOp{
+		op: "storesvc-list-refresh",
+		cand: store.RefreshCandidate{
+			Channel:  "some-channel",
+			SnapID:   "some-snap-id",
+			Revision: snap.R(7),
+			StoreID:  "other-store",
+		},
+		revno: snap.R(11),
+	})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(11))
+	c.Check(snapst.StoreID, Equals, "other-store")
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyPassesStoreID(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+		StoreID:  "other-store",
+	})
+
+	_, _, err := snapstate.UpdateMany(s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	op := s.fakeBackend.ops.First("storesvc-list-refresh")
+	c.Assert(op, NotNil)
+	c.Check(op.cand.StoreID, Equals, "other-store")
+}
+
 func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 	// use services-snap here to make sure services would be stopped/started appropriately
 	si := snap.SideInfo{
@@ -2340,7 +2703,7 @@ func (s *snapmgrTestSuite) TestMultiUpdateBlockedRevision(c *C) {
 		SnapType: "app",
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID)
+	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID, nil)
 	c.Assert(err, IsNil)
 	c.Check(updates, DeepEquals, []string{"some-snap"})
 
@@ -2378,7 +2741,7 @@ func (s *snapmgrTestSuite) TestAllUpdateBlockedRevision(c *C) {
 		Current:  si7.Revision,
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, nil, s.user.ID)
+	updates, _, err := snapstate.UpdateMany(s.state, nil, s.user.ID, nil)
 	c.Check(err, IsNil)
 	c.Check(updates, HasLen, 0)
 
@@ -2480,7 +2843,7 @@ func (s *snapmgrTestSuite) TestUpdateManyAutoAliasesScenarios(c *C) {
 			snapstate.Set(s.state, snapName, &snapst)
 		}
 
-		updates, tts, err := snapstate.UpdateMany(s.state, scenario.names, s.user.ID)
+		updates, tts, err := snapstate.UpdateMany(s.state, scenario.names, s.user.ID, nil)
 		c.Check(err, IsNil)
 
 		_, dropped, err := snapstate.AutoAliasesDelta(s.state, []string{"some-snap", "other-snap"})
@@ -2658,7 +3021,7 @@ func (s *snapmgrTestSuite) TestUpdateOneAutoAliasesScenarios(c *C) {
 		}
 		if scenario.update {
 			first := tasks[j]
-			j += 16
+			j += 17
 			c.Check(first.Kind(), Equals, "prerequisites")
 			wait := false
 			if expectedPruned["other-snap"]["aliasA"] {
@@ -3773,7 +4136,7 @@ func (s *snapmgrTestSuite) TestRevertRestoresConfigSnapshot(c *C) {
 	tr.Commit()
 
 	chg := s.state.NewChange("revert", "revert snap")
-	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{})
+	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{RevertConfig: true})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -3797,6 +4160,45 @@ func (s *snapmgrTestSuite) TestRevertRestoresConfigSnapshot(c *C) {
 	c.Assert(res, Equals, "100")
 }
 
+func (s *snapmgrTestSuite) TestRevertKeepsConfigByDefault(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+			{RealName: "some-snap", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("some-snap", "foo", "100")
+	tr.Commit()
+	config.SaveRevisionConfig(s.state, "some-snap", snap.R(1))
+	tr = config.NewTransaction(s.state)
+	tr.Set("some-snap", "foo", "200")
+	tr.Commit()
+
+	chg := s.state.NewChange("revert", "revert snap")
+	ts, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+
+	s.state.Lock()
+	// the configuration of rev. 2 is still there
+	tr = config.NewTransaction(s.state)
+	var res string
+	c.Assert(tr.Get("some-snap", "foo", &res), IsNil)
+	c.Assert(res, Equals, "200")
+}
+
 func (s *snapmgrTestSuite) TestUpdateDoesGC(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -4624,15 +5026,126 @@ func (s *snapmgrTestSuite) TestUndoMountSnapFailsInCopyData(c *C) {
 	c.Assert(s.fakeBackend.ops, DeepEquals, expected)
 }
 
+func (s *snapmgrTestSuite) TestUpdateCheckHealthTimeout(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	for _, t := range []struct {
+		value   interface{}
+		timeout time.Duration
+	}{
+		{nil, 5 * time.Minute},
+		{"90s", 90 * time.Second},
+		{"-1m", 5 * time.Minute},
+		{"soon", 5 * time.Minute},
+	} {
+		if t.value != nil {
+			tr := config.NewTransaction(s.state)
+			c.Assert(tr.Set("core", "refresh.check-health-timeout", t.value), IsNil)
+			tr.Commit()
+		}
+
+		ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), 0, snapstate.Flags{})
+		c.Assert(err, IsNil)
+
+		var hooksup hookstate.HookSetup
+		for _, t := range tasksWithKind(ts, "run-hook") {
+			c.Assert(t.Get("hook-setup", &hooksup), IsNil)
+			if hooksup.Hook == "check-health" {
+				break
+			}
+		}
+		c.Check(hooksup.Hook, Equals, "check-health")
+		c.Check(hooksup.Optional, Equals, true)
+		c.Check(hooksup.Timeout, Equals, t.timeout, Commentf("%v", t.value))
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallNoCheckHealth(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	for _, t := range tasksWithKind(ts, "run-hook") {
+		var hooksup hookstate.HookSetup
+		c.Assert(t.Get("hook-setup", &hooksup), IsNil)
+		c.Check(hooksup.Hook, Not(Equals), "check-health")
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdateUnhealthyReverts(c *C) {
+	// make the check-health hook fail
+	restore := snapstate.MockSetupCheckHealthHook(func(st *state.State, snapName string, timeout time.Duration) *state.Task {
+		return st.NewTask("error-trigger", "snap is unhealthy")
+	})
+	defer restore()
+
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		SnapID:   "some-snap-id",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*snap is unhealthy \(error out\).*`)
+
+	for _, t := range chg.Tasks() {
+		switch t.Kind() {
+		case "link-snap", "start-snap-services":
+			c.Check(t.Status(), Equals, state.UndoneStatus, Commentf(t.Kind()))
+		}
+	}
+
+	// back to the previous revision
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Check(snapst.Sequence, HasLen, 1)
+}
+
 func (s *snapmgrTestSuite) TestRefreshFailureCausesErrorReport(c *C) {
 	var errSnap, errMsg, errSig string
 	var errExtra map[string]string
 	var n int
-	restore := snapstate.MockErrtrackerReport(func(aSnap, aErrMsg, aDupSig string, extra map[string]string) (string, error) {
-		errSnap = aSnap
-		errMsg = aErrMsg
-		errSig = aDupSig
-		errExtra = extra
+	restore := snapstate.MockErrReport(func(st *state.State, r *errreport.Report) (string, error) {
+		c.Check(r.Kind, Equals, "snap")
+		errSnap = r.Snap
+		errMsg = r.Message
+		errSig = r.DuplicateSignature
+		errExtra = r.Extra
 		n += 1
 		return "oopsid", nil
 	})
@@ -4688,6 +5201,7 @@ set-auto-aliases: Hold
 setup-aliases: Hold
 run-hook: Hold
 start-snap-services: Hold
+run-hook: Hold
 cleanup: Hold
 run-hook: Hold`)
 	c.Check(errSig, Matches, `(?sm)snap-install:
@@ -4703,6 +5217,7 @@ set-auto-aliases: Hold
 setup-aliases: Hold
 run-hook: Hold
 start-snap-services: Hold
+run-hook: Hold
 cleanup: Hold
 run-hook: Hold`)
 
@@ -4762,6 +5277,68 @@ func (s *snapmgrTestSuite) TestEnsureRefreshRefusesWeekdaySchedules(c *C) {
 	c.Check(logbuf.String(), testutil.Contains, `cannot use refresh.schedule configuration: "mon@12:00-14:00" uses weekdays which is currently not supported`)
 }
 
+func (s *snapmgrTestSuite) TestEnsureRefreshManaged(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+	snapstate.CanManageRefreshes = func(*state.State) bool { return true }
+	defer func() { snapstate.CanManageRefreshes = nil }()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.schedule", "managed")
+	tr.Commit()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	// Ensure() also runs ensureRefreshes()
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	// no auto-refresh even though there is an update
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(s.snapmgr.RefreshSchedule(), Equals, "managed")
+	c.Check(s.snapmgr.NextRefresh().IsZero(), Equals, true)
+	var lastRefresh time.Time
+	c.Check(s.state.Get("last-refresh", &lastRefresh), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestEnsureRefreshManagedNotAllowed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+	snapstate.CanManageRefreshes = func(*state.State) bool { return false }
+	defer func() { snapstate.CanManageRefreshes = nil }()
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.schedule", "managed")
+	tr.Commit()
+
+	// Ensure() also runs ensureRefreshes()
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	c.Check(logbuf.String(), testutil.Contains, `cannot use refresh.schedule configuration: managed refreshes are not allowed by the model`)
+	// the default schedule is used, the option is kept
+	c.Check(s.snapmgr.RefreshSchedule(), Equals, "00:00-04:59/5:00-10:59/11:00-16:59/17:00-23:59")
+	var schedule string
+	tr = config.NewTransaction(s.state)
+	c.Assert(tr.Get("core", "refresh.schedule", &schedule), IsNil)
+	c.Check(schedule, Equals, "managed")
+	s.verifyRefreshLast(c)
+}
+
 func (s *snapmgrTestSuite) TestEnsureRefreshesNoUpdate(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -6025,7 +6602,7 @@ func (s *snapmgrTestSuite) TestInstallMany(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	installed, tts, err := snapstate.InstallMany(s.state, []string{"one", "two"}, 0)
+	installed, tts, err := snapstate.InstallMany(s.state, []string{"one", "two"}, 0, nil)
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 2)
 	c.Check(installed, DeepEquals, []string{"one", "two"})
@@ -6035,6 +6612,34 @@ func (s *snapmgrTestSuite) TestInstallMany(c *C) {
 	}
 }
 
+func (s *snapmgrTestSuite) TestInstallManyWithOptions(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	opts := map[string]*snapstate.SnapOptions{
+		"one": {Channel: "beta"},
+		"two": {Revision: snap.R(42), Flags: snapstate.Flags{DevMode: true}},
+	}
+	installed, tts, err := snapstate.InstallMany(s.state, []string{"one", "two"}, 0, opts)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(installed, DeepEquals, []string{"one", "two"})
+
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Name(), Equals, "one")
+	c.Check(snapsup.Channel, Equals, "beta")
+	c.Check(snapsup.Revision(), Equals, snap.R(11))
+	c.Check(snapsup.DevMode, Equals, false)
+
+	snapsup, err = snapstate.TaskSnapSetup(tts[1].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Name(), Equals, "two")
+	c.Check(snapsup.Channel, Equals, "stable")
+	c.Check(snapsup.Revision(), Equals, snap.R(42))
+	c.Check(snapsup.DevMode, Equals, true)
+}
+
 func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -6150,6 +6755,51 @@ func (s *snapmgrTestSuite) TestConfigDefaults(c *C) {
 	c.Assert(err, Equals, state.ErrNoState)
 }
 
+func (s *snapmgrTestSuite) TestConfigDefaultsFromSeed(c *C) {
+	r := release.MockOnClassic(false)
+	defer r()
+
+	// using MockSnap, we want to read the bits on disk
+	snapstate.MockReadInfo(snap.ReadInfo)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.prepareGadget(c)
+
+	seed := &snap.Seed{
+		Defaults: map[string]map[string]interface{}{
+			"some-snap":  {"key": "image-value", "other": "x"},
+			"local-snap": {"foo": "bar"},
+		},
+	}
+	err := os.MkdirAll(dirs.SnapSeedDir, 0755)
+	c.Assert(err, IsNil)
+	err = seed.Write(filepath.Join(dirs.SnapSeedDir, "seed.yaml"))
+	c.Assert(err, IsNil)
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(11), SnapID: "some-snap-id"},
+		},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+
+	// the image defaults win over the gadget ones
+	defls, err := snapstate.ConfigDefaults(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Assert(defls, DeepEquals, map[string]interface{}{"key": "image-value", "other": "x"})
+
+	defls, err = snapstate.ConfigDefaults(s.state, "local-snap")
+	c.Assert(err, IsNil)
+	c.Assert(defls, DeepEquals, map[string]interface{}{"foo": "bar"})
+
+	_, err = snapstate.ConfigDefaults(s.state, "other-snap")
+	c.Assert(err, Equals, state.ErrNoState)
+}
+
 func (s *snapmgrTestSuite) TestGadgetDefaultsAreNormalizedForConfigHook(c *C) {
 	var mockGadgetSnapYaml = `
 name: canonical-pc
@@ -6996,6 +7646,74 @@ func (s *snapmgrTestSuite) TestConflictMany(c *C) {
 	}
 }
 
+func (s *snapmgrTestSuite) TestConflictIgnoringPreparation(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	// need a change to make the tasks visible
+	s.state.NewChange("refresh", "...").AddAll(ts)
+
+	tasks := make(map[string]*state.Task)
+	for _, t := range ts.Tasks() {
+		tasks[t.Kind()] = t
+	}
+
+	// while downloading only operations ignoring preparation can go ahead
+	tasks["prerequisites"].SetStatus(state.DoneStatus)
+	tasks["download-snap"].SetStatus(state.DoingStatus)
+	c.Check(snapstate.CheckChangeConflict(s.state, "some-snap", nil, nil), ErrorMatches, `snap "some-snap" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflictIgnoringPreparation(s.state, "some-snap", nil), IsNil)
+
+	// once the snap is being mounted the change conflicts
+	tasks["download-snap"].SetStatus(state.DoneStatus)
+	tasks["validate-snap"].SetStatus(state.DoneStatus)
+	tasks["mount-snap"].SetStatus(state.DoingStatus)
+	c.Check(snapstate.CheckChangeConflictIgnoringPreparation(s.state, "some-snap", nil), ErrorMatches, `snap "some-snap" has changes in progress`)
+}
+
+func (s *snapmgrTestSuite) TestConflictAffectedSnapsByKind(c *C) {
+	snapstate.AddAffectedSnapsByKind("vendor-task", func(t *state.Task) ([]string, error) {
+		var snapNames []string
+		err := t.Get("snap-names", &snapNames)
+		return snapNames, err
+	})
+	defer snapstate.RemoveAffectedSnapsByKind("vendor-task")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("vendor-change", "...")
+	t := s.state.NewTask("vendor-task", "...")
+	t.Set("snap-names", []string{"some-snap", "other-snap"})
+	chg.AddTask(t)
+
+	c.Check(snapstate.CheckChangeConflict(s.state, "other-snap", nil, nil), ErrorMatches, `snap "other-snap" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflictMany(s.state, []string{"unrelated-snap", "some-snap"}, nil), ErrorMatches, `snap "some-snap" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflict(s.state, "unrelated-snap", nil, nil), IsNil)
+
+	// the predicate is honored
+	c.Check(snapstate.CheckChangeConflict(s.state, "some-snap", func(kind string) bool { return kind != "vendor-task" }, nil), IsNil)
+
+	// tasks of ready changes don't conflict
+	t.SetStatus(state.DoneStatus)
+	c.Check(snapstate.CheckChangeConflict(s.state, "some-snap", nil, nil), IsNil)
+
+	// tasks whose snaps cannot be found out are reported
+	t = s.state.NewTask("vendor-task", "...")
+	t.Set("snap-names", "not-a-list")
+	s.state.NewChange("vendor-change", "...").AddTask(t)
+	c.Check(snapstate.CheckChangeConflict(s.state, "some-snap", nil, nil), ErrorMatches, `internal error: cannot obtain affected snaps from task: \.\.\.`)
+}
+
 func (s *snapmgrTestSuite) TestInstallWithoutCoreRunThrough1(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
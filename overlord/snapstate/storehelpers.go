@@ -20,7 +20,11 @@
 package snapstate
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
 	"github.com/snapcore/snapd/snap"
@@ -54,6 +58,7 @@ func updateInfo(st *state.State, snapst *SnapState, channel string, userID int)
 		SnapID:   curInfo.SnapID,
 		Revision: curInfo.Revision,
 		Epoch:    curInfo.Epoch,
+		StoreID:  snapst.StoreID,
 	}
 
 	theStore := storestate.Store(st)
@@ -64,6 +69,10 @@ func updateInfo(st *state.State, snapst *SnapState, channel string, userID int)
 }
 
 func snapInfo(st *state.State, name, channel string, revision snap.Revision, userID int) (*snap.Info, error) {
+	return snapInfoFromStore(st, name, "", channel, revision, userID)
+}
+
+func snapInfoFromStore(st *state.State, name, storeID, channel string, revision snap.Revision, userID int) (*snap.Info, error) {
 	user, err := userFromUserID(st, userID)
 	if err != nil {
 		return nil, err
@@ -74,8 +83,28 @@ func snapInfo(st *state.State, name, channel string, revision snap.Revision, use
 		Name:     name,
 		Channel:  channel,
 		Revision: revision,
+		StoreID:  storeID,
 	}
 	snap, err := theStore.SnapInfo(spec, user)
 	st.Lock()
 	return snap, err
 }
+
+// checkStoreOverride checks that snaps may be fetched from the given
+// store instead of the device one, which is the case only for the
+// stores listed in the comma separated store.allowed-overrides core
+// option.
+func checkStoreOverride(st *state.State, storeID string) error {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "store.allowed-overrides", &value)
+	if err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	for _, allowed := range strings.Split(value, ",") {
+		if strings.TrimSpace(allowed) == storeID {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use store %q: not in the allowed store overrides", storeID)
+}
@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/squashfs"
+)
+
+// SnapVerity allows to hook looking up the dm-verity root hash and salt
+// asserted for the snap file with the given digest, if any.
+var SnapVerity func(st *state.State, snapSHA3_384 string) (rootHash, salt string, err error)
+
+var veritySupported = squashfs.VeritySupported
+
+// verityEnabled returns whether snaps should be mounted with dm-verity
+// enforcement, as set by the snaps.verity core option. Invalid values
+// are ignored.
+func verityEnabled(st *state.State) (bool, error) {
+	var value interface{}
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "snaps.verity", &value)
+	if config.IsNoOption(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		logger.Noticef("cannot use snaps.verity configuration: invalid value %v", value)
+		return false, nil
+	}
+	return enabled, nil
+}
+
+// verityOptions returns how the snap should be mounted with dm-verity
+// enforcement, or nil if it should not. The root hash is checked
+// against the one asserted for the snap file when there is one.
+// It must be called with the state unlocked.
+func verityOptions(st *state.State, snapsup *SnapSetup) (*backend.VerityOptions, error) {
+	st.Lock()
+	enabled, err := verityEnabled(st)
+	st.Unlock()
+	if err != nil || !enabled {
+		return nil, err
+	}
+	if !veritySupported() {
+		logger.Noticef("Cannot mount snap %q with dm-verity enforcement: not supported on this system", snapsup.Name())
+		return nil, nil
+	}
+
+	opts := &backend.VerityOptions{}
+	if SnapVerity == nil || snapsup.SideInfo == nil || snapsup.SideInfo.SnapID == "" {
+		return opts, nil
+	}
+	digest, _, err := asserts.SnapFileSHA3_384(snapsup.SnapPath)
+	if err != nil {
+		return nil, err
+	}
+	st.Lock()
+	defer st.Unlock()
+	opts.RootHash, opts.Salt, err = SnapVerity(st, digest)
+	if err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package standby decides when snapd has been idle long enough to exit,
+// leaving it to socket activation to start it again.
+package standby
+
+import (
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	// standbyPoll is how often snapd checks whether it can go into
+	// standby.
+	standbyPoll = 5 * time.Second
+	// minIdleExit is the shortest idle period accepted for the
+	// daemon.idle-exit option, so that snapd doesn't flap.
+	minIdleExit = 1 * time.Minute
+)
+
+// Opinionator is implemented by the parts of snapd that may need it to
+// keep running.
+type Opinionator interface {
+	// CanStandby returns whether snapd could stop right now as far as
+	// the implementer is concerned.
+	CanStandby() bool
+}
+
+// StandbyOpinions collects the opinions of the parts of snapd and asks
+// for the daemon to stop once they all agree it can, and have been
+// agreeing for as long as the daemon.idle-exit option says.
+type StandbyOpinions struct {
+	state     *state.State
+	opinions  []Opinionator
+	idleSince time.Time
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+// New returns a StandbyOpinions for the given state. Until Start is
+// called it does nothing.
+func New(st *state.State) *StandbyOpinions {
+	return &StandbyOpinions{
+		state:  st,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// AddOpinion adds a part of snapd to ask before going into standby.
+func (m *StandbyOpinions) AddOpinion(opi Opinionator) {
+	if opi != nil {
+		m.opinions = append(m.opinions, opi)
+	}
+}
+
+// idleExit returns how long snapd must be idle before exiting, as set by
+// the daemon.idle-exit core option (a duration such as "10m"), or zero if
+// snapd should keep running.
+func idleExit(st *state.State) time.Duration {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "daemon.idle-exit", &value)
+	if err != nil {
+		if !config.IsNoOption(err) {
+			logger.Noticef("cannot read daemon.idle-exit configuration: %v", err)
+		}
+		return 0
+	}
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logger.Noticef("cannot use daemon.idle-exit configuration: invalid duration %q", value)
+		return 0
+	}
+	if d > 0 && d < minIdleExit {
+		d = minIdleExit
+	}
+	return d
+}
+
+// busy returns whether some changes are still in progress.
+func busy(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// CanStandby returns whether snapd has been idle long enough to stop.
+func (m *StandbyOpinions) CanStandby() bool {
+	now := time.Now()
+
+	m.state.Lock()
+	wait := idleExit(m.state)
+	idle := wait > 0 && !busy(m.state) && !m.state.Restarting()
+	m.state.Unlock()
+
+	for _, opi := range m.opinions {
+		if !idle {
+			break
+		}
+		idle = opi.CanStandby()
+	}
+	if !idle {
+		m.idleSince = time.Time{}
+		return false
+	}
+	if m.idleSince.IsZero() {
+		m.idleSince = now
+	}
+	return now.Sub(m.idleSince) >= wait
+}
+
+// Start checks periodically whether snapd can stop, asking for it to do
+// so through RequestRestart(state.StopDaemon) the first time it can.
+func (m *StandbyOpinions) Start() {
+	m.doneCh = make(chan struct{})
+	go func() {
+		defer close(m.doneCh)
+		ticker := time.NewTicker(standbyPoll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-ticker.C:
+				if m.CanStandby() {
+					logger.Noticef("Stopping as snapd has been idle, socket activation will start it again.")
+					m.state.RequestRestart(state.StopDaemon)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the checks started by Start and waits for them to be done.
+func (m *StandbyOpinions) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+	if m.doneCh != nil {
+		<-m.doneCh
+	}
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package standby_test
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/standby"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type standbySuite struct {
+	state *state.State
+
+	restartRequested chan state.RestartType
+	restore          []func()
+}
+
+var _ = Suite(&standbySuite{})
+
+type fakeBackend struct {
+	restartRequested chan state.RestartType
+}
+
+func (b *fakeBackend) Checkpoint([]byte) error            { return nil }
+func (b *fakeBackend) EnsureBefore(d time.Duration)       {}
+func (b *fakeBackend) RequestRestart(t state.RestartType) { b.restartRequested <- t }
+
+type opinion struct {
+	canStandby bool
+}
+
+func (o *opinion) CanStandby() bool {
+	return o.canStandby
+}
+
+func (s *standbySuite) SetUpTest(c *C) {
+	s.restartRequested = make(chan state.RestartType, 1)
+	s.state = state.New(&fakeBackend{restartRequested: s.restartRequested})
+	s.restore = []func(){
+		standby.MockStandbyPoll(time.Millisecond),
+		standby.MockMinIdleExit(time.Millisecond),
+	}
+}
+
+func (s *standbySuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+}
+
+func (s *standbySuite) setIdleExit(c *C, value string) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "daemon.idle-exit", value), IsNil)
+	tr.Commit()
+}
+
+func (s *standbySuite) TestCanStandbyDisabledByDefault(c *C) {
+	m := standby.New(s.state)
+	c.Check(m.CanStandby(), Equals, false)
+
+	for _, value := range []string{"", "0", "-1m", "soon"} {
+		s.setIdleExit(c, value)
+		c.Check(m.CanStandby(), Equals, false, Commentf(value))
+	}
+}
+
+func (s *standbySuite) TestCanStandby(c *C) {
+	s.setIdleExit(c, "1ms")
+	m := standby.New(s.state)
+	// snapd needs to be idle for a while
+	c.Check(m.CanStandby(), Equals, false)
+	time.Sleep(2 * time.Millisecond)
+	c.Check(m.CanStandby(), Equals, true)
+}
+
+func (s *standbySuite) TestCanStandbyMinIdleExit(c *C) {
+	restore := standby.MockMinIdleExit(time.Hour)
+	defer restore()
+	s.setIdleExit(c, "1ms")
+	m := standby.New(s.state)
+	c.Check(m.CanStandby(), Equals, false)
+	time.Sleep(2 * time.Millisecond)
+	c.Check(m.CanStandby(), Equals, false)
+}
+
+func (s *standbySuite) TestCannotStandbyWithChangesInProgress(c *C) {
+	s.setIdleExit(c, "1ms")
+	m := standby.New(s.state)
+
+	s.state.Lock()
+	chg := s.state.NewChange("foo", "...")
+	chg.AddTask(s.state.NewTask("bar", "..."))
+	s.state.Unlock()
+
+	c.Check(m.CanStandby(), Equals, false)
+	time.Sleep(2 * time.Millisecond)
+	c.Check(m.CanStandby(), Equals, false)
+
+	s.state.Lock()
+	chg.SetStatus(state.DoneStatus)
+	s.state.Unlock()
+
+	// the idle period starts over
+	c.Check(m.CanStandby(), Equals, false)
+	time.Sleep(2 * time.Millisecond)
+	c.Check(m.CanStandby(), Equals, true)
+}
+
+func (s *standbySuite) TestOpinions(c *C) {
+	s.setIdleExit(c, "1ms")
+	m := standby.New(s.state)
+	busy := &opinion{canStandby: false}
+	m.AddOpinion(&opinion{canStandby: true})
+	m.AddOpinion(busy)
+
+	c.Check(m.CanStandby(), Equals, false)
+	time.Sleep(2 * time.Millisecond)
+	c.Check(m.CanStandby(), Equals, false)
+
+	busy.canStandby = true
+	c.Check(m.CanStandby(), Equals, false)
+	time.Sleep(2 * time.Millisecond)
+	c.Check(m.CanStandby(), Equals, true)
+}
+
+func (s *standbySuite) TestStartRequestsStop(c *C) {
+	s.setIdleExit(c, "1ms")
+	m := standby.New(s.state)
+	m.Start()
+	defer m.Stop()
+
+	select {
+	case t := <-s.restartRequested:
+		c.Check(t, Equals, state.StopDaemon)
+	case <-time.After(5 * time.Second):
+		c.Fatal("stop was not requested")
+	}
+}
+
+func (s *standbySuite) TestStopBeforeStandby(c *C) {
+	m := standby.New(s.state)
+	m.Start()
+	time.Sleep(5 * time.Millisecond)
+	m.Stop()
+	// stopping again, or without starting, is fine
+	m.Stop()
+	standby.New(s.state).Stop()
+
+	select {
+	case <-s.restartRequested:
+		c.Fatal("stop was requested")
+	default:
+	}
+}
@@ -121,6 +121,7 @@ type Change struct {
 	summary string
 	status  Status
 	clean   bool
+	paused  bool
 	data    customData
 	taskIDs []string
 	lanes   int
@@ -155,6 +156,7 @@ type marshalledChange struct {
 	Summary string                      `json:"summary"`
 	Status  Status                      `json:"status"`
 	Clean   bool                        `json:"clean,omitempty"`
+	Paused  bool                        `json:"paused,omitempty"`
 	Data    map[string]*json.RawMessage `json:"data,omitempty"`
 	TaskIDs []string                    `json:"task-ids,omitempty"`
 	Lanes   int                         `json:"lanes,omitempty"`
@@ -176,6 +178,7 @@ func (c *Change) MarshalJSON() ([]byte, error) {
 		Summary: c.summary,
 		Status:  c.status,
 		Clean:   c.clean,
+		Paused:  c.paused,
 		Data:    c.data,
 		TaskIDs: c.taskIDs,
 		Lanes:   c.lanes,
@@ -200,6 +203,7 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 	c.summary = unmarshalled.Summary
 	c.status = unmarshalled.Status
 	c.clean = unmarshalled.Clean
+	c.paused = unmarshalled.Paused
 	custData := unmarshalled.Data
 	if custData == nil {
 		custData = make(customData)
@@ -488,6 +492,27 @@ func (c *Change) Abort() {
 	c.abortTasks(tasks, make(map[int]bool), make(map[string]bool))
 }
 
+// Pause flags the change so that none of its pending tasks are started,
+// tasks already running are left to complete. Undoing, for instance
+// after an abort, is not affected. See Resume.
+func (c *Change) Pause() {
+	c.state.writing()
+	c.paused = true
+}
+
+// Resume lets the pending tasks of a paused change run again from the
+// next ensure pass.
+func (c *Change) Resume() {
+	c.state.writing()
+	c.paused = false
+}
+
+// IsPaused returns whether the change was paused with Pause.
+func (c *Change) IsPaused() bool {
+	c.state.reading()
+	return c.paused
+}
+
 // AbortLanes aborts all tasks in the provided lanes and any tasks waiting on them,
 // except for tasks that are also in a healthy lane (not aborted, and not waiting
 // on aborted).
@@ -20,14 +20,16 @@
 package state_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/snapcore/snapd/overlord/state"
-
 	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type changeSuite struct{}
@@ -324,12 +326,15 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 		func() { chg.AddTask(nil) },
 		func() { chg.AddAll(nil) },
 		func() { chg.UnmarshalJSON(nil) },
+		func() { chg.Pause() },
+		func() { chg.Resume() },
 	}
 
 	reads := []func(){
 		func() { chg.Get("a", nil) },
 		func() { chg.Status() },
 		func() { chg.IsClean() },
+		func() { chg.IsPaused() },
 		func() { chg.Tasks() },
 		func() { chg.Err() },
 		func() { chg.MarshalJSON() },
@@ -354,6 +359,27 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 	}
 }
 
+func (cs *changeSuite) TestPauseResume(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	c.Check(chg.IsPaused(), Equals, false)
+
+	chg.Pause()
+	c.Check(chg.IsPaused(), Equals, true)
+	data, err := json.Marshal(chg)
+	c.Assert(err, IsNil)
+	c.Check(string(data), testutil.Contains, `"paused":true`)
+
+	chg.Resume()
+	c.Check(chg.IsPaused(), Equals, false)
+	data, err = json.Marshal(chg)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Not(testutil.Contains), `"paused"`)
+}
+
 func (cs *changeSuite) TestAbort(c *C) {
 	st := state.New(nil)
 	st.Lock()
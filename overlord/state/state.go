@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"sort"
 	"strconv"
 	"sync"
@@ -32,6 +33,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 )
 
 // A Backend is used by State to checkpoint on every unlock operation
@@ -76,6 +78,9 @@ const (
 	RestartUnset RestartType = iota
 	RestartDaemon
 	RestartSystem
+	// StopDaemon asks for the daemon to stop, to be started again by
+	// socket activation or a timer when needed.
+	StopDaemon
 )
 
 // State represents an evolving system state that persists across restarts.
@@ -89,6 +94,9 @@ const (
 type State struct {
 	mu  sync.Mutex
 	muC int32
+	// where and since when the lock is held, for diagnostics
+	holderPC    uintptr
+	holderSince int64
 
 	lastTaskId   int
 	lastChangeId int
@@ -103,6 +111,12 @@ type State struct {
 
 	cache map[interface{}]interface{}
 
+	logArchive LogArchive
+
+	taskErrorHandler func(t *Task, err error)
+
+	taskMetrics *metrics.Registry
+
 	restarting bool
 	restartLck sync.Mutex
 }
@@ -116,6 +130,8 @@ func New(backend Backend) *State {
 		tasks:    make(map[string]*Task),
 		modified: true,
 		cache:    make(map[interface{}]interface{}),
+
+		taskMetrics: metrics.New(),
 	}
 }
 
@@ -124,10 +140,35 @@ func (s *State) Modified() bool {
 	return s.modified
 }
 
+// SetTaskErrorHandler sets a function to call whenever a task fails
+// with an error. It is called by the task runners with the state
+// locked, so it must not block.
+func (s *State) SetTaskErrorHandler(handler func(t *Task, err error)) {
+	s.reading() // Doesn't touch persisted data.
+	s.taskErrorHandler = handler
+}
+
 // Lock acquires the state lock.
 func (s *State) Lock() {
 	s.mu.Lock()
 	atomic.AddInt32(&s.muC, 1)
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	atomic.StoreUintptr(&s.holderPC, pcs[0])
+	atomic.StoreInt64(&s.holderSince, time.Now().UnixNano())
+}
+
+// LockHolder returns the function and line that acquired the state lock,
+// and when, or an empty string if the lock is not held. Unlike the other
+// methods it is meant to be called without holding the lock.
+func (s *State) LockHolder() (holder string, since time.Time) {
+	pc := atomic.LoadUintptr(&s.holderPC)
+	nanos := atomic.LoadInt64(&s.holderSince)
+	if pc == 0 {
+		return "", time.Time{}
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line), time.Unix(0, nanos)
 }
 
 func (s *State) reading() {
@@ -144,6 +185,7 @@ func (s *State) writing() {
 }
 
 func (s *State) unlock() {
+	atomic.StoreUintptr(&s.holderPC, 0)
 	atomic.AddInt32(&s.muC, -1)
 	s.mu.Unlock()
 }
@@ -303,6 +345,13 @@ func (s *State) Cache(key, value interface{}) {
 	}
 }
 
+// TaskMetrics returns the registry where the task runners record how
+// long running the tasks of each kind takes and how often it fails.
+// The registry is not persisted and can be used without the state lock.
+func (s *State) TaskMetrics() *metrics.Registry {
+	return s.taskMetrics
+}
+
 // NewChange adds a new change to the state.
 func (s *State) NewChange(kind, summary string) *Change {
 	s.writing()
@@ -348,6 +397,19 @@ func (s *State) NewTask(kind, summary string) *Task {
 	return t
 }
 
+// DiscardTasks removes from the state the given tasks, none of which
+// may have been added to a change. It is meant for tasks that were
+// created only to inspect what an operation would do.
+func (s *State) DiscardTasks(tasks []*Task) {
+	s.writing()
+	for _, t := range tasks {
+		if chg := t.Change(); chg != nil {
+			panic(fmt.Sprintf("internal error: cannot discard task %s of change %s", t.ID(), chg.ID()))
+		}
+		delete(s.tasks, t.ID())
+	}
+}
+
 // Tasks returns all tasks currently known to the state and linked to changes.
 func (s *State) Tasks() []*Task {
 	s.reading()
@@ -391,7 +453,8 @@ func (s *State) tasksIn(tids []string) []*Task {
 // It also removes tasks unlinked to changes after pruneWait. When
 // there are more changes than the limit set via "maxReadyChanges"
 // those changes in ready state will also removed even if they are below
-// the pruneWait duration.
+// the pruneWait duration. The task logs of the ready changes that are
+// kept are moved to the log archive, if one was set.
 func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	now := time.Now()
 	pruneLimit := now.Add(-pruneWait)
@@ -428,12 +491,16 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
 			s.writing()
+			s.discardLogs(chg)
 			for _, t := range chg.Tasks() {
 				delete(s.tasks, t.ID())
 			}
 			delete(s.changes, chg.ID())
 			readyChangesCount--
+			continue
 		}
+		// keep the logs of ready changes out of the state
+		s.archiveLogs(chg)
 	}
 
 	for tid, t := range s.tasks {
@@ -458,5 +525,6 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	s.backend = backend
 	s.modified = false
 	s.cache = make(map[interface{}]interface{})
+	s.taskMetrics = metrics.New()
 	return s, err
 }
@@ -55,6 +55,22 @@ func (ss *stateSuite) TestLockUnlock(c *C) {
 	st.Unlock()
 }
 
+func (ss *stateSuite) TestLockHolder(c *C) {
+	st := state.New(nil)
+	holder, _ := st.LockHolder()
+	c.Check(holder, Equals, "")
+
+	before := time.Now()
+	st.Lock()
+	holder, since := st.LockHolder()
+	c.Check(holder, Matches, `.*/overlord/state_test.\(\*stateSuite\).TestLockHolder \(.*state_test.go:[0-9]+\)`)
+	c.Check(since.Before(before), Equals, false)
+	st.Unlock()
+
+	holder, _ = st.LockHolder()
+	c.Check(holder, Equals, "")
+}
+
 func (ss *stateSuite) TestGetAndSet(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -680,6 +696,25 @@ func (ss *stateSuite) TestNewTaskHiddenUntilLinked(c *C) {
 	c.Check(st.Task(t1.ID()), IsNil)
 }
 
+func (ss *stateSuite) TestDiscardTasks(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t1 := st.NewTask("check", "...")
+	t2 := st.NewTask("inst", "...")
+	st.DiscardTasks([]*state.Task{t1})
+
+	// linking the discarded task doesn't bring it back
+	chg := st.NewChange("install", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	c.Check(st.Task(t1.ID()), IsNil)
+	c.Check(st.Task(t2.ID()), Equals, t2)
+
+	c.Check(func() { st.DiscardTasks([]*state.Task{t2}) }, PanicMatches, `internal error: cannot discard task 2 of change 1`)
+}
+
 func (ss *stateSuite) TestMethodEntrance(c *C) {
 	st := state.New(&fakeStateBackend{})
 
@@ -693,6 +728,7 @@ func (ss *stateSuite) TestMethodEntrance(c *C) {
 		func() { st.NewTask("download", "...") },
 		func() { st.UnmarshalJSON(nil) },
 		func() { st.NewLane() },
+		func() { st.DiscardTasks(nil) },
 	}
 
 	reads := []func(){
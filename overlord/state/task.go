@@ -52,6 +52,9 @@ type Task struct {
 	log       []string
 	change    string
 
+	// logArchived is set when the log was moved to the log archive
+	logArchived bool
+
 	spawnTime time.Time
 	readyTime time.Time
 
@@ -84,6 +87,8 @@ type marshalledTask struct {
 	Log       []string                    `json:"log,omitempty"`
 	Change    string                      `json:"change"`
 
+	LogArchived bool `json:"log-archived,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 
@@ -115,6 +120,8 @@ func (t *Task) MarshalJSON() ([]byte, error) {
 		Log:       t.log,
 		Change:    t.change,
 
+		LogArchived: t.logArchived,
+
 		SpawnTime: t.spawnTime,
 		ReadyTime: readyTime,
 
@@ -148,6 +155,7 @@ func (t *Task) UnmarshalJSON(data []byte) error {
 	t.lanes = unmarshalled.Lanes
 	t.log = unmarshalled.Log
 	t.change = unmarshalled.Change
+	t.logArchived = unmarshalled.LogArchived
 	t.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
 		t.readyTime = *unmarshalled.ReadyTime
@@ -292,6 +300,12 @@ func MockTime(now time.Time) (restore func()) {
 }
 
 func (t *Task) addLog(kind, format string, args []interface{}) {
+	if t.logArchived {
+		// carry on from the archived log, it is archived again
+		// when pruning
+		t.log = append([]string(nil), t.state.archivedLog(t)...)
+		t.logArchived = false
+	}
 	if len(t.log) > 9 {
 		copy(t.log, t.log[len(t.log)-9:])
 		t.log = t.log[:9]
@@ -312,10 +326,17 @@ func (t *Task) addLog(kind, format string, args []interface{}) {
 // Messages are prefixed with one of the known message kinds.
 // See details about LogInfo and LogError.
 //
+// The log of a task of a ready change may have been moved to the log
+// archive of the state when pruning, in which case it is loaded from
+// there.
+//
 // The returned slice should not be read from without the
 // state lock held, and should not be written to.
 func (t *Task) Log() []string {
 	t.state.reading()
+	if t.logArchived {
+		return t.state.archivedLog(t)
+	}
 	return t.log
 }
 
@@ -0,0 +1,128 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"github.com/snapcore/snapd/logger"
+)
+
+// A LogArchive keeps the logs of the tasks of ready changes out of
+// the state, so that they do not need to stay in memory.
+type LogArchive interface {
+	// Store saves the logs of the tasks of the given change,
+	// keyed by task id.
+	Store(chgID string, logs map[string][]string) error
+	// Load returns the logs saved for the given change.
+	Load(chgID string) (map[string][]string, error)
+	// Discard forgets the logs saved for the given change.
+	Discard(chgID string) error
+}
+
+// SetLogArchive sets the archive that the logs of the tasks of ready
+// changes are moved to when pruning.
+func (s *State) SetLogArchive(archive LogArchive) {
+	s.reading() // Doesn't touch persisted data.
+	s.logArchive = archive
+}
+
+// archiveLogs moves the logs of the tasks of the given ready change
+// to the log archive, if any.
+func (s *State) archiveLogs(chg *Change) {
+	if s.logArchive == nil {
+		return
+	}
+	tasks := chg.Tasks()
+	pending := false
+	for _, t := range tasks {
+		if len(t.log) > 0 {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		return
+	}
+	// the logs archived before for the change are stored again
+	// together with the new ones
+	logs := make(map[string][]string)
+	for _, t := range tasks {
+		if t.logArchived {
+			logs[t.id] = s.archivedLog(t)
+		} else if len(t.log) > 0 {
+			logs[t.id] = t.log
+		}
+	}
+	delete(s.cache, archivedLogsKey{})
+	if err := s.logArchive.Store(chg.id, logs); err != nil {
+		logger.Noticef("cannot archive the task logs of change %s: %v", chg.id, err)
+		return
+	}
+	s.writing()
+	for _, t := range tasks {
+		if len(t.log) > 0 {
+			t.log = nil
+			t.logArchived = true
+		}
+	}
+}
+
+// discardLogs forgets the archived logs of the tasks of the given change.
+func (s *State) discardLogs(chg *Change) {
+	if s.logArchive == nil {
+		return
+	}
+	archived := false
+	for _, t := range chg.Tasks() {
+		archived = archived || t.logArchived
+	}
+	if !archived {
+		return
+	}
+	if err := s.logArchive.Discard(chg.id); err != nil {
+		logger.Noticef("cannot discard the task logs of change %s: %v", chg.id, err)
+	}
+	delete(s.cache, archivedLogsKey{})
+}
+
+type archivedLogsKey struct{}
+
+type archivedLogs struct {
+	chgID string
+	logs  map[string][]string
+}
+
+// archivedLog returns the log of the given task from the log
+// archive. The logs of the last change loaded are kept around as
+// the logs of all its tasks are usually wanted together.
+func (s *State) archivedLog(t *Task) []string {
+	if s.logArchive == nil {
+		return nil
+	}
+	if cached, ok := s.cache[archivedLogsKey{}].(*archivedLogs); ok && cached.chgID == t.change {
+		return cached.logs[t.id]
+	}
+	logs, err := s.logArchive.Load(t.change)
+	if err != nil {
+		logger.Noticef("cannot load the task logs of change %s: %v", t.change, err)
+		return nil
+	}
+	s.cache[archivedLogsKey{}] = &archivedLogs{chgID: t.change, logs: logs}
+	return logs[t.id]
+}
@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type taskLogSuite struct {
+	archive *fakeLogArchive
+}
+
+var _ = Suite(&taskLogSuite{})
+
+type fakeLogArchive struct {
+	logs     map[string]map[string][]string
+	loads    int
+	storeErr error
+}
+
+func (a *fakeLogArchive) Store(chgID string, logs map[string][]string) error {
+	if a.storeErr != nil {
+		return a.storeErr
+	}
+	a.logs[chgID] = logs
+	return nil
+}
+
+func (a *fakeLogArchive) Load(chgID string) (map[string][]string, error) {
+	a.loads++
+	logs, ok := a.logs[chgID]
+	if !ok {
+		return nil, fmt.Errorf("no logs for change %s", chgID)
+	}
+	return logs, nil
+}
+
+func (a *fakeLogArchive) Discard(chgID string) error {
+	delete(a.logs, chgID)
+	return nil
+}
+
+func (s *taskLogSuite) SetUpTest(c *C) {
+	s.archive = &fakeLogArchive{logs: make(map[string]map[string][]string)}
+}
+
+func (s *taskLogSuite) readyChange(st *state.State, readyAgo time.Duration) (*state.Change, *state.Task, *state.Task) {
+	now := time.Now()
+	t1 := st.NewTask("foo", "...")
+	t1.Logf("one")
+	t1.Errorf("two")
+	t2 := st.NewTask("foo", "...")
+	chg := st.NewChange("install", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	chg.SetStatus(state.DoneStatus)
+	state.MockChangeTimes(chg, now.Add(-readyAgo), now.Add(-readyAgo))
+	return chg, t1, t2
+}
+
+func (s *taskLogSuite) TestPruneArchivesLogs(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	st.SetLogArchive(s.archive)
+
+	chg, t1, t2 := s.readyChange(st, 10*time.Minute)
+	logs := t1.Log()
+	c.Assert(logs, HasLen, 2)
+
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	c.Assert(st.Change(chg.ID()), Equals, chg)
+	c.Check(s.archive.logs, DeepEquals, map[string]map[string][]string{
+		chg.ID(): {t1.ID(): logs},
+	})
+
+	data, err := t1.MarshalJSON()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Not(testutil.Contains), `"log":`)
+	c.Check(string(data), testutil.Contains, `"log-archived":true`)
+
+	// loaded lazily, once per change
+	c.Check(s.archive.loads, Equals, 0)
+	c.Check(t1.Log(), DeepEquals, logs)
+	c.Check(t2.Log(), HasLen, 0)
+	c.Check(t1.Log(), DeepEquals, logs)
+	c.Check(s.archive.loads, Equals, 1)
+
+	// pruning again has nothing more to archive
+	st.Prune(time.Hour, 3*time.Hour, 100)
+	c.Check(t1.Log(), DeepEquals, logs)
+	c.Check(s.archive.loads, Equals, 1)
+}
+
+func (s *taskLogSuite) TestPruneKeepsLogsOfPendingChanges(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	st.SetLogArchive(s.archive)
+
+	t := st.NewTask("foo", "...")
+	t.Logf("one")
+	chg := st.NewChange("install", "...")
+	chg.AddTask(t)
+
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	c.Check(s.archive.logs, HasLen, 0)
+	c.Check(t.Log(), HasLen, 1)
+}
+
+func (s *taskLogSuite) TestPruneWithoutArchive(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, t1, _ := s.readyChange(st, 10*time.Minute)
+
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	c.Check(t1.Log(), HasLen, 2)
+	data, err := t1.MarshalJSON()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Not(testutil.Contains), `"log-archived"`)
+}
+
+func (s *taskLogSuite) TestPruneArchiveError(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	s.archive.storeErr = fmt.Errorf("boom")
+	st.SetLogArchive(s.archive)
+
+	_, t1, _ := s.readyChange(st, 10*time.Minute)
+
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	// the logs stay in the state
+	c.Check(t1.Log(), HasLen, 2)
+	c.Check(s.archive.loads, Equals, 0)
+}
+
+func (s *taskLogSuite) TestPruneDiscardsArchivedLogs(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	st.SetLogArchive(s.archive)
+
+	chg, _, _ := s.readyChange(st, 10*time.Minute)
+	st.Prune(time.Hour, 3*time.Hour, 100)
+	c.Assert(s.archive.logs, HasLen, 1)
+
+	state.MockChangeTimes(chg, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	c.Check(st.Change(chg.ID()), IsNil)
+	c.Check(s.archive.logs, HasLen, 0)
+}
+
+func (s *taskLogSuite) TestLogAfterArchiving(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	st.SetLogArchive(s.archive)
+
+	chg, t1, t2 := s.readyChange(st, 10*time.Minute)
+	logs := t1.Log()
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	t1.Logf("three")
+	c.Assert(t1.Log(), HasLen, 3)
+	c.Check(t1.Log()[:2], DeepEquals, logs)
+	t2.Logf("four")
+
+	st.Prune(time.Hour, 3*time.Hour, 100)
+
+	c.Check(s.archive.logs[chg.ID()], HasLen, 2)
+	c.Check(t1.Log(), HasLen, 3)
+	c.Check(t2.Log(), HasLen, 1)
+}
+
+func (s *taskLogSuite) TestArchivedLogsAcrossRestarts(c *C) {
+	b := &fakeStateBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.SetLogArchive(s.archive)
+	chg, t1, _ := s.readyChange(st, 10*time.Minute)
+	logs := t1.Log()
+	st.Prune(time.Hour, 3*time.Hour, 100)
+	st.Unlock()
+
+	st2, err := state.ReadState(nil, bytes.NewReader(b.checkpoints[len(b.checkpoints)-1]))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	st2.SetLogArchive(s.archive)
+
+	c.Check(st2.Change(chg.ID()).Tasks()[0].Log(), DeepEquals, logs)
+}
@@ -116,6 +116,8 @@ func (r *TaskRunner) SetBlocked(pred func(t *Task, running []*Task) bool) {
 // run must be called with the state lock in place
 func (r *TaskRunner) run(t *Task) {
 	var handler HandlerFunc
+	// the runs of undo handlers are recorded apart in the metrics
+	metricName := t.Kind()
 	switch t.Status() {
 	case DoStatus:
 		t.SetStatus(DoingStatus)
@@ -128,6 +130,7 @@ func (r *TaskRunner) run(t *Task) {
 		fallthrough
 	case UndoingStatus:
 		handler = r.handlers[t.Kind()].undo
+		metricName += "/undo"
 
 	default:
 		panic("internal error: attempted to run task in status " + t.Status().String())
@@ -143,7 +146,9 @@ func (r *TaskRunner) run(t *Task) {
 		// Capture the error result with tomb.Kill so we can
 		// use tomb.Err uniformily to consider both it or a
 		// overriding previous Kill reason.
+		start := time.Now()
 		tomb.Kill(handler(t, tomb))
+		duration := time.Since(start)
 
 		// Locks must be acquired in the same order everywhere.
 		r.mu.Lock()
@@ -173,6 +178,11 @@ func (r *TaskRunner) run(t *Task) {
 			}
 		}
 
+		if _, ok := err.(*Retry); !ok {
+			// only runs that settle the task are measured
+			r.state.TaskMetrics().Record(metricName, duration, err != nil)
+		}
+
 		switch x := err.(type) {
 		case *Retry:
 			// Handler asked to be called again later.
@@ -208,6 +218,9 @@ func (r *TaskRunner) run(t *Task) {
 			r.abortLanes(t.Change(), t.Lanes())
 			t.SetStatus(ErrorStatus)
 			t.Errorf("%s", err)
+			if r.state.taskErrorHandler != nil {
+				r.state.taskErrorHandler(t, err)
+			}
 		}
 
 		return nil
@@ -350,6 +363,11 @@ func (r *TaskRunner) Ensure() {
 			continue
 		}
 
+		if status == DoStatus && isPaused(t) {
+			// The change was paused, hold back its pending tasks.
+			continue
+		}
+
 		// skip tasks scheduled for later and also track the earliest one
 		tWhen := t.AtTime()
 		if !tWhen.IsZero() && ensureTime.Before(tWhen) {
@@ -395,6 +413,12 @@ func mustWait(t *Task) bool {
 	return false
 }
 
+// isPaused returns whether task t belongs to a paused change.
+func isPaused(t *Task) bool {
+	chg := t.Change()
+	return chg != nil && chg.IsPaused()
+}
+
 // wait expects to be called with th r.mu lock held
 func (r *TaskRunner) wait() {
 	for len(r.tombs) > 0 {
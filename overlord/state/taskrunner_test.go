@@ -340,6 +340,98 @@ func (ts *taskRunnerSuite) TestSequenceTests(c *C) {
 	}
 }
 
+func (ts *taskRunnerSuite) TestTaskMetrics(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	retried := false
+	r.AddHandler("ok", func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	})
+	r.AddHandler("retry", func(t *state.Task, tb *tomb.Tomb) error {
+		if !retried {
+			retried = true
+			return &state.Retry{}
+		}
+		return nil
+	}, nil)
+	r.AddHandler("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return errors.New("boom")
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("ok", "...")
+	t2 := st.NewTask("retry", "...")
+	t2.WaitFor(t1)
+	t3 := st.NewTask("fail", "...")
+	t3.WaitFor(t2)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	chg.AddTask(t3)
+	st.Unlock()
+
+	// retries do not ask for an ensure, run it until done
+	for i := 0; i < 10; i++ {
+		r.Ensure()
+		r.Wait()
+	}
+	st.Lock()
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	st.Unlock()
+
+	summaries := st.TaskMetrics().Summaries()
+	var got []string
+	for _, s := range summaries {
+		got = append(got, fmt.Sprintf("%s:%d:%d", s.Name, s.Count, s.Failures))
+	}
+	// the retried run is not measured, t2 has no undo handler
+	c.Check(got, DeepEquals, []string{"fail:1:1", "ok:1:0", "ok/undo:1:0", "retry:1:0"})
+}
+
+func (ts *taskRunnerSuite) TestTaskErrorHandler(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var failed []string
+	st.Lock()
+	st.SetTaskErrorHandler(func(t *state.Task, err error) {
+		// called with the state locked
+		c.Check(t.Status(), Equals, state.ErrorStatus)
+		failed = append(failed, fmt.Sprintf("%s:%v", t.Kind(), err))
+	})
+	st.Unlock()
+
+	r.AddHandler("ok", func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	}, nil)
+	r.AddHandler("fail", func(t *state.Task, tb *tomb.Tomb) error {
+		return errors.New("boom")
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("ok", "...")
+	t2 := st.NewTask("fail", "...")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(failed, DeepEquals, []string{"fail:boom"})
+}
+
 func (ts *taskRunnerSuite) TestExternalAbort(c *C) {
 	sb := &stateBackend{}
 	st := state.New(sb)
@@ -370,6 +462,106 @@ func (ts *taskRunnerSuite) TestExternalAbort(c *C) {
 	ensureChange(c, r, sb, chg)
 }
 
+func (ts *taskRunnerSuite) TestPausedChange(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var ran []string
+	ch := make(chan bool)
+	r.AddHandler("blocking", func(t *state.Task, tb *tomb.Tomb) error {
+		ch <- true
+		<-ch
+		st.Lock()
+		ran = append(ran, t.Summary())
+		st.Unlock()
+		return nil
+	}, nil)
+	r.AddHandler("plain", func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		ran = append(ran, t.Summary())
+		st.Unlock()
+		return nil
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("blocking", "t1")
+	t2 := st.NewTask("plain", "t2")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	r.Ensure()
+	<-ch
+
+	// pausing lets the running task finish but starts nothing new
+	st.Lock()
+	chg.Pause()
+	st.Unlock()
+	ch <- true
+	r.Wait()
+
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	c.Check(ran, DeepEquals, []string{"t1"})
+	c.Check(t1.Status(), Equals, state.DoneStatus)
+	c.Check(t2.Status(), Equals, state.DoStatus)
+	c.Check(chg.Status().Ready(), Equals, false)
+
+	chg.Resume()
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, DeepEquals, []string{"t1", "t2"})
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+}
+
+func (ts *taskRunnerSuite) TestPausedChangeCanBeAborted(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var undone []string
+	r.AddHandler("plain", func(t *state.Task, tb *tomb.Tomb) error {
+		return nil
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		st.Lock()
+		undone = append(undone, t.Summary())
+		st.Unlock()
+		return nil
+	})
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("plain", "t1")
+	t2 := st.NewTask("plain", "t2")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	t1.SetStatus(state.DoneStatus)
+	chg.Pause()
+	chg.Abort()
+	st.Unlock()
+
+	// undoing is not held back by the pause
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(undone, DeepEquals, []string{"t1"})
+	c.Check(t1.Status(), Equals, state.UndoneStatus)
+	c.Check(t2.Status(), Equals, state.HoldStatus)
+}
+
 func (ts *taskRunnerSuite) TestStopHandlerJustFinishing(c *C) {
 	sb := &stateBackend{}
 	st := state.New(sb)
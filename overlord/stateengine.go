@@ -22,6 +22,7 @@ package overlord
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/logger"
 
@@ -43,6 +44,15 @@ type StateManager interface {
 	Stop()
 }
 
+// WakeupScheduler is implemented by the state managers that have work
+// scheduled for later, which needs snapd to be running.
+type WakeupScheduler interface {
+	// NextWakeup returns when the manager has work to do next, or
+	// the zero time if it has none scheduled.
+	// The caller should be holding the state lock.
+	NextWakeup() time.Time
+}
+
 // StateEngine controls the dispatching of state changes to state managers.
 //
 // Most of the actual work performed by the state engine is in fact done
@@ -112,6 +122,23 @@ func (se *StateEngine) AddManager(m StateManager) {
 	se.managers = append(se.managers, m)
 }
 
+// NextWakeups returns when the managers have work scheduled next, see
+// WakeupScheduler, leaving out the managers without any.
+// The caller should be holding the state lock.
+func (se *StateEngine) NextWakeups() []time.Time {
+	se.mgrLock.Lock()
+	defer se.mgrLock.Unlock()
+	var wakeups []time.Time
+	for _, m := range se.managers {
+		if ws, ok := m.(WakeupScheduler); ok {
+			if next := ws.NextWakeup(); !next.IsZero() {
+				wakeups = append(wakeups, next)
+			}
+		}
+	}
+	return wakeups
+}
+
 // Wait waits for all managers current activities.
 func (se *StateEngine) Wait() {
 	se.mgrLock.Lock()
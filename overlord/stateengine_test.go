@@ -21,6 +21,7 @@ package overlord_test
 
 import (
 	"errors"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -81,6 +82,32 @@ func (ses *stateEngineSuite) TestEnsure(c *C) {
 	c.Check(calls, DeepEquals, []string{"ensure:mgr1", "ensure:mgr2", "ensure:mgr1", "ensure:mgr2"})
 }
 
+type fakeWakeupManager struct {
+	fakeManager
+	next time.Time
+}
+
+func (fm *fakeWakeupManager) NextWakeup() time.Time {
+	return fm.next
+}
+
+var _ overlord.WakeupScheduler = (*fakeWakeupManager)(nil)
+
+func (ses *stateEngineSuite) TestNextWakeups(c *C) {
+	s := state.New(nil)
+	se := overlord.NewStateEngine(s)
+
+	calls := []string{}
+	next := time.Now().Add(time.Hour)
+
+	se.AddManager(&fakeManager{name: "mgr1", calls: &calls})
+	se.AddManager(&fakeWakeupManager{fakeManager: fakeManager{name: "mgr2", calls: &calls}, next: next})
+	se.AddManager(&fakeWakeupManager{fakeManager: fakeManager{name: "mgr3", calls: &calls}})
+
+	c.Check(se.NextWakeups(), DeepEquals, []time.Time{next})
+	c.Check(calls, HasLen, 0)
+}
+
 func (ses *stateEngineSuite) TestEnsureError(c *C) {
 	s := state.New(nil)
 	se := overlord.NewStateEngine(s)
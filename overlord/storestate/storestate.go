@@ -27,7 +27,9 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
@@ -66,11 +68,14 @@ func updateBaseURL(st *state.State, baseURL string) {
 type StoreService interface {
 	SnapInfo(spec store.SnapSpec, user *auth.UserState) (*snap.Info, error)
 	Find(search *store.Search, user *auth.UserState) ([]*snap.Info, error)
+	PublisherSnaps(publisherID string, user *auth.UserState) ([]string, error)
 	LookupRefresh(*store.RefreshCandidate, *auth.UserState) (*snap.Info, error)
 	ListRefresh([]*store.RefreshCandidate, *auth.UserState) ([]*snap.Info, error)
 	Sections(user *auth.UserState) ([]string, error)
 	WriteCatalogs(names io.Writer) error
-	Download(context.Context, string, string, *snap.DownloadInfo, progress.Meter, *auth.UserState) error
+	Download(context.Context, string, string, *snap.DownloadInfo, progress.Meter, *auth.UserState, *store.DownloadOptions) error
+
+	ConnectivityCheck() (map[string]bool, error)
 
 	Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error)
 
@@ -103,6 +108,7 @@ func SetBaseURL(state *state.State, u *url.URL) error {
 			return err
 		}
 	}
+	config.DNS = dnsConf(state)
 	store := store.New(config, cachedAuthContext(state))
 	ReplaceStore(state, store)
 	updateBaseURL(state, baseURL)
@@ -111,6 +117,7 @@ func SetBaseURL(state *state.State, u *url.URL) error {
 
 func initialStoreConfig(st *state.State) (*store.Config, error) {
 	config := store.DefaultConfig()
+	config.DNS = dnsConf(st)
 	if baseURL := BaseURL(st); baseURL != "" {
 		u, err := url.Parse(baseURL)
 		if err != nil {
@@ -124,6 +131,30 @@ func initialStoreConfig(st *state.State) (*store.Config, error) {
 	return config, nil
 }
 
+// dnsConf returns a function giving how to resolve the store hosts, as set
+// by the store.dns.mode ("https" or "tls") and store.dns.server core
+// options, so that snapd can reach the store on networks where plain DNS
+// is filtered or unreliable.
+func dnsConf(st *state.State) func() (*httputil.DNSConfig, error) {
+	return func() (*httputil.DNSConfig, error) {
+		st.Lock()
+		defer st.Unlock()
+
+		var dnsConfig httputil.DNSConfig
+		tr := config.NewTransaction(st)
+		if err := tr.Get("core", "store.dns.mode", &dnsConfig.Mode); err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		if err := tr.Get("core", "store.dns.server", &dnsConfig.Server); err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		if err := dnsConfig.Validate(); err != nil {
+			return nil, fmt.Errorf("cannot use store.dns configuration: %v", err)
+		}
+		return &dnsConfig, nil
+	}
+}
+
 type cachedAuthContextKey struct{}
 
 func saveAuthContext(state *state.State, authContext auth.AuthContext) {
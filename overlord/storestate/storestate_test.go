@@ -26,7 +26,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storestate"
 	"github.com/snapcore/snapd/store"
@@ -150,6 +152,45 @@ func (ss *storeStateSuite) TestSetupStoreBaseURLFromState(c *C) {
 	c.Check(config.StoreBaseURL.String(), Equals, "http://example.com/")
 }
 
+func (ss *storeStateSuite) TestSetupStoreDNSConfig(c *C) {
+	var storeConfig *store.Config
+	defer storestate.MockStoreNew(func(c *store.Config, _ auth.AuthContext) *store.Store {
+		storeConfig = c
+		return nil
+	})()
+
+	st := ss.state(c, "")
+	st.Lock()
+	err := storestate.SetupStore(st, nil)
+	st.Unlock()
+	c.Assert(err, IsNil)
+	c.Assert(storeConfig.DNS, NotNil)
+
+	// the system resolver is used by default
+	dnsConfig, err := storeConfig.DNS()
+	c.Assert(err, IsNil)
+	c.Check(dnsConfig, DeepEquals, &httputil.DNSConfig{})
+
+	// the configuration is read as it changes
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "store.dns.mode", "https")
+	tr.Set("core", "store.dns.server", "https://1.1.1.1/dns-query")
+	tr.Commit()
+	st.Unlock()
+	dnsConfig, err = storeConfig.DNS()
+	c.Assert(err, IsNil)
+	c.Check(dnsConfig, DeepEquals, &httputil.DNSConfig{Mode: "https", Server: "https://1.1.1.1/dns-query"})
+
+	st.Lock()
+	tr = config.NewTransaction(st)
+	tr.Set("core", "store.dns.mode", "carrier-pigeon")
+	tr.Commit()
+	st.Unlock()
+	_, err = storeConfig.DNS()
+	c.Check(err, ErrorMatches, `cannot use store.dns configuration: unsupported DNS resolution mode "carrier-pigeon"`)
+}
+
 func (ss *storeStateSuite) TestSetupStoreBadEnvironURLOverride(c *C) {
 	// We need store state to trigger this.
 	st := ss.state(c, `{"data":{"store":{"base-url": "http://example.com/"}}}`)
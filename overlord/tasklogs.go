@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// taskLogArchive keeps the task logs of each change in a compressed
+// file of its own, see state.LogArchive.
+type taskLogArchive struct {
+	dir string
+}
+
+func (a *taskLogArchive) path(chgID string) string {
+	return filepath.Join(a.dir, chgID+".json.gz")
+}
+
+func (a *taskLogArchive) Store(chgID string, logs map[string][]string) error {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(logs); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(a.path(chgID), buf.Bytes(), 0600, 0)
+}
+
+func (a *taskLogArchive) Load(chgID string) (map[string][]string, error) {
+	f, err := os.Open(a.path(chgID))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var logs map[string][]string
+	if err := json.NewDecoder(r).Decode(&logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (a *taskLogArchive) Discard(chgID string) error {
+	err := os.Remove(a.path(chgID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"encoding/json"
+	"runtime"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	stuckCheckInterval    = 5 * time.Minute
+	defaultStuckThreshold = 6 * time.Hour
+
+	// maxGoroutineDump caps the size of the goroutine dump kept in
+	// the state
+	maxGoroutineDump = 64 * 1024
+)
+
+// stuckTask is what the watchdog captured about a task that stayed in
+// Doing or Undoing for longer than the stuck threshold. It is kept in
+// the "stuck-tasks" entry of the change, by task ID, for later triage.
+type stuckTask struct {
+	Kind     string          `json:"kind"`
+	Status   string          `json:"status"`
+	Since    time.Time       `json:"since"`
+	Captured time.Time       `json:"captured"`
+	Task     json.RawMessage `json:"task"`
+	// LockHolder is who held the state lock when the watchdog
+	// needed it, if anyone.
+	LockHolder string `json:"lock-holder,omitempty"`
+	Goroutines string `json:"goroutines"`
+}
+
+// stuckThreshold returns for how long a task can be in Doing or Undoing
+// before the watchdog considers it stuck, as set by the
+// changes.stuck-threshold core option (a duration such as "2h"). A zero
+// duration disables the watchdog.
+func stuckThreshold(st *state.State) time.Duration {
+	var value string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "changes.stuck-threshold", &value)
+	if err != nil {
+		if !config.IsNoOption(err) {
+			logger.Noticef("cannot use changes.stuck-threshold configuration: %v", err)
+		}
+		return defaultStuckThreshold
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold < 0 {
+		logger.Noticef("cannot use changes.stuck-threshold configuration: invalid duration %q", value)
+		return defaultStuckThreshold
+	}
+	return threshold
+}
+
+// stuckWatchdog notices the tasks that stay in Doing or Undoing for too
+// long and captures diagnostics about them into their change, so that
+// stuck changes on devices nobody watches document themselves.
+type stuckWatchdog struct {
+	st *state.State
+	// since when each running task has been seen running, as the
+	// state doesn't record it
+	running map[string]time.Time
+}
+
+func newStuckWatchdog(st *state.State) *stuckWatchdog {
+	return &stuckWatchdog{
+		st:      st,
+		running: make(map[string]time.Time),
+	}
+}
+
+func goroutineDump() string {
+	buf := make([]byte, maxGoroutineDump)
+	n := runtime.Stack(buf, true)
+	return string(buf[:n])
+}
+
+// check looks for stuck tasks, capturing diagnostics about the new ones.
+func (w *stuckWatchdog) check() {
+	now := time.Now()
+	// the lock might be what everything is stuck on, note who has it
+	// before waiting for it
+	holder, heldSince := w.st.LockHolder()
+
+	w.st.Lock()
+	defer w.st.Unlock()
+
+	threshold := stuckThreshold(w.st)
+	if holder != "" && threshold > 0 && now.Sub(heldSince) > threshold {
+		logger.Noticef("WARNING: the state lock was held by %s for %v", holder, now.Sub(heldSince))
+	}
+
+	running := make(map[string]time.Time)
+	for _, t := range w.st.Tasks() {
+		status := t.Status()
+		if status != state.DoingStatus && status != state.UndoingStatus {
+			continue
+		}
+		since, ok := w.running[t.ID()]
+		if !ok {
+			since = now
+		}
+		running[t.ID()] = since
+		if threshold == 0 || now.Sub(since) <= threshold {
+			continue
+		}
+		w.capture(t, since, holder)
+	}
+	w.running = running
+}
+
+func (w *stuckWatchdog) capture(t *state.Task, since time.Time, holder string) {
+	chg := t.Change()
+	if chg == nil {
+		return
+	}
+	var stuck map[string]*stuckTask
+	if err := chg.Get("stuck-tasks", &stuck); err != nil && err != state.ErrNoState {
+		logger.Noticef("cannot read the stuck tasks of change %s: %v", chg.ID(), err)
+		return
+	}
+	if _, ok := stuck[t.ID()]; ok {
+		// already captured
+		return
+	}
+	if stuck == nil {
+		stuck = make(map[string]*stuckTask)
+	}
+	taskJSON, err := json.Marshal(t)
+	if err != nil {
+		logger.Noticef("cannot capture task %s: %v", t.ID(), err)
+		return
+	}
+	stuck[t.ID()] = &stuckTask{
+		Kind:       t.Kind(),
+		Status:     t.Status().String(),
+		Since:      since,
+		Captured:   time.Now(),
+		Task:       taskJSON,
+		LockHolder: holder,
+		Goroutines: goroutineDump(),
+	}
+	chg.Set("stuck-tasks", stuck)
+	t.Logf("task stuck in %s since %s, diagnostics captured in change %s", t.Status(), since.Format(time.RFC3339), chg.ID())
+	logger.Noticef("WARNING: task %s (%s) of change %s stuck in %s since %s, diagnostics captured", t.ID(), t.Kind(), chg.ID(), t.Status(), since.Format(time.RFC3339))
+}
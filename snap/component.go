@@ -0,0 +1,201 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+)
+
+// ComponentType is the type of a snap component.
+type ComponentType string
+
+const (
+	// StandardComponent is a component with optional content for
+	// the snap, like debug symbols, extra locales or large models.
+	StandardComponent ComponentType = "standard"
+)
+
+// Component describes a component declared in the components
+// section of snap.yaml.
+type Component struct {
+	Snap *Info
+
+	Name        string
+	Type        ComponentType
+	Summary     string
+	Description string
+}
+
+// ComponentInfo holds the information read from the
+// meta/component.yaml of a component file.
+type ComponentInfo struct {
+	SnapName    string
+	Name        string
+	Type        ComponentType
+	Version     string
+	Summary     string
+	Description string
+}
+
+// FullName returns the name of the component prefixed by the name of its
+// snap, as in <snap>+<component>.
+func (ci *ComponentInfo) FullName() string {
+	return ci.SnapName + "+" + ci.Name
+}
+
+type componentYaml struct {
+	Component   string        `yaml:"component"`
+	Type        ComponentType `yaml:"type"`
+	Version     string        `yaml:"version"`
+	Summary     string        `yaml:"summary"`
+	Description string        `yaml:"description"`
+}
+
+// InfoFromComponentYaml creates a new ComponentInfo from the given
+// component.yaml data.
+func InfoFromComponentYaml(yamlData []byte) (*ComponentInfo, error) {
+	var y componentYaml
+	if err := yaml.Unmarshal(yamlData, &y); err != nil {
+		return nil, fmt.Errorf("cannot parse component.yaml: %s", err)
+	}
+
+	snapName, compName := SplitSnapComponent(y.Component)
+	if snapName == "" || compName == "" {
+		return nil, fmt.Errorf("invalid component %q: expected <snap>+<component>", y.Component)
+	}
+	if err := ValidateName(snapName); err != nil {
+		return nil, err
+	}
+	if err := ValidateComponentName(compName); err != nil {
+		return nil, err
+	}
+	if err := validateComponentType(y.Type); err != nil {
+		return nil, err
+	}
+	if y.Version == "" {
+		return nil, fmt.Errorf("component %q version cannot be empty", y.Component)
+	}
+
+	return &ComponentInfo{
+		SnapName:    snapName,
+		Name:        compName,
+		Type:        y.Type,
+		Version:     y.Version,
+		Summary:     y.Summary,
+		Description: y.Description,
+	}, nil
+}
+
+// ReadComponentInfoFromContainer reads the ComponentInfo from the
+// meta/component.yaml of the given container.
+func ReadComponentInfoFromContainer(c Container) (*ComponentInfo, error) {
+	meta, err := c.ReadFile("meta/component.yaml")
+	if err != nil {
+		return nil, err
+	}
+	return InfoFromComponentYaml(meta)
+}
+
+// SplitSnapComponent splits a <snap>+<component> reference in the snap
+// and component names. Both are empty if ref is not of that form.
+func SplitSnapComponent(ref string) (snapName, compName string) {
+	i := strings.IndexRune(ref, '+')
+	if i < 0 {
+		return "", ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+var validComponentName = regexp.MustCompile("^[a-z](?:-?[a-z0-9])*$")
+
+// ValidateComponentName checks if a string can be used as a component name.
+func ValidateComponentName(name string) error {
+	if !validComponentName.MatchString(name) {
+		return fmt.Errorf("invalid component name: %q", name)
+	}
+	return nil
+}
+
+func validateComponentType(typ ComponentType) error {
+	switch typ {
+	case StandardComponent:
+		return nil
+	case "":
+		return fmt.Errorf("component type cannot be empty")
+	default:
+		return fmt.Errorf("unknown component type %q", typ)
+	}
+}
+
+func validateComponents(info *Info) error {
+	for name, comp := range info.Components {
+		if err := ValidateComponentName(name); err != nil {
+			return err
+		}
+		if err := validateComponentType(comp.Type); err != nil {
+			return fmt.Errorf("invalid component %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ComponentPlaceInfo holds the location information of a revision of
+// a component.
+type ComponentPlaceInfo struct {
+	SnapName string
+	Name     string
+	Revision Revision
+}
+
+// ComponentsDir returns the directory under which the components of
+// the given snap are mounted.
+func ComponentsDir(snapName string) string {
+	return filepath.Join(dirs.SnapMountDir, snapName, "components")
+}
+
+// BaseDir returns the directory holding all the mounted revisions of
+// the component.
+func (cpi ComponentPlaceInfo) BaseDir() string {
+	return filepath.Join(ComponentsDir(cpi.SnapName), cpi.Name)
+}
+
+// MountDir returns the directory where the component revision is mounted.
+func (cpi ComponentPlaceInfo) MountDir() string {
+	return filepath.Join(cpi.BaseDir(), cpi.Revision.String())
+}
+
+// MountFile returns the path where the component file that is mounted
+// is installed.
+func (cpi ComponentPlaceInfo) MountFile() string {
+	return filepath.Join(dirs.SnapBlobDir, fmt.Sprintf("%s+%s_%s.comp", cpi.SnapName, cpi.Name, cpi.Revision))
+}
+
+// CurrentLink returns the path of the symlink pointing to the mount
+// directory of the active revision of the component.
+func (cpi ComponentPlaceInfo) CurrentLink() string {
+	return filepath.Join(cpi.BaseDir(), "current")
+}
@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+)
+
+type componentSuite struct{}
+
+var _ = Suite(&componentSuite{})
+
+func (s *componentSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *componentSuite) TestInfoFromComponentYaml(c *C) {
+	ci, err := snap.InfoFromComponentYaml([]byte(`component: foo+debug
+type: standard
+version: 1.0
+summary: debug symbols
+description: the debug symbols of foo
+`))
+	c.Assert(err, IsNil)
+	c.Check(ci, DeepEquals, &snap.ComponentInfo{
+		SnapName:    "foo",
+		Name:        "debug",
+		Type:        snap.StandardComponent,
+		Version:     "1.0",
+		Summary:     "debug symbols",
+		Description: "the debug symbols of foo",
+	})
+	c.Check(ci.FullName(), Equals, "foo+debug")
+}
+
+func (s *componentSuite) TestInfoFromComponentYamlErrors(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"component: foo\ntype: standard\nversion: 1", `invalid component "foo": expected <snap>\+<component>`},
+		{"component: foo+\ntype: standard\nversion: 1", `invalid component "foo\+": expected <snap>\+<component>`},
+		{"component: Foo+debug\ntype: standard\nversion: 1", `invalid snap name: "Foo"`},
+		{"component: foo+de_bug\ntype: standard\nversion: 1", `invalid component name: "de_bug"`},
+		{"component: foo+debug\nversion: 1", `component type cannot be empty`},
+		{"component: foo+debug\ntype: potato\nversion: 1", `unknown component type "potato"`},
+		{"component: foo+debug\ntype: standard", `component "foo\+debug" version cannot be empty`},
+		{"component: [", `cannot parse component.yaml: .*`},
+	} {
+		_, err := snap.InfoFromComponentYaml([]byte(t.yaml))
+		c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
+
+func (s *componentSuite) TestReadComponentInfoFromContainer(c *C) {
+	compDir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(compDir, "meta"), 0755), IsNil)
+	err := ioutil.WriteFile(filepath.Join(compDir, "meta", "component.yaml"), []byte("component: foo+locales\ntype: standard\nversion: 2\n"), 0644)
+	c.Assert(err, IsNil)
+
+	container, err := snap.Open(compDir)
+	c.Assert(err, IsNil)
+	ci, err := snap.ReadComponentInfoFromContainer(container)
+	c.Assert(err, IsNil)
+	c.Check(ci.FullName(), Equals, "foo+locales")
+	c.Check(ci.Version, Equals, "2")
+}
+
+func (s *componentSuite) TestSplitSnapComponent(c *C) {
+	snapName, compName := snap.SplitSnapComponent("foo+bar")
+	c.Check(snapName, Equals, "foo")
+	c.Check(compName, Equals, "bar")
+
+	snapName, compName = snap.SplitSnapComponent("foo")
+	c.Check(snapName, Equals, "")
+	c.Check(compName, Equals, "")
+}
+
+func (s *componentSuite) TestComponentPlaceInfo(c *C) {
+	dirs.SetRootDir("/")
+	cpi := snap.ComponentPlaceInfo{SnapName: "foo", Name: "debug", Revision: snap.R(3)}
+
+	c.Check(snap.ComponentsDir("foo"), Equals, filepath.Join(dirs.SnapMountDir, "foo/components"))
+	c.Check(cpi.BaseDir(), Equals, filepath.Join(dirs.SnapMountDir, "foo/components/debug"))
+	c.Check(cpi.MountDir(), Equals, filepath.Join(dirs.SnapMountDir, "foo/components/debug/3"))
+	c.Check(cpi.CurrentLink(), Equals, filepath.Join(dirs.SnapMountDir, "foo/components/debug/current"))
+	c.Check(cpi.MountFile(), Equals, "/var/lib/snapd/snaps/foo+debug_3.comp")
+}
@@ -68,6 +68,10 @@ func Open(path string) (Container, error) {
 		if osutil.FileExists(filepath.Join(path, "meta", "snap.yaml")) {
 			return snapdir.New(path), nil
 		}
+		// components are directories with a component.yaml instead
+		if osutil.FileExists(filepath.Join(path, "meta", "component.yaml")) {
+			return snapdir.New(path), nil
+		}
 
 		return nil, NotSnapError{Path: path}
 	}
@@ -28,9 +28,12 @@ var supportedHooks = []*HookType{
 	newHookType(regexp.MustCompile("^configure$")),
 	newHookType(regexp.MustCompile("^install$")),
 	newHookType(regexp.MustCompile("^post-refresh$")),
+	newHookType(regexp.MustCompile("^check-health$")),
+	newHookType(regexp.MustCompile("^migrate-data$")),
 	newHookType(regexp.MustCompile("^remove$")),
 	newHookType(regexp.MustCompile("^prepare-(?:plug|slot)-[-a-z0-9]+$")),
 	newHookType(regexp.MustCompile("^connect-(?:plug|slot)-[-a-z0-9]+$")),
+	newHookType(regexp.MustCompile("^disconnect-(?:plug|slot)-[-a-z0-9]+$")),
 }
 
 // HookType represents a pattern of supported hook names.
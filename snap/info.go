@@ -155,12 +155,15 @@ type Info struct {
 	License          string
 	Epoch            string
 	Base             string
+	Requires         []string
+	Recommends       []string
 	Confinement      ConfinementType
 	Apps             map[string]*AppInfo
 	LegacyAliases    map[string]*AppInfo // FIXME: eventually drop this
 	Hooks            map[string]*HookInfo
 	Plugs            map[string]*PlugInfo
 	Slots            map[string]*SlotInfo
+	Components       map[string]*Component
 
 	// The information in all the remaining fields is not sourced from the snap blob itself.
 	SideInfo
@@ -175,8 +178,10 @@ type Info struct {
 	Prices  map[string]float64
 	MustBuy bool
 
-	PublisherID string
-	Publisher   string
+	PublisherID         string
+	Publisher           string
+	PublisherValidation string
+	PublisherContact    string
 
 	Screenshots []ScreenshotInfo
 
@@ -185,6 +190,9 @@ type Info struct {
 
 	// The ordered list of tracks that contain channels
 	Tracks []string
+	// DefaultTrack is the track chosen by the publisher as the
+	// default one, as reported by the store (empty if unset)
+	DefaultTrack string
 
 	Layout map[string]*Layout
 }
@@ -362,8 +370,12 @@ type PlugInfo struct {
 	Interface string
 	Attrs     map[string]interface{}
 	Label     string
-	Apps      map[string]*AppInfo
-	Hooks     map[string]*HookInfo
+	// Labels are the translations of Label, by language.
+	Labels map[string]string
+	// Category is one of the Category* constants, if set.
+	Category string
+	Apps     map[string]*AppInfo
+	Hooks    map[string]*HookInfo
 }
 
 // SecurityTags returns security tags associated with a given plug.
@@ -398,7 +410,11 @@ type SlotInfo struct {
 	Interface string
 	Attrs     map[string]interface{}
 	Label     string
-	Apps      map[string]*AppInfo
+	// Labels are the translations of Label, by language.
+	Labels map[string]string
+	// Category is one of the Category* constants, if set.
+	Category string
+	Apps     map[string]*AppInfo
 }
 
 // AppInfo provides information about a app.
@@ -441,6 +457,10 @@ type HookInfo struct {
 
 	Name  string
 	Plugs map[string]*PlugInfo
+
+	// RequiresNetwork is set for hooks that cannot work without
+	// network connectivity, snapd waits for it before running them.
+	RequiresNetwork bool
 }
 
 // SecurityTag returns application-specific security tag.
@@ -46,6 +46,8 @@ type snapYaml struct {
 	LicenseVersion   string                 `yaml:"license-version,omitempty"`
 	Epoch            string                 `yaml:"epoch,omitempty"`
 	Base             string                 `yaml:"base,omitempty"`
+	Requires         []string               `yaml:"requires,omitempty"`
+	Recommends       []string               `yaml:"recommends,omitempty"`
 	Confinement      ConfinementType        `yaml:"confinement,omitempty"`
 	Environment      strutil.OrderedMap     `yaml:"environment,omitempty"`
 	Plugs            map[string]interface{} `yaml:"plugs,omitempty"`
@@ -53,6 +55,8 @@ type snapYaml struct {
 	Apps             map[string]appYaml     `yaml:"apps,omitempty"`
 	Hooks            map[string]hookYaml    `yaml:"hooks,omitempty"`
 	Layout           map[string]layoutYaml  `yaml:"layout,omitempty"`
+
+	Components map[string]componentDeclYaml `yaml:"components,omitempty"`
 }
 
 type appYaml struct {
@@ -78,7 +82,8 @@ type appYaml struct {
 }
 
 type hookYaml struct {
-	PlugNames []string `yaml:"plugs,omitempty"`
+	PlugNames       []string `yaml:"plugs,omitempty"`
+	RequiresNetwork bool     `yaml:"requires-network,omitempty"`
 }
 
 type layoutYaml struct {
@@ -90,6 +95,12 @@ type layoutYaml struct {
 	Symlink string `yaml:"symlink,omitempty"`
 }
 
+type componentDeclYaml struct {
+	Type        ComponentType `yaml:"type"`
+	Summary     string        `yaml:"summary,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+}
+
 // InfoFromSnapYaml creates a new info based on the given snap.yaml data
 func InfoFromSnapYaml(yamlData []byte) (*Info, error) {
 	var y snapYaml
@@ -161,6 +172,20 @@ func InfoFromSnapYaml(yamlData []byte) (*Info, error) {
 		}
 	}
 
+	// Collect the declared components.
+	if len(y.Components) > 0 {
+		snap.Components = make(map[string]*Component, len(y.Components))
+		for name, comp := range y.Components {
+			snap.Components[name] = &Component{
+				Snap:        snap,
+				Name:        name,
+				Type:        comp.Type,
+				Summary:     comp.Summary,
+				Description: comp.Description,
+			}
+		}
+	}
+
 	// Rename specific plugs on the core snap.
 	snap.renameClashingCorePlugs()
 
@@ -205,6 +230,8 @@ func infoSkeletonFromSnapYaml(y snapYaml) *Info {
 		Epoch:               epoch,
 		Confinement:         confinement,
 		Base:                y.Base,
+		Requires:            y.Requires,
+		Recommends:          y.Recommends,
 		Apps:                make(map[string]*AppInfo),
 		LegacyAliases:       make(map[string]*AppInfo),
 		Hooks:               make(map[string]*HookInfo),
@@ -220,7 +247,7 @@ func infoSkeletonFromSnapYaml(y snapYaml) *Info {
 
 func setPlugsFromSnapYaml(y snapYaml, snap *Info) error {
 	for name, data := range y.Plugs {
-		iface, label, attrs, err := convertToSlotOrPlugData("plug", name, data)
+		iface, pres, attrs, err := convertToSlotOrPlugData("plug", name, data)
 		if err != nil {
 			return err
 		}
@@ -229,7 +256,9 @@ func setPlugsFromSnapYaml(y snapYaml, snap *Info) error {
 			Name:      name,
 			Interface: iface,
 			Attrs:     attrs,
-			Label:     label,
+			Label:     pres.label,
+			Labels:    pres.labels,
+			Category:  pres.category,
 		}
 		if len(y.Apps) > 0 {
 			snap.Plugs[name].Apps = make(map[string]*AppInfo)
@@ -244,7 +273,7 @@ func setPlugsFromSnapYaml(y snapYaml, snap *Info) error {
 
 func setSlotsFromSnapYaml(y snapYaml, snap *Info) error {
 	for name, data := range y.Slots {
-		iface, label, attrs, err := convertToSlotOrPlugData("slot", name, data)
+		iface, pres, attrs, err := convertToSlotOrPlugData("slot", name, data)
 		if err != nil {
 			return err
 		}
@@ -253,7 +282,9 @@ func setSlotsFromSnapYaml(y snapYaml, snap *Info) error {
 			Name:      name,
 			Interface: iface,
 			Attrs:     attrs,
-			Label:     label,
+			Label:     pres.label,
+			Labels:    pres.labels,
+			Category:  pres.category,
 		}
 		if len(y.Apps) > 0 {
 			snap.Slots[name].Apps = make(map[string]*AppInfo)
@@ -338,6 +369,8 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info) {
 		hook := &HookInfo{
 			Snap: snap,
 			Name: hookName,
+
+			RequiresNetwork: yHook.RequiresNetwork,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
 			hook.Plugs = make(map[string]*PlugInfo)
@@ -407,24 +440,31 @@ func bindUnboundSlots(slotNames []string, snap *Info) error {
 	return nil
 }
 
-func convertToSlotOrPlugData(plugOrSlot, name string, data interface{}) (iface, label string, attrs map[string]interface{}, err error) {
+// plugOrSlotPresentation holds how a plug or slot is presented to users.
+type plugOrSlotPresentation struct {
+	label    string
+	labels   map[string]string
+	category string
+}
+
+func convertToSlotOrPlugData(plugOrSlot, name string, data interface{}) (iface string, pres plugOrSlotPresentation, attrs map[string]interface{}, err error) {
 	iface = name
 	switch data.(type) {
 	case string:
-		return data.(string), "", nil, nil
+		return data.(string), pres, nil, nil
 	case nil:
-		return name, "", nil, nil
+		return name, pres, nil, nil
 	case map[interface{}]interface{}:
 		for keyData, valueData := range data.(map[interface{}]interface{}) {
 			key, ok := keyData.(string)
 			if !ok {
 				err := fmt.Errorf("%s %q has attribute that is not a string (found %T)",
 					plugOrSlot, name, keyData)
-				return "", "", nil, err
+				return "", pres, nil, err
 			}
 			if strings.HasPrefix(key, "$") {
 				err := fmt.Errorf("%s %q uses reserved attribute %q", plugOrSlot, name, key)
-				return "", "", nil, err
+				return "", pres, nil, err
 			}
 			switch key {
 			case "interface":
@@ -432,7 +472,7 @@ func convertToSlotOrPlugData(plugOrSlot, name string, data interface{}) (iface,
 				if !ok {
 					err := fmt.Errorf("interface name on %s %q is not a string (found %T)",
 						plugOrSlot, name, valueData)
-					return "", "", nil, err
+					return "", pres, nil, err
 				}
 				iface = value
 			case "label":
@@ -440,24 +480,49 @@ func convertToSlotOrPlugData(plugOrSlot, name string, data interface{}) (iface,
 				if !ok {
 					err := fmt.Errorf("label of %s %q is not a string (found %T)",
 						plugOrSlot, name, valueData)
-					return "", "", nil, err
+					return "", pres, nil, err
+				}
+				pres.label = value
+			case "labels":
+				value, ok := valueData.(map[interface{}]interface{})
+				if !ok {
+					err := fmt.Errorf("labels of %s %q are not a map (found %T)",
+						plugOrSlot, name, valueData)
+					return "", pres, nil, err
+				}
+				pres.labels = make(map[string]string, len(value))
+				for langData, labelData := range value {
+					lang, ok1 := langData.(string)
+					label, ok2 := labelData.(string)
+					if !ok1 || !ok2 {
+						err := fmt.Errorf("labels of %s %q must map languages to strings", plugOrSlot, name)
+						return "", pres, nil, err
+					}
+					pres.labels[lang] = label
+				}
+			case "category":
+				value, ok := valueData.(string)
+				if !ok {
+					err := fmt.Errorf("category of %s %q is not a string (found %T)",
+						plugOrSlot, name, valueData)
+					return "", pres, nil, err
 				}
-				label = value
+				pres.category = value
 			default:
 				if attrs == nil {
 					attrs = make(map[string]interface{})
 				}
 				value, err := normalizeYamlValue(valueData)
 				if err != nil {
-					return "", "", nil, fmt.Errorf("attribute %q of %s %q: %v", key, plugOrSlot, name, err)
+					return "", pres, nil, fmt.Errorf("attribute %q of %s %q: %v", key, plugOrSlot, name, err)
 				}
 				attrs[key] = value
 			}
 		}
-		return iface, label, attrs, nil
+		return iface, pres, attrs, nil
 	default:
 		err := fmt.Errorf("%s %q has malformed definition (found %T)", plugOrSlot, name, data)
-		return "", "", nil, err
+		return "", pres, nil, err
 	}
 }
 
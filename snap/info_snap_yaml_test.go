@@ -383,6 +383,58 @@ plugs:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalPlugWithLabelsAndCategory(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+plugs:
+    cam:
+        interface: camera
+        label: Take photos
+        labels:
+            fr: Prendre des photos
+            pt_BR: Tirar fotos
+        category: privacy
+`))
+	c.Assert(err, IsNil)
+	c.Assert(info.Plugs["cam"], DeepEquals, &snap.PlugInfo{
+		Snap:      info,
+		Name:      "cam",
+		Interface: "camera",
+		Label:     "Take photos",
+		Labels:    map[string]string{"fr": "Prendre des photos", "pt_BR": "Tirar fotos"},
+		Category:  "privacy",
+	})
+}
+
+func (s *YamlSuite) TestUnmarshalCorruptedPlugWithInvalidLabels(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	_, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+plugs:
+    cam:
+        labels: Prendre des photos
+`))
+	c.Assert(err, ErrorMatches, `labels of plug "cam" are not a map \(found string\)`)
+
+	_, err = snap.InfoFromSnapYaml([]byte(`
+name: snap
+plugs:
+    cam:
+        labels:
+            fr: [1]
+`))
+	c.Assert(err, ErrorMatches, `labels of plug "cam" must map languages to strings`)
+
+	_, err = snap.InfoFromSnapYaml([]byte(`
+name: snap
+plugs:
+    cam:
+        category: [privacy]
+`))
+	c.Assert(err, ErrorMatches, `category of plug "cam" is not a string \(found \[\]interface \{\}\)`)
+}
+
 func (s *YamlSuite) TestUnmarshalCorruptedPlugWithNonStringInterfaceName(c *C) {
 	// NOTE: yaml content cannot use tabs, indent the section with spaces.
 	_, err := snap.InfoFromSnapYaml([]byte(`
@@ -725,6 +777,29 @@ slots:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalSlotWithLabelsAndCategory(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+slots:
+    led0:
+        interface: bool-file
+        label: Front panel LED (red)
+        labels:
+            de: Frontplatten-LED (rot)
+        category: hardware
+`))
+	c.Assert(err, IsNil)
+	c.Assert(info.Slots["led0"], DeepEquals, &snap.SlotInfo{
+		Snap:      info,
+		Name:      "led0",
+		Interface: "bool-file",
+		Label:     "Front panel LED (red)",
+		Labels:    map[string]string{"de": "Frontplatten-LED (rot)"},
+		Category:  "hardware",
+	})
+}
+
 func (s *YamlSuite) TestUnmarshalCorruptedSlotWithNonStringInterfaceName(c *C) {
 	// NOTE: yaml content cannot use tabs, indent the section with spaces.
 	_, err := snap.InfoFromSnapYaml([]byte(`
@@ -817,6 +892,21 @@ hooks:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalHookRequiresNetwork(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+hooks:
+    test-hook:
+        requires-network: true
+    other-test-hook:
+`))
+	c.Assert(err, IsNil)
+	c.Assert(info.Hooks, HasLen, 2)
+	c.Check(info.Hooks["test-hook"].RequiresNetwork, Equals, true)
+	c.Check(info.Hooks["other-test-hook"].RequiresNetwork, Equals, false)
+}
+
 func (s *YamlSuite) TestUnmarshalUnsupportedHook(c *C) {
 	s.restore()
 	hookType := snap.NewHookType(regexp.MustCompile("not-test-hook"))
@@ -1232,6 +1322,19 @@ version: 1.0
 	c.Assert(info.Epoch, Equals, "0")
 }
 
+func (s *YamlSuite) TestSnapYamlRequiresRecommends(c *C) {
+	y := []byte(`name: binary
+version: 1.0
+requires: [foo, bar]
+recommends:
+  - baz
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Requires, DeepEquals, []string{"foo", "bar"})
+	c.Check(info.Recommends, DeepEquals, []string{"baz"})
+}
+
 func (s *YamlSuite) TestSnapYamlConfinementDefault(c *C) {
 	y := []byte(`name: binary
 version: 1.0
@@ -1333,6 +1436,7 @@ apps:
    stop-timeout: 25s
    daemon: forking
    stop-command: stop-cmd
+   reload-command: reload-cmd
    post-stop-command: post-stop-cmd
    restart-condition: on-abnormal
    bus-name: busName
@@ -1348,12 +1452,48 @@ apps:
 			RestartCond:     snap.RestartOnAbnormal,
 			StopTimeout:     timeout.Timeout(25 * time.Second),
 			StopCommand:     "stop-cmd",
+			ReloadCommand:   "reload-cmd",
 			PostStopCommand: "post-stop-cmd",
 			BusName:         "busName",
 		},
 	})
 }
 
+func (s *YamlSuite) TestSnapYamlComponents(c *C) {
+	y := []byte(`name: foo
+version: 1.0
+components:
+ debug:
+  type: standard
+  summary: debug symbols
+  description: the debug symbols of foo
+ locales:
+  type: standard
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Components, DeepEquals, map[string]*snap.Component{
+		"debug": {
+			Snap:        info,
+			Name:        "debug",
+			Type:        snap.StandardComponent,
+			Summary:     "debug symbols",
+			Description: "the debug symbols of foo",
+		},
+		"locales": {
+			Snap: info,
+			Name: "locales",
+			Type: snap.StandardComponent,
+		},
+	})
+}
+
+func (s *YamlSuite) TestSnapYamlNoComponents(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\n"))
+	c.Assert(err, IsNil)
+	c.Check(info.Components, IsNil)
+}
+
 func (s *YamlSuite) TestSnapYamlGlobalEnvironment(c *C) {
 	y := []byte(`
 name: foo
@@ -55,6 +55,10 @@ type SeedSnap struct {
 
 type Seed struct {
 	Snaps []*SeedSnap `yaml:"snaps"`
+
+	// Defaults holds default configuration for snaps in the seed
+	// (snap name => key => value), set when preparing the image.
+	Defaults map[string]map[string]interface{} `yaml:"defaults,omitempty"`
 }
 
 func ReadSeedYaml(fn string) (*Seed, error) {
@@ -75,6 +79,14 @@ func ReadSeedYaml(fn string) (*Seed, error) {
 		}
 	}
 
+	for name, v := range seed.Defaults {
+		dflt, err := normalizeYamlValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("default value %q of %q: %v", v, name, err)
+		}
+		seed.Defaults[name] = dflt.(map[string]interface{})
+	}
+
 	return &seed, nil
 }
 
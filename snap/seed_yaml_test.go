@@ -81,3 +81,29 @@ func (s *seedYamlTestSuite) TestNoPathAllowed(c *C) {
 	_, err = snap.ReadSeedYaml(fn)
 	c.Assert(err, ErrorMatches, `"foo/bar.snap" must be a filename, not a path`)
 }
+
+var mockSeedYamlWithDefaults = []byte(`
+snaps:
+ - name: foo
+   file: foo_1.0_all.snap
+defaults:
+  foo:
+    key: value
+    nested:
+      num: 1
+`)
+
+func (s *seedYamlTestSuite) TestDefaults(c *C) {
+	fn := filepath.Join(c.MkDir(), "seed.yaml")
+	err := ioutil.WriteFile(fn, mockSeedYamlWithDefaults, 0644)
+	c.Assert(err, IsNil)
+
+	seed, err := snap.ReadSeedYaml(fn)
+	c.Assert(err, IsNil)
+	c.Check(seed.Defaults, DeepEquals, map[string]map[string]interface{}{
+		"foo": {
+			"key":    "value",
+			"nested": map[string]interface{}{"num": int64(1)},
+		},
+	})
+}
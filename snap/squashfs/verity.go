@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package squashfs
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// VerityHashFile returns the path of the dm-verity hash tree of the given
+// snap file.
+func VerityHashFile(snapPath string) string {
+	return snapPath + ".verity"
+}
+
+var (
+	dmVerityModule = "/sys/module/dm_verity"
+	dmControl      = "/dev/mapper/control"
+)
+
+// VeritySupported returns whether snap files can be mounted with dm-verity
+// enforcement: veritysetup is available, the kernel has dm-verity and
+// device-mapper can be used, which it cannot from most containers.
+func VeritySupported() bool {
+	if _, err := exec.LookPath("veritysetup"); err != nil {
+		return false
+	}
+	return osutil.FileExists(dmVerityModule) && osutil.FileExists(dmControl)
+}
+
+var verityRootHash = regexp.MustCompile(`(?m)^Root hash:\s+([0-9a-f]+)$`)
+
+// FormatVerity computes the dm-verity hash tree of the snap file into
+// VerityHashFile and returns its root hash, hex encoded. The default
+// veritysetup parameters are used, with the given salt, hex encoded, if
+// any, so that the root hash can be compared to the one the store
+// asserted; a random salt is used otherwise.
+func FormatVerity(snapPath, salt string) (rootHash string, err error) {
+	args := []string{"format"}
+	if salt != "" {
+		args = append(args, "--salt="+salt)
+	}
+	args = append(args, snapPath, VerityHashFile(snapPath))
+	output, err := exec.Command("veritysetup", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("cannot compute dm-verity data of %q: %v", snapPath, osutil.OutputErr(output, err))
+	}
+	m := verityRootHash.FindSubmatch(output)
+	if m == nil {
+		return "", fmt.Errorf("cannot find the root hash of the dm-verity data of %q in: %q", snapPath, output)
+	}
+	return string(m[1]), nil
+}
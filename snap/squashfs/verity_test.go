@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package squashfs
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/testutil"
+)
+
+const veritysetupOutput = `VERITY header information for foo.snap.verity
+UUID:            	d7e0c5a5-1f1c-4b8e-8e5a-1b6a3b2c9f10
+Hash type:       	1
+Data blocks:     	4
+Data block size: 	4096
+Hash block size: 	4096
+Hash algorithm:  	sha256
+Salt:            	00ff
+Root hash:      	5a1f0c1a3b4c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e
+`
+
+func (s *SquashfsTestSuite) TestFormatVerity(c *C) {
+	cmd := testutil.MockCommand(c, "veritysetup", "cat <<'EOF'\n"+veritysetupOutput+"EOF\n")
+	defer cmd.Restore()
+
+	rootHash, err := FormatVerity("/var/lib/snapd/snaps/foo_1.snap", "00ff")
+	c.Assert(err, IsNil)
+	c.Check(rootHash, Equals, "5a1f0c1a3b4c9d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e")
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"veritysetup", "format", "--salt=00ff", "/var/lib/snapd/snaps/foo_1.snap", "/var/lib/snapd/snaps/foo_1.snap.verity"},
+	})
+
+	cmd.ForgetCalls()
+	_, err = FormatVerity("/var/lib/snapd/snaps/foo_1.snap", "")
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"veritysetup", "format", "/var/lib/snapd/snaps/foo_1.snap", "/var/lib/snapd/snaps/foo_1.snap.verity"},
+	})
+}
+
+func (s *SquashfsTestSuite) TestFormatVerityErrors(c *C) {
+	cmd := testutil.MockCommand(c, "veritysetup", "echo nope; exit 1")
+	defer cmd.Restore()
+	_, err := FormatVerity("foo.snap", "")
+	c.Check(err, ErrorMatches, `cannot compute dm-verity data of "foo.snap": nope`)
+
+	cmd = testutil.MockCommand(c, "veritysetup", "echo done")
+	defer cmd.Restore()
+	_, err = FormatVerity("foo.snap", "")
+	c.Check(err, ErrorMatches, `cannot find the root hash of the dm-verity data of "foo.snap" in: "done\\n"`)
+}
+
+func (s *SquashfsTestSuite) TestVeritySupported(c *C) {
+	d := c.MkDir()
+	oldModule, oldControl := dmVerityModule, dmControl
+	defer func() { dmVerityModule, dmControl = oldModule, oldControl }()
+	dmVerityModule = filepath.Join(d, "dm_verity")
+	dmControl = filepath.Join(d, "control")
+
+	cmd := testutil.MockCommand(c, "veritysetup", "")
+	defer cmd.Restore()
+	c.Check(VeritySupported(), Equals, false)
+
+	c.Assert(os.Mkdir(dmVerityModule, 0755), IsNil)
+	c.Check(VeritySupported(), Equals, false)
+
+	f, err := os.Create(dmControl)
+	c.Assert(err, IsNil)
+	f.Close()
+	c.Check(VeritySupported(), Equals, true)
+}
@@ -81,6 +81,25 @@ func ValidateAlias(alias string) error {
 	return nil
 }
 
+// validateDependencies checks the snap names listed in the given
+// requires or recommends stanza.
+func validateDependencies(info *Info, stanza string, names []string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if err := ValidateName(name); err != nil {
+			return fmt.Errorf("invalid snap name in %s: %v", stanza, err)
+		}
+		if name == info.Name() {
+			return fmt.Errorf("snap %q cannot list itself in %s", name, stanza)
+		}
+		if seen[name] {
+			return fmt.Errorf("snap %q is listed more than once in %s", name, stanza)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
 // Validate verifies the content in the info.
 func Validate(info *Info) error {
 	name := info.Name()
@@ -109,6 +128,17 @@ func Validate(info *Info) error {
 		}
 	}
 
+	if err := validateDependencies(info, "requires", info.Requires); err != nil {
+		return err
+	}
+	if err := validateDependencies(info, "recommends", info.Recommends); err != nil {
+		return err
+	}
+
+	if err := validateComponents(info); err != nil {
+		return err
+	}
+
 	// validate app entries
 	for _, app := range info.Apps {
 		err := ValidateApp(app)
@@ -137,6 +167,17 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	for _, plug := range info.Plugs {
+		if err := validatePresentation("plug", plug.Name, plug.Labels, plug.Category); err != nil {
+			return err
+		}
+	}
+	for _, slot := range info.Slots {
+		if err := validatePresentation("slot", slot.Name, slot.Labels, slot.Category); err != nil {
+			return err
+		}
+	}
+
 	for _, layout := range info.Layout {
 		if err := ValidateLayout(layout); err != nil {
 			return err
@@ -155,6 +196,34 @@ func plugsSlotsUniqueNames(info *Info) error {
 	return nil
 }
 
+// Categories of plugs and slots, grouping them when presented to users.
+const (
+	CategoryHardware = "hardware"
+	CategoryPrivacy  = "privacy"
+	CategorySystem   = "system"
+)
+
+var validCategories = map[string]bool{
+	CategoryHardware: true,
+	CategoryPrivacy:  true,
+	CategorySystem:   true,
+}
+
+// validLabelLanguage matches languages such as "fr" or "pt_BR".
+var validLabelLanguage = regexp.MustCompile(`^[a-z]{2,3}(?:_[A-Z]{2})?$`)
+
+func validatePresentation(plugOrSlot, name string, labels map[string]string, category string) error {
+	for lang := range labels {
+		if !validLabelLanguage.MatchString(lang) {
+			return fmt.Errorf("invalid language %q for a label of %s %q", lang, plugOrSlot, name)
+		}
+	}
+	if category != "" && !validCategories[category] {
+		return fmt.Errorf("invalid category %q of %s %q, expected one of hardware, privacy or system", category, plugOrSlot, name)
+	}
+	return nil
+}
+
 func validateField(name, cont string, whitelist *regexp.Regexp) error {
 	if !whitelist.MatchString(cont) {
 		return fmt.Errorf("app description field '%s' contains illegal %q (legal: '%s')", name, cont, whitelist)
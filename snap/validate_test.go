@@ -152,6 +152,7 @@ func (s *ValidateSuite) TestValidateAppName(c *C) {
 func (s *ValidateSuite) TestAppWhitelistSimple(c *C) {
 	c.Check(ValidateApp(&AppInfo{Name: "foo", Command: "foo"}), IsNil)
 	c.Check(ValidateApp(&AppInfo{Name: "foo", StopCommand: "foo"}), IsNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", ReloadCommand: "foo"}), IsNil)
 	c.Check(ValidateApp(&AppInfo{Name: "foo", PostStopCommand: "foo"}), IsNil)
 }
 
@@ -160,6 +161,7 @@ func (s *ValidateSuite) TestAppWhitelistIllegal(c *C) {
 	c.Check(ValidateApp(&AppInfo{Name: "test!me"}), NotNil)
 	c.Check(ValidateApp(&AppInfo{Name: "foo", Command: "foo\n"}), NotNil)
 	c.Check(ValidateApp(&AppInfo{Name: "foo", StopCommand: "foo\n"}), NotNil)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", ReloadCommand: "foo\n"}), NotNil)
 	c.Check(ValidateApp(&AppInfo{Name: "foo", PostStopCommand: "foo\n"}), NotNil)
 	c.Check(ValidateApp(&AppInfo{Name: "foo", BusName: "foo\n"}), NotNil)
 }
@@ -308,6 +310,71 @@ slots:
 	c.Check(err, ErrorMatches, `cannot have plug and slot with the same name: "foo"`)
 }
 
+func (s *ValidateSuite) TestValidatePlugSlotPresentation(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"plugs:\n cam:\n  labels: {fr: Photos, pt_BR: Fotos}\n  category: privacy", ""},
+		{"slots:\n led:\n  category: hardware", ""},
+		{"plugs:\n cam:\n  labels: {French: Photos}", `invalid language "French" for a label of plug "cam"`},
+		{"slots:\n led:\n  labels: {pt-BR: Fotos}", `invalid language "pt-BR" for a label of slot "led"`},
+		{"plugs:\n cam:\n  category: camera", `invalid category "camera" of plug "cam", expected one of hardware, privacy or system`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: snap\nversion: 1.0\n" + t.yaml))
+		c.Assert(err, IsNil, Commentf(t.yaml))
+		err = Validate(info)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf(t.yaml))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+		}
+	}
+}
+
+func (s *ValidateSuite) TestValidateRequiresRecommends(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"requires: [foo, bar]\nrecommends: [baz]", ""},
+		{"requires: [Foo]", `invalid snap name in requires: invalid snap name: "Foo"`},
+		{"recommends: [foo_bar]", `invalid snap name in recommends: invalid snap name: "foo_bar"`},
+		{"requires: [snap]", `snap "snap" cannot list itself in requires`},
+		{"recommends: [foo, foo]", `snap "foo" is listed more than once in recommends`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: snap\nversion: 1.0\n" + t.yaml))
+		c.Assert(err, IsNil)
+		err = Validate(info)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf(t.yaml))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+		}
+	}
+}
+
+func (s *ValidateSuite) TestValidateComponents(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"components:\n debug:\n  type: standard", ""},
+		{"components:\n de_bug:\n  type: standard", `invalid component name: "de_bug"`},
+		{"components:\n debug:\n  summary: debug symbols", `invalid component "debug": component type cannot be empty`},
+		{"components:\n debug:\n  type: potato", `invalid component "debug": unknown component type "potato"`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: snap\nversion: 1.0\n" + t.yaml))
+		c.Assert(err, IsNil)
+		err = Validate(info)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf(t.yaml))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+		}
+	}
+}
+
 func (s *ValidateSuite) TestIllegalAliasName(c *C) {
 	info, err := InfoFromSnapYaml([]byte(`name: foo
 version: 1.0
@@ -58,11 +58,18 @@ type snapDetails struct {
 	// TODO: have the store return a 'developer_username' for this
 	Developer   string `json:"origin"`
 	DeveloperID string `json:"developer_id"`
+	// DeveloperValidation is how far the store vouches for the
+	// developer: "verified", "starred" or "unproven".
+	DeveloperValidation string `json:"developer_validation,omitempty"`
+	DeveloperContact    string `json:"developer_contact,omitempty"`
 
 	Private     bool   `json:"private"`
 	Confinement string `json:"confinement"`
 
 	ChannelMapList []channelMap `json:"channel_maps_list,omitempty"`
+	// DefaultTrack is the track the publisher wants new installs to
+	// follow when no track is given.
+	DefaultTrack string `json:"default_track,omitempty"`
 }
 
 // channelMap contains
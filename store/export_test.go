@@ -20,6 +20,10 @@
 package store
 
 import (
+	"time"
+
+	"golang.org/x/net/context"
+
 	"github.com/snapcore/snapd/testutil"
 
 	"gopkg.in/retry.v1"
@@ -33,3 +37,12 @@ func MockDefaultRetryStrategy(t *testutil.BaseTest, strategy retry.Strategy) {
 		defaultRetryStrategy = originalDefaultRetryStrategy
 	})
 }
+
+// MockRateLimitSleep mocks the function used to wait when rate limiting downloads
+func MockRateLimitSleep(f func(ctx context.Context, d time.Duration) error) (restore func()) {
+	old := rateLimitSleep
+	rateLimitSleep = f
+	return func() {
+		rateLimitSleep = old
+	}
+}
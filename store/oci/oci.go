@@ -0,0 +1,443 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package oci fetches snaps published as OCI artifacts in container
+// registries that implement the OCI distribution API.
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Media types of the layers making up a snap artifact.
+const (
+	ManifestMediaType   = "application/vnd.oci.image.manifest.v1+json"
+	SnapMediaType       = "application/vnd.snapcraft.snap.v1"
+	AssertionsMediaType = "application/vnd.snapcraft.assertions.v1"
+)
+
+// Scheme is the URL scheme used to refer to snaps in a registry.
+const Scheme = "oci://"
+
+// the maximum size of a manifest or of an assertions bundle
+const maxMetadataSize = 4 * 1024 * 1024
+
+// Reference identifies an artifact in a registry.
+type Reference struct {
+	Registry   string
+	Repository string
+	// Tag or Digest identifies the artifact in the repository,
+	// Digest takes precedence if set.
+	Tag    string
+	Digest string
+}
+
+var (
+	validRepository = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+	validTag        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	validDigest     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// ParseReference parses a reference of the form
+// [oci://]registry/repository[:tag|@digest]. The tag defaults to
+// "latest".
+func ParseReference(s string) (*Reference, error) {
+	ref := &Reference{}
+	rest := strings.TrimPrefix(s, Scheme)
+	if i := strings.IndexRune(rest, '/'); i > 0 {
+		ref.Registry = rest[:i]
+		rest = rest[i+1:]
+	} else {
+		return nil, fmt.Errorf("cannot parse OCI reference %q: missing registry", s)
+	}
+	if i := strings.IndexRune(rest, '@'); i >= 0 {
+		ref.Digest = rest[i+1:]
+		rest = rest[:i]
+		if !validDigest.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("cannot parse OCI reference %q: invalid digest %q", s, ref.Digest)
+		}
+	} else if i := strings.LastIndex(rest, ":"); i >= 0 {
+		ref.Tag = rest[i+1:]
+		rest = rest[:i]
+		if !validTag.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("cannot parse OCI reference %q: invalid tag %q", s, ref.Tag)
+		}
+	} else {
+		ref.Tag = "latest"
+	}
+	if !validRepository.MatchString(rest) {
+		return nil, fmt.Errorf("cannot parse OCI reference %q: invalid repository %q", s, rest)
+	}
+	ref.Repository = rest
+	return ref, nil
+}
+
+func (ref *Reference) String() string {
+	if ref.Digest != "" {
+		return fmt.Sprintf("%s%s/%s@%s", Scheme, ref.Registry, ref.Repository, ref.Digest)
+	}
+	return fmt.Sprintf("%s%s/%s:%s", Scheme, ref.Registry, ref.Repository, ref.Tag)
+}
+
+// Config holds the configuration for accessing a registry.
+type Config struct {
+	// Username and Password are used to authenticate to the
+	// registry, if set.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// PlainHTTP makes the client talk to the registry over HTTP,
+	// meant for testing and local mirrors.
+	PlainHTTP bool `yaml:"plain-http,omitempty"`
+}
+
+// LoadConfig returns the configuration for accessing the given
+// registry that the administrator put in dirs.SnapRegistriesFile, for
+// instance:
+//
+//   registries:
+//     registry.example.com:
+//       username: acme
+//       password: s3cr3t
+//
+// It returns a nil configuration if there is none for the registry.
+// As the file holds credentials it must not be accessible to anybody
+// but its owner.
+func LoadConfig(registry string) (*Config, error) {
+	f, err := os.Open(dirs.SnapRegistriesFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("cannot use %s: it must not be accessible by other users", dirs.SnapRegistriesFile)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxMetadataSize))
+	if err != nil {
+		return nil, err
+	}
+	var cfg struct {
+		Registries map[string]*Config `yaml:"registries"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %v", dirs.SnapRegistriesFile, err)
+	}
+	return cfg.Registries[registry], nil
+}
+
+// Client fetches snap artifacts from a registry.
+type Client struct {
+	cfg    Config
+	client *http.Client
+	token  string
+}
+
+// New creates a new registry client with the given configuration,
+// which can be nil.
+func New(cfg *Config) *Client {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	return &Client{
+		cfg:    *cfg,
+		client: httputil.NewHTTPClient(nil),
+	}
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// Artifact describes a snap artifact fetched from a registry.
+type Artifact struct {
+	// Digest is the digest of the snap layer.
+	Digest string
+	Size   int64
+	// Assertions is the bundle of assertions published alongside
+	// the snap, if any.
+	Assertions []byte
+}
+
+func (c *Client) endpoint(ref *Reference, kind, what string) string {
+	scheme := "https"
+	if c.cfg.PlainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, ref.Registry, ref.Repository, kind, what)
+}
+
+var bearerParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// authenticate obtains a bearer token as described by the given
+// WWW-Authenticate challenge.
+func (c *Client) authenticate(ctx context.Context, challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	params := make(map[string]string)
+	for _, m := range bearerParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("missing realm in authentication challenge %q", challenge)
+	}
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return fmt.Errorf("invalid realm in authentication challenge: %v", err)
+	}
+	q := u.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", httputil.UserAgent())
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("cannot obtain registry token: got unexpected HTTP status code %d", resp.StatusCode)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxMetadataSize)).Decode(&tok); err != nil {
+		return fmt.Errorf("cannot decode registry token: %v", err)
+	}
+	c.token = tok.Token
+	if c.token == "" {
+		c.token = tok.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("cannot obtain registry token: empty token")
+	}
+	return nil
+}
+
+// get issues a GET request against the registry, authenticating as
+// requested by the registry.
+func (c *Client) get(ctx context.Context, u string, accept string) (*http.Response, error) {
+	authenticated := false
+	for {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", httputil.UserAgent())
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		switch {
+		case c.token != "":
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		case c.cfg.Username != "":
+			req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+		}
+		resp, err := ctxhttp.Do(ctx, c.client, req)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case 200:
+			return resp, nil
+		case 401:
+			resp.Body.Close()
+			challenge := resp.Header.Get("WWW-Authenticate")
+			if authenticated || challenge == "" {
+				return nil, fmt.Errorf("cannot access %s: unauthorized", u)
+			}
+			if err := c.authenticate(ctx, challenge); err != nil {
+				return nil, err
+			}
+			authenticated = true
+		case 404:
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot find %s", u)
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot access %s: got unexpected HTTP status code %d", u, resp.StatusCode)
+		}
+	}
+}
+
+func (c *Client) manifest(ctx context.Context, ref *Reference) (*manifest, error) {
+	what := ref.Digest
+	if what == "" {
+		what = ref.Tag
+	}
+	resp, err := c.get(ctx, c.endpoint(ref, "manifests", what), ManifestMediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return nil, err
+	}
+	if ref.Digest != "" {
+		if err := checkDigest(ref.Digest, data); err != nil {
+			return nil, fmt.Errorf("cannot use manifest of %s: %v", ref, err)
+		}
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot decode manifest of %s: %v", ref, err)
+	}
+	if m.SchemaVersion != 2 {
+		return nil, fmt.Errorf("cannot use manifest of %s: unsupported schema version %d", ref, m.SchemaVersion)
+	}
+	return &m, nil
+}
+
+func checkDigest(digest string, data []byte) error {
+	h := sha256.Sum256(data)
+	if actual := "sha256:" + hex.EncodeToString(h[:]); actual != digest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", digest, actual)
+	}
+	return nil
+}
+
+// blob streams the given blob into w, verifying its size and digest.
+func (c *Client) blob(ctx context.Context, ref *Reference, desc *descriptor, w io.Writer) error {
+	if !validDigest.MatchString(desc.Digest) {
+		return fmt.Errorf("cannot fetch blob: invalid digest %q", desc.Digest)
+	}
+	resp, err := c.get(ctx, c.endpoint(ref, "blobs", desc.Digest), "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), io.LimitReader(resp.Body, desc.Size+1))
+	if err != nil {
+		return err
+	}
+	if n != desc.Size {
+		return fmt.Errorf("cannot fetch blob %s: size mismatch: expected %d, got %d", desc.Digest, desc.Size, n)
+	}
+	if actual := "sha256:" + hex.EncodeToString(h.Sum(nil)); actual != desc.Digest {
+		return fmt.Errorf("cannot fetch blob %s: digest mismatch: got %s", desc.Digest, actual)
+	}
+	return nil
+}
+
+// Download fetches the snap artifact referred to by ref into
+// targetPath, returning the assertions published alongside it.
+func (c *Client) Download(ctx context.Context, ref *Reference, targetPath string) (*Artifact, error) {
+	m, err := c.manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapDesc, assertsDesc *descriptor
+	for i := range m.Layers {
+		layer := &m.Layers[i]
+		switch layer.MediaType {
+		case SnapMediaType:
+			if snapDesc != nil {
+				return nil, fmt.Errorf("cannot use %s: more than one snap layer", ref)
+			}
+			snapDesc = layer
+		case AssertionsMediaType:
+			if assertsDesc != nil {
+				return nil, fmt.Errorf("cannot use %s: more than one assertions layer", ref)
+			}
+			assertsDesc = layer
+		}
+	}
+	if snapDesc == nil {
+		return nil, fmt.Errorf("cannot use %s: no snap layer", ref)
+	}
+
+	artifact := &Artifact{
+		Digest: snapDesc.Digest,
+		Size:   snapDesc.Size,
+	}
+	if assertsDesc != nil {
+		if assertsDesc.Size > maxMetadataSize {
+			return nil, fmt.Errorf("cannot use %s: assertions layer too big", ref)
+		}
+		var buf bytes.Buffer
+		if err := c.blob(ctx, ref, assertsDesc, &buf); err != nil {
+			return nil, err
+		}
+		artifact.Assertions = buf.Bytes()
+	}
+
+	w, err := osutil.NewAtomicFile(targetPath, 0600, 0, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Cancel()
+	if err := c.blob(ctx, ref, snapDesc, w); err != nil {
+		return nil, err
+	}
+	if err := w.Commit(); err != nil {
+		return nil, err
+	}
+
+	return artifact, nil
+}
@@ -0,0 +1,279 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package oci_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/store/oci"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ociSuite struct {
+	server *httptest.Server
+	blobs  map[string][]byte
+	// manifests by tag
+	manifests map[string][]byte
+	// if set, requests need to carry this bearer token
+	token string
+}
+
+var _ = Suite(&ociSuite{})
+
+func digest(data []byte) string {
+	h := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+func (s *ociSuite) SetUpTest(c *C) {
+	s.blobs = make(map[string][]byte)
+	s.manifests = make(map[string][]byte)
+	s.token = ""
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+}
+
+func (s *ociSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *ociSuite) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		c := r.URL.Query()
+		if c.Get("service") != "registry" || c.Get("scope") != "repository:acme/hello:pull" {
+			w.WriteHeader(400)
+			return
+		}
+		fmt.Fprintf(w, `{"token": %q}`, s.token)
+		return
+	}
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:acme/hello:pull"`, s.server.URL))
+		w.WriteHeader(401)
+		return
+	}
+	const prefix = "/v2/acme/hello/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(404)
+		return
+	}
+	kind, what := filepath.Split(strings.TrimPrefix(r.URL.Path, prefix))
+	var data []byte
+	switch kind {
+	case "manifests/":
+		if r.Header.Get("Accept") != oci.ManifestMediaType {
+			w.WriteHeader(406)
+			return
+		}
+		data = s.manifests[what]
+		if data == nil {
+			for _, m := range s.manifests {
+				if digest(m) == what {
+					data = m
+				}
+			}
+		}
+	case "blobs/":
+		data = s.blobs[what]
+	}
+	if data == nil {
+		w.WriteHeader(404)
+		return
+	}
+	w.Write(data)
+}
+
+func (s *ociSuite) addBlob(data []byte) string {
+	d := digest(data)
+	s.blobs[d] = data
+	return d
+}
+
+type layer struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int    `json:"size"`
+}
+
+func (s *ociSuite) addManifest(c *C, tag string, layers map[string][]byte) []byte {
+	m := map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     oci.ManifestMediaType,
+	}
+	var ls []layer
+	for mediaType, data := range layers {
+		ls = append(ls, layer{mediaType, s.addBlob(data), len(data)})
+	}
+	m["layers"] = ls
+	data, err := json.Marshal(m)
+	c.Assert(err, IsNil)
+	s.manifests[tag] = data
+	return data
+}
+
+func (s *ociSuite) ref(c *C, what string) *oci.Reference {
+	ref, err := oci.ParseReference(oci.Scheme + strings.TrimPrefix(s.server.URL, "http://") + "/acme/hello" + what)
+	c.Assert(err, IsNil)
+	return ref
+}
+
+func (s *ociSuite) TestParseReference(c *C) {
+	for _, t := range []struct {
+		in  string
+		ref oci.Reference
+		str string
+	}{
+		{"oci://registry.example.com/acme/hello", oci.Reference{Registry: "registry.example.com", Repository: "acme/hello", Tag: "latest"}, "oci://registry.example.com/acme/hello:latest"},
+		{"registry.example.com:5000/hello:1.0", oci.Reference{Registry: "registry.example.com:5000", Repository: "hello", Tag: "1.0"}, "oci://registry.example.com:5000/hello:1.0"},
+		{"oci://localhost/a/b/c@sha256:" + strings.Repeat("a", 64), oci.Reference{Registry: "localhost", Repository: "a/b/c", Digest: "sha256:" + strings.Repeat("a", 64)}, "oci://localhost/a/b/c@sha256:" + strings.Repeat("a", 64)},
+	} {
+		ref, err := oci.ParseReference(t.in)
+		c.Assert(err, IsNil, Commentf(t.in))
+		c.Check(*ref, DeepEquals, t.ref)
+		c.Check(ref.String(), Equals, t.str)
+	}
+
+	for _, t := range []struct {
+		in  string
+		err string
+	}{
+		{"oci://hello", `cannot parse OCI reference "oci://hello": missing registry`},
+		{"oci://localhost/Hello", `cannot parse OCI reference "oci://localhost/Hello": invalid repository "Hello"`},
+		{"oci://localhost/hello:", `cannot parse OCI reference "oci://localhost/hello:": invalid tag ""`},
+		{"oci://localhost/hello@sha256:abc", `cannot parse OCI reference "oci://localhost/hello@sha256:abc": invalid digest "sha256:abc"`},
+	} {
+		_, err := oci.ParseReference(t.in)
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(t.err))
+	}
+}
+
+func (s *ociSuite) TestDownload(c *C) {
+	s.addManifest(c, "1.0", map[string][]byte{
+		oci.SnapMediaType:       []byte("snap-data"),
+		oci.AssertionsMediaType: []byte("assertions"),
+		"application/x-other":   []byte("ignored"),
+	})
+
+	target := filepath.Join(c.MkDir(), "hello.snap")
+	cli := oci.New(&oci.Config{PlainHTTP: true})
+	artifact, err := cli.Download(context.TODO(), s.ref(c, ":1.0"), target)
+	c.Assert(err, IsNil)
+	c.Check(artifact, DeepEquals, &oci.Artifact{
+		Digest:     digest([]byte("snap-data")),
+		Size:       9,
+		Assertions: []byte("assertions"),
+	})
+	data, err := ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "snap-data")
+}
+
+func (s *ociSuite) TestDownloadByDigestWithToken(c *C) {
+	s.token = "s3kr3t"
+	m := s.addManifest(c, "1.0", map[string][]byte{
+		oci.SnapMediaType: []byte("snap-data"),
+	})
+
+	target := filepath.Join(c.MkDir(), "hello.snap")
+	cli := oci.New(&oci.Config{PlainHTTP: true})
+	artifact, err := cli.Download(context.TODO(), s.ref(c, "@"+digest(m)), target)
+	c.Assert(err, IsNil)
+	c.Check(artifact.Assertions, IsNil)
+	c.Check(osutil.FileExists(target), Equals, true)
+}
+
+func (s *ociSuite) TestDownloadErrors(c *C) {
+	s.addManifest(c, "no-snap", map[string][]byte{
+		oci.AssertionsMediaType: []byte("assertions"),
+	})
+	s.addManifest(c, "corrupted", map[string][]byte{
+		oci.SnapMediaType: []byte("snap-data"),
+	})
+	s.blobs[digest([]byte("snap-data"))] = []byte("snap-dat4")
+
+	cli := oci.New(&oci.Config{PlainHTTP: true})
+	target := filepath.Join(c.MkDir(), "hello.snap")
+
+	_, err := cli.Download(context.TODO(), s.ref(c, ":no-snap"), target)
+	c.Check(err, ErrorMatches, `cannot use oci://.*/acme/hello:no-snap: no snap layer`)
+
+	_, err = cli.Download(context.TODO(), s.ref(c, ":corrupted"), target)
+	c.Check(err, ErrorMatches, `cannot fetch blob sha256:.*: digest mismatch: got sha256:.*`)
+	c.Check(osutil.FileExists(target), Equals, false)
+
+	_, err = cli.Download(context.TODO(), s.ref(c, ":missing"), target)
+	c.Check(err, ErrorMatches, `cannot find http://.*/v2/acme/hello/manifests/missing`)
+}
+
+func (s *ociSuite) TestLoadConfig(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	// no configuration at all
+	cfg, err := oci.LoadConfig("registry.example.com")
+	c.Assert(err, IsNil)
+	c.Check(cfg, IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapRegistriesFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapRegistriesFile, []byte(`
+registries:
+  registry.example.com:
+    username: acme
+    password: s3cr3t
+  localhost:5000:
+    plain-http: true
+`), 0600), IsNil)
+
+	cfg, err = oci.LoadConfig("registry.example.com")
+	c.Assert(err, IsNil)
+	c.Check(cfg, DeepEquals, &oci.Config{Username: "acme", Password: "s3cr3t"})
+	cfg, err = oci.LoadConfig("localhost:5000")
+	c.Assert(err, IsNil)
+	c.Check(cfg, DeepEquals, &oci.Config{PlainHTTP: true})
+	cfg, err = oci.LoadConfig("other.example.com")
+	c.Assert(err, IsNil)
+	c.Check(cfg, IsNil)
+
+	// credentials must not leak to other users
+	c.Assert(os.Chmod(dirs.SnapRegistriesFile, 0644), IsNil)
+	_, err = oci.LoadConfig("registry.example.com")
+	c.Check(err, ErrorMatches, `cannot use .*/etc/snapd/registries.yaml: it must not be accessible by other users`)
+
+	c.Assert(ioutil.WriteFile(dirs.SnapRegistriesFile, []byte(`registries: [`), 0600), IsNil)
+	c.Assert(os.Chmod(dirs.SnapRegistriesFile, 0600), IsNil)
+	_, err = oci.LoadConfig("registry.example.com")
+	c.Check(err, ErrorMatches, `cannot parse .*/etc/snapd/registries.yaml: .*`)
+}
@@ -0,0 +1,79 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// rateLimitSleep waits for the given duration, returning early with
+// an error if the context is cancelled first.
+var rateLimitSleep = func(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitedReader is a reader that keeps the average read speed
+// under a given number of bytes per second by sleeping between reads.
+type rateLimitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit int64
+
+	start time.Time
+	total int64
+}
+
+func newRateLimitedReader(ctx context.Context, r io.Reader, limit int64) *rateLimitedReader {
+	return &rateLimitedReader{
+		ctx:   ctx,
+		r:     r,
+		limit: limit,
+		start: time.Now(),
+	}
+}
+
+func (rl *rateLimitedReader) Read(p []byte) (int, error) {
+	// read at most a second worth of data at a time so that the
+	// waits stay short and the download does not come in bursts
+	if int64(len(p)) > rl.limit {
+		p = p[:rl.limit]
+	}
+	n, err := rl.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	rl.total += int64(n)
+
+	due := time.Duration(float64(rl.total) / float64(rl.limit) * float64(time.Second))
+	if wait := due - time.Since(rl.start); wait > 0 {
+		if serr := rateLimitSleep(rl.ctx, wait); serr != nil {
+			return n, serr
+		}
+	}
+	return n, err
+}
@@ -34,6 +34,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -92,6 +93,8 @@ func infoFromRemote(d *snapDetails) *snap.Info {
 	info.EditedDescription = d.Description
 	info.PublisherID = d.DeveloperID
 	info.Publisher = d.Developer
+	info.PublisherValidation = d.DeveloperValidation
+	info.PublisherContact = d.DeveloperContact
 	info.Channel = d.Channel
 	info.Sha3_384 = d.DownloadSha3_384
 	info.Size = d.DownloadSize
@@ -103,6 +106,7 @@ func infoFromRemote(d *snapDetails) *snap.Info {
 	info.Confinement = snap.ConfinementType(d.Confinement)
 	info.Contact = d.Contact
 	info.License = d.License
+	info.DefaultTrack = d.DefaultTrack
 
 	deltas := make([]snap.DeltaInfo, len(d.Deltas))
 	for i, d := range d.Deltas {
@@ -178,6 +182,10 @@ type Config struct {
 
 	DetailFields []string
 	DeltaFormat  string
+
+	// DNS returns how to resolve the store hosts, the system resolver
+	// being used if it is nil or returns no mode.
+	DNS func() (*httputil.DNSConfig, error)
 }
 
 // SetBaseURL updates the store API's base URL in the Config. Must not be used
@@ -225,6 +233,7 @@ type Store struct {
 
 	detailFields []string
 	deltaFormat  string
+	dnsConfig    func() (*httputil.DNSConfig, error)
 	// reused http client
 	client *http.Client
 
@@ -232,6 +241,7 @@ type Store struct {
 
 	mu                sync.Mutex
 	suggestedCurrency string
+	publisherSnaps    map[string]cachedPublisherSnaps
 }
 
 func respToError(resp *http.Response, msg string) error {
@@ -437,10 +447,12 @@ func New(cfg *Config, authContext auth.AuthContext) *Store {
 		detailFields:    fields,
 		authContext:     authContext,
 		deltaFormat:     deltaFormat,
+		dnsConfig:       cfg.DNS,
 
 		client: httputil.NewHTTPClient(&httputil.ClientOpts{
 			Timeout:    10 * time.Second,
 			MayLogBody: true,
+			DNS:        cfg.DNS,
 		}),
 	}
 
@@ -636,7 +648,11 @@ func authenticateDevice(r *http.Request, device *auth.DeviceState) {
 	}
 }
 
-func (s *Store) setStoreID(r *http.Request) {
+func (s *Store) setStoreID(r *http.Request, override string) {
+	if override != "" {
+		r.Header.Set("X-Ubuntu-Store", override)
+		return
+	}
 	storeID := s.fallbackStoreID
 	if s.authContext != nil {
 		cand, err := s.authContext.StoreID(storeID)
@@ -659,6 +675,8 @@ type requestOptions struct {
 	ContentType  string
 	ExtraHeaders map[string]string
 	Data         []byte
+	// StoreID if set is used instead of the store id of the device.
+	StoreID string
 }
 
 func cancelled(ctx context.Context) bool {
@@ -844,7 +862,7 @@ func (s *Store) newRequest(reqOptions *requestOptions, user *auth.UserState) (*h
 		req.Header.Set(header, value)
 	}
 
-	s.setStoreID(req)
+	s.setStoreID(req, reqOptions.StoreID)
 
 	return req, nil
 }
@@ -965,6 +983,8 @@ type SnapSpec struct {
 	AnyChannel bool
 	// Revision can be set to query for an exact revision
 	Revision snap.Revision
+	// StoreID can be set to query a store other than the device one
+	StoreID string
 }
 
 // SnapInfo returns the snap.Info for the store-hosted snap matching the given spec, or an error.
@@ -991,9 +1011,10 @@ func (s *Store) SnapInfo(snapSpec SnapSpec, user *auth.UserState) (*snap.Info, e
 
 	u := endpointURL(s.detailsURI, snapSpec.Name, query)
 	reqOptions := &requestOptions{
-		Method: "GET",
-		URL:    u,
-		Accept: halJsonContentType,
+		Method:  "GET",
+		URL:     u,
+		Accept:  halJsonContentType,
+		StoreID: snapSpec.StoreID,
 	}
 
 	var remote *snapDetails
@@ -1031,6 +1052,9 @@ type Search struct {
 	Section string
 	Private bool
 	Prefix  bool
+	// Publisher restricts the search to the snaps of the account
+	// with the given id.
+	Publisher string
 }
 
 // Find finds  (installable) snaps from the store, matching the
@@ -1068,9 +1092,12 @@ func (s *Store) Find(search *Search, user *auth.UserState) ([]*snap.Info, error)
 
 	if search.Prefix {
 		q.Set("name", searchTerm)
-	} else {
+	} else if searchTerm != "" || search.Publisher == "" {
 		q.Set("q", searchTerm)
 	}
+	if search.Publisher != "" {
+		q.Set("publisher", search.Publisher)
+	}
 	if search.Section != "" {
 		q.Set("section", search.Section)
 	}
@@ -1117,6 +1144,53 @@ func (s *Store) Find(search *Search, user *auth.UserState) ([]*snap.Info, error)
 	return snaps, nil
 }
 
+// publisherSnapsCacheTTL is how long the snaps of a publisher are
+// remembered before asking the store again.
+var publisherSnapsCacheTTL = 1 * time.Hour
+
+type cachedPublisherSnaps struct {
+	names  []string
+	expiry time.Time
+}
+
+// PublisherSnaps returns the sorted names of the snaps published by the
+// account with the given id. Results are cached for a while, as they
+// are meant for informational output that can be shown often.
+func (s *Store) PublisherSnaps(publisherID string, user *auth.UserState) ([]string, error) {
+	if publisherID == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	s.mu.Lock()
+	cached, ok := s.publisherSnaps[publisherID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expiry) {
+		return cached.names, nil
+	}
+
+	found, err := s.Find(&Search{Publisher: publisherID}, user)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(found))
+	for i, info := range found {
+		names[i] = info.Name()
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.publisherSnaps == nil {
+		s.publisherSnaps = make(map[string]cachedPublisherSnaps)
+	}
+	s.publisherSnaps[publisherID] = cachedPublisherSnaps{
+		names:  names,
+		expiry: time.Now().Add(publisherSnapsCacheTTL),
+	}
+
+	return names, nil
+}
+
 // Sections retrieves the list of available store sections.
 func (s *Store) Sections(user *auth.UserState) ([]string, error) {
 	reqOptions := &requestOptions{
@@ -1147,6 +1221,38 @@ func (s *Store) Sections(user *auth.UserState) ([]string, error) {
 	return sectionNames, nil
 }
 
+// connectivityCheckTimeout is how long ConnectivityCheck waits for
+// each host to answer.
+var connectivityCheckTimeout = 10 * time.Second
+
+// ConnectivityCheck checks whether the hosts of the store API and of
+// the assertions service can be reached, returning the result for each
+// of them by host name. Any HTTP answer counts as reachable.
+func (s *Store) ConnectivityCheck() (status map[string]bool, err error) {
+	client := httputil.NewHTTPClient(&httputil.ClientOpts{
+		Timeout: connectivityCheckTimeout,
+		DNS:     s.dnsConfig,
+	})
+
+	status = make(map[string]bool)
+	for _, u := range []*url.URL{s.detailsURI, s.assertionsURI} {
+		if _, ok := status[u.Host]; ok {
+			continue
+		}
+		checkURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+		resp, err := client.Head(checkURL.String())
+		if err != nil {
+			logger.Debugf("Cannot reach %s: %v", u.Host, err)
+			status[u.Host] = false
+			continue
+		}
+		resp.Body.Close()
+		status[u.Host] = true
+	}
+
+	return status, nil
+}
+
 // WriteCatalogs queries the "commands" endpoint and writes the
 // command names into the given io.Writer.
 func (s *Store) WriteCatalogs(names io.Writer) error {
@@ -1194,6 +1300,9 @@ type RefreshCandidate struct {
 
 	// the desired channel
 	Channel string
+
+	// the store the snap is associated with, if not the device one
+	StoreID string
 }
 
 // the exact bits that we need to send to the store
@@ -1239,7 +1348,7 @@ func currentSnap(cs *RefreshCandidate) *currentSnapJSON {
 }
 
 // query the store for the information about currently offered revisions of snaps
-func (s *Store) refreshForCandidates(currentSnaps []*currentSnapJSON, user *auth.UserState) ([]*snapDetails, error) {
+func (s *Store) refreshForCandidates(currentSnaps []*currentSnapJSON, storeID string, user *auth.UserState) ([]*snapDetails, error) {
 	if len(currentSnaps) == 0 {
 		// nothing to do
 		return nil, nil
@@ -1260,6 +1369,7 @@ func (s *Store) refreshForCandidates(currentSnaps []*currentSnapJSON, user *auth
 		Accept:      halJsonContentType,
 		ContentType: jsonContentType,
 		Data:        jsonData,
+		StoreID:     storeID,
 	}
 
 	if useDeltas() {
@@ -1293,7 +1403,7 @@ func (s *Store) LookupRefresh(installed *RefreshCandidate, user *auth.UserState)
 		return nil, ErrLocalSnap
 	}
 
-	latest, err := refreshForCandidates(s, []*currentSnapJSON{cur}, user)
+	latest, err := refreshForCandidates(s, []*currentSnapJSON{cur}, installed.StoreID, user)
 	if err != nil {
 		return nil, err
 	}
@@ -1315,19 +1425,28 @@ func (s *Store) LookupRefresh(installed *RefreshCandidate, user *auth.UserState)
 func (s *Store) ListRefresh(installed []*RefreshCandidate, user *auth.UserState) (snaps []*snap.Info, err error) {
 
 	candidateMap := map[string]*RefreshCandidate{}
-	currentSnaps := make([]*currentSnapJSON, 0, len(installed))
+	// snaps associated with a different store are asked about separately
+	var storeIDs []string
+	currentSnapsByStore := make(map[string][]*currentSnapJSON)
 	for _, cs := range installed {
 		cur := currentSnap(cs)
 		if cur == nil {
 			continue
 		}
-		currentSnaps = append(currentSnaps, cur)
+		if _, ok := currentSnapsByStore[cs.StoreID]; !ok {
+			storeIDs = append(storeIDs, cs.StoreID)
+		}
+		currentSnapsByStore[cs.StoreID] = append(currentSnapsByStore[cs.StoreID], cur)
 		candidateMap[cs.SnapID] = cs
 	}
 
-	latest, err := s.refreshForCandidates(currentSnaps, user)
-	if err != nil {
-		return nil, err
+	var latest []*snapDetails
+	for _, storeID := range storeIDs {
+		fromStore, err := refreshForCandidates(s, currentSnapsByStore[storeID], storeID, user)
+		if err != nil {
+			return nil, err
+		}
+		latest = append(latest, fromStore...)
 	}
 
 	toRefresh := make([]*snap.Info, 0, len(latest))
@@ -1366,11 +1485,74 @@ func (e HashError) Error() string {
 	return fmt.Sprintf("sha3-384 mismatch for %q: got %s but expected %s", e.name, e.sha3_384, e.targetSha3_384)
 }
 
+// DownloadOptions carries options for a snap download.
+type DownloadOptions struct {
+	// RateLimit is the maximum download speed in bytes per
+	// second, zero means unlimited.
+	RateLimit int64
+
+	// Partial describes a download interrupted earlier, e.g. by a
+	// restart, that should be resumed if it still matches.
+	Partial *PartialDownload
+	// SavePartial, if set, is called from time to time while
+	// downloading, and when the download is interrupted, with what
+	// is needed to resume it later.
+	SavePartial func(*PartialDownload)
+}
+
+// PartialDownload describes a download that has not completed yet.
+type PartialDownload struct {
+	// Path is the file the download is written to.
+	Path string `json:"path"`
+	// Offset is how much of the download is known to be safely
+	// written to Path.
+	Offset int64 `json:"offset"`
+	// ETag is the entity tag of the downloaded content, used to
+	// check that it did not change when resuming.
+	ETag string `json:"etag,omitempty"`
+}
+
+// partialSaveInterval is how often the progress of a download is saved.
+var partialSaveInterval = 5 * time.Second
+
+// partialSaver is fed the downloaded data after it has been written
+// out and from time to time saves what is needed to resume the
+// download.
+type partialSaver struct {
+	w        io.Writer
+	partial  PartialDownload
+	save     func(*PartialDownload)
+	lastSave time.Time
+}
+
+func (ps *partialSaver) Write(p []byte) (int, error) {
+	ps.partial.Offset += int64(len(p))
+	if time.Since(ps.lastSave) >= partialSaveInterval {
+		ps.flush()
+	}
+	return len(p), nil
+}
+
+// flush saves the current progress, once the data is safely on disk.
+func (ps *partialSaver) flush() {
+	if f, ok := ps.w.(interface {
+		Sync() error
+	}); ok {
+		if err := f.Sync(); err != nil {
+			logger.Noticef("Cannot sync partial download %q: %v", ps.partial.Path, err)
+			return
+		}
+	}
+	partial := ps.partial
+	ps.save(&partial)
+	ps.lastSave = time.Now()
+}
+
 // Download downloads the snap addressed by download info and returns its
 // filename.
 // The file is saved in temporary storage, and should be removed
 // after use to prevent the disk from running out of space.
-func (s *Store) Download(ctx context.Context, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState) error {
+func (s *Store) Download(ctx context.Context, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *DownloadOptions) error {
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 		return err
 	}
@@ -1378,7 +1560,12 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 
 		if len(downloadInfo.Deltas) == 1 {
-			err := s.downloadAndApplyDelta(name, targetPath, downloadInfo, pbar, user)
+			var deltaOpts *DownloadOptions
+			if dlOpts != nil {
+				// deltas are not resumed across restarts
+				deltaOpts = &DownloadOptions{RateLimit: dlOpts.RateLimit}
+			}
+			err := s.downloadAndApplyDelta(ctx, name, targetPath, downloadInfo, pbar, user, deltaOpts)
 			if err == nil {
 				return nil
 			}
@@ -1400,11 +1587,35 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		if cerr := w.Close(); cerr != nil && err == nil {
 			err = cerr
 		}
-		if err != nil {
+		// keep what was downloaded when cancelled (e.g. because
+		// snapd is stopping), so that it can be resumed
+		if err != nil && !cancelled(ctx) {
 			os.Remove(w.Name())
 		}
 	}()
 
+	if dlOpts != nil && dlOpts.SavePartial != nil {
+		var etag string
+		if p := dlOpts.Partial; p != nil {
+			// only trust what was saved as safely written
+			if p.Path == partialPath && p.Offset <= resume {
+				resume = p.Offset
+				etag = p.ETag
+			} else {
+				resume = 0
+			}
+			if err := w.Truncate(resume); err != nil {
+				return err
+			}
+			if _, err := w.Seek(resume, os.SEEK_SET); err != nil {
+				return err
+			}
+		}
+		opts := *dlOpts
+		opts.Partial = &PartialDownload{Path: partialPath, Offset: resume, ETag: etag}
+		dlOpts = &opts
+	}
+
 	authAvail, err := s.authAvailable(user)
 	if err != nil {
 		return err
@@ -1416,7 +1627,7 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 	}
 
 	if downloadInfo.Size == 0 || resume < downloadInfo.Size {
-		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, w, resume, pbar)
+		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, w, resume, pbar, dlOpts)
 	} else {
 		// we're done! check the hash though
 		h := crypto.SHA3_384.New()
@@ -1442,7 +1653,7 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		if err != nil {
 			return err
 		}
-		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, w, 0, pbar)
+		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, w, 0, pbar, dlOpts)
 	}
 
 	if err != nil {
@@ -1457,12 +1668,21 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 }
 
 // download writes an http.Request showing a progress.Meter
-var download = func(ctx context.Context, name, sha3_384, downloadURL string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+var download = func(ctx context.Context, name, sha3_384, downloadURL string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 	storeURL, err := url.Parse(downloadURL)
 	if err != nil {
 		return err
 	}
 
+	var etag string
+	var savePartial func(*PartialDownload)
+	if dlOpts != nil && dlOpts.Partial != nil && dlOpts.SavePartial != nil {
+		savePartial = dlOpts.SavePartial
+		if dlOpts.Partial.Offset == resume {
+			etag = dlOpts.Partial.ETag
+		}
+	}
+
 	var finalErr error
 	startTime := time.Now()
 	for attempt := retry.Start(defaultRetryStrategy, nil); attempt.Next(); {
@@ -1478,6 +1698,10 @@ var download = func(ctx context.Context, name, sha3_384, downloadURL string, use
 			reqOptions.ExtraHeaders = map[string]string{
 				"Range": fmt.Sprintf("bytes=%d-", resume),
 			}
+			if etag != "" {
+				// get everything again if the content changed
+				reqOptions.ExtraHeaders["If-Range"] = etag
+			}
 			// seed the sha3 with the already local file
 			if _, err := w.Seek(0, os.SEEK_SET); err != nil {
 				return err
@@ -1495,7 +1719,7 @@ var download = func(ctx context.Context, name, sha3_384, downloadURL string, use
 			return fmt.Errorf("The download has been cancelled: %s", ctx.Err())
 		}
 		var resp *http.Response
-		resp, finalErr = s.doRequest(ctx, httputil.NewHTTPClient(nil), reqOptions, user)
+		resp, finalErr = s.doRequest(ctx, httputil.NewHTTPClient(&httputil.ClientOpts{DNS: s.dnsConfig}), reqOptions, user)
 
 		if cancelled(ctx) {
 			return fmt.Errorf("The download has been cancelled: %s", ctx.Err())
@@ -1515,21 +1739,48 @@ var download = func(ctx context.Context, name, sha3_384, downloadURL string, use
 		defer resp.Body.Close()
 
 		switch resp.StatusCode {
-		case 200, 206: // OK, Partial Content
+		case 200: // OK
+			if resume > 0 && reqOptions.ExtraHeaders["If-Range"] != "" {
+				// the content changed, start over
+				if err := restartDownload(w); err != nil {
+					return err
+				}
+				h = crypto.SHA3_384.New()
+				resume = 0
+			}
+		case 206: // Partial Content
 		case 402: // Payment Required
 
 			return fmt.Errorf("please buy %s before installing it.", name)
 		default:
 			return &DownloadError{Code: resp.StatusCode, URL: resp.Request.URL}
 		}
+		etag = resp.Header.Get("ETag")
 
 		if pbar == nil {
 			pbar = &progress.NullProgress{}
 		}
 		pbar.Start(name, float64(resp.ContentLength))
 		mw := io.MultiWriter(w, h, pbar)
-		_, finalErr = io.Copy(mw, resp.Body)
+		var saver *partialSaver
+		if savePartial != nil {
+			saver = &partialSaver{
+				w:        w,
+				partial:  PartialDownload{Path: dlOpts.Partial.Path, Offset: resume, ETag: etag},
+				save:     savePartial,
+				lastSave: time.Now(),
+			}
+			mw = io.MultiWriter(w, h, pbar, saver)
+		}
+		var body io.Reader = resp.Body
+		if dlOpts != nil && dlOpts.RateLimit > 0 {
+			body = newRateLimitedReader(ctx, resp.Body, dlOpts.RateLimit)
+		}
+		_, finalErr = io.Copy(mw, body)
 		pbar.Finished()
+		if finalErr != nil && saver != nil {
+			saver.flush()
+		}
 		if finalErr != nil {
 			if httputil.ShouldRetryError(attempt, finalErr) {
 				// error while downloading should resume
@@ -1556,8 +1807,21 @@ var download = func(ctx context.Context, name, sha3_384, downloadURL string, use
 	return finalErr
 }
 
+// restartDownload discards what was downloaded so far to w.
+func restartDownload(w io.ReadWriteSeeker) error {
+	if t, ok := w.(interface {
+		Truncate(int64) error
+	}); ok {
+		if err := t.Truncate(0); err != nil {
+			return err
+		}
+	}
+	_, err := w.Seek(0, os.SEEK_SET)
+	return err
+}
+
 // downloadDelta downloads the delta for the preferred format, returning the path.
-func (s *Store) downloadDelta(deltaName string, downloadInfo *snap.DownloadInfo, w io.ReadWriteSeeker, pbar progress.Meter, user *auth.UserState) error {
+func (s *Store) downloadDelta(ctx context.Context, deltaName string, downloadInfo *snap.DownloadInfo, w io.ReadWriteSeeker, pbar progress.Meter, user *auth.UserState, dlOpts *DownloadOptions) error {
 
 	if len(downloadInfo.Deltas) != 1 {
 		return errors.New("store returned more than one download delta")
@@ -1579,7 +1843,7 @@ func (s *Store) downloadDelta(deltaName string, downloadInfo *snap.DownloadInfo,
 		url = deltaInfo.DownloadURL
 	}
 
-	return download(context.TODO(), deltaName, deltaInfo.Sha3_384, url, user, s, w, 0, pbar)
+	return download(ctx, deltaName, deltaInfo.Sha3_384, url, user, s, w, 0, pbar, dlOpts)
 }
 
 func getXdelta3Cmd(args ...string) (*exec.Cmd, error) {
@@ -1640,7 +1904,7 @@ var applyDelta = func(name string, deltaPath string, deltaInfo *snap.DeltaInfo,
 }
 
 // downloadAndApplyDelta downloads and then applies the delta to the current snap.
-func (s *Store) downloadAndApplyDelta(name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState) error {
+func (s *Store) downloadAndApplyDelta(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *DownloadOptions) error {
 	deltaInfo := &downloadInfo.Deltas[0]
 
 	deltaPath := fmt.Sprintf("%s.%s-%d-to-%d.partial", targetPath, deltaInfo.Format, deltaInfo.FromRevision, deltaInfo.ToRevision)
@@ -1657,7 +1921,7 @@ func (s *Store) downloadAndApplyDelta(name, targetPath string, downloadInfo *sna
 		os.Remove(deltaPath)
 	}()
 
-	err = s.downloadDelta(deltaName, downloadInfo, w, pbar, user)
+	err = s.downloadDelta(ctx, deltaName, downloadInfo, w, pbar, user, dlOpts)
 	if err != nil {
 		return err
 	}
@@ -155,7 +155,7 @@ type remoteRepoTestSuite struct {
 	localUser *auth.UserState
 	device    *auth.DeviceState
 
-	origDownloadFunc func(context.Context, string, string, string, *auth.UserState, *Store, io.ReadWriteSeeker, int64, progress.Meter) error
+	origDownloadFunc func(context.Context, string, string, string, *auth.UserState, *Store, io.ReadWriteSeeker, int64, progress.Meter, *DownloadOptions) error
 	mockXDelta       *testutil.MockCmd
 
 	restoreLogger func()
@@ -390,7 +390,7 @@ func (t *remoteRepoTestSuite) expectedAuthorization(c *C, user *auth.UserState)
 
 func (t *remoteRepoTestSuite) TestDownloadOK(c *C) {
 	expectedContent := []byte("I was downloaded")
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		c.Check(url, Equals, "anon-url")
 		w.Write(expectedContent)
 		return nil
@@ -403,7 +403,7 @@ func (t *remoteRepoTestSuite) TestDownloadOK(c *C) {
 	snap.Size = int64(len(expectedContent))
 
 	path := filepath.Join(c.MkDir(), "downloaded-file")
-	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 	defer os.Remove(path)
 
@@ -417,7 +417,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequest(c *C) {
 	missingContentStr := "was downloaded"
 	expectedContentStr := partialContentStr + missingContentStr
 
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		c.Check(resume, Equals, int64(len(partialContentStr)))
 		c.Check(url, Equals, "anon-url")
 		w.Write([]byte(missingContentStr))
@@ -435,7 +435,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequest(c *C) {
 	err := ioutil.WriteFile(targetFn+".partial", []byte(partialContentStr), 0644)
 	c.Assert(err, IsNil)
 
-	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 
 	content, err := ioutil.ReadFile(targetFn)
@@ -459,7 +459,7 @@ func (t *remoteRepoTestSuite) TestResumeOfCompleted(c *C) {
 	err := ioutil.WriteFile(targetFn+".partial", []byte(expectedContentStr), 0644)
 	c.Assert(err, IsNil)
 
-	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 
 	content, err := ioutil.ReadFile(targetFn)
@@ -467,6 +467,156 @@ func (t *remoteRepoTestSuite) TestResumeOfCompleted(c *C) {
 	c.Assert(string(content), Equals, expectedContentStr)
 }
 
+func (t *remoteRepoTestSuite) TestDownloadResumesSavedPartial(c *C) {
+	restore := partialSaveInterval
+	partialSaveInterval = 0
+	defer func() { partialSaveInterval = restore }()
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Range"), Equals, "bytes=5-")
+		c.Check(r.Header.Get("If-Range"), Equals, `"etag"`)
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(206)
+		io.WriteString(w, "data")
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.AnonDownloadURL = mockServer.URL
+	snap.DownloadURL = "AUTH-URL"
+	snap.Sha3_384 = fmt.Sprintf("%x", sha3.Sum384([]byte("some data")))
+	snap.Size = int64(len("some data"))
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	// only "some " was saved as written before the restart
+	err := ioutil.WriteFile(targetFn+".partial", []byte("some garbage"), 0644)
+	c.Assert(err, IsNil)
+
+	var saved []PartialDownload
+	dlOpts := &DownloadOptions{
+		Partial: &PartialDownload{Path: targetFn + ".partial", Offset: 5, ETag: `"etag"`},
+		SavePartial: func(p *PartialDownload) {
+			saved = append(saved, *p)
+		},
+	}
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, dlOpts)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(targetFn)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "some data")
+
+	c.Assert(saved, Not(HasLen), 0)
+	c.Check(saved[len(saved)-1], DeepEquals, PartialDownload{Path: targetFn + ".partial", Offset: 9, ETag: `"etag"`})
+}
+
+func (t *remoteRepoTestSuite) TestDownloadSavedPartialContentChanged(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("If-Range"), Equals, `"old-etag"`)
+		// the content changed, so the whole of it is sent
+		w.Header().Set("ETag", `"new-etag"`)
+		io.WriteString(w, "new data")
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.AnonDownloadURL = mockServer.URL
+	snap.DownloadURL = "AUTH-URL"
+	snap.Sha3_384 = fmt.Sprintf("%x", sha3.Sum384([]byte("new data")))
+	snap.Size = int64(len("new data"))
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	err := ioutil.WriteFile(targetFn+".partial", []byte("old "), 0644)
+	c.Assert(err, IsNil)
+
+	dlOpts := &DownloadOptions{
+		Partial:     &PartialDownload{Path: targetFn + ".partial", Offset: 4, ETag: `"old-etag"`},
+		SavePartial: func(*PartialDownload) {},
+	}
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, dlOpts)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(targetFn)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "new data")
+}
+
+func (t *remoteRepoTestSuite) TestDownloadSavedPartialMismatchStartsOver(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Range"), Equals, "")
+		io.WriteString(w, "data")
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.AnonDownloadURL = mockServer.URL
+	snap.DownloadURL = "AUTH-URL"
+	snap.Sha3_384 = fmt.Sprintf("%x", sha3.Sum384([]byte("data")))
+	snap.Size = int64(len("data"))
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	err := ioutil.WriteFile(targetFn+".partial", []byte("unknown"), 0644)
+	c.Assert(err, IsNil)
+
+	dlOpts := &DownloadOptions{
+		Partial:     &PartialDownload{Path: "/some/other/path.partial", Offset: 3},
+		SavePartial: func(*PartialDownload) {},
+	}
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, dlOpts)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(targetFn)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "data")
+}
+
+func (t *remoteRepoTestSuite) TestDownloadCancelledKeepsPartial(c *C) {
+	restore := partialSaveInterval
+	partialSaveInterval = 0
+	defer func() { partialSaveInterval = restore }()
+
+	done := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"etag"`)
+		io.WriteString(w, "foo")
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+	defer close(done)
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.AnonDownloadURL = mockServer.URL
+	snap.DownloadURL = "AUTH-URL"
+	snap.Size = 6
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var saved PartialDownload
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	dlOpts := &DownloadOptions{
+		SavePartial: func(p *PartialDownload) {
+			saved = *p
+			// e.g. snapd is stopping
+			cancel()
+		},
+	}
+	err := t.store.Download(ctx, "foo", targetFn, &snap.DownloadInfo, nil, nil, dlOpts)
+	c.Assert(err, ErrorMatches, ".*context canceled")
+
+	c.Check(saved, DeepEquals, PartialDownload{Path: targetFn + ".partial", Offset: 3, ETag: `"etag"`})
+	content, err := ioutil.ReadFile(targetFn + ".partial")
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "foo")
+}
+
 func (t *remoteRepoTestSuite) TestDownloadEOFHandlesResumeHashCorrectly(c *C) {
 	n := 0
 	var mockServer *httptest.Server
@@ -502,7 +652,7 @@ func (t *remoteRepoTestSuite) TestDownloadEOFHandlesResumeHashCorrectly(c *C) {
 	snap.Size = 50000
 
 	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
-	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 
 	content, err := ioutil.ReadFile(targetFn)
@@ -512,6 +662,71 @@ func (t *remoteRepoTestSuite) TestDownloadEOFHandlesResumeHashCorrectly(c *C) {
 	c.Assert(t.logbuf.String(), Matches, "(?s).*Retrying .* attempt 2, .*")
 }
 
+func (t *remoteRepoTestSuite) TestDownloadRateLimited(c *C) {
+	buf := bytes.Repeat([]byte{'x'}, 50000)
+	h := crypto.SHA3_384.New()
+	h.Write(buf)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	var lastWait time.Duration
+	restore := MockRateLimitSleep(func(ctx context.Context, d time.Duration) error {
+		lastWait = d
+		return nil
+	})
+	defer restore()
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.AnonDownloadURL = mockServer.URL
+	snap.DownloadURL = "AUTH-URL"
+	snap.Sha3_384 = fmt.Sprintf("%x", h.Sum(nil))
+	snap.Size = int64(len(buf))
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, &DownloadOptions{RateLimit: 10000})
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(targetFn)
+	c.Assert(err, IsNil)
+	c.Check(content, DeepEquals, buf)
+
+	// sleeping is mocked out so the last wait is close to the
+	// five seconds 50000 bytes take at 10000 bytes per second
+	c.Check(lastWait > 4*time.Second, Equals, true)
+	c.Check(lastWait <= 5*time.Second, Equals, true)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadRateLimitedCancelled(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte{'x'}, 50000))
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	restore := MockRateLimitSleep(func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	})
+	defer restore()
+
+	snap := &snap.Info{}
+	snap.RealName = "foo"
+	snap.AnonDownloadURL = mockServer.URL
+	snap.DownloadURL = "AUTH-URL"
+	snap.Size = 50000
+
+	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
+	err := t.store.Download(ctx, "foo", targetFn, &snap.DownloadInfo, nil, nil, &DownloadOptions{RateLimit: 10000})
+	c.Assert(err, ErrorMatches, ".*context canceled")
+	c.Check(osutil.FileExists(targetFn), Equals, false)
+}
+
 func (t *remoteRepoTestSuite) TestDownloadRetryHashErrorIsFullyRetried(c *C) {
 	n := 0
 	var mockServer *httptest.Server
@@ -550,7 +765,7 @@ func (t *remoteRepoTestSuite) TestDownloadRetryHashErrorIsFullyRetried(c *C) {
 	snap.Size = 50000
 
 	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
-	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 
 	content, err := ioutil.ReadFile(targetFn)
@@ -589,7 +804,7 @@ func (t *remoteRepoTestSuite) TestResumeOfCompletedRetriedOnHashFailure(c *C) {
 
 	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
 	c.Assert(ioutil.WriteFile(targetFn+".partial", badbuf, 0644), IsNil)
-	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 
 	content, err := ioutil.ReadFile(targetFn)
@@ -619,7 +834,7 @@ func (t *remoteRepoTestSuite) TestDownloadRetryHashErrorIsFullyRetriedOnlyOnce(c
 	snap.Size = int64(len("something invalid"))
 
 	targetFn := filepath.Join(c.MkDir(), "foo_1.0_all.snap")
-	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 
 	_, ok := err.(HashError)
 	c.Assert(ok, Equals, true)
@@ -632,7 +847,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequestRetryOnHashError(c *C) {
 	partialContentStr := "partial content "
 
 	n := 0
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		n++
 		if n == 1 {
 			// force sha3 error on first download
@@ -654,7 +869,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequestRetryOnHashError(c *C) {
 	err := ioutil.WriteFile(targetFn+".partial", []byte(partialContentStr), 0644)
 	c.Assert(err, IsNil)
 
-	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 	c.Assert(n, Equals, 2)
 
@@ -667,7 +882,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequestFailOnHashError(c *C) {
 	partialContentStr := "partial content "
 
 	n := 0
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		n++
 		return HashError{"foo", "1234", "5678"}
 	}
@@ -683,7 +898,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequestFailOnHashError(c *C) {
 	err := ioutil.WriteFile(targetFn+".partial", []byte(partialContentStr), 0644)
 	c.Assert(err, IsNil)
 
-	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil)
+	err = t.store.Download(context.TODO(), "foo", targetFn, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, NotNil)
 	c.Assert(err, ErrorMatches, `sha3-384 mismatch for "foo": got 1234 but expected 5678`)
 	c.Assert(n, Equals, 2)
@@ -691,7 +906,7 @@ func (t *remoteRepoTestSuite) TestDownloadRangeRequestFailOnHashError(c *C) {
 
 func (t *remoteRepoTestSuite) TestAuthenticatedDownloadDoesNotUseAnonURL(c *C) {
 	expectedContent := []byte("I was downloaded")
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		// check user is pass and auth url is used
 		c.Check(user, Equals, t.user)
 		c.Check(url, Equals, "AUTH-URL")
@@ -707,7 +922,7 @@ func (t *remoteRepoTestSuite) TestAuthenticatedDownloadDoesNotUseAnonURL(c *C) {
 	snap.Size = int64(len(expectedContent))
 
 	path := filepath.Join(c.MkDir(), "downloaded-file")
-	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, t.user)
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, t.user, nil)
 	c.Assert(err, IsNil)
 	defer os.Remove(path)
 
@@ -718,7 +933,7 @@ func (t *remoteRepoTestSuite) TestAuthenticatedDownloadDoesNotUseAnonURL(c *C) {
 
 func (t *remoteRepoTestSuite) TestAuthenticatedDeviceDoesNotUseAnonURL(c *C) {
 	expectedContent := []byte("I was downloaded")
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		// check auth url is used
 		c.Check(url, Equals, "AUTH-URL")
 
@@ -737,7 +952,7 @@ func (t *remoteRepoTestSuite) TestAuthenticatedDeviceDoesNotUseAnonURL(c *C) {
 	c.Assert(repo, NotNil)
 
 	path := filepath.Join(c.MkDir(), "downloaded-file")
-	err := repo.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil)
+	err := repo.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, IsNil)
 	defer os.Remove(path)
 
@@ -748,7 +963,7 @@ func (t *remoteRepoTestSuite) TestAuthenticatedDeviceDoesNotUseAnonURL(c *C) {
 
 func (t *remoteRepoTestSuite) TestLocalUserDownloadUsesAnonURL(c *C) {
 	expectedContentStr := "I was downloaded"
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		c.Check(url, Equals, "anon-url")
 
 		w.Write([]byte(expectedContentStr))
@@ -762,7 +977,7 @@ func (t *remoteRepoTestSuite) TestLocalUserDownloadUsesAnonURL(c *C) {
 	snap.Size = int64(len(expectedContentStr))
 
 	path := filepath.Join(c.MkDir(), "downloaded-file")
-	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, t.localUser)
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, t.localUser, nil)
 	c.Assert(err, IsNil)
 	defer os.Remove(path)
 
@@ -773,7 +988,7 @@ func (t *remoteRepoTestSuite) TestLocalUserDownloadUsesAnonURL(c *C) {
 
 func (t *remoteRepoTestSuite) TestDownloadFails(c *C) {
 	var tmpfile *os.File
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		tmpfile = w.(*os.File)
 		return fmt.Errorf("uh, it failed")
 	}
@@ -785,7 +1000,7 @@ func (t *remoteRepoTestSuite) TestDownloadFails(c *C) {
 	snap.Size = 1
 	// simulate a failed download
 	path := filepath.Join(c.MkDir(), "downloaded-file")
-	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, ErrorMatches, "uh, it failed")
 	// ... and ensure that the tempfile is removed
 	c.Assert(osutil.FileExists(tmpfile.Name()), Equals, false)
@@ -793,7 +1008,7 @@ func (t *remoteRepoTestSuite) TestDownloadFails(c *C) {
 
 func (t *remoteRepoTestSuite) TestDownloadSyncFails(c *C) {
 	var tmpfile *os.File
-	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 		tmpfile = w.(*os.File)
 		w.Write([]byte("sync will fail"))
 		err := tmpfile.Close()
@@ -809,7 +1024,7 @@ func (t *remoteRepoTestSuite) TestDownloadSyncFails(c *C) {
 
 	// simulate a failed sync
 	path := filepath.Join(c.MkDir(), "downloaded-file")
-	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil)
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil, nil)
 	c.Assert(err, ErrorMatches, `(sync|fsync:) .*`)
 	// ... and ensure that the tempfile is removed
 	c.Assert(osutil.FileExists(tmpfile.Name()), Equals, false)
@@ -828,7 +1043,7 @@ func (t *remoteRepoTestSuite) TestActualDownload(c *C) {
 	var buf SillyBuffer
 	// keep tests happy
 	sha3 := ""
-	err := download(context.TODO(), "foo", sha3, mockServer.URL, nil, theStore, &buf, 0, nil)
+	err := download(context.TODO(), "foo", sha3, mockServer.URL, nil, theStore, &buf, 0, nil, nil)
 	c.Assert(err, IsNil)
 	c.Check(buf.String(), Equals, "response-data")
 	c.Check(n, Equals, 1)
@@ -857,7 +1072,7 @@ func (t *remoteRepoTestSuite) TestDownloadCancellation(c *C) {
 	go func() {
 		sha3 := ""
 		var buf SillyBuffer
-		err := download(ctx, "foo", sha3, mockServer.URL, nil, theStore, &buf, 0, nil)
+		err := download(ctx, "foo", sha3, mockServer.URL, nil, theStore, &buf, 0, nil, nil)
 		result <- err.Error()
 		close(result)
 	}()
@@ -889,7 +1104,7 @@ func (t *remoteRepoTestSuite) TestActualDownloadNonPurchased402(c *C) {
 
 	theStore := New(&Config{}, nil)
 	var buf bytes.Buffer
-	err := download(context.TODO(), "foo", "sha3", mockServer.URL, nil, theStore, nopeSeeker{&buf}, -1, nil)
+	err := download(context.TODO(), "foo", "sha3", mockServer.URL, nil, theStore, nopeSeeker{&buf}, -1, nil, nil)
 	c.Assert(err, NotNil)
 	c.Check(err.Error(), Equals, "please buy foo before installing it.")
 	c.Check(n, Equals, 1)
@@ -906,7 +1121,7 @@ func (t *remoteRepoTestSuite) TestActualDownload404(c *C) {
 
 	theStore := New(&Config{}, nil)
 	var buf SillyBuffer
-	err := download(context.TODO(), "foo", "sha3", mockServer.URL, nil, theStore, &buf, 0, nil)
+	err := download(context.TODO(), "foo", "sha3", mockServer.URL, nil, theStore, &buf, 0, nil, nil)
 	c.Assert(err, NotNil)
 	c.Assert(err, FitsTypeOf, &DownloadError{})
 	c.Check(err.(*DownloadError).Code, Equals, 404)
@@ -924,7 +1139,7 @@ func (t *remoteRepoTestSuite) TestActualDownload500(c *C) {
 
 	theStore := New(&Config{}, nil)
 	var buf SillyBuffer
-	err := download(context.TODO(), "foo", "sha3", mockServer.URL, nil, theStore, &buf, 0, nil)
+	err := download(context.TODO(), "foo", "sha3", mockServer.URL, nil, theStore, &buf, 0, nil, nil)
 	c.Assert(err, NotNil)
 	c.Assert(err, FitsTypeOf, &DownloadError{})
 	c.Check(err.(*DownloadError).Code, Equals, 500)
@@ -948,7 +1163,7 @@ func (t *remoteRepoTestSuite) TestActualDownload500Once(c *C) {
 	var buf SillyBuffer
 	// keep tests happy
 	sha3 := ""
-	err := download(context.TODO(), "foo", sha3, mockServer.URL, nil, theStore, &buf, 0, nil)
+	err := download(context.TODO(), "foo", sha3, mockServer.URL, nil, theStore, &buf, 0, nil, nil)
 	c.Assert(err, IsNil)
 	c.Check(buf.String(), Equals, "response-data")
 	c.Check(n, Equals, 2)
@@ -1015,7 +1230,7 @@ func (t *remoteRepoTestSuite) TestActualDownloadResume(c *C) {
 	h := crypto.SHA3_384.New()
 	h.Write([]byte("some data"))
 	sha3 := fmt.Sprintf("%x", h.Sum(nil))
-	err := download(context.TODO(), "foo", sha3, mockServer.URL, nil, theStore, buf, int64(len("some ")), nil)
+	err := download(context.TODO(), "foo", sha3, mockServer.URL, nil, theStore, buf, int64(len("some ")), nil, nil)
 	c.Check(err, IsNil)
 	c.Check(buf.String(), Equals, "some data")
 	c.Check(n, Equals, 1)
@@ -1149,7 +1364,7 @@ func (t *remoteRepoTestSuite) TestDownloadWithDelta(c *C) {
 	for _, testCase := range deltaTests {
 		testCase.info.Size = int64(len(testCase.expectedContent))
 		downloadIndex := 0
-		download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+		download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 			if testCase.downloads[downloadIndex].error {
 				downloadIndex++
 				return errors.New("Bang")
@@ -1167,7 +1382,7 @@ func (t *remoteRepoTestSuite) TestDownloadWithDelta(c *C) {
 		}
 
 		path := filepath.Join(c.MkDir(), "subdir", "downloaded-file")
-		err := t.store.Download(context.TODO(), "foo", path, &testCase.info, nil, nil)
+		err := t.store.Download(context.TODO(), "foo", path, &testCase.info, nil, nil, nil)
 
 		c.Assert(err, IsNil)
 		defer os.Remove(path)
@@ -1281,7 +1496,7 @@ func (t *remoteRepoTestSuite) TestDownloadDelta(c *C) {
 
 	for _, testCase := range downloadDeltaTests {
 		repo.deltaFormat = testCase.format
-		download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+		download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
 			expectedUser := t.user
 			if testCase.useLocalUser {
 				expectedUser = t.localUser
@@ -1312,7 +1527,7 @@ func (t *remoteRepoTestSuite) TestDownloadDelta(c *C) {
 			authedUser = nil
 		}
 
-		err = repo.downloadDelta("snapname", &testCase.info, w, nil, authedUser)
+		err = repo.downloadDelta(context.TODO(), "snapname", &testCase.info, w, nil, authedUser, nil)
 
 		if testCase.expectError {
 			c.Assert(err, NotNil)
@@ -1774,6 +1989,7 @@ const MockDetailsJSON = `{
     "support_url": "mailto:snappy-devel@lists.ubuntu.com",
     "title": "Hello World",
     "version": "6.3",
+    "default_track": "latest",
     "channel_maps_list": [
       {
         "track": "latest",
@@ -2130,6 +2346,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsAndChannels(c *C)
 	c.Assert(err, IsNil)
 	c.Assert(n, Equals, 1)
 	c.Check(result.Name(), Equals, "hello-world")
+	c.Check(result.DefaultTrack, Equals, "latest")
 	c.Check(result.Channels, DeepEquals, map[string]*snap.ChannelSnapInfo{
 		"latest/stable": {
 			Revision:    snap.R(1),
@@ -2248,6 +2465,36 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryStoreIDFromAuthContext(c
 	c.Check(result.Name(), Equals, "hello-world")
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryStoreIDFromSpec(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", detailsPathPattern)
+		storeID := r.Header.Get("X-Ubuntu-Store")
+		c.Check(storeID, Equals, "other-store-id")
+
+		w.WriteHeader(200)
+		io.WriteString(w, MockDetailsJSON)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	cfg := DefaultConfig()
+	cfg.StoreBaseURL = mockServerURL
+	repo := New(cfg, &testAuthContext{c: c, device: t.device, storeID: "my-brand-store-id"})
+	c.Assert(repo, NotNil)
+
+	// the actual test
+	spec := SnapSpec{
+		Name:    "hello-world",
+		Channel: "edge",
+		StoreID: "other-store-id",
+	}
+	result, err := repo.SnapInfo(spec, nil)
+	c.Assert(err, IsNil)
+	c.Check(result.Name(), Equals, "hello-world")
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRevision(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -2543,6 +2790,58 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreSectionsQuery(c *C) {
 	c.Check(sections, DeepEquals, []string{"featured", "database"})
 }
 
+func (t *remoteRepoTestSuite) TestConnectivityCheck(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "HEAD")
+		c.Check(r.URL.Path, Equals, "/")
+		n++
+		w.WriteHeader(404)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	// nothing listens on this one
+	downServer := httptest.NewServer(nil)
+	downServer.Close()
+
+	serverURL, _ := url.Parse(mockServer.URL + "/api/v1/snaps/")
+	downURL, _ := url.Parse(downServer.URL + "/api/v1/snaps/")
+	repo := New(&Config{
+		StoreBaseURL:      serverURL,
+		AssertionsBaseURL: downURL,
+	}, nil)
+
+	status, err := repo.ConnectivityCheck()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, map[string]bool{
+		serverURL.Host: true,
+		downURL.Host:   false,
+	})
+	c.Check(n, Equals, 1)
+}
+
+func (t *remoteRepoTestSuite) TestConnectivityCheckSameHost(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	serverURL, _ := url.Parse(mockServer.URL)
+	assertsURL, _ := url.Parse(mockServer.URL + "/assertions/")
+	repo := New(&Config{
+		StoreBaseURL:      serverURL,
+		AssertionsBaseURL: assertsURL,
+	}, nil)
+
+	status, err := repo.ConnectivityCheck()
+	c.Assert(err, IsNil)
+	c.Check(status, DeepEquals, map[string]bool{serverURL.Host: true})
+	c.Check(n, Equals, 1)
+}
+
 const mockNamesJSON = `
 {
   "_embedded": {
@@ -2659,6 +2958,60 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreFindPrivate(c *C) {
 	c.Check(err, Equals, ErrBadQuery)
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStorePublisherSnaps(c *C) {
+	restore := publisherSnapsCacheTTL
+	defer func() { publisherSnapsCacheTTL = restore }()
+
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", searchPath)
+		query := r.URL.Query()
+		c.Check(query.Get("publisher"), Equals, "canonical-id")
+		_, hasQ := query["q"]
+		c.Check(hasQ, Equals, false)
+
+		w.Header().Set("Content-Type", "application/hal+json")
+		w.WriteHeader(200)
+		io.WriteString(w, strings.Replace(MockSearchJSON, `"origin": "canonical",`,
+			`"origin": "canonical", "developer_validation": "verified", "developer_contact": "mailto:snaps@canonical.com",`, -1))
+
+		n++
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	serverURL, _ := url.Parse(mockServer.URL)
+	repo := New(&Config{StoreBaseURL: serverURL}, nil)
+	c.Assert(repo, NotNil)
+
+	snaps, err := repo.Find(&Search{Publisher: "canonical-id"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(snaps, HasLen, 1)
+	c.Check(snaps[0].PublisherValidation, Equals, "verified")
+	c.Check(snaps[0].PublisherContact, Equals, "mailto:snaps@canonical.com")
+	c.Check(n, Equals, 1)
+
+	// the names are cached
+	for i := 0; i < 2; i++ {
+		names, err := repo.PublisherSnaps("canonical-id", nil)
+		c.Assert(err, IsNil)
+		c.Check(names, DeepEquals, []string{"hello-world"})
+	}
+	c.Check(n, Equals, 2)
+
+	// until they expire
+	publisherSnapsCacheTTL = 0
+	repo = New(&Config{StoreBaseURL: serverURL}, nil)
+	for i := 0; i < 2; i++ {
+		_, err := repo.PublisherSnaps("canonical-id", nil)
+		c.Assert(err, IsNil)
+	}
+	c.Check(n, Equals, 4)
+
+	_, err = repo.PublisherSnaps("", nil)
+	c.Check(err, Equals, ErrEmptyQuery)
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreFindFailures(c *C) {
 	repo := New(&Config{StoreBaseURL: new(url.URL)}, nil)
 	_, err := repo.Find(&Search{Query: "foo:bar"}, nil)
@@ -2988,7 +3341,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidates(c *C
 			Revision: 1,
 			Epoch:    "0",
 		},
-	}, nil)
+	}, "", nil)
 
 	c.Assert(err, IsNil)
 	c.Assert(results, HasLen, 1)
@@ -3037,14 +3390,14 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidatesRetri
 		Channel:  "stable",
 		Revision: 1,
 		Epoch:    "0",
-	}}, nil)
+	}}, "", nil)
 	c.Assert(err, IsNil)
 	c.Assert(n, Equals, 4)
 	c.Assert(results, HasLen, 1)
 	c.Assert(results[0].Name, Equals, "hello-world")
 }
 
-func mockRFC(newRFC func(*Store, []*currentSnapJSON, *auth.UserState) ([]*snapDetails, error)) func() {
+func mockRFC(newRFC func(*Store, []*currentSnapJSON, string, *auth.UserState) ([]*snapDetails, error)) func() {
 	oldRFC := refreshForCandidates
 	refreshForCandidates = newRFC
 	return func() {
@@ -3053,7 +3406,7 @@ func mockRFC(newRFC func(*Store, []*currentSnapJSON, *auth.UserState) ([]*snapDe
 }
 
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefresh(c *C) {
-	defer mockRFC(func(_ *Store, currentSnaps []*currentSnapJSON, _ *auth.UserState) ([]*snapDetails, error) {
+	defer mockRFC(func(_ *Store, currentSnaps []*currentSnapJSON, _ string, _ *auth.UserState) ([]*snapDetails, error) {
 		c.Check(currentSnaps, DeepEquals, []*currentSnapJSON{{
 			SnapID:   helloWorldSnapID,
 			Channel:  "stable",
@@ -3088,7 +3441,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefresh(c *C) {
 }
 
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshLocalSnap(c *C) {
-	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ *auth.UserState) ([]*snapDetails, error) {
+	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ string, _ *auth.UserState) ([]*snapDetails, error) {
 		panic("unexpected call to refreshForCandidates")
 	})()
 
@@ -3104,7 +3457,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshLocalSnap(c
 
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshRFCError(c *C) {
 	anError := errors.New("ouchie")
-	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ *auth.UserState) ([]*snapDetails, error) {
+	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ string, _ *auth.UserState) ([]*snapDetails, error) {
 		return nil, anError
 	})()
 
@@ -3120,7 +3473,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshRFCError(c *
 }
 
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshEmptyResponse(c *C) {
-	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ *auth.UserState) ([]*snapDetails, error) {
+	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ string, _ *auth.UserState) ([]*snapDetails, error) {
 		return nil, nil
 	})()
 
@@ -3136,7 +3489,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshEmptyRespons
 }
 
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefreshNoUpdate(c *C) {
-	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ *auth.UserState) ([]*snapDetails, error) {
+	defer mockRFC(func(_ *Store, _ []*currentSnapJSON, _ string, _ *auth.UserState) ([]*snapDetails, error) {
 		return []*snapDetails{{
 			SnapID:   helloWorldDeveloperID,
 			Revision: 1,
@@ -3212,6 +3565,44 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefresh(c *C) {
 	c.Assert(results[0].Deltas, HasLen, 0)
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshPerStore(c *C) {
+	var seen []string
+	defer mockRFC(func(_ *Store, currentSnaps []*currentSnapJSON, storeID string, _ *auth.UserState) ([]*snapDetails, error) {
+		seen = append(seen, storeID)
+		var snapIDs []string
+		for _, cur := range currentSnaps {
+			snapIDs = append(snapIDs, cur.SnapID)
+		}
+		switch storeID {
+		case "":
+			c.Check(snapIDs, DeepEquals, []string{"snap-id-1", "snap-id-3"})
+		case "other-store-id":
+			c.Check(snapIDs, DeepEquals, []string{"snap-id-2"})
+		default:
+			c.Fatalf("unexpected store id %q", storeID)
+		}
+		return []*snapDetails{{
+			Name:     "snap-from-" + storeID,
+			Revision: 2,
+			SnapID:   snapIDs[0],
+		}}, nil
+	})()
+
+	repo := New(nil, &testAuthContext{c: c, device: t.device})
+	c.Assert(repo, NotNil)
+
+	results, err := repo.ListRefresh([]*RefreshCandidate{
+		{SnapID: "snap-id-1", Revision: snap.R(1)},
+		{SnapID: "snap-id-2", Revision: snap.R(1), StoreID: "other-store-id"},
+		{SnapID: "snap-id-3", Revision: snap.R(1)},
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Check(seen, DeepEquals, []string{"", "other-store-id"})
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].SnapID, Equals, "snap-id-1")
+	c.Check(results[1].SnapID, Equals, "snap-id-2")
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshDefaultChannelIsStable(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assertRequest(c, r, "POST", metadataPath)
@@ -3349,7 +3740,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreUnexpectedEOFhandling(c *C) {
 			Channel:  "stable",
 			Revision: 1,
 			Epoch:    "0",
-		}}, nil)
+		}}, "", nil)
 		return err
 	}
 
@@ -3395,7 +3786,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidatesEOF(c
 		Channel:  "stable",
 		Revision: 1,
 		Epoch:    "0",
-	}}, nil)
+	}}, "", nil)
 	c.Assert(err, NotNil)
 	c.Assert(err, ErrorMatches, `^Post http://127.0.0.1:.*?/metadata: EOF$`)
 	c.Assert(n, Equals, 5)
@@ -3428,7 +3819,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidatesUnaut
 		Channel:  "stable",
 		Revision: 24,
 		Epoch:    "0",
-	}}, nil)
+	}}, "", nil)
 	c.Assert(n, Equals, 1)
 	c.Assert(err, ErrorMatches, `cannot query the store for updates: got unexpected HTTP status code 401 via POST to "http://.*?/metadata"`)
 }
@@ -3448,7 +3839,7 @@ func (t *remoteRepoTestSuite) TestRefreshForCandidatesFailOnDNS(c *C) {
 		Channel:  "stable",
 		Revision: 24,
 		Epoch:    "0",
-	}}, nil)
+	}}, "", nil)
 	// the error differs depending on whether a proxy is in use (e.g. on travis), so don't inspect error message
 	c.Assert(err, NotNil)
 }
@@ -3476,7 +3867,7 @@ func (t *remoteRepoTestSuite) TestRefreshForCandidates500(c *C) {
 		Channel:  "stable",
 		Revision: 24,
 		Epoch:    "0",
-	}}, nil)
+	}}, "", nil)
 	c.Assert(err, ErrorMatches, `cannot query the store for updates: got unexpected HTTP status code 500 via POST to "http://.*?/metadata"`)
 	c.Assert(n, Equals, 5)
 }
@@ -3505,7 +3896,7 @@ func (t *remoteRepoTestSuite) TestRefreshForCandidates500DurationExceeded(c *C)
 		Channel:  "stable",
 		Revision: 24,
 		Epoch:    "0",
-	}}, nil)
+	}}, "", nil)
 	c.Assert(err, ErrorMatches, `cannot query the store for updates: got unexpected HTTP status code 500 via POST to "http://.*?/metadata"`)
 	c.Assert(n, Equals, 1)
 }
@@ -3700,7 +4091,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDefaultsDeltasOnClassicOn
 			Channel:  "stable",
 			Revision: 1,
 			Epoch:    "0",
-		}}, nil)
+		}}, "", nil)
 	}
 }
 
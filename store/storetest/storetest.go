@@ -49,6 +49,10 @@ func (Store) Find(*store.Search, *auth.UserState) ([]*snap.Info, error) {
 	panic("Store.Find not expected")
 }
 
+func (Store) PublisherSnaps(string, *auth.UserState) ([]string, error) {
+	panic("Store.PublisherSnaps not expected")
+}
+
 func (Store) LookupRefresh(*store.RefreshCandidate, *auth.UserState) (*snap.Info, error) {
 	panic("Store.LookupRefresh not expected")
 }
@@ -57,10 +61,14 @@ func (Store) ListRefresh([]*store.RefreshCandidate, *auth.UserState) ([]*snap.In
 	panic("Store.ListRefresh not expected")
 }
 
-func (Store) Download(context.Context, string, string, *snap.DownloadInfo, progress.Meter, *auth.UserState) error {
+func (Store) Download(context.Context, string, string, *snap.DownloadInfo, progress.Meter, *auth.UserState, *store.DownloadOptions) error {
 	panic("Store.Download not expected")
 }
 
+func (Store) ConnectivityCheck() (map[string]bool, error) {
+	panic("Store.ConnectivityCheck not expected")
+}
+
 func (Store) SuggestedCurrency() string {
 	panic("Store.SuggestedCurrency not expected")
 }
@@ -22,6 +22,7 @@ package strutil
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -61,6 +62,43 @@ func SizeToStr(size int64) string {
 	panic("SizeToStr got a size bigger than math.MaxInt64")
 }
 
+// ParseByteSize parses a size such as "512kB" or "2MB", using the same
+// decimal suffixes as SizeToStr, into a number of bytes. A number
+// without a suffix is taken as bytes.
+func ParseByteSize(inp string) (int64, error) {
+	suffixes := []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+
+	idx := strings.IndexFunc(inp, func(r rune) bool { return r < '0' || r > '9' })
+	if idx == -1 {
+		idx = len(inp)
+	}
+	num, suffix := inp[:idx], inp[idx:]
+	if num == "" {
+		return 0, fmt.Errorf("cannot parse %q: expected a non-negative number of bytes", inp)
+	}
+	mul := int64(1)
+	if suffix != "" {
+		i := 0
+		for ; i < len(suffixes); i++ {
+			if suffixes[i] == suffix {
+				break
+			}
+		}
+		if i == len(suffixes) {
+			return 0, fmt.Errorf("cannot parse %q: unknown unit %q", inp, suffix)
+		}
+		for ; i > 0; i-- {
+			mul *= 1000
+		}
+	}
+
+	val, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || val > math.MaxInt64/mul {
+		return 0, fmt.Errorf("cannot parse %q: size is too big", inp)
+	}
+	return val * mul, nil
+}
+
 // Quoted formats a slice of strings to a quoted list of
 // comma-separated strings, e.g. `"snap1", "snap2"`
 func Quoted(names []string) string {
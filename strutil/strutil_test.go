@@ -83,6 +83,39 @@ func (ts *strutilSuite) TestSizeToStr(c *check.C) {
 	}
 }
 
+func (ts *strutilSuite) TestParseByteSize(c *check.C) {
+	for _, t := range []struct {
+		str  string
+		size int64
+		err  string
+	}{
+		{"0", 0, ""},
+		{"400", 400, ""},
+		{"400B", 400, ""},
+		{"1kB", 1000, ""},
+		{"900kB", 900 * 1000, ""},
+		{"20MB", 20 * 1000 * 1000, ""},
+		{"31GB", 31 * 1000 * 1000 * 1000, ""},
+		{"9EB", 9 * 1000 * 1000 * 1000 * 1000 * 1000 * 1000, ""},
+		{"", 0, `cannot parse "": expected a non-negative number of bytes`},
+		{"kB", 0, `cannot parse "kB": expected a non-negative number of bytes`},
+		{"-1", 0, `cannot parse "-1": expected a non-negative number of bytes`},
+		{"1.5MB", 0, `cannot parse "1.5MB": unknown unit ".5MB"`},
+		{"1KB", 0, `cannot parse "1KB": unknown unit "KB"`},
+		{"1MiB", 0, `cannot parse "1MiB": unknown unit "MiB"`},
+		{"99999999999999999999", 0, `cannot parse "99999999999999999999": size is too big`},
+		{"10EB", 0, `cannot parse "10EB": size is too big`},
+	} {
+		size, err := strutil.ParseByteSize(t.str)
+		if t.err == "" {
+			c.Check(err, check.IsNil, check.Commentf("%q", t.str))
+			c.Check(size, check.Equals, t.size, check.Commentf("%q", t.str))
+		} else {
+			c.Check(err, check.ErrorMatches, t.err, check.Commentf("%q", t.str))
+		}
+	}
+}
+
 func (ts *strutilSuite) TestWordWrap(c *check.C) {
 	for _, t := range []struct {
 		in  string
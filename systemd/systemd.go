@@ -110,6 +110,7 @@ type Systemd interface {
 	Status(services ...string) ([]*ServiceStatus, error)
 	LogReader(services []string, n string, follow bool) (io.ReadCloser, error)
 	WriteMountUnitFile(name, what, where, fstype string) (string, error)
+	WriteVerityMountUnitFile(name, what, where, hashFile, rootHash string) (string, error)
 }
 
 // A Log is a single entry in the systemd journal
@@ -426,6 +427,22 @@ func (s *systemd) WriteMountUnitFile(name, what, where, fstype string) (string,
 		fstype = "fuse.squashfuse"
 	}
 
+	return writeMountUnitFile(name, what, where, fstype, options)
+}
+
+// WriteVerityMountUnitFile writes the unit mounting the given squashfs
+// file with dm-verity enforcement, from the hash tree in hashFile, the
+// root hash of which is given hex encoded. Reading blocks of the file that
+// do not match fails.
+func (s *systemd) WriteVerityMountUnitFile(name, what, where, hashFile, rootHash string) (string, error) {
+	if useFuse() {
+		return "", fmt.Errorf("cannot mount %q with dm-verity enforcement using squashfuse", what)
+	}
+	options := []string{"nodev", "ro", "verity.hashdevice=" + hashFile, "verity.roothash=" + rootHash}
+	return writeMountUnitFile(name, what, where, "squashfs", options)
+}
+
+func writeMountUnitFile(name, what, where, fstype string, options []string) (string, error) {
 	c := fmt.Sprintf(`[Unit]
 Description=Mount unit for %s
 
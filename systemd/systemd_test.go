@@ -488,6 +488,33 @@ WantedBy=multi-user.target
 `, snapDir))
 }
 
+func (s *SystemdTestSuite) TestWriteVerityMountUnit(c *C) {
+	mockSnapPath := filepath.Join(c.MkDir(), "/var/lib/snappy/snaps/foo_1.0.snap")
+	err := os.MkdirAll(filepath.Dir(mockSnapPath), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(mockSnapPath, nil, 0644)
+	c.Assert(err, IsNil)
+
+	mountUnitName, err := New("", nil).WriteVerityMountUnitFile("foo", mockSnapPath, "/apps/foo/1.0", mockSnapPath+".verity", "abcd")
+	c.Assert(err, IsNil)
+	defer os.Remove(mountUnitName)
+
+	mount, err := ioutil.ReadFile(filepath.Join(dirs.SnapServicesDir, mountUnitName))
+	c.Assert(err, IsNil)
+	c.Assert(string(mount), Equals, fmt.Sprintf(`[Unit]
+Description=Mount unit for foo
+
+[Mount]
+What=%[1]s
+Where=/apps/foo/1.0
+Type=squashfs
+Options=nodev,ro,verity.hashdevice=%[1]s.verity,verity.roothash=abcd
+
+[Install]
+WantedBy=multi-user.target
+`, mockSnapPath))
+}
+
 func (s *SystemdTestSuite) TestFuseInContainer(c *C) {
 	if !osutil.FileExists("/dev/fuse") {
 		c.Skip("No /dev/fuse on the system")
@@ -584,3 +611,35 @@ func (s *SystemdTestSuite) TestJctl(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(args, DeepEquals, []string{"-o", "json", "-n", "99", "--no-pager", "-f", "-u", "foo", "-u", "bar", "-u", "baz"})
 }
+
+func (s *SystemdTestSuite) TestScheduleStart(c *C) {
+	var runArgs []string
+	restore := MockSystemdRun(func(args ...string) ([]byte, error) {
+		runArgs = args
+		return nil, nil
+	})
+	defer restore()
+	// the timer may not exist
+	s.errors = []error{&Error{}}
+
+	t := time.Date(2018, 4, 12, 16, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	err := ScheduleStart("snapd-wakeup", "snapd.service", t)
+	c.Assert(err, IsNil)
+	c.Check(s.argses, DeepEquals, [][]string{{"stop", "snapd-wakeup.timer"}})
+	c.Check(runArgs, DeepEquals, []string{
+		"--unit=snapd-wakeup",
+		"--on-calendar=2018-04-12 14:30:00 UTC",
+		"--timer-property=AccuracySec=1min",
+		"systemctl", "start", "--no-block", "snapd.service",
+	})
+}
+
+func (s *SystemdTestSuite) TestScheduleStartError(c *C) {
+	restore := MockSystemdRun(func(args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	defer restore()
+
+	err := ScheduleStart("snapd-wakeup", "snapd.service", time.Now())
+	c.Check(err, ErrorMatches, "cannot schedule start of snapd.service: boom")
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// systemdRunCmd calls systemd-run with the given args.
+var systemdRunCmd = func(args ...string) ([]byte, error) {
+	bs, err := exec.Command("systemd-run", args...).CombinedOutput()
+	if err != nil {
+		return nil, osutil.OutputErr(bs, err)
+	}
+	return bs, nil
+}
+
+// MockSystemdRun mocks the calls to systemd-run.
+func MockSystemdRun(f func(args ...string) ([]byte, error)) func() {
+	oldSystemdRunCmd := systemdRunCmd
+	systemdRunCmd = f
+	return func() {
+		systemdRunCmd = oldSystemdRunCmd
+	}
+}
+
+// ScheduleStart sets up a transient timer, with the given name, to start
+// the given service at the given time, replacing the previous timer of
+// that name if any. The time is on the realtime clock so that it is kept
+// when the system is suspended meanwhile.
+func ScheduleStart(timerName, serviceName string, t time.Time) error {
+	// the timer remains once elapsed, and systemd-run cannot replace it
+	systemctlCmd("stop", timerName+".timer")
+
+	_, err := systemdRunCmd(
+		"--unit="+timerName,
+		"--on-calendar="+t.UTC().Format("2006-01-02 15:04:05 UTC"),
+		"--timer-property=AccuracySec=1min",
+		"systemctl", "start", "--no-block", serviceName)
+	if err != nil {
+		return fmt.Errorf("cannot schedule start of %s: %v", serviceName, err)
+	}
+	return nil
+}
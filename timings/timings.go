@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package timings records how long the different steps of a
+// long-running operation, like the startup of snapd, take.
+package timings
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Span is a measured step.
+type Span struct {
+	Label string `json:"label"`
+	// Start is the offset of the beginning of the step from the
+	// creation of the recorder.
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Recorder collects spans, it can be used from different goroutines.
+type Recorder struct {
+	mu    sync.Mutex
+	start time.Time
+	spans []Span
+}
+
+var timeNow = time.Now
+
+// New returns a new recorder, the start of spans is measured
+// relative to the time of its creation.
+func New() *Recorder {
+	return &Recorder{start: timeNow()}
+}
+
+// StartSpan starts measuring a step with the given label, the
+// returned function must be called once the step is done.
+func (r *Recorder) StartSpan(label string) (done func()) {
+	start := timeNow()
+	return func() {
+		r.Record(label, start, timeNow().Sub(start))
+	}
+}
+
+// Record records a step with the given label that began at start and
+// took the given duration.
+func (r *Recorder) Record(label string, start time.Time, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, Span{
+		Label:    label,
+		Start:    start.Sub(r.start),
+		Duration: duration,
+	})
+}
+
+type byStart []Span
+
+func (s byStart) Len() int           { return len(s) }
+func (s byStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byStart) Less(i, j int) bool { return s[i].Start < s[j].Start }
+
+// Spans returns the spans recorded so far, ordered by when they started.
+func (r *Recorder) Spans() []Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := make([]Span, len(r.spans))
+	copy(spans, r.spans)
+	sort.Stable(byStart(spans))
+	return spans
+}
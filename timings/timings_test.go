@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timings_test
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/timings"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type timingsSuite struct{}
+
+var _ = Suite(&timingsSuite{})
+
+func (s *timingsSuite) TestSpans(c *C) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	restore := timings.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	r := timings.New()
+
+	now = now.Add(time.Second)
+	doneOuter := r.StartSpan("outer")
+	now = now.Add(time.Second)
+	doneInner := r.StartSpan("inner")
+	now = now.Add(500 * time.Millisecond)
+	doneInner()
+	now = now.Add(time.Second)
+	doneOuter()
+
+	r.Record("early", now.Add(-3*time.Second), time.Second)
+
+	c.Check(r.Spans(), DeepEquals, []timings.Span{
+		{Label: "early", Start: 500 * time.Millisecond, Duration: time.Second},
+		{Label: "outer", Start: time.Second, Duration: 2500 * time.Millisecond},
+		{Label: "inner", Start: 2 * time.Second, Duration: 500 * time.Millisecond},
+	})
+}
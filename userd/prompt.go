@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package userd
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+)
+
+// The labels of the buttons of the prompts besides the ok ("Allow
+// once") and cancel ("Deny once") ones, which zenity prints when they
+// are pressed.
+const (
+	alwaysAllowLabel = "Always allow"
+	alwaysDenyLabel  = "Always deny"
+)
+
+var promptPollInterval = 5 * time.Second
+
+// PromptAgent asks the user of the desktop session about the accesses
+// of snaps snapd waits for a decision on, and relays the answers.
+type PromptAgent struct {
+	cli *client.Client
+	// asked has the ids of the requests already asked about.
+	asked map[string]bool
+}
+
+// NewPromptAgent returns a prompt agent talking to snapd with the given
+// client.
+func NewPromptAgent(cli *client.Client) *PromptAgent {
+	return &PromptAgent{
+		cli:   cli,
+		asked: make(map[string]bool),
+	}
+}
+
+func (a *PromptAgent) run(dying <-chan struct{}) error {
+	ticker := time.NewTicker(promptPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := a.HandleRequests(); err != nil {
+			logger.Debugf("cannot handle prompt requests: %v", err)
+		}
+		select {
+		case <-dying:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// HandleRequests asks the user about the requests waiting for an answer
+// that were not asked about yet, and sends the answers to snapd.
+func (a *PromptAgent) HandleRequests() error {
+	requests, err := a.cli.PromptRequests()
+	if err != nil {
+		return err
+	}
+	waiting := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		waiting[req.ID] = true
+		if a.asked[req.ID] {
+			continue
+		}
+		a.asked[req.ID] = true
+
+		reply, err := askUser(req)
+		if err != nil {
+			logger.Noticef("cannot ask about access of snap %q to %q: %v", req.Snap, req.Path, err)
+			continue
+		}
+		if _, err := a.cli.ReplyToPrompt(req.ID, reply); err != nil {
+			logger.Noticef("cannot reply to prompt about access of snap %q to %q: %v", req.Snap, req.Path, err)
+		}
+	}
+	// forget about the requests answered meanwhile
+	for id := range a.asked {
+		if !waiting[id] {
+			delete(a.asked, id)
+		}
+	}
+	return nil
+}
+
+// askUser asks the user about the request with a zenity dialog.
+func askUser(req *client.PromptRequest) (*client.PromptReply, error) {
+	text := fmt.Sprintf("Allow snap %q to %s %q through the %s interface?", req.Snap, req.Permission, req.Path, req.Interface)
+	cmd := exec.Command("zenity", "--question",
+		"--title=Snap access request",
+		"--text="+text,
+		"--ok-label=Allow once",
+		"--cancel-label=Deny once",
+		"--extra-button="+alwaysAllowLabel,
+		"--extra-button="+alwaysDenyLabel)
+	output, err := cmd.Output()
+	if err == nil {
+		return &client.PromptReply{Outcome: "allow", Lifespan: "single"}, nil
+	}
+	if _, ok := err.(*exec.ExitError); !ok {
+		return nil, err
+	}
+	switch strings.TrimSpace(string(output)) {
+	case alwaysAllowLabel:
+		return &client.PromptReply{Outcome: "allow", Lifespan: "forever"}, nil
+	case alwaysDenyLabel:
+		return &client.PromptReply{Outcome: "deny", Lifespan: "forever"}, nil
+	}
+	// denied or dismissed
+	return &client.PromptReply{Outcome: "deny", Lifespan: "single"}, nil
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package userd_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/userd"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type promptSuite struct {
+	server   *httptest.Server
+	requests []*client.PromptRequest
+	replies  map[string]*client.PromptReply
+	zenity   *testutil.MockCmd
+	agent    *userd.PromptAgent
+}
+
+var _ = Suite(&promptSuite{})
+
+func (s *promptSuite) SetUpTest(c *C) {
+	s.requests = nil
+	s.replies = make(map[string]*client.PromptReply)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v2/prompting/requests":
+			json.NewEncoder(w).Encode(map[string]interface{}{"type": "sync", "result": s.requests})
+		case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/v2/prompting/requests/"):
+			var reply client.PromptReply
+			c.Assert(json.NewDecoder(r.Body).Decode(&reply), IsNil)
+			s.replies[strings.TrimPrefix(r.URL.Path, "/v2/prompting/requests/")] = &reply
+			fmt.Fprintln(w, `{"type": "sync", "result": null}`)
+		default:
+			c.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	s.zenity = testutil.MockCommand(c, "zenity", `
+case "$3" in
+    *allow-once*) exit 0;;
+    *allow-always*) echo "Always allow"; exit 1;;
+    *deny-always*) echo "Always deny"; exit 1;;
+    *) exit 1;;
+esac`)
+	s.agent = userd.NewPromptAgent(client.New(&client.Config{BaseURL: s.server.URL}))
+}
+
+func (s *promptSuite) TearDownTest(c *C) {
+	s.server.Close()
+	s.zenity.Restore()
+}
+
+func (s *promptSuite) TestHandleRequests(c *C) {
+	for i, path := range []string{"/allow-once", "/allow-always", "/deny-always", "/deny-once"} {
+		s.requests = append(s.requests, &client.PromptRequest{
+			ID:         fmt.Sprint(i + 1),
+			Snap:       "foo",
+			Interface:  "home",
+			Path:       path,
+			Permission: "read",
+		})
+	}
+
+	c.Assert(s.agent.HandleRequests(), IsNil)
+	c.Check(s.replies, DeepEquals, map[string]*client.PromptReply{
+		"1": {Outcome: "allow", Lifespan: "single"},
+		"2": {Outcome: "allow", Lifespan: "forever"},
+		"3": {Outcome: "deny", Lifespan: "forever"},
+		"4": {Outcome: "deny", Lifespan: "single"},
+	})
+	calls := s.zenity.Calls()
+	c.Assert(calls, HasLen, 4)
+	c.Check(calls[0], DeepEquals, []string{
+		"zenity", "--question",
+		"--title=Snap access request",
+		`--text=Allow snap "foo" to read "/allow-once" through the home interface?`,
+		"--ok-label=Allow once",
+		"--cancel-label=Deny once",
+		"--extra-button=Always allow",
+		"--extra-button=Always deny",
+	})
+}
+
+func (s *promptSuite) TestHandleRequestsAsksOnce(c *C) {
+	s.requests = []*client.PromptRequest{{ID: "1", Snap: "foo", Interface: "camera", Path: "/dev/video0", Permission: "read"}}
+
+	// the request is still waiting, as if the reply didn't make it
+	c.Assert(s.agent.HandleRequests(), IsNil)
+	c.Assert(s.agent.HandleRequests(), IsNil)
+	c.Check(s.zenity.Calls(), HasLen, 1)
+
+	// once gone, a request with the same id is a new one
+	s.requests = nil
+	c.Assert(s.agent.HandleRequests(), IsNil)
+	s.requests = []*client.PromptRequest{{ID: "1", Snap: "foo", Interface: "camera", Path: "/dev/video0", Permission: "read"}}
+	c.Assert(s.agent.HandleRequests(), IsNil)
+	c.Check(s.zenity.Calls(), HasLen, 2)
+}
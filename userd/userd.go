@@ -27,6 +27,7 @@ import (
 	"github.com/godbus/dbus/introspect"
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 )
 
@@ -101,6 +102,11 @@ func (ud *Userd) Start() {
 		}
 		return nil
 	})
+
+	agent := NewPromptAgent(client.New(nil))
+	ud.tomb.Go(func() error {
+		return agent.run(ud.tomb.Dying())
+	})
 }
 
 func (ud *Userd) Stop() error {